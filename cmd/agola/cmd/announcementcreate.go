@@ -0,0 +1,85 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	gwapitypes "agola.io/agola/services/gateway/api/types"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdAnnouncementCreate = &cobra.Command{
+	Use:   "create",
+	Short: "create an announcement",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := announcementCreate(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type announcementCreateOptions struct {
+	message    string
+	severity   string
+	expireTime string
+}
+
+var announcementCreateOpts announcementCreateOptions
+
+func init() {
+	flags := cmdAnnouncementCreate.Flags()
+
+	flags.StringVar(&announcementCreateOpts.message, "message", "", "announcement message")
+	flags.StringVar(&announcementCreateOpts.severity, "severity", "info", "announcement severity (info, warning, critical)")
+	flags.StringVar(&announcementCreateOpts.expireTime, "expire-time", "", "announcement expire time (RFC3339 format)")
+
+	if err := cmdAnnouncementCreate.MarkFlagRequired("message"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdAnnouncement.AddCommand(cmdAnnouncementCreate)
+}
+
+func announcementCreate(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	req := &gwapitypes.CreateAnnouncementRequest{
+		Message:  announcementCreateOpts.message,
+		Severity: announcementCreateOpts.severity,
+	}
+
+	if announcementCreateOpts.expireTime != "" {
+		expireTime, err := time.Parse(time.RFC3339, announcementCreateOpts.expireTime)
+		if err != nil {
+			return errors.Wrapf(err, "cannot parse expire time %q", announcementCreateOpts.expireTime)
+		}
+		req.ExpireTime = &expireTime
+	}
+
+	log.Info().Msgf("creating announcement")
+	announcement, _, err := gwclient.CreateAnnouncement(context.TODO(), req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create announcement")
+	}
+	log.Info().Msgf("announcement %q created", announcement.ID)
+
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdAnnouncementExpire = &cobra.Command{
+	Use:   "expire",
+	Short: "expire an announcement",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := announcementExpire(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type announcementExpireOptions struct {
+	announcementID string
+}
+
+var announcementExpireOpts announcementExpireOptions
+
+func init() {
+	flags := cmdAnnouncementExpire.Flags()
+
+	flags.StringVar(&announcementExpireOpts.announcementID, "announcement-id", "", "announcement id")
+
+	if err := cmdAnnouncementExpire.MarkFlagRequired("announcement-id"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdAnnouncement.AddCommand(cmdAnnouncementExpire)
+}
+
+func announcementExpire(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	log.Info().Msgf("expiring announcement %q", announcementExpireOpts.announcementID)
+	if _, _, err := gwclient.ExpireAnnouncement(context.TODO(), announcementExpireOpts.announcementID); err != nil {
+		return errors.Wrapf(err, "failed to expire announcement")
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdAnnouncementList = &cobra.Command{
+	Use:   "list",
+	Short: "list announcements",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := announcementList(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type announcementListOptions struct {
+	all bool
+}
+
+var announcementListOpts announcementListOptions
+
+func init() {
+	flags := cmdAnnouncementList.Flags()
+
+	flags.BoolVar(&announcementListOpts.all, "all", false, "list also expired announcements (admin only)")
+
+	cmdAnnouncement.AddCommand(cmdAnnouncementList)
+}
+
+func announcementList(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	announcements, _, err := gwclient.GetAnnouncements(context.TODO(), announcementListOpts.all)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get announcements")
+	}
+
+	out, err := json.MarshalIndent(announcements, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	os.Stdout.Write(out)
+
+	return nil
+}
@@ -56,6 +56,11 @@ type directRunStartOptions struct {
 
 	vars     []string
 	varFiles []string
+
+	runNames  []string
+	taskNames []string
+
+	diffBase string
 }
 
 var directRunStartOpts directRunStartOptions
@@ -71,6 +76,9 @@ func init() {
 	flags.StringArrayVar(&directRunStartOpts.prRefRegexes, "pull-request-ref-regexes", []string{`refs/pull/(\d+)/head`, `refs/merge-requests/(\d+)/head`}, `regular expression to determine if a ref is a pull request`)
 	flags.StringArrayVar(&directRunStartOpts.vars, "var", []string{}, `list of variables (name=value). This option can be repeated multiple times`)
 	flags.StringArrayVar(&directRunStartOpts.varFiles, "var-file", []string{}, `yaml file containing the variables as a yaml/json map. This option can be repeated multiple times`)
+	flags.StringArrayVar(&directRunStartOpts.runNames, "run", []string{}, `name of the run to execute (default to all the runs). This option can be repeated multiple times`)
+	flags.StringArrayVar(&directRunStartOpts.taskNames, "task", []string{}, `name of the task to execute, its dependencies will be automatically included (default to all the tasks). This option can be repeated multiple times`)
+	flags.StringVar(&directRunStartOpts.diffBase, "diff-base", "", `git revision (i.e. "origin/master") the pushed changes are compared to to get the changed files matched by the when paths conditions. When empty the paths conditions aren't evaluated`)
 
 	cmdDirectRun.AddCommand(cmdDirectRunStart)
 }
@@ -183,21 +191,30 @@ func directRunStart(cmd *cobra.Command, args []string) error {
 		return errors.WithStack(err)
 	}
 
+	var changedFiles []string
+	if directRunStartOpts.diffBase != "" {
+		// compare with the merge base like done for pull requests
+		changedFiles, err = git.OutputLines(context.Background(), nil, "diff", "--name-only", directRunStartOpts.diffBase+"..."+commitSHA)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get the files changed from %q", directRunStartOpts.diffBase)
+		}
+	}
+
 	log.Info().Msgf("pushing branch")
 	repoPath := fmt.Sprintf("%s/%s", user.ID, repoUUID)
 	repoURL := fmt.Sprintf("%s/repos/%s/%s.git", gatewayURL, user.ID, repoUUID)
 
 	// push to a branch with default branch refs "refs/heads/branch"
 	if branch != "" {
-		if err := gitsave.GitPush("", repoURL, fmt.Sprintf("%s:refs/heads/%s", path.Join(gs.RefsPrefix(), localBranch), branch)); err != nil {
+		if err := gitsave.GitPush("", repoURL, fmt.Sprintf("%s:refs/heads/%s", path.Join(gs.RefsPrefix(), localBranch), branch), token); err != nil {
 			return errors.WithStack(err)
 		}
 	} else if tag != "" {
-		if err := gitsave.GitPush("", repoURL, fmt.Sprintf("%s:refs/tags/%s", path.Join(gs.RefsPrefix(), localBranch), tag)); err != nil {
+		if err := gitsave.GitPush("", repoURL, fmt.Sprintf("%s:refs/tags/%s", path.Join(gs.RefsPrefix(), localBranch), tag), token); err != nil {
 			return errors.WithStack(err)
 		}
 	} else if ref != "" {
-		if err := gitsave.GitPush("", repoURL, fmt.Sprintf("%s:%s", path.Join(gs.RefsPrefix(), localBranch), ref)); err != nil {
+		if err := gitsave.GitPush("", repoURL, fmt.Sprintf("%s:%s", path.Join(gs.RefsPrefix(), localBranch), ref), token); err != nil {
 			return errors.WithStack(err)
 		}
 	}
@@ -213,6 +230,9 @@ func directRunStart(cmd *cobra.Command, args []string) error {
 		Message:               message,
 		PullRequestRefRegexes: directRunStartOpts.prRefRegexes,
 		Variables:             variables,
+		RunNames:              directRunStartOpts.runNames,
+		TaskNames:             directRunStartOpts.taskNames,
+		ChangedFiles:          changedFiles,
 	}
 	if _, err := gwclient.UserCreateRun(context.TODO(), req); err != nil {
 		return errors.WithStack(err)
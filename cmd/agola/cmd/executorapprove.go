@@ -0,0 +1,67 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdExecutorApprove = &cobra.Command{
+	Use:   "approve",
+	Short: "approve an executor",
+	Long: `approve an executor
+
+When the runservice requires executors approval, no tasks are scheduled on a new executor until it is approved.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := executorApprove(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type executorApproveOptions struct {
+	executorID string
+}
+
+var executorApproveOpts executorApproveOptions
+
+func init() {
+	flags := cmdExecutorApprove.Flags()
+
+	flags.StringVar(&executorApproveOpts.executorID, "executor-id", "", "executor id")
+
+	if err := cmdExecutorApprove.MarkFlagRequired("executor-id"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdExecutor.AddCommand(cmdExecutorApprove)
+}
+
+func executorApprove(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	log.Info().Msgf("approving executor %q", executorApproveOpts.executorID)
+	if _, err := gwclient.ApproveExecutor(context.TODO(), executorApproveOpts.executorID); err != nil {
+		return errors.Wrapf(err, "failed to approve executor")
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdExecutorBan = &cobra.Command{
+	Use:   "ban",
+	Short: "ban an executor",
+	Long: `ban an executor
+
+A banned executor cannot fetch or update its tasks and no new tasks are scheduled on it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := executorBan(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type executorBanOptions struct {
+	executorID string
+}
+
+var executorBanOpts executorBanOptions
+
+func init() {
+	flags := cmdExecutorBan.Flags()
+
+	flags.StringVar(&executorBanOpts.executorID, "executor-id", "", "executor id")
+
+	if err := cmdExecutorBan.MarkFlagRequired("executor-id"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdExecutor.AddCommand(cmdExecutorBan)
+}
+
+func executorBan(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	log.Info().Msgf("banning executor %q", executorBanOpts.executorID)
+	if _, err := gwclient.BanExecutor(context.TODO(), executorBanOpts.executorID); err != nil {
+		return errors.Wrapf(err, "failed to ban executor")
+	}
+
+	return nil
+}
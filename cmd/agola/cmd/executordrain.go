@@ -0,0 +1,67 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdExecutorDrain = &cobra.Command{
+	Use:   "drain",
+	Short: "drain an executor",
+	Long: `drain an executor
+
+No new tasks will be scheduled on a draining executor. When its active tasks are finished the executor deregisters itself and can be safely stopped.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := executorDrain(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type executorDrainOptions struct {
+	executorID string
+}
+
+var executorDrainOpts executorDrainOptions
+
+func init() {
+	flags := cmdExecutorDrain.Flags()
+
+	flags.StringVar(&executorDrainOpts.executorID, "executor-id", "", "executor id")
+
+	if err := cmdExecutorDrain.MarkFlagRequired("executor-id"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdExecutor.AddCommand(cmdExecutorDrain)
+}
+
+func executorDrain(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	log.Info().Msgf("draining executor %q", executorDrainOpts.executorID)
+	if _, err := gwclient.DrainExecutor(context.TODO(), executorDrainOpts.executorID); err != nil {
+		return errors.Wrapf(err, "failed to drain executor")
+	}
+
+	return nil
+}
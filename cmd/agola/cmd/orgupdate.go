@@ -0,0 +1,78 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"agola.io/agola/internal/errors"
+	gwapitypes "agola.io/agola/services/gateway/api/types"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdOrgUpdate = &cobra.Command{
+	Use:   "update",
+	Short: "update an organization",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := orgUpdate(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type orgUpdateOptions struct {
+	name            string
+	maxRunningRuns  int
+	maxRunningTasks int
+}
+
+var orgUpdateOpts orgUpdateOptions
+
+func init() {
+	flags := cmdOrgUpdate.Flags()
+
+	flags.StringVarP(&orgUpdateOpts.name, "name", "n", "", "organization name")
+	flags.IntVar(&orgUpdateOpts.maxRunningRuns, "max-running-runs", 0, `max number of concurrently running organization runs (0 means no limit)`)
+	flags.IntVar(&orgUpdateOpts.maxRunningTasks, "max-running-tasks", 0, `max number of concurrently running organization run tasks (0 means no limit)`)
+
+	if err := cmdOrgUpdate.MarkFlagRequired("name"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdOrg.AddCommand(cmdOrgUpdate)
+}
+
+func orgUpdate(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	req := &gwapitypes.UpdateOrgRequest{
+		ConcurrencyLimits: gwapitypes.ConcurrencyLimits{
+			MaxRunningRuns:  orgUpdateOpts.maxRunningRuns,
+			MaxRunningTasks: orgUpdateOpts.maxRunningTasks,
+		},
+	}
+
+	log.Info().Msgf("updating organization %q", orgUpdateOpts.name)
+	org, _, err := gwclient.UpdateOrg(context.TODO(), orgUpdateOpts.name, req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update organization")
+	}
+	log.Info().Msgf("organization %q updated, ID: %q", org.Name, org.ID)
+
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdOrgUsage = &cobra.Command{
+	Use:   "usage",
+	Short: "export organization projects usage",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := orgUsage(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type orgUsageOptions struct {
+	name   string
+	start  string
+	end    string
+	format string
+}
+
+var orgUsageOpts orgUsageOptions
+
+func init() {
+	flags := cmdOrgUsage.Flags()
+
+	flags.StringVarP(&orgUsageOpts.name, "name", "n", "", "organization name")
+	flags.StringVar(&orgUsageOpts.start, "start", "", "usage start time (RFC3339), defaults to 30 days before the end time")
+	flags.StringVar(&orgUsageOpts.end, "end", "", "usage end time (RFC3339), defaults to now")
+	flags.StringVar(&orgUsageOpts.format, "format", "json", `output format ("json" or "csv")`)
+
+	if err := cmdOrgUsage.MarkFlagRequired("name"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdOrg.AddCommand(cmdOrgUsage)
+}
+
+func orgUsage(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	var startTime, endTime time.Time
+	if orgUsageOpts.start != "" {
+		var err error
+		startTime, err = time.Parse(time.RFC3339, orgUsageOpts.start)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse start time")
+		}
+	}
+	if orgUsageOpts.end != "" {
+		var err error
+		endTime, err = time.Parse(time.RFC3339, orgUsageOpts.end)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse end time")
+		}
+	}
+
+	switch orgUsageOpts.format {
+	case "json":
+		usage, _, err := gwclient.GetOrgUsage(context.TODO(), orgUsageOpts.name, startTime, endTime)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get organization usage")
+		}
+
+		out, err := json.MarshalIndent(usage, "", "\t")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		os.Stdout.Write(out)
+
+	case "csv":
+		resp, err := gwclient.GetOrgUsageCSV(context.TODO(), orgUsageOpts.name, startTime, endTime)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get organization usage")
+		}
+		defer resp.Body.Close()
+
+		if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+			return errors.WithStack(err)
+		}
+
+	default:
+		return errors.Errorf("unknown format %q", orgUsageOpts.format)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdProjectCaches = &cobra.Command{
+	Use:   "caches",
+	Short: "show the project caches usage",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := projectCaches(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type projectCachesOptions struct {
+	projectRef string
+}
+
+var projectCachesOpts projectCachesOptions
+
+func init() {
+	flags := cmdProjectCaches.Flags()
+
+	flags.StringVar(&projectCachesOpts.projectRef, "project", "", "project id or full path")
+
+	if err := cmdProjectCaches.MarkFlagRequired("project"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdProject.AddCommand(cmdProjectCaches)
+}
+
+func projectCaches(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	caches, _, err := gwclient.GetProjectCaches(context.TODO(), projectCachesOpts.projectRef)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get project caches")
+	}
+	prettyJSON, err := json.MarshalIndent(caches, "", "\t")
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert project caches to json")
+	}
+	fmt.Printf("%s\n", string(prettyJSON))
+
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"path"
+
+	"agola.io/agola/internal/errors"
+	gwapitypes "agola.io/agola/services/gateway/api/types"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdProjectClone = &cobra.Command{
+	Use:   "clone <src> <dest>",
+	Short: "create a new project copying the settings, variables and optionally secrets of an existing project",
+	Long: `create a new project copying the settings, variables and optionally secrets of an existing project
+
+<src> is the source project path or id
+<dest> is the new project path (i.e "org/org01/newproject") or just the new project name to create it in the same project group of the source project`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := projectClone(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type projectCloneOptions struct {
+	repoPath         string
+	remoteSourceName string
+	cloneSecrets     bool
+	cloneSecretsData bool
+}
+
+var projectCloneOpts projectCloneOptions
+
+func init() {
+	flags := cmdProjectClone.Flags()
+
+	flags.StringVar(&projectCloneOpts.repoPath, "repo-path", "", "repository path (i.e agola-io/agola)")
+	flags.StringVar(&projectCloneOpts.remoteSourceName, "remote-source", "", "remote source name (defaults to the source project remote source)")
+	flags.BoolVar(&projectCloneOpts.cloneSecrets, "clone-secrets", false, "also copy the source project secrets names and keys without their values")
+	flags.BoolVar(&projectCloneOpts.cloneSecretsData, "clone-secrets-data", false, "also copy the source project secrets values (sealed values are never copied)")
+
+	if err := cmdProjectClone.MarkFlagRequired("repo-path"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdProject.AddCommand(cmdProjectClone)
+}
+
+func projectClone(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	srcProjectRef := args[0]
+	destProjectPath := path.Clean(args[1])
+
+	// when only a name is provided the project will be created in the source
+	// project parent project group
+	parentRef := path.Dir(destProjectPath)
+	if parentRef == "." {
+		parentRef = ""
+	}
+
+	req := &gwapitypes.CloneProjectRequest{
+		Name:             path.Base(destProjectPath),
+		ParentRef:        parentRef,
+		RemoteSourceName: projectCloneOpts.remoteSourceName,
+		RepoPath:         projectCloneOpts.repoPath,
+		CloneSecrets:     projectCloneOpts.cloneSecrets,
+		CloneSecretsData: projectCloneOpts.cloneSecretsData,
+	}
+
+	log.Info().Msgf("cloning project %s", srcProjectRef)
+
+	project, _, err := gwclient.CloneProject(context.TODO(), srcProjectRef, req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to clone project")
+	}
+	log.Info().Msgf("project %s created, ID: %s", project.Name, project.ID)
+
+	return nil
+}
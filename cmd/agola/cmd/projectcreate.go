@@ -16,6 +16,7 @@ package cmd
 
 import (
 	"context"
+	"strings"
 
 	"agola.io/agola/internal/errors"
 	gwapitypes "agola.io/agola/services/gateway/api/types"
@@ -36,13 +37,27 @@ var cmdProjectCreate = &cobra.Command{
 }
 
 type projectCreateOptions struct {
-	name                string
-	parentPath          string
-	repoPath            string
-	remoteSourceName    string
-	skipSSHHostKeyCheck bool
-	visibility          string
-	passVarsToForkedPR  bool
+	name                    string
+	parentPath              string
+	repoPath                string
+	remoteSourceName        string
+	skipSSHHostKeyCheck     bool
+	visibility              string
+	passVarsToForkedPR      bool
+	reportSkippedRuns       bool
+	tags                    []string
+	postPullRequestComments bool
+	useDepsProxy            bool
+	cancelSupersededRuns    bool
+	runsVisibility          string
+	logsVisibility          string
+	protectedBranches       []string
+	protectedTags           []string
+	allowedUpstreamProjects []string
+	schedules               []string
+	importRepoTopics        bool
+	maxRunningRuns          int
+	maxRunningTasks         int
 }
 
 var projectCreateOpts projectCreateOptions
@@ -57,6 +72,20 @@ func init() {
 	flags.StringVar(&projectCreateOpts.parentPath, "parent", "", `parent project group path (i.e "org/org01" for root project group in org01, "user/user01/group01/subgroub01") or project group id where the project should be created`)
 	flags.StringVar(&projectCreateOpts.visibility, "visibility", "public", `project visibility (public or private)`)
 	flags.BoolVar(&projectCreateOpts.passVarsToForkedPR, "pass-vars-to-forked-pr", false, `pass variables to run even if triggered by PR from forked repo`)
+	flags.BoolVar(&projectCreateOpts.reportSkippedRuns, "report-skipped-runs", false, `create a commit status for runs skipped by a "[ci skip]" commit message or not matching when conditions`)
+	flags.StringSliceVar(&projectCreateOpts.tags, "tags", nil, `project tags (comma separated)`)
+	flags.BoolVar(&projectCreateOpts.postPullRequestComments, "post-pull-request-comments", false, `post a pull request comment with the run results summary`)
+	flags.BoolVar(&projectCreateOpts.useDepsProxy, "use-deps-proxy", false, `configure the runs package managers to use the dependencies proxy`)
+	flags.BoolVar(&projectCreateOpts.cancelSupersededRuns, "cancel-superseded-runs", false, `stop the running runs of a branch or pull request when a new commit is pushed`)
+	flags.StringVar(&projectCreateOpts.runsVisibility, "runs-visibility", "", `who can read the runs results (owners, members or public). When empty the project visibility is used`)
+	flags.StringVar(&projectCreateOpts.logsVisibility, "logs-visibility", "", `who can read the runs logs (owners, members or public). When empty the project visibility is used`)
+	flags.StringSliceVar(&projectCreateOpts.protectedBranches, "protected-branches", nil, `protected branches glob patterns (comma separated). Protected variables values are provided only to the runs of protected branches and tags`)
+	flags.StringSliceVar(&projectCreateOpts.protectedTags, "protected-tags", nil, `protected tags glob patterns (comma separated)`)
+	flags.StringSliceVar(&projectCreateOpts.allowedUpstreamProjects, "allowed-upstream-projects", nil, `ids or paths of the projects, of the same owner, allowed to trigger runs in this project (comma separated)`)
+	flags.StringArrayVar(&projectCreateOpts.schedules, "schedule", nil, `schedule creating periodic runs on a branch in the "name:branch:cron expression" format (i.e. "nightly:master:0 2 * * *"). Can be repeated`)
+	flags.BoolVar(&projectCreateOpts.importRepoTopics, "import-repo-topics", false, `add the remote repository topics to the project tags`)
+	flags.IntVar(&projectCreateOpts.maxRunningRuns, "max-running-runs", 0, `max number of concurrently running project runs (0 means no limit)`)
+	flags.IntVar(&projectCreateOpts.maxRunningTasks, "max-running-tasks", 0, `max number of concurrently running project run tasks (0 means no limit)`)
 
 	if err := cmdProjectCreate.MarkFlagRequired("name"); err != nil {
 		log.Fatal().Err(err).Send()
@@ -84,6 +113,33 @@ func IsValidVisibility(v string) bool {
 	return true
 }
 
+func IsValidRunsVisibility(v string) bool {
+	switch gwapitypes.RunsVisibility(v) {
+	case gwapitypes.RunsVisibilityProject:
+	case gwapitypes.RunsVisibilityOwners:
+	case gwapitypes.RunsVisibilityMembers:
+	case gwapitypes.RunsVisibilityPublic:
+	default:
+		return false
+	}
+	return true
+}
+
+// parseProjectSchedules parses the schedules in the name:branch:cron format.
+// Since git branch names cannot contain a colon only the first two are used as
+// separators.
+func parseProjectSchedules(ss []string) ([]*gwapitypes.ProjectSchedule, error) {
+	var schedules []*gwapitypes.ProjectSchedule
+	for _, s := range ss {
+		parts := strings.SplitN(s, ":", 3)
+		if len(parts) != 3 {
+			return nil, errors.Errorf("wrong schedule %q, must be in the name:branch:cron format", s)
+		}
+		schedules = append(schedules, &gwapitypes.ProjectSchedule{Name: parts[0], Branch: parts[1], Cron: parts[2]})
+	}
+	return schedules, nil
+}
+
 func projectCreate(cmd *cobra.Command, args []string) error {
 	gwclient := gwclient.NewClient(gatewayURL, token)
 
@@ -91,15 +147,41 @@ func projectCreate(cmd *cobra.Command, args []string) error {
 	if !IsValidVisibility(projectCreateOpts.visibility) {
 		return errors.Errorf("invalid visibility %q", projectCreateOpts.visibility)
 	}
+	if !IsValidRunsVisibility(projectCreateOpts.runsVisibility) {
+		return errors.Errorf("invalid runs visibility %q", projectCreateOpts.runsVisibility)
+	}
+	if !IsValidRunsVisibility(projectCreateOpts.logsVisibility) {
+		return errors.Errorf("invalid logs visibility %q", projectCreateOpts.logsVisibility)
+	}
+	schedules, err := parseProjectSchedules(projectCreateOpts.schedules)
+	if err != nil {
+		return errors.WithStack(err)
+	}
 
 	req := &gwapitypes.CreateProjectRequest{
-		Name:                projectCreateOpts.name,
-		ParentRef:           projectCreateOpts.parentPath,
-		Visibility:          gwapitypes.Visibility(projectCreateOpts.visibility),
-		RepoPath:            projectCreateOpts.repoPath,
-		RemoteSourceName:    projectCreateOpts.remoteSourceName,
-		SkipSSHHostKeyCheck: projectCreateOpts.skipSSHHostKeyCheck,
-		PassVarsToForkedPR:  projectCreateOpts.passVarsToForkedPR,
+		Name:                    projectCreateOpts.name,
+		ParentRef:               projectCreateOpts.parentPath,
+		Visibility:              gwapitypes.Visibility(projectCreateOpts.visibility),
+		RepoPath:                projectCreateOpts.repoPath,
+		RemoteSourceName:        projectCreateOpts.remoteSourceName,
+		SkipSSHHostKeyCheck:     projectCreateOpts.skipSSHHostKeyCheck,
+		PassVarsToForkedPR:      projectCreateOpts.passVarsToForkedPR,
+		ReportSkippedRuns:       projectCreateOpts.reportSkippedRuns,
+		Tags:                    projectCreateOpts.tags,
+		PostPullRequestComments: projectCreateOpts.postPullRequestComments,
+		UseDepsProxy:            projectCreateOpts.useDepsProxy,
+		CancelSupersededRuns:    projectCreateOpts.cancelSupersededRuns,
+		RunsVisibility:          gwapitypes.RunsVisibility(projectCreateOpts.runsVisibility),
+		LogsVisibility:          gwapitypes.RunsVisibility(projectCreateOpts.logsVisibility),
+		ProtectedBranches:       projectCreateOpts.protectedBranches,
+		ProtectedTags:           projectCreateOpts.protectedTags,
+		AllowedUpstreamProjects: projectCreateOpts.allowedUpstreamProjects,
+		Schedules:               schedules,
+		ImportRepoTopics:        projectCreateOpts.importRepoTopics,
+		ConcurrencyLimits: gwapitypes.ConcurrencyLimits{
+			MaxRunningRuns:  projectCreateOpts.maxRunningRuns,
+			MaxRunningTasks: projectCreateOpts.maxRunningTasks,
+		},
 	}
 
 	log.Info().Msgf("creating project")
@@ -27,6 +27,9 @@ import (
 var cmdProjectDelete = &cobra.Command{
 	Use:   "delete",
 	Short: "delete a project",
+	Long: `delete a project
+
+The project is removed and its runs are stopped. An administrator can restore it with "agola project undelete" until the deletion grace period expires, then the project is permanently deleted with its runs, logs and caches.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := projectDelete(cmd, args); err != nil {
 			log.Fatal().Err(err).Send()
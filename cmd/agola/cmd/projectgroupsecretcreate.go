@@ -28,6 +28,9 @@ The secret data should be provided by a yaml document. Examples:
 
 data01: secretvalue01
 data02: secretvalue02
+
+With --sealed the data values are encrypted client side with the sealed secrets
+public key and can only be decrypted when executing the run tasks.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := secretCreate(cmd, "projectgroup", args); err != nil {
@@ -42,6 +45,7 @@ func init() {
 	flags.StringVar(&secretCreateOpts.parentRef, "projectgroup", "", "project group id or full path")
 	flags.StringVarP(&secretCreateOpts.name, "name", "n", "", "secret name")
 	flags.StringVarP(&secretCreateOpts.file, "file", "f", "", `yaml file containing the secret data (use "-" to read from stdin)`)
+	flags.BoolVar(&secretCreateOpts.sealed, "sealed", false, "seal the secret data values client side")
 
 	if err := cmdProjectGroupSecretCreate.MarkFlagRequired("projectgroup"); err != nil {
 		log.Fatal().Err(err).Send()
@@ -43,6 +43,7 @@ func init() {
 	flags.StringVarP(&secretUpdateOpts.name, "name", "n", "", "secret name")
 	flags.StringVarP(&secretUpdateOpts.newName, "new-name", "", "", "secret new name")
 	flags.StringVarP(&secretUpdateOpts.file, "file", "f", "", `yaml file containing the secret data (use "-" to read from stdin)`)
+	flags.BoolVar(&secretUpdateOpts.sealed, "sealed", false, "seal the secret data values client side")
 
 	if err := cmdProjectGroupSecretUpdate.MarkFlagRequired("projectgroup"); err != nil {
 		log.Fatal().Err(err).Send()
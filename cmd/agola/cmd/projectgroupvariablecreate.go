@@ -41,8 +41,12 @@ The variable values should be provided by a yaml document. Examples:
         - '#/refs/pull/.*#'
         - '#/refs/heads/devel.*#'
       exclude: /refs/heads/develop
+- secret_name: secret03
+  secret_var: data03
+  protected: true
 
-The above yaml document defines a variable that can have two different values depending on the first matching condition.
+The above yaml document defines a variable that can have three different values depending on the first matching condition.
+Protected values are used only by the runs of the protected branches and tags of the projects.
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := variableCreate(cmd, "projectgroup", args); err != nil {
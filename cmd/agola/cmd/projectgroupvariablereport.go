@@ -0,0 +1,71 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdProjectGroupVariableReport = &cobra.Command{
+	Use:   "report",
+	Short: "report the variables and secrets resolved by every project in the project group subtree",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := variableReport(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type variableReportOptions struct {
+	projectGroupRef string
+}
+
+var variableReportOpts variableReportOptions
+
+func init() {
+	flags := cmdProjectGroupVariableReport.Flags()
+
+	flags.StringVar(&variableReportOpts.projectGroupRef, "projectgroup", "", "project group id or full path")
+
+	if err := cmdProjectGroupVariableReport.MarkFlagRequired("projectgroup"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdProjectGroupVariable.AddCommand(cmdProjectGroupVariableReport)
+}
+
+func variableReport(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	reports, _, err := gwclient.GetProjectGroupVariablesReport(context.TODO(), variableReportOpts.projectGroupRef)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get project group variables report")
+	}
+	prettyJSON, err := json.MarshalIndent(reports, "", "\t")
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert project group variables report to json")
+	}
+	fmt.Printf("%s\n", string(prettyJSON))
+
+	return nil
+}
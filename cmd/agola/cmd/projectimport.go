@@ -0,0 +1,130 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"agola.io/agola/internal/errors"
+	gwapitypes "agola.io/agola/services/gateway/api/types"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdProjectImport = &cobra.Command{
+	Use:   "import",
+	Short: "create a project for every repository of a remote source organization",
+	Long: `create a project for every repository of a remote source organization
+
+Only the repositories where the user can create deploy keys and webhooks are imported. The projects are named like the repositories and repositories with an existing project with the same name are skipped.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := projectImport(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type projectImportOptions struct {
+	remoteSourceName    string
+	remoteOrg           string
+	parentPath          string
+	include             []string
+	exclude             []string
+	dryRun              bool
+	concurrency         int
+	visibility          string
+	skipSSHHostKeyCheck bool
+	importRepoTopics    bool
+}
+
+var projectImportOpts projectImportOptions
+
+func init() {
+	flags := cmdProjectImport.Flags()
+
+	flags.StringVar(&projectImportOpts.remoteSourceName, "remote-source", "", "remote source name")
+	flags.StringVar(&projectImportOpts.remoteOrg, "org", "", "remote source organization (or user or group) owning the repositories")
+	flags.StringVar(&projectImportOpts.parentPath, "group", "", `project group path (i.e "org/org01" for root project group in org01, "user/user01/group01/subgroub01") or project group id where the projects should be created`)
+	flags.StringSliceVar(&projectImportOpts.include, "include", nil, `only import the repositories with a name matching one of these glob patterns (comma separated)`)
+	flags.StringSliceVar(&projectImportOpts.exclude, "exclude", nil, `don't import the repositories with a name matching one of these glob patterns (comma separated)`)
+	flags.BoolVar(&projectImportOpts.dryRun, "dry-run", false, "only report the projects that will be created")
+	flags.IntVar(&projectImportOpts.concurrency, "concurrency", 0, "number of projects created in parallel (defaults to 4)")
+	flags.StringVar(&projectImportOpts.visibility, "visibility", "public", `projects visibility (public or private)`)
+	flags.BoolVarP(&projectImportOpts.skipSSHHostKeyCheck, "skip-ssh-host-key-check", "s", false, "skip ssh host key check")
+	flags.BoolVar(&projectImportOpts.importRepoTopics, "import-repo-topics", false, `add the remote repositories topics to the projects tags`)
+
+	if err := cmdProjectImport.MarkFlagRequired("remote-source"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+	if err := cmdProjectImport.MarkFlagRequired("org"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+	if err := cmdProjectImport.MarkFlagRequired("group"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdProject.AddCommand(cmdProjectImport)
+}
+
+func projectImport(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	if !IsValidVisibility(projectImportOpts.visibility) {
+		return errors.Errorf("invalid visibility %q", projectImportOpts.visibility)
+	}
+
+	req := &gwapitypes.ImportProjectsRequest{
+		RemoteSourceName:    projectImportOpts.remoteSourceName,
+		RemoteOrg:           projectImportOpts.remoteOrg,
+		Include:             projectImportOpts.include,
+		Exclude:             projectImportOpts.exclude,
+		DryRun:              projectImportOpts.dryRun,
+		Concurrency:         projectImportOpts.concurrency,
+		Visibility:          gwapitypes.Visibility(projectImportOpts.visibility),
+		SkipSSHHostKeyCheck: projectImportOpts.skipSSHHostKeyCheck,
+		ImportRepoTopics:    projectImportOpts.importRepoTopics,
+	}
+
+	log.Info().Msgf("importing the repositories of %s", projectImportOpts.remoteOrg)
+
+	results, _, err := gwclient.ImportProjects(context.TODO(), projectImportOpts.parentPath, req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to import projects")
+	}
+
+	failed := 0
+	for _, r := range results {
+		fmt.Printf("%s: Project: %s, Status: %s", r.RepoPath, r.ProjectName, r.Status)
+		if r.ProjectID != "" {
+			fmt.Printf(", ID: %s", r.ProjectID)
+		}
+		if r.Error != "" {
+			fmt.Printf(", Error: %s", r.Error)
+		}
+		fmt.Println()
+
+		if r.Status == gwapitypes.ImportProjectStatusFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d repositories failed to import", failed, len(results))
+	}
+
+	return nil
+}
@@ -17,6 +17,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"agola.io/agola/internal/errors"
 	gwapitypes "agola.io/agola/services/gateway/api/types"
@@ -38,6 +39,7 @@ var cmdProjectList = &cobra.Command{
 
 type projectListOptions struct {
 	parentPath string
+	tags       []string
 }
 
 var projectListOpts projectListOptions
@@ -46,6 +48,7 @@ func init() {
 	flags := cmdProjectList.Flags()
 
 	flags.StringVar(&projectListOpts.parentPath, "parent", "", `project group path (i.e "org/org01" for root project group in org01, "user/user01/group01/subgroub01") or project group id`)
+	flags.StringSliceVar(&projectListOpts.tags, "tag", nil, `only list the projects with all the provided tags (comma separated)`)
 
 	if err := cmdProjectList.MarkFlagRequired("parent"); err != nil {
 		log.Fatal().Err(err).Send()
@@ -56,14 +59,18 @@ func init() {
 
 func printProjects(projects []*gwapitypes.ProjectResponse) {
 	for _, project := range projects {
-		fmt.Printf("%s: Name: %s\n", project.ID, project.Name)
+		fmt.Printf("%s: Name: %s", project.ID, project.Name)
+		if len(project.Tags) > 0 {
+			fmt.Printf(", Tags: %s", strings.Join(project.Tags, ","))
+		}
+		fmt.Println()
 	}
 }
 
 func projectList(cmd *cobra.Command, args []string) error {
 	gwclient := gwclient.NewClient(gatewayURL, token)
 
-	projects, _, err := gwclient.GetProjectGroupProjects(context.TODO(), projectListOpts.parentPath)
+	projects, _, err := gwclient.GetProjectGroupProjects(context.TODO(), projectListOpts.parentPath, projectListOpts.tags)
 	if err != nil {
 		return errors.WithStack(err)
 	}
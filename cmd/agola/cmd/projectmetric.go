@@ -0,0 +1,82 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdProjectMetric = &cobra.Command{
+	Use:   "metric",
+	Short: "show the values of a metric emitted by the project runs, from the latest run backwards",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := projectMetric(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type projectMetricOptions struct {
+	projectRef string
+	name       string
+	branch     string
+	start      uint64
+	limit      int
+}
+
+var projectMetricOpts projectMetricOptions
+
+func init() {
+	flags := cmdProjectMetric.Flags()
+
+	flags.StringVar(&projectMetricOpts.projectRef, "project", "", "project id or full path")
+	flags.StringVarP(&projectMetricOpts.name, "name", "n", "", "metric name")
+	flags.StringVar(&projectMetricOpts.branch, "branch", "", "only show the values of the runs of this branch")
+	flags.Uint64Var(&projectMetricOpts.start, "start", 0, "starting run number (excluded)")
+	flags.IntVar(&projectMetricOpts.limit, "limit", 25, "max number of values to show")
+
+	if err := cmdProjectMetric.MarkFlagRequired("project"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+	if err := cmdProjectMetric.MarkFlagRequired("name"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdProject.AddCommand(cmdProjectMetric)
+}
+
+func projectMetric(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	values, _, err := gwclient.GetProjectMetric(context.TODO(), projectMetricOpts.projectRef, projectMetricOpts.name, projectMetricOpts.branch, projectMetricOpts.start, projectMetricOpts.limit)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get project metric")
+	}
+	prettyJSON, err := json.MarshalIndent(values, "", "\t")
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert project metric values to json")
+	}
+	fmt.Printf("%s\n", string(prettyJSON))
+
+	return nil
+}
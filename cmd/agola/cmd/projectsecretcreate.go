@@ -20,6 +20,7 @@ import (
 	"os"
 
 	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/sealedsecret"
 	gwapitypes "agola.io/agola/services/gateway/api/types"
 	gwclient "agola.io/agola/services/gateway/client"
 
@@ -37,6 +38,9 @@ The secret data should be provided by a yaml document. Examples:
 
 data01: secretvalue01
 data02: secretvalue02
+
+With --sealed the data values are encrypted client side with the sealed secrets
+public key and can only be decrypted when executing the run tasks.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := secretCreate(cmd, "project", args); err != nil {
@@ -49,6 +53,7 @@ type secretCreateOptions struct {
 	parentRef string
 	name      string
 	file      string
+	sealed    bool
 }
 
 var secretCreateOpts secretCreateOptions
@@ -59,6 +64,7 @@ func init() {
 	flags.StringVar(&secretCreateOpts.parentRef, "project", "", "project id or full path")
 	flags.StringVarP(&secretCreateOpts.name, "name", "n", "", "secret name")
 	flags.StringVarP(&secretCreateOpts.file, "file", "f", "", `yaml file containing the secret data (use "-" to read from stdin)`)
+	flags.BoolVar(&secretCreateOpts.sealed, "sealed", false, "seal the secret data values client side")
 
 	if err := cmdProjectSecretCreate.MarkFlagRequired("project"); err != nil {
 		log.Fatal().Err(err).Send()
@@ -95,10 +101,18 @@ func secretCreate(cmd *cobra.Command, ownertype string, args []string) error {
 	if err := yaml.Unmarshal(data, &secretData); err != nil {
 		log.Fatal().Msgf("failed to unmarshal secret: %v", err)
 	}
+	if secretCreateOpts.sealed {
+		secretData, err = sealSecretData(context.TODO(), gwclient, ownertype, secretCreateOpts.parentRef, secretCreateOpts.name, secretData)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
 	req := &gwapitypes.CreateSecretRequest{
-		Name: secretCreateOpts.name,
-		Type: gwapitypes.SecretTypeInternal,
-		Data: secretData,
+		Name:   secretCreateOpts.name,
+		Type:   gwapitypes.SecretTypeInternal,
+		Data:   secretData,
+		Sealed: secretCreateOpts.sealed,
 	}
 
 	switch ownertype {
@@ -120,3 +134,43 @@ func secretCreate(cmd *cobra.Command, ownertype string, args []string) error {
 
 	return nil
 }
+
+// sealSecretData encrypts the secret data values with the sealed secrets
+// public key. The values are bound to the secret parent and name so they can
+// be decrypted only when resolved from this secret
+func sealSecretData(ctx context.Context, gwclient *gwclient.Client, ownertype, parentRef, secretName string, data map[string]string) (map[string]string, error) {
+	var parentID string
+	switch ownertype {
+	case "project":
+		project, _, err := gwclient.GetProject(ctx, parentRef)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get project %q", parentRef)
+		}
+		parentID = project.ID
+	case "projectgroup":
+		projectGroup, _, err := gwclient.GetProjectGroup(ctx, parentRef)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get project group %q", parentRef)
+		}
+		parentID = projectGroup.ID
+	}
+
+	res, _, err := gwclient.GetSealedSecretsPublicKey(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get sealed secrets public key")
+	}
+	publicKey, err := sealedsecret.ParsePublicKey(res.PublicKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sealedData := make(map[string]string, len(data))
+	for k, v := range data {
+		sealedData[k], err = sealedsecret.Seal(publicKey, sealedsecret.Binding(parentID, secretName), v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to seal secret data %q", k)
+		}
+	}
+
+	return sealedData, nil
+}
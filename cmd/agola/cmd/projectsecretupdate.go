@@ -50,6 +50,7 @@ type secretUpdateOptions struct {
 	name      string
 	newName   string
 	file      string
+	sealed    bool
 }
 
 var secretUpdateOpts secretUpdateOptions
@@ -61,6 +62,7 @@ func init() {
 	flags.StringVarP(&secretUpdateOpts.name, "name", "n", "", "secret name")
 	flags.StringVarP(&secretUpdateOpts.newName, "new-name", "", "", "secret new name")
 	flags.StringVarP(&secretUpdateOpts.file, "file", "f", "", `yaml file containing the secret data (use "-" to read from stdin)`)
+	flags.BoolVar(&secretUpdateOpts.sealed, "sealed", false, "seal the secret data values client side")
 
 	if err := cmdProjectSecretUpdate.MarkFlagRequired("project"); err != nil {
 		log.Fatal().Err(err).Send()
@@ -97,10 +99,12 @@ func secretUpdate(cmd *cobra.Command, ownertype string, args []string) error {
 	if err := yaml.Unmarshal(data, &secretData); err != nil {
 		log.Fatal().Msgf("failed to unmarshal secret: %v", err)
 	}
+
 	req := &gwapitypes.UpdateSecretRequest{
-		Name: secretUpdateOpts.name,
-		Type: gwapitypes.SecretTypeInternal,
-		Data: secretData,
+		Name:   secretUpdateOpts.name,
+		Type:   gwapitypes.SecretTypeInternal,
+		Data:   secretData,
+		Sealed: secretUpdateOpts.sealed,
 	}
 
 	flags := cmd.Flags()
@@ -108,6 +112,14 @@ func secretUpdate(cmd *cobra.Command, ownertype string, args []string) error {
 		req.Name = secretUpdateOpts.newName
 	}
 
+	// seal the values with the final secret name since they are bound to it
+	if secretUpdateOpts.sealed {
+		req.Data, err = sealSecretData(context.TODO(), gwclient, ownertype, secretUpdateOpts.parentRef, req.Name, secretData)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
 	switch ownertype {
 	case "project":
 		log.Info().Msgf("creating project secret")
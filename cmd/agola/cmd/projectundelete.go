@@ -0,0 +1,67 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdProjectUndelete = &cobra.Command{
+	Use:   "undelete",
+	Short: "restore a deleted project during its deletion grace period (admin only)",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := projectUndelete(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type projectUndeleteOptions struct {
+	ref string
+}
+
+var projectUndeleteOpts projectUndeleteOptions
+
+func init() {
+	flags := cmdProjectUndelete.Flags()
+
+	flags.StringVar(&projectUndeleteOpts.ref, "ref", "", "project path or id")
+
+	if err := cmdProjectUndelete.MarkFlagRequired("ref"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdProject.AddCommand(cmdProjectUndelete)
+}
+
+func projectUndelete(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	log.Info().Msgf("restoring project")
+
+	project, _, err := gwclient.UndeleteProject(context.TODO(), projectUndeleteOpts.ref)
+	if err != nil {
+		return errors.Wrapf(err, "failed to restore project")
+	}
+	log.Info().Msgf("project %s restored, ID: %s", project.Name, project.ID)
+
+	return nil
+}
@@ -38,10 +38,24 @@ var cmdProjectUpdate = &cobra.Command{
 type projectUpdateOptions struct {
 	ref string
 
-	name               string
-	parentPath         string
-	visibility         string
-	passVarsToForkedPR bool
+	name                    string
+	parentPath              string
+	visibility              string
+	passVarsToForkedPR      bool
+	reportSkippedRuns       bool
+	tags                    []string
+	postPullRequestComments bool
+	useDepsProxy            bool
+	cancelSupersededRuns    bool
+	runsVisibility          string
+	logsVisibility          string
+	protectedBranches       []string
+	protectedTags           []string
+	allowedUpstreamProjects []string
+	schedules               []string
+	importRepoTopics        bool
+	maxRunningRuns          int
+	maxRunningTasks         int
 }
 
 var projectUpdateOpts projectUpdateOptions
@@ -54,6 +68,20 @@ func init() {
 	flags.StringVar(&projectUpdateOpts.parentPath, "parent", "", `parent project group path (i.e "org/org01" for root project group in org01, "user/user01/group01/subgroub01") or project group id where the project should be moved`)
 	flags.StringVar(&projectUpdateOpts.visibility, "visibility", "public", `project visibility (public or private)`)
 	flags.BoolVar(&projectUpdateOpts.passVarsToForkedPR, "pass-vars-to-forked-pr", false, `pass variables to run even if triggered by PR from forked repo`)
+	flags.BoolVar(&projectUpdateOpts.reportSkippedRuns, "report-skipped-runs", false, `create a commit status for runs skipped by a "[ci skip]" commit message or not matching when conditions`)
+	flags.StringSliceVar(&projectUpdateOpts.tags, "tags", nil, `project tags (comma separated), replaces the current tags`)
+	flags.BoolVar(&projectUpdateOpts.postPullRequestComments, "post-pull-request-comments", false, `post a pull request comment with the run results summary`)
+	flags.BoolVar(&projectUpdateOpts.useDepsProxy, "use-deps-proxy", false, `configure the runs package managers to use the dependencies proxy`)
+	flags.BoolVar(&projectUpdateOpts.cancelSupersededRuns, "cancel-superseded-runs", false, `stop the running runs of a branch or pull request when a new commit is pushed`)
+	flags.StringVar(&projectUpdateOpts.runsVisibility, "runs-visibility", "", `who can read the runs results (owners, members or public). When empty the project visibility is used`)
+	flags.StringVar(&projectUpdateOpts.logsVisibility, "logs-visibility", "", `who can read the runs logs (owners, members or public). When empty the project visibility is used`)
+	flags.StringSliceVar(&projectUpdateOpts.protectedBranches, "protected-branches", nil, `protected branches glob patterns (comma separated), replaces the current ones. Protected variables values are provided only to the runs of protected branches and tags`)
+	flags.StringSliceVar(&projectUpdateOpts.protectedTags, "protected-tags", nil, `protected tags glob patterns (comma separated), replaces the current ones`)
+	flags.StringSliceVar(&projectUpdateOpts.allowedUpstreamProjects, "allowed-upstream-projects", nil, `ids or paths of the projects, of the same owner, allowed to trigger runs in this project (comma separated), replaces the current ones`)
+	flags.StringArrayVar(&projectUpdateOpts.schedules, "schedule", nil, `schedule creating periodic runs on a branch in the "name:branch:cron expression" format (i.e. "nightly:master:0 2 * * *"). Can be repeated, replaces the current schedules. Use an empty value to remove all the schedules`)
+	flags.BoolVar(&projectUpdateOpts.importRepoTopics, "import-repo-topics", false, `add the remote repository topics to the project tags`)
+	flags.IntVar(&projectUpdateOpts.maxRunningRuns, "max-running-runs", 0, `max number of concurrently running project runs (0 means no limit)`)
+	flags.IntVar(&projectUpdateOpts.maxRunningTasks, "max-running-tasks", 0, `max number of concurrently running project run tasks (0 means no limit)`)
 
 	if err := cmdProjectUpdate.MarkFlagRequired("ref"); err != nil {
 		log.Fatal().Err(err).Send()
@@ -84,6 +112,76 @@ func projectUpdate(cmd *cobra.Command, args []string) error {
 	if flags.Changed("pass-vars-to-forked-pr") {
 		req.PassVarsToForkedPR = &projectUpdateOpts.passVarsToForkedPR
 	}
+	if flags.Changed("report-skipped-runs") {
+		req.ReportSkippedRuns = &projectUpdateOpts.reportSkippedRuns
+	}
+	if flags.Changed("tags") {
+		req.Tags = &projectUpdateOpts.tags
+	}
+	if flags.Changed("post-pull-request-comments") {
+		req.PostPullRequestComments = &projectUpdateOpts.postPullRequestComments
+	}
+	if flags.Changed("use-deps-proxy") {
+		req.UseDepsProxy = &projectUpdateOpts.useDepsProxy
+	}
+	if flags.Changed("cancel-superseded-runs") {
+		req.CancelSupersededRuns = &projectUpdateOpts.cancelSupersededRuns
+	}
+	if flags.Changed("runs-visibility") {
+		if !IsValidRunsVisibility(projectUpdateOpts.runsVisibility) {
+			return errors.Errorf("invalid runs visibility %q", projectUpdateOpts.runsVisibility)
+		}
+		runsVisibility := gwapitypes.RunsVisibility(projectUpdateOpts.runsVisibility)
+		req.RunsVisibility = &runsVisibility
+	}
+	if flags.Changed("logs-visibility") {
+		if !IsValidRunsVisibility(projectUpdateOpts.logsVisibility) {
+			return errors.Errorf("invalid logs visibility %q", projectUpdateOpts.logsVisibility)
+		}
+		logsVisibility := gwapitypes.RunsVisibility(projectUpdateOpts.logsVisibility)
+		req.LogsVisibility = &logsVisibility
+	}
+	if flags.Changed("protected-branches") {
+		req.ProtectedBranches = &projectUpdateOpts.protectedBranches
+	}
+	if flags.Changed("protected-tags") {
+		req.ProtectedTags = &projectUpdateOpts.protectedTags
+	}
+	if flags.Changed("allowed-upstream-projects") {
+		req.AllowedUpstreamProjects = &projectUpdateOpts.allowedUpstreamProjects
+	}
+	if flags.Changed("schedule") {
+		var ss []string
+		for _, s := range projectUpdateOpts.schedules {
+			if s != "" {
+				ss = append(ss, s)
+			}
+		}
+		schedules, err := parseProjectSchedules(ss)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if schedules == nil {
+			schedules = []*gwapitypes.ProjectSchedule{}
+		}
+		req.Schedules = &schedules
+	}
+	if flags.Changed("max-running-runs") || flags.Changed("max-running-tasks") {
+		// keep the current value of the limit not provided
+		project, _, err := gwclient.GetProject(context.TODO(), projectUpdateOpts.ref)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get project")
+		}
+		concurrencyLimits := project.ConcurrencyLimits
+		if flags.Changed("max-running-runs") {
+			concurrencyLimits.MaxRunningRuns = projectUpdateOpts.maxRunningRuns
+		}
+		if flags.Changed("max-running-tasks") {
+			concurrencyLimits.MaxRunningTasks = projectUpdateOpts.maxRunningTasks
+		}
+		req.ConcurrencyLimits = &concurrencyLimits
+	}
+	req.ImportRepoTopics = projectUpdateOpts.importRepoTopics
 
 	log.Info().Msgf("updating project")
 	project, _, err := gwclient.UpdateProject(context.TODO(), projectUpdateOpts.ref, req)
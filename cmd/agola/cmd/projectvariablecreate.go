@@ -51,8 +51,12 @@ The variable values should be provided by a yaml document. Examples:
         - '#/refs/pull/.*#'
         - '#/refs/heads/devel.*#'
       exclude: /refs/heads/develop
+- secret_name: secret03
+  secret_var: data03
+  protected: true
 
-The above yaml document defines a variable that can have two different values depending on the first matching condition.
+The above yaml document defines a variable that can have three different values depending on the first matching condition.
+Protected values are used only by the runs of the project protected branches and tags.
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := variableCreate(cmd, "project", args); err != nil {
@@ -93,7 +97,8 @@ type VariableValue struct {
 	SecretName string `json:"secret_name,omitempty"`
 	SecretVar  string `json:"secret_var,omitempty"`
 
-	When *config.When `json:"when,omitempty"`
+	When      *config.When `json:"when,omitempty"`
+	Protected bool         `json:"protected,omitempty"`
 }
 
 func variableCreate(cmd *cobra.Command, ownertype string, args []string) error {
@@ -124,6 +129,7 @@ func variableCreate(cmd *cobra.Command, ownertype string, args []string) error {
 			SecretName: value.SecretName,
 			SecretVar:  value.SecretVar,
 			When:       value.When.ToWhen(),
+			Protected:  value.Protected,
 		})
 	}
 	req := &gwapitypes.CreateVariableRequest{
@@ -96,6 +96,7 @@ func variableUpdate(cmd *cobra.Command, ownertype string, args []string) error {
 			SecretName: value.SecretName,
 			SecretVar:  value.SecretVar,
 			When:       value.When.ToWhen(),
+			Protected:  value.Protected,
 		})
 	}
 	req := &gwapitypes.UpdateVariableRequest{
@@ -47,6 +47,8 @@ type remoteSourceCreateOptions struct {
 	oauth2ClientSecret  string
 	sshHostKey          string
 	skipSSHHostKeyCheck bool
+	sshEndpoint         string
+	orgWebhooks         bool
 	registrationEnabled bool
 	loginEnabled        bool
 }
@@ -59,12 +61,14 @@ func init() {
 	flags.StringVarP(&remoteSourceCreateOpts.name, "name", "n", "", "remotesource name")
 	flags.StringVar(&remoteSourceCreateOpts.rsType, "type", "", "remotesource type")
 	flags.StringVar(&remoteSourceCreateOpts.authType, "auth-type", "", "remote source auth type")
-	flags.StringVar(&remoteSourceCreateOpts.apiURL, "api-url", "", `remotesource api url (when type is "github" defaults to "https://api.github.com")`)
+	flags.StringVar(&remoteSourceCreateOpts.apiURL, "api-url", "", `remotesource api url (when type is "github" defaults to "https://api.github.com", when type is "git" it's the base git url, i.e. "ssh://git@example.com")`)
 	flags.BoolVarP(&remoteSourceCreateOpts.skipVerify, "skip-verify", "", false, "skip remote source api tls certificate verification")
 	flags.StringVar(&remoteSourceCreateOpts.oauth2ClientID, "clientid", "", "remotesource oauth2 client id")
 	flags.StringVar(&remoteSourceCreateOpts.oauth2ClientSecret, "secret", "", "remotesource oauth2 secret")
 	flags.StringVar(&remoteSourceCreateOpts.sshHostKey, "ssh-host-key", "", "remotesource ssh public host key")
 	flags.BoolVarP(&remoteSourceCreateOpts.skipSSHHostKeyCheck, "skip-ssh-host-key-check", "s", false, "skip ssh host key check")
+	flags.StringVar(&remoteSourceCreateOpts.sshEndpoint, "ssh-endpoint", "", "override the host and port (host[:port]) of the remotesource repositories ssh clone urls")
+	flags.BoolVar(&remoteSourceCreateOpts.orgWebhooks, "org-webhooks", false, "use a single organization level webhook for all the projects of the same remote organization")
 	flags.BoolVar(&remoteSourceCreateOpts.registrationEnabled, "registration-enabled", true, "enabled/disable user registration with this remote source")
 	flags.BoolVar(&remoteSourceCreateOpts.loginEnabled, "login-enabled", true, "enabled/disable user login with this remote source")
 
@@ -110,6 +114,8 @@ func remoteSourceCreate(cmd *cobra.Command, args []string) error {
 		Oauth2ClientSecret:  remoteSourceCreateOpts.oauth2ClientSecret,
 		SSHHostKey:          remoteSourceCreateOpts.sshHostKey,
 		SkipSSHHostKeyCheck: remoteSourceCreateOpts.skipSSHHostKeyCheck,
+		SSHEndpoint:         remoteSourceCreateOpts.sshEndpoint,
+		OrgWebhooks:         remoteSourceCreateOpts.orgWebhooks,
 		RegistrationEnabled: util.BoolP(remoteSourceCreateOpts.registrationEnabled),
 		LoginEnabled:        util.BoolP(remoteSourceCreateOpts.loginEnabled),
 	}
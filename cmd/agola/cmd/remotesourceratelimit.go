@@ -0,0 +1,71 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdRemoteSourceRateLimit = &cobra.Command{
+	Use: "ratelimit",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := remoteSourceRateLimit(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+	Short: "show the remote source api rate limit status",
+}
+
+type remoteSourceRateLimitOptions struct {
+	ref string
+}
+
+var remoteSourceRateLimitOpts remoteSourceRateLimitOptions
+
+func init() {
+	flags := cmdRemoteSourceRateLimit.Flags()
+
+	flags.StringVarP(&remoteSourceRateLimitOpts.ref, "ref", "", "", "remotesource name or id")
+
+	if err := cmdRemoteSourceRateLimit.MarkFlagRequired("ref"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdRemoteSource.AddCommand(cmdRemoteSourceRateLimit)
+}
+
+func remoteSourceRateLimit(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	rl, _, err := gwclient.GetRemoteSourceRateLimit(context.TODO(), remoteSourceRateLimitOpts.ref)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	fmt.Printf("Limit: %d\n", rl.Limit)
+	fmt.Printf("Remaining: %d\n", rl.Remaining)
+	fmt.Printf("Reset: %s\n", rl.Reset)
+	fmt.Printf("Active requests: %d\n", rl.ActiveRequests)
+	fmt.Printf("Rate limited requests: %d\n", rl.RateLimitedRequests)
+
+	return nil
+}
@@ -45,6 +45,8 @@ type remoteSourceUpdateOptions struct {
 	oauth2ClientSecret  string
 	sshHostKey          string
 	skipSSHHostKeyCheck bool
+	sshEndpoint         string
+	orgWebhooks         bool
 	registrationEnabled bool
 	loginEnabled        bool
 }
@@ -62,6 +64,8 @@ func init() {
 	flags.StringVar(&remoteSourceUpdateOpts.oauth2ClientSecret, "secret", "", "remotesource oauth2 secret")
 	flags.StringVar(&remoteSourceUpdateOpts.sshHostKey, "ssh-host-key", "", "remotesource ssh public host key")
 	flags.BoolVarP(&remoteSourceUpdateOpts.skipSSHHostKeyCheck, "skip-ssh-host-key-check", "s", false, "skip ssh host key check")
+	flags.StringVar(&remoteSourceUpdateOpts.sshEndpoint, "ssh-endpoint", "", "override the host and port (host[:port]) of the remotesource repositories ssh clone urls")
+	flags.BoolVar(&remoteSourceUpdateOpts.orgWebhooks, "org-webhooks", false, "use a single organization level webhook for all the projects of the same remote organization")
 	flags.BoolVar(&remoteSourceUpdateOpts.registrationEnabled, "registration-enabled", false, "enabled/disable user registration with this remote source")
 	flags.BoolVar(&remoteSourceUpdateOpts.loginEnabled, "login-enabled", false, "enabled/disable user login with this remote source")
 
@@ -99,6 +103,12 @@ func remoteSourceUpdate(cmd *cobra.Command, args []string) error {
 	if flags.Changed("skip-ssh-host-key-check") {
 		req.SkipSSHHostKeyCheck = &remoteSourceUpdateOpts.skipSSHHostKeyCheck
 	}
+	if flags.Changed("ssh-endpoint") {
+		req.SSHEndpoint = &remoteSourceUpdateOpts.sshEndpoint
+	}
+	if flags.Changed("org-webhooks") {
+		req.OrgWebhooks = &remoteSourceUpdateOpts.orgWebhooks
+	}
 	if flags.Changed("registration-enabled") {
 		req.RegistrationEnabled = &remoteSourceUpdateOpts.registrationEnabled
 	}
@@ -41,6 +41,8 @@ type runCreateOptions struct {
 	tag        string
 	ref        string
 	commitSHA  string
+	vars       []string
+	inputs     []string
 }
 
 var runCreateOpts runCreateOptions
@@ -48,15 +50,13 @@ var runCreateOpts runCreateOptions
 func init() {
 	flags := cmdRunCreate.Flags()
 
-	flags.StringVar(&runCreateOpts.projectRef, "project", "", "project id or full path")
+	flags.StringVar(&runCreateOpts.projectRef, "project", "", "project id or full path (defaults to the user preferences default project)")
 	flags.StringVar(&runCreateOpts.branch, "branch", "", "git branch")
 	flags.StringVar(&runCreateOpts.tag, "tag", "", "git tag")
 	flags.StringVar(&runCreateOpts.ref, "ref", "", "git ref")
 	flags.StringVar(&runCreateOpts.commitSHA, "commit-sha", "", "git commit sha")
-
-	if err := cmdRunCreate.MarkFlagRequired("project"); err != nil {
-		log.Fatal().Err(err).Send()
-	}
+	flags.StringArrayVar(&runCreateOpts.vars, "var", []string{}, `list of variables (name=value) overriding the project variables. This option can be repeated multiple times`)
+	flags.StringArrayVar(&runCreateOpts.inputs, "input", []string{}, `list of config inputs values (name=value). This option can be repeated multiple times`)
 
 	cmdRun.AddCommand(cmdRunCreate)
 }
@@ -79,14 +79,49 @@ func runCreate(cmd *cobra.Command, args []string) error {
 		return errors.Errorf(`one of "--branch", "--tag" or "--ref" must be provided`)
 	}
 
+	var variables map[string]string
+	for _, variable := range runCreateOpts.vars {
+		varname, varvalue, err := parseVariable(variable)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if variables == nil {
+			variables = map[string]string{}
+		}
+		variables[varname] = varvalue
+	}
+
+	var inputs map[string]string
+	for _, input := range runCreateOpts.inputs {
+		name, value, err := parseVariable(input)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if inputs == nil {
+			inputs = map[string]string{}
+		}
+		inputs[name] = value
+	}
+
 	req := &gwapitypes.ProjectCreateRunRequest{
 		Branch:    runCreateOpts.branch,
 		Tag:       runCreateOpts.tag,
 		Ref:       runCreateOpts.ref,
 		CommitSHA: runCreateOpts.commitSHA,
+		Variables: variables,
+		Inputs:    inputs,
+	}
+
+	projectRef := runCreateOpts.projectRef
+	if projectRef == "" {
+		var err error
+		projectRef, err = defaultProjectRef(context.TODO(), gwclient)
+		if err != nil {
+			return errors.WithStack(err)
+		}
 	}
 
-	_, err := gwclient.ProjectCreateRun(context.TODO(), runCreateOpts.projectRef, req)
+	_, err := gwclient.ProjectCreateRun(context.TODO(), projectRef, req)
 
 	return errors.WithStack(err)
 }
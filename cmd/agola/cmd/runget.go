@@ -0,0 +1,89 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdRunGet = &cobra.Command{
+	Use: "get",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runGet(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+	Short: "get a project run or a user direct run by its number",
+}
+
+type runGetOptions struct {
+	projectRef string
+	username   string
+	number     uint64
+}
+
+var runGetOpts runGetOptions
+
+func init() {
+	flags := cmdRunGet.Flags()
+
+	flags.StringVar(&runGetOpts.projectRef, "project", "", "project id or full path (defaults to the user preferences default project)")
+	flags.StringVar(&runGetOpts.username, "username", "", "User name for user direct runs")
+	flags.Uint64VarP(&runGetOpts.number, "number", "n", 0, "run number")
+
+	if err := cmdRunGet.MarkFlagRequired("number"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdRun.AddCommand(cmdRunGet)
+}
+
+func runGet(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+
+	if flags.Changed("username") && flags.Changed("project") {
+		return errors.Errorf(`only one of "--username" or "--project" can be provided`)
+	}
+
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	isProject := !flags.Changed("username")
+	groupRef := runGetOpts.username
+	if isProject {
+		groupRef = runGetOpts.projectRef
+		if groupRef == "" {
+			projectRef, err := defaultProjectRef(context.TODO(), gwclient)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			groupRef = projectRef
+		}
+	}
+
+	run, err := getRunDetails(context.TODO(), gwclient, isProject, groupRef, runGetOpts.number)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get run #%d", runGetOpts.number)
+	}
+
+	printRuns([]*runDetails{run})
+
+	return nil
+}
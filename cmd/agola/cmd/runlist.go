@@ -41,6 +41,7 @@ type runListOptions struct {
 	projectRef  string
 	username    string
 	phaseFilter []string
+	labelFilter []string
 	limit       int
 	start       uint64
 }
@@ -62,9 +63,10 @@ var runListOpts runListOptions
 func init() {
 	flags := cmdRunList.Flags()
 
-	flags.StringVar(&runListOpts.projectRef, "project", "", "project id or full path")
+	flags.StringVar(&runListOpts.projectRef, "project", "", "project id or full path (defaults to the user preferences default project)")
 	flags.StringVar(&runListOpts.username, "username", "", "User name for user direct runs")
 	flags.StringSliceVarP(&runListOpts.phaseFilter, "phase", "s", nil, "filter runs matching the provided phase. This option can be repeated multiple times")
+	flags.StringSliceVar(&runListOpts.labelFilter, "label", nil, "filter runs matching the provided label in the format key=value. This option can be repeated multiple times")
 	flags.IntVar(&runListOpts.limit, "limit", 10, "max number of runs to show")
 	flags.Uint64Var(&runListOpts.start, "start", 0, "starting run number (excluded) to fetch")
 
@@ -79,6 +81,12 @@ func printRuns(runs []*runDetails) {
 			if task.retrieveError != nil {
 				fmt.Printf("\t\tfailed to retrieve task information: %v\n", task.retrieveError)
 			} else {
+				if task.runTaskResponse.Attempt > 1 {
+					fmt.Printf("\t\tAttempt: %d\n", task.runTaskResponse.Attempt)
+				}
+				if task.runTaskResponse.FailError != "" {
+					fmt.Printf("\t\tFailError: %s\n", task.runTaskResponse.FailError)
+				}
 				for n, step := range task.runTaskResponse.Steps {
 					if step.Phase.IsFinished() && step.Type == "run" && step.ExitStatus != nil {
 						fmt.Printf("\t\tStep: %d, Name: %s, Type: %s, Phase: %s, ExitStatus: %d\n", n, step.Name, step.Type, step.Phase, *step.ExitStatus)
@@ -97,69 +105,88 @@ func runList(cmd *cobra.Command, args []string) error {
 	if flags.Changed("username") && flags.Changed("project") {
 		return errors.Errorf(`only one of "--username" or "--project" can be provided`)
 	}
-	if !flags.Changed("username") && !flags.Changed("project") {
-		return errors.Errorf(`one of "--username" or "--project" must be provided`)
-	}
 
 	gwclient := gwclient.NewClient(gatewayURL, token)
 
 	isProject := !flags.Changed("username")
+	if isProject && runListOpts.projectRef == "" {
+		projectRef, err := defaultProjectRef(context.TODO(), gwclient)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		runListOpts.projectRef = projectRef
+	}
 
 	var runsResp []*gwapitypes.RunsResponse
 	var err error
 	if isProject {
-		runsResp, _, err = gwclient.GetProjectRuns(context.TODO(), runListOpts.projectRef, runListOpts.phaseFilter, nil, runListOpts.start, runListOpts.limit, false)
+		runsResp, _, err = gwclient.GetProjectRuns(context.TODO(), runListOpts.projectRef, runListOpts.phaseFilter, nil, runListOpts.labelFilter, runListOpts.start, runListOpts.limit, false)
 	} else {
-		runsResp, _, err = gwclient.GetUserRuns(context.TODO(), runListOpts.username, runListOpts.phaseFilter, nil, runListOpts.start, runListOpts.limit, false)
+		runsResp, _, err = gwclient.GetUserRuns(context.TODO(), runListOpts.username, runListOpts.phaseFilter, nil, runListOpts.labelFilter, runListOpts.start, runListOpts.limit, false)
 	}
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
+	groupRef := runListOpts.projectRef
+	if !isProject {
+		groupRef = runListOpts.username
+	}
+
 	runs := make([]*runDetails, len(runsResp))
 	for i, runResponse := range runsResp {
-		var err error
-		var run *gwapitypes.RunResponse
-		if isProject {
-			run, _, err = gwclient.GetProjectRun(context.TODO(), runListOpts.projectRef, runResponse.Number)
-		} else {
-			run, _, err = gwclient.GetUserRun(context.TODO(), runListOpts.username, runResponse.Number)
-		}
+		run, err := getRunDetails(context.TODO(), gwclient, isProject, groupRef, runResponse.Number)
 		if err != nil {
 			return errors.WithStack(err)
 		}
+		runs[i] = run
+	}
 
-		tasks := []*taskDetails{}
-		for _, task := range run.Tasks {
-			var runTaskResponse *gwapitypes.RunTaskResponse
-			if isProject {
-				runTaskResponse, _, err = gwclient.GetUserRunTask(context.TODO(), runListOpts.projectRef, run.Number, task.ID)
-			} else {
-				runTaskResponse, _, err = gwclient.GetProjectRunTask(context.TODO(), runListOpts.username, run.Number, task.ID)
-			}
-			t := &taskDetails{
-				name:            task.Name,
-				level:           task.Level,
-				runTaskResponse: runTaskResponse,
-				retrieveError:   err,
-			}
-			tasks = append(tasks, t)
-		}
+	printRuns(runs)
 
-		sort.Slice(tasks, func(i, j int) bool {
-			if tasks[i].level != tasks[j].level {
-				return tasks[i].level < tasks[j].level
-			}
-			return tasks[i].name < tasks[j].name
-		})
+	return nil
+}
 
-		runs[i] = &runDetails{
-			runResponse: run,
-			tasks:       tasks,
+// getRunDetails fetches the project or user direct run with the provided
+// number and its tasks
+func getRunDetails(ctx context.Context, gwclient *gwclient.Client, isProject bool, groupRef string, runNumber uint64) (*runDetails, error) {
+	var err error
+	var run *gwapitypes.RunResponse
+	if isProject {
+		run, _, err = gwclient.GetProjectRun(ctx, groupRef, runNumber)
+	} else {
+		run, _, err = gwclient.GetUserRun(ctx, groupRef, runNumber)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	tasks := []*taskDetails{}
+	for _, task := range run.Tasks {
+		var runTaskResponse *gwapitypes.RunTaskResponse
+		if isProject {
+			runTaskResponse, _, err = gwclient.GetProjectRunTask(ctx, groupRef, run.Number, task.ID)
+		} else {
+			runTaskResponse, _, err = gwclient.GetUserRunTask(ctx, groupRef, run.Number, task.ID)
 		}
+		t := &taskDetails{
+			name:            task.Name,
+			level:           task.Level,
+			runTaskResponse: runTaskResponse,
+			retrieveError:   err,
+		}
+		tasks = append(tasks, t)
 	}
 
-	printRuns(runs)
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].level != tasks[j].level {
+			return tasks[i].level < tasks[j].level
+		}
+		return tasks[i].name < tasks[j].name
+	})
 
-	return nil
+	return &runDetails{
+		runResponse: run,
+		tasks:       tasks,
+	}, nil
 }
@@ -22,6 +22,7 @@ import (
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/services/config"
 	"agola.io/agola/internal/services/configstore"
+	"agola.io/agola/internal/services/depsproxy"
 	"agola.io/agola/internal/services/executor"
 	rsexecutor "agola.io/agola/internal/services/executor"
 	"agola.io/agola/internal/services/gateway"
@@ -49,6 +50,7 @@ var componentsNames = []string{
 	"executor",
 	"configstore",
 	"gitserver",
+	"depsproxy",
 }
 
 var cmdServe = &cobra.Command{
@@ -73,7 +75,7 @@ func init() {
 	flags := cmdServe.Flags()
 
 	flags.StringVar(&serveOpts.config, "config", "./config.yml", "config file path")
-	flags.StringSliceVar(&serveOpts.components, "components", []string{}, `list of components to start. Specify "all-base" to start all base components (excluding the executor).`)
+	flags.StringSliceVar(&serveOpts.components, "components", []string{}, `list of components to start. Specify "all-base" to start all base components (excluding the executor and the depsproxy).`)
 
 	if err := cmdServe.MarkFlagRequired("components"); err != nil {
 		log.Fatal().Err(err).Send()
@@ -83,7 +85,7 @@ func init() {
 }
 
 func isComponentEnabled(name string) bool {
-	if util.StringInSlice(serveOpts.components, "all-base") && name != "executor" {
+	if util.StringInSlice(serveOpts.components, "all-base") && name != "executor" && name != "depsproxy" {
 		return true
 	}
 	return util.StringInSlice(serveOpts.components, name)
@@ -108,7 +110,7 @@ func serve(cmd *cobra.Command, args []string) error {
 
 	var rs *rsscheduler.Runservice
 	if isComponentEnabled("runservice") {
-		rs, err = rsscheduler.NewRunservice(ctx, log.Logger, &c.Runservice)
+		rs, err = rsscheduler.NewRunservice(ctx, log.Logger, c)
 		if err != nil {
 			return errors.Wrapf(err, "failed to start run service scheduler")
 		}
@@ -116,7 +118,7 @@ func serve(cmd *cobra.Command, args []string) error {
 
 	var ex *rsexecutor.Executor
 	if isComponentEnabled("executor") {
-		ex, err = executor.NewExecutor(ctx, log.Logger, &c.Executor)
+		ex, err = executor.NewExecutor(ctx, log.Logger, c)
 		if err != nil {
 			return errors.Wrapf(err, "failed to start run service executor")
 		}
@@ -124,7 +126,7 @@ func serve(cmd *cobra.Command, args []string) error {
 
 	var cs *configstore.Configstore
 	if isComponentEnabled("configstore") {
-		cs, err = configstore.NewConfigstore(ctx, log.Logger, &c.Configstore)
+		cs, err = configstore.NewConfigstore(ctx, log.Logger, c)
 		if err != nil {
 			return errors.Wrapf(err, "failed to start config store")
 		}
@@ -132,7 +134,7 @@ func serve(cmd *cobra.Command, args []string) error {
 
 	var sched *scheduler.Scheduler
 	if isComponentEnabled("scheduler") {
-		sched, err = scheduler.NewScheduler(ctx, log.Logger, &c.Scheduler)
+		sched, err = scheduler.NewScheduler(ctx, log.Logger, c)
 		if err != nil {
 			return errors.Wrapf(err, "failed to start scheduler")
 		}
@@ -156,12 +158,20 @@ func serve(cmd *cobra.Command, args []string) error {
 
 	var gs *gitserver.Gitserver
 	if isComponentEnabled("gitserver") {
-		gs, err = gitserver.NewGitserver(ctx, log.Logger, &c.Gitserver)
+		gs, err = gitserver.NewGitserver(ctx, log.Logger, c)
 		if err != nil {
 			return errors.Wrapf(err, "failed to start git server")
 		}
 	}
 
+	var dp *depsproxy.DepsProxy
+	if isComponentEnabled("depsproxy") {
+		dp, err = depsproxy.NewDepsProxy(ctx, log.Logger, c)
+		if err != nil {
+			return errors.Wrapf(err, "failed to start dependencies proxy")
+		}
+	}
+
 	errCh := make(chan error)
 
 	if rs != nil {
@@ -185,6 +195,9 @@ func serve(cmd *cobra.Command, args []string) error {
 	if gs != nil {
 		go func() { errCh <- gs.Run(ctx) }()
 	}
+	if dp != nil {
+		go func() { errCh <- dp.Run(ctx) }()
+	}
 
 	return <-errCh
 }
@@ -0,0 +1,47 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"agola.io/agola/internal/errors"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdUserPreferences = &cobra.Command{
+	Use:   "preferences",
+	Short: "preferences",
+}
+
+func init() {
+	cmdUser.AddCommand(cmdUserPreferences)
+}
+
+// defaultProjectRef returns the default project saved in the current user
+// preferences
+func defaultProjectRef(ctx context.Context, gwclient *gwclient.Client) (string, error) {
+	userPreferences, _, err := gwclient.GetUserPreferences(ctx)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get user preferences")
+	}
+	if userPreferences.DefaultProjectID == "" {
+		return "", errors.Errorf("no project provided and no default project set in user preferences")
+	}
+
+	return userPreferences.DefaultProjectID, nil
+}
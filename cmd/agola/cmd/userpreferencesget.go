@@ -0,0 +1,61 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"agola.io/agola/internal/errors"
+	gwapitypes "agola.io/agola/services/gateway/api/types"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdUserPreferencesGet = &cobra.Command{
+	Use:   "get",
+	Short: "get current user preferences",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := userPreferencesGet(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+func init() {
+	cmdUserPreferences.AddCommand(cmdUserPreferencesGet)
+}
+
+func printUserPreferences(userPreferences *gwapitypes.UserPreferencesResponse) {
+	fmt.Printf("Default org: %s\n", userPreferences.DefaultOrgID)
+	fmt.Printf("Default project: %s\n", userPreferences.DefaultProjectID)
+	fmt.Printf("Timezone: %s\n", userPreferences.Timezone)
+	fmt.Printf("Date format: %s\n", userPreferences.DateFormat)
+}
+
+func userPreferencesGet(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	userPreferences, _, err := gwclient.GetUserPreferences(context.TODO())
+	if err != nil {
+		return errors.Wrapf(err, "failed to get user preferences")
+	}
+
+	printUserPreferences(userPreferences)
+
+	return nil
+}
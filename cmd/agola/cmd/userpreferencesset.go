@@ -0,0 +1,99 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+
+	"agola.io/agola/internal/errors"
+	gwapitypes "agola.io/agola/services/gateway/api/types"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdUserPreferencesSet = &cobra.Command{
+	Use:   "set",
+	Short: "set current user preferences",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := userPreferencesSet(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type userPreferencesSetOptions struct {
+	defaultOrg     string
+	defaultProject string
+	timezone       string
+	dateFormat     string
+}
+
+var userPreferencesSetOpts userPreferencesSetOptions
+
+func init() {
+	flags := cmdUserPreferencesSet.Flags()
+
+	flags.StringVar(&userPreferencesSetOpts.defaultOrg, "default-org", "", "default organization name or id (empty to unset)")
+	flags.StringVar(&userPreferencesSetOpts.defaultProject, "default-project", "", "default project id or full path (empty to unset)")
+	flags.StringVar(&userPreferencesSetOpts.timezone, "timezone", "", "timezone (i.e. Europe/Rome)")
+	flags.StringVar(&userPreferencesSetOpts.dateFormat, "date-format", "", "date format")
+
+	cmdUserPreferences.AddCommand(cmdUserPreferencesSet)
+}
+
+func userPreferencesSet(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	// the api replaces all the preferences so start from the current ones and
+	// only change the provided flags
+	userPreferences, _, err := gwclient.GetUserPreferences(context.TODO())
+	if err != nil {
+		return errors.Wrapf(err, "failed to get user preferences")
+	}
+
+	req := &gwapitypes.UpdateUserPreferencesRequest{
+		DefaultOrgRef:     userPreferences.DefaultOrgID,
+		DefaultProjectRef: userPreferences.DefaultProjectID,
+		Timezone:          userPreferences.Timezone,
+		DateFormat:        userPreferences.DateFormat,
+		UISettings:        userPreferences.UISettings,
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("default-org") {
+		req.DefaultOrgRef = userPreferencesSetOpts.defaultOrg
+	}
+	if flags.Changed("default-project") {
+		req.DefaultProjectRef = userPreferencesSetOpts.defaultProject
+	}
+	if flags.Changed("timezone") {
+		req.Timezone = userPreferencesSetOpts.timezone
+	}
+	if flags.Changed("date-format") {
+		req.DateFormat = userPreferencesSetOpts.dateFormat
+	}
+
+	log.Info().Msgf("updating user preferences")
+	userPreferences, _, err = gwclient.UpdateUserPreferences(context.TODO(), req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update user preferences")
+	}
+
+	printUserPreferences(userPreferences)
+
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	gwapitypes "agola.io/agola/services/gateway/api/types"
+	gwclient "agola.io/agola/services/gateway/client"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var cmdUserTokenList = &cobra.Command{
+	Use:   "list",
+	Short: "list user tokens",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := userTokenList(cmd, args); err != nil {
+			log.Fatal().Err(err).Send()
+		}
+	},
+}
+
+type userTokenListOptions struct {
+	userName string
+}
+
+var userTokenListOpts userTokenListOptions
+
+func init() {
+	flags := cmdUserTokenList.Flags()
+
+	flags.StringVarP(&userTokenListOpts.userName, "username", "n", "", "user name")
+
+	if err := cmdUserTokenList.MarkFlagRequired("username"); err != nil {
+		log.Fatal().Err(err).Send()
+	}
+
+	cmdUserToken.AddCommand(cmdUserTokenList)
+}
+
+func printUserTokens(tokens []*gwapitypes.UserTokenResponse) {
+	for _, token := range tokens {
+		lastUsed := "never"
+		if token.LastUsedTime != nil {
+			lastUsed = token.LastUsedTime.Format(time.RFC3339)
+		}
+		fmt.Printf("%s: Created: %s, Last used: %s\n", token.Name, token.CreationTime.Format(time.RFC3339), lastUsed)
+	}
+}
+
+func userTokenList(cmd *cobra.Command, args []string) error {
+	gwclient := gwclient.NewClient(gatewayURL, token)
+
+	tokens, _, err := gwclient.GetUserTokens(context.TODO(), userTokenListOpts.userName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get user tokens")
+	}
+
+	printUserTokens(tokens)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"agola.io/agola/internal/toolbox/vmagent"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+)
+
+var cmdAgent = &cobra.Command{
+	Use:   "agent",
+	Run:   agentRun,
+	Short: "executes the requests received on a vsock port. Used inside virtual machines started by the executor",
+}
+
+type agentOptions struct {
+	port uint32
+}
+
+var agentOpts agentOptions
+
+func init() {
+	flags := cmdAgent.PersistentFlags()
+
+	flags.Uint32VarP(&agentOpts.port, "port", "p", vmagent.DefaultPort, "vsock port to listen on")
+
+	CmdToolbox.AddCommand(cmdAgent)
+}
+
+func agentRun(cmd *cobra.Command, args []string) {
+	fd, err := unix.Socket(unix.AF_VSOCK, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		log.Fatalf("failed to create vsock socket: %v", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrVM{CID: unix.VMADDR_CID_ANY, Port: agentOpts.port}); err != nil {
+		log.Fatalf("failed to bind vsock port %d: %v", agentOpts.port, err)
+	}
+	if err := unix.Listen(fd, 16); err != nil {
+		log.Fatalf("failed to listen on vsock port %d: %v", agentOpts.port, err)
+	}
+
+	for {
+		nfd, _, err := unix.Accept4(fd, unix.SOCK_CLOEXEC)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			log.Fatalf("failed to accept connection: %v", err)
+		}
+		conn := os.NewFile(uintptr(nfd), fmt.Sprintf("vsock:%d", nfd))
+		go func() {
+			if err := vmagent.Serve(conn); err != nil {
+				log.Printf("request failed: %v", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"log"
+	"net"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdCheckTCP = &cobra.Command{
+	Use:   "check-tcp address",
+	Run:   checkTCPRun,
+	Short: "check that the provided tcp address accepts connections",
+	Args:  cobra.ExactArgs(1),
+}
+
+func init() {
+	CmdToolbox.AddCommand(cmdCheckTCP)
+}
+
+func checkTCPRun(cmd *cobra.Command, args []string) {
+	conn, err := net.DialTimeout("tcp", args[0], 1*time.Second)
+	if err != nil {
+		log.Fatalf("failed to connect to %q: %v", args[0], err)
+	}
+	conn.Close()
+}
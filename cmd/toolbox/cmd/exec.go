@@ -20,7 +20,6 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -79,7 +78,7 @@ func execRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatalf("failed to find executable %q: %v", args[0], err)
 	}
-	if err := syscall.Exec(p, args, env); err != nil {
+	if err := execProcess(p, args, env); err != nil {
 		log.Fatalf("failed to exec: %v", err)
 	}
 }
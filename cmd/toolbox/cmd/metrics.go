@@ -0,0 +1,152 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	metricsFileName = "agola-metrics.json"
+
+	maxMetrics          = 100
+	maxMetricNameLength = 100
+)
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+var cmdSetMetric = &cobra.Command{
+	Use:   "set-metric name=value...",
+	Run:   setMetricRun,
+	Short: "set the provided numeric metrics (i.e. bundle_size=1024 coverage=83.5). They will be saved in the run by the executor at the end of the current step",
+}
+
+var cmdGetMetrics = &cobra.Command{
+	Use:    "get-metrics",
+	Run:    getMetricsRun,
+	Short:  "returns the metrics set by the task steps in json format",
+	Hidden: true,
+}
+
+func init() {
+	CmdToolbox.AddCommand(cmdSetMetric)
+	CmdToolbox.AddCommand(cmdGetMetrics)
+}
+
+// metricsFilePath returns the path of the file containing the metrics. Like
+// the run metadata file it can be overridden by the executor driver using the
+// AGOLA_METRICS_FILE env var
+func metricsFilePath() string {
+	if p := os.Getenv("AGOLA_METRICS_FILE"); p != "" {
+		return p
+	}
+	dir := "/tmp"
+	if runtime.GOOS == "windows" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, metricsFileName)
+}
+
+func readMetrics() (map[string]float64, error) {
+	metrics := map[string]float64{}
+
+	data, err := ioutil.ReadFile(metricsFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return metrics, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	if len(data) == 0 {
+		return metrics, nil
+	}
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return metrics, nil
+}
+
+func setMetricRun(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		log.Fatalf("no metrics specified")
+	}
+
+	metrics, err := readMetrics()
+	if err != nil {
+		log.Fatalf("failed to read metrics: %v", err)
+	}
+
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			log.Fatalf("wrong metric %q, must be in the name=value format", arg)
+		}
+		name, valueS := kv[0], kv[1]
+		if len(name) > maxMetricNameLength || !metricNameRegexp.MatchString(name) {
+			log.Fatalf("invalid metric name %q", name)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(valueS), 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			log.Fatalf("metric %q value %q isn't a valid number", name, valueS)
+		}
+		metrics[name] = value
+	}
+	if len(metrics) > maxMetrics {
+		log.Fatalf("too many metrics (max %d)", maxMetrics)
+	}
+
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		log.Fatalf("failed to marshal metrics: %v", err)
+	}
+
+	// the file is rewritten in place (and not renamed) and made world
+	// writable since steps could be executed by different users
+	f, err := os.OpenFile(metricsFilePath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		log.Fatalf("failed to open metrics file: %v", err)
+	}
+	defer f.Close()
+	// ignore the error since the file could be owned by another user
+	_ = f.Chmod(0666)
+	if _, err := f.Write(data); err != nil {
+		log.Fatalf("failed to write metrics file: %v", err)
+	}
+}
+
+func getMetricsRun(cmd *cobra.Command, args []string) {
+	metrics, err := readMetrics()
+	if err != nil {
+		log.Fatalf("failed to read metrics: %v", err)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(metrics); err != nil {
+		log.Fatalf("failed to write metrics: %v", err)
+	}
+}
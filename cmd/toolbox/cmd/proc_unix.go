@@ -0,0 +1,47 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func childsReaper() {
+	var sigs = make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGCHLD)
+
+	for {
+		for range sigs {
+			for {
+				var wstatus syscall.WaitStatus
+				if _, err := syscall.Wait4(-1, &wstatus, syscall.WNOHANG|syscall.WUNTRACED|syscall.WCONTINUED, nil); errors.Is(err, syscall.EINTR) {
+					continue
+				}
+				break
+			}
+		}
+	}
+}
+
+// execProcess replaces the current process with the provided executable
+func execProcess(p string, args, env []string) error {
+	return syscall.Exec(p, args, env)
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+
+	"agola.io/agola/internal/errors"
+)
+
+// childsReaper does nothing since on windows there're no zombie processes to
+// reap
+func childsReaper() {}
+
+// execProcess executes the provided executable as a child process, since
+// windows doesn't support replacing the current process, and exits with its
+// exit code
+func execProcess(p string, args, env []string) error {
+	cmd := exec.Command(p, args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		return errors.WithStack(err)
+	}
+	os.Exit(0)
+
+	return nil
+}
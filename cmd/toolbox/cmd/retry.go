@@ -0,0 +1,106 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"agola.io/agola/internal/errors"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdRetry = &cobra.Command{
+	Use:   "retry -- command [args...]",
+	Run:   retryRun,
+	Short: "executes the provided command retrying it when it exits with a non zero exit code",
+	Args:  cobra.MinimumNArgs(1),
+}
+
+type retryOptions struct {
+	retries  int
+	interval time.Duration
+	backoff  float64
+}
+
+var retryOpts retryOptions
+
+func init() {
+	flags := cmdRetry.Flags()
+
+	flags.IntVar(&retryOpts.retries, "retries", 0, "number of retries")
+	flags.DurationVar(&retryOpts.interval, "interval", 5*time.Second, "time to wait between retries")
+	flags.Float64Var(&retryOpts.backoff, "backoff", 1, "factor the time to wait is multiplied by after every retry")
+
+	CmdToolbox.AddCommand(cmdRetry)
+}
+
+func retryRun(cmd *cobra.Command, args []string) {
+	if retryOpts.retries < 0 {
+		log.Fatalf("negative retries")
+	}
+	if retryOpts.backoff < 1 {
+		log.Fatalf("backoff must be greater or equal than 1")
+	}
+
+	attempts := retryOpts.retries + 1
+	interval := retryOpts.interval
+	var exitCode int
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			fmt.Printf("--- attempt %d of %d ---\n", attempt, attempts)
+		}
+
+		var err error
+		exitCode, err = runCommand(args)
+		if err != nil {
+			log.Fatalf("failed to execute command: %v", err)
+		}
+		if exitCode == 0 {
+			return
+		}
+
+		if attempt < attempts {
+			fmt.Printf("\n--- attempt %d of %d failed with exit code %d, retrying in %s ---\n", attempt, attempts, exitCode, interval)
+			time.Sleep(interval)
+			interval = time.Duration(float64(interval) * retryOpts.backoff)
+		}
+	}
+
+	os.Exit(exitCode)
+}
+
+// runCommand executes the command attached to the toolbox standard streams and
+// returns its exit code
+func runCommand(args []string) (int, error) {
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	if err := c.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return -1, err
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	runMetaFileName = "agola-run-meta.json"
+
+	maxRunMetaKeys        = 100
+	maxRunMetaKeyLength   = 100
+	maxRunMetaValueLength = 4096
+)
+
+var runMetaKeyRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+var cmdSetRunMeta = &cobra.Command{
+	Use:   "set-run-meta key=value...",
+	Run:   setRunMetaRun,
+	Short: "set the provided run metadata. They will be saved in the run by the executor at the end of the current step",
+}
+
+var cmdGetRunMeta = &cobra.Command{
+	Use:    "get-run-meta",
+	Run:    getRunMetaRun,
+	Short:  "returns the run metadata set by the task steps in json format",
+	Hidden: true,
+}
+
+func init() {
+	CmdToolbox.AddCommand(cmdSetRunMeta)
+	CmdToolbox.AddCommand(cmdGetRunMeta)
+}
+
+// runMetaFilePath returns the path of the file containing the run metadata.
+// It can be overridden by the executor driver using the AGOLA_RUN_META_FILE
+// env var (i.e. when the tmp dir is shared by multiple tasks). A fixed dir is
+// used on unix since the TMPDIR env var could be changed by the steps
+func runMetaFilePath() string {
+	if p := os.Getenv("AGOLA_RUN_META_FILE"); p != "" {
+		return p
+	}
+	dir := "/tmp"
+	if runtime.GOOS == "windows" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, runMetaFileName)
+}
+
+func readRunMeta() (map[string]string, error) {
+	meta := map[string]string{}
+
+	data, err := ioutil.ReadFile(runMetaFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	if len(data) == 0 {
+		return meta, nil
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return meta, nil
+}
+
+func setRunMetaRun(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		log.Fatalf("no run metadata specified")
+	}
+
+	meta, err := readRunMeta()
+	if err != nil {
+		log.Fatalf("failed to read run metadata: %v", err)
+	}
+
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			log.Fatalf("wrong run metadata %q, must be in the key=value format", arg)
+		}
+		key, value := kv[0], kv[1]
+		if len(key) > maxRunMetaKeyLength || !runMetaKeyRegexp.MatchString(key) {
+			log.Fatalf("invalid run metadata key %q", key)
+		}
+		if len(value) > maxRunMetaValueLength {
+			log.Fatalf("run metadata %q value is too long (max %d bytes)", key, maxRunMetaValueLength)
+		}
+		meta[key] = value
+	}
+	if len(meta) > maxRunMetaKeys {
+		log.Fatalf("too many run metadata (max %d)", maxRunMetaKeys)
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		log.Fatalf("failed to marshal run metadata: %v", err)
+	}
+
+	// the file is rewritten in place (and not renamed) and made world
+	// writable since steps could be executed by different users
+	f, err := os.OpenFile(runMetaFilePath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		log.Fatalf("failed to open run metadata file: %v", err)
+	}
+	defer f.Close()
+	// ignore the error since the file could be owned by another user
+	_ = f.Chmod(0666)
+	if _, err := f.Write(data); err != nil {
+		log.Fatalf("failed to write run metadata file: %v", err)
+	}
+}
+
+func getRunMetaRun(cmd *cobra.Command, args []string) {
+	meta, err := readRunMeta()
+	if err != nil {
+		log.Fatalf("failed to read run metadata: %v", err)
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(meta); err != nil {
+		log.Fatalf("failed to write run metadata: %v", err)
+	}
+}
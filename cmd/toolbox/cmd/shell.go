@@ -17,7 +17,6 @@ package cmd
 import (
 	"log"
 	"os"
-	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -33,7 +32,7 @@ func init() {
 }
 
 func shellRun(cmd *cobra.Command, args []string) {
-	filename, err := createFile(os.Stdin)
+	filename, err := createFile(os.Stdin, "")
 	if err != nil {
 		log.Fatalf("failed to write file: %v", err)
 	}
@@ -41,7 +40,7 @@ func shellRun(cmd *cobra.Command, args []string) {
 	env := os.Environ()
 
 	args = append(args, filename)
-	if err := syscall.Exec(args[0], args, env); err != nil {
+	if err := execProcess(args[0], args, env); err != nil {
 		log.Fatalf("failed to exec: %v", err)
 	}
 }
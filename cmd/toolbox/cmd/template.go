@@ -22,13 +22,16 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
 	"agola.io/agola/internal/errors"
 
+	"github.com/bmatcuk/doublestar"
 	"github.com/spf13/cobra"
 )
 
@@ -94,6 +97,69 @@ func sha256sum(filename string) (string, error) {
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
+// checksum returns the sha256 checksum of the paths and contents of all the
+// files, under the current dir, matching one of the provided patterns. Files
+// are read in lexical order, so the result doesn't depend on the patterns
+// order.
+func checksum(patterns ...string) (string, error) {
+	if len(patterns) == 0 {
+		return "", errors.New("no patterns provided")
+	}
+
+	h := sha256.New()
+	matched := false
+	err := filepath.Walk(".", func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		match := false
+		for _, pattern := range patterns {
+			ok, err := doublestar.Match(pattern, filepath.ToSlash(path))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if ok {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return nil
+		}
+		matched = true
+
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer f.Close()
+
+		fmt.Fprintf(h, "%s\x00", filepath.ToSlash(path))
+		if _, err := io.Copy(h, f); err != nil {
+			return errors.WithStack(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if !matched {
+		return "", errors.Errorf("no files matching patterns %q", patterns)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// branch returns the git branch of the run
+func branch() string {
+	return os.Getenv("AGOLA_GIT_BRANCH")
+}
+
 type tmplData struct {
 	Environment map[string]string
 }
@@ -103,6 +169,8 @@ func templateRun(cmd *cobra.Command, args []string) {
 	funcMap := map[string]interface{}{
 		"md5sum":    md5sum,
 		"sha256sum": sha256sum,
+		"checksum":  checksum,
+		"branch":    branch,
 		"env":       func(s string) string { return os.Getenv(s) },
 		"os":        func() string { return runtime.GOOS },
 		"arch":      func() string { return runtime.GOARCH },
@@ -122,7 +190,13 @@ func templateRun(cmd *cobra.Command, args []string) {
 		log.Fatalf("failed to parse template: %v", err)
 	}
 
-	data := &tmplData{}
+	data := &tmplData{Environment: map[string]string{}}
+	for _, e := range os.Environ() {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) == 2 {
+			data.Environment[kv[0]] = kv[1]
+		}
+	}
 	if err := tmpl.Execute(os.Stdout, data); err != nil {
 		log.Fatalf("failed to execute template: %v", err)
 	}
@@ -0,0 +1,182 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for p, data := range files {
+		fp := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if err := ioutil.WriteFile(fp, []byte(data), 0644); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+}
+
+func testChecksum(t *testing.T, files map[string]string, patterns ...string) (string, error) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, files)
+
+	curDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer func() {
+		if err := os.Chdir(curDir); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}()
+
+	return checksum(patterns...)
+}
+
+func TestChecksum(t *testing.T) {
+	files := map[string]string{
+		"go.mod":           "module example.com/test",
+		"go.sum":           "example.com/dep v1.0.0 h1:abc",
+		"web/package.json": "{}",
+		"web/src/main.js":  "console.log('main')",
+	}
+
+	base, err := testChecksum(t, files, "**/go.sum", "**/package.json")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(base) != 64 {
+		t.Fatalf("expected a sha256 hex checksum, got %q", base)
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		patterns []string
+		same     bool
+		err      bool
+	}{
+		{
+			name:     "same files and patterns",
+			files:    files,
+			patterns: []string{"**/go.sum", "**/package.json"},
+			same:     true,
+		},
+		{
+			name:     "patterns in different order",
+			files:    files,
+			patterns: []string{"**/package.json", "**/go.sum"},
+			same:     true,
+		},
+		{
+			name: "changed not matching file",
+			files: map[string]string{
+				"go.mod":           "module example.com/other",
+				"go.sum":           "example.com/dep v1.0.0 h1:abc",
+				"web/package.json": "{}",
+				"web/src/main.js":  "console.log('other')",
+			},
+			patterns: []string{"**/go.sum", "**/package.json"},
+			same:     true,
+		},
+		{
+			name: "changed matching file content",
+			files: map[string]string{
+				"go.mod":           "module example.com/test",
+				"go.sum":           "example.com/dep v1.1.0 h1:def",
+				"web/package.json": "{}",
+			},
+			patterns: []string{"**/go.sum", "**/package.json"},
+			same:     false,
+		},
+		{
+			name: "moved matching file",
+			files: map[string]string{
+				"go.mod":                "module example.com/test",
+				"go.sum":                "example.com/dep v1.0.0 h1:abc",
+				"frontend/package.json": "{}",
+			},
+			patterns: []string{"**/go.sum", "**/package.json"},
+			same:     false,
+		},
+		{
+			name:     "no patterns",
+			files:    files,
+			patterns: []string{},
+			err:      true,
+		},
+		{
+			name:     "no matching files",
+			files:    files,
+			patterns: []string{"**/Cargo.lock"},
+			err:      true,
+		},
+		{
+			name:     "bad pattern",
+			files:    files,
+			patterns: []string{"[go.sum"},
+			err:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum, err := testChecksum(t, tt.files, tt.patterns...)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got checksum %q", sum)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if same := sum == base; same != tt.same {
+				t.Fatalf("expected same checksum: %t, got checksum %q, base checksum %q", tt.same, sum, base)
+			}
+		})
+	}
+}
+
+func TestBranch(t *testing.T) {
+	tests := []struct {
+		name   string
+		branch string
+	}{
+		{name: "simple branch", branch: "master"},
+		{name: "branch with path separators", branch: "feature/new-ui"},
+		{name: "no branch", branch: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AGOLA_GIT_BRANCH", tt.branch)
+
+			// the branch is returned as is since path separators are allowed
+			// in cache keys
+			if b := branch(); b != tt.branch {
+				t.Fatalf("expected branch %q, got %q", tt.branch, b)
+			}
+		})
+	}
+}
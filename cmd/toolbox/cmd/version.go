@@ -0,0 +1,47 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"agola.io/agola/cmd"
+	"agola.io/agola/internal/toolbox/protocol"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdVersion = &cobra.Command{
+	Use:   "version",
+	Run:   versionRun,
+	Short: "returns the toolbox version and protocol version used by the executor",
+}
+
+func init() {
+	CmdToolbox.AddCommand(cmdVersion)
+}
+
+func versionRun(c *cobra.Command, args []string) {
+	info := &protocol.Info{
+		Version:         cmd.Version,
+		ProtocolVersion: protocol.Version,
+	}
+
+	if err := json.NewEncoder(os.Stdout).Encode(info); err != nil {
+		log.Fatalf("failed to encode version info: %v", err)
+	}
+}
@@ -17,25 +17,36 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"path"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"agola.io/agola/internal/errors"
 	itypes "agola.io/agola/internal/services/types"
 	"agola.io/agola/internal/util"
+	cstypes "agola.io/agola/services/configstore/types"
+	rstypes "agola.io/agola/services/runservice/types"
 	"agola.io/agola/services/types"
 
+	"github.com/bmatcuk/doublestar"
 	"github.com/ghodss/yaml"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 const (
-	maxConfigSize     = 1024 * 1024 // 1MiB
-	maxRunNameLength  = 100
-	maxTaskNameLength = 100
-	maxStepNameLength = 100
+	maxConfigSize             = 1024 * 1024 // 1MiB
+	maxRunNameLength          = 100
+	maxConcurrencyGroupLength = 100
+	maxTaskNameLength         = 100
+	maxStepNameLength         = 100
+	// max container name length, it must be a valid hostname label
+	maxContainerNameLength = 63
 
 	defaultWorkingDir = "~/project"
+
+	defaultDockerLayerCacheDir = "/tmp/agola-docker-layer-cache"
 )
 
 type ConfigFormat int
@@ -45,22 +56,181 @@ const (
 	ConfigFormatJSON ConfigFormat = iota
 	ConfigFormatJsonnet
 	ConfigFormatStarlark
+	// ConfigFormatYAML is parsed like ConfigFormatJSON but kept separated to
+	// report the real config format
+	ConfigFormatYAML
 )
 
+// FormatFromFilename returns the config format from the config file name
+// extension
+func FormatFromFilename(filename string) (ConfigFormat, error) {
+	switch path.Ext(filename) {
+	case ".star":
+		return ConfigFormatStarlark, nil
+	case ".jsonnet":
+		return ConfigFormatJsonnet, nil
+	case ".json":
+		return ConfigFormatJSON, nil
+	case ".yml", ".yaml":
+		return ConfigFormatYAML, nil
+	default:
+		return 0, errors.Errorf("unknown config file %q extension", filename)
+	}
+}
+
 var (
 	regExpDelimiters = []string{"/", "#"}
+
+	containerNameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	capabilityRegexp    = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	inputNameRegexp     = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 )
 
 type Config struct {
 	Runs []*Run `json:"runs"`
 
 	DockerRegistriesAuth map[string]*DockerRegistryAuth `json:"docker_registries_auth"`
+
+	// Include are the config files whose runs, docker registries auth and
+	// task templates are merged in the config
+	Include []*Include `json:"include"`
+
+	// TaskTemplates are the task definitions, by name, that tasks can extend
+	TaskTemplates map[string]*Task `json:"task_templates"`
+
+	// Inputs are the parameters provided when manually creating the runs
+	Inputs []*Input `json:"inputs"`
+}
+
+type InputType string
+
+const (
+	InputTypeString  InputType = "string"
+	InputTypeChoice  InputType = "choice"
+	InputTypeBoolean InputType = "boolean"
+)
+
+// Input defines a parameter provided when manually creating a run. Its value
+// is set as a run variable and as the AGOLA_INPUT_<NAME> environment variable
+type Input struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Type        InputType `json:"type"`
+	// Required inputs without a default must be provided
+	Required bool         `json:"required"`
+	Default  InputDefault `json:"default"`
+	// Options are the allowed values of a choice input
+	Options []string `json:"options"`
+}
+
+// InputDefault is an input default value. Boolean and number values are
+// converted to their string representation
+type InputDefault string
+
+func (d *InputDefault) UnmarshalJSON(b []byte) error {
+	var ival interface{}
+	if err := json.Unmarshal(b, &ival); err != nil {
+		return errors.WithStack(err)
+	}
+	switch defaultValue := ival.(type) {
+	case string:
+		*d = InputDefault(defaultValue)
+	case bool:
+		*d = InputDefault(strconv.FormatBool(defaultValue))
+	case float64:
+		*d = InputDefault(strconv.FormatFloat(defaultValue, 'f', -1, 64))
+	default:
+		return errors.Errorf("unknown input default format: %v", defaultValue)
+	}
+
+	return nil
+}
+
+// inputValue validates the provided input value and returns it in its
+// canonical form
+func inputValue(in *Input, value string) (string, error) {
+	switch in.Type {
+	case InputTypeChoice:
+		if !util.StringInSlice(in.Options, value) {
+			return "", errors.Errorf("input %q value %q isn't one of the allowed options", in.Name, value)
+		}
+	case InputTypeBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", errors.Errorf("input %q value %q isn't a boolean", in.Name, value)
+		}
+		value = strconv.FormatBool(b)
+	}
+
+	return value, nil
+}
+
+// InputsValues returns the config inputs values: the provided values or, when
+// not provided, the inputs defaults. When checkRequired is true the required
+// inputs without a default must be provided.
+func (c *Config) InputsValues(values map[string]string, checkRequired bool) (map[string]string, error) {
+	for name := range values {
+		found := false
+		for _, in := range c.Inputs {
+			if in.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("input %q isn't defined in the config", name)
+		}
+	}
+
+	inputs := map[string]string{}
+	for _, in := range c.Inputs {
+		value, ok := values[in.Name]
+		if !ok {
+			if in.Default == "" {
+				if in.Required && checkRequired {
+					return nil, errors.Errorf("required input %q not provided", in.Name)
+				}
+				continue
+			}
+			value = string(in.Default)
+		}
+		value, err := inputValue(in, value)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		inputs[in.Name] = value
+	}
+
+	return inputs, nil
 }
 
 type RuntimeType string
 
 const (
 	RuntimeTypePod RuntimeType = "pod"
+	// RuntimeTypeHost executes the task directly on the executor host. It's
+	// accepted only by executors using the host driver
+	RuntimeTypeHost RuntimeType = "host"
+)
+
+type ExecutorAffinity string
+
+const (
+	// ExecutorAffinityRun prefers executors running other tasks of the same run
+	ExecutorAffinityRun ExecutorAffinity = "affinity"
+	// ExecutorAntiAffinityRun prefers executors not running other tasks of the
+	// same run
+	ExecutorAntiAffinityRun ExecutorAffinity = "anti_affinity"
+)
+
+type RunWorkspace string
+
+const (
+	// RunWorkspacePersistent keeps the tasks working dir across the run tasks:
+	// every task saves its whole working dir and the dependent tasks restore
+	// it, without the need of cloning the sources again or of explicit
+	// workspace steps
+	RunWorkspacePersistent RunWorkspace = "persistent"
 )
 
 type DockerRegistryAuthType string
@@ -68,6 +238,7 @@ type DockerRegistryAuthType string
 const (
 	DockerRegistryAuthTypeBasic       DockerRegistryAuthType = "basic"
 	DockerRegistryAuthTypeEncodedAuth DockerRegistryAuthType = "encodedauth"
+	DockerRegistryAuthTypeToken       DockerRegistryAuthType = "token"
 )
 
 type DockerRegistryAuth struct {
@@ -80,22 +251,102 @@ type DockerRegistryAuth struct {
 	// encoded auth
 	Auth Value `json:"auth"`
 
+	// registry bearer token auth
+	Token Value `json:"token"`
+
 	// future auths like aws ecr auth
 }
 
 type Runtime struct {
-	Type       RuntimeType  `json:"type,omitempty"`
-	Arch       types.Arch   `json:"arch,omitempty"`
-	Containers []*Container `json:"containers,omitempty"`
+	Type           RuntimeType       `json:"type,omitempty"`
+	Arch           types.Arch        `json:"arch,omitempty"`
+	Containers     []*Container      `json:"containers,omitempty"`
+	ExecutorLabels map[string]string `json:"executor_labels,omitempty"`
+	// ExecutorAffinity is a scheduling hint to prefer or avoid the executors
+	// running other tasks of the same run
+	ExecutorAffinity ExecutorAffinity `json:"executor_affinity,omitempty"`
+	// Services are containers started alongside the task containers. The task
+	// steps are executed only when all the services are ready
+	Services []*Service `json:"services,omitempty"`
+	// GPUs is the number of GPUs assigned to the task main container. The task
+	// will be executed only by executors providing GPUs
+	GPUs int `json:"gpus,omitempty"`
+	// NodeSelector are the labels of the nodes where the task pod can be
+	// scheduled. Used only by the k8s driver
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	// Tolerations are the taints tolerated by the task pod. Used only by the
+	// k8s driver
+	Tolerations []*Toleration `json:"tolerations,omitempty"`
+}
+
+type Toleration struct {
+	Key string `json:"key,omitempty"`
+	// Operator is Exists or Equal. Defaults to Equal
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	// Effect is NoSchedule, PreferNoSchedule or NoExecute. When empty all
+	// the taint effects are tolerated
+	Effect string `json:"effect,omitempty"`
+}
+
+type Service struct {
+	Container
+	Readiness *Readiness `json:"readiness,omitempty"`
+}
+
+// Readiness defines the check executed to know when a service is ready. Only
+// one of TCPPort and Command must be defined
+type Readiness struct {
+	// TCPPort is the service port that must accept connections
+	TCPPort int `json:"tcp_port,omitempty"`
+	// Command is executed in the task main container using the task shell and
+	// must exit with a zero exit code
+	Command string `json:"command,omitempty"`
+	// TimeoutSeconds is the max time to wait for the service to be ready.
+	// When 0 the executor default is used
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
 }
 
 type Container struct {
+	// Name is the container name. When defined the other task containers can
+	// reach this container using it as hostname
+	Name        string           `json:"name,omitempty"`
 	Image       string           `json:"image,omitempty"`
 	Environment map[string]Value `json:"environment,omitempty"`
 	User        string           `json:"user"`
 	Privileged  bool             `json:"privileged"`
-	Entrypoint  string           `json:"entrypoint"`
-	Volumes     []Volume         `json:"volumes"`
+	// Entrypoint overrides the image entrypoint. In the main container it's
+	// used as a wrapper of the command that keeps the container running
+	Entrypoint string `json:"entrypoint"`
+	// Args overrides the image command. It cannot be defined for the main
+	// container
+	Args      []string   `json:"args,omitempty"`
+	Volumes   []Volume   `json:"volumes"`
+	Resources *Resources `json:"resources"`
+	// PullPolicy is the image pull policy (always, if-not-present or never)
+	PullPolicy string `json:"pull_policy,omitempty"`
+	// Capabilities are the linux capabilities added to or dropped from the
+	// container default ones
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	// Devices are the host device paths (i.e. /dev/fuse) made available inside
+	// the container at the same path
+	Devices []string `json:"devices,omitempty"`
+}
+
+type Capabilities struct {
+	Add  []string `json:"add,omitempty"`
+	Drop []string `json:"drop,omitempty"`
+}
+
+// Resources defines the container cpu and memory requests and limits
+type Resources struct {
+	Requests *ResourceList `json:"requests"`
+	Limits   *ResourceList `json:"limits"`
+}
+
+type ResourceList struct {
+	CPU    *resource.Quantity `json:"cpu"`
+	Memory *resource.Quantity `json:"memory"`
 }
 
 type Volume struct {
@@ -113,6 +364,96 @@ type Run struct {
 	Tasks                []*Task                        `json:"tasks"`
 	When                 *When                          `json:"when"`
 	DockerRegistriesAuth map[string]*DockerRegistryAuth `json:"docker_registries_auth"`
+	Labels               map[string]string              `json:"labels"`
+	// Workspace defines the run workspace mode
+	Workspace RunWorkspace `json:"workspace"`
+	// DependsOn are the names of the runs of the same config that must
+	// succeed before this run is started
+	DependsOn []string `json:"depends_on"`
+	// MinimalRun, when the run when paths conditions don't match the changed
+	// files, creates a run with all the tasks skipped instead of no run. In
+	// this way the run commit status is still reported (i.e. for docs only
+	// changes when the git source requires it to merge)
+	MinimalRun bool `json:"minimal_run"`
+	// Environment contains the environment variables set in every run task.
+	// The task environment variables with the same name override them
+	Environment map[string]Value `json:"environment,omitempty"`
+	// Defaults are the values used by the run tasks not defining them
+	Defaults *RunDefaults `json:"defaults"`
+	// Concurrency limits to one the running runs of the project with the
+	// same concurrency group
+	Concurrency *Concurrency `json:"concurrency"`
+	// FailFast, when a task fails, stops the running tasks and cancels the
+	// not started ones, excluding the tasks marked as always run
+	FailFast bool `json:"fail_fast"`
+	// Triggers are the downstream runs created in other projects when the run
+	// finishes successfully
+	Triggers []*Trigger `json:"triggers"`
+}
+
+// Trigger defines a downstream run created in another project of the same
+// owner on the provided branch or tag with the provided variables. The
+// downstream project must allow the upstream project to trigger its runs and
+// the variables cannot override the downstream project variables. Pull request
+// runs cannot trigger downstream runs
+type Trigger struct {
+	// Project is the project id or full path
+	Project   string            `json:"project"`
+	Branch    string            `json:"branch"`
+	Tag       string            `json:"tag"`
+	Variables map[string]string `json:"variables"`
+}
+
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyPolicyQueue keeps the new run queued until the running run
+	// of the same concurrency group finishes
+	ConcurrencyPolicyQueue ConcurrencyPolicy = "queue"
+	// ConcurrencyPolicyCancelInProgress stops the running and cancels the
+	// queued runs of the same concurrency group when a new run is created
+	ConcurrencyPolicyCancelInProgress ConcurrencyPolicy = "cancel_in_progress"
+)
+
+// Concurrency defines the run concurrency group. It's defined as the group
+// name or as a map with the group name and the policy
+type Concurrency struct {
+	Group  string            `json:"group"`
+	Policy ConcurrencyPolicy `json:"policy"`
+}
+
+type concurrency Concurrency
+
+func (c *Concurrency) UnmarshalJSON(b []byte) error {
+	var ival interface{}
+	if err := json.Unmarshal(b, &ival); err != nil {
+		return errors.WithStack(err)
+	}
+	switch concurrencyValue := ival.(type) {
+	case string:
+		*c = Concurrency{Group: concurrencyValue}
+	case map[string]interface{}:
+		var ci concurrency
+		if err := json.Unmarshal(b, &ci); err != nil {
+			return errors.WithStack(err)
+		}
+		*c = Concurrency(ci)
+	default:
+		return errors.Errorf("unknown concurrency format: %v", concurrencyValue)
+	}
+	if c.Policy == "" {
+		c.Policy = ConcurrencyPolicyQueue
+	}
+
+	return nil
+}
+
+// RunDefaults defines the task fields values used by the run tasks not
+// defining them
+type RunDefaults struct {
+	Runtime    *Runtime `json:"runtime"`
+	Shell      Shell    `json:"shell"`
+	WorkingDir string   `json:"working_dir"`
 }
 
 type Task struct {
@@ -120,14 +461,102 @@ type Task struct {
 	Runtime              *Runtime                       `json:"runtime"`
 	Environment          map[string]Value               `json:"environment,omitempty"`
 	WorkingDir           string                         `json:"working_dir"`
-	Shell                string                         `json:"shell"`
+	Shell                Shell                          `json:"shell"`
 	User                 string                         `json:"user"`
 	Steps                Steps                          `json:"steps"`
 	Depends              Depends                        `json:"depends"`
 	IgnoreFailure        bool                           `json:"ignore_failure"`
-	Approval             bool                           `json:"approval"`
+	Approval             Approval                       `json:"approval"`
 	When                 *When                          `json:"when"`
 	DockerRegistriesAuth map[string]*DockerRegistryAuth `json:"docker_registries_auth"`
+	Labels               map[string]string              `json:"labels"`
+	// AllowFailure marks the task as failed when it fails without failing
+	// the run. Its dependents are executed or skipped based on their depend
+	// conditions (i.e. "on_failure"). It's an alias of IgnoreFailure
+	AllowFailure bool `json:"allow_failure"`
+	// SkipWorkspace marks the task as not needing the sources or the
+	// workspace so clone and workspace steps aren't allowed
+	SkipWorkspace bool `json:"skip_workspace"`
+	// DockerLayerCache saves the buildkit layer cache exported by the docker
+	// builds executed in the task and restores it in the next runs
+	DockerLayerCache *DockerLayerCache `json:"docker_layer_cache"`
+	// Matrix expands the task in multiple tasks, one for every combination of
+	// the matrix axes values
+	Matrix *Matrix `json:"matrix"`
+	// Extends is the name of the task template the task definition is merged
+	// into
+	Extends string `json:"extends"`
+	// Timeout is the max duration of the task steps (i.e. 30m). When exceeded
+	// the task is stopped and marked as failed. When empty the installation
+	// default is used
+	Timeout string `json:"timeout"`
+	// Retries is the number of times the failed task is executed again in a
+	// new pod. The steps logs keep the output of all the attempts
+	Retries int `json:"retries"`
+	// RetryInterval is the time to wait before retrying the task (i.e. 30s).
+	// When empty the executor default is used
+	RetryInterval string `json:"retry_interval"`
+	// RetryBackoff is the factor the retry interval is multiplied by after
+	// every retry. When empty the retry interval is constant
+	RetryBackoff float64 `json:"retry_backoff"`
+	// AlwaysRun marks the task as executed also when the run has already
+	// failed: it isn't cancelled or stopped by the run fail fast
+	AlwaysRun bool `json:"always_run"`
+}
+
+// Approval defines if the task must be approved before being executed. It's
+// defined as a boolean or as a map restricting who can approve the task and
+// how many approvals are required
+type Approval struct {
+	// Required reports if the task needs an approval
+	Required bool `json:"-"`
+	// Users are the names of the users allowed to approve the task
+	Users []string `json:"users"`
+	// OrgRoles are the roles (owner, member) of the project organization
+	// members allowed to approve the task
+	OrgRoles []cstypes.MemberRole `json:"org_roles"`
+	// MinApprovals is the number of approvals required. Defaults to 1
+	MinApprovals int `json:"min_approvals"`
+}
+
+type approval Approval
+
+func (a *Approval) UnmarshalJSON(b []byte) error {
+	var ival interface{}
+	if err := json.Unmarshal(b, &ival); err != nil {
+		return errors.WithStack(err)
+	}
+	switch approvalValue := ival.(type) {
+	case nil:
+		*a = Approval{}
+	case bool:
+		*a = Approval{Required: approvalValue}
+	case map[string]interface{}:
+		var ai approval
+		if err := json.Unmarshal(b, &ai); err != nil {
+			return errors.WithStack(err)
+		}
+		*a = Approval(ai)
+		a.Required = true
+	default:
+		return errors.Errorf("unknown approval format: %v", approvalValue)
+	}
+
+	return nil
+}
+
+// DockerLayerCache defines a directory where the docker builds export and
+// import their buildkit local layer cache (i.e. docker buildx build
+// --cache-from type=local,src=$AGOLA_DOCKER_LAYER_CACHE_DIR --cache-to
+// type=local,dest=$AGOLA_DOCKER_LAYER_CACHE_DIR,mode=max). The directory is
+// restored from the cache before the task steps and saved to the cache after
+// them
+type DockerLayerCache struct {
+	// Key is the cache key prefix. It can be a template like the cache steps
+	// keys
+	Key string `json:"key"`
+	// Dir is the layer cache directory
+	Dir string `json:"dir"`
 }
 
 type DependCondition string
@@ -136,6 +565,9 @@ const (
 	DependConditionOnSuccess DependCondition = "on_success"
 	DependConditionOnFailure DependCondition = "on_failure"
 	DependConditionOnSkipped DependCondition = "on_skipped"
+	// DependConditionAlways matches a parent task finished with any status
+	// (i.e. to execute cleanup tasks)
+	DependConditionAlways DependCondition = "always"
 )
 
 type Depends []*Depend
@@ -153,6 +585,19 @@ type BaseStep struct {
 	Type string `json:"type"`
 	Name string `json:"name"`
 	When *When  `json:"when"`
+	// Timeout is the max step duration (i.e. 5m). When exceeded the step is
+	// stopped and the task marked as failed
+	Timeout string `json:"timeout"`
+}
+
+func (s *BaseStep) baseStep() *BaseStep { return s }
+
+// stepBase returns the base fields of a config step
+func stepBase(s Step) *BaseStep {
+	if bs, ok := s.(interface{ baseStep() *BaseStep }); ok {
+		return bs.baseStep()
+	}
+	return nil
 }
 
 type CloneStep struct {
@@ -166,8 +611,17 @@ type RunStep struct {
 	Command     string           `json:"command"`
 	Environment map[string]Value `json:"environment,omitempty"`
 	WorkingDir  string           `json:"working_dir"`
-	Shell       string           `json:"shell"`
+	Shell       Shell            `json:"shell"`
 	Tty         *bool            `json:"tty"`
+	// Retries is the number of times the command is executed again when it
+	// exits with a non zero exit code
+	Retries int `json:"retries,omitempty"`
+	// RetryInterval is the time to wait before retrying the command (i.e.
+	// 10s). When empty the toolbox default is used
+	RetryInterval string `json:"retry_interval,omitempty"`
+	// RetryBackoff is the factor the retry interval is multiplied by after
+	// every retry. When empty the retry interval is constant
+	RetryBackoff float64 `json:"retry_backoff,omitempty"`
 }
 
 type SaveToWorkspaceStep struct {
@@ -192,6 +646,30 @@ type RestoreCacheStep struct {
 	DestDir  string   `json:"dest_dir"`
 }
 
+// SaveArtifactsStep saves the provided contents as run artifacts that can be
+// downloaded or restored by the dependent tasks
+type SaveArtifactsStep struct {
+	BaseStep `json:",inline"`
+	Contents []*SaveContent `json:"contents"`
+}
+
+// RestoreArtifactsStep restores the artifacts saved by the provided tasks
+type RestoreArtifactsStep struct {
+	BaseStep `json:",inline"`
+	// Tasks are the names of the tasks, that must be dependencies of the
+	// task, whose artifacts are restored
+	Tasks   []string `json:"tasks"`
+	DestDir string   `json:"dest_dir"`
+}
+
+// TestReportStep collects the JUnit/XUnit XML test reports matching the
+// provided paths. It's executed also when a previous step of the task failed.
+type TestReportStep struct {
+	BaseStep `json:",inline"`
+	// Paths are the report files patterns relative to the task working dir
+	Paths []string `json:"paths"`
+}
+
 type SaveContent struct {
 	SourceDir string   `json:"source_dir"`
 	DestDir   string   `json:"dest_dir"`
@@ -274,6 +752,30 @@ func (s *Steps) UnmarshalJSON(b []byte) error {
 				}
 				s.Type = stepType
 				step = &s
+
+			case "save_artifacts":
+				var s SaveArtifactsStep
+				if err := json.Unmarshal(stepRaw, &s); err != nil {
+					return errors.WithStack(err)
+				}
+				s.Type = stepType
+				step = &s
+
+			case "restore_artifacts":
+				var s RestoreArtifactsStep
+				if err := json.Unmarshal(stepRaw, &s); err != nil {
+					return errors.WithStack(err)
+				}
+				s.Type = stepType
+				step = &s
+
+			case "test_report":
+				var s TestReportStep
+				if err := json.Unmarshal(stepRaw, &s); err != nil {
+					return errors.WithStack(err)
+				}
+				s.Type = stepType
+				step = &s
 			default:
 				return errors.Errorf("unknown step type: %s", stepType)
 			}
@@ -341,6 +843,30 @@ func (s *Steps) UnmarshalJSON(b []byte) error {
 					}
 					s.Type = stepType
 					step = &s
+
+				case "save_artifacts":
+					var s SaveArtifactsStep
+					if err := json.Unmarshal(stepSpecRaw, &s); err != nil {
+						return errors.WithStack(err)
+					}
+					s.Type = stepType
+					step = &s
+
+				case "restore_artifacts":
+					var s RestoreArtifactsStep
+					if err := json.Unmarshal(stepSpecRaw, &s); err != nil {
+						return errors.WithStack(err)
+					}
+					s.Type = stepType
+					step = &s
+
+				case "test_report":
+					var s TestReportStep
+					if err := json.Unmarshal(stepSpecRaw, &s); err != nil {
+						return errors.WithStack(err)
+					}
+					s.Type = stepType
+					step = &s
 				default:
 					return errors.Errorf("unknown step type: %s", stepType)
 				}
@@ -464,12 +990,75 @@ func (val *Value) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// Shell is the shell executing the run steps commands. It's defined as a
+// known shell name (pwsh, powershell), as a custom interpreter command line or
+// as a custom interpreter args list. The command file is provided to the
+// interpreter as the last argument.
+// Any other value, including plain sh and bash, is used verbatim as the
+// interpreter command line like before the introduction of the known shells,
+// so options like errexit must be explicitly provided (i.e. "/bin/sh -e")
+type Shell string
+
+// knownShells are the command lines of the shells that can be referenced by
+// name. They're only shells that cannot execute the command file without
+// additional options
+var knownShells = map[Shell]string{
+	"pwsh":       "pwsh -NoProfile -NonInteractive -ExecutionPolicy Bypass -File",
+	"powershell": "powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -File",
+}
+
+// CommandLine returns the shell command line
+func (s Shell) CommandLine() string {
+	if cl, ok := knownShells[s]; ok {
+		return cl
+	}
+	return string(s)
+}
+
+func (s *Shell) UnmarshalJSON(b []byte) error {
+	var ival interface{}
+	if err := json.Unmarshal(b, &ival); err != nil {
+		return errors.WithStack(err)
+	}
+	switch shellValue := ival.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = Shell(shellValue)
+	case []interface{}:
+		args := make([]string, len(shellValue))
+		for i, v := range shellValue {
+			arg, ok := v.(string)
+			if !ok {
+				return errors.Errorf("unknown shell arg format: %v", v)
+			}
+			// the runservice and the executor handle the shell as a space
+			// separated command line
+			if arg == "" || strings.ContainsAny(arg, " \t\n") {
+				return errors.Errorf("shell arg %q cannot be empty or contain whitespaces", arg)
+			}
+			args[i] = arg
+		}
+		*s = Shell(strings.Join(args, " "))
+	default:
+		return errors.Errorf("unknown shell format: %v", ival)
+	}
+	return nil
+}
+
 type When types.When
 
 type when struct {
-	Branch interface{} `json:"branch"`
-	Tag    interface{} `json:"tag"`
-	Ref    interface{} `json:"ref"`
+	Branch   interface{} `json:"branch"`
+	Tag      interface{} `json:"tag"`
+	Ref      interface{} `json:"ref"`
+	Paths    interface{} `json:"paths"`
+	Schedule interface{} `json:"schedule"`
+	Message  interface{} `json:"message"`
+	// Expression is a boolean expression over the run annotations and variables
+	Expression string `json:"expression"`
+	// PathsIgnore is a shortcut for the paths excludes
+	PathsIgnore interface{} `json:"paths_ignore"`
 }
 
 func (w *When) ToWhen() *types.When {
@@ -505,6 +1094,61 @@ func (w *When) UnmarshalJSON(b []byte) error {
 		}
 	}
 
+	if wi.Schedule != nil {
+		w.Schedule, err = parseWhenConditions(wi.Schedule)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if wi.Message != nil {
+		w.Message, err = parseWhenConditions(wi.Message)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if wi.Expression != "" {
+		if err := types.ValidateWhenExpression(wi.Expression); err != nil {
+			return errors.WithStack(err)
+		}
+		w.Expression = wi.Expression
+	}
+
+	if wi.Paths != nil {
+		w.Paths, err = parseWhenConditions(wi.Paths)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if wi.PathsIgnore != nil {
+		ignore, err := parseStringOrSlice(wi.PathsIgnore)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		exclude, err := parseWhenConditionSlice(ignore)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if w.Paths == nil {
+			w.Paths = &types.WhenConditions{}
+		}
+		w.Paths.Exclude = append(w.Paths.Exclude, exclude...)
+	}
+
+	if w.Paths != nil {
+		for _, c := range append(w.Paths.Include, w.Paths.Exclude...) {
+			if c.Type != types.WhenConditionTypeSimple {
+				continue
+			}
+			// matching the pattern against itself reports syntax errors
+			if _, err := doublestar.Match(c.Match, c.Match); err != nil {
+				return errors.Errorf("wrong paths glob pattern %q", c.Match)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -656,10 +1300,40 @@ type ConfigContext struct {
 	Branch        string            `json:"branch"`
 	Tag           string            `json:"tag"`
 	PullRequestID string            `json:"pull_request_id"`
+	Schedule      string            `json:"schedule"`
 	CommitSHA     string            `json:"commit_sha"`
+
+	// Env contains the environment variables that can be read by jsonnet
+	// configs using the env native function
+	Env map[string]string `json:"-"`
+	// FetchFile returns the content of a repository file at the run commit.
+	// It's used by the jsonnet hashFiles native function and by the local
+	// config includes
+	FetchFile func(path string) ([]byte, error) `json:"-"`
+	// FetchRemoteFile returns the content of a remote url. It's used by the
+	// remote config includes
+	FetchRemoteFile func(url string) ([]byte, error) `json:"-"`
+	// FetchRepoFile returns the content of a file of another repository of the
+	// same git source. It's used by the repo config includes
+	FetchRepoFile func(repoPath, ref, path string) ([]byte, error) `json:"-"`
 }
 
 func ParseConfig(configData []byte, format ConfigFormat, configContext *ConfigContext) (*Config, error) {
+	config, err := parseConfigData(configData, format, configContext)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := mergeIncludes(config, configContext); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return config, checkConfig(config)
+}
+
+// parseConfigData generates (for jsonnet and starlark) and unmarshals the
+// config without checking it
+func parseConfigData(configData []byte, format ConfigFormat, configContext *ConfigContext) (*Config, error) {
 	// TODO(sgotti) execute jsonnet and starlark executor in a
 	// separate process to avoid issues with malformat config that
 	// could lead to infinite executions and memory exhaustion
@@ -689,7 +1363,214 @@ func ParseConfig(configData []byte, format ConfigFormat, configContext *ConfigCo
 		return nil, errors.Wrapf(err, "failed to unmarshal config")
 	}
 
-	return &config, checkConfig(&config)
+	return &config, nil
+}
+
+func validateResources(r *Resources) error {
+	if r == nil {
+		return nil
+	}
+
+	for _, rl := range []*ResourceList{r.Requests, r.Limits} {
+		if rl == nil {
+			continue
+		}
+		if rl.CPU != nil && rl.CPU.Sign() < 0 {
+			return errors.Errorf("negative cpu resource %q", rl.CPU.String())
+		}
+		if rl.Memory != nil && rl.Memory.Sign() < 0 {
+			return errors.Errorf("negative memory resource %q", rl.Memory.String())
+		}
+	}
+
+	if r.Requests != nil && r.Limits != nil {
+		if r.Requests.CPU != nil && r.Limits.CPU != nil && r.Requests.CPU.Cmp(*r.Limits.CPU) > 0 {
+			return errors.Errorf("cpu request %q greater than cpu limit %q", r.Requests.CPU.String(), r.Limits.CPU.String())
+		}
+		if r.Requests.Memory != nil && r.Limits.Memory != nil && r.Requests.Memory.Cmp(*r.Limits.Memory) > 0 {
+			return errors.Errorf("memory request %q greater than memory limit %q", r.Requests.Memory.String(), r.Limits.Memory.String())
+		}
+	}
+
+	return nil
+}
+
+func validateToleration(t *Toleration) error {
+	switch t.Operator {
+	case "", "Equal":
+		if t.Key == "" {
+			return errors.Errorf("key must be defined with operator Equal")
+		}
+	case "Exists":
+		if t.Value != "" {
+			return errors.Errorf("value cannot be defined with operator Exists")
+		}
+	default:
+		return errors.Errorf("unknown operator %q", t.Operator)
+	}
+
+	switch t.Effect {
+	case "", "NoSchedule", "PreferNoSchedule", "NoExecute":
+	default:
+		return errors.Errorf("unknown effect %q", t.Effect)
+	}
+
+	return nil
+}
+
+func checkReadiness(r *Readiness) error {
+	if r == nil {
+		return nil
+	}
+	if (r.TCPPort == 0) == (r.Command == "") {
+		return errors.Errorf("readiness must define one of tcp_port or command")
+	}
+	if r.TCPPort < 0 || r.TCPPort > 65535 {
+		return errors.Errorf("readiness: invalid tcp_port %d", r.TCPPort)
+	}
+	if r.TimeoutSeconds < 0 {
+		return errors.Errorf("readiness: negative timeout_seconds")
+	}
+
+	return nil
+}
+
+func checkCapabilities(c *Capabilities) error {
+	if c == nil {
+		return nil
+	}
+	for _, capability := range append(append([]string{}, c.Add...), c.Drop...) {
+		if !capabilityRegexp.MatchString(capability) {
+			return errors.Errorf("invalid capability %q", capability)
+		}
+	}
+
+	return nil
+}
+
+func checkDockerRegistriesAuth(auths map[string]*DockerRegistryAuth) error {
+	for regname, auth := range auths {
+		if auth == nil {
+			return errors.Errorf("docker registry %q auth is empty", regname)
+		}
+		switch auth.Type {
+		case "", DockerRegistryAuthTypeBasic, DockerRegistryAuthTypeEncodedAuth, DockerRegistryAuthTypeToken:
+		default:
+			return errors.Errorf("docker registry %q: wrong auth type %q", regname, auth.Type)
+		}
+	}
+
+	return nil
+}
+
+// checkTimeout checks that the provided timeout, if defined, is a positive
+// duration
+func checkTimeout(timeout string) error {
+	if timeout == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return errors.Wrapf(err, "wrong timeout %q", timeout)
+	}
+	if d <= 0 {
+		return errors.Errorf("timeout %q must be greater than 0", timeout)
+	}
+	return nil
+}
+
+// checkRetryBackoff checks that the provided retry backoff, if defined, doesn't
+// decrease the retry interval
+func checkRetryBackoff(backoff float64) error {
+	if backoff != 0 && backoff < 1 {
+		return errors.Errorf("retry_backoff %v must be greater or equal than 1", backoff)
+	}
+	return nil
+}
+
+func checkApproval(a *Approval) error {
+	if a.MinApprovals < 0 {
+		return errors.Errorf("approval: negative min_approvals")
+	}
+	for _, u := range a.Users {
+		if u == "" {
+			return errors.Errorf("approval: empty user name")
+		}
+	}
+	for _, r := range a.OrgRoles {
+		if !cstypes.IsValidMemberRole(r) {
+			return errors.Errorf("approval: invalid org role %q", r)
+		}
+	}
+	// only the listed users can approve the task
+	if len(a.Users) > 0 && len(a.OrgRoles) == 0 && a.MinApprovals > len(a.Users) {
+		return errors.Errorf("approval: min_approvals %d is greater than the number of users allowed to approve", a.MinApprovals)
+	}
+
+	return nil
+}
+
+func checkTrigger(t *Trigger) error {
+	if t == nil {
+		return errors.Errorf("empty trigger")
+	}
+	if t.Project == "" {
+		return errors.Errorf("empty project")
+	}
+	if t.Branch == "" && t.Tag == "" {
+		return errors.Errorf("one of branch or tag is required")
+	}
+	if t.Branch != "" && t.Tag != "" {
+		return errors.Errorf("only one of branch or tag can be provided")
+	}
+	for name := range t.Variables {
+		if name == "" {
+			return errors.Errorf("empty variable name")
+		}
+	}
+
+	return nil
+}
+
+func checkInputs(inputs []*Input) error {
+	seenInputs := map[string]struct{}{}
+	for i, in := range inputs {
+		if in == nil {
+			return errors.Errorf("input at index %d is empty", i)
+		}
+		if !inputNameRegexp.MatchString(in.Name) {
+			return errors.Errorf("invalid input name %q", in.Name)
+		}
+		if _, ok := seenInputs[in.Name]; ok {
+			return errors.Errorf("duplicate input name: %s", in.Name)
+		}
+		seenInputs[in.Name] = struct{}{}
+
+		if in.Type == "" {
+			in.Type = InputTypeString
+		}
+
+		switch in.Type {
+		case InputTypeString, InputTypeBoolean:
+			if len(in.Options) > 0 {
+				return errors.Errorf("input %q: options can be defined only with choice inputs", in.Name)
+			}
+		case InputTypeChoice:
+			if len(in.Options) == 0 {
+				return errors.Errorf("input %q: no options defined", in.Name)
+			}
+		default:
+			return errors.Errorf("input %q: wrong type %q", in.Name, in.Type)
+		}
+
+		if in.Default != "" {
+			if _, err := inputValue(in, string(in.Default)); err != nil {
+				return errors.Wrapf(err, "wrong default")
+			}
+		}
+	}
+
+	return nil
 }
 
 func checkConfig(config *Config) error {
@@ -697,6 +1578,14 @@ func checkConfig(config *Config) error {
 		return errors.Errorf("no runs defined")
 	}
 
+	if err := checkInputs(config.Inputs); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := checkDockerRegistriesAuth(config.DockerRegistriesAuth); err != nil {
+		return errors.WithStack(err)
+	}
+
 	seenRuns := map[string]struct{}{}
 	for ri, run := range config.Runs {
 		if run == nil {
@@ -716,6 +1605,50 @@ func checkConfig(config *Config) error {
 		}
 		seenRuns[run.Name] = struct{}{}
 
+		if err := checkDockerRegistriesAuth(run.DockerRegistriesAuth); err != nil {
+			return errors.Wrapf(err, "run %q", run.Name)
+		}
+
+		if err := util.ValidateLabels(run.Labels); err != nil {
+			return errors.Wrapf(err, "run %q", run.Name)
+		}
+
+		switch run.Workspace {
+		case "", RunWorkspacePersistent:
+		default:
+			return errors.Errorf("run %q: wrong workspace %q", run.Name, run.Workspace)
+		}
+
+		if c := run.Concurrency; c != nil {
+			if c.Group == "" {
+				return errors.Errorf("run %q: empty concurrency group", run.Name)
+			}
+			if len(c.Group) > maxConcurrencyGroupLength {
+				return errors.Errorf("run %q: concurrency group %q too long", run.Name, c.Group)
+			}
+			switch c.Policy {
+			case ConcurrencyPolicyQueue, ConcurrencyPolicyCancelInProgress:
+			default:
+				return errors.Errorf("run %q: wrong concurrency policy %q", run.Name, c.Policy)
+			}
+		}
+
+		for i, t := range run.Triggers {
+			if err := checkTrigger(t); err != nil {
+				return errors.Wrapf(err, "run %q: trigger at index %d", run.Name, i)
+			}
+		}
+
+		if err := applyTaskTemplates(run, config.TaskTemplates); err != nil {
+			return errors.Wrapf(err, "run %q", run.Name)
+		}
+
+		applyRunDefaults(run)
+
+		if err := expandMatrixTasks(run); err != nil {
+			return errors.Wrapf(err, "run %q", run.Name)
+		}
+
 		seenTasks := map[string]struct{}{}
 		for ti, task := range run.Tasks {
 			if task == nil {
@@ -735,32 +1668,159 @@ func checkConfig(config *Config) error {
 			}
 			seenTasks[task.Name] = struct{}{}
 
+			if err := checkDockerRegistriesAuth(task.DockerRegistriesAuth); err != nil {
+				return errors.Wrapf(err, "task %q", task.Name)
+			}
+
+			if err := util.ValidateLabels(task.Labels); err != nil {
+				return errors.Wrapf(err, "task %q", task.Name)
+			}
+
+			if err := checkTimeout(task.Timeout); err != nil {
+				return errors.Wrapf(err, "task %q", task.Name)
+			}
+
+			if task.Retries < 0 {
+				return errors.Errorf("negative retries for task %q", task.Name)
+			}
+			if task.RetryInterval != "" {
+				d, err := time.ParseDuration(task.RetryInterval)
+				if err != nil {
+					return errors.Wrapf(err, "wrong retry_interval for task %q", task.Name)
+				}
+				if d < 0 {
+					return errors.Errorf("negative retry_interval for task %q", task.Name)
+				}
+			}
+			if err := checkRetryBackoff(task.RetryBackoff); err != nil {
+				return errors.Wrapf(err, "task %q", task.Name)
+			}
+			if err := checkApproval(&task.Approval); err != nil {
+				return errors.Wrapf(err, "task %q", task.Name)
+			}
+
 			// check tasks runtime
 			if task.Runtime == nil {
 				return errors.Errorf("task %q: runtime is not defined", task.Name)
 			}
 
 			r := task.Runtime
-			if r.Type != "" {
-				if r.Type != RuntimeTypePod {
-					return errors.Errorf("task %q runtime: wrong type %q", task.Name, r.Type)
+			switch r.Type {
+			case "", RuntimeTypePod:
+				if len(r.Containers) == 0 {
+					return errors.Errorf("task %q runtime: at least one container must be defined", task.Name)
 				}
-			}
-			if len(r.Containers) == 0 {
-				return errors.Errorf("task %q runtime: at least one container must be defined", task.Name)
+				// the main container command is the toolbox sleeper used to execute
+				// the task steps
+				if len(r.Containers[0].Args) > 0 {
+					return errors.Errorf("task %q runtime: args cannot be defined for the main container", task.Name)
+				}
+			case RuntimeTypeHost:
+				// a container can be defined only to set the environment and the user
+				if len(r.Containers) > 1 {
+					return errors.Errorf("task %q runtime: only one container can be defined with runtime type %q", task.Name, r.Type)
+				}
+				for _, container := range r.Containers {
+					if container.Image != "" || container.Entrypoint != "" || container.Privileged || len(container.Volumes) > 0 {
+						return errors.Errorf("task %q runtime: container image, entrypoint, privileged and volumes cannot be defined with runtime type %q", task.Name, r.Type)
+					}
+					if len(container.Args) > 0 {
+						return errors.Errorf("task %q runtime: container args cannot be defined with runtime type %q", task.Name, r.Type)
+					}
+					if container.Capabilities != nil || len(container.Devices) > 0 {
+						return errors.Errorf("task %q runtime: container capabilities and devices cannot be defined with runtime type %q", task.Name, r.Type)
+					}
+				}
+			default:
+				return errors.Errorf("task %q runtime: wrong type %q", task.Name, r.Type)
 			}
 			if r.Arch != "" {
 				if !types.IsValidArch(r.Arch) {
 					return errors.Errorf("task %q runtime: invalid arch %q", task.Name, r.Arch)
 				}
 			}
+			if os, ok := r.ExecutorLabels[rstypes.ExecutorLabelOS]; ok {
+				if !types.IsValidOS(types.OS(os)) {
+					return errors.Errorf("task %q runtime: invalid executor label %s value %q", task.Name, rstypes.ExecutorLabelOS, os)
+				}
+			}
+			if arch, ok := r.ExecutorLabels[rstypes.ExecutorLabelArch]; ok {
+				if !types.IsValidArch(types.Arch(arch)) {
+					return errors.Errorf("task %q runtime: invalid executor label %s value %q", task.Name, rstypes.ExecutorLabelArch, arch)
+				}
+			}
+			switch r.ExecutorAffinity {
+			case "", ExecutorAffinityRun, ExecutorAntiAffinityRun:
+			default:
+				return errors.Errorf("task %q runtime: wrong executor affinity %q", task.Name, r.ExecutorAffinity)
+			}
+
+			if r.GPUs < 0 {
+				return errors.Errorf("task %q runtime: gpus must be positive", task.Name)
+			}
+			if r.GPUs > 0 && r.Type == RuntimeTypeHost {
+				return errors.Errorf("task %q runtime: gpus cannot be defined with runtime type %q", task.Name, r.Type)
+			}
 
-			for _, container := range r.Containers {
+			if (len(r.NodeSelector) > 0 || len(r.Tolerations) > 0) && r.Type == RuntimeTypeHost {
+				return errors.Errorf("task %q runtime: node selector and tolerations cannot be defined with runtime type %q", task.Name, r.Type)
+			}
+			for i, t := range r.Tolerations {
+				if t == nil {
+					return errors.Errorf("task %q runtime: toleration at index %d is empty", task.Name, i)
+				}
+				if err := validateToleration(t); err != nil {
+					return errors.Wrapf(err, "task %q runtime: toleration at index %d", task.Name, i)
+				}
+			}
+
+			if len(r.Services) > 0 && r.Type == RuntimeTypeHost {
+				return errors.Errorf("task %q runtime: services cannot be defined with runtime type %q", task.Name, r.Type)
+			}
+			containers := append([]*Container{}, r.Containers...)
+			for i, service := range r.Services {
+				if service == nil {
+					return errors.Errorf("task %q runtime: service at index %d is empty", task.Name, i)
+				}
+				if service.Image == "" {
+					return errors.Errorf("task %q runtime: service at index %d image is empty", task.Name, i)
+				}
+				if err := checkReadiness(service.Readiness); err != nil {
+					return errors.Wrapf(err, "task %q runtime: service at index %d", task.Name, i)
+				}
+				containers = append(containers, &service.Container)
+			}
+
+			containerNames := map[string]struct{}{}
+			for _, container := range containers {
+				if container.Name != "" {
+					if len(container.Name) > maxContainerNameLength || !containerNameRegexp.MatchString(container.Name) {
+						return errors.Errorf("task %q runtime: invalid container name %q", task.Name, container.Name)
+					}
+					if _, ok := containerNames[container.Name]; ok {
+						return errors.Errorf("task %q runtime: duplicate container name %q", task.Name, container.Name)
+					}
+					containerNames[container.Name] = struct{}{}
+				}
+				if container.PullPolicy != "" && !rstypes.IsValidPullPolicy(rstypes.PullPolicy(container.PullPolicy)) {
+					return errors.Errorf("task %q runtime: invalid container pull policy %q", task.Name, container.PullPolicy)
+				}
 				for _, vol := range container.Volumes {
 					if vol.TmpFS == nil {
 						return errors.Errorf("no volume config specified")
 					}
 				}
+				if err := validateResources(container.Resources); err != nil {
+					return errors.Wrapf(err, "task %q runtime", task.Name)
+				}
+				if err := checkCapabilities(container.Capabilities); err != nil {
+					return errors.Wrapf(err, "task %q runtime", task.Name)
+				}
+				for _, device := range container.Devices {
+					if !path.IsAbs(device) {
+						return errors.Errorf("task %q runtime: device %q must be an absolute path", task.Name, device)
+					}
+				}
 			}
 		}
 	}
@@ -778,6 +1838,13 @@ func checkConfig(config *Config) error {
 				if _, ok := allTasks[dep.TaskName]; !ok {
 					return errors.Errorf("run task %q needed by task %q doesn't exist", dep.TaskName, task.Name)
 				}
+				for _, cond := range dep.Conditions {
+					switch cond {
+					case DependConditionOnSuccess, DependConditionOnFailure, DependConditionOnSkipped, DependConditionAlways:
+					default:
+						return errors.Errorf("unknown condition %q for dependency %q of task %q", cond, dep.TaskName, task.Name)
+					}
+				}
 			}
 		}
 	}
@@ -841,19 +1908,59 @@ func checkConfig(config *Config) error {
 
 	for _, run := range config.Runs {
 		for _, task := range run.Tasks {
+			hasSaveArtifactsStep := false
+			hasTestReportStep := false
 			for i, s := range task.Steps {
+				if bs := stepBase(s); bs != nil {
+					if err := checkTimeout(bs.Timeout); err != nil {
+						return errors.Wrapf(err, "step %d in task %q", i, task.Name)
+					}
+				}
+
 				switch step := s.(type) {
 				// TODO(sgotti) we could use the run step command as step name but when the
 				// command is very long or multi line it doesn't makes sense and will
 				// probably be quite unuseful/confusing from an UI point of view
 				case *CloneStep:
+					if task.SkipWorkspace {
+						return errors.Errorf("clone step %d not allowed in task %q with skip_workspace", i, task.Name)
+					}
 					if step.Depth != nil && *step.Depth < 1 {
 						return errors.Errorf("depth value must be greater than 0 for clone step in task %q", task.Name)
 					}
+				case *SaveToWorkspaceStep:
+					if task.SkipWorkspace {
+						return errors.Errorf("save_to_workspace step %d not allowed in task %q with skip_workspace", i, task.Name)
+					}
+					if run.Workspace == RunWorkspacePersistent {
+						return errors.Errorf("save_to_workspace step %d not allowed in task %q of run %q with persistent workspace", i, task.Name, run.Name)
+					}
+				case *RestoreWorkspaceStep:
+					if task.SkipWorkspace {
+						return errors.Errorf("restore_workspace step %d not allowed in task %q with skip_workspace", i, task.Name)
+					}
+					if run.Workspace == RunWorkspacePersistent {
+						return errors.Errorf("restore_workspace step %d not allowed in task %q of run %q with persistent workspace", i, task.Name, run.Name)
+					}
 				case *RunStep:
 					if step.Command == "" {
 						return errors.Errorf("no command defined for step %d (run) in task %q", i, task.Name)
 					}
+					if step.Retries < 0 {
+						return errors.Errorf("negative retries for step %d (run) in task %q", i, task.Name)
+					}
+					if step.RetryInterval != "" {
+						d, err := time.ParseDuration(step.RetryInterval)
+						if err != nil {
+							return errors.Wrapf(err, "wrong retry_interval for step %d (run) in task %q", i, task.Name)
+						}
+						if d < 0 {
+							return errors.Errorf("negative retry_interval for step %d (run) in task %q", i, task.Name)
+						}
+					}
+					if err := checkRetryBackoff(step.RetryBackoff); err != nil {
+						return errors.Wrapf(err, "step %d (run) in task %q", i, task.Name)
+					}
 
 				case *SaveCacheStep:
 					if step.Key == "" {
@@ -864,6 +1971,44 @@ func checkConfig(config *Config) error {
 					if len(step.Keys) == 0 {
 						return errors.Errorf("no keys defined for step %d (restore_cache) in task %q", i, task.Name)
 					}
+
+				case *SaveArtifactsStep:
+					if len(step.Contents) == 0 {
+						return errors.Errorf("no contents defined for step %d (save_artifacts) in task %q", i, task.Name)
+					}
+					// the task artifacts are saved in a single archive
+					if hasSaveArtifactsStep {
+						return errors.Errorf("only one save_artifacts step is allowed in task %q", task.Name)
+					}
+					hasSaveArtifactsStep = true
+
+				case *RestoreArtifactsStep:
+					if len(step.Tasks) == 0 {
+						return errors.Errorf("no tasks defined for step %d (restore_artifacts) in task %q", i, task.Name)
+					}
+					parents := getAllTaskParents(run, task)
+					for _, taskName := range step.Tasks {
+						found := false
+						for _, p := range parents {
+							if p.Name == taskName {
+								found = true
+								break
+							}
+						}
+						if !found {
+							return errors.Errorf("restore_artifacts step %d in task %q restores the artifacts of task %q that isn't one of its dependencies", i, task.Name, taskName)
+						}
+					}
+
+				case *TestReportStep:
+					if len(step.Paths) == 0 {
+						return errors.Errorf("no paths defined for step %d (test_report) in task %q", i, task.Name)
+					}
+					// the task test reports are saved in a single archive
+					if hasTestReportStep {
+						return errors.Errorf("only one test_report step is allowed in task %q", task.Name)
+					}
+					hasTestReportStep = true
 				}
 			}
 		}
@@ -896,11 +2041,26 @@ func checkConfig(config *Config) error {
 				task.WorkingDir = defaultWorkingDir
 			}
 
+			// set docker layer cache defaults
+			if task.DockerLayerCache != nil {
+				if task.DockerLayerCache.Key == "" {
+					task.DockerLayerCache.Key = fmt.Sprintf("docker-layer-cache-%s", task.Name)
+				}
+				if task.DockerLayerCache.Dir == "" {
+					task.DockerLayerCache.Dir = defaultDockerLayerCacheDir
+				}
+			}
+
 			// set task runtime type to pod if empty
 			r := task.Runtime
 			if r.Type == "" {
 				r.Type = RuntimeTypePod
 			}
+			// the host runtime always has a container used to define the
+			// environment and the user
+			if r.Type == RuntimeTypeHost && len(r.Containers) == 0 {
+				r.Containers = []*Container{{}}
+			}
 
 			// set steps defaults
 			for i, s := range task.Steps {
@@ -932,14 +2092,77 @@ func checkConfig(config *Config) error {
 							content.Paths = []string{"**"}
 						}
 					}
+				case *SaveArtifactsStep:
+					for _, content := range step.Contents {
+						if len(content.Paths) == 0 {
+							// default to all files inside the sourceDir
+							content.Paths = []string{"**"}
+						}
+					}
 				}
 			}
 		}
 	}
 
+	if _, err := SortRunsByDependencies(config.Runs); err != nil {
+		return errors.WithStack(err)
+	}
+
 	return nil
 }
 
+// SortRunsByDependencies returns the runs sorted so every run comes after
+// the runs it depends on. Runs without dependencies keep their order.
+func SortRunsByDependencies(runs []*Run) ([]*Run, error) {
+	runsMap := map[string]*Run{}
+	for _, run := range runs {
+		runsMap[run.Name] = run
+	}
+	for _, run := range runs {
+		for _, d := range run.DependsOn {
+			if d == run.Name {
+				return nil, errors.Errorf("run %q cannot depend on itself", run.Name)
+			}
+			if _, ok := runsMap[d]; !ok {
+				return nil, errors.Errorf("run %q depends on non existent run %q", run.Name, d)
+			}
+		}
+	}
+
+	sorted := make([]*Run, 0, len(runs))
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+
+	var visit func(run *Run) error
+	visit = func(run *Run) error {
+		if done[run.Name] {
+			return nil
+		}
+		if visiting[run.Name] {
+			return errors.Errorf("run %q has a circular dependency", run.Name)
+		}
+		visiting[run.Name] = true
+		for _, d := range run.DependsOn {
+			if err := visit(runsMap[d]); err != nil {
+				return err
+			}
+		}
+		visiting[run.Name] = false
+		done[run.Name] = true
+		sorted = append(sorted, run)
+
+		return nil
+	}
+
+	for _, run := range runs {
+		if err := visit(run); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return sorted, nil
+}
+
 // getTaskParents returns direct parents of task.
 func getTaskParents(run *Run, task *Task) []*Task {
 	parents := []*Task{}
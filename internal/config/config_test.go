@@ -15,12 +15,15 @@
 package config
 
 import (
+	"strings"
 	"testing"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/util"
+	cstypes "agola.io/agola/services/configstore/types"
 	"agola.io/agola/services/types"
 
+	"github.com/ghodss/yaml"
 	"github.com/google/go-cmp/cmp"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
@@ -89,6 +92,630 @@ func TestParseConfig(t *testing.T) {
                 `,
 			err: errors.Errorf(`task "task01" runtime: invalid arch "invalidarch"`),
 		},
+		{
+			name: "test invalid runtime executor os label",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          executor_labels:
+                            agola.io/os: invalidos
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`task "task01" runtime: invalid executor label agola.io/os value "invalidos"`),
+		},
+		{
+			name: "test invalid runtime executor arch label",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          executor_labels:
+                            agola.io/arch: invalidarch
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`task "task01" runtime: invalid executor label agola.io/arch value "invalidarch"`),
+		},
+		{
+			name: "test invalid task docker registry auth type",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        docker_registries_auth:
+                          registry.example.com:
+                            type: invalid
+                            token: token
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`task "task01": docker registry "registry.example.com": wrong auth type "invalid"`),
+		},
+		{
+			name: "test invalid container pull policy",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                              pull_policy: sometimes
+                `,
+			err: errors.Errorf(`task "task01" runtime: invalid container pull policy "sometimes"`),
+		},
+		{
+			name: "test service readiness with both tcp port and command",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                          services:
+                            - image: postgres
+                              readiness:
+                                tcp_port: 5432
+                                command: pg_isready
+                `,
+			err: errors.Errorf(`task "task01" runtime: service at index 0: readiness must define one of tcp_port or command`),
+		},
+		{
+			name: "test services with host runtime",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: host
+                          services:
+                            - image: postgres
+                `,
+			err: errors.Errorf(`task "task01" runtime: services cannot be defined with runtime type "host"`),
+		},
+		{
+			name: "test run step with negative retries",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run:
+                              command: apk add git
+                              retries: -1
+                `,
+			err: errors.Errorf(`negative retries for step 0 (run) in task "task01"`),
+		},
+		{
+			name: "test run step with wrong retry interval",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run:
+                              command: apk add git
+                              retries: 3
+                              retry_interval: "10"
+                `,
+			err: errors.Errorf(`wrong retry_interval for step 0 (run) in task "task01": time: missing unit in duration "10"`),
+		},
+		{
+			name: "test task approval with invalid org role",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run: echo
+                        approval:
+                          org_roles: [ admin ]
+                `,
+			err: errors.Errorf(`task "task01": approval: invalid org role "admin"`),
+		},
+		{
+			name: "test task approval with more min approvals than users",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run: echo
+                        approval:
+                          users: [ user01 ]
+                          min_approvals: 2
+                `,
+			err: errors.Errorf(`task "task01": approval: min_approvals 2 is greater than the number of users allowed to approve`),
+		},
+		{
+			name: "test task with wrong when expression",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run: echo
+                        when:
+                          expression: "variables.deploy == 'true"
+                `,
+			err: errors.Errorf(`failed to unmarshal config: error unmarshaling JSON: wrong expression "variables.deploy == 'true": unterminated string at position 20`),
+		},
+		{
+			name: "test task with unknown depend condition",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run: make
+                      - name: task02
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run: make clean
+                        depends:
+                          - task01:
+                            - on_error
+                `,
+			err: errors.Errorf(`unknown condition "on_error" for dependency "task01" of task "task02"`),
+		},
+		{
+			name: "test task with negative retries",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        retries: -1
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run: apk add git
+                `,
+			err: errors.Errorf(`negative retries for task "task01"`),
+		},
+		{
+			name: "test step with wrong retry_backoff",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run:
+                              command: apk add git
+                              retries: 3
+                              retry_backoff: 0.5
+                `,
+			err: errors.Errorf(`step 0 (run) in task "task01": retry_backoff 0.5 must be greater or equal than 1`),
+		},
+		{
+			name: "test task with wrong timeout",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        timeout: 10
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run: apk add git
+                `,
+			err: errors.Errorf(`task "task01": wrong timeout "10": time: missing unit in duration "10"`),
+		},
+		{
+			name: "test step with negative timeout",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run:
+                              command: apk add git
+                              timeout: -5m
+                `,
+			err: errors.Errorf(`step 0 in task "task01": timeout "-5m" must be greater than 0`),
+		},
+		{
+			name: "test args in main container",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                              args: ["sh", "-c", "sleep 10"]
+                `,
+			err: errors.Errorf(`task "task01" runtime: args cannot be defined for the main container`),
+		},
+		{
+			name: "test duplicate container and service name",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                              name: db
+                          services:
+                            - image: postgres
+                              name: db
+                `,
+			err: errors.Errorf(`task "task01" runtime: duplicate container name "db"`),
+		},
+		{
+			name: "test invalid runtime executor affinity",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          executor_affinity: invalid
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`task "task01" runtime: wrong executor affinity "invalid"`),
+		},
+		{
+			name: "test invalid run label key",
+			in: `
+                runs:
+                  - name: run01
+                    labels:
+                      "team name": backend
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`run "run01": invalid label key "team name"`),
+		},
+		{
+			name: "test invalid task label value",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        labels:
+                          tier: e2e=true
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`task "task01": label "tier": invalid value "e2e=true"`),
+		},
+		{
+			name: "test clone step with skip workspace",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        skip_workspace: true
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - clone:
+                          - run: ./notify
+                `,
+			err: errors.Errorf(`clone step 0 not allowed in task "task01" with skip_workspace`),
+		},
+		{
+			name: "test multiple save_artifacts steps",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - save_artifacts:
+                              contents:
+                                - source_dir: ./bin
+                          - save_artifacts:
+                              contents:
+                                - source_dir: ./reports
+                `,
+			err: errors.Errorf(`only one save_artifacts step is allowed in task "task01"`),
+		},
+		{
+			name: "test test_report step without paths",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - run: go test ./...
+                          - test_report: {}
+                `,
+			err: errors.Errorf(`no paths defined for step 1 (test_report) in task "task01"`),
+		},
+		{
+			name: "test multiple test_report steps",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - test_report:
+                              paths:
+                                - report.xml
+                          - test_report:
+                              paths:
+                                - reports/*.xml
+                `,
+			err: errors.Errorf(`only one test_report step is allowed in task "task01"`),
+		},
+		{
+			name: "test restore_artifacts step of a task that isn't a dependency",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - save_artifacts:
+                              contents:
+                                - source_dir: ./bin
+                      - name: task02
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - restore_artifacts:
+                              tasks:
+                                - task01
+                `,
+			err: errors.Errorf(`restore_artifacts step 0 in task "task02" restores the artifacts of task "task01" that isn't one of its dependencies`),
+		},
+		{
+			name: "test wrong when paths glob pattern",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        when:
+                          paths: "src/[a-"
+                `,
+			err: errors.Errorf(`failed to unmarshal config: error unmarshaling JSON: wrong paths glob pattern "src/[a-"`),
+		},
+		{
+			name: "test wrong run workspace",
+			in: `
+                runs:
+                  - name: run01
+                    workspace: shared
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`run "run01": wrong workspace "shared"`),
+		},
+		{
+			name: "test save_to_workspace step with persistent run workspace",
+			in: `
+                runs:
+                  - name: run01
+                    workspace: persistent
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                        steps:
+                          - clone:
+                          - save_to_workspace:
+                              contents:
+                                - source_dir: .
+                                  dest_dir: .
+                                  paths:
+                                    - '**'
+                `,
+			err: errors.Errorf(`save_to_workspace step 1 not allowed in task "task01" of run "run01" with persistent workspace`),
+		},
+		{
+			name: "test gpus with host runtime",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: host
+                          gpus: 1
+                `,
+			err: errors.Errorf(`task "task01" runtime: gpus cannot be defined with runtime type "host"`),
+		},
+		{
+			name: "test toleration with invalid operator",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                          node_selector:
+                            pool: builds
+                          tolerations:
+                            - key: pool
+                              operator: Matches
+                              value: builds
+                `,
+			err: errors.Errorf(`task "task01" runtime: toleration at index 0: unknown operator "Matches"`),
+		},
+		{
+			name: "test invalid container capability",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                              capabilities:
+                                add:
+                                  - net_admin
+                `,
+			err: errors.Errorf(`task "task01" runtime: invalid capability "net_admin"`),
+		},
+		{
+			name: "test relative container device path",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                              devices:
+                                - dev/fuse
+                `,
+			err: errors.Errorf(`task "task01" runtime: device "dev/fuse" must be an absolute path`),
+		},
+		{
+			name: "test invalid container name",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                            - name: Postgres_01
+                              image: postgres
+                `,
+			err: errors.Errorf(`task "task01" runtime: invalid container name "Postgres_01"`),
+		},
+		{
+			name: "test duplicate container name",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - name: db
+                              image: busybox
+                            - name: db
+                              image: postgres
+                `,
+			err: errors.Errorf(`task "task01" runtime: duplicate container name "db"`),
+		},
 		{
 			name: "test missing task dependency",
 			in: `
@@ -100,10 +727,135 @@ func TestParseConfig(t *testing.T) {
                           type: pod
                           containers:
                             - image: busybox
-                        depends:
-                          - task02
+                        depends:
+                          - task02
+                `,
+			err: errors.Errorf(`run task "task02" needed by task "task01" doesn't exist`),
+		},
+		{
+			name: "test run with wrong concurrency policy",
+			in: `
+                runs:
+                  - name: run01
+                    concurrency:
+                      group: deploy
+                      policy: cancel
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`run "run01": wrong concurrency policy "cancel"`),
+		},
+		{
+			name: "test choice input without options",
+			in: `
+                inputs:
+                  - name: environment
+                    type: choice
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`input "environment": no options defined`),
+		},
+		{
+			name: "test input with wrong default",
+			in: `
+                inputs:
+                  - name: dry_run
+                    type: boolean
+                    default: maybe
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
                 `,
-			err: errors.Errorf(`run task "task02" needed by task "task01" doesn't exist`),
+			err: errors.Errorf(`wrong default: input "dry_run" value "maybe" isn't a boolean`),
+		},
+		{
+			name: "test run trigger without branch or tag",
+			in: `
+                runs:
+                  - name: run01
+                    triggers:
+                      - project: org/org01/project01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`run "run01": trigger at index 0: one of branch or tag is required`),
+		},
+		{
+			name: "test run trigger with empty project",
+			in: `
+                runs:
+                  - name: run01
+                    triggers:
+                      - branch: master
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`run "run01": trigger at index 0: empty project`),
+		},
+		{
+			name: "test run depends on non existent run",
+			in: `
+                runs:
+                  - name: run01
+                    depends_on:
+                      - run02
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`run "run01" depends on non existent run "run02"`),
+		},
+		{
+			name: "test circular dependency between 2 runs",
+			in: `
+                runs:
+                  - name: run01
+                    depends_on:
+                      - run02
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                  - name: run02
+                    depends_on:
+                      - run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`run "run01" has a circular dependency`),
 		},
 		{
 			name: "test circular dependency between 2 tasks a -> b -> a",
@@ -198,6 +950,50 @@ func TestParseConfig(t *testing.T) {
                 `,
 			err: errors.Errorf("task %q and its dependency %q have both a dependency on task %q", "task04", "task03", "task01"),
 		},
+		{
+			name: "test task container resources request greater than limit",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: pod
+                          containers:
+                            - image: busybox
+                              resources:
+                                requests:
+                                  memory: 2Gi
+                                limits:
+                                  memory: 1Gi
+                `,
+			err: errors.Errorf(`task "task01" runtime: memory request "2Gi" greater than memory limit "1Gi"`),
+		},
+		{
+			name: "test host runtime without containers",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: host
+                `,
+		},
+		{
+			name: "test host runtime with container image",
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime:
+                          type: host
+                          containers:
+                            - image: busybox
+                `,
+			err: errors.Errorf(`task "task01" runtime: container image, entrypoint, privileged and volumes cannot be defined with runtime type "host"`),
+		},
 	}
 
 	for _, tt := range tests {
@@ -227,15 +1023,41 @@ func TestParseConfig(t *testing.T) {
 
 func TestParseOutput(t *testing.T) {
 	tests := []struct {
-		name string
-		in   string
-		out  *Config
+		name   string
+		format ConfigFormat
+		in     string
+		out    *Config
 	}{
 		{
-			name: "test task all options",
+			name:   "test task all options",
+			format: ConfigFormatJSON,
 			in: `
+                inputs:
+                  - name: environment
+                    description: deploy environment
+                    type: choice
+                    options:
+                      - staging
+                      - production
+                    default: staging
+                  - name: dry_run
+                    type: boolean
+                    default: true
+                  - name: message
+                    required: true
                 runs:
                   - name: run01
+                    concurrency:
+                      group: deploy-master
+                      policy: cancel_in_progress
+                    fail_fast: true
+                    triggers:
+                      - project: org/org01/project01
+                        branch: master
+                        variables:
+                          DEPLOY_ENV: production
+                      - project: org/org01/project02
+                        tag: v1.0.0
                     docker_registries_auth:
                       index.docker.io:
                         username: username
@@ -307,6 +1129,13 @@ func TestParseOutput(t *testing.T) {
                           ref:
                             include: master
                             exclude: [ /branch01/ , branch02 ]
+                          paths:
+                            include: [ "src/**", "/^go\\.(mod|sum)$/" ]
+                            exclude: "**/*.md"
+                          paths_ignore: docs/**
+                          message:
+                            exclude: /\[skip task01\]/
+                          expression: "annotations.pull_request_id != '' && variables.deploy == 'true'"
                         depends:
                           - task: task02
                             conditions:
@@ -320,6 +1149,10 @@ func TestParseOutput(t *testing.T) {
                           type: pod
                           containers:
                             - image: image01
+                        approval:
+                          users: [ user01, user02 ]
+                          org_roles: [ owner ]
+                          min_approvals: 2
                       - name: task03
                         runtime:
                           type: pod
@@ -329,6 +1162,8 @@ func TestParseOutput(t *testing.T) {
                                 - path: /mnt/tmpfs
                                   tmpfs:
                                     size: 1Gi
+                        approval: true
+                        always_run: true
                       - name: task04
                         runtime:
                           type: pod
@@ -337,6 +1172,12 @@ func TestParseOutput(t *testing.T) {
                               volumes:
                                 - path: /mnt/tmpfs
                                   tmpfs: {}
+                              resources:
+                                requests:
+                                  cpu: 500m
+                                  memory: 512Mi
+                                limits:
+                                  cpu: 1
                       - name: task05
                         runtime:
                           type: pod
@@ -356,9 +1197,44 @@ func TestParseOutput(t *testing.T) {
                             tty: false
           `,
 			out: &Config{
+				Inputs: []*Input{
+					{
+						Name:        "environment",
+						Description: "deploy environment",
+						Type:        InputTypeChoice,
+						Default:     "staging",
+						Options:     []string{"staging", "production"},
+					},
+					{
+						Name:    "dry_run",
+						Type:    InputTypeBoolean,
+						Default: "true",
+					},
+					{
+						Name:     "message",
+						Type:     InputTypeString,
+						Required: true,
+					},
+				},
 				Runs: []*Run{
 					&Run{
 						Name: "run01",
+						Concurrency: &Concurrency{
+							Group:  "deploy-master",
+							Policy: ConcurrencyPolicyCancelInProgress,
+						},
+						FailFast: true,
+						Triggers: []*Trigger{
+							{
+								Project:   "org/org01/project01",
+								Branch:    "master",
+								Variables: map[string]string{"DEPLOY_ENV": "production"},
+							},
+							{
+								Project: "org/org01/project02",
+								Tag:     "v1.0.0",
+							},
+						},
 						DockerRegistriesAuth: map[string]*DockerRegistryAuth{
 							"index.docker.io": {
 								Type:     DockerRegistryAuthTypeBasic,
@@ -468,7 +1344,7 @@ func TestParseOutput(t *testing.T) {
 									},
 								},
 								IgnoreFailure: false,
-								Approval:      false,
+								Approval:      Approval{},
 								When: &When{
 									Branch: &types.WhenConditions{
 										Include: []types.WhenCondition{
@@ -490,6 +1366,22 @@ func TestParseOutput(t *testing.T) {
 											{Type: types.WhenConditionTypeSimple, Match: "branch02"},
 										},
 									},
+									Paths: &types.WhenConditions{
+										Include: []types.WhenCondition{
+											{Type: types.WhenConditionTypeSimple, Match: "src/**"},
+											{Type: types.WhenConditionTypeRegExp, Match: `^go\.(mod|sum)$`},
+										},
+										Exclude: []types.WhenCondition{
+											{Type: types.WhenConditionTypeSimple, Match: "**/*.md"},
+											{Type: types.WhenConditionTypeSimple, Match: "docs/**"},
+										},
+									},
+									Message: &types.WhenConditions{
+										Exclude: []types.WhenCondition{
+											{Type: types.WhenConditionTypeRegExp, Match: `\[skip task01\]`},
+										},
+									},
+									Expression: "annotations.pull_request_id != '' && variables.deploy == 'true'",
 								},
 								Depends: []*Depend{
 									&Depend{TaskName: "task02", Conditions: []DependCondition{DependConditionOnSuccess, DependConditionOnFailure}},
@@ -511,6 +1403,12 @@ func TestParseOutput(t *testing.T) {
 								WorkingDir: defaultWorkingDir,
 								Steps:      nil,
 								Depends:    nil,
+								Approval: Approval{
+									Required:     true,
+									Users:        []string{"user01", "user02"},
+									OrgRoles:     []cstypes.MemberRole{cstypes.MemberRoleOwner},
+									MinApprovals: 2,
+								},
 							},
 							&Task{
 								Name: "task03",
@@ -527,6 +1425,8 @@ func TestParseOutput(t *testing.T) {
 								WorkingDir: defaultWorkingDir,
 								Steps:      nil,
 								Depends:    nil,
+								Approval:   Approval{Required: true},
+								AlwaysRun:  true,
 							},
 							&Task{
 								Name: "task04",
@@ -537,6 +1437,15 @@ func TestParseOutput(t *testing.T) {
 										&Container{
 											Image:   "image01",
 											Volumes: []Volume{{Path: "/mnt/tmpfs", TmpFS: &VolumeTmpFS{}}},
+											Resources: &Resources{
+												Requests: &ResourceList{
+													CPU:    resource.NewMilliQuantity(500, resource.DecimalSI),
+													Memory: resource.NewQuantity(512*1024*1024, resource.BinarySI),
+												},
+												Limits: &ResourceList{
+													CPU: resource.NewQuantity(1, resource.DecimalSI),
+												},
+											},
 										},
 									},
 								},
@@ -589,11 +1498,65 @@ func TestParseOutput(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:   "test yaml config with anchors",
+			format: ConfigFormatYAML,
+			in: `
+                runs:
+                  - name: run01
+                    tasks:
+                      - name: task01
+                        runtime: &runtime
+                          type: pod
+                          containers:
+                            - image: image01
+                      - name: task02
+                        runtime: *runtime
+                        depends:
+                          - task01
+          `,
+			out: &Config{
+				Runs: []*Run{
+					&Run{
+						Name: "run01",
+						Tasks: []*Task{
+							&Task{
+								Name: "task01",
+								Runtime: &Runtime{
+									Type: "pod",
+									Containers: []*Container{
+										&Container{
+											Image: "image01",
+										},
+									},
+								},
+								WorkingDir: defaultWorkingDir,
+							},
+							&Task{
+								Name: "task02",
+								Runtime: &Runtime{
+									Type: "pod",
+									Containers: []*Container{
+										&Container{
+											Image: "image01",
+										},
+									},
+								},
+								WorkingDir: defaultWorkingDir,
+								Depends: Depends{
+									&Depend{TaskName: "task01"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			out, err := ParseConfig([]byte(tt.in), ConfigFormatJSON, &ConfigContext{})
+			out, err := ParseConfig([]byte(tt.in), tt.format, &ConfigContext{})
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -612,3 +1575,212 @@ func TestParseOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestShell(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+		err  string
+	}{
+		{
+			name: "test known shell name",
+			in:   `pwsh`,
+			out:  "pwsh -NoProfile -NonInteractive -ExecutionPolicy Bypass -File",
+		},
+		{
+			name: "test plain sh is kept verbatim",
+			in:   `sh`,
+			out:  "sh",
+		},
+		{
+			name: "test plain bash is kept verbatim",
+			in:   `bash`,
+			out:  "bash",
+		},
+		{
+			name: "test sh command line is kept verbatim",
+			in:   `/bin/sh -e`,
+			out:  "/bin/sh -e",
+		},
+		{
+			name: "test bash args list",
+			in:   `["bash", "-eo", "pipefail"]`,
+			out:  "bash -eo pipefail",
+		},
+		{
+			name: "test custom interpreter command line",
+			in:   `/usr/bin/python3 -u`,
+			out:  "/usr/bin/python3 -u",
+		},
+		{
+			name: "test custom interpreter args list",
+			in:   `["/usr/bin/env", "node"]`,
+			out:  "/usr/bin/env node",
+		},
+		{
+			name: "test args list with an arg containing spaces",
+			in:   `["/bin/sh", "-e -x"]`,
+			err:  `shell arg "-e -x" cannot be empty or contain whitespaces`,
+		},
+		{
+			name: "test wrong shell format",
+			in:   `{"cmd": "bash"}`,
+			err:  `unknown shell format: map[cmd:bash]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Shell
+			err := yaml.Unmarshal([]byte(tt.in), &s)
+			if err != nil {
+				if tt.err == "" {
+					t.Fatalf("got error: %v, expected no error", err)
+				}
+				if !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error: %v, want error: %v", err, tt.err)
+				}
+				return
+			}
+			if tt.err != "" {
+				t.Fatalf("got nil error, want error: %v", tt.err)
+			}
+			if out := s.CommandLine(); out != tt.out {
+				t.Fatalf("expected command line %q, got %q", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestSortRunsByDependencies(t *testing.T) {
+	runs := []*Run{
+		{Name: "deploy", DependsOn: []string{"build", "test"}},
+		{Name: "test", DependsOn: []string{"build"}},
+		{Name: "build"},
+		{Name: "lint"},
+	}
+
+	sorted, err := SortRunsByDependencies(runs)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	out := []string{}
+	for _, run := range sorted {
+		out = append(out, run.Name)
+	}
+	if diff := cmp.Diff([]string{"build", "test", "deploy", "lint"}, out); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		out      ConfigFormat
+		err      error
+	}{
+		{filename: ".agola/config.star", out: ConfigFormatStarlark},
+		{filename: ".agola/config.jsonnet", out: ConfigFormatJsonnet},
+		{filename: ".agola/config.json", out: ConfigFormatJSON},
+		{filename: ".agola/config.yml", out: ConfigFormatYAML},
+		{filename: ".agola/config.yaml", out: ConfigFormatYAML},
+		{filename: ".agola/config.toml", err: errors.Errorf(`unknown config file ".agola/config.toml" extension`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			out, err := FormatFromFilename(tt.filename)
+			if tt.err != nil {
+				if err == nil {
+					t.Fatalf("got nil error, want error: %v", tt.err)
+				}
+				if err.Error() != tt.err.Error() {
+					t.Fatalf("got error: %v, want error: %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out != tt.out {
+				t.Fatalf("expected format %d, got %d", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestInputsValues(t *testing.T) {
+	c := &Config{
+		Inputs: []*Input{
+			{Name: "environment", Type: InputTypeChoice, Options: []string{"staging", "production"}, Default: "staging"},
+			{Name: "dry_run", Type: InputTypeBoolean},
+			{Name: "message", Type: InputTypeString, Required: true},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		values        map[string]string
+		checkRequired bool
+		out           map[string]string
+		err           error
+	}{
+		{
+			name:          "provided values",
+			values:        map[string]string{"environment": "production", "dry_run": "1", "message": "release"},
+			checkRequired: true,
+			out:           map[string]string{"environment": "production", "dry_run": "true", "message": "release"},
+		},
+		{
+			name:          "defaults",
+			values:        map[string]string{"message": "release"},
+			checkRequired: true,
+			out:           map[string]string{"environment": "staging", "message": "release"},
+		},
+		{
+			name:          "required input not checked",
+			checkRequired: false,
+			out:           map[string]string{"environment": "staging"},
+		},
+		{
+			name:          "missing required input",
+			checkRequired: true,
+			err:           errors.Errorf(`required input "message" not provided`),
+		},
+		{
+			name:          "wrong choice",
+			values:        map[string]string{"environment": "testing", "message": "release"},
+			checkRequired: true,
+			err:           errors.Errorf(`input "environment" value "testing" isn't one of the allowed options`),
+		},
+		{
+			name:          "undefined input",
+			values:        map[string]string{"message": "release", "region": "eu"},
+			checkRequired: true,
+			err:           errors.Errorf(`input "region" isn't defined in the config`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := c.InputsValues(tt.values, tt.checkRequired)
+			if tt.err != nil {
+				if err == nil {
+					t.Fatalf("got nil error, want error: %v", tt.err)
+				}
+				if err.Error() != tt.err.Error() {
+					t.Fatalf("got error: %v, want error: %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.out, out); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
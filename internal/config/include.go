@@ -0,0 +1,193 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"path"
+
+	"agola.io/agola/internal/errors"
+)
+
+const (
+	// maxConfigIncludes is the max number of config includes
+	maxConfigIncludes = 20
+)
+
+// Include is a config file whose runs, docker registries auth and task
+// templates are merged in the including config. Exactly one of Local, Remote or Repo must be
+// defined. The include file format is detected from its extension.
+type Include struct {
+	// Local is the path of a file of the same repository at the run commit
+	Local string `json:"local,omitempty"`
+
+	// Remote is the http(s) url of a remote file. SHA256 is the required hex
+	// encoded sha256 of the file content used to pin it. The url must resolve
+	// to a public address
+	Remote string `json:"remote,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Repo is the path of another repository of the same git source and
+	// owner. Ref is the required commit sha (or tag) pinning the repository
+	// and Path is the file path. Repo includes aren't allowed in forked pull
+	// requests
+	Repo string `json:"repo,omitempty"`
+	Ref  string `json:"ref,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+func checkInclude(include *Include) error {
+	defined := 0
+	for _, s := range []string{include.Local, include.Remote, include.Repo} {
+		if s != "" {
+			defined++
+		}
+	}
+	if defined != 1 {
+		return errors.Errorf("exactly one of local, remote or repo must be defined")
+	}
+
+	if include.Remote != "" {
+		u, err := url.Parse(include.Remote)
+		if err != nil {
+			return errors.Wrapf(err, "invalid remote url %q", include.Remote)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return errors.Errorf("remote url %q must be an http or https url", include.Remote)
+		}
+		if include.SHA256 == "" {
+			return errors.Errorf("remote %q requires a sha256", include.Remote)
+		}
+	}
+	if include.Repo != "" {
+		if include.Ref == "" {
+			return errors.Errorf("repo %q requires a ref", include.Repo)
+		}
+		if include.Path == "" {
+			return errors.Errorf("repo %q requires a path", include.Repo)
+		}
+	}
+
+	return nil
+}
+
+// fetchInclude returns the include content and its file name used to detect
+// its format
+func fetchInclude(include *Include, configContext *ConfigContext) ([]byte, string, error) {
+	switch {
+	case include.Local != "":
+		if configContext.FetchFile == nil {
+			return nil, "", errors.Errorf("cannot fetch local file %q", include.Local)
+		}
+		data, err := configContext.FetchFile(include.Local)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to fetch local file %q", include.Local)
+		}
+		return data, include.Local, nil
+
+	case include.Remote != "":
+		if configContext.FetchRemoteFile == nil {
+			return nil, "", errors.Errorf("cannot fetch remote file %q", include.Remote)
+		}
+		data, err := configContext.FetchRemoteFile(include.Remote)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to fetch remote file %q", include.Remote)
+		}
+		h := sha256.Sum256(data)
+		// don't report the content sha256 since the url could be of a
+		// private service
+		if hex.EncodeToString(h[:]) != include.SHA256 {
+			return nil, "", errors.Errorf("remote file %q content doesn't match sha256 %s", include.Remote, include.SHA256)
+		}
+		u, _ := url.Parse(include.Remote)
+		return data, path.Base(u.Path), nil
+
+	default:
+		if configContext.FetchRepoFile == nil {
+			return nil, "", errors.Errorf("cannot fetch repo %q file %q", include.Repo, include.Path)
+		}
+		data, err := configContext.FetchRepoFile(include.Repo, include.Ref, include.Path)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to fetch repo %q file %q", include.Repo, include.Path)
+		}
+		return data, include.Path, nil
+	}
+}
+
+// mergeIncludes adds the runs of the included configs before the config runs.
+// The docker registries auth and task templates of the config take precedence
+// over the included ones. Nested includes aren't supported.
+func mergeIncludes(config *Config, configContext *ConfigContext) error {
+	if len(config.Include) == 0 {
+		return nil
+	}
+	if len(config.Include) > maxConfigIncludes {
+		return errors.Errorf("too many config includes: %d > %d", len(config.Include), maxConfigIncludes)
+	}
+
+	var runs []*Run
+	registriesAuth := map[string]*DockerRegistryAuth{}
+	taskTemplates := map[string]*Task{}
+	for i, include := range config.Include {
+		if include == nil {
+			return errors.Errorf("include at index %d is empty", i)
+		}
+		if err := checkInclude(include); err != nil {
+			return errors.Wrapf(err, "include at index %d", i)
+		}
+
+		data, filename, err := fetchInclude(include, configContext)
+		if err != nil {
+			return errors.Wrapf(err, "include at index %d", i)
+		}
+		format, err := FormatFromFilename(filename)
+		if err != nil {
+			return errors.Wrapf(err, "include at index %d", i)
+		}
+		ic, err := parseConfigData(data, format, configContext)
+		if err != nil {
+			return errors.Wrapf(err, "include at index %d", i)
+		}
+		if len(ic.Include) > 0 {
+			return errors.Errorf("include at index %d: nested includes are not supported", i)
+		}
+
+		runs = append(runs, ic.Runs...)
+		for k, v := range ic.DockerRegistriesAuth {
+			registriesAuth[k] = v
+		}
+		for k, v := range ic.TaskTemplates {
+			taskTemplates[k] = v
+		}
+	}
+	for k, v := range config.DockerRegistriesAuth {
+		registriesAuth[k] = v
+	}
+	for k, v := range config.TaskTemplates {
+		taskTemplates[k] = v
+	}
+
+	config.Runs = append(runs, config.Runs...)
+	if len(registriesAuth) > 0 {
+		config.DockerRegistriesAuth = registriesAuth
+	}
+	if len(taskTemplates) > 0 {
+		config.TaskTemplates = taskTemplates
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"agola.io/agola/internal/errors"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIncludes(t *testing.T) {
+	sharedRun := `
+runs:
+  - name: shared
+    tasks:
+      - name: task01
+        runtime:
+          containers:
+            - image: image01
+        steps:
+          - run: command01
+docker_registries_auth:
+  index.docker.io:
+    username: shared
+    password: shared
+  registry.example.com:
+    username: shared
+    password: shared
+`
+	sharedRunSHA := sha256.Sum256([]byte(sharedRun))
+
+	files := map[string]string{
+		".agola/shared.yml": sharedRun,
+		".agola/nested.yml": `
+include:
+  - local: .agola/shared.yml
+runs: []
+`,
+	}
+	remoteFiles := map[string]string{
+		"https://example.com/agola/shared.yml": sharedRun,
+	}
+	repoFiles := map[string]string{
+		"org/shared@v1:shared.json": `{ "runs": [ { "name": "shared", "tasks": [ { "name": "task01", "runtime": { "containers": [ { "image": "image01" } ] }, "steps": [ { "type": "run", "command": "command01" } ] } ] } ] }`,
+	}
+
+	configContext := &ConfigContext{
+		FetchFile: func(path string) ([]byte, error) {
+			data, ok := files[path]
+			if !ok {
+				return nil, errors.Errorf("file %q doesn't exist", path)
+			}
+			return []byte(data), nil
+		},
+		FetchRemoteFile: func(url string) ([]byte, error) {
+			data, ok := remoteFiles[url]
+			if !ok {
+				return nil, errors.Errorf("url %q doesn't exist", url)
+			}
+			return []byte(data), nil
+		},
+		FetchRepoFile: func(repoPath, ref, path string) ([]byte, error) {
+			data, ok := repoFiles[repoPath+"@"+ref+":"+path]
+			if !ok {
+				return nil, errors.Errorf("repo file %q doesn't exist", path)
+			}
+			return []byte(data), nil
+		},
+	}
+
+	mainRun := `
+runs:
+  - name: run01
+    tasks:
+      - name: task01
+        runtime:
+          containers:
+            - image: image01
+        steps:
+          - run: command01
+`
+
+	tests := []struct {
+		name                string
+		in                  string
+		runs                []string
+		registriesAuthUsers map[string]string
+		err                 string
+	}{
+		{
+			name: "test local include",
+			in: `
+include:
+  - local: .agola/shared.yml
+docker_registries_auth:
+  index.docker.io:
+    username: main
+    password: main
+` + mainRun,
+			runs: []string{"shared", "run01"},
+			registriesAuthUsers: map[string]string{
+				"index.docker.io":      "main",
+				"registry.example.com": "shared",
+			},
+		},
+		{
+			name: "test remote include",
+			in: `
+include:
+  - remote: https://example.com/agola/shared.yml
+    sha256: ` + hex.EncodeToString(sharedRunSHA[:]) + `
+` + mainRun,
+			runs: []string{"shared", "run01"},
+			registriesAuthUsers: map[string]string{
+				"index.docker.io":      "shared",
+				"registry.example.com": "shared",
+			},
+		},
+		{
+			name: "test repo include",
+			in: `
+include:
+  - repo: org/shared
+    ref: v1
+    path: shared.json
+` + mainRun,
+			runs: []string{"shared", "run01"},
+		},
+		{
+			name: "test remote include with wrong sha256",
+			in: `
+include:
+  - remote: https://example.com/agola/shared.yml
+    sha256: "0000"
+` + mainRun,
+			err: `include at index 0: remote file "https://example.com/agola/shared.yml" content doesn't match sha256 0000`,
+		},
+		{
+			name: "test remote include without sha256",
+			in: `
+include:
+  - remote: https://example.com/agola/shared.yml
+` + mainRun,
+			err: `include at index 0: remote "https://example.com/agola/shared.yml" requires a sha256`,
+		},
+		{
+			name: "test include with multiple sources",
+			in: `
+include:
+  - local: .agola/shared.yml
+    repo: org/shared
+` + mainRun,
+			err: `include at index 0: exactly one of local, remote or repo must be defined`,
+		},
+		{
+			name: "test nested include",
+			in: `
+include:
+  - local: .agola/nested.yml
+` + mainRun,
+			err: `include at index 0: nested includes are not supported`,
+		},
+		{
+			name: "test include with duplicate run name",
+			in: `
+include:
+  - local: .agola/shared.yml
+runs:
+  - name: shared
+    tasks:
+      - name: task01
+        runtime:
+          containers:
+            - image: image01
+        steps:
+          - run: command01
+`,
+			err: `duplicate run name: shared`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseConfig([]byte(tt.in), ConfigFormatYAML, configContext)
+			if err != nil {
+				if tt.err == "" {
+					t.Fatalf("got error: %v, expected no error", err)
+				}
+				if err.Error() != tt.err {
+					t.Fatalf("got error: %v, want error: %v", err, tt.err)
+				}
+				return
+			}
+			if tt.err != "" {
+				t.Fatalf("got nil error, want error: %v", tt.err)
+			}
+
+			var runs []string
+			for _, run := range config.Runs {
+				runs = append(runs, run.Name)
+			}
+			if diff := cmp.Diff(tt.runs, runs); diff != "" {
+				t.Fatalf("runs mismatch (-want +got):\n%s", diff)
+			}
+
+			var registriesAuthUsers map[string]string
+			for k, v := range config.DockerRegistriesAuth {
+				if registriesAuthUsers == nil {
+					registriesAuthUsers = map[string]string{}
+				}
+				registriesAuthUsers[k] = v.Username.Value
+			}
+			if diff := cmp.Diff(tt.registriesAuthUsers, registriesAuthUsers); diff != "" {
+				t.Fatalf("docker registries auth mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
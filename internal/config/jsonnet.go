@@ -15,14 +15,120 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"regexp"
 
 	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/util"
+
 	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
 )
 
+// jsonnetNativeFunctions returns the agola native functions available to
+// jsonnet configs using std.native("name")
+func jsonnetNativeFunctions(configContext *ConfigContext) []*jsonnet.NativeFunction {
+	return []*jsonnet.NativeFunction{
+		{
+			// semverCompare returns -1, 0 or 1 if v1 is lower, equal or
+			// greater than v2
+			Name:   "semverCompare",
+			Params: ast.Identifiers{"v1", "v2"},
+			Func: func(args []interface{}) (interface{}, error) {
+				v1, ok1 := args[0].(string)
+				v2, ok2 := args[1].(string)
+				if !ok1 || !ok2 {
+					return nil, errors.Errorf("semverCompare arguments must be strings")
+				}
+				c, err := util.CompareSemver(v1, v2)
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				return float64(c), nil
+			},
+		},
+		{
+			// regexMatch reports if the string matches the regular expression
+			Name:   "regexMatch",
+			Params: ast.Identifiers{"regex", "s"},
+			Func: func(args []interface{}) (interface{}, error) {
+				regex, ok1 := args[0].(string)
+				s, ok2 := args[1].(string)
+				if !ok1 || !ok2 {
+					return nil, errors.Errorf("regexMatch arguments must be strings")
+				}
+				re, err := regexp.Compile(regex)
+				if err != nil {
+					return nil, errors.Wrapf(err, "wrong regular expression %q", regex)
+				}
+				return re.MatchString(s), nil
+			},
+		},
+		{
+			// env returns the value of an environment variable. Only the
+			// variables provided in the config context can be read
+			Name:   "env",
+			Params: ast.Identifiers{"name"},
+			Func: func(args []interface{}) (interface{}, error) {
+				name, ok := args[0].(string)
+				if !ok {
+					return nil, errors.Errorf("env argument must be a string")
+				}
+				v, ok := configContext.Env[name]
+				if !ok {
+					return nil, errors.Errorf("env variable %q is not available", name)
+				}
+				return v, nil
+			},
+		},
+		{
+			// hashFiles returns the sha256 hash of the content of the
+			// provided repository files
+			Name:   "hashFiles",
+			Params: ast.Identifiers{"files"},
+			Func: func(args []interface{}) (interface{}, error) {
+				var files []string
+				switch v := args[0].(type) {
+				case string:
+					files = []string{v}
+				case []interface{}:
+					for _, f := range v {
+						file, ok := f.(string)
+						if !ok {
+							return nil, errors.Errorf("hashFiles argument must be a string or an array of strings")
+						}
+						files = append(files, file)
+					}
+				default:
+					return nil, errors.Errorf("hashFiles argument must be a string or an array of strings")
+				}
+				if configContext.FetchFile == nil {
+					return nil, errors.Errorf("hashFiles cannot fetch repository files")
+				}
+
+				h := sha256.New()
+				for _, file := range files {
+					data, err := configContext.FetchFile(file)
+					if err != nil {
+						return nil, errors.Wrapf(err, "failed to fetch file %q", file)
+					}
+					fh := sha256.Sum256(data)
+					_, _ = h.Write(fh[:])
+				}
+				return hex.EncodeToString(h.Sum(nil)), nil
+			},
+		},
+	}
+}
+
 func execJsonnet(configData []byte, configContext *ConfigContext) ([]byte, error) {
 	vm := jsonnet.MakeVM()
+	for _, f := range jsonnetNativeFunctions(configContext) {
+		vm.NativeFunction(f)
+	}
+
 	cj, err := json.Marshal(configContext)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to marshal config context")
@@ -0,0 +1,97 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"agola.io/agola/internal/errors"
+)
+
+func TestJsonnetNativeFunctions(t *testing.T) {
+	configContext := &ConfigContext{
+		Env: map[string]string{"ENV01": "value01"},
+		FetchFile: func(path string) ([]byte, error) {
+			switch path {
+			case "go.sum":
+				return []byte("content01"), nil
+			case "go.mod":
+				return []byte("content02"), nil
+			}
+			return nil, errors.Errorf("file %q doesn't exist", path)
+		},
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		out  string
+		err  bool
+	}{
+		{
+			name: "test semverCompare",
+			in:   `[std.native("semverCompare")("v1.2.0", "1.10.0"), std.native("semverCompare")("1.0.0", "1.0.0-rc.1")]`,
+			out:  "[\n   -1,\n   1\n]\n",
+		},
+		{
+			name: "test semverCompare with wrong version",
+			in:   `std.native("semverCompare")("1.2", "1.10.0")`,
+			err:  true,
+		},
+		{
+			name: "test regexMatch",
+			in:   `[std.native("regexMatch")("^release-.*$", "release-1.0"), std.native("regexMatch")("^release-.*$", "master")]`,
+			out:  "[\n   true,\n   false\n]\n",
+		},
+		{
+			name: "test env",
+			in:   `std.native("env")("ENV01")`,
+			out:  "\"value01\"\n",
+		},
+		{
+			name: "test env with not available variable",
+			in:   `std.native("env")("HOME")`,
+			err:  true,
+		},
+		{
+			name: "test hashFiles",
+			in:   `std.native("hashFiles")(["go.mod", "go.sum"]) == std.native("hashFiles")(["go.mod", "go.sum"]) && std.native("hashFiles")("go.mod") != std.native("hashFiles")("go.sum")`,
+			out:  "true\n",
+		},
+		{
+			name: "test hashFiles with not existing file",
+			in:   `std.native("hashFiles")(["notexisting"])`,
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := execJsonnet([]byte("function(ctx) "+tt.in), configContext)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got nil error, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if string(out) != tt.out {
+				t.Fatalf("got %q, want %q", out, tt.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+)
+
+const (
+	// maxMatrixTasks is the max number of tasks generated by a task matrix
+	maxMatrixTasks = 64
+)
+
+var matrixAxisNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Matrix defines the axes of a task matrix. The task is expanded in a task for
+// every combination of the axes values not matching an exclude entry. The
+// axes values are set as environment variables (named like the axes) of the
+// generated tasks. Example:
+//
+//	matrix:
+//	  axes:
+//	    GO_VERSION: ["1.16", "1.17"]
+//	    GOOS: [linux, windows]
+//	  exclude:
+//	    - GO_VERSION: "1.16"
+//	      GOOS: windows
+type Matrix struct {
+	Axes map[string][]string `json:"axes"`
+	// Exclude are the (also partial) axes values combinations to not generate
+	Exclude []map[string]string `json:"exclude"`
+}
+
+// combinations returns the axes values combinations not excluded
+func (m *Matrix) combinations(axes []string) []map[string]string {
+	combinations := []map[string]string{{}}
+	for _, axis := range axes {
+		var ncombinations []map[string]string
+		for _, c := range combinations {
+			for _, v := range m.Axes[axis] {
+				nc := make(map[string]string, len(c)+1)
+				for k, cv := range c {
+					nc[k] = cv
+				}
+				nc[axis] = v
+				ncombinations = append(ncombinations, nc)
+			}
+		}
+		combinations = ncombinations
+	}
+
+	var filtered []map[string]string
+	for _, c := range combinations {
+		excluded := false
+		for _, e := range m.Exclude {
+			match := true
+			for k, v := range e {
+				if c[k] != v {
+					match = false
+					break
+				}
+			}
+			if match {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			filtered = append(filtered, c)
+		}
+	}
+
+	return filtered
+}
+
+func checkMatrix(m *Matrix) error {
+	if len(m.Axes) == 0 {
+		return errors.Errorf("no axes defined")
+	}
+	for axis, values := range m.Axes {
+		if !matrixAxisNameRegexp.MatchString(axis) {
+			return errors.Errorf("invalid axis name %q", axis)
+		}
+		if len(values) == 0 {
+			return errors.Errorf("axis %q has no values", axis)
+		}
+		seen := map[string]struct{}{}
+		for _, v := range values {
+			if _, ok := seen[v]; ok {
+				return errors.Errorf("axis %q has duplicate value %q", axis, v)
+			}
+			seen[v] = struct{}{}
+		}
+	}
+	for i, e := range m.Exclude {
+		if len(e) == 0 {
+			return errors.Errorf("exclude entry at index %d is empty", i)
+		}
+		for axis := range e {
+			if _, ok := m.Axes[axis]; !ok {
+				return errors.Errorf("exclude entry at index %d: unknown axis %q", i, axis)
+			}
+		}
+	}
+
+	return nil
+}
+
+// expandMatrixTasks replaces the run tasks with a matrix with the tasks
+// generated from the matrix axes values. The dependencies on a matrix task are
+// replaced with dependencies on all its generated tasks.
+func expandMatrixTasks(run *Run) error {
+	// generated tasks names by matrix task name
+	matrixTasks := map[string][]string{}
+
+	var tasks []*Task
+	for _, task := range run.Tasks {
+		if task == nil || task.Matrix == nil {
+			tasks = append(tasks, task)
+			continue
+		}
+
+		if err := checkMatrix(task.Matrix); err != nil {
+			return errors.Wrapf(err, "task %q matrix", task.Name)
+		}
+
+		axes := make([]string, 0, len(task.Matrix.Axes))
+		for axis := range task.Matrix.Axes {
+			axes = append(axes, axis)
+		}
+		sort.Strings(axes)
+
+		combinations := task.Matrix.combinations(axes)
+		if len(combinations) == 0 {
+			return errors.Errorf("task %q matrix: all the axes values combinations are excluded", task.Name)
+		}
+		if len(combinations) > maxMatrixTasks {
+			return errors.Errorf("task %q matrix: too many axes values combinations %d > %d", task.Name, len(combinations), maxMatrixTasks)
+		}
+
+		for _, c := range combinations {
+			values := make([]string, len(axes))
+			for i, axis := range axes {
+				values[i] = c[axis]
+			}
+
+			nt := *task
+			nt.Name = fmt.Sprintf("%s (%s)", task.Name, strings.Join(values, ", "))
+			nt.Matrix = nil
+			nt.Environment = make(map[string]Value, len(task.Environment)+len(axes))
+			for k, v := range task.Environment {
+				nt.Environment[k] = v
+			}
+			for _, axis := range axes {
+				nt.Environment[axis] = Value{Type: ValueTypeString, Value: c[axis]}
+			}
+			if task.DockerLayerCache != nil {
+				dlc := *task.DockerLayerCache
+				nt.DockerLayerCache = &dlc
+			}
+
+			tasks = append(tasks, &nt)
+			matrixTasks[task.Name] = append(matrixTasks[task.Name], nt.Name)
+		}
+	}
+
+	if len(matrixTasks) == 0 {
+		return nil
+	}
+
+	for _, task := range tasks {
+		if task == nil {
+			continue
+		}
+		var depends Depends
+		for _, dep := range task.Depends {
+			taskNames, ok := matrixTasks[dep.TaskName]
+			if !ok {
+				depends = append(depends, dep)
+				continue
+			}
+			for _, taskName := range taskNames {
+				depends = append(depends, &Depend{TaskName: taskName, Conditions: dep.Conditions})
+			}
+		}
+		task.Depends = depends
+	}
+
+	run.Tasks = tasks
+
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMatrix(t *testing.T) {
+	type taskOut struct {
+		name    string
+		env     map[string]Value
+		depends []string
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		out  []taskOut
+		err  string
+	}{
+		{
+			name: "test matrix with exclude",
+			in: `
+runs:
+  - name: run01
+    tasks:
+      - name: test
+        runtime:
+          containers:
+            - image: golang:1.17
+        environment:
+          ENV01: ENV01
+        matrix:
+          axes:
+            GO_VERSION: ["1.16", "1.17"]
+            GOOS: [linux, windows]
+          exclude:
+            - GO_VERSION: "1.16"
+              GOOS: windows
+        steps:
+          - run: go test ./...
+      - name: release
+        runtime:
+          containers:
+            - image: golang:1.17
+        depends:
+          - test
+        steps:
+          - run: make release
+`,
+			out: []taskOut{
+				{
+					name: "test (linux, 1.16)",
+					env: map[string]Value{
+						"ENV01":      {Type: ValueTypeString, Value: "ENV01"},
+						"GOOS":       {Type: ValueTypeString, Value: "linux"},
+						"GO_VERSION": {Type: ValueTypeString, Value: "1.16"},
+					},
+				},
+				{
+					name: "test (linux, 1.17)",
+					env: map[string]Value{
+						"ENV01":      {Type: ValueTypeString, Value: "ENV01"},
+						"GOOS":       {Type: ValueTypeString, Value: "linux"},
+						"GO_VERSION": {Type: ValueTypeString, Value: "1.17"},
+					},
+				},
+				{
+					name: "test (windows, 1.17)",
+					env: map[string]Value{
+						"ENV01":      {Type: ValueTypeString, Value: "ENV01"},
+						"GOOS":       {Type: ValueTypeString, Value: "windows"},
+						"GO_VERSION": {Type: ValueTypeString, Value: "1.17"},
+					},
+				},
+				{
+					name:    "release",
+					depends: []string{"test (linux, 1.16)", "test (linux, 1.17)", "test (windows, 1.17)"},
+				},
+			},
+		},
+		{
+			name: "test matrix exclude with unknown axis",
+			in: `
+runs:
+  - name: run01
+    tasks:
+      - name: test
+        runtime:
+          containers:
+            - image: golang:1.17
+        matrix:
+          axes:
+            GO_VERSION: ["1.16", "1.17"]
+          exclude:
+            - GOOS: windows
+        steps:
+          - run: go test ./...
+`,
+			err: `run "run01": task "test" matrix: exclude entry at index 0: unknown axis "GOOS"`,
+		},
+		{
+			name: "test matrix with all the combinations excluded",
+			in: `
+runs:
+  - name: run01
+    tasks:
+      - name: test
+        runtime:
+          containers:
+            - image: golang:1.17
+        matrix:
+          axes:
+            GO_VERSION: ["1.16"]
+          exclude:
+            - GO_VERSION: "1.16"
+        steps:
+          - run: go test ./...
+`,
+			err: `run "run01": task "test" matrix: all the axes values combinations are excluded`,
+		},
+		{
+			name: "test matrix with invalid axis name",
+			in: `
+runs:
+  - name: run01
+    tasks:
+      - name: test
+        runtime:
+          containers:
+            - image: golang:1.17
+        matrix:
+          axes:
+            GO-VERSION: ["1.16"]
+        steps:
+          - run: go test ./...
+`,
+			err: `run "run01": task "test" matrix: invalid axis name "GO-VERSION"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseConfig([]byte(tt.in), ConfigFormatYAML, &ConfigContext{})
+			if err != nil {
+				if tt.err == "" {
+					t.Fatalf("got error: %v, expected no error", err)
+				}
+				if err.Error() != tt.err {
+					t.Fatalf("got error: %v, want error: %v", err, tt.err)
+				}
+				return
+			}
+			if tt.err != "" {
+				t.Fatalf("got nil error, want error: %v", tt.err)
+			}
+
+			var out []taskOut
+			for _, task := range config.Runs[0].Tasks {
+				var depends []string
+				for _, dep := range task.Depends {
+					depends = append(depends, dep.TaskName)
+				}
+				out = append(out, taskOut{name: task.Name, env: task.Environment, depends: depends})
+			}
+			if diff := cmp.Diff(tt.out, out, cmp.AllowUnexported(taskOut{})); diff != "" {
+				t.Fatalf(diff)
+			}
+		})
+	}
+}
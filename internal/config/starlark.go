@@ -18,11 +18,119 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"agola.io/agola/internal/errors"
 	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
 )
 
+// starlarkStdlib is the "agola" module predeclared in the starlark config. Its
+// functions return the dicts defining the config objects. The keyword
+// arguments not handled by a function are added as is to the returned dict.
+var starlarkStdlib = &starlarkstruct.Module{
+	Name: "agola",
+	Members: starlark.StringDict{
+		"config":   starlarkDictBuiltin("config", nil, "runs"),
+		"run":      starlarkDictBuiltin("run", nil, "name", "tasks"),
+		"task":     starlarkDictBuiltin("task", starlarkTaskImage, "name", "steps", "image?"),
+		"step":     starlarkDictBuiltin("step", nil, "type"),
+		"clone":    starlarkDictBuiltin("clone", starlarkStepType("clone")),
+		"run_step": starlarkDictBuiltin("run_step", starlarkStepType("run"), "command", "name?"),
+	},
+}
+
+// starlarkDictBuiltin returns a builtin creating a dict containing the
+// provided params and the extra keyword arguments. Params ending with "?" are
+// optional. The optional post function is called on the created dict.
+func starlarkDictBuiltin(name string, post func(d *starlark.Dict) error, params ...string) *starlark.Builtin {
+	keys := make(map[string]struct{}, len(params))
+	for _, p := range params {
+		keys[strings.TrimSuffix(p, "?")] = struct{}{}
+	}
+
+	return starlark.NewBuiltin(name, func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var paramsKwargs, extraKwargs []starlark.Tuple
+		for _, kw := range kwargs {
+			if _, ok := keys[string(kw[0].(starlark.String))]; ok {
+				paramsKwargs = append(paramsKwargs, kw)
+			} else {
+				extraKwargs = append(extraKwargs, kw)
+			}
+		}
+
+		values := make([]starlark.Value, len(params))
+		pairs := make([]interface{}, 0, len(params)*2)
+		for i, p := range params {
+			pairs = append(pairs, p, &values[i])
+		}
+		if err := starlark.UnpackArgs(b.Name(), args, paramsKwargs, pairs...); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		d := &starlark.Dict{}
+		for i, p := range params {
+			if values[i] == nil || values[i] == starlark.None {
+				continue
+			}
+			if err := d.SetKey(starlark.String(strings.TrimSuffix(p, "?")), values[i]); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		for _, kw := range extraKwargs {
+			if err := d.SetKey(kw[0], kw[1]); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+
+		if post != nil {
+			if err := post(d); err != nil {
+				return nil, errors.Wrapf(err, "%s", b.Name())
+			}
+		}
+
+		return d, nil
+	})
+}
+
+func starlarkStepType(stepType string) func(d *starlark.Dict) error {
+	return func(d *starlark.Dict) error {
+		return errors.WithStack(d.SetKey(starlark.String("type"), starlark.String(stepType)))
+	}
+}
+
+// starlarkTaskImage replaces the task image shortcut with a pod runtime with
+// a single container using the image
+func starlarkTaskImage(d *starlark.Dict) error {
+	image, found, err := d.Get(starlark.String("image"))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !found {
+		return nil
+	}
+	if _, found, _ := d.Get(starlark.String("runtime")); found {
+		return errors.Errorf("image and runtime cannot be both defined")
+	}
+	if _, _, err := d.Delete(starlark.String("image")); err != nil {
+		return errors.WithStack(err)
+	}
+
+	container := &starlark.Dict{}
+	if err := container.SetKey(starlark.String("image"), image); err != nil {
+		return errors.WithStack(err)
+	}
+	runtime := &starlark.Dict{}
+	if err := runtime.SetKey(starlark.String("type"), starlark.String(RuntimeTypePod)); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := runtime.SetKey(starlark.String("containers"), starlark.NewList([]starlark.Value{container})); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(d.SetKey(starlark.String("runtime"), runtime))
+}
+
 func starlarkArgs(cc *ConfigContext) (starlark.Tuple, error) {
 	d := &starlark.Dict{}
 	if err := d.SetKey(starlark.String("ref_type"), starlark.String(cc.RefType)); err != nil {
@@ -40,6 +148,9 @@ func starlarkArgs(cc *ConfigContext) (starlark.Tuple, error) {
 	if err := d.SetKey(starlark.String("pull_request_id"), starlark.String(cc.PullRequestID)); err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if err := d.SetKey(starlark.String("schedule"), starlark.String(cc.Schedule)); err != nil {
+		return nil, errors.WithStack(err)
+	}
 	if err := d.SetKey(starlark.String("commit_sha"), starlark.String(cc.CommitSHA)); err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -122,7 +233,10 @@ func execStarlark(configData []byte, configContext *ConfigContext) ([]byte, erro
 		// TODO(sgotti) redirect print to a logger?
 		Print: func(_ *starlark.Thread, msg string) {},
 	}
-	globals, err := starlark.ExecFile(thread, "config.star", configData, nil)
+	predeclared := starlark.StringDict{
+		"agola": starlarkStdlib,
+	}
+	globals, err := starlark.ExecFile(thread, "config.star", configData, predeclared)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -99,3 +99,111 @@ func TestStarlarkJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestStarlarkStdlib(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		yaml string
+		err  string
+	}{
+		{
+			name: "test stdlib config",
+			in: `
+def main(ctx):
+    steps = [
+        agola.clone(),
+        agola.run_step("go build .", name = "build"),
+        agola.step("save_to_workspace", contents = [{"source_dir": ".", "dest_dir": "/bin", "paths": ["*"]}]),
+    ]
+    return agola.config(runs = [
+        agola.run("run01", [
+            agola.task("build", steps, image = "golang:1.17", working_dir = "/go/src"),
+            agola.task("test", [agola.run_step("go test ./...")], runtime = {"type": "pod", "containers": [{"image": "golang:1.17"}]}, depends = ["build"]),
+        ], when = {"branch": ctx["branch"]}),
+    ])
+`,
+			yaml: `
+runs:
+  - name: run01
+    when:
+      branch: master
+    tasks:
+      - name: build
+        runtime:
+          type: pod
+          containers:
+            - image: golang:1.17
+        working_dir: /go/src
+        steps:
+          - type: clone
+          - type: run
+            name: build
+            command: go build .
+          - type: save_to_workspace
+            contents:
+              - source_dir: .
+                dest_dir: /bin
+                paths:
+                  - "*"
+      - name: test
+        runtime:
+          type: pod
+          containers:
+            - image: golang:1.17
+        depends:
+          - build
+        steps:
+          - type: run
+            command: go test ./...
+`,
+		},
+		{
+			name: "test task with image and runtime",
+			in: `
+def main(ctx):
+    return agola.config(runs = [
+        agola.run("run01", [
+            agola.task("build", [agola.clone()], image = "golang:1.17", runtime = {"type": "pod"}),
+        ]),
+    ])
+`,
+			err: "failed to execute starlark: task: image and runtime cannot be both defined",
+		},
+		{
+			name: "test missing required param",
+			in: `
+def main(ctx):
+    return agola.config(runs = [agola.run("run01")])
+`,
+			err: "failed to execute starlark: run: missing argument for tasks",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configContext := &ConfigContext{Branch: "master"}
+			out, err := ParseConfig([]byte(tt.in), ConfigFormatStarlark, configContext)
+			if err != nil {
+				if tt.err == "" {
+					t.Fatalf("got error: %v, expected no error", err)
+				}
+				if err.Error() != tt.err {
+					t.Fatalf("got error: %v, want error: %v", err, tt.err)
+				}
+				return
+			}
+			if tt.err != "" {
+				t.Fatalf("got nil error, want error: %v", tt.err)
+			}
+
+			expected, err := ParseConfig([]byte(tt.yaml), ConfigFormatYAML, configContext)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(expected, out); diff != "" {
+				t.Fatalf(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"agola.io/agola/internal/errors"
+)
+
+// applyTaskTemplates replaces the run tasks extending a task template with the
+// template merged with the task definition. The task fields override the
+// template ones, with these exceptions:
+//
+//	environment, labels and docker_registries_auth are merged by key
+//	boolean fields can only be enabled since a false value isn't an override
+//
+// Task templates cannot extend other task templates.
+func applyTaskTemplates(run *Run, templates map[string]*Task) error {
+	for i, task := range run.Tasks {
+		if task == nil || task.Extends == "" {
+			continue
+		}
+
+		template, ok := templates[task.Extends]
+		if !ok {
+			return errors.Errorf("task %q extends unknown task template %q", task.Name, task.Extends)
+		}
+		if template == nil {
+			return errors.Errorf("task template %q is empty", task.Extends)
+		}
+		if template.Extends != "" {
+			return errors.Errorf("task template %q: task templates cannot extend other task templates", task.Extends)
+		}
+
+		run.Tasks[i] = mergeTaskTemplate(template, task)
+	}
+
+	return nil
+}
+
+func mergeTaskTemplate(template, task *Task) *Task {
+	nt := *template
+	nt.Name = task.Name
+	nt.Extends = ""
+
+	if task.Runtime != nil {
+		nt.Runtime = task.Runtime
+	}
+	if task.WorkingDir != "" {
+		nt.WorkingDir = task.WorkingDir
+	}
+	if task.Shell != "" {
+		nt.Shell = task.Shell
+	}
+	if task.User != "" {
+		nt.User = task.User
+	}
+	if len(task.Steps) > 0 {
+		nt.Steps = task.Steps
+	}
+	if len(task.Depends) > 0 {
+		nt.Depends = task.Depends
+	}
+	if task.When != nil {
+		nt.When = task.When
+	}
+	if task.DockerLayerCache != nil {
+		nt.DockerLayerCache = task.DockerLayerCache
+	}
+	if task.Matrix != nil {
+		nt.Matrix = task.Matrix
+	}
+	if task.Timeout != "" {
+		nt.Timeout = task.Timeout
+	}
+	if task.Retries != 0 {
+		nt.Retries = task.Retries
+	}
+	if task.RetryInterval != "" {
+		nt.RetryInterval = task.RetryInterval
+	}
+	if task.RetryBackoff != 0 {
+		nt.RetryBackoff = task.RetryBackoff
+	}
+
+	nt.IgnoreFailure = template.IgnoreFailure || task.IgnoreFailure
+	nt.AllowFailure = template.AllowFailure || task.AllowFailure
+	nt.Approval = template.Approval
+	if task.Approval.Required {
+		nt.Approval = task.Approval
+	}
+	nt.SkipWorkspace = template.SkipWorkspace || task.SkipWorkspace
+	nt.AlwaysRun = template.AlwaysRun || task.AlwaysRun
+
+	if len(template.Environment)+len(task.Environment) > 0 {
+		nt.Environment = make(map[string]Value, len(template.Environment)+len(task.Environment))
+		for k, v := range template.Environment {
+			nt.Environment[k] = v
+		}
+		for k, v := range task.Environment {
+			nt.Environment[k] = v
+		}
+	}
+	if len(template.Labels)+len(task.Labels) > 0 {
+		nt.Labels = make(map[string]string, len(template.Labels)+len(task.Labels))
+		for k, v := range template.Labels {
+			nt.Labels[k] = v
+		}
+		for k, v := range task.Labels {
+			nt.Labels[k] = v
+		}
+	}
+	if len(template.DockerRegistriesAuth)+len(task.DockerRegistriesAuth) > 0 {
+		nt.DockerRegistriesAuth = make(map[string]*DockerRegistryAuth, len(template.DockerRegistriesAuth)+len(task.DockerRegistriesAuth))
+		for k, v := range template.DockerRegistriesAuth {
+			nt.DockerRegistriesAuth[k] = v
+		}
+		for k, v := range task.DockerRegistriesAuth {
+			nt.DockerRegistriesAuth[k] = v
+		}
+	}
+
+	return &nt
+}
+
+// applyRunDefaults merges the run environment and defaults into every run
+// task. The task fields, also when inherited from a task template, override
+// the run ones and the environment is merged by key.
+func applyRunDefaults(run *Run) {
+	if len(run.Environment) == 0 && run.Defaults == nil {
+		return
+	}
+
+	for _, task := range run.Tasks {
+		if task == nil {
+			continue
+		}
+
+		if len(run.Environment) > 0 {
+			env := make(map[string]Value, len(run.Environment)+len(task.Environment))
+			for k, v := range run.Environment {
+				env[k] = v
+			}
+			for k, v := range task.Environment {
+				env[k] = v
+			}
+			task.Environment = env
+		}
+
+		if run.Defaults == nil {
+			continue
+		}
+		if task.Runtime == nil {
+			task.Runtime = run.Defaults.Runtime
+		}
+		if task.Shell == "" {
+			task.Shell = run.Defaults.Shell
+		}
+		if task.WorkingDir == "" {
+			task.WorkingDir = run.Defaults.WorkingDir
+		}
+	}
+}
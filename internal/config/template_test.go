@@ -0,0 +1,266 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTaskTemplates(t *testing.T) {
+	type taskOut struct {
+		name          string
+		image         string
+		env           map[string]Value
+		labels        map[string]string
+		steps         int
+		ignoreFailure bool
+	}
+
+	templates := `
+task_templates:
+  go:
+    runtime:
+      containers:
+        - image: golang:1.17
+    environment:
+      ENV01: ENV01
+      ENV02: ENV02
+    labels:
+      label01: value01
+    steps:
+      - clone:
+      - run: go test ./...
+`
+
+	tests := []struct {
+		name string
+		in   string
+		out  []taskOut
+		err  string
+	}{
+		{
+			name: "test task extending a template",
+			in: templates + `
+runs:
+  - name: run01
+    tasks:
+      - name: test
+        extends: go
+      - name: test-race
+        extends: go
+        ignore_failure: true
+        runtime:
+          containers:
+            - image: golang:1.16
+        environment:
+          ENV02: ENV02-override
+          ENV03: ENV03
+        steps:
+          - clone:
+          - run: go test -race ./...
+          - run: echo done
+`,
+			out: []taskOut{
+				{
+					name:   "test",
+					image:  "golang:1.17",
+					env:    map[string]Value{"ENV01": {Value: "ENV01"}, "ENV02": {Value: "ENV02"}},
+					labels: map[string]string{"label01": "value01"},
+					steps:  2,
+				},
+				{
+					name:          "test-race",
+					image:         "golang:1.16",
+					env:           map[string]Value{"ENV01": {Value: "ENV01"}, "ENV02": {Value: "ENV02-override"}, "ENV03": {Value: "ENV03"}},
+					labels:        map[string]string{"label01": "value01"},
+					steps:         3,
+					ignoreFailure: true,
+				},
+			},
+		},
+		{
+			name: "test task extending an unknown template",
+			in: templates + `
+runs:
+  - name: run01
+    tasks:
+      - name: test
+        extends: unknown
+`,
+			err: `run "run01": task "test" extends unknown task template "unknown"`,
+		},
+		{
+			name: "test task template extending another template",
+			in: templates + `
+  go-race:
+    extends: go
+runs:
+  - name: run01
+    tasks:
+      - name: test
+        extends: go-race
+`,
+			err: `run "run01": task template "go-race": task templates cannot extend other task templates`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseConfig([]byte(tt.in), ConfigFormatYAML, &ConfigContext{})
+			if err != nil {
+				if tt.err == "" {
+					t.Fatalf("got error: %v, expected no error", err)
+				}
+				if err.Error() != tt.err {
+					t.Fatalf("got error: %v, want error: %v", err, tt.err)
+				}
+				return
+			}
+			if tt.err != "" {
+				t.Fatalf("got nil error, want error: %v", tt.err)
+			}
+
+			var out []taskOut
+			for _, task := range config.Runs[0].Tasks {
+				out = append(out, taskOut{
+					name:          task.Name,
+					image:         task.Runtime.Containers[0].Image,
+					env:           task.Environment,
+					labels:        task.Labels,
+					steps:         len(task.Steps),
+					ignoreFailure: task.IgnoreFailure,
+				})
+			}
+			if diff := cmp.Diff(tt.out, out, cmp.AllowUnexported(taskOut{})); diff != "" {
+				t.Fatalf(diff)
+			}
+		})
+	}
+}
+
+func TestRunDefaults(t *testing.T) {
+	type taskOut struct {
+		name       string
+		image      string
+		env        map[string]Value
+		shell      string
+		workingDir string
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		out  []taskOut
+		err  string
+	}{
+		{
+			name: "test run environment and defaults",
+			in: `
+task_templates:
+  go:
+    runtime:
+      containers:
+        - image: golang:1.17
+    shell: /bin/bash
+runs:
+  - name: run01
+    environment:
+      ENV01: ENV01
+      ENV02: ENV02
+    defaults:
+      runtime:
+        containers:
+          - image: alpine
+      shell: /bin/sh
+      working_dir: /src
+    tasks:
+      - name: build
+        steps:
+          - run: make
+      - name: test
+        extends: go
+        working_dir: /go/src
+        environment:
+          ENV02: ENV02-override
+        steps:
+          - run: go test ./...
+`,
+			out: []taskOut{
+				{
+					name:       "build",
+					image:      "alpine",
+					env:        map[string]Value{"ENV01": {Type: ValueTypeString, Value: "ENV01"}, "ENV02": {Type: ValueTypeString, Value: "ENV02"}},
+					shell:      "/bin/sh",
+					workingDir: "/src",
+				},
+				{
+					name:       "test",
+					image:      "golang:1.17",
+					env:        map[string]Value{"ENV01": {Type: ValueTypeString, Value: "ENV01"}, "ENV02": {Type: ValueTypeString, Value: "ENV02-override"}},
+					shell:      "/bin/bash",
+					workingDir: "/go/src",
+				},
+			},
+		},
+		{
+			name: "test task without runtime and no run default runtime",
+			in: `
+runs:
+  - name: run01
+    defaults:
+      shell: /bin/sh
+    tasks:
+      - name: build
+        steps:
+          - run: make
+`,
+			err: `task "build": runtime is not defined`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseConfig([]byte(tt.in), ConfigFormatYAML, &ConfigContext{})
+			if err != nil {
+				if tt.err == "" {
+					t.Fatalf("got error: %v, expected no error", err)
+				}
+				if err.Error() != tt.err {
+					t.Fatalf("got error: %v, want error: %v", err, tt.err)
+				}
+				return
+			}
+			if tt.err != "" {
+				t.Fatalf("got nil error, want error: %v", tt.err)
+			}
+
+			var out []taskOut
+			for _, task := range config.Runs[0].Tasks {
+				out = append(out, taskOut{
+					name:       task.Name,
+					image:      task.Runtime.Containers[0].Image,
+					env:        task.Environment,
+					shell:      string(task.Shell),
+					workingDir: task.WorkingDir,
+				})
+			}
+			if diff := cmp.Diff(tt.out, out, cmp.AllowUnexported(taskOut{})); diff != "" {
+				t.Fatalf(diff)
+			}
+		})
+	}
+}
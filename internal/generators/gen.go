@@ -11,6 +11,11 @@ func init() {
 func main() {
 	flag.Parse()
 
-	genInsertDelete()
-	genFetch()
+	switch componentName {
+	case "gateway":
+		genOpenAPI()
+	default:
+		genInsertDelete()
+		genFetch()
+	}
 }
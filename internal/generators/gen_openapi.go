@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"text/template"
+
+	"agola.io/agola/internal/services/gateway/openapi"
+)
+
+func genOpenAPI() {
+	spec, err := openapi.Generate()
+	if err != nil {
+		panic(err)
+	}
+	// the spec is embedded in a raw string literal
+	if bytes.ContainsRune(spec, '`') {
+		panic("openapi spec contains a backtick")
+	}
+
+	f, err := os.Create("spec.go")
+	if err != nil {
+		panic(err)
+	}
+
+	defer f.Close()
+
+	if err := openAPITemplate.Execute(f, string(spec)); err != nil {
+		panic(err)
+	}
+}
+
+var openAPITemplate = template.Must(template.New("").Parse(`// Code generated by go generate; DO NOT EDIT.
+package openapi
+
+// Spec is the gateway api OpenAPI specification in json format
+const Spec = ` + "`{{ . }}`" + `
+`))
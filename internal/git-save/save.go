@@ -137,9 +137,16 @@ func GitAddRemote(configPath, name, url string) error {
 	return errors.WithStack(err)
 }
 
-func GitPush(configPath, remote, branch string) error {
+// GitPush force pushes the branch to the remote. If token isn't empty it's
+// provided as agola token in the requests authorization header.
+func GitPush(configPath, remote, branch, token string) error {
 	git := &util.Git{}
-	_, err := git.Output(context.Background(), nil, "push", remote, branch, "-f")
+	args := []string{}
+	if token != "" {
+		args = append(args, "-c", "http.extraHeader=Authorization: token "+token)
+	}
+	args = append(args, "push", remote, branch, "-f")
+	_, err := git.Output(context.Background(), nil, args...)
 	return errors.WithStack(err)
 }
 
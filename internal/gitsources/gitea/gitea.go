@@ -71,6 +71,8 @@ type Client struct {
 // fromCommitStatus converts a gitsource commit status to a gitea commit status
 func fromCommitStatus(status gitsource.CommitStatus) gitea.StatusState {
 	switch status {
+	case gitsource.CommitStatusQueued:
+		return gitea.StatusPending
 	case gitsource.CommitStatusPending:
 		return gitea.StatusPending
 	case gitsource.CommitStatusSuccess:
@@ -79,6 +81,8 @@ func fromCommitStatus(status gitsource.CommitStatus) gitea.StatusState {
 		return gitea.StatusError
 	case gitsource.CommitStatusFailed:
 		return gitea.StatusFailure
+	case gitsource.CommitStatusSkipped:
+		return gitea.StatusWarning
 	default:
 		panic(errors.Errorf("unknown commit status %q", status))
 	}
@@ -87,7 +91,7 @@ func fromCommitStatus(status gitsource.CommitStatus) gitea.StatusState {
 func parseRepoPath(repopath string) (string, string, error) {
 	parts := strings.Split(repopath, "/")
 	if len(parts) != 2 {
-		return "", "", errors.Errorf("wrong gitea repo path: %q", repopath)
+		return "", "", errors.Errorf("wrong gitea repo path: %q, the path must be in the form owner/repo", repopath)
 	}
 	return parts[0], parts[1], nil
 }
@@ -107,7 +111,7 @@ func New(opts Opts) (*Client, error) {
 		ExpectContinueTimeout: 1 * time.Second,
 		TLSClientConfig:       &tls.Config{InsecureSkipVerify: opts.SkipVerify},
 	}
-	httpClient := &http.Client{Transport: transport}
+	httpClient := &http.Client{Transport: gitsource.NewRateLimitTransport(opts.APIURL, transport)}
 
 	client := gitea.NewClient(opts.APIURL, opts.Token)
 	client.SetHTTPClient(httpClient)
@@ -222,9 +226,11 @@ func (c *Client) GetUserInfo() (*gitsource.UserInfo, error) {
 		return nil, errors.WithStack(err)
 	}
 	return &gitsource.UserInfo{
-		ID:        strconv.FormatInt(user.ID, 10),
-		LoginName: user.UserName,
-		Email:     user.Email,
+		ID:          strconv.FormatInt(user.ID, 10),
+		LoginName:   user.UserName,
+		Email:       user.Email,
+		DisplayName: user.FullName,
+		AvatarURL:   user.AvatarURL,
 	}, nil
 }
 
@@ -237,7 +243,16 @@ func (c *Client) GetRepoInfo(repopath string) (*gitsource.RepoInfo, error) {
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return fromGiteaRepo(rr), nil
+	repoInfo := fromGiteaRepo(rr)
+
+	// the gitea repository doesn't contain the topics
+	topics, err := c.client.ListRepoTopics(owner, reponame, gitea.ListRepoTopicsOptions{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	repoInfo.Topics = topics
+
+	return repoInfo, nil
 }
 
 func (c *Client) GetFile(repopath, commit, file string) ([]byte, error) {
@@ -369,6 +384,42 @@ func (c *Client) DeleteRepoWebhook(repopath, u string) error {
 	return nil
 }
 
+func (c *Client) CreateOrgWebhook(org, url, secret string) error {
+	opts := gitea.CreateHookOption{
+		Type: "gitea",
+		Config: map[string]string{
+			"url":          url,
+			"content_type": "json",
+			"secret":       secret,
+		},
+		Events: []string{"push", "pull_request"},
+		Active: true,
+	}
+
+	if _, err := c.client.CreateOrgHook(org, opts); err != nil {
+		return errors.Wrapf(err, "error creating organization webhook")
+	}
+
+	return nil
+}
+
+func (c *Client) DeleteOrgWebhook(org, u string) error {
+	hooks, err := c.client.ListOrgHooks(org, gitea.ListHooksOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "error retrieving organization webhooks")
+	}
+
+	for _, hook := range hooks {
+		if hook.Config["url"] == u {
+			if err := c.client.DeleteOrgHook(org, hook.ID); err != nil {
+				return errors.Wrapf(err, "error deleting existing organization webhook")
+			}
+		}
+	}
+
+	return nil
+}
+
 func (c *Client) CreateCommitStatus(repopath, commitSHA string, status gitsource.CommitStatus, targetURL, description, context string) error {
 	owner, reponame, err := parseRepoPath(repopath)
 	if err != nil {
@@ -383,6 +434,45 @@ func (c *Client) CreateCommitStatus(repopath, commitSHA string, status gitsource
 	return errors.WithStack(err)
 }
 
+func (c *Client) CreateOrUpdatePullRequestComment(repopath, prID, marker, body string) error {
+	owner, reponame, err := parseRepoPath(repopath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	index, err := strconv.ParseInt(prID, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "wrong pull request id %q", prID)
+	}
+
+	page := 1
+	for {
+		comments, err := c.client.ListIssueComments(owner, reponame, index, gitea.ListIssueCommentOptions{
+			ListOptions: gitea.ListOptions{
+				Page:     page,
+				PageSize: 50, // Gitea SDK limit per page.
+			},
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		for _, comment := range comments {
+			if strings.Contains(comment.Body, marker) {
+				_, err := c.client.EditIssueComment(owner, reponame, comment.ID, gitea.EditIssueCommentOption{Body: body})
+				return errors.WithStack(err)
+			}
+		}
+
+		if len(comments) < 50 {
+			break
+		}
+		page++
+	}
+
+	_, err = c.client.CreateIssueComment(owner, reponame, index, gitea.CreateIssueCommentOption{Body: body})
+	return errors.WithStack(err)
+}
+
 func (c *Client) ListUserRepos() ([]*gitsource.RepoInfo, error) {
 	page := 1
 	repos := []*gitsource.RepoInfo{}
@@ -29,6 +29,7 @@ import (
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/services/types"
+	"agola.io/agola/internal/util"
 )
 
 const (
@@ -52,8 +53,10 @@ func (c *Client) ParseWebhook(r *http.Request, secret string) (*types.WebhookDat
 
 	// verify signature
 	signature := r.Header.Get(signatureHeader)
-	// old versions of gitea doesn't provide a signature
-	if secret != "" && signature != "" {
+	if secret != "" {
+		if signature == "" {
+			return nil, errors.Errorf("missing webhook signature")
+		}
 		ds, err := hex.DecodeString(signature)
 		if err != nil {
 			return nil, errors.Errorf("wrong webhook signature")
@@ -136,6 +139,7 @@ func webhookDataFromPush(hook *pushHook) (*types.WebhookData, error) {
 		if len(hook.Commits) > 0 {
 			whd.Message = hook.Commits[0].Message
 		}
+		whd.ChangedFiles = pushChangedFiles(hook)
 	case strings.HasPrefix(hook.Ref, "refs/tags/"):
 		whd.Event = types.WebhookEventTag
 		whd.Tag = strings.TrimPrefix(hook.Ref, "refs/tags/")
@@ -150,6 +154,18 @@ func webhookDataFromPush(hook *pushHook) (*types.WebhookData, error) {
 }
 
 // helper function that extracts the Build data from a Gitea pull_request hook
+// pushChangedFiles returns the files changed by the push commits
+func pushChangedFiles(hook *pushHook) []string {
+	files := []string{}
+	for _, c := range hook.Commits {
+		files = append(files, c.Added...)
+		files = append(files, c.Removed...)
+		files = append(files, c.Modified...)
+	}
+
+	return util.UniqueSortedStrings(files)
+}
+
 func webhookDataFromPullRequest(hook *pullRequestHook) *types.WebhookData {
 	sender := hook.Sender.Username
 	if sender == "" {
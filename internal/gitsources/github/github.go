@@ -30,6 +30,7 @@ import (
 
 	"agola.io/agola/internal/errors"
 	gitsource "agola.io/agola/internal/gitsources"
+	"agola.io/agola/internal/util"
 
 	"github.com/google/go-github/v29/github"
 	"golang.org/x/oauth2"
@@ -44,6 +45,9 @@ var (
 	pullRequestRefFmt   = "refs/pull/%s/head"
 )
 
+// maxCompareFiles is the max number of files returned by the compare api
+const maxCompareFiles = 300
+
 const (
 	GitHubAPIURL = "https://api.github.com"
 	GitHubWebURL = "https://github.com"
@@ -72,6 +76,8 @@ type Client struct {
 // fromCommitStatus converts a gitsource commit status to a github commit status
 func fromCommitStatus(status gitsource.CommitStatus) string {
 	switch status {
+	case gitsource.CommitStatusQueued:
+		return "pending"
 	case gitsource.CommitStatusPending:
 		return "pending"
 	case gitsource.CommitStatusSuccess:
@@ -80,6 +86,9 @@ func fromCommitStatus(status gitsource.CommitStatus) string {
 		return "error"
 	case gitsource.CommitStatusFailed:
 		return "failure"
+	case gitsource.CommitStatusSkipped:
+		// github commit statuses don't have a neutral state
+		return "success"
 	default:
 		panic(errors.Errorf("unknown commit status %q", status))
 	}
@@ -88,7 +97,7 @@ func fromCommitStatus(status gitsource.CommitStatus) string {
 func parseRepoPath(repopath string) (string, string, error) {
 	parts := strings.Split(repopath, "/")
 	if len(parts) != 2 {
-		return "", "", errors.Errorf("wrong github repo path: %q", repopath)
+		return "", "", errors.Errorf("wrong github repo path: %q, the path must be in the form owner/repo", repopath)
 	}
 	return parts[0], parts[1], nil
 }
@@ -122,7 +131,7 @@ func New(opts Opts) (*Client, error) {
 		ExpectContinueTimeout: 1 * time.Second,
 		TLSClientConfig:       &tls.Config{InsecureSkipVerify: opts.SkipVerify},
 	}
-	httpClient := &http.Client{Transport: &TokenTransport{token: opts.Token, rt: transport}}
+	httpClient := &http.Client{Transport: &TokenTransport{token: opts.Token, rt: gitsource.NewRateLimitTransport(opts.APIURL, transport)}}
 	oauth2HTTPClient := &http.Client{Transport: transport}
 
 	isPublicGithub := false
@@ -216,6 +225,12 @@ func (c *Client) GetUserInfo() (*gitsource.UserInfo, error) {
 	if user.Email != nil {
 		userInfo.Email = *user.Email
 	}
+	if user.Name != nil {
+		userInfo.DisplayName = *user.Name
+	}
+	if user.AvatarURL != nil {
+		userInfo.AvatarURL = *user.AvatarURL
+	}
 
 	return userInfo, nil
 }
@@ -376,6 +391,51 @@ func (c *Client) DeleteRepoWebhook(repopath, u string) error {
 	return nil
 }
 
+func (c *Client) CreateOrgWebhook(org, url, secret string) error {
+	hook := &github.Hook{
+		Config: map[string]interface{}{
+			"url":          url,
+			"content_type": "json",
+			"secret":       secret,
+		},
+		Events: []string{"push", "pull_request"},
+		Active: github.Bool(true),
+	}
+
+	if _, _, err := c.client.Organizations.CreateHook(context.TODO(), org, hook); err != nil {
+		return errors.Wrapf(err, "error creating organization webhook")
+	}
+
+	return nil
+}
+
+func (c *Client) DeleteOrgWebhook(org, u string) error {
+	hooks := []*github.Hook{}
+
+	opt := &github.ListOptions{}
+	for {
+		pHooks, resp, err := c.client.Organizations.ListHooks(context.TODO(), org, opt)
+		if err != nil {
+			return errors.Wrapf(err, "error retrieving organization webhooks")
+		}
+		hooks = append(hooks, pHooks...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	for _, hook := range hooks {
+		if hook.Config["url"] == u {
+			if _, err := c.client.Organizations.DeleteHook(context.TODO(), org, *hook.ID); err != nil {
+				return errors.Wrapf(err, "error deleting existing organization webhook")
+			}
+		}
+	}
+
+	return nil
+}
+
 func (c *Client) CreateCommitStatus(repopath, commitSHA string, status gitsource.CommitStatus, targetURL, description, statusContext string) error {
 	owner, reponame, err := parseRepoPath(repopath)
 	if err != nil {
@@ -390,6 +450,164 @@ func (c *Client) CreateCommitStatus(repopath, commitSHA string, status gitsource
 	return errors.WithStack(err)
 }
 
+func (c *Client) CreateOrUpdatePullRequestComment(repopath, prID, marker, body string) error {
+	owner, reponame, err := parseRepoPath(repopath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	number, err := strconv.Atoi(prID)
+	if err != nil {
+		return errors.Wrapf(err, "wrong pull request id %q", prID)
+	}
+
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := c.client.Issues.ListComments(context.TODO(), owner, reponame, number, opts)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		for _, comment := range comments {
+			if strings.Contains(comment.GetBody(), marker) {
+				_, _, err := c.client.Issues.EditComment(context.TODO(), owner, reponame, comment.GetID(), &github.IssueComment{Body: github.String(body)})
+				return errors.WithStack(err)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	_, _, err = c.client.Issues.CreateComment(context.TODO(), owner, reponame, number, &github.IssueComment{Body: github.String(body)})
+	return errors.WithStack(err)
+}
+
+func (c *Client) CompareCommits(repopath, base, head string) ([]string, error) {
+	owner, reponame, err := parseRepoPath(repopath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	comparison, _, err := c.client.Repositories.CompareCommits(context.TODO(), owner, reponame, base, head)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// the changed files are truncated
+	if len(comparison.Files) >= maxCompareFiles {
+		return nil, nil
+	}
+
+	files := []string{}
+	for _, f := range comparison.Files {
+		files = append(files, f.GetFilename())
+		// a renamed file is also a change of its previous path
+		if f.PreviousFilename != nil {
+			files = append(files, f.GetPreviousFilename())
+		}
+	}
+
+	return util.UniqueSortedStrings(files), nil
+}
+
+// fromCheckRunStatus converts a gitsource commit status to a github check run
+// status and conclusion
+func fromCheckRunStatus(status gitsource.CommitStatus) (string, string) {
+	switch status {
+	case gitsource.CommitStatusQueued:
+		return "queued", ""
+	case gitsource.CommitStatusPending:
+		return "in_progress", ""
+	case gitsource.CommitStatusSuccess:
+		return "completed", "success"
+	case gitsource.CommitStatusError:
+		return "completed", "failure"
+	case gitsource.CommitStatusFailed:
+		return "completed", "failure"
+	case gitsource.CommitStatusSkipped:
+		return "completed", "neutral"
+	default:
+		panic(errors.Errorf("unknown commit status %q", status))
+	}
+}
+
+func (c *Client) CreateOrUpdateCheckRun(repopath, commitSHA string, checkRun *gitsource.CheckRun) error {
+	owner, reponame, err := parseRepoPath(repopath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	status, conclusion := fromCheckRunStatus(checkRun.Status)
+
+	output := &github.CheckRunOutput{
+		Title:   github.String(checkRun.Title),
+		Summary: github.String(checkRun.Summary),
+	}
+	if checkRun.Text != "" {
+		output.Text = github.String(checkRun.Text)
+	}
+	for _, a := range checkRun.Annotations {
+		output.Annotations = append(output.Annotations, &github.CheckRunAnnotation{
+			Path:            github.String(a.Path),
+			StartLine:       github.Int(1),
+			EndLine:         github.Int(1),
+			AnnotationLevel: github.String(string(a.Level)),
+			Title:           github.String(a.Title),
+			Message:         github.String(a.Message),
+		})
+	}
+
+	var completedAt *github.Timestamp
+	if conclusion != "" {
+		completedAt = &github.Timestamp{Time: time.Now()}
+	}
+
+	// find an existing check run for the same agola run
+	checkRuns, _, err := c.client.Checks.ListCheckRunsForRef(context.TODO(), owner, reponame, commitSHA, &github.ListCheckRunsOptions{CheckName: github.String(checkRun.Name)})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list check runs")
+	}
+	var checkRunID *int64
+	for _, cr := range checkRuns.CheckRuns {
+		if cr.GetExternalID() == checkRun.ExternalID {
+			checkRunID = cr.ID
+			break
+		}
+	}
+
+	if checkRunID != nil {
+		opts := github.UpdateCheckRunOptions{
+			Name:        checkRun.Name,
+			DetailsURL:  github.String(checkRun.DetailsURL),
+			ExternalID:  github.String(checkRun.ExternalID),
+			Status:      github.String(status),
+			CompletedAt: completedAt,
+			Output:      output,
+		}
+		if conclusion != "" {
+			opts.Conclusion = github.String(conclusion)
+		}
+		_, _, err := c.client.Checks.UpdateCheckRun(context.TODO(), owner, reponame, *checkRunID, opts)
+		return errors.WithStack(err)
+	}
+
+	opts := github.CreateCheckRunOptions{
+		Name:        checkRun.Name,
+		HeadSHA:     commitSHA,
+		DetailsURL:  github.String(checkRun.DetailsURL),
+		ExternalID:  github.String(checkRun.ExternalID),
+		Status:      github.String(status),
+		CompletedAt: completedAt,
+		Output:      output,
+	}
+	if conclusion != "" {
+		opts.Conclusion = github.String(conclusion)
+	}
+	_, _, err = c.client.Checks.CreateCheckRun(context.TODO(), owner, reponame, opts)
+	return errors.WithStack(err)
+}
+
 func (c *Client) ListUserRepos() ([]*gitsource.RepoInfo, error) {
 	remoteRepos := []*github.Repository{}
 
@@ -428,6 +646,7 @@ func fromGithubRepo(rr *github.Repository) *gitsource.RepoInfo {
 		HTMLURL:      *rr.HTMLURL,
 		SSHCloneURL:  *rr.SSHURL,
 		HTTPCloneURL: *rr.CloneURL,
+		Topics:       rr.Topics,
 	}
 }
 
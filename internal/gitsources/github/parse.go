@@ -23,6 +23,7 @@ import (
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/services/types"
+	"agola.io/agola/internal/util"
 
 	"github.com/google/go-github/v29/github"
 )
@@ -81,6 +82,11 @@ func webhookDataFromPush(hook *github.PushEvent) (*types.WebhookData, error) {
 		whd.Branch = strings.TrimPrefix(*hook.Ref, "refs/heads/")
 		whd.BranchLink = fmt.Sprintf("%s/tree/%s", *hook.Repo.HTMLURL, whd.Branch)
 		whd.Message = *hook.HeadCommit.Message
+		whd.ChangedFiles = pushChangedFiles(hook)
+		// the before commit is all zeros for new branches
+		if hook.Before != nil && strings.Trim(*hook.Before, "0") != "" {
+			whd.CompareBase = *hook.Before
+		}
 
 	case strings.HasPrefix(*hook.Ref, "refs/tags/"):
 		whd.Event = types.WebhookEventTag
@@ -102,6 +108,23 @@ func webhookDataFromPush(hook *github.PushEvent) (*types.WebhookData, error) {
 	return whd, nil
 }
 
+// pushChangedFiles returns the files changed by the push commits or nil if
+// they aren't all reported in the webhook
+func pushChangedFiles(hook *github.PushEvent) []string {
+	if hook.Size != nil && *hook.Size > len(hook.Commits) {
+		return nil
+	}
+
+	files := []string{}
+	for _, c := range hook.Commits {
+		files = append(files, c.Added...)
+		files = append(files, c.Removed...)
+		files = append(files, c.Modified...)
+	}
+
+	return util.UniqueSortedStrings(files)
+}
+
 func webhookDataFromPullRequest(hook *github.PullRequestEvent) (*types.WebhookData, error) {
 	// skip non open pull requests
 	if *hook.PullRequest.State != prStateOpen {
@@ -132,6 +155,7 @@ func webhookDataFromPullRequest(hook *github.PullRequestEvent) (*types.WebhookDa
 		PullRequestID:   strconv.Itoa(*hook.PullRequest.Number),
 		PullRequestLink: *hook.PullRequest.HTMLURL,
 		PRFromSameRepo:  prFromSameRepo,
+		CompareBase:     hook.PullRequest.Base.GetSHA(),
 
 		Repo: types.WebhookDataRepo{
 			Path:   path.Join(*hook.Repo.Owner.Login, *hook.Repo.Name),
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -28,6 +29,7 @@ import (
 
 	"agola.io/agola/internal/errors"
 	gitsource "agola.io/agola/internal/gitsources"
+	"agola.io/agola/internal/util"
 
 	gitlab "github.com/xanzy/go-gitlab"
 	"golang.org/x/oauth2"
@@ -61,19 +63,45 @@ type Client struct {
 // fromCommitStatus converts a gitsource commit status to a gitlab commit status
 func fromCommitStatus(status gitsource.CommitStatus) gitlab.BuildStateValue {
 	switch status {
-	case gitsource.CommitStatusPending:
+	case gitsource.CommitStatusQueued:
 		return gitlab.Pending
+	case gitsource.CommitStatusPending:
+		return gitlab.Running
 	case gitsource.CommitStatusSuccess:
 		return gitlab.Success
 	case gitsource.CommitStatusError:
 		return gitlab.Failed
 	case gitsource.CommitStatusFailed:
 		return gitlab.Failed
+	case gitsource.CommitStatusSkipped:
+		return gitlab.Skipped
 	default:
 		panic(errors.Errorf("unknown commit status %q", status))
 	}
 }
 
+// parseRepoPath validates and cleans a gitlab repository path. Gitlab
+// repositories can be inside nested groups (subgroups) so the path is made
+// of one or more namespaces followed by the repository name (i.e.
+// group/subgroup01/subgroup02/repo).
+// Leading and trailing slashes and the .git suffix are removed.
+func parseRepoPath(repopath string) (string, error) {
+	p := strings.Trim(strings.TrimSpace(repopath), "/")
+	p = strings.TrimSuffix(p, ".git")
+
+	parts := strings.Split(p, "/")
+	if len(parts) < 2 {
+		return "", errors.Errorf("wrong gitlab repo path: %q", repopath)
+	}
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return "", errors.Errorf("wrong gitlab repo path: %q", repopath)
+		}
+	}
+
+	return p, nil
+}
+
 func New(opts Opts) (*Client, error) {
 	// copied from net/http until it has a clone function: https://github.com/golang/go/issues/26013
 	transport := &http.Transport{
@@ -89,7 +117,7 @@ func New(opts Opts) (*Client, error) {
 		ExpectContinueTimeout: 1 * time.Second,
 		TLSClientConfig:       &tls.Config{InsecureSkipVerify: opts.SkipVerify},
 	}
-	httpClient := &http.Client{Transport: transport}
+	httpClient := &http.Client{Transport: gitsource.NewRateLimitTransport(opts.APIURL, transport)}
 
 	client := gitlab.NewOAuthClient(httpClient, opts.Token)
 	if err := client.SetBaseURL(opts.APIURL); err != nil {
@@ -148,6 +176,10 @@ func (c *Client) RefreshOauth2Token(refreshToken string) (*oauth2.Token, error)
 }
 
 func (c *Client) GetRepoInfo(repopath string) (*gitsource.RepoInfo, error) {
+	repopath, err := parseRepoPath(repopath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 	rr, _, err := c.client.Projects.GetProject(repopath, nil)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -161,9 +193,11 @@ func (c *Client) GetUserInfo() (*gitsource.UserInfo, error) {
 		return nil, errors.WithStack(err)
 	}
 	return &gitsource.UserInfo{
-		ID:        strconv.Itoa(user.ID),
-		LoginName: user.Username,
-		Email:     user.Email,
+		ID:          strconv.Itoa(user.ID),
+		LoginName:   user.Username,
+		Email:       user.Email,
+		DisplayName: user.Name,
+		AvatarURL:   user.AvatarURL,
 	}, nil
 }
 
@@ -268,6 +302,61 @@ func (c *Client) DeleteRepoWebhook(repopath, u string) error {
 	return nil
 }
 
+// groupHook is a gitlab group webhook. Group webhooks aren't provided by the
+// gitlab client library so they are managed with raw api requests
+type groupHook struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+}
+
+func (c *Client) CreateOrgWebhook(group, u, secret string) error {
+	opts := &gitlab.AddProjectHookOptions{
+		URL:                 gitlab.String(u),
+		PushEvents:          gitlab.Bool(true),
+		TagPushEvents:       gitlab.Bool(true),
+		MergeRequestsEvents: gitlab.Bool(true),
+		Token:               gitlab.String(secret),
+	}
+	req, err := c.client.NewRequest("POST", fmt.Sprintf("groups/%s/hooks", pathEscape(group)), opts, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := c.client.Do(req, nil); err != nil {
+		return errors.Wrapf(err, "error creating group webhook")
+	}
+
+	return nil
+}
+
+func (c *Client) DeleteOrgWebhook(group, u string) error {
+	req, err := c.client.NewRequest("GET", fmt.Sprintf("groups/%s/hooks", pathEscape(group)), nil, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var hooks []*groupHook
+	if _, err := c.client.Do(req, &hooks); err != nil {
+		return errors.Wrapf(err, "error retrieving group webhooks")
+	}
+
+	for _, hook := range hooks {
+		if hook.URL == u {
+			req, err := c.client.NewRequest("DELETE", fmt.Sprintf("groups/%s/hooks/%d", pathEscape(group), hook.ID), nil, nil)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if _, err := c.client.Do(req, nil); err != nil {
+				return errors.Wrapf(err, "error deleting existing group webhook")
+			}
+		}
+	}
+
+	return nil
+}
+
+func pathEscape(s string) string {
+	return strings.Replace(url.PathEscape(s), ".", "%2E", -1)
+}
+
 func (c *Client) CreateCommitStatus(repopath, commitSHA string, status gitsource.CommitStatus, targetURL, description, context string) error {
 	_, _, err := c.client.Commits.SetCommitStatus(repopath, commitSHA, &gitlab.SetCommitStatusOptions{
 		State:       fromCommitStatus(status),
@@ -278,6 +367,57 @@ func (c *Client) CreateCommitStatus(repopath, commitSHA string, status gitsource
 	return errors.WithStack(err)
 }
 
+func (c *Client) CreateOrUpdatePullRequestComment(repopath, prID, marker, body string) error {
+	mrID, err := strconv.Atoi(prID)
+	if err != nil {
+		return errors.Wrapf(err, "wrong merge request id %q", prID)
+	}
+
+	opts := &gitlab.ListMergeRequestNotesOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	for {
+		notes, resp, err := c.client.Notes.ListMergeRequestNotes(repopath, mrID, opts)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		for _, note := range notes {
+			if strings.Contains(note.Body, marker) {
+				_, _, err := c.client.Notes.UpdateMergeRequestNote(repopath, mrID, note.ID, &gitlab.UpdateMergeRequestNoteOptions{Body: gitlab.String(body)})
+				return errors.WithStack(err)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	_, _, err = c.client.Notes.CreateMergeRequestNote(repopath, mrID, &gitlab.CreateMergeRequestNoteOptions{Body: gitlab.String(body)})
+	return errors.WithStack(err)
+}
+
+func (c *Client) CompareCommits(repopath, base, head string) ([]string, error) {
+	compare, _, err := c.client.Repositories.Compare(repopath, &gitlab.CompareOptions{From: gitlab.String(base), To: gitlab.String(head)})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// the diffs are incomplete
+	if compare.CompareTimeout {
+		return nil, nil
+	}
+
+	files := []string{}
+	for _, d := range compare.Diffs {
+		files = append(files, d.NewPath)
+		if d.RenamedFile {
+			files = append(files, d.OldPath)
+		}
+	}
+
+	return util.UniqueSortedStrings(files), nil
+}
+
 func (c *Client) ListUserRepos() ([]*gitsource.RepoInfo, error) {
 	// get only repos with permission greater or equal to maintainer
 	opts := &gitlab.ListProjectsOptions{MinAccessLevel: gitlab.AccessLevel(gitlab.MaintainerPermissions)}
@@ -302,6 +442,7 @@ func fromGitlabRepo(rr *gitlab.Project) *gitsource.RepoInfo {
 		HTMLURL:      rr.WebURL,
 		SSHCloneURL:  rr.SSHURLToRepo,
 		HTTPCloneURL: rr.HTTPURLToRepo,
+		Topics:       rr.TagList,
 	}
 }
 
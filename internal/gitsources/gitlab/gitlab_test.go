@@ -0,0 +1,129 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitlab
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gitsource "agola.io/agola/internal/gitsources"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRepoPath(t *testing.T) {
+	tests := []struct {
+		repoPath string
+		out      string
+		err      bool
+	}{
+		{repoPath: "group/repo", out: "group/repo"},
+		{repoPath: "group/subgroup01/subgroup02/repo", out: "group/subgroup01/subgroup02/repo"},
+		{repoPath: "/group/subgroup01/repo/", out: "group/subgroup01/repo"},
+		{repoPath: " group/subgroup01/repo.git ", out: "group/subgroup01/repo"},
+		{repoPath: "repo", err: true},
+		{repoPath: "repo.git", err: true},
+		{repoPath: "", err: true},
+		{repoPath: "group//repo", err: true},
+		{repoPath: "group/../repo", err: true},
+		{repoPath: "group/./repo", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repoPath, func(t *testing.T) {
+			out, err := parseRepoPath(tt.repoPath)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected err")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if out != tt.out {
+				t.Fatalf("expected repo path %q, got %q", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestGetRepoInfo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		// the project path must be sent escaped as a single path entry
+		if r.Method != "GET" || r.URL.EscapedPath() != "/api/v4/projects/group%2Fsubgroup01%2Fsubgroup02%2Frepo" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"404 Project Not Found"}`))
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":                  10,
+			"path_with_namespace": "group/subgroup01/subgroup02/repo",
+			"web_url":             "https://gitlab.example.com/group/subgroup01/subgroup02/repo",
+			"ssh_url_to_repo":     "git@gitlab.example.com:group/subgroup01/subgroup02/repo.git",
+			"http_url_to_repo":    "https://gitlab.example.com/group/subgroup01/subgroup02/repo.git",
+		}); err != nil {
+			t.Errorf("unexpected err: %v", err)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := New(Opts{APIURL: ts.URL, Token: "token01"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	expectedRepoInfo := &gitsource.RepoInfo{
+		ID:           "10",
+		Path:         "group/subgroup01/subgroup02/repo",
+		HTMLURL:      "https://gitlab.example.com/group/subgroup01/subgroup02/repo",
+		SSHCloneURL:  "git@gitlab.example.com:group/subgroup01/subgroup02/repo.git",
+		HTTPCloneURL: "https://gitlab.example.com/group/subgroup01/subgroup02/repo.git",
+	}
+
+	tests := []struct {
+		repoPath string
+		out      *gitsource.RepoInfo
+		err      bool
+	}{
+		{repoPath: "group/subgroup01/subgroup02/repo", out: expectedRepoInfo},
+		{repoPath: "/group/subgroup01/subgroup02/repo.git", out: expectedRepoInfo},
+		{repoPath: "group/subgroup01/repo", err: true},
+		{repoPath: "repo", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repoPath, func(t *testing.T) {
+			out, err := c.GetRepoInfo(tt.repoPath)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected err")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if diff := cmp.Diff(tt.out, out); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
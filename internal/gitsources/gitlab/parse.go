@@ -15,6 +15,7 @@
 package gitlab
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -25,6 +26,7 @@ import (
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/services/types"
+	"agola.io/agola/internal/util"
 )
 
 const (
@@ -46,7 +48,7 @@ func (c *Client) ParseWebhook(r *http.Request, secret string) (*types.WebhookDat
 	// secret)
 	if secret != "" {
 		token := r.Header.Get(tokenHeader)
-		if token != secret {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
 			return nil, errors.Errorf("wrong webhook token")
 		}
 	}
@@ -119,6 +121,11 @@ func webhookDataFromPush(hook *pushHook) (*types.WebhookData, error) {
 		if len(hook.Commits) > 0 {
 			whd.Message = hook.Commits[0].Message
 		}
+		whd.ChangedFiles = pushChangedFiles(hook)
+		// the before commit is all zeros for new branches
+		if strings.Trim(hook.Before, "0") != "" {
+			whd.CompareBase = hook.Before
+		}
 	case strings.HasPrefix(hook.Ref, "refs/tags/"):
 		whd.Event = types.WebhookEventTag
 		whd.Tag = strings.TrimPrefix(hook.Ref, "refs/tags/")
@@ -133,6 +140,23 @@ func webhookDataFromPush(hook *pushHook) (*types.WebhookData, error) {
 }
 
 // helper function that extracts the Build data from a Gitea pull_request hook
+// pushChangedFiles returns the files changed by the push commits or nil if
+// they aren't all reported in the webhook
+func pushChangedFiles(hook *pushHook) []string {
+	if hook.TotalCommitsCount > len(hook.Commits) {
+		return nil
+	}
+
+	files := []string{}
+	for _, c := range hook.Commits {
+		files = append(files, c.Added...)
+		files = append(files, c.Removed...)
+		files = append(files, c.Modified...)
+	}
+
+	return util.UniqueSortedStrings(files)
+}
+
 func webhookDataFromPullRequest(hook *pullRequestHook) *types.WebhookData {
 	// TODO(sgotti) Use PR opener username or last commit user name?
 	sender := hook.User.Name
@@ -155,6 +179,7 @@ func webhookDataFromPullRequest(hook *pullRequestHook) *types.WebhookData {
 		PullRequestID:   strconv.Itoa(hook.ObjectAttributes.Iid),
 		PullRequestLink: hook.ObjectAttributes.URL,
 		PRFromSameRepo:  prFromSameRepo,
+		CompareBase:     hook.ObjectAttributes.TargetBranch,
 
 		Repo: types.WebhookDataRepo{
 			Path:   hook.Project.PathWithNamespace,
@@ -0,0 +1,68 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitlab
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseWebhookToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		err   bool
+	}{
+		{
+			name:  "test right token",
+			token: "secret01",
+		},
+		{
+			name:  "test wrong token",
+			token: "secret02",
+			err:   true,
+		},
+		{
+			name:  "test token prefix",
+			token: "secret0",
+			err:   true,
+		},
+		{
+			name: "test missing token",
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a push without commits is verified and then skipped
+			r := httptest.NewRequest("POST", "/webhooks", strings.NewReader(`{"commits": []}`))
+			r.Header.Set(hookEvent, hookPush)
+			if tt.token != "" {
+				r.Header.Set(tokenHeader, tt.token)
+			}
+
+			c := &Client{}
+			_, err := c.ParseWebhook(r, "secret01")
+			if tt.err && err == nil {
+				t.Fatalf("expected error")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+}
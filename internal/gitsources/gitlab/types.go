@@ -53,6 +53,8 @@ type pushHook struct {
 			Name  string `json:"name"`
 			Email string `json:"email"`
 		} `json:"author"`
+		Added    []string `json:"added"`
+		Removed  []string `json:"removed"`
 		Modified []string `json:"modified"`
 	} `json:"commits"`
 	TotalCommitsCount int `json:"total_commits_count"`
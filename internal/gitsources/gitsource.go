@@ -26,10 +26,14 @@ import (
 type CommitStatus string
 
 const (
+	// CommitStatusQueued reports a run created but not yet started
+	CommitStatusQueued  CommitStatus = "queued"
 	CommitStatusPending CommitStatus = "pending"
 	CommitStatusSuccess CommitStatus = "success"
 	CommitStatusError   CommitStatus = "error"
 	CommitStatusFailed  CommitStatus = "failed"
+	// CommitStatusSkipped reports a commit intentionally skipped by agola
+	CommitStatusSkipped CommitStatus = "skipped"
 )
 
 var ErrUnauthorized = errors.New("unauthorized")
@@ -61,6 +65,37 @@ type GitSource interface {
 	PullRequestLink(repoInfo *RepoInfo, prID string) string
 }
 
+// OrgWebhookSource is implemented by git sources that can register a single
+// organization level webhook receiving the events of all the organization
+// repositories
+type OrgWebhookSource interface {
+	DeleteOrgWebhook(org, url string) error
+	CreateOrgWebhook(org, url, secret string) error
+}
+
+// CheckRunSource is implemented by git sources that can report the run
+// results using check runs instead of plain commit statuses
+type CheckRunSource interface {
+	// CreateOrUpdateCheckRun creates a check run or updates the existing one
+	// with the same name and external id
+	CreateOrUpdateCheckRun(repopath, commitSHA string, checkRun *CheckRun) error
+}
+
+// PullRequestCommentSource is implemented by git sources that can comment on
+// pull requests
+type PullRequestCommentSource interface {
+	// CreateOrUpdatePullRequestComment updates the pull request comment
+	// containing marker or creates a new one if it doesn't exist
+	CreateOrUpdatePullRequestComment(repopath, prID, marker, body string) error
+}
+
+type CompareSource interface {
+	// CompareCommits returns the files changed between the base commit (or
+	// its merge base with head) and the head commit. It returns nil files
+	// when the git source doesn't report all the changed files
+	CompareCommits(repopath, base, head string) ([]string, error)
+}
+
 type UserSource interface {
 	GetUserInfo() (*UserInfo, error)
 }
@@ -87,12 +122,17 @@ type RepoInfo struct {
 	HTMLURL      string
 	SSHCloneURL  string
 	HTTPCloneURL string
+	// Topics are the repository topics (or tags for git sources calling them
+	// in this way). Not all the git sources provide them.
+	Topics []string
 }
 
 type UserInfo struct {
-	ID        string
-	LoginName string
-	Email     string
+	ID          string
+	LoginName   string
+	Email       string
+	DisplayName string
+	AvatarURL   string
 }
 
 type RefType int
@@ -112,3 +152,33 @@ type Commit struct {
 	SHA     string
 	Message string
 }
+
+type CheckRunAnnotationLevel string
+
+const (
+	CheckRunAnnotationLevelNotice  CheckRunAnnotationLevel = "notice"
+	CheckRunAnnotationLevelWarning CheckRunAnnotationLevel = "warning"
+	CheckRunAnnotationLevelFailure CheckRunAnnotationLevel = "failure"
+)
+
+type CheckRun struct {
+	Name       string
+	ExternalID string
+	// Status is the check run status, a pending status means that the check is
+	// in progress while the other statuses mean that it's completed
+	Status     CommitStatus
+	DetailsURL string
+
+	Title   string
+	Summary string
+	Text    string
+
+	Annotations []*CheckRunAnnotation
+}
+
+type CheckRunAnnotation struct {
+	Path    string
+	Level   CheckRunAnnotationLevel
+	Title   string
+	Message string
+}
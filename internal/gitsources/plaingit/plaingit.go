@@ -0,0 +1,337 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plaingit implements a git source for plain git servers (cgit, bare
+// ssh servers etc...) that don't provide any api. All the operations are done
+// using the git command and, since webhooks cannot be installed, new refs must
+// be detected by periodically polling the remote repository.
+package plaingit
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+	gitsource "agola.io/agola/internal/gitsources"
+	"agola.io/agola/internal/services/types"
+	"agola.io/agola/internal/util"
+)
+
+var (
+	branchRefPrefix = "refs/heads/"
+	tagRefPrefix    = "refs/tags/"
+
+	peeledRefSuffix = "^{}"
+)
+
+type Opts struct {
+	// URL is the base git url, the repository path will be appended to it.
+	// I.e. ssh://git@example.com:2222 or git@example.com:
+	URL                 string
+	SSHPrivateKey       string
+	SSHHostKey          string
+	SkipSSHHostKeyCheck bool
+	// UserName is the linked account user name. Since there isn't any user
+	// api it's just the login name provided when creating the linked account.
+	UserName string
+}
+
+type Client struct {
+	url                 string
+	sshPrivateKey       string
+	sshHostKey          string
+	skipSSHHostKeyCheck bool
+	userName            string
+}
+
+func New(opts Opts) (*Client, error) {
+	if opts.URL == "" {
+		return nil, errors.Errorf("empty git url")
+	}
+
+	return &Client{
+		url:                 opts.URL,
+		sshPrivateKey:       opts.SSHPrivateKey,
+		sshHostKey:          opts.SSHHostKey,
+		skipSSHHostKeyCheck: opts.SkipSSHHostKeyCheck,
+		userName:            opts.UserName,
+	}, nil
+}
+
+// RepoURL returns the git url of the repository at repopath
+func (c *Client) RepoURL(repopath string) string {
+	// scp like url (git@example.com:)
+	if strings.HasSuffix(c.url, ":") {
+		return c.url + repopath
+	}
+	return strings.TrimSuffix(c.url, "/") + "/" + strings.TrimPrefix(repopath, "/")
+}
+
+// withGit creates a temporary dir containing the ssh private key and known
+// hosts files and calls f with a git command configured to use them.
+func (c *Client) withGit(f func(git *util.Git, tmpDir string) error) error {
+	tmpDir, err := ioutil.TempDir("", "agola-plaingit")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	sshCommand := []string{"ssh", "-o", "BatchMode=yes"}
+	if c.sshPrivateKey != "" {
+		keyPath := filepath.Join(tmpDir, "id")
+		if err := ioutil.WriteFile(keyPath, []byte(c.sshPrivateKey), 0600); err != nil {
+			return errors.WithStack(err)
+		}
+		sshCommand = append(sshCommand, "-i", keyPath, "-o", "IdentitiesOnly=yes")
+	}
+	switch {
+	case c.skipSSHHostKeyCheck:
+		sshCommand = append(sshCommand, "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null")
+	case c.sshHostKey != "":
+		knownHostsPath := filepath.Join(tmpDir, "known_hosts")
+		if err := ioutil.WriteFile(knownHostsPath, []byte(c.sshHostKey+"\n"), 0600); err != nil {
+			return errors.WithStack(err)
+		}
+		sshCommand = append(sshCommand, "-o", "StrictHostKeyChecking=yes", "-o", "UserKnownHostsFile="+knownHostsPath)
+	}
+
+	git := &util.Git{Env: []string{"GIT_SSH_COMMAND=" + strings.Join(sshCommand, " "), "GIT_TERMINAL_PROMPT=0"}}
+
+	return f(git, tmpDir)
+}
+
+// ListRefs returns all the branches and tags refs of the remote repository.
+// For annotated tags the returned commit sha is the one of the tagged commit.
+func (c *Client) ListRefs(repopath string) ([]*gitsource.Ref, error) {
+	var lines []string
+	err := c.withGit(func(git *util.Git, tmpDir string) error {
+		var err error
+		lines, err = git.OutputLines(context.Background(), nil, "ls-remote", "--heads", "--tags", c.RepoURL(repopath))
+		return errors.WithStack(err)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list remote refs")
+	}
+
+	return parseLsRemote(lines)
+}
+
+func parseLsRemote(lines []string) ([]*gitsource.Ref, error) {
+	refs := []*gitsource.Ref{}
+	refsMap := map[string]*gitsource.Ref{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, errors.Errorf("wrong ls-remote line %q", line)
+		}
+		sha, name := fields[0], fields[1]
+
+		// use the peeled ref commit for annotated tags
+		if strings.HasSuffix(name, peeledRefSuffix) {
+			name = strings.TrimSuffix(name, peeledRefSuffix)
+			if ref, ok := refsMap[name]; ok {
+				ref.CommitSHA = sha
+				continue
+			}
+		}
+
+		ref := &gitsource.Ref{Ref: name, CommitSHA: sha}
+		refsMap[name] = ref
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+// fetchCommit fetches the provided commit in a new bare repository and calls f
+// with a git command using it.
+func (c *Client) fetchCommit(repopath, commitSHA string, f func(git *util.Git) error) error {
+	return c.withGit(func(git *util.Git, tmpDir string) error {
+		ctx := context.Background()
+		git.GitDir = filepath.Join(tmpDir, "repo.git")
+
+		if _, err := git.Output(ctx, nil, "init", "--bare", "--quiet", git.GitDir); err != nil {
+			return errors.WithStack(err)
+		}
+		// not all servers permit fetching a commit not pointed by a ref, in
+		// such case fallback to fetching all the branches and tags
+		if _, err := git.Output(ctx, nil, "fetch", "--quiet", "--depth", "1", c.RepoURL(repopath), commitSHA); err != nil {
+			if _, err := git.Output(ctx, nil, "fetch", "--quiet", c.RepoURL(repopath), "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"); err != nil {
+				return errors.Wrapf(err, "failed to fetch repository")
+			}
+		}
+
+		return f(git)
+	})
+}
+
+func (c *Client) GetUserInfo() (*gitsource.UserInfo, error) {
+	if c.userName == "" {
+		return nil, errors.Errorf("empty user name")
+	}
+	return &gitsource.UserInfo{
+		ID:        c.userName,
+		LoginName: c.userName,
+	}, nil
+}
+
+// LoginPassword just returns the provided username since there's no way to
+// authenticate it. The returned "token" is only used to identify the linked
+// account.
+func (c *Client) LoginPassword(username, password, tokenName string) (string, error) {
+	if username == "" {
+		return "", errors.Errorf("empty user name")
+	}
+	return username, nil
+}
+
+func (c *Client) GetRepoInfo(repopath string) (*gitsource.RepoInfo, error) {
+	repoURL := c.RepoURL(repopath)
+
+	repoInfo := &gitsource.RepoInfo{
+		ID:          repopath,
+		Path:        repopath,
+		SSHCloneURL: repoURL,
+	}
+	if strings.HasPrefix(repoURL, "http://") || strings.HasPrefix(repoURL, "https://") {
+		repoInfo.HTTPCloneURL = repoURL
+	}
+
+	return repoInfo, nil
+}
+
+func (c *Client) GetFile(repopath, commit, file string) ([]byte, error) {
+	var data []byte
+	err := c.fetchCommit(repopath, commit, func(git *util.Git) error {
+		var err error
+		data, err = git.Output(context.Background(), nil, "show", fmt.Sprintf("%s:%s", commit, file))
+		return errors.WithStack(err)
+	})
+
+	return data, errors.WithStack(err)
+}
+
+func (c *Client) CreateDeployKey(repopath, title, pubKey string, readonly bool) error {
+	return nil
+}
+
+func (c *Client) DeleteDeployKey(repopath, title string) error {
+	return nil
+}
+
+func (c *Client) UpdateDeployKey(repopath, title, pubKey string, readonly bool) error {
+	return nil
+}
+
+func (c *Client) CreateRepoWebhook(repopath, url, secret string) error {
+	return nil
+}
+
+func (c *Client) ParseWebhook(r *http.Request, secret string) (*types.WebhookData, error) {
+	return nil, errors.Errorf("webhooks aren't supported by plain git sources")
+}
+
+func (c *Client) DeleteRepoWebhook(repopath, u string) error {
+	return nil
+}
+
+func (c *Client) CreateCommitStatus(repopath, commitSHA string, status gitsource.CommitStatus, targetURL, description, context string) error {
+	return nil
+}
+
+func (c *Client) ListUserRepos() ([]*gitsource.RepoInfo, error) {
+	return []*gitsource.RepoInfo{}, nil
+}
+
+func (c *Client) GetRef(repopath, ref string) (*gitsource.Ref, error) {
+	refs, err := c.ListRefs(repopath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, r := range refs {
+		if r.Ref == ref {
+			return r, nil
+		}
+	}
+
+	return nil, errors.Errorf("ref %q doesn't exist", ref)
+}
+
+func (c *Client) RefType(ref string) (gitsource.RefType, string, error) {
+	switch {
+	case strings.HasPrefix(ref, branchRefPrefix):
+		return gitsource.RefTypeBranch, strings.TrimPrefix(ref, branchRefPrefix), nil
+
+	case strings.HasPrefix(ref, tagRefPrefix):
+		return gitsource.RefTypeTag, strings.TrimPrefix(ref, tagRefPrefix), nil
+
+	default:
+		return -1, "", errors.Errorf("unsupported ref: %s", ref)
+	}
+}
+
+func (c *Client) GetCommit(repopath, commitSHA string) (*gitsource.Commit, error) {
+	var commit *gitsource.Commit
+	err := c.fetchCommit(repopath, commitSHA, func(git *util.Git) error {
+		// sha and message are separated by a NUL
+		out, err := git.Output(context.Background(), nil, "log", "-1", "--format=%H%x00%B", commitSHA)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		parts := strings.SplitN(string(out), "\x00", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("wrong git log output")
+		}
+		commit = &gitsource.Commit{
+			SHA:     parts[0],
+			Message: strings.TrimSpace(parts[1]),
+		}
+		return nil
+	})
+
+	return commit, errors.WithStack(err)
+}
+
+func (c *Client) BranchRef(branch string) string {
+	return branchRefPrefix + branch
+}
+
+func (c *Client) TagRef(tag string) string {
+	return tagRefPrefix + tag
+}
+
+func (c *Client) PullRequestRef(prID string) string {
+	return ""
+}
+
+func (c *Client) CommitLink(repoInfo *gitsource.RepoInfo, commitSHA string) string {
+	return ""
+}
+
+func (c *Client) BranchLink(repoInfo *gitsource.RepoInfo, branch string) string {
+	return ""
+}
+
+func (c *Client) TagLink(repoInfo *gitsource.RepoInfo, tag string) string {
+	return ""
+}
+
+func (c *Client) PullRequestLink(repoInfo *gitsource.RepoInfo, prID string) string {
+	return ""
+}
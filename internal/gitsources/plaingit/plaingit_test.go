@@ -0,0 +1,342 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plaingit
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gitsource "agola.io/agola/internal/gitsources"
+	"agola.io/agola/internal/util"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// initRepo creates a git repository at dir with a commit for every provided
+// message on the master branch and returns the commits shas. Every commit
+// writes its message to the README file.
+func initRepo(t *testing.T, ctx context.Context, dir string, messages ...string) (*util.Git, []string) {
+	workDir := t.TempDir()
+	git := &util.Git{GitDir: dir, Env: []string{"GIT_WORK_TREE=" + workDir}}
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"config", "--unset", "core.bare"},
+		{"config", "user.email", "user01@example.com"},
+		{"config", "user.name", "user01"},
+		{"symbolic-ref", "HEAD", "refs/heads/master"},
+	} {
+		if _, err := git.Output(ctx, nil, args...); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+
+	shas := []string{}
+	for _, message := range messages {
+		if err := ioutil.WriteFile(filepath.Join(workDir, "README"), []byte(message), 0644); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if _, err := git.Output(ctx, nil, "add", "README"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if _, err := git.Output(ctx, nil, "commit", "--quiet", "-m", message); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		out, err := git.Output(ctx, nil, "rev-parse", "HEAD")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		shas = append(shas, strings.TrimSpace(string(out)))
+	}
+
+	return git, shas
+}
+
+func TestRepoURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		repoPath string
+		out      string
+	}{
+		{url: "ssh://git@example.com:2222", repoPath: "org/repo.git", out: "ssh://git@example.com:2222/org/repo.git"},
+		{url: "ssh://git@example.com:2222/", repoPath: "/org/repo.git", out: "ssh://git@example.com:2222/org/repo.git"},
+		{url: "git@example.com:", repoPath: "org/repo.git", out: "git@example.com:org/repo.git"},
+		{url: "https://example.com/git", repoPath: "repo.git", out: "https://example.com/git/repo.git"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			c, err := New(Opts{URL: tt.url})
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if out := c.RepoURL(tt.repoPath); out != tt.out {
+				t.Fatalf("expected url %q, got %q", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestWithGit(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Opts
+		// env is the expected git env, $TMPDIR is replaced with the temporary
+		// dir
+		env        []string
+		knownHosts string
+	}{
+		{
+			name: "no key",
+			opts: Opts{URL: "git@example.com:"},
+			env:  []string{"GIT_SSH_COMMAND=ssh -o BatchMode=yes", "GIT_TERMINAL_PROMPT=0"},
+		},
+		{
+			name: "private key",
+			opts: Opts{URL: "git@example.com:", SSHPrivateKey: "privatekey"},
+			env:  []string{"GIT_SSH_COMMAND=ssh -o BatchMode=yes -i $TMPDIR/id -o IdentitiesOnly=yes", "GIT_TERMINAL_PROMPT=0"},
+		},
+		{
+			name:       "host key",
+			opts:       Opts{URL: "git@example.com:", SSHPrivateKey: "privatekey", SSHHostKey: "example.com ssh-ed25519 AAAA"},
+			env:        []string{"GIT_SSH_COMMAND=ssh -o BatchMode=yes -i $TMPDIR/id -o IdentitiesOnly=yes -o StrictHostKeyChecking=yes -o UserKnownHostsFile=$TMPDIR/known_hosts", "GIT_TERMINAL_PROMPT=0"},
+			knownHosts: "example.com ssh-ed25519 AAAA\n",
+		},
+		{
+			name: "skip host key check",
+			opts: Opts{URL: "git@example.com:", SSHHostKey: "example.com ssh-ed25519 AAAA", SkipSSHHostKeyCheck: true},
+			env:  []string{"GIT_SSH_COMMAND=ssh -o BatchMode=yes -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null", "GIT_TERMINAL_PROMPT=0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			err = c.withGit(func(git *util.Git, tmpDir string) error {
+				env := []string{}
+				for _, e := range tt.env {
+					env = append(env, strings.ReplaceAll(e, "$TMPDIR", tmpDir))
+				}
+				if diff := cmp.Diff(env, git.Env); diff != "" {
+					t.Errorf("env mismatch (-want +got):\n%s", diff)
+				}
+
+				if tt.opts.SSHPrivateKey != "" {
+					key, err := ioutil.ReadFile(filepath.Join(tmpDir, "id"))
+					if err != nil {
+						t.Fatalf("unexpected err: %v", err)
+					}
+					if string(key) != tt.opts.SSHPrivateKey {
+						t.Errorf("expected private key %q, got %q", tt.opts.SSHPrivateKey, key)
+					}
+				}
+				if tt.knownHosts != "" {
+					knownHosts, err := ioutil.ReadFile(filepath.Join(tmpDir, "known_hosts"))
+					if err != nil {
+						t.Fatalf("unexpected err: %v", err)
+					}
+					if string(knownHosts) != tt.knownHosts {
+						t.Errorf("expected known hosts %q, got %q", tt.knownHosts, knownHosts)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseLsRemote(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		out   []*gitsource.Ref
+		err   bool
+	}{
+		{
+			name:  "no refs",
+			lines: []string{},
+			out:   []*gitsource.Ref{},
+		},
+		{
+			name: "branches and lightweight tags",
+			lines: []string{
+				"1111111111111111111111111111111111111111\trefs/heads/master",
+				"2222222222222222222222222222222222222222\trefs/heads/feature",
+				"1111111111111111111111111111111111111111\trefs/tags/v1.0",
+			},
+			out: []*gitsource.Ref{
+				{Ref: "refs/heads/master", CommitSHA: "1111111111111111111111111111111111111111"},
+				{Ref: "refs/heads/feature", CommitSHA: "2222222222222222222222222222222222222222"},
+				{Ref: "refs/tags/v1.0", CommitSHA: "1111111111111111111111111111111111111111"},
+			},
+		},
+		{
+			name: "annotated tag uses the peeled commit",
+			lines: []string{
+				"1111111111111111111111111111111111111111\trefs/heads/master",
+				"3333333333333333333333333333333333333333\trefs/tags/v1.0",
+				"1111111111111111111111111111111111111111\trefs/tags/v1.0^{}",
+			},
+			out: []*gitsource.Ref{
+				{Ref: "refs/heads/master", CommitSHA: "1111111111111111111111111111111111111111"},
+				{Ref: "refs/tags/v1.0", CommitSHA: "1111111111111111111111111111111111111111"},
+			},
+		},
+		{
+			name:  "wrong line",
+			lines: []string{"1111111111111111111111111111111111111111"},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := parseLsRemote(tt.lines)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if diff := cmp.Diff(tt.out, out); diff != "" {
+				t.Fatalf("refs mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRefType(t *testing.T) {
+	c, err := New(Opts{URL: "git@example.com:"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		ref     string
+		refType gitsource.RefType
+		name    string
+		err     bool
+	}{
+		{ref: "refs/heads/master", refType: gitsource.RefTypeBranch, name: "master"},
+		{ref: "refs/heads/feature/one", refType: gitsource.RefTypeBranch, name: "feature/one"},
+		{ref: "refs/tags/v1.0", refType: gitsource.RefTypeTag, name: "v1.0"},
+		{ref: "refs/pull/1/head", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			refType, name, err := c.RefType(tt.ref)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if refType != tt.refType || name != tt.name {
+				t.Fatalf("expected ref type %d name %q, got ref type %d name %q", tt.refType, tt.name, refType, name)
+			}
+		})
+	}
+}
+
+func TestRemoteRepository(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	git, shas := initRepo(t, ctx, filepath.Join(dir, "repo01"), "first commit", "second commit\n\nbody")
+	if _, err := git.Output(ctx, nil, "tag", "-a", "v1.0", "-m", "tag v1.0", shas[0]); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	c, err := New(Opts{URL: "file://" + dir})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	t.Run("list refs", func(t *testing.T) {
+		refs, err := c.ListRefs("repo01")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		expectedRefs := []*gitsource.Ref{
+			{Ref: "refs/heads/master", CommitSHA: shas[1]},
+			{Ref: "refs/tags/v1.0", CommitSHA: shas[0]},
+		}
+		if diff := cmp.Diff(expectedRefs, refs); diff != "" {
+			t.Fatalf("refs mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("get ref", func(t *testing.T) {
+		ref, err := c.GetRef("repo01", "refs/tags/v1.0")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if ref.CommitSHA != shas[0] {
+			t.Fatalf("expected commit sha %q, got %q", shas[0], ref.CommitSHA)
+		}
+
+		if _, err := c.GetRef("repo01", "refs/heads/notexistent"); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	t.Run("get commit", func(t *testing.T) {
+		commit, err := c.GetCommit("repo01", shas[1])
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		expectedCommit := &gitsource.Commit{SHA: shas[1], Message: "second commit\n\nbody"}
+		if diff := cmp.Diff(expectedCommit, commit); diff != "" {
+			t.Fatalf("commit mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("get file", func(t *testing.T) {
+		data, err := c.GetFile("repo01", shas[0], "README")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if string(data) != "first commit" {
+			t.Fatalf("expected file content %q, got %q", "first commit", data)
+		}
+
+		if _, err := c.GetFile("repo01", shas[1], "notexistent"); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	t.Run("list refs of not existent repository", func(t *testing.T) {
+		if _, err := c.ListRefs("repo02"); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+}
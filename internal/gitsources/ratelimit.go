@@ -0,0 +1,274 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitsource
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/util"
+)
+
+const (
+	// MaxConcurrentRequests is the max number of concurrent requests to the
+	// same git source api
+	MaxConcurrentRequests = 10
+
+	// rateLimitMaxRetries is the max number of retries of a rate limited
+	// request
+	rateLimitMaxRetries = 3
+	// rateLimitMaxWait is the max time to wait for a rate limit reset. If the
+	// reset happens later the request fails
+	rateLimitMaxWait = 1 * time.Minute
+	// rateLimitDefaultWait is the base wait time when the git source doesn't
+	// report when the rate limit will be reset
+	rateLimitDefaultWait = 5 * time.Second
+)
+
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitStatus is the rate limit status of a git source api as reported by
+// its last response.
+type RateLimitStatus struct {
+	// Limit is the max number of requests in the rate limit window. 0 if the
+	// git source doesn't report it
+	Limit int
+	// Remaining is the number of requests remaining in the rate limit window.
+	// -1 if the git source doesn't report it
+	Remaining int
+	// Reset is the time when the rate limit window is reset
+	Reset time.Time
+	// ActiveRequests is the number of in flight requests
+	ActiveRequests int
+	// RateLimitedRequests is the total number of rate limited responses
+	RateLimitedRequests int64
+}
+
+type rateLimiter struct {
+	sem chan struct{}
+
+	mu     sync.Mutex
+	status RateLimitStatus
+}
+
+var (
+	rateLimitersMu sync.Mutex
+	rateLimiters   = map[string]*rateLimiter{}
+)
+
+func getRateLimiter(apiURL string) *rateLimiter {
+	rateLimitersMu.Lock()
+	defer rateLimitersMu.Unlock()
+
+	rl, ok := rateLimiters[apiURL]
+	if !ok {
+		rl = &rateLimiter{
+			sem:    make(chan struct{}, MaxConcurrentRequests),
+			status: RateLimitStatus{Remaining: -1},
+		}
+		rateLimiters[apiURL] = rl
+	}
+
+	return rl
+}
+
+// GetRateLimitStatus returns the rate limit status of the git source with the
+// provided api url. The status is kept in memory, so it's related only to the
+// requests done by the current process.
+func GetRateLimitStatus(apiURL string) RateLimitStatus {
+	rl := getRateLimiter(apiURL)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.status
+}
+
+// RateLimitTransport is an http.RoundTripper that limits the concurrent
+// requests to a git source api, keeps track of its rate limits and retries the
+// rate limited requests.
+// All the transports created for the same api url share the same limits.
+type RateLimitTransport struct {
+	rt http.RoundTripper
+	rl *rateLimiter
+}
+
+func NewRateLimitTransport(apiURL string, rt http.RoundTripper) *RateLimitTransport {
+	return &RateLimitTransport{rt: rt, rl: getRateLimiter(apiURL)}
+}
+
+func (t *RateLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+
+	select {
+	case t.rl.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, errors.WithStack(ctx.Err())
+	}
+	t.rl.addActiveRequests(1)
+	defer func() {
+		t.rl.addActiveRequests(-1)
+		<-t.rl.sem
+	}()
+
+	for attempt := 0; ; attempt++ {
+		if err := t.rl.waitReset(ctx); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		req := r
+		if attempt > 0 {
+			// requests with a body can be retried only if it can be recreated
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			req = new(http.Request)
+			*req = *r
+			req.Body = body
+		}
+
+		resp, err := t.rt.RoundTrip(req)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		t.rl.update(resp.Header)
+
+		if !isRateLimited(resp) {
+			return resp, nil
+		}
+		t.rl.addRateLimitedRequest()
+
+		if attempt >= rateLimitMaxRetries || (r.Body != nil && r.GetBody == nil) {
+			return resp, nil
+		}
+		wait := t.rl.retryWait(resp.Header, attempt)
+		if wait > rateLimitMaxWait {
+			return resp, nil
+		}
+
+		// drain the body to reuse the connection
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		if err := sleep(ctx, util.Jitter(wait, 0.1)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+}
+
+func isRateLimited(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	// github reports primary rate limits using 403 with no remaining requests
+	if resp.StatusCode == http.StatusForbidden && rateLimitHeader(resp.Header, "Remaining") == "0" {
+		return true
+	}
+	return false
+}
+
+// rateLimitHeader returns the value of the rate limit header with the provided
+// name. Github and gitea use the X-RateLimit prefix, gitlab the RateLimit
+// prefix.
+func rateLimitHeader(h http.Header, name string) string {
+	if v := h.Get("X-RateLimit-" + name); v != "" {
+		return v
+	}
+	return h.Get("RateLimit-" + name)
+}
+
+func (rl *rateLimiter) addActiveRequests(n int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.status.ActiveRequests += n
+}
+
+func (rl *rateLimiter) addRateLimitedRequest() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.status.RateLimitedRequests++
+}
+
+func (rl *rateLimiter) update(h http.Header) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if v, err := strconv.Atoi(rateLimitHeader(h, "Limit")); err == nil {
+		rl.status.Limit = v
+	}
+	if v, err := strconv.Atoi(rateLimitHeader(h, "Remaining")); err == nil {
+		rl.status.Remaining = v
+	}
+	if v, err := strconv.ParseInt(rateLimitHeader(h, "Reset"), 10, 64); err == nil {
+		rl.status.Reset = time.Unix(v, 0)
+	}
+}
+
+// waitReset waits for the rate limit reset when there're no remaining
+// requests.
+func (rl *rateLimiter) waitReset(ctx context.Context) error {
+	rl.mu.Lock()
+	remaining := rl.status.Remaining
+	reset := rl.status.Reset
+	rl.mu.Unlock()
+
+	if remaining != 0 {
+		return nil
+	}
+	wait := time.Until(reset)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > rateLimitMaxWait {
+		return errors.Wrapf(ErrRateLimited, "rate limit will be reset at %s", reset)
+	}
+
+	return sleep(ctx, util.Jitter(wait, 0.1))
+}
+
+// retryWait returns the time to wait before retrying a rate limited request
+func (rl *rateLimiter) retryWait(h http.Header, attempt int) time.Duration {
+	if v, err := strconv.Atoi(h.Get("Retry-After")); err == nil {
+		return time.Duration(v) * time.Second
+	}
+
+	rl.mu.Lock()
+	reset := rl.status.Reset
+	rl.mu.Unlock()
+	if wait := time.Until(reset); wait > 0 {
+		return wait
+	}
+
+	return rateLimitDefaultWait << uint(attempt)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
@@ -15,8 +15,10 @@ import (
 	oldcstypes "agola.io/agola/internal/migration/configstore/types"
 	ndb "agola.io/agola/internal/services/configstore/db"
 	"agola.io/agola/internal/sql"
+	"agola.io/agola/internal/util"
 	"agola.io/agola/services/configstore/types"
 
+	"github.com/gofrs/uuid"
 	"github.com/mitchellh/mapstructure"
 	"github.com/rs/zerolog/log"
 )
@@ -215,6 +217,14 @@ func MigrateConfigStore(ctx context.Context, r io.Reader, w io.Writer) error {
 			project.WebhookSecret = oldProject.WebhookSecret
 			project.PassVarsToForkedPR = oldProject.PassVarsToForkedPR
 
+			// generate the WebhookSecret for projects created before it was
+			// introduced since webhooks without a signature are rejected. The
+			// project must be reconfigured to update the remote webhook
+			if project.WebhookSecret == "" {
+				log.Warn().Msgf("project %s doesn't have a webhook secret, generating a new one. The project must be reconfigured to update the repository webhook", project.ID)
+				project.WebhookSecret = util.EncodeSha1Hex(uuid.Must(uuid.NewV4()).String())
+			}
+
 			if err := newd.InsertProject(newTx, project); err != nil {
 				return errors.WithStack(err)
 			}
@@ -0,0 +1,72 @@
+package migration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	oldcstypes "agola.io/agola/internal/migration/configstore/types"
+	"agola.io/agola/services/configstore/types"
+)
+
+func TestMigrateConfigStoreProjectWebhookSecret(t *testing.T) {
+	oldProjects := []*oldcstypes.Project{
+		{
+			ID:            "6a2a1b1e-0b7c-4b8e-9a43-0c7cbd6f3d01",
+			Name:          "project01",
+			Parent:        oldcstypes.Parent{Type: oldcstypes.ConfigTypeProjectGroup, ID: "6a2a1b1e-0b7c-4b8e-9a43-0c7cbd6f3d00"},
+			WebhookSecret: "secret01",
+		},
+		{
+			ID:     "6a2a1b1e-0b7c-4b8e-9a43-0c7cbd6f3d02",
+			Name:   "project02",
+			Parent: oldcstypes.Parent{Type: oldcstypes.ConfigTypeProjectGroup, ID: "6a2a1b1e-0b7c-4b8e-9a43-0c7cbd6f3d00"},
+		},
+	}
+
+	var in bytes.Buffer
+	enc := json.NewEncoder(&in)
+	for _, p := range oldProjects {
+		data, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if err := enc.Encode(&DataEntry{ID: p.ID, DataType: "project", Data: data}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := MigrateConfigStore(context.Background(), &in, &out); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	projects := map[string]*types.Project{}
+	scanner := bufio.NewScanner(&out)
+	scanner.Buffer(nil, 10*1024*1024)
+	for scanner.Scan() {
+		var project *types.Project
+		if err := json.Unmarshal(scanner.Bytes(), &project); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if project.Kind != types.ProjectKind {
+			continue
+		}
+		projects[project.Name] = project
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if len(projects) != 2 {
+		t.Fatalf("expected 2 projects, got %d", len(projects))
+	}
+	if secret := projects["project01"].WebhookSecret; secret != "secret01" {
+		t.Fatalf("expected project01 webhook secret %q, got %q", "secret01", secret)
+	}
+	if secret := projects["project02"].WebhookSecret; secret == "" {
+		t.Fatalf("expected project02 webhook secret to be generated")
+	}
+}
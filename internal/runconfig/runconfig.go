@@ -17,6 +17,7 @@ package runconfig
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"agola.io/agola/internal/config"
 	"agola.io/agola/internal/errors"
@@ -28,53 +29,147 @@ import (
 
 const (
 	defaultShell = "/bin/sh -e"
+	// defaultWindowsShell is the default shell of tasks targeting windows
+	// executors
+	defaultWindowsShell = "powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -File"
+
+	// dockerLayerCacheDirEnv is the env var containing the task docker layer
+	// cache dir
+	dockerLayerCacheDirEnv = "AGOLA_DOCKER_LAYER_CACHE_DIR"
 )
 
 func genRuntime(c *config.Config, ce *config.Runtime, variables map[string]string) *rstypes.Runtime {
 	containers := []*rstypes.Container{}
 	for _, cc := range ce.Containers {
-		env := genEnv(cc.Environment, variables)
-		container := &rstypes.Container{
-			Image:       cc.Image,
-			Environment: env,
-			User:        cc.User,
-			Privileged:  cc.Privileged,
-			Entrypoint:  cc.Entrypoint,
-			Volumes:     make([]rstypes.Volume, len(cc.Volumes)),
-		}
-
-		for i, ccVol := range cc.Volumes {
-			container.Volumes[i] = rstypes.Volume{
-				Path: ccVol.Path,
-			}
-
-			if ccVol.TmpFS != nil {
-				var size int64
-				if ccVol.TmpFS.Size != nil {
-					size = ccVol.TmpFS.Size.Value()
-				}
-				container.Volumes[i].TmpFS = &rstypes.VolumeTmpFS{
-					Size: size,
-				}
+		containers = append(containers, genContainer(cc, variables))
+	}
+	// services are started after the task containers
+	for _, cs := range ce.Services {
+		container := genContainer(&cs.Container, variables)
+		if cs.Readiness != nil {
+			container.Readiness = &rstypes.ContainerReadiness{
+				TCPPort: cs.Readiness.TCPPort,
+				Command: cs.Readiness.Command,
+				Timeout: time.Duration(cs.Readiness.TimeoutSeconds) * time.Second,
 			}
 		}
 		containers = append(containers, container)
 	}
 
+	var tolerations []rstypes.Toleration
+	for _, t := range ce.Tolerations {
+		tolerations = append(tolerations, rstypes.Toleration{
+			Key:      t.Key,
+			Operator: t.Operator,
+			Value:    t.Value,
+			Effect:   t.Effect,
+		})
+	}
+
 	return &rstypes.Runtime{
-		Type:       rstypes.RuntimeType(ce.Type),
-		Arch:       ce.Arch,
-		Containers: containers,
+		Type:             rstypes.RuntimeType(ce.Type),
+		Arch:             ce.Arch,
+		Containers:       containers,
+		ExecutorLabels:   ce.ExecutorLabels,
+		ExecutorAffinity: rstypes.ExecutorAffinity(ce.ExecutorAffinity),
+		GPUs:             ce.GPUs,
+		NodeSelector:     ce.NodeSelector,
+		Tolerations:      tolerations,
+	}
+}
+
+// genCapabilities removes the optional CAP_ prefix from the capabilities
+// names
+func genCapabilities(capabilities []string) []string {
+	if len(capabilities) == 0 {
+		return nil
 	}
+	caps := make([]string, len(capabilities))
+	for i, c := range capabilities {
+		caps[i] = strings.TrimPrefix(c, "CAP_")
+	}
+	return caps
 }
 
-func stepFromConfigStep(csi interface{}, variables map[string]string) interface{} {
+func genContainer(cc *config.Container, variables map[string]string) *rstypes.Container {
+	env := genEnv(cc.Environment, variables)
+	container := &rstypes.Container{
+		Name:        cc.Name,
+		Image:       cc.Image,
+		Environment: env,
+		User:        cc.User,
+		Privileged:  cc.Privileged,
+		Entrypoint:  cc.Entrypoint,
+		Args:        cc.Args,
+		Volumes:     make([]rstypes.Volume, len(cc.Volumes)),
+		PullPolicy:  rstypes.PullPolicy(cc.PullPolicy),
+	}
+
+	for i, ccVol := range cc.Volumes {
+		container.Volumes[i] = rstypes.Volume{
+			Path: ccVol.Path,
+		}
+
+		if ccVol.TmpFS != nil {
+			var size int64
+			if ccVol.TmpFS.Size != nil {
+				size = ccVol.TmpFS.Size.Value()
+			}
+			container.Volumes[i].TmpFS = &rstypes.VolumeTmpFS{
+				Size: size,
+			}
+		}
+	}
+	if cc.Capabilities != nil {
+		container.CapAdd = genCapabilities(cc.Capabilities.Add)
+		container.CapDrop = genCapabilities(cc.Capabilities.Drop)
+	}
+	if len(cc.Devices) > 0 {
+		container.Devices = append([]string{}, cc.Devices...)
+	}
+	if cc.Resources != nil {
+		container.Resources = rstypes.Resources{
+			Requests: genResourceList(cc.Resources.Requests),
+			Limits:   genResourceList(cc.Resources.Limits),
+		}
+	}
+
+	return container
+}
+
+func genResourceList(rl *config.ResourceList) rstypes.ResourceList {
+	var res rstypes.ResourceList
+	if rl == nil {
+		return res
+	}
+	if rl.CPU != nil {
+		res.CPU = rl.CPU.MilliValue()
+	}
+	if rl.Memory != nil {
+		res.Memory = rl.Memory.Value()
+	}
+
+	return res
+}
+
+// parseTimeout parses a config timeout. The timeout has already been validated
+// by the config parser
+func parseTimeout(timeout string) time.Duration {
+	if timeout == "" {
+		return 0
+	}
+	d, _ := time.ParseDuration(timeout)
+	return d
+}
+
+func stepFromConfigStep(csi interface{}, variables map[string]string, taskIDs map[string]string) interface{} {
 	switch cs := csi.(type) {
 	case *config.CloneStep:
 		// transform a "clone" step in a "run" step command
 		rs := &config.RunStep{}
 		rs.Type = "run"
 		rs.Name = "Clone repository and checkout code"
+		rs.Timeout = cs.Timeout
 		rs.Command = fmt.Sprintf(`
 set -x
 
@@ -116,6 +211,12 @@ Host $AGOLA_GIT_HOST
 EOF
 )
 
+# Provide the repository http credentials with a credential helper reading
+# them from the environment so they aren't saved in the repository config
+if [ -n "$AGOLA_GIT_PASSWORD" ]; then
+	git config --global credential.helper '!f() { test "$1" = get && echo "username=$AGOLA_GIT_USERNAME" && echo "password=$AGOLA_GIT_PASSWORD"; }; f'
+fi
+
 git clone %s $AGOLA_REPOSITORY_URL .
 git fetch origin $AGOLA_GIT_REF
 
@@ -126,7 +227,7 @@ else
 fi
 `, genCloneOptions(cs))
 
-		return rs
+		return stepFromConfigStep(rs, variables, taskIDs)
 
 	case *config.RunStep:
 		rs := &rstypes.RunStep{}
@@ -138,8 +239,15 @@ fi
 		rs.Command = cs.Command
 		rs.Environment = env
 		rs.WorkingDir = cs.WorkingDir
-		rs.Shell = cs.Shell
+		rs.Shell = cs.Shell.CommandLine()
 		rs.Tty = cs.Tty
+		rs.Timeout = parseTimeout(cs.Timeout)
+		rs.Retries = cs.Retries
+		if cs.RetryInterval != "" {
+			// the retry interval has already been validated by the config parser
+			rs.RetryInterval, _ = time.ParseDuration(cs.RetryInterval)
+		}
+		rs.RetryBackoff = cs.RetryBackoff
 		return rs
 
 	case *config.SaveToWorkspaceStep:
@@ -147,6 +255,7 @@ fi
 
 		sws.Type = cs.Type
 		sws.Name = cs.Name
+		sws.Timeout = parseTimeout(cs.Timeout)
 
 		sws.Contents = make([]rstypes.SaveContent, len(cs.Contents))
 		for i, csc := range cs.Contents {
@@ -162,6 +271,7 @@ fi
 	case *config.RestoreWorkspaceStep:
 		rws := &rstypes.RestoreWorkspaceStep{}
 		rws.Name = cs.Name
+		rws.Timeout = parseTimeout(cs.Timeout)
 		rws.Type = cs.Type
 		rws.DestDir = cs.DestDir
 
@@ -172,6 +282,7 @@ fi
 
 		sws.Type = cs.Type
 		sws.Name = cs.Name
+		sws.Timeout = parseTimeout(cs.Timeout)
 		sws.Key = cs.Key
 
 		sws.Contents = make([]rstypes.SaveContent, len(cs.Contents))
@@ -188,12 +299,54 @@ fi
 	case *config.RestoreCacheStep:
 		rws := &rstypes.RestoreCacheStep{}
 		rws.Name = cs.Name
+		rws.Timeout = parseTimeout(cs.Timeout)
 		rws.Type = cs.Type
 		rws.Keys = cs.Keys
 		rws.DestDir = cs.DestDir
 
 		return rws
 
+	case *config.SaveArtifactsStep:
+		sas := &rstypes.SaveArtifactsStep{}
+
+		sas.Type = cs.Type
+		sas.Name = cs.Name
+		sas.Timeout = parseTimeout(cs.Timeout)
+
+		sas.Contents = make([]rstypes.SaveContent, len(cs.Contents))
+		for i, csc := range cs.Contents {
+			sc := rstypes.SaveContent{}
+			sc.SourceDir = csc.SourceDir
+			sc.DestDir = csc.DestDir
+			sc.Paths = csc.Paths
+
+			sas.Contents[i] = sc
+		}
+		return sas
+
+	case *config.RestoreArtifactsStep:
+		ras := &rstypes.RestoreArtifactsStep{}
+		ras.Name = cs.Name
+		ras.Timeout = parseTimeout(cs.Timeout)
+		ras.Type = cs.Type
+		ras.DestDir = cs.DestDir
+
+		ras.TaskIDs = make([]string, len(cs.Tasks))
+		for i, taskName := range cs.Tasks {
+			ras.TaskIDs[i] = taskIDs[taskName]
+		}
+
+		return ras
+
+	case *config.TestReportStep:
+		trs := &rstypes.TestReportStep{}
+		trs.Name = cs.Name
+		trs.Timeout = parseTimeout(cs.Timeout)
+		trs.Type = cs.Type
+		trs.Paths = cs.Paths
+
+		return trs
+
 	default:
 		panic(errors.Errorf("unknown config step type: %s", util.Dump(cs)))
 	}
@@ -201,38 +354,78 @@ fi
 
 // GenRunConfigTasks generates a run config tasks from a run in the config, expanding all the references to tasks
 // this functions assumes that the config is already checked for possible errors (i.e referenced task must exits)
-func GenRunConfigTasks(uuid util.UUIDGenerator, c *config.Config, runName string, variables map[string]string, refType itypes.RunRefType, branch, tag, ref string) map[string]*rstypes.RunConfigTask {
+func GenRunConfigTasks(uuid util.UUIDGenerator, c *config.Config, runName string, variables, annotations map[string]string, refType itypes.RunRefType, branch, tag, ref, schedule, message string, changedFiles []string) map[string]*rstypes.RunConfigTask {
 	cr := c.Run(runName)
 
 	rcts := map[string]*rstypes.RunConfigTask{}
 
+	// generate the tasks ids before the tasks since the steps can reference
+	// other tasks
+	taskIDs := make(map[string]string, len(cr.Tasks))
 	for _, ct := range cr.Tasks {
-		include := types.MatchWhen(ct.When.ToWhen(), refType, branch, tag, ref)
+		taskIDs[ct.Name] = uuid.New(ct.Name).String()
+	}
+
+	for _, ct := range cr.Tasks {
+		include := types.MatchWhen(ct.When.ToWhen(), refType, branch, tag, ref, schedule) && types.MatchWhenMessage(ct.When.ToWhen(), message) && types.MatchWhenPaths(ct.When.ToWhen(), changedFiles) && types.MatchWhenExpression(ct.When.ToWhen(), annotations, variables)
 
 		steps := make(rstypes.Steps, len(ct.Steps))
 		for i, cpts := range ct.Steps {
-			steps[i] = stepFromConfigStep(cpts, variables)
+			steps[i] = stepFromConfigStep(cpts, variables, taskIDs)
+		}
+
+		if ct.DockerLayerCache != nil {
+			steps = dockerLayerCacheSteps(steps, ct.DockerLayerCache)
+		}
+
+		persistentWorkspace := cr.Workspace == config.RunWorkspacePersistent && !ct.SkipWorkspace
+		if persistentWorkspace {
+			steps = persistentWorkspaceSteps(steps, len(ct.Depends) > 0)
 		}
 
 		tEnv := genEnv(ct.Environment, variables)
+		if ct.DockerLayerCache != nil {
+			tEnv[dockerLayerCacheDirEnv] = ct.DockerLayerCache.Dir
+		}
 
 		t := &rstypes.RunConfigTask{
-			ID:                   uuid.New(ct.Name).String(),
+			ID:                   taskIDs[ct.Name],
 			Name:                 ct.Name,
 			Runtime:              genRuntime(c, ct.Runtime, variables),
 			Environment:          tEnv,
 			WorkingDir:           ct.WorkingDir,
-			Shell:                ct.Shell,
+			Shell:                ct.Shell.CommandLine(),
 			User:                 ct.User,
 			Steps:                steps,
-			IgnoreFailure:        ct.IgnoreFailure,
+			IgnoreFailure:        ct.IgnoreFailure || ct.AllowFailure,
 			Skip:                 !include,
-			NeedsApproval:        ct.Approval,
+			NeedsApproval:        ct.Approval.Required,
 			DockerRegistriesAuth: make(map[string]rstypes.DockerRegistryAuth),
+			Labels:               ct.Labels,
+			SkipWorkspace:        ct.SkipWorkspace,
+			Timeout:              parseTimeout(ct.Timeout),
+			PersistentWorkspace:  persistentWorkspace,
+			Retries:              ct.Retries,
+			RetryBackoff:         ct.RetryBackoff,
+			AlwaysRun:            ct.AlwaysRun,
+		}
+		if ct.Approval.Required {
+			t.ApprovalUsers = ct.Approval.Users
+			for _, r := range ct.Approval.OrgRoles {
+				t.ApprovalOrgRoles = append(t.ApprovalOrgRoles, string(r))
+			}
+			t.MinApprovals = ct.Approval.MinApprovals
+		}
+		if ct.RetryInterval != "" {
+			// the retry interval has already been validated by the config parser
+			t.RetryInterval, _ = time.ParseDuration(ct.RetryInterval)
 		}
 
 		if t.Shell == "" {
 			t.Shell = defaultShell
+			if types.OS(t.Runtime.ExecutorLabels[rstypes.ExecutorLabelOS]) == types.OSWindows {
+				t.Shell = defaultWindowsShell
+			}
 		}
 
 		if c.DockerRegistriesAuth != nil {
@@ -242,6 +435,7 @@ func GenRunConfigTasks(uuid util.UUIDGenerator, c *config.Config, runName string
 					Username: genValue(auth.Username, variables),
 					Password: genValue(auth.Password, variables),
 					Auth:     genValue(auth.Auth, variables),
+					Token:    genValue(auth.Token, variables),
 				}
 			}
 		}
@@ -254,6 +448,7 @@ func GenRunConfigTasks(uuid util.UUIDGenerator, c *config.Config, runName string
 					Username: genValue(auth.Username, variables),
 					Password: genValue(auth.Password, variables),
 					Auth:     genValue(auth.Auth, variables),
+					Token:    genValue(auth.Token, variables),
 				}
 			}
 		}
@@ -266,6 +461,7 @@ func GenRunConfigTasks(uuid util.UUIDGenerator, c *config.Config, runName string
 					Username: genValue(auth.Username, variables),
 					Password: genValue(auth.Password, variables),
 					Auth:     genValue(auth.Auth, variables),
+					Token:    genValue(auth.Token, variables),
 				}
 			}
 		}
@@ -293,6 +489,8 @@ func GenRunConfigTasks(uuid util.UUIDGenerator, c *config.Config, runName string
 						condition = rstypes.RunConfigTaskDependConditionOnFailure
 					case config.DependConditionOnSkipped:
 						condition = rstypes.RunConfigTaskDependConditionOnSkipped
+					case config.DependConditionAlways:
+						condition = rstypes.RunConfigTaskDependConditionAlways
 					}
 					conditions[ic] = condition
 				}
@@ -311,6 +509,51 @@ func GenRunConfigTasks(uuid util.UUIDGenerator, c *config.Config, runName string
 	return rcts
 }
 
+// persistentWorkspaceSteps adds to the task steps the steps to restore the
+// working dir saved by the parent tasks and to save the whole working dir at
+// the end
+func persistentWorkspaceSteps(steps rstypes.Steps, hasParents bool) rstypes.Steps {
+	psteps := rstypes.Steps{}
+	if hasParents {
+		psteps = append(psteps, &rstypes.RestoreWorkspaceStep{
+			BaseStep: rstypes.BaseStep{Type: "restore_workspace", Name: "restore persistent workspace"},
+			DestDir:  ".",
+		})
+	}
+	psteps = append(psteps, steps...)
+	psteps = append(psteps, &rstypes.SaveToWorkspaceStep{
+		BaseStep: rstypes.BaseStep{Type: "save_to_workspace", Name: "save persistent workspace"},
+		Contents: []rstypes.SaveContent{{SourceDir: ".", DestDir: ".", Paths: []string{"**"}}},
+	})
+
+	return psteps
+}
+
+// dockerLayerCacheSteps adds to the task steps the steps to restore the docker
+// layer cache dir and to save it at the end. Every run saves a new cache entry
+// and the most recent one is restored
+func dockerLayerCacheSteps(steps rstypes.Steps, dlc *config.DockerLayerCache) rstypes.Steps {
+	psteps := rstypes.Steps{}
+	psteps = append(psteps, &rstypes.RunStep{
+		BaseStep: rstypes.BaseStep{Type: "run", Name: "create docker layer cache dir"},
+		Command:  fmt.Sprintf("mkdir -p '%s'", dlc.Dir),
+		Tty:      util.BoolP(false),
+	})
+	psteps = append(psteps, &rstypes.RestoreCacheStep{
+		BaseStep: rstypes.BaseStep{Type: "restore_cache", Name: "restore docker layer cache"},
+		Keys:     []string{dlc.Key + "-"},
+		DestDir:  dlc.Dir,
+	})
+	psteps = append(psteps, steps...)
+	psteps = append(psteps, &rstypes.SaveCacheStep{
+		BaseStep: rstypes.BaseStep{Type: "save_cache", Name: "save docker layer cache"},
+		Key:      dlc.Key + "-{{ unixtime }}",
+		Contents: []rstypes.SaveContent{{SourceDir: dlc.Dir, DestDir: ".", Paths: []string{"**"}}},
+	})
+
+	return psteps
+}
+
 func getRunConfigTaskByName(rcts map[string]*rstypes.RunConfigTask, name string) *rstypes.RunConfigTask {
 	for _, rct := range rcts {
 		if rct.Name == name {
@@ -360,6 +603,21 @@ func CheckRunConfigTasks(rcts map[string]*rstypes.RunConfigTask) error {
 		}
 	}
 
+	for _, t := range rcts {
+		if err := util.ValidateLabels(t.Labels); err != nil {
+			return errors.Wrapf(err, "task %q", t.Name)
+		}
+
+		if t.SkipWorkspace {
+			for i, s := range t.Steps {
+				switch s.(type) {
+				case *rstypes.SaveToWorkspaceStep, *rstypes.RestoreWorkspaceStep:
+					return errors.Errorf("task %q: workspace step %d not allowed with skip workspace", t.Name, i)
+				}
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -445,6 +703,24 @@ func GetAllParents(rcts map[string]*rstypes.RunConfigTask, task *rstypes.RunConf
 	return parents
 }
 
+// FilterRunConfigTasks returns only the run config tasks with the provided
+// names and all their parents (both direct and ancestors) so the resulting
+// tasks graph is complete.
+func FilterRunConfigTasks(rcts map[string]*rstypes.RunConfigTask, taskNames []string) map[string]*rstypes.RunConfigTask {
+	frcts := map[string]*rstypes.RunConfigTask{}
+	for _, taskName := range taskNames {
+		rct := getRunConfigTaskByName(rcts, taskName)
+		if rct == nil {
+			continue
+		}
+		frcts[rct.ID] = rct
+		for _, parent := range GetAllParents(rcts, rct) {
+			frcts[parent.ID] = parent
+		}
+	}
+	return frcts
+}
+
 func GetParentDependConditions(t, pt *rstypes.RunConfigTask) []rstypes.RunConfigTaskDependCondition {
 	if dt, ok := t.Depends[pt.ID]; ok {
 		return dt.Conditions
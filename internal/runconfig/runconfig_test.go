@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"agola.io/agola/internal/config"
 	"agola.io/agola/internal/errors"
@@ -452,12 +453,100 @@ func TestGetAllParents(t *testing.T) {
 	}
 }
 
-func TestCheckRunConfig(t *testing.T) {
+func TestFilterRunConfigTasks(t *testing.T) {
 	type task struct {
 		ID      string
-		Level   int
 		Depends map[string]*rstypes.RunConfigTaskDepend
 	}
+	// a -> (b, c) -> d, e
+	in := []task{
+		{
+			ID: "a",
+		},
+		{
+			ID: "b",
+			Depends: map[string]*rstypes.RunConfigTaskDepend{
+				"a": &rstypes.RunConfigTaskDepend{TaskID: "a"},
+			},
+		},
+		{
+			ID: "c",
+			Depends: map[string]*rstypes.RunConfigTaskDepend{
+				"a": &rstypes.RunConfigTaskDepend{TaskID: "a"},
+			},
+		},
+		{
+			ID: "d",
+			Depends: map[string]*rstypes.RunConfigTaskDepend{
+				"b": &rstypes.RunConfigTaskDepend{TaskID: "b"},
+				"c": &rstypes.RunConfigTaskDepend{TaskID: "c"},
+			},
+		},
+		{
+			ID: "e",
+		},
+	}
+
+	tests := []struct {
+		name      string
+		taskNames []string
+		out       []string
+	}{
+		{
+			name:      "test root task",
+			taskNames: []string{"a"},
+			out:       []string{"a"},
+		},
+		{
+			name:      "test task with all its parents",
+			taskNames: []string{"d"},
+			out:       []string{"a", "b", "c", "d"},
+		},
+		{
+			name:      "test multiple tasks",
+			taskNames: []string{"b", "e"},
+			out:       []string{"a", "b", "e"},
+		},
+		{
+			name:      "test unexisting task",
+			taskNames: []string{"f"},
+			out:       []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inRcts := map[string]*rstypes.RunConfigTask{}
+			for _, t := range in {
+				// use the id also as name
+				inRcts[t.ID] = &rstypes.RunConfigTask{
+					ID:      t.ID,
+					Name:    t.ID,
+					Depends: t.Depends,
+				}
+			}
+
+			outRcts := FilterRunConfigTasks(inRcts, tt.taskNames)
+
+			outList := []string{}
+			for _, rct := range outRcts {
+				outList = append(outList, rct.ID)
+			}
+			if !util.CompareStringSliceNoOrder(tt.out, outList) {
+				t.Fatalf("got %s, expected %s", util.Dump(outList), util.Dump(tt.out))
+			}
+		})
+	}
+}
+
+func TestCheckRunConfig(t *testing.T) {
+	type task struct {
+		ID            string
+		Level         int
+		Depends       map[string]*rstypes.RunConfigTaskDepend
+		Steps         rstypes.Steps
+		SkipWorkspace bool
+	}
 	tests := []struct {
 		name string
 		in   []task
@@ -648,16 +737,41 @@ func TestCheckRunConfig(t *testing.T) {
 			},
 			err: errors.Errorf("task %q and its parent %q have both a dependency on task %q", "task4", "task3", "task1"),
 		},
+		{
+			name: "test skip workspace task without workspace steps",
+			in: []task{
+				{
+					ID:            "1",
+					Level:         -1,
+					Steps:         rstypes.Steps{&rstypes.RunStep{Command: "command01"}},
+					SkipWorkspace: true,
+				},
+			},
+		},
+		{
+			name: "test skip workspace task with a restore workspace step",
+			in: []task{
+				{
+					ID:            "1",
+					Level:         -1,
+					Steps:         rstypes.Steps{&rstypes.RestoreWorkspaceStep{DestDir: "."}},
+					SkipWorkspace: true,
+				},
+			},
+			err: errors.Errorf("task %q: workspace step %d not allowed with skip workspace", "task1", 0),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			inRcts := map[string]*rstypes.RunConfigTask{}
 			for _, t := range tt.in {
 				inRcts[t.ID] = &rstypes.RunConfigTask{
-					Name:    fmt.Sprintf("task%s", t.ID),
-					ID:      t.ID,
-					Level:   t.Level,
-					Depends: t.Depends,
+					Name:          fmt.Sprintf("task%s", t.ID),
+					ID:            t.ID,
+					Level:         t.Level,
+					Depends:       t.Depends,
+					Steps:         t.Steps,
+					SkipWorkspace: t.SkipWorkspace,
 				}
 
 			}
@@ -733,6 +847,15 @@ func TestGenRunConfig(t *testing.T) {
 													TmpFS: &config.VolumeTmpFS{Size: resource.NewQuantity(1024*1024*1024, resource.BinarySI)},
 												},
 											},
+											Resources: &config.Resources{
+												Requests: &config.ResourceList{
+													CPU:    resource.NewMilliQuantity(500, resource.DecimalSI),
+													Memory: resource.NewQuantity(512*1024*1024, resource.BinarySI),
+												},
+												Limits: &config.ResourceList{
+													CPU: resource.NewQuantity(2, resource.DecimalSI),
+												},
+											},
 										},
 									},
 								},
@@ -773,7 +896,7 @@ func TestGenRunConfig(t *testing.T) {
 
 								Depends:       []*config.Depend{},
 								IgnoreFailure: false,
-								Approval:      false,
+								Approval:      config.Approval{},
 								When: &config.When{
 									Branch: &types.WhenConditions{Include: []types.WhenCondition{{Match: "master"}}},
 									Tag:    &types.WhenConditions{Include: []types.WhenCondition{{Match: "v1.x"}, {Match: "v2.x"}}},
@@ -820,6 +943,10 @@ func TestGenRunConfig(t *testing.T) {
 										TmpFS: &rstypes.VolumeTmpFS{Size: 1024 * 1024 * 1024},
 									},
 								},
+								Resources: rstypes.Resources{
+									Requests: rstypes.ResourceList{CPU: 500, Memory: 512 * 1024 * 1024},
+									Limits:   rstypes.ResourceList{CPU: 2000},
+								},
 							},
 						},
 					},
@@ -837,6 +964,215 @@ func TestGenRunConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "test runtime services",
+			in: &config.Config{
+				Runs: []*config.Run{
+					&config.Run{
+						Name: "run01",
+						Tasks: []*config.Task{
+							&config.Task{
+								Name: "task01",
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "image01",
+										},
+									},
+									Services: []*config.Service{
+										&config.Service{
+											Container: config.Container{
+												Name:  "db",
+												Image: "postgres",
+											},
+											Readiness: &config.Readiness{
+												TCPPort:        5432,
+												TimeoutSeconds: 30,
+											},
+										},
+										&config.Service{
+											Container: config.Container{
+												Image: "redis",
+											},
+										},
+									},
+								},
+								Steps: config.Steps{
+									&config.RunStep{
+										BaseStep: config.BaseStep{
+											Type: "run",
+											Name: "command01",
+										},
+										Command: "command01",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			out: map[string]*rstypes.RunConfigTask{
+				uuid.New("task01").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task01").String(),
+					Name: "task01", Depends: map[string]*rstypes.RunConfigTaskDepend{},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+							{
+								Name:        "db",
+								Image:       "postgres",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+								Readiness: &rstypes.ContainerReadiness{
+									TCPPort: 5432,
+									Timeout: 30 * time.Second,
+								},
+							},
+							{
+								Image:       "redis",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					Shell:       "/bin/sh -e",
+					Environment: map[string]string{},
+					Steps: rstypes.Steps{
+						&rstypes.RunStep{BaseStep: rstypes.BaseStep{Type: "run", Name: "command01"}, Command: "command01", Environment: map[string]string{}},
+					},
+				},
+			},
+		},
+		{
+			name: "test task labels",
+			in: &config.Config{
+				Runs: []*config.Run{
+					&config.Run{
+						Name:   "run01",
+						Labels: map[string]string{"team": "backend"},
+						Tasks: []*config.Task{
+							&config.Task{
+								Name:   "task01",
+								Labels: map[string]string{"tier": "e2e"},
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "image01",
+										},
+									},
+								},
+								Steps: config.Steps{
+									&config.RunStep{
+										BaseStep: config.BaseStep{
+											Type: "run",
+											Name: "command01",
+										},
+										Command: "command01",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			out: map[string]*rstypes.RunConfigTask{
+				uuid.New("task01").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task01").String(),
+					Name: "task01", Depends: map[string]*rstypes.RunConfigTaskDepend{},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{},
+					Labels:               map[string]string{"tier": "e2e"},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					Shell:       "/bin/sh -e",
+					Environment: map[string]string{},
+					Steps: rstypes.Steps{
+						&rstypes.RunStep{BaseStep: rstypes.BaseStep{Type: "run", Name: "command01"}, Command: "command01", Environment: map[string]string{}},
+					},
+				},
+			},
+		},
+		{
+			name: "test task token auth from variable",
+			in: &config.Config{
+				Runs: []*config.Run{
+					&config.Run{
+						Name: "run01",
+						Tasks: []*config.Task{
+							&config.Task{
+								Name: "task01",
+								DockerRegistriesAuth: map[string]*config.DockerRegistryAuth{
+									"registry.example.com": {
+										Type:  config.DockerRegistryAuthTypeToken,
+										Token: config.Value{Type: config.ValueTypeFromVariable, Value: "token"},
+									},
+								},
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Arch: "",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "registry.example.com/image01",
+										},
+									},
+								},
+								Steps: config.Steps{
+									&config.RunStep{
+										BaseStep: config.BaseStep{
+											Type: "run",
+											Name: "command01",
+										},
+										Command: "command01",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			variables: map[string]string{
+				"token": "yourregistrytoken",
+			},
+			out: map[string]*rstypes.RunConfigTask{
+				uuid.New("task01").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task01").String(),
+					Name: "task01", Depends: map[string]*rstypes.RunConfigTaskDepend{},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{
+						"registry.example.com": {
+							Type:  rstypes.DockerRegistryAuthTypeToken,
+							Token: "yourregistrytoken",
+						},
+					},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "registry.example.com/image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					Shell:       "/bin/sh -e",
+					Environment: map[string]string{},
+					Steps: rstypes.Steps{
+						&rstypes.RunStep{BaseStep: rstypes.BaseStep{Type: "run", Name: "command01"}, Command: "command01", Environment: map[string]string{}},
+					},
+				},
+			},
+		},
 		{
 			name: "test run auth used for task undefined auth",
 			in: &config.Config{
@@ -1093,6 +1429,557 @@ func TestGenRunConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "test run with persistent workspace",
+			in: &config.Config{
+				Runs: []*config.Run{
+					&config.Run{
+						Name:      "run01",
+						Workspace: config.RunWorkspacePersistent,
+						Tasks: []*config.Task{
+							&config.Task{
+								Name: "task01",
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Arch: "",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "image01",
+										},
+									},
+								},
+							},
+							&config.Task{
+								Name: "task02",
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Arch: "",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "image01",
+										},
+									},
+								},
+								Depends: config.Depends{
+									&config.Depend{
+										TaskName: "task01",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			out: map[string]*rstypes.RunConfigTask{
+				uuid.New("task01").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task01").String(),
+					Name: "task01", Depends: map[string]*rstypes.RunConfigTaskDepend{},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{},
+					Shell:                "/bin/sh -e",
+					Environment:          map[string]string{},
+					Steps: rstypes.Steps{
+						&rstypes.SaveToWorkspaceStep{
+							BaseStep: rstypes.BaseStep{Type: "save_to_workspace", Name: "save persistent workspace"},
+							Contents: []rstypes.SaveContent{{SourceDir: ".", DestDir: ".", Paths: []string{"**"}}},
+						},
+					},
+					PersistentWorkspace: true,
+				},
+				uuid.New("task02").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task02").String(),
+					Name: "task02",
+					Depends: map[string]*rstypes.RunConfigTaskDepend{
+						uuid.New("task01").String(): &rstypes.RunConfigTaskDepend{
+							TaskID:     uuid.New("task01").String(),
+							Conditions: []rstypes.RunConfigTaskDependCondition{rstypes.RunConfigTaskDependConditionOnSuccess},
+						},
+					},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					Shell:       "/bin/sh -e",
+					Environment: map[string]string{},
+					Steps: rstypes.Steps{
+						&rstypes.RestoreWorkspaceStep{
+							BaseStep: rstypes.BaseStep{Type: "restore_workspace", Name: "restore persistent workspace"},
+							DestDir:  ".",
+						},
+						&rstypes.SaveToWorkspaceStep{
+							BaseStep: rstypes.BaseStep{Type: "save_to_workspace", Name: "save persistent workspace"},
+							Contents: []rstypes.SaveContent{{SourceDir: ".", DestDir: ".", Paths: []string{"**"}}},
+						},
+					},
+					PersistentWorkspace: true,
+				},
+			},
+		},
+		{
+			name: "test task with docker layer cache",
+			in: &config.Config{
+				Runs: []*config.Run{
+					&config.Run{
+						Name: "run01",
+						Tasks: []*config.Task{
+							&config.Task{
+								Name: "task01",
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Arch: "",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "image01",
+										},
+									},
+								},
+								Steps: config.Steps{
+									&config.RunStep{
+										BaseStep: config.BaseStep{
+											Type: "run",
+											Name: "build",
+										},
+										Command: "docker buildx build .",
+									},
+								},
+								DockerLayerCache: &config.DockerLayerCache{
+									Key: "docker-layer-cache-task01",
+									Dir: "/tmp/dockercache",
+								},
+							},
+						},
+					},
+				},
+			},
+			out: map[string]*rstypes.RunConfigTask{
+				uuid.New("task01").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task01").String(),
+					Name: "task01", Depends: map[string]*rstypes.RunConfigTaskDepend{},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{},
+					Shell:                "/bin/sh -e",
+					Environment: map[string]string{
+						"AGOLA_DOCKER_LAYER_CACHE_DIR": "/tmp/dockercache",
+					},
+					Steps: rstypes.Steps{
+						&rstypes.RunStep{
+							BaseStep: rstypes.BaseStep{Type: "run", Name: "create docker layer cache dir"},
+							Command:  "mkdir -p '/tmp/dockercache'",
+							Tty:      util.BoolP(false),
+						},
+						&rstypes.RestoreCacheStep{
+							BaseStep: rstypes.BaseStep{Type: "restore_cache", Name: "restore docker layer cache"},
+							Keys:     []string{"docker-layer-cache-task01-"},
+							DestDir:  "/tmp/dockercache",
+						},
+						&rstypes.RunStep{
+							BaseStep:    rstypes.BaseStep{Type: "run", Name: "build"},
+							Command:     "docker buildx build .",
+							Environment: map[string]string{},
+						},
+						&rstypes.SaveCacheStep{
+							BaseStep: rstypes.BaseStep{Type: "save_cache", Name: "save docker layer cache"},
+							Key:      "docker-layer-cache-task01-{{ unixtime }}",
+							Contents: []rstypes.SaveContent{{SourceDir: "/tmp/dockercache", DestDir: ".", Paths: []string{"**"}}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "test run step with retries",
+			in: &config.Config{
+				Runs: []*config.Run{
+					&config.Run{
+						Name: "run01",
+						Tasks: []*config.Task{
+							&config.Task{
+								Name: "task01",
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Arch: "",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "image01",
+										},
+									},
+								},
+								Steps: config.Steps{
+									&config.RunStep{
+										BaseStep: config.BaseStep{
+											Type: "run",
+											Name: "install packages",
+										},
+										Command:       "apk add git",
+										Retries:       3,
+										RetryInterval: "10s",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			out: map[string]*rstypes.RunConfigTask{
+				uuid.New("task01").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task01").String(),
+					Name: "task01", Depends: map[string]*rstypes.RunConfigTaskDepend{},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{},
+					Shell:                "/bin/sh -e",
+					Environment:          map[string]string{},
+					Steps: rstypes.Steps{
+						&rstypes.RunStep{
+							BaseStep:      rstypes.BaseStep{Type: "run", Name: "install packages"},
+							Command:       "apk add git",
+							Environment:   map[string]string{},
+							Retries:       3,
+							RetryInterval: 10 * time.Second,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "test task and step timeouts",
+			in: &config.Config{
+				Runs: []*config.Run{
+					&config.Run{
+						Name: "run01",
+						Tasks: []*config.Task{
+							&config.Task{
+								Name: "task01",
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Arch: "",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "image01",
+										},
+									},
+								},
+								Timeout: "30m",
+								Steps: config.Steps{
+									&config.RunStep{
+										BaseStep: config.BaseStep{
+											Type:    "run",
+											Name:    "build",
+											Timeout: "5m",
+										},
+										Command: "make",
+									},
+									&config.SaveCacheStep{
+										BaseStep: config.BaseStep{
+											Type:    "save_cache",
+											Timeout: "1m",
+										},
+										Key:      "cache-key",
+										Contents: []*config.SaveContent{{SourceDir: "/go/pkg/mod", Paths: []string{"**"}}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			out: map[string]*rstypes.RunConfigTask{
+				uuid.New("task01").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task01").String(),
+					Name: "task01", Depends: map[string]*rstypes.RunConfigTaskDepend{},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{},
+					Shell:                "/bin/sh -e",
+					Environment:          map[string]string{},
+					Timeout:              30 * time.Minute,
+					Steps: rstypes.Steps{
+						&rstypes.RunStep{
+							BaseStep:    rstypes.BaseStep{Type: "run", Name: "build", Timeout: 5 * time.Minute},
+							Command:     "make",
+							Environment: map[string]string{},
+						},
+						&rstypes.SaveCacheStep{
+							BaseStep: rstypes.BaseStep{Type: "save_cache", Timeout: 1 * time.Minute},
+							Key:      "cache-key",
+							Contents: []rstypes.SaveContent{{SourceDir: "/go/pkg/mod", Paths: []string{"**"}}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "test task with allow_failure",
+			in: &config.Config{
+				Runs: []*config.Run{
+					&config.Run{
+						Name: "run01",
+						Tasks: []*config.Task{
+							&config.Task{
+								Name: "task01",
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Arch: "",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "image01",
+										},
+									},
+								},
+								AllowFailure: true,
+								Steps: config.Steps{
+									&config.RunStep{
+										BaseStep: config.BaseStep{
+											Type: "run",
+										},
+										Command: "make lint",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			out: map[string]*rstypes.RunConfigTask{
+				uuid.New("task01").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task01").String(),
+					Name: "task01", Depends: map[string]*rstypes.RunConfigTaskDepend{},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{},
+					Shell:                "/bin/sh -e",
+					Environment:          map[string]string{},
+					IgnoreFailure:        true,
+					Steps: rstypes.Steps{
+						&rstypes.RunStep{
+							BaseStep:    rstypes.BaseStep{Type: "run"},
+							Command:     "make lint",
+							Environment: map[string]string{},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "test task and step retries",
+			in: &config.Config{
+				Runs: []*config.Run{
+					&config.Run{
+						Name: "run01",
+						Tasks: []*config.Task{
+							&config.Task{
+								Name: "task01",
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Arch: "",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "image01",
+										},
+									},
+								},
+								Retries:       2,
+								RetryInterval: "30s",
+								RetryBackoff:  2,
+								Steps: config.Steps{
+									&config.RunStep{
+										BaseStep: config.BaseStep{
+											Type: "run",
+											Name: "test",
+										},
+										Command:       "make test",
+										Retries:       3,
+										RetryInterval: "5s",
+										RetryBackoff:  1.5,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			out: map[string]*rstypes.RunConfigTask{
+				uuid.New("task01").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task01").String(),
+					Name: "task01", Depends: map[string]*rstypes.RunConfigTaskDepend{},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{},
+					Shell:                "/bin/sh -e",
+					Environment:          map[string]string{},
+					Retries:              2,
+					RetryInterval:        30 * time.Second,
+					RetryBackoff:         2,
+					Steps: rstypes.Steps{
+						&rstypes.RunStep{
+							BaseStep:      rstypes.BaseStep{Type: "run", Name: "test"},
+							Command:       "make test",
+							Environment:   map[string]string{},
+							Retries:       3,
+							RetryInterval: 5 * time.Second,
+							RetryBackoff:  1.5,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "test task restoring the artifacts of its dependency",
+			in: &config.Config{
+				Runs: []*config.Run{
+					&config.Run{
+						Name: "run01",
+						Tasks: []*config.Task{
+							&config.Task{
+								Name: "task01",
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Arch: "",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "image01",
+										},
+									},
+								},
+								Steps: config.Steps{
+									&config.SaveArtifactsStep{
+										BaseStep: config.BaseStep{Type: "save_artifacts"},
+										Contents: []*config.SaveContent{{SourceDir: "./bin", DestDir: "bin", Paths: []string{"**"}}},
+									},
+								},
+							},
+							&config.Task{
+								Name: "task02",
+								Runtime: &config.Runtime{
+									Type: "pod",
+									Arch: "",
+									Containers: []*config.Container{
+										&config.Container{
+											Image: "image01",
+										},
+									},
+								},
+								Steps: config.Steps{
+									&config.RestoreArtifactsStep{
+										BaseStep: config.BaseStep{Type: "restore_artifacts"},
+										Tasks:    []string{"task01"},
+										DestDir:  ".",
+									},
+								},
+								Depends: config.Depends{
+									&config.Depend{
+										TaskName: "task01",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			out: map[string]*rstypes.RunConfigTask{
+				uuid.New("task01").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task01").String(),
+					Name: "task01", Depends: map[string]*rstypes.RunConfigTaskDepend{},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{},
+					Shell:                "/bin/sh -e",
+					Environment:          map[string]string{},
+					Steps: rstypes.Steps{
+						&rstypes.SaveArtifactsStep{
+							BaseStep: rstypes.BaseStep{Type: "save_artifacts"},
+							Contents: []rstypes.SaveContent{{SourceDir: "./bin", DestDir: "bin", Paths: []string{"**"}}},
+						},
+					},
+				},
+				uuid.New("task02").String(): &rstypes.RunConfigTask{
+					ID:   uuid.New("task02").String(),
+					Name: "task02",
+					Depends: map[string]*rstypes.RunConfigTaskDepend{
+						uuid.New("task01").String(): &rstypes.RunConfigTaskDepend{
+							TaskID:     uuid.New("task01").String(),
+							Conditions: []rstypes.RunConfigTaskDependCondition{rstypes.RunConfigTaskDependConditionOnSuccess},
+						},
+					},
+					DockerRegistriesAuth: map[string]rstypes.DockerRegistryAuth{},
+					Runtime: &rstypes.Runtime{Type: rstypes.RuntimeType("pod"),
+						Containers: []*rstypes.Container{
+							{
+								Image:       "image01",
+								Environment: map[string]string{},
+								Volumes:     []rstypes.Volume{},
+							},
+						},
+					},
+					Shell:       "/bin/sh -e",
+					Environment: map[string]string{},
+					Steps: rstypes.Steps{
+						&rstypes.RestoreArtifactsStep{
+							BaseStep: rstypes.BaseStep{Type: "restore_artifacts"},
+							TaskIDs:  []string{uuid.New("task01").String()},
+							DestDir:  ".",
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "test runconfig generation encodedauth global",
 			in: &config.Config{
@@ -1323,7 +2210,7 @@ func TestGenRunConfig(t *testing.T) {
 								},
 								Depends:       []*config.Depend{},
 								IgnoreFailure: false,
-								Approval:      false,
+								Approval:      config.Approval{},
 							},
 						},
 					},
@@ -1362,7 +2249,7 @@ func TestGenRunConfig(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			out := GenRunConfigTasks(uuid, tt.in, "run01", tt.variables, "", "", "", "")
+			out := GenRunConfigTasks(uuid, tt.in, "run01", tt.variables, nil, "", "", "", "", "", "", nil)
 
 			if diff := cmp.Diff(tt.out, out); diff != "" {
 				t.Error(diff)
@@ -0,0 +1,150 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sealedsecret implements the encryption of the secrets values on the
+// client side. Values are encrypted using an anonymous nacl box with the
+// runservice public key so only the runservice, owning the private key, can
+// decrypt them when generating the executor tasks.
+//
+// Every sealed value is bound to the secret it's saved in (its parent project
+// or project group and its name) so it cannot be decrypted when copied in
+// another secret.
+package sealedsecret
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+const (
+	// Prefix is the prefix of every sealed value. It's used to detect sealed
+	// values inside the run environment
+	Prefix = "agola-sealed:v1:"
+
+	keySize = 32
+)
+
+// Key is a sealed secrets key pair
+type Key struct {
+	PublicKey  *[keySize]byte
+	PrivateKey *[keySize]byte
+}
+
+// ParseKey parses a base64 encoded 32 bytes private key and derives its
+// public key
+func ParseKey(s string) (*Key, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode sealed secrets private key")
+	}
+	if len(b) != keySize {
+		return nil, errors.Errorf("wrong sealed secrets private key size %d, must be %d bytes", len(b), keySize)
+	}
+
+	privateKey := new([keySize]byte)
+	copy(privateKey[:], b)
+	publicKey := new([keySize]byte)
+	curve25519.ScalarBaseMult(publicKey, privateKey)
+
+	return &Key{PublicKey: publicKey, PrivateKey: privateKey}, nil
+}
+
+// EncodePublicKey returns the base64 encoded public key
+func (k *Key) EncodePublicKey() string {
+	return base64.StdEncoding.EncodeToString(k.PublicKey[:])
+}
+
+// ParsePublicKey parses a base64 encoded public key
+func ParsePublicKey(s string) (*[keySize]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode sealed secrets public key")
+	}
+	if len(b) != keySize {
+		return nil, errors.Errorf("wrong sealed secrets public key size %d, must be %d bytes", len(b), keySize)
+	}
+
+	publicKey := new([keySize]byte)
+	copy(publicKey[:], b)
+
+	return publicKey, nil
+}
+
+// IsSealed reports if the value is a sealed value
+func IsSealed(v string) bool {
+	return strings.HasPrefix(v, Prefix)
+}
+
+// Validate checks that the value is a well formed sealed value
+func Validate(v string) error {
+	if !IsSealed(v) {
+		return errors.Errorf("value isn't sealed")
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, Prefix))
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode sealed value")
+	}
+	if len(b) < box.AnonymousOverhead {
+		return errors.Errorf("sealed value too short")
+	}
+
+	return nil
+}
+
+// Binding returns the binding of the values sealed in the secret with the
+// provided parent id and name
+func Binding(parentID, secretName string) string {
+	return fmt.Sprintf("%s/%s", parentID, secretName)
+}
+
+// Seal encrypts the value, bound to the provided binding, with the provided
+// public key
+func Seal(publicKey *[keySize]byte, binding, v string) (string, error) {
+	b, err := box.SealAnonymous(nil, []byte(binding+"\x00"+v), publicKey, rand.Reader)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return Prefix + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Open decrypts a sealed value checking that it's bound to the provided
+// binding
+func (k *Key) Open(v, binding string) (string, error) {
+	if err := Validate(v); err != nil {
+		return "", errors.WithStack(err)
+	}
+	b, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, Prefix))
+
+	out, ok := box.OpenAnonymous(nil, b, k.PublicKey, k.PrivateKey)
+	if !ok {
+		return "", errors.Errorf("failed to decrypt sealed value")
+	}
+	parts := strings.SplitN(string(out), "\x00", 2)
+	if len(parts) != 2 {
+		return "", errors.Errorf("sealed value without binding")
+	}
+	if parts[0] != binding {
+		return "", errors.Errorf("sealed value isn't bound to secret %q", binding)
+	}
+
+	return parts[1], nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sealedsecret
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+func genTestKey(t *testing.T) *Key {
+	b := make([]byte, keySize)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	key, err := ParseKey(base64.StdEncoding.EncodeToString(b))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	return key
+}
+
+func TestSealOpen(t *testing.T) {
+	key := genTestKey(t)
+
+	publicKey, err := ParsePublicKey(key.EncodePublicKey())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	binding := Binding("project01", "secret01")
+	sealed, err := Seal(publicKey, binding, "secretvalue01")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !IsSealed(sealed) {
+		t.Fatalf("expected sealed value, got %q", sealed)
+	}
+	if err := Validate(sealed); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	v, err := key.Open(sealed, binding)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if v != "secretvalue01" {
+		t.Fatalf("expected value %q, got %q", "secretvalue01", v)
+	}
+
+	// a different key must not be able to open the value
+	otherKey := genTestKey(t)
+	if _, err := otherKey.Open(sealed, binding); err == nil {
+		t.Fatalf("expected error opening value with a different key")
+	}
+}
+
+func TestOpenBinding(t *testing.T) {
+	key := genTestKey(t)
+
+	sealed, err := Seal(key.PublicKey, Binding("project01", "secret01"), "secretvalue01")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		binding string
+		err     bool
+	}{
+		{name: "test same secret", binding: Binding("project01", "secret01")},
+		{name: "test secret in another project", binding: Binding("project02", "secret01"), err: true},
+		{name: "test another secret in the same project", binding: Binding("project01", "secret02"), err: true},
+		{name: "test empty binding", binding: "", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := key.Open(sealed, tt.binding)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got value %q", v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if v != "secretvalue01" {
+				t.Fatalf("expected value %q, got %q", "secretvalue01", v)
+			}
+		})
+	}
+}
+
+func TestValidateInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{name: "test plain value", in: "secretvalue01"},
+		{name: "test wrong base64", in: Prefix + "%%%"},
+		{name: "test too short value", in: Prefix + base64.StdEncoding.EncodeToString([]byte("short"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.in); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	if _, err := ParseKey(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"agola.io/agola/internal/gitsources/gitea"
 	"agola.io/agola/internal/gitsources/github"
 	"agola.io/agola/internal/gitsources/gitlab"
+	"agola.io/agola/internal/gitsources/plaingit"
+	"agola.io/agola/internal/util"
 	cstypes "agola.io/agola/services/configstore/types"
 )
 
@@ -59,6 +61,34 @@ func newGithub(rs *cstypes.RemoteSource, accessToken string) (*github.Client, er
 	return c, errors.WithStack(err)
 }
 
+func newPlainGit(rs *cstypes.RemoteSource, accessToken string) (*plaingit.Client, error) {
+	c, err := plaingit.New(plaingit.Opts{
+		URL:                 rs.APIURL,
+		SSHHostKey:          rs.SSHHostKey,
+		SkipSSHHostKeyCheck: rs.SkipSSHHostKeyCheck,
+		UserName:            accessToken,
+	})
+
+	return c, errors.WithStack(err)
+}
+
+// GetPlainGitSource returns a plain git source client that will access the
+// repositories using the provided ssh private key (usually the project one).
+func GetPlainGitSource(rs *cstypes.RemoteSource, sshPrivateKey string, skipSSHHostKeyCheck bool) (*plaingit.Client, error) {
+	if rs.Type != cstypes.RemoteSourceTypeGit {
+		return nil, errors.Errorf("remote source %s isn't a plain git source", rs.Name)
+	}
+
+	c, err := plaingit.New(plaingit.Opts{
+		URL:                 rs.APIURL,
+		SSHPrivateKey:       sshPrivateKey,
+		SSHHostKey:          rs.SSHHostKey,
+		SkipSSHHostKeyCheck: rs.SkipSSHHostKeyCheck || skipSSHHostKeyCheck,
+	})
+
+	return c, errors.WithStack(err)
+}
+
 func GetAccessToken(rs *cstypes.RemoteSource, userAccessToken, oauth2AccessToken string) (string, error) {
 	switch rs.AuthType {
 	case cstypes.RemoteSourceAuthTypePassword:
@@ -89,6 +119,8 @@ func GetGitSource(rs *cstypes.RemoteSource, la *cstypes.LinkedAccount) (gitsourc
 		gitSource, err = newGitlab(rs, accessToken)
 	case cstypes.RemoteSourceTypeGithub:
 		gitSource, err = newGithub(rs, accessToken)
+	case cstypes.RemoteSourceTypeGit:
+		gitSource, err = newPlainGit(rs, accessToken)
 	default:
 		return nil, errors.Errorf("remote source %s isn't a valid git source", rs.Name)
 	}
@@ -134,9 +166,21 @@ func GetPasswordSource(rs *cstypes.RemoteSource, accessToken string) (gitsource.
 	switch rs.Type {
 	case cstypes.RemoteSourceTypeGitea:
 		passwordSource, err = newGitea(rs, accessToken)
+	case cstypes.RemoteSourceTypeGit:
+		passwordSource, err = newPlainGit(rs, accessToken)
 	default:
 		return nil, errors.Errorf("remote source %s isn't a valid oauth2 source", rs.Name)
 	}
 
 	return passwordSource, errors.WithStack(err)
 }
+
+// GetSSHCloneURL returns the ssh clone url of a remote source repository,
+// rewritten to use the remote source ssh endpoint when defined
+func GetSSHCloneURL(rs *cstypes.RemoteSource, cloneURL string) (string, error) {
+	u, err := util.RewriteSSHCloneURL(cloneURL, rs.SSHEndpoint)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to rewrite clone url %q", cloneURL)
+	}
+	return u, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"agola.io/agola/internal/gitsources/plaingit"
+	cstypes "agola.io/agola/services/configstore/types"
+)
+
+func TestPlainGitSource(t *testing.T) {
+	tests := []struct {
+		name string
+		rs   *cstypes.RemoteSource
+		err  bool
+	}{
+		{
+			name: "plain git source",
+			rs: &cstypes.RemoteSource{
+				Name:     "rs01",
+				Type:     cstypes.RemoteSourceTypeGit,
+				AuthType: cstypes.RemoteSourceAuthTypePassword,
+				APIURL:   "ssh://git@example.com:2222",
+			},
+		},
+		{
+			name: "plain git source without url",
+			rs: &cstypes.RemoteSource{
+				Name:     "rs01",
+				Type:     cstypes.RemoteSourceTypeGit,
+				AuthType: cstypes.RemoteSourceAuthTypePassword,
+			},
+			err: true,
+		},
+		{
+			name: "gitea source",
+			rs: &cstypes.RemoteSource{
+				Name:     "rs01",
+				Type:     cstypes.RemoteSourceTypeGitea,
+				AuthType: cstypes.RemoteSourceAuthTypePassword,
+				APIURL:   "https://gitea.example.com",
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := GetPlainGitSource(tt.rs, "privatekey", false)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected err")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if out := c.RepoURL("org/repo.git"); out != "ssh://git@example.com:2222/org/repo.git" {
+				t.Fatalf("unexpected repo url %q", out)
+			}
+
+			// the linked account git source and password source are also
+			// plain git clients
+			gitSource, err := GetGitSource(tt.rs, &cstypes.LinkedAccount{UserAccessToken: "user01"})
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if _, ok := gitSource.(*plaingit.Client); !ok {
+				t.Fatalf("expected plain git source, got %T", gitSource)
+			}
+			passwordSource, err := GetPasswordSource(tt.rs, "user01")
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if _, ok := passwordSource.(*plaingit.Client); !ok {
+				t.Fatalf("expected plain git password source, got %T", passwordSource)
+			}
+		})
+	}
+}
@@ -17,9 +17,11 @@ package common
 import (
 	"crypto/rsa"
 	"encoding/json"
+	"io/ioutil"
 	"time"
 
 	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/services/config"
 	"github.com/golang-jwt/jwt/v4"
 )
 
@@ -31,6 +33,51 @@ type TokenSigningData struct {
 	Key        []byte
 }
 
+// NewTokenSigningData returns the token signing data from the token signing
+// config
+func NewTokenSigningData(c *config.TokenSigning) (*TokenSigningData, error) {
+	sd := &TokenSigningData{Duration: c.Duration}
+	switch c.Method {
+	case "hmac":
+		sd.Method = jwt.SigningMethodHS256
+		if c.Key == "" {
+			return nil, errors.Errorf("empty token signing key for hmac method")
+		}
+		sd.Key = []byte(c.Key)
+	case "rsa":
+		if c.PrivateKeyPath == "" {
+			return nil, errors.Errorf("token signing private key file for rsa method not defined")
+		}
+		if c.PublicKeyPath == "" {
+			return nil, errors.Errorf("token signing public key file for rsa method not defined")
+		}
+
+		sd.Method = jwt.SigningMethodRS256
+		privateKeyData, err := ioutil.ReadFile(c.PrivateKeyPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading token signing private key")
+		}
+		sd.PrivateKey, err = jwt.ParseRSAPrivateKeyFromPEM(privateKeyData)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing token signing private key")
+		}
+		publicKeyData, err := ioutil.ReadFile(c.PublicKeyPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading token signing public key")
+		}
+		sd.PublicKey, err = jwt.ParseRSAPublicKeyFromPEM(publicKeyData)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing token signing public key")
+		}
+	case "":
+		return nil, errors.Errorf("missing token signing method")
+	default:
+		return nil, errors.Errorf("unknown token signing method: %q", c.Method)
+	}
+
+	return sd, nil
+}
+
 func GenerateGenericJWTToken(sd *TokenSigningData, claims jwt.Claims) (string, error) {
 	token := jwt.NewWithClaims(sd.Method, claims)
 
@@ -68,3 +115,61 @@ func GenerateLoginJWTToken(sd *TokenSigningData, userID string) (string, error)
 		"exp": time.Now().Add(sd.Duration).Unix(),
 	})
 }
+
+// RepoTokenDuration is the duration of the repo tokens. The runservice
+// generates a new token every time a task is sent to an executor so it must
+// only be enough to let the task clone the repository.
+const RepoTokenDuration = 3 * time.Hour
+
+// GenerateRepoJWTToken generates a token that grants read access only to the
+// provided gitserver repository. It's used to let runs clone the repositories
+// of user direct runs.
+// It's limited to a single repository, expires after RepoTokenDuration and
+// contains no subject so it cannot be used to authenticate as an user.
+func GenerateRepoJWTToken(sd *TokenSigningData, repoPath string) (string, error) {
+	now := time.Now()
+	return GenerateGenericJWTToken(sd, jwt.MapClaims{
+		"repo": repoPath,
+		"iat":  now.Unix(),
+		"exp":  now.Add(RepoTokenDuration).Unix(),
+	})
+}
+
+// ParseRepoJWTToken verifies the provided repo token and returns the repository
+// path it grants access to.
+func ParseRepoJWTToken(sd *TokenSigningData, tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != sd.Method {
+			return nil, errors.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		switch sd.Method {
+		case jwt.SigningMethodRS256:
+			return sd.PublicKey, nil
+		case jwt.SigningMethodHS256:
+			return sd.Key, nil
+		default:
+			return nil, errors.Errorf("unsupported signing method %q", sd.Method.Alg())
+		}
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if !token.Valid {
+		return "", errors.Errorf("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", errors.Errorf("invalid token claims")
+	}
+	// tokens without expiration aren't accepted
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return "", errors.Errorf("token without expiration")
+	}
+	repoPath, ok := claims["repo"].(string)
+	if !ok || repoPath == "" {
+		return "", errors.Errorf("token without repo claim")
+	}
+
+	return repoPath, nil
+}
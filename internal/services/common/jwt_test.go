@@ -0,0 +1,68 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestRepoJWTToken(t *testing.T) {
+	sd := &TokenSigningData{Duration: time.Hour, Method: jwt.SigningMethodHS256, Key: []byte("key")}
+	otherSD := &TokenSigningData{Method: jwt.SigningMethodHS256, Key: []byte("otherkey")}
+
+	token, err := GenerateRepoJWTToken(sd, "user01/repo01")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	repoPath, err := ParseRepoJWTToken(sd, token)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if repoPath != "user01/repo01" {
+		t.Fatalf("got repo path %q, want %q", repoPath, "user01/repo01")
+	}
+
+	if _, err := ParseRepoJWTToken(otherSD, token); err == nil {
+		t.Fatalf("expected error for token signed with another key")
+	}
+
+	loginToken, err := GenerateLoginJWTToken(sd, "user01")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := ParseRepoJWTToken(sd, loginToken); err == nil {
+		t.Fatalf("expected error for login token")
+	}
+
+	noExpToken, err := GenerateGenericJWTToken(sd, jwt.MapClaims{"repo": "user01/repo01"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := ParseRepoJWTToken(sd, noExpToken); err == nil {
+		t.Fatalf("expected error for token without expiration")
+	}
+
+	expiredToken, err := GenerateGenericJWTToken(sd, jwt.MapClaims{"repo": "user01/repo01", "exp": time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := ParseRepoJWTToken(sd, expiredToken); err == nil {
+		t.Fatalf("expected error for expired token")
+	}
+}
@@ -17,6 +17,7 @@ package common
 import (
 	"net/url"
 	"path"
+	"time"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/services/types"
@@ -36,8 +37,19 @@ const (
 	GroupTypePullRequest GroupType = "pr"
 
 	ApproversAnnotation = "approvers"
+	// ApprovalsAnnotation contains the details of the run task approvals
+	ApprovalsAnnotation = "approvals"
 )
 
+// RunTaskApproval is a run task approval saved in the run task approvals
+// annotation
+type RunTaskApproval struct {
+	UserID   string    `json:"user_id"`
+	UserName string    `json:"user_name"`
+	Comment  string    `json:"comment,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
 func WebHookEventToRunRefType(we types.WebhookEvent) types.RunRefType {
 	switch we {
 	case types.WebhookEventPush:
@@ -0,0 +1,317 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/rsa"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/services/config"
+
+	"github.com/golang-jwt/jwt/v4"
+	jwtrequest "github.com/golang-jwt/jwt/v4/request"
+	"github.com/rs/zerolog"
+)
+
+// ServiceName is the identity of an agola service used in the internal
+// services tokens
+type ServiceName string
+
+const (
+	ServiceGateway      ServiceName = "gateway"
+	ServiceScheduler    ServiceName = "scheduler"
+	ServiceNotification ServiceName = "notification"
+	ServiceRunservice   ServiceName = "runservice"
+	ServiceExecutor     ServiceName = "executor"
+	ServiceConfigstore  ServiceName = "configstore"
+	ServiceGitserver    ServiceName = "gitserver"
+)
+
+const (
+	serviceTokenIssuer   = "agola-internal"
+	serviceTokenDuration = 10 * time.Minute
+)
+
+type serviceToken struct {
+	token      string
+	expiration time.Time
+}
+
+// ServiceAuth generates and verifies the signed tokens used by a service to
+// authenticate its calls to the internal services apis. Every token contains
+// the calling service as subject and the called service as audience.
+// Every service signs its tokens with its own private key and the called
+// services verify them with the calling service public key, so a service
+// cannot generate the tokens of another service.
+type ServiceAuth struct {
+	service    ServiceName
+	enabled    bool
+	privateKey *rsa.PrivateKey
+	publicKeys map[ServiceName]*rsa.PublicKey
+
+	mu     sync.Mutex
+	tokens map[ServiceName]*serviceToken
+}
+
+func NewServiceAuth(c *config.InternalServicesAuth, service ServiceName) (*ServiceAuth, error) {
+	a := &ServiceAuth{
+		service:    service,
+		enabled:    c.Enabled,
+		publicKeys: make(map[ServiceName]*rsa.PublicKey),
+		tokens:     make(map[ServiceName]*serviceToken),
+	}
+	if !c.Enabled {
+		return a, nil
+	}
+
+	for name, keys := range c.Services {
+		publicKeyData, err := ioutil.ReadFile(keys.PublicKeyPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading service %q public key", name)
+		}
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(publicKeyData)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing service %q public key", name)
+		}
+		a.publicKeys[ServiceName(name)] = publicKey
+	}
+
+	// services not calling other services don't need a private key
+	if keys, ok := c.Services[string(service)]; ok && keys.PrivateKeyPath != "" {
+		privateKeyData, err := ioutil.ReadFile(keys.PrivateKeyPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading service %q private key", service)
+		}
+		a.privateKey, err = jwt.ParseRSAPrivateKeyFromPEM(privateKeyData)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing service %q private key", service)
+		}
+	}
+
+	return a, nil
+}
+
+// Token returns a token to call the target service. Tokens are reused until
+// half of their duration.
+func (a *ServiceAuth) Token(target ServiceName) (string, error) {
+	if a.privateKey == nil {
+		return "", errors.Errorf("service %q private key not configured", a.service)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if t, ok := a.tokens[target]; ok && now.Add(serviceTokenDuration/2).Before(t.expiration) {
+		return t.token, nil
+	}
+
+	expiration := now.Add(serviceTokenDuration)
+	token, err := GenerateGenericJWTToken(&TokenSigningData{Method: jwt.SigningMethodRS256, PrivateKey: a.privateKey}, &jwt.StandardClaims{
+		Issuer:    serviceTokenIssuer,
+		Subject:   string(a.service),
+		Audience:  string(target),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiration.Unix(),
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	a.tokens[target] = &serviceToken{token: token, expiration: expiration}
+
+	return token, nil
+}
+
+// Verify verifies that the token is signed by the calling service and issued
+// to call this service and returns the calling service.
+func (a *ServiceAuth) Verify(tokenString string) (ServiceName, error) {
+	claims := &jwt.StandardClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, errors.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		// the token is signed by the service in the (not yet verified)
+		// subject, use its public key
+		publicKey, ok := a.publicKeys[ServiceName(claims.Subject)]
+		if !ok {
+			return nil, errors.Errorf("unknown service %q", claims.Subject)
+		}
+		return publicKey, nil
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if !token.Valid {
+		return "", errors.Errorf("invalid token")
+	}
+	if claims.Issuer != serviceTokenIssuer {
+		return "", errors.Errorf("wrong token issuer %q", claims.Issuer)
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return "", errors.Errorf("token without expiration")
+	}
+	if !claims.VerifyAudience(string(a.service), true) {
+		return "", errors.Errorf("token audience %q doesn't match service %q", claims.Audience, a.service)
+	}
+	if claims.Subject == "" {
+		return "", errors.Errorf("token without subject")
+	}
+
+	return ServiceName(claims.Subject), nil
+}
+
+type serviceAuthTransport struct {
+	a      *ServiceAuth
+	target ServiceName
+	base   http.RoundTripper
+}
+
+func (t *serviceAuthTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := t.a.Token(t.target)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// the RoundTripper must not modify the request
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	return t.base.RoundTrip(r)
+}
+
+// HTTPClient returns an http client that authenticates the requests to the
+// target service. When the internal services authentication is disabled it
+// returns an http client without authentication.
+func (a *ServiceAuth) HTTPClient(target ServiceName) *http.Client {
+	if !a.enabled {
+		return &http.Client{}
+	}
+
+	return &http.Client{Transport: &serviceAuthTransport{a: a, target: target, base: http.DefaultTransport}}
+}
+
+// ServiceAuthorization allows the calls to the api paths matching Path with
+// one of Methods (all the methods when empty). Path segments equal to "*"
+// match any segment and, when Prefix is true, also the paths below Path are
+// matched.
+type ServiceAuthorization struct {
+	Methods []string
+	Path    string
+	Prefix  bool
+}
+
+func (sa ServiceAuthorization) allowed(method, p string) bool {
+	if len(sa.Methods) > 0 {
+		found := false
+		for _, m := range sa.Methods {
+			if m == method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	saSegments := strings.Split(strings.Trim(sa.Path, "/"), "/")
+	if len(segments) < len(saSegments) {
+		return false
+	}
+	if len(segments) > len(saSegments) && !sa.Prefix {
+		return false
+	}
+	for i, s := range saSegments {
+		if s != "*" && s != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ServiceAuthorizations defines, for every service allowed to call a service
+// api, the api calls it can do. A service without authorizations can do all
+// the api calls.
+type ServiceAuthorizations map[ServiceName][]ServiceAuthorization
+
+func (sa ServiceAuthorizations) allowed(service ServiceName, method, path string) bool {
+	authorizations, ok := sa[service]
+	if !ok {
+		return false
+	}
+	if len(authorizations) == 0 {
+		return true
+	}
+	for _, a := range authorizations {
+		if a.allowed(method, path) {
+			return true
+		}
+	}
+	return false
+}
+
+type serviceAuthHandler struct {
+	log            zerolog.Logger
+	a              *ServiceAuth
+	authorizations ServiceAuthorizations
+	next           http.Handler
+}
+
+// NewServiceAuthHandler returns an handler that, when the internal services
+// authentication is enabled, rejects the requests without a valid service
+// token or from a service not authorized to do the requested call.
+func (a *ServiceAuth) NewServiceAuthHandler(log zerolog.Logger, authorizations ServiceAuthorizations, next http.Handler) http.Handler {
+	if !a.enabled {
+		return next
+	}
+
+	return &serviceAuthHandler{
+		log:            log,
+		a:              a,
+		authorizations: authorizations,
+		next:           next,
+	}
+}
+
+func (h *serviceAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tokenString, err := jwtrequest.AuthorizationHeaderExtractor.ExtractToken(r)
+	if err != nil {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	service, err := h.a.Verify(tokenString)
+	if err != nil {
+		h.log.Warn().Err(err).Msgf("invalid service token")
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	if !h.authorizations.allowed(service, r.Method, path.Clean(r.URL.Path)) {
+		h.log.Warn().Msgf("service %q not authorized to call %s %s", service, r.Method, r.URL.Path)
+		http.Error(w, "", http.StatusForbidden)
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
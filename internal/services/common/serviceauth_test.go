@@ -0,0 +1,210 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"agola.io/agola/internal/services/config"
+
+	"github.com/rs/zerolog"
+)
+
+func writeServiceKeys(t *testing.T, dir string, service ServiceName) config.InternalServiceAuthKeys {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	publicKeyData, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	keys := config.InternalServiceAuthKeys{
+		PrivateKeyPath: filepath.Join(dir, string(service)+".key"),
+		PublicKeyPath:  filepath.Join(dir, string(service)+".pub"),
+	}
+	if err := ioutil.WriteFile(keys.PrivateKeyPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := ioutil.WriteFile(keys.PublicKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyData}), 0644); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	return keys
+}
+
+// serviceAuthConfigs returns, for every service, a config containing only
+// its private key and the public keys of all the services like it's done in
+// a deployment where every service only knows its own private key
+func serviceAuthConfigs(t *testing.T, services ...ServiceName) map[ServiceName]*config.InternalServicesAuth {
+	dir := t.TempDir()
+
+	keys := map[string]config.InternalServiceAuthKeys{}
+	for _, service := range services {
+		keys[string(service)] = writeServiceKeys(t, dir, service)
+	}
+
+	configs := map[ServiceName]*config.InternalServicesAuth{}
+	for _, service := range services {
+		c := &config.InternalServicesAuth{Enabled: true, Services: map[string]config.InternalServiceAuthKeys{}}
+		for name, k := range keys {
+			if name != string(service) {
+				k.PrivateKeyPath = ""
+			}
+			c.Services[name] = k
+		}
+		configs[service] = c
+	}
+
+	return configs
+}
+
+func TestServiceAuthVerify(t *testing.T) {
+	configs := serviceAuthConfigs(t, ServiceGateway, ServiceExecutor, ServiceRunservice)
+
+	gateway, err := NewServiceAuth(configs[ServiceGateway], ServiceGateway)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	executor, err := NewServiceAuth(configs[ServiceExecutor], ServiceExecutor)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	runservice, err := NewServiceAuth(configs[ServiceRunservice], ServiceRunservice)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	token, err := gateway.Token(ServiceRunservice)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	service, err := runservice.Verify(token)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if service != ServiceGateway {
+		t.Fatalf("got service %q, want %q", service, ServiceGateway)
+	}
+
+	// a token issued for another service must be rejected
+	if _, err := executor.Verify(token); err == nil {
+		t.Fatalf("expected error for token with another audience")
+	}
+
+	// the executor signs with its own key, so it cannot impersonate the gateway
+	executorAsGateway := &ServiceAuth{
+		service:    ServiceGateway,
+		enabled:    true,
+		privateKey: executor.privateKey,
+		tokens:     map[ServiceName]*serviceToken{},
+	}
+	token, err = executorAsGateway.Token(ServiceRunservice)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := runservice.Verify(token); err == nil {
+		t.Fatalf("expected error for token signed by the executor with the gateway subject")
+	}
+
+	// a service without a private key cannot generate tokens
+	if _, err := (&ServiceAuth{service: ServiceGateway, enabled: true}).Token(ServiceRunservice); err == nil {
+		t.Fatalf("expected error generating a token without private key")
+	}
+}
+
+func TestServiceAuthHandler(t *testing.T) {
+	configs := serviceAuthConfigs(t, ServiceGateway, ServiceNotification, ServiceExecutor, ServiceRunservice)
+
+	runservice, err := NewServiceAuth(configs[ServiceRunservice], ServiceRunservice)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	authorizations := ServiceAuthorizations{
+		ServiceGateway: nil,
+		ServiceNotification: {
+			{Methods: []string{"GET"}, Path: "/api/v1alpha/runs/*"},
+			{Methods: []string{"GET"}, Path: "/api/v1alpha/runs/*/testreports"},
+		},
+		ServiceExecutor: {
+			{Path: "/api/v1alpha/executor", Prefix: true},
+		},
+	}
+
+	handler := runservice.NewServiceAuthHandler(zerolog.Nop(), authorizations, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		service ServiceName
+		method  string
+		path    string
+		status  int
+	}{
+		{name: "gateway can call any api", service: ServiceGateway, method: "DELETE", path: "/api/v1alpha/runs/run01", status: http.StatusOK},
+		{name: "notification can get a run", service: ServiceNotification, method: "GET", path: "/api/v1alpha/runs/run01", status: http.StatusOK},
+		{name: "notification can get the run events", service: ServiceNotification, method: "GET", path: "/api/v1alpha/runs/events", status: http.StatusOK},
+		{name: "notification can get a run test reports", service: ServiceNotification, method: "GET", path: "/api/v1alpha/runs/run01/testreports", status: http.StatusOK},
+		{name: "notification cannot change a run", service: ServiceNotification, method: "PUT", path: "/api/v1alpha/runs/run01/actions", status: http.StatusForbidden},
+		{name: "notification cannot post a run action", service: ServiceNotification, method: "POST", path: "/api/v1alpha/runs/run01", status: http.StatusForbidden},
+		{name: "notification cannot get the run tasks logs", service: ServiceNotification, method: "GET", path: "/api/v1alpha/runs/run01/tasks/task01/logs", status: http.StatusForbidden},
+		{name: "notification cannot escape the path", service: ServiceNotification, method: "GET", path: "/api/v1alpha/runs/run01/../../executor/archives", status: http.StatusForbidden},
+		{name: "executor can call the executor api", service: ServiceExecutor, method: "PUT", path: "/api/v1alpha/executor/tasks/task01", status: http.StatusOK},
+		{name: "executor cannot call the runs api", service: ServiceExecutor, method: "GET", path: "/api/v1alpha/runs/run01", status: http.StatusForbidden},
+		{name: "executor cannot call a path with the executor prefix", service: ServiceExecutor, method: "GET", path: "/api/v1alpha/executors", status: http.StatusForbidden},
+		{name: "request without token", method: "GET", path: "/api/v1alpha/runs/run01", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{}
+			if tt.service != "" {
+				a, err := NewServiceAuth(configs[tt.service], tt.service)
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				client = a.HTTPClient(ServiceRunservice)
+			}
+
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			// keep the unclean path as is
+			req.URL.RawPath = ""
+			req.URL.Opaque = tt.path
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
@@ -16,13 +16,19 @@ package config
 
 import (
 	"io/ioutil"
+	"regexp"
+	"strings"
 	"time"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/sql"
 	"agola.io/agola/internal/util"
 
+	"github.com/bmatcuk/doublestar"
+	ghodssyaml "github.com/ghodss/yaml"
 	yaml "gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 const (
@@ -42,6 +48,32 @@ type Config struct {
 	Executor     Executor     `yaml:"executor"`
 	Configstore  Configstore  `yaml:"configstore"`
 	Gitserver    Gitserver    `yaml:"gitserver"`
+	DepsProxy    DepsProxy    `yaml:"depsProxy"`
+
+	InternalServicesAuth InternalServicesAuth `yaml:"internalServicesAuth"`
+}
+
+// InternalServicesAuth configures the authentication of the calls between the
+// agola services. When enabled, every service signs its calls to the internal
+// services (runservice, configstore, executor and gitserver) with a token
+// containing its identity and the internal services only accept the calls
+// from the services authorized to use their apis.
+type InternalServicesAuth struct {
+	Enabled bool `yaml:"enabled"`
+	// Services are the keys of the agola services by service name (gateway,
+	// scheduler, notification, runservice, executor, configstore, gitserver)
+	Services map[string]InternalServiceAuthKeys `yaml:"services"`
+}
+
+// InternalServiceAuthKeys are the rsa keys of a service. A service signs its
+// tokens with its private key and the called services verify them with its
+// public key, so a service cannot generate the tokens of another service.
+type InternalServiceAuthKeys struct {
+	// path to a file containing the service pem encoded private key. It must
+	// be provided only to the instances running the service
+	PrivateKeyPath string `yaml:"privateKeyPath"`
+	// path to a file containing the service pem encoded public key
+	PublicKeyPath string `yaml:"publicKeyPath"`
 }
 
 type Gateway struct {
@@ -61,15 +93,56 @@ type Gateway struct {
 	Web           Web           `yaml:"web"`
 	ObjectStorage ObjectStorage `yaml:"objectStorage"`
 
+	// TokenSigning is also used by the runservice to sign the tokens
+	// granting the run tasks read access to the gitserver repositories
 	TokenSigning TokenSigning `yaml:"tokenSigning"`
 
 	AdminToken string `yaml:"adminToken"`
+
+	// ProjectDeletionGracePeriod is the time a deleted project can be
+	// restored before it's permanently deleted with its runs and caches
+	ProjectDeletionGracePeriod time.Duration `yaml:"projectDeletionGracePeriod"`
+
+	// WebhookQueue configures the queue of the received webhooks
+	WebhookQueue WebhookQueue `yaml:"webhookQueue"`
+
+	// ConfigEnv is the list of the gateway environment variables that jsonnet
+	// run configs can read using the env native function
+	ConfigEnv []string `yaml:"configEnv"`
+
+	// DepsProxyURL is the dependencies proxy url reachable by the tasks. When
+	// set, the runs of the projects using the dependencies proxy will have
+	// their package managers configured to use it
+	DepsProxyURL string `yaml:"depsProxyURL"`
+
+	// SwaggerUI enables serving a Swagger UI showing the gateway api OpenAPI
+	// specification at /api/v1alpha/swaggerui
+	SwaggerUI bool `yaml:"swaggerUI"`
+
+	// DB is used to elect the gateway instance executing the background jobs
+	// (like the scheduled runs creation) when running multiple gateways. Only
+	// a postgres db provides a lock shared between instances.
+	DB DB `yaml:"db"`
 }
 
 type Scheduler struct {
 	Debug bool `yaml:"debug"`
 
 	RunserviceURL string `yaml:"runserviceURL"`
+
+	// ConfigstoreURL and GatewayURL are used to poll the projects using a
+	// plain git remote source. Polling is disabled when they aren't defined.
+	ConfigstoreURL string `yaml:"configstoreURL"`
+	GatewayURL     string `yaml:"gatewayURL"`
+
+	// GitPollInterval is the interval between polls of projects using a plain
+	// git remote source
+	GitPollInterval time.Duration `yaml:"gitPollInterval"`
+
+	// DB is used to elect the scheduler instance polling the projects when
+	// running multiple schedulers. Only a postgres db provides a lock shared
+	// between instances.
+	DB DB `yaml:"db"`
 }
 
 type Notification struct {
@@ -96,8 +169,99 @@ type Runservice struct {
 
 	ObjectStorage ObjectStorage `yaml:"objectStorage"`
 
+	// RunCacheExpireInterval is deprecated, use Cache.ExpireInterval
 	RunCacheExpireInterval     time.Duration `yaml:"runCacheExpireInterval"`
 	RunWorkspaceExpireInterval time.Duration `yaml:"runWorkspaceExpireInterval"`
+
+	// Cache defines the tasks caches retention
+	Cache RunCache `yaml:"cache"`
+
+	// Limits are the installation wide defaults and limits applied to all the
+	// runs
+	Limits RunLimits `yaml:"limits"`
+
+	// SealedSecretsKeyFile is the file containing the base64 encoded 32 bytes
+	// private key used to decrypt the secrets sealed client side. When empty
+	// sealed secrets aren't supported
+	SealedSecretsKeyFile string `yaml:"sealedSecretsKeyFile"`
+
+	// Provisioner is the executors provisioner periodically called with the
+	// pending tasks demand to scale the executors capacity
+	Provisioner Provisioner `yaml:"provisioner"`
+
+	// ExecutorsApproval requires new executors to be approved by an admin
+	// before executing tasks. New executors must also provide a registration
+	// token
+	ExecutorsApproval bool `yaml:"executorsApproval"`
+
+	// ExecutorsHealth defines when an executor is unhealthy. No tasks are
+	// scheduled on unhealthy executors
+	ExecutorsHealth ExecutorsHealth `yaml:"executorsHealth"`
+}
+
+// ExecutorsHealth defines the thresholds applied to the health reported by
+// the executors
+type ExecutorsHealth struct {
+	// MinDiskFreePercent is the min percentage of free space of the
+	// executor data dir filesystem (defaults to 5). 0 disables the check
+	MinDiskFreePercent int `yaml:"minDiskFreePercent"`
+	// MaxLoadPerCPU is the max 1 minute load average per cpu of the executor
+	// host. 0 disables the check
+	MaxLoadPerCPU float64 `yaml:"maxLoadPerCPU"`
+}
+
+type ProvisionerType string
+
+const (
+	// ProvisionerTypeWebhook calls an external provisioner (i.e. an
+	// autoscaler) posting to it the tasks demand and the current executors
+	ProvisionerTypeWebhook ProvisionerType = "webhook"
+)
+
+// Provisioner configures the executors provisioner. When Type is empty no
+// provisioner is used.
+type Provisioner struct {
+	Type ProvisionerType `yaml:"type"`
+	// URL is the webhook provisioner url
+	URL string `yaml:"url"`
+	// Interval is the interval between the provisioner calls
+	Interval time.Duration `yaml:"interval"`
+}
+
+// RunCache defines the tasks caches retention. The caches are accounted by
+// cache group (a project or a user direct runs repository). 0 means no
+// expiration or no quota.
+type RunCache struct {
+	// ExpireInterval is the max time since the last use of a cache, after it
+	// the cache is removed
+	ExpireInterval time.Duration `yaml:"expireInterval"`
+	// GroupQuota is the max size in bytes of the caches of a cache group. When
+	// exceeded the least recently used caches are removed
+	GroupQuota int64 `yaml:"groupQuota"`
+}
+
+// RunLimits defines the defaults and the max values applied to the run
+// configs when creating a run. Values provided by the run configs are capped
+// to the max values. 0 means no default or no limit.
+type RunLimits struct {
+	// DefaultTaskTimeout is the timeout of the tasks that don't define one
+	DefaultTaskTimeout time.Duration `yaml:"defaultTaskTimeout"`
+	// MaxTaskTimeout is the max task timeout
+	MaxTaskTimeout time.Duration `yaml:"maxTaskTimeout"`
+	// MaxRunTimeout is the max duration of a run, after it the run is stopped
+	MaxRunTimeout time.Duration `yaml:"maxRunTimeout"`
+	// MaxStepLogSize is the max size in bytes of a step log. Logs exceeding it
+	// are truncated
+	MaxStepLogSize int64 `yaml:"maxStepLogSize"`
+	// MaxCacheSize is the max size in bytes of a cache archive. Bigger caches
+	// are rejected
+	MaxCacheSize int64 `yaml:"maxCacheSize"`
+	// MaxArtifactsSize is the max size in bytes of the artifacts archive of a
+	// task. Bigger archives are rejected
+	MaxArtifactsSize int64 `yaml:"maxArtifactsSize"`
+	// MaxTestReportsSize is the max size in bytes of the test reports archive
+	// of a task. Bigger archives are rejected
+	MaxTestReportsSize int64 `yaml:"maxTestReportsSize"`
 }
 
 type Executor struct {
@@ -114,11 +278,96 @@ type Executor struct {
 
 	InitImage InitImage `yaml:"initImage"`
 
+	// Labels are custom executor labels that can be used by tasks to select
+	// the executor. The labels automatically detected by the executor (os,
+	// arch, driver etc...) will override the ones defined here
 	Labels map[string]string `yaml:"labels"`
 	// ActiveTasksLimit is the max number of concurrent active tasks
 	ActiveTasksLimit int `yaml:"activeTasksLimit"`
+	// LabelsActiveTasksLimits are the max number of concurrent active tasks
+	// having all the provided task labels (i.e. only one task with label
+	// type: deploy)
+	LabelsActiveTasksLimits []LabelsActiveTasksLimit `yaml:"labelsActiveTasksLimits"`
 
+	// AllowPrivilegedContainers allows the execution of privileged containers
+	// and of containers with added capabilities or host devices
 	AllowPrivilegedContainers bool `yaml:"allowPrivilegedContainers"`
+	// PrivilegedContainersProjects restricts the privileged containers to the
+	// runs of the provided project ids. When empty the runs of every project
+	// (and user direct runs) can use them
+	PrivilegedContainersProjects []string `yaml:"privilegedContainersProjects"`
+
+	// TransferBandwidthLimits limits the bandwidth used to transfer the
+	// workspace archives and the caches from/to the runservice
+	TransferBandwidthLimits TransferBandwidthLimits `yaml:"transferBandwidthLimits"`
+
+	// DefaultContainerResources are the resources assigned to the task
+	// containers that don't define them. Currently used only by the k8s driver
+	DefaultContainerResources ContainerResources `yaml:"defaultContainerResources"`
+	// MaxContainerResources are the max resources a task container can
+	// define. Tasks exceeding them will fail. Currently used only by the k8s
+	// driver
+	MaxContainerResources ContainerResources `yaml:"maxContainerResources"`
+
+	// DefaultPullPolicy is the image pull policy of the task containers that
+	// don't define it. Defaults to always
+	DefaultPullPolicy PullPolicy `yaml:"defaultPullPolicy"`
+
+	// GPUs is the number of nvidia GPUs available to the tasks. Tasks
+	// requiring GPUs are scheduled only on executors providing them. Currently
+	// used only by the docker and k8s drivers
+	GPUs int `yaml:"gpus"`
+
+	// ImagePolicy restricts the images that can be used by the task
+	// containers. Tasks using a not allowed image will fail
+	ImagePolicy ImagePolicy `yaml:"imagePolicy"`
+}
+
+type LabelsActiveTasksLimit struct {
+	Labels map[string]string `yaml:"labels"`
+	Limit  int               `yaml:"limit"`
+}
+
+type PullPolicy string
+
+const (
+	PullPolicyAlways       PullPolicy = "always"
+	PullPolicyIfNotPresent PullPolicy = "if-not-present"
+	PullPolicyNever        PullPolicy = "never"
+)
+
+// ContainerResources defines the containers cpu and memory requests and
+// limits using the kubernetes quantity format (i.e. cpu: 500m, memory: 1Gi)
+type ContainerResources struct {
+	Requests ContainerResourceList `yaml:"requests"`
+	Limits   ContainerResourceList `yaml:"limits"`
+}
+
+type ContainerResourceList struct {
+	CPU    string `yaml:"cpu"`
+	Memory string `yaml:"memory"`
+}
+
+// TransferBandwidthLimits defines the executor max upload and download
+// bandwidth in bytes per second. The limits are shared by all the executor
+// tasks. 0 means no limit.
+type ImagePolicy struct {
+	// AllowedRegistries are the registries the images can be pulled from.
+	// The docker hub registry is index.docker.io. When empty every registry
+	// is allowed
+	AllowedRegistries []string `yaml:"allowedRegistries"`
+	// AllowedImages are the patterns the images must match. A pattern is a
+	// glob (i.e. "golang:*" or "registry.example.com/**") or, when enclosed in
+	// slashes, a regular expression. When empty every image is allowed
+	AllowedImages []string `yaml:"allowedImages"`
+	// DeniedImages are the patterns of the images that cannot be used. They
+	// take precedence over the allowed ones
+	DeniedImages []string `yaml:"deniedImages"`
+}
+
+type TransferBandwidthLimits struct {
+	Upload   int64 `yaml:"upload"`
+	Download int64 `yaml:"download"`
 }
 
 type InitImage struct {
@@ -170,9 +419,39 @@ type Gitserver struct {
 	RepositoryRefsExpireInterval time.Duration `yaml:"repositoryRefsExpireInterval"`
 }
 
+// DepsProxy is a read-through caching proxy of the common package registries
+// (go modules proxy, npm, pypi). The immutable artifacts are saved in the
+// object storage so they're downloaded from the upstream registries only once
+type DepsProxy struct {
+	Debug bool `yaml:"debug"`
+
+	Web           Web           `yaml:"web"`
+	ObjectStorage ObjectStorage `yaml:"objectStorage"`
+
+	// ExposedURL is the dependencies proxy url reachable by the tasks. It's
+	// used to rewrite the artifacts urls in the registries metadata
+	ExposedURL string `yaml:"exposedURL"`
+
+	Upstreams DepsProxyUpstreams `yaml:"upstreams"`
+}
+
+// DepsProxyUpstreams are the upstream registries urls
+type DepsProxyUpstreams struct {
+	GoModules string `yaml:"goModules"`
+	Npm       string `yaml:"npm"`
+	PyPI      string `yaml:"pypi"`
+	// PyPIFiles is the url of the server hosting the pypi packages files
+	PyPIFiles string `yaml:"pypiFiles"`
+}
+
 type Web struct {
 	// http listen addess
 	ListenAddress string `yaml:"listenAddress"`
+	// additional http listen addresses. IPv6 addresses must be enclosed in
+	// square brackets (i.e. [::1]:8000). An empty host or an IPv6 wildcard
+	// address ([::]:8000), when it's the only address, listens on both IPv4
+	// and IPv6
+	ListenAddresses []string `yaml:"listenAddresses"`
 
 	// use TLS (https)
 	TLS bool `yaml:"tls"`
@@ -189,6 +468,20 @@ type Web struct {
 	AllowedOrigins []string `yaml:"allowedOrigins"`
 }
 
+// Addresses returns all the configured http listen addresses
+func (w *Web) Addresses() []string {
+	addresses := []string{}
+	if w.ListenAddress != "" {
+		addresses = append(addresses, w.ListenAddress)
+	}
+	for _, addr := range w.ListenAddresses {
+		if addr != w.ListenAddress {
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses
+}
+
 type DB struct {
 	Type       sql.Type `yaml:"type"`
 	ConnString string   `yaml:"connString"`
@@ -221,6 +514,13 @@ type DriverType string
 const (
 	DriverTypeDocker DriverType = "docker"
 	DriverTypeK8s    DriverType = "kubernetes"
+	DriverTypeLXD    DriverType = "lxd"
+
+	DriverTypeFirecracker DriverType = "firecracker"
+	// DriverTypeHost executes the tasks directly on the executor host without
+	// any isolation. Only tasks with the host runtime type will be scheduled
+	// on executors using it
+	DriverTypeHost DriverType = "host"
 )
 
 type Driver struct {
@@ -229,7 +529,79 @@ type Driver struct {
 	// docker fields
 
 	// k8s fields
+	K8s K8s `yaml:"k8s"`
+
+	// firecracker fields
+	Firecracker Firecracker `yaml:"firecracker"`
+}
+
+// K8s defines the customizations of the task pods created by the k8s driver
+type K8s struct {
+	// NodeSelector is merged with the node selector of every task pod. Its
+	// labels override the ones defined by the task
+	NodeSelector map[string]string `yaml:"nodeSelector"`
+	// Tolerations are added to the tolerations of every task pod
+	Tolerations []K8sToleration `yaml:"tolerations"`
+	// Affinity is the yaml (or json) encoded k8s affinity of the task pods
+	Affinity string `yaml:"affinity"`
+	// ServiceAccount is the service account of the task pods. Its token isn't
+	// mounted inside the pods
+	ServiceAccount string `yaml:"serviceAccount"`
+	// PodTemplatePatch is a yaml (or json) encoded strategic merge patch
+	// applied to every task pod before its creation
+	PodTemplatePatch string `yaml:"podTemplatePatch"`
+}
+
+type K8sToleration struct {
+	Key string `yaml:"key"`
+	// Operator is Exists or Equal. Defaults to Equal
+	Operator string `yaml:"operator"`
+	Value    string `yaml:"value"`
+	// Effect is NoSchedule, PreferNoSchedule or NoExecute. When empty all
+	// the taint effects are tolerated
+	Effect string `yaml:"effect"`
+	// TolerationSeconds is the time a pod with effect NoExecute stays bound
+	// to the tainted node
+	TolerationSeconds *int64 `yaml:"tolerationSeconds"`
+}
+
+// Firecracker defines the firecracker driver configuration. Every task is
+// executed inside a microVM booted from a copy of the provided rootfs image.
+// The rootfs image must start the "agola-toolbox agent" command at boot.
+type Firecracker struct {
+	// BinaryPath is the firecracker executable path (defaults to the
+	// firecracker executable found in PATH)
+	BinaryPath string `yaml:"binaryPath"`
+	// KernelImage is the uncompressed guest kernel image path
+	KernelImage string `yaml:"kernelImage"`
+	// KernelArgs are the guest kernel boot args
+	KernelArgs string `yaml:"kernelArgs"`
+	// RootfsImage is the ext4 rootfs image path
+	RootfsImage string `yaml:"rootfsImage"`
+	// VCPUs is the default number of microVM vcpus (defaults to 1)
+	VCPUs int `yaml:"vcpus"`
+	// MemoryMiB is the default microVM memory size in MiB (defaults to 1024)
+	MemoryMiB int `yaml:"memoryMiB"`
+	// TapDevices are the host tap devices assigned to the microVMs. Every
+	// microVM uses a tap device so they also limit the number of concurrent
+	// tasks. If empty the microVMs will have no network access
+	TapDevices []string `yaml:"tapDevices"`
+}
 
+// WebhookQueue configures the queue where the received webhooks are persisted
+// (in the gateway object storage) before being processed.
+// Webhooks failing with a temporary error (i.e. configstore or git provider
+// unavailable) are retried. Webhooks failing with a permanent error or
+// exceeding the max attempts are moved to the dead letter queue.
+type WebhookQueue struct {
+	// MaxAttempts is the max number of processing attempts of a webhook
+	MaxAttempts int `yaml:"maxAttempts"`
+	// RetryInterval is the base interval between the processing attempts of
+	// a failed webhook. It's multiplied by the number of failed attempts
+	RetryInterval time.Duration `yaml:"retryInterval"`
+	// DeadLetterRetention is how long the webhooks that failed all the
+	// processing attempts are kept before being removed
+	DeadLetterRetention time.Duration `yaml:"deadLetterRetention"`
 }
 
 type TokenSigning struct {
@@ -251,10 +623,27 @@ var defaultConfig = Config{
 		TokenSigning: TokenSigning{
 			Duration: 12 * time.Hour,
 		},
+		ProjectDeletionGracePeriod: 7 * 24 * time.Hour,
+		WebhookQueue: WebhookQueue{
+			MaxAttempts:         5,
+			RetryInterval:       30 * time.Second,
+			DeadLetterRetention: 7 * 24 * time.Hour,
+		},
+	},
+	Scheduler: Scheduler{
+		GitPollInterval: 1 * time.Minute,
 	},
 	Runservice: Runservice{
-		RunCacheExpireInterval:     7 * 24 * time.Hour,
 		RunWorkspaceExpireInterval: 7 * 24 * time.Hour,
+		Cache: RunCache{
+			ExpireInterval: 7 * 24 * time.Hour,
+		},
+		Provisioner: Provisioner{
+			Interval: 30 * time.Second,
+		},
+		ExecutorsHealth: ExecutorsHealth{
+			MinDiskFreePercent: 5,
+		},
 	},
 	Executor: Executor{
 		InitImage: InitImage{
@@ -266,6 +655,14 @@ var defaultConfig = Config{
 		RepositoryCleanupInterval:    24 * time.Hour,
 		RepositoryRefsExpireInterval: 30 * 24 * time.Hour,
 	},
+	DepsProxy: DepsProxy{
+		Upstreams: DepsProxyUpstreams{
+			GoModules: "https://proxy.golang.org",
+			Npm:       "https://registry.npmjs.org",
+			PyPI:      "https://pypi.org",
+			PyPIFiles: "https://files.pythonhosted.org",
+		},
+	},
 }
 
 func Parse(configFile string, componentsNames []string) (*Config, error) {
@@ -274,12 +671,18 @@ func Parse(configFile string, componentsNames []string) (*Config, error) {
 		return nil, errors.WithStack(err)
 	}
 
-	c := &defaultConfig
+	// copy the default config to not change it when unmarshaling
+	c := defaultConfig
 	if err := yaml.Unmarshal(configData, &c); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	return c, Validate(c, componentsNames)
+	// keep supporting the deprecated runservice cache expire interval
+	if c.Runservice.RunCacheExpireInterval != 0 {
+		c.Runservice.Cache.ExpireInterval = c.Runservice.RunCacheExpireInterval
+	}
+
+	return &c, Validate(&c, componentsNames)
 }
 
 func validateDB(db *DB) error {
@@ -301,9 +704,15 @@ func validateDB(db *DB) error {
 }
 
 func validateWeb(w *Web) error {
-	if w.ListenAddress == "" {
+	addresses := w.Addresses()
+	if len(addresses) == 0 {
 		return errors.Errorf("listen address undefined")
 	}
+	for _, addr := range addresses {
+		if err := util.ValidateListenAddress(addr); err != nil {
+			return errors.WithStack(err)
+		}
+	}
 
 	if w.TLS {
 		if w.TLSKeyFile == "" {
@@ -317,6 +726,50 @@ func validateWeb(w *Web) error {
 	return nil
 }
 
+func validateContainerResources(r *ContainerResources) error {
+	for _, rl := range []*ContainerResourceList{&r.Requests, &r.Limits} {
+		for _, v := range []string{rl.CPU, rl.Memory} {
+			if v == "" {
+				continue
+			}
+			q, err := resource.ParseQuantity(v)
+			if err != nil {
+				return errors.Wrapf(err, "invalid quantity %q", v)
+			}
+			if q.Sign() < 0 {
+				return errors.Errorf("negative quantity %q", v)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateImagePolicy(p *ImagePolicy) error {
+	for _, r := range p.AllowedRegistries {
+		if r == "" {
+			return errors.Errorf("empty allowed registry")
+		}
+	}
+	for _, pattern := range append(append([]string{}, p.AllowedImages...), p.DeniedImages...) {
+		if pattern == "" {
+			return errors.Errorf("empty image pattern")
+		}
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			if _, err := regexp.Compile(pattern[1 : len(pattern)-1]); err != nil {
+				return errors.Wrapf(err, "invalid image pattern %q", pattern)
+			}
+			continue
+		}
+		// matching the pattern against itself reports syntax errors
+		if _, err := doublestar.Match(pattern, pattern); err != nil {
+			return errors.Wrapf(err, "invalid image pattern %q", pattern)
+		}
+	}
+
+	return nil
+}
+
 func validateInitImage(i *InitImage) error {
 	if i.Image == "" {
 		return errors.Errorf("image is empty")
@@ -325,6 +778,88 @@ func validateInitImage(i *InitImage) error {
 	return nil
 }
 
+func validateRunLimits(l *RunLimits) error {
+	if l.DefaultTaskTimeout < 0 || l.MaxTaskTimeout < 0 || l.MaxRunTimeout < 0 || l.MaxStepLogSize < 0 || l.MaxCacheSize < 0 || l.MaxArtifactsSize < 0 || l.MaxTestReportsSize < 0 {
+		return errors.Errorf("limits must be positive")
+	}
+	if l.MaxTaskTimeout > 0 && l.DefaultTaskTimeout > l.MaxTaskTimeout {
+		return errors.Errorf("defaultTaskTimeout %s greater than maxTaskTimeout %s", l.DefaultTaskTimeout, l.MaxTaskTimeout)
+	}
+
+	return nil
+}
+
+func validateProvisioner(p *Provisioner) error {
+	switch p.Type {
+	case "":
+		return nil
+	case ProvisionerTypeWebhook:
+		if p.URL == "" {
+			return errors.Errorf("webhook provisioner url is empty")
+		}
+	default:
+		return errors.Errorf("unknown provisioner type %q", p.Type)
+	}
+	if p.Interval <= 0 {
+		return errors.Errorf("interval must be greater than 0")
+	}
+
+	return nil
+}
+
+func validateK8s(c *K8s) error {
+	for i, t := range c.Tolerations {
+		switch t.Operator {
+		case "", "Equal":
+			if t.Key == "" {
+				return errors.Errorf("toleration %d: key must be defined with operator Equal", i)
+			}
+		case "Exists":
+			if t.Value != "" {
+				return errors.Errorf("toleration %d: value cannot be defined with operator Exists", i)
+			}
+		default:
+			return errors.Errorf("toleration %d: unknown operator %q", i, t.Operator)
+		}
+		switch t.Effect {
+		case "", "NoSchedule", "PreferNoSchedule", "NoExecute":
+		default:
+			return errors.Errorf("toleration %d: unknown effect %q", i, t.Effect)
+		}
+	}
+	if c.Affinity != "" {
+		var affinity corev1.Affinity
+		if err := ghodssyaml.Unmarshal([]byte(c.Affinity), &affinity); err != nil {
+			return errors.Wrapf(err, "invalid affinity")
+		}
+	}
+	if c.PodTemplatePatch != "" {
+		var patch map[string]interface{}
+		if err := ghodssyaml.Unmarshal([]byte(c.PodTemplatePatch), &patch); err != nil {
+			return errors.Wrapf(err, "invalid podTemplatePatch")
+		}
+	}
+
+	return nil
+}
+
+func validateFirecracker(c *Firecracker) error {
+	if c.KernelImage == "" {
+		return errors.Errorf("kernelImage is empty")
+	}
+	if c.RootfsImage == "" {
+		return errors.Errorf("rootfsImage is empty")
+	}
+	if c.VCPUs < 0 {
+		return errors.Errorf("vcpus must be positive")
+	}
+	if c.MemoryMiB < 0 {
+		return errors.Errorf("memoryMiB must be positive")
+	}
+
+	return nil
+}
+
 func Validate(c *Config, componentsNames []string) error {
 	// Global
 	if len(c.ID) > maxIDLength {
@@ -333,6 +868,19 @@ func Validate(c *Config, componentsNames []string) error {
 	if !util.ValidateName(c.ID) {
 		return errors.Errorf("invalid id")
 	}
+	if c.InternalServicesAuth.Enabled {
+		for name, keys := range c.InternalServicesAuth.Services {
+			if keys.PublicKeyPath == "" {
+				return errors.Errorf("internalServicesAuth service %q public key file not defined", name)
+			}
+		}
+		// the services calling other services need their private key
+		for _, name := range []string{"gateway", "scheduler", "notification", "runservice", "executor"} {
+			if isComponentEnabled(componentsNames, name) && c.InternalServicesAuth.Services[name].PrivateKeyPath == "" {
+				return errors.Errorf("internalServicesAuth service %q private key file not defined", name)
+			}
+		}
+	}
 
 	// Gateway
 	if isComponentEnabled(componentsNames, "gateway") {
@@ -351,6 +899,18 @@ func Validate(c *Config, componentsNames []string) error {
 		if err := validateWeb(&c.Gateway.Web); err != nil {
 			return errors.Wrapf(err, "gateway web configuration error")
 		}
+		if c.Gateway.ProjectDeletionGracePeriod < 0 {
+			return errors.Errorf("gateway projectDeletionGracePeriod must be greater or equal than 0")
+		}
+		if c.Gateway.WebhookQueue.MaxAttempts < 1 {
+			return errors.Errorf("gateway webhookQueue maxAttempts must be greater than 0")
+		}
+		if c.Gateway.WebhookQueue.RetryInterval <= 0 {
+			return errors.Errorf("gateway webhookQueue retryInterval must be greater than 0")
+		}
+		if c.Gateway.WebhookQueue.DeadLetterRetention <= 0 {
+			return errors.Errorf("gateway webhookQueue deadLetterRetention must be greater than 0")
+		}
 	}
 
 	// Configstore
@@ -377,6 +937,18 @@ func Validate(c *Config, componentsNames []string) error {
 		if err := validateWeb(&c.Runservice.Web); err != nil {
 			return errors.Wrapf(err, "runservice web configuration error")
 		}
+		if err := validateRunLimits(&c.Runservice.Limits); err != nil {
+			return errors.Wrapf(err, "runservice limits configuration error")
+		}
+		if c.Runservice.Cache.ExpireInterval < 0 || c.Runservice.Cache.GroupQuota < 0 {
+			return errors.Errorf("runservice cache configuration error: values must be positive")
+		}
+		if err := validateProvisioner(&c.Runservice.Provisioner); err != nil {
+			return errors.Wrapf(err, "runservice provisioner configuration error")
+		}
+		if h := c.Runservice.ExecutorsHealth; h.MinDiskFreePercent < 0 || h.MinDiskFreePercent > 100 || h.MaxLoadPerCPU < 0 {
+			return errors.Errorf("runservice executorsHealth configuration error: minDiskFreePercent must be between 0 and 100 and maxLoadPerCPU must be positive")
+		}
 	}
 
 	// Executor
@@ -396,6 +968,15 @@ func Validate(c *Config, componentsNames []string) error {
 		switch c.Executor.Driver.Type {
 		case DriverTypeDocker:
 		case DriverTypeK8s:
+			if err := validateK8s(&c.Executor.Driver.K8s); err != nil {
+				return errors.Wrapf(err, "executor k8s driver configuration error")
+			}
+		case DriverTypeLXD:
+		case DriverTypeFirecracker:
+			if err := validateFirecracker(&c.Executor.Driver.Firecracker); err != nil {
+				return errors.Wrapf(err, "executor firecracker driver configuration error")
+			}
+		case DriverTypeHost:
 		default:
 			return errors.Errorf("executor driver type %q unknown", c.Executor.Driver.Type)
 		}
@@ -403,6 +984,47 @@ func Validate(c *Config, componentsNames []string) error {
 		if err := validateInitImage(&c.Executor.InitImage); err != nil {
 			return errors.Wrapf(err, "executor initImage configuration error")
 		}
+
+		if c.Executor.GPUs < 0 {
+			return errors.Errorf("executor gpus must be positive")
+		}
+		switch c.Executor.Driver.Type {
+		case DriverTypeDocker, DriverTypeK8s:
+		default:
+			if c.Executor.GPUs > 0 {
+				return errors.Errorf("executor gpus aren't supported by driver type %q", c.Executor.Driver.Type)
+			}
+		}
+
+		if c.Executor.TransferBandwidthLimits.Upload < 0 || c.Executor.TransferBandwidthLimits.Download < 0 {
+			return errors.Errorf("executor transferBandwidthLimits must be positive")
+		}
+
+		if err := validateContainerResources(&c.Executor.DefaultContainerResources); err != nil {
+			return errors.Wrapf(err, "executor defaultContainerResources configuration error")
+		}
+		if err := validateContainerResources(&c.Executor.MaxContainerResources); err != nil {
+			return errors.Wrapf(err, "executor maxContainerResources configuration error")
+		}
+
+		switch c.Executor.DefaultPullPolicy {
+		case "", PullPolicyAlways, PullPolicyIfNotPresent, PullPolicyNever:
+		default:
+			return errors.Errorf("executor defaultPullPolicy %q unknown", c.Executor.DefaultPullPolicy)
+		}
+
+		for i, l := range c.Executor.LabelsActiveTasksLimits {
+			if len(l.Labels) == 0 {
+				return errors.Errorf("executor labelsActiveTasksLimits %d: no labels defined", i)
+			}
+			if l.Limit < 1 {
+				return errors.Errorf("executor labelsActiveTasksLimits %d: limit must be greater than 0", i)
+			}
+		}
+
+		if err := validateImagePolicy(&c.Executor.ImagePolicy); err != nil {
+			return errors.Wrapf(err, "executor imagePolicy configuration error")
+		}
 	}
 
 	// Scheduler
@@ -410,6 +1032,12 @@ func Validate(c *Config, componentsNames []string) error {
 		if c.Scheduler.RunserviceURL == "" {
 			return errors.Errorf("scheduler runserviceURL is empty")
 		}
+		if (c.Scheduler.ConfigstoreURL == "") != (c.Scheduler.GatewayURL == "") {
+			return errors.Errorf("scheduler configstoreURL and gatewayURL must be both defined to enable git polling")
+		}
+		if c.Scheduler.GitPollInterval <= 0 {
+			return errors.Errorf("scheduler gitPollInterval must be greater than 0")
+		}
 	}
 
 	// Notification
@@ -432,11 +1060,25 @@ func Validate(c *Config, componentsNames []string) error {
 		}
 	}
 
+	// Dependencies proxy
+	if isComponentEnabled(componentsNames, "depsproxy") {
+		if c.DepsProxy.ExposedURL == "" {
+			return errors.Errorf("depsproxy exposedURL is empty")
+		}
+		if err := validateWeb(&c.DepsProxy.Web); err != nil {
+			return errors.Wrapf(err, "depsproxy web configuration error")
+		}
+		u := c.DepsProxy.Upstreams
+		if u.GoModules == "" || u.Npm == "" || u.PyPI == "" || u.PyPIFiles == "" {
+			return errors.Errorf("depsproxy upstreams urls cannot be empty")
+		}
+	}
+
 	return nil
 }
 
 func isComponentEnabled(componentsNames []string, name string) bool {
-	if util.StringInSlice(componentsNames, "all-base") && name != "executor" {
+	if util.StringInSlice(componentsNames, "all-base") && name != "executor" && name != "depsproxy" {
 		return true
 	}
 	return util.StringInSlice(componentsNames, name)
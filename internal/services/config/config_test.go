@@ -220,6 +220,312 @@ gitserver:
   dataDir:`,
 			err: errors.Errorf("git server dataDir is empty"),
 		},
+		{
+			name:     "test config for gateway with multiple listen addresses",
+			services: []string{"gateway"},
+			in: `
+gateway:
+  apiExposedURL: "http://[::1]:8000"
+  webExposedURL: "http://[::1]:8000"
+  runserviceURL: "http://[::1]:4000"
+  configstoreURL: "http://[::1]:4002"
+  gitserverURL: "http://[::1]:4003"
+
+  web:
+    listenAddress: "0.0.0.0:8000"
+    listenAddresses:
+      - "[::]:8000"
+  tokenSigning:
+    method: hmac
+    key: supersecretsigningkey
+  adminToken: "admintoken"`,
+		},
+		{
+			name:     "test config for gateway with ipv6 listen address without square brackets",
+			services: []string{"gateway"},
+			in: `
+gateway:
+  apiExposedURL: "http://[::1]:8000"
+  webExposedURL: "http://[::1]:8000"
+  runserviceURL: "http://[::1]:4000"
+  configstoreURL: "http://[::1]:4002"
+  gitserverURL: "http://[::1]:4003"
+
+  web:
+    listenAddress: "0.0.0.0:8000"
+    listenAddresses:
+      - "::1:8000"
+  tokenSigning:
+    method: hmac
+    key: supersecretsigningkey
+  adminToken: "admintoken"`,
+			err: errors.Errorf(`gateway web configuration error: invalid listen address "::1:8000": address ::1:8000: too many colons in address`),
+		},
+		{
+			name:     "test config for executor with lxd driver",
+			services: []string{"executor"},
+			in: `
+executor:
+  dataDir: /data/agola/executor
+  toolboxPath: ./bin
+  runserviceURL: "http://localhost:4000"
+  web:
+    listenAddress: ":4001"
+  activeTasksLimit: 5
+  driver:
+    type: lxd`,
+		},
+		{
+			name:     "test config for executor with host driver",
+			services: []string{"executor"},
+			in: `
+executor:
+  dataDir: /data/agola/executor
+  toolboxPath: ./bin
+  runserviceURL: "http://localhost:4000"
+  web:
+    listenAddress: ":4001"
+  activeTasksLimit: 1
+  driver:
+    type: host`,
+		},
+		{
+			name:     "test config for executor with firecracker driver",
+			services: []string{"executor"},
+			in: `
+executor:
+  dataDir: /data/agola/executor
+  toolboxPath: ./bin
+  runserviceURL: "http://localhost:4000"
+  web:
+    listenAddress: ":4001"
+  activeTasksLimit: 5
+  driver:
+    type: firecracker
+    firecracker:
+      kernelImage: /var/lib/agola/vmlinux
+      rootfsImage: /var/lib/agola/rootfs.ext4
+      tapDevices:
+        - tap0
+        - tap1`,
+		},
+		{
+			name:     "test config for executor with firecracker driver without rootfs image",
+			services: []string{"executor"},
+			in: `
+executor:
+  dataDir: /data/agola/executor
+  toolboxPath: ./bin
+  runserviceURL: "http://localhost:4000"
+  web:
+    listenAddress: ":4001"
+  activeTasksLimit: 5
+  driver:
+    type: firecracker
+    firecracker:
+      kernelImage: /var/lib/agola/vmlinux
+      rootfsImage: ""`,
+			err: errors.Errorf("executor firecracker driver configuration error: rootfsImage is empty"),
+		},
+		{
+			name:     "test config for executor with negative max container resources",
+			services: []string{"executor"},
+			in: `
+executor:
+  dataDir: /data/agola/executor
+  toolboxPath: ./bin
+  runserviceURL: "http://localhost:4000"
+  web:
+    listenAddress: ":4001"
+  activeTasksLimit: 5
+  driver:
+    type: kubernetes
+  maxContainerResources:
+    limits:
+      memory: -1Gi`,
+			err: errors.Errorf(`executor maxContainerResources configuration error: negative quantity "-1Gi"`),
+		},
+		{
+			name:     "test config for executor with negative transfer bandwidth limit",
+			services: []string{"executor"},
+			in: `
+executor:
+  dataDir: /data/agola/executor
+  toolboxPath: ./bin
+  runserviceURL: "http://localhost:4000"
+  web:
+    listenAddress: ":4001"
+  activeTasksLimit: 5
+  driver:
+    type: docker
+  transferBandwidthLimits:
+    upload: -1`,
+			err: errors.Errorf("executor transferBandwidthLimits must be positive"),
+		},
+		{
+			name:     "test config for executor with unknown default pull policy",
+			services: []string{"executor"},
+			in: `
+executor:
+  dataDir: /data/agola/executor
+  toolboxPath: ./bin
+  runserviceURL: "http://localhost:4000"
+  web:
+    listenAddress: ":4001"
+  activeTasksLimit: 5
+  driver:
+    type: docker
+  defaultPullPolicy: sometimes`,
+			err: errors.Errorf(`executor defaultPullPolicy "sometimes" unknown`),
+		},
+		{
+			name:     "test config for executor with labels active tasks limit without limit",
+			services: []string{"executor"},
+			in: `
+executor:
+  dataDir: /data/agola/executor
+  toolboxPath: ./bin
+  runserviceURL: "http://localhost:4000"
+  web:
+    listenAddress: ":4001"
+  activeTasksLimit: 5
+  driver:
+    type: docker
+  labelsActiveTasksLimits:
+    - labels:
+        type: deploy`,
+			err: errors.Errorf("executor labelsActiveTasksLimits 0: limit must be greater than 0"),
+		},
+		{
+			name:     "test config for executor with k8s driver toleration without key",
+			services: []string{"executor"},
+			in: `
+executor:
+  dataDir: /data/agola/executor
+  toolboxPath: ./bin
+  runserviceURL: "http://localhost:4000"
+  web:
+    listenAddress: ":4001"
+  activeTasksLimit: 5
+  driver:
+    type: kubernetes
+    k8s:
+      tolerations:
+        - value: builds
+          effect: NoSchedule`,
+			err: errors.Errorf("executor k8s driver configuration error: toleration 0: key must be defined with operator Equal"),
+		},
+		{
+			name:     "test config for executor with invalid image policy regexp",
+			services: []string{"executor"},
+			in: `
+executor:
+  dataDir: /data/agola/executor
+  toolboxPath: ./bin
+  runserviceURL: "http://localhost:4000"
+  web:
+    listenAddress: ":4001"
+  activeTasksLimit: 5
+  driver:
+    type: docker
+  imagePolicy:
+    allowedImages:
+      - "golang:*"
+      - "/alpine:(3/"`,
+			err: errors.Errorf("executor imagePolicy configuration error: invalid image pattern \"/alpine:(3/\": error parsing regexp: missing closing ): `alpine:(3`"),
+		},
+		{
+			name:     "test config for executor with invalid image policy glob",
+			services: []string{"executor"},
+			in: `
+executor:
+  dataDir: /data/agola/executor
+  toolboxPath: ./bin
+  runserviceURL: "http://localhost:4000"
+  web:
+    listenAddress: ":4001"
+  activeTasksLimit: 5
+  driver:
+    type: docker
+  imagePolicy:
+    deniedImages:
+      - "registry.example.com/[a-"`,
+			err: errors.Errorf("executor imagePolicy configuration error: invalid image pattern \"registry.example.com/[a-\": syntax error in pattern"),
+		},
+		{
+			name:     "test config for runservice with default task timeout greater than max task timeout",
+			services: []string{"runservice"},
+			in: `
+runservice:
+  dataDir: /data/agola/runservice
+  db:
+    type: sqlite3
+    connString: /opt/data/agola/runservice/db
+  objectStorage:
+    type: posix
+    path: /agola/runservice/ost
+  web:
+    listenAddress: ":4000"
+  limits:
+    defaultTaskTimeout: 2h
+    maxTaskTimeout: 1h`,
+			err: errors.Errorf("runservice limits configuration error: defaultTaskTimeout 2h0m0s greater than maxTaskTimeout 1h0m0s"),
+		},
+		{
+			name:     "test config for runservice with webhook provisioner without url",
+			services: []string{"runservice"},
+			in: `
+runservice:
+  dataDir: /data/agola/runservice
+  db:
+    type: sqlite3
+    connString: /opt/data/agola/runservice/db
+  objectStorage:
+    type: posix
+    path: /agola/runservice/ost
+  web:
+    listenAddress: ":4000"
+  provisioner:
+    type: webhook`,
+			err: errors.Errorf("runservice provisioner configuration error: webhook provisioner url is empty"),
+		},
+		{
+			name:     "test config with internal services auth enabled without service private key",
+			services: []string{"scheduler"},
+			in: `
+internalServicesAuth:
+  enabled: true
+  services:
+    scheduler:
+      publicKeyPath: /etc/agola/scheduler.pub
+
+scheduler:
+  runserviceURL: "http://localhost:4000"`,
+			err: errors.Errorf("internalServicesAuth service \"scheduler\" private key file not defined"),
+		},
+		{
+			name:     "test config with internal services auth enabled without service public key",
+			services: []string{"scheduler"},
+			in: `
+internalServicesAuth:
+  enabled: true
+  services:
+    scheduler:
+      privateKeyPath: /etc/agola/scheduler.key
+
+scheduler:
+  runserviceURL: "http://localhost:4000"`,
+			err: errors.Errorf("internalServicesAuth service \"scheduler\" public key file not defined"),
+		},
+		{
+			name:     "test config for scheduler git polling without gatewayURL",
+			services: []string{"scheduler"},
+			in: `
+scheduler:
+  runserviceURL: "http://localhost:4000"
+  configstoreURL: "http://localhost:4002"`,
+			err: errors.Errorf("scheduler configstoreURL and gatewayURL must be both defined to enable git polling"),
+		},
 	}
 
 	for _, tt := range tests {
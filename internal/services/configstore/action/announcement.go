@@ -0,0 +1,113 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/sql"
+	"agola.io/agola/internal/util"
+	"agola.io/agola/services/configstore/types"
+)
+
+func (h *ActionHandler) GetAnnouncements(ctx context.Context, onlyActive bool) ([]*types.Announcement, error) {
+	var announcements []*types.Announcement
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		announcements, err = h.d.GetAnnouncements(tx)
+		return errors.WithStack(err)
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if !onlyActive {
+		return announcements, nil
+	}
+
+	now := time.Now()
+	activeAnnouncements := []*types.Announcement{}
+	for _, a := range announcements {
+		if !a.IsExpired(now) {
+			activeAnnouncements = append(activeAnnouncements, a)
+		}
+	}
+
+	return activeAnnouncements, nil
+}
+
+type CreateAnnouncementRequest struct {
+	Message    string
+	Severity   types.AnnouncementSeverity
+	ExpireTime *time.Time
+}
+
+func (h *ActionHandler) CreateAnnouncement(ctx context.Context, req *CreateAnnouncementRequest) (*types.Announcement, error) {
+	if req.Message == "" {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("announcement message required"))
+	}
+	severity := req.Severity
+	if severity == "" {
+		severity = types.AnnouncementSeverityInfo
+	}
+	if !types.IsValidAnnouncementSeverity(severity) {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("invalid announcement severity %q", severity))
+	}
+
+	announcement := types.NewAnnouncement()
+	announcement.Message = req.Message
+	announcement.Severity = severity
+	announcement.ExpireTime = req.ExpireTime
+
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		return errors.WithStack(h.d.InsertAnnouncement(tx, announcement))
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return announcement, nil
+}
+
+// ExpireAnnouncement expires the announcement now. Already expired
+// announcements are left untouched
+func (h *ActionHandler) ExpireAnnouncement(ctx context.Context, announcementID string) (*types.Announcement, error) {
+	var announcement *types.Announcement
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		announcement, err = h.d.GetAnnouncement(tx, announcementID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if announcement == nil {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("announcement %q doesn't exist", announcementID))
+		}
+
+		now := time.Now()
+		if announcement.IsExpired(now) {
+			return nil
+		}
+		announcement.ExpireTime = &now
+
+		return errors.WithStack(h.d.UpdateAnnouncement(tx, announcement))
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return announcement, nil
+}
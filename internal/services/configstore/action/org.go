@@ -145,6 +145,51 @@ func (h *ActionHandler) CreateOrg(ctx context.Context, req *CreateOrgRequest) (*
 	return org, errors.WithStack(err)
 }
 
+type UpdateOrgRequest struct {
+	ConcurrencyLimits types.ConcurrencyLimits
+}
+
+func (h *ActionHandler) UpdateOrg(ctx context.Context, orgRef string, req *UpdateOrgRequest) (*types.Organization, error) {
+	if err := validateConcurrencyLimits(req.ConcurrencyLimits); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var org *types.Organization
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		org, err = h.d.GetOrg(tx, orgRef)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if org == nil {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("org %q doesn't exist", orgRef))
+		}
+
+		org.ConcurrencyLimits = req.ConcurrencyLimits
+
+		if err := h.d.UpdateOrganization(tx, org); err != nil {
+			return errors.WithStack(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return org, nil
+}
+
+func validateConcurrencyLimits(l types.ConcurrencyLimits) error {
+	if l.MaxRunningRuns < 0 {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("max running runs must be greater or equal than 0"))
+	}
+	if l.MaxRunningTasks < 0 {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("max running tasks must be greater or equal than 0"))
+	}
+	return nil
+}
+
 func (h *ActionHandler) DeleteOrg(ctx context.Context, orgRef string) error {
 	var org *types.Organization
 
@@ -17,6 +17,7 @@ package action
 import (
 	"context"
 	"path"
+	"time"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/sql"
@@ -24,9 +25,15 @@ import (
 	"agola.io/agola/internal/util"
 	"agola.io/agola/services/configstore/types"
 
+	"github.com/bmatcuk/doublestar"
 	"github.com/gofrs/uuid"
 )
 
+const (
+	maxProjectTags      = 50
+	maxProjectSchedules = 10
+)
+
 func (h *ActionHandler) ValidateProjectReq(ctx context.Context, req *CreateUpdateProjectRequest) error {
 	if req.Name == "" {
 		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("project name required"))
@@ -43,6 +50,12 @@ func (h *ActionHandler) ValidateProjectReq(ctx context.Context, req *CreateUpdat
 	if !types.IsValidVisibility(req.Visibility) {
 		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("invalid project visibility"))
 	}
+	if !types.IsValidRunsVisibility(req.RunsVisibility) {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("invalid project runs visibility %q", req.RunsVisibility))
+	}
+	if !types.IsValidRunsVisibility(req.LogsVisibility) {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("invalid project logs visibility %q", req.LogsVisibility))
+	}
 	if !types.IsValidRemoteRepositoryConfigType(req.RemoteRepositoryConfigType) {
 		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("invalid project remote repository config type %q", req.RemoteRepositoryConfigType))
 	}
@@ -60,6 +73,47 @@ func (h *ActionHandler) ValidateProjectReq(ctx context.Context, req *CreateUpdat
 			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty remote repository path"))
 		}
 	}
+	if len(req.Tags) > maxProjectTags {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("too many project tags, max %d", maxProjectTags))
+	}
+	for _, tag := range req.Tags {
+		if !util.ValidateTag(tag) {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("invalid project tag %q", tag))
+		}
+	}
+	for _, pattern := range append(append([]string{}, req.ProtectedBranches...), req.ProtectedTags...) {
+		if pattern == "" {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty project protected branch or tag pattern"))
+		}
+		if _, err := doublestar.Match(pattern, pattern); err != nil {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("invalid project protected branch or tag pattern %q", pattern))
+		}
+	}
+	if len(req.Schedules) > maxProjectSchedules {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("too many project schedules, max %d", maxProjectSchedules))
+	}
+	schedules := map[string]struct{}{}
+	for _, s := range req.Schedules {
+		if s == nil {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty project schedule"))
+		}
+		if !util.ValidateName(s.Name) {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("invalid project schedule name %q", s.Name))
+		}
+		if _, ok := schedules[s.Name]; ok {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("duplicate project schedule %q", s.Name))
+		}
+		schedules[s.Name] = struct{}{}
+		if _, err := util.ParseCronSchedule(s.Cron); err != nil {
+			return util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "invalid project schedule %q", s.Name))
+		}
+		if s.Branch == "" {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty project schedule %q branch", s.Name))
+		}
+	}
+	if err := validateConcurrencyLimits(req.ConcurrencyLimits); err != nil {
+		return errors.WithStack(err)
+	}
 	return nil
 }
 
@@ -93,6 +147,18 @@ type CreateUpdateProjectRequest struct {
 	SSHPrivateKey              string
 	SkipSSHHostKeyCheck        bool
 	PassVarsToForkedPR         bool
+	ReportSkippedRuns          bool
+	Tags                       []string
+	PostPullRequestComments    bool
+	UseDepsProxy               bool
+	CancelSupersededRuns       bool
+	RunsVisibility             types.RunsVisibility
+	LogsVisibility             types.RunsVisibility
+	ProtectedBranches          []string
+	ProtectedTags              []string
+	AllowedUpstreamProjects    []string
+	Schedules                  []*types.ProjectSchedule
+	ConcurrencyLimits          types.ConcurrencyLimits
 }
 
 func (h *ActionHandler) CreateProject(ctx context.Context, req *CreateUpdateProjectRequest) (*types.Project, error) {
@@ -124,6 +190,9 @@ func (h *ActionHandler) CreateProject(ctx context.Context, req *CreateUpdateProj
 			return errors.WithStack(err)
 		}
 		if p != nil {
+			if p.DeletionTime != nil {
+				return util.NewAPIError(util.ErrBadRequest, errors.Errorf("project with name %q, path %q already exists and is pending deletion", p.Name, pp))
+			}
 			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("project with name %q, path %q already exists", p.Name, pp))
 		}
 
@@ -162,6 +231,18 @@ func (h *ActionHandler) CreateProject(ctx context.Context, req *CreateUpdateProj
 		project.SSHPrivateKey = req.SSHPrivateKey
 		project.SkipSSHHostKeyCheck = req.SkipSSHHostKeyCheck
 		project.PassVarsToForkedPR = req.PassVarsToForkedPR
+		project.ReportSkippedRuns = req.ReportSkippedRuns
+		project.Tags = util.UniqueSortedStrings(req.Tags)
+		project.PostPullRequestComments = req.PostPullRequestComments
+		project.UseDepsProxy = req.UseDepsProxy
+		project.CancelSupersededRuns = req.CancelSupersededRuns
+		project.RunsVisibility = req.RunsVisibility
+		project.LogsVisibility = req.LogsVisibility
+		project.ProtectedBranches = util.UniqueSortedStrings(req.ProtectedBranches)
+		project.ProtectedTags = util.UniqueSortedStrings(req.ProtectedTags)
+		project.AllowedUpstreamProjects = util.UniqueSortedStrings(req.AllowedUpstreamProjects)
+		project.Schedules = req.Schedules
+		project.ConcurrencyLimits = req.ConcurrencyLimits
 
 		// generate the Secret and the WebhookSecret
 		// TODO(sgotti) move this to the gateway?
@@ -221,6 +302,9 @@ func (h *ActionHandler) UpdateProject(ctx context.Context, curProjectRef string,
 				return errors.WithStack(err)
 			}
 			if ap != nil {
+				if ap.DeletionTime != nil {
+					return util.NewAPIError(util.ErrBadRequest, errors.Errorf("project with name %q, path %q already exists and is pending deletion", req.Name, pp))
+				}
 				return util.NewAPIError(util.ErrBadRequest, errors.Errorf("project with name %q, path %q already exists", req.Name, pp))
 			}
 		}
@@ -259,7 +343,7 @@ func (h *ActionHandler) UpdateProject(ctx context.Context, curProjectRef string,
 			}
 		}
 
-		// TODO(sgotti) Secret and WebhookSecret are not updated
+		// TODO(sgotti) Secret is not updated
 		project.Name = req.Name
 		project.Parent = req.Parent
 		project.Visibility = req.Visibility
@@ -271,6 +355,23 @@ func (h *ActionHandler) UpdateProject(ctx context.Context, curProjectRef string,
 		project.SSHPrivateKey = req.SSHPrivateKey
 		project.SkipSSHHostKeyCheck = req.SkipSSHHostKeyCheck
 		project.PassVarsToForkedPR = req.PassVarsToForkedPR
+		project.ReportSkippedRuns = req.ReportSkippedRuns
+		project.Tags = util.UniqueSortedStrings(req.Tags)
+		project.PostPullRequestComments = req.PostPullRequestComments
+		project.UseDepsProxy = req.UseDepsProxy
+		project.CancelSupersededRuns = req.CancelSupersededRuns
+		project.RunsVisibility = req.RunsVisibility
+		project.LogsVisibility = req.LogsVisibility
+		project.ProtectedBranches = util.UniqueSortedStrings(req.ProtectedBranches)
+		project.ProtectedTags = util.UniqueSortedStrings(req.ProtectedTags)
+		project.AllowedUpstreamProjects = util.UniqueSortedStrings(req.AllowedUpstreamProjects)
+		project.Schedules = req.Schedules
+		project.ConcurrencyLimits = req.ConcurrencyLimits
+
+		// generate the WebhookSecret for projects created before it was introduced
+		if project.WebhookSecret == "" {
+			project.WebhookSecret = util.EncodeSha1Hex(uuid.Must(uuid.NewV4()).String())
+		}
 
 		if err := h.d.UpdateProject(tx, project); err != nil {
 			return errors.WithStack(err)
@@ -301,6 +402,16 @@ func (h *ActionHandler) DeleteProject(ctx context.Context, projectRef string) er
 			return errors.WithStack(err)
 		}
 
+		projectPolledRefs, err := h.d.GetProjectPolledRefs(tx, project.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if projectPolledRefs != nil {
+			if err := h.d.DeleteProjectPolledRefs(tx, projectPolledRefs.ID); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -309,3 +420,137 @@ func (h *ActionHandler) DeleteProject(ctx context.Context, projectRef string) er
 
 	return errors.WithStack(err)
 }
+
+// SoftDeleteProject marks the project as deleted. The project will be
+// permanently deleted by the gateway after the deletion grace period.
+func (h *ActionHandler) SoftDeleteProject(ctx context.Context, projectRef string) (*types.Project, error) {
+	var project *types.Project
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		project, err = h.d.GetProject(tx, projectRef)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if project == nil || project.DeletionTime != nil {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("project %q doesn't exist", projectRef))
+		}
+
+		now := time.Now()
+		project.DeletionTime = &now
+
+		return errors.WithStack(h.d.UpdateProject(tx, project))
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return project, nil
+}
+
+// UndeleteProject restores a deleted project not yet permanently deleted.
+func (h *ActionHandler) UndeleteProject(ctx context.Context, projectRef string) (*types.Project, error) {
+	var project *types.Project
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		project, err = h.d.GetProject(tx, projectRef)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if project == nil {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("project %q doesn't exist", projectRef))
+		}
+		if project.DeletionTime == nil {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("project %q isn't deleted", projectRef))
+		}
+
+		project.DeletionTime = nil
+
+		return errors.WithStack(h.d.UpdateProject(tx, project))
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return project, nil
+}
+
+func (h *ActionHandler) GetDeletedProjects(ctx context.Context) ([]*types.Project, error) {
+	var projects []*types.Project
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		projects, err = h.d.GetDeletedProjects(tx)
+		return errors.WithStack(err)
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return projects, nil
+}
+
+// filterDeletedProjects removes the deleted projects
+func filterDeletedProjects(projects []*types.Project) []*types.Project {
+	filteredProjects := []*types.Project{}
+	for _, project := range projects {
+		if project.DeletionTime == nil {
+			filteredProjects = append(filteredProjects, project)
+		}
+	}
+
+	return filteredProjects
+}
+
+// GetProjectPolledRefs returns the project refs seen by the last poll. If the
+// project has never been polled nil is returned
+func (h *ActionHandler) GetProjectPolledRefs(ctx context.Context, projectRef string) (*types.ProjectPolledRefs, error) {
+	var projectPolledRefs *types.ProjectPolledRefs
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		project, err := h.d.GetProject(tx, projectRef)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if project == nil {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("project %q doesn't exist", projectRef))
+		}
+
+		projectPolledRefs, err = h.d.GetProjectPolledRefs(tx, project.ID)
+		return errors.WithStack(err)
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return projectPolledRefs, nil
+}
+
+// UpdateProjectPolledRefs replaces the project refs seen by the last poll
+func (h *ActionHandler) UpdateProjectPolledRefs(ctx context.Context, projectRef string, refs map[string]string) (*types.ProjectPolledRefs, error) {
+	var projectPolledRefs *types.ProjectPolledRefs
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		project, err := h.d.GetProject(tx, projectRef)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if project == nil {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("project %q doesn't exist", projectRef))
+		}
+
+		projectPolledRefs, err = h.d.GetProjectPolledRefs(tx, project.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if projectPolledRefs == nil {
+			projectPolledRefs = types.NewProjectPolledRefs()
+			projectPolledRefs.ProjectID = project.ID
+		}
+
+		projectPolledRefs.Refs = refs
+
+		return errors.WithStack(h.d.InsertOrUpdateProjectPolledRefs(tx, projectPolledRefs))
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return projectPolledRefs, nil
+}
@@ -67,7 +67,9 @@ func (h *ActionHandler) GetProjectGroupSubgroups(ctx context.Context, projectGro
 	return projectGroups, nil
 }
 
-func (h *ActionHandler) GetProjectGroupProjects(ctx context.Context, projectGroupRef string) ([]*types.Project, error) {
+// GetProjectGroupProjects returns the projects of the project group. When tags
+// are provided only the projects having all of them are returned.
+func (h *ActionHandler) GetProjectGroupProjects(ctx context.Context, projectGroupRef string, tags []string) ([]*types.Project, error) {
 	var projects []*types.Project
 	err := h.d.Do(ctx, func(tx *sql.Tx) error {
 		var err error
@@ -86,7 +88,21 @@ func (h *ActionHandler) GetProjectGroupProjects(ctx context.Context, projectGrou
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return projects, nil
+
+	projects = filterDeletedProjects(projects)
+
+	if len(tags) == 0 {
+		return projects, nil
+	}
+
+	filteredProjects := []*types.Project{}
+	for _, project := range projects {
+		if len(util.Difference(tags, project.Tags)) == 0 {
+			filteredProjects = append(filteredProjects, project)
+		}
+	}
+
+	return filteredProjects, nil
 }
 
 func (h *ActionHandler) ValidateProjectGroupReq(ctx context.Context, req *CreateUpdateProjectGroupRequest) error {
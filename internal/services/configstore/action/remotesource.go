@@ -22,6 +22,8 @@ import (
 
 	"agola.io/agola/internal/util"
 	"agola.io/agola/services/configstore/types"
+
+	"github.com/gofrs/uuid"
 )
 
 func (h *ActionHandler) ValidateRemoteSourceReq(ctx context.Context, req *CreateUpdateRemoteSourceRequest) error {
@@ -57,6 +59,14 @@ func (h *ActionHandler) ValidateRemoteSourceReq(ctx context.Context, req *Create
 			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("remotesource oauth2clientsecret required for auth type %q", types.RemoteSourceAuthTypeOauth2))
 		}
 	}
+	if req.OrgWebhooks && req.Type == types.RemoteSourceTypeGit {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("remotesource type %q doesn't support organization webhooks", req.Type))
+	}
+	if req.SSHEndpoint != "" {
+		if err := util.ValidateSSHEndpoint(req.SSHEndpoint); err != nil {
+			return util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "invalid remotesource ssh endpoint %q", req.SSHEndpoint))
+		}
+	}
 
 	return nil
 }
@@ -71,6 +81,8 @@ type CreateUpdateRemoteSourceRequest struct {
 	Oauth2ClientSecret  string
 	SSHHostKey          string
 	SkipSSHHostKeyCheck bool
+	SSHEndpoint         string
+	OrgWebhooks         bool
 	RegistrationEnabled *bool
 	LoginEnabled        *bool
 }
@@ -101,6 +113,11 @@ func (h *ActionHandler) CreateRemoteSource(ctx context.Context, req *CreateUpdat
 		remoteSource.Oauth2ClientSecret = req.Oauth2ClientSecret
 		remoteSource.SSHHostKey = req.SSHHostKey
 		remoteSource.SkipSSHHostKeyCheck = req.SkipSSHHostKeyCheck
+		remoteSource.SSHEndpoint = req.SSHEndpoint
+		remoteSource.OrgWebhooks = req.OrgWebhooks
+		if remoteSource.OrgWebhooks && remoteSource.WebhookSecret == "" {
+			remoteSource.WebhookSecret = util.EncodeSha1Hex(uuid.Must(uuid.NewV4()).String())
+		}
 		remoteSource.RegistrationEnabled = req.RegistrationEnabled
 		remoteSource.LoginEnabled = req.LoginEnabled
 
@@ -155,6 +172,11 @@ func (h *ActionHandler) UpdateRemoteSource(ctx context.Context, remoteSourceRef
 		remoteSource.Oauth2ClientSecret = req.Oauth2ClientSecret
 		remoteSource.SSHHostKey = req.SSHHostKey
 		remoteSource.SkipSSHHostKeyCheck = req.SkipSSHHostKeyCheck
+		remoteSource.SSHEndpoint = req.SSHEndpoint
+		remoteSource.OrgWebhooks = req.OrgWebhooks
+		if remoteSource.OrgWebhooks && remoteSource.WebhookSecret == "" {
+			remoteSource.WebhookSecret = util.EncodeSha1Hex(uuid.Must(uuid.NewV4()).String())
+		}
 		remoteSource.RegistrationEnabled = req.RegistrationEnabled
 		remoteSource.LoginEnabled = req.LoginEnabled
 
@@ -194,3 +216,26 @@ func (h *ActionHandler) DeleteRemoteSource(ctx context.Context, remoteSourceName
 
 	return errors.WithStack(err)
 }
+
+// GetRemoteSourceProjects returns the projects using the remote source. When
+// repositoryPath isn't empty only the projects of that repository are returned.
+func (h *ActionHandler) GetRemoteSourceProjects(ctx context.Context, remoteSourceRef, repositoryPath string) ([]*types.Project, error) {
+	var projects []*types.Project
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		remoteSource, err := h.d.GetRemoteSource(tx, remoteSourceRef)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if remoteSource == nil {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("remotesource %q doesn't exist", remoteSourceRef))
+		}
+
+		projects, err = h.d.GetRemoteSourceProjects(tx, remoteSource.ID, repositoryPath)
+		return errors.WithStack(err)
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return filterDeletedProjects(projects), nil
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 
 	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/sealedsecret"
 	"agola.io/agola/internal/sql"
 	"agola.io/agola/internal/util"
 	"agola.io/agola/services/configstore/types"
@@ -77,6 +78,13 @@ func (h *ActionHandler) ValidateSecretReq(ctx context.Context, req *CreateUpdate
 		if len(req.Data) == 0 {
 			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty secret data"))
 		}
+		if req.Sealed {
+			for k, v := range req.Data {
+				if err := sealedsecret.Validate(v); err != nil {
+					return util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "invalid sealed secret data %q", k))
+				}
+			}
+		}
 	}
 	if req.Parent.Kind == "" {
 		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("secret parent kind required"))
@@ -96,6 +104,7 @@ type CreateUpdateSecretRequest struct {
 	Parent           types.Parent
 	Type             types.SecretType
 	Data             map[string]string
+	Sealed           bool
 	SecretProviderID string
 	Path             string
 }
@@ -128,6 +137,7 @@ func (h *ActionHandler) CreateSecret(ctx context.Context, req *CreateUpdateSecre
 		secret.Parent = req.Parent
 		secret.Type = req.Type
 		secret.Data = req.Data
+		secret.Sealed = req.Sealed
 		secret.SecretProviderID = req.SecretProviderID
 		secret.Path = req.Path
 
@@ -183,6 +193,7 @@ func (h *ActionHandler) UpdateSecret(ctx context.Context, curSecretName string,
 		secret.Parent = req.Parent
 		secret.Type = req.Type
 		secret.Data = req.Data
+		secret.Sealed = req.Sealed
 		secret.SecretProviderID = req.SecretProviderID
 		secret.Path = req.Path
 
@@ -16,6 +16,7 @@ package action
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"agola.io/agola/internal/errors"
@@ -83,6 +84,8 @@ func (h *ActionHandler) CreateUser(ctx context.Context, req *CreateUserRequest)
 			la.RemoteSourceID = rs.ID
 			la.RemoteUserID = req.CreateUserLARequest.RemoteUserID
 			la.RemoteUserName = req.CreateUserLARequest.RemoteUserName
+			la.RemoteUserDisplayName = req.CreateUserLARequest.RemoteUserDisplayName
+			la.RemoteUserAvatarURL = req.CreateUserLARequest.RemoteUserAvatarURL
 			la.UserAccessToken = req.CreateUserLARequest.UserAccessToken
 			la.Oauth2AccessToken = req.CreateUserLARequest.Oauth2AccessToken
 			la.Oauth2RefreshToken = req.CreateUserLARequest.Oauth2RefreshToken
@@ -91,6 +94,8 @@ func (h *ActionHandler) CreateUser(ctx context.Context, req *CreateUserRequest)
 			if err := h.d.InsertLinkedAccount(tx, la); err != nil {
 				return errors.WithStack(err)
 			}
+
+			syncUserProfile(user, la)
 		}
 
 		// create root user project group
@@ -131,6 +136,16 @@ func (h *ActionHandler) DeleteUser(ctx context.Context, userRef string) error {
 			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("user %q doesn't exist", userRef))
 		}
 
+		userPreferences, err := h.d.GetUserPreferences(tx, user.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if userPreferences != nil {
+			if err := h.d.DeleteUserPreferences(tx, userPreferences.ID); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
 		if err := h.d.DeleteUser(tx, user.ID); err != nil {
 			return errors.WithStack(err)
 		}
@@ -225,6 +240,8 @@ type CreateUserLARequest struct {
 	RemoteSourceName           string
 	RemoteUserID               string
 	RemoteUserName             string
+	RemoteUserDisplayName      string
+	RemoteUserAvatarURL        string
 	UserAccessToken            string
 	Oauth2AccessToken          string
 	Oauth2RefreshToken         string
@@ -270,6 +287,8 @@ func (h *ActionHandler) CreateUserLA(ctx context.Context, req *CreateUserLAReque
 		la.RemoteSourceID = rs.ID
 		la.RemoteUserID = req.RemoteUserID
 		la.RemoteUserName = req.RemoteUserName
+		la.RemoteUserDisplayName = req.RemoteUserDisplayName
+		la.RemoteUserAvatarURL = req.RemoteUserAvatarURL
 		la.UserAccessToken = req.UserAccessToken
 		la.Oauth2AccessToken = req.Oauth2AccessToken
 		la.Oauth2RefreshToken = req.Oauth2RefreshToken
@@ -279,6 +298,12 @@ func (h *ActionHandler) CreateUserLA(ctx context.Context, req *CreateUserLAReque
 			return errors.WithStack(err)
 		}
 
+		if syncUserProfile(user, la) {
+			if err := h.d.UpdateUser(tx, user); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -334,12 +359,30 @@ func (h *ActionHandler) DeleteUserLA(ctx context.Context, userRef, laID string)
 	return errors.WithStack(err)
 }
 
+// syncUserProfile updates the user profile with the linked account remote user
+// profile. It returns true if the user has been changed.
+func syncUserProfile(user *types.User, la *types.LinkedAccount) bool {
+	changed := false
+	if la.RemoteUserDisplayName != "" && user.DisplayName != la.RemoteUserDisplayName {
+		user.DisplayName = la.RemoteUserDisplayName
+		changed = true
+	}
+	if la.RemoteUserAvatarURL != "" && user.AvatarURL != la.RemoteUserAvatarURL {
+		user.AvatarURL = la.RemoteUserAvatarURL
+		changed = true
+	}
+
+	return changed
+}
+
 type UpdateUserLARequest struct {
 	UserRef string
 
 	LinkedAccountID            string
 	RemoteUserID               string
 	RemoteUserName             string
+	RemoteUserDisplayName      string
+	RemoteUserAvatarURL        string
 	UserAccessToken            string
 	Oauth2AccessToken          string
 	Oauth2RefreshToken         string
@@ -384,15 +427,23 @@ func (h *ActionHandler) UpdateUserLA(ctx context.Context, req *UpdateUserLAReque
 
 		la.RemoteUserID = req.RemoteUserID
 		la.RemoteUserName = req.RemoteUserName
+		la.RemoteUserDisplayName = req.RemoteUserDisplayName
+		la.RemoteUserAvatarURL = req.RemoteUserAvatarURL
 		la.UserAccessToken = req.UserAccessToken
 		la.Oauth2AccessToken = req.Oauth2AccessToken
 		la.Oauth2RefreshToken = req.Oauth2RefreshToken
 		la.Oauth2AccessTokenExpiresAt = req.Oauth2AccessTokenExpiresAt
 
-		if err := h.d.UpdateUser(tx, user); err != nil {
+		if err := h.d.UpdateLinkedAccount(tx, la); err != nil {
 			return errors.WithStack(err)
 		}
 
+		if syncUserProfile(user, la) {
+			if err := h.d.UpdateUser(tx, user); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -515,6 +566,44 @@ func (h *ActionHandler) DeleteUserToken(ctx context.Context, userRef, tokenName
 	return errors.WithStack(err)
 }
 
+// UpdateUserTokenLastUsed sets the last used time of the user token with the
+// provided value. Older times are ignored.
+func (h *ActionHandler) UpdateUserTokenLastUsed(ctx context.Context, userRef, tokenValue string, lastUsedTime time.Time) error {
+	if userRef == "" {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("user ref required"))
+	}
+	if tokenValue == "" {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("token required"))
+	}
+
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		user, err := h.d.GetUser(tx, userRef)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if user == nil {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("user %q doesn't exist", userRef))
+		}
+
+		userToken, err := h.d.GetUserTokenByValue(tx, tokenValue)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if userToken == nil || userToken.UserID != user.ID {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("token for user %q doesn't exist", userRef))
+		}
+
+		if !lastUsedTime.After(userToken.LastUsedTime) {
+			return nil
+		}
+		userToken.LastUsedTime = lastUsedTime
+
+		return errors.WithStack(h.d.UpdateUserToken(tx, userToken))
+	})
+
+	return errors.WithStack(err)
+}
+
 type UserOrgsResponse struct {
 	Organization *types.Organization
 	Role         types.MemberRole
@@ -553,3 +642,126 @@ func (h *ActionHandler) GetUserOrgs(ctx context.Context, userRef string) ([]*Use
 
 	return res, nil
 }
+
+const maxUserPreferencesUISettingsSize = 64 * 1024
+
+// GetUserPreferences returns the user preferences. If the user hasn't saved
+// any preference an empty one is returned
+func (h *ActionHandler) GetUserPreferences(ctx context.Context, userRef string) (*types.UserPreferences, error) {
+	var userPreferences *types.UserPreferences
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		user, err := h.d.GetUser(tx, userRef)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if user == nil {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("user %q doesn't exist", userRef))
+		}
+
+		userPreferences, err = h.d.GetUserPreferences(tx, user.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if userPreferences == nil {
+			userPreferences = types.NewUserPreferences()
+			userPreferences.UserID = user.ID
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return userPreferences, nil
+}
+
+type UpdateUserPreferencesRequest struct {
+	DefaultOrgRef     string
+	DefaultProjectRef string
+	Timezone          string
+	DateFormat        string
+	UISettings        json.RawMessage
+}
+
+// UpdateUserPreferences replaces all the user preferences with the provided ones
+func (h *ActionHandler) UpdateUserPreferences(ctx context.Context, userRef string, req *UpdateUserPreferencesRequest) (*types.UserPreferences, error) {
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("invalid timezone %q", req.Timezone))
+		}
+	}
+	if len(req.DateFormat) > 64 {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("date format too long"))
+	}
+	uiSettings := req.UISettings
+	if string(uiSettings) == "null" {
+		uiSettings = nil
+	}
+	if len(uiSettings) > 0 {
+		if len(uiSettings) > maxUserPreferencesUISettingsSize {
+			return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("ui settings bigger than %d bytes", maxUserPreferencesUISettingsSize))
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(uiSettings, &m); err != nil {
+			return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("ui settings must be a json object"))
+		}
+	}
+
+	var userPreferences *types.UserPreferences
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		user, err := h.d.GetUser(tx, userRef)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if user == nil {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("user %q doesn't exist", userRef))
+		}
+
+		var defaultOrgID string
+		if req.DefaultOrgRef != "" {
+			org, err := h.d.GetOrg(tx, req.DefaultOrgRef)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if org == nil {
+				return util.NewAPIError(util.ErrBadRequest, errors.Errorf("org %q doesn't exist", req.DefaultOrgRef))
+			}
+			defaultOrgID = org.ID
+		}
+
+		var defaultProjectID string
+		if req.DefaultProjectRef != "" {
+			project, err := h.d.GetProject(tx, req.DefaultProjectRef)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if project == nil {
+				return util.NewAPIError(util.ErrBadRequest, errors.Errorf("project %q doesn't exist", req.DefaultProjectRef))
+			}
+			defaultProjectID = project.ID
+		}
+
+		userPreferences, err = h.d.GetUserPreferences(tx, user.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if userPreferences == nil {
+			userPreferences = types.NewUserPreferences()
+			userPreferences.UserID = user.ID
+		}
+
+		userPreferences.DefaultOrgID = defaultOrgID
+		userPreferences.DefaultProjectID = defaultProjectID
+		userPreferences.Timezone = req.Timezone
+		userPreferences.DateFormat = req.DateFormat
+		userPreferences.UISettings = uiSettings
+
+		return errors.WithStack(h.d.InsertOrUpdateUserPreferences(tx, userPreferences))
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return userPreferences, nil
+}
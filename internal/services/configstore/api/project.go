@@ -133,12 +133,22 @@ func (h *ProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	_, deleted := r.URL.Query()["deleted"]
+
 	project, err := h.ah.GetProject(ctx, projectRef)
 	if util.HTTPError(w, err) {
 		h.log.Err(err).Send()
 		return
 	}
 
+	// deleted projects are hidden unless explicitly requested
+	if project.DeletionTime != nil && !deleted {
+		err := util.NewAPIError(util.ErrNotExist, errors.Errorf("project %q doesn't exist", projectRef))
+		util.HTTPError(w, err)
+		h.log.Err(err).Send()
+		return
+	}
+
 	resProject, err := projectResponse(ctx, h.readDB, project)
 	if util.HTTPError(w, err) {
 		h.log.Err(err).Send()
@@ -182,6 +192,18 @@ func (h *CreateProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		SSHPrivateKey:              req.SSHPrivateKey,
 		SkipSSHHostKeyCheck:        req.SkipSSHHostKeyCheck,
 		PassVarsToForkedPR:         req.PassVarsToForkedPR,
+		ReportSkippedRuns:          req.ReportSkippedRuns,
+		Tags:                       req.Tags,
+		PostPullRequestComments:    req.PostPullRequestComments,
+		UseDepsProxy:               req.UseDepsProxy,
+		CancelSupersededRuns:       req.CancelSupersededRuns,
+		RunsVisibility:             req.RunsVisibility,
+		LogsVisibility:             req.LogsVisibility,
+		ProtectedBranches:          req.ProtectedBranches,
+		ProtectedTags:              req.ProtectedTags,
+		AllowedUpstreamProjects:    req.AllowedUpstreamProjects,
+		Schedules:                  req.Schedules,
+		ConcurrencyLimits:          req.ConcurrencyLimits,
 	}
 
 	project, err := h.ah.CreateProject(ctx, areq)
@@ -240,6 +262,18 @@ func (h *UpdateProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		SSHPrivateKey:              req.SSHPrivateKey,
 		SkipSSHHostKeyCheck:        req.SkipSSHHostKeyCheck,
 		PassVarsToForkedPR:         req.PassVarsToForkedPR,
+		ReportSkippedRuns:          req.ReportSkippedRuns,
+		Tags:                       req.Tags,
+		PostPullRequestComments:    req.PostPullRequestComments,
+		UseDepsProxy:               req.UseDepsProxy,
+		CancelSupersededRuns:       req.CancelSupersededRuns,
+		RunsVisibility:             req.RunsVisibility,
+		LogsVisibility:             req.LogsVisibility,
+		ProtectedBranches:          req.ProtectedBranches,
+		ProtectedTags:              req.ProtectedTags,
+		AllowedUpstreamProjects:    req.AllowedUpstreamProjects,
+		Schedules:                  req.Schedules,
+		ConcurrencyLimits:          req.ConcurrencyLimits,
 	}
 
 	project, err := h.ah.UpdateProject(ctx, projectRef, areq)
@@ -278,15 +312,159 @@ func (h *DeleteProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.ah.DeleteProject(ctx, projectRef)
+	// a soft deleted project is kept until permanently deleted after the
+	// deletion grace period
+	if _, soft := r.URL.Query()["soft"]; soft {
+		_, err = h.ah.SoftDeleteProject(ctx, projectRef)
+	} else {
+		err = h.ah.DeleteProject(ctx, projectRef)
+	}
 	if util.HTTPError(w, err) {
 		h.log.Err(err).Send()
+		return
 	}
 	if err := util.HTTPResponse(w, http.StatusNoContent, nil); err != nil {
 		h.log.Err(err).Send()
 	}
 }
 
+type UndeleteProjectHandler struct {
+	log    zerolog.Logger
+	ah     *action.ActionHandler
+	readDB *db.DB
+}
+
+func NewUndeleteProjectHandler(log zerolog.Logger, ah *action.ActionHandler, readDB *db.DB) *UndeleteProjectHandler {
+	return &UndeleteProjectHandler{log: log, ah: ah, readDB: readDB}
+}
+
+func (h *UndeleteProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	projectRef, err := url.PathUnescape(vars["projectref"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	project, err := h.ah.UndeleteProject(ctx, projectRef)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	resProject, err := projectResponse(ctx, h.readDB, project)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, resProject); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type DeletedProjectsHandler struct {
+	log    zerolog.Logger
+	ah     *action.ActionHandler
+	readDB *db.DB
+}
+
+func NewDeletedProjectsHandler(log zerolog.Logger, ah *action.ActionHandler, readDB *db.DB) *DeletedProjectsHandler {
+	return &DeletedProjectsHandler{log: log, ah: ah, readDB: readDB}
+}
+
+func (h *DeletedProjectsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	projects, err := h.ah.GetDeletedProjects(ctx)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	resProjects, err := projectsResponse(ctx, h.readDB, projects)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, resProjects); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type ProjectPolledRefsHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewProjectPolledRefsHandler(log zerolog.Logger, ah *action.ActionHandler) *ProjectPolledRefsHandler {
+	return &ProjectPolledRefsHandler{log: log, ah: ah}
+}
+
+func (h *ProjectPolledRefsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	projectRef, err := url.PathUnescape(vars["projectref"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	projectPolledRefs, err := h.ah.GetProjectPolledRefs(ctx, projectRef)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+	if projectPolledRefs == nil {
+		err := util.NewAPIError(util.ErrNotExist, errors.Errorf("project %q polled refs don't exist", projectRef))
+		util.HTTPError(w, err)
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, projectPolledRefs); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type UpdateProjectPolledRefsHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewUpdateProjectPolledRefsHandler(log zerolog.Logger, ah *action.ActionHandler) *UpdateProjectPolledRefsHandler {
+	return &UpdateProjectPolledRefsHandler{log: log, ah: ah}
+}
+
+func (h *UpdateProjectPolledRefsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	projectRef, err := url.PathUnescape(vars["projectref"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	var req *csapitypes.UpdateProjectPolledRefsRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	projectPolledRefs, err := h.ah.UpdateProjectPolledRefs(ctx, projectRef, req.Refs)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, projectPolledRefs); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
 const (
 	DefaultProjectsLimit = 10
 	MaxProjectsLimit     = 20
@@ -137,7 +137,9 @@ func (h *ProjectGroupProjectsHandler) ServeHTTP(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	projects, err := h.ah.GetProjectGroupProjects(ctx, projectGroupRef)
+	tags := r.URL.Query()["tag"]
+
+	projects, err := h.ah.GetProjectGroupProjects(ctx, projectGroupRef, tags)
 	if util.HTTPError(w, err) {
 		h.log.Err(err).Send()
 		return
@@ -94,7 +94,10 @@ func (h *CreateRemoteSourceHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 		AuthType:            req.AuthType,
 		Oauth2ClientID:      req.Oauth2ClientID,
 		Oauth2ClientSecret:  req.Oauth2ClientSecret,
+		SSHHostKey:          req.SSHHostKey,
 		SkipSSHHostKeyCheck: req.SkipSSHHostKeyCheck,
+		SSHEndpoint:         req.SSHEndpoint,
+		OrgWebhooks:         req.OrgWebhooks,
 		RegistrationEnabled: req.RegistrationEnabled,
 		LoginEnabled:        req.LoginEnabled,
 	}
@@ -140,7 +143,10 @@ func (h *UpdateRemoteSourceHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 		AuthType:            req.AuthType,
 		Oauth2ClientID:      req.Oauth2ClientID,
 		Oauth2ClientSecret:  req.Oauth2ClientSecret,
+		SSHHostKey:          req.SSHHostKey,
 		SkipSSHHostKeyCheck: req.SkipSSHHostKeyCheck,
+		SSHEndpoint:         req.SSHEndpoint,
+		OrgWebhooks:         req.OrgWebhooks,
 		RegistrationEnabled: req.RegistrationEnabled,
 		LoginEnabled:        req.LoginEnabled,
 	}
@@ -238,3 +244,36 @@ func (h *RemoteSourcesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		h.log.Err(err).Send()
 	}
 }
+
+type RemoteSourceProjectsHandler struct {
+	log    zerolog.Logger
+	ah     *action.ActionHandler
+	readDB *db.DB
+}
+
+func NewRemoteSourceProjectsHandler(log zerolog.Logger, ah *action.ActionHandler, readDB *db.DB) *RemoteSourceProjectsHandler {
+	return &RemoteSourceProjectsHandler{log: log, ah: ah, readDB: readDB}
+}
+
+func (h *RemoteSourceProjectsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	rsRef := vars["remotesourceref"]
+	repositoryPath := r.URL.Query().Get("repositorypath")
+
+	projects, err := h.ah.GetRemoteSourceProjects(ctx, rsRef, repositoryPath)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	resProjects, err := projectsResponse(ctx, h.readDB, projects)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, resProjects); err != nil {
+		h.log.Err(err).Send()
+	}
+}
@@ -142,6 +142,7 @@ func (h *CreateSecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		},
 		Type:             req.Type,
 		Data:             req.Data,
+		Sealed:           req.Sealed,
 		SecretProviderID: req.SecretProviderID,
 		Path:             req.Path,
 	}
@@ -192,6 +193,7 @@ func (h *UpdateSecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		},
 		Type:             req.Type,
 		Data:             req.Data,
+		Sealed:           req.Sealed,
 		SecretProviderID: req.SecretProviderID,
 		Path:             req.Path,
 	}
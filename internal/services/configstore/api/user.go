@@ -94,6 +94,8 @@ func (h *CreateUserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			RemoteSourceName:           req.CreateUserLARequest.RemoteSourceName,
 			RemoteUserID:               req.CreateUserLARequest.RemoteUserID,
 			RemoteUserName:             req.CreateUserLARequest.RemoteUserName,
+			RemoteUserDisplayName:      req.CreateUserLARequest.RemoteUserDisplayName,
+			RemoteUserAvatarURL:        req.CreateUserLARequest.RemoteUserAvatarURL,
 			UserAccessToken:            req.CreateUserLARequest.UserAccessToken,
 			Oauth2AccessToken:          req.CreateUserLARequest.Oauth2AccessToken,
 			Oauth2RefreshToken:         req.CreateUserLARequest.Oauth2RefreshToken,
@@ -332,6 +334,8 @@ func (h *CreateUserLAHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		RemoteSourceName:           req.RemoteSourceName,
 		RemoteUserID:               req.RemoteUserID,
 		RemoteUserName:             req.RemoteUserName,
+		RemoteUserDisplayName:      req.RemoteUserDisplayName,
+		RemoteUserAvatarURL:        req.RemoteUserAvatarURL,
 		UserAccessToken:            req.UserAccessToken,
 		Oauth2AccessToken:          req.Oauth2AccessToken,
 		Oauth2RefreshToken:         req.Oauth2RefreshToken,
@@ -399,6 +403,8 @@ func (h *UpdateUserLAHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		LinkedAccountID:            linkedAccountID,
 		RemoteUserID:               req.RemoteUserID,
 		RemoteUserName:             req.RemoteUserName,
+		RemoteUserDisplayName:      req.RemoteUserDisplayName,
+		RemoteUserAvatarURL:        req.RemoteUserAvatarURL,
 		UserAccessToken:            req.UserAccessToken,
 		Oauth2AccessToken:          req.Oauth2AccessToken,
 		Oauth2RefreshToken:         req.Oauth2RefreshToken,
@@ -501,6 +507,37 @@ func (h *DeleteUserTokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+type UpdateUserTokenLastUsedHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewUpdateUserTokenLastUsedHandler(log zerolog.Logger, ah *action.ActionHandler) *UpdateUserTokenLastUsedHandler {
+	return &UpdateUserTokenLastUsedHandler{log: log, ah: ah}
+}
+
+func (h *UpdateUserTokenLastUsedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	userRef := vars["userref"]
+
+	var req csapitypes.UpdateUserTokenLastUsedRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	err := h.ah.UpdateUserTokenLastUsed(ctx, userRef, req.Token, req.LastUsedTime)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+	if err := util.HTTPResponse(w, http.StatusNoContent, nil); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
 func userOrgsResponse(userOrg *action.UserOrgsResponse) *csapitypes.UserOrgsResponse {
 	return &csapitypes.UserOrgsResponse{
 		Organization: userOrg.Organization,
@@ -537,3 +574,70 @@ func (h *UserOrgsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.log.Err(err).Send()
 	}
 }
+
+type UserPreferencesHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewUserPreferencesHandler(log zerolog.Logger, ah *action.ActionHandler) *UserPreferencesHandler {
+	return &UserPreferencesHandler{log: log, ah: ah}
+}
+
+func (h *UserPreferencesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	userRef := vars["userref"]
+
+	userPreferences, err := h.ah.GetUserPreferences(ctx, userRef)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, userPreferences); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type UpdateUserPreferencesHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewUpdateUserPreferencesHandler(log zerolog.Logger, ah *action.ActionHandler) *UpdateUserPreferencesHandler {
+	return &UpdateUserPreferencesHandler{log: log, ah: ah}
+}
+
+func (h *UpdateUserPreferencesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	userRef := vars["userref"]
+
+	var req *csapitypes.UpdateUserPreferencesRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	creq := &action.UpdateUserPreferencesRequest{
+		DefaultOrgRef:     req.DefaultOrgRef,
+		DefaultProjectRef: req.DefaultProjectRef,
+		Timezone:          req.Timezone,
+		DateFormat:        req.DateFormat,
+		UISettings:        req.UISettings,
+	}
+
+	userPreferences, err := h.ah.UpdateUserPreferences(ctx, userRef, creq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, userPreferences); err != nil {
+		h.log.Err(err).Send()
+	}
+}
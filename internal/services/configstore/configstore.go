@@ -26,6 +26,7 @@ import (
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/lock"
 	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/config"
 	action "agola.io/agola/internal/services/configstore/action"
 	"agola.io/agola/internal/services/configstore/api"
@@ -80,9 +81,12 @@ type Configstore struct {
 	lf              lock.LockFactory
 	ah              *action.ActionHandler
 	maintenanceMode bool
+	serviceAuth     *common.ServiceAuth
 }
 
-func NewConfigstore(ctx context.Context, log zerolog.Logger, c *config.Configstore) (*Configstore, error) {
+func NewConfigstore(ctx context.Context, log zerolog.Logger, gc *config.Config) (*Configstore, error) {
+	c := &gc.Configstore
+
 	if c.Debug {
 		log = log.Level(zerolog.DebugLevel)
 	}
@@ -92,10 +96,16 @@ func NewConfigstore(ctx context.Context, log zerolog.Logger, c *config.Configsto
 		return nil, errors.WithStack(err)
 	}
 
+	serviceAuth, err := common.NewServiceAuth(&gc.InternalServicesAuth, common.ServiceConfigstore)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	cs := &Configstore{
-		log: log,
-		c:   c,
-		ost: ost,
+		log:         log,
+		c:           c,
+		ost:         ost,
+		serviceAuth: serviceAuth,
 	}
 
 	sdb, err := sql.NewDB(c.DB.Type, c.DB.ConnString)
@@ -147,6 +157,10 @@ func (s *Configstore) setupDefaultRouter() http.Handler {
 	createProjectHandler := api.NewCreateProjectHandler(s.log, s.ah, s.d)
 	updateProjectHandler := api.NewUpdateProjectHandler(s.log, s.ah, s.d)
 	deleteProjectHandler := api.NewDeleteProjectHandler(s.log, s.ah)
+	undeleteProjectHandler := api.NewUndeleteProjectHandler(s.log, s.ah, s.d)
+	projectPolledRefsHandler := api.NewProjectPolledRefsHandler(s.log, s.ah)
+	updateProjectPolledRefsHandler := api.NewUpdateProjectPolledRefsHandler(s.log, s.ah)
+	deletedProjectsHandler := api.NewDeletedProjectsHandler(s.log, s.ah, s.d)
 
 	secretsHandler := api.NewSecretsHandler(s.log, s.ah, s.d)
 	createSecretHandler := api.NewCreateSecretHandler(s.log, s.ah)
@@ -172,12 +186,16 @@ func (s *Configstore) setupDefaultRouter() http.Handler {
 	userTokensHandler := api.NewUserTokensHandler(s.log, s.ah)
 	createUserTokenHandler := api.NewCreateUserTokenHandler(s.log, s.ah)
 	deleteUserTokenHandler := api.NewDeleteUserTokenHandler(s.log, s.ah)
+	updateUserTokenLastUsedHandler := api.NewUpdateUserTokenLastUsedHandler(s.log, s.ah)
+	userPreferencesHandler := api.NewUserPreferencesHandler(s.log, s.ah)
+	updateUserPreferencesHandler := api.NewUpdateUserPreferencesHandler(s.log, s.ah)
 
 	userOrgsHandler := api.NewUserOrgsHandler(s.log, s.ah)
 
 	orgHandler := api.NewOrgHandler(s.log, s.d)
 	orgsHandler := api.NewOrgsHandler(s.log, s.d)
 	createOrgHandler := api.NewCreateOrgHandler(s.log, s.ah)
+	updateOrgHandler := api.NewUpdateOrgHandler(s.log, s.ah)
 	deleteOrgHandler := api.NewDeleteOrgHandler(s.log, s.ah)
 
 	orgMembersHandler := api.NewOrgMembersHandler(s.log, s.ah)
@@ -189,6 +207,11 @@ func (s *Configstore) setupDefaultRouter() http.Handler {
 	createRemoteSourceHandler := api.NewCreateRemoteSourceHandler(s.log, s.ah)
 	updateRemoteSourceHandler := api.NewUpdateRemoteSourceHandler(s.log, s.ah)
 	deleteRemoteSourceHandler := api.NewDeleteRemoteSourceHandler(s.log, s.ah)
+	remoteSourceProjectsHandler := api.NewRemoteSourceProjectsHandler(s.log, s.ah, s.d)
+
+	announcementsHandler := api.NewAnnouncementsHandler(s.log, s.ah)
+	createAnnouncementHandler := api.NewCreateAnnouncementHandler(s.log, s.ah)
+	expireAnnouncementHandler := api.NewExpireAnnouncementHandler(s.log, s.ah)
 
 	router := mux.NewRouter()
 	apirouter := router.PathPrefix("/api/v1alpha").Subrouter().UseEncodedPath()
@@ -204,6 +227,10 @@ func (s *Configstore) setupDefaultRouter() http.Handler {
 	apirouter.Handle("/projects", createProjectHandler).Methods("POST")
 	apirouter.Handle("/projects/{projectref}", updateProjectHandler).Methods("PUT")
 	apirouter.Handle("/projects/{projectref}", deleteProjectHandler).Methods("DELETE")
+	apirouter.Handle("/projects/{projectref}/undelete", undeleteProjectHandler).Methods("PUT")
+	apirouter.Handle("/projects/{projectref}/polledrefs", projectPolledRefsHandler).Methods("GET")
+	apirouter.Handle("/projects/{projectref}/polledrefs", updateProjectPolledRefsHandler).Methods("PUT")
+	apirouter.Handle("/deletedprojects", deletedProjectsHandler).Methods("GET")
 
 	apirouter.Handle("/projectgroups/{projectgroupref}/secrets", secretsHandler).Methods("GET")
 	apirouter.Handle("/projects/{projectref}/secrets", secretsHandler).Methods("GET")
@@ -236,12 +263,16 @@ func (s *Configstore) setupDefaultRouter() http.Handler {
 	apirouter.Handle("/users/{userref}/tokens", userTokensHandler).Methods("GET")
 	apirouter.Handle("/users/{userref}/tokens", createUserTokenHandler).Methods("POST")
 	apirouter.Handle("/users/{userref}/tokens/{tokenname}", deleteUserTokenHandler).Methods("DELETE")
+	apirouter.Handle("/users/{userref}/tokens/lastused", updateUserTokenLastUsedHandler).Methods("PUT")
+	apirouter.Handle("/users/{userref}/preferences", userPreferencesHandler).Methods("GET")
+	apirouter.Handle("/users/{userref}/preferences", updateUserPreferencesHandler).Methods("PUT")
 
 	apirouter.Handle("/users/{userref}/orgs", userOrgsHandler).Methods("GET")
 
 	apirouter.Handle("/orgs/{orgref}", orgHandler).Methods("GET")
 	apirouter.Handle("/orgs", orgsHandler).Methods("GET")
 	apirouter.Handle("/orgs", createOrgHandler).Methods("POST")
+	apirouter.Handle("/orgs/{orgref}", updateOrgHandler).Methods("PUT")
 	apirouter.Handle("/orgs/{orgref}", deleteOrgHandler).Methods("DELETE")
 	apirouter.Handle("/orgs/{orgref}/members", orgMembersHandler).Methods("GET")
 	apirouter.Handle("/orgs/{orgref}/members/{userref}", addOrgMemberHandler).Methods("PUT")
@@ -252,6 +283,11 @@ func (s *Configstore) setupDefaultRouter() http.Handler {
 	apirouter.Handle("/remotesources", createRemoteSourceHandler).Methods("POST")
 	apirouter.Handle("/remotesources/{remotesourceref}", updateRemoteSourceHandler).Methods("PUT")
 	apirouter.Handle("/remotesources/{remotesourceref}", deleteRemoteSourceHandler).Methods("DELETE")
+	apirouter.Handle("/remotesources/{remotesourceref}/projects", remoteSourceProjectsHandler).Methods("GET")
+
+	apirouter.Handle("/announcements", announcementsHandler).Methods("GET")
+	apirouter.Handle("/announcements", createAnnouncementHandler).Methods("POST")
+	apirouter.Handle("/announcements/{announcementid}/expire", expireAnnouncementHandler).Methods("PUT")
 
 	apirouter.Handle("/maintenance", maintenanceModeHandler).Methods("PUT", "DELETE")
 
@@ -336,19 +372,24 @@ func (s *Configstore) run(ctx context.Context) error {
 		// TODO(sgotti) wait for all goroutines exiting
 	}
 
+	serviceAuthorizations := common.ServiceAuthorizations{
+		common.ServiceGateway:      nil,
+		common.ServiceNotification: nil,
+		// the scheduler only lists the projects to poll
+		common.ServiceScheduler: {
+			{Methods: []string{"GET"}, Path: "/api/v1alpha/remotesources"},
+			{Methods: []string{"GET"}, Path: "/api/v1alpha/remotesources/*/projects"},
+		},
+	}
+
 	httpServer := http.Server{
-		Addr:      s.c.Web.ListenAddress,
-		Handler:   mainrouter,
+		Handler:   s.serviceAuth.NewServiceAuthHandler(s.log, serviceAuthorizations, mainrouter),
 		TLSConfig: tlsConfig,
 	}
 
 	lerrCh := make(chan error, 1)
 	util.GoWait(&wg, func() {
-		if !s.c.Web.TLS {
-			lerrCh <- httpServer.ListenAndServe()
-		} else {
-			lerrCh <- httpServer.ListenAndServeTLS("", "")
-		}
+		lerrCh <- util.ListenAndServe(&httpServer, s.c.Web.Addresses(), s.c.Web.TLS)
 	})
 	defer httpServer.Close()
 
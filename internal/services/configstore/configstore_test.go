@@ -22,6 +22,7 @@ import (
 	"net"
 	"path"
 	"path/filepath"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -68,7 +69,7 @@ func setupConfigstore(ctx context.Context, t *testing.T, log zerolog.Logger, dir
 	csConfig.DataDir = csDir
 	csConfig.Web.ListenAddress = net.JoinHostPort(listenAddress, port)
 
-	cs, err := NewConfigstore(ctx, log, &csConfig)
+	cs, err := NewConfigstore(ctx, log, &config.Config{Configstore: csConfig})
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -433,6 +434,94 @@ func TestUser(t *testing.T) {
 			t.Fatalf("expected %d users, got %d", len(prevUsers)+1, len(users))
 		}
 	})
+
+	t.Run("delete user", func(t *testing.T) {
+		if err := cs.ah.DeleteUser(ctx, "user01"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		users, err := getUsers(ctx, cs)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		for _, user := range users {
+			if user.Name == "user01" {
+				t.Fatalf("expected user %q to be deleted", "user01")
+			}
+		}
+
+		// the user name must be available again
+		if _, err := cs.ah.CreateUser(ctx, &action.CreateUserRequest{UserName: "user01"}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+}
+
+func TestUserTokenLastUsed(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	cs := setupConfigstore(ctx, t, log, dir)
+
+	t.Logf("starting cs")
+	go func() {
+		_ = cs.Run(ctx)
+	}()
+
+	if _, err := cs.ah.CreateUser(ctx, &action.CreateUserRequest{UserName: "user01"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := cs.ah.CreateUser(ctx, &action.CreateUserRequest{UserName: "user02"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	token, err := cs.ah.CreateUserToken(ctx, "user01", "token01")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	getLastUsedTime := func() time.Time {
+		tokens, err := cs.ah.GetUserTokens(ctx, "user01")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		return tokens[0].LastUsedTime
+	}
+
+	if lastUsedTime := getLastUsedTime(); !lastUsedTime.IsZero() {
+		t.Fatalf("expected zero last used time, got %v", lastUsedTime)
+	}
+
+	now := time.Now().Truncate(time.Second)
+
+	t.Run("update token last used time", func(t *testing.T) {
+		if err := cs.ah.UpdateUserTokenLastUsed(ctx, "user01", token.Value, now); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if lastUsedTime := getLastUsedTime(); !lastUsedTime.Equal(now) {
+			t.Fatalf("expected last used time %v, got %v", now, lastUsedTime)
+		}
+	})
+
+	t.Run("older last used time is ignored", func(t *testing.T) {
+		if err := cs.ah.UpdateUserTokenLastUsed(ctx, "user01", token.Value, now.Add(-time.Hour)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if lastUsedTime := getLastUsedTime(); !lastUsedTime.Equal(now) {
+			t.Fatalf("expected last used time %v, got %v", now, lastUsedTime)
+		}
+	})
+
+	t.Run("update token of another user", func(t *testing.T) {
+		expectedErr := fmt.Sprintf("token for user %q doesn't exist", "user02")
+		err := cs.ah.UpdateUserTokenLastUsed(ctx, "user02", token.Value, now.Add(time.Hour))
+		if err == nil {
+			t.Fatalf("expected error %v, got nil err", expectedErr)
+		}
+		if err.Error() != expectedErr {
+			t.Fatalf("expected err %v, got err: %v", expectedErr, err)
+		}
+	})
 }
 
 func TestProjectGroupsAndProjectsCreate(t *testing.T) {
@@ -630,6 +719,224 @@ func TestProjectUpdate(t *testing.T) {
 	})
 }
 
+func TestProjectSoftDelete(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	cs := setupConfigstore(ctx, t, log, dir)
+
+	t.Logf("starting cs")
+	go func() {
+		_ = cs.Run(ctx)
+	}()
+
+	user, err := cs.ah.CreateUser(ctx, &action.CreateUserRequest{UserName: "user01"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	projectRef := path.Join("user", user.Name, "project01")
+	p01 := &action.CreateUpdateProjectRequest{Name: "project01", Parent: types.Parent{Kind: types.ObjectKindProjectGroup, ID: path.Join("user", user.Name)}, Visibility: types.VisibilityPublic, RemoteRepositoryConfigType: types.RemoteRepositoryConfigTypeManual}
+	project, err := cs.ah.CreateProject(ctx, p01)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if _, err := cs.ah.SoftDeleteProject(ctx, projectRef); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	t.Run("deleted project is hidden", func(t *testing.T) {
+		projects, err := cs.ah.GetProjectGroupProjects(ctx, path.Join("user", user.Name), nil)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(projects) != 0 {
+			t.Fatalf("expected 0 projects, got %d projects", len(projects))
+		}
+
+		deletedProjects, err := cs.ah.GetDeletedProjects(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(deletedProjects) != 1 || deletedProjects[0].ID != project.ID {
+			t.Fatalf("expected deleted project %q, got %v", project.ID, deletedProjects)
+		}
+	})
+	t.Run("delete an already deleted project", func(t *testing.T) {
+		expectedErr := fmt.Sprintf("project %q doesn't exist", projectRef)
+		_, err := cs.ah.SoftDeleteProject(ctx, projectRef)
+		if err == nil || err.Error() != expectedErr {
+			t.Fatalf("expected err %v, got err: %v", expectedErr, err)
+		}
+	})
+	t.Run("create project with the same name of a deleted project", func(t *testing.T) {
+		expectedErr := fmt.Sprintf("project with name %q, path %q already exists and is pending deletion", "project01", projectRef)
+		_, err := cs.ah.CreateProject(ctx, p01)
+		if err == nil || err.Error() != expectedErr {
+			t.Fatalf("expected err %v, got err: %v", expectedErr, err)
+		}
+	})
+	t.Run("undelete project", func(t *testing.T) {
+		p, err := cs.ah.UndeleteProject(ctx, projectRef)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if p.DeletionTime != nil {
+			t.Fatalf("expected empty deletion time, got %v", p.DeletionTime)
+		}
+
+		projects, err := cs.ah.GetProjectGroupProjects(ctx, path.Join("user", user.Name), nil)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(projects) != 1 {
+			t.Fatalf("expected 1 project, got %d projects", len(projects))
+		}
+
+		deletedProjects, err := cs.ah.GetDeletedProjects(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(deletedProjects) != 0 {
+			t.Fatalf("expected 0 deleted projects, got %d deleted projects", len(deletedProjects))
+		}
+	})
+	t.Run("undelete a not deleted project", func(t *testing.T) {
+		expectedErr := fmt.Sprintf("project %q isn't deleted", projectRef)
+		_, err := cs.ah.UndeleteProject(ctx, projectRef)
+		if err == nil || err.Error() != expectedErr {
+			t.Fatalf("expected err %v, got err: %v", expectedErr, err)
+		}
+	})
+}
+
+func TestRemoteSourceProjects(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	cs := setupConfigstore(ctx, t, log, dir)
+
+	t.Logf("starting cs")
+	go func() {
+		_ = cs.Run(ctx)
+	}()
+
+	remoteSources := []*types.RemoteSource{}
+	for _, name := range []string{"rs01", "rs02"} {
+		rs, err := cs.ah.CreateRemoteSource(ctx, &action.CreateUpdateRemoteSourceRequest{
+			Name:               name,
+			APIURL:             "https://api.example.com",
+			Type:               types.RemoteSourceTypeGitea,
+			AuthType:           types.RemoteSourceAuthTypeOauth2,
+			Oauth2ClientID:     "clientid",
+			Oauth2ClientSecret: "clientsecret",
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		remoteSources = append(remoteSources, rs)
+	}
+
+	user, err := cs.ah.CreateUser(ctx, &action.CreateUserRequest{UserName: "user01"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	linkedAccounts := []*types.LinkedAccount{}
+	for _, rs := range remoteSources {
+		la, err := cs.ah.CreateUserLA(ctx, &action.CreateUserLARequest{UserRef: user.Name, RemoteSourceName: rs.Name, RemoteUserID: "1", RemoteUserName: "user01"})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		linkedAccounts = append(linkedAccounts, la)
+	}
+
+	createProject := func(name string, rsIndex int, repoPath string) *types.Project {
+		project, err := cs.ah.CreateProject(ctx, &action.CreateUpdateProjectRequest{
+			Name:                       name,
+			Parent:                     types.Parent{Kind: types.ObjectKindProjectGroup, ID: path.Join("user", user.Name)},
+			Visibility:                 types.VisibilityPublic,
+			RemoteRepositoryConfigType: types.RemoteRepositoryConfigTypeRemoteSource,
+			RemoteSourceID:             remoteSources[rsIndex].ID,
+			LinkedAccountID:            linkedAccounts[rsIndex].ID,
+			RepositoryID:               name,
+			RepositoryPath:             repoPath,
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		return project
+	}
+
+	p01 := createProject("project01", 0, "org01/repo01")
+	p02 := createProject("project02", 0, "org01/Repo01")
+	p03 := createProject("project03", 0, "org01/repo02")
+	createProject("project04", 1, "org01/repo01")
+
+	if _, err := cs.ah.SoftDeleteProject(ctx, path.Join("user", user.Name, p03.Name)); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	projectIDs := func(projects []*types.Project) []string {
+		ids := []string{}
+		for _, p := range projects {
+			ids = append(ids, p.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	tests := []struct {
+		name           string
+		repositoryPath string
+		want           []*types.Project
+	}{
+		{
+			name: "all remote source projects",
+			want: []*types.Project{p01, p02},
+		},
+		{
+			name:           "remote source repository projects",
+			repositoryPath: "org01/repo01",
+			want:           []*types.Project{p01, p02},
+		},
+		{
+			name:           "remote source repository projects matched case insensitively",
+			repositoryPath: "ORG01/REPO01",
+			want:           []*types.Project{p01, p02},
+		},
+		{
+			name:           "deleted projects are ignored",
+			repositoryPath: "org01/repo02",
+			want:           []*types.Project{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projects, err := cs.ah.GetRemoteSourceProjects(ctx, remoteSources[0].Name, tt.repositoryPath)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if diff := cmp.Diff(projectIDs(tt.want), projectIDs(projects)); diff != "" {
+				t.Fatalf("projects mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("deleted projects", func(t *testing.T) {
+		projects, err := cs.ah.GetDeletedProjects(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if diff := cmp.Diff([]string{p03.ID}, projectIDs(projects)); diff != "" {
+			t.Fatalf("deleted projects mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
 func TestProjectGroupUpdate(t *testing.T) {
 	dir := t.TempDir()
 	ctx := context.Background()
@@ -900,7 +1207,7 @@ func TestProjectGroupDeleteDontSeeOldChildObjects(t *testing.T) {
 	}
 
 	// Get by projectgroup id
-	projects, err := cs.ah.GetProjectGroupProjects(ctx, spg01.ID)
+	projects, err := cs.ah.GetProjectGroupProjects(ctx, spg01.ID, nil)
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -909,7 +1216,7 @@ func TestProjectGroupDeleteDontSeeOldChildObjects(t *testing.T) {
 	}
 
 	// Get by projectgroup path
-	projects, err = cs.ah.GetProjectGroupProjects(ctx, path.Join("org", org.Name, pg01.Name, spg01.Name))
+	projects, err = cs.ah.GetProjectGroupProjects(ctx, path.Join("org", org.Name, pg01.Name, spg01.Name), nil)
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -1147,6 +1454,49 @@ func TestRemoteSource(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "test create remote source with ssh endpoint",
+			f: func(ctx context.Context, t *testing.T, cs *Configstore) {
+				rsreq := &action.CreateUpdateRemoteSourceRequest{
+					Name:               "rs01",
+					APIURL:             "https://api.example.com",
+					Type:               types.RemoteSourceTypeGitea,
+					AuthType:           types.RemoteSourceAuthTypeOauth2,
+					Oauth2ClientID:     "clientid",
+					Oauth2ClientSecret: "clientsecret",
+					SSHEndpoint:        "ssh.example.com:2222",
+				}
+				rs, err := cs.ah.CreateRemoteSource(ctx, rsreq)
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				if rs.SSHEndpoint != rsreq.SSHEndpoint {
+					t.Fatalf("expected ssh endpoint %q, got %q", rsreq.SSHEndpoint, rs.SSHEndpoint)
+				}
+			},
+		},
+		{
+			name: "test create remote source with invalid ssh endpoint",
+			f: func(ctx context.Context, t *testing.T, cs *Configstore) {
+				rsreq := &action.CreateUpdateRemoteSourceRequest{
+					Name:               "rs01",
+					APIURL:             "https://api.example.com",
+					Type:               types.RemoteSourceTypeGitea,
+					AuthType:           types.RemoteSourceAuthTypeOauth2,
+					Oauth2ClientID:     "clientid",
+					Oauth2ClientSecret: "clientsecret",
+					SSHEndpoint:        "git@ssh.example.com",
+				}
+				expectedError := util.NewAPIError(util.ErrBadRequest, errors.Errorf(`invalid remotesource ssh endpoint "git@ssh.example.com": ssh endpoint must be in the host[:port] format`))
+				_, err := cs.ah.CreateRemoteSource(ctx, rsreq)
+				if err == nil {
+					t.Fatalf("expected err: %v, got no error", expectedError.Error())
+				}
+				if err.Error() != expectedError.Error() {
+					t.Fatalf("expected err: %v, got err: %v", expectedError.Error(), err.Error())
+				}
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1166,3 +1516,293 @@ func TestRemoteSource(t *testing.T) {
 		})
 	}
 }
+
+func TestAnnouncements(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	cs := setupConfigstore(ctx, t, log, dir)
+
+	t.Logf("starting cs")
+	go func() { _ = cs.Run(ctx) }()
+
+	expireTime := time.Now().Add(-1 * time.Hour)
+	expired, err := cs.ah.CreateAnnouncement(ctx, &action.CreateAnnouncementRequest{Message: "expired maintenance", ExpireTime: &expireTime})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	active, err := cs.ah.CreateAnnouncement(ctx, &action.CreateAnnouncementRequest{Message: "maintenance tomorrow", Severity: types.AnnouncementSeverityWarning})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	t.Run("test create announcement with invalid severity", func(t *testing.T) {
+		expectedError := util.NewAPIError(util.ErrBadRequest, errors.Errorf(`invalid announcement severity "fatal"`))
+		_, err := cs.ah.CreateAnnouncement(ctx, &action.CreateAnnouncementRequest{Message: "message", Severity: "fatal"})
+		if err == nil {
+			t.Fatalf("expected err: %v, got no error", expectedError.Error())
+		}
+		if err.Error() != expectedError.Error() {
+			t.Fatalf("expected err: %v, got err: %v", expectedError.Error(), err.Error())
+		}
+	})
+
+	t.Run("test get announcements", func(t *testing.T) {
+		announcements, err := cs.ah.GetAnnouncements(ctx, false)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(announcements) != 2 {
+			t.Fatalf("expected 2 announcements, got %d", len(announcements))
+		}
+		if expired.Severity != types.AnnouncementSeverityInfo {
+			t.Fatalf("expected default severity %q, got %q", types.AnnouncementSeverityInfo, expired.Severity)
+		}
+	})
+
+	t.Run("test get active announcements", func(t *testing.T) {
+		announcements, err := cs.ah.GetAnnouncements(ctx, true)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(announcements) != 1 || announcements[0].ID != active.ID {
+			t.Fatalf("expected only announcement %q to be active, got: %v", active.ID, announcements)
+		}
+	})
+
+	t.Run("test expire announcement", func(t *testing.T) {
+		if _, err := cs.ah.ExpireAnnouncement(ctx, active.ID); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		announcements, err := cs.ah.GetAnnouncements(ctx, true)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(announcements) != 0 {
+			t.Fatalf("expected no active announcements, got %d", len(announcements))
+		}
+	})
+}
+
+func TestUserPreferences(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	cs := setupConfigstore(ctx, t, log, dir)
+
+	t.Logf("starting cs")
+	go func() { _ = cs.Run(ctx) }()
+
+	user, err := cs.ah.CreateUser(ctx, &action.CreateUserRequest{UserName: "user01"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	org, err := cs.ah.CreateOrg(ctx, &action.CreateOrgRequest{Name: "org01", Visibility: types.VisibilityPublic})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	project, err := cs.ah.CreateProject(ctx, &action.CreateUpdateProjectRequest{Name: "project01", Parent: types.Parent{Kind: types.ObjectKindProjectGroup, ID: path.Join("org", org.Name)}, Visibility: types.VisibilityPublic, RemoteRepositoryConfigType: types.RemoteRepositoryConfigTypeManual})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	t.Run("test get user preferences when not set", func(t *testing.T) {
+		userPreferences, err := cs.ah.GetUserPreferences(ctx, user.Name)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if userPreferences.UserID != user.ID || userPreferences.DefaultProjectID != "" {
+			t.Fatalf("expected empty user preferences, got: %v", userPreferences)
+		}
+	})
+
+	t.Run("test update user preferences", func(t *testing.T) {
+		req := &action.UpdateUserPreferencesRequest{
+			DefaultOrgRef:     org.Name,
+			DefaultProjectRef: path.Join("org", org.Name, project.Name),
+			Timezone:          "UTC",
+			DateFormat:        "2006-01-02",
+			UISettings:        []byte(`{"theme":"dark"}`),
+		}
+		if _, err := cs.ah.UpdateUserPreferences(ctx, user.Name, req); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		// update again to check that the existing preferences are replaced
+		req.DateFormat = "02/01/2006"
+		if _, err := cs.ah.UpdateUserPreferences(ctx, user.ID, req); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		userPreferences, err := cs.ah.GetUserPreferences(ctx, user.Name)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if userPreferences.DefaultOrgID != org.ID {
+			t.Fatalf("expected default org %q, got %q", org.ID, userPreferences.DefaultOrgID)
+		}
+		if userPreferences.DefaultProjectID != project.ID {
+			t.Fatalf("expected default project %q, got %q", project.ID, userPreferences.DefaultProjectID)
+		}
+		if userPreferences.DateFormat != "02/01/2006" {
+			t.Fatalf("expected date format %q, got %q", "02/01/2006", userPreferences.DateFormat)
+		}
+		if string(userPreferences.UISettings) != `{"theme":"dark"}` {
+			t.Fatalf("unexpected ui settings: %s", userPreferences.UISettings)
+		}
+	})
+
+	t.Run("test update user preferences with invalid values", func(t *testing.T) {
+		tests := []struct {
+			name          string
+			req           *action.UpdateUserPreferencesRequest
+			expectedError error
+		}{
+			{
+				name:          "invalid timezone",
+				req:           &action.UpdateUserPreferencesRequest{Timezone: "Not/Existing"},
+				expectedError: util.NewAPIError(util.ErrBadRequest, errors.Errorf(`invalid timezone "Not/Existing"`)),
+			},
+			{
+				name:          "ui settings not an object",
+				req:           &action.UpdateUserPreferencesRequest{UISettings: []byte(`["dark"]`)},
+				expectedError: util.NewAPIError(util.ErrBadRequest, errors.Errorf("ui settings must be a json object")),
+			},
+			{
+				name:          "not existing default project",
+				req:           &action.UpdateUserPreferencesRequest{DefaultProjectRef: "org/org01/notexisting"},
+				expectedError: util.NewAPIError(util.ErrBadRequest, errors.Errorf(`project "org/org01/notexisting" doesn't exist`)),
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := cs.ah.UpdateUserPreferences(ctx, user.Name, tt.req)
+				if err == nil {
+					t.Fatalf("expected err: %v, got no error", tt.expectedError.Error())
+				}
+				if err.Error() != tt.expectedError.Error() {
+					t.Fatalf("expected err: %v, got err: %v", tt.expectedError.Error(), err.Error())
+				}
+			})
+		}
+	})
+
+	t.Run("test delete user removes user preferences", func(t *testing.T) {
+		if err := cs.ah.DeleteUser(ctx, user.Name); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		var userPreferences *types.UserPreferences
+		err := cs.d.Do(ctx, func(tx *sql.Tx) error {
+			var err error
+			userPreferences, err = cs.d.GetUserPreferences(tx, user.ID)
+			return errors.WithStack(err)
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if userPreferences != nil {
+			t.Fatalf("expected user preferences to be deleted")
+		}
+	})
+}
+
+func TestProjectPolledRefs(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	cs := setupConfigstore(ctx, t, log, dir)
+
+	t.Logf("starting cs")
+	go func() {
+		_ = cs.Run(ctx)
+	}()
+
+	rs, err := cs.ah.CreateRemoteSource(ctx, &action.CreateUpdateRemoteSourceRequest{
+		Name:     "rs01",
+		APIURL:   "ssh://git@example.com:2222",
+		Type:     types.RemoteSourceTypeGit,
+		AuthType: types.RemoteSourceAuthTypePassword,
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	user, err := cs.ah.CreateUser(ctx, &action.CreateUserRequest{UserName: "user01"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	la, err := cs.ah.CreateUserLA(ctx, &action.CreateUserLARequest{UserRef: user.Name, RemoteSourceName: rs.Name, RemoteUserID: "user01", RemoteUserName: "user01"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	project, err := cs.ah.CreateProject(ctx, &action.CreateUpdateProjectRequest{
+		Name:                       "project01",
+		Parent:                     types.Parent{Kind: types.ObjectKindProjectGroup, ID: path.Join("user", user.Name)},
+		Visibility:                 types.VisibilityPublic,
+		RemoteRepositoryConfigType: types.RemoteRepositoryConfigTypeRemoteSource,
+		RemoteSourceID:             rs.ID,
+		LinkedAccountID:            la.ID,
+		RepositoryID:               "repo01",
+		RepositoryPath:             "repo01",
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	t.Run("project never polled", func(t *testing.T) {
+		projectPolledRefs, err := cs.ah.GetProjectPolledRefs(ctx, project.ID)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if projectPolledRefs != nil {
+			t.Fatalf("expected nil polled refs, got %v", projectPolledRefs)
+		}
+	})
+
+	t.Run("update project polled refs", func(t *testing.T) {
+		for _, refs := range []map[string]string{
+			{"refs/heads/master": "sha01"},
+			{"refs/heads/master": "sha02", "refs/tags/v1.0": "sha02"},
+		} {
+			if _, err := cs.ah.UpdateProjectPolledRefs(ctx, project.ID, refs); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			projectPolledRefs, err := cs.ah.GetProjectPolledRefs(ctx, project.ID)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if diff := cmp.Diff(refs, projectPolledRefs.Refs); diff != "" {
+				t.Fatalf("polled refs mismatch (-want +got):\n%s", diff)
+			}
+		}
+	})
+
+	t.Run("not existent project", func(t *testing.T) {
+		if _, err := cs.ah.UpdateProjectPolledRefs(ctx, "notexistent", map[string]string{}); !util.APIErrorIs(err, util.ErrNotExist) {
+			t.Fatalf("expected not exist error, got: %v", err)
+		}
+	})
+
+	t.Run("polled refs are removed with the project", func(t *testing.T) {
+		if err := cs.ah.DeleteProject(ctx, project.ID); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		var projectPolledRefs *types.ProjectPolledRefs
+		err := cs.d.Do(ctx, func(tx *sql.Tx) error {
+			var err error
+			projectPolledRefs, err = cs.d.GetProjectPolledRefs(tx, project.ID)
+			return errors.WithStack(err)
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if projectPolledRefs != nil {
+			t.Fatalf("expected polled refs to be deleted")
+		}
+	})
+}
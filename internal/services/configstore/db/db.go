@@ -22,8 +22,8 @@ import (
 //go:generate ../../../../tools/bin/generators -component configstore
 
 const (
-	dataTablesVersion  = 1
-	queryTablesVersion = 1
+	dataTablesVersion  = 4
+	queryTablesVersion = 2
 )
 
 var dstmts = []string{
@@ -38,6 +38,9 @@ var dstmts = []string{
 	"create table if not exists project (id varchar, revision bigint, data bytea, PRIMARY KEY (id))",
 	"create table if not exists secret (id varchar, revision bigint, data bytea, PRIMARY KEY (id))",
 	"create table if not exists variable (id varchar, revision bigint, data bytea, PRIMARY KEY (id))",
+	"create table if not exists announcement (id varchar, revision bigint, data bytea, PRIMARY KEY (id))",
+	"create table if not exists userpreferences (id varchar, revision bigint, data bytea, PRIMARY KEY (id))",
+	"create table if not exists projectpolledrefs (id varchar, revision bigint, data bytea, PRIMARY KEY (id))",
 }
 
 var qstmts = []string{
@@ -49,9 +52,14 @@ var qstmts = []string{
 	"create table if not exists org_q (id varchar, revision bigint, name varchar, data bytea, PRIMARY KEY (id))",
 	"create table if not exists orgmember_q (id varchar, revision bigint, org_id varchar, user_id varchar, data bytea, PRIMARY KEY (id))",
 	"create table if not exists projectgroup_q (id varchar, revision bigint, name varchar, parent_id varchar, parent_kind varchar, data bytea, PRIMARY KEY (id))",
-	"create table if not exists project_q (id varchar, revision bigint, name varchar, parent_id varchar, parent_kind varchar, data bytea, PRIMARY KEY (id))",
+	"create table if not exists project_q (id varchar, revision bigint, name varchar, parent_id varchar, parent_kind varchar, remotesource_id varchar, repository_path varchar, deleted boolean, data bytea, PRIMARY KEY (id))",
+	"create index if not exists project_q_remotesource_id_repository_path on project_q (remotesource_id, repository_path)",
+	"create index if not exists project_q_deleted on project_q (deleted)",
 	"create table if not exists secret_q (id varchar, revision bigint, name varchar, parent_id varchar, parent_kind varchar, data bytea, PRIMARY KEY (id))",
 	"create table if not exists variable_q (id varchar, revision bigint, name varchar, parent_id varchar, parent_kind varchar, data bytea, PRIMARY KEY (id))",
+	"create table if not exists announcement_q (id varchar, revision bigint, data bytea, PRIMARY KEY (id))",
+	"create table if not exists userpreferences_q (id varchar, revision bigint, user_id varchar, data bytea, PRIMARY KEY (id))",
+	"create table if not exists projectpolledrefs_q (id varchar, revision bigint, project_id varchar, data bytea, PRIMARY KEY (id))",
 }
 
 // denormalized tables for querying, can be rebuilt by query tables.
@@ -149,6 +157,12 @@ func (d *DB) UnmarshalObject(data []byte) (stypes.Object, error) {
 		obj = &types.Secret{}
 	case types.VariableKind:
 		obj = &types.Variable{}
+	case types.AnnouncementKind:
+		obj = &types.Announcement{}
+	case types.UserPreferencesKind:
+		obj = &types.UserPreferences{}
+	case types.ProjectPolledRefsKind:
+		obj = &types.ProjectPolledRefs{}
 	default:
 		panic(errors.Errorf("unknown object kind %q", om.Kind))
 	}
@@ -181,6 +195,12 @@ func (d *DB) InsertRawObject(tx *sql.Tx, obj stypes.Object) ([]byte, error) {
 		return d.insertRawSecretData(tx, obj.(*types.Secret))
 	case types.VariableKind:
 		return d.insertRawVariableData(tx, obj.(*types.Variable))
+	case types.AnnouncementKind:
+		return d.insertRawAnnouncementData(tx, obj.(*types.Announcement))
+	case types.UserPreferencesKind:
+		return d.insertRawUserPreferencesData(tx, obj.(*types.UserPreferences))
+	case types.ProjectPolledRefsKind:
+		return d.insertRawProjectPolledRefsData(tx, obj.(*types.ProjectPolledRefs))
 	default:
 		panic(errors.Errorf("unknown object kind %q", obj.GetKind()))
 	}
@@ -380,6 +400,21 @@ func (d *DB) GetUserToken(tx *sql.Tx, userID, tokenName string) (*types.UserToke
 	return userTokens[0], nil
 }
 
+func (d *DB) GetUserTokenByValue(tx *sql.Tx, tokenValue string) (*types.UserToken, error) {
+	q := userTokenQSelect.Where(sq.Eq{"usertoken_q.value": tokenValue})
+	userTokens, _, err := d.fetchUserTokens(tx, q)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(userTokens) > 1 {
+		return nil, errors.Errorf("too many rows returned")
+	}
+	if len(userTokens) == 0 {
+		return nil, nil
+	}
+	return userTokens[0], nil
+}
+
 func (d *DB) GetUserByTokenValue(tx *sql.Tx, tokenValue string) (*types.User, error) {
 	q := userQSelect
 	q = q.Join("usertoken_q on usertoken_q.user_id = user_t_q.id")
@@ -1011,6 +1046,24 @@ func (d *DB) GetProjectGroupProjects(tx *sql.Tx, parentID string) ([]*types.Proj
 	return projects, errors.WithStack(err)
 }
 
+// GetRemoteSourceProjects returns the projects using the remote source. When
+// repositoryPath isn't empty only the projects of that repository (matched case
+// insensitively) are returned.
+func (d *DB) GetRemoteSourceProjects(tx *sql.Tx, remoteSourceID, repositoryPath string) ([]*types.Project, error) {
+	q := projectQSelect.Where(sq.Eq{"remotesource_id": remoteSourceID})
+	if repositoryPath != "" {
+		q = q.Where(sq.Eq{"repository_path": strings.ToLower(repositoryPath)})
+	}
+	projects, _, err := d.fetchProjects(tx, q)
+	return projects, errors.WithStack(err)
+}
+
+func (d *DB) GetDeletedProjects(tx *sql.Tx) ([]*types.Project, error) {
+	q := projectQSelect.Where(sq.Eq{"deleted": true}).OrderBy("id")
+	projects, _, err := d.fetchProjects(tx, q)
+	return projects, errors.WithStack(err)
+}
+
 func (d *DB) GetSecretByID(tx *sql.Tx, secretID string) (*types.Secret, error) {
 	q := secretQSelect.Where(sq.Eq{"id": secretID})
 	secrets, _, err := d.fetchSecrets(tx, q)
@@ -1222,3 +1275,54 @@ func (d *DB) GetAllVariables(tx *sql.Tx) ([]*types.Variable, error) {
 
 	return variables, errors.WithStack(err)
 }
+
+func (d *DB) GetAnnouncement(tx *sql.Tx, announcementID string) (*types.Announcement, error) {
+	q := announcementQSelect.Where(sq.Eq{"id": announcementID})
+	announcements, _, err := d.fetchAnnouncements(tx, q)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(announcements) > 1 {
+		return nil, errors.Errorf("too many rows returned")
+	}
+	if len(announcements) == 0 {
+		return nil, nil
+	}
+	return announcements[0], nil
+}
+
+func (d *DB) GetAnnouncements(tx *sql.Tx) ([]*types.Announcement, error) {
+	q := announcementQSelect.OrderBy("id")
+	announcements, _, err := d.fetchAnnouncements(tx, q)
+	return announcements, errors.WithStack(err)
+}
+
+func (d *DB) GetUserPreferences(tx *sql.Tx, userID string) (*types.UserPreferences, error) {
+	q := userPreferencesQSelect.Where(sq.Eq{"userpreferences_q.user_id": userID})
+	userPreferencesList, _, err := d.fetchUserPreferencess(tx, q)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(userPreferencesList) > 1 {
+		return nil, errors.Errorf("too many rows returned")
+	}
+	if len(userPreferencesList) == 0 {
+		return nil, nil
+	}
+	return userPreferencesList[0], nil
+}
+
+func (d *DB) GetProjectPolledRefs(tx *sql.Tx, projectID string) (*types.ProjectPolledRefs, error) {
+	q := projectPolledRefsQSelect.Where(sq.Eq{"projectpolledrefs_q.project_id": projectID})
+	projectPolledRefsList, _, err := d.fetchProjectPolledRefss(tx, q)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(projectPolledRefsList) > 1 {
+		return nil, errors.Errorf("too many rows returned")
+	}
+	if len(projectPolledRefsList) == 0 {
+		return nil, nil
+	}
+	return projectPolledRefsList[0], nil
+}
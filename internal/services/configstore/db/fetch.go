@@ -671,3 +671,201 @@ func (d *DB) scanVariables(rows *stdsql.Rows) ([]*types.Variable, []string, erro
 	}
 	return vs, ids, nil
 }
+
+func (d *DB) fetchAnnouncements(tx *sql.Tx, q sq.Sqlizer) ([]*types.Announcement, []string, error) {
+	rows, err := d.query(tx, q)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	return d.scanAnnouncements(rows)
+}
+
+func (d *DB) scanAnnouncement(rows *stdsql.Rows, additionalFields []interface{}) (*types.Announcement, string, error) {
+	var id string
+	var revision uint64
+	var data []byte
+	fields := append([]interface{}{&id, &revision, &data}, additionalFields...)
+	if err := rows.Scan(fields...); err != nil {
+		return nil, "", errors.Wrap(err, "failed to scan rows")
+	}
+	v := types.Announcement{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, "", errors.Wrap(err, "failed to unmarshal Announcement")
+		}
+	}
+
+	v.Revision = revision
+
+	return &v, id, nil
+}
+
+func (d *DB) scanAnnouncements(rows *stdsql.Rows) ([]*types.Announcement, []string, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	fieldsNumber := len(cols)
+	if fieldsNumber < 3 {
+		return nil, nil, errors.Errorf("not enough columns (%d < 3)", len(cols))
+	}
+	var additionalFieldsPtr []interface{}
+	if fieldsNumber > 3 {
+		additionalFieldsNumber := fieldsNumber - 3
+		additionalFields := make([]interface{}, additionalFieldsNumber)
+		additionalFieldsPtr = make([]interface{}, additionalFieldsNumber)
+		for i := 0; i < additionalFieldsNumber; i++ {
+			additionalFieldsPtr[i] = &additionalFields[i]
+		}
+	}
+
+	vs := []*types.Announcement{}
+	ids := []string{}
+	for rows.Next() {
+		v, id, err := d.scanAnnouncement(rows, additionalFieldsPtr)
+		if err != nil {
+			rows.Close()
+			return nil, nil, errors.WithStack(err)
+		}
+		vs = append(vs, v)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return vs, ids, nil
+}
+
+func (d *DB) fetchUserPreferencess(tx *sql.Tx, q sq.Sqlizer) ([]*types.UserPreferences, []string, error) {
+	rows, err := d.query(tx, q)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	return d.scanUserPreferencess(rows)
+}
+
+func (d *DB) scanUserPreferences(rows *stdsql.Rows, additionalFields []interface{}) (*types.UserPreferences, string, error) {
+	var id string
+	var revision uint64
+	var data []byte
+	fields := append([]interface{}{&id, &revision, &data}, additionalFields...)
+	if err := rows.Scan(fields...); err != nil {
+		return nil, "", errors.Wrap(err, "failed to scan rows")
+	}
+	v := types.UserPreferences{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, "", errors.Wrap(err, "failed to unmarshal UserPreferences")
+		}
+	}
+
+	v.Revision = revision
+
+	return &v, id, nil
+}
+
+func (d *DB) scanUserPreferencess(rows *stdsql.Rows) ([]*types.UserPreferences, []string, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	fieldsNumber := len(cols)
+	if fieldsNumber < 3 {
+		return nil, nil, errors.Errorf("not enough columns (%d < 3)", len(cols))
+	}
+	var additionalFieldsPtr []interface{}
+	if fieldsNumber > 3 {
+		additionalFieldsNumber := fieldsNumber - 3
+		additionalFields := make([]interface{}, additionalFieldsNumber)
+		additionalFieldsPtr = make([]interface{}, additionalFieldsNumber)
+		for i := 0; i < additionalFieldsNumber; i++ {
+			additionalFieldsPtr[i] = &additionalFields[i]
+		}
+	}
+
+	vs := []*types.UserPreferences{}
+	ids := []string{}
+	for rows.Next() {
+		v, id, err := d.scanUserPreferences(rows, additionalFieldsPtr)
+		if err != nil {
+			rows.Close()
+			return nil, nil, errors.WithStack(err)
+		}
+		vs = append(vs, v)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return vs, ids, nil
+}
+
+func (d *DB) fetchProjectPolledRefss(tx *sql.Tx, q sq.Sqlizer) ([]*types.ProjectPolledRefs, []string, error) {
+	rows, err := d.query(tx, q)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	return d.scanProjectPolledRefss(rows)
+}
+
+func (d *DB) scanProjectPolledRefs(rows *stdsql.Rows, additionalFields []interface{}) (*types.ProjectPolledRefs, string, error) {
+	var id string
+	var revision uint64
+	var data []byte
+	fields := append([]interface{}{&id, &revision, &data}, additionalFields...)
+	if err := rows.Scan(fields...); err != nil {
+		return nil, "", errors.Wrap(err, "failed to scan rows")
+	}
+	v := types.ProjectPolledRefs{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, "", errors.Wrap(err, "failed to unmarshal ProjectPolledRefs")
+		}
+	}
+
+	v.Revision = revision
+
+	return &v, id, nil
+}
+
+func (d *DB) scanProjectPolledRefss(rows *stdsql.Rows) ([]*types.ProjectPolledRefs, []string, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	fieldsNumber := len(cols)
+	if fieldsNumber < 3 {
+		return nil, nil, errors.Errorf("not enough columns (%d < 3)", len(cols))
+	}
+	var additionalFieldsPtr []interface{}
+	if fieldsNumber > 3 {
+		additionalFieldsNumber := fieldsNumber - 3
+		additionalFields := make([]interface{}, additionalFieldsNumber)
+		additionalFieldsPtr = make([]interface{}, additionalFieldsNumber)
+		for i := 0; i < additionalFieldsNumber; i++ {
+			additionalFieldsPtr[i] = &additionalFields[i]
+		}
+	}
+
+	vs := []*types.ProjectPolledRefs{}
+	ids := []string{}
+	for rows.Next() {
+		v, id, err := d.scanProjectPolledRefs(rows, additionalFieldsPtr)
+		if err != nil {
+			rows.Close()
+			return nil, nil, errors.WithStack(err)
+		}
+		vs = append(vs, v)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return vs, ids, nil
+}
@@ -1282,3 +1282,384 @@ func (d *DB) deleteVariableData(tx *sql.Tx, id string) error {
 
 	return nil
 }
+
+func (d *DB) InsertOrUpdateAnnouncement(tx *sql.Tx, v *types.Announcement) error {
+	var err error
+	if v.Revision == 0 {
+		err = d.InsertAnnouncement(tx, v)
+	} else {
+		err = d.UpdateAnnouncement(tx, v)
+	}
+
+	return errors.WithStack(err)
+}
+
+func (d *DB) InsertAnnouncement(tx *sql.Tx, v *types.Announcement) error {
+	if v.Revision != 0 {
+		return errors.Errorf("expected revision 0 got %d", v.Revision)
+	}
+
+	data, err := d.insertAnnouncementData(tx, v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return d.insertAnnouncementQ(tx, v, data)
+}
+
+func (d *DB) insertAnnouncementData(tx *sql.Tx, v *types.Announcement) ([]byte, error) {
+	v.Revision = 1
+
+	now := time.Now()
+	v.SetCreationTime(now)
+	v.SetUpdateTime(now)
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		v.Revision = 0
+		return nil, errors.WithStack(err)
+	}
+
+	q := sb.Insert("announcement").Columns("id", "revision", "data").Values(v.ID, v.Revision, data)
+	if _, err := d.exec(tx, q); err != nil {
+		v.Revision = 0
+		return nil, errors.Wrap(err, "failed to insert announcement")
+	}
+
+	return data, nil
+}
+
+// insertRawAnnouncementData should be used only for import.
+// It won't update object times.
+func (d *DB) insertRawAnnouncementData(tx *sql.Tx, v *types.Announcement) ([]byte, error) {
+	v.Revision = 1
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		v.Revision = 0
+		return nil, errors.WithStack(err)
+	}
+
+	q := sb.Insert("announcement").Columns("id", "revision", "data").Values(v.ID, v.Revision, data)
+	if _, err := d.exec(tx, q); err != nil {
+		v.Revision = 0
+		return nil, errors.Wrap(err, "failed to insert announcement")
+	}
+
+	return data, nil
+}
+
+func (d *DB) UpdateAnnouncement(tx *sql.Tx, v *types.Announcement) error {
+	data, err := d.updateAnnouncementData(tx, v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return d.updateAnnouncementQ(tx, v, data)
+}
+
+func (d *DB) updateAnnouncementData(tx *sql.Tx, v *types.Announcement) ([]byte, error) {
+	if v.Revision < 1 {
+		return nil, errors.Errorf("expected revision > 0 got %d", v.Revision)
+	}
+
+	curRevision := v.Revision
+	v.Revision++
+
+	v.SetUpdateTime(time.Now())
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	q := sb.Update("announcement").SetMap(map[string]interface{}{"id": v.ID, "revision": v.Revision, "data": data}).Where(sq.Eq{"id": v.ID, "revision": curRevision})
+	res, err := d.exec(tx, q)
+	if err != nil {
+		v.Revision = curRevision
+		return nil, errors.Wrap(err, "failed to update announcement")
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		v.Revision = curRevision
+		return nil, errors.Wrap(err, "failed to update announcement")
+	}
+
+	if rows != 1 {
+		v.Revision = curRevision
+		return nil, idb.ErrConcurrent
+	}
+
+	return data, nil
+}
+
+func (d *DB) DeleteAnnouncement(tx *sql.Tx, id string) error {
+	if err := d.deleteAnnouncementData(tx, id); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return d.deleteAnnouncementQ(tx, id)
+}
+
+func (d *DB) deleteAnnouncementData(tx *sql.Tx, id string) error {
+	if _, err := tx.Exec("delete from announcement where id = $1", id); err != nil {
+		return errors.Wrap(err, "failed to delete announcement")
+	}
+
+	return nil
+}
+
+func (d *DB) InsertOrUpdateUserPreferences(tx *sql.Tx, v *types.UserPreferences) error {
+	var err error
+	if v.Revision == 0 {
+		err = d.InsertUserPreferences(tx, v)
+	} else {
+		err = d.UpdateUserPreferences(tx, v)
+	}
+
+	return errors.WithStack(err)
+}
+
+func (d *DB) InsertUserPreferences(tx *sql.Tx, v *types.UserPreferences) error {
+	if v.Revision != 0 {
+		return errors.Errorf("expected revision 0 got %d", v.Revision)
+	}
+
+	data, err := d.insertUserPreferencesData(tx, v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return d.insertUserPreferencesQ(tx, v, data)
+}
+
+func (d *DB) insertUserPreferencesData(tx *sql.Tx, v *types.UserPreferences) ([]byte, error) {
+	v.Revision = 1
+
+	now := time.Now()
+	v.SetCreationTime(now)
+	v.SetUpdateTime(now)
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		v.Revision = 0
+		return nil, errors.WithStack(err)
+	}
+
+	q := sb.Insert("userpreferences").Columns("id", "revision", "data").Values(v.ID, v.Revision, data)
+	if _, err := d.exec(tx, q); err != nil {
+		v.Revision = 0
+		return nil, errors.Wrap(err, "failed to insert userpreferences")
+	}
+
+	return data, nil
+}
+
+// insertRawUserPreferencesData should be used only for import.
+// It won't update object times.
+func (d *DB) insertRawUserPreferencesData(tx *sql.Tx, v *types.UserPreferences) ([]byte, error) {
+	v.Revision = 1
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		v.Revision = 0
+		return nil, errors.WithStack(err)
+	}
+
+	q := sb.Insert("userpreferences").Columns("id", "revision", "data").Values(v.ID, v.Revision, data)
+	if _, err := d.exec(tx, q); err != nil {
+		v.Revision = 0
+		return nil, errors.Wrap(err, "failed to insert userpreferences")
+	}
+
+	return data, nil
+}
+
+func (d *DB) UpdateUserPreferences(tx *sql.Tx, v *types.UserPreferences) error {
+	data, err := d.updateUserPreferencesData(tx, v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return d.updateUserPreferencesQ(tx, v, data)
+}
+
+func (d *DB) updateUserPreferencesData(tx *sql.Tx, v *types.UserPreferences) ([]byte, error) {
+	if v.Revision < 1 {
+		return nil, errors.Errorf("expected revision > 0 got %d", v.Revision)
+	}
+
+	curRevision := v.Revision
+	v.Revision++
+
+	v.SetUpdateTime(time.Now())
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	q := sb.Update("userpreferences").SetMap(map[string]interface{}{"id": v.ID, "revision": v.Revision, "data": data}).Where(sq.Eq{"id": v.ID, "revision": curRevision})
+	res, err := d.exec(tx, q)
+	if err != nil {
+		v.Revision = curRevision
+		return nil, errors.Wrap(err, "failed to update userpreferences")
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		v.Revision = curRevision
+		return nil, errors.Wrap(err, "failed to update userpreferences")
+	}
+
+	if rows != 1 {
+		v.Revision = curRevision
+		return nil, idb.ErrConcurrent
+	}
+
+	return data, nil
+}
+
+func (d *DB) DeleteUserPreferences(tx *sql.Tx, id string) error {
+	if err := d.deleteUserPreferencesData(tx, id); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return d.deleteUserPreferencesQ(tx, id)
+}
+
+func (d *DB) deleteUserPreferencesData(tx *sql.Tx, id string) error {
+	if _, err := tx.Exec("delete from userpreferences where id = $1", id); err != nil {
+		return errors.Wrap(err, "failed to delete userpreferences")
+	}
+
+	return nil
+}
+
+func (d *DB) InsertOrUpdateProjectPolledRefs(tx *sql.Tx, v *types.ProjectPolledRefs) error {
+	var err error
+	if v.Revision == 0 {
+		err = d.InsertProjectPolledRefs(tx, v)
+	} else {
+		err = d.UpdateProjectPolledRefs(tx, v)
+	}
+
+	return errors.WithStack(err)
+}
+
+func (d *DB) InsertProjectPolledRefs(tx *sql.Tx, v *types.ProjectPolledRefs) error {
+	if v.Revision != 0 {
+		return errors.Errorf("expected revision 0 got %d", v.Revision)
+	}
+
+	data, err := d.insertProjectPolledRefsData(tx, v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return d.insertProjectPolledRefsQ(tx, v, data)
+}
+
+func (d *DB) insertProjectPolledRefsData(tx *sql.Tx, v *types.ProjectPolledRefs) ([]byte, error) {
+	v.Revision = 1
+
+	now := time.Now()
+	v.SetCreationTime(now)
+	v.SetUpdateTime(now)
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		v.Revision = 0
+		return nil, errors.WithStack(err)
+	}
+
+	q := sb.Insert("projectpolledrefs").Columns("id", "revision", "data").Values(v.ID, v.Revision, data)
+	if _, err := d.exec(tx, q); err != nil {
+		v.Revision = 0
+		return nil, errors.Wrap(err, "failed to insert projectpolledrefs")
+	}
+
+	return data, nil
+}
+
+// insertRawProjectPolledRefsData should be used only for import.
+// It won't update object times.
+func (d *DB) insertRawProjectPolledRefsData(tx *sql.Tx, v *types.ProjectPolledRefs) ([]byte, error) {
+	v.Revision = 1
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		v.Revision = 0
+		return nil, errors.WithStack(err)
+	}
+
+	q := sb.Insert("projectpolledrefs").Columns("id", "revision", "data").Values(v.ID, v.Revision, data)
+	if _, err := d.exec(tx, q); err != nil {
+		v.Revision = 0
+		return nil, errors.Wrap(err, "failed to insert projectpolledrefs")
+	}
+
+	return data, nil
+}
+
+func (d *DB) UpdateProjectPolledRefs(tx *sql.Tx, v *types.ProjectPolledRefs) error {
+	data, err := d.updateProjectPolledRefsData(tx, v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return d.updateProjectPolledRefsQ(tx, v, data)
+}
+
+func (d *DB) updateProjectPolledRefsData(tx *sql.Tx, v *types.ProjectPolledRefs) ([]byte, error) {
+	if v.Revision < 1 {
+		return nil, errors.Errorf("expected revision > 0 got %d", v.Revision)
+	}
+
+	curRevision := v.Revision
+	v.Revision++
+
+	v.SetUpdateTime(time.Now())
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	q := sb.Update("projectpolledrefs").SetMap(map[string]interface{}{"id": v.ID, "revision": v.Revision, "data": data}).Where(sq.Eq{"id": v.ID, "revision": curRevision})
+	res, err := d.exec(tx, q)
+	if err != nil {
+		v.Revision = curRevision
+		return nil, errors.Wrap(err, "failed to update projectpolledrefs")
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		v.Revision = curRevision
+		return nil, errors.Wrap(err, "failed to update projectpolledrefs")
+	}
+
+	if rows != 1 {
+		v.Revision = curRevision
+		return nil, idb.ErrConcurrent
+	}
+
+	return data, nil
+}
+
+func (d *DB) DeleteProjectPolledRefs(tx *sql.Tx, id string) error {
+	if err := d.deleteProjectPolledRefsData(tx, id); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return d.deleteProjectPolledRefsQ(tx, id)
+}
+
+func (d *DB) deleteProjectPolledRefsData(tx *sql.Tx, id string) error {
+	if _, err := tx.Exec("delete from projectpolledrefs where id = $1", id); err != nil {
+		return errors.Wrap(err, "failed to delete projectpolledrefs")
+	}
+
+	return nil
+}
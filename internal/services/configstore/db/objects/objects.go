@@ -15,4 +15,7 @@ var ObjectsInfo = []idb.ObjectInfo{
 	{Name: "Project", Table: "project"},
 	{Name: "Secret", Table: "secret"},
 	{Name: "Variable", Table: "variable"},
+	{Name: "Announcement", Table: "announcement"},
+	{Name: "UserPreferences", Table: "userpreferences"},
+	{Name: "ProjectPolledRefs", Table: "projectpolledrefs"},
 }
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"strings"
+
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/sql"
 	"agola.io/agola/services/configstore/types"
@@ -69,11 +71,11 @@ var (
 	}
 
 	projectQSelect = sb.Select("project_q.id", "project_q.revision", "project_q.data").From("project_q")
-	projectQInsert = func(id string, revision uint64, name, parentID string, parentKind types.ObjectKind, data []byte) sq.InsertBuilder {
-		return sb.Insert("project_q").Columns("id", "revision", "name", "parent_id", "parent_kind", "data").Values(id, revision, name, parentID, parentKind, data)
+	projectQInsert = func(id string, revision uint64, name, parentID string, parentKind types.ObjectKind, remoteSourceID, repositoryPath string, deleted bool, data []byte) sq.InsertBuilder {
+		return sb.Insert("project_q").Columns("id", "revision", "name", "parent_id", "parent_kind", "remotesource_id", "repository_path", "deleted", "data").Values(id, revision, name, parentID, parentKind, remoteSourceID, repositoryPath, deleted, data)
 	}
-	projectQUpdate = func(id string, revision uint64, name, parentID string, parentKind types.ObjectKind, data []byte) sq.UpdateBuilder {
-		return sb.Update("project_q").SetMap(map[string]interface{}{"id": id, "revision": revision, "name": name, "parent_id": parentID, "parent_kind": parentKind, "data": data}).Where(sq.Eq{"id": id})
+	projectQUpdate = func(id string, revision uint64, name, parentID string, parentKind types.ObjectKind, remoteSourceID, repositoryPath string, deleted bool, data []byte) sq.UpdateBuilder {
+		return sb.Update("project_q").SetMap(map[string]interface{}{"id": id, "revision": revision, "name": name, "parent_id": parentID, "parent_kind": parentKind, "remotesource_id": remoteSourceID, "repository_path": repositoryPath, "deleted": deleted, "data": data}).Where(sq.Eq{"id": id})
 	}
 
 	secretQSelect = sb.Select("secret_q.id", "secret_q.revision", "secret_q.data").From("secret_q")
@@ -91,6 +93,30 @@ var (
 	variableQUpdate = func(id string, revision uint64, name, parentID string, parentKind types.ObjectKind, data []byte) sq.UpdateBuilder {
 		return sb.Update("variable_q").SetMap(map[string]interface{}{"id": id, "revision": revision, "name": name, "parent_id": parentID, "parent_kind": parentKind, "data": data}).Where(sq.Eq{"id": id})
 	}
+
+	announcementQSelect = sb.Select("announcement_q.id", "announcement_q.revision", "announcement_q.data").From("announcement_q")
+	announcementQInsert = func(id string, revision uint64, data []byte) sq.InsertBuilder {
+		return sb.Insert("announcement_q").Columns("id", "revision", "data").Values(id, revision, data)
+	}
+	announcementQUpdate = func(id string, revision uint64, data []byte) sq.UpdateBuilder {
+		return sb.Update("announcement_q").SetMap(map[string]interface{}{"id": id, "revision": revision, "data": data}).Where(sq.Eq{"id": id})
+	}
+
+	userPreferencesQSelect = sb.Select("userpreferences_q.id", "userpreferences_q.revision", "userpreferences_q.data").From("userpreferences_q")
+	userPreferencesQInsert = func(id string, revision uint64, userID string, data []byte) sq.InsertBuilder {
+		return sb.Insert("userpreferences_q").Columns("id", "revision", "user_id", "data").Values(id, revision, userID, data)
+	}
+	userPreferencesQUpdate = func(id string, revision uint64, userID string, data []byte) sq.UpdateBuilder {
+		return sb.Update("userpreferences_q").SetMap(map[string]interface{}{"id": id, "revision": revision, "user_id": userID, "data": data}).Where(sq.Eq{"id": id})
+	}
+
+	projectPolledRefsQSelect = sb.Select("projectpolledrefs_q.id", "projectpolledrefs_q.revision", "projectpolledrefs_q.data").From("projectpolledrefs_q")
+	projectPolledRefsQInsert = func(id string, revision uint64, projectID string, data []byte) sq.InsertBuilder {
+		return sb.Insert("projectpolledrefs_q").Columns("id", "revision", "project_id", "data").Values(id, revision, projectID, data)
+	}
+	projectPolledRefsQUpdate = func(id string, revision uint64, projectID string, data []byte) sq.UpdateBuilder {
+		return sb.Update("projectpolledrefs_q").SetMap(map[string]interface{}{"id": id, "revision": revision, "project_id": projectID, "data": data}).Where(sq.Eq{"id": id})
+	}
 )
 
 func (d *DB) InsertObjectQ(tx *sql.Tx, obj stypes.Object, data []byte) error {
@@ -115,6 +141,12 @@ func (d *DB) InsertObjectQ(tx *sql.Tx, obj stypes.Object, data []byte) error {
 		return d.insertSecretQ(tx, obj.(*types.Secret), data)
 	case types.VariableKind:
 		return d.insertVariableQ(tx, obj.(*types.Variable), data)
+	case types.AnnouncementKind:
+		return d.insertAnnouncementQ(tx, obj.(*types.Announcement), data)
+	case types.UserPreferencesKind:
+		return d.insertUserPreferencesQ(tx, obj.(*types.UserPreferences), data)
+	case types.ProjectPolledRefsKind:
+		return d.insertProjectPolledRefsQ(tx, obj.(*types.ProjectPolledRefs), data)
 
 	default:
 		panic(errors.Errorf("unknown object kind %q", obj.GetKind()))
@@ -166,8 +198,8 @@ func (d *DB) updateUserQ(tx *sql.Tx, user *types.User, data []byte) error {
 }
 
 func (d *DB) deleteUserQ(tx *sql.Tx, id string) error {
-	if _, err := tx.Exec("delete from user_q where id = $1", id); err != nil {
-		return errors.Wrapf(err, "failed to delete user_q")
+	if _, err := tx.Exec("delete from user_t_q where id = $1", id); err != nil {
+		return errors.Wrapf(err, "failed to delete user_t_q")
 	}
 
 	return nil
@@ -304,7 +336,8 @@ func (d *DB) deleteProjectGroupQ(tx *sql.Tx, id string) error {
 }
 
 func (d *DB) insertProjectQ(tx *sql.Tx, project *types.Project, data []byte) error {
-	q := projectQInsert(project.ID, project.Revision, project.Name, project.Parent.ID, project.Parent.Kind, data)
+	// the repository path is saved lowercase to match it case insensitively
+	q := projectQInsert(project.ID, project.Revision, project.Name, project.Parent.ID, project.Parent.Kind, project.RemoteSourceID, strings.ToLower(project.RepositoryPath), project.DeletionTime != nil, data)
 	if _, err := d.exec(tx, q); err != nil {
 		return errors.Wrapf(err, "failed to insert project_q")
 	}
@@ -313,7 +346,7 @@ func (d *DB) insertProjectQ(tx *sql.Tx, project *types.Project, data []byte) err
 }
 
 func (d *DB) updateProjectQ(tx *sql.Tx, project *types.Project, data []byte) error {
-	q := projectQUpdate(project.ID, project.Revision, project.Name, project.Parent.ID, project.Parent.Kind, data)
+	q := projectQUpdate(project.ID, project.Revision, project.Name, project.Parent.ID, project.Parent.Kind, project.RemoteSourceID, strings.ToLower(project.RepositoryPath), project.DeletionTime != nil, data)
 	if _, err := d.exec(tx, q); err != nil {
 		return errors.Wrapf(err, "failed to insert project_q")
 	}
@@ -380,3 +413,81 @@ func (d *DB) deleteVariableQ(tx *sql.Tx, id string) error {
 
 	return nil
 }
+
+func (d *DB) insertAnnouncementQ(tx *sql.Tx, announcement *types.Announcement, data []byte) error {
+	q := announcementQInsert(announcement.ID, announcement.Revision, data)
+	if _, err := d.exec(tx, q); err != nil {
+		return errors.Wrapf(err, "failed to insert announcement_q")
+	}
+
+	return nil
+}
+
+func (d *DB) updateAnnouncementQ(tx *sql.Tx, announcement *types.Announcement, data []byte) error {
+	q := announcementQUpdate(announcement.ID, announcement.Revision, data)
+	if _, err := d.exec(tx, q); err != nil {
+		return errors.Wrapf(err, "failed to insert announcement_q")
+	}
+
+	return nil
+}
+
+func (d *DB) deleteAnnouncementQ(tx *sql.Tx, id string) error {
+	if _, err := tx.Exec("delete from announcement_q where id = $1", id); err != nil {
+		return errors.Wrapf(err, "failed to delete announcement_q")
+	}
+
+	return nil
+}
+
+func (d *DB) insertUserPreferencesQ(tx *sql.Tx, userPreferences *types.UserPreferences, data []byte) error {
+	q := userPreferencesQInsert(userPreferences.ID, userPreferences.Revision, userPreferences.UserID, data)
+	if _, err := d.exec(tx, q); err != nil {
+		return errors.Wrapf(err, "failed to insert userpreferences_q")
+	}
+
+	return nil
+}
+
+func (d *DB) updateUserPreferencesQ(tx *sql.Tx, userPreferences *types.UserPreferences, data []byte) error {
+	q := userPreferencesQUpdate(userPreferences.ID, userPreferences.Revision, userPreferences.UserID, data)
+	if _, err := d.exec(tx, q); err != nil {
+		return errors.Wrapf(err, "failed to insert userpreferences_q")
+	}
+
+	return nil
+}
+
+func (d *DB) deleteUserPreferencesQ(tx *sql.Tx, id string) error {
+	if _, err := tx.Exec("delete from userpreferences_q where id = $1", id); err != nil {
+		return errors.Wrapf(err, "failed to delete userpreferences_q")
+	}
+
+	return nil
+}
+
+func (d *DB) insertProjectPolledRefsQ(tx *sql.Tx, projectPolledRefs *types.ProjectPolledRefs, data []byte) error {
+	q := projectPolledRefsQInsert(projectPolledRefs.ID, projectPolledRefs.Revision, projectPolledRefs.ProjectID, data)
+	if _, err := d.exec(tx, q); err != nil {
+		return errors.Wrapf(err, "failed to insert projectpolledrefs_q")
+	}
+
+	return nil
+}
+
+func (d *DB) updateProjectPolledRefsQ(tx *sql.Tx, projectPolledRefs *types.ProjectPolledRefs, data []byte) error {
+	q := projectPolledRefsQUpdate(projectPolledRefs.ID, projectPolledRefs.Revision, projectPolledRefs.ProjectID, data)
+	if _, err := d.exec(tx, q); err != nil {
+		return errors.Wrapf(err, "failed to insert projectpolledrefs_q")
+	}
+
+	return nil
+}
+
+func (d *DB) deleteProjectPolledRefsQ(tx *sql.Tx, id string) error {
+	if _, err := tx.Exec("delete from projectpolledrefs_q where id = $1", id); err != nil {
+		return errors.Wrapf(err, "failed to delete projectpolledrefs_q")
+	}
+
+	return nil
+}
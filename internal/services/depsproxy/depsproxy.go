@@ -0,0 +1,320 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"agola.io/agola/internal/common"
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/services/config"
+	"agola.io/agola/internal/util"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	upstreamRequestTimeout = 5 * time.Minute
+
+	// max size of the registries metadata rewritten by the proxy
+	maxMetadataSize = 64 * 1024 * 1024
+)
+
+type RegistryType string
+
+const (
+	RegistryTypeGoModules RegistryType = "gomod"
+	RegistryTypeNpm       RegistryType = "npm"
+	RegistryTypePyPI      RegistryType = "pypi"
+)
+
+// pypiFilesPrefix is the path prefix, under the pypi registry path, of the
+// packages files. The pypi simple index links to a different server so its
+// urls are rewritten to this prefix
+const pypiFilesPrefix = "files"
+
+// registry is a proxied registry
+type registry struct {
+	t           RegistryType
+	upstreamURL string
+	// filesUpstreamURL is the upstream url of the pypi packages files
+	filesUpstreamURL string
+}
+
+type DepsProxy struct {
+	log        zerolog.Logger
+	c          *config.DepsProxy
+	ost        *objectstorage.ObjStorage
+	client     *http.Client
+	registries map[RegistryType]*registry
+}
+
+func NewDepsProxy(ctx context.Context, log zerolog.Logger, gc *config.Config) (*DepsProxy, error) {
+	c := &gc.DepsProxy
+
+	if c.Debug {
+		log = log.Level(zerolog.DebugLevel)
+	}
+
+	ost, err := common.NewObjectStorage(&c.ObjectStorage)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return newDepsProxy(log, c, ost), nil
+}
+
+func newDepsProxy(log zerolog.Logger, c *config.DepsProxy, ost *objectstorage.ObjStorage) *DepsProxy {
+	return &DepsProxy{
+		log:    log,
+		c:      c,
+		ost:    ost,
+		client: &http.Client{Timeout: upstreamRequestTimeout},
+		registries: map[RegistryType]*registry{
+			RegistryTypeGoModules: {t: RegistryTypeGoModules, upstreamURL: c.Upstreams.GoModules},
+			RegistryTypeNpm:       {t: RegistryTypeNpm, upstreamURL: c.Upstreams.Npm},
+			RegistryTypePyPI:      {t: RegistryTypePyPI, upstreamURL: c.Upstreams.PyPI, filesUpstreamURL: c.Upstreams.PyPIFiles},
+		},
+	}
+}
+
+func (p *DepsProxy) Run(ctx context.Context) error {
+	var tlsConfig *tls.Config
+	if p.c.Web.TLS {
+		var err error
+		tlsConfig, err = util.NewTLSConfig(p.c.Web.TLSCertFile, p.c.Web.TLSKeyFile, "", false)
+		if err != nil {
+			p.log.Err(err).Send()
+			return errors.WithStack(err)
+		}
+	}
+
+	// the proxy is called by the tasks so it doesn't use the internal
+	// services authentication
+	httpServer := http.Server{
+		Handler:   p,
+		TLSConfig: tlsConfig,
+	}
+
+	lerrCh := make(chan error)
+	go func() {
+		lerrCh <- util.ListenAndServe(&httpServer, p.c.Web.Addresses(), p.c.Web.TLS)
+	}()
+
+	select {
+	case <-ctx.Done():
+		p.log.Info().Msgf("depsproxy exiting")
+		httpServer.Close()
+	case err := <-lerrCh:
+		if err != nil {
+			p.log.Err(err).Msgf("http server listen error")
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+func (p *DepsProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// the path is /$registrytype/$registrypath
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	reg, ok := p.registries[RegistryType(parts[0])]
+	if !ok {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	regPath := parts[1]
+	if cleanPath := path.Clean("/" + regPath); cleanPath != "/"+strings.TrimSuffix(regPath, "/") {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	upstreamURL := reg.upstreamURL + "/" + regPath
+	if reg.t == RegistryTypePyPI && strings.HasPrefix(regPath, pypiFilesPrefix+"/") {
+		upstreamURL = reg.filesUpstreamURL + "/" + strings.TrimPrefix(regPath, pypiFilesPrefix+"/")
+	}
+	if r.URL.RawQuery != "" {
+		upstreamURL += "?" + r.URL.RawQuery
+	}
+
+	var err error
+	if isImmutable(reg.t, regPath) && r.URL.RawQuery == "" {
+		err = p.serveCached(w, r, reg, regPath, upstreamURL)
+	} else {
+		err = p.serveUpstream(w, r, reg, upstreamURL)
+	}
+	if err != nil {
+		p.log.Err(err).Send()
+		http.Error(w, "", http.StatusBadGateway)
+	}
+}
+
+// isImmutable reports if the registry path is an immutable artifact that can
+// be cached forever. Metadata like the module versions list, the npm packages
+// documents and the pypi index pages change and are always fetched from the
+// upstream registry.
+func isImmutable(t RegistryType, regPath string) bool {
+	switch t {
+	case RegistryTypeGoModules:
+		if !strings.Contains(regPath, "/@v/") {
+			return false
+		}
+		switch path.Ext(regPath) {
+		case ".info", ".mod", ".zip":
+			return true
+		}
+	case RegistryTypeNpm:
+		return strings.Contains(regPath, "/-/") && strings.HasSuffix(regPath, ".tgz")
+	case RegistryTypePyPI:
+		return strings.HasPrefix(regPath, pypiFilesPrefix+"/")
+	}
+
+	return false
+}
+
+func objectPath(t RegistryType, regPath string) string {
+	return path.Join(string(t), regPath)
+}
+
+func (p *DepsProxy) serveCached(w http.ResponseWriter, r *http.Request, reg *registry, regPath, upstreamURL string) error {
+	op := objectPath(reg.t, regPath)
+
+	f, err := p.ost.ReadObject(op)
+	if err != nil && !objectstorage.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+	if err == nil {
+		defer f.Close()
+		p.log.Debug().Msgf("serving cached %q", op)
+		http.ServeContent(w, r, "", time.Time{}, f)
+		return nil
+	}
+
+	resp, err := p.upstreamRequest(r, upstreamURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || r.Method == "HEAD" {
+		copyHeaders(w, resp)
+		w.WriteHeader(resp.StatusCode)
+		_, err := io.Copy(w, resp.Body)
+		return errors.WithStack(err)
+	}
+
+	// save the artifact while sending it to the client. The object is written
+	// atomically so an interrupted transfer won't leave a partial artifact
+	copyHeaders(w, resp)
+	w.WriteHeader(resp.StatusCode)
+	if err := p.ost.WriteObject(op, io.TeeReader(resp.Body, w), resp.ContentLength, false); err != nil {
+		return errors.Wrapf(err, "failed to save %q", op)
+	}
+
+	return nil
+}
+
+func (p *DepsProxy) serveUpstream(w http.ResponseWriter, r *http.Request, reg *registry, upstreamURL string) error {
+	resp, err := p.upstreamRequest(r, upstreamURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	rewrites := p.rewrites(reg)
+	if resp.StatusCode != http.StatusOK || len(rewrites) == 0 {
+		copyHeaders(w, resp)
+		w.WriteHeader(resp.StatusCode)
+		_, err := io.Copy(w, resp.Body)
+		return errors.WithStack(err)
+	}
+
+	// rewrite the artifacts urls in the metadata so they'll be fetched using
+	// the proxy
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for from, to := range rewrites {
+		data = bytes.ReplaceAll(data, []byte(from), []byte(to))
+	}
+
+	copyHeaders(w, resp)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(resp.StatusCode)
+	_, err = w.Write(data)
+	return errors.WithStack(err)
+}
+
+// rewrites returns the upstream urls, contained in the registry metadata, to
+// replace with the proxy urls
+func (p *DepsProxy) rewrites(reg *registry) map[string]string {
+	proxyURL := strings.TrimSuffix(p.c.ExposedURL, "/") + "/" + string(reg.t)
+
+	switch reg.t {
+	case RegistryTypeNpm:
+		return map[string]string{strings.TrimSuffix(reg.upstreamURL, "/") + "/": proxyURL + "/"}
+	case RegistryTypePyPI:
+		return map[string]string{strings.TrimSuffix(reg.filesUpstreamURL, "/") + "/": proxyURL + "/" + pypiFilesPrefix + "/"}
+	}
+
+	return nil
+}
+
+func (p *DepsProxy) upstreamRequest(r *http.Request, upstreamURL string) (*http.Response, error) {
+	p.log.Debug().Msgf("upstream request %s %q", r.Method, upstreamURL)
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, upstreamURL, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// npm uses the accept header to request the abbreviated packages metadata
+	if accept := r.Header.Get("Accept"); accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return resp, nil
+}
+
+func copyHeaders(w http.ResponseWriter, resp *http.Response) {
+	for _, h := range []string{"Content-Type", "Content-Length"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package depsproxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/services/config"
+	"agola.io/agola/internal/testutil"
+)
+
+func TestDepsProxy(t *testing.T) {
+	var mu sync.Mutex
+	upstreamRequests := map[string]int{}
+
+	var upstreamURL string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		upstreamRequests[r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/gomod/example.com/mod/@v/v1.0.0.zip":
+			fmt.Fprint(w, "module zip")
+		case "/gomod/example.com/mod/@v/list":
+			fmt.Fprint(w, "v1.0.0\n")
+		case "/npm/pkg":
+			fmt.Fprintf(w, `{"dist":{"tarball":"%s/npm/pkg/-/pkg-1.0.0.tgz"}}`, upstreamURL)
+		case "/npm/pkg/-/pkg-1.0.0.tgz":
+			fmt.Fprint(w, "package tarball")
+		default:
+			http.Error(w, "", http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+	upstreamURL = upstream.URL
+
+	dir := t.TempDir()
+	ost, err := objectstorage.NewPosix(path.Join(dir, "ost"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	c := &config.DepsProxy{
+		ExposedURL: "http://depsproxy.example.com",
+		Upstreams: config.DepsProxyUpstreams{
+			GoModules: upstreamURL + "/gomod",
+			Npm:       upstreamURL + "/npm",
+			PyPI:      upstreamURL + "/pypi",
+			PyPIFiles: upstreamURL + "/pypifiles",
+		},
+	}
+	p := newDepsProxy(testutil.NewLogger(t), c, objectstorage.NewObjStorage(ost, "/"))
+
+	get := func(reqPath string) (int, string) {
+		r := httptest.NewRequest("GET", reqPath, nil)
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		body, _ := ioutil.ReadAll(w.Result().Body)
+		return w.Code, string(body)
+	}
+
+	tests := []struct {
+		name             string
+		path             string
+		upstreamPath     string
+		code             int
+		body             string
+		upstreamRequests int
+	}{
+		{
+			name:             "test module zip fetched from upstream",
+			path:             "/gomod/example.com/mod/@v/v1.0.0.zip",
+			upstreamPath:     "/gomod/example.com/mod/@v/v1.0.0.zip",
+			code:             http.StatusOK,
+			body:             "module zip",
+			upstreamRequests: 1,
+		},
+		{
+			name:             "test module zip served from cache",
+			path:             "/gomod/example.com/mod/@v/v1.0.0.zip",
+			upstreamPath:     "/gomod/example.com/mod/@v/v1.0.0.zip",
+			code:             http.StatusOK,
+			body:             "module zip",
+			upstreamRequests: 1,
+		},
+		{
+			name:             "test module versions list always fetched from upstream",
+			path:             "/gomod/example.com/mod/@v/list",
+			upstreamPath:     "/gomod/example.com/mod/@v/list",
+			code:             http.StatusOK,
+			body:             "v1.0.0\n",
+			upstreamRequests: 1,
+		},
+		{
+			name:             "test module versions list fetched again from upstream",
+			path:             "/gomod/example.com/mod/@v/list",
+			upstreamPath:     "/gomod/example.com/mod/@v/list",
+			code:             http.StatusOK,
+			body:             "v1.0.0\n",
+			upstreamRequests: 2,
+		},
+		{
+			name:             "test not existing module",
+			path:             "/gomod/example.com/notexists/@v/v1.0.0.zip",
+			upstreamPath:     "/gomod/example.com/notexists/@v/v1.0.0.zip",
+			code:             http.StatusNotFound,
+			upstreamRequests: 1,
+		},
+		{
+			name:             "test npm package metadata with rewritten tarball url",
+			path:             "/npm/pkg",
+			upstreamPath:     "/npm/pkg",
+			code:             http.StatusOK,
+			body:             `{"dist":{"tarball":"http://depsproxy.example.com/npm/pkg/-/pkg-1.0.0.tgz"}}`,
+			upstreamRequests: 1,
+		},
+		{
+			name:             "test npm package tarball",
+			path:             "/npm/pkg/-/pkg-1.0.0.tgz",
+			upstreamPath:     "/npm/pkg/-/pkg-1.0.0.tgz",
+			code:             http.StatusOK,
+			body:             "package tarball",
+			upstreamRequests: 1,
+		},
+		{
+			name: "test unknown registry",
+			path: "/unknown/pkg",
+			code: http.StatusNotFound,
+		},
+		{
+			name: "test path with parent directory",
+			path: "/gomod/example.com/mod/../../../secret",
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := get(tt.path)
+			if code != tt.code {
+				t.Fatalf("got status code %d, want %d", code, tt.code)
+			}
+			if tt.code == http.StatusOK && body != tt.body {
+				t.Fatalf("got body %q, want %q", body, tt.body)
+			}
+			if tt.upstreamPath != "" {
+				mu.Lock()
+				n := upstreamRequests[tt.upstreamPath]
+				mu.Unlock()
+				if n != tt.upstreamRequests {
+					t.Fatalf("got %d upstream requests, want %d", n, tt.upstreamRequests)
+				}
+			}
+		})
+	}
+
+	for upstreamPath := range upstreamRequests {
+		if strings.Contains(upstreamPath, "secret") {
+			t.Fatalf("unexpected upstream request %q", upstreamPath)
+		}
+	}
+}
@@ -16,6 +16,7 @@ package executor
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -24,6 +25,7 @@ import (
 	"time"
 
 	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/util"
 	"agola.io/agola/services/runservice/types"
 	"github.com/rs/zerolog"
 )
@@ -219,7 +221,7 @@ func (h *archivesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Cache-Control", "no-cache")
 
-	if err := h.readArchive(taskID, step, w); err != nil {
+	if err := h.readArchive(r.Context(), taskID, step, w); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			http.Error(w, "", http.StatusNotFound)
 		} else {
@@ -229,7 +231,7 @@ func (h *archivesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *archivesHandler) readArchive(taskID string, step int, w http.ResponseWriter) error {
+func (h *archivesHandler) readArchive(ctx context.Context, taskID string, step int, w http.ResponseWriter) error {
 	archivePath := h.e.archivePath(taskID, step)
 
 	f, err := os.Open(archivePath)
@@ -246,6 +248,6 @@ func (h *archivesHandler) readArchive(taskID string, step int, w http.ResponseWr
 
 	br := bufio.NewReader(f)
 
-	_, err = io.Copy(w, br)
+	_, err = io.Copy(w, util.NewRateLimitedReader(ctx, br, h.e.uploadLimiter))
 	return errors.WithStack(err)
 }
@@ -21,7 +21,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -30,12 +29,14 @@ import (
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/services/executor/registry"
+	rstypes "agola.io/agola/services/runservice/types"
 	"agola.io/agola/services/types"
 
 	dockertypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/archive"
@@ -51,6 +52,12 @@ type DockerDriver struct {
 	initDockerConfig *registry.DockerConfig
 	executorID       string
 	arch             types.Arch
+	// os is the os of the docker daemon containers
+	os types.OS
+	// kernelVersion and serverVersion are the docker daemon host kernel
+	// version and the docker daemon version
+	kernelVersion string
+	serverVersion string
 }
 
 func NewDockerDriver(log zerolog.Logger, executorID, toolboxPath, initImage string, initDockerConfig *registry.DockerConfig) (*DockerDriver, error) {
@@ -71,11 +78,40 @@ func NewDockerDriver(log zerolog.Logger, executorID, toolboxPath, initImage stri
 }
 
 func (d *DockerDriver) Setup(ctx context.Context) error {
+	info, err := d.client.Info(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	d.os = types.OSFromString(info.OSType)
+	if d.os == "" {
+		return errors.Errorf("unsupported docker daemon os type %q", info.OSType)
+	}
+	d.kernelVersion = info.KernelVersion
+	d.serverVersion = info.ServerVersion
+
 	return nil
 }
 
+// toolboxVolumeTmpDir is the dir where the toolbox volume is mounted in the
+// container used to copy the toolbox
+func (d *DockerDriver) toolboxVolumeTmpDir() string {
+	if d.os == types.OSWindows {
+		return `C:\agola`
+	}
+	return "/tmp/agola"
+}
+
 func (d *DockerDriver) createToolboxVolume(ctx context.Context, podID string, out io.Writer) (*dockertypes.Volume, error) {
-	if err := d.fetchImage(ctx, d.initImage, false, d.initDockerConfig, out); err != nil {
+	// fetch the init image only if missing or when using the latest tag
+	initImageTag, err := registry.GetImageTagOrDigest(d.initImage)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	initImagePullPolicy := rstypes.PullPolicyIfNotPresent
+	if initImageTag == "latest" {
+		initImagePullPolicy = rstypes.PullPolicyAlways
+	}
+	if err := d.fetchImage(ctx, d.initImage, initImagePullPolicy, d.initDockerConfig, out); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
@@ -93,7 +129,7 @@ func (d *DockerDriver) createToolboxVolume(ctx context.Context, podID string, ou
 		Image:      d.initImage,
 		Tty:        true,
 	}, &container.HostConfig{
-		Binds: []string{fmt.Sprintf("%s:%s", toolboxVol.Name, "/tmp/agola")},
+		Binds: []string{fmt.Sprintf("%s:%s", toolboxVol.Name, d.toolboxVolumeTmpDir())},
 	}, nil, "")
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -101,11 +137,15 @@ func (d *DockerDriver) createToolboxVolume(ctx context.Context, podID string, ou
 
 	containerID := resp.ID
 
-	if err := d.client.ContainerStart(ctx, containerID, dockertypes.ContainerStartOptions{}); err != nil {
-		return nil, errors.WithStack(err)
+	// windows containers can't be written while running (with hyper-v
+	// isolation) so just copy the toolbox in the created container
+	if d.os != types.OSWindows {
+		if err := d.client.ContainerStart(ctx, containerID, dockertypes.ContainerStartOptions{}); err != nil {
+			return nil, errors.WithStack(err)
+		}
 	}
 
-	toolboxExecPath, err := toolboxExecPath(d.toolboxPath, d.arch)
+	toolboxExecPath, err := toolboxExecPath(d.toolboxPath, d.os, d.arch)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get toolbox path for arch %q", d.arch)
 	}
@@ -113,7 +153,7 @@ func (d *DockerDriver) createToolboxVolume(ctx context.Context, podID string, ou
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	srcInfo.RebaseName = "agola-toolbox"
+	srcInfo.RebaseName = toolboxContainerName(d.os)
 
 	srcArchive, err := archive.TarResource(srcInfo)
 	if err != nil {
@@ -126,7 +166,7 @@ func (d *DockerDriver) createToolboxVolume(ctx context.Context, podID string, ou
 		CopyUIDGID:                false,
 	}
 
-	if err := d.client.CopyToContainer(ctx, containerID, "/tmp/agola", srcArchive, options); err != nil {
+	if err := d.client.CopyToContainer(ctx, containerID, d.toolboxVolumeTmpDir(), srcArchive, options); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
@@ -136,11 +176,63 @@ func (d *DockerDriver) createToolboxVolume(ctx context.Context, podID string, ou
 	return &toolboxVol, nil
 }
 
+// podNetworkName returns the name of the pod dedicated network
+func podNetworkName(podID string) string {
+	return "agola-pod-" + podID
+}
+
+// createPodNetwork creates a dedicated network for the pod so the pod
+// containers are isolated from the other pods
+func (d *DockerDriver) createPodNetwork(ctx context.Context, podID string) (string, error) {
+	networkDriver := "bridge"
+	if d.os == types.OSWindows {
+		networkDriver = "nat"
+	}
+
+	labels := map[string]string{}
+	labels[agolaLabelKey] = agolaLabelValue
+	labels[executorIDKey] = d.executorID
+	labels[podIDKey] = podID
+	resp, err := d.client.NetworkCreate(ctx, podNetworkName(podID), dockertypes.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         networkDriver,
+		Labels:         labels,
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return resp.ID, nil
+}
+
 func (d *DockerDriver) Archs(ctx context.Context) ([]types.Arch, error) {
 	// since we are using the local docker driver we can return our go arch information
 	return []types.Arch{d.arch}, nil
 }
 
+func (d *DockerDriver) OS(ctx context.Context) (types.OS, error) {
+	return d.os, nil
+}
+
+func (d *DockerDriver) Labels(ctx context.Context) (map[string]string, error) {
+	labels := map[string]string{
+		rstypes.ExecutorLabelDriver:        "docker",
+		rstypes.ExecutorLabelDockerVersion: d.serverVersion,
+	}
+	if d.kernelVersion != "" {
+		labels[rstypes.ExecutorLabelKernelVersion] = d.kernelVersion
+	}
+	return labels, nil
+}
+
+func (d *DockerDriver) ImagesSize(ctx context.Context) (int64, error) {
+	du, err := d.client.DiskUsage(ctx)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return du.LayersSize, nil
+}
+
 func (d *DockerDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.Writer) (Pod, error) {
 	if len(podConfig.Containers) == 0 {
 		return nil, errors.Errorf("empty container config")
@@ -151,9 +243,14 @@ func (d *DockerDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.
 		return nil, errors.WithStack(err)
 	}
 
+	networkID, err := d.createPodNetwork(ctx, podConfig.ID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	var mainContainerID string
 	for cindex := range podConfig.Containers {
-		resp, err := d.createContainer(ctx, cindex, podConfig, mainContainerID, toolboxVol, out)
+		resp, err := d.createContainer(ctx, cindex, podConfig, mainContainerID, networkID, toolboxVol, out)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -196,7 +293,9 @@ func (d *DockerDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.
 		executorID:        d.executorID,
 		containers:        []*DockerContainer{},
 		toolboxVolumeName: toolboxVol.Name,
+		networkID:         networkID,
 		initVolumeDir:     podConfig.InitVolumeDir,
+		os:                d.os,
 	}
 
 	count := 0
@@ -233,7 +332,7 @@ func (d *DockerDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.
 	return pod, nil
 }
 
-func (d *DockerDriver) fetchImage(ctx context.Context, image string, alwaysFetch bool, registryConfig *registry.DockerConfig, out io.Writer) error {
+func (d *DockerDriver) fetchImage(ctx context.Context, image string, pullPolicy rstypes.PullPolicy, registryConfig *registry.DockerConfig, out io.Writer) error {
 	regName, err := registry.GetRegistry(image)
 	if err != nil {
 		return errors.WithStack(err)
@@ -250,11 +349,6 @@ func (d *DockerDriver) fetchImage(ctx context.Context, image string, alwaysFetch
 	}
 	registryAuthEnc := base64.URLEncoding.EncodeToString(buf)
 
-	tag, err := registry.GetImageTagOrDigest(image)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
 	args := filters.NewArgs()
 	args.Add("reference", image)
 	img, err := d.client.ImageList(ctx, dockertypes.ImageListOptions{Filters: args})
@@ -263,26 +357,33 @@ func (d *DockerDriver) fetchImage(ctx context.Context, image string, alwaysFetch
 	}
 	exists := len(img) > 0
 
-	// fetch only if forced, is latest tag or image doesn't exist
-	if alwaysFetch || tag == "latest" || !exists {
-		reader, err := d.client.ImagePull(ctx, image, dockertypes.ImagePullOptions{RegistryAuth: registryAuthEnc})
-		if err != nil {
-			return errors.WithStack(err)
+	switch pullPolicy {
+	case rstypes.PullPolicyNever:
+		if !exists {
+			return errors.Errorf("image %q doesn't exist and pull policy is %q", image, pullPolicy)
+		}
+		return nil
+	case rstypes.PullPolicyIfNotPresent:
+		if exists {
+			return nil
 		}
+	}
 
-		_, err = io.Copy(out, reader)
+	reader, err := d.client.ImagePull(ctx, image, dockertypes.ImagePullOptions{RegistryAuth: registryAuthEnc})
+	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	return nil
+	_, err = io.Copy(out, reader)
+	return errors.WithStack(err)
 }
 
-func (d *DockerDriver) createContainer(ctx context.Context, index int, podConfig *PodConfig, maincontainerID string, toolboxVol *dockertypes.Volume, out io.Writer) (*container.ContainerCreateCreatedBody, error) {
+func (d *DockerDriver) createContainer(ctx context.Context, index int, podConfig *PodConfig, maincontainerID, networkID string, toolboxVol *dockertypes.Volume, out io.Writer) (*container.ContainerCreateCreatedBody, error) {
 	containerConfig := podConfig.Containers[index]
 
 	// by default always try to pull the image so we are sure only authorized users can fetch them
 	// see https://kubernetes.io/docs/reference/access-authn-authz/admission-controllers/#alwayspullimages
-	if err := d.fetchImage(ctx, containerConfig.Image, true, podConfig.DockerConfig, out); err != nil {
+	if err := d.fetchImage(ctx, containerConfig.Image, containerConfig.PullPolicy, podConfig.DockerConfig, out); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
@@ -300,6 +401,7 @@ func (d *DockerDriver) createContainer(ctx context.Context, index int, podConfig
 
 	cliContainerConfig := &container.Config{
 		Entrypoint: containerConfig.Cmd,
+		Cmd:        containerConfig.Args,
 		Env:        makeEnvSlice(containerConfig.Env),
 		WorkingDir: containerConfig.WorkingDir,
 		Image:      containerConfig.Image,
@@ -309,12 +411,57 @@ func (d *DockerDriver) createContainer(ctx context.Context, index int, podConfig
 
 	cliHostConfig := &container.HostConfig{
 		Privileged: containerConfig.Privileged,
+		CapAdd:     containerConfig.CapAdd,
+		CapDrop:    containerConfig.CapDrop,
+	}
+	if d.os == types.OSWindows && (len(containerConfig.CapAdd) > 0 || len(containerConfig.CapDrop) > 0 || len(containerConfig.Devices) > 0) {
+		return nil, errors.Errorf("capabilities and devices aren't supported by windows containers")
+	}
+	if containerConfig.GPUs > 0 {
+		cliHostConfig.DeviceRequests = []container.DeviceRequest{
+			{
+				Driver:       "nvidia",
+				Count:        containerConfig.GPUs,
+				Capabilities: [][]string{{"gpu"}},
+			},
+		}
+	}
+	for _, device := range containerConfig.Devices {
+		cliHostConfig.Devices = append(cliHostConfig.Devices, container.DeviceMapping{
+			PathOnHost:        device,
+			PathInContainer:   device,
+			CgroupPermissions: "rwm",
+		})
 	}
+	var cliNetworkingConfig *network.NetworkingConfig
 	if index == 0 {
+		// attach the main container to the pod network. Since all the other
+		// containers share the main container network namespace, all the
+		// container names are set as aliases of the main container so they
+		// can be resolved by the other containers
+		aliases := []string{}
+		for _, c := range podConfig.Containers {
+			if c.Name != "" {
+				aliases = append(aliases, c.Name)
+			}
+		}
+		cliHostConfig.NetworkMode = container.NetworkMode(podNetworkName(podConfig.ID))
+		cliNetworkingConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				podNetworkName(podConfig.ID): {
+					NetworkID: networkID,
+					Aliases:   aliases,
+				},
+			},
+		}
+
 		// main container requires the initvolume containing the toolbox
 		// TODO(sgotti) migrate this to cliHostConfig.Mounts
 		cliHostConfig.Binds = []string{fmt.Sprintf("%s:%s", toolboxVol.Name, podConfig.InitVolumeDir)}
-		cliHostConfig.ReadonlyPaths = []string{fmt.Sprintf("%s:%s", toolboxVol.Name, podConfig.InitVolumeDir)}
+		// readonly paths aren't supported by windows containers
+		if d.os != types.OSWindows {
+			cliHostConfig.ReadonlyPaths = []string{fmt.Sprintf("%s:%s", toolboxVol.Name, podConfig.InitVolumeDir)}
+		}
 	} else {
 		// attach other containers to maincontainer network
 		cliHostConfig.NetworkMode = container.NetworkMode(fmt.Sprintf("container:%s", maincontainerID))
@@ -323,6 +470,9 @@ func (d *DockerDriver) createContainer(ctx context.Context, index int, podConfig
 	var mounts []mount.Mount
 
 	for _, vol := range containerConfig.Volumes {
+		if vol.TmpFS != nil && d.os == types.OSWindows {
+			return nil, errors.Errorf("tmpfs volumes aren't supported by windows containers")
+		}
 		if vol.TmpFS != nil {
 			mounts = append(mounts, mount.Mount{
 				Type:   mount.TypeTmpfs,
@@ -339,7 +489,7 @@ func (d *DockerDriver) createContainer(ctx context.Context, index int, podConfig
 		cliHostConfig.Mounts = mounts
 	}
 
-	resp, err := d.client.ContainerCreate(ctx, cliContainerConfig, cliHostConfig, nil, "")
+	resp, err := d.client.ContainerCreate(ctx, cliContainerConfig, cliHostConfig, cliNetworkingConfig, "")
 	return &resp, errors.WithStack(err)
 }
 
@@ -369,6 +519,11 @@ func (d *DockerDriver) GetPods(ctx context.Context, all bool) ([]Pod, error) {
 		return nil, errors.WithStack(err)
 	}
 
+	networks, err := d.client.NetworkList(ctx, dockertypes.NetworkListOptions{Filters: args})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	podsMap := map[string]*DockerPod{}
 	for _, container := range containers {
 		executorID, ok := container.Labels[executorIDKey]
@@ -387,6 +542,7 @@ func (d *DockerDriver) GetPods(ctx context.Context, all bool) ([]Pod, error) {
 				client:     d.client,
 				executorID: d.executorID,
 				containers: []*DockerContainer{},
+				os:         d.os,
 				// TODO(sgotti) initvolumeDir isn't set
 			}
 			podsMap[podID] = pod
@@ -458,6 +614,27 @@ func (d *DockerDriver) GetPods(ctx context.Context, all bool) ([]Pod, error) {
 		pod.toolboxVolumeName = vol.Name
 	}
 
+	for _, net := range networks {
+		executorID, ok := net.Labels[executorIDKey]
+		if !ok || executorID != d.executorID {
+			// skip network
+			continue
+		}
+		podID, ok := net.Labels[podIDKey]
+		if !ok {
+			// skip network
+			continue
+		}
+
+		pod, ok := podsMap[podID]
+		if !ok {
+			// skip network
+			continue
+		}
+
+		pod.networkID = net.ID
+	}
+
 	pods := make([]Pod, 0, len(podsMap))
 	for _, pod := range podsMap {
 		// put the containers in the right order based on their container index
@@ -473,9 +650,11 @@ type DockerPod struct {
 	labels            map[string]string
 	containers        []*DockerContainer
 	toolboxVolumeName string
+	networkID         string
 	executorID        string
 
 	initVolumeDir string
+	os            types.OS
 }
 
 type DockerContainer struct {
@@ -527,12 +706,65 @@ func (dp *DockerPod) Remove(ctx context.Context) error {
 			errs = append(errs, err)
 		}
 	}
+	// the network can be removed only after all the attached containers are removed
+	if dp.networkID != "" {
+		if err := dp.client.NetworkRemove(ctx, dp.networkID); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	if len(errs) != 0 {
 		return errors.Errorf("remove errors: %v", errs)
 	}
 	return nil
 }
 
+func (dp *DockerPod) Stats(ctx context.Context) (*PodStats, error) {
+	stats := &PodStats{}
+	memoryUnlimited := false
+	for _, container := range dp.containers {
+		cs, err := dp.client.ContainerStats(ctx, container.ID, false)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var s dockertypes.StatsJSON
+		err = json.NewDecoder(cs.Body).Decode(&s)
+		cs.Body.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode container %q stats", container.ID)
+		}
+
+		cpuTime := time.Duration(s.CPUStats.CPUUsage.TotalUsage)
+		// on windows the cpu usage is reported in 100ns intervals
+		if cs.OSType == "windows" {
+			cpuTime *= 100
+		}
+		stats.CPUTime += cpuTime
+		stats.Memory += int64(s.MemoryStats.Usage)
+
+		// the stats memory limit is the host memory when the container has no
+		// limit, so take it from the container config
+		c, _, err := dp.client.ContainerInspectWithRaw(ctx, container.ID, true)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if c.HostConfig == nil || c.HostConfig.Memory == 0 {
+			memoryUnlimited = true
+		} else {
+			stats.MemoryLimit += c.HostConfig.Memory
+		}
+		if c.SizeRw != nil {
+			stats.Disk += *c.SizeRw
+		}
+	}
+
+	// the pod memory isn't limited if one of its containers isn't limited
+	if memoryUnlimited {
+		stats.MemoryLimit = 0
+	}
+
+	return stats, nil
+}
+
 type DockerContainerExec struct {
 	execID string
 	hresp  *dockertypes.HijackedResponse
@@ -568,7 +800,7 @@ func (dp *DockerPod) Exec(ctx context.Context, execConfig *ExecConfig) (Containe
 		return nil, errors.WithStack(err)
 	}
 
-	cmd := []string{filepath.Join(dp.initVolumeDir, "agola-toolbox"), "exec", "-e", string(envj), "-w", execConfig.WorkingDir, "--"}
+	cmd := []string{ToolboxContainerPath(dp.os, dp.initVolumeDir), "exec", "-e", string(envj), "-w", execConfig.WorkingDir, "--"}
 	cmd = append(cmd, execConfig.Cmd...)
 
 	dockerExecConfig := dockertypes.ExecConfig{
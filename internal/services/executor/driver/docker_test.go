@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"agola.io/agola/internal/testutil"
+	rstypes "agola.io/agola/services/runservice/types"
 
 	"github.com/docker/docker/api/types"
 	"github.com/gofrs/uuid"
@@ -71,6 +72,25 @@ func TestDockerPod(t *testing.T) {
 		defer func() { _ = pod.Remove(ctx) }()
 	})
 
+	t.Run("create a pod with a missing image and pull policy never", func(t *testing.T) {
+		pod, err := d.NewPod(ctx, &PodConfig{
+			ID:     uuid.Must(uuid.NewV4()).String(),
+			TaskID: uuid.Must(uuid.NewV4()).String(),
+			Containers: []*ContainerConfig{
+				&ContainerConfig{
+					Cmd:        []string{"cat"},
+					Image:      "agola-missing-image:" + uuid.Must(uuid.NewV4()).String(),
+					PullPolicy: rstypes.PullPolicyNever,
+				},
+			},
+			InitVolumeDir: "/tmp/agola",
+		}, ioutil.Discard)
+		if err == nil {
+			_ = pod.Remove(ctx)
+			t.Fatalf("expected error creating pod")
+		}
+	})
+
 	t.Run("execute a command inside a pod", func(t *testing.T) {
 		pod, err := d.NewPod(ctx, &PodConfig{
 			ID:     uuid.Must(uuid.NewV4()).String(),
@@ -221,6 +241,132 @@ func TestDockerPod(t *testing.T) {
 		}
 	})
 
+	t.Run("create a pod with an image with a custom entrypoint", func(t *testing.T) {
+		// the alpine/git image entrypoint is git, the container cmd must
+		// override it
+		pod, err := d.NewPod(ctx, &PodConfig{
+			ID:     uuid.Must(uuid.NewV4()).String(),
+			TaskID: uuid.Must(uuid.NewV4()).String(),
+			Containers: []*ContainerConfig{
+				&ContainerConfig{
+					Cmd:   []string{"cat"},
+					Image: "alpine/git",
+				},
+			},
+			InitVolumeDir: "/tmp/agola",
+		}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		defer func() { _ = pod.Remove(ctx) }()
+
+		ce, err := pod.Exec(ctx, &ExecConfig{
+			Cmd: []string{"git", "--version"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		code, err := ce.Wait(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d", code)
+		}
+	})
+
+	t.Run("test service container with cmd and args", func(t *testing.T) {
+		pod, err := d.NewPod(ctx, &PodConfig{
+			ID:     uuid.Must(uuid.NewV4()).String(),
+			TaskID: uuid.Must(uuid.NewV4()).String(),
+			Containers: []*ContainerConfig{
+				&ContainerConfig{
+					Cmd:   []string{"cat"},
+					Image: "busybox",
+				},
+				&ContainerConfig{
+					Cmd:   []string{"httpd"},
+					Args:  []string{"-f", "-p", "8080"},
+					Image: "busybox",
+				},
+			},
+			InitVolumeDir: "/tmp/agola",
+		}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		defer func() { _ = pod.Remove(ctx) }()
+
+		// wait for httpd up
+		time.Sleep(1 * time.Second)
+
+		ce, err := pod.Exec(ctx, &ExecConfig{
+			Cmd: []string{"nc", "-z", "localhost", "8080"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		code, err := ce.Wait(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d", code)
+		}
+	})
+
+	t.Run("test communication between two containers using the container name", func(t *testing.T) {
+		podID := uuid.Must(uuid.NewV4()).String()
+		pod, err := d.NewPod(ctx, &PodConfig{
+			ID:     podID,
+			TaskID: uuid.Must(uuid.NewV4()).String(),
+			Containers: []*ContainerConfig{
+				&ContainerConfig{
+					Cmd:   []string{"cat"},
+					Image: "busybox",
+				},
+				&ContainerConfig{
+					Name:  "nginx",
+					Image: "nginx:1.16",
+				},
+			},
+			InitVolumeDir: "/tmp/agola",
+		}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		defer func() { _ = pod.Remove(ctx) }()
+
+		// wait for nginx up
+		time.Sleep(1 * time.Second)
+
+		ce, err := pod.Exec(ctx, &ExecConfig{
+			Cmd: []string{"nc", "-z", "nginx", "80"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		code, err := ce.Wait(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d", code)
+		}
+
+		if err := pod.Remove(ctx); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		// check that the pod network has been removed
+		if _, err := d.client.NetworkInspect(ctx, podNetworkName(podID), types.NetworkInspectOptions{}); err == nil {
+			t.Fatalf("expected pod network %q to be removed", podNetworkName(podID))
+		}
+	})
+
 	t.Run("test get pods single container", func(t *testing.T) {
 		pod, err := d.NewPod(ctx, &PodConfig{
 			ID:     uuid.Must(uuid.NewV4()).String(),
@@ -18,11 +18,16 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/services/executor/registry"
+	rstypes "agola.io/agola/services/runservice/types"
 	"agola.io/agola/services/types"
 )
 
@@ -55,6 +60,14 @@ type Driver interface {
 	ExecutorGroup(ctx context.Context) (string, error)
 	GetExecutors(ctx context.Context) ([]string, error)
 	Archs(ctx context.Context) ([]types.Arch, error)
+	// OS returns the os of the containers executed by the driver
+	OS(ctx context.Context) (types.OS, error)
+	// Labels returns the executor labels detected by the driver (driver type,
+	// kernel version, runtime version etc...)
+	Labels(ctx context.Context) (map[string]string, error)
+	// ImagesSize returns the size in bytes of the images cached by the driver.
+	// Drivers not caching images locally return 0
+	ImagesSize(ctx context.Context) (int64, error)
 }
 
 type Pod interface {
@@ -70,6 +83,22 @@ type Pod interface {
 	Remove(ctx context.Context) error
 	// Exec executes a command inside the first container in the Pod
 	Exec(ctx context.Context, execConfig *ExecConfig) (ContainerExec, error)
+	// Stats returns the resource usage of the pod containers. It returns nil
+	// stats if the driver doesn't support it
+	Stats(ctx context.Context) (*PodStats, error)
+}
+
+// PodStats contains the resource usage of all the pod containers
+type PodStats struct {
+	// CPUTime is the total cpu time used by the containers
+	CPUTime time.Duration
+	// Memory is the memory used by the containers in bytes
+	Memory int64
+	// MemoryLimit is the sum of the containers memory limits in bytes. 0 means
+	// no limit
+	MemoryLimit int64
+	// Disk is the disk space used by the containers in bytes
+	Disk int64
 }
 
 type ContainerExec interface {
@@ -85,16 +114,48 @@ type PodConfig struct {
 	// The container dir where the init volume will be mounted
 	InitVolumeDir string
 	DockerConfig  *registry.DockerConfig
+	// NodeSelector and Tolerations are used only by the k8s driver
+	NodeSelector map[string]string
+	Tolerations  []rstypes.Toleration
 }
 
 type ContainerConfig struct {
-	Cmd        []string
+	// Name is the optional container name, other pod containers can reach
+	// the container using it as hostname
+	Name string
+	// Cmd overrides the image entrypoint
+	Cmd []string
+	// Args overrides the image command. When Cmd is defined and Args is
+	// empty the image command isn't used
+	Args       []string
 	Env        map[string]string
 	WorkingDir string
 	Image      string
 	User       string
 	Privileged bool
 	Volumes    []Volume
+	Resources  Resources
+	PullPolicy rstypes.PullPolicy
+	// CapAdd and CapDrop are the capabilities added to or dropped from the
+	// container default ones
+	CapAdd  []string
+	CapDrop []string
+	// Devices are the host devices paths to make available in the container
+	Devices []string
+	// GPUs is the number of nvidia GPUs assigned to the container
+	GPUs int
+}
+
+type Resources struct {
+	Requests ResourceList
+	Limits   ResourceList
+}
+
+// ResourceList defines the cpu (in millicores) and memory (in bytes)
+// resources. Zero values mean not defined.
+type ResourceList struct {
+	CPU    int64
+	Memory int64
 }
 
 type Volume struct {
@@ -118,11 +179,41 @@ type ExecConfig struct {
 	Tty         bool
 }
 
-func toolboxExecPath(toolboxDir string, arch types.Arch) (string, error) {
-	toolboxPath := filepath.Join(toolboxDir, fmt.Sprintf("%s-linux-%s", toolboxPrefix, arch))
+func toolboxExecPath(toolboxDir string, containerOS types.OS, arch types.Arch) (string, error) {
+	toolboxPath := filepath.Join(toolboxDir, fmt.Sprintf("%s-%s-%s", toolboxPrefix, containerOS, arch))
+	if containerOS == types.OSWindows {
+		toolboxPath += ".exe"
+	}
 	_, err := os.Stat(toolboxPath)
 	if err != nil {
 		return "", errors.WithStack(err)
 	}
 	return toolboxPath, nil
 }
+
+// toolboxContainerName returns the toolbox file name inside the containers
+func toolboxContainerName(containerOS types.OS) string {
+	if containerOS == types.OSWindows {
+		return toolboxPrefix + ".exe"
+	}
+	return toolboxPrefix
+}
+
+// ToolboxContainerPath returns the toolbox path inside the provided container
+// init volume dir for the provided container os
+func ToolboxContainerPath(containerOS types.OS, initVolumeDir string) string {
+	if containerOS == types.OSWindows {
+		return initVolumeDir + `\` + toolboxContainerName(containerOS)
+	}
+	return path.Join(initVolumeDir, toolboxContainerName(containerOS))
+}
+
+// hostKernelVersion returns the kernel version of the local host. It returns
+// an empty string when it cannot be detected (i.e. on non linux hosts)
+func hostKernelVersion() string {
+	data, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
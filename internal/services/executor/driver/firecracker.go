@@ -0,0 +1,760 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/toolbox/vmagent"
+	rstypes "agola.io/agola/services/runservice/types"
+	"agola.io/agola/services/types"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	firecrackerDefaultKernelArgs = "console=ttyS0 reboot=k panic=1 pci=off"
+	firecrackerDefaultVCPUs      = 1
+	firecrackerDefaultMemoryMiB  = 1024
+
+	// firecrackerGuestCID is the guest vsock context id. Every microVM has
+	// its own vsock device so the same cid can be used by all of them
+	firecrackerGuestCID = 3
+
+	firecrackerRootfsFile   = "rootfs.ext4"
+	firecrackerAPISockFile  = "api.sock"
+	firecrackerVsockFile    = "vsock.sock"
+	firecrackerLogFile      = "firecracker.log"
+	firecrackerPodStateFile = "pod.json"
+
+	firecrackerStartTimeout      = 10 * time.Second
+	firecrackerAgentStartTimeout = 2 * time.Minute
+)
+
+type FirecrackerConfig struct {
+	BinaryPath  string
+	KernelImage string
+	KernelArgs  string
+	RootfsImage string
+	VCPUs       int
+	MemoryMiB   int
+	TapDevices  []string
+}
+
+// FirecrackerDriver runs every pod inside a firecracker microVM booted from
+// a copy of the configured rootfs image. The commands are executed by the
+// toolbox agent running inside the guest and reached using the firecracker
+// vsock device. Since the microVM is the isolation unit only pods with a
+// single container are supported and the container image is ignored.
+type FirecrackerDriver struct {
+	log         zerolog.Logger
+	c           *FirecrackerConfig
+	dataDir     string
+	toolboxPath string
+	executorID  string
+	arch        types.Arch
+
+	// podsMu serializes the pods creation and removal since the tap
+	// devices assignment is computed from the current pods
+	podsMu sync.Mutex
+}
+
+func NewFirecrackerDriver(log zerolog.Logger, executorID, toolboxPath, dataDir string, c *FirecrackerConfig) (*FirecrackerDriver, error) {
+	fc := *c
+	if fc.BinaryPath == "" {
+		binaryPath, err := exec.LookPath("firecracker")
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot find firecracker executable")
+		}
+		fc.BinaryPath = binaryPath
+	}
+	if fc.KernelArgs == "" {
+		fc.KernelArgs = firecrackerDefaultKernelArgs
+	}
+	if fc.VCPUs == 0 {
+		fc.VCPUs = firecrackerDefaultVCPUs
+	}
+	if fc.MemoryMiB == 0 {
+		fc.MemoryMiB = firecrackerDefaultMemoryMiB
+	}
+
+	return &FirecrackerDriver{
+		log:         log,
+		c:           &fc,
+		dataDir:     dataDir,
+		toolboxPath: toolboxPath,
+		executorID:  executorID,
+		arch:        types.ArchFromString(runtime.GOARCH),
+	}, nil
+}
+
+func (d *FirecrackerDriver) Setup(ctx context.Context) error {
+	for _, p := range []string{d.c.BinaryPath, d.c.KernelImage, d.c.RootfsImage} {
+		if _, err := os.Stat(p); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := os.MkdirAll(d.dataDir, 0770); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (d *FirecrackerDriver) Archs(ctx context.Context) ([]types.Arch, error) {
+	// the microVMs run on the local host so we can return our go arch information
+	return []types.Arch{d.arch}, nil
+}
+
+func (d *FirecrackerDriver) OS(ctx context.Context) (types.OS, error) {
+	return types.OSLinux, nil
+}
+
+func (d *FirecrackerDriver) Labels(ctx context.Context) (map[string]string, error) {
+	// the kernel version isn't reported since the microVMs use the configured
+	// kernel image and not the host kernel
+	return map[string]string{
+		rstypes.ExecutorLabelDriver: "firecracker",
+	}, nil
+}
+
+func (d *FirecrackerDriver) ImagesSize(ctx context.Context) (int64, error) {
+	// the microVMs use a copy of the configured rootfs image, no images are
+	// cached
+	return 0, nil
+}
+
+func (d *FirecrackerDriver) ExecutorGroup(ctx context.Context) (string, error) {
+	// use the same group as the executor id
+	return d.executorID, nil
+}
+
+func (d *FirecrackerDriver) GetExecutors(ctx context.Context) ([]string, error) {
+	return []string{d.executorID}, nil
+}
+
+// firecrackerPodState is the pod state saved in the pod dir
+type firecrackerPodState struct {
+	ID            string            `json:"id"`
+	TaskID        string            `json:"task_id"`
+	ExecutorID    string            `json:"executor_id"`
+	PID           int               `json:"pid"`
+	TapDevice     string            `json:"tap_device"`
+	InitVolumeDir string            `json:"init_volume_dir"`
+	Env           map[string]string `json:"env"`
+	User          string            `json:"user"`
+}
+
+func (d *FirecrackerDriver) podDir(podID string) string {
+	return filepath.Join(d.dataDir, podID)
+}
+
+func (d *FirecrackerDriver) savePodState(state *firecrackerPodState) error {
+	statej, err := json.Marshal(state)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(ioutil.WriteFile(filepath.Join(d.podDir(state.ID), firecrackerPodStateFile), statej, 0600))
+}
+
+func (d *FirecrackerDriver) podStates() ([]*firecrackerPodState, error) {
+	entries, err := ioutil.ReadDir(d.dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	states := []*firecrackerPodState{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		statej, err := ioutil.ReadFile(filepath.Join(d.dataDir, entry.Name(), firecrackerPodStateFile))
+		if err != nil {
+			// pod not yet created or partially removed
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.WithStack(err)
+		}
+		var state *firecrackerPodState
+		if err := json.Unmarshal(statej, &state); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+// freeTapDevice returns a tap device not used by the current pods
+func (d *FirecrackerDriver) freeTapDevice() (string, error) {
+	states, err := d.podStates()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	usedTaps := map[string]struct{}{}
+	for _, state := range states {
+		usedTaps[state.TapDevice] = struct{}{}
+	}
+	for _, tap := range d.c.TapDevices {
+		if _, ok := usedTaps[tap]; !ok {
+			return tap, nil
+		}
+	}
+
+	return "", errors.Errorf("no free tap device")
+}
+
+func (d *FirecrackerDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.Writer) (Pod, error) {
+	if len(podConfig.Containers) == 0 {
+		return nil, errors.Errorf("empty container config")
+	}
+	if len(podConfig.Containers) > 1 {
+		return nil, errors.Errorf("firecracker driver doesn't support pods with multiple containers")
+	}
+	if podConfig.Arch != "" && podConfig.Arch != d.arch {
+		return nil, errors.Errorf("unsupported arch %q", podConfig.Arch)
+	}
+
+	containerConfig := podConfig.Containers[0]
+	if len(containerConfig.CapAdd) > 0 || len(containerConfig.CapDrop) > 0 || len(containerConfig.Devices) > 0 {
+		return nil, errors.Errorf("firecracker driver doesn't support container capabilities and devices")
+	}
+	if containerConfig.GPUs > 0 {
+		return nil, errors.Errorf("firecracker driver doesn't support gpus")
+	}
+
+	vcpus := d.c.VCPUs
+	if cpu := containerConfig.Resources.Limits.CPU; cpu != 0 {
+		vcpus = int((cpu + 999) / 1000)
+	}
+	memoryMiB := d.c.MemoryMiB
+	if memory := containerConfig.Resources.Limits.Memory; memory != 0 {
+		memoryMiB = int((memory + 1024*1024 - 1) / (1024 * 1024))
+	}
+
+	pod, err := d.startMicroVM(ctx, podConfig, containerConfig, vcpus, memoryMiB, out)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := pod.waitAgent(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	toolboxExecPath, err := toolboxExecPath(d.toolboxPath, types.OSLinux, d.arch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get toolbox path for arch %q", d.arch)
+	}
+	if err := pod.putFile(ctx, toolboxExecPath, filepath.Join(podConfig.InitVolumeDir, "agola-toolbox"), 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to copy toolbox to the microVM")
+	}
+
+	for _, vol := range containerConfig.Volumes {
+		if vol.TmpFS == nil {
+			return nil, errors.Errorf("missing volume config")
+		}
+		opts := "mode=1777"
+		if vol.TmpFS.Size != 0 {
+			opts += fmt.Sprintf(",size=%d", vol.TmpFS.Size)
+		}
+		if err := pod.run(ctx, "mkdir", "-p", vol.Path); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := pod.run(ctx, "mount", "-t", "tmpfs", "-o", opts, "tmpfs", vol.Path); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return pod, nil
+}
+
+func (d *FirecrackerDriver) startMicroVM(ctx context.Context, podConfig *PodConfig, containerConfig *ContainerConfig, vcpus, memoryMiB int, out io.Writer) (*FirecrackerPod, error) {
+	d.podsMu.Lock()
+	defer d.podsMu.Unlock()
+
+	var tap string
+	if len(d.c.TapDevices) > 0 {
+		var err error
+		tap, err = d.freeTapDevice()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	podDir := d.podDir(podConfig.ID)
+	if err := os.MkdirAll(podDir, 0770); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	state := &firecrackerPodState{
+		ID:            podConfig.ID,
+		TaskID:        podConfig.TaskID,
+		ExecutorID:    d.executorID,
+		TapDevice:     tap,
+		InitVolumeDir: podConfig.InitVolumeDir,
+		Env:           containerConfig.Env,
+		User:          containerConfig.User,
+	}
+	// save the state before starting the microVM so the pod dir will be
+	// removed also if the pod creation fails
+	if err := d.savePodState(state); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	fmt.Fprintf(out, "Starting firecracker microVM with rootfs %q (the task image %q is ignored).\n", d.c.RootfsImage, containerConfig.Image)
+
+	rootfsPath := filepath.Join(podDir, firecrackerRootfsFile)
+	if err := copyFile(d.c.RootfsImage, rootfsPath, 0600); err != nil {
+		return nil, errors.Wrapf(err, "failed to copy rootfs image")
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(podDir, firecrackerLogFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer logFile.Close()
+
+	apiSock := filepath.Join(podDir, firecrackerAPISockFile)
+	cmd := exec.Command(d.c.BinaryPath, "--api-sock", apiSock, "--id", podConfig.ID)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	// use a new process group to not receive the signals sent to the executor
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// reap the process when it exits
+	go func() { _ = cmd.Wait() }()
+
+	state.PID = cmd.Process.Pid
+	if err := d.savePodState(state); err != nil {
+		_ = cmd.Process.Kill()
+		return nil, errors.WithStack(err)
+	}
+
+	pod := &FirecrackerPod{d: d, state: state}
+
+	if err := pod.configureMicroVM(ctx, rootfsPath, vcpus, memoryMiB); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return pod, nil
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	sf, err := os.Open(src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer sf.Close()
+
+	df, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := io.Copy(df, sf); err != nil {
+		df.Close()
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(df.Close())
+}
+
+func (d *FirecrackerDriver) GetPods(ctx context.Context, all bool) ([]Pod, error) {
+	states, err := d.podStates()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pods := []Pod{}
+	for _, state := range states {
+		if !all && state.ExecutorID != d.executorID {
+			continue
+		}
+		pods = append(pods, &FirecrackerPod{d: d, state: state})
+	}
+
+	return pods, nil
+}
+
+type FirecrackerPod struct {
+	d     *FirecrackerDriver
+	state *firecrackerPodState
+}
+
+func (fp *FirecrackerPod) ID() string {
+	return fp.state.ID
+}
+
+func (fp *FirecrackerPod) ExecutorID() string {
+	return fp.state.ExecutorID
+}
+
+func (fp *FirecrackerPod) TaskID() string {
+	return fp.state.TaskID
+}
+
+func (fp *FirecrackerPod) podDir() string {
+	return fp.d.podDir(fp.state.ID)
+}
+
+// apiRequest executes a request to the firecracker api server
+func (fp *FirecrackerPod) apiRequest(ctx context.Context, path string, body interface{}) error {
+	apiSock := filepath.Join(fp.podDir(), firecrackerAPISockFile)
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", apiSock)
+			},
+		},
+	}
+
+	bodyj, err := json.Marshal(body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "PUT", "http://localhost"+path, bytes.NewReader(bodyj))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("firecracker api request %s failed with status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	return nil
+}
+
+func (fp *FirecrackerPod) configureMicroVM(ctx context.Context, rootfsPath string, vcpus, memoryMiB int) error {
+	// wait for the api socket
+	apiSock := filepath.Join(fp.podDir(), firecrackerAPISockFile)
+	start := time.Now()
+	for {
+		if _, err := os.Stat(apiSock); err == nil {
+			break
+		}
+		if time.Since(start) > firecrackerStartTimeout {
+			return errors.Errorf("timeout waiting for firecracker api socket")
+		}
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	type apiReq struct {
+		path string
+		body interface{}
+	}
+	reqs := []apiReq{
+		{"/machine-config", map[string]interface{}{
+			"vcpu_count":   vcpus,
+			"mem_size_mib": memoryMiB,
+		}},
+		{"/boot-source", map[string]interface{}{
+			"kernel_image_path": fp.d.c.KernelImage,
+			"boot_args":         fp.d.c.KernelArgs,
+		}},
+		{"/drives/rootfs", map[string]interface{}{
+			"drive_id":       "rootfs",
+			"path_on_host":   rootfsPath,
+			"is_root_device": true,
+			"is_read_only":   false,
+		}},
+	}
+	if fp.state.TapDevice != "" {
+		reqs = append(reqs, apiReq{"/network-interfaces/eth0", map[string]interface{}{
+			"iface_id":      "eth0",
+			"host_dev_name": fp.state.TapDevice,
+		}})
+	}
+	reqs = append(reqs,
+		apiReq{"/vsock", map[string]interface{}{
+			"vsock_id":  "vsock0",
+			"guest_cid": firecrackerGuestCID,
+			"uds_path":  filepath.Join(fp.podDir(), firecrackerVsockFile),
+		}},
+		apiReq{"/actions", map[string]interface{}{
+			"action_type": "InstanceStart",
+		}},
+	)
+
+	for _, req := range reqs {
+		if err := fp.apiRequest(ctx, req.path, req.body); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// agentConn connects to the guest agent using the firecracker vsock unix
+// socket handshake
+func (fp *FirecrackerPod) agentConn(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", filepath.Join(fp.podDir(), firecrackerVsockFile))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(firecrackerStartTimeout)); err != nil {
+		conn.Close()
+		return nil, errors.WithStack(err)
+	}
+	if _, err := fmt.Fprintf(conn, "CONNECT %d\n", vmagent.DefaultPort); err != nil {
+		conn.Close()
+		return nil, errors.WithStack(err)
+	}
+	// read the reply one byte at a time to not consume the agent data
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := conn.Read(b); err != nil {
+			conn.Close()
+			return nil, errors.WithStack(err)
+		}
+		if b[0] == '\n' {
+			break
+		}
+		line = append(line, b[0])
+	}
+	if !strings.HasPrefix(string(line), "OK ") {
+		conn.Close()
+		return nil, errors.Errorf("vsock connection failed: %q", line)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, errors.WithStack(err)
+	}
+
+	return conn, nil
+}
+
+// waitAgent waits for the guest agent to accept connections
+func (fp *FirecrackerPod) waitAgent(ctx context.Context) error {
+	start := time.Now()
+	for {
+		conn, err := fp.agentConn(ctx)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Since(start) > firecrackerAgentStartTimeout {
+			return errors.Wrapf(err, "timeout waiting for the microVM agent")
+		}
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func (fp *FirecrackerPod) putFile(ctx context.Context, src, dst string, mode os.FileMode) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	conn, err := fp.agentConn(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(vmagent.PutFile(conn, dst, mode, f))
+}
+
+// run executes the provided command as root returning an error if it fails
+func (fp *FirecrackerPod) run(ctx context.Context, cmd ...string) error {
+	conn, err := fp.agentConn(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var stderr bytes.Buffer
+	e, err := vmagent.Start(conn, &vmagent.Request{Type: vmagent.RequestTypeExec, Cmd: cmd}, ioutil.Discard, &stderr)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := e.Stdin().Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	exitCode, err := e.Wait()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if exitCode != 0 {
+		return errors.Errorf("%s failed with exit code %d: %s", cmd[0], exitCode, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// kill kills the firecracker process
+func (fp *FirecrackerPod) kill() error {
+	if fp.state.PID == 0 {
+		return nil
+	}
+	// check that the pid is still a firecracker process of this pod since it
+	// could have been reused
+	cmdline, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", fp.state.PID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	if !bytes.Contains(cmdline, []byte(fp.podDir())) {
+		return nil
+	}
+
+	if err := syscall.Kill(fp.state.PID, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (fp *FirecrackerPod) Stop(ctx context.Context) error {
+	return errors.WithStack(fp.kill())
+}
+
+func (fp *FirecrackerPod) Remove(ctx context.Context) error {
+	fp.d.podsMu.Lock()
+	defer fp.d.podsMu.Unlock()
+
+	if err := fp.kill(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.RemoveAll(fp.podDir()))
+}
+
+// Stats isn't currently supported by the firecracker driver
+func (fp *FirecrackerPod) Stats(ctx context.Context) (*PodStats, error) {
+	return nil, nil
+}
+
+func (fp *FirecrackerPod) Exec(ctx context.Context, execConfig *ExecConfig) (ContainerExec, error) {
+	user := execConfig.User
+	if user == "" {
+		user = fp.state.User
+	}
+
+	// use the toolbox exec command to set the env and the working dir like
+	// done by the docker driver. The exec env overrides the container env
+	env := map[string]string{}
+	for k, v := range fp.state.Env {
+		env[k] = v
+	}
+	for k, v := range execConfig.Env {
+		env[k] = v
+	}
+	envj, err := json.Marshal(env)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cmd := []string{filepath.Join(fp.state.InitVolumeDir, "agola-toolbox"), "exec", "-e", string(envj), "-w", execConfig.WorkingDir, "--"}
+	cmd = append(cmd, execConfig.Cmd...)
+
+	conn, err := fp.agentConn(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	stdout := execConfig.Stdout
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	stderr := execConfig.Stderr
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+
+	e, err := vmagent.Start(conn, &vmagent.Request{Type: vmagent.RequestTypeExec, Cmd: cmd, User: user}, stdout, stderr)
+	if err != nil {
+		conn.Close()
+		return nil, errors.WithStack(err)
+	}
+
+	var stdin io.WriteCloser
+	if execConfig.AttachStdin {
+		stdin = e.Stdin()
+	} else if err := e.Stdin().Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	endCh := make(chan execResult, 1)
+	go func() {
+		exitCode, err := e.Wait()
+		endCh <- execResult{exitCode: exitCode, err: err}
+	}()
+
+	return &FirecrackerContainerExec{
+		stdin: stdin,
+		endCh: endCh,
+	}, nil
+}
+
+type execResult struct {
+	exitCode int
+	err      error
+}
+
+type FirecrackerContainerExec struct {
+	stdin io.WriteCloser
+	endCh chan execResult
+}
+
+func (e *FirecrackerContainerExec) Wait(ctx context.Context) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, errors.WithStack(ctx.Err())
+	case res := <-e.endCh:
+		return res.exitCode, errors.WithStack(res.err)
+	}
+}
+
+func (e *FirecrackerContainerExec) Stdin() io.WriteCloser {
+	return e.stdin
+}
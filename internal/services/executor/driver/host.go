@@ -0,0 +1,437 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	osuser "os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"agola.io/agola/internal/errors"
+	rstypes "agola.io/agola/services/runservice/types"
+	"agola.io/agola/services/types"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	hostPodStateFile = "pod.json"
+	hostInitDir      = "init"
+	hostHomeDir      = "home"
+	hostRunMetaFile  = "run-meta.json"
+	hostMetricsFile  = "metrics.json"
+)
+
+// hostInheritedEnv are the executor environment variables inherited by the
+// executed commands. The other variables aren't inherited to not leak the
+// executor environment to the tasks.
+var hostInheritedEnv = []string{"PATH", "LANG", "LC_ALL", "TMPDIR", "USER", "LOGNAME", "SHELL"}
+
+// HostDriver executes the pods commands directly on the executor host, as the
+// executor user, without any isolation. Every pod has its own directory,
+// used as the HOME and where the init volume dir is mapped. Only pods with a
+// single container, without image, are supported.
+type HostDriver struct {
+	log         zerolog.Logger
+	dataDir     string
+	toolboxPath string
+	executorID  string
+	arch        types.Arch
+
+	// procs contains the process groups started by every pod
+	procsMu sync.Mutex
+	procs   map[string]map[int]struct{}
+}
+
+func NewHostDriver(log zerolog.Logger, executorID, toolboxPath, dataDir string) (*HostDriver, error) {
+	return &HostDriver{
+		log:         log,
+		dataDir:     dataDir,
+		toolboxPath: toolboxPath,
+		executorID:  executorID,
+		arch:        types.ArchFromString(runtime.GOARCH),
+		procs:       make(map[string]map[int]struct{}),
+	}, nil
+}
+
+func (d *HostDriver) Setup(ctx context.Context) error {
+	if err := os.MkdirAll(d.dataDir, 0770); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (d *HostDriver) Archs(ctx context.Context) ([]types.Arch, error) {
+	// we are executing on the local host so we can return our go arch information
+	return []types.Arch{d.arch}, nil
+}
+
+func (d *HostDriver) OS(ctx context.Context) (types.OS, error) {
+	return types.OS(runtime.GOOS), nil
+}
+
+func (d *HostDriver) Labels(ctx context.Context) (map[string]string, error) {
+	labels := map[string]string{
+		rstypes.ExecutorLabelDriver: "host",
+	}
+	if kernelVersion := hostKernelVersion(); kernelVersion != "" {
+		labels[rstypes.ExecutorLabelKernelVersion] = kernelVersion
+	}
+	return labels, nil
+}
+
+func (d *HostDriver) ImagesSize(ctx context.Context) (int64, error) {
+	// host tasks don't use images
+	return 0, nil
+}
+
+func (d *HostDriver) ExecutorGroup(ctx context.Context) (string, error) {
+	// use the same group as the executor id
+	return d.executorID, nil
+}
+
+func (d *HostDriver) GetExecutors(ctx context.Context) ([]string, error) {
+	return []string{d.executorID}, nil
+}
+
+// hostToolboxExecPath returns the toolbox for the executor host os and arch
+func hostToolboxExecPath(toolboxDir string, arch types.Arch) (string, error) {
+	toolboxPath := filepath.Join(toolboxDir, fmt.Sprintf("%s-%s-%s", toolboxPrefix, runtime.GOOS, arch))
+	if _, err := os.Stat(toolboxPath); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return toolboxPath, nil
+}
+
+// hostPodState is the pod state saved in the pod dir
+type hostPodState struct {
+	ID            string            `json:"id"`
+	TaskID        string            `json:"task_id"`
+	ExecutorID    string            `json:"executor_id"`
+	InitVolumeDir string            `json:"init_volume_dir"`
+	Env           map[string]string `json:"env"`
+	User          string            `json:"user"`
+}
+
+func (d *HostDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.Writer) (Pod, error) {
+	if len(podConfig.Containers) == 0 {
+		return nil, errors.Errorf("empty container config")
+	}
+	if len(podConfig.Containers) > 1 {
+		return nil, errors.Errorf("host driver doesn't support pods with multiple containers")
+	}
+	if podConfig.Arch != "" && podConfig.Arch != d.arch {
+		return nil, errors.Errorf("unsupported arch %q", podConfig.Arch)
+	}
+
+	containerConfig := podConfig.Containers[0]
+	if containerConfig.Image != "" {
+		return nil, errors.Errorf("host driver doesn't support container images")
+	}
+	if containerConfig.Privileged {
+		return nil, errors.Errorf("host driver doesn't support privileged containers")
+	}
+	if len(containerConfig.CapAdd) > 0 || len(containerConfig.CapDrop) > 0 || len(containerConfig.Devices) > 0 {
+		return nil, errors.Errorf("host driver doesn't support capabilities and devices")
+	}
+	if containerConfig.GPUs > 0 {
+		return nil, errors.Errorf("host driver doesn't support gpus")
+	}
+	if len(containerConfig.Volumes) > 0 {
+		return nil, errors.Errorf("host driver doesn't support volumes")
+	}
+	if err := checkHostUser(containerConfig.User); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	podDir := filepath.Join(d.dataDir, podConfig.ID)
+	for _, dir := range []string{podDir, filepath.Join(podDir, hostInitDir), filepath.Join(podDir, hostHomeDir)} {
+		if err := os.MkdirAll(dir, 0770); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	state := &hostPodState{
+		ID:            podConfig.ID,
+		TaskID:        podConfig.TaskID,
+		ExecutorID:    d.executorID,
+		InitVolumeDir: podConfig.InitVolumeDir,
+		Env:           containerConfig.Env,
+		User:          containerConfig.User,
+	}
+	statej, err := json.Marshal(state)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(podDir, hostPodStateFile), statej, 0600); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	fmt.Fprintf(out, "Executing on host in directory %q.\n", podDir)
+
+	toolboxExecPath, err := hostToolboxExecPath(d.toolboxPath, d.arch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get toolbox path for arch %q", d.arch)
+	}
+	if err := copyFile(toolboxExecPath, filepath.Join(podDir, hostInitDir, "agola-toolbox"), 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to copy toolbox")
+	}
+
+	return &HostPod{d: d, state: state}, nil
+}
+
+func (d *HostDriver) GetPods(ctx context.Context, all bool) ([]Pod, error) {
+	entries, err := ioutil.ReadDir(d.dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Pod{}, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	pods := []Pod{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		statej, err := ioutil.ReadFile(filepath.Join(d.dataDir, entry.Name(), hostPodStateFile))
+		if err != nil {
+			// pod not yet created or partially removed
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.WithStack(err)
+		}
+		var state *hostPodState
+		if err := json.Unmarshal(statej, &state); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !all && state.ExecutorID != d.executorID {
+			continue
+		}
+		pods = append(pods, &HostPod{d: d, state: state})
+	}
+
+	return pods, nil
+}
+
+func (d *HostDriver) addProc(podID string, pid int) {
+	d.procsMu.Lock()
+	defer d.procsMu.Unlock()
+
+	if _, ok := d.procs[podID]; !ok {
+		d.procs[podID] = make(map[int]struct{})
+	}
+	d.procs[podID][pid] = struct{}{}
+}
+
+func (d *HostDriver) removeProc(podID string, pid int) {
+	d.procsMu.Lock()
+	defer d.procsMu.Unlock()
+
+	delete(d.procs[podID], pid)
+	if len(d.procs[podID]) == 0 {
+		delete(d.procs, podID)
+	}
+}
+
+// killProcs kills the process groups started by the pod. The processes
+// started before an executor restart aren't tracked.
+func (d *HostDriver) killProcs(podID string) error {
+	d.procsMu.Lock()
+	defer d.procsMu.Unlock()
+
+	for pid := range d.procs[podID] {
+		if err := killProcessGroup(pid); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// checkHostUser checks that the provided user is empty or the executor user
+// since the commands can only be executed as the executor user
+func checkHostUser(user string) error {
+	if user == "" {
+		return nil
+	}
+	curUser, err := osuser.Current()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	u := strings.SplitN(user, ":", 2)[0]
+	if u != curUser.Username && u != curUser.Uid {
+		return errors.Errorf("host driver can execute commands only as the executor user %q, requested user %q", curUser.Username, user)
+	}
+
+	return nil
+}
+
+type HostPod struct {
+	d     *HostDriver
+	state *hostPodState
+}
+
+func (hp *HostPod) ID() string {
+	return hp.state.ID
+}
+
+func (hp *HostPod) ExecutorID() string {
+	return hp.state.ExecutorID
+}
+
+func (hp *HostPod) TaskID() string {
+	return hp.state.TaskID
+}
+
+func (hp *HostPod) podDir() string {
+	return filepath.Join(hp.d.dataDir, hp.state.ID)
+}
+
+func (hp *HostPod) Stop(ctx context.Context) error {
+	return errors.WithStack(hp.d.killProcs(hp.state.ID))
+}
+
+func (hp *HostPod) Remove(ctx context.Context) error {
+	if err := hp.d.killProcs(hp.state.ID); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.RemoveAll(hp.podDir()))
+}
+
+// Stats isn't currently supported by the host driver
+func (hp *HostPod) Stats(ctx context.Context) (*PodStats, error) {
+	return nil, nil
+}
+
+// hostPath maps a path inside the init volume dir to the pod init dir
+func (hp *HostPod) hostPath(p string) string {
+	if hp.state.InitVolumeDir == "" {
+		return p
+	}
+	rel, err := filepath.Rel(hp.state.InitVolumeDir, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return p
+	}
+	return filepath.Join(hp.podDir(), hostInitDir, rel)
+}
+
+func (hp *HostPod) Exec(ctx context.Context, execConfig *ExecConfig) (ContainerExec, error) {
+	if len(execConfig.Cmd) == 0 {
+		return nil, errors.Errorf("empty command")
+	}
+	if err := checkHostUser(execConfig.User); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	env := map[string]string{}
+	for _, k := range hostInheritedEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			env[k] = v
+		}
+	}
+	env["HOME"] = filepath.Join(hp.podDir(), hostHomeDir)
+	// use per pod run metadata and metrics files since the host tmp dir is
+	// shared by all the tasks
+	env["AGOLA_RUN_META_FILE"] = filepath.Join(hp.podDir(), hostRunMetaFile)
+	env["AGOLA_METRICS_FILE"] = filepath.Join(hp.podDir(), hostMetricsFile)
+	for k, v := range hp.state.Env {
+		env[k] = v
+	}
+	for k, v := range execConfig.Env {
+		env[k] = v
+	}
+
+	cmd := exec.Command(hp.hostPath(execConfig.Cmd[0]), execConfig.Cmd[1:]...)
+	cmd.Dir = execConfig.WorkingDir
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+	cmd.Stdout = execConfig.Stdout
+	cmd.Stderr = execConfig.Stderr
+	if cmd.Stdout == nil {
+		cmd.Stdout = ioutil.Discard
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = ioutil.Discard
+	}
+	// use a process group to kill also the child processes on stop
+	setProcessGroup(cmd)
+
+	var stdin io.WriteCloser
+	if execConfig.AttachStdin {
+		var err error
+		stdin, err = cmd.StdinPipe()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pid := cmd.Process.Pid
+	hp.d.addProc(hp.state.ID, pid)
+
+	endCh := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		hp.d.removeProc(hp.state.ID, pid)
+		endCh <- err
+	}()
+
+	return &HostContainerExec{
+		stdin: stdin,
+		endCh: endCh,
+	}, nil
+}
+
+type HostContainerExec struct {
+	stdin io.WriteCloser
+	endCh chan error
+}
+
+func (e *HostContainerExec) Wait(ctx context.Context) (int, error) {
+	var err error
+	select {
+	case <-ctx.Done():
+		return 0, errors.WithStack(ctx.Err())
+	case err = <-e.endCh:
+	}
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return -1, errors.WithStack(err)
+	}
+
+	return 0, nil
+}
+
+func (e *HostContainerExec) Stdin() io.WriteCloser {
+	return e.stdin
+}
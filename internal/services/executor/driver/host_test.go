@@ -0,0 +1,181 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"agola.io/agola/internal/testutil"
+	rstypes "agola.io/agola/services/runservice/types"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestHostPod(t *testing.T) {
+	toolboxPath := t.TempDir()
+	// a fake toolbox, it's only copied inside the pod
+	if err := ioutil.WriteFile(filepath.Join(toolboxPath, fmt.Sprintf("%s-%s-%s", toolboxPrefix, runtime.GOOS, runtime.GOARCH)), []byte("#!/bin/sh\necho -n toolbox $@\n"), 0755); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	dataDir := t.TempDir()
+
+	log := testutil.NewLogger(t)
+
+	d, err := NewHostDriver(log, "executorid01", toolboxPath, dataDir)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := d.Setup(ctx); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	newPod := func(t *testing.T) Pod {
+		pod, err := d.NewPod(ctx, &PodConfig{
+			ID:     uuid.Must(uuid.NewV4()).String(),
+			TaskID: uuid.Must(uuid.NewV4()).String(),
+			Containers: []*ContainerConfig{
+				{
+					Env: map[string]string{"ENV01": "ENVVALUE01"},
+				},
+			},
+			InitVolumeDir: "/tmp/agola",
+		}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		return pod
+	}
+
+	t.Run("detected labels", func(t *testing.T) {
+		labels, err := d.Labels(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if labels[rstypes.ExecutorLabelDriver] != "host" {
+			t.Fatalf("expected driver label %q, got %q", "host", labels[rstypes.ExecutorLabelDriver])
+		}
+	})
+
+	t.Run("execute a command", func(t *testing.T) {
+		pod := newPod(t)
+		defer func() { _ = pod.Remove(ctx) }()
+
+		var buf bytes.Buffer
+		ce, err := pod.Exec(ctx, &ExecConfig{
+			Cmd:        []string{"sh", "-c", "echo -n $ENV01 $ENV02 $HOME"},
+			Env:        map[string]string{"ENV02": "ENVVALUE02"},
+			WorkingDir: "/",
+			Stdout:     &buf,
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		code, err := ce.Wait(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d", code)
+		}
+
+		expected := fmt.Sprintf("ENVVALUE01 ENVVALUE02 %s", filepath.Join(dataDir, pod.ID(), hostHomeDir))
+		if buf.String() != expected {
+			t.Fatalf("expected %q, got %q", expected, buf.String())
+		}
+	})
+
+	t.Run("execute the toolbox from the init volume dir", func(t *testing.T) {
+		pod := newPod(t)
+		defer func() { _ = pod.Remove(ctx) }()
+
+		var buf bytes.Buffer
+		ce, err := pod.Exec(ctx, &ExecConfig{
+			Cmd:        []string{"/tmp/agola/agola-toolbox", "mkdir"},
+			WorkingDir: "/",
+			Stdout:     &buf,
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if _, err := ce.Wait(ctx); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		expected := "toolbox mkdir"
+		if buf.String() != expected {
+			t.Fatalf("expected %q, got %q", expected, buf.String())
+		}
+	})
+
+	t.Run("check exit code", func(t *testing.T) {
+		pod := newPod(t)
+		defer func() { _ = pod.Remove(ctx) }()
+
+		ce, err := pod.Exec(ctx, &ExecConfig{
+			Cmd:        []string{"sh", "-c", "exit 2"},
+			WorkingDir: "/",
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		code, err := ce.Wait(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if code != 2 {
+			t.Fatalf("expected exit code 2, got: %d", code)
+		}
+	})
+
+	t.Run("test get pods and remove", func(t *testing.T) {
+		pod := newPod(t)
+
+		pods, err := d.GetPods(ctx, true)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(pods) != 1 || pods[0].ID() != pod.ID() || pods[0].TaskID() != pod.TaskID() {
+			t.Fatalf("expected pod %q, got %v", pod.ID(), pods)
+		}
+
+		if err := pod.Remove(ctx); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dataDir, pod.ID())); !os.IsNotExist(err) {
+			t.Fatalf("expected pod dir removed, got err: %v", err)
+		}
+
+		pods, err = d.GetPods(ctx, true)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(pods) != 0 {
+			t.Fatalf("expected no pods, got %d pods", len(pods))
+		}
+	})
+}
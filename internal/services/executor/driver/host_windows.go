@@ -0,0 +1,36 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing since windows has no process groups
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills only the process with the provided pid since windows
+// has no process groups
+func killProcessGroup(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		// process already exited
+		return nil
+	}
+	// ignore the error since the process could be already exited
+	_ = p.Kill()
+	return nil
+}
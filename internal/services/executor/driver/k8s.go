@@ -29,6 +29,7 @@ import (
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/services/executor/registry"
 	"agola.io/agola/internal/util"
+	rstypes "agola.io/agola/services/runservice/types"
 	"agola.io/agola/services/types"
 
 	"github.com/docker/docker/pkg/archive"
@@ -39,6 +40,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apilabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -68,10 +70,26 @@ const (
 	informerResyncInterval     = 10 * time.Second
 
 	k8sLabelArchBeta = "beta.kubernetes.io/arch"
+
+	// k8sGPUResourceName is the extended resource name exposed by the nvidia
+	// device plugin
+	k8sGPUResourceName corev1.ResourceName = "nvidia.com/gpu"
 )
 
+// K8sConfig defines the customizations of the task pods
+type K8sConfig struct {
+	NodeSelector   map[string]string
+	Tolerations    []corev1.Toleration
+	Affinity       *corev1.Affinity
+	ServiceAccount string
+	// PodTemplatePatch is a json encoded strategic merge patch applied to the
+	// task pods
+	PodTemplatePatch []byte
+}
+
 type K8sDriver struct {
 	log              zerolog.Logger
+	c                *K8sConfig
 	restconfig       *restclient.Config
 	client           *kubernetes.Clientset
 	toolboxPath      string
@@ -86,6 +104,7 @@ type K8sDriver struct {
 	cmLister         listerscorev1.ConfigMapLister
 	leaseLister      coordinationlistersv1.LeaseLister
 	k8sLabelArch     string
+	serverVersion    string
 }
 
 type K8sPod struct {
@@ -98,7 +117,11 @@ type K8sPod struct {
 	initVolumeDir string
 }
 
-func NewK8sDriver(log zerolog.Logger, executorID, toolboxPath, initImage string, initDockerConfig *registry.DockerConfig) (*K8sDriver, error) {
+func NewK8sDriver(log zerolog.Logger, executorID, toolboxPath, initImage string, initDockerConfig *registry.DockerConfig, c *K8sConfig) (*K8sDriver, error) {
+	if c == nil {
+		c = &K8sConfig{}
+	}
+
 	kubeClientConfig := NewKubeClientConfig("", "", "")
 	kubecfg, err := kubeClientConfig.ClientConfig()
 	if err != nil {
@@ -116,6 +139,7 @@ func NewK8sDriver(log zerolog.Logger, executorID, toolboxPath, initImage string,
 
 	d := &K8sDriver{
 		log:              log,
+		c:                c,
 		restconfig:       kubecfg,
 		client:           kubecli,
 		toolboxPath:      toolboxPath,
@@ -130,6 +154,7 @@ func NewK8sDriver(log zerolog.Logger, executorID, toolboxPath, initImage string,
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	d.serverVersion = serverVersion.GitVersion
 	sv, err := parseGitVersion(serverVersion.GitVersion)
 	// if server version parsing fails just warn but ignore it
 	if err != nil {
@@ -272,6 +297,24 @@ func (d *K8sDriver) Archs(ctx context.Context) ([]types.Arch, error) {
 	return archs, nil
 }
 
+func (d *K8sDriver) OS(ctx context.Context) (types.OS, error) {
+	return types.OSLinux, nil
+}
+
+func (d *K8sDriver) Labels(ctx context.Context) (map[string]string, error) {
+	// the kernel version isn't reported since the nodes could have different
+	// kernel versions
+	return map[string]string{
+		rstypes.ExecutorLabelDriver:            "kubernetes",
+		rstypes.ExecutorLabelKubernetesVersion: d.serverVersion,
+	}, nil
+}
+
+func (d *K8sDriver) ImagesSize(ctx context.Context) (int64, error) {
+	// the images are cached by the nodes container runtime
+	return 0, nil
+}
+
 func (d *K8sDriver) ExecutorGroup(ctx context.Context) (string, error) {
 	return d.executorsGroupID, nil
 }
@@ -312,6 +355,61 @@ func (d *K8sDriver) getOrCreateExecutorsGroupID(ctx context.Context) (string, er
 	return executorsGroupID, nil
 }
 
+// customizePod applies to the pod the task and driver node selectors,
+// tolerations, affinity, service account and the pod template patch
+func (d *K8sDriver) customizePod(pod *corev1.Pod, podConfig *PodConfig) (*corev1.Pod, error) {
+	nodeSelector := map[string]string{}
+	for k, v := range podConfig.NodeSelector {
+		nodeSelector[k] = v
+	}
+	if podConfig.Arch != "" {
+		nodeSelector[d.k8sLabelArch] = string(podConfig.Arch)
+	}
+	// the driver node selector overrides the task one
+	for k, v := range d.c.NodeSelector {
+		nodeSelector[k] = v
+	}
+	if len(nodeSelector) > 0 {
+		pod.Spec.NodeSelector = nodeSelector
+	}
+
+	for _, t := range podConfig.Tolerations {
+		pod.Spec.Tolerations = append(pod.Spec.Tolerations, corev1.Toleration{
+			Key:      t.Key,
+			Operator: corev1.TolerationOperator(t.Operator),
+			Value:    t.Value,
+			Effect:   corev1.TaintEffect(t.Effect),
+		})
+	}
+	pod.Spec.Tolerations = append(pod.Spec.Tolerations, d.c.Tolerations...)
+
+	if d.c.Affinity != nil {
+		pod.Spec.Affinity = d.c.Affinity.DeepCopy()
+	}
+	if d.c.ServiceAccount != "" {
+		pod.Spec.ServiceAccountName = d.c.ServiceAccount
+	}
+
+	if len(d.c.PodTemplatePatch) == 0 {
+		return pod, nil
+	}
+
+	podj, err := json.Marshal(pod)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	patchedPodj, err := strategicpatch.StrategicMergePatch(podj, d.c.PodTemplatePatch, corev1.Pod{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to apply pod template patch")
+	}
+	var patchedPod *corev1.Pod
+	if err := json.Unmarshal(patchedPodj, &patchedPod); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return patchedPod, nil
+}
+
 func (d *K8sDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.Writer) (Pod, error) {
 	if len(podConfig.Containers) == 0 {
 		return nil, errors.Errorf("empty container config")
@@ -330,6 +428,15 @@ func (d *K8sDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.Wri
 	// pod and secret name, based on pod id
 	name := podNamePrefix + podConfig.ID
 
+	// the kubelet doesn't support registry bearer tokens
+	if podConfig.DockerConfig != nil {
+		for regName, auth := range podConfig.DockerConfig.Auths {
+			if auth.RegistryToken != "" {
+				return nil, errors.Errorf("docker registry %q: token auth is not supported by the kubernetes driver", regName)
+			}
+		}
+	}
+
 	dockerconfigj, err := json.Marshal(podConfig.DockerConfig)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -395,8 +502,23 @@ func (d *K8sDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.Wri
 		},
 	}
 
+	// pod containers share the same network namespace, resolve the container
+	// names to localhost
+	hostnames := []string{}
+	for _, containerConfig := range podConfig.Containers {
+		if containerConfig.Name != "" {
+			hostnames = append(hostnames, containerConfig.Name)
+		}
+	}
+	if len(hostnames) > 0 {
+		pod.Spec.HostAliases = []corev1.HostAlias{{IP: "127.0.0.1", Hostnames: hostnames}}
+	}
+
 	// define containers
 	for cIndex, containerConfig := range podConfig.Containers {
+		if len(containerConfig.Devices) > 0 {
+			return nil, errors.Errorf("k8s driver doesn't support host devices")
+		}
 		var containerName string
 		if cIndex == 0 {
 			containerName = mainContainerName
@@ -404,19 +526,31 @@ func (d *K8sDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.Wri
 			containerName = fmt.Sprintf("service%d", cIndex)
 		}
 		c := corev1.Container{
-			Name:       containerName,
-			Image:      containerConfig.Image,
-			Command:    containerConfig.Cmd,
-			Env:        genEnvVars(containerConfig.Env),
-			Stdin:      true,
-			WorkingDir: containerConfig.WorkingDir,
-			// by default always try to pull the image so we are sure only authorized users can fetch them
-			// see https://kubernetes.io/docs/reference/access-authn-authz/admission-controllers/#alwayspullimages
-			ImagePullPolicy: corev1.PullAlways,
+			Name:            containerName,
+			Image:           containerConfig.Image,
+			Command:         containerConfig.Cmd,
+			Args:            containerConfig.Args,
+			Env:             genEnvVars(containerConfig.Env),
+			Stdin:           true,
+			WorkingDir:      containerConfig.WorkingDir,
+			ImagePullPolicy: genPullPolicy(containerConfig.PullPolicy),
 			SecurityContext: &corev1.SecurityContext{
-				Privileged: &containerConfig.Privileged,
+				Privileged:   &containerConfig.Privileged,
+				Capabilities: genCapabilities(containerConfig.CapAdd, containerConfig.CapDrop),
+			},
+			Resources: corev1.ResourceRequirements{
+				Requests: genResourceList(containerConfig.Resources.Requests),
+				Limits:   genResourceList(containerConfig.Resources.Limits),
 			},
 		}
+		if containerConfig.GPUs > 0 {
+			// extended resources are defined only as limits, requests default
+			// to the limits
+			if c.Resources.Limits == nil {
+				c.Resources.Limits = corev1.ResourceList{}
+			}
+			c.Resources.Limits[k8sGPUResourceName] = *resource.NewQuantity(int64(containerConfig.GPUs), resource.DecimalSI)
+		}
 		if cIndex == 0 {
 			// main container requires the initvolume containing the toolbox
 			c.VolumeMounts = []corev1.VolumeMount{
@@ -461,10 +595,9 @@ func (d *K8sDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.Wri
 		pod.Spec.Containers = append(pod.Spec.Containers, c)
 	}
 
-	if podConfig.Arch != "" {
-		pod.Spec.NodeSelector = map[string]string{
-			d.k8sLabelArch: string(podConfig.Arch),
-		}
+	pod, err = d.customizePod(pod, podConfig)
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
 
 	pod, err = podClient.Create(pod)
@@ -564,7 +697,7 @@ func (d *K8sDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.Wri
 	}
 
 	// copy the toolbox for the pod arch
-	toolboxExecPath, err := toolboxExecPath(d.toolboxPath, arch)
+	toolboxExecPath, err := toolboxExecPath(d.toolboxPath, types.OSLinux, arch)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get toolbox path for arch %q", arch)
 	}
@@ -729,6 +862,11 @@ func (p *K8sPod) Remove(ctx context.Context) error {
 	return p.Stop(ctx)
 }
 
+// Stats isn't currently supported by the k8s driver
+func (p *K8sPod) Stats(ctx context.Context) (*PodStats, error) {
+	return nil, nil
+}
+
 type K8sContainerExec struct {
 	endCh chan error
 
@@ -848,3 +986,46 @@ func parseGitVersion(gitVersion string) (*serverVersion, error) {
 	}
 	return sv, nil
 }
+
+func genPullPolicy(p rstypes.PullPolicy) corev1.PullPolicy {
+	switch p {
+	case rstypes.PullPolicyIfNotPresent:
+		return corev1.PullIfNotPresent
+	case rstypes.PullPolicyNever:
+		return corev1.PullNever
+	default:
+		// by default always try to pull the image so we are sure only authorized users can fetch them
+		// see https://kubernetes.io/docs/reference/access-authn-authz/admission-controllers/#alwayspullimages
+		return corev1.PullAlways
+	}
+}
+
+func genResourceList(rl ResourceList) corev1.ResourceList {
+	res := corev1.ResourceList{}
+	if rl.CPU != 0 {
+		res[corev1.ResourceCPU] = *resource.NewMilliQuantity(rl.CPU, resource.DecimalSI)
+	}
+	if rl.Memory != 0 {
+		res[corev1.ResourceMemory] = *resource.NewQuantity(rl.Memory, resource.BinarySI)
+	}
+	if len(res) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+func genCapabilities(capAdd, capDrop []string) *corev1.Capabilities {
+	if len(capAdd) == 0 && len(capDrop) == 0 {
+		return nil
+	}
+	caps := &corev1.Capabilities{}
+	for _, c := range capAdd {
+		caps.Add = append(caps.Add, corev1.Capability(c))
+	}
+	for _, c := range capDrop {
+		caps.Drop = append(caps.Drop, corev1.Capability(c))
+	}
+
+	return caps
+}
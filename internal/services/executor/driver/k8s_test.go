@@ -24,8 +24,12 @@ import (
 	"time"
 
 	"agola.io/agola/internal/testutil"
+	rstypes "agola.io/agola/services/runservice/types"
+	"agola.io/agola/services/types"
 
 	"github.com/gofrs/uuid"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
 )
 
 func TestK8sPod(t *testing.T) {
@@ -41,7 +45,7 @@ func TestK8sPod(t *testing.T) {
 
 	initImage := "busybox:stable"
 
-	d, err := NewK8sDriver(log, "executorid01", toolboxPath, initImage, nil)
+	d, err := NewK8sDriver(log, "executorid01", toolboxPath, initImage, nil, nil)
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -224,6 +228,82 @@ func TestK8sPod(t *testing.T) {
 		}
 	})
 
+	t.Run("create a pod with an image with a custom entrypoint", func(t *testing.T) {
+		// the alpine/git image entrypoint is git, the container cmd must
+		// override it
+		pod, err := d.NewPod(ctx, &PodConfig{
+			ID:     uuid.Must(uuid.NewV4()).String(),
+			TaskID: uuid.Must(uuid.NewV4()).String(),
+			Containers: []*ContainerConfig{
+				&ContainerConfig{
+					Cmd:   []string{"cat"},
+					Image: "alpine/git",
+				},
+			},
+			InitVolumeDir: "/tmp/agola",
+		}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		defer func() { _ = pod.Remove(ctx) }()
+
+		ce, err := pod.Exec(ctx, &ExecConfig{
+			Cmd: []string{"git", "--version"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		code, err := ce.Wait(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d", code)
+		}
+	})
+
+	t.Run("test service container with cmd and args", func(t *testing.T) {
+		pod, err := d.NewPod(ctx, &PodConfig{
+			ID:     uuid.Must(uuid.NewV4()).String(),
+			TaskID: uuid.Must(uuid.NewV4()).String(),
+			Containers: []*ContainerConfig{
+				&ContainerConfig{
+					Cmd:   []string{"cat"},
+					Image: "busybox",
+				},
+				&ContainerConfig{
+					Cmd:   []string{"httpd"},
+					Args:  []string{"-f", "-p", "8080"},
+					Image: "busybox",
+				},
+			},
+			InitVolumeDir: "/tmp/agola",
+		}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		defer func() { _ = pod.Remove(ctx) }()
+
+		// wait for httpd up
+		time.Sleep(1 * time.Second)
+
+		ce, err := pod.Exec(ctx, &ExecConfig{
+			Cmd: []string{"nc", "-z", "localhost", "8080"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		code, err := ce.Wait(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d", code)
+		}
+	})
+
 	t.Run("test get pods", func(t *testing.T) {
 		pod, err := d.NewPod(ctx, &PodConfig{
 			ID:     uuid.Must(uuid.NewV4()).String(),
@@ -399,3 +479,82 @@ func TestParseGitVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestK8sCustomizePod(t *testing.T) {
+	newPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: mainContainerName, Image: "busybox"}},
+			},
+		}
+	}
+
+	t.Run("task and driver customizations", func(t *testing.T) {
+		d := &K8sDriver{
+			c: &K8sConfig{
+				NodeSelector: map[string]string{"pool": "agola"},
+				Tolerations: []corev1.Toleration{
+					{Key: "agola", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+				},
+				ServiceAccount: "agola-task",
+			},
+			k8sLabelArch: corev1.LabelArchStable,
+		}
+
+		pod, err := d.customizePod(newPod(), &PodConfig{
+			Arch:         types.ArchAMD64,
+			NodeSelector: map[string]string{"pool": "builds", "disk": "ssd"},
+			Tolerations: []rstypes.Toleration{
+				{Key: "pool", Value: "builds", Effect: "NoSchedule"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		expectedNodeSelector := map[string]string{
+			"pool":                 "agola",
+			"disk":                 "ssd",
+			corev1.LabelArchStable: "amd64",
+		}
+		if diff := cmp.Diff(expectedNodeSelector, pod.Spec.NodeSelector); diff != "" {
+			t.Fatalf("node selector mismatch (-want +got):\n%s", diff)
+		}
+		expectedTolerations := []corev1.Toleration{
+			{Key: "pool", Value: "builds", Effect: corev1.TaintEffectNoSchedule},
+			{Key: "agola", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+		}
+		if diff := cmp.Diff(expectedTolerations, pod.Spec.Tolerations); diff != "" {
+			t.Fatalf("tolerations mismatch (-want +got):\n%s", diff)
+		}
+		if pod.Spec.ServiceAccountName != "agola-task" {
+			t.Fatalf("expected service account %q, got %q", "agola-task", pod.Spec.ServiceAccountName)
+		}
+	})
+
+	t.Run("pod template patch", func(t *testing.T) {
+		d := &K8sDriver{
+			c: &K8sConfig{
+				PodTemplatePatch: []byte(`{"spec":{"priorityClassName":"agola","containers":[{"name":"maincontainer","imagePullPolicy":"IfNotPresent"}]}}`),
+			},
+			k8sLabelArch: corev1.LabelArchStable,
+		}
+
+		pod, err := d.customizePod(newPod(), &PodConfig{})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if pod.Spec.PriorityClassName != "agola" {
+			t.Fatalf("expected priority class name %q, got %q", "agola", pod.Spec.PriorityClassName)
+		}
+		// containers are merged by name
+		if len(pod.Spec.Containers) != 1 {
+			t.Fatalf("expected 1 container, got %d", len(pod.Spec.Containers))
+		}
+		c := pod.Spec.Containers[0]
+		if c.Image != "busybox" || c.ImagePullPolicy != corev1.PullIfNotPresent {
+			t.Fatalf("unexpected patched container: %+v", c)
+		}
+	})
+}
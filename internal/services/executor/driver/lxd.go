@@ -0,0 +1,414 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+	rstypes "agola.io/agola/services/runservice/types"
+	"agola.io/agola/services/types"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	lxdContainerPrefix = "agola-"
+
+	// lxd instances user config keys used as labels
+	lxdConfigPrefix     = "user.agola."
+	lxdAgolaKey         = lxdConfigPrefix + "agola"
+	lxdExecutorIDKey    = lxdConfigPrefix + "executorid"
+	lxdPodIDKey         = lxdConfigPrefix + "podid"
+	lxdTaskIDKey        = lxdConfigPrefix + "taskid"
+	lxdInitVolumeDirKey = lxdConfigPrefix + "initvolumedir"
+)
+
+// LXDDriver runs the pods as LXD system containers using the lxc command
+// line client. Since LXD containers don't share their network namespace only
+// pods with a single container are supported.
+type LXDDriver struct {
+	log         zerolog.Logger
+	lxcPath     string
+	toolboxPath string
+	executorID  string
+	arch        types.Arch
+}
+
+func NewLXDDriver(log zerolog.Logger, executorID, toolboxPath string) (*LXDDriver, error) {
+	lxcPath, err := exec.LookPath("lxc")
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot find lxc executable")
+	}
+
+	return &LXDDriver{
+		log:         log,
+		lxcPath:     lxcPath,
+		toolboxPath: toolboxPath,
+		executorID:  executorID,
+		arch:        types.ArchFromString(runtime.GOARCH),
+	}, nil
+}
+
+// lxc executes the lxc client with the provided args and returns its output
+func lxc(ctx context.Context, lxcPath string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, lxcPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "lxc %s failed: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func (d *LXDDriver) Setup(ctx context.Context) error {
+	// check that the lxd daemon is reachable
+	if _, err := lxc(ctx, d.lxcPath, "info"); err != nil {
+		return errors.Wrapf(err, "failed to connect to lxd")
+	}
+	return nil
+}
+
+func (d *LXDDriver) Archs(ctx context.Context) ([]types.Arch, error) {
+	// since we are using the local lxd daemon we can return our go arch information
+	return []types.Arch{d.arch}, nil
+}
+
+func (d *LXDDriver) OS(ctx context.Context) (types.OS, error) {
+	return types.OSLinux, nil
+}
+
+func (d *LXDDriver) Labels(ctx context.Context) (map[string]string, error) {
+	labels := map[string]string{
+		rstypes.ExecutorLabelDriver: "lxd",
+	}
+	// containers share the local host kernel
+	if kernelVersion := hostKernelVersion(); kernelVersion != "" {
+		labels[rstypes.ExecutorLabelKernelVersion] = kernelVersion
+	}
+	return labels, nil
+}
+
+func (d *LXDDriver) ImagesSize(ctx context.Context) (int64, error) {
+	// the images are stored in the lxd storage pools that aren't inspected
+	return 0, nil
+}
+
+func (d *LXDDriver) ExecutorGroup(ctx context.Context) (string, error) {
+	// use the same group as the executor id
+	return d.executorID, nil
+}
+
+func (d *LXDDriver) GetExecutors(ctx context.Context) ([]string, error) {
+	return []string{d.executorID}, nil
+}
+
+func (d *LXDDriver) NewPod(ctx context.Context, podConfig *PodConfig, out io.Writer) (Pod, error) {
+	if len(podConfig.Containers) == 0 {
+		return nil, errors.Errorf("empty container config")
+	}
+	if len(podConfig.Containers) > 1 {
+		return nil, errors.Errorf("lxd driver doesn't support pods with multiple containers")
+	}
+	if podConfig.Arch != "" && podConfig.Arch != d.arch {
+		return nil, errors.Errorf("unsupported arch %q", podConfig.Arch)
+	}
+
+	containerConfig := podConfig.Containers[0]
+	if len(containerConfig.CapAdd) > 0 || len(containerConfig.CapDrop) > 0 || len(containerConfig.Devices) > 0 {
+		return nil, errors.Errorf("lxd driver doesn't support container capabilities and devices")
+	}
+	if containerConfig.GPUs > 0 {
+		return nil, errors.Errorf("lxd driver doesn't support gpus")
+	}
+	name := lxdContainerPrefix + podConfig.ID
+
+	args := []string{"launch", containerConfig.Image, name,
+		"-c", lxdAgolaKey + "=" + agolaLabelValue,
+		"-c", lxdExecutorIDKey + "=" + d.executorID,
+		"-c", lxdPodIDKey + "=" + podConfig.ID,
+		"-c", lxdTaskIDKey + "=" + podConfig.TaskID,
+		"-c", lxdInitVolumeDirKey + "=" + podConfig.InitVolumeDir,
+	}
+	for k, v := range containerConfig.Env {
+		args = append(args, "-c", fmt.Sprintf("environment.%s=%s", k, v))
+	}
+	if containerConfig.Privileged {
+		args = append(args, "-c", "security.privileged=true", "-c", "security.nesting=true")
+	}
+	if cpu := containerConfig.Resources.Limits.CPU; cpu != 0 {
+		// lxd limits.cpu is the number of cpus
+		args = append(args, "-c", fmt.Sprintf("limits.cpu=%d", (cpu+999)/1000))
+	}
+	if memory := containerConfig.Resources.Limits.Memory; memory != 0 {
+		args = append(args, "-c", fmt.Sprintf("limits.memory=%d", memory))
+	}
+
+	fmt.Fprintf(out, "Launching lxd container %q with image %q.\n", name, containerConfig.Image)
+	if _, err := lxc(ctx, d.lxcPath, args...); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pod := &LXDPod{
+		id:            podConfig.ID,
+		name:          name,
+		lxcPath:       d.lxcPath,
+		executorID:    d.executorID,
+		taskID:        podConfig.TaskID,
+		initVolumeDir: podConfig.InitVolumeDir,
+	}
+
+	toolboxExecPath, err := toolboxExecPath(d.toolboxPath, types.OSLinux, d.arch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get toolbox path for arch %q", d.arch)
+	}
+	if _, err := lxc(ctx, d.lxcPath, "file", "push", "-p", "--mode", "0755", toolboxExecPath, name+filepath.Join(podConfig.InitVolumeDir, "agola-toolbox")); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for _, vol := range containerConfig.Volumes {
+		if vol.TmpFS == nil {
+			return nil, errors.Errorf("missing volume config")
+		}
+		opts := "mode=1777"
+		if vol.TmpFS.Size != 0 {
+			opts += fmt.Sprintf(",size=%d", vol.TmpFS.Size)
+		}
+		if _, err := lxc(ctx, d.lxcPath, "exec", name, "--", "mkdir", "-p", vol.Path); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if _, err := lxc(ctx, d.lxcPath, "exec", name, "--", "mount", "-t", "tmpfs", "-o", opts, "tmpfs", vol.Path); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return pod, nil
+}
+
+type lxdInstance struct {
+	Name   string            `json:"name"`
+	Config map[string]string `json:"config"`
+}
+
+func (d *LXDDriver) GetPods(ctx context.Context, all bool) ([]Pod, error) {
+	outb, err := lxc(ctx, d.lxcPath, "list", "--format", "json")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var instances []*lxdInstance
+	if err := json.Unmarshal(outb, &instances); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pods := []Pod{}
+	for _, instance := range instances {
+		if instance.Config[lxdAgolaKey] != agolaLabelValue {
+			continue
+		}
+		executorID := instance.Config[lxdExecutorIDKey]
+		if !all && executorID != d.executorID {
+			continue
+		}
+		podID, ok := instance.Config[lxdPodIDKey]
+		if !ok {
+			continue
+		}
+
+		pods = append(pods, &LXDPod{
+			id:            podID,
+			name:          instance.Name,
+			lxcPath:       d.lxcPath,
+			executorID:    executorID,
+			taskID:        instance.Config[lxdTaskIDKey],
+			initVolumeDir: instance.Config[lxdInitVolumeDirKey],
+		})
+	}
+
+	return pods, nil
+}
+
+type LXDPod struct {
+	id            string
+	name          string
+	lxcPath       string
+	executorID    string
+	taskID        string
+	initVolumeDir string
+}
+
+func (lp *LXDPod) ID() string {
+	return lp.id
+}
+
+func (lp *LXDPod) ExecutorID() string {
+	return lp.executorID
+}
+
+func (lp *LXDPod) TaskID() string {
+	return lp.taskID
+}
+
+func (lp *LXDPod) Stop(ctx context.Context) error {
+	_, err := lxc(ctx, lp.lxcPath, "stop", lp.name, "--force")
+	return errors.WithStack(err)
+}
+
+func (lp *LXDPod) Remove(ctx context.Context) error {
+	_, err := lxc(ctx, lp.lxcPath, "delete", lp.name, "--force")
+	return errors.WithStack(err)
+}
+
+// Stats isn't currently supported by the lxd driver
+func (lp *LXDPod) Stats(ctx context.Context) (*PodStats, error) {
+	return nil, nil
+}
+
+// userIDs returns the uid and gid of the provided user. lxc exec only accepts
+// numeric ids so user names are resolved inside the container.
+func (lp *LXDPod) userIDs(ctx context.Context, user string) (string, string, error) {
+	parts := strings.SplitN(user, ":", 2)
+	uid := parts[0]
+	var gid string
+	if len(parts) > 1 {
+		gid = parts[1]
+	}
+
+	if _, err := strconv.Atoi(uid); err != nil {
+		outb, err := lxc(ctx, lp.lxcPath, "exec", lp.name, "--", "id", "-u", uid)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "failed to get user %q uid", uid)
+		}
+		uid = strings.TrimSpace(string(outb))
+	}
+	if gid == "" {
+		outb, err := lxc(ctx, lp.lxcPath, "exec", lp.name, "--", "id", "-g", uid)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "failed to get user %q gid", uid)
+		}
+		gid = strings.TrimSpace(string(outb))
+	} else if _, err := strconv.Atoi(gid); err != nil {
+		outb, err := lxc(ctx, lp.lxcPath, "exec", lp.name, "--", "getent", "group", gid)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "failed to get group %q gid", gid)
+		}
+		// group entry format is name:password:gid:members
+		fields := strings.Split(strings.TrimSpace(string(outb)), ":")
+		if len(fields) < 3 {
+			return "", "", errors.Errorf("wrong group %q entry", gid)
+		}
+		gid = fields[2]
+	}
+
+	return uid, gid, nil
+}
+
+func (lp *LXDPod) Exec(ctx context.Context, execConfig *ExecConfig) (ContainerExec, error) {
+	args := []string{"exec", lp.name}
+	if execConfig.Tty {
+		args = append(args, "--force-interactive")
+	} else {
+		args = append(args, "--force-noninteractive")
+	}
+	if execConfig.User != "" {
+		uid, gid, err := lp.userIDs(ctx, execConfig.User)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		args = append(args, "--user", uid, "--group", gid)
+	}
+
+	// use the toolbox exec command to set the env and the working dir like
+	// done by the docker driver
+	envj, err := json.Marshal(execConfig.Env)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	args = append(args, "--", filepath.Join(lp.initVolumeDir, "agola-toolbox"), "exec", "-e", string(envj), "-w", execConfig.WorkingDir, "--")
+	args = append(args, execConfig.Cmd...)
+
+	cmd := exec.Command(lp.lxcPath, args...)
+	cmd.Stdout = execConfig.Stdout
+	cmd.Stderr = execConfig.Stderr
+	if cmd.Stdout == nil {
+		cmd.Stdout = ioutil.Discard
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = ioutil.Discard
+	}
+
+	var stdin io.WriteCloser
+	if execConfig.AttachStdin {
+		stdin, err = cmd.StdinPipe()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	endCh := make(chan error, 1)
+	go func() {
+		endCh <- cmd.Wait()
+	}()
+
+	return &LXDContainerExec{
+		stdin: stdin,
+		endCh: endCh,
+	}, nil
+}
+
+type LXDContainerExec struct {
+	stdin io.WriteCloser
+	endCh chan error
+}
+
+func (e *LXDContainerExec) Wait(ctx context.Context) (int, error) {
+	var err error
+	select {
+	case <-ctx.Done():
+		return 0, errors.WithStack(ctx.Err())
+	case err = <-e.endCh:
+	}
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return -1, errors.WithStack(err)
+	}
+
+	return 0, nil
+}
+
+func (e *LXDContainerExec) Stdin() io.WriteCloser {
+	return e.stdin
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+
+	"agola.io/agola/internal/testutil"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestLXDPod(t *testing.T) {
+	if os.Getenv("SKIP_LXD_TESTS") == "1" {
+		t.Skip("skipping since env var SKIP_LXD_TESTS is 1")
+	}
+	if _, err := exec.LookPath("lxc"); err != nil {
+		t.Skip("skipping since lxc executable isn't available")
+	}
+	toolboxPath := os.Getenv("AGOLA_TOOLBOX_PATH")
+	if toolboxPath == "" {
+		t.Fatalf("env var AGOLA_TOOLBOX_PATH is undefined")
+	}
+
+	log := testutil.NewLogger(t)
+
+	d, err := NewLXDDriver(log, "executorid01", toolboxPath)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	ctx := context.Background()
+
+	if err := d.Setup(ctx); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	image := "images:alpine/edge"
+
+	t.Run("execute a command inside a pod", func(t *testing.T) {
+		pod, err := d.NewPod(ctx, &PodConfig{
+			ID:     uuid.Must(uuid.NewV4()).String(),
+			TaskID: uuid.Must(uuid.NewV4()).String(),
+			Containers: []*ContainerConfig{
+				&ContainerConfig{
+					Image: image,
+					Env:   map[string]string{"ENV01": "ENVVALUE01"},
+				},
+			},
+			InitVolumeDir: "/tmp/agola",
+		}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		defer func() { _ = pod.Remove(ctx) }()
+
+		var buf bytes.Buffer
+		ce, err := pod.Exec(ctx, &ExecConfig{
+			Cmd:         []string{"sh", "-c", "echo -n $ENV01"},
+			Env:         map[string]string{"ENV01": "ENVVALUE01"},
+			WorkingDir:  "/",
+			AttachStdin: true,
+			Stdout:      &buf,
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		_ = ce.Stdin().Close()
+
+		code, err := ce.Wait(ctx)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if code != 0 {
+			t.Fatalf("unexpected exit code: %d", code)
+		}
+		if buf.String() != "ENVVALUE01" {
+			t.Fatalf("got output %q, want %q", buf.String(), "ENVVALUE01")
+		}
+	})
+
+	t.Run("test get pods", func(t *testing.T) {
+		podID := uuid.Must(uuid.NewV4()).String()
+		pod, err := d.NewPod(ctx, &PodConfig{
+			ID:     podID,
+			TaskID: uuid.Must(uuid.NewV4()).String(),
+			Containers: []*ContainerConfig{
+				&ContainerConfig{
+					Image: image,
+				},
+			},
+			InitVolumeDir: "/tmp/agola",
+		}, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		defer func() { _ = pod.Remove(ctx) }()
+
+		pods, err := d.GetPods(ctx, false)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		found := false
+		for _, p := range pods {
+			if p.ID() == podID {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("pod %q not found", podID)
+		}
+	})
+
+	t.Run("pods with multiple containers aren't supported", func(t *testing.T) {
+		_, err := d.NewPod(ctx, &PodConfig{
+			ID:     uuid.Must(uuid.NewV4()).String(),
+			TaskID: uuid.Must(uuid.NewV4()).String(),
+			Containers: []*ContainerConfig{
+				&ContainerConfig{Image: image},
+				&ContainerConfig{Image: image},
+			},
+			InitVolumeDir: "/tmp/agola",
+		}, ioutil.Discard)
+		if err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+}
@@ -25,38 +25,71 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	acmd "agola.io/agola/cmd"
 	"agola.io/agola/internal/common"
 	"agola.io/agola/internal/errors"
 
+	scommon "agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/config"
 	"agola.io/agola/internal/services/executor/driver"
 	"agola.io/agola/internal/services/executor/registry"
+	rscommon "agola.io/agola/internal/services/runservice/common"
+	"agola.io/agola/internal/toolbox/protocol"
 	"agola.io/agola/internal/util"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
 	rsclient "agola.io/agola/services/runservice/client"
 	"agola.io/agola/services/runservice/types"
+	stypes "agola.io/agola/services/types"
 
+	"github.com/ghodss/yaml"
 	"github.com/gofrs/uuid"
 	"github.com/gorilla/mux"
 	sockaddr "github.com/hashicorp/go-sockaddr"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
 )
 
 const (
-	defaultShell = "/bin/sh -e"
+	defaultShell        = "/bin/sh -e"
+	defaultWindowsShell = "powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -File"
 
-	toolboxContainerDir = "/mnt/agola"
-)
+	toolboxContainerDir        = "/mnt/agola"
+	windowsToolboxContainerDir = `C:\agola`
+
+	// defaultServiceReadinessTimeout is the max time to wait for a service
+	// container to be ready when not defined by the task
+	defaultServiceReadinessTimeout = 60 * time.Second
+	serviceReadinessCheckInterval  = 1 * time.Second
 
-var (
-	toolboxContainerPath = filepath.Join(toolboxContainerDir, "/agola-toolbox")
+	resourceUsageSampleInterval = 10 * time.Second
+
+	// defaultTaskRetryInterval is the time to wait before retrying a failed
+	// task when not defined by the task
+	defaultTaskRetryInterval = 10 * time.Second
 )
 
+// toolboxContainerDir returns the dir where the volume containing the toolbox
+// is mounted inside the containers
+func (e *Executor) toolboxContainerDir() string {
+	if e.os == stypes.OSWindows {
+		return windowsToolboxContainerDir
+	}
+	return toolboxContainerDir
+}
+
+func (e *Executor) toolboxContainerPath() string {
+	return driver.ToolboxContainerPath(e.os, e.toolboxContainerDir())
+}
+
 func (e *Executor) getAllPods(ctx context.Context, all bool) ([]driver.Pod, error) {
 	pods, err := e.driver.GetPods(ctx, all)
 	return pods, errors.WithStack(err)
@@ -72,8 +105,11 @@ func stepUser(t *types.ExecutorTask) string {
 	return user
 }
 
-func (e *Executor) createFile(ctx context.Context, pod driver.Pod, command, user string, outf io.Writer) (string, error) {
-	cmd := []string{toolboxContainerPath, "createfile"}
+func (e *Executor) createFile(ctx context.Context, pod driver.Pod, command, user, suffix string, outf io.Writer) (string, error) {
+	cmd := []string{e.toolboxContainerPath(), "createfile"}
+	if suffix != "" {
+		cmd = append(cmd, "--suffix", suffix)
+	}
 
 	var buf bytes.Buffer
 	execConfig := &driver.ExecConfig{
@@ -106,29 +142,62 @@ func (e *Executor) createFile(ctx context.Context, pod driver.Pod, command, user
 	return buf.String(), nil
 }
 
+// shellScriptSuffix returns the file suffix required by some shells to
+// execute a script file
+// openTaskLogFile opens a task log file for appending. The logs of a retried
+// task contain the output of all the attempts, each one preceded by an attempt
+// header
+func openTaskLogFile(t *types.ExecutorTask, logPath string) (*os.File, error) {
+	f, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if t.Status.Attempt > 1 {
+		if _, err := fmt.Fprintf(f, "\n--- task attempt %d of %d ---\n", t.Status.Attempt, t.Spec.Retries+1); err != nil {
+			f.Close()
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return f, nil
+}
+
+func shellScriptSuffix(shell string) string {
+	name := strings.ToLower(strings.Split(shell, " ")[0])
+	// the shell could be provided with an unix or windows path
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, ".exe")
+
+	switch name {
+	case "powershell", "pwsh":
+		return ".ps1"
+	case "cmd":
+		return ".cmd"
+	}
+
+	return ""
+}
+
 func (e *Executor) doRunStep(ctx context.Context, s *types.RunStep, t *types.ExecutorTask, pod driver.Pod, logPath string) (int, error) {
 	if err := os.MkdirAll(filepath.Dir(logPath), 0770); err != nil {
 		return -1, errors.WithStack(err)
 	}
-	outf, err := os.Create(logPath)
+	outf, err := openTaskLogFile(t, logPath)
 	if err != nil {
 		return -1, errors.WithStack(err)
 	}
 	defer outf.Close()
 
-	// TODO(sgotti) this line is used only for old runconfig versions that don't
-	// set a task default shell in the runconfig
-	shell := defaultShell
-	if t.Spec.Shell != "" {
-		shell = t.Spec.Shell
-	}
+	shell := e.taskShell(t)
 	if s.Shell != "" {
 		shell = s.Shell
 	}
 
 	var cmd []string
 	if s.Command != "" {
-		filename, err := e.createFile(ctx, pod, s.Command, stepUser(t), outf)
+		filename, err := e.createFile(ctx, pod, s.Command, stepUser(t), shellScriptSuffix(shell), outf)
 		if err != nil {
 			return -1, errors.Wrapf(err, "create file err")
 		}
@@ -139,12 +208,21 @@ func (e *Executor) doRunStep(ctx context.Context, s *types.RunStep, t *types.Exe
 		cmd = strings.Split(shell, " ")
 	}
 
-	// override task working dir with runstep working dir if provided
-	workingDir := t.Spec.WorkingDir
-	if s.WorkingDir != "" {
-		workingDir = s.WorkingDir
+	// let the toolbox retry the command when it fails
+	if s.Retries > 0 {
+		retryCmd := []string{e.toolboxContainerPath(), "retry", "--retries", strconv.Itoa(s.Retries)}
+		if s.RetryInterval > 0 {
+			retryCmd = append(retryCmd, "--interval", s.RetryInterval.String())
+		}
+		if s.RetryBackoff > 1 {
+			retryCmd = append(retryCmd, "--backoff", strconv.FormatFloat(s.RetryBackoff, 'f', -1, 64))
+		}
+		cmd = append(append(retryCmd, "--"), cmd...)
 	}
 
+	// override task working dir with runstep working dir if provided
+	workingDir := e.stepWorkingDir(t, s)
+
 	// generate the environment using the task environment and then overriding with the runstep environment
 	environment := map[string]string{}
 	for envName, envValue := range t.Spec.Environment {
@@ -160,14 +238,20 @@ func (e *Executor) doRunStep(ctx context.Context, s *types.RunStep, t *types.Exe
 		return -1, errors.WithStack(err)
 	}
 
+	// truncate the step log if it exceeds the max log size
+	var logw io.Writer = outf
+	if t.Spec.MaxStepLogSize > 0 {
+		logw = util.NewTruncatingWriter(outf, t.Spec.MaxStepLogSize, fmt.Sprintf("\nlog truncated since it exceeded the max log size of %d bytes\n", t.Spec.MaxStepLogSize))
+	}
+
 	execConfig := &driver.ExecConfig{
 		Cmd:         cmd,
 		Env:         environment,
 		WorkingDir:  workingDir,
 		User:        stepUser(t),
 		AttachStdin: true,
-		Stdout:      outf,
-		Stderr:      outf,
+		Stdout:      logw,
+		Stderr:      logw,
 		Tty:         *s.Tty,
 	}
 
@@ -184,13 +268,120 @@ func (e *Executor) doRunStep(ctx context.Context, s *types.RunStep, t *types.Exe
 	return exitCode, nil
 }
 
+// stepWorkingDir returns the run step working dir. A relative step working dir
+// is relative to the task working dir
+func (e *Executor) stepWorkingDir(t *types.ExecutorTask, s *types.RunStep) string {
+	dir := s.WorkingDir
+	if dir == "" {
+		return t.Spec.WorkingDir
+	}
+	// absolute, home relative or starting with an environment variable
+	if path.IsAbs(dir) || strings.HasPrefix(dir, "~") || strings.HasPrefix(dir, "$") {
+		return dir
+	}
+	if e.os == stypes.OSWindows {
+		if strings.HasPrefix(dir, `\`) || (len(dir) > 1 && dir[1] == ':') {
+			return dir
+		}
+		return strings.TrimRight(t.Spec.WorkingDir, `\`) + `\` + dir
+	}
+
+	return path.Join(t.Spec.WorkingDir, dir)
+}
+
+func (e *Executor) taskShell(t *types.ExecutorTask) string {
+	// TODO(sgotti) this line is used only for old runconfig versions that don't
+	// set a task default shell in the runconfig
+	shell := defaultShell
+	if e.os == stypes.OSWindows {
+		shell = defaultWindowsShell
+	}
+	if t.Spec.Shell != "" {
+		shell = t.Spec.Shell
+	}
+	return shell
+}
+
+// waitServicesReady waits for all the task service containers with a readiness
+// check to be ready. The checks are executed in the main container since it
+// shares the network with the services.
+func (e *Executor) waitServicesReady(ctx context.Context, t *types.ExecutorTask, pod driver.Pod, outf io.Writer) error {
+	for i, c := range t.Spec.Containers {
+		if c.Readiness == nil {
+			continue
+		}
+
+		name := c.Name
+		if name == "" {
+			name = c.Image
+		}
+
+		var cmd []string
+		if c.Readiness.TCPPort != 0 {
+			cmd = []string{e.toolboxContainerPath(), "check-tcp", fmt.Sprintf("localhost:%d", c.Readiness.TCPPort)}
+		} else {
+			shell := e.taskShell(t)
+			filename, err := e.createFile(ctx, pod, c.Readiness.Command, stepUser(t), shellScriptSuffix(shell), outf)
+			if err != nil {
+				return errors.Wrapf(err, "create file err")
+			}
+			cmd = append(strings.Split(shell, " "), filename)
+		}
+
+		timeout := c.Readiness.Timeout
+		if timeout == 0 {
+			timeout = defaultServiceReadinessTimeout
+		}
+
+		fmt.Fprintf(outf, "Waiting for service %d (%s) to be ready.\n", i, name)
+		if err := e.waitServiceReady(ctx, t, pod, cmd, timeout); err != nil {
+			fmt.Fprintf(outf, "Service %d (%s) not ready after %s.\n", i, name, timeout)
+			return errors.Wrapf(err, "service %d not ready", i)
+		}
+		fmt.Fprintf(outf, "Service %d (%s) ready.\n", i, name)
+	}
+
+	return nil
+}
+
+func (e *Executor) waitServiceReady(ctx context.Context, t *types.ExecutorTask, pod driver.Pod, cmd []string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		execConfig := &driver.ExecConfig{
+			Cmd:  cmd,
+			Env:  t.Spec.Environment,
+			User: stepUser(t),
+		}
+
+		ce, err := pod.Exec(ctx, execConfig)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		exitCode, err := ce.Wait(ctx)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if exitCode == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-time.After(serviceReadinessCheckInterval):
+		}
+	}
+}
+
 func (e *Executor) doSaveToWorkspaceStep(ctx context.Context, s *types.SaveToWorkspaceStep, t *types.ExecutorTask, pod driver.Pod, logPath string, archivePath string) (int, error) {
-	cmd := []string{toolboxContainerPath, "archive"}
+	cmd := []string{e.toolboxContainerPath(), "archive"}
 
 	if err := os.MkdirAll(filepath.Dir(logPath), 0770); err != nil {
 		return -1, errors.WithStack(err)
 	}
-	logf, err := os.Create(logPath)
+	logf, err := openTaskLogFile(t, logPath)
 	if err != nil {
 		return -1, errors.WithStack(err)
 	}
@@ -268,7 +459,7 @@ func (e *Executor) doSaveToWorkspaceStep(ctx context.Context, s *types.SaveToWor
 
 func (e *Executor) expandDir(ctx context.Context, t *types.ExecutorTask, pod driver.Pod, logf io.Writer, dir string) (string, error) {
 	args := []string{dir}
-	cmd := append([]string{toolboxContainerPath, "expanddir"}, args...)
+	cmd := append([]string{e.toolboxContainerPath(), "expanddir"}, args...)
 
 	// limit the template answer to max 1MiB
 	stdout := &bytes.Buffer{}
@@ -300,7 +491,7 @@ func (e *Executor) expandDir(ctx context.Context, t *types.ExecutorTask, pod dri
 
 func (e *Executor) mkdir(ctx context.Context, t *types.ExecutorTask, pod driver.Pod, logf io.Writer, dir string) error {
 	args := []string{dir}
-	cmd := append([]string{toolboxContainerPath, "mkdir"}, args...)
+	cmd := append([]string{e.toolboxContainerPath(), "mkdir"}, args...)
 
 	execConfig := &driver.ExecConfig{
 		Cmd:         cmd,
@@ -328,7 +519,7 @@ func (e *Executor) mkdir(ctx context.Context, t *types.ExecutorTask, pod driver.
 }
 
 func (e *Executor) template(ctx context.Context, t *types.ExecutorTask, pod driver.Pod, logf io.Writer, key string) (string, error) {
-	cmd := []string{toolboxContainerPath, "template"}
+	cmd := []string{e.toolboxContainerPath(), "template"}
 
 	// limit the template answer to max 1MiB
 	stdout := util.NewLimitedBuffer(1024 * 1024)
@@ -371,6 +562,118 @@ func (e *Executor) template(ctx context.Context, t *types.ExecutorTask, pod driv
 	return stdout.String(), nil
 }
 
+// getRunMeta returns the run metadata set by the task steps
+func (e *Executor) getRunMeta(ctx context.Context, t *types.ExecutorTask, pod driver.Pod) (map[string]string, error) {
+	cmd := []string{e.toolboxContainerPath(), "get-run-meta"}
+
+	// limit the run metadata to max 1MiB
+	stdout := util.NewLimitedBuffer(1024 * 1024)
+	stderr := util.NewLimitedBuffer(4096)
+
+	execConfig := &driver.ExecConfig{
+		Cmd:    cmd,
+		Env:    t.Spec.Environment,
+		User:   stepUser(t),
+		Stdout: stdout,
+		Stderr: stderr,
+	}
+
+	ce, err := pod.Exec(ctx, execConfig)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	exitCode, err := ce.Wait(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if exitCode != 0 {
+		return nil, errors.Errorf("get-run-meta ended with exit code %d: %s", exitCode, stderr.String())
+	}
+
+	var meta map[string]string
+	if err := json.Unmarshal(stdout.Bytes(), &meta); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return meta, nil
+}
+
+// getMetrics returns the metrics set by the task steps
+func (e *Executor) getMetrics(ctx context.Context, t *types.ExecutorTask, pod driver.Pod) (map[string]float64, error) {
+	cmd := []string{e.toolboxContainerPath(), "get-metrics"}
+
+	// limit the metrics to max 64KiB
+	stdout := util.NewLimitedBuffer(64 * 1024)
+	stderr := util.NewLimitedBuffer(4096)
+
+	execConfig := &driver.ExecConfig{
+		Cmd:    cmd,
+		Env:    t.Spec.Environment,
+		User:   stepUser(t),
+		Stdout: stdout,
+		Stderr: stderr,
+	}
+
+	ce, err := pod.Exec(ctx, execConfig)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	exitCode, err := ce.Wait(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if exitCode != 0 {
+		return nil, errors.Errorf("get-metrics ended with exit code %d: %s", exitCode, stderr.String())
+	}
+
+	var metrics map[string]float64
+	if err := json.Unmarshal(stdout.Bytes(), &metrics); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return metrics, nil
+}
+
+// toolboxInfo returns the version info of the toolbox copied into the pod
+func (e *Executor) toolboxInfo(ctx context.Context, t *types.ExecutorTask, pod driver.Pod) (*protocol.Info, error) {
+	cmd := []string{e.toolboxContainerPath(), "version"}
+
+	stdout := util.NewLimitedBuffer(4096)
+	stderr := util.NewLimitedBuffer(4096)
+
+	execConfig := &driver.ExecConfig{
+		Cmd:    cmd,
+		Env:    t.Spec.Environment,
+		User:   stepUser(t),
+		Stdout: stdout,
+		Stderr: stderr,
+	}
+
+	ce, err := pod.Exec(ctx, execConfig)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	exitCode, err := ce.Wait(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// toolboxes older than the protocol negotiation don't provide the version
+	// command
+	if exitCode != 0 {
+		return nil, errors.Errorf("toolbox version ended with exit code %d, the toolbox is probably older than the executor: %s", exitCode, stderr.String())
+	}
+
+	var info *protocol.Info
+	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return info, nil
+}
+
 func (e *Executor) unarchive(ctx context.Context, t *types.ExecutorTask, source io.Reader, pod driver.Pod, logf io.Writer, destDir string, overwrite, removeDestDir bool) error {
 	args := []string{"--destdir", destDir}
 	if overwrite {
@@ -379,7 +682,7 @@ func (e *Executor) unarchive(ctx context.Context, t *types.ExecutorTask, source
 	if removeDestDir {
 		args = append(args, "--remove-destdir")
 	}
-	cmd := append([]string{toolboxContainerPath, "unarchive"}, args...)
+	cmd := append([]string{e.toolboxContainerPath(), "unarchive"}, args...)
 
 	workingDir, err := e.expandDir(ctx, t, pod, logf, t.Spec.WorkingDir)
 	if err != nil {
@@ -419,11 +722,11 @@ func (e *Executor) unarchive(ctx context.Context, t *types.ExecutorTask, source
 	return nil
 }
 
-func (e *Executor) doRestoreWorkspaceStep(ctx context.Context, s *types.RestoreWorkspaceStep, t *types.ExecutorTask, pod driver.Pod, logPath string) (int, error) {
+func (e *Executor) doRestoreWorkspaceStep(ctx context.Context, s *types.RestoreWorkspaceStep, t *types.ExecutorTask, pod driver.Pod, logPath string, ts *types.TransferStats) (int, error) {
 	if err := os.MkdirAll(filepath.Dir(logPath), 0770); err != nil {
 		return -1, errors.WithStack(err)
 	}
-	logf, err := os.Create(logPath)
+	logf, err := openTaskLogFile(t, logPath)
 	if err != nil {
 		return -1, errors.WithStack(err)
 	}
@@ -438,23 +741,42 @@ func (e *Executor) doRestoreWorkspaceStep(ctx context.Context, s *types.RestoreW
 			return -1, errors.WithStack(err)
 		}
 		archivef := resp.Body
-		if err := e.unarchive(ctx, t, archivef, pod, logf, s.DestDir, false, false); err != nil {
-			archivef.Close()
+		start := time.Now()
+		cr := util.NewCountingReader(util.NewRateLimitedReader(ctx, archivef, e.downloadLimiter))
+		err = e.unarchive(ctx, t, cr, pod, logf, s.DestDir, op.Overwrite, false)
+		archivef.Close()
+		ts.Add(cr.Count(), time.Since(start))
+		if err != nil {
 			return -1, errors.WithStack(err)
 		}
-		archivef.Close()
 	}
+	fmt.Fprintf(logf, "transferred %d bytes in %s\n", ts.Bytes, ts.Duration)
 
 	return 0, nil
 }
 
-func (e *Executor) doSaveCacheStep(ctx context.Context, s *types.SaveCacheStep, t *types.ExecutorTask, pod driver.Pod, logPath string, archivePath string) (int, error) {
-	cmd := []string{toolboxContainerPath, "archive"}
+// checkCacheKey checks that the templated cache key is valid. Path separators
+// are allowed (i.e. to use a branch name in the key) but every path element
+// must be a valid name so the cache will be saved inside the caches dir
+func checkCacheKey(key string) error {
+	if key == "" {
+		return errors.Errorf("empty cache key")
+	}
+	for _, e := range strings.Split(key, "/") {
+		if e == "" || e == "." || e == ".." {
+			return errors.Errorf("cache key %q contains invalid path element %q", key, e)
+		}
+	}
+	return nil
+}
+
+func (e *Executor) doSaveCacheStep(ctx context.Context, s *types.SaveCacheStep, t *types.ExecutorTask, pod driver.Pod, logPath string, archivePath string, ts *types.TransferStats) (int, error) {
+	cmd := []string{e.toolboxContainerPath(), "archive"}
 
 	if err := os.MkdirAll(filepath.Dir(logPath), 0770); err != nil {
 		return -1, errors.WithStack(err)
 	}
-	logf, err := os.Create(logPath)
+	logf, err := openTaskLogFile(t, logPath)
 	if err != nil {
 		return -1, errors.WithStack(err)
 	}
@@ -468,12 +790,13 @@ func (e *Executor) doSaveCacheStep(ctx context.Context, s *types.SaveCacheStep,
 		return -1, errors.WithStack(err)
 	}
 	fmt.Fprintf(logf, "cache key %q\n", userKey)
-
-	// append cache prefix
-	key := t.Spec.CachePrefix + "-" + userKey
+	if err := checkCacheKey(userKey); err != nil {
+		fmt.Fprintf(logf, "%v\n", err)
+		return -1, errors.WithStack(err)
+	}
 
 	// check that the cache key doesn't already exists
-	resp, err := e.runserviceClient.CheckCache(ctx, key, false)
+	resp, err := e.runserviceClient.CheckCache(ctx, t.Spec.CachePrefix, userKey, false)
 	if err != nil {
 		// ignore 404 errors since they means that the cache key doesn't exists
 		if resp != nil && resp.StatusCode == http.StatusNotFound {
@@ -572,21 +895,29 @@ func (e *Executor) doSaveCacheStep(ctx context.Context, s *types.SaveCacheStep,
 	}
 
 	// send cache archive to scheduler
-	if resp, err := e.runserviceClient.PutCache(ctx, key, fi.Size(), f); err != nil {
+	start := time.Now()
+	cr := util.NewCountingReader(util.NewRateLimitedReader(ctx, f, e.uploadLimiter))
+	resp, err = e.runserviceClient.PutCache(ctx, t.Spec.CachePrefix, userKey, fi.Size(), cr)
+	ts.Add(cr.Count(), time.Since(start))
+	if err != nil {
 		if resp != nil && resp.StatusCode == http.StatusNotModified {
 			return exitCode, nil
 		}
+		if resp != nil && resp.StatusCode == http.StatusRequestEntityTooLarge {
+			fmt.Fprintf(logf, "cache archive of %d bytes exceeds the max cache size\n", fi.Size())
+		}
 		return -1, errors.WithStack(err)
 	}
+	fmt.Fprintf(logf, "transferred %d bytes in %s\n", ts.Bytes, ts.Duration)
 
 	return exitCode, nil
 }
 
-func (e *Executor) doRestoreCacheStep(ctx context.Context, s *types.RestoreCacheStep, t *types.ExecutorTask, pod driver.Pod, logPath string) (int, error) {
+func (e *Executor) doRestoreCacheStep(ctx context.Context, s *types.RestoreCacheStep, t *types.ExecutorTask, pod driver.Pod, logPath string, ts *types.TransferStats) (int, error) {
 	if err := os.MkdirAll(filepath.Dir(logPath), 0770); err != nil {
 		return -1, errors.WithStack(err)
 	}
-	logf, err := os.Create(logPath)
+	logf, err := openTaskLogFile(t, logPath)
 	if err != nil {
 		return -1, errors.WithStack(err)
 	}
@@ -600,11 +931,12 @@ func (e *Executor) doRestoreCacheStep(ctx context.Context, s *types.RestoreCache
 			return -1, errors.WithStack(err)
 		}
 		fmt.Fprintf(logf, "cache key %q\n", userKey)
+		if err := checkCacheKey(userKey); err != nil {
+			fmt.Fprintf(logf, "%v\n", err)
+			return -1, errors.WithStack(err)
+		}
 
-		// append cache prefix
-		key := t.Spec.CachePrefix + "-" + userKey
-
-		resp, err := e.runserviceClient.GetCache(ctx, key, true)
+		resp, err := e.runserviceClient.GetCache(ctx, t.Spec.CachePrefix, userKey, true)
 		if err != nil {
 			// ignore 404 errors since they means that the cache key doesn't exists
 			if resp != nil && resp.StatusCode == http.StatusNotFound {
@@ -615,13 +947,22 @@ func (e *Executor) doRestoreCacheStep(ctx context.Context, s *types.RestoreCache
 			fmt.Fprintf(logf, "error reading cache: %v\n", err)
 			return -1, errors.WithStack(err)
 		}
-		fmt.Fprintf(logf, "restoring cache with key %q\n", userKey)
+		// the matched key is path escaped
+		matchedKey, err := url.PathUnescape(resp.Header.Get(rscommon.CacheKeyHeader))
+		if err != nil || matchedKey == "" {
+			matchedKey = userKey
+		}
+		fmt.Fprintf(logf, "restoring cache with key %q\n", matchedKey)
 		cachef := resp.Body
-		if err := e.unarchive(ctx, t, cachef, pod, logf, s.DestDir, false, false); err != nil {
-			cachef.Close()
+		start := time.Now()
+		cr := util.NewCountingReader(util.NewRateLimitedReader(ctx, cachef, e.downloadLimiter))
+		err = e.unarchive(ctx, t, cr, pod, logf, s.DestDir, false, false)
+		cachef.Close()
+		ts.Add(cr.Count(), time.Since(start))
+		if err != nil {
 			return -1, errors.WithStack(err)
 		}
-		cachef.Close()
+		fmt.Fprintf(logf, "transferred %d bytes in %s\n", ts.Bytes, ts.Duration)
 
 		// stop here
 		break
@@ -630,10 +971,272 @@ func (e *Executor) doRestoreCacheStep(ctx context.Context, s *types.RestoreCache
 	return 0, nil
 }
 
+func (e *Executor) doSaveArtifactsStep(ctx context.Context, s *types.SaveArtifactsStep, t *types.ExecutorTask, pod driver.Pod, logPath string, archivePath string, ts *types.TransferStats) (int, error) {
+	cmd := []string{e.toolboxContainerPath(), "archive"}
+
+	if err := os.MkdirAll(filepath.Dir(logPath), 0770); err != nil {
+		return -1, errors.WithStack(err)
+	}
+	logf, err := openTaskLogFile(t, logPath)
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+	defer logf.Close()
+
+	fmt.Fprintf(logf, "archiving artifacts\n")
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0770); err != nil {
+		return -1, errors.WithStack(err)
+	}
+	archivef, err := os.Create(archivePath)
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+	defer archivef.Close()
+
+	workingDir, err := e.expandDir(ctx, t, pod, logf, t.Spec.WorkingDir)
+	if err != nil {
+		_, _ = io.WriteString(logf, fmt.Sprintf("failed to expand working dir %q. Error: %s\n", t.Spec.WorkingDir, err))
+		return -1, errors.WithStack(err)
+	}
+
+	execConfig := &driver.ExecConfig{
+		Cmd:         cmd,
+		Env:         t.Spec.Environment,
+		WorkingDir:  workingDir,
+		User:        stepUser(t),
+		AttachStdin: true,
+		Stdout:      archivef,
+		Stderr:      logf,
+	}
+
+	ce, err := pod.Exec(ctx, execConfig)
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+
+	type ArchiveInfo struct {
+		SourceDir string
+		DestDir   string
+		Paths     []string
+	}
+	type Archive struct {
+		ArchiveInfos []*ArchiveInfo
+		OutFile      string
+	}
+
+	a := &Archive{
+		OutFile:      "", // use stdout
+		ArchiveInfos: make([]*ArchiveInfo, len(s.Contents)),
+	}
+
+	for i, c := range s.Contents {
+		a.ArchiveInfos[i] = &ArchiveInfo{
+			SourceDir: c.SourceDir,
+			DestDir:   c.DestDir,
+			Paths:     c.Paths,
+		}
+	}
+
+	stdin := ce.Stdin()
+	enc := json.NewEncoder(stdin)
+
+	go func() {
+		_ = enc.Encode(a)
+		stdin.Close()
+	}()
+
+	exitCode, err := ce.Wait(ctx)
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+
+	if exitCode != 0 {
+		return exitCode, errors.Errorf("save artifacts archiving command ended with exit code %d", exitCode)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+
+	// send artifacts archive to the runservice
+	start := time.Now()
+	cr := util.NewCountingReader(util.NewRateLimitedReader(ctx, f, e.uploadLimiter))
+	resp, err := e.runserviceClient.PutTaskArtifacts(ctx, t.Spec.RunID, t.Spec.RunTaskID, fi.Size(), cr)
+	ts.Add(cr.Count(), time.Since(start))
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusRequestEntityTooLarge {
+			fmt.Fprintf(logf, "artifacts archive of %d bytes exceeds the max artifacts size\n", fi.Size())
+		}
+		return -1, errors.WithStack(err)
+	}
+	fmt.Fprintf(logf, "transferred %d bytes in %s\n", ts.Bytes, ts.Duration)
+
+	return exitCode, nil
+}
+
+func (e *Executor) doRestoreArtifactsStep(ctx context.Context, s *types.RestoreArtifactsStep, t *types.ExecutorTask, pod driver.Pod, logPath string, ts *types.TransferStats) (int, error) {
+	if err := os.MkdirAll(filepath.Dir(logPath), 0770); err != nil {
+		return -1, errors.WithStack(err)
+	}
+	logf, err := openTaskLogFile(t, logPath)
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+	defer logf.Close()
+
+	for _, taskID := range s.TaskIDs {
+		resp, err := e.runserviceClient.GetTaskArtifacts(ctx, t.Spec.RunID, taskID)
+		if err != nil {
+			// ignore 404 errors since they means that the task didn't save
+			// any artifact
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				fmt.Fprintf(logf, "no artifacts available for task %q\n", taskID)
+				continue
+			}
+			// TODO(sgotti) retry before giving up
+			fmt.Fprintf(logf, "error reading artifacts: %v\n", err)
+			return -1, errors.WithStack(err)
+		}
+		fmt.Fprintf(logf, "restoring artifacts of task %q\n", taskID)
+		artifactsf := resp.Body
+		start := time.Now()
+		cr := util.NewCountingReader(util.NewRateLimitedReader(ctx, artifactsf, e.downloadLimiter))
+		err = e.unarchive(ctx, t, cr, pod, logf, s.DestDir, false, false)
+		artifactsf.Close()
+		ts.Add(cr.Count(), time.Since(start))
+		if err != nil {
+			return -1, errors.WithStack(err)
+		}
+	}
+	fmt.Fprintf(logf, "transferred %d bytes in %s\n", ts.Bytes, ts.Duration)
+
+	return 0, nil
+}
+
+func (e *Executor) doTestReportStep(ctx context.Context, s *types.TestReportStep, t *types.ExecutorTask, pod driver.Pod, logPath string, archivePath string, ts *types.TransferStats) (int, error) {
+	cmd := []string{e.toolboxContainerPath(), "archive"}
+
+	if err := os.MkdirAll(filepath.Dir(logPath), 0770); err != nil {
+		return -1, errors.WithStack(err)
+	}
+	logf, err := openTaskLogFile(t, logPath)
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+	defer logf.Close()
+
+	fmt.Fprintf(logf, "archiving test reports\n")
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0770); err != nil {
+		return -1, errors.WithStack(err)
+	}
+	archivef, err := os.Create(archivePath)
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+	defer archivef.Close()
+
+	workingDir, err := e.expandDir(ctx, t, pod, logf, t.Spec.WorkingDir)
+	if err != nil {
+		_, _ = io.WriteString(logf, fmt.Sprintf("failed to expand working dir %q. Error: %s\n", t.Spec.WorkingDir, err))
+		return -1, errors.WithStack(err)
+	}
+
+	execConfig := &driver.ExecConfig{
+		Cmd:         cmd,
+		Env:         t.Spec.Environment,
+		WorkingDir:  workingDir,
+		User:        stepUser(t),
+		AttachStdin: true,
+		Stdout:      archivef,
+		Stderr:      logf,
+	}
+
+	ce, err := pod.Exec(ctx, execConfig)
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+
+	type ArchiveInfo struct {
+		SourceDir string
+		DestDir   string
+		Paths     []string
+	}
+	type Archive struct {
+		ArchiveInfos []*ArchiveInfo
+		OutFile      string
+	}
+
+	// the report paths are relative to the task working dir
+	a := &Archive{
+		OutFile: "", // use stdout
+		ArchiveInfos: []*ArchiveInfo{
+			{
+				SourceDir: workingDir,
+				Paths:     s.Paths,
+			},
+		},
+	}
+
+	stdin := ce.Stdin()
+	enc := json.NewEncoder(stdin)
+
+	go func() {
+		_ = enc.Encode(a)
+		stdin.Close()
+	}()
+
+	exitCode, err := ce.Wait(ctx)
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+
+	if exitCode != 0 {
+		return exitCode, errors.Errorf("test report archiving command ended with exit code %d", exitCode)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return -1, errors.WithStack(err)
+	}
+
+	// send test reports archive to the runservice that will parse it
+	start := time.Now()
+	cr := util.NewCountingReader(util.NewRateLimitedReader(ctx, f, e.uploadLimiter))
+	resp, err := e.runserviceClient.PutTaskTestReports(ctx, t.Spec.RunID, t.Spec.RunTaskID, fi.Size(), cr)
+	ts.Add(cr.Count(), time.Since(start))
+	if err != nil {
+		switch {
+		case resp != nil && resp.StatusCode == http.StatusRequestEntityTooLarge:
+			fmt.Fprintf(logf, "test reports archive of %d bytes exceeds the max test reports size\n", fi.Size())
+		case resp != nil && resp.StatusCode == http.StatusBadRequest:
+			fmt.Fprintf(logf, "invalid test reports: %v\n", err)
+		}
+		return -1, errors.WithStack(err)
+	}
+	fmt.Fprintf(logf, "transferred %d bytes in %s\n", ts.Bytes, ts.Duration)
+
+	return exitCode, nil
+}
+
 func (e *Executor) executorIDPath() string {
 	return filepath.Join(e.c.DataDir, "id")
 }
 
+func (e *Executor) executorTokenPath() string {
+	return filepath.Join(e.c.DataDir, "token")
+}
+
 func (e *Executor) tasksDir() string {
 	return filepath.Join(e.c.DataDir, "tasks")
 }
@@ -658,31 +1261,60 @@ func (e *Executor) archivePath(taskID string, stepID int) string {
 	return filepath.Join(e.taskPath(taskID), "archives", fmt.Sprintf("%d.tar", stepID))
 }
 
-func (e *Executor) sendExecutorStatus(ctx context.Context) error {
-	labels := e.c.Labels
-	if labels == nil {
-		labels = make(map[string]string)
-	}
+// executorFeatures are the task execution features provided by the executor
+// and reported to the runservice
+var executorFeatures = []types.ExecutorFeature{
+	types.ExecutorFeatureCacheGroups,
+	types.ExecutorFeatureWorkspaceOverwrite,
+	types.ExecutorFeatureArtifacts,
+	types.ExecutorFeatureStepRetries,
+	types.ExecutorFeatureStepTimeouts,
+	types.ExecutorFeatureStepRetryBackoff,
+	types.ExecutorFeatureTaskRetries,
+	types.ExecutorFeatureTestReports,
+}
 
+// sendExecutorStatus sends the executor status to the runservice. It returns
+// true when the executor is draining and, since it has no more active tasks,
+// it has been deregistered.
+func (e *Executor) sendExecutorStatus(ctx context.Context) (bool, error) {
 	activeTasks := e.runningTasks.len()
 
 	archs, err := e.driver.Archs(ctx)
 	if err != nil {
-		return errors.WithStack(err)
+		return false, errors.WithStack(err)
+	}
+
+	driverLabels, err := e.driver.Labels(ctx)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	// the automatically detected labels override the configured ones
+	labels := make(map[string]string)
+	for k, v := range e.c.Labels {
+		labels[k] = v
+	}
+	for k, v := range driverLabels {
+		labels[k] = v
+	}
+	labels[types.ExecutorLabelOS] = string(e.os)
+	if len(archs) == 1 {
+		labels[types.ExecutorLabelArch] = string(archs[0])
 	}
 
 	executorGroup, err := e.driver.ExecutorGroup(ctx)
 	if err != nil {
-		return errors.WithStack(err)
+		return false, errors.WithStack(err)
 	}
 	// report all the executors that are active OR that have some owned pods not yet removed
 	activeExecutors, err := e.driver.GetExecutors(ctx)
 	if err != nil {
-		return errors.WithStack(err)
+		return false, errors.WithStack(err)
 	}
 	pods, err := e.driver.GetPods(ctx, true)
 	if err != nil {
-		return errors.WithStack(err)
+		return false, errors.WithStack(err)
 	}
 
 	executorsMap := map[string]struct{}{}
@@ -697,22 +1329,53 @@ func (e *Executor) sendExecutorStatus(ctx context.Context) error {
 		siblingsExecutors = append(siblingsExecutors, executorID)
 	}
 
+	labelsActiveTasksLimits := make([]types.LabelsActiveTasksLimit, len(e.c.LabelsActiveTasksLimits))
+	for i, l := range e.c.LabelsActiveTasksLimits {
+		labelsActiveTasksLimits[i] = types.LabelsActiveTasksLimit{
+			Labels: l.Labels,
+			Limit:  l.Limit,
+		}
+	}
+
 	executor := &types.Executor{
-		ExecutorID:                e.id,
-		Archs:                     archs,
-		AllowPrivilegedContainers: e.c.AllowPrivilegedContainers,
-		ListenURL:                 e.listenURL,
-		Labels:                    labels,
-		ActiveTasksLimit:          e.c.ActiveTasksLimit,
-		ActiveTasks:               activeTasks,
-		Dynamic:                   e.dynamic,
-		ExecutorGroup:             executorGroup,
-		SiblingsExecutors:         siblingsExecutors,
+		ExecutorID:                   e.id,
+		Archs:                        archs,
+		AllowPrivilegedContainers:    e.c.AllowPrivilegedContainers,
+		PrivilegedContainersProjects: e.c.PrivilegedContainersProjects,
+		GPUs:                         e.c.GPUs,
+		RuntimeType:                  e.runtimeType,
+		ListenURL:                    e.listenURL,
+		Labels:                       labels,
+		ActiveTasksLimit:             e.c.ActiveTasksLimit,
+		ActiveTasks:                  activeTasks,
+		LabelsActiveTasksLimits:      labelsActiveTasksLimits,
+		Dynamic:                      e.dynamic,
+		ExecutorGroup:                executorGroup,
+		SiblingsExecutors:            siblingsExecutors,
+		Version:                      acmd.Version,
+		Features:                     executorFeatures,
+		Health:                       e.executorHealth(ctx),
 	}
 
 	e.log.Debug().Msgf("send executor status: %s", util.Dump(executor))
-	_, err = e.runserviceClient.SendExecutorStatus(ctx, executor)
-	return errors.WithStack(err)
+	rexecutor, _, err := e.runserviceClient.SendExecutorStatus(ctx, executor)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	if rexecutor.State == types.ExecutorStatePending {
+		e.log.Warn().Msgf("executor %s is waiting to be approved", e.id)
+	}
+
+	if !rexecutor.Draining || activeTasks > 0 {
+		return false, nil
+	}
+
+	if _, err := e.runserviceClient.DeleteExecutor(ctx, e.id); err != nil {
+		return false, errors.Wrapf(err, "failed to deregister executor")
+	}
+
+	return true, nil
 }
 
 func (e *Executor) sendExecutorTaskStatus(ctx context.Context, et *types.ExecutorTask) error {
@@ -750,8 +1413,89 @@ func (e *Executor) executeTask(rt *runningTask) {
 
 	et := rt.et
 
+	// incrementally upload the task logs while the task is executing
+	logChunksStopCh := make(chan struct{})
+	defer close(logChunksStopCh)
+	go e.logChunksUploaderLoop(ctx, et, logChunksStopCh)
+
 	et.Status.Phase = types.ExecutorTaskPhaseRunning
 	et.Status.StartTime = util.TimeP(time.Now())
+
+	retryInterval := et.Spec.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultTaskRetryInterval
+	}
+
+	var err error
+	attempts := et.Spec.Retries + 1
+	for attempt := 1; attempt <= attempts; attempt++ {
+		et.Status.Attempt = attempt
+		if attempt > 1 {
+			// reset the status of the previous attempt
+			et.Status.FailError = ""
+			et.Status.SetupStep = types.ExecutorTaskStepStatus{Phase: types.ExecutorTaskPhaseNotStarted}
+			for i := range et.Status.Steps {
+				et.Status.Steps[i] = &types.ExecutorTaskStepStatus{Phase: types.ExecutorTaskPhaseNotStarted}
+			}
+		}
+
+		err = e.executeTaskAttempt(ctx, rt)
+		if err == nil || et.Spec.Stop || ctx.Err() != nil || attempt == attempts {
+			break
+		}
+
+		e.log.Info().Msgf("task %s attempt %d of %d failed, retrying in %s", et.ID, attempt, attempts, retryInterval)
+
+		// remove the pod of the failed attempt, a new one will be created by
+		// the next attempt
+		if rt.pod != nil {
+			if err := rt.pod.Remove(context.Background()); err != nil {
+				e.log.Err(err).Msgf("error removing the pod")
+			}
+			rt.pod = nil
+		}
+
+		rt.Unlock()
+		select {
+		case <-ctx.Done():
+		case <-time.After(retryInterval):
+		}
+		rt.Lock()
+
+		if et.Spec.Stop || ctx.Err() != nil {
+			break
+		}
+
+		if et.Spec.RetryBackoff > 1 {
+			retryInterval = time.Duration(float64(retryInterval) * et.Spec.RetryBackoff)
+		}
+	}
+
+	if err != nil {
+		e.log.Err(err).Send()
+		if et.Spec.Stop {
+			et.Status.Phase = types.ExecutorTaskPhaseStopped
+		} else {
+			et.Status.Phase = types.ExecutorTaskPhaseFailed
+		}
+	} else {
+		et.Status.Phase = types.ExecutorTaskPhaseSuccess
+	}
+
+	et.Status.EndTime = util.TimeP(time.Now())
+
+	if err := e.sendExecutorTaskStatus(ctx, et); err != nil {
+		e.log.Err(err).Send()
+	}
+	rt.Unlock()
+}
+
+// executeTaskAttempt executes an attempt of the task: it sets up the task pod
+// and executes the task steps.
+// It must be called with rt locked and returns with rt locked.
+func (e *Executor) executeTaskAttempt(ctx context.Context, rt *runningTask) error {
+	et := rt.et
+
 	et.Status.SetupStep.Phase = types.ExecutorTaskPhaseRunning
 	et.Status.SetupStep.StartTime = util.TimeP(time.Now())
 	if err := e.sendExecutorTaskStatus(ctx, et); err != nil {
@@ -759,16 +1503,12 @@ func (e *Executor) executeTask(rt *runningTask) {
 	}
 
 	if err := e.setupTask(ctx, rt); err != nil {
-		e.log.Err(err).Send()
-		et.Status.Phase = types.ExecutorTaskPhaseFailed
-		et.Status.EndTime = util.TimeP(time.Now())
 		et.Status.SetupStep.Phase = types.ExecutorTaskPhaseFailed
 		et.Status.SetupStep.EndTime = util.TimeP(time.Now())
 		if err := e.sendExecutorTaskStatus(ctx, et); err != nil {
 			e.log.Err(err).Send()
 		}
-		rt.Unlock()
-		return
+		return errors.WithStack(err)
 	}
 
 	et.Status.SetupStep.Phase = types.ExecutorTaskPhaseSuccess
@@ -779,32 +1519,130 @@ func (e *Executor) executeTask(rt *runningTask) {
 
 	rt.Unlock()
 
-	_, err := e.executeTaskSteps(ctx, rt, rt.pod)
+	// the task timeout doesn't include the setup time (i.e. the images pull)
+	stepsCtx := ctx
+	if et.Spec.Timeout > 0 {
+		var cancel context.CancelFunc
+		stepsCtx, cancel = context.WithTimeout(ctx, et.Spec.Timeout)
+		defer cancel()
+	}
+
+	// sample the pod resource usage while executing the steps
+	samplerStopCh := make(chan struct{})
+	samplerDoneCh := make(chan struct{})
+	go func() {
+		e.resourceUsageSamplerLoop(ctx, rt, rt.pod, samplerStopCh)
+		close(samplerDoneCh)
+	}()
+
+	_, err := e.executeTaskSteps(stepsCtx, rt, rt.pod)
+
+	close(samplerStopCh)
+	<-samplerDoneCh
 
 	rt.Lock()
-	if err != nil {
-		e.log.Err(err).Send()
-		if rt.et.Spec.Stop {
-			et.Status.Phase = types.ExecutorTaskPhaseStopped
-		} else {
-			et.Status.Phase = types.ExecutorTaskPhaseFailed
+	if err != nil && !et.Spec.Stop && errors.Is(stepsCtx.Err(), context.DeadlineExceeded) {
+		et.Status.FailError = fmt.Sprintf("task timed out after %s", et.Spec.Timeout)
+	}
+
+	return errors.WithStack(err)
+}
+
+// resourceUsageSampler computes the task resource usage from the pod stats
+// samples
+type resourceUsageSampler struct {
+	lastCPUTime    time.Duration
+	lastSampleTime time.Time
+}
+
+func (s *resourceUsageSampler) update(ru *types.ResourceUsage, ps *driver.PodStats, now time.Time) {
+	// the cpu usage is the cpu time used between two samples
+	if !s.lastSampleTime.IsZero() {
+		if d := now.Sub(s.lastSampleTime); d > 0 && ps.CPUTime > s.lastCPUTime {
+			cpu := int64((ps.CPUTime - s.lastCPUTime) * 1000 / d)
+			if cpu > ru.MaxCPU {
+				ru.MaxCPU = cpu
+			}
 		}
-	} else {
-		et.Status.Phase = types.ExecutorTaskPhaseSuccess
 	}
+	s.lastCPUTime = ps.CPUTime
+	s.lastSampleTime = now
 
-	et.Status.EndTime = util.TimeP(time.Now())
+	ru.CPUTime = ps.CPUTime
+	ru.Memory = ps.Memory
+	if ps.Memory > ru.MaxMemory {
+		ru.MaxMemory = ps.Memory
+	}
+	ru.MemoryLimit = ps.MemoryLimit
+	ru.Disk = ps.Disk
+}
 
-	if err := e.sendExecutorTaskStatus(ctx, et); err != nil {
-		e.log.Err(err).Send()
+// resourceUsageSamplerLoop periodically samples the pod resource usage until
+// stopCh is closed, then it takes a last sample
+func (e *Executor) resourceUsageSamplerLoop(ctx context.Context, rt *runningTask, pod driver.Pod, stopCh <-chan struct{}) {
+	s := &resourceUsageSampler{}
+	for {
+		stop := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			stop = true
+		case <-time.After(resourceUsageSampleInterval):
+		}
+
+		if err := e.sampleResourceUsage(ctx, rt, pod, s); err != nil {
+			e.log.Warn().Err(err).Msgf("failed to sample task %q resource usage", rt.et.ID)
+		}
+		if stop {
+			return
+		}
 	}
-	rt.Unlock()
+}
+
+func (e *Executor) sampleResourceUsage(ctx context.Context, rt *runningTask, pod driver.Pod, s *resourceUsageSampler) error {
+	ps, err := pod.Stats(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// not supported by the driver
+	if ps == nil {
+		return nil
+	}
+
+	rt.Lock()
+	defer rt.Unlock()
+
+	if rt.et.Status.ResourceUsage == nil {
+		rt.et.Status.ResourceUsage = &types.ResourceUsage{}
+	}
+	s.update(rt.et.Status.ResourceUsage, ps, time.Now())
+
+	return nil
+}
+
+// containerCmd returns the entrypoint and the args of the task container at
+// the provided index. The main container must always run the toolbox sleeper
+// since the steps are executed inside it, so a user defined entrypoint is used
+// as a wrapper of the sleeper instead of replacing it. This also ignores the
+// image entrypoint and command that could make the container exit.
+func (e *Executor) containerCmd(index int, c *types.Container) ([]string, []string) {
+	entrypoint := strings.Fields(c.Entrypoint)
+	if index == 0 {
+		return append(entrypoint, e.toolboxContainerPath(), "sleeper"), nil
+	}
+
+	return entrypoint, c.Args
 }
 
 func (e *Executor) setupTask(ctx context.Context, rt *runningTask) error {
 	et := rt.et
-	if err := os.RemoveAll(e.taskPath(et.ID)); err != nil {
-		return errors.WithStack(err)
+	// keep the data of the previous attempts of a retried task so its logs
+	// contain the output of all the attempts
+	if et.Status.Attempt <= 1 {
+		if err := os.RemoveAll(e.taskPath(et.ID)); err != nil {
+			return errors.WithStack(err)
+		}
 	}
 	if err := os.MkdirAll(e.taskPath(et.ID), 0770); err != nil {
 		return errors.WithStack(err)
@@ -814,16 +1652,26 @@ func (e *Executor) setupTask(ctx context.Context, rt *runningTask) error {
 	if err := os.MkdirAll(filepath.Dir(setupLogPath), 0770); err != nil {
 		return errors.WithStack(err)
 	}
-	outf, err := os.Create(setupLogPath)
+	outf, err := openTaskLogFile(et, setupLogPath)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	defer outf.Close()
 
+	// error out if the task runtime type isn't the one supported by the driver
+	runtimeType := et.Spec.RuntimeType
+	if runtimeType == "" {
+		runtimeType = types.RuntimeTypePod
+	}
+	if runtimeType != e.runtimeType {
+		_, _ = outf.WriteString(fmt.Sprintf("Executor doesn't support runtime type %q.\n", runtimeType))
+		return errors.Errorf("executor doesn't support runtime type %q", runtimeType)
+	}
+
 	// error out if privileged containers are required but not allowed
 	requiresPrivilegedContainers := false
 	for _, c := range et.Spec.Containers {
-		if c.Privileged {
+		if c.RequiresPrivileges() {
 			requiresPrivilegedContainers = true
 			break
 		}
@@ -833,11 +1681,33 @@ func (e *Executor) setupTask(ctx context.Context, rt *runningTask) error {
 		return errors.Errorf("executor doesn't allow executing privileged containers")
 	}
 
+	// error out if the task requires more gpus than the ones provided
+	if et.Spec.GPUs > e.c.GPUs {
+		_, _ = outf.WriteString(fmt.Sprintf("Executor doesn't provide the %d required gpus.\n", et.Spec.GPUs))
+		return errors.Errorf("executor doesn't provide the %d required gpus", et.Spec.GPUs)
+	}
+
+	// error out if a container image isn't allowed by the image policy
+	for _, c := range et.Spec.Containers {
+		// host runtime tasks have no images
+		if c.Image == "" {
+			continue
+		}
+		if err := e.imagePolicy.check(c.Image); err != nil {
+			_, _ = outf.WriteString(fmt.Sprintf("Image %q not allowed by the executor image policy: %s.\n", c.Image, err))
+			return errors.Wrapf(err, "image %q not allowed by the executor image policy", c.Image)
+		}
+	}
+
 	e.log.Debug().Msgf("starting pod")
 
-	dockerConfig, err := registry.GenDockerConfig(et.Spec.DockerRegistriesAuth, []string{et.Spec.Containers[0].Image})
-	if err != nil {
-		return errors.WithStack(err)
+	// host runtime tasks have no images
+	var dockerConfig *registry.DockerConfig
+	if et.Spec.Containers[0].Image != "" {
+		dockerConfig, err = registry.GenDockerConfig(et.Spec.DockerRegistriesAuth, []string{et.Spec.Containers[0].Image})
+		if err != nil {
+			return errors.WithStack(err)
+		}
 	}
 
 	podConfig := &driver.PodConfig{
@@ -846,26 +1716,48 @@ func (e *Executor) setupTask(ctx context.Context, rt *runningTask) error {
 		ID:            uuid.Must(uuid.NewV4()).String(),
 		TaskID:        et.ID,
 		Arch:          et.Spec.Arch,
-		InitVolumeDir: toolboxContainerDir,
+		InitVolumeDir: e.toolboxContainerDir(),
 		DockerConfig:  dockerConfig,
+		NodeSelector:  et.Spec.NodeSelector,
+		Tolerations:   et.Spec.Tolerations,
 		Containers:    make([]*driver.ContainerConfig, len(et.Spec.Containers)),
 	}
 	for i, c := range et.Spec.Containers {
-		var cmd []string
+		var gpus int
 		if i == 0 {
-			cmd = []string{toolboxContainerPath, "sleeper"}
+			gpus = et.Spec.GPUs
+		}
+		cmd, args := e.containerCmd(i, c)
+
+		resources, err := e.containerResources(c.Resources)
+		if err != nil {
+			_, _ = outf.WriteString(fmt.Sprintf("Wrong container %d resources: %s\n", i, err))
+			return errors.WithStack(err)
+		}
+
+		pullPolicy := c.PullPolicy
+		if pullPolicy == "" {
+			pullPolicy = types.PullPolicy(e.c.DefaultPullPolicy)
 		}
-		if c.Entrypoint != "" {
-			cmd = strings.Split(c.Entrypoint, " ")
+		if pullPolicy == "" {
+			pullPolicy = types.PullPolicyAlways
 		}
 
 		containerConfig := &driver.ContainerConfig{
+			Name:       c.Name,
 			Image:      c.Image,
 			Cmd:        cmd,
+			Args:       args,
 			Env:        c.Environment,
 			User:       c.User,
 			Privileged: c.Privileged,
+			CapAdd:     c.CapAdd,
+			CapDrop:    c.CapDrop,
+			Devices:    c.Devices,
+			GPUs:       gpus,
 			Volumes:    make([]driver.Volume, len(c.Volumes)),
+			Resources:  resources,
+			PullPolicy: pullPolicy,
 		}
 
 		for vIndex, cVol := range c.Volumes {
@@ -889,6 +1781,19 @@ func (e *Executor) setupTask(ctx context.Context, rt *runningTask) error {
 		return errors.WithStack(err)
 	}
 	_, _ = outf.WriteString("Pod started.\n")
+	// set the pod now so it'll be removed before retrying a task failed
+	// during the setup
+	rt.pod = pod
+
+	toolboxInfo, err := e.toolboxInfo(ctx, et, pod)
+	if err != nil {
+		_, _ = outf.WriteString(fmt.Sprintf("Failed to get toolbox version. Error: %s\n", err))
+		return errors.WithStack(err)
+	}
+	if err := toolboxInfo.Check(); err != nil {
+		_, _ = outf.WriteString(fmt.Sprintf("Incompatible toolbox. Error: %s\n", err))
+		return errors.WithStack(err)
+	}
 
 	if et.Spec.WorkingDir != "" {
 		_, _ = outf.WriteString(fmt.Sprintf("Creating working dir %q.\n", et.Spec.WorkingDir))
@@ -898,12 +1803,31 @@ func (e *Executor) setupTask(ctx context.Context, rt *runningTask) error {
 		}
 	}
 
-	rt.pod = pod
+	if err := e.waitServicesReady(ctx, et, pod, outf); err != nil {
+		_, _ = outf.WriteString(fmt.Sprintf("Services failed to be ready. Error: %s\n", err))
+		return errors.WithStack(err)
+	}
+
 	return nil
 }
 
 func (e *Executor) executeTaskSteps(ctx context.Context, rt *runningTask, pod driver.Pod) (int, error) {
+	// the index and error of the first failed step
+	var failedStep int
+	var ferr error
+
 	for i, step := range rt.et.Spec.Steps {
+		// after a step failure only the test report steps are executed to
+		// collect the reports of the failed tests
+		if ferr != nil {
+			rt.Lock()
+			stop := rt.et.Spec.Stop
+			rt.Unlock()
+			if _, ok := step.(*types.TestReportStep); !ok || stop || ctx.Err() != nil {
+				continue
+			}
+		}
+
 		rt.Lock()
 		rt.et.Status.Steps[i].Phase = types.ExecutorTaskPhaseRunning
 		rt.et.Status.Steps[i].StartTime = util.TimeP(time.Now())
@@ -915,43 +1839,107 @@ func (e *Executor) executeTaskSteps(ctx context.Context, rt *runningTask, pod dr
 		var err error
 		var exitCode int
 		var stepName string
+		var ts *types.TransferStats
+
+		// the step is stopped when its timeout is exceeded
+		stepCtx := ctx
+		var stepTimeout time.Duration
+		var cancel context.CancelFunc = func() {}
+		if bs := types.StepBase(step); bs != nil && bs.Timeout > 0 {
+			stepTimeout = bs.Timeout
+			stepCtx, cancel = context.WithTimeout(ctx, stepTimeout)
+		}
 
 		switch s := step.(type) {
 		case *types.RunStep:
 			e.log.Debug().Msgf("run step: %s", util.Dump(s))
 			stepName = s.Name
-			exitCode, err = e.doRunStep(ctx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i))
+			exitCode, err = e.doRunStep(stepCtx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i))
+
+			// collect the run metadata set by the step
+			if runMeta, merr := e.getRunMeta(ctx, rt.et, pod); merr != nil {
+				e.log.Warn().Err(merr).Msgf("failed to get run metadata")
+			} else {
+				rt.Lock()
+				rt.et.Status.RunMeta = runMeta
+				rt.Unlock()
+			}
+
+			// collect the metrics emitted by the step
+			if metrics, merr := e.getMetrics(ctx, rt.et, pod); merr != nil {
+				e.log.Warn().Err(merr).Msgf("failed to get metrics")
+			} else {
+				rt.Lock()
+				rt.et.Status.Metrics = metrics
+				rt.Unlock()
+			}
 
 		case *types.SaveToWorkspaceStep:
 			e.log.Debug().Msgf("save to workspace step: %s", util.Dump(s))
 			stepName = s.Name
+			if rt.et.Spec.SkipWorkspace {
+				err = errors.Errorf("save to workspace step not allowed in task with skip workspace")
+				break
+			}
 			archivePath := e.archivePath(rt.et.ID, i)
-			exitCode, err = e.doSaveToWorkspaceStep(ctx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i), archivePath)
+			exitCode, err = e.doSaveToWorkspaceStep(stepCtx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i), archivePath)
 
 		case *types.RestoreWorkspaceStep:
 			e.log.Debug().Msgf("restore workspace step: %s", util.Dump(s))
 			stepName = s.Name
-			exitCode, err = e.doRestoreWorkspaceStep(ctx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i))
+			if rt.et.Spec.SkipWorkspace {
+				err = errors.Errorf("restore workspace step not allowed in task with skip workspace")
+				break
+			}
+			ts = &types.TransferStats{}
+			exitCode, err = e.doRestoreWorkspaceStep(stepCtx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i), ts)
 
 		case *types.SaveCacheStep:
 			e.log.Debug().Msgf("save cache step: %s", util.Dump(s))
 			stepName = s.Name
 			archivePath := e.archivePath(rt.et.ID, i)
-			exitCode, err = e.doSaveCacheStep(ctx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i), archivePath)
+			ts = &types.TransferStats{}
+			exitCode, err = e.doSaveCacheStep(stepCtx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i), archivePath, ts)
 
 		case *types.RestoreCacheStep:
 			e.log.Debug().Msgf("restore cache step: %s", util.Dump(s))
 			stepName = s.Name
-			exitCode, err = e.doRestoreCacheStep(ctx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i))
+			ts = &types.TransferStats{}
+			exitCode, err = e.doRestoreCacheStep(stepCtx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i), ts)
+
+		case *types.SaveArtifactsStep:
+			e.log.Debug().Msgf("save artifacts step: %s", util.Dump(s))
+			stepName = s.Name
+			archivePath := e.archivePath(rt.et.ID, i)
+			ts = &types.TransferStats{}
+			exitCode, err = e.doSaveArtifactsStep(stepCtx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i), archivePath, ts)
+
+		case *types.RestoreArtifactsStep:
+			e.log.Debug().Msgf("restore artifacts step: %s", util.Dump(s))
+			stepName = s.Name
+			ts = &types.TransferStats{}
+			exitCode, err = e.doRestoreArtifactsStep(stepCtx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i), ts)
+
+		case *types.TestReportStep:
+			e.log.Debug().Msgf("test report step: %s", util.Dump(s))
+			stepName = s.Name
+			archivePath := e.archivePath(rt.et.ID, i)
+			ts = &types.TransferStats{}
+			exitCode, err = e.doTestReportStep(stepCtx, s, rt.et, pod, e.stepLogPath(rt.et.ID, i), archivePath, ts)
 
 		default:
+			cancel()
 			return i, errors.Errorf("unknown step type: %s", util.Dump(s))
 		}
 
+		stepTimedOut := stepTimeout > 0 && errors.Is(stepCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+		cancel()
+
 		var serr error
 
 		rt.Lock()
 		rt.et.Status.Steps[i].EndTime = util.TimeP(time.Now())
+		rt.et.Status.Steps[i].Transfer = ts
 
 		rt.et.Status.Steps[i].Phase = types.ExecutorTaskPhaseSuccess
 
@@ -973,18 +1961,22 @@ func (e *Executor) executeTaskSteps(ctx context.Context, rt *runningTask, pod dr
 		} else if exitCode == 0 {
 			rt.et.Status.Steps[i].ExitStatus = util.IntP(exitCode)
 		}
+		if serr != nil && stepTimedOut {
+			rt.et.Status.FailError = fmt.Sprintf("step %q timed out after %s", stepName, stepTimeout)
+		}
 
 		if err := e.sendExecutorTaskStatus(ctx, rt.et); err != nil {
 			e.log.Err(err).Send()
 		}
 		rt.Unlock()
 
-		if serr != nil {
-			return i, errors.WithStack(serr)
+		if serr != nil && ferr == nil {
+			failedStep = i
+			ferr = errors.WithStack(serr)
 		}
 	}
 
-	return 0, nil
+	return failedStep, ferr
 }
 
 func (e *Executor) podsCleanerLoop(ctx context.Context) {
@@ -1047,9 +2039,16 @@ func (e *Executor) executorStatusSenderLoop(ctx context.Context) {
 	for {
 		e.log.Debug().Msgf("executorStatusSenderLoop")
 
-		if err := e.sendExecutorStatus(ctx); err != nil {
+		deregistered, err := e.sendExecutorStatus(ctx)
+		if err != nil {
 			e.log.Err(err).Send()
 		}
+		if deregistered {
+			// stop sending the executor status or the executor will be
+			// registered again
+			e.log.Info().Msgf("executor drained and deregistered, it can be safely stopped")
+			return
+		}
 
 		sleepCh := time.NewTimer(2 * time.Second).C
 		select {
@@ -1347,19 +2346,99 @@ func (e *Executor) saveExecutorID(id string) error {
 	return nil
 }
 
+func (e *Executor) getExecutorToken() (string, error) {
+	token, err := ioutil.ReadFile(e.executorTokenPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", errors.WithStack(err)
+	}
+	return string(token), nil
+}
+
+func (e *Executor) saveExecutorToken(token string) error {
+	if err := common.WriteFileAtomic(e.executorTokenPath(), []byte(token), 0600); err != nil {
+		return errors.Wrapf(err, "failed to write executor token file")
+	}
+	return nil
+}
+
+// executorTokenTransport adds the executor id and registration token to the
+// requests to the runservice
+type executorTokenTransport struct {
+	id    string
+	token string
+	base  http.RoundTripper
+}
+
+func (t *executorTokenTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// the RoundTripper must not modify the request
+	r = r.Clone(r.Context())
+	r.Header.Set(rsapitypes.ExecutorIDHeader, t.id)
+	r.Header.Set(rsapitypes.ExecutorTokenHeader, t.token)
+
+	return t.base.RoundTrip(r)
+}
+
 type Executor struct {
 	log              zerolog.Logger
 	c                *config.Executor
+	serviceAuth      *scommon.ServiceAuth
 	runserviceClient *rsclient.Client
+	uploadLimiter    *rate.Limiter
+	downloadLimiter  *rate.Limiter
+	defaultResources driver.Resources
+	maxResources     driver.Resources
+	imagePolicy      *imagePolicy
 	id               string
 	runningTasks     *runningTasks
 	driver           driver.Driver
-	listenAddress    string
+	listenAddresses  []string
 	listenURL        string
 	dynamic          bool
+	// runtimeType is the task runtime type supported by the driver
+	runtimeType types.RuntimeType
+	// os is the os of the containers executed by the driver
+	os stypes.OS
+
+	// health is the last collected executor health, refreshed every
+	// healthInterval by the executor status loop
+	health     *types.ExecutorHealth
+	healthTime time.Time
+}
+
+func genK8sDriverConfig(c *config.K8s) (*driver.K8sConfig, error) {
+	k8sConfig := &driver.K8sConfig{
+		NodeSelector:   c.NodeSelector,
+		ServiceAccount: c.ServiceAccount,
+	}
+	for _, t := range c.Tolerations {
+		k8sConfig.Tolerations = append(k8sConfig.Tolerations, corev1.Toleration{
+			Key:               t.Key,
+			Operator:          corev1.TolerationOperator(t.Operator),
+			Value:             t.Value,
+			Effect:            corev1.TaintEffect(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+	if c.Affinity != "" {
+		k8sConfig.Affinity = &corev1.Affinity{}
+		if err := yaml.Unmarshal([]byte(c.Affinity), k8sConfig.Affinity); err != nil {
+			return nil, errors.Wrapf(err, "invalid affinity")
+		}
+	}
+	if c.PodTemplatePatch != "" {
+		patch, err := yaml.YAMLToJSON([]byte(c.PodTemplatePatch))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pod template patch")
+		}
+		k8sConfig.PodTemplatePatch = patch
+	}
+
+	return k8sConfig, nil
 }
 
-func NewExecutor(ctx context.Context, log zerolog.Logger, c *config.Executor) (*Executor, error) {
+func NewExecutor(ctx context.Context, log zerolog.Logger, gc *config.Config) (*Executor, error) {
+	c := &gc.Executor
+
 	if c.Debug {
 		log = log.Level(zerolog.DebugLevel)
 	}
@@ -1370,13 +2449,34 @@ func NewExecutor(ctx context.Context, log zerolog.Logger, c *config.Executor) (*
 		return nil, errors.Wrapf(err, "cannot determine \"agola-toolbox\" absolute path")
 	}
 
+	serviceAuth, err := scommon.NewServiceAuth(&gc.InternalServicesAuth, scommon.ServiceExecutor)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	runserviceClient := rsclient.NewClient(c.RunserviceURL)
+
 	e := &Executor{
 		log:              log,
 		c:                c,
-		runserviceClient: rsclient.NewClient(c.RunserviceURL),
+		serviceAuth:      serviceAuth,
+		runserviceClient: runserviceClient,
+		uploadLimiter:    util.NewBandwidthLimiter(c.TransferBandwidthLimits.Upload),
+		downloadLimiter:  util.NewBandwidthLimiter(c.TransferBandwidthLimits.Download),
 		runningTasks: &runningTasks{
 			tasks: make(map[string]*runningTask),
 		},
+		runtimeType: types.RuntimeTypePod,
+	}
+
+	if e.defaultResources, err = parseContainerResources(&c.DefaultContainerResources); err != nil {
+		return nil, errors.Wrapf(err, "wrong default container resources")
+	}
+	if e.maxResources, err = parseContainerResources(&c.MaxContainerResources); err != nil {
+		return nil, errors.Wrapf(err, "wrong max container resources")
+	}
+	if e.imagePolicy, err = newImagePolicy(&c.ImagePolicy); err != nil {
+		return nil, errors.Wrapf(err, "wrong image policy")
 	}
 
 	if err := os.MkdirAll(e.tasksDir(), 0770); err != nil {
@@ -1396,8 +2496,28 @@ func NewExecutor(ctx context.Context, log zerolog.Logger, c *config.Executor) (*
 
 	e.id = id
 
+	// the registration token identifies the executor to the runservice
+	token, err := e.getExecutorToken()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if token == "" {
+		token = util.EncodeSha256Hex(uuid.Must(uuid.NewV4()).String())
+		if err := e.saveExecutorToken(token); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	httpClient := serviceAuth.HTTPClient(scommon.ServiceRunservice)
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	httpClient.Transport = &executorTokenTransport{id: id, token: token, base: transport}
+	runserviceClient.SetHTTPClient(httpClient)
+
 	// TODO(sgotti) now the first available private ip will be used and the executor will bind to the wildcard address
-	// improve this to let the user define the bind and the advertize address
+	// (on every configured listen address port) improve this to let the user define the bind and the advertize address
 	addr, err := sockaddr.GetPrivateIP()
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot discover executor listen address")
@@ -1409,15 +2529,26 @@ func NewExecutor(ctx context.Context, log zerolog.Logger, c *config.Executor) (*
 	if c.Web.TLS {
 		u.Scheme = "https"
 	}
-	_, port, err := net.SplitHostPort(c.Web.ListenAddress)
-	if err != nil {
-		return nil, errors.Wrapf(err, "cannot get web listen port")
+	listenAddresses := c.Web.Addresses()
+	if len(listenAddresses) == 0 {
+		return nil, errors.Errorf("listen address undefined")
+	}
+	for _, listenAddress := range listenAddresses {
+		_, port, err := net.SplitHostPort(listenAddress)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get web listen port")
+		}
+		if !util.StringInSlice(e.listenAddresses, ":"+port) {
+			e.listenAddresses = append(e.listenAddresses, ":"+port)
+		}
 	}
+
+	// advertise the first listen address port. net.JoinHostPort will
+	// correctly enclose an IPv6 address in square brackets
+	_, port, _ := net.SplitHostPort(listenAddresses[0])
 	u.Host = net.JoinHostPort(addr, port)
 	e.listenURL = u.String()
 
-	e.listenAddress = fmt.Sprintf(":%s", port)
-
 	var initDockerConfig *registry.DockerConfig
 
 	if e.c.InitImage.Auth != nil {
@@ -1446,11 +2577,41 @@ func NewExecutor(ctx context.Context, log zerolog.Logger, c *config.Executor) (*
 			return nil, errors.Wrapf(err, "failed to create docker driver")
 		}
 	case config.DriverTypeK8s:
-		d, err = driver.NewK8sDriver(log, e.id, c.ToolboxPath, e.c.InitImage.Image, initDockerConfig)
+		k8sConfig, err := genK8sDriverConfig(&c.Driver.K8s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "wrong kubernetes driver config")
+		}
+		d, err = driver.NewK8sDriver(log, e.id, c.ToolboxPath, e.c.InitImage.Image, initDockerConfig, k8sConfig)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to create kubernetes driver")
 		}
 		e.dynamic = true
+	case config.DriverTypeLXD:
+		d, err = driver.NewLXDDriver(log, e.id, e.c.ToolboxPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create lxd driver")
+		}
+	case config.DriverTypeHost:
+		d, err = driver.NewHostDriver(log, e.id, e.c.ToolboxPath, filepath.Join(e.c.DataDir, "host"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create host driver")
+		}
+		e.runtimeType = types.RuntimeTypeHost
+	case config.DriverTypeFirecracker:
+		fc := c.Driver.Firecracker
+		firecrackerConfig := &driver.FirecrackerConfig{
+			BinaryPath:  fc.BinaryPath,
+			KernelImage: fc.KernelImage,
+			KernelArgs:  fc.KernelArgs,
+			RootfsImage: fc.RootfsImage,
+			VCPUs:       fc.VCPUs,
+			MemoryMiB:   fc.MemoryMiB,
+			TapDevices:  fc.TapDevices,
+		}
+		d, err = driver.NewFirecrackerDriver(log, e.id, e.c.ToolboxPath, filepath.Join(e.c.DataDir, "firecracker"), firecrackerConfig)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create firecracker driver")
+		}
 	default:
 		return nil, errors.Errorf("unknown driver type %q", c.Driver.Type)
 	}
@@ -1463,6 +2624,11 @@ func (e *Executor) Run(ctx context.Context) error {
 	if err := e.driver.Setup(ctx); err != nil {
 		return errors.WithStack(err)
 	}
+	containerOS, err := e.driver.OS(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	e.os = containerOS
 
 	ch := make(chan *types.ExecutorTask)
 	schedulerHandler := NewTaskSubmissionHandler(ch)
@@ -1484,17 +2650,17 @@ func (e *Executor) Run(ctx context.Context) error {
 
 	go e.handleTasks(ctx, ch)
 
+	// only the runservice can call the executor api
+	serviceAuthorizations := scommon.ServiceAuthorizations{
+		scommon.ServiceRunservice: nil,
+	}
+
 	httpServer := http.Server{
-		Addr:    e.listenAddress,
-		Handler: apirouter,
+		Handler: e.serviceAuth.NewServiceAuthHandler(e.log, serviceAuthorizations, apirouter),
 	}
 	lerrCh := make(chan error)
 	go func() {
-		if !e.c.Web.TLS {
-			lerrCh <- httpServer.ListenAndServe()
-		} else {
-			lerrCh <- httpServer.ListenAndServeTLS("", "")
-		}
+		lerrCh <- util.ListenAndServe(&httpServer, e.listenAddresses, e.c.Web.TLS)
 	}()
 
 	select {
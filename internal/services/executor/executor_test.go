@@ -0,0 +1,124 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"testing"
+
+	"agola.io/agola/services/runservice/types"
+	stypes "agola.io/agola/services/types"
+)
+
+func TestStepWorkingDir(t *testing.T) {
+	tests := []struct {
+		name           string
+		os             stypes.OS
+		taskWorkingDir string
+		stepWorkingDir string
+		out            string
+	}{
+		{
+			name:           "no step working dir",
+			os:             stypes.OSLinux,
+			taskWorkingDir: "~/project",
+			out:            "~/project",
+		},
+		{
+			name:           "relative step working dir",
+			os:             stypes.OSLinux,
+			taskWorkingDir: "~/project",
+			stepWorkingDir: "frontend/",
+			out:            "~/project/frontend",
+		},
+		{
+			name:           "absolute step working dir",
+			os:             stypes.OSLinux,
+			taskWorkingDir: "~/project",
+			stepWorkingDir: "/tmp/build",
+			out:            "/tmp/build",
+		},
+		{
+			name:           "home relative step working dir",
+			os:             stypes.OSLinux,
+			taskWorkingDir: "/src",
+			stepWorkingDir: "~/build",
+			out:            "~/build",
+		},
+		{
+			name:           "step working dir starting with an environment variable",
+			os:             stypes.OSLinux,
+			taskWorkingDir: "/src",
+			stepWorkingDir: "$GOPATH/src",
+			out:            "$GOPATH/src",
+		},
+		{
+			name:           "windows relative step working dir",
+			os:             stypes.OSWindows,
+			taskWorkingDir: `C:\project\`,
+			stepWorkingDir: "frontend",
+			out:            `C:\project\frontend`,
+		},
+		{
+			name:           "windows absolute step working dir",
+			os:             stypes.OSWindows,
+			taskWorkingDir: `C:\project`,
+			stepWorkingDir: `D:\build`,
+			out:            `D:\build`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Executor{os: tt.os}
+			et := &types.ExecutorTask{Spec: types.ExecutorTaskSpec{ExecutorTaskSpecData: &types.ExecutorTaskSpecData{WorkingDir: tt.taskWorkingDir}}}
+			s := &types.RunStep{WorkingDir: tt.stepWorkingDir}
+
+			if out := e.stepWorkingDir(et, s); out != tt.out {
+				t.Fatalf("expected working dir %q, got %q", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestCheckCacheKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		ok   bool
+	}{
+		{name: "simple key", key: "cache-0123abcd", ok: true},
+		{name: "key with branch path separators", key: "cache-feature/new-ui-0123abcd", ok: true},
+		{name: "key with multiple path elements", key: "deps/feature/new-ui/linux", ok: true},
+		{name: "empty key", key: "", ok: false},
+		{name: "key with leading path separator", key: "/cache", ok: false},
+		{name: "key with trailing path separator", key: "cache/", ok: false},
+		{name: "key with empty path element", key: "cache//feature", ok: false},
+		{name: "key with dot path element", key: "cache/./feature", ok: false},
+		{name: "key with parent path element", key: "cache/../../runs/run01", ok: false},
+		{name: "parent path key", key: "..", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCacheKey(tt.key)
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatalf("expected error for cache key %q", tt.key)
+			}
+		})
+	}
+}
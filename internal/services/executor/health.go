@@ -0,0 +1,87 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/services/runservice/types"
+)
+
+const (
+	// healthInterval is the interval between the collections of the executor
+	// health since computing the images size could be expensive
+	healthInterval = 1 * time.Minute
+)
+
+// executorHealth returns the executor health reported to the runservice.
+// Collection errors are logged and the related values are left empty.
+func (e *Executor) executorHealth(ctx context.Context) *types.ExecutorHealth {
+	if e.health != nil && time.Since(e.healthTime) < healthInterval {
+		return e.health
+	}
+
+	health := &types.ExecutorHealth{
+		CPUs: runtime.NumCPU(),
+	}
+
+	var err error
+	health.DiskTotal, health.DiskFree, err = diskUsage(e.c.DataDir)
+	if err != nil {
+		e.log.Warn().Err(err).Msgf("failed to get data dir disk usage")
+	}
+	health.ImagesSize, err = e.driver.ImagesSize(ctx)
+	if err != nil {
+		e.log.Warn().Err(err).Msgf("failed to get driver images size")
+	}
+	health.Load1, err = loadAverage()
+	if err != nil {
+		e.log.Debug().Err(err).Msgf("failed to get load average")
+	}
+
+	e.health = health
+	e.healthTime = time.Now()
+
+	return health
+}
+
+// loadAverage returns the 1 minute load average. It's available only on linux
+func loadAverage() (float64, error) {
+	data, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	return parseLoadAverage(string(data))
+}
+
+func parseLoadAverage(s string) (float64, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, errors.Errorf("empty load average")
+	}
+	load1, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "wrong load average %q", fields[0])
+	}
+
+	return load1, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"regexp"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/services/config"
+	"agola.io/agola/internal/services/executor/registry"
+	"agola.io/agola/internal/util"
+
+	"github.com/bmatcuk/doublestar"
+)
+
+// imagePattern matches an image using a glob or a regular expression
+type imagePattern struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+func newImagePattern(pattern string) (*imagePattern, error) {
+	p := &imagePattern{pattern: pattern}
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid image pattern %q", pattern)
+		}
+		p.re = re
+		return p, nil
+	}
+	// matching the pattern against itself reports syntax errors
+	if _, err := doublestar.Match(pattern, pattern); err != nil {
+		return nil, errors.Wrapf(err, "invalid image pattern %q", pattern)
+	}
+
+	return p, nil
+}
+
+func (p *imagePattern) match(image string) bool {
+	if p.re != nil {
+		return p.re.MatchString(image)
+	}
+	// the pattern has already been validated
+	ok, _ := doublestar.Match(p.pattern, image)
+	return ok
+}
+
+type imagePolicy struct {
+	allowedRegistries []string
+	allowedImages     []*imagePattern
+	deniedImages      []*imagePattern
+}
+
+func newImagePolicy(c *config.ImagePolicy) (*imagePolicy, error) {
+	p := &imagePolicy{
+		allowedRegistries: c.AllowedRegistries,
+	}
+	for _, pattern := range c.AllowedImages {
+		ip, err := newImagePattern(pattern)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		p.allowedImages = append(p.allowedImages, ip)
+	}
+	for _, pattern := range c.DeniedImages {
+		ip, err := newImagePattern(pattern)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		p.deniedImages = append(p.deniedImages, ip)
+	}
+
+	return p, nil
+}
+
+// check returns an error if the image isn't allowed by the policy
+func (p *imagePolicy) check(image string) error {
+	if len(p.allowedRegistries) > 0 {
+		regName, err := registry.GetRegistry(image)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if !util.StringInSlice(p.allowedRegistries, regName) {
+			return errors.Errorf("registry %q isn't allowed", regName)
+		}
+	}
+
+	for _, ip := range p.deniedImages {
+		if ip.match(image) {
+			return errors.Errorf("image matches denied pattern %q", ip.pattern)
+		}
+	}
+
+	if len(p.allowedImages) == 0 {
+		return nil
+	}
+	for _, ip := range p.allowedImages {
+		if ip.match(image) {
+			return nil
+		}
+	}
+
+	return errors.Errorf("image doesn't match any allowed pattern")
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"testing"
+
+	"agola.io/agola/internal/services/config"
+)
+
+func TestImagePolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy config.ImagePolicy
+		image  string
+		err    bool
+	}{
+		{
+			name:  "empty policy",
+			image: "alpine:3.15",
+		},
+		{
+			name: "allowed registry",
+			policy: config.ImagePolicy{
+				AllowedRegistries: []string{"registry.example.com"},
+			},
+			image: "registry.example.com/project/image:v1",
+		},
+		{
+			name: "not allowed registry",
+			policy: config.ImagePolicy{
+				AllowedRegistries: []string{"registry.example.com"},
+			},
+			image: "alpine:3.15",
+			err:   true,
+		},
+		{
+			name: "docker hub registry",
+			policy: config.ImagePolicy{
+				AllowedRegistries: []string{"index.docker.io"},
+			},
+			image: "alpine:3.15",
+		},
+		{
+			name: "image matching allowed glob",
+			policy: config.ImagePolicy{
+				AllowedImages: []string{"golang:*", "registry.example.com/**"},
+			},
+			image: "registry.example.com/project/image:v1",
+		},
+		{
+			name: "image not matching allowed glob",
+			policy: config.ImagePolicy{
+				AllowedImages: []string{"golang:*"},
+			},
+			image: "alpine:3.15",
+			err:   true,
+		},
+		{
+			name: "image matching allowed regexp",
+			policy: config.ImagePolicy{
+				AllowedImages: []string{`/^alpine:3\.1[0-9]$/`},
+			},
+			image: "alpine:3.15",
+		},
+		{
+			name: "image matching allowed and denied patterns",
+			policy: config.ImagePolicy{
+				AllowedImages: []string{"golang:*"},
+				DeniedImages:  []string{"golang:1.1?"},
+			},
+			image: "golang:1.12",
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newImagePolicy(&tt.policy)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			err = p.check(tt.image)
+			if tt.err && err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/services/runservice/types"
+)
+
+const (
+	logChunksUploadInterval = 5 * time.Second
+	// maxLogChunkSize is the max size of an uploaded log chunk, it must not be
+	// greater than the one accepted by the runservice
+	maxLogChunkSize = 1024 * 1024
+)
+
+// logChunksUploader incrementally uploads the task logs to the runservice
+// while the task is executing, so they are kept also if the executor is lost
+// before the runservice fetches them
+type logChunksUploader struct {
+	e     *Executor
+	etID  string
+	steps int
+
+	// setupOffset and stepsOffsets are the already uploaded logs sizes
+	setupOffset  int64
+	stepsOffsets []int64
+}
+
+func (e *Executor) logChunksUploaderLoop(ctx context.Context, et *types.ExecutorTask, stopCh <-chan struct{}) {
+	u := &logChunksUploader{
+		e:            e,
+		etID:         et.ID,
+		steps:        len(et.Spec.Steps),
+		stepsOffsets: make([]int64, len(et.Spec.Steps)),
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-time.After(logChunksUploadInterval):
+		}
+
+		u.upload(ctx)
+	}
+}
+
+func (u *logChunksUploader) upload(ctx context.Context) {
+	var err error
+	u.setupOffset, err = u.uploadLog(ctx, true, 0, u.setupOffset)
+	if err != nil {
+		u.e.log.Warn().Err(err).Msgf("failed to upload task %q setup log chunk", u.etID)
+	}
+	for i := 0; i < u.steps; i++ {
+		u.stepsOffsets[i], err = u.uploadLog(ctx, false, i, u.stepsOffsets[i])
+		if err != nil {
+			u.e.log.Warn().Err(err).Msgf("failed to upload task %q step %d log chunk", u.etID, i)
+		}
+	}
+}
+
+// uploadLog uploads the log data after offset and returns the new uploaded
+// size
+func (u *logChunksUploader) uploadLog(ctx context.Context, setup bool, step int, offset int64) (int64, error) {
+	var logPath string
+	if setup {
+		logPath = u.e.setupLogPath(u.etID)
+	} else {
+		logPath = u.e.stepLogPath(u.etID, step)
+	}
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		// step not yet started
+		if os.IsNotExist(err) {
+			return offset, nil
+		}
+		return offset, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return offset, errors.WithStack(err)
+	}
+
+	for offset < fi.Size() {
+		size := fi.Size() - offset
+		if size > maxLogChunkSize {
+			size = maxLogChunkSize
+		}
+		sr := io.NewSectionReader(f, offset, size)
+		if _, err := u.e.runserviceClient.SendExecutorTaskLogChunk(ctx, u.e.id, u.etID, setup, step, offset, size, sr); err != nil {
+			return offset, errors.WithStack(err)
+		}
+		offset += size
+	}
+
+	return offset, nil
+}
@@ -53,10 +53,12 @@ type DockerConfig struct {
 }
 
 // Docker config represents the docker config.json auth part. We only consider the "auth" token part
+// and the registry bearer token
 type DockerConfigAuth struct {
-	Username string `json:"username,omitempty"`
-	Password string `json:"password,omitempty"`
-	Auth     string `json:"auth,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	Auth          string `json:"auth,omitempty"`
+	RegistryToken string `json:"registrytoken,omitempty"`
 }
 
 // There are a variety of ways a domain may get qualified within the Docker credential file.
@@ -93,8 +95,8 @@ func GetRegistry(image string) (string, error) {
 	return regName, nil
 }
 
-// ResolveAuth resolves the auth username and password for the provided registry name
-func ResolveAuth(auths map[string]types.DockerRegistryAuth, regname string) (string, string, error) {
+// ResolveAuth resolves the auth for the provided registry name
+func ResolveAuth(auths map[string]types.DockerRegistryAuth, regname string) (DockerConfigAuth, error) {
 	if auths != nil {
 		for _, form := range domainForms {
 			if auth, ok := auths[fmt.Sprintf(form, regname)]; ok {
@@ -102,23 +104,31 @@ func ResolveAuth(auths map[string]types.DockerRegistryAuth, regname string) (str
 				case types.DockerRegistryAuthTypeEncodedAuth:
 					decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
 					if err != nil {
-						return "", "", errors.Wrapf(err, "failed to decode docker auth")
+						return DockerConfigAuth{}, errors.Wrapf(err, "failed to decode docker auth")
 					}
 					parts := strings.Split(string(decoded), ":")
 					if len(parts) != 2 {
-						return "", "", errors.Wrapf(err, "wrong docker auth")
+						return DockerConfigAuth{}, errors.Wrapf(err, "wrong docker auth")
 					}
-					return parts[0], parts[1], nil
+					return basicDockerConfigAuth(parts[0], parts[1]), nil
 				case types.DockerRegistryAuthTypeBasic:
-					return auth.Username, auth.Password, nil
+					return basicDockerConfigAuth(auth.Username, auth.Password), nil
+				case types.DockerRegistryAuthTypeToken:
+					return DockerConfigAuth{RegistryToken: auth.Token}, nil
 				default:
-					return "", "", errors.Errorf("unsupported auth type %q", auth.Type)
+					return DockerConfigAuth{}, errors.Errorf("unsupported auth type %q", auth.Type)
 				}
 			}
 		}
 	}
 
-	return "", "", nil
+	return basicDockerConfigAuth("", ""), nil
+}
+
+func basicDockerConfigAuth(username, password string) DockerConfigAuth {
+	delimited := fmt.Sprintf("%s:%s", username, password)
+	auth := base64.StdEncoding.EncodeToString([]byte(delimited))
+	return DockerConfigAuth{Username: username, Password: password, Auth: auth}
 }
 
 func GenDockerConfig(auths map[string]types.DockerRegistryAuth, images []string) (*DockerConfig, error) {
@@ -134,13 +144,11 @@ func GenDockerConfig(auths map[string]types.DockerRegistryAuth, images []string)
 			continue
 		}
 
-		username, password, err := ResolveAuth(auths, regName)
+		auth, err := ResolveAuth(auths, regName)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to resolve auth")
 		}
-		delimited := fmt.Sprintf("%s:%s", username, password)
-		auth := base64.StdEncoding.EncodeToString([]byte(delimited))
-		dockerConfig.Auths[regName] = DockerConfigAuth{Username: username, Password: password, Auth: auth}
+		dockerConfig.Auths[regName] = auth
 	}
 
 	return dockerConfig, nil
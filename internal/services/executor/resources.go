@@ -0,0 +1,111 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/services/config"
+	"agola.io/agola/internal/services/executor/driver"
+	"agola.io/agola/services/runservice/types"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func parseResourceList(rl *config.ContainerResourceList) (driver.ResourceList, error) {
+	var res driver.ResourceList
+	if rl.CPU != "" {
+		q, err := resource.ParseQuantity(rl.CPU)
+		if err != nil {
+			return res, errors.Wrapf(err, "invalid cpu quantity %q", rl.CPU)
+		}
+		res.CPU = q.MilliValue()
+	}
+	if rl.Memory != "" {
+		q, err := resource.ParseQuantity(rl.Memory)
+		if err != nil {
+			return res, errors.Wrapf(err, "invalid memory quantity %q", rl.Memory)
+		}
+		res.Memory = q.Value()
+	}
+
+	return res, nil
+}
+
+func parseContainerResources(r *config.ContainerResources) (driver.Resources, error) {
+	var res driver.Resources
+	var err error
+	if res.Requests, err = parseResourceList(&r.Requests); err != nil {
+		return res, errors.WithStack(err)
+	}
+	if res.Limits, err = parseResourceList(&r.Limits); err != nil {
+		return res, errors.WithStack(err)
+	}
+
+	return res, nil
+}
+
+// containerResourceList applies the default and checks the max of every
+// resource. When a max is defined an undefined resource gets the max value.
+func containerResourceList(kind string, rl types.ResourceList, def, max driver.ResourceList) (driver.ResourceList, error) {
+	res := driver.ResourceList{CPU: rl.CPU, Memory: rl.Memory}
+
+	if res.CPU == 0 {
+		res.CPU = def.CPU
+	}
+	if res.Memory == 0 {
+		res.Memory = def.Memory
+	}
+
+	if max.CPU != 0 {
+		if res.CPU == 0 {
+			res.CPU = max.CPU
+		}
+		if res.CPU > max.CPU {
+			return res, errors.Errorf("cpu %s %dm exceeds the executor max %dm", kind, res.CPU, max.CPU)
+		}
+	}
+	if max.Memory != 0 {
+		if res.Memory == 0 {
+			res.Memory = max.Memory
+		}
+		if res.Memory > max.Memory {
+			return res, errors.Errorf("memory %s %d exceeds the executor max %d", kind, res.Memory, max.Memory)
+		}
+	}
+
+	return res, nil
+}
+
+// containerResources returns the container resources after applying the
+// executor defaults and max.
+func (e *Executor) containerResources(r types.Resources) (driver.Resources, error) {
+	var res driver.Resources
+	var err error
+	if res.Requests, err = containerResourceList("request", r.Requests, e.defaultResources.Requests, e.maxResources.Requests); err != nil {
+		return res, errors.WithStack(err)
+	}
+	if res.Limits, err = containerResourceList("limit", r.Limits, e.defaultResources.Limits, e.maxResources.Limits); err != nil {
+		return res, errors.WithStack(err)
+	}
+
+	if res.Limits.CPU != 0 && res.Requests.CPU > res.Limits.CPU {
+		return res, errors.Errorf("cpu request %dm greater than cpu limit %dm", res.Requests.CPU, res.Limits.CPU)
+	}
+	if res.Limits.Memory != 0 && res.Requests.Memory > res.Limits.Memory {
+		return res, errors.Errorf("memory request %d greater than memory limit %d", res.Requests.Memory, res.Limits.Memory)
+	}
+
+	return res, nil
+}
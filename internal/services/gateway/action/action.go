@@ -15,7 +15,9 @@
 package action
 
 import (
+	"agola.io/agola/internal/lock"
 	"agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/util"
 	csclient "agola.io/agola/services/configstore/client"
 	rsclient "agola.io/agola/services/runservice/client"
 
@@ -27,19 +29,34 @@ type ActionHandler struct {
 	sd                *common.TokenSigningData
 	configstoreClient *csclient.Client
 	runserviceClient  *rsclient.Client
+	lf                lock.LockFactory
 	agolaID           string
 	apiExposedURL     string
 	webExposedURL     string
+	// configEnv are the environment variables readable by the run configs
+	configEnv map[string]string
+	// depsProxyURL is the dependencies proxy url reachable by the tasks
+	depsProxyURL string
+
+	// rsCache caches the remote sources by id and name
+	rsCache *util.TTLCache
+	// remoteInfoCache caches the git sources repositories and users info
+	remoteInfoCache *util.TTLCache
 }
 
-func NewActionHandler(log zerolog.Logger, sd *common.TokenSigningData, configstoreClient *csclient.Client, runserviceClient *rsclient.Client, agolaID, apiExposedURL, webExposedURL string) *ActionHandler {
+func NewActionHandler(log zerolog.Logger, sd *common.TokenSigningData, configstoreClient *csclient.Client, runserviceClient *rsclient.Client, lf lock.LockFactory, agolaID, apiExposedURL, webExposedURL string, configEnv map[string]string, depsProxyURL string) *ActionHandler {
 	return &ActionHandler{
 		log:               log,
 		sd:                sd,
 		configstoreClient: configstoreClient,
 		runserviceClient:  runserviceClient,
+		lf:                lf,
 		agolaID:           agolaID,
 		apiExposedURL:     apiExposedURL,
 		webExposedURL:     webExposedURL,
+		configEnv:         configEnv,
+		depsProxyURL:      depsProxyURL,
+		rsCache:           util.NewTTLCache(remoteSourceCacheTTL, cacheMaxEntries),
+		remoteInfoCache:   util.NewTTLCache(remoteInfoCacheTTL, cacheMaxEntries),
 	}
 }
@@ -0,0 +1,81 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/services/gateway/common"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	cstypes "agola.io/agola/services/configstore/types"
+)
+
+// GetAnnouncements returns the active announcements. All the announcements,
+// also the expired ones, can be requested only by an admin
+func (h *ActionHandler) GetAnnouncements(ctx context.Context, all bool) ([]*cstypes.Announcement, error) {
+	if all && !common.IsUserAdmin(ctx) {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not admin"))
+	}
+
+	announcements, _, err := h.configstoreClient.GetAnnouncements(ctx, !all)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	return announcements, nil
+}
+
+type CreateAnnouncementRequest struct {
+	Message    string
+	Severity   cstypes.AnnouncementSeverity
+	ExpireTime *time.Time
+}
+
+func (h *ActionHandler) CreateAnnouncement(ctx context.Context, req *CreateAnnouncementRequest) (*cstypes.Announcement, error) {
+	if !common.IsUserAdmin(ctx) {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not admin"))
+	}
+
+	creq := &csapitypes.CreateAnnouncementRequest{
+		Message:    req.Message,
+		Severity:   req.Severity,
+		ExpireTime: req.ExpireTime,
+	}
+
+	h.log.Info().Msgf("creating announcement")
+	announcement, _, err := h.configstoreClient.CreateAnnouncement(ctx, creq)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to create announcement"))
+	}
+	h.log.Info().Msgf("announcement %s created", announcement.ID)
+
+	return announcement, nil
+}
+
+func (h *ActionHandler) ExpireAnnouncement(ctx context.Context, announcementID string) (*cstypes.Announcement, error) {
+	if !common.IsUserAdmin(ctx) {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not admin"))
+	}
+
+	announcement, _, err := h.configstoreClient.ExpireAnnouncement(ctx, announcementID)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to expire announcement"))
+	}
+
+	return announcement, nil
+}
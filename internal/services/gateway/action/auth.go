@@ -22,6 +22,7 @@ import (
 	"agola.io/agola/internal/services/gateway/common"
 	"agola.io/agola/internal/util"
 	cstypes "agola.io/agola/services/configstore/types"
+	rstypes "agola.io/agola/services/runservice/types"
 )
 
 func (h *ActionHandler) IsOrgOwner(ctx context.Context, orgID string) (bool, error) {
@@ -145,8 +146,21 @@ func (h *ActionHandler) IsVariableOwner(ctx context.Context, parentType cstypes.
 	return h.IsProjectOwner(ctx, ownerType, ownerID)
 }
 
+// CanGetRun reports if the current user can read the runs results of the
+// provided group
 func (h *ActionHandler) CanGetRun(ctx context.Context, groupType scommon.GroupType, ref string) (bool, string, error) {
+	return h.canReadRuns(ctx, groupType, ref, false)
+}
+
+// CanGetRunLogs reports if the current user can read the runs logs of the
+// provided group
+func (h *ActionHandler) CanGetRunLogs(ctx context.Context, groupType scommon.GroupType, ref string) (bool, string, error) {
+	return h.canReadRuns(ctx, groupType, ref, true)
+}
+
+func (h *ActionHandler) canReadRuns(ctx context.Context, groupType scommon.GroupType, ref string, logs bool) (bool, string, error) {
 	var visibility cstypes.Visibility
+	var runsVisibility cstypes.RunsVisibility
 	var ownerType cstypes.ObjectKind
 	var refID string
 	var ownerID string
@@ -160,6 +174,10 @@ func (h *ActionHandler) CanGetRun(ctx context.Context, groupType scommon.GroupTy
 		ownerID = p.OwnerID
 		ownerType = p.OwnerType
 		visibility = p.GlobalVisibility
+		runsVisibility = p.RunsVisibility
+		if logs {
+			runsVisibility = p.LogsVisibility
+		}
 	case scommon.GroupTypeUser:
 		u, _, err := h.configstoreClient.GetUser(ctx, ref)
 		if err != nil {
@@ -173,50 +191,133 @@ func (h *ActionHandler) CanGetRun(ctx context.Context, groupType scommon.GroupTy
 		visibility = cstypes.VisibilityPrivate
 	}
 
-	if visibility == cstypes.VisibilityPublic {
-		return true, refID, nil
+	runsVisibility = effectiveRunsVisibility(visibility, runsVisibility)
+
+	var allowed bool
+	var err error
+	switch runsVisibility {
+	case cstypes.RunsVisibilityPublic:
+		allowed = true
+	case cstypes.RunsVisibilityMembers:
+		allowed, err = h.IsProjectMember(ctx, ownerType, ownerID)
+	case cstypes.RunsVisibilityOwners:
+		allowed, err = h.IsProjectOwner(ctx, ownerType, ownerID)
 	}
-	isProjectMember, err := h.IsProjectMember(ctx, ownerType, ownerID)
 	if err != nil {
 		return false, "", errors.Wrapf(err, "failed to determine ownership")
 	}
-	if !isProjectMember {
+	if !allowed {
 		return false, "", nil
 	}
 	return true, refID, nil
 }
 
+// effectiveRunsVisibility returns the runs visibility to apply, resolving an
+// empty runs visibility from the project visibility
+func effectiveRunsVisibility(visibility cstypes.Visibility, runsVisibility cstypes.RunsVisibility) cstypes.RunsVisibility {
+	if runsVisibility != cstypes.RunsVisibilityProject {
+		return runsVisibility
+	}
+	if visibility == cstypes.VisibilityPublic {
+		return cstypes.RunsVisibilityPublic
+	}
+	return cstypes.RunsVisibilityMembers
+}
+
 func (h *ActionHandler) CanDoRunActions(ctx context.Context, groupType scommon.GroupType, ref string) (bool, string, error) {
-	var ownerType cstypes.ObjectKind
-	var refID string
-	var ownerID string
+	refID, ownerType, ownerID, err := h.runGroupOwner(ctx, groupType, ref)
+	if err != nil {
+		return false, "", err
+	}
+
+	isProjectOwner, err := h.IsProjectOwner(ctx, ownerType, ownerID)
+	if err != nil {
+		return false, "", errors.Wrapf(err, "failed to determine ownership")
+	}
+	if !isProjectOwner {
+		return false, "", nil
+	}
+	return true, refID, nil
+}
+
+// CanApproveRunTask reports if the current user can approve the run task. When
+// the task doesn't restrict its approvers only the project owners can approve
+// it, otherwise the users with the listed names or with the listed project
+// organization roles
+func (h *ActionHandler) CanApproveRunTask(ctx context.Context, groupType scommon.GroupType, ref string, rct *rstypes.RunConfigTask) (bool, error) {
+	_, ownerType, ownerID, err := h.runGroupOwner(ctx, groupType, ref)
+	if err != nil {
+		return false, err
+	}
+
+	if len(rct.ApprovalUsers) == 0 && len(rct.ApprovalOrgRoles) == 0 {
+		isProjectOwner, err := h.IsProjectOwner(ctx, ownerType, ownerID)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to determine ownership")
+		}
+		return isProjectOwner, nil
+	}
+
+	if common.IsUserAdmin(ctx) {
+		return true, nil
+	}
+
+	userID := common.CurrentUserID(ctx)
+	if userID == "" {
+		return false, nil
+	}
+
+	if len(rct.ApprovalUsers) > 0 {
+		user, _, err := h.configstoreClient.GetUser(ctx, userID)
+		if err != nil {
+			return false, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q", userID))
+		}
+		for _, userName := range rct.ApprovalUsers {
+			if userName == user.Name {
+				return true, nil
+			}
+		}
+	}
+
+	if len(rct.ApprovalOrgRoles) > 0 && ownerType == cstypes.ObjectKindOrg {
+		userOrgs, _, err := h.configstoreClient.GetUserOrgs(ctx, userID)
+		if err != nil {
+			return false, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user orgs"))
+		}
+
+		for _, userOrg := range userOrgs {
+			if userOrg.Organization.ID != ownerID {
+				continue
+			}
+			for _, role := range rct.ApprovalOrgRoles {
+				if cstypes.MemberRole(role) == userOrg.Role {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// runGroupOwner returns the id and the owner of the runs base group
+func (h *ActionHandler) runGroupOwner(ctx context.Context, groupType scommon.GroupType, ref string) (string, cstypes.ObjectKind, string, error) {
 	switch groupType {
 	case scommon.GroupTypeProject:
 		p, _, err := h.configstoreClient.GetProject(ctx, ref)
 		if err != nil {
-			return false, "", util.NewAPIError(util.KindFromRemoteError(err), err)
+			return "", "", "", util.NewAPIError(util.KindFromRemoteError(err), err)
 		}
-		refID = p.ID
-		ownerType = p.OwnerType
-		ownerID = p.OwnerID
+		return p.ID, p.OwnerType, p.OwnerID, nil
 	case scommon.GroupTypeUser:
 		u, _, err := h.configstoreClient.GetUser(ctx, ref)
 		if err != nil {
-			return false, "", util.NewAPIError(util.KindFromRemoteError(err), err)
+			return "", "", "", util.NewAPIError(util.KindFromRemoteError(err), err)
 		}
 
 		// user direct runs
-		refID = u.ID
-		ownerType = cstypes.ObjectKindUser
-		ownerID = u.ID
+		return u.ID, cstypes.ObjectKindUser, u.ID, nil
 	}
 
-	isProjectOwner, err := h.IsProjectOwner(ctx, ownerType, ownerID)
-	if err != nil {
-		return false, "", errors.Wrapf(err, "failed to determine ownership")
-	}
-	if !isProjectOwner {
-		return false, "", nil
-	}
-	return true, refID, nil
+	return "", "", "", errors.Errorf("unknown group type %q", groupType)
 }
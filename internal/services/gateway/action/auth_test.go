@@ -0,0 +1,187 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	scommon "agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/services/gateway/common"
+	"agola.io/agola/internal/testutil"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	csclient "agola.io/agola/services/configstore/client"
+	cstypes "agola.io/agola/services/configstore/types"
+	stypes "agola.io/agola/services/types"
+)
+
+// runsVisibilityTestConfigstore fakes the configstore api calls done to check
+// the runs visibility of an organization project
+type runsVisibilityTestConfigstore struct {
+	mu      sync.Mutex
+	project *csapitypes.Project
+}
+
+func (s *runsVisibilityTestConfigstore) server(t *testing.T) *httptest.Server {
+	org := &cstypes.Organization{ObjectMeta: stypes.ObjectMeta{ID: "org01"}, Name: "org01"}
+	usersOrgs := map[string][]*csapitypes.UserOrgsResponse{
+		"owner01":  {{Organization: org, Role: cstypes.MemberRoleOwner}},
+		"member01": {{Organization: org, Role: cstypes.MemberRoleMember}},
+		"user01":   {},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/projects/"+s.project.ID:
+			writeTestJSON(t, w, http.StatusOK, s.project)
+		case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/api/v1alpha/users/") && strings.HasSuffix(r.URL.Path, "/orgs"):
+			userOrgs, ok := usersOrgs[strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1alpha/users/"), "/orgs")]
+			if !ok {
+				writeTestJSON(t, w, http.StatusNotFound, map[string]string{"message": "user doesn't exist"})
+				return
+			}
+			writeTestJSON(t, w, http.StatusOK, userOrgs)
+		default:
+			t.Errorf("unexpected configstore request %s %s", r.Method, r.URL.Path)
+			writeTestJSON(t, w, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func (s *runsVisibilityTestConfigstore) setProject(visibility cstypes.Visibility, runsVisibility, logsVisibility cstypes.RunsVisibility) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.project.GlobalVisibility = visibility
+	s.project.RunsVisibility = runsVisibility
+	s.project.LogsVisibility = logsVisibility
+}
+
+func TestRunsVisibility(t *testing.T) {
+	log := testutil.NewLogger(t)
+
+	s := &runsVisibilityTestConfigstore{
+		project: &csapitypes.Project{
+			Project:   &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project01"}, Name: "project01"},
+			OwnerType: cstypes.ObjectKindOrg,
+			OwnerID:   "org01",
+		},
+	}
+	h := NewActionHandler(log, nil, csclient.NewClient(s.server(t).URL), nil, nil, "agola", "", "", nil, "")
+
+	userCtx := func(userID string) context.Context {
+		return context.WithValue(context.Background(), common.ContextKeyUserID, userID)
+	}
+	ctxs := map[string]context.Context{
+		"anonymous": context.Background(),
+		"user":      userCtx("user01"),
+		"member":    userCtx("member01"),
+		"owner":     userCtx("owner01"),
+		"admin":     context.WithValue(userCtx("user01"), common.ContextKeyUserAdmin, true),
+	}
+
+	tests := []struct {
+		name           string
+		visibility     cstypes.Visibility
+		runsVisibility cstypes.RunsVisibility
+		logsVisibility cstypes.RunsVisibility
+		// users that can read the runs and the logs
+		runsReaders []string
+		logsReaders []string
+	}{
+		{
+			name:        "public project with project runs visibility",
+			visibility:  cstypes.VisibilityPublic,
+			runsReaders: []string{"anonymous", "user", "member", "owner", "admin"},
+			logsReaders: []string{"anonymous", "user", "member", "owner", "admin"},
+		},
+		{
+			name:        "private project with project runs visibility",
+			visibility:  cstypes.VisibilityPrivate,
+			runsReaders: []string{"member", "owner", "admin"},
+			logsReaders: []string{"member", "owner", "admin"},
+		},
+		{
+			name:           "public project with members logs visibility",
+			visibility:     cstypes.VisibilityPublic,
+			logsVisibility: cstypes.RunsVisibilityMembers,
+			runsReaders:    []string{"anonymous", "user", "member", "owner", "admin"},
+			logsReaders:    []string{"member", "owner", "admin"},
+		},
+		{
+			name:           "public project with owners runs and logs visibility",
+			visibility:     cstypes.VisibilityPublic,
+			runsVisibility: cstypes.RunsVisibilityOwners,
+			logsVisibility: cstypes.RunsVisibilityOwners,
+			runsReaders:    []string{"owner", "admin"},
+			logsReaders:    []string{"owner", "admin"},
+		},
+		{
+			name:           "private project with public runs visibility",
+			visibility:     cstypes.VisibilityPrivate,
+			runsVisibility: cstypes.RunsVisibilityPublic,
+			runsReaders:    []string{"anonymous", "user", "member", "owner", "admin"},
+			logsReaders:    []string{"member", "owner", "admin"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.setProject(tt.visibility, tt.runsVisibility, tt.logsVisibility)
+
+			for _, user := range []string{"anonymous", "user", "member", "owner", "admin"} {
+				ctx := ctxs[user]
+
+				canGetRun, groupID, err := h.CanGetRun(ctx, scommon.GroupTypeProject, "project01")
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				if expected := util.StringInSlice(tt.runsReaders, user); canGetRun != expected {
+					t.Fatalf("%s: expected can get run %t, got %t", user, expected, canGetRun)
+				}
+				if canGetRun && groupID != "project01" {
+					t.Fatalf("%s: expected group id %q, got %q", user, "project01", groupID)
+				}
+
+				canGetRunLogs, _, err := h.CanGetRunLogs(ctx, scommon.GroupTypeProject, "project01")
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				if expected := util.StringInSlice(tt.logsReaders, user); canGetRunLogs != expected {
+					t.Fatalf("%s: expected can get run logs %t, got %t", user, expected, canGetRunLogs)
+				}
+
+				// the logs are rejected before querying the runservice
+				if !canGetRunLogs {
+					_, err := h.GetLogs(ctx, &GetLogsRequest{GroupType: scommon.GroupTypeProject, Ref: "project01", RunNumber: 1, TaskID: "task01", Step: 1})
+					if !util.APIErrorIs(err, util.ErrForbidden) {
+						t.Fatalf("%s: expected forbidden error, got: %v", user, err)
+					}
+				}
+			}
+		})
+	}
+}
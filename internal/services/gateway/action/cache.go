@@ -0,0 +1,112 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"fmt"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	gitsource "agola.io/agola/internal/gitsources"
+	"agola.io/agola/internal/util"
+	cstypes "agola.io/agola/services/configstore/types"
+)
+
+const (
+	// remoteSourceCacheTTL is the ttl of the cached remote sources. Every
+	// gateway instance has its own cache and only invalidates it on its own
+	// remote source updates, so this is also the max time an instance could
+	// use a remote source changed by another instance.
+	remoteSourceCacheTTL = 30 * time.Second
+	// remoteInfoCacheTTL is the ttl of the cached git source repository and
+	// user info
+	remoteInfoCacheTTL = 1 * time.Minute
+
+	cacheMaxEntries = 10000
+)
+
+func remoteSourceCacheKey(rsRef string) string {
+	return "rs/" + rsRef
+}
+
+func repoInfoCacheKeyPrefix(rsID string) string {
+	return fmt.Sprintf("repo/%s/", rsID)
+}
+
+// repoInfoCacheKey returns the repo info cache key. The repository info (and
+// its visibility) depends on the account used to access it so it's part of the
+// key.
+func repoInfoCacheKey(rsID, accountID, repoPath string) string {
+	return fmt.Sprintf("%s%s/%s", repoInfoCacheKeyPrefix(rsID), accountID, repoPath)
+}
+
+func userInfoCacheKeyPrefix(rsID string) string {
+	return fmt.Sprintf("user/%s/", rsID)
+}
+
+// userInfoCacheKey returns the user info cache key. The access token hash is
+// used to not keep the tokens in memory.
+func userInfoCacheKey(rsID, accessToken string) string {
+	return userInfoCacheKeyPrefix(rsID) + util.EncodeSha256Hex(accessToken)
+}
+
+// invalidateRemoteSourceCache removes the remote source and all its cached
+// repositories and users info.
+func (h *ActionHandler) invalidateRemoteSourceCache(rs *cstypes.RemoteSource) {
+	h.rsCache.Delete(remoteSourceCacheKey(rs.ID))
+	h.rsCache.Delete(remoteSourceCacheKey(rs.Name))
+	h.remoteInfoCache.DeletePrefix(repoInfoCacheKeyPrefix(rs.ID))
+	h.remoteInfoCache.DeletePrefix(userInfoCacheKeyPrefix(rs.ID))
+}
+
+// getRepoInfo returns the repository info using the repository info cache.
+// accountID is the id of the account used by the gitsource (the linked account
+// id or, for plain git sources, the project id).
+func (h *ActionHandler) getRepoInfo(gitSource gitsource.GitSource, rsID, accountID, repoPath string) (*gitsource.RepoInfo, error) {
+	key := repoInfoCacheKey(rsID, accountID, repoPath)
+	if v, ok := h.remoteInfoCache.Get(key); ok {
+		repoInfo := *(v.(*gitsource.RepoInfo))
+		return &repoInfo, nil
+	}
+
+	repoInfo, err := gitSource.GetRepoInfo(repoPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	crepoInfo := *repoInfo
+	h.remoteInfoCache.Set(key, &crepoInfo)
+
+	return repoInfo, nil
+}
+
+// getRemoteUserInfo returns the remote user info using the user info cache.
+func (h *ActionHandler) getRemoteUserInfo(userSource gitsource.UserSource, rsID, accessToken string) (*gitsource.UserInfo, error) {
+	key := userInfoCacheKey(rsID, accessToken)
+	if v, ok := h.remoteInfoCache.Get(key); ok {
+		userInfo := *(v.(*gitsource.UserInfo))
+		return &userInfo, nil
+	}
+
+	userInfo, err := userSource.GetUserInfo()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	cuserInfo := *userInfo
+	h.remoteInfoCache.Set(key, &cuserInfo)
+
+	return userInfo, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/services/gateway/common"
+	"agola.io/agola/internal/util"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+	rstypes "agola.io/agola/services/runservice/types"
+)
+
+func (h *ActionHandler) GetExecutors(ctx context.Context) ([]*rstypes.Executor, error) {
+	if !common.IsUserAdmin(ctx) {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not admin"))
+	}
+
+	executors, _, err := h.runserviceClient.GetExecutors(ctx)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	return executors, nil
+}
+
+type ExecutorActionType string
+
+const (
+	ExecutorActionTypeDrain   ExecutorActionType = "drain"
+	ExecutorActionTypeApprove ExecutorActionType = "approve"
+	ExecutorActionTypeBan     ExecutorActionType = "ban"
+)
+
+type ExecutorActionsRequest struct {
+	ExecutorID string
+	ActionType ExecutorActionType
+}
+
+func (h *ActionHandler) ExecutorAction(ctx context.Context, req *ExecutorActionsRequest) error {
+	if !common.IsUserAdmin(ctx) {
+		return util.NewAPIError(util.ErrForbidden, errors.Errorf("user not admin"))
+	}
+
+	switch req.ActionType {
+	case ExecutorActionTypeDrain:
+		rsreq := &rsapitypes.ExecutorActionsRequest{
+			ActionType: rsapitypes.ExecutorActionTypeDrain,
+		}
+		if _, err := h.runserviceClient.ExecutorActions(ctx, req.ExecutorID, rsreq); err != nil {
+			return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to drain executor"))
+		}
+	case ExecutorActionTypeApprove:
+		rsreq := &rsapitypes.ExecutorActionsRequest{
+			ActionType: rsapitypes.ExecutorActionTypeApprove,
+		}
+		if _, err := h.runserviceClient.ExecutorActions(ctx, req.ExecutorID, rsreq); err != nil {
+			return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to approve executor"))
+		}
+	case ExecutorActionTypeBan:
+		rsreq := &rsapitypes.ExecutorActionsRequest{
+			ActionType: rsapitypes.ExecutorActionTypeBan,
+		}
+		if _, err := h.runserviceClient.ExecutorActions(ctx, req.ExecutorID, rsreq); err != nil {
+			return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to ban executor"))
+		}
+	default:
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("wrong executor action type %q", req.ActionType))
+	}
+
+	return nil
+}
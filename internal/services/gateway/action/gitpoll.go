@@ -0,0 +1,194 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"fmt"
+
+	"agola.io/agola/internal/errors"
+	gitsource "agola.io/agola/internal/gitsources"
+	scommon "agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/services/types"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	cstypes "agola.io/agola/services/configstore/types"
+)
+
+// PollPlainGitProject polls the refs of a project using a plain git remote
+// source and creates runs for new or updated branches and tags.
+// Since no webhooks can be installed on these remote sources this is the only
+// way to detect repository changes.
+// The refs seen by the last poll are saved in the configstore. The first time a
+// project is polled its refs are only recorded, so no runs are created for
+// already existing branches and tags.
+func (h *ActionHandler) PollPlainGitProject(ctx context.Context, projectRef string) error {
+	p, _, err := h.configstoreClient.GetProject(ctx, projectRef)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q", projectRef))
+	}
+
+	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, p.RemoteSourceID)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", p.RemoteSourceID))
+	}
+	if rs.Type != cstypes.RemoteSourceTypeGit {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("project %q doesn't use a plain git remote source", projectRef))
+	}
+
+	var prevRefs map[string]string
+	projectPolledRefs, _, err := h.configstoreClient.GetProjectPolledRefs(ctx, p.ID)
+	if err != nil {
+		if !util.RemoteErrorIs(err, util.ErrNotExist) {
+			return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q polled refs", projectRef))
+		}
+	} else {
+		prevRefs = projectPolledRefs.Refs
+		if prevRefs == nil {
+			prevRefs = map[string]string{}
+		}
+	}
+
+	refs, err := h.pollPlainGitProject(ctx, rs, p, prevRefs)
+	if err != nil {
+		return errors.Wrapf(err, "failed to poll project %q", p.Path)
+	}
+
+	if _, _, err := h.configstoreClient.UpdateProjectPolledRefs(ctx, p.ID, &csapitypes.UpdateProjectPolledRefsRequest{Refs: refs}); err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to update project %q polled refs", projectRef))
+	}
+
+	return nil
+}
+
+func (h *ActionHandler) pollPlainGitProject(ctx context.Context, rs *cstypes.RemoteSource, p *csapitypes.Project, prevRefs map[string]string) (map[string]string, error) {
+	gitSource, err := scommon.GetPlainGitSource(rs, p.SSHPrivateKey, p.SkipSSHHostKeyCheck)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create gitsource client")
+	}
+
+	refs, err := gitSource.ListRefs(p.RepositoryPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	curRefs := map[string]string{}
+	for _, ref := range refs {
+		refType, name, err := gitSource.RefType(ref.Ref)
+		if err != nil {
+			// ignore unsupported refs
+			continue
+		}
+
+		curRefs[ref.Ref] = ref.CommitSHA
+
+		// first poll of this project, only record the refs
+		if prevRefs == nil {
+			continue
+		}
+		if prevRefs[ref.Ref] == ref.CommitSHA {
+			continue
+		}
+
+		// check if a run for this commit already exists (i.e. created by
+		// a previous poll that failed to save the polled refs)
+		var groupType scommon.GroupType
+		switch refType {
+		case gitsource.RefTypeBranch:
+			groupType = scommon.GroupTypeBranch
+		case gitsource.RefTypeTag:
+			groupType = scommon.GroupTypeTag
+		default:
+			continue
+		}
+		runGroup := scommon.GenRunGroup(scommon.GroupTypeProject, p.ID, groupType, name)
+		runsResp, _, err := h.runserviceClient.GetGroupLastRun(ctx, runGroup, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get last run for group %q", runGroup)
+		}
+		if len(runsResp.Runs) > 0 && runsResp.Runs[0].Annotations[AnnotationCommitSHA] == ref.CommitSHA {
+			continue
+		}
+
+		h.log.Info().Msgf("creating run for project %q ref %q commit %q", p.Path, ref.Ref, ref.CommitSHA)
+		if err := h.createPlainGitRun(ctx, rs, p, gitSource, refType, name, ref); err != nil {
+			h.log.Err(err).Msgf("failed to create run for project %q ref %q", p.Path, ref.Ref)
+			// keep the previous commit sha so we'll retry at the next poll
+			if sha, ok := prevRefs[ref.Ref]; ok {
+				curRefs[ref.Ref] = sha
+			} else {
+				delete(curRefs, ref.Ref)
+			}
+		}
+	}
+
+	return curRefs, nil
+}
+
+func (h *ActionHandler) createPlainGitRun(ctx context.Context, rs *cstypes.RemoteSource, p *csapitypes.Project, gitSource gitsource.GitSource, refType gitsource.RefType, name string, ref *gitsource.Ref) error {
+	repoInfo, err := h.getRepoInfo(gitSource, rs.ID, p.ID, p.RepositoryPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get repository info from gitsource")
+	}
+
+	var runRefType types.RunRefType
+	var message, branch, tag string
+	switch refType {
+	case gitsource.RefTypeBranch:
+		commit, err := gitSource.GetCommit(p.RepositoryPath, ref.CommitSHA)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get commit information from git source for commit sha %q", ref.CommitSHA)
+		}
+		runRefType = types.RunRefTypeBranch
+		branch = name
+		message = commit.Message
+	case gitsource.RefTypeTag:
+		runRefType = types.RunRefTypeTag
+		tag = name
+		message = fmt.Sprintf("Tag %s", tag)
+	}
+
+	cloneURL, err := scommon.GetSSHCloneURL(rs, repoInfo.SSHCloneURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// use remotesource skipSSHHostKeyCheck config and override with project config if set to true there
+	skipSSHHostKeyCheck := rs.SkipSSHHostKeyCheck
+	if p.SkipSSHHostKeyCheck {
+		skipSSHHostKeyCheck = p.SkipSSHHostKeyCheck
+	}
+
+	req := &CreateRunRequest{
+		RunType:            types.RunTypeProject,
+		RefType:            runRefType,
+		RunCreationTrigger: types.RunCreationTriggerTypePoll,
+
+		Project:             p.Project,
+		RepoPath:            p.RepositoryPath,
+		GitSource:           gitSource,
+		CommitSHA:           ref.CommitSHA,
+		Message:             message,
+		Branch:              branch,
+		Tag:                 tag,
+		Ref:                 ref.Ref,
+		SSHPrivKey:          p.SSHPrivateKey,
+		SSHHostKey:          rs.SSHHostKey,
+		SkipSSHHostKeyCheck: skipSSHHostKeyCheck,
+		CloneURL:            cloneURL,
+	}
+
+	return h.CreateRuns(ctx, req)
+}
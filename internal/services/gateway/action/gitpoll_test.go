@@ -0,0 +1,346 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/testutil"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	csclient "agola.io/agola/services/configstore/client"
+	cstypes "agola.io/agola/services/configstore/types"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+	rsclient "agola.io/agola/services/runservice/client"
+	rstypes "agola.io/agola/services/runservice/types"
+	stypes "agola.io/agola/services/types"
+)
+
+const gitPollTestConfig = `
+{
+  runs: [
+    {
+      name: 'run01',
+      tasks: [
+        {
+          name: 'task01',
+          runtime: {
+            containers: [
+              {
+                image: 'alpine/git',
+              },
+            ],
+          },
+          steps: [
+            { type: 'clone' },
+          ],
+        },
+      ],
+    },
+  ],
+}
+`
+
+// gitPollTestRepo is a plain git repository used by the polled project
+type gitPollTestRepo struct {
+	t       *testing.T
+	git     *util.Git
+	workDir string
+}
+
+func newGitPollTestRepo(t *testing.T, dir string) *gitPollTestRepo {
+	r := &gitPollTestRepo{
+		t:       t,
+		git:     &util.Git{GitDir: dir},
+		workDir: t.TempDir(),
+	}
+	r.git.Env = []string{"GIT_WORK_TREE=" + r.workDir}
+
+	r.run("init", "--quiet")
+	r.run("config", "--unset", "core.bare")
+	r.run("config", "user.email", "user01@example.com")
+	r.run("config", "user.name", "user01")
+	r.run("symbolic-ref", "HEAD", "refs/heads/master")
+
+	if err := os.MkdirAll(filepath.Join(r.workDir, ".agola"), 0755); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(r.workDir, ".agola", "config.jsonnet"), []byte(gitPollTestConfig), 0644); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	r.run("add", ".agola")
+
+	return r
+}
+
+func (r *gitPollTestRepo) run(args ...string) string {
+	out, err := r.git.Output(context.Background(), nil, args...)
+	if err != nil {
+		r.t.Fatalf("unexpected err: %v", err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (r *gitPollTestRepo) commit(message string) string {
+	r.run("commit", "--quiet", "--allow-empty", "-m", message)
+	return r.run("rev-parse", "HEAD")
+}
+
+// gitPollTestServices fakes the configstore and runservice api calls done when
+// polling a project and creating its runs
+type gitPollTestServices struct {
+	t  *testing.T
+	mu sync.Mutex
+
+	rs         *cstypes.RemoteSource
+	project    *csapitypes.Project
+	polledRefs *cstypes.ProjectPolledRefs
+	variables  []*csapitypes.Variable
+	secrets    []*csapitypes.Secret
+	runs       []*rstypes.Run
+	// runRequests are the received run creation requests
+	runRequests []*rsapitypes.RunCreateRequest
+}
+
+func (s *gitPollTestServices) configstore(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		projectPath := "/api/v1alpha/projects/" + s.project.ID
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/remotesources/"+s.rs.ID:
+			writeTestJSON(t, w, http.StatusOK, s.rs)
+		case r.Method == "GET" && r.URL.Path == projectPath:
+			writeTestJSON(t, w, http.StatusOK, s.project)
+		case r.Method == "GET" && r.URL.Path == projectPath+"/polledrefs":
+			if s.polledRefs == nil {
+				writeTestJSON(t, w, http.StatusNotFound, map[string]string{"message": "project polled refs don't exist"})
+				return
+			}
+			writeTestJSON(t, w, http.StatusOK, s.polledRefs)
+		case r.Method == "PUT" && r.URL.Path == projectPath+"/polledrefs":
+			var req *csapitypes.UpdateProjectPolledRefsRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			s.polledRefs = &cstypes.ProjectPolledRefs{ProjectID: s.project.ID, Refs: req.Refs}
+			writeTestJSON(t, w, http.StatusOK, s.polledRefs)
+		case r.Method == "GET" && r.URL.Path == projectPath+"/variables":
+			variables := []*csapitypes.Variable{}
+			variables = append(variables, s.variables...)
+			writeTestJSON(t, w, http.StatusOK, variables)
+		case r.Method == "GET" && r.URL.Path == projectPath+"/secrets":
+			secrets := []*csapitypes.Secret{}
+			secrets = append(secrets, s.secrets...)
+			writeTestJSON(t, w, http.StatusOK, secrets)
+		default:
+			t.Errorf("unexpected configstore request %s %s", r.Method, r.URL.Path)
+			writeTestJSON(t, w, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func (s *gitPollTestServices) runservice(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/runs":
+			// only the group last run is requested
+			group := r.URL.Query().Get("group")
+			res := &rsapitypes.GetRunsResponse{Runs: []*rstypes.Run{}}
+			for i := len(s.runs) - 1; i >= 0; i-- {
+				if s.runs[i].Group == group {
+					res.Runs = append(res.Runs, s.runs[i])
+					break
+				}
+			}
+			writeTestJSON(t, w, http.StatusOK, res)
+		case r.Method == "POST" && r.URL.Path == "/api/v1alpha/runs":
+			var req *rsapitypes.RunCreateRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			run := &rstypes.Run{Name: req.Name, Group: req.Group, Annotations: req.Annotations}
+			s.runs = append(s.runs, run)
+			s.runRequests = append(s.runRequests, req)
+			writeTestJSON(t, w, http.StatusCreated, &rsapitypes.RunResponse{Run: run})
+		default:
+			t.Errorf("unexpected runservice request %s %s", r.Method, r.URL.Path)
+			writeTestJSON(t, w, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// createdRuns returns the commit sha of the created runs by run group
+func (s *gitPollTestServices) createdRuns() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := map[string]string{}
+	for _, run := range s.runs {
+		if _, ok := runs[run.Group]; ok {
+			s.t.Errorf("duplicate run for group %q", run.Group)
+		}
+		runs[run.Group] = run.Annotations[AnnotationCommitSHA]
+	}
+	return runs
+}
+
+func (s *gitPollTestServices) savedRefs() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.polledRefs == nil {
+		return nil
+	}
+	return s.polledRefs.Refs
+}
+
+func (s *gitPollTestServices) setSavedRefs(refs map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.polledRefs.Refs = refs
+}
+
+func TestPollPlainGitProject(t *testing.T) {
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+	dir := t.TempDir()
+
+	repo := newGitPollTestRepo(t, filepath.Join(dir, "repo01"))
+	firstSHA := repo.commit("first commit")
+
+	s := &gitPollTestServices{
+		t: t,
+		rs: &cstypes.RemoteSource{
+			ObjectMeta: stypes.ObjectMeta{ID: "rs01"},
+			Name:       "rs01",
+			APIURL:     "file://" + dir,
+			Type:       cstypes.RemoteSourceTypeGit,
+		},
+		project: &csapitypes.Project{
+			Project: &cstypes.Project{
+				ObjectMeta:     stypes.ObjectMeta{ID: "project01"},
+				Name:           "project01",
+				RemoteSourceID: "rs01",
+				RepositoryPath: "repo01",
+			},
+			OwnerType: cstypes.ObjectKindUser,
+			OwnerID:   "user01",
+			Path:      "user/user01/project01",
+		},
+	}
+
+	csClient := csclient.NewClient(s.configstore(t).URL)
+	rsClient := rsclient.NewClient(s.runservice(t).URL)
+	h := NewActionHandler(log, nil, csClient, rsClient, nil, "agola", "", "", nil, "")
+
+	branchGroup := common.GenRunGroup(common.GroupTypeProject, "project01", common.GroupTypeBranch, "master")
+	tagGroup := common.GenRunGroup(common.GroupTypeProject, "project01", common.GroupTypeTag, "v1.0")
+
+	t.Run("first poll only records the refs", func(t *testing.T) {
+		if err := h.PollPlainGitProject(ctx, "project01"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if runs := s.createdRuns(); len(runs) != 0 {
+			t.Fatalf("expected no runs, got %v", runs)
+		}
+		if refs := s.savedRefs(); refs["refs/heads/master"] != firstSHA {
+			t.Fatalf("expected polled refs with master at %q, got %v", firstSHA, refs)
+		}
+	})
+
+	t.Run("poll without changes doesn't create runs", func(t *testing.T) {
+		if err := h.PollPlainGitProject(ctx, "project01"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if runs := s.createdRuns(); len(runs) != 0 {
+			t.Fatalf("expected no runs, got %v", runs)
+		}
+	})
+
+	var secondSHA string
+	t.Run("poll creates runs for updated branches and new tags", func(t *testing.T) {
+		secondSHA = repo.commit("second commit")
+		repo.run("tag", "v1.0")
+
+		if err := h.PollPlainGitProject(ctx, "project01"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		runs := s.createdRuns()
+		if len(runs) != 2 || runs[branchGroup] != secondSHA || runs[tagGroup] != secondSHA {
+			t.Fatalf("expected runs for branch and tag at %q, got %v", secondSHA, runs)
+		}
+		if refs := s.savedRefs(); refs["refs/heads/master"] != secondSHA || refs["refs/tags/v1.0"] != secondSHA {
+			t.Fatalf("expected polled refs at %q, got %v", secondSHA, refs)
+		}
+	})
+
+	t.Run("poll doesn't create a run for an already existing run", func(t *testing.T) {
+		// simulate a previous poll that created the runs but failed to save
+		// the polled refs
+		s.setSavedRefs(map[string]string{"refs/heads/master": firstSHA})
+
+		if err := h.PollPlainGitProject(ctx, "project01"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if runs := s.createdRuns(); len(runs) != 2 {
+			t.Fatalf("expected 2 runs, got %v", runs)
+		}
+		if refs := s.savedRefs(); refs["refs/heads/master"] != secondSHA {
+			t.Fatalf("expected polled refs with master at %q, got %v", secondSHA, refs)
+		}
+	})
+
+	t.Run("poll of a project not using a plain git remote source", func(t *testing.T) {
+		s.mu.Lock()
+		s.rs.Type = cstypes.RemoteSourceTypeGitea
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			s.rs.Type = cstypes.RemoteSourceTypeGit
+			s.mu.Unlock()
+		}()
+
+		err := h.PollPlainGitProject(ctx, "project01")
+		if !util.APIErrorIs(err, util.ErrBadRequest) {
+			t.Fatalf("expected bad request error, got: %v", err)
+		}
+	})
+}
@@ -16,14 +16,24 @@ package action
 
 import (
 	"context"
+	"path"
+	"time"
 
 	"agola.io/agola/internal/errors"
+	scommon "agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/gateway/common"
 	"agola.io/agola/internal/util"
 	csapitypes "agola.io/agola/services/configstore/api/types"
 	cstypes "agola.io/agola/services/configstore/types"
 )
 
+const (
+	// orgUsageRunsLimit is the number of runs fetched in a single request
+	// when computing the org usage (must not be greater than the runservice
+	// max runs limit)
+	orgUsageRunsLimit = 40
+)
+
 func (h *ActionHandler) GetOrg(ctx context.Context, orgRef string) (*cstypes.Organization, error) {
 	org, _, err := h.configstoreClient.GetOrg(ctx, orgRef)
 	if err != nil {
@@ -117,6 +127,31 @@ func (h *ActionHandler) CreateOrg(ctx context.Context, req *CreateOrgRequest) (*
 	return org, nil
 }
 
+type UpdateOrgRequest struct {
+	ConcurrencyLimits cstypes.ConcurrencyLimits
+}
+
+func (h *ActionHandler) UpdateOrg(ctx context.Context, orgRef string, req *UpdateOrgRequest) (*cstypes.Organization, error) {
+	// only an admin can change the org limits since they protect the other
+	// instance users
+	if !common.IsUserAdmin(ctx) {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	creq := &csapitypes.UpdateOrgRequest{
+		ConcurrencyLimits: req.ConcurrencyLimits,
+	}
+
+	h.log.Info().Msgf("updating organization")
+	org, _, err := h.configstoreClient.UpdateOrg(ctx, orgRef, creq)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to update organization"))
+	}
+	h.log.Info().Msgf("organization %s updated, ID: %s", org.Name, org.ID)
+
+	return org, nil
+}
+
 func (h *ActionHandler) DeleteOrg(ctx context.Context, orgRef string) error {
 	org, _, err := h.configstoreClient.GetOrg(ctx, orgRef)
 	if err != nil {
@@ -193,3 +228,126 @@ func (h *ActionHandler) RemoveOrgMember(ctx context.Context, orgRef, userRef str
 
 	return nil
 }
+
+type GetOrgUsageRequest struct {
+	OrgRef    string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+type OrgUsageResponse struct {
+	Organization *cstypes.Organization
+	StartTime    time.Time
+	EndTime      time.Time
+	Projects     []*ProjectUsage
+}
+
+type ProjectUsage struct {
+	Project *csapitypes.Project
+	// Runs is the number of runs enqueued in the time range
+	Runs uint64
+	// TaskDuration is the sum of the duration of the tasks of these runs
+	TaskDuration time.Duration
+}
+
+// GetOrgUsage returns the usage of every project of an organization computed
+// from the runs enqueued in the requested time range.
+func (h *ActionHandler) GetOrgUsage(ctx context.Context, req *GetOrgUsageRequest) (*OrgUsageResponse, error) {
+	if !req.StartTime.Before(req.EndTime) {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("start time must be before end time"))
+	}
+
+	org, _, err := h.configstoreClient.GetOrg(ctx, req.OrgRef)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	isOrgOwner, err := h.IsOrgOwner(ctx, org.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine ownership")
+	}
+	if !isOrgOwner {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	projects, err := h.getProjectGroupProjectsTree(ctx, path.Join("org", org.Name))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	res := &OrgUsageResponse{
+		Organization: org,
+		StartTime:    req.StartTime,
+		EndTime:      req.EndTime,
+		Projects:     make([]*ProjectUsage, len(projects)),
+	}
+	for i, project := range projects {
+		res.Projects[i], err = h.getProjectUsage(ctx, project, req.StartTime, req.EndTime)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return res, nil
+}
+
+// getProjectGroupProjectsTree returns all the projects of the project group
+// and of its subgroups
+func (h *ActionHandler) getProjectGroupProjectsTree(ctx context.Context, projectGroupRef string) ([]*csapitypes.Project, error) {
+	projects, _, err := h.configstoreClient.GetProjectGroupProjects(ctx, projectGroupRef, nil)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	subgroups, _, err := h.configstoreClient.GetProjectGroupSubgroups(ctx, projectGroupRef)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+	for _, subgroup := range subgroups {
+		subprojects, err := h.getProjectGroupProjectsTree(ctx, subgroup.ID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		projects = append(projects, subprojects...)
+	}
+
+	return projects, nil
+}
+
+func (h *ActionHandler) getProjectUsage(ctx context.Context, project *csapitypes.Project, startTime, endTime time.Time) (*ProjectUsage, error) {
+	pu := &ProjectUsage{Project: project}
+
+	group := scommon.GenBaseRunGroup(scommon.GroupTypeProject, project.ID)
+
+	// run counters are assigned at run creation so runs sorted by counter
+	// are also sorted by enqueue time
+	var startRunCounter uint64
+	for {
+		runsResp, _, err := h.runserviceClient.GetGroupRuns(ctx, nil, nil, nil, group, nil, startRunCounter, orgUsageRunsLimit, true)
+		if err != nil {
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+		}
+
+		for _, run := range runsResp.Runs {
+			startRunCounter = run.Counter
+			if run.EnqueueTime == nil || run.EnqueueTime.Before(startTime) {
+				continue
+			}
+			if !run.EnqueueTime.Before(endTime) {
+				return pu, nil
+			}
+
+			pu.Runs++
+			for _, rt := range run.Tasks {
+				if rt.StartTime == nil || rt.EndTime == nil {
+					continue
+				}
+				pu.TaskDuration += rt.EndTime.Sub(*rt.StartTime)
+			}
+		}
+
+		if len(runsResp.Runs) < orgUsageRunsLimit {
+			return pu, nil
+		}
+	}
+}
@@ -19,14 +19,21 @@ import (
 	"fmt"
 	"net/url"
 	"path"
+	"sort"
+	"strings"
+	"sync"
 
 	"agola.io/agola/internal/errors"
 	gitsource "agola.io/agola/internal/gitsources"
+	scommon "agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/gateway/common"
 	"agola.io/agola/internal/services/types"
 	"agola.io/agola/internal/util"
 	csapitypes "agola.io/agola/services/configstore/api/types"
 	cstypes "agola.io/agola/services/configstore/types"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+
+	"github.com/bmatcuk/doublestar"
 )
 
 func (h *ActionHandler) GetProject(ctx context.Context, projectRef string) (*csapitypes.Project, error) {
@@ -50,13 +57,27 @@ func (h *ActionHandler) GetProject(ctx context.Context, projectRef string) (*csa
 }
 
 type CreateProjectRequest struct {
-	Name                string
-	ParentRef           string
-	Visibility          cstypes.Visibility
-	RemoteSourceName    string
-	RepoPath            string
-	SkipSSHHostKeyCheck bool
-	PassVarsToForkedPR  bool
+	Name                    string
+	ParentRef               string
+	Visibility              cstypes.Visibility
+	RemoteSourceName        string
+	RepoPath                string
+	SkipSSHHostKeyCheck     bool
+	PassVarsToForkedPR      bool
+	ReportSkippedRuns       bool
+	Tags                    []string
+	PostPullRequestComments bool
+	UseDepsProxy            bool
+	CancelSupersededRuns    bool
+	RunsVisibility          cstypes.RunsVisibility
+	LogsVisibility          cstypes.RunsVisibility
+	ProtectedBranches       []string
+	ProtectedTags           []string
+	AllowedUpstreamProjects []string
+	Schedules               []*cstypes.ProjectSchedule
+	ConcurrencyLimits       cstypes.ConcurrencyLimits
+	// ImportRepoTopics adds the remote repository topics to the project tags
+	ImportRepoTopics bool
 }
 
 func (h *ActionHandler) CreateProject(ctx context.Context, req *CreateProjectRequest) (*csapitypes.Project, error) {
@@ -104,7 +125,7 @@ func (h *ActionHandler) CreateProject(ctx context.Context, req *CreateProjectReq
 		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("project %q already exists", projectPath))
 	}
 
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, req.RemoteSourceName)
+	rs, err := h.GetRemoteSource(ctx, req.RemoteSourceName)
 	if err != nil {
 		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", req.RemoteSourceName))
 	}
@@ -130,17 +151,25 @@ func (h *ActionHandler) CreateProject(ctx context.Context, req *CreateProjectReq
 		return nil, errors.Wrapf(err, "failed to create gitsource client")
 	}
 
-	repo, err := gitSource.GetRepoInfo(req.RepoPath)
+	repo, err := h.getRepoInfo(gitSource, rs.ID, la.ID, req.RepoPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get repository info from gitsource")
 	}
 
+	tags := req.Tags
+	if req.ImportRepoTopics {
+		tags = append(tags, repoTopicsTags(repo.Topics)...)
+	}
+
 	h.log.Info().Msgf("generating ssh key pairs")
 	privateKey, _, err := util.GenSSHKeyPair(4096)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to generate ssh key pair")
 	}
 
+	// save the repository path returned by the git source since it's the
+	// canonical one (i.e. the requested path could have a .git suffix or a
+	// different case)
 	creq := &csapitypes.CreateUpdateProjectRequest{
 		Name: req.Name,
 		Parent: cstypes.Parent{
@@ -152,10 +181,22 @@ func (h *ActionHandler) CreateProject(ctx context.Context, req *CreateProjectReq
 		RemoteSourceID:             rs.ID,
 		LinkedAccountID:            la.ID,
 		RepositoryID:               repo.ID,
-		RepositoryPath:             req.RepoPath,
+		RepositoryPath:             repo.Path,
 		SSHPrivateKey:              string(privateKey),
 		SkipSSHHostKeyCheck:        req.SkipSSHHostKeyCheck,
 		PassVarsToForkedPR:         req.PassVarsToForkedPR,
+		ReportSkippedRuns:          req.ReportSkippedRuns,
+		Tags:                       tags,
+		PostPullRequestComments:    req.PostPullRequestComments,
+		UseDepsProxy:               req.UseDepsProxy,
+		CancelSupersededRuns:       req.CancelSupersededRuns,
+		RunsVisibility:             req.RunsVisibility,
+		LogsVisibility:             req.LogsVisibility,
+		ProtectedBranches:          req.ProtectedBranches,
+		ProtectedTags:              req.ProtectedTags,
+		AllowedUpstreamProjects:    req.AllowedUpstreamProjects,
+		Schedules:                  req.Schedules,
+		ConcurrencyLimits:          req.ConcurrencyLimits,
 	}
 
 	h.log.Info().Msgf("creating project")
@@ -188,8 +229,22 @@ type UpdateProjectRequest struct {
 	Name      *string
 	ParentRef *string
 
-	Visibility         *cstypes.Visibility
-	PassVarsToForkedPR *bool
+	Visibility              *cstypes.Visibility
+	PassVarsToForkedPR      *bool
+	ReportSkippedRuns       *bool
+	Tags                    *[]string
+	PostPullRequestComments *bool
+	UseDepsProxy            *bool
+	CancelSupersededRuns    *bool
+	RunsVisibility          *cstypes.RunsVisibility
+	LogsVisibility          *cstypes.RunsVisibility
+	ProtectedBranches       *[]string
+	ProtectedTags           *[]string
+	AllowedUpstreamProjects *[]string
+	Schedules               *[]*cstypes.ProjectSchedule
+	ConcurrencyLimits       *cstypes.ConcurrencyLimits
+	// ImportRepoTopics adds the remote repository topics to the project tags
+	ImportRepoTopics bool
 }
 
 func (h *ActionHandler) UpdateProject(ctx context.Context, projectRef string, req *UpdateProjectRequest) (*csapitypes.Project, error) {
@@ -218,20 +273,51 @@ func (h *ActionHandler) UpdateProject(ctx context.Context, projectRef string, re
 	if req.PassVarsToForkedPR != nil {
 		p.PassVarsToForkedPR = *req.PassVarsToForkedPR
 	}
-
-	creq := &csapitypes.CreateUpdateProjectRequest{
-		Name:                       p.Name,
-		Parent:                     p.Parent,
-		Visibility:                 p.Visibility,
-		RemoteRepositoryConfigType: p.RemoteRepositoryConfigType,
-		RemoteSourceID:             p.RemoteSourceID,
-		LinkedAccountID:            p.LinkedAccountID,
-		RepositoryID:               p.RepositoryID,
-		RepositoryPath:             p.RepositoryPath,
-		SSHPrivateKey:              p.SSHPrivateKey,
-		SkipSSHHostKeyCheck:        p.SkipSSHHostKeyCheck,
-		PassVarsToForkedPR:         p.PassVarsToForkedPR,
+	if req.ReportSkippedRuns != nil {
+		p.ReportSkippedRuns = *req.ReportSkippedRuns
+	}
+	if req.Tags != nil {
+		p.Tags = *req.Tags
 	}
+	if req.PostPullRequestComments != nil {
+		p.PostPullRequestComments = *req.PostPullRequestComments
+	}
+	if req.UseDepsProxy != nil {
+		p.UseDepsProxy = *req.UseDepsProxy
+	}
+	if req.CancelSupersededRuns != nil {
+		p.CancelSupersededRuns = *req.CancelSupersededRuns
+	}
+	if req.RunsVisibility != nil {
+		p.RunsVisibility = *req.RunsVisibility
+	}
+	if req.LogsVisibility != nil {
+		p.LogsVisibility = *req.LogsVisibility
+	}
+	if req.ProtectedBranches != nil {
+		p.ProtectedBranches = *req.ProtectedBranches
+	}
+	if req.ProtectedTags != nil {
+		p.ProtectedTags = *req.ProtectedTags
+	}
+	if req.AllowedUpstreamProjects != nil {
+		p.AllowedUpstreamProjects = *req.AllowedUpstreamProjects
+	}
+	if req.Schedules != nil {
+		p.Schedules = *req.Schedules
+	}
+	if req.ConcurrencyLimits != nil {
+		p.ConcurrencyLimits = *req.ConcurrencyLimits
+	}
+	if req.ImportRepoTopics {
+		topics, err := h.getProjectRepoTopics(ctx, p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get repository topics")
+		}
+		p.Tags = append(p.Tags, repoTopicsTags(topics)...)
+	}
+
+	creq := updateProjectRequest(p)
 
 	h.log.Info().Msgf("updating project")
 	rp, _, err := h.configstoreClient.UpdateProject(ctx, p.ID, creq)
@@ -264,7 +350,7 @@ func (h *ActionHandler) ProjectUpdateRepoLinkedAccount(ctx context.Context, proj
 		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
 	}
 
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, p.RemoteSourceID)
+	rs, err := h.GetRemoteSource(ctx, p.RemoteSourceID)
 	if err != nil {
 		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", p.RemoteSourceID))
 	}
@@ -291,26 +377,14 @@ func (h *ActionHandler) ProjectUpdateRepoLinkedAccount(ctx context.Context, proj
 	}
 
 	// check user has access to the repository
-	_, err = gitsource.GetRepoInfo(p.RepositoryPath)
+	_, err = h.getRepoInfo(gitsource, rs.ID, la.ID, p.RepositoryPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get repository info from gitsource")
 	}
 
 	p.LinkedAccountID = la.ID
 
-	creq := &csapitypes.CreateUpdateProjectRequest{
-		Name:                       p.Name,
-		Parent:                     p.Parent,
-		Visibility:                 p.Visibility,
-		RemoteRepositoryConfigType: p.RemoteRepositoryConfigType,
-		RemoteSourceID:             p.RemoteSourceID,
-		LinkedAccountID:            p.LinkedAccountID,
-		RepositoryID:               p.RepositoryID,
-		RepositoryPath:             p.RepositoryPath,
-		SSHPrivateKey:              p.SSHPrivateKey,
-		SkipSSHHostKeyCheck:        p.SkipSSHHostKeyCheck,
-		PassVarsToForkedPR:         p.PassVarsToForkedPR,
-	}
+	creq := updateProjectRequest(p)
 
 	h.log.Info().Msgf("updating project")
 	rp, _, err := h.configstoreClient.UpdateProject(ctx, p.ID, creq)
@@ -350,6 +424,17 @@ func (h *ActionHandler) setupGitSourceRepo(ctx context.Context, rs *cstypes.Remo
 	if err := gitsource.DeleteRepoWebhook(project.RepositoryPath, webhookURL); err != nil {
 		return errors.Wrapf(err, "failed to delete repository webhook")
 	}
+
+	if rs.OrgWebhooks {
+		created, err := h.setupGitSourceOrgWebhook(gitsource, rs, project)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if created {
+			return nil
+		}
+	}
+
 	h.log.Info().Msgf("creating webhook to url: %s", webhookURL)
 	if err := gitsource.CreateRepoWebhook(project.RepositoryPath, webhookURL, project.WebhookSecret); err != nil {
 		return errors.Wrapf(err, "failed to create repository webhook")
@@ -358,6 +443,50 @@ func (h *ActionHandler) setupGitSourceRepo(ctx context.Context, rs *cstypes.Remo
 	return nil
 }
 
+// setupGitSourceOrgWebhook (re)creates the organization webhook of the
+// organization owning the project repository. It returns false when the
+// organization webhook cannot be created (i.e. the git source doesn't support
+// them or the repository isn't owned by an organization) so the caller should
+// fallback to a repository webhook.
+func (h *ActionHandler) setupGitSourceOrgWebhook(gs gitsource.GitSource, rs *cstypes.RemoteSource, project *csapitypes.Project) (bool, error) {
+	ows, ok := gs.(gitsource.OrgWebhookSource)
+	if !ok {
+		return false, nil
+	}
+	org := repoPathOrg(project.RepositoryPath)
+	if org == "" {
+		return false, nil
+	}
+
+	webhookURL, err := h.genOrgWebhookURL(rs)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to generate organization webhook url")
+	}
+
+	h.log.Info().Msgf("deleting existing organization %q webhooks", org)
+	if err := ows.DeleteOrgWebhook(org, webhookURL); err != nil {
+		h.log.Warn().Msgf("failed to delete organization %q webhook, falling back to repository webhook: %v", org, err)
+		return false, nil
+	}
+	h.log.Info().Msgf("creating organization %q webhook to url: %s", org, webhookURL)
+	if err := ows.CreateOrgWebhook(org, webhookURL, rs.WebhookSecret); err != nil {
+		h.log.Warn().Msgf("failed to create organization %q webhook, falling back to repository webhook: %v", org, err)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// repoPathOrg returns the organization (or group) owning the repository
+func repoPathOrg(repoPath string) string {
+	repoPath = strings.Trim(repoPath, "/")
+	i := strings.LastIndex(repoPath, "/")
+	if i < 0 {
+		return ""
+	}
+	return repoPath[:i]
+}
+
 func (h *ActionHandler) cleanupGitSourceRepo(ctx context.Context, rs *cstypes.RemoteSource, user *cstypes.User, la *cstypes.LinkedAccount, project *csapitypes.Project) error {
 	gitsource, err := h.GetGitSource(ctx, rs, user.Name, la)
 	if err != nil {
@@ -399,6 +528,20 @@ func (h *ActionHandler) genWebhookURL(project *csapitypes.Project) (string, erro
 	return webhookURL.String(), nil
 }
 
+func (h *ActionHandler) genOrgWebhookURL(rs *cstypes.RemoteSource) (string, error) {
+	baseWebhookURL := fmt.Sprintf("%s/webhooks", h.apiExposedURL)
+	webhookURL, err := url.Parse(baseWebhookURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse base webhook url %q", baseWebhookURL)
+	}
+	q := url.Values{}
+	q.Add("remotesourceid", rs.ID)
+	q.Add("agolaid", h.agolaID)
+	webhookURL.RawQuery = q.Encode()
+
+	return webhookURL.String(), nil
+}
+
 func (h *ActionHandler) ReconfigProject(ctx context.Context, projectRef string) error {
 	p, _, err := h.configstoreClient.GetProject(ctx, projectRef)
 	if err != nil {
@@ -420,9 +563,49 @@ func (h *ActionHandler) ReconfigProject(ctx context.Context, projectRef string)
 
 	// TODO(sgotti) update project repo path if the remote let us query by repository id
 
+	// projects created before webhook secrets were introduced don't have one.
+	// Updating the project will generate it.
+	if p.WebhookSecret == "" {
+		h.log.Info().Msgf("generating project webhook secret")
+		p, _, err = h.configstoreClient.UpdateProject(ctx, p.ID, updateProjectRequest(p))
+		if err != nil {
+			return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to update project"))
+		}
+	}
+
 	return h.setupGitSourceRepo(ctx, rs, user, la, p)
 }
 
+// updateProjectRequest returns a configstore update request with the current
+// project values
+func updateProjectRequest(p *csapitypes.Project) *csapitypes.CreateUpdateProjectRequest {
+	return &csapitypes.CreateUpdateProjectRequest{
+		Name:                       p.Name,
+		Parent:                     p.Parent,
+		Visibility:                 p.Visibility,
+		RemoteRepositoryConfigType: p.RemoteRepositoryConfigType,
+		RemoteSourceID:             p.RemoteSourceID,
+		LinkedAccountID:            p.LinkedAccountID,
+		RepositoryID:               p.RepositoryID,
+		RepositoryPath:             p.RepositoryPath,
+		SSHPrivateKey:              p.SSHPrivateKey,
+		SkipSSHHostKeyCheck:        p.SkipSSHHostKeyCheck,
+		PassVarsToForkedPR:         p.PassVarsToForkedPR,
+		ReportSkippedRuns:          p.ReportSkippedRuns,
+		Tags:                       p.Tags,
+		PostPullRequestComments:    p.PostPullRequestComments,
+		UseDepsProxy:               p.UseDepsProxy,
+		CancelSupersededRuns:       p.CancelSupersededRuns,
+		RunsVisibility:             p.RunsVisibility,
+		LogsVisibility:             p.LogsVisibility,
+		ProtectedBranches:          p.ProtectedBranches,
+		ProtectedTags:              p.ProtectedTags,
+		AllowedUpstreamProjects:    p.AllowedUpstreamProjects,
+		Schedules:                  p.Schedules,
+		ConcurrencyLimits:          p.ConcurrencyLimits,
+	}
+}
+
 func (h *ActionHandler) DeleteProject(ctx context.Context, projectRef string) error {
 	p, _, err := h.configstoreClient.GetProject(ctx, projectRef)
 	if err != nil {
@@ -446,8 +629,10 @@ func (h *ActionHandler) DeleteProject(ctx context.Context, projectRef string) er
 		h.log.Err(err).Msgf("failed to get remote repo access data: %+v", err)
 	}
 
+	// the project is soft deleted and will be permanently deleted, with its
+	// runs, after the deletion grace period
 	h.log.Info().Msgf("deleting project with ID: %q", p.ID)
-	if _, err = h.configstoreClient.DeleteProject(ctx, projectRef); err != nil {
+	if _, err = h.configstoreClient.SoftDeleteProject(ctx, p.ID); err != nil {
 		return util.NewAPIError(util.KindFromRemoteError(err), err)
 	}
 
@@ -460,38 +645,189 @@ func (h *ActionHandler) DeleteProject(ctx context.Context, projectRef string) er
 		}
 	}
 
+	// stop the project runs
+	// we'll log but ignore errors since they'll be stopped again before the purge
+	if err := h.stopProjectRuns(ctx, p.ID); err != nil {
+		h.log.Err(err).Msgf("failed to stop project runs: %+v", err)
+	}
+
 	return nil
 }
 
-func (h *ActionHandler) ProjectCreateRun(ctx context.Context, projectRef, branch, tag, refName, commitSHA string) error {
-	curUserID := common.CurrentUserID(ctx)
+type CloneProjectRequest struct {
+	Name             string
+	ParentRef        string
+	RemoteSourceName string
+	RepoPath         string
+	// CloneSecrets copies the secrets names, types and data keys. Their
+	// values are copied only with CloneSecretsData
+	CloneSecrets     bool
+	CloneSecretsData bool
+}
 
-	user, _, err := h.configstoreClient.GetUser(ctx, curUserID)
+// CloneProject creates a new project on the provided repository path copying
+// the source project settings and variables (and optionally its secrets).
+// The user must own both the source project and the new project parent.
+// The new project is removed when its secrets or variables cannot be copied.
+func (h *ActionHandler) CloneProject(ctx context.Context, projectRef string, req *CloneProjectRequest) (*csapitypes.Project, error) {
+	sp, _, err := h.configstoreClient.GetProject(ctx, projectRef)
 	if err != nil {
-		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q", curUserID))
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q", projectRef))
 	}
 
-	p, _, err := h.configstoreClient.GetProject(ctx, projectRef)
+	isProjectOwner, err := h.IsProjectOwner(ctx, sp.OwnerType, sp.OwnerID)
 	if err != nil {
-		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q", projectRef))
+		return nil, errors.Wrapf(err, "failed to determine ownership")
+	}
+	if !isProjectOwner {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
 	}
 
-	isProjectOwner, err := h.IsProjectOwner(ctx, p.OwnerType, p.OwnerID)
+	remoteSourceName := req.RemoteSourceName
+	if remoteSourceName == "" {
+		// default to the source project remote source
+		rs, err := h.GetRemoteSource(ctx, sp.RemoteSourceID)
+		if err != nil {
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", sp.RemoteSourceID))
+		}
+		remoteSourceName = rs.Name
+	}
+
+	parentRef := req.ParentRef
+	if parentRef == "" {
+		parentRef = sp.ParentPath
+	}
+
+	var secrets []*csapitypes.Secret
+	if req.CloneSecrets || req.CloneSecretsData {
+		secrets, _, err = h.configstoreClient.GetProjectSecrets(ctx, sp.ID, false)
+		if err != nil {
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q secrets", sp.ID))
+		}
+	}
+	variables, _, err := h.configstoreClient.GetProjectVariables(ctx, sp.ID, false)
 	if err != nil {
-		return errors.Wrapf(err, "failed to determine ownership")
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q variables", sp.ID))
+	}
+
+	creq := &CreateProjectRequest{
+		Name:                    req.Name,
+		ParentRef:               parentRef,
+		Visibility:              sp.Visibility,
+		RemoteSourceName:        remoteSourceName,
+		RepoPath:                req.RepoPath,
+		SkipSSHHostKeyCheck:     sp.SkipSSHHostKeyCheck,
+		PassVarsToForkedPR:      sp.PassVarsToForkedPR,
+		ReportSkippedRuns:       sp.ReportSkippedRuns,
+		Tags:                    sp.Tags,
+		PostPullRequestComments: sp.PostPullRequestComments,
+		UseDepsProxy:            sp.UseDepsProxy,
+		CancelSupersededRuns:    sp.CancelSupersededRuns,
+		RunsVisibility:          sp.RunsVisibility,
+		LogsVisibility:          sp.LogsVisibility,
+		ProtectedBranches:       sp.ProtectedBranches,
+		ProtectedTags:           sp.ProtectedTags,
+		AllowedUpstreamProjects: sp.AllowedUpstreamProjects,
+		Schedules:               sp.Schedules,
+		ConcurrencyLimits:       sp.ConcurrencyLimits,
+	}
+
+	// CreateProject will also setup the remote repository (deploy keys and webhooks)
+	rp, err := h.CreateProject(ctx, creq)
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-	if !isProjectOwner {
-		return util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+
+	// secrets must be created before variables since variables reference them
+	for _, secret := range secrets {
+		sreq := cloneSecretRequest(secret, req.CloneSecretsData)
+		h.log.Info().Msgf("cloning project secret %q", secret.Name)
+		if _, _, err := h.configstoreClient.CreateProjectSecret(ctx, rp.ID, sreq); err != nil {
+			h.cleanupClonedProject(ctx, rp)
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to clone secret %q", secret.Name))
+		}
+	}
+
+	for _, variable := range variables {
+		vreq := &csapitypes.CreateUpdateVariableRequest{
+			Name:   variable.Name,
+			Values: variable.Values,
+		}
+		h.log.Info().Msgf("cloning project variable %q", variable.Name)
+		if _, _, err := h.configstoreClient.CreateProjectVariable(ctx, rp.ID, vreq); err != nil {
+			h.cleanupClonedProject(ctx, rp)
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to clone variable %q", variable.Name))
+		}
+	}
+
+	h.log.Info().Msgf("project %s cloned from project %s, ID: %s", rp.Name, sp.ID, rp.ID)
+
+	return rp, nil
+}
+
+// cloneSecretRequest returns the request creating a copy of the secret. The
+// data values are copied only when requested, otherwise only the data keys are
+// copied with empty values. Sealed values are bound to the source project so
+// they are never copied.
+func cloneSecretRequest(secret *csapitypes.Secret, cloneData bool) *csapitypes.CreateUpdateSecretRequest {
+	cloneData = cloneData && !secret.Sealed
+
+	sreq := &csapitypes.CreateUpdateSecretRequest{
+		Name: secret.Name,
+		Type: secret.Type,
+		Data: make(map[string]string, len(secret.Data)),
+	}
+	for k, v := range secret.Data {
+		if !cloneData {
+			v = ""
+		}
+		sreq.Data[k] = v
+	}
+	if cloneData {
+		sreq.SecretProviderID = secret.SecretProviderID
+		sreq.Path = secret.Path
+	}
+
+	return sreq
+}
+
+// cleanupClonedProject removes a partially cloned project and its git source
+// repository configs. Errors are logged but ignored
+func (h *ActionHandler) cleanupClonedProject(ctx context.Context, rp *csapitypes.Project) {
+	h.log.Info().Msgf("deleting project with ID: %q", rp.ID)
+	if _, err := h.configstoreClient.DeleteProject(ctx, rp.ID); err != nil {
+		h.log.Err(err).Msgf("failed to delete project")
 	}
 
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, p.RemoteSourceID)
+	user, rs, la, err := h.getRemoteRepoAccessData(ctx, rp.LinkedAccountID)
 	if err != nil {
-		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", p.RemoteSourceID))
+		h.log.Err(err).Msgf("failed to get remote repo access data")
+		return
+	}
+	h.log.Info().Msgf("cleanup git source repo")
+	if err := h.cleanupGitSourceRepo(ctx, rs, user, la, rp); err != nil {
+		h.log.Err(err).Msgf("failed to cleanup git source repo")
+	}
+}
+
+// projectUserGitSource returns the project git source client and repository
+// info using the current user linked account
+func (h *ActionHandler) projectUserGitSource(ctx context.Context, p *csapitypes.Project) (gitsource.GitSource, *cstypes.RemoteSource, *gitsource.RepoInfo, error) {
+	curUserID := common.CurrentUserID(ctx)
+
+	user, _, err := h.configstoreClient.GetUser(ctx, curUserID)
+	if err != nil {
+		return nil, nil, nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q", curUserID))
+	}
+
+	rs, err := h.GetRemoteSource(ctx, p.RemoteSourceID)
+	if err != nil {
+		return nil, nil, nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", p.RemoteSourceID))
 	}
 
 	linkedAccounts, _, err := h.configstoreClient.GetUserLinkedAccounts(ctx, user.ID)
 	if err != nil {
-		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q linked accounts", user.ID))
+		return nil, nil, nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q linked accounts", user.ID))
 	}
 
 	var la *cstypes.LinkedAccount
@@ -502,18 +838,50 @@ func (h *ActionHandler) ProjectCreateRun(ctx context.Context, projectRef, branch
 		}
 	}
 	if la == nil {
-		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("user doesn't have a linked account for remote source %q", rs.Name))
+		return nil, nil, nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("user doesn't have a linked account for remote source %q", rs.Name))
 	}
 
 	gitSource, err := h.GetGitSource(ctx, rs, user.Name, la)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create gitsource client")
+		return nil, nil, nil, errors.Wrapf(err, "failed to create gitsource client")
+	}
+	accountID := la.ID
+	// plain git sources have no api, the repository is accessed using the
+	// project deploy key
+	if rs.Type == cstypes.RemoteSourceTypeGit {
+		gitSource, err = scommon.GetPlainGitSource(rs, p.SSHPrivateKey, p.SkipSSHHostKeyCheck)
+		if err != nil {
+			return nil, nil, nil, errors.Wrapf(err, "failed to create gitsource client")
+		}
+		accountID = p.ID
 	}
 
 	// check user has access to the repository
-	repoInfo, err := gitSource.GetRepoInfo(p.RepositoryPath)
+	repoInfo, err := h.getRepoInfo(gitSource, rs.ID, accountID, p.RepositoryPath)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "failed to get repository info from gitsource")
+	}
+
+	return gitSource, rs, repoInfo, nil
+}
+
+func (h *ActionHandler) ProjectCreateRun(ctx context.Context, projectRef, branch, tag, refName, commitSHA string, variables, inputs map[string]string) error {
+	p, _, err := h.configstoreClient.GetProject(ctx, projectRef)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q", projectRef))
+	}
+
+	isProjectOwner, err := h.IsProjectOwner(ctx, p.OwnerType, p.OwnerID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to determine ownership")
+	}
+	if !isProjectOwner {
+		return util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	gitSource, rs, repoInfo, err := h.projectUserGitSource(ctx, p)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get repository info from gitsource")
+		return errors.WithStack(err)
 	}
 
 	set := 0
@@ -532,6 +900,11 @@ func (h *ActionHandler) ProjectCreateRun(ctx context.Context, projectRef, branch
 	if set > 1 {
 		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("only one of branch, tag or ref can be provided"))
 	}
+	for name := range variables {
+		if name == "" {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty variable name"))
+		}
+	}
 
 	var refType types.RunRefType
 	var message string
@@ -593,6 +966,11 @@ func (h *ActionHandler) ProjectCreateRun(ctx context.Context, projectRef, branch
 		tagLink = gitSource.TagLink(repoInfo, tag)
 	}
 
+	cloneURL, err := scommon.GetSSHCloneURL(rs, repoInfo.SSHCloneURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	// use remotesource skipSSHHostKeyCheck config and override with project config if set to true there
 	skipSSHHostKeyCheck := rs.SkipSSHHostKeyCheck
 	if p.SkipSSHHostKeyCheck {
@@ -616,12 +994,15 @@ func (h *ActionHandler) ProjectCreateRun(ctx context.Context, projectRef, branch
 		SSHPrivKey:          p.SSHPrivateKey,
 		SSHHostKey:          rs.SSHHostKey,
 		SkipSSHHostKeyCheck: skipSSHHostKeyCheck,
-		CloneURL:            repoInfo.SSHCloneURL,
+		CloneURL:            cloneURL,
 
 		CommitLink:      gitSource.CommitLink(repoInfo, commitSHA),
 		BranchLink:      branchLink,
 		TagLink:         tagLink,
 		PullRequestLink: "",
+
+		Variables: variables,
+		Inputs:    inputs,
 	}
 
 	return h.CreateRuns(ctx, req)
@@ -650,10 +1031,296 @@ func (h *ActionHandler) getRemoteRepoAccessData(ctx context.Context, linkedAccou
 		return nil, nil, nil, errors.Errorf("linked account %q for user %q doesn't exist", linkedAccountID, user.Name)
 	}
 
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, la.RemoteSourceID)
+	rs, err := h.GetRemoteSource(ctx, la.RemoteSourceID)
 	if err != nil {
 		return nil, nil, nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", la.RemoteSourceID))
 	}
 
 	return user, rs, la, nil
 }
+
+// getProjectRepoTopics returns the current topics of the project remote
+// repository. Plain git repositories have no topics.
+func (h *ActionHandler) getProjectRepoTopics(ctx context.Context, p *csapitypes.Project) ([]string, error) {
+	if p.RemoteRepositoryConfigType != cstypes.RemoteRepositoryConfigTypeRemoteSource {
+		return nil, nil
+	}
+
+	user, rs, la, err := h.getRemoteRepoAccessData(ctx, p.LinkedAccountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get remote repo access data")
+	}
+	if rs.Type == cstypes.RemoteSourceTypeGit {
+		return nil, nil
+	}
+
+	gitSource, err := h.GetGitSource(ctx, rs, user.Name, la)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create gitsource client")
+	}
+
+	// don't use the repository info cache since the user explicitly asked to
+	// import the current topics
+	repoInfo, err := gitSource.GetRepoInfo(p.RepositoryPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get repository info from gitsource")
+	}
+
+	return repoInfo.Topics, nil
+}
+
+// repoTopicsTags converts the remote repository topics to project tags.
+// Topics that aren't valid tags (i.e. gitlab tags containing spaces) are
+// ignored.
+func repoTopicsTags(topics []string) []string {
+	tags := []string{}
+	for _, topic := range topics {
+		tag := strings.ToLower(strings.TrimSpace(topic))
+		if !util.ValidateTag(tag) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// GetProjectCaches returns the project caches usage
+func (h *ActionHandler) GetProjectCaches(ctx context.Context, projectRef string) (*rsapitypes.CacheGroupResponse, error) {
+	p, _, err := h.configstoreClient.GetProject(ctx, projectRef)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q", projectRef))
+	}
+
+	isProjectMember, err := h.IsProjectMember(ctx, p.OwnerType, p.OwnerID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine ownership")
+	}
+	if !isProjectMember {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	// the project caches group is the project id
+	cacheGroup, _, err := h.runserviceClient.GetCacheGroup(ctx, p.ID)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q caches", projectRef))
+	}
+
+	return cacheGroup, nil
+}
+
+const (
+	defaultImportProjectsConcurrency = 4
+	maxImportProjectsConcurrency     = 16
+)
+
+type ImportProjectStatus string
+
+const (
+	// ImportProjectStatusCreated reports that the project has been created
+	ImportProjectStatusCreated ImportProjectStatus = "created"
+	// ImportProjectStatusToCreate reports that the project will be created
+	// (dry run)
+	ImportProjectStatusToCreate ImportProjectStatus = "tocreate"
+	// ImportProjectStatusExists reports that the repository has been skipped
+	// since a project with the same name already exists
+	ImportProjectStatusExists ImportProjectStatus = "exists"
+	// ImportProjectStatusFailed reports that the project creation failed
+	ImportProjectStatusFailed ImportProjectStatus = "failed"
+)
+
+type ImportProjectsRequest struct {
+	RemoteSourceName string
+	// RemoteOrg is the remote source organization (or user or group) owning
+	// the repositories to import
+	RemoteOrg string
+	// Include and Exclude are glob patterns matched against the repositories
+	// names. When Include is empty all the repositories are included
+	Include []string
+	Exclude []string
+	// DryRun only reports the projects that will be created
+	DryRun bool
+	// Concurrency is the number of projects created in parallel
+	Concurrency int
+
+	// options of the created projects
+	Visibility          cstypes.Visibility
+	SkipSSHHostKeyCheck bool
+	ImportRepoTopics    bool
+}
+
+type ImportProjectResult struct {
+	RepoPath    string
+	ProjectName string
+	ProjectID   string
+	Status      ImportProjectStatus
+	Error       string
+}
+
+// ImportProjects creates a project in the project group for every repository
+// of the remote source organization. Only the repositories where the user can
+// create deploy keys and webhooks are imported. The result of every
+// repository is reported, a failed project creation doesn't stop the import.
+func (h *ActionHandler) ImportProjects(ctx context.Context, projectGroupRef string, req *ImportProjectsRequest) ([]*ImportProjectResult, error) {
+	if req.RemoteSourceName == "" {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty remote source name"))
+	}
+	if req.RemoteOrg == "" {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty remote organization"))
+	}
+	for _, pattern := range append(append([]string{}, req.Include...), req.Exclude...) {
+		if _, err := doublestar.Match(pattern, pattern); err != nil {
+			return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("invalid repository name pattern %q", pattern))
+		}
+	}
+	concurrency := req.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultImportProjectsConcurrency
+	}
+	if concurrency < 0 || concurrency > maxImportProjectsConcurrency {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("concurrency must be between 1 and %d", maxImportProjectsConcurrency))
+	}
+
+	curUserID := common.CurrentUserID(ctx)
+
+	user, _, err := h.configstoreClient.GetUser(ctx, curUserID)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q", curUserID))
+	}
+
+	pg, _, err := h.configstoreClient.GetProjectGroup(ctx, projectGroupRef)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project group %q", projectGroupRef))
+	}
+
+	isProjectOwner, err := h.IsProjectOwner(ctx, pg.OwnerType, pg.OwnerID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine ownership")
+	}
+	if !isProjectOwner {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	rs, err := h.GetRemoteSource(ctx, req.RemoteSourceName)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", req.RemoteSourceName))
+	}
+
+	linkedAccounts, _, err := h.configstoreClient.GetUserLinkedAccounts(ctx, user.ID)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q linked accounts", user.ID))
+	}
+
+	var la *cstypes.LinkedAccount
+	for _, v := range linkedAccounts {
+		if v.RemoteSourceID == rs.ID {
+			la = v
+			break
+		}
+	}
+	if la == nil {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("user doesn't have a linked account for remote source %q", rs.Name))
+	}
+
+	gitSource, err := h.GetGitSource(ctx, rs, user.Name, la)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create gitsource client")
+	}
+
+	remoteRepos, err := gitSource.ListUserRepos()
+	if err != nil {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "failed to get user repositories from git source"))
+	}
+
+	projects, _, err := h.configstoreClient.GetProjectGroupProjects(ctx, pg.ID, nil)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project group %q projects", projectGroupRef))
+	}
+	projectsNames := map[string]struct{}{}
+	for _, p := range projects {
+		projectsNames[p.Name] = struct{}{}
+	}
+
+	var results []*ImportProjectResult
+	for _, repo := range remoteRepos {
+		if !strings.EqualFold(path.Dir(repo.Path), req.RemoteOrg) {
+			continue
+		}
+		name := path.Base(repo.Path)
+		if !matchRepoName(req.Include, name, true) || matchRepoName(req.Exclude, name, false) {
+			continue
+		}
+
+		result := &ImportProjectResult{
+			RepoPath:    repo.Path,
+			ProjectName: name,
+			Status:      ImportProjectStatusToCreate,
+		}
+		if _, ok := projectsNames[name]; ok {
+			result.Status = ImportProjectStatusExists
+		} else if !util.ValidateName(name) {
+			result.Status = ImportProjectStatusFailed
+			result.Error = fmt.Sprintf("invalid project name %q", name)
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].RepoPath < results[j].RepoPath })
+
+	if req.DryRun {
+		return results, nil
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, result := range results {
+		if result.Status != ImportProjectStatusToCreate {
+			continue
+		}
+		result := result
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			creq := &CreateProjectRequest{
+				Name:                result.ProjectName,
+				ParentRef:           pg.ID,
+				Visibility:          req.Visibility,
+				RemoteSourceName:    rs.Name,
+				RepoPath:            result.RepoPath,
+				SkipSSHHostKeyCheck: req.SkipSSHHostKeyCheck,
+				ImportRepoTopics:    req.ImportRepoTopics,
+			}
+			p, err := h.CreateProject(ctx, creq)
+			if err != nil {
+				h.log.Err(err).Msgf("failed to import repository %q", result.RepoPath)
+				result.Status = ImportProjectStatusFailed
+				result.Error = err.Error()
+				return
+			}
+			result.Status = ImportProjectStatusCreated
+			result.ProjectID = p.ID
+		}()
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// matchRepoName reports if the repository name matches one of the patterns.
+// When there're no patterns it returns def
+func matchRepoName(patterns []string, name string, def bool) bool {
+	if len(patterns) == 0 {
+		return def
+	}
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
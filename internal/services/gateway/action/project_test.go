@@ -0,0 +1,654 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"agola.io/agola/internal/services/gateway/common"
+	"agola.io/agola/internal/testutil"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	csclient "agola.io/agola/services/configstore/client"
+	cstypes "agola.io/agola/services/configstore/types"
+	stypes "agola.io/agola/services/types"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// projectTestGiteaRepo is a repository returned by the fake gitea api
+type projectTestGiteaRepo struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Owner struct {
+		UserName string `json:"login"`
+	} `json:"owner"`
+	Permissions struct {
+		Admin bool `json:"admin"`
+	} `json:"permissions"`
+}
+
+// projectTestServices fakes the configstore and gitea api calls done when
+// creating, importing or cloning projects
+type projectTestServices struct {
+	mu sync.Mutex
+
+	rs    *cstypes.RemoteSource
+	repos []*projectTestGiteaRepo
+	// existingProjects are the projects already in the project group
+	existingProjects []string
+	// failingProjects are the projects whose creation fails
+	failingProjects []string
+	// sourceProject is the project to clone with its secrets and variables
+	sourceProject *csapitypes.Project
+	secrets       []*csapitypes.Secret
+	variables     []*csapitypes.Variable
+
+	// requestedPages are the requested gitea user repositories pages
+	requestedPages []int
+	// createdProjects are the created projects names
+	createdProjects []string
+	// createProjectRequests are the create project requests by project name
+	createProjectRequests map[string]*csapitypes.CreateUpdateProjectRequest
+	// createdObjects are the secrets and variables created in the projects,
+	// in creation order
+	createdObjects []string
+	// createdSecretsData are the created secrets data by secret path
+	createdSecretsData map[string]map[string]string
+	// failingVariables are the variables whose creation fails
+	failingVariables []string
+	// deletedProjects are the deleted projects ids
+	deletedProjects []string
+	// reposWebhooks are the repositories with a created webhook
+	reposWebhooks []string
+}
+
+func (s *projectTestServices) configstore(t *testing.T) *httptest.Server {
+	user := &cstypes.User{ObjectMeta: stypes.ObjectMeta{ID: "user01"}, Name: "user01"}
+	pg := &csapitypes.ProjectGroup{
+		ProjectGroup: &cstypes.ProjectGroup{ObjectMeta: stypes.ObjectMeta{ID: "pg01"}, Name: "user01"},
+		OwnerType:    cstypes.ObjectKindUser,
+		OwnerID:      "user01",
+		Path:         "user/user01",
+	}
+	la := &cstypes.LinkedAccount{
+		ObjectMeta:      stypes.ObjectMeta{ID: "la01"},
+		UserID:          "user01",
+		RemoteSourceID:  "rs01",
+		UserAccessToken: "token01",
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/users/user01":
+			writeTestJSON(t, w, http.StatusOK, user)
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/users/user01/linkedaccounts":
+			writeTestJSON(t, w, http.StatusOK, []*cstypes.LinkedAccount{la})
+		case r.Method == "GET" && (r.URL.Path == "/api/v1alpha/projectgroups/user/user01" || r.URL.Path == "/api/v1alpha/projectgroups/pg01"):
+			writeTestJSON(t, w, http.StatusOK, pg)
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/projectgroups/pg01/projects":
+			projects := []*csapitypes.Project{}
+			for _, name := range s.existingProjects {
+				projects = append(projects, &csapitypes.Project{
+					Project: &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project-" + name}, Name: name},
+				})
+			}
+			writeTestJSON(t, w, http.StatusOK, projects)
+		case r.Method == "GET" && (r.URL.Path == "/api/v1alpha/remotesources/"+s.rs.Name || r.URL.Path == "/api/v1alpha/remotesources/"+s.rs.ID):
+			writeTestJSON(t, w, http.StatusOK, s.rs)
+		case s.sourceProject != nil && r.Method == "GET" && r.URL.Path == "/api/v1alpha/projects/"+s.sourceProject.ID:
+			writeTestJSON(t, w, http.StatusOK, s.sourceProject)
+		case s.sourceProject != nil && r.Method == "GET" && r.URL.Path == "/api/v1alpha/projects/"+s.sourceProject.ID+"/secrets":
+			writeTestJSON(t, w, http.StatusOK, s.secrets)
+		case s.sourceProject != nil && r.Method == "GET" && r.URL.Path == "/api/v1alpha/projects/"+s.sourceProject.ID+"/variables":
+			writeTestJSON(t, w, http.StatusOK, s.variables)
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/api/v1alpha/projects/") && strings.HasSuffix(r.URL.Path, "/secrets"):
+			var req *csapitypes.CreateUpdateSecretRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			projectID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1alpha/projects/"), "/secrets")
+			s.createdObjects = append(s.createdObjects, path.Join(projectID, "secret", req.Name))
+			if s.createdSecretsData == nil {
+				s.createdSecretsData = map[string]map[string]string{}
+			}
+			s.createdSecretsData[path.Join(projectID, "secret", req.Name)] = req.Data
+			writeTestJSON(t, w, http.StatusCreated, &csapitypes.Secret{Secret: &cstypes.Secret{Name: req.Name}})
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/api/v1alpha/projects/") && strings.HasSuffix(r.URL.Path, "/variables"):
+			var req *csapitypes.CreateUpdateVariableRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			for _, name := range s.failingVariables {
+				if req.Name == name {
+					writeTestJSON(t, w, http.StatusBadRequest, map[string]string{"message": "variable creation failed"})
+					return
+				}
+			}
+			projectID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1alpha/projects/"), "/variables")
+			s.createdObjects = append(s.createdObjects, path.Join(projectID, "variable", req.Name))
+			writeTestJSON(t, w, http.StatusCreated, &csapitypes.Variable{Variable: &cstypes.Variable{Name: req.Name}})
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/users" && r.URL.Query().Get("linkedaccountid") == la.ID:
+			writeTestJSON(t, w, http.StatusOK, []*cstypes.User{user})
+		case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/api/v1alpha/projects/"):
+			s.deletedProjects = append(s.deletedProjects, strings.TrimPrefix(r.URL.Path, "/api/v1alpha/projects/"))
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/api/v1alpha/projects/user/user01/"):
+			writeTestJSON(t, w, http.StatusNotFound, map[string]string{"message": "project doesn't exist"})
+		case r.Method == "POST" && r.URL.Path == "/api/v1alpha/projects":
+			var req *csapitypes.CreateUpdateProjectRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			for _, name := range s.failingProjects {
+				if req.Name == name {
+					writeTestJSON(t, w, http.StatusBadRequest, map[string]string{"message": "project creation failed"})
+					return
+				}
+			}
+			s.createdProjects = append(s.createdProjects, req.Name)
+			if s.createProjectRequests == nil {
+				s.createProjectRequests = map[string]*csapitypes.CreateUpdateProjectRequest{}
+			}
+			s.createProjectRequests[req.Name] = req
+			writeTestJSON(t, w, http.StatusCreated, &csapitypes.Project{
+				Project: &cstypes.Project{
+					ObjectMeta:      stypes.ObjectMeta{ID: "project-" + req.Name},
+					Name:            req.Name,
+					RemoteSourceID:  req.RemoteSourceID,
+					LinkedAccountID: req.LinkedAccountID,
+					RepositoryID:    req.RepositoryID,
+					RepositoryPath:  req.RepositoryPath,
+					SSHPrivateKey:   req.SSHPrivateKey,
+					WebhookSecret:   "secret01",
+				},
+				OwnerType: cstypes.ObjectKindUser,
+				OwnerID:   "user01",
+				Path:      path.Join(pg.Path, req.Name),
+			})
+		default:
+			t.Errorf("unexpected configstore request %s %s", r.Method, r.URL.Path)
+			writeTestJSON(t, w, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func (s *projectTestServices) gitea(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.Method == "GET" && r.URL.Path == "/api/v1/user/repos" {
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			s.requestedPages = append(s.requestedPages, page)
+
+			repos := []*projectTestGiteaRepo{}
+			for i := (page - 1) * limit; i < page*limit && i < len(s.repos); i++ {
+				repos = append(repos, s.repos[i])
+			}
+			writeTestJSON(t, w, http.StatusOK, repos)
+			return
+		}
+
+		// repository api paths are /api/v1/repos/{owner}/{repo}[/{resource}]
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/api/v1/repos/"), "/", 3)
+		if !strings.HasPrefix(r.URL.Path, "/api/v1/repos/") || len(parts) < 2 {
+			t.Errorf("unexpected gitea request %s %s", r.Method, r.URL.Path)
+			writeTestJSON(t, w, http.StatusNotFound, map[string]string{"message": "not found"})
+			return
+		}
+		repoPath := path.Join(parts[0], parts[1])
+		var repo *projectTestGiteaRepo
+		for _, rr := range s.repos {
+			if path.Join(rr.Owner.UserName, rr.Name) == repoPath {
+				repo = rr
+			}
+		}
+		if repo == nil {
+			writeTestJSON(t, w, http.StatusNotFound, map[string]string{"message": "not found"})
+			return
+		}
+		var resource string
+		if len(parts) == 3 {
+			resource = parts[2]
+		}
+
+		switch {
+		case r.Method == "GET" && resource == "":
+			writeTestJSON(t, w, http.StatusOK, repo)
+		case r.Method == "GET" && resource == "topics":
+			writeTestJSON(t, w, http.StatusOK, map[string][]string{"topics": {}})
+		case r.Method == "GET" && (resource == "keys" || resource == "hooks"):
+			writeTestJSON(t, w, http.StatusOK, []interface{}{})
+		case r.Method == "POST" && resource == "keys":
+			writeTestJSON(t, w, http.StatusCreated, map[string]interface{}{})
+		case r.Method == "POST" && resource == "hooks":
+			s.reposWebhooks = append(s.reposWebhooks, repoPath)
+			writeTestJSON(t, w, http.StatusCreated, map[string]interface{}{})
+		default:
+			t.Errorf("unexpected gitea request %s %s", r.Method, r.URL.Path)
+			writeTestJSON(t, w, http.StatusNotFound, map[string]string{"message": "not found"})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func (s *projectTestServices) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requestedPages = nil
+	s.createdProjects = nil
+	s.createProjectRequests = nil
+	s.createdObjects = nil
+	s.createdSecretsData = nil
+	s.deletedProjects = nil
+	s.reposWebhooks = nil
+}
+
+func (s *projectTestServices) state() (requestedPages []int, createdProjects, reposWebhooks []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	createdProjects = append([]string{}, s.createdProjects...)
+	reposWebhooks = append([]string{}, s.reposWebhooks...)
+	sort.Strings(createdProjects)
+	sort.Strings(reposWebhooks)
+
+	return append([]int{}, s.requestedPages...), createdProjects, reposWebhooks
+}
+
+func writeTestJSON(t *testing.T, w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+}
+
+func newProjectTestGiteaRepo(id int64, owner, name string, admin bool) *projectTestGiteaRepo {
+	repo := &projectTestGiteaRepo{ID: id, Name: name}
+	repo.Owner.UserName = owner
+	repo.Permissions.Admin = admin
+	return repo
+}
+
+// importResult is the part of an import project result compared in the tests
+type importResult struct {
+	RepoPath  string
+	ProjectID string
+	Status    ImportProjectStatus
+	Failed    bool
+}
+
+func importResults(results []*ImportProjectResult) []importResult {
+	out := []importResult{}
+	for _, r := range results {
+		out = append(out, importResult{
+			RepoPath:  r.RepoPath,
+			ProjectID: r.ProjectID,
+			Status:    r.Status,
+			Failed:    r.Error != "",
+		})
+	}
+	return out
+}
+
+func TestImportProjects(t *testing.T) {
+	log := testutil.NewLogger(t)
+	ctx := context.WithValue(context.Background(), common.ContextKeyUserID, "user01")
+
+	s := &projectTestServices{
+		existingProjects: []string{"repo02"},
+		failingProjects:  []string{"repo03"},
+	}
+	// the repositories span two gitea pages (50 repositories per page)
+	s.repos = append(s.repos,
+		newProjectTestGiteaRepo(1000, "org02", "repo01", true),
+		newProjectTestGiteaRepo(1001, "org01", "repo-noadmin", false),
+		newProjectTestGiteaRepo(1002, "org01", "repo_invalid", true),
+	)
+	for i := 1; i <= 55; i++ {
+		s.repos = append(s.repos, newProjectTestGiteaRepo(int64(i), "org01", fmt.Sprintf("repo%02d", i), true))
+	}
+
+	s.rs = &cstypes.RemoteSource{
+		ObjectMeta: stypes.ObjectMeta{ID: "rs01"},
+		Name:       "rs01",
+		APIURL:     s.gitea(t).URL,
+		Type:       cstypes.RemoteSourceTypeGitea,
+		AuthType:   cstypes.RemoteSourceAuthTypePassword,
+	}
+
+	csClient := csclient.NewClient(s.configstore(t).URL)
+	h := NewActionHandler(log, nil, csClient, nil, nil, "agola", "http://localhost:8000", "", nil, "")
+
+	t.Run("dry run reports the repositories of all the remote pages", func(t *testing.T) {
+		s.reset()
+
+		results, err := h.ImportProjects(ctx, "user/user01", &ImportProjectsRequest{
+			RemoteSourceName: "rs01",
+			RemoteOrg:        "org01",
+			DryRun:           true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		expected := []importResult{}
+		for i := 1; i <= 55; i++ {
+			status := ImportProjectStatusToCreate
+			if i == 2 {
+				status = ImportProjectStatusExists
+			}
+			expected = append(expected, importResult{RepoPath: fmt.Sprintf("org01/repo%02d", i), Status: status})
+		}
+		expected = append(expected, importResult{RepoPath: "org01/repo_invalid", Status: ImportProjectStatusFailed, Failed: true})
+
+		if diff := cmp.Diff(expected, importResults(results)); diff != "" {
+			t.Fatalf("mismatch (-want +got):\n%s", diff)
+		}
+
+		requestedPages, createdProjects, _ := s.state()
+		if diff := cmp.Diff([]int{1, 2, 3}, requestedPages); diff != "" {
+			t.Fatalf("requested pages mismatch (-want +got):\n%s", diff)
+		}
+		if len(createdProjects) != 0 {
+			t.Fatalf("expected no created projects, got %v", createdProjects)
+		}
+	})
+
+	t.Run("import continues after a failed project creation", func(t *testing.T) {
+		s.reset()
+
+		results, err := h.ImportProjects(ctx, "user/user01", &ImportProjectsRequest{
+			RemoteSourceName: "rs01",
+			RemoteOrg:        "org01",
+			Include:          []string{"repo0[1-4]", "repo55", "repo_*"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		expected := []importResult{
+			{RepoPath: "org01/repo01", ProjectID: "project-repo01", Status: ImportProjectStatusCreated},
+			{RepoPath: "org01/repo02", Status: ImportProjectStatusExists},
+			{RepoPath: "org01/repo03", Status: ImportProjectStatusFailed, Failed: true},
+			{RepoPath: "org01/repo04", ProjectID: "project-repo04", Status: ImportProjectStatusCreated},
+			{RepoPath: "org01/repo55", ProjectID: "project-repo55", Status: ImportProjectStatusCreated},
+			{RepoPath: "org01/repo_invalid", Status: ImportProjectStatusFailed, Failed: true},
+		}
+		if diff := cmp.Diff(expected, importResults(results)); diff != "" {
+			t.Fatalf("mismatch (-want +got):\n%s", diff)
+		}
+
+		_, createdProjects, reposWebhooks := s.state()
+		if diff := cmp.Diff([]string{"repo01", "repo04", "repo55"}, createdProjects); diff != "" {
+			t.Fatalf("created projects mismatch (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff([]string{"org01/repo01", "org01/repo04", "org01/repo55"}, reposWebhooks); diff != "" {
+			t.Fatalf("repositories webhooks mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("excluded repositories aren't imported", func(t *testing.T) {
+		s.reset()
+
+		results, err := h.ImportProjects(ctx, "user/user01", &ImportProjectsRequest{
+			RemoteSourceName: "rs01",
+			RemoteOrg:        "org01",
+			Include:          []string{"repo0*"},
+			Exclude:          []string{"repo0[1-8]"},
+			DryRun:           true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		expected := []importResult{
+			{RepoPath: "org01/repo09", Status: ImportProjectStatusToCreate},
+		}
+		if diff := cmp.Diff(expected, importResults(results)); diff != "" {
+			t.Fatalf("mismatch (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestCloneProject(t *testing.T) {
+	log := testutil.NewLogger(t)
+	ctx := context.WithValue(context.Background(), common.ContextKeyUserID, "user01")
+
+	s := &projectTestServices{
+		repos: []*projectTestGiteaRepo{
+			newProjectTestGiteaRepo(1, "org01", "repo01", true),
+			newProjectTestGiteaRepo(2, "org01", "repo02", true),
+		},
+		sourceProject: &csapitypes.Project{
+			Project: &cstypes.Project{
+				ObjectMeta:              stypes.ObjectMeta{ID: "project01"},
+				Name:                    "project01",
+				Visibility:              cstypes.VisibilityPrivate,
+				RemoteSourceID:          "rs01",
+				LinkedAccountID:         "la01",
+				RepositoryID:            "1",
+				RepositoryPath:          "org01/repo01",
+				SkipSSHHostKeyCheck:     true,
+				PassVarsToForkedPR:      true,
+				ReportSkippedRuns:       true,
+				Tags:                    []string{"backend"},
+				PostPullRequestComments: true,
+				RunsVisibility:          cstypes.RunsVisibilityMembers,
+				LogsVisibility:          cstypes.RunsVisibilityOwners,
+				CancelSupersededRuns:    true,
+				ProtectedBranches:       []string{"master"},
+				ProtectedTags:           []string{"v*"},
+				ConcurrencyLimits:       cstypes.ConcurrencyLimits{MaxRunningRuns: 2, MaxRunningTasks: 4},
+			},
+			OwnerType:  cstypes.ObjectKindUser,
+			OwnerID:    "user01",
+			Path:       "user/user01/project01",
+			ParentPath: "user/user01",
+		},
+		secrets: []*csapitypes.Secret{
+			{Secret: &cstypes.Secret{Name: "secret01", Type: cstypes.SecretTypeInternal, Data: map[string]string{"password": "password01"}}},
+			{Secret: &cstypes.Secret{Name: "secret02", Type: cstypes.SecretTypeInternal, Data: map[string]string{"token": "sealedtoken01"}, Sealed: true}},
+		},
+		variables: []*csapitypes.Variable{
+			{Variable: &cstypes.Variable{Name: "var01", Values: []cstypes.VariableValue{{SecretName: "secret01", SecretVar: "password"}}}},
+		},
+	}
+	s.rs = &cstypes.RemoteSource{
+		ObjectMeta: stypes.ObjectMeta{ID: "rs01"},
+		Name:       "rs01",
+		APIURL:     s.gitea(t).URL,
+		Type:       cstypes.RemoteSourceTypeGitea,
+		AuthType:   cstypes.RemoteSourceAuthTypePassword,
+	}
+
+	csClient := csclient.NewClient(s.configstore(t).URL)
+	h := NewActionHandler(log, nil, csClient, nil, nil, "agola", "http://localhost:8000", "", nil, "")
+
+	// the clone copies the source project settings on the new repository
+	expectedRequest := func(name string) *csapitypes.CreateUpdateProjectRequest {
+		sp := s.sourceProject
+		return &csapitypes.CreateUpdateProjectRequest{
+			Name:                       name,
+			Parent:                     cstypes.Parent{Kind: cstypes.ObjectKindProjectGroup, ID: "user/user01"},
+			Visibility:                 sp.Visibility,
+			RemoteRepositoryConfigType: cstypes.RemoteRepositoryConfigTypeRemoteSource,
+			RemoteSourceID:             "rs01",
+			LinkedAccountID:            "la01",
+			RepositoryID:               "2",
+			RepositoryPath:             "org01/repo02",
+			SkipSSHHostKeyCheck:        sp.SkipSSHHostKeyCheck,
+			PassVarsToForkedPR:         sp.PassVarsToForkedPR,
+			ReportSkippedRuns:          sp.ReportSkippedRuns,
+			Tags:                       sp.Tags,
+			PostPullRequestComments:    sp.PostPullRequestComments,
+			CancelSupersededRuns:       sp.CancelSupersededRuns,
+			RunsVisibility:             sp.RunsVisibility,
+			LogsVisibility:             sp.LogsVisibility,
+			ProtectedBranches:          sp.ProtectedBranches,
+			ProtectedTags:              sp.ProtectedTags,
+			ConcurrencyLimits:          sp.ConcurrencyLimits,
+		}
+	}
+	createRequest := func(name string) *csapitypes.CreateUpdateProjectRequest {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		req, ok := s.createProjectRequests[name]
+		if !ok {
+			t.Fatalf("project %q wasn't created", name)
+		}
+		if req.SSHPrivateKey == "" {
+			t.Fatalf("expected a generated ssh private key")
+		}
+		creq := *req
+		creq.SSHPrivateKey = ""
+		return &creq
+	}
+	createdObjects := func() []string {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return append([]string{}, s.createdObjects...)
+	}
+	createdSecretsData := func() map[string]map[string]string {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return s.createdSecretsData
+	}
+	deletedProjects := func() []string {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return append([]string{}, s.deletedProjects...)
+	}
+
+	t.Run("clone without secrets", func(t *testing.T) {
+		s.reset()
+
+		p, err := h.CloneProject(ctx, "project01", &CloneProjectRequest{Name: "project02", RepoPath: "org01/repo02"})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if p.ID != "project-project02" {
+			t.Fatalf("expected project id %q, got %q", "project-project02", p.ID)
+		}
+
+		if diff := cmp.Diff(expectedRequest("project02"), createRequest("project02")); diff != "" {
+			t.Fatalf("create project request mismatch (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff([]string{"project-project02/variable/var01"}, createdObjects()); diff != "" {
+			t.Fatalf("created objects mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("clone with secrets", func(t *testing.T) {
+		s.reset()
+
+		if _, err := h.CloneProject(ctx, "project01", &CloneProjectRequest{Name: "project03", RepoPath: "org01/repo02", CloneSecrets: true}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if diff := cmp.Diff(expectedRequest("project03"), createRequest("project03")); diff != "" {
+			t.Fatalf("create project request mismatch (-want +got):\n%s", diff)
+		}
+		// secrets are created before the variables referencing them
+		if diff := cmp.Diff([]string{"project-project03/secret/secret01", "project-project03/secret/secret02", "project-project03/variable/var01"}, createdObjects()); diff != "" {
+			t.Fatalf("created objects mismatch (-want +got):\n%s", diff)
+		}
+		// only the secrets data keys are copied
+		expectedSecretsData := map[string]map[string]string{
+			"project-project03/secret/secret01": {"password": ""},
+			"project-project03/secret/secret02": {"token": ""},
+		}
+		if diff := cmp.Diff(expectedSecretsData, createdSecretsData()); diff != "" {
+			t.Fatalf("created secrets data mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("clone with secrets data", func(t *testing.T) {
+		s.reset()
+
+		if _, err := h.CloneProject(ctx, "project01", &CloneProjectRequest{Name: "project05", RepoPath: "org01/repo02", CloneSecretsData: true}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		// sealed values are bound to the source project and aren't copied
+		expectedSecretsData := map[string]map[string]string{
+			"project-project05/secret/secret01": {"password": "password01"},
+			"project-project05/secret/secret02": {"token": ""},
+		}
+		if diff := cmp.Diff(expectedSecretsData, createdSecretsData()); diff != "" {
+			t.Fatalf("created secrets data mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("clone failing to copy a variable", func(t *testing.T) {
+		s.reset()
+		s.mu.Lock()
+		s.failingVariables = []string{"var01"}
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			s.failingVariables = nil
+			s.mu.Unlock()
+		}()
+
+		if _, err := h.CloneProject(ctx, "project01", &CloneProjectRequest{Name: "project06", RepoPath: "org01/repo02"}); err == nil {
+			t.Fatalf("expected error")
+		}
+
+		// the partially cloned project is removed
+		if diff := cmp.Diff([]string{"project-project06"}, deletedProjects()); diff != "" {
+			t.Fatalf("deleted projects mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("clone by a user not owning the source project", func(t *testing.T) {
+		s.reset()
+
+		ctx := context.WithValue(context.Background(), common.ContextKeyUserID, "user02")
+		_, err := h.CloneProject(ctx, "project01", &CloneProjectRequest{Name: "project04", RepoPath: "org01/repo02"})
+		if !util.APIErrorIs(err, util.ErrForbidden) {
+			t.Fatalf("expected forbidden error, got: %v", err)
+		}
+		if created := createdObjects(); len(created) != 0 {
+			t.Fatalf("expected no created objects, got %v", created)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	scommon "agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/services/gateway/common"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+	rstypes "agola.io/agola/services/runservice/types"
+)
+
+const stopProjectRunsLimit = 100
+
+// stopProjectRuns cancels the queued runs and stops the running runs of the
+// project.
+func (h *ActionHandler) stopProjectRuns(ctx context.Context, projectID string) error {
+	group := scommon.GenBaseRunGroup(scommon.GroupTypeProject, projectID)
+	phaseFilter := []string{string(rstypes.RunPhaseQueued), string(rstypes.RunPhaseRunning)}
+
+	var startRunCounter uint64
+	for {
+		runsResp, _, err := h.runserviceClient.GetGroupRuns(ctx, phaseFilter, nil, nil, group, nil, startRunCounter, stopProjectRunsLimit, true)
+		if err != nil {
+			return util.NewAPIError(util.KindFromRemoteError(err), err)
+		}
+
+		for _, run := range runsResp.Runs {
+			startRunCounter = run.Counter
+
+			rsreq := &rsapitypes.RunActionsRequest{
+				ActionType: rsapitypes.RunActionTypeStop,
+			}
+			if run.Phase == rstypes.RunPhaseQueued {
+				rsreq = &rsapitypes.RunActionsRequest{
+					ActionType: rsapitypes.RunActionTypeChangePhase,
+					Phase:      rstypes.RunPhaseCancelled,
+				}
+			}
+
+			// the run phase could have changed in the meantime, just log the
+			// error
+			if _, err := h.runserviceClient.RunActions(ctx, run.ID, rsreq); err != nil {
+				h.log.Warn().Err(err).Msgf("failed to stop run %q", run.ID)
+			}
+		}
+
+		if len(runsResp.Runs) < stopProjectRunsLimit {
+			return nil
+		}
+	}
+}
+
+// UndeleteProject restores a deleted project during its deletion grace period
+// and configures again its remote repository.
+func (h *ActionHandler) UndeleteProject(ctx context.Context, projectRef string) (*csapitypes.Project, error) {
+	if !common.IsUserAdmin(ctx) {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	p, _, err := h.configstoreClient.UndeleteProject(ctx, projectRef)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to undelete project %q", projectRef))
+	}
+	h.log.Info().Msgf("project %s undeleted, ID: %s", p.Name, p.ID)
+
+	// try to setup again the gitsource configs removed at deletion
+	// we'll log but ignore errors, the project can be reconfigured later
+	user, rs, la, err := h.getRemoteRepoAccessData(ctx, p.LinkedAccountID)
+	if err != nil {
+		h.log.Err(err).Msgf("failed to get remote repo access data: %+v", err)
+		return p, nil
+	}
+	if err := h.setupGitSourceRepo(ctx, rs, user, la, p); err != nil {
+		h.log.Err(err).Msgf("failed to setup git source repo: %+v", err)
+	}
+
+	return p, nil
+}
+
+// PurgeDeletedProjects permanently deletes the projects deleted more than
+// gracePeriod ago with their runs and caches.
+func (h *ActionHandler) PurgeDeletedProjects(ctx context.Context, gracePeriod time.Duration, now time.Time) error {
+	projects, _, err := h.configstoreClient.GetDeletedProjects(ctx)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	for _, p := range projects {
+		if p.DeletionTime == nil || p.DeletionTime.Add(gracePeriod).After(now) {
+			continue
+		}
+
+		// a failed purge will be retried at the next call
+		if err := h.purgeProject(ctx, p); err != nil {
+			h.log.Err(err).Msgf("failed to purge project %q", p.ID)
+		}
+	}
+
+	return nil
+}
+
+func (h *ActionHandler) purgeProject(ctx context.Context, p *csapitypes.Project) error {
+	h.log.Info().Msgf("purging deleted project with ID: %q", p.ID)
+
+	// runs started before the project deletion could still be active
+	if err := h.stopProjectRuns(ctx, p.ID); err != nil {
+		return errors.WithStack(err)
+	}
+
+	group := scommon.GenBaseRunGroup(scommon.GroupTypeProject, p.ID)
+	if _, err := h.runserviceClient.DeleteGroupRuns(ctx, group); err != nil {
+		return errors.Wrapf(err, "failed to delete project runs")
+	}
+
+	if _, err := h.runserviceClient.DeleteCacheGroup(ctx, p.ID); err != nil {
+		return errors.Wrapf(err, "failed to delete project caches")
+	}
+
+	if _, err := h.configstoreClient.DeleteProject(ctx, p.ID); err != nil {
+		return errors.Wrapf(err, "failed to delete project")
+	}
+
+	return nil
+}
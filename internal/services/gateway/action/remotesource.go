@@ -18,17 +18,31 @@ import (
 	"context"
 
 	"agola.io/agola/internal/errors"
+	gitsource "agola.io/agola/internal/gitsources"
 	"agola.io/agola/internal/services/gateway/common"
 	"agola.io/agola/internal/util"
 	csapitypes "agola.io/agola/services/configstore/api/types"
 	cstypes "agola.io/agola/services/configstore/types"
 )
 
+// GetRemoteSource returns the remote source with the provided ref (name or
+// id) using the remote sources cache.
 func (h *ActionHandler) GetRemoteSource(ctx context.Context, rsRef string) (*cstypes.RemoteSource, error) {
+	if v, ok := h.rsCache.Get(remoteSourceCacheKey(rsRef)); ok {
+		// return a copy since the caller could change it
+		rs := *(v.(*cstypes.RemoteSource))
+		return &rs, nil
+	}
+
 	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, rsRef)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+
+	crs := *rs
+	h.rsCache.Set(remoteSourceCacheKey(rs.ID), &crs)
+	h.rsCache.Set(remoteSourceCacheKey(rs.Name), &crs)
+
 	return rs, nil
 }
 
@@ -56,6 +70,8 @@ type CreateRemoteSourceRequest struct {
 	Oauth2ClientSecret  string
 	SSHHostKey          string
 	SkipSSHHostKeyCheck bool
+	SSHEndpoint         string
+	OrgWebhooks         bool
 	RegistrationEnabled *bool
 	LoginEnabled        *bool
 }
@@ -95,6 +111,11 @@ func (h *ActionHandler) CreateRemoteSource(ctx context.Context, req *CreateRemot
 			return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("remotesource oauth2 client secret required"))
 		}
 	}
+	if req.SSHEndpoint != "" {
+		if err := util.ValidateSSHEndpoint(req.SSHEndpoint); err != nil {
+			return nil, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "invalid remotesource ssh endpoint %q", req.SSHEndpoint))
+		}
+	}
 
 	creq := &csapitypes.CreateUpdateRemoteSourceRequest{
 		Name:                req.Name,
@@ -106,6 +127,8 @@ func (h *ActionHandler) CreateRemoteSource(ctx context.Context, req *CreateRemot
 		Oauth2ClientSecret:  req.Oauth2ClientSecret,
 		SSHHostKey:          req.SSHHostKey,
 		SkipSSHHostKeyCheck: req.SkipSSHHostKeyCheck,
+		SSHEndpoint:         req.SSHEndpoint,
+		OrgWebhooks:         req.OrgWebhooks,
 		RegistrationEnabled: req.RegistrationEnabled,
 		LoginEnabled:        req.LoginEnabled,
 	}
@@ -130,6 +153,8 @@ type UpdateRemoteSourceRequest struct {
 	Oauth2ClientSecret  *string
 	SSHHostKey          *string
 	SkipSSHHostKeyCheck *bool
+	SSHEndpoint         *string
+	OrgWebhooks         *bool
 	RegistrationEnabled *bool
 	LoginEnabled        *bool
 }
@@ -143,6 +168,7 @@ func (h *ActionHandler) UpdateRemoteSource(ctx context.Context, req *UpdateRemot
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	prevName := rs.Name
 
 	if req.Name != nil {
 		rs.Name = *req.Name
@@ -165,6 +191,12 @@ func (h *ActionHandler) UpdateRemoteSource(ctx context.Context, req *UpdateRemot
 	if req.SkipSSHHostKeyCheck != nil {
 		rs.SkipSSHHostKeyCheck = *req.SkipSSHHostKeyCheck
 	}
+	if req.SSHEndpoint != nil {
+		rs.SSHEndpoint = *req.SSHEndpoint
+	}
+	if req.OrgWebhooks != nil {
+		rs.OrgWebhooks = *req.OrgWebhooks
+	}
 	if req.RegistrationEnabled != nil {
 		rs.RegistrationEnabled = req.RegistrationEnabled
 	}
@@ -182,6 +214,8 @@ func (h *ActionHandler) UpdateRemoteSource(ctx context.Context, req *UpdateRemot
 		Oauth2ClientSecret:  rs.Oauth2ClientSecret,
 		SSHHostKey:          rs.SSHHostKey,
 		SkipSSHHostKeyCheck: rs.SkipSSHHostKeyCheck,
+		SSHEndpoint:         rs.SSHEndpoint,
+		OrgWebhooks:         rs.OrgWebhooks,
 		RegistrationEnabled: rs.RegistrationEnabled,
 		LoginEnabled:        rs.LoginEnabled,
 	}
@@ -191,6 +225,9 @@ func (h *ActionHandler) UpdateRemoteSource(ctx context.Context, req *UpdateRemot
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to update remotesource")
 	}
+	h.invalidateRemoteSourceCache(rs)
+	// also remove the entry with the previous name
+	h.rsCache.Delete(remoteSourceCacheKey(prevName))
 	h.log.Info().Msgf("remotesource %s updated", rs.Name)
 
 	return rs, nil
@@ -201,8 +238,32 @@ func (h *ActionHandler) DeleteRemoteSource(ctx context.Context, rsRef string) er
 		return errors.Errorf("user not admin")
 	}
 
+	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, rsRef)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	if _, err := h.configstoreClient.DeleteRemoteSource(ctx, rsRef); err != nil {
 		return errors.Wrapf(err, "failed to delete remote source")
 	}
+	h.invalidateRemoteSourceCache(rs)
+
 	return nil
 }
+
+// GetRemoteSourceRateLimit returns the api rate limit status of the remote
+// source as seen by this gateway instance.
+func (h *ActionHandler) GetRemoteSourceRateLimit(ctx context.Context, rsRef string) (*gitsource.RateLimitStatus, error) {
+	if !common.IsUserAdmin(ctx) {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not admin"))
+	}
+
+	rs, err := h.GetRemoteSource(ctx, rsRef)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	status := gitsource.GetRateLimitStatus(rs.APIURL)
+
+	return &status, nil
+}
@@ -17,9 +17,18 @@ package action
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"path"
 	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"agola.io/agola/internal/config"
 	"agola.io/agola/internal/errors"
@@ -43,6 +52,11 @@ const (
 	agolaDefaultJsonnetConfigFile  = "config.jsonnet"
 	agolaDefaultJsonConfigFile     = "config.json"
 	agolaDefaultYamlConfigFile     = "config.yml"
+	agolaAltYamlConfigFile         = "config.yaml"
+
+	// remote config includes fetch timeout and max size
+	remoteConfigFileTimeout = 30 * time.Second
+	maxRemoteConfigFileSize = 1024 * 1024 // 1MiB
 
 	// List of runs annotations
 	AnnotationRunType   = "run_type"
@@ -66,6 +80,24 @@ const (
 	AnnotationTagLink         = "tag_link"
 	AnnotationPullRequestID   = "pull_request_id"
 	AnnotationPullRequestLink = "pull_request_link"
+
+	// AnnotationSchedule is the name of the project schedule that created the
+	// run and AnnotationScheduleTime its activation time
+	AnnotationSchedule     = "schedule"
+	AnnotationScheduleTime = "schedule_time"
+
+	// AnnotationRerunOf is the id of the run rerun with a new config
+	AnnotationRerunOf = "rerun_of"
+	// AnnotationConfigCommitSHA is the commit sha the run config was fetched
+	// from when different from the run commit sha
+	AnnotationConfigCommitSHA = "config_commit_sha"
+
+	// AnnotationParentRunID and AnnotationParentProjectID are the upstream run
+	// and project of a downstream run and AnnotationParentTrigger the index of
+	// the upstream run trigger that created it
+	AnnotationParentRunID     = "parent_run_id"
+	AnnotationParentProjectID = "parent_project_id"
+	AnnotationParentTrigger   = "parent_trigger"
 )
 
 var (
@@ -97,6 +129,7 @@ type GetRunsRequest struct {
 	SubGroup        string
 	PhaseFilter     []string
 	ResultFilter    []string
+	LabelFilter     []string
 	StartRunCounter uint64
 	Limit           int
 	Asc             bool
@@ -114,7 +147,7 @@ func (h *ActionHandler) GetRuns(ctx context.Context, req *GetRunsRequest) (*rsap
 	group := scommon.GenBaseRunGroup(req.GroupType, groupID)
 	group = path.Join(group, req.SubGroup)
 
-	runsResp, _, err := h.runserviceClient.GetGroupRuns(ctx, req.PhaseFilter, req.ResultFilter, group, nil, req.StartRunCounter, req.Limit, req.Asc)
+	runsResp, _, err := h.runserviceClient.GetGroupRuns(ctx, req.PhaseFilter, req.ResultFilter, req.LabelFilter, group, nil, req.StartRunCounter, req.Limit, req.Asc)
 	if err != nil {
 		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
 	}
@@ -130,14 +163,15 @@ type GetLogsRequest struct {
 	Setup     bool
 	Step      int
 	Follow    bool
+	LogsRange *rsapitypes.LogsRange
 }
 
 func (h *ActionHandler) GetLogs(ctx context.Context, req *GetLogsRequest) (*http.Response, error) {
-	canGetRun, groupID, err := h.CanGetRun(ctx, req.GroupType, req.Ref)
+	canGetRunLogs, groupID, err := h.CanGetRunLogs(ctx, req.GroupType, req.Ref)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to determine permissions")
 	}
-	if !canGetRun {
+	if !canGetRunLogs {
 		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
 	}
 
@@ -148,7 +182,7 @@ func (h *ActionHandler) GetLogs(ctx context.Context, req *GetLogsRequest) (*http
 		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
 	}
 
-	resp, err := h.runserviceClient.GetLogs(ctx, runResp.Run.ID, req.TaskID, req.Setup, req.Step, req.Follow)
+	resp, err := h.runserviceClient.GetLogs(ctx, runResp.Run.ID, req.TaskID, req.Setup, req.Step, req.Follow, req.LogsRange)
 	if err != nil {
 		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
 	}
@@ -156,6 +190,39 @@ func (h *ActionHandler) GetLogs(ctx context.Context, req *GetLogsRequest) (*http
 	return resp, nil
 }
 
+type GetLogsInfoRequest struct {
+	GroupType scommon.GroupType
+	Ref       string
+	RunNumber uint64
+	TaskID    string
+	Setup     bool
+	Step      int
+}
+
+func (h *ActionHandler) GetLogsInfo(ctx context.Context, req *GetLogsInfoRequest) (*rsapitypes.LogsInfoResponse, error) {
+	canGetRunLogs, groupID, err := h.CanGetRunLogs(ctx, req.GroupType, req.Ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine permissions")
+	}
+	if !canGetRunLogs {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	group := scommon.GenBaseRunGroup(req.GroupType, groupID)
+
+	runResp, _, err := h.runserviceClient.GetRunByGroup(ctx, group, req.RunNumber, nil)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	logsInfo, _, err := h.runserviceClient.GetLogsInfo(ctx, runResp.Run.ID, req.TaskID, req.Setup, req.Step)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	return logsInfo, nil
+}
+
 type DeleteLogsRequest struct {
 	GroupType scommon.GroupType
 	Ref       string
@@ -188,12 +255,142 @@ func (h *ActionHandler) DeleteLogs(ctx context.Context, req *DeleteLogsRequest)
 	return nil
 }
 
+type GetRunArtifactsRequest struct {
+	GroupType scommon.GroupType
+	Ref       string
+	RunNumber uint64
+}
+
+type RunArtifact struct {
+	TaskID   string
+	TaskName string
+	Path     string
+	Size     int64
+}
+
+func (h *ActionHandler) GetRunArtifacts(ctx context.Context, req *GetRunArtifactsRequest) ([]*RunArtifact, error) {
+	canGetRunLogs, groupID, err := h.CanGetRunLogs(ctx, req.GroupType, req.Ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine permissions")
+	}
+	if !canGetRunLogs {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	group := scommon.GenBaseRunGroup(req.GroupType, groupID)
+
+	runResp, _, err := h.runserviceClient.GetRunByGroup(ctx, group, req.RunNumber, nil)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	artifactsResp, _, err := h.runserviceClient.GetRunArtifacts(ctx, runResp.Run.ID)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	artifacts := make([]*RunArtifact, len(artifactsResp.Artifacts))
+	for i, a := range artifactsResp.Artifacts {
+		artifacts[i] = &RunArtifact{
+			TaskID: a.TaskID,
+			Path:   a.Path,
+			Size:   a.Size,
+		}
+		if rct, ok := runResp.RunConfig.Tasks[a.TaskID]; ok {
+			artifacts[i].TaskName = rct.Name
+		}
+	}
+
+	return artifacts, nil
+}
+
+type GetRunTestReportsRequest struct {
+	GroupType scommon.GroupType
+	Ref       string
+	RunNumber uint64
+}
+
+type RunTestReport struct {
+	TaskID   string
+	TaskName string
+	Report   *rstypes.TestReport
+}
+
+func (h *ActionHandler) GetRunTestReports(ctx context.Context, req *GetRunTestReportsRequest) ([]*RunTestReport, error) {
+	canGetRunLogs, groupID, err := h.CanGetRunLogs(ctx, req.GroupType, req.Ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine permissions")
+	}
+	if !canGetRunLogs {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	group := scommon.GenBaseRunGroup(req.GroupType, groupID)
+
+	runResp, _, err := h.runserviceClient.GetRunByGroup(ctx, group, req.RunNumber, nil)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	testReportsResp, _, err := h.runserviceClient.GetRunTestReports(ctx, runResp.Run.ID)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	testReports := make([]*RunTestReport, len(testReportsResp.TestReports))
+	for i, tr := range testReportsResp.TestReports {
+		testReports[i] = &RunTestReport{
+			TaskID: tr.TaskID,
+			Report: tr.Report,
+		}
+		if rct, ok := runResp.RunConfig.Tasks[tr.TaskID]; ok {
+			testReports[i].TaskName = rct.Name
+		}
+	}
+
+	return testReports, nil
+}
+
+type GetRunTaskArtifactRequest struct {
+	GroupType scommon.GroupType
+	Ref       string
+	RunNumber uint64
+	TaskID    string
+	Path      string
+}
+
+func (h *ActionHandler) GetRunTaskArtifact(ctx context.Context, req *GetRunTaskArtifactRequest) (*http.Response, error) {
+	canGetRunLogs, groupID, err := h.CanGetRunLogs(ctx, req.GroupType, req.Ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine permissions")
+	}
+	if !canGetRunLogs {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	group := scommon.GenBaseRunGroup(req.GroupType, groupID)
+
+	runResp, _, err := h.runserviceClient.GetRunByGroup(ctx, group, req.RunNumber, nil)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	resp, err := h.runserviceClient.GetRunTaskArtifact(ctx, runResp.Run.ID, req.TaskID, req.Path)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+	}
+
+	return resp, nil
+}
+
 type RunActionType string
 
 const (
 	RunActionTypeRestart RunActionType = "restart"
 	RunActionTypeCancel  RunActionType = "cancel"
 	RunActionTypeStop    RunActionType = "stop"
+
+	RunActionTypeRerunWithConfig RunActionType = "rerunwithconfig"
 )
 
 type RunActionsRequest struct {
@@ -204,6 +401,9 @@ type RunActionsRequest struct {
 
 	// Restart
 	FromStart bool
+
+	// RerunWithConfig
+	ConfigRef string
 }
 
 func (h *ActionHandler) RunAction(ctx context.Context, req *RunActionsRequest) (*rsapitypes.RunResponse, error) {
@@ -254,6 +454,11 @@ func (h *ActionHandler) RunAction(ctx context.Context, req *RunActionsRequest) (
 			return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
 		}
 
+	case RunActionTypeRerunWithConfig:
+		if err := h.rerunWithConfig(ctx, runResp.Run, req.ConfigRef); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
 	default:
 		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("wrong run action type %q", req.ActionType))
 	}
@@ -261,6 +466,88 @@ func (h *ActionHandler) RunAction(ctx context.Context, req *RunActionsRequest) (
 	return runResp, nil
 }
 
+// rerunWithConfig creates a new run for the same commit of the provided run
+// using the config fetched at configRef (defaults to the run ref). It's
+// useful when the config of a run was broken but the code is fine.
+func (h *ActionHandler) rerunWithConfig(ctx context.Context, run *rstypes.Run, configRef string) error {
+	if run.Annotations[AnnotationRunType] != string(itypes.RunTypeProject) {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("only project runs can be rerun with a new config"))
+	}
+
+	projectID := run.Annotations[AnnotationProjectID]
+	p, _, err := h.configstoreClient.GetProject(ctx, projectID)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q", projectID))
+	}
+
+	gitSource, rs, repoInfo, err := h.projectUserGitSource(ctx, p)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if configRef == "" {
+		configRef = run.Annotations[AnnotationRef]
+	}
+	ref, err := gitSource.GetRef(p.RepositoryPath, configRef)
+	if err != nil {
+		return util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "failed to get ref information from git source for ref %q", configRef))
+	}
+
+	// recreate only the same run. If the previous config couldn't be parsed
+	// recreate all the runs
+	var runNames []string
+	if run.Name != rstypes.RunGenericSetupErrorName {
+		runNames = []string{run.Name}
+	}
+
+	cloneURL, err := scommon.GetSSHCloneURL(rs, repoInfo.SSHCloneURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// use remotesource skipSSHHostKeyCheck config and override with project config if set to true there
+	skipSSHHostKeyCheck := rs.SkipSSHHostKeyCheck
+	if p.SkipSSHHostKeyCheck {
+		skipSSHHostKeyCheck = p.SkipSSHHostKeyCheck
+	}
+
+	req := &CreateRunRequest{
+		RunType:            itypes.RunTypeProject,
+		RefType:            itypes.RunRefType(run.Annotations[AnnotationRefType]),
+		RunCreationTrigger: itypes.RunCreationTriggerTypeManual,
+
+		Project:   p.Project,
+		RepoPath:  p.RepositoryPath,
+		GitSource: gitSource,
+		CommitSHA: run.Annotations[AnnotationCommitSHA],
+		Message:   run.Annotations[AnnotationMessage],
+		Branch:    run.Annotations[AnnotationBranch],
+		Tag:       run.Annotations[AnnotationTag],
+		Ref:       run.Annotations[AnnotationRef],
+		// since we don't know if the pull request comes from a forked
+		// repository, variables will be passed only if enabled in the project
+		PullRequestID:       run.Annotations[AnnotationPullRequestID],
+		PRFromSameRepo:      false,
+		Schedule:            run.Annotations[AnnotationSchedule],
+		SSHPrivKey:          p.SSHPrivateKey,
+		SSHHostKey:          rs.SSHHostKey,
+		SkipSSHHostKeyCheck: skipSSHHostKeyCheck,
+		CloneURL:            cloneURL,
+
+		CommitLink:      run.Annotations[AnnotationCommitLink],
+		BranchLink:      run.Annotations[AnnotationBranchLink],
+		TagLink:         run.Annotations[AnnotationTagLink],
+		PullRequestLink: run.Annotations[AnnotationPullRequestLink],
+
+		RunNames: runNames,
+
+		ConfigCommitSHA: ref.CommitSHA,
+		RerunOfRunID:    run.ID,
+	}
+
+	return h.CreateRuns(ctx, req)
+}
+
 type RunTaskActionType string
 
 const (
@@ -274,14 +561,16 @@ type RunTaskActionsRequest struct {
 	TaskID    string
 
 	ActionType RunTaskActionType
+	// Comment is the optional approval comment
+	Comment string
 }
 
 func (h *ActionHandler) RunTaskAction(ctx context.Context, req *RunTaskActionsRequest) error {
-	canDoRunAction, groupID, err := h.CanDoRunActions(ctx, req.GroupType, req.Ref)
+	canGetRun, groupID, err := h.CanGetRun(ctx, req.GroupType, req.Ref)
 	if err != nil {
 		return errors.Wrapf(err, "failed to determine permissions")
 	}
-	if !canDoRunAction {
+	if !canGetRun {
 		return util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
 	}
 
@@ -305,8 +594,23 @@ func (h *ActionHandler) RunTaskAction(ctx context.Context, req *RunTaskActionsRe
 		if !ok {
 			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("run %q doesn't have task %q", req.RunNumber, req.TaskID))
 		}
+		rct := runResp.RunConfig.Tasks[req.TaskID]
+
+		canApprove, err := h.CanApproveRunTask(ctx, req.GroupType, req.Ref, rct)
+		if err != nil {
+			return errors.Wrapf(err, "failed to determine permissions")
+		}
+		if !canApprove {
+			return util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+		}
+
+		curUser, _, err := h.configstoreClient.GetUser(ctx, curUserID)
+		if err != nil {
+			return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q", curUserID))
+		}
 
 		approvers := []string{}
+		approvals := []*scommon.RunTaskApproval{}
 		annotations := map[string]string{}
 		if rt.Annotations != nil {
 			annotations = rt.Annotations
@@ -317,6 +621,12 @@ func (h *ActionHandler) RunTaskAction(ctx context.Context, req *RunTaskActionsRe
 				return errors.Wrapf(err, "failed to unmarshal run task approvers annotation")
 			}
 		}
+		approvalsAnnotation, ok := annotations[scommon.ApprovalsAnnotation]
+		if ok {
+			if err := json.Unmarshal([]byte(approvalsAnnotation), &approvals); err != nil {
+				return errors.Wrapf(err, "failed to unmarshal run task approvals annotation")
+			}
+		}
 
 		for _, approver := range approvers {
 			if approver == curUserID {
@@ -324,13 +634,24 @@ func (h *ActionHandler) RunTaskAction(ctx context.Context, req *RunTaskActionsRe
 			}
 		}
 		approvers = append(approvers, curUserID)
+		approvals = append(approvals, &scommon.RunTaskApproval{
+			UserID:   curUser.ID,
+			UserName: curUser.Name,
+			Comment:  req.Comment,
+			Time:     time.Now(),
+		})
 
 		approversj, err := json.Marshal(approvers)
 		if err != nil {
 			return errors.Wrapf(err, "failed to marshal run task approvers annotation")
 		}
+		approvalsj, err := json.Marshal(approvals)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal run task approvals annotation")
+		}
 
 		annotations[scommon.ApproversAnnotation] = string(approversj)
+		annotations[scommon.ApprovalsAnnotation] = string(approvalsj)
 
 		rsreq := &rsapitypes.RunTaskActionsRequest{
 			ActionType:              rsapitypes.RunTaskActionTypeSetAnnotations,
@@ -354,21 +675,30 @@ type CreateRunRequest struct {
 	RefType            itypes.RunRefType
 	RunCreationTrigger itypes.RunCreationTriggerType
 
-	Project             *cstypes.Project
-	User                *cstypes.User
-	RepoPath            string
-	GitSource           gitsource.GitSource
-	CommitSHA           string
-	Message             string
-	Branch              string
-	Tag                 string
-	Ref                 string
-	PullRequestID       string
-	PRFromSameRepo      bool
+	Project        *cstypes.Project
+	User           *cstypes.User
+	RepoPath       string
+	GitSource      gitsource.GitSource
+	CommitSHA      string
+	Message        string
+	Branch         string
+	Tag            string
+	Ref            string
+	PullRequestID  string
+	PRFromSameRepo bool
+	// Schedule is the name of the project schedule creating the run and
+	// ScheduleTime its activation time. Only used with the schedule ref type
+	Schedule            string
+	ScheduleTime        time.Time
 	SSHPrivKey          string
 	SSHHostKey          string
 	SkipSSHHostKeyCheck bool
 	CloneURL            string
+	// CloneRepoTokenPath is the gitserver repository path of user direct
+	// runs. The runservice provides to the run tasks a token granting read
+	// access to it, generated when the task is sent to the executor, so no
+	// expiring credential is saved in CloneURL or in the run environment
+	CloneRepoTokenPath string
 
 	WebhookEvent  string
 	WebhookSender string
@@ -382,9 +712,38 @@ type CreateRunRequest struct {
 	// commit compare link
 	CompareLink string
 
+	// ChangedFiles are the files changed by the pushed commits, used to match
+	// the when paths conditions. Nil when unknown
+	ChangedFiles []string
+	// CompareBase, when ChangedFiles is nil, is the commit or ref used to get
+	// the changed files from the git source
+	CompareBase string
+
 	// fields only used with user direct runs
 	UserRunRepoUUID string
-	Variables       map[string]string
+	// Variables are the user direct run variables. With project runs they
+	// override the project variables with the same name
+	Variables map[string]string
+	// Inputs are the config inputs values provided when manually creating a
+	// project run
+	Inputs map[string]string
+	// RunNames and TaskNames, when provided, limit the created runs and run
+	// tasks to the ones with the provided names (tasks dependencies are
+	// automatically included)
+	RunNames  []string
+	TaskNames []string
+
+	// ConfigCommitSHA, when provided, is the commit used to fetch the config
+	// files instead of CommitSHA
+	ConfigCommitSHA string
+	// RerunOfRunID is the id of the run rerun with a new config
+	RerunOfRunID string
+
+	// ParentRunID, ParentProjectID and ParentTrigger are only used with
+	// downstream runs and define the upstream run trigger creating them
+	ParentRunID     string
+	ParentProjectID string
+	ParentTrigger   int
 }
 
 func (h *ActionHandler) CreateRuns(ctx context.Context, req *CreateRunRequest) error {
@@ -411,7 +770,7 @@ func (h *ActionHandler) CreateRuns(ctx context.Context, req *CreateRunRequest) e
 	}
 
 	switch req.RefType {
-	case itypes.RunRefTypeBranch:
+	case itypes.RunRefTypeBranch, itypes.RunRefTypeSchedule:
 		groupType = scommon.GroupTypeBranch
 		group = req.Branch
 	case itypes.RunRefTypeTag:
@@ -455,16 +814,32 @@ func (h *ActionHandler) CreateRuns(ctx context.Context, req *CreateRunRequest) e
 	if req.SkipSSHHostKeyCheck {
 		env["AGOLA_SKIPSSHHOSTKEYCHECK"] = "1"
 	}
+	if req.RunType == itypes.RunTypeProject && req.Project.UseDepsProxy && h.depsProxyURL != "" {
+		for k, v := range depsProxyEnv(h.depsProxyURL) {
+			env[k] = v
+		}
+	}
 
 	var variables map[string]string
+	var sealedValues map[string]rstypes.SealedValue
 	if req.RunType == itypes.RunTypeProject {
 		if req.RefType != itypes.RunRefTypePullRequest || req.PRFromSameRepo || req.Project.PassVarsToForkedPR {
 			var err error
-			variables, err = h.genRunVariables(ctx, req)
+			variables, sealedValues, err = h.genRunVariables(ctx, req)
 			if err != nil {
 				return errors.WithStack(err)
 			}
 		}
+		if len(req.Variables) > 0 {
+			if variables == nil {
+				variables = map[string]string{}
+			}
+			for k, v := range req.Variables {
+				variables[k] = v
+				// a sealed value provided by the user must never be decrypted
+				delete(sealedValues, v)
+			}
+		}
 	} else {
 		variables = req.Variables
 	}
@@ -500,6 +875,26 @@ func (h *ActionHandler) CreateRuns(ctx context.Context, req *CreateRunRequest) e
 		annotations[AnnotationPullRequestID] = req.PullRequestID
 		annotations[AnnotationPullRequestLink] = req.PullRequestLink
 	}
+	if req.Schedule != "" {
+		annotations[AnnotationSchedule] = req.Schedule
+		if !req.ScheduleTime.IsZero() {
+			annotations[AnnotationScheduleTime] = req.ScheduleTime.UTC().Format(time.RFC3339)
+		}
+	}
+	if req.RerunOfRunID != "" {
+		annotations[AnnotationRerunOf] = req.RerunOfRunID
+	}
+	if req.ParentRunID != "" {
+		annotations[AnnotationParentRunID] = req.ParentRunID
+		annotations[AnnotationParentProjectID] = req.ParentProjectID
+		annotations[AnnotationParentTrigger] = strconv.Itoa(req.ParentTrigger)
+	}
+
+	configCommitSHA := req.CommitSHA
+	if req.ConfigCommitSHA != "" && req.ConfigCommitSHA != req.CommitSHA {
+		configCommitSHA = req.ConfigCommitSHA
+		annotations[AnnotationConfigCommitSHA] = configCommitSHA
+	}
 
 	// Since user belong to the same group (the user uuid) we needed another way to differentiate the cache. We'll use the user uuid + the user run repo uuid
 	var cacheGroup string
@@ -507,23 +902,15 @@ func (h *ActionHandler) CreateRuns(ctx context.Context, req *CreateRunRequest) e
 		cacheGroup = req.User.ID + "-" + req.UserRunRepoUUID
 	}
 
-	data, filename, err := h.fetchConfigFiles(ctx, req.GitSource, req.RepoPath, req.CommitSHA)
+	data, filename, err := h.fetchConfigFiles(ctx, req.GitSource, req.RepoPath, configCommitSHA)
 	if err != nil {
 		return util.NewAPIError(util.ErrInternal, errors.Wrapf(err, "failed to fetch config file"))
 	}
 	h.log.Debug().Msgf("data: %s", data)
 
-	var configFormat config.ConfigFormat
-	switch path.Ext(filename) {
-	case ".star":
-		configFormat = config.ConfigFormatStarlark
-	case ".jsonnet":
-		configFormat = config.ConfigFormatJsonnet
-	case ".json":
-		fallthrough
-	case ".yml":
-		configFormat = config.ConfigFormatJSON
-
+	configFormat, err := config.FormatFromFilename(filename)
+	if err != nil {
+		return util.NewAPIError(util.ErrInternal, errors.WithStack(err))
 	}
 
 	configContext := &config.ConfigContext{
@@ -532,7 +919,26 @@ func (h *ActionHandler) CreateRuns(ctx context.Context, req *CreateRunRequest) e
 		Branch:        req.Branch,
 		Tag:           req.Tag,
 		PullRequestID: req.PullRequestID,
+		Schedule:      req.Schedule,
 		CommitSHA:     req.CommitSHA,
+		Env:           h.configEnv,
+		FetchFile: func(file string) ([]byte, error) {
+			return req.GitSource.GetFile(req.RepoPath, configCommitSHA, file)
+		},
+		FetchRemoteFile: func(u string) ([]byte, error) {
+			return fetchRemoteConfigFile(ctx, u)
+		},
+		FetchRepoFile: func(repoPath, ref, file string) ([]byte, error) {
+			// the file is read with the project linked account so don't let
+			// forked pull requests read other repositories
+			if req.RefType == itypes.RunRefTypePullRequest && !req.PRFromSameRepo {
+				return nil, errors.Errorf("repo includes aren't allowed in forked pull requests")
+			}
+			if err := checkRepoInclude(req.RepoPath, repoPath); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return req.GitSource.GetFile(repoPath, ref, file)
+		},
 	}
 
 	config, err := config.ParseConfig([]byte(data), configFormat, configContext)
@@ -558,18 +964,131 @@ func (h *ActionHandler) CreateRuns(ctx context.Context, req *CreateRunRequest) e
 		return nil
 	}
 
-	for _, run := range config.Runs {
+	for _, runName := range req.RunNames {
+		if !configHasRun(config, runName) {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("run %q doesn't exist in config", runName))
+		}
+	}
+	for _, taskName := range req.TaskNames {
+		if !configHasTask(config, req.RunNames, taskName) {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("task %q doesn't exist in config", taskName))
+		}
+	}
+
+	// the inputs values are provided only when manually creating a project
+	// run, the other runs use the inputs defaults
+	manualInputs := req.RunType == itypes.RunTypeProject && req.RunCreationTrigger == itypes.RunCreationTriggerTypeManual && req.RerunOfRunID == ""
+	inputs, err := config.InputsValues(req.Inputs, manualInputs)
+	if err != nil {
+		return util.NewAPIError(util.ErrBadRequest, errors.WithStack(err))
+	}
+	if len(inputs) > 0 {
+		if variables == nil {
+			variables = map[string]string{}
+		}
+		for name, value := range inputs {
+			// a project variable with the same name of an input wins over
+			// the input default value, only an explicitly provided input
+			// value overrides it
+			if _, provided := req.Inputs[name]; !provided {
+				if v, ok := variables[name]; ok {
+					env[inputEnvName(name)] = v
+					continue
+				}
+			}
+			variables[name] = value
+			env[inputEnvName(name)] = value
+			delete(sealedValues, value)
+		}
+	}
+
+	// create the runs after the runs they depend on
+	runs, err := configRunsByDependencies(config)
+	if err != nil {
+		return util.NewAPIError(util.ErrBadRequest, err)
+	}
+
+	concurrencyGroups, err := h.runConcurrencyGroups(ctx, req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	changedFiles := req.ChangedFiles
+	if changedFiles == nil && configHasPathsConditions(config) {
+		changedFiles = h.compareChangedFiles(req)
+	}
+
+	// ids of the created runs by run name
+	createdRuns := map[string]string{}
+
+	for _, run := range runs {
 		if SkipRunMessage.MatchString(req.Message) {
 			h.log.Debug().Msgf("skipping run since special commit message")
+			h.reportSkippedRun(req, run.Name, "commit message contains [ci skip]")
+			continue
+		}
+
+		if len(req.RunNames) > 0 && !util.StringInSlice(req.RunNames, run.Name) {
+			h.log.Debug().Msgf("skipping run %q since not selected", run.Name)
 			continue
 		}
 
-		if match := types.MatchWhen(run.When.ToWhen(), req.RefType, req.Branch, req.Tag, req.Ref); !match {
+		if match := types.MatchWhen(run.When.ToWhen(), req.RefType, req.Branch, req.Tag, req.Ref, req.Schedule); !match {
 			h.log.Debug().Msgf("skipping run since when condition doesn't match")
+			h.reportSkippedRun(req, run.Name, "when conditions don't match")
 			continue
 		}
 
-		rcts := runconfig.GenRunConfigTasks(util.DefaultUUIDGenerator{}, config, run.Name, variables, req.RefType, req.Branch, req.Tag, req.Ref)
+		if match := types.MatchWhenMessage(run.When.ToWhen(), req.Message); !match {
+			h.log.Debug().Msgf("skipping run since when message condition doesn't match")
+			h.reportSkippedRun(req, run.Name, "commit message doesn't match the message conditions")
+			continue
+		}
+
+		if match := types.MatchWhenExpression(run.When.ToWhen(), annotations, variables); !match {
+			h.log.Debug().Msgf("skipping run since when expression doesn't match")
+			h.reportSkippedRun(req, run.Name, "when expression doesn't match")
+			continue
+		}
+
+		// when the run paths conditions don't match create a minimal run
+		// (all tasks skipped) if requested so the commit status is reported
+		minimalRun := false
+		if match := types.MatchWhenPaths(run.When.ToWhen(), changedFiles); !match {
+			if !run.MinimalRun {
+				h.log.Debug().Msgf("skipping run since when paths condition doesn't match")
+				h.reportSkippedRun(req, run.Name, "no changed files match the paths conditions")
+				continue
+			}
+			minimalRun = true
+		}
+
+		dependsOn, missingDep := runDependencies(run, createdRuns, req.RunNames)
+		if missingDep != "" {
+			h.log.Debug().Msgf("skipping run %q since the run %q it depends on wasn't created", run.Name, missingDep)
+			h.reportSkippedRun(req, run.Name, fmt.Sprintf("depends on skipped run %q", missingDep))
+			continue
+		}
+
+		rcts := runconfig.GenRunConfigTasks(util.DefaultUUIDGenerator{}, config, run.Name, variables, annotations, req.RefType, req.Branch, req.Tag, req.Ref, req.Schedule, req.Message, changedFiles)
+		if minimalRun {
+			for _, rct := range rcts {
+				rct.Skip = true
+			}
+		}
+
+		if len(req.TaskNames) > 0 {
+			rcts = runconfig.FilterRunConfigTasks(rcts, req.TaskNames)
+			if len(rcts) == 0 {
+				h.log.Debug().Msgf("skipping run %q since it doesn't contain any of the selected tasks", run.Name)
+				continue
+			}
+		}
+
+		runConcurrencyGroups := concurrencyGroups
+		if run.Concurrency != nil {
+			runConcurrencyGroups = append(append([]*rstypes.RunConcurrencyGroup{}, concurrencyGroups...), configConcurrencyGroup(baseGroupType, baseGroupID, run.Concurrency))
+		}
 
 		createRunReq := &rsapitypes.RunCreateRequest{
 			RunConfigTasks:    rcts,
@@ -579,22 +1098,217 @@ func (h *ActionHandler) CreateRuns(ctx context.Context, req *CreateRunRequest) e
 			StaticEnvironment: env,
 			Annotations:       annotations,
 			CacheGroup:        cacheGroup,
+			RepoTokenPath:     req.CloneRepoTokenPath,
+			Labels:            run.Labels,
+			DependsOn:         dependsOn,
+			ConcurrencyGroups: runConcurrencyGroups,
+			CancelSuperseded:  cancelSuperseded(req),
+			FailFast:          run.FailFast,
+			Triggers:          runConfigTriggers(run.Triggers),
+			SealedValues:      sealedValues,
 		}
 
-		if _, _, err := h.runserviceClient.CreateRun(ctx, createRunReq); err != nil {
+		rr, _, err := h.runserviceClient.CreateRun(ctx, createRunReq)
+		if err != nil {
 			h.log.Err(err).Msgf("failed to create run")
 			return util.NewAPIError(util.KindFromRemoteError(err), err)
 		}
+		createdRuns[run.Name] = rr.Run.ID
 	}
 
 	return nil
 }
 
+// inputEnvName returns the name of the environment variable containing the
+// config input value
+func inputEnvName(name string) string {
+	return "AGOLA_INPUT_" + strings.ToUpper(name)
+}
+
+// runConcurrencyGroups returns the concurrency groups limiting a project run:
+// its organization, if the project belongs to one, and the project
+func (h *ActionHandler) runConcurrencyGroups(ctx context.Context, req *CreateRunRequest) ([]*rstypes.RunConcurrencyGroup, error) {
+	if req.RunType != itypes.RunTypeProject {
+		return nil, nil
+	}
+
+	p, _, err := h.configstoreClient.GetProject(ctx, req.Project.ID)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q", req.Project.ID))
+	}
+
+	var groups []*rstypes.RunConcurrencyGroup
+	if p.OwnerType == cstypes.ObjectKindOrg {
+		org, _, err := h.configstoreClient.GetOrg(ctx, p.OwnerID)
+		if err != nil {
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get org %q", p.OwnerID))
+		}
+		if l := org.ConcurrencyLimits; l.MaxRunningRuns > 0 || l.MaxRunningTasks > 0 {
+			groups = append(groups, &rstypes.RunConcurrencyGroup{
+				Name:            path.Join("/", string(cstypes.ObjectKindOrg), org.ID),
+				MaxRunningRuns:  l.MaxRunningRuns,
+				MaxRunningTasks: l.MaxRunningTasks,
+			})
+		}
+	}
+	if l := p.ConcurrencyLimits; l.MaxRunningRuns > 0 || l.MaxRunningTasks > 0 {
+		groups = append(groups, &rstypes.RunConcurrencyGroup{
+			Name:            path.Join("/", string(cstypes.ObjectKindProject), p.ID),
+			MaxRunningRuns:  l.MaxRunningRuns,
+			MaxRunningTasks: l.MaxRunningTasks,
+		})
+	}
+
+	return groups, nil
+}
+
+// cancelSuperseded reports if the run created by a push or a pull request
+// update must stop the older runs of the same ref
+func cancelSuperseded(req *CreateRunRequest) bool {
+	if req.RunType != itypes.RunTypeProject || !req.Project.CancelSupersededRuns {
+		return false
+	}
+	if req.RunCreationTrigger != itypes.RunCreationTriggerTypeWebhook && req.RunCreationTrigger != itypes.RunCreationTriggerTypePoll {
+		return false
+	}
+	return req.RefType == itypes.RunRefTypeBranch || req.RefType == itypes.RunRefTypePullRequest
+}
+
+// configConcurrencyGroup returns the concurrency group of a run defining a
+// config concurrency group. The group name is scoped to the project (or user)
+// and only one run of the group can be running
+func configConcurrencyGroup(baseGroupType scommon.GroupType, baseGroupID string, c *config.Concurrency) *rstypes.RunConcurrencyGroup {
+	return &rstypes.RunConcurrencyGroup{
+		Name:             path.Join(scommon.GenBaseRunGroup(baseGroupType, baseGroupID), "concurrency", url.PathEscape(c.Group)),
+		MaxRunningRuns:   1,
+		CancelInProgress: c.Policy == config.ConcurrencyPolicyCancelInProgress,
+	}
+}
+
+// runDependencies returns the ids of the created runs the run depends on. The
+// dependencies on runs not selected by the user are ignored. If a dependency
+// run has been skipped its name is returned.
+func runDependencies(run *config.Run, createdRuns map[string]string, runNames []string) ([]string, string) {
+	dependsOn := []string{}
+	for _, d := range run.DependsOn {
+		if runID, ok := createdRuns[d]; ok {
+			dependsOn = append(dependsOn, runID)
+			continue
+		}
+		if len(runNames) > 0 && !util.StringInSlice(runNames, d) {
+			continue
+		}
+		return nil, d
+	}
+
+	return dependsOn, ""
+}
+
+// depsProxyEnv returns the environment variables configuring the package
+// managers to use the dependencies proxy
+func depsProxyEnv(depsProxyURL string) map[string]string {
+	u := strings.TrimSuffix(depsProxyURL, "/")
+
+	return map[string]string{
+		// fallback to direct fetches for modules not available in the
+		// upstream proxy (i.e. private modules)
+		"GOPROXY":             u + "/gomod,direct",
+		"npm_config_registry": u + "/npm/",
+		"PIP_INDEX_URL":       u + "/pypi/simple/",
+	}
+}
+
+// reportSkippedRun creates a commit status for a skipped project run, if
+// enabled in the project, so users can distinguish between commits not seen
+// and commits intentionally skipped. Errors are only logged since they
+// shouldn't block the creation of the other runs.
+func (h *ActionHandler) reportSkippedRun(req *CreateRunRequest, runName, reason string) {
+	if req.RunType != itypes.RunTypeProject || !req.Project.ReportSkippedRuns {
+		return
+	}
+
+	context := fmt.Sprintf("%s/%s/%s", h.agolaID, req.Project.Name, runName)
+	description := fmt.Sprintf("Skipped by agola: %s", reason)
+	if err := req.GitSource.CreateCommitStatus(req.RepoPath, req.CommitSHA, gitsource.CommitStatusSkipped, "", description, context); err != nil {
+		h.log.Err(err).Msgf("failed to create skipped run commit status")
+	}
+}
+
+// configRunsByDependencies returns the config runs sorted so every run comes
+// after the runs it depends on
+func configRunsByDependencies(c *config.Config) ([]*config.Run, error) {
+	runs, err := config.SortRunsByDependencies(c.Runs)
+	return runs, errors.WithStack(err)
+}
+
+func configHasRun(c *config.Config, runName string) bool {
+	for _, run := range c.Runs {
+		if run.Name == runName {
+			return true
+		}
+	}
+	return false
+}
+
+// configHasTask reports if a task with the provided name exists in one of the
+// provided runs (or in any run if runNames is empty)
+func configHasTask(c *config.Config, runNames []string, taskName string) bool {
+	for _, run := range c.Runs {
+		if len(runNames) > 0 && !util.StringInSlice(runNames, run.Name) {
+			continue
+		}
+		for _, task := range run.Tasks {
+			if task.Name == taskName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// configHasPathsConditions reports if a run or a task defines when paths
+// conditions
+func configHasPathsConditions(c *config.Config) bool {
+	for _, run := range c.Runs {
+		if run.When != nil && run.When.Paths != nil {
+			return true
+		}
+		for _, task := range run.Tasks {
+			if task.When != nil && task.When.Paths != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compareChangedFiles returns the files changed between the request compare
+// base and commit using the git source compare api. It returns nil (the
+// changed files are unknown and the paths conditions always match) when not
+// supported by the git source or on errors.
+func (h *ActionHandler) compareChangedFiles(req *CreateRunRequest) []string {
+	if req.CompareBase == "" {
+		return nil
+	}
+	compareSource, ok := req.GitSource.(gitsource.CompareSource)
+	if !ok {
+		return nil
+	}
+
+	changedFiles, err := compareSource.CompareCommits(req.RepoPath, req.CompareBase, req.CommitSHA)
+	if err != nil {
+		h.log.Warn().Err(err).Msgf("failed to get the files changed between %q and %q", req.CompareBase, req.CommitSHA)
+		return nil
+	}
+
+	return changedFiles
+}
+
 func (h *ActionHandler) fetchConfigFiles(ctx context.Context, gitSource gitsource.GitSource, repopath, commitSHA string) ([]byte, string, error) {
 	var data []byte
 	var filename string
 	err := util.ExponentialBackoff(ctx, util.FetchFileBackoff, func() (bool, error) {
-		for _, filename = range []string{agolaDefaultStarlarkConfigFile, agolaDefaultJsonnetConfigFile, agolaDefaultJsonConfigFile, agolaDefaultYamlConfigFile} {
+		for _, filename = range []string{agolaDefaultStarlarkConfigFile, agolaDefaultJsonnetConfigFile, agolaDefaultJsonConfigFile, agolaDefaultYamlConfigFile, agolaAltYamlConfigFile} {
 			var err error
 			data, err = gitSource.GetFile(repopath, commitSHA, path.Join(agolaDefaultConfigDir, filename))
 			if err == nil {
@@ -610,13 +1324,100 @@ func (h *ActionHandler) fetchConfigFiles(ctx context.Context, gitSource gitsourc
 	return data, filename, nil
 }
 
-func (h *ActionHandler) genRunVariables(ctx context.Context, req *CreateRunRequest) (map[string]string, error) {
+// remoteConfigFileClient is the http client used to fetch remote config
+// includes. It doesn't use the environment proxy and only connects to public
+// addresses (also when following redirects) so the config files can't be used
+// to reach the services of the gateway network
+var remoteConfigFileClient = &http.Client{
+	Timeout: remoteConfigFileTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: remoteConfigFileTimeout,
+			Control: checkRemoteConfigFileAddress,
+		}).DialContext,
+		TLSHandshakeTimeout:   remoteConfigFileTimeout,
+		ResponseHeaderTimeout: remoteConfigFileTimeout,
+		DisableKeepAlives:     true,
+	},
+}
+
+// checkRemoteConfigFileAddress rejects the connections to non public
+// addresses. It's called with the resolved address so it also covers host
+// names resolving to private addresses
+func checkRemoteConfigFileAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Errorf("invalid address %q", host)
+	}
+	if !isPublicIP(ip) {
+		return errors.Errorf("address %q isn't a public address", host)
+	}
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// checkRepoInclude checks that the included repository has the same owner of
+// the run repository since its files are read with the project linked account
+func checkRepoInclude(repoPath, includeRepoPath string) error {
+	repoPath = strings.Trim(repoPath, "/")
+	includeRepoPath = path.Clean(strings.Trim(includeRepoPath, "/"))
+	owner := path.Dir(repoPath)
+	if owner == "." || path.Dir(includeRepoPath) != owner {
+		return errors.Errorf("repo %q doesn't have the same owner of repo %q", includeRepoPath, repoPath)
+	}
+	return nil
+}
+
+// fetchRemoteConfigFile returns the content of a remote config include
+func fetchRemoteConfigFile(ctx context.Context, u string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteConfigFileTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp, err := remoteConfigFileClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected http status code %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigFileSize+1))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(data) > maxRemoteConfigFileSize {
+		return nil, errors.Errorf("remote file size is greater than allowed max size %d", maxRemoteConfigFileSize)
+	}
+
+	return data, nil
+}
+
+// genRunVariables returns the project variables values matching the run and
+// the sealed values, resolved from the sealed secrets, that the runservice
+// will decrypt
+func (h *ActionHandler) genRunVariables(ctx context.Context, req *CreateRunRequest) (map[string]string, map[string]rstypes.SealedValue, error) {
 	variables := map[string]string{}
+	sealedValues := map[string]rstypes.SealedValue{}
 
 	// get project variables
 	pvars, _, err := h.configstoreClient.GetProjectVariables(ctx, req.Project.ID, true)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get project variables")
+		return nil, nil, errors.Wrapf(err, "failed to get project variables")
 	}
 
 	// remove overriden variables
@@ -625,13 +1426,20 @@ func (h *ActionHandler) genRunVariables(ctx context.Context, req *CreateRunReque
 	// get project secrets
 	secrets, _, err := h.configstoreClient.GetProjectSecrets(ctx, req.Project.ID, true)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get project secrets")
+		return nil, nil, errors.Wrapf(err, "failed to get project secrets")
 	}
+
+	// protected values are used only by the runs of protected branches and tags
+	protectedRef := types.MatchProtectedRef(req.Project.ProtectedBranches, req.Project.ProtectedTags, req.RefType, req.Branch, req.Tag)
+
 	for _, pvar := range pvars {
 		// find the value match
 		var varval cstypes.VariableValue
 		for _, varval = range pvar.Values {
-			match := types.MatchWhen(varval.When, req.RefType, req.Branch, req.Tag, req.Ref)
+			if varval.Protected && !protectedRef {
+				continue
+			}
+			match := types.MatchWhen(varval.When, req.RefType, req.Branch, req.Tag, req.Ref, req.Schedule) && types.MatchWhenMessage(varval.When, req.Message)
 			if !match {
 				continue
 			}
@@ -641,11 +1449,14 @@ func (h *ActionHandler) genRunVariables(ctx context.Context, req *CreateRunReque
 				varValue, ok := secret.Data[varval.SecretVar]
 				if ok {
 					variables[pvar.Name] = varValue
+					if secret.Sealed {
+						sealedValues[varValue] = rstypes.SealedValue{ParentID: secret.Parent.ID, SecretName: secret.Name}
+					}
 				}
 			}
 			break
 		}
 	}
 
-	return variables, nil
+	return variables, sealedValues, nil
 }
@@ -0,0 +1,447 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"agola.io/agola/internal/services/common"
+	itypes "agola.io/agola/internal/services/types"
+	"agola.io/agola/internal/testutil"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	csclient "agola.io/agola/services/configstore/client"
+	cstypes "agola.io/agola/services/configstore/types"
+	rsclient "agola.io/agola/services/runservice/client"
+	rstypes "agola.io/agola/services/runservice/types"
+	stypes "agola.io/agola/services/types"
+)
+
+func TestCheckRemoteConfigFileAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		err     bool
+	}{
+		{address: "93.184.216.34:443"},
+		{address: "[2606:2800:220:1:248:1893:25c8:1946]:443"},
+		{address: "127.0.0.1:80", err: true},
+		{address: "[::1]:80", err: true},
+		{address: "10.0.0.1:80", err: true},
+		{address: "172.16.0.1:80", err: true},
+		{address: "192.168.1.1:80", err: true},
+		{address: "169.254.169.254:80", err: true},
+		{address: "[fe80::1]:80", err: true},
+		{address: "[fd00::1]:80", err: true},
+		{address: "0.0.0.0:80", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := checkRemoteConfigFileAddress("tcp", tt.address, nil)
+			if tt.err && err == nil {
+				t.Fatalf("expected error")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestFetchRemoteConfigFilePrivateAddress(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("runs: []"))
+	}))
+	defer ts.Close()
+
+	if _, err := fetchRemoteConfigFile(context.Background(), ts.URL); err == nil {
+		t.Fatalf("expected error fetching a loopback address")
+	}
+}
+
+func TestCheckRepoInclude(t *testing.T) {
+	tests := []struct {
+		name            string
+		repoPath        string
+		includeRepoPath string
+		err             bool
+	}{
+		{
+			name:            "test same owner",
+			repoPath:        "org01/repo01",
+			includeRepoPath: "org01/repo02",
+		},
+		{
+			name:            "test same nested owner",
+			repoPath:        "group01/subgroup01/repo01",
+			includeRepoPath: "group01/subgroup01/repo02",
+		},
+		{
+			name:            "test other owner",
+			repoPath:        "org01/repo01",
+			includeRepoPath: "org02/repo02",
+			err:             true,
+		},
+		{
+			name:            "test parent owner",
+			repoPath:        "group01/subgroup01/repo01",
+			includeRepoPath: "group01/repo02",
+			err:             true,
+		},
+		{
+			name:            "test path traversal",
+			repoPath:        "org01/repo01",
+			includeRepoPath: "org01/../org02/repo02",
+			err:             true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRepoInclude(tt.repoPath, tt.includeRepoPath)
+			if tt.err && err == nil {
+				t.Fatalf("expected error")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateRunsConfigCommitSHA(t *testing.T) {
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+	dir := t.TempDir()
+
+	// the first commit has a broken config fixed by the second one
+	repo := newGitPollTestRepo(t, filepath.Join(dir, "repo01"))
+	configPath := filepath.Join(repo.workDir, ".agola", "config.jsonnet")
+	if err := ioutil.WriteFile(configPath, []byte("{ runs: ["), 0644); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	repo.run("add", ".agola")
+	brokenSHA := repo.commit("broken config")
+	if err := ioutil.WriteFile(configPath, []byte(gitPollTestConfig), 0644); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	repo.run("add", ".agola")
+	fixedSHA := repo.commit("fixed config")
+
+	s := &gitPollTestServices{
+		t: t,
+		rs: &cstypes.RemoteSource{
+			ObjectMeta: stypes.ObjectMeta{ID: "rs01"},
+			Name:       "rs01",
+			APIURL:     "file://" + dir,
+			Type:       cstypes.RemoteSourceTypeGit,
+		},
+		project: &csapitypes.Project{
+			Project: &cstypes.Project{
+				ObjectMeta:     stypes.ObjectMeta{ID: "project01"},
+				Name:           "project01",
+				RemoteSourceID: "rs01",
+				RepositoryPath: "repo01",
+			},
+		},
+	}
+
+	csClient := csclient.NewClient(s.configstore(t).URL)
+	rsClient := rsclient.NewClient(s.runservice(t).URL)
+	h := NewActionHandler(log, nil, csClient, rsClient, nil, "agola", "", "", nil, "")
+
+	gitSource, err := common.GetPlainGitSource(s.rs, "", false)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		commitSHA       string
+		configCommitSHA string
+		rerunOfRunID    string
+		runName         string
+		annotations     map[string]string
+	}{
+		{
+			name:      "broken config",
+			commitSHA: brokenSHA,
+			runName:   rstypes.RunGenericSetupErrorName,
+		},
+		{
+			name:            "broken config rerun with the fixed config",
+			commitSHA:       brokenSHA,
+			configCommitSHA: fixedSHA,
+			rerunOfRunID:    "run01",
+			runName:         "run01",
+			annotations: map[string]string{
+				AnnotationConfigCommitSHA: fixedSHA,
+				AnnotationRerunOf:         "run01",
+			},
+		},
+		{
+			name:            "config commit sha equal to the commit sha",
+			commitSHA:       fixedSHA,
+			configCommitSHA: fixedSHA,
+			runName:         "run01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.mu.Lock()
+			s.runs = nil
+			s.mu.Unlock()
+
+			req := &CreateRunRequest{
+				RunType:            itypes.RunTypeProject,
+				RefType:            itypes.RunRefTypeBranch,
+				RunCreationTrigger: itypes.RunCreationTriggerTypeManual,
+
+				Project:   s.project.Project,
+				RepoPath:  s.project.RepositoryPath,
+				GitSource: gitSource,
+				CommitSHA: tt.commitSHA,
+				Message:   "commit message",
+				Branch:    "master",
+				Ref:       "refs/heads/master",
+				CloneURL:  gitSource.RepoURL(s.project.RepositoryPath),
+
+				ConfigCommitSHA: tt.configCommitSHA,
+				RerunOfRunID:    tt.rerunOfRunID,
+			}
+			if err := h.CreateRuns(ctx, req); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if len(s.runs) != 1 {
+				t.Fatalf("expected 1 run, got %d", len(s.runs))
+			}
+			run := s.runs[0]
+			if run.Name != tt.runName {
+				t.Fatalf("expected run name %q, got %q", tt.runName, run.Name)
+			}
+			if run.Annotations[AnnotationCommitSHA] != tt.commitSHA {
+				t.Fatalf("expected commit sha annotation %q, got %q", tt.commitSHA, run.Annotations[AnnotationCommitSHA])
+			}
+			for _, k := range []string{AnnotationConfigCommitSHA, AnnotationRerunOf} {
+				if run.Annotations[k] != tt.annotations[k] {
+					t.Fatalf("expected %s annotation %q, got %q", k, tt.annotations[k], run.Annotations[k])
+				}
+			}
+		})
+	}
+}
+
+const inputsTestConfig = `
+{
+  inputs: [
+    {
+      name: 'environment',
+      default: 'staging',
+    },
+  ],
+  runs: [
+    {
+      name: 'run01',
+      tasks: [
+        {
+          name: 'task01',
+          runtime: {
+            containers: [
+              {
+                image: 'alpine/git',
+              },
+            ],
+          },
+          environment: {
+            ENVIRONMENT: { from_variable: 'environment' },
+          },
+          steps: [
+            { type: 'clone' },
+          ],
+        },
+      ],
+    },
+  ],
+}
+`
+
+func TestCreateRunsInputsProjectVariables(t *testing.T) {
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+	dir := t.TempDir()
+
+	repo := newGitPollTestRepo(t, filepath.Join(dir, "repo01"))
+	if err := ioutil.WriteFile(filepath.Join(repo.workDir, ".agola", "config.jsonnet"), []byte(inputsTestConfig), 0644); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	repo.run("add", ".agola")
+	commitSHA := repo.commit("inputs config")
+
+	// the project variable environment has the same name of the config input
+	s := &gitPollTestServices{
+		t: t,
+		rs: &cstypes.RemoteSource{
+			ObjectMeta: stypes.ObjectMeta{ID: "rs01"},
+			Name:       "rs01",
+			APIURL:     "file://" + dir,
+			Type:       cstypes.RemoteSourceTypeGit,
+		},
+		project: &csapitypes.Project{
+			Project: &cstypes.Project{
+				ObjectMeta:     stypes.ObjectMeta{ID: "project01"},
+				Name:           "project01",
+				RemoteSourceID: "rs01",
+				RepositoryPath: "repo01",
+			},
+			Path: "user/user01/project01",
+		},
+		variables: []*csapitypes.Variable{
+			{
+				Variable: &cstypes.Variable{
+					Name:   "environment",
+					Values: []cstypes.VariableValue{{SecretName: "secret01", SecretVar: "environment"}},
+				},
+				ParentPath: "user/user01/project01",
+			},
+		},
+		secrets: []*csapitypes.Secret{
+			{
+				Secret: &cstypes.Secret{
+					Name: "secret01",
+					Type: cstypes.SecretTypeInternal,
+					Data: map[string]string{"environment": "production"},
+				},
+				ParentPath: "user/user01/project01",
+			},
+		},
+	}
+
+	csClient := csclient.NewClient(s.configstore(t).URL)
+	rsClient := rsclient.NewClient(s.runservice(t).URL)
+	h := NewActionHandler(log, nil, csClient, rsClient, nil, "agola", "", "", nil, "")
+
+	gitSource, err := common.GetPlainGitSource(s.rs, "", false)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		trigger itypes.RunCreationTriggerType
+		inputs  map[string]string
+		value   string
+	}{
+		{
+			name:    "webhook run",
+			trigger: itypes.RunCreationTriggerTypeWebhook,
+			value:   "production",
+		},
+		{
+			name:    "manual run without inputs",
+			trigger: itypes.RunCreationTriggerTypeManual,
+			value:   "production",
+		},
+		{
+			name:    "manual run with provided input",
+			trigger: itypes.RunCreationTriggerTypeManual,
+			inputs:  map[string]string{"environment": "qa"},
+			value:   "qa",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.mu.Lock()
+			s.runs = nil
+			s.runRequests = nil
+			s.mu.Unlock()
+
+			req := &CreateRunRequest{
+				RunType:            itypes.RunTypeProject,
+				RefType:            itypes.RunRefTypeBranch,
+				RunCreationTrigger: tt.trigger,
+
+				Project:   s.project.Project,
+				RepoPath:  s.project.RepositoryPath,
+				GitSource: gitSource,
+				CommitSHA: commitSHA,
+				Message:   "commit message",
+				Branch:    "master",
+				Ref:       "refs/heads/master",
+				CloneURL:  gitSource.RepoURL(s.project.RepositoryPath),
+
+				Inputs: tt.inputs,
+			}
+			if err := h.CreateRuns(ctx, req); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if len(s.runRequests) != 1 {
+				t.Fatalf("expected 1 run, got %d", len(s.runRequests))
+			}
+			runReq := s.runRequests[0]
+			if runReq.Name != "run01" {
+				t.Fatalf("expected run name %q, got %q", "run01", runReq.Name)
+			}
+			if v := runReq.StaticEnvironment["AGOLA_INPUT_ENVIRONMENT"]; v != tt.value {
+				t.Fatalf("expected input env value %q, got %q", tt.value, v)
+			}
+			for _, rct := range runReq.RunConfigTasks {
+				if v := rct.Environment["ENVIRONMENT"]; v != tt.value {
+					t.Fatalf("expected task environment variable value %q, got %q", tt.value, v)
+				}
+			}
+		})
+	}
+}
+
+func TestRerunWithConfigNotProjectRun(t *testing.T) {
+	h := &ActionHandler{}
+
+	tests := []struct {
+		name    string
+		runType string
+	}{
+		{name: "user direct run", runType: string(itypes.RunTypeUser)},
+		{name: "run without run type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := &rstypes.Run{Annotations: map[string]string{}}
+			if tt.runType != "" {
+				run.Annotations[AnnotationRunType] = tt.runType
+			}
+
+			err := h.rerunWithConfig(context.Background(), run, "")
+			if !util.APIErrorIs(err, util.ErrBadRequest) {
+				t.Fatalf("expected bad request error, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	gitsource "agola.io/agola/internal/gitsources"
+	scommon "agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/services/types"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	cstypes "agola.io/agola/services/configstore/types"
+)
+
+const remoteSourcesFetchLimit = 20
+
+// scheduledRunsCheckLimit is the number of the latest schedule branch runs
+// checked to detect an already created scheduled run
+const scheduledRunsCheckLimit = 10
+
+// ScheduledRuns contains the last evaluated activation time of every project
+// schedule (schedule key -> time)
+type ScheduledRuns map[string]time.Time
+
+func scheduleKey(p *csapitypes.Project, s *cstypes.ProjectSchedule) string {
+	// a changed schedule is considered a new schedule
+	return fmt.Sprintf("%s/%s/%s/%s", p.ID, s.Name, s.Branch, s.Cron)
+}
+
+// CreateScheduledRuns creates the runs of the projects schedules activated
+// since their previous evaluation. When multiple activations were missed only
+// one run is created.
+// The first time a schedule is seen its activation time is restored from its
+// latest run. When there're no runs it's only recorded, so no runs are created
+// for activations before the gateway start.
+func (h *ActionHandler) CreateScheduledRuns(ctx context.Context, prev ScheduledRuns, now time.Time) (ScheduledRuns, error) {
+	cur := ScheduledRuns{}
+	now = now.UTC()
+
+	var start string
+	for {
+		remoteSources, _, err := h.configstoreClient.GetRemoteSources(ctx, start, remoteSourcesFetchLimit, true)
+		if err != nil {
+			return prev, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote sources"))
+		}
+
+		for _, rs := range remoteSources {
+			projects, _, err := h.configstoreClient.GetRemoteSourceProjects(ctx, rs.ID, "")
+			if err != nil {
+				h.log.Err(err).Msgf("failed to get remote source %q projects", rs.Name)
+				// keep the previous activation times of all the schedules
+				// since we don't know the remote source projects
+				for k, v := range prev {
+					cur[k] = v
+				}
+				continue
+			}
+
+			for _, p := range projects {
+				for _, s := range p.Schedules {
+					key := scheduleKey(p, s)
+					cs, err := util.ParseCronSchedule(s.Cron)
+					if err != nil {
+						h.log.Err(err).Msgf("project %q schedule %q", p.Path, s.Name)
+						continue
+					}
+
+					last, ok := prev[key]
+					if !ok {
+						// restore the activation time from the last
+						// scheduled run (i.e. after a gateway restart)
+						last, err = h.lastScheduledRunTime(ctx, p, s, cs)
+						if err != nil {
+							h.log.Err(err).Msgf("project %q schedule %q", p.Path, s.Name)
+							continue
+						}
+						if last.IsZero() {
+							cur[key] = now
+							continue
+						}
+					}
+					cur[key] = last
+
+					next := cs.Next(last)
+					if next.IsZero() || next.After(now) {
+						continue
+					}
+					for n := cs.Next(next); !n.IsZero() && !n.After(now); n = cs.Next(n) {
+						next = n
+					}
+					// don't retry on errors since they are usually not
+					// transient (i.e. removed branch)
+					cur[key] = next
+
+					h.log.Info().Msgf("creating scheduled run for project %q schedule %q", p.Path, s.Name)
+					if err := h.createScheduledRun(ctx, rs, p, s, next); err != nil {
+						h.log.Err(err).Msgf("failed to create scheduled run for project %q schedule %q", p.Path, s.Name)
+					}
+				}
+			}
+		}
+
+		if len(remoteSources) < remoteSourcesFetchLimit {
+			break
+		}
+		start = remoteSources[len(remoteSources)-1].Name
+	}
+
+	return cur, nil
+}
+
+// lastScheduledRunTime returns the activation time of the latest run created
+// by the schedule. It's zero when there're no runs of the schedule or the
+// latest one was created by a schedule with a different cron.
+func (h *ActionHandler) lastScheduledRunTime(ctx context.Context, p *csapitypes.Project, s *cstypes.ProjectSchedule, cs *util.CronSchedule) (time.Time, error) {
+	runGroup := scommon.GenRunGroup(scommon.GroupTypeProject, p.ID, scommon.GroupTypeBranch, s.Branch)
+	runsResp, _, err := h.runserviceClient.GetGroupRuns(ctx, nil, nil, nil, runGroup, nil, 0, scheduledRunsCheckLimit, false)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to get runs for group %q", runGroup)
+	}
+	for _, run := range runsResp.Runs {
+		if run.Annotations[AnnotationSchedule] != s.Name {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, run.Annotations[AnnotationScheduleTime])
+		if err != nil {
+			return time.Time{}, nil
+		}
+		// ignore the runs of a previous schedule cron
+		if !cs.Next(t.Add(-time.Second)).Equal(t) {
+			return time.Time{}, nil
+		}
+		return t.UTC(), nil
+	}
+	return time.Time{}, nil
+}
+
+func (h *ActionHandler) createScheduledRun(ctx context.Context, rs *cstypes.RemoteSource, p *csapitypes.Project, s *cstypes.ProjectSchedule, scheduleTime time.Time) error {
+	// check if the run was already created (i.e. by another gateway instance)
+	runGroup := scommon.GenRunGroup(scommon.GroupTypeProject, p.ID, scommon.GroupTypeBranch, s.Branch)
+	runsResp, _, err := h.runserviceClient.GetGroupRuns(ctx, nil, nil, nil, runGroup, nil, 0, scheduledRunsCheckLimit, false)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get runs for group %q", runGroup)
+	}
+	for _, run := range runsResp.Runs {
+		if run.Annotations[AnnotationSchedule] == s.Name && run.Annotations[AnnotationScheduleTime] == scheduleTime.Format(time.RFC3339) {
+			return nil
+		}
+	}
+
+	gitSource, repoInfo, err := h.projectGitSource(ctx, rs, p)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	refName := gitSource.BranchRef(s.Branch)
+	ref, err := gitSource.GetRef(p.RepositoryPath, refName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get ref information from git source for ref %q", refName)
+	}
+
+	cloneURL, err := scommon.GetSSHCloneURL(rs, repoInfo.SSHCloneURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// use remotesource skipSSHHostKeyCheck config and override with project config if set to true there
+	skipSSHHostKeyCheck := rs.SkipSSHHostKeyCheck
+	if p.SkipSSHHostKeyCheck {
+		skipSSHHostKeyCheck = p.SkipSSHHostKeyCheck
+	}
+
+	req := &CreateRunRequest{
+		RunType:            types.RunTypeProject,
+		RefType:            types.RunRefTypeSchedule,
+		RunCreationTrigger: types.RunCreationTriggerTypeSchedule,
+
+		Project:   p.Project,
+		RepoPath:  p.RepositoryPath,
+		GitSource: gitSource,
+		CommitSHA: ref.CommitSHA,
+		// don't use the commit message since it could contain [ci skip]
+		Message:             fmt.Sprintf("Schedule %s", s.Name),
+		Branch:              s.Branch,
+		Ref:                 refName,
+		Schedule:            s.Name,
+		ScheduleTime:        scheduleTime,
+		SSHPrivKey:          p.SSHPrivateKey,
+		SSHHostKey:          rs.SSHHostKey,
+		SkipSSHHostKeyCheck: skipSSHHostKeyCheck,
+		CloneURL:            cloneURL,
+
+		CommitLink: gitSource.CommitLink(repoInfo, ref.CommitSHA),
+		BranchLink: gitSource.BranchLink(repoInfo, s.Branch),
+	}
+
+	return h.CreateRuns(ctx, req)
+}
+
+// projectGitSource returns the project git source client using the project
+// linked account or, for plain git remote sources, the project deploy key
+func (h *ActionHandler) projectGitSource(ctx context.Context, rs *cstypes.RemoteSource, p *csapitypes.Project) (gitsource.GitSource, *gitsource.RepoInfo, error) {
+	var gitSource gitsource.GitSource
+	var err error
+	accountID := p.ID
+	// plain git sources have no api, the repository is accessed using the
+	// project deploy key
+	if rs.Type == cstypes.RemoteSourceTypeGit {
+		gitSource, err = scommon.GetPlainGitSource(rs, p.SSHPrivateKey, p.SkipSSHHostKeyCheck)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to create gitsource client")
+		}
+	} else {
+		user, _, la, err := h.getRemoteRepoAccessData(ctx, p.LinkedAccountID)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to get remote repo access data")
+		}
+		gitSource, err = h.GetGitSource(ctx, rs, user.Name, la)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to create gitsource client")
+		}
+		accountID = la.ID
+	}
+
+	repoInfo, err := h.getRepoInfo(gitSource, rs.ID, accountID, p.RepositoryPath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get repository info from gitsource")
+	}
+
+	return gitSource, repoInfo, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"agola.io/agola/internal/testutil"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	cstypes "agola.io/agola/services/configstore/types"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+	rsclient "agola.io/agola/services/runservice/client"
+	rstypes "agola.io/agola/services/runservice/types"
+	stypes "agola.io/agola/services/types"
+)
+
+func TestLastScheduledRunTime(t *testing.T) {
+	p := &csapitypes.Project{Project: &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project01"}}}
+	s := &cstypes.ProjectSchedule{Name: "nightly", Branch: "master", Cron: "0 2 * * *"}
+	cs, err := util.ParseCronSchedule(s.Cron)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	scheduledRun := func(schedule, scheduleTime string) *rstypes.Run {
+		return &rstypes.Run{Annotations: map[string]string{AnnotationSchedule: schedule, AnnotationScheduleTime: scheduleTime}}
+	}
+
+	tests := []struct {
+		name string
+		runs []*rstypes.Run
+		out  time.Time
+	}{
+		{
+			name: "test no runs",
+		},
+		{
+			name: "test runs of other schedules",
+			runs: []*rstypes.Run{{}, scheduledRun("weekly", "2022-03-06T02:00:00Z")},
+		},
+		{
+			name: "test latest schedule run",
+			runs: []*rstypes.Run{{}, scheduledRun("nightly", "2022-03-07T02:00:00Z"), scheduledRun("nightly", "2022-03-06T02:00:00Z")},
+			out:  time.Date(2022, 3, 7, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "test latest schedule run with a previous cron",
+			runs: []*rstypes.Run{scheduledRun("nightly", "2022-03-07T03:00:00Z")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "GET" || !strings.HasPrefix(r.URL.Path, "/api/v1alpha/runs/group/") {
+					t.Errorf("unexpected runservice request %s %s", r.Method, r.URL.Path)
+				}
+				runs := tt.runs
+				if runs == nil {
+					runs = []*rstypes.Run{}
+				}
+				writeTestJSON(t, w, http.StatusOK, &rsapitypes.GetRunsResponse{Runs: runs})
+			}))
+			defer ts.Close()
+
+			log := testutil.NewLogger(t)
+			h := NewActionHandler(log, nil, nil, rsclient.NewClient(ts.URL), nil, "agola", "", "", nil, "")
+
+			out, err := h.lastScheduledRunTime(context.Background(), p, s, cs)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !out.Equal(tt.out) {
+				t.Fatalf("expected time %v, got %v", tt.out, out)
+			}
+		})
+	}
+}
@@ -62,7 +62,8 @@ type CreateSecretRequest struct {
 	Type cstypes.SecretType
 
 	// internal secret
-	Data map[string]string
+	Data   map[string]string
+	Sealed bool
 
 	// external secret
 	SecretProviderID string
@@ -83,9 +84,10 @@ func (h *ActionHandler) CreateSecret(ctx context.Context, req *CreateSecretReque
 	}
 
 	creq := &csapitypes.CreateUpdateSecretRequest{
-		Name: req.Name,
-		Type: req.Type,
-		Data: req.Data,
+		Name:   req.Name,
+		Type:   req.Type,
+		Data:   req.Data,
+		Sealed: req.Sealed,
 	}
 
 	var rs *csapitypes.Secret
@@ -116,7 +118,8 @@ type UpdateSecretRequest struct {
 	Type cstypes.SecretType
 
 	// internal secret
-	Data map[string]string
+	Data   map[string]string
+	Sealed bool
 
 	// external secret
 	SecretProviderID string
@@ -137,9 +140,10 @@ func (h *ActionHandler) UpdateSecret(ctx context.Context, req *UpdateSecretReque
 	}
 
 	creq := &csapitypes.CreateUpdateSecretRequest{
-		Name: req.Name,
-		Type: req.Type,
-		Data: req.Data,
+		Name:   req.Name,
+		Type:   req.Type,
+		Data:   req.Data,
+		Sealed: req.Sealed,
 	}
 
 	var rs *csapitypes.Secret
@@ -159,6 +163,15 @@ func (h *ActionHandler) UpdateSecret(ctx context.Context, req *UpdateSecretReque
 	return rs, nil
 }
 
+func (h *ActionHandler) GetSealedSecretsPublicKey(ctx context.Context) (string, error) {
+	res, _, err := h.runserviceClient.GetSealedSecretsPublicKey(ctx)
+	if err != nil {
+		return "", util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get sealed secrets public key"))
+	}
+
+	return res.PublicKey, nil
+}
+
 func (h *ActionHandler) DeleteSecret(ctx context.Context, parentType cstypes.ObjectKind, parentRef, name string) error {
 	isVariableOwner, err := h.IsVariableOwner(ctx, parentType, parentRef)
 	if err != nil {
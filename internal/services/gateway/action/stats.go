@@ -0,0 +1,108 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	scommon "agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/util"
+	rstypes "agola.io/agola/services/runservice/types"
+)
+
+const (
+	// metricsRunsPageSize is the number of runs fetched from the runservice
+	// in a single request when looking for a metric values
+	metricsRunsPageSize = 40
+	// maxMetricsScannedRuns is the max number of runs scanned in a single
+	// metric values request
+	maxMetricsScannedRuns = 1000
+)
+
+type GetProjectMetricRequest struct {
+	ProjectRef string
+	Metric     string
+	// Branch limits the values to the runs of the provided branch. If empty
+	// the values of the runs of all the branches are returned
+	Branch         string
+	StartRunNumber uint64
+	Limit          int
+}
+
+// MetricValue is the value of a metric emitted by a run
+type MetricValue struct {
+	RunNumber uint64
+	Branch    string
+	Result    rstypes.RunResult
+	Time      *time.Time
+	Value     float64
+}
+
+// GetProjectMetric returns the values, from the latest run backwards, of a
+// metric emitted by the finished project branches runs.
+func (h *ActionHandler) GetProjectMetric(ctx context.Context, req *GetProjectMetricRequest) ([]*MetricValue, error) {
+	if req.Metric == "" {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty metric name"))
+	}
+
+	canGetRun, projectID, err := h.CanGetRun(ctx, scommon.GroupTypeProject, req.ProjectRef)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine permissions")
+	}
+	if !canGetRun {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	// if branch is empty we get the runs of every branch.
+	group := path.Join(scommon.GenBaseRunGroup(scommon.GroupTypeProject, projectID), string(scommon.GroupTypeBranch), url.PathEscape(req.Branch))
+
+	values := []*MetricValue{}
+	startRunNumber := req.StartRunNumber
+	for scanned := 0; scanned < maxMetricsScannedRuns; {
+		runsResp, _, err := h.runserviceClient.GetGroupRuns(ctx, []string{string(rstypes.RunPhaseFinished)}, nil, nil, group, nil, startRunNumber, metricsRunsPageSize, false)
+		if err != nil {
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), err)
+		}
+
+		for _, run := range runsResp.Runs {
+			startRunNumber = run.Counter
+			value, ok := run.Metrics[req.Metric]
+			if !ok {
+				continue
+			}
+			values = append(values, &MetricValue{
+				RunNumber: run.Counter,
+				Branch:    run.Annotations[AnnotationBranch],
+				Result:    run.Result,
+				Time:      run.EndTime,
+				Value:     value,
+			})
+			if req.Limit > 0 && len(values) >= req.Limit {
+				return values, nil
+			}
+		}
+
+		scanned += len(runsResp.Runs)
+		if len(runsResp.Runs) < metricsRunsPageSize {
+			break
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,308 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"agola.io/agola/internal/config"
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/lock"
+	scommon "agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/services/types"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	rstypes "agola.io/agola/services/runservice/types"
+)
+
+// downstreamRunsCheckLimit is the number of the latest downstream group runs
+// checked to detect an already created downstream run
+const downstreamRunsCheckLimit = 10
+
+func runConfigTriggers(triggers []*config.Trigger) []*rstypes.RunConfigTrigger {
+	if len(triggers) == 0 {
+		return nil
+	}
+
+	rcts := make([]*rstypes.RunConfigTrigger, len(triggers))
+	for i, t := range triggers {
+		rcts[i] = &rstypes.RunConfigTrigger{
+			Project:   t.Project,
+			Branch:    t.Branch,
+			Tag:       t.Tag,
+			Variables: t.Variables,
+		}
+	}
+
+	return rcts
+}
+
+// HandleDownstreamRuns consumes the runservice run events and creates the
+// downstream runs of the runs finished successfully. It returns when the
+// events stream ends.
+func (h *ActionHandler) HandleDownstreamRuns(ctx context.Context) error {
+	resp, err := h.runserviceClient.GetRunEvents(ctx, "")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("http status code: %d", resp.StatusCode)
+	}
+	defer resp.Body.Close()
+
+	br := bufio.NewReader(resp.Body)
+	stop := false
+
+	var buf bytes.Buffer
+	for {
+		if stop {
+			return nil
+		}
+		line, err := br.ReadBytes('\n')
+		if err != nil {
+			if err != io.EOF {
+				return errors.WithStack(err)
+			}
+			if len(line) == 0 {
+				return nil
+			}
+			stop = true
+		}
+		switch {
+		case bytes.HasPrefix(line, []byte("data: ")):
+			buf.Write(line[6:])
+		case bytes.Equal(line, []byte("\n")):
+			data := buf.Bytes()
+			buf.Reset()
+
+			var ev *rstypes.RunEvent
+			if err := json.Unmarshal(data, &ev); err != nil {
+				return errors.WithStack(err)
+			}
+
+			if ev.Phase != rstypes.RunPhaseFinished || ev.Result != rstypes.RunResultSuccess {
+				continue
+			}
+			if err := h.CreateDownstreamRuns(ctx, ev.RunID); err != nil {
+				h.log.Err(err).Msgf("failed to create downstream runs of run %q", ev.RunID)
+			}
+
+		default:
+			return errors.Errorf("wrong data")
+		}
+	}
+}
+
+// CreateDownstreamRuns creates the downstream runs defined by the triggers of
+// a successfully finished project run. Every gateway instance receives the run
+// events, so a downstream run is created holding a lock keyed by the parent
+// run and trigger and the downstream runs already created (i.e. by another
+// gateway instance) are skipped.
+func (h *ActionHandler) CreateDownstreamRuns(ctx context.Context, runID string) error {
+	runResp, _, err := h.runserviceClient.GetRun(ctx, runID, nil)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get run %q", runID))
+	}
+	run := runResp.Run
+	rc := runResp.RunConfig
+
+	if len(rc.Triggers) == 0 {
+		return nil
+	}
+	if run.Phase != rstypes.RunPhaseFinished || run.Result != rstypes.RunResultSuccess {
+		return nil
+	}
+	// user direct runs cannot trigger downstream runs
+	if run.Annotations[AnnotationRunType] != string(types.RunTypeProject) {
+		return nil
+	}
+	// pull request runs cannot trigger downstream runs since their config is
+	// provided by the pull request author
+	if run.Annotations[AnnotationRefType] == string(types.RunRefTypePullRequest) {
+		h.log.Info().Msgf("ignoring the triggers of pull request run %q", run.ID)
+		return nil
+	}
+
+	projectID := run.Annotations[AnnotationProjectID]
+	parentProject, _, err := h.configstoreClient.GetProject(ctx, projectID)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q", projectID))
+	}
+
+	for i, t := range rc.Triggers {
+		if err := h.createDownstreamRun(ctx, run, parentProject, i, t); err != nil {
+			h.log.Err(err).Msgf("failed to create downstream run of run %q trigger %d", run.ID, i)
+		}
+	}
+
+	return nil
+}
+
+// checkDownstreamProject checks that the upstream project can trigger runs in
+// the downstream project
+func checkDownstreamProject(parentProject, p *csapitypes.Project) error {
+	// only the projects of the same owner can be triggered since the upstream
+	// run provides the downstream run variables
+	if p.OwnerType != parentProject.OwnerType || p.OwnerID != parentProject.OwnerID {
+		return errors.Errorf("project %q has a different owner than the upstream project %q", p.Path, parentProject.Path)
+	}
+	// the downstream project must explicitly allow the upstream project
+	if !util.StringInSlice(p.AllowedUpstreamProjects, parentProject.ID) && !util.StringInSlice(p.AllowedUpstreamProjects, parentProject.Path) {
+		return errors.Errorf("project %q doesn't allow runs triggered by project %q", p.Path, parentProject.Path)
+	}
+
+	return nil
+}
+
+// downstreamRunVariables returns the trigger variables, removing the ones
+// defined by the downstream project since they cannot be overridden by the
+// upstream run
+func downstreamRunVariables(variables map[string]string, pvars []*csapitypes.Variable) map[string]string {
+	if len(variables) == 0 {
+		return nil
+	}
+
+	out := make(map[string]string, len(variables))
+	for k, v := range variables {
+		out[k] = v
+	}
+	for _, pvar := range pvars {
+		delete(out, pvar.Name)
+	}
+
+	return out
+}
+
+func (h *ActionHandler) createDownstreamRun(ctx context.Context, parentRun *rstypes.Run, parentProject *csapitypes.Project, index int, t *rstypes.RunConfigTrigger) error {
+	p, _, err := h.configstoreClient.GetProject(ctx, t.Project)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q", t.Project))
+	}
+
+	if err := checkDownstreamProject(parentProject, p); err != nil {
+		return errors.WithStack(err)
+	}
+
+	l := h.lf.NewLock(fmt.Sprintf("gateway-downstreamrun-%s-%d", parentRun.ID, index))
+	if err := l.TryLock(ctx); err != nil {
+		// another gateway instance is creating the downstream run
+		if errors.Is(err, lock.ErrLocked) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	defer func() { _ = l.Unlock() }()
+
+	groupType, group := scommon.GroupTypeBranch, t.Branch
+	if t.Tag != "" {
+		groupType, group = scommon.GroupTypeTag, t.Tag
+	}
+
+	// check if the run was already created (i.e. by another gateway instance)
+	runGroup := scommon.GenRunGroup(scommon.GroupTypeProject, p.ID, groupType, group)
+	runsResp, _, err := h.runserviceClient.GetGroupRuns(ctx, nil, nil, nil, runGroup, nil, 0, downstreamRunsCheckLimit, false)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get runs for group %q", runGroup)
+	}
+	for _, run := range runsResp.Runs {
+		if run.Annotations[AnnotationParentRunID] == parentRun.ID && run.Annotations[AnnotationParentTrigger] == strconv.Itoa(index) {
+			return nil
+		}
+	}
+
+	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, p.RemoteSourceID)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", p.RemoteSourceID))
+	}
+
+	gitSource, repoInfo, err := h.projectGitSource(ctx, rs, p)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var refType types.RunRefType
+	var refName string
+	var branchLink, tagLink string
+	if t.Branch != "" {
+		refType = types.RunRefTypeBranch
+		refName = gitSource.BranchRef(t.Branch)
+		branchLink = gitSource.BranchLink(repoInfo, t.Branch)
+	} else {
+		refType = types.RunRefTypeTag
+		refName = gitSource.TagRef(t.Tag)
+		tagLink = gitSource.TagLink(repoInfo, t.Tag)
+	}
+
+	ref, err := gitSource.GetRef(p.RepositoryPath, refName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get ref information from git source for ref %q", refName)
+	}
+
+	cloneURL, err := scommon.GetSSHCloneURL(rs, repoInfo.SSHCloneURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// use remotesource skipSSHHostKeyCheck config and override with project config if set to true there
+	skipSSHHostKeyCheck := rs.SkipSSHHostKeyCheck
+	if p.SkipSSHHostKeyCheck {
+		skipSSHHostKeyCheck = p.SkipSSHHostKeyCheck
+	}
+
+	pvars, _, err := h.configstoreClient.GetProjectVariables(ctx, p.ID, true)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q variables", p.ID))
+	}
+
+	h.log.Info().Msgf("creating downstream run for project %q triggered by project %q run %q", p.Path, parentProject.Path, parentRun.ID)
+
+	req := &CreateRunRequest{
+		RunType:            types.RunTypeProject,
+		RefType:            refType,
+		RunCreationTrigger: types.RunCreationTriggerTypeDownstream,
+
+		Project:   p.Project,
+		RepoPath:  p.RepositoryPath,
+		GitSource: gitSource,
+		CommitSHA: ref.CommitSHA,
+		// don't use the commit message since it could contain [ci skip]
+		Message:             fmt.Sprintf("Triggered by %s run #%d", parentProject.Path, parentRun.Counter),
+		Branch:              t.Branch,
+		Tag:                 t.Tag,
+		Ref:                 refName,
+		SSHPrivKey:          p.SSHPrivateKey,
+		SSHHostKey:          rs.SSHHostKey,
+		SkipSSHHostKeyCheck: skipSSHHostKeyCheck,
+		CloneURL:            cloneURL,
+
+		CommitLink: gitSource.CommitLink(repoInfo, ref.CommitSHA),
+		BranchLink: branchLink,
+		TagLink:    tagLink,
+
+		Variables:       downstreamRunVariables(t.Variables, pvars),
+		ParentRunID:     parentRun.ID,
+		ParentProjectID: parentProject.ID,
+		ParentTrigger:   index,
+	}
+
+	return h.CreateRuns(ctx, req)
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"agola.io/agola/internal/lock"
+	"agola.io/agola/internal/testutil"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	csclient "agola.io/agola/services/configstore/client"
+	cstypes "agola.io/agola/services/configstore/types"
+	rsclient "agola.io/agola/services/runservice/client"
+	rstypes "agola.io/agola/services/runservice/types"
+	stypes "agola.io/agola/services/types"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckDownstreamProject(t *testing.T) {
+	parentProject := &csapitypes.Project{
+		Project:   &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project01"}, Name: "project01"},
+		OwnerType: cstypes.ObjectKindOrg,
+		OwnerID:   "org01",
+		Path:      "org/org01/project01",
+	}
+
+	tests := []struct {
+		name            string
+		ownerID         string
+		allowedProjects []string
+		err             bool
+	}{
+		{
+			name:            "test upstream project allowed by id",
+			ownerID:         "org01",
+			allowedProjects: []string{"project01"},
+		},
+		{
+			name:            "test upstream project allowed by path",
+			ownerID:         "org01",
+			allowedProjects: []string{"org/org01/project03", "org/org01/project01"},
+		},
+		{
+			name:    "test no allowed upstream projects",
+			ownerID: "org01",
+			err:     true,
+		},
+		{
+			name:            "test upstream project not allowed",
+			ownerID:         "org01",
+			allowedProjects: []string{"org/org01/project03"},
+			err:             true,
+		},
+		{
+			name:            "test project of another owner",
+			ownerID:         "org02",
+			allowedProjects: []string{"project01"},
+			err:             true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &csapitypes.Project{
+				Project: &cstypes.Project{
+					ObjectMeta:              stypes.ObjectMeta{ID: "project02"},
+					Name:                    "project02",
+					AllowedUpstreamProjects: tt.allowedProjects,
+				},
+				OwnerType: cstypes.ObjectKindOrg,
+				OwnerID:   tt.ownerID,
+				Path:      "org/" + tt.ownerID + "/project02",
+			}
+
+			err := checkDownstreamProject(parentProject, p)
+			if tt.err && err == nil {
+				t.Fatalf("expected error")
+			}
+			if !tt.err && err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+}
+
+func TestDownstreamRunVariables(t *testing.T) {
+	pvars := []*csapitypes.Variable{
+		{Variable: &cstypes.Variable{Name: "DEPLOY_TOKEN"}},
+		{Variable: &cstypes.Variable{Name: "REGISTRY"}},
+	}
+
+	tests := []struct {
+		name      string
+		variables map[string]string
+		pvars     []*csapitypes.Variable
+		out       map[string]string
+	}{
+		{
+			name: "test no variables",
+		},
+		{
+			name:      "test variables not defined by the project",
+			variables: map[string]string{"VERSION": "v1.0.0"},
+			pvars:     pvars,
+			out:       map[string]string{"VERSION": "v1.0.0"},
+		},
+		{
+			name:      "test variables defined by the project are removed",
+			variables: map[string]string{"VERSION": "v1.0.0", "DEPLOY_TOKEN": "token01", "REGISTRY": "registry01"},
+			pvars:     pvars,
+			out:       map[string]string{"VERSION": "v1.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := downstreamRunVariables(tt.variables, tt.pvars)
+			if diff := cmp.Diff(tt.out, out); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCreateDownstreamRunLocked(t *testing.T) {
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	parentProject := &csapitypes.Project{
+		Project:   &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project01"}, Name: "project01"},
+		OwnerType: cstypes.ObjectKindOrg,
+		OwnerID:   "org01",
+		Path:      "org/org01/project01",
+	}
+	p := &csapitypes.Project{
+		Project: &cstypes.Project{
+			ObjectMeta:              stypes.ObjectMeta{ID: "project02"},
+			Name:                    "project02",
+			AllowedUpstreamProjects: []string{"project01"},
+		},
+		OwnerType: cstypes.ObjectKindOrg,
+		OwnerID:   "org01",
+		Path:      "org/org01/project02",
+	}
+
+	cs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/api/v1alpha/projects/project02" {
+			writeTestJSON(t, w, http.StatusOK, p)
+			return
+		}
+		t.Errorf("unexpected configstore request %s %s", r.Method, r.URL.Path)
+		writeTestJSON(t, w, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+	}))
+	defer cs.Close()
+	rs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected runservice request %s %s", r.Method, r.URL.Path)
+		writeTestJSON(t, w, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+	}))
+	defer rs.Close()
+
+	lf := lock.NewLocalLockFactory(lock.NewLocalLocks())
+	h := NewActionHandler(log, nil, csclient.NewClient(cs.URL), rsclient.NewClient(rs.URL), lf, "agola", "", "", nil, "")
+
+	// another gateway instance is creating the downstream run
+	l := lf.NewLock("gateway-downstreamrun-run01-0")
+	if err := l.Lock(ctx); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer func() { _ = l.Unlock() }()
+
+	parentRun := &rstypes.Run{ObjectMeta: stypes.ObjectMeta{ID: "run01"}}
+	trigger := &rstypes.RunConfigTrigger{Project: "project02", Branch: "master"}
+	if err := h.createDownstreamRun(ctx, parentRun, parentProject, 0, trigger); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
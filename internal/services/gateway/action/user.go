@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
@@ -202,7 +203,7 @@ type CreateUserLARequest struct {
 
 func (h *ActionHandler) CreateUserLA(ctx context.Context, req *CreateUserLARequest) (*cstypes.LinkedAccount, error) {
 	userRef := req.UserRef
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, req.RemoteSourceName)
+	rs, err := h.GetRemoteSource(ctx, req.RemoteSourceName)
 	if err != nil {
 		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", req.RemoteSourceName))
 	}
@@ -231,7 +232,7 @@ func (h *ActionHandler) CreateUserLA(ctx context.Context, req *CreateUserLAReque
 		return nil, errors.WithStack(err)
 	}
 
-	remoteUserInfo, err := userSource.GetUserInfo()
+	remoteUserInfo, err := h.getRemoteUserInfo(userSource, rs.ID, accessToken)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to retrieve remote user info for remote source %q", rs.ID)
 	}
@@ -243,6 +244,8 @@ func (h *ActionHandler) CreateUserLA(ctx context.Context, req *CreateUserLAReque
 		RemoteSourceName:           req.RemoteSourceName,
 		RemoteUserID:               remoteUserInfo.ID,
 		RemoteUserName:             remoteUserInfo.LoginName,
+		RemoteUserDisplayName:      remoteUserInfo.DisplayName,
+		RemoteUserAvatarURL:        remoteUserInfo.AvatarURL,
 		UserAccessToken:            req.UserAccessToken,
 		Oauth2AccessToken:          req.Oauth2AccessToken,
 		Oauth2RefreshToken:         req.Oauth2RefreshToken,
@@ -279,6 +282,8 @@ func (h *ActionHandler) UpdateUserLA(ctx context.Context, userRef string, la *cs
 	creq := &csapitypes.UpdateUserLARequest{
 		RemoteUserID:               la.RemoteUserID,
 		RemoteUserName:             la.RemoteUserName,
+		RemoteUserDisplayName:      la.RemoteUserDisplayName,
+		RemoteUserAvatarURL:        la.RemoteUserAvatarURL,
 		UserAccessToken:            la.UserAccessToken,
 		Oauth2AccessToken:          la.Oauth2AccessToken,
 		Oauth2RefreshToken:         la.Oauth2RefreshToken,
@@ -352,7 +357,7 @@ func (h *ActionHandler) RegisterUser(ctx context.Context, req *RegisterUserReque
 		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("invalid user name %q", req.UserName))
 	}
 
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, req.RemoteSourceName)
+	rs, err := h.GetRemoteSource(ctx, req.RemoteSourceName)
 	if err != nil {
 		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", req.RemoteSourceName))
 	}
@@ -369,7 +374,7 @@ func (h *ActionHandler) RegisterUser(ctx context.Context, req *RegisterUserReque
 		return nil, errors.WithStack(err)
 	}
 
-	remoteUserInfo, err := userSource.GetUserInfo()
+	remoteUserInfo, err := h.getRemoteUserInfo(userSource, rs.ID, accessToken)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to retrieve remote user info for remote source %q", rs.ID)
 	}
@@ -383,6 +388,8 @@ func (h *ActionHandler) RegisterUser(ctx context.Context, req *RegisterUserReque
 			RemoteSourceName:           req.RemoteSourceName,
 			RemoteUserID:               remoteUserInfo.ID,
 			RemoteUserName:             remoteUserInfo.LoginName,
+			RemoteUserDisplayName:      remoteUserInfo.DisplayName,
+			RemoteUserAvatarURL:        remoteUserInfo.AvatarURL,
 			UserAccessToken:            req.UserAccessToken,
 			Oauth2AccessToken:          req.Oauth2AccessToken,
 			Oauth2RefreshToken:         req.Oauth2RefreshToken,
@@ -414,7 +421,7 @@ type LoginUserResponse struct {
 }
 
 func (h *ActionHandler) LoginUser(ctx context.Context, req *LoginUserRequest) (*LoginUserResponse, error) {
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, req.RemoteSourceName)
+	rs, err := h.GetRemoteSource(ctx, req.RemoteSourceName)
 	if err != nil {
 		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", req.RemoteSourceName))
 	}
@@ -431,7 +438,7 @@ func (h *ActionHandler) LoginUser(ctx context.Context, req *LoginUserRequest) (*
 		return nil, errors.WithStack(err)
 	}
 
-	remoteUserInfo, err := userSource.GetUserInfo()
+	remoteUserInfo, err := h.getRemoteUserInfo(userSource, rs.ID, accessToken)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to retrieve remote user info for remote source %q", rs.ID)
 	}
@@ -461,17 +468,26 @@ func (h *ActionHandler) LoginUser(ctx context.Context, req *LoginUserRequest) (*
 	}
 
 	// Update oauth tokens if they have changed since the getuserinfo request may have updated them
+	// Also refresh the remote user profile if changed
 	if la.Oauth2AccessToken != req.Oauth2AccessToken ||
 		la.Oauth2RefreshToken != req.Oauth2RefreshToken ||
-		la.UserAccessToken != req.UserAccessToken {
+		la.UserAccessToken != req.UserAccessToken ||
+		la.RemoteUserName != remoteUserInfo.LoginName ||
+		la.RemoteUserDisplayName != remoteUserInfo.DisplayName ||
+		la.RemoteUserAvatarURL != remoteUserInfo.AvatarURL {
 
 		la.Oauth2AccessToken = req.Oauth2AccessToken
 		la.Oauth2RefreshToken = req.Oauth2RefreshToken
 		la.UserAccessToken = req.UserAccessToken
+		la.RemoteUserName = remoteUserInfo.LoginName
+		la.RemoteUserDisplayName = remoteUserInfo.DisplayName
+		la.RemoteUserAvatarURL = remoteUserInfo.AvatarURL
 
 		creq := &csapitypes.UpdateUserLARequest{
 			RemoteUserID:               la.RemoteUserID,
 			RemoteUserName:             la.RemoteUserName,
+			RemoteUserDisplayName:      la.RemoteUserDisplayName,
+			RemoteUserAvatarURL:        la.RemoteUserAvatarURL,
 			UserAccessToken:            la.UserAccessToken,
 			Oauth2AccessToken:          la.Oauth2AccessToken,
 			Oauth2RefreshToken:         la.Oauth2RefreshToken,
@@ -484,6 +500,12 @@ func (h *ActionHandler) LoginUser(ctx context.Context, req *LoginUserRequest) (*
 			return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to update user"))
 		}
 		h.log.Info().Msgf("linked account %q for user %q updated", la.ID, user.Name)
+
+		// get the user again since its profile could have been updated
+		user, _, err = h.configstoreClient.GetUser(ctx, user.ID)
+		if err != nil {
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q", la.UserID))
+		}
 	}
 
 	// generate jwt token
@@ -511,7 +533,7 @@ type AuthorizeResponse struct {
 }
 
 func (h *ActionHandler) Authorize(ctx context.Context, req *AuthorizeRequest) (*AuthorizeResponse, error) {
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, req.RemoteSourceName)
+	rs, err := h.GetRemoteSource(ctx, req.RemoteSourceName)
 	if err != nil {
 		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", req.RemoteSourceName))
 	}
@@ -525,7 +547,7 @@ func (h *ActionHandler) Authorize(ctx context.Context, req *AuthorizeRequest) (*
 		return nil, errors.WithStack(err)
 	}
 
-	remoteUserInfo, err := userSource.GetUserInfo()
+	remoteUserInfo, err := h.getRemoteUserInfo(userSource, rs.ID, accessToken)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to retrieve remote user info for remote source %q", rs.ID)
 	}
@@ -545,11 +567,17 @@ type RemoteSourceAuthResponse struct {
 }
 
 func (h *ActionHandler) HandleRemoteSourceAuth(ctx context.Context, remoteSourceName, loginName, loginPassword string, requestType RemoteSourceRequestType, req interface{}) (*RemoteSourceAuthResponse, error) {
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, remoteSourceName)
+	rs, err := h.GetRemoteSource(ctx, remoteSourceName)
 	if err != nil {
 		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", remoteSourceName))
 	}
 
+	// plain git sources cannot authenticate users so they can only be used to
+	// create linked accounts
+	if rs.Type == cstypes.RemoteSourceTypeGit && requestType != RemoteSourceRequestTypeCreateUserLA {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("remote source %q of type %q cannot be used for user authentication", rs.Name, rs.Type))
+	}
+
 	switch requestType {
 	case RemoteSourceRequestTypeCreateUserLA:
 		req := req.(*CreateUserLARequest)
@@ -787,7 +815,7 @@ func (h *ActionHandler) HandleOauth2Callback(ctx context.Context, code, state st
 	requestType := RemoteSourceRequestType(claims["request_type"].(string))
 	requestString := claims["request"].(string)
 
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, remoteSourceName)
+	rs, err := h.GetRemoteSource(ctx, remoteSourceName)
 	if err != nil {
 		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q", remoteSourceName))
 	}
@@ -840,6 +868,32 @@ func (h *ActionHandler) DeleteUserLA(ctx context.Context, userRef, laID string)
 	return nil
 }
 
+func (h *ActionHandler) GetUserTokens(ctx context.Context, userRef string) ([]*cstypes.UserToken, error) {
+	if !common.IsUserLoggedOrAdmin(ctx) {
+		return nil, errors.Errorf("user not logged in")
+	}
+
+	isAdmin := common.IsUserAdmin(ctx)
+	curUserID := common.CurrentUserID(ctx)
+
+	user, _, err := h.configstoreClient.GetUser(ctx, userRef)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q", userRef))
+	}
+
+	// only admin or the same logged user can get the tokens
+	if !isAdmin && user.ID != curUserID {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("logged in user cannot get tokens of another user"))
+	}
+
+	tokens, _, err := h.configstoreClient.GetUserTokens(ctx, user.ID)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q tokens", user.ID))
+	}
+
+	return tokens, nil
+}
+
 func (h *ActionHandler) DeleteUserToken(ctx context.Context, userRef, tokenName string) error {
 	if !common.IsUserLoggedOrAdmin(ctx) {
 		return errors.Errorf("user not logged in")
@@ -875,6 +929,11 @@ type UserCreateRunRequest struct {
 
 	PullRequestRefRegexes []string
 	Variables             map[string]string
+
+	RunNames  []string
+	TaskNames []string
+
+	ChangedFiles []string
 }
 
 func (h *ActionHandler) UserCreateRun(ctx context.Context, req *UserCreateRunRequest) error {
@@ -927,8 +986,23 @@ func (h *ActionHandler) UserCreateRun(ctx context.Context, req *UserCreateRunReq
 		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("only one of branch, tag or ref can be provided"))
 	}
 
-	gitSource := agolagit.New(h.apiExposedURL+"/repos", prRefRegexes)
-	cloneURL := fmt.Sprintf("%s/%s.git", h.apiExposedURL+"/repos", req.RepoPath)
+	// generate a token granting read access only to this repository, used to
+	// fetch the run config. The run tasks get their own token from the
+	// runservice
+	repoToken, err := scommon.GenerateRepoJWTToken(h.sd, req.RepoPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	reposURL, err := url.Parse(h.apiExposedURL + "/repos")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// the run tasks get their token in a dedicated env var, provided to git
+	// by a credential helper, so keep it out of the clone url
+	cloneURL := fmt.Sprintf("%s/%s.git", reposURL.String(), req.RepoPath)
+	reposURL.User = url.UserPassword("agola", repoToken)
+
+	gitSource := agolagit.New(reposURL.String(), prRefRegexes)
 
 	if ref == "" {
 		if branch != "" {
@@ -986,6 +1060,8 @@ func (h *ActionHandler) UserCreateRun(ctx context.Context, req *UserCreateRunReq
 		PullRequestID: pullRequestID,
 		CloneURL:      cloneURL,
 
+		CloneRepoTokenPath: req.RepoPath,
+
 		CommitLink:      "",
 		BranchLink:      "",
 		TagLink:         "",
@@ -993,7 +1069,68 @@ func (h *ActionHandler) UserCreateRun(ctx context.Context, req *UserCreateRunReq
 
 		UserRunRepoUUID: req.RepoUUID,
 		Variables:       req.Variables,
+		RunNames:        req.RunNames,
+		TaskNames:       req.TaskNames,
+		ChangedFiles:    req.ChangedFiles,
 	}
 
 	return h.CreateRuns(ctx, creq)
 }
+
+func (h *ActionHandler) GetUserPreferences(ctx context.Context) (*cstypes.UserPreferences, error) {
+	userID := common.CurrentUserID(ctx)
+	if userID == "" {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("user not authenticated"))
+	}
+
+	userPreferences, _, err := h.configstoreClient.GetUserPreferences(ctx, userID)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q preferences", userID))
+	}
+
+	return userPreferences, nil
+}
+
+type UpdateUserPreferencesRequest struct {
+	DefaultOrgRef     string
+	DefaultProjectRef string
+	Timezone          string
+	DateFormat        string
+	UISettings        json.RawMessage
+}
+
+func (h *ActionHandler) UpdateUserPreferences(ctx context.Context, req *UpdateUserPreferencesRequest) (*cstypes.UserPreferences, error) {
+	userID := common.CurrentUserID(ctx)
+	if userID == "" {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("user not authenticated"))
+	}
+
+	creq := &csapitypes.UpdateUserPreferencesRequest{
+		Timezone:   req.Timezone,
+		DateFormat: req.DateFormat,
+		UISettings: req.UISettings,
+	}
+
+	if req.DefaultOrgRef != "" {
+		org, err := h.GetOrg(ctx, req.DefaultOrgRef)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		creq.DefaultOrgRef = org.ID
+	}
+	if req.DefaultProjectRef != "" {
+		// use GetProject to also check that the user can see the project
+		project, err := h.GetProject(ctx, req.DefaultProjectRef)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		creq.DefaultProjectRef = project.ID
+	}
+
+	userPreferences, _, err := h.configstoreClient.UpdateUserPreferences(ctx, userID, creq)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to update user %q preferences", userID))
+	}
+
+	return userPreferences, nil
+}
@@ -16,6 +16,7 @@ package action
 
 import (
 	"context"
+	"sort"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/services/common"
@@ -67,6 +68,74 @@ func (h *ActionHandler) GetVariables(ctx context.Context, req *GetVariablesReque
 	return csvars, cssecrets, nil
 }
 
+// ProjectVariablesReport contains the effective variables of a project and
+// all the secrets visible to it
+type ProjectVariablesReport struct {
+	Project   *csapitypes.Project
+	Variables []*csapitypes.Variable
+	Secrets   []*csapitypes.Secret
+}
+
+// GetProjectGroupVariablesReport returns, for every project in the project
+// group subtree, the variables and secrets resolved by the project
+func (h *ActionHandler) GetProjectGroupVariablesReport(ctx context.Context, projectGroupRef string) ([]*ProjectVariablesReport, error) {
+	isVariableOwner, err := h.IsVariableOwner(ctx, cstypes.ObjectKindProjectGroup, projectGroupRef)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to determine ownership")
+	}
+	if !isVariableOwner {
+		return nil, util.NewAPIError(util.ErrForbidden, errors.Errorf("user not authorized"))
+	}
+
+	pg, _, err := h.configstoreClient.GetProjectGroup(ctx, projectGroupRef)
+	if err != nil {
+		return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project group %q", projectGroupRef))
+	}
+
+	projects := []*csapitypes.Project{}
+	pgIDs := []string{pg.ID}
+	for len(pgIDs) > 0 {
+		pgID := pgIDs[0]
+		pgIDs = pgIDs[1:]
+
+		pgProjects, _, err := h.configstoreClient.GetProjectGroupProjects(ctx, pgID, nil)
+		if err != nil {
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project group %q projects", pgID))
+		}
+		projects = append(projects, pgProjects...)
+
+		subgroups, _, err := h.configstoreClient.GetProjectGroupSubgroups(ctx, pgID)
+		if err != nil {
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project group %q subgroups", pgID))
+		}
+		for _, subgroup := range subgroups {
+			pgIDs = append(pgIDs, subgroup.ID)
+		}
+	}
+
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Path < projects[j].Path })
+
+	reports := make([]*ProjectVariablesReport, len(projects))
+	for i, p := range projects {
+		csvars, _, err := h.configstoreClient.GetProjectVariables(ctx, p.ID, true)
+		if err != nil {
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q variables", p.Path))
+		}
+		cssecrets, _, err := h.configstoreClient.GetProjectSecrets(ctx, p.ID, true)
+		if err != nil {
+			return nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %q secrets", p.Path))
+		}
+
+		reports[i] = &ProjectVariablesReport{
+			Project:   p,
+			Variables: common.FilterOverriddenVariables(csvars),
+			Secrets:   cssecrets,
+		}
+	}
+
+	return reports, nil
+}
+
 type CreateVariableRequest struct {
 	Name string
 
@@ -0,0 +1,229 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agola.io/agola/internal/services/gateway/common"
+	"agola.io/agola/internal/testutil"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	csclient "agola.io/agola/services/configstore/client"
+	cstypes "agola.io/agola/services/configstore/types"
+	stypes "agola.io/agola/services/types"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// variablesReportTestConfigstore fakes the configstore api calls done when
+// generating a project group variables report. The project group pg01
+// contains the project projectb and the subgroup pg02 containing the project
+// projecta.
+func variablesReportTestConfigstore(t *testing.T) *httptest.Server {
+	projectGroup := func(id, path string) *csapitypes.ProjectGroup {
+		return &csapitypes.ProjectGroup{
+			ProjectGroup: &cstypes.ProjectGroup{ObjectMeta: stypes.ObjectMeta{ID: id}, Name: id},
+			OwnerType:    cstypes.ObjectKindUser,
+			OwnerID:      "user01",
+			Path:         path,
+		}
+	}
+	project := func(id, path string) *csapitypes.Project {
+		return &csapitypes.Project{
+			Project:   &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: id}, Name: id},
+			OwnerType: cstypes.ObjectKindUser,
+			OwnerID:   "user01",
+			Path:      path,
+		}
+	}
+	variable := func(name, parentPath string) *csapitypes.Variable {
+		return &csapitypes.Variable{Variable: &cstypes.Variable{Name: name}, ParentPath: parentPath}
+	}
+	secret := func(name, parentPath string) *csapitypes.Secret {
+		return &csapitypes.Secret{Secret: &cstypes.Secret{Name: name}, ParentPath: parentPath}
+	}
+
+	projectGroups := map[string]*csapitypes.ProjectGroup{
+		"pg01": projectGroup("pg01", "user/user01/pg01"),
+		"pg02": projectGroup("pg02", "user/user01/pg01/pg02"),
+	}
+	subgroups := map[string][]*csapitypes.ProjectGroup{
+		"pg01": {projectGroups["pg02"]},
+		"pg02": {},
+	}
+	projects := map[string][]*csapitypes.Project{
+		"pg01": {project("projectb", "user/user01/pg01/projectb")},
+		"pg02": {project("projecta", "user/user01/pg01/pg02/projecta")},
+	}
+	variables := map[string][]*csapitypes.Variable{
+		"projecta": {
+			variable("var01", "user/user01/pg01/pg02/projecta"),
+			variable("var02", "user/user01/pg01/pg02"),
+			variable("var01", "user/user01/pg01"),
+		},
+		"projectb": {
+			variable("var01", "user/user01/pg01"),
+		},
+	}
+	secrets := map[string][]*csapitypes.Secret{
+		"projecta": {secret("secret01", "user/user01/pg01")},
+		"projectb": {secret("secret01", "user/user01/pg01")},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1alpha/"), "/")
+		switch {
+		case r.Method == "GET" && len(parts) == 2 && parts[0] == "projectgroups" && projectGroups[parts[1]] != nil:
+			writeTestJSON(t, w, http.StatusOK, projectGroups[parts[1]])
+		case r.Method == "GET" && len(parts) == 3 && parts[0] == "projectgroups" && parts[2] == "subgroups":
+			writeTestJSON(t, w, http.StatusOK, subgroups[parts[1]])
+		case r.Method == "GET" && len(parts) == 3 && parts[0] == "projectgroups" && parts[2] == "projects":
+			writeTestJSON(t, w, http.StatusOK, projects[parts[1]])
+		case r.Method == "GET" && len(parts) == 3 && parts[0] == "projects" && parts[2] == "variables":
+			if _, ok := r.URL.Query()["tree"]; !ok {
+				t.Errorf("expected tree variables request")
+			}
+			writeTestJSON(t, w, http.StatusOK, variables[parts[1]])
+		case r.Method == "GET" && len(parts) == 3 && parts[0] == "projects" && parts[2] == "secrets":
+			if _, ok := r.URL.Query()["tree"]; !ok {
+				t.Errorf("expected tree secrets request")
+			}
+			writeTestJSON(t, w, http.StatusOK, secrets[parts[1]])
+		default:
+			writeTestJSON(t, w, http.StatusNotFound, map[string]string{"message": "not found"})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// variablesReportSummary is a project variables report with only the
+// project path and the variables and secrets names and parent paths
+type variablesReportSummary struct {
+	Project   string
+	Variables []string
+	Secrets   []string
+}
+
+func TestGetProjectGroupVariablesReport(t *testing.T) {
+	log := testutil.NewLogger(t)
+	h := NewActionHandler(log, nil, csclient.NewClient(variablesReportTestConfigstore(t).URL), nil, nil, "agola", "", "", nil, "")
+
+	userCtx := func(userID string) context.Context {
+		return context.WithValue(context.Background(), common.ContextKeyUserID, userID)
+	}
+
+	tests := []struct {
+		name            string
+		ctx             context.Context
+		projectGroupRef string
+		out             []variablesReportSummary
+		err             bool
+		errKind         util.ErrorKind
+	}{
+		{
+			name:            "root project group",
+			ctx:             userCtx("user01"),
+			projectGroupRef: "pg01",
+			out: []variablesReportSummary{
+				{
+					Project:   "user/user01/pg01/pg02/projecta",
+					Variables: []string{"var01 user/user01/pg01/pg02/projecta", "var02 user/user01/pg01/pg02"},
+					Secrets:   []string{"secret01 user/user01/pg01"},
+				},
+				{
+					Project:   "user/user01/pg01/projectb",
+					Variables: []string{"var01 user/user01/pg01"},
+					Secrets:   []string{"secret01 user/user01/pg01"},
+				},
+			},
+		},
+		{
+			name:            "subgroup",
+			ctx:             userCtx("user01"),
+			projectGroupRef: "pg02",
+			out: []variablesReportSummary{
+				{
+					Project:   "user/user01/pg01/pg02/projecta",
+					Variables: []string{"var01 user/user01/pg01/pg02/projecta", "var02 user/user01/pg01/pg02"},
+					Secrets:   []string{"secret01 user/user01/pg01"},
+				},
+			},
+		},
+		{
+			name:            "admin",
+			ctx:             context.WithValue(userCtx("user02"), common.ContextKeyUserAdmin, true),
+			projectGroupRef: "pg02",
+			out: []variablesReportSummary{
+				{
+					Project:   "user/user01/pg01/pg02/projecta",
+					Variables: []string{"var01 user/user01/pg01/pg02/projecta", "var02 user/user01/pg01/pg02"},
+					Secrets:   []string{"secret01 user/user01/pg01"},
+				},
+			},
+		},
+		{
+			name:            "not owner user",
+			ctx:             userCtx("user02"),
+			projectGroupRef: "pg01",
+			err:             true,
+			errKind:         util.ErrForbidden,
+		},
+		{
+			name:            "not existing project group",
+			ctx:             userCtx("user01"),
+			projectGroupRef: "pg03",
+			err:             true,
+			errKind:         util.ErrNotExist,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports, err := h.GetProjectGroupVariablesReport(tt.ctx, tt.projectGroupRef)
+			if tt.err {
+				if !util.APIErrorIs(err, tt.errKind) {
+					t.Fatalf("expected %s error, got: %v", tt.errKind, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			out := []variablesReportSummary{}
+			for _, report := range reports {
+				summary := variablesReportSummary{Project: report.Project.Path}
+				for _, v := range report.Variables {
+					summary.Variables = append(summary.Variables, v.Name+" "+v.ParentPath)
+				}
+				for _, s := range report.Secrets {
+					summary.Secrets = append(summary.Secrets, s.Name+" "+s.ParentPath)
+				}
+				out = append(out, summary)
+			}
+
+			if diff := cmp.Diff(tt.out, out); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
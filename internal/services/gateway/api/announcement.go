@@ -0,0 +1,132 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"agola.io/agola/internal/services/gateway/action"
+	"agola.io/agola/internal/util"
+	cstypes "agola.io/agola/services/configstore/types"
+	gwapitypes "agola.io/agola/services/gateway/api/types"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+)
+
+func createAnnouncementResponse(a *cstypes.Announcement) *gwapitypes.AnnouncementResponse {
+	return &gwapitypes.AnnouncementResponse{
+		ID:           a.ID,
+		Message:      a.Message,
+		Severity:     string(a.Severity),
+		CreationTime: a.CreationTime,
+		ExpireTime:   a.ExpireTime,
+	}
+}
+
+type AnnouncementsHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewAnnouncementsHandler(log zerolog.Logger, ah *action.ActionHandler) *AnnouncementsHandler {
+	return &AnnouncementsHandler{log: log, ah: ah}
+}
+
+func (h *AnnouncementsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	_, all := query["all"]
+
+	announcements, err := h.ah.GetAnnouncements(ctx, all)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := make([]*gwapitypes.AnnouncementResponse, len(announcements))
+	for i, a := range announcements {
+		res[i] = createAnnouncementResponse(a)
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type CreateAnnouncementHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewCreateAnnouncementHandler(log zerolog.Logger, ah *action.ActionHandler) *CreateAnnouncementHandler {
+	return &CreateAnnouncementHandler{log: log, ah: ah}
+}
+
+func (h *CreateAnnouncementHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req gwapitypes.CreateAnnouncementRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	areq := &action.CreateAnnouncementRequest{
+		Message:    req.Message,
+		Severity:   cstypes.AnnouncementSeverity(req.Severity),
+		ExpireTime: req.ExpireTime,
+	}
+
+	announcement, err := h.ah.CreateAnnouncement(ctx, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := createAnnouncementResponse(announcement)
+	if err := util.HTTPResponse(w, http.StatusCreated, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type ExpireAnnouncementHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewExpireAnnouncementHandler(log zerolog.Logger, ah *action.ActionHandler) *ExpireAnnouncementHandler {
+	return &ExpireAnnouncementHandler{log: log, ah: ah}
+}
+
+func (h *ExpireAnnouncementHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	announcementID := vars["announcementid"]
+
+	announcement, err := h.ah.ExpireAnnouncement(ctx, announcementID)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := createAnnouncementResponse(announcement)
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
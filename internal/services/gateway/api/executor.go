@@ -0,0 +1,121 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"agola.io/agola/internal/services/gateway/action"
+	"agola.io/agola/internal/util"
+	gwapitypes "agola.io/agola/services/gateway/api/types"
+	rstypes "agola.io/agola/services/runservice/types"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+)
+
+func createExecutorResponse(e *rstypes.Executor) *gwapitypes.ExecutorResponse {
+	archs := make([]string, len(e.Archs))
+	for i, arch := range e.Archs {
+		archs[i] = string(arch)
+	}
+
+	var health *gwapitypes.ExecutorHealth
+	if e.Health != nil {
+		health = &gwapitypes.ExecutorHealth{
+			DiskTotal:  e.Health.DiskTotal,
+			DiskFree:   e.Health.DiskFree,
+			ImagesSize: e.Health.ImagesSize,
+			Load1:      e.Health.Load1,
+			CPUs:       e.Health.CPUs,
+		}
+	}
+
+	return &gwapitypes.ExecutorResponse{
+		ExecutorID:       e.ExecutorID,
+		ListenURL:        e.ListenURL,
+		Archs:            archs,
+		Labels:           e.Labels,
+		ActiveTasksLimit: e.ActiveTasksLimit,
+		ActiveTasks:      e.ActiveTasks,
+		Draining:         e.Draining,
+		State:            string(e.State),
+		LastUpdateTime:   e.UpdateTime,
+		Health:           health,
+		HealthProblems:   e.HealthProblems,
+	}
+}
+
+type ExecutorsHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewExecutorsHandler(log zerolog.Logger, ah *action.ActionHandler) *ExecutorsHandler {
+	return &ExecutorsHandler{log: log, ah: ah}
+}
+
+func (h *ExecutorsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	executors, err := h.ah.GetExecutors(ctx)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := make([]*gwapitypes.ExecutorResponse, len(executors))
+	for i, e := range executors {
+		res[i] = createExecutorResponse(e)
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type ExecutorActionsHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewExecutorActionsHandler(log zerolog.Logger, ah *action.ActionHandler) *ExecutorActionsHandler {
+	return &ExecutorActionsHandler{log: log, ah: ah}
+}
+
+func (h *ExecutorActionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	executorID := vars["executorid"]
+
+	var req gwapitypes.ExecutorActionsRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	areq := &action.ExecutorActionsRequest{
+		ExecutorID: executorID,
+		ActionType: action.ExecutorActionType(req.ActionType),
+	}
+
+	err := h.ah.ExecutorAction(ctx, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+}
@@ -52,11 +52,12 @@ func (h *OAuth2CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		authresp := cresp.Response.(*action.CreateUserLAResponse)
 		response = &gwapitypes.CreateUserLAResponse{
 			LinkedAccount: &gwapitypes.LinkedAccount{
-				ID:                  authresp.LinkedAccount.ID,
-				RemoteUserID:        authresp.LinkedAccount.RemoteUserID,
-				RemoteUserName:      authresp.LinkedAccount.RemoteUserName,
-				RemoteUserAvatarURL: authresp.LinkedAccount.RemoteUserAvatarURL,
-				RemoteSourceID:      authresp.LinkedAccount.RemoteUserID,
+				ID:                    authresp.LinkedAccount.ID,
+				RemoteUserID:          authresp.LinkedAccount.RemoteUserID,
+				RemoteUserName:        authresp.LinkedAccount.RemoteUserName,
+				RemoteUserDisplayName: authresp.LinkedAccount.RemoteUserDisplayName,
+				RemoteUserAvatarURL:   authresp.LinkedAccount.RemoteUserAvatarURL,
+				RemoteSourceID:        authresp.LinkedAccount.RemoteUserID,
 			},
 		}
 
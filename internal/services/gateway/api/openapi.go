@@ -0,0 +1,76 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"agola.io/agola/internal/services/gateway/openapi"
+
+	"github.com/rs/zerolog"
+)
+
+type OpenAPIHandler struct {
+	log zerolog.Logger
+}
+
+func NewOpenAPIHandler(log zerolog.Logger) *OpenAPIHandler {
+	return &OpenAPIHandler{log: log}
+}
+
+func (h *OpenAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write([]byte(openapi.Spec)); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+// swaggerUIPage is the Swagger UI page showing the gateway OpenAPI
+// specification. The Swagger UI assets are loaded from a public CDN.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8" />
+  <title>Agola API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@4/swagger-ui.css" />
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@4/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function() {
+      window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+type SwaggerUIHandler struct {
+	log zerolog.Logger
+}
+
+func NewSwaggerUIHandler(log zerolog.Logger) *SwaggerUIHandler {
+	return &SwaggerUIHandler{log: log}
+}
+
+func (h *SwaggerUIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if _, err := w.Write([]byte(swaggerUIPage)); err != nil {
+		h.log.Err(err).Send()
+	}
+}
@@ -15,9 +15,14 @@
 package api
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/services/gateway/action"
@@ -69,6 +74,43 @@ func (h *CreateOrgHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+type UpdateOrgHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewUpdateOrgHandler(log zerolog.Logger, ah *action.ActionHandler) *UpdateOrgHandler {
+	return &UpdateOrgHandler{log: log, ah: ah}
+}
+
+func (h *UpdateOrgHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	orgRef := vars["orgref"]
+
+	var req gwapitypes.UpdateOrgRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	areq := &action.UpdateOrgRequest{
+		ConcurrencyLimits: fromConcurrencyLimits(req.ConcurrencyLimits),
+	}
+
+	org, err := h.ah.UpdateOrg(ctx, orgRef, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := createOrgResponse(org)
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
 type DeleteOrgHandler struct {
 	log zerolog.Logger
 	ah  *action.ActionHandler
@@ -122,13 +164,22 @@ func (h *OrgHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func createOrgResponse(o *cstypes.Organization) *gwapitypes.OrgResponse {
 	org := &gwapitypes.OrgResponse{
-		ID:         o.ID,
-		Name:       o.Name,
-		Visibility: gwapitypes.Visibility(o.Visibility),
+		ID:                o.ID,
+		Name:              o.Name,
+		Visibility:        gwapitypes.Visibility(o.Visibility),
+		ConcurrencyLimits: toConcurrencyLimits(o.ConcurrencyLimits),
 	}
 	return org
 }
 
+func fromConcurrencyLimits(l gwapitypes.ConcurrencyLimits) cstypes.ConcurrencyLimits {
+	return cstypes.ConcurrencyLimits{MaxRunningRuns: l.MaxRunningRuns, MaxRunningTasks: l.MaxRunningTasks}
+}
+
+func toConcurrencyLimits(l cstypes.ConcurrencyLimits) gwapitypes.ConcurrencyLimits {
+	return gwapitypes.ConcurrencyLimits{MaxRunningRuns: l.MaxRunningRuns, MaxRunningTasks: l.MaxRunningTasks}
+}
+
 type OrgsHandler struct {
 	log zerolog.Logger
 	ah  *action.ActionHandler
@@ -297,3 +348,121 @@ func (h *RemoveOrgMemberHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 		h.log.Err(err).Send()
 	}
 }
+
+const (
+	// DefaultOrgUsagePeriod is the usage time range when no start time is
+	// provided
+	DefaultOrgUsagePeriod = 30 * 24 * time.Hour
+)
+
+type OrgUsageHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewOrgUsageHandler(log zerolog.Logger, ah *action.ActionHandler) *OrgUsageHandler {
+	return &OrgUsageHandler{log: log, ah: ah}
+}
+
+func (h *OrgUsageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	vars := mux.Vars(r)
+	orgRef := vars["orgref"]
+
+	endTime := time.Now()
+	if endS := query.Get("end"); endS != "" {
+		var err error
+		endTime, err = time.Parse(time.RFC3339, endS)
+		if err != nil {
+			util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "cannot parse end time")))
+			return
+		}
+	}
+	startTime := endTime.Add(-DefaultOrgUsagePeriod)
+	if startS := query.Get("start"); startS != "" {
+		var err error
+		startTime, err = time.Parse(time.RFC3339, startS)
+		if err != nil {
+			util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "cannot parse start time")))
+			return
+		}
+	}
+
+	format := query.Get("format")
+	switch format {
+	case "", "json", "csv":
+	default:
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("unknown format %q", format)))
+		return
+	}
+
+	areq := &action.GetOrgUsageRequest{
+		OrgRef:    orgRef,
+		StartTime: startTime,
+		EndTime:   endTime,
+	}
+	ares, err := h.ah.GetOrgUsage(ctx, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := createOrgUsageResponse(ares)
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-usage.csv", ares.Organization.Name)))
+		if err := writeOrgUsageCSV(w, res); err != nil {
+			h.log.Err(err).Send()
+		}
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+func createOrgUsageResponse(u *action.OrgUsageResponse) *gwapitypes.OrgUsageResponse {
+	res := &gwapitypes.OrgUsageResponse{
+		Organization: createOrgResponse(u.Organization),
+		StartTime:    u.StartTime,
+		EndTime:      u.EndTime,
+		Projects:     make([]*gwapitypes.ProjectUsageResponse, len(u.Projects)),
+	}
+	for i, pu := range u.Projects {
+		res.Projects[i] = &gwapitypes.ProjectUsageResponse{
+			ID:          pu.Project.ID,
+			Path:        pu.Project.Path,
+			Runs:        pu.Runs,
+			TaskMinutes: math.Round(pu.TaskDuration.Minutes()*100) / 100,
+		}
+	}
+
+	return res
+}
+
+func writeOrgUsageCSV(w io.Writer, res *gwapitypes.OrgUsageResponse) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"project_id", "project_path", "start_time", "end_time", "runs", "task_minutes"}); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, pu := range res.Projects {
+		record := []string{
+			pu.ID,
+			pu.Path,
+			res.StartTime.Format(time.RFC3339),
+			res.EndTime.Format(time.RFC3339),
+			strconv.FormatUint(pu.Runs, 10),
+			strconv.FormatFloat(pu.TaskMinutes, 'f', 2, 64),
+		}
+		if err := cw.Write(record); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	cw.Flush()
+
+	return errors.WithStack(cw.Error())
+}
@@ -49,13 +49,26 @@ func (h *CreateProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 
 	areq := &action.CreateProjectRequest{
-		Name:                req.Name,
-		ParentRef:           req.ParentRef,
-		Visibility:          cstypes.Visibility(req.Visibility),
-		RepoPath:            req.RepoPath,
-		RemoteSourceName:    req.RemoteSourceName,
-		SkipSSHHostKeyCheck: req.SkipSSHHostKeyCheck,
-		PassVarsToForkedPR:  req.PassVarsToForkedPR,
+		Name:                    req.Name,
+		ParentRef:               req.ParentRef,
+		Visibility:              cstypes.Visibility(req.Visibility),
+		RepoPath:                req.RepoPath,
+		RemoteSourceName:        req.RemoteSourceName,
+		SkipSSHHostKeyCheck:     req.SkipSSHHostKeyCheck,
+		PassVarsToForkedPR:      req.PassVarsToForkedPR,
+		ReportSkippedRuns:       req.ReportSkippedRuns,
+		Tags:                    req.Tags,
+		PostPullRequestComments: req.PostPullRequestComments,
+		UseDepsProxy:            req.UseDepsProxy,
+		CancelSupersededRuns:    req.CancelSupersededRuns,
+		RunsVisibility:          cstypes.RunsVisibility(req.RunsVisibility),
+		LogsVisibility:          cstypes.RunsVisibility(req.LogsVisibility),
+		ProtectedBranches:       req.ProtectedBranches,
+		ProtectedTags:           req.ProtectedTags,
+		AllowedUpstreamProjects: req.AllowedUpstreamProjects,
+		Schedules:               fromProjectSchedules(req.Schedules),
+		ConcurrencyLimits:       fromConcurrencyLimits(req.ConcurrencyLimits),
+		ImportRepoTopics:        req.ImportRepoTopics,
 	}
 
 	project, err := h.ah.CreateProject(ctx, areq)
@@ -100,12 +113,45 @@ func (h *UpdateProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		v := cstypes.Visibility(*req.Visibility)
 		visibility = &v
 	}
+	var runsVisibility *cstypes.RunsVisibility
+	if req.RunsVisibility != nil {
+		v := cstypes.RunsVisibility(*req.RunsVisibility)
+		runsVisibility = &v
+	}
+	var logsVisibility *cstypes.RunsVisibility
+	if req.LogsVisibility != nil {
+		v := cstypes.RunsVisibility(*req.LogsVisibility)
+		logsVisibility = &v
+	}
+	var schedules *[]*cstypes.ProjectSchedule
+	if req.Schedules != nil {
+		s := fromProjectSchedules(*req.Schedules)
+		schedules = &s
+	}
+	var concurrencyLimits *cstypes.ConcurrencyLimits
+	if req.ConcurrencyLimits != nil {
+		l := fromConcurrencyLimits(*req.ConcurrencyLimits)
+		concurrencyLimits = &l
+	}
 
 	areq := &action.UpdateProjectRequest{
-		Name:               req.Name,
-		ParentRef:          req.ParentRef,
-		Visibility:         visibility,
-		PassVarsToForkedPR: req.PassVarsToForkedPR,
+		Name:                    req.Name,
+		ParentRef:               req.ParentRef,
+		Visibility:              visibility,
+		PassVarsToForkedPR:      req.PassVarsToForkedPR,
+		ReportSkippedRuns:       req.ReportSkippedRuns,
+		Tags:                    req.Tags,
+		PostPullRequestComments: req.PostPullRequestComments,
+		UseDepsProxy:            req.UseDepsProxy,
+		CancelSupersededRuns:    req.CancelSupersededRuns,
+		RunsVisibility:          runsVisibility,
+		LogsVisibility:          logsVisibility,
+		ProtectedBranches:       req.ProtectedBranches,
+		ProtectedTags:           req.ProtectedTags,
+		AllowedUpstreamProjects: req.AllowedUpstreamProjects,
+		Schedules:               schedules,
+		ConcurrencyLimits:       concurrencyLimits,
+		ImportRepoTopics:        req.ImportRepoTopics,
 	}
 	project, err := h.ah.UpdateProject(ctx, projectRef, areq)
 	if util.HTTPError(w, err) {
@@ -119,6 +165,110 @@ func (h *UpdateProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+type CloneProjectHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewCloneProjectHandler(log zerolog.Logger, ah *action.ActionHandler) *CloneProjectHandler {
+	return &CloneProjectHandler{log: log, ah: ah}
+}
+
+func (h *CloneProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	projectRef, err := url.PathUnescape(vars["projectref"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	var req gwapitypes.CloneProjectRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	areq := &action.CloneProjectRequest{
+		Name:             req.Name,
+		ParentRef:        req.ParentRef,
+		RemoteSourceName: req.RemoteSourceName,
+		RepoPath:         req.RepoPath,
+		CloneSecrets:     req.CloneSecrets,
+		CloneSecretsData: req.CloneSecretsData,
+	}
+
+	project, err := h.ah.CloneProject(ctx, projectRef, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := createProjectResponse(project)
+	if err := util.HTTPResponse(w, http.StatusCreated, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type ImportProjectsHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewImportProjectsHandler(log zerolog.Logger, ah *action.ActionHandler) *ImportProjectsHandler {
+	return &ImportProjectsHandler{log: log, ah: ah}
+}
+
+func (h *ImportProjectsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	projectGroupRef, err := url.PathUnescape(vars["projectgroupref"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	var req gwapitypes.ImportProjectsRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	areq := &action.ImportProjectsRequest{
+		RemoteSourceName:    req.RemoteSourceName,
+		RemoteOrg:           req.RemoteOrg,
+		Include:             req.Include,
+		Exclude:             req.Exclude,
+		DryRun:              req.DryRun,
+		Concurrency:         req.Concurrency,
+		Visibility:          cstypes.Visibility(req.Visibility),
+		SkipSSHHostKeyCheck: req.SkipSSHHostKeyCheck,
+		ImportRepoTopics:    req.ImportRepoTopics,
+	}
+
+	results, err := h.ah.ImportProjects(ctx, projectGroupRef, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := make([]*gwapitypes.ImportProjectResponse, len(results))
+	for i, r := range results {
+		res[i] = &gwapitypes.ImportProjectResponse{
+			RepoPath:    r.RepoPath,
+			ProjectName: r.ProjectName,
+			ProjectID:   r.ProjectID,
+			Status:      gwapitypes.ImportProjectStatus(r.Status),
+			Error:       r.Error,
+		}
+	}
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
 type ProjectReconfigHandler struct {
 	log zerolog.Logger
 	ah  *action.ActionHandler
@@ -146,6 +296,34 @@ func (h *ProjectReconfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+type PollProjectHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewPollProjectHandler(log zerolog.Logger, ah *action.ActionHandler) *PollProjectHandler {
+	return &PollProjectHandler{log: log, ah: ah}
+}
+
+func (h *PollProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	projectRef, err := url.PathUnescape(vars["projectref"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	if err := h.ah.PollPlainGitProject(ctx, projectRef); err != nil {
+		h.log.Err(err).Send()
+		util.HTTPError(w, err)
+		return
+	}
+	if err := util.HTTPResponse(w, http.StatusNoContent, nil); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
 type ProjectUpdateRepoLinkedAccountHandler struct {
 	log zerolog.Logger
 	ah  *action.ActionHandler
@@ -205,6 +383,36 @@ func (h *DeleteProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+type UndeleteProjectHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewUndeleteProjectHandler(log zerolog.Logger, ah *action.ActionHandler) *UndeleteProjectHandler {
+	return &UndeleteProjectHandler{log: log, ah: ah}
+}
+
+func (h *UndeleteProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	projectRef, err := url.PathUnescape(vars["projectref"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	project, err := h.ah.UndeleteProject(ctx, projectRef)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := createProjectResponse(project)
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
 type ProjectHandler struct {
 	log zerolog.Logger
 	ah  *action.ActionHandler
@@ -235,15 +443,52 @@ func (h *ProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func fromProjectSchedules(schedules []*gwapitypes.ProjectSchedule) []*cstypes.ProjectSchedule {
+	if schedules == nil {
+		return nil
+	}
+	res := make([]*cstypes.ProjectSchedule, 0, len(schedules))
+	for _, s := range schedules {
+		if s == nil {
+			continue
+		}
+		res = append(res, &cstypes.ProjectSchedule{Name: s.Name, Cron: s.Cron, Branch: s.Branch})
+	}
+	return res
+}
+
+func toProjectSchedules(schedules []*cstypes.ProjectSchedule) []*gwapitypes.ProjectSchedule {
+	if schedules == nil {
+		return nil
+	}
+	res := make([]*gwapitypes.ProjectSchedule, len(schedules))
+	for i, s := range schedules {
+		res[i] = &gwapitypes.ProjectSchedule{Name: s.Name, Cron: s.Cron, Branch: s.Branch}
+	}
+	return res
+}
+
 func createProjectResponse(r *csapitypes.Project) *gwapitypes.ProjectResponse {
 	res := &gwapitypes.ProjectResponse{
-		ID:                 r.ID,
-		Name:               r.Name,
-		Path:               r.Path,
-		ParentPath:         r.ParentPath,
-		Visibility:         gwapitypes.Visibility(r.Visibility),
-		GlobalVisibility:   string(r.GlobalVisibility),
-		PassVarsToForkedPR: r.PassVarsToForkedPR,
+		ID:                      r.ID,
+		Name:                    r.Name,
+		Path:                    r.Path,
+		ParentPath:              r.ParentPath,
+		Visibility:              gwapitypes.Visibility(r.Visibility),
+		GlobalVisibility:        string(r.GlobalVisibility),
+		PassVarsToForkedPR:      r.PassVarsToForkedPR,
+		ReportSkippedRuns:       r.ReportSkippedRuns,
+		Tags:                    r.Tags,
+		PostPullRequestComments: r.PostPullRequestComments,
+		UseDepsProxy:            r.UseDepsProxy,
+		CancelSupersededRuns:    r.CancelSupersededRuns,
+		RunsVisibility:          gwapitypes.RunsVisibility(r.RunsVisibility),
+		LogsVisibility:          gwapitypes.RunsVisibility(r.LogsVisibility),
+		ProtectedBranches:       r.ProtectedBranches,
+		ProtectedTags:           r.ProtectedTags,
+		AllowedUpstreamProjects: r.AllowedUpstreamProjects,
+		Schedules:               toProjectSchedules(r.Schedules),
+		ConcurrencyLimits:       toConcurrencyLimits(r.ConcurrencyLimits),
 	}
 
 	return res
@@ -274,7 +519,7 @@ func (h *ProjectCreateRunHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = h.ah.ProjectCreateRun(ctx, projectRef, req.Branch, req.Tag, req.Ref, req.CommitSHA)
+	err = h.ah.ProjectCreateRun(ctx, projectRef, req.Branch, req.Tag, req.Ref, req.CommitSHA, req.Variables, req.Inputs)
 	if util.HTTPError(w, err) {
 		h.log.Err(err).Send()
 		return
@@ -284,3 +529,45 @@ func (h *ProjectCreateRunHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 		h.log.Err(err).Send()
 	}
 }
+
+type ProjectCachesHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewProjectCachesHandler(log zerolog.Logger, ah *action.ActionHandler) *ProjectCachesHandler {
+	return &ProjectCachesHandler{log: log, ah: ah}
+}
+
+func (h *ProjectCachesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	projectRef, err := url.PathUnescape(vars["projectref"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	cacheGroup, err := h.ah.GetProjectCaches(ctx, projectRef)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := &gwapitypes.ProjectCachesResponse{
+		Size:   cacheGroup.Size,
+		Quota:  cacheGroup.Quota,
+		Caches: make([]*gwapitypes.ProjectCacheResponse, len(cacheGroup.Caches)),
+	}
+	for i, c := range cacheGroup.Caches {
+		res.Caches[i] = &gwapitypes.ProjectCacheResponse{
+			Key:      c.Key,
+			Size:     c.Size,
+			LastUsed: c.LastUsed,
+		}
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
@@ -200,7 +200,9 @@ func (h *ProjectGroupProjectsHandler) ServeHTTP(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	csprojects, err := h.ah.GetProjectGroupProjects(ctx, projectGroupRef)
+	tags := r.URL.Query()["tag"]
+
+	csprojects, err := h.ah.GetProjectGroupProjects(ctx, projectGroupRef, tags)
 	if util.HTTPError(w, err) {
 		h.log.Err(err).Send()
 		return
@@ -66,7 +66,7 @@ func (h *UserRemoteReposHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, remoteSourceRef)
+	rs, err := h.ah.GetRemoteSource(ctx, remoteSourceRef)
 	if util.HTTPError(w, err) {
 		h.log.Err(err).Send()
 		return
@@ -58,6 +58,8 @@ func (h *CreateRemoteSourceHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 		Oauth2ClientSecret:  req.Oauth2ClientSecret,
 		SSHHostKey:          req.SSHHostKey,
 		SkipSSHHostKeyCheck: req.SkipSSHHostKeyCheck,
+		SSHEndpoint:         req.SSHEndpoint,
+		OrgWebhooks:         req.OrgWebhooks,
 		RegistrationEnabled: req.RegistrationEnabled,
 		LoginEnabled:        req.LoginEnabled,
 	}
@@ -104,6 +106,8 @@ func (h *UpdateRemoteSourceHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 		Oauth2ClientSecret:  req.Oauth2ClientSecret,
 		SSHHostKey:          req.SSHHostKey,
 		SkipSSHHostKeyCheck: req.SkipSSHHostKeyCheck,
+		SSHEndpoint:         req.SSHEndpoint,
+		OrgWebhooks:         req.OrgWebhooks,
 		RegistrationEnabled: req.RegistrationEnabled,
 		LoginEnabled:        req.LoginEnabled,
 	}
@@ -126,6 +130,8 @@ func createRemoteSourceResponse(r *cstypes.RemoteSource) *gwapitypes.RemoteSourc
 		AuthType:            string(r.AuthType),
 		RegistrationEnabled: *r.RegistrationEnabled,
 		LoginEnabled:        *r.LoginEnabled,
+		OrgWebhooks:         r.OrgWebhooks,
+		SSHEndpoint:         r.SSHEndpoint,
 	}
 	return rs
 }
@@ -238,3 +244,35 @@ func (h *DeleteRemoteSourceHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 		h.log.Err(err).Send()
 	}
 }
+
+type RemoteSourceRateLimitHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewRemoteSourceRateLimitHandler(log zerolog.Logger, ah *action.ActionHandler) *RemoteSourceRateLimitHandler {
+	return &RemoteSourceRateLimitHandler{log: log, ah: ah}
+}
+
+func (h *RemoteSourceRateLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	rsRef := vars["remotesourceref"]
+
+	status, err := h.ah.GetRemoteSourceRateLimit(ctx, rsRef)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := &gwapitypes.RemoteSourceRateLimitResponse{
+		Limit:               status.Limit,
+		Remaining:           status.Remaining,
+		Reset:               status.Reset,
+		ActiveRequests:      status.ActiveRequests,
+		RateLimitedRequests: status.RateLimitedRequests,
+	}
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
@@ -18,20 +18,96 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"path"
+	"strings"
 
+	"agola.io/agola/internal/errors"
+	scommon "agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/services/gateway/common"
 	util "agola.io/agola/internal/util"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
 )
 
+const (
+	gitSuffix = ".git"
+)
+
 type ReposHandler struct {
 	log          zerolog.Logger
+	sd           *scommon.TokenSigningData
+	client       *http.Client
 	gitServerURL string
 }
 
-func NewReposHandler(log zerolog.Logger, gitServerURL string) *ReposHandler {
-	return &ReposHandler{log: log, gitServerURL: gitServerURL}
+func NewReposHandler(log zerolog.Logger, sd *scommon.TokenSigningData, client *http.Client, gitServerURL string) *ReposHandler {
+	return &ReposHandler{log: log, sd: sd, client: client, gitServerURL: gitServerURL}
+}
+
+// parseRepoPath returns the repository path (without the .git suffix) and its
+// namespace (the first path component, the user id for user direct runs
+// repositories) from the requested path.
+func parseRepoPath(p string) (string, string, error) {
+	if p == "" || path.Clean("/"+p) != "/"+strings.TrimSuffix(p, "/") {
+		return "", "", errors.Errorf("wrong repo path %q", p)
+	}
+
+	idx := strings.Index(p, gitSuffix+"/")
+	if idx < 0 {
+		return "", "", errors.Errorf("wrong repo path %q", p)
+	}
+	repoPath := p[:idx]
+
+	parts := strings.Split(repoPath, "/")
+	if len(parts) < 2 || parts[0] == "" {
+		return "", "", errors.Errorf("wrong repo path %q", p)
+	}
+
+	return repoPath, parts[0], nil
+}
+
+// isRepoWriteRequest reports if the request is a git push
+func isRepoWriteRequest(r *http.Request, p string) bool {
+	return strings.HasSuffix(p, "/git-receive-pack") || r.URL.Query().Get("service") == "git-receive-pack"
+}
+
+// authorize checks that the request can access the repository. Users can
+// read and write only the repositories in their namespace (admins can access
+// all of them). Repositories can also be read using a repo token provided as
+// basic auth password.
+func (h *ReposHandler) authorize(r *http.Request, p string) (int, error) {
+	ctx := r.Context()
+
+	repoPath, namespace, err := parseRepoPath(p)
+	if err != nil {
+		return http.StatusBadRequest, errors.WithStack(err)
+	}
+
+	if common.IsUserAdmin(ctx) {
+		return 0, nil
+	}
+
+	userID := common.CurrentUserID(ctx)
+	if userID != "" {
+		if userID == namespace {
+			return 0, nil
+		}
+		return http.StatusForbidden, errors.Errorf("user %q cannot access repo %q", userID, repoPath)
+	}
+
+	if _, password, ok := r.BasicAuth(); ok && !isRepoWriteRequest(r, p) {
+		tokenRepoPath, err := scommon.ParseRepoJWTToken(h.sd, password)
+		if err != nil {
+			return http.StatusUnauthorized, errors.Wrapf(err, "invalid repo token")
+		}
+		if tokenRepoPath != repoPath {
+			return http.StatusForbidden, errors.Errorf("repo token cannot access repo %q", repoPath)
+		}
+		return 0, nil
+	}
+
+	return http.StatusUnauthorized, errors.Errorf("unauthenticated access to repo %q", repoPath)
 }
 
 func (h *ReposHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -39,6 +115,16 @@ func (h *ReposHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	path := vars["rest"]
 
+	if status, err := h.authorize(r, path); err != nil {
+		h.log.Info().Err(err).Msgf("repo access denied")
+		if status == http.StatusUnauthorized {
+			// ask git clients to provide credentials
+			w.Header().Set("WWW-Authenticate", `Basic realm="agola"`)
+		}
+		http.Error(w, "", status)
+		return
+	}
+
 	u, err := url.Parse(h.gitServerURL)
 	if err != nil {
 		h.log.Err(err).Send()
@@ -58,14 +144,18 @@ func (h *ReposHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// copy request headers
+	// copy request headers, the user credentials aren't forwarded to the
+	// gitserver
 	for k, vv := range r.Header {
+		if k == "Authorization" {
+			continue
+		}
 		for _, v := range vv {
 			req.Header.Add(k, v)
 		}
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := h.client.Do(req)
 	if err != nil {
 		h.log.Err(err).Send()
 		util.HTTPError(w, err)
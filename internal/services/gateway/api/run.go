@@ -26,6 +26,7 @@ import (
 	"agola.io/agola/internal/services/gateway/action"
 	"agola.io/agola/internal/util"
 	gwapitypes "agola.io/agola/services/gateway/api/types"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
 	rstypes "agola.io/agola/services/runservice/types"
 
 	"github.com/gorilla/mux"
@@ -37,6 +38,9 @@ func createRunResponse(r *rstypes.Run, rc *rstypes.RunConfig) *gwapitypes.RunRes
 		Number:      r.Counter,
 		Name:        r.Name,
 		Annotations: r.Annotations,
+		Meta:        r.Meta,
+		Metrics:     r.Metrics,
+		Labels:      r.Labels,
 		Phase:       r.Phase,
 		Result:      r.Result,
 		Stopping:    r.Stop,
@@ -67,6 +71,8 @@ func createRunResponseTask(r *rstypes.Run, rt *rstypes.RunTask, rct *rstypes.Run
 		Name:   rct.Name,
 		Status: rt.Status,
 
+		AllowFailure: rct.IgnoreFailure,
+
 		StartTime: rt.StartTime,
 		EndTime:   rt.EndTime,
 
@@ -74,18 +80,49 @@ func createRunResponseTask(r *rstypes.Run, rt *rstypes.RunTask, rct *rstypes.Run
 		Approved:            rt.Approved,
 		ApprovalAnnotations: rt.Annotations,
 
+		FailError: rt.FailError,
+		Attempt:   rt.Attempt,
+
 		Level:   rct.Level,
 		Depends: rct.Depends,
+		Labels:  rt.Labels,
 	}
 
 	return t
 }
 
+// createRunTaskApprovalsResponse returns the task approvals saved in the task
+// approvals annotation
+func createRunTaskApprovalsResponse(rt *rstypes.RunTask) []*gwapitypes.RunTaskApprovalResponse {
+	res := []*gwapitypes.RunTaskApprovalResponse{}
+
+	approvalsAnnotation, ok := rt.Annotations[common.ApprovalsAnnotation]
+	if !ok {
+		return res
+	}
+	var approvals []*common.RunTaskApproval
+	// the annotation is written by the gateway, ignore a wrong value
+	if err := json.Unmarshal([]byte(approvalsAnnotation), &approvals); err != nil {
+		return res
+	}
+	for _, a := range approvals {
+		res = append(res, &gwapitypes.RunTaskApprovalResponse{
+			UserID:   a.UserID,
+			UserName: a.UserName,
+			Comment:  a.Comment,
+			Time:     a.Time,
+		})
+	}
+
+	return res
+}
+
 func createRunTaskResponse(rt *rstypes.RunTask, rct *rstypes.RunConfigTask) *gwapitypes.RunTaskResponse {
 	t := &gwapitypes.RunTaskResponse{
 		ID:     rt.ID,
 		Name:   rct.Name,
 		Status: rt.Status,
+		Labels: rt.Labels,
 
 		WaitingApproval:     rt.WaitingApproval,
 		Approved:            rt.Approved,
@@ -93,10 +130,20 @@ func createRunTaskResponse(rt *rstypes.RunTask, rct *rstypes.RunConfigTask) *gwa
 
 		Steps: make([]*gwapitypes.RunTaskResponseStep, len(rt.Steps)),
 
+		FailError: rt.FailError,
+		Attempt:   rt.Attempt,
+
 		StartTime: rt.StartTime,
 		EndTime:   rt.EndTime,
 	}
 
+	if rct.NeedsApproval {
+		t.Approvals = createRunTaskApprovalsResponse(rt)
+		t.RequiredApprovals = rct.RequiredApprovals()
+		t.ApprovalUsers = rct.ApprovalUsers
+		t.ApprovalOrgRoles = rct.ApprovalOrgRoles
+	}
+
 	t.SetupStep = &gwapitypes.RunTaskResponseSetupStep{
 		Name:      "Task setup",
 		Phase:     rt.SetupStep.Phase,
@@ -104,6 +151,17 @@ func createRunTaskResponse(rt *rstypes.RunTask, rct *rstypes.RunConfigTask) *gwa
 		EndTime:   rt.SetupStep.EndTime,
 	}
 
+	if rt.ResourceUsage != nil {
+		t.ResourceUsage = &gwapitypes.RunTaskResponseResourceUsage{
+			CPUTime:     rt.ResourceUsage.CPUTime,
+			MaxCPU:      rt.ResourceUsage.MaxCPU,
+			Memory:      rt.ResourceUsage.Memory,
+			MaxMemory:   rt.ResourceUsage.MaxMemory,
+			MemoryLimit: rt.ResourceUsage.MemoryLimit,
+			Disk:        rt.ResourceUsage.Disk,
+		}
+	}
+
 	for i := 0; i < len(t.Steps); i++ {
 		s := &gwapitypes.RunTaskResponseStep{
 			Phase:     rt.Steps[i].Phase,
@@ -116,6 +174,12 @@ func createRunTaskResponse(rt *rstypes.RunTask, rct *rstypes.RunConfigTask) *gwa
 		if rts.LogPhase == rstypes.RunTaskFetchPhaseFinished {
 			s.LogArchived = true
 		}
+		if rts.Transfer != nil {
+			s.Transfer = &gwapitypes.RunTaskResponseStepTransfer{
+				Bytes:    rts.Transfer.Bytes,
+				Duration: rts.Transfer.Duration,
+			}
+		}
 
 		switch rcts := rcts.(type) {
 		case *rstypes.RunStep:
@@ -142,6 +206,15 @@ func createRunTaskResponse(rt *rstypes.RunTask, rct *rstypes.RunConfigTask) *gwa
 		case *rstypes.RestoreCacheStep:
 			s.Type = "restore_cache"
 			s.Name = "restore cache"
+		case *rstypes.SaveArtifactsStep:
+			s.Type = "save_artifacts"
+			s.Name = "save artifacts"
+		case *rstypes.RestoreArtifactsStep:
+			s.Type = "restore_artifacts"
+			s.Name = "restore artifacts"
+		case *rstypes.TestReportStep:
+			s.Type = "test_report"
+			s.Name = "test report"
 		}
 
 		t.Steps[i] = s
@@ -274,6 +347,9 @@ func createRunsResponse(r *rstypes.Run) *gwapitypes.RunsResponse {
 		Number:      r.Counter,
 		Name:        r.Name,
 		Annotations: r.Annotations,
+		Meta:        r.Meta,
+		Metrics:     r.Metrics,
+		Labels:      r.Labels,
 		Phase:       r.Phase,
 		Result:      r.Result,
 
@@ -318,6 +394,7 @@ func (h *RunsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	subGroup := q.Get("subgroup")
 	phaseFilter := q["phase"]
 	resultFilter := q["result"]
+	labelFilter := q["label"]
 
 	limitS := q.Get("limit")
 	limit := DefaultRunsLimit
@@ -359,6 +436,7 @@ func (h *RunsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		SubGroup:        subGroup,
 		PhaseFilter:     phaseFilter,
 		ResultFilter:    resultFilter,
+		LabelFilter:     labelFilter,
 		StartRunCounter: startRunNumber,
 		Limit:           limit,
 		Asc:             asc,
@@ -430,6 +508,7 @@ func (h *RunActionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		RunNumber:  runNumber,
 		ActionType: action.RunActionType(req.ActionType),
 		FromStart:  req.FromStart,
+		ConfigRef:  req.ConfigRef,
 	}
 
 	runResp, err := h.ah.RunAction(ctx, areq)
@@ -497,6 +576,7 @@ func (h *RunTaskActionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		RunNumber:  runNumber,
 		TaskID:     taskID,
 		ActionType: action.RunTaskActionType(req.ActionType),
+		Comment:    req.Comment,
 	}
 
 	err = h.ah.RunTaskAction(ctx, areq)
@@ -506,68 +586,127 @@ func (h *RunTaskActionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 	}
 }
 
-type LogsHandler struct {
-	log       zerolog.Logger
-	ah        *action.ActionHandler
-	groupType common.GroupType
+// logsParams are the common params of the logs requests
+type logsParams struct {
+	ref       string
+	runNumber uint64
+	taskID    string
+	setup     bool
+	step      int
 }
 
-func NewLogsHandler(log zerolog.Logger, ah *action.ActionHandler, groupType common.GroupType) *LogsHandler {
-	return &LogsHandler{log: log, ah: ah, groupType: groupType}
-}
-
-func (h *LogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	vars := mux.Vars(r)
-
-	q := r.URL.Query()
+func parseLogsParams(vars map[string]string, q url.Values, groupType common.GroupType) (*logsParams, error) {
+	p := &logsParams{}
 
-	var err error
-	var ref string
-	switch h.groupType {
+	switch groupType {
 	case common.GroupTypeProject:
-		ref, err = url.PathUnescape(vars["projectref"])
+		ref, err := url.PathUnescape(vars["projectref"])
 		if err != nil {
-			util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("projectref is empty")))
-			return
+			return nil, errors.Errorf("projectref is empty")
 		}
+		p.ref = ref
 	case common.GroupTypeUser:
-		ref = vars["userref"]
+		p.ref = vars["userref"]
 	}
 
 	runNumberStr := vars["runnumber"]
-
-	var runNumber uint64
 	if runNumberStr != "" {
-		var err error
-		runNumber, err = strconv.ParseUint(runNumberStr, 10, 64)
+		runNumber, err := strconv.ParseUint(runNumberStr, 10, 64)
 		if err != nil {
-			util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "cannot parse run number")))
-			return
+			return nil, errors.Wrapf(err, "cannot parse run number")
 		}
+		p.runNumber = runNumber
 	}
 
-	taskID := vars["taskid"]
+	p.taskID = vars["taskid"]
 
 	_, setup := q["setup"]
 	stepStr := q.Get("step")
 	if !setup && stepStr == "" {
-		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("no setup or step number provided")))
-		return
+		return nil, errors.Errorf("no setup or step number provided")
 	}
 	if setup && stepStr != "" {
-		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("both setup and step number provided")))
-		return
+		return nil, errors.Errorf("both setup and step number provided")
 	}
+	p.setup = setup
 
-	var step int
 	if stepStr != "" {
-		var err error
-		step, err = strconv.Atoi(stepStr)
+		step, err := strconv.Atoi(stepStr)
 		if err != nil {
-			util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "cannot parse step number")))
-			return
+			return nil, errors.Wrapf(err, "cannot parse step number")
 		}
+		p.step = step
+	}
+
+	return p, nil
+}
+
+// parseLogsRange parses the logs range query params. It returns nil if no
+// range is requested.
+func parseLogsRange(q url.Values) (*rsapitypes.LogsRange, error) {
+	unitStr := q.Get("unit")
+	offsetStr := q.Get("offset")
+	limitStr := q.Get("limit")
+	if unitStr == "" && offsetStr == "" && limitStr == "" {
+		return nil, nil
+	}
+
+	logsRange := &rsapitypes.LogsRange{Unit: rsapitypes.LogsRangeUnitLine}
+	if unitStr != "" {
+		switch gwapitypes.LogsRangeUnit(unitStr) {
+		case gwapitypes.LogsRangeUnitLine:
+			logsRange.Unit = rsapitypes.LogsRangeUnitLine
+		case gwapitypes.LogsRangeUnitByte:
+			logsRange.Unit = rsapitypes.LogsRangeUnitByte
+		default:
+			return nil, errors.Errorf("wrong logs range unit %q", unitStr)
+		}
+	}
+
+	if offsetStr != "" {
+		offset, err := strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil || offset < 0 {
+			return nil, errors.Errorf("wrong logs range offset %q", offsetStr)
+		}
+		logsRange.Offset = offset
+	}
+	if limitStr != "" {
+		limit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || limit < 0 {
+			return nil, errors.Errorf("wrong logs range limit %q", limitStr)
+		}
+		logsRange.Limit = limit
+	}
+
+	return logsRange, nil
+}
+
+type LogsHandler struct {
+	log       zerolog.Logger
+	ah        *action.ActionHandler
+	groupType common.GroupType
+}
+
+func NewLogsHandler(log zerolog.Logger, ah *action.ActionHandler, groupType common.GroupType) *LogsHandler {
+	return &LogsHandler{log: log, ah: ah, groupType: groupType}
+}
+
+func (h *LogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	q := r.URL.Query()
+
+	p, err := parseLogsParams(vars, q, h.groupType)
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	logsRange, err := parseLogsRange(q)
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
 	}
 
 	follow := false
@@ -577,12 +716,13 @@ func (h *LogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	areq := &action.GetLogsRequest{
 		GroupType: h.groupType,
-		Ref:       ref,
-		RunNumber: runNumber,
-		TaskID:    taskID,
-		Setup:     setup,
-		Step:      step,
+		Ref:       p.ref,
+		RunNumber: p.runNumber,
+		TaskID:    p.taskID,
+		Setup:     p.setup,
+		Step:      p.step,
 		Follow:    follow,
+		LogsRange: logsRange,
 	}
 
 	resp, err := h.ah.GetLogs(ctx, areq)
@@ -659,66 +799,267 @@ func (h *LogsDeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	q := r.URL.Query()
 
-	var err error
+	p, err := parseLogsParams(vars, q, h.groupType)
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	areq := &action.DeleteLogsRequest{
+		GroupType: h.groupType,
+		Ref:       p.ref,
+		RunNumber: p.runNumber,
+		TaskID:    p.taskID,
+		Setup:     p.setup,
+		Step:      p.step,
+	}
+
+	err = h.ah.DeleteLogs(ctx, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+}
+
+type LogsInfoHandler struct {
+	log       zerolog.Logger
+	ah        *action.ActionHandler
+	groupType common.GroupType
+}
+
+func NewLogsInfoHandler(log zerolog.Logger, ah *action.ActionHandler, groupType common.GroupType) *LogsInfoHandler {
+	return &LogsInfoHandler{log: log, ah: ah, groupType: groupType}
+}
+
+func (h *LogsInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	q := r.URL.Query()
+
+	p, err := parseLogsParams(vars, q, h.groupType)
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	areq := &action.GetLogsInfoRequest{
+		GroupType: h.groupType,
+		Ref:       p.ref,
+		RunNumber: p.runNumber,
+		TaskID:    p.taskID,
+		Setup:     p.setup,
+		Step:      p.step,
+	}
+
+	logsInfo, err := h.ah.GetLogsInfo(ctx, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := &gwapitypes.LogsInfoResponse{
+		Size:     logsInfo.Size,
+		Lines:    logsInfo.Lines,
+		Complete: logsInfo.Complete,
+	}
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+func parseRunParams(vars map[string]string, groupType common.GroupType) (string, uint64, error) {
 	var ref string
-	switch h.groupType {
+	switch groupType {
 	case common.GroupTypeProject:
+		var err error
 		ref, err = url.PathUnescape(vars["projectref"])
 		if err != nil {
-			util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("projectref is empty")))
-			return
+			return "", 0, errors.Errorf("projectref is empty")
 		}
 	case common.GroupTypeUser:
 		ref = vars["userref"]
 	}
 
-	runNumberStr := vars["runnumber"]
+	runNumber, err := strconv.ParseUint(vars["runnumber"], 10, 64)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "cannot parse run number")
+	}
 
-	var runNumber uint64
-	if runNumberStr != "" {
-		var err error
-		runNumber, err = strconv.ParseUint(runNumberStr, 10, 64)
-		if err != nil {
-			util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "cannot parse run number")))
-			return
+	return ref, runNumber, nil
+}
+
+type RunArtifactsHandler struct {
+	log       zerolog.Logger
+	ah        *action.ActionHandler
+	groupType common.GroupType
+}
+
+func NewRunArtifactsHandler(log zerolog.Logger, ah *action.ActionHandler, groupType common.GroupType) *RunArtifactsHandler {
+	return &RunArtifactsHandler{log: log, ah: ah, groupType: groupType}
+}
+
+func (h *RunArtifactsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	ref, runNumber, err := parseRunParams(vars, h.groupType)
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	areq := &action.GetRunArtifactsRequest{
+		GroupType: h.groupType,
+		Ref:       ref,
+		RunNumber: runNumber,
+	}
+
+	artifacts, err := h.ah.GetRunArtifacts(ctx, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := &gwapitypes.RunArtifactsResponse{
+		Artifacts: make([]*gwapitypes.RunArtifactResponse, len(artifacts)),
+	}
+	for i, a := range artifacts {
+		res.Artifacts[i] = &gwapitypes.RunArtifactResponse{
+			TaskID:   a.TaskID,
+			TaskName: a.TaskName,
+			Path:     a.Path,
+			Size:     a.Size,
 		}
 	}
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
 
-	taskID := vars["taskid"]
+type RunTestReportsHandler struct {
+	log       zerolog.Logger
+	ah        *action.ActionHandler
+	groupType common.GroupType
+}
 
-	_, setup := q["setup"]
-	stepStr := q.Get("step")
-	if !setup && stepStr == "" {
-		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("no setup or step number provided")))
+func NewRunTestReportsHandler(log zerolog.Logger, ah *action.ActionHandler, groupType common.GroupType) *RunTestReportsHandler {
+	return &RunTestReportsHandler{log: log, ah: ah, groupType: groupType}
+}
+
+func (h *RunTestReportsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	ref, runNumber, err := parseRunParams(vars, h.groupType)
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
 		return
 	}
-	if setup && stepStr != "" {
-		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("both setup and step number provided")))
+
+	areq := &action.GetRunTestReportsRequest{
+		GroupType: h.groupType,
+		Ref:       ref,
+		RunNumber: runNumber,
+	}
+
+	testReports, err := h.ah.GetRunTestReports(ctx, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
 		return
 	}
 
-	var step int
-	if stepStr != "" {
-		var err error
-		step, err = strconv.Atoi(stepStr)
-		if err != nil {
-			util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "cannot parse step number")))
-			return
+	res := &gwapitypes.RunTestReportsResponse{
+		TestReports: make([]*gwapitypes.RunTestReportResponse, len(testReports)),
+	}
+	for i, tr := range testReports {
+		res.TestReports[i] = createRunTestReportResponse(tr)
+	}
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+func createRunTestReportResponse(tr *action.RunTestReport) *gwapitypes.RunTestReportResponse {
+	res := &gwapitypes.RunTestReportResponse{
+		TaskID:      tr.TaskID,
+		TaskName:    tr.TaskName,
+		Tests:       tr.Report.Tests,
+		Passed:      tr.Report.Passed(),
+		Failures:    tr.Report.Failures,
+		Errors:      tr.Report.Errors,
+		Skipped:     tr.Report.Skipped,
+		Duration:    tr.Report.Duration,
+		FailedTests: make([]*gwapitypes.TestCaseFailureResponse, len(tr.Report.FailedTests)),
+	}
+	for i, f := range tr.Report.FailedTests {
+		res.FailedTests[i] = &gwapitypes.TestCaseFailureResponse{
+			Suite:     f.Suite,
+			ClassName: f.ClassName,
+			Name:      f.Name,
+			Error:     f.Error,
+			Message:   f.Message,
 		}
 	}
 
-	areq := &action.DeleteLogsRequest{
+	return res
+}
+
+type RunTaskArtifactHandler struct {
+	log       zerolog.Logger
+	ah        *action.ActionHandler
+	groupType common.GroupType
+}
+
+func NewRunTaskArtifactHandler(log zerolog.Logger, ah *action.ActionHandler, groupType common.GroupType) *RunTaskArtifactHandler {
+	return &RunTaskArtifactHandler{log: log, ah: ah, groupType: groupType}
+}
+
+func (h *RunTaskArtifactHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	ref, runNumber, err := parseRunParams(vars, h.groupType)
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	taskID := vars["taskid"]
+	if taskID == "" {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("taskid is empty")))
+		return
+	}
+
+	artifactPath := r.URL.Query().Get("path")
+	if artifactPath == "" {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("path is empty")))
+		return
+	}
+
+	areq := &action.GetRunTaskArtifactRequest{
 		GroupType: h.groupType,
 		Ref:       ref,
 		RunNumber: runNumber,
 		TaskID:    taskID,
-		Setup:     setup,
-		Step:      step,
+		Path:      artifactPath,
 	}
 
-	err = h.ah.DeleteLogs(ctx, areq)
+	resp, err := h.ah.GetRunTaskArtifact(ctx, areq)
 	if util.HTTPError(w, err) {
 		h.log.Err(err).Send()
 		return
 	}
+	defer resp.Body.Close()
+
+	for _, k := range []string{"Content-Type", "Content-Length"} {
+		if v := resp.Header.Get(k); v != "" {
+			w.Header().Set(k, v)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		h.log.Err(err).Send()
+	}
 }
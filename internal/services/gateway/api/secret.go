@@ -33,6 +33,7 @@ func createSecretResponse(s *csapitypes.Secret) *gwapitypes.SecretResponse {
 		ID:         s.ID,
 		Name:       s.Name,
 		ParentPath: s.ParentPath,
+		Sealed:     s.Sealed,
 	}
 }
 
@@ -108,6 +109,7 @@ func (h *CreateSecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		ParentRef:        parentRef,
 		Type:             cstypes.SecretType(req.Type),
 		Data:             req.Data,
+		Sealed:           req.Sealed,
 		SecretProviderID: req.SecretProviderID,
 		Path:             req.Path,
 	}
@@ -157,6 +159,7 @@ func (h *UpdateSecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		ParentRef:        parentRef,
 		Type:             cstypes.SecretType(req.Type),
 		Data:             req.Data,
+		Sealed:           req.Sealed,
 		SecretProviderID: req.SecretProviderID,
 		Path:             req.Path,
 	}
@@ -172,6 +175,30 @@ func (h *UpdateSecretHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+type SealedSecretsPublicKeyHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewSealedSecretsPublicKeyHandler(log zerolog.Logger, ah *action.ActionHandler) *SealedSecretsPublicKeyHandler {
+	return &SealedSecretsPublicKeyHandler{log: log, ah: ah}
+}
+
+func (h *SealedSecretsPublicKeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	publicKey, err := h.ah.GetSealedSecretsPublicKey(ctx)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := &gwapitypes.SealedSecretsPublicKeyResponse{PublicKey: publicKey}
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
 type DeleteSecretHandler struct {
 	log zerolog.Logger
 	ah  *action.ActionHandler
@@ -0,0 +1,116 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/services/gateway/action"
+	"agola.io/agola/internal/util"
+	gwapitypes "agola.io/agola/services/gateway/api/types"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+)
+
+const (
+	DefaultMetricValuesLimit = 100
+	MaxMetricValuesLimit     = 1000
+)
+
+type ProjectMetricHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewProjectMetricHandler(log zerolog.Logger, ah *action.ActionHandler) *ProjectMetricHandler {
+	return &ProjectMetricHandler{log: log, ah: ah}
+}
+
+func (h *ProjectMetricHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	q := r.URL.Query()
+
+	projectRef, err := url.PathUnescape(vars["projectref"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+	metric, err := url.PathUnescape(vars["metric"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	limitS := q.Get("limit")
+	limit := DefaultMetricValuesLimit
+	if limitS != "" {
+		var err error
+		limit, err = strconv.Atoi(limitS)
+		if err != nil {
+			util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "cannot parse limit")))
+			return
+		}
+	}
+	if limit < 0 {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("limit must be greater or equal than 0")))
+		return
+	}
+	if limit == 0 || limit > MaxMetricValuesLimit {
+		limit = MaxMetricValuesLimit
+	}
+
+	var startRunNumber uint64
+	if startRunNumberStr := q.Get("start"); startRunNumberStr != "" {
+		var err error
+		startRunNumber, err = strconv.ParseUint(startRunNumberStr, 10, 64)
+		if err != nil {
+			util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "cannot parse run number")))
+			return
+		}
+	}
+
+	areq := &action.GetProjectMetricRequest{
+		ProjectRef:     projectRef,
+		Metric:         metric,
+		Branch:         q.Get("branch"),
+		StartRunNumber: startRunNumber,
+		Limit:          limit,
+	}
+	values, err := h.ah.GetProjectMetric(ctx, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := make([]*gwapitypes.MetricValueResponse, len(values))
+	for i, v := range values {
+		res[i] = &gwapitypes.MetricValueResponse{
+			RunNumber: v.RunNumber,
+			Branch:    v.Branch,
+			Result:    v.Result,
+			Time:      v.Time,
+			Value:     v.Value,
+		}
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
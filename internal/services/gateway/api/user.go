@@ -163,10 +163,11 @@ func createPrivateUserResponse(u *cstypes.User, tokens []*cstypes.UserToken, lin
 
 	for _, la := range linkedAccounts {
 		user.LinkedAccounts = append(user.LinkedAccounts, &gwapitypes.LinkedAccountResponse{
-			ID:                  la.ID,
-			RemoteSourceID:      la.RemoteSourceID,
-			RemoteUserName:      la.RemoteUserName,
-			RemoteUserAvatarURL: la.RemoteUserAvatarURL,
+			ID:                    la.ID,
+			RemoteSourceID:        la.RemoteSourceID,
+			RemoteUserName:        la.RemoteUserName,
+			RemoteUserDisplayName: la.RemoteUserDisplayName,
+			RemoteUserAvatarURL:   la.RemoteUserAvatarURL,
 		})
 	}
 
@@ -175,8 +176,10 @@ func createPrivateUserResponse(u *cstypes.User, tokens []*cstypes.UserToken, lin
 
 func createUserResponse(u *cstypes.User) *gwapitypes.UserResponse {
 	user := &gwapitypes.UserResponse{
-		ID:       u.ID,
-		UserName: u.Name,
+		ID:          u.ID,
+		UserName:    u.Name,
+		DisplayName: u.DisplayName,
+		AvatarURL:   u.AvatarURL,
 	}
 
 	return user
@@ -293,11 +296,12 @@ func (h *CreateUserLAHandler) createUserLA(ctx context.Context, userRef string,
 
 	resp := &gwapitypes.CreateUserLAResponse{
 		LinkedAccount: &gwapitypes.LinkedAccount{
-			ID:                  authresp.LinkedAccount.ID,
-			RemoteUserID:        authresp.LinkedAccount.RemoteUserID,
-			RemoteUserName:      authresp.LinkedAccount.RemoteUserName,
-			RemoteUserAvatarURL: authresp.LinkedAccount.RemoteUserAvatarURL,
-			RemoteSourceID:      authresp.LinkedAccount.RemoteUserID,
+			ID:                    authresp.LinkedAccount.ID,
+			RemoteUserID:          authresp.LinkedAccount.RemoteUserID,
+			RemoteUserName:        authresp.LinkedAccount.RemoteUserName,
+			RemoteUserDisplayName: authresp.LinkedAccount.RemoteUserDisplayName,
+			RemoteUserAvatarURL:   authresp.LinkedAccount.RemoteUserAvatarURL,
+			RemoteSourceID:        authresp.LinkedAccount.RemoteUserID,
 		},
 	}
 	h.log.Info().Msgf("linked account %q for user %q created", resp.LinkedAccount.ID, userRef)
@@ -371,6 +375,53 @@ func (h *CreateUserTokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+type UserTokensHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewUserTokensHandler(log zerolog.Logger, ah *action.ActionHandler) *UserTokensHandler {
+	return &UserTokensHandler{log: log, ah: ah}
+}
+
+func (h *UserTokensHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	userRef := vars["userref"]
+
+	tokens, err := h.ah.GetUserTokens(ctx, userRef)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Name < tokens[j].Name })
+
+	res := make([]*gwapitypes.UserTokenResponse, len(tokens))
+	for i, token := range tokens {
+		res[i] = createUserTokenResponse(token)
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+// createUserTokenResponse returns the token metadata. The token value is never
+// returned after its creation.
+func createUserTokenResponse(t *cstypes.UserToken) *gwapitypes.UserTokenResponse {
+	res := &gwapitypes.UserTokenResponse{
+		Name:         t.Name,
+		CreationTime: t.CreationTime,
+	}
+	if !t.LastUsedTime.IsZero() {
+		lastUsedTime := t.LastUsedTime
+		res.LastUsedTime = &lastUsedTime
+	}
+
+	return res
+}
+
 type DeleteUserTokenHandler struct {
 	log zerolog.Logger
 	ah  *action.ActionHandler
@@ -398,6 +449,78 @@ func (h *DeleteUserTokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+type UserPreferencesHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewUserPreferencesHandler(log zerolog.Logger, ah *action.ActionHandler) *UserPreferencesHandler {
+	return &UserPreferencesHandler{log: log, ah: ah}
+}
+
+func (h *UserPreferencesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userPreferences, err := h.ah.GetUserPreferences(ctx)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := createUserPreferencesResponse(userPreferences)
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type UpdateUserPreferencesHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewUpdateUserPreferencesHandler(log zerolog.Logger, ah *action.ActionHandler) *UpdateUserPreferencesHandler {
+	return &UpdateUserPreferencesHandler{log: log, ah: ah}
+}
+
+func (h *UpdateUserPreferencesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req gwapitypes.UpdateUserPreferencesRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	areq := &action.UpdateUserPreferencesRequest{
+		DefaultOrgRef:     req.DefaultOrgRef,
+		DefaultProjectRef: req.DefaultProjectRef,
+		Timezone:          req.Timezone,
+		DateFormat:        req.DateFormat,
+		UISettings:        req.UISettings,
+	}
+	userPreferences, err := h.ah.UpdateUserPreferences(ctx, areq)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := createUserPreferencesResponse(userPreferences)
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+func createUserPreferencesResponse(p *cstypes.UserPreferences) *gwapitypes.UserPreferencesResponse {
+	return &gwapitypes.UserPreferencesResponse{
+		DefaultOrgID:     p.DefaultOrgID,
+		DefaultProjectID: p.DefaultProjectID,
+		Timezone:         p.Timezone,
+		DateFormat:       p.DateFormat,
+		UISettings:       p.UISettings,
+	}
+}
+
 type RegisterUserHandler struct {
 	log zerolog.Logger
 	ah  *action.ActionHandler
@@ -589,6 +712,9 @@ func (h *UserCreateRunHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		Message:               req.Message,
 		PullRequestRefRegexes: req.PullRequestRefRegexes,
 		Variables:             req.Variables,
+		RunNames:              req.RunNames,
+		TaskNames:             req.TaskNames,
+		ChangedFiles:          req.ChangedFiles,
 	}
 	err := h.ah.UserCreateRun(ctx, creq)
 	if util.HTTPError(w, err) {
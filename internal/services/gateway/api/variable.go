@@ -17,6 +17,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 
 	"agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/gateway/action"
@@ -42,6 +43,7 @@ func createVariableResponse(v *csapitypes.Variable, secrets []*csapitypes.Secret
 			SecretName: varvalue.SecretName,
 			SecretVar:  varvalue.SecretVar,
 			When:       varvalue.When,
+			Protected:  varvalue.Protected,
 		}
 		// get matching secret for var value
 		secret := common.GetVarValueMatchingSecret(varvalue, v.ParentPath, secrets)
@@ -96,6 +98,55 @@ func (h *VariableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+type ProjectGroupVariablesReportHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewProjectGroupVariablesReportHandler(log zerolog.Logger, ah *action.ActionHandler) *ProjectGroupVariablesReportHandler {
+	return &ProjectGroupVariablesReportHandler{log: log, ah: ah}
+}
+
+func (h *ProjectGroupVariablesReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	projectGroupRef, err := url.PathUnescape(vars["projectgroupref"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	reports, err := h.ah.GetProjectGroupVariablesReport(ctx, projectGroupRef)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := make([]*gwapitypes.ProjectVariablesReportResponse, len(reports))
+	for i, report := range reports {
+		variables := make([]*gwapitypes.VariableResponse, len(report.Variables))
+		for j, v := range report.Variables {
+			variables[j] = createVariableResponse(v, report.Secrets)
+		}
+		cssecrets := common.FilterOverriddenSecrets(report.Secrets)
+		secrets := make([]*gwapitypes.SecretResponse, len(cssecrets))
+		for j, s := range cssecrets {
+			secrets[j] = createSecretResponse(s)
+		}
+
+		res[i] = &gwapitypes.ProjectVariablesReportResponse{
+			ProjectID:   report.Project.ID,
+			ProjectPath: report.Project.Path,
+			Variables:   variables,
+			Secrets:     secrets,
+		}
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
 type CreateVariableHandler struct {
 	log zerolog.Logger
 	ah  *action.ActionHandler
@@ -222,6 +273,7 @@ func fromApiVariableValues(apivalues []gwapitypes.VariableValueRequest) []cstype
 			SecretName: v.SecretName,
 			SecretVar:  v.SecretVar,
 			When:       v.When,
+			Protected:  v.Protected,
 		}
 	}
 	return values
@@ -15,10 +15,15 @@
 package api
 
 import (
+	"context"
 	"net/http"
 
 	"agola.io/agola/internal/errors"
+	gitsource "agola.io/agola/internal/gitsources"
+	"agola.io/agola/internal/lock"
+	"agola.io/agola/internal/objectstorage"
 	"agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/services/config"
 	"agola.io/agola/internal/services/gateway/action"
 	"agola.io/agola/internal/services/types"
 	"agola.io/agola/internal/util"
@@ -32,23 +37,34 @@ import (
 type webhooksHandler struct {
 	log               zerolog.Logger
 	ah                *action.ActionHandler
+	ost               *objectstorage.ObjStorage
+	lf                lock.LockFactory
 	configstoreClient *csclient.Client
 	runserviceClient  *rsclient.Client
 	apiExposedURL     string
+	queueConfig       *config.WebhookQueue
+
+	// queueNotifyCh wakes up the queue processing loop when a new webhook
+	// is queued
+	queueNotifyCh chan struct{}
 }
 
-func NewWebhooksHandler(log zerolog.Logger, ah *action.ActionHandler, configstoreClient *csclient.Client, runserviceClient *rsclient.Client, apiExposedURL string) *webhooksHandler {
+func NewWebhooksHandler(log zerolog.Logger, ah *action.ActionHandler, ost *objectstorage.ObjStorage, lf lock.LockFactory, configstoreClient *csclient.Client, runserviceClient *rsclient.Client, apiExposedURL string, queueConfig *config.WebhookQueue) *webhooksHandler {
 	return &webhooksHandler{
 		log:               log,
 		ah:                ah,
+		ost:               ost,
+		lf:                lf,
 		configstoreClient: configstoreClient,
 		runserviceClient:  runserviceClient,
 		apiExposedURL:     apiExposedURL,
+		queueConfig:       queueConfig,
+		queueNotifyCh:     make(chan struct{}, 1),
 	}
 }
 
 func (h *webhooksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	err := h.handleWebhook(r)
+	err := h.queueWebhook(w, r)
 	if util.HTTPError(w, err) {
 		h.log.Err(err).Send()
 		return
@@ -59,29 +75,125 @@ func (h *webhooksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *webhooksHandler) handleWebhook(r *http.Request) error {
+// handleWebhook parses the webhook and creates its runs. The webhook signature
+// is verified unless it has been already verified
+func (h *webhooksHandler) handleWebhook(r *http.Request, verified bool) error {
 	ctx := r.Context()
 
+	defer r.Body.Close()
+
+	// organization webhooks are registered by remote source
+	if remoteSourceID := r.URL.Query().Get("remotesourceid"); remoteSourceID != "" {
+		return h.handleOrgWebhook(r, remoteSourceID, verified)
+	}
+
 	projectID := r.URL.Query().Get("projectid")
 	if projectID == "" {
 		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("bad webhook url %q. Missing projectid", r.URL))
 	}
 
-	defer r.Body.Close()
-
 	csProject, _, err := h.configstoreClient.GetProject(ctx, projectID)
 	if err != nil {
-		return util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "failed to get project %s", projectID))
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %s", projectID))
 	}
 	project := csProject.Project
 
+	// reject webhooks for projects without a webhook secret since we cannot
+	// verify them
+	if project.WebhookSecret == "" {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("project %s doesn't have a webhook secret, the project must be reconfigured", projectID))
+	}
+
+	rs, gitSource, err := h.getProjectGitSource(ctx, project)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	secret := project.WebhookSecret
+	if verified {
+		secret = ""
+	}
+	webhookData, err := gitSource.ParseWebhook(r, secret)
+	if err != nil {
+		return util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "failed to parse webhook"))
+	}
+	// skip nil webhook data
+	// TODO(sgotti) report the reason of the skip
+	if webhookData == nil {
+		h.log.Info().Msgf("skipping webhook")
+		return nil
+	}
+
+	return h.createProjectRuns(ctx, project, rs, gitSource, webhookData)
+}
+
+// handleOrgWebhook handles the webhooks received from an organization webhook
+// creating the runs for all the remote source projects referencing the
+// webhook repository
+func (h *webhooksHandler) handleOrgWebhook(r *http.Request, remoteSourceID string, verified bool) error {
+	ctx := r.Context()
+
+	rs, err := h.ah.GetRemoteSource(ctx, remoteSourceID)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %s", remoteSourceID))
+	}
+	// reject webhooks from organization webhooks left on the remote after
+	// disabling them
+	if !rs.OrgWebhooks || rs.WebhookSecret == "" {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("remote source %s doesn't have organization webhooks enabled", rs.Name))
+	}
+
+	// the webhook is signed with the remote source secret so no user
+	// credentials are needed to parse it
+	gitSource, err := common.GetGitSource(rs, nil)
+	if err != nil {
+		return util.NewAPIError(util.ErrInternal, errors.Wrapf(err, "failed to create gitsource client"))
+	}
+	secret := rs.WebhookSecret
+	if verified {
+		secret = ""
+	}
+	webhookData, err := gitSource.ParseWebhook(r, secret)
+	if err != nil {
+		return util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "failed to parse webhook"))
+	}
+	if webhookData == nil {
+		h.log.Info().Msgf("skipping webhook")
+		return nil
+	}
+
+	projects, _, err := h.configstoreClient.GetRemoteSourceProjects(ctx, rs.ID, webhookData.Repo.Path)
+	if err != nil {
+		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %q projects", rs.Name))
+	}
+
+	for _, p := range projects {
+		project := p.Project
+
+		// errors are only logged to not skip the runs of the other projects
+		_, projectGitSource, err := h.getProjectGitSource(ctx, project)
+		if err != nil {
+			h.log.Err(err).Msgf("failed to get project %q git source", project.ID)
+			continue
+		}
+		if err := h.createProjectRuns(ctx, project, rs, projectGitSource, webhookData); err != nil {
+			h.log.Err(err).Msgf("failed to create runs for project %q", project.ID)
+		}
+	}
+
+	return nil
+}
+
+// getProjectGitSource returns the project remote source and a git source
+// client using the project linked account
+func (h *webhooksHandler) getProjectGitSource(ctx context.Context, project *cstypes.Project) (*cstypes.RemoteSource, gitsource.GitSource, error) {
 	user, _, err := h.configstoreClient.GetUserByLinkedAccount(ctx, project.LinkedAccountID)
 	if err != nil {
-		return util.NewAPIError(util.ErrInternal, errors.Wrapf(err, "failed to get user by linked account %q", project.LinkedAccountID))
+		return nil, nil, util.NewAPIError(util.ErrInternal, errors.Wrapf(err, "failed to get user by linked account %q", project.LinkedAccountID))
 	}
 	linkedAccounts, _, err := h.configstoreClient.GetUserLinkedAccounts(ctx, user.ID)
 	if err != nil {
-		return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q linked accounts", user.ID))
+		return nil, nil, util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get user %q linked accounts", user.ID))
 	}
 
 	var la *cstypes.LinkedAccount
@@ -93,19 +205,23 @@ func (h *webhooksHandler) handleWebhook(r *http.Request) error {
 	}
 
 	if la == nil {
-		return util.NewAPIError(util.ErrInternal, errors.Errorf("linked account %q for user %q doesn't exist", project.LinkedAccountID, user.Name))
+		return nil, nil, util.NewAPIError(util.ErrInternal, errors.Errorf("linked account %q for user %q doesn't exist", project.LinkedAccountID, user.Name))
 	}
 
-	rs, _, err := h.configstoreClient.GetRemoteSource(ctx, la.RemoteSourceID)
+	rs, err := h.ah.GetRemoteSource(ctx, la.RemoteSourceID)
 	if err != nil {
-		return util.NewAPIError(util.ErrInternal, errors.Wrapf(err, "failed to get remote source %q", la.RemoteSourceID))
+		return nil, nil, util.NewAPIError(util.ErrInternal, errors.Wrapf(err, "failed to get remote source %q", la.RemoteSourceID))
 	}
 
 	gitSource, err := h.ah.GetGitSource(ctx, rs, user.Name, la)
 	if err != nil {
-		return util.NewAPIError(util.ErrInternal, errors.Wrapf(err, "failed to create gitea client"))
+		return nil, nil, util.NewAPIError(util.ErrInternal, errors.Wrapf(err, "failed to create gitea client"))
 	}
 
+	return rs, gitSource, nil
+}
+
+func (h *webhooksHandler) createProjectRuns(ctx context.Context, project *cstypes.Project, rs *cstypes.RemoteSource, gitSource gitsource.GitSource, webhookData *types.WebhookData) error {
 	sshPrivKey := project.SSHPrivateKey
 	sshHostKey := rs.SSHHostKey
 	// use remotesource skipSSHHostKeyCheck config and override with project config if set to true there
@@ -114,19 +230,11 @@ func (h *webhooksHandler) handleWebhook(r *http.Request) error {
 		skipSSHHostKeyCheck = project.SkipSSHHostKeyCheck
 	}
 
-	webhookData, err := gitSource.ParseWebhook(r, project.WebhookSecret)
+	cloneURL, err := common.GetSSHCloneURL(rs, webhookData.SSHURL)
 	if err != nil {
-		return util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "failed to parse webhook"))
-	}
-	// skip nil webhook data
-	// TODO(sgotti) report the reason of the skip
-	if webhookData == nil {
-		h.log.Info().Msgf("skipping webhook")
-		return nil
+		return errors.WithStack(err)
 	}
 
-	cloneURL := webhookData.SSHURL
-
 	req := &action.CreateRunRequest{
 		RunType:            types.RunTypeProject,
 		RefType:            common.WebHookEventToRunRefType(webhookData.Event),
@@ -153,6 +261,9 @@ func (h *webhooksHandler) handleWebhook(r *http.Request) error {
 		TagLink:         webhookData.TagLink,
 		PullRequestLink: webhookData.PullRequestLink,
 		CompareLink:     webhookData.CompareLink,
+
+		ChangedFiles: webhookData.ChangedFiles,
+		CompareBase:  webhookData.CompareBase,
 	}
 	if err := h.ah.CreateRuns(ctx, req); err != nil {
 		return util.NewAPIError(util.ErrInternal, errors.Wrapf(err, "failed to create run"))
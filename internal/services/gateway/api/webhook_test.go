@@ -0,0 +1,179 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agola.io/agola/internal/services/gateway/action"
+	"agola.io/agola/internal/testutil"
+	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	csclient "agola.io/agola/services/configstore/client"
+	cstypes "agola.io/agola/services/configstore/types"
+	stypes "agola.io/agola/services/types"
+)
+
+// fakeWebhookConfigstore serves the configstore api calls needed to handle
+// the project and organization webhooks
+func fakeWebhookConfigstore(t *testing.T) *httptest.Server {
+	remoteSources := map[string]*cstypes.RemoteSource{
+		"rs01": {
+			ObjectMeta:    stypes.ObjectMeta{ID: "rs01"},
+			Name:          "rs01",
+			APIURL:        "http://127.0.0.1:1",
+			Type:          cstypes.RemoteSourceTypeGitea,
+			AuthType:      cstypes.RemoteSourceAuthTypePassword,
+			OrgWebhooks:   true,
+			WebhookSecret: "rssecret",
+		},
+		"rs02": {
+			ObjectMeta: stypes.ObjectMeta{ID: "rs02"},
+			Name:       "rs02",
+			APIURL:     "http://127.0.0.1:1",
+			Type:       cstypes.RemoteSourceTypeGitea,
+			AuthType:   cstypes.RemoteSourceAuthTypePassword,
+		},
+	}
+	projects := map[string]*csapitypes.Project{
+		"project01": {Project: &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project01"}, RemoteSourceID: "rs01", LinkedAccountID: "la01", WebhookSecret: "secret"}},
+		"project02": {Project: &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project02"}, RemoteSourceID: "rs01", LinkedAccountID: "la01"}},
+	}
+	user := &cstypes.User{ObjectMeta: stypes.ObjectMeta{ID: "user01"}, Name: "user01"}
+	la := &cstypes.LinkedAccount{ObjectMeta: stypes.ObjectMeta{ID: "la01"}, UserID: "user01", RemoteSourceID: "rs01", UserAccessToken: "token01"}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/api/v1alpha/remotesources/"):
+			rs, ok := remoteSources[strings.TrimPrefix(r.URL.Path, "/api/v1alpha/remotesources/")]
+			if !ok {
+				writeTestJSON(t, w, http.StatusNotFound, map[string]string{"message": "remote source doesn't exist"})
+				return
+			}
+			writeTestJSON(t, w, http.StatusOK, rs)
+		case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/api/v1alpha/projects/"):
+			p, ok := projects[strings.TrimPrefix(r.URL.Path, "/api/v1alpha/projects/")]
+			if !ok {
+				writeTestJSON(t, w, http.StatusNotFound, map[string]string{"message": "project doesn't exist"})
+				return
+			}
+			writeTestJSON(t, w, http.StatusOK, p)
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/users" && r.URL.Query().Get("linkedaccountid") == la.ID:
+			writeTestJSON(t, w, http.StatusOK, []*cstypes.User{user})
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/users/user01/linkedaccounts":
+			writeTestJSON(t, w, http.StatusOK, []*cstypes.LinkedAccount{la})
+		default:
+			t.Errorf("unexpected configstore request %s %s", r.Method, r.URL.Path)
+			writeTestJSON(t, w, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func writeTestJSON(t *testing.T, w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+}
+
+func TestHandleWebhook(t *testing.T) {
+	log := testutil.NewLogger(t)
+
+	configstoreClient := csclient.NewClient(fakeWebhookConfigstore(t).URL)
+	ah := action.NewActionHandler(log, nil, configstoreClient, nil, nil, "agola", "", "", nil, "")
+	h := NewWebhooksHandler(log, ah, nil, nil, configstoreClient, nil, "", nil)
+
+	// a closed pull request webhook is skipped after being verified so no runs
+	// are created
+	closedPRBody := []byte(`{"action": "closed", "pull_request": {"state": "closed"}}`)
+	closedPRRequest := func(target, secret string) *http.Request {
+		r := signedWebhookRequest(target, secret, closedPRBody)
+		r.Header.Set("X-Gitea-Event", "pull_request")
+		return r
+	}
+
+	tests := []struct {
+		name string
+		r    *http.Request
+		err  bool
+	}{
+		{
+			name: "project webhook",
+			r:    closedPRRequest("/webhooks?projectid=project01", "secret"),
+		},
+		{
+			name: "project webhook without signature",
+			r:    closedPRRequest("/webhooks?projectid=project01", ""),
+			err:  true,
+		},
+		{
+			name: "project webhook with wrong signature",
+			r:    closedPRRequest("/webhooks?projectid=project01", "wrongsecret"),
+			err:  true,
+		},
+		{
+			name: "project webhook signed with the remote source secret",
+			r:    closedPRRequest("/webhooks?projectid=project01", "rssecret"),
+			err:  true,
+		},
+		{
+			name: "project webhook for a project without webhook secret",
+			r:    closedPRRequest("/webhooks?projectid=project02", ""),
+			err:  true,
+		},
+		{
+			name: "organization webhook",
+			r:    closedPRRequest("/webhooks?remotesourceid=rs01", "rssecret"),
+		},
+		{
+			name: "organization webhook without signature",
+			r:    closedPRRequest("/webhooks?remotesourceid=rs01", ""),
+			err:  true,
+		},
+		{
+			name: "organization webhook with wrong signature",
+			r:    closedPRRequest("/webhooks?remotesourceid=rs01", "secret"),
+			err:  true,
+		},
+		{
+			name: "organization webhook for a remote source without organization webhooks",
+			r:    closedPRRequest("/webhooks?remotesourceid=rs02", ""),
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := h.handleWebhook(tt.r, false)
+			if !tt.err {
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				return
+			}
+			if !util.APIErrorIs(err, util.ErrBadRequest) {
+				t.Fatalf("expected bad request error, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/lock"
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/util"
+	cstypes "agola.io/agola/services/configstore/types"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	webhookQueueDir      = "webhooks/queue"
+	webhookDeadLetterDir = "webhooks/deadletter"
+
+	// maxWebhookSize is the max accepted webhook body size (github, the git
+	// source with the biggest payloads, caps them at 25MiB)
+	maxWebhookSize = 25 * 1024 * 1024
+)
+
+// queuedWebhookHeaders are the headers needed to parse the queued webhooks.
+// The token and signature headers aren't persisted since the webhooks are
+// verified before being queued
+var queuedWebhookHeaders = []string{"Content-Type", "X-GitHub-Event", "X-Gitea-Event", "X-Gitlab-Event"}
+
+// queuedWebhook is a received webhook persisted in the object storage
+type queuedWebhook struct {
+	ID           string      `json:"id"`
+	ReceivedTime time.Time   `json:"received_time"`
+	RawQuery     string      `json:"raw_query"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+
+	Attempts        int       `json:"attempts"`
+	LastError       string    `json:"last_error,omitempty"`
+	NextAttemptTime time.Time `json:"next_attempt_time"`
+}
+
+func (w *queuedWebhook) request(ctx context.Context) (*http.Request, error) {
+	r, err := http.NewRequest("POST", "/webhooks?"+w.RawQuery, bytes.NewReader(w.Body))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r.Header = w.Header
+
+	return r.WithContext(ctx), nil
+}
+
+// queuedWebhookHeader returns the received webhook headers to persist
+func queuedWebhookHeader(header http.Header) http.Header {
+	qh := http.Header{}
+	for _, k := range queuedWebhookHeaders {
+		for _, v := range header.Values(k) {
+			qh.Add(k, v)
+		}
+	}
+
+	return qh
+}
+
+// isPermanentWebhookError reports whether the webhook processing error won't
+// be fixed by retrying
+func isPermanentWebhookError(err error) bool {
+	for _, kind := range []util.ErrorKind{util.ErrBadRequest, util.ErrNotExist, util.ErrForbidden, util.ErrUnauthorized} {
+		if util.APIErrorIs(err, kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queueWebhook verifies the received webhook, persists it in the queue and
+// wakes up the queue processing loop
+func (h *webhooksHandler) queueWebhook(w http.ResponseWriter, r *http.Request) error {
+	defer r.Body.Close()
+
+	q := r.URL.Query()
+	if q.Get("remotesourceid") == "" && q.Get("projectid") == "" {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("bad webhook url %q. Missing projectid", r.URL))
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		return util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "failed to read webhook body"))
+	}
+
+	// only persist authentic webhooks so anonymous clients cannot fill the
+	// object storage
+	if err := h.verifyWebhook(r, body); err != nil {
+		return errors.WithStack(err)
+	}
+
+	now := time.Now()
+	qw := &queuedWebhook{
+		// ids are time ordered so the webhooks are processed in the receive order
+		ID:              fmt.Sprintf("%020d-%s", now.UnixNano(), uuid.Must(uuid.NewV4()).String()),
+		ReceivedTime:    now,
+		RawQuery:        r.URL.RawQuery,
+		Header:          queuedWebhookHeader(r.Header),
+		Body:            body,
+		NextAttemptTime: now,
+	}
+	if err := h.writeQueuedWebhook(path.Join(webhookQueueDir, qw.ID), qw); err != nil {
+		return util.NewAPIError(util.ErrInternal, errors.Wrapf(err, "failed to queue webhook"))
+	}
+	h.log.Debug().Msgf("queued webhook %s", qw.ID)
+
+	select {
+	case h.queueNotifyCh <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// verifyWebhook resolves the webhook project, or the remote source of
+// organization webhooks, and verifies the webhook signature
+func (h *webhooksHandler) verifyWebhook(r *http.Request, body []byte) error {
+	ctx := r.Context()
+
+	var rs *cstypes.RemoteSource
+	var secret string
+	if remoteSourceID := r.URL.Query().Get("remotesourceid"); remoteSourceID != "" {
+		var err error
+		rs, _, err = h.configstoreClient.GetRemoteSource(ctx, remoteSourceID)
+		if err != nil {
+			return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %s", remoteSourceID))
+		}
+		if !rs.OrgWebhooks || rs.WebhookSecret == "" {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("remote source %s doesn't have organization webhooks enabled", rs.Name))
+		}
+		secret = rs.WebhookSecret
+	} else {
+		projectID := r.URL.Query().Get("projectid")
+		p, _, err := h.configstoreClient.GetProject(ctx, projectID)
+		if err != nil {
+			return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get project %s", projectID))
+		}
+		if p.WebhookSecret == "" {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("project %s doesn't have a webhook secret, the project must be reconfigured", projectID))
+		}
+		rs, _, err = h.configstoreClient.GetRemoteSource(ctx, p.RemoteSourceID)
+		if err != nil {
+			return util.NewAPIError(util.KindFromRemoteError(err), errors.Wrapf(err, "failed to get remote source %s", p.RemoteSourceID))
+		}
+		secret = p.WebhookSecret
+	}
+
+	// the signature is verified by the webhook parsing, no user credentials
+	// are needed
+	gitSource, err := common.GetGitSource(rs, nil)
+	if err != nil {
+		return util.NewAPIError(util.ErrInternal, errors.Wrapf(err, "failed to create gitsource client"))
+	}
+	vr, err := http.NewRequest("POST", r.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	vr.Header = r.Header
+	if _, err := gitSource.ParseWebhook(vr.WithContext(ctx), secret); err != nil {
+		return util.NewAPIError(util.ErrUnauthorized, errors.Wrapf(err, "failed to verify webhook"))
+	}
+
+	return nil
+}
+
+func (h *webhooksHandler) writeQueuedWebhook(p string, w *queuedWebhook) error {
+	wj, err := json.Marshal(w)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(h.ost.WriteObject(p, bytes.NewReader(wj), int64(len(wj)), true))
+}
+
+func (h *webhooksHandler) readQueuedWebhook(p string) (*queuedWebhook, error) {
+	f, err := h.ost.ReadObject(p)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	var w *queuedWebhook
+	if err := json.NewDecoder(f).Decode(&w); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return w, nil
+}
+
+// ProcessQueueLoop processes the queued webhooks. It's woken up when a new
+// webhook is queued and periodically to retry the failed webhooks and the ones
+// queued before a restart
+func (h *webhooksHandler) ProcessQueueLoop(ctx context.Context) {
+	for {
+		if err := h.processQueue(ctx); err != nil {
+			h.log.Err(err).Send()
+		}
+
+		sleepCh := time.NewTimer(h.queueConfig.RetryInterval).C
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.queueNotifyCh:
+		case <-sleepCh:
+		}
+	}
+}
+
+func (h *webhooksHandler) processQueue(ctx context.Context) error {
+	if err := h.purgeDeadLetterWebhooks(time.Now()); err != nil {
+		h.log.Err(err).Msgf("failed to purge dead letter webhooks")
+	}
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	var paths []string
+	for object := range h.ost.List(webhookQueueDir+"/", "", true, doneCh) {
+		if object.Err != nil {
+			return errors.WithStack(object.Err)
+		}
+		paths = append(paths, object.Path)
+	}
+
+	for _, p := range paths {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := h.processQueuedWebhook(ctx, p); err != nil {
+			h.log.Err(err).Msgf("failed to process queued webhook %q", p)
+		}
+	}
+
+	return nil
+}
+
+// processQueuedWebhook processes a queued webhook. Every gateway instance
+// processes the queue, so the webhook is processed holding a lock keyed by its
+// path
+func (h *webhooksHandler) processQueuedWebhook(ctx context.Context, p string) error {
+	l := h.lf.NewLock("gateway-webhook-" + path.Base(p))
+	if err := l.TryLock(ctx); err != nil {
+		// another gateway instance is processing the webhook
+		if errors.Is(err, lock.ErrLocked) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	defer func() { _ = l.Unlock() }()
+
+	w, err := h.readQueuedWebhook(p)
+	if err != nil {
+		// already processed by another gateway instance
+		if objectstorage.IsNotExist(err) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+
+	now := time.Now()
+	if w.NextAttemptTime.After(now) {
+		return nil
+	}
+
+	r, err := w.request(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// the webhook signature was verified before queuing it
+	herr := h.handleWebhook(r, true)
+	if herr == nil {
+		h.log.Debug().Msgf("processed webhook %s", w.ID)
+		return errors.WithStack(h.ost.DeleteObject(p))
+	}
+
+	w.Attempts++
+	w.LastError = herr.Error()
+
+	if isPermanentWebhookError(herr) || w.Attempts >= h.queueConfig.MaxAttempts {
+		h.log.Err(herr).Msgf("webhook %s failed after %d attempts, moving it to the dead letter queue", w.ID, w.Attempts)
+		if err := h.writeQueuedWebhook(path.Join(webhookDeadLetterDir, w.ID), w); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(h.ost.DeleteObject(p))
+	}
+
+	h.log.Warn().Err(herr).Msgf("webhook %s failed (attempt %d), retrying", w.ID, w.Attempts)
+	w.NextAttemptTime = now.Add(h.queueConfig.RetryInterval * time.Duration(w.Attempts))
+
+	return errors.WithStack(h.writeQueuedWebhook(p, w))
+}
+
+// purgeDeadLetterWebhooks removes the dead letter webhooks older than the
+// dead letter retention
+func (h *webhooksHandler) purgeDeadLetterWebhooks(now time.Time) error {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	var paths []string
+	for object := range h.ost.List(webhookDeadLetterDir+"/", "", true, doneCh) {
+		if object.Err != nil {
+			return errors.WithStack(object.Err)
+		}
+		if now.Sub(object.LastModified) > h.queueConfig.DeadLetterRetention {
+			paths = append(paths, object.Path)
+		}
+	}
+
+	for _, p := range paths {
+		// ignore the webhooks already removed by another gateway instance
+		if err := h.ost.DeleteObject(p); err != nil && !objectstorage.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+		h.log.Debug().Msgf("removed dead letter webhook %s", p)
+	}
+
+	return nil
+}
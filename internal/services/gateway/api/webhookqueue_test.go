@@ -0,0 +1,266 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"agola.io/agola/internal/lock"
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/services/config"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	csclient "agola.io/agola/services/configstore/client"
+	cstypes "agola.io/agola/services/configstore/types"
+	stypes "agola.io/agola/services/types"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rs/zerolog"
+)
+
+// fakeConfigstore serves the configstore api calls needed to verify the
+// webhooks. The other calls fail with an internal error to make the webhook
+// processing fail with a temporary error
+func fakeConfigstore(t *testing.T) *httptest.Server {
+	rs := &cstypes.RemoteSource{
+		ObjectMeta: stypes.ObjectMeta{ID: "rs01"},
+		Name:       "rs01",
+		APIURL:     "http://127.0.0.1:1",
+		Type:       cstypes.RemoteSourceTypeGitea,
+	}
+	projects := map[string]*csapitypes.Project{
+		"project01": {Project: &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project01"}, RemoteSourceID: "rs01", WebhookSecret: "secret"}},
+		"project02": {Project: &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project02"}, RemoteSourceID: "rs01"}},
+	}
+
+	writeJSON := func(w http.ResponseWriter, code int, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Errorf("unexpected err: %v", err)
+		}
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1alpha/remotesources/rs01":
+			writeJSON(w, http.StatusOK, rs)
+		case strings.HasPrefix(r.URL.Path, "/api/v1alpha/projects/"):
+			p, ok := projects[strings.TrimPrefix(r.URL.Path, "/api/v1alpha/projects/")]
+			if !ok {
+				writeJSON(w, http.StatusNotFound, map[string]string{"message": "project doesn't exist"})
+				return
+			}
+			writeJSON(w, http.StatusOK, p)
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func signedWebhookRequest(target, secret string, body []byte) *http.Request {
+	r := httptest.NewRequest("POST", target, bytes.NewReader(body))
+	r.Header.Set("X-Gitea-Event", "push")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		_, _ = mac.Write(body)
+		r.Header.Set("X-Gitea-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	return r
+}
+
+func TestWebhookQueue(t *testing.T) {
+	posix, err := objectstorage.NewPosix(path.Join(t.TempDir(), "ost"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	ost := objectstorage.NewObjStorage(posix, "/")
+
+	ts := fakeConfigstore(t)
+	configstoreClient := csclient.NewClient(ts.URL)
+	queueConfig := &config.WebhookQueue{MaxAttempts: 2, RetryInterval: 1 * time.Nanosecond, DeadLetterRetention: 1 * time.Hour}
+
+	lf := lock.NewLocalLockFactory(lock.NewLocalLocks())
+	h := NewWebhooksHandler(zerolog.Nop(), nil, ost, lf, configstoreClient, nil, "", queueConfig)
+
+	listObjects := func(dir string) []string {
+		doneCh := make(chan struct{})
+		defer close(doneCh)
+
+		var paths []string
+		for object := range ost.List(dir+"/", "", true, doneCh) {
+			if object.Err != nil {
+				t.Fatalf("unexpected err: %v", object.Err)
+			}
+			paths = append(paths, object.Path)
+		}
+		return paths
+	}
+
+	pushBody := []byte(`{"ref": "refs/heads/master", "after": "0123456789abcdef", "repository": {"name": "repo01", "owner": {"username": "user01"}}}`)
+
+	rejectTests := []struct {
+		name string
+		r    *http.Request
+		code int
+	}{
+		{
+			name: "webhook without project or remote source",
+			r:    signedWebhookRequest("/webhooks", "secret", pushBody),
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "webhook for a non existent project",
+			r:    signedWebhookRequest("/webhooks?projectid=project03", "secret", pushBody),
+			code: http.StatusNotFound,
+		},
+		{
+			name: "webhook for a project without webhook secret",
+			r:    signedWebhookRequest("/webhooks?projectid=project02", "secret", pushBody),
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "webhook without signature",
+			r:    signedWebhookRequest("/webhooks?projectid=project01", "", pushBody),
+			code: http.StatusUnauthorized,
+		},
+		{
+			name: "webhook with wrong signature",
+			r:    signedWebhookRequest("/webhooks?projectid=project01", "wrongsecret", pushBody),
+			code: http.StatusUnauthorized,
+		},
+		{
+			name: "webhook with a too big body",
+			r:    signedWebhookRequest("/webhooks?projectid=project01", "secret", bytes.Repeat([]byte(" "), maxWebhookSize+1)),
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range rejectTests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, tt.r)
+			if w.Code != tt.code {
+				t.Fatalf("expected status code %d, got %d", tt.code, w.Code)
+			}
+			if paths := listObjects(webhookQueueDir); len(paths) != 0 {
+				t.Fatalf("expected no queued webhooks, got %v", paths)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedWebhookRequest("/webhooks?projectid=project01", "secret", pushBody))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	paths := listObjects(webhookQueueDir)
+	if len(paths) != 1 {
+		t.Fatalf("expected 1 queued webhook, got %v", paths)
+	}
+
+	// only the headers needed to parse the webhook are persisted
+	qw, err := h.readQueuedWebhook(paths[0])
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	expectedHeader := http.Header{"X-Gitea-Event": []string{"push"}}
+	if diff := cmp.Diff(expectedHeader, qw.Header); diff != "" {
+		t.Fatalf("queued webhook header mismatch (-want +got):\n%s", diff)
+	}
+
+	ctx := context.Background()
+
+	// the webhook is skipped while another gateway instance is processing it
+	l := lf.NewLock("gateway-webhook-" + path.Base(paths[0]))
+	if err := l.Lock(ctx); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := h.processQueue(ctx); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	qw, err = h.readQueuedWebhook(paths[0])
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if qw.Attempts != 0 {
+		t.Fatalf("expected 0 attempts, got %d", qw.Attempts)
+	}
+
+	// first failed attempt, the webhook is kept in the queue
+	if err := h.processQueue(ctx); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	qw, err = h.readQueuedWebhook(paths[0])
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if qw.Attempts != 1 {
+		t.Fatalf("expected 1 attempt, got %d", qw.Attempts)
+	}
+	if qw.LastError == "" {
+		t.Fatalf("expected last error to be set")
+	}
+
+	// max attempts reached, the webhook is moved to the dead letter queue
+	if err := h.processQueue(ctx); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if paths := listObjects(webhookQueueDir); len(paths) != 0 {
+		t.Fatalf("expected no queued webhooks, got %v", paths)
+	}
+	paths = listObjects(webhookDeadLetterDir)
+	if len(paths) != 1 {
+		t.Fatalf("expected 1 dead letter webhook, got %v", paths)
+	}
+	qw, err = h.readQueuedWebhook(paths[0])
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if qw.Attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", qw.Attempts)
+	}
+
+	// dead letter webhooks are kept until the retention expires
+	if err := h.purgeDeadLetterWebhooks(time.Now()); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if paths := listObjects(webhookDeadLetterDir); len(paths) != 1 {
+		t.Fatalf("expected 1 dead letter webhook, got %v", paths)
+	}
+	if err := h.purgeDeadLetterWebhooks(time.Now().Add(queueConfig.DeadLetterRetention + time.Minute)); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if paths := listObjects(webhookDeadLetterDir); len(paths) != 0 {
+		t.Fatalf("expected no dead letter webhooks, got %v", paths)
+	}
+}
@@ -17,22 +17,24 @@ package gateway
 import (
 	"context"
 	"crypto/tls"
-	"io/ioutil"
 	"net/http"
+	"os"
+	"time"
 
 	scommon "agola.io/agola/internal/common"
 	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/lock"
 	"agola.io/agola/internal/objectstorage"
 	"agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/config"
 	"agola.io/agola/internal/services/gateway/action"
 	"agola.io/agola/internal/services/gateway/api"
 	"agola.io/agola/internal/services/gateway/handlers"
+	"agola.io/agola/internal/sql"
 	"agola.io/agola/internal/util"
 	csclient "agola.io/agola/services/configstore/client"
 	rsclient "agola.io/agola/services/runservice/client"
 
-	"github.com/golang-jwt/jwt/v4"
 	ghandlers "github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
@@ -41,6 +43,21 @@ import (
 
 const (
 	maxRequestSize = 1024 * 1024
+
+	// scheduledRunsInterval is the interval between projects schedules
+	// evaluations. Must be lower than the cron minute resolution.
+	scheduledRunsInterval = 30 * time.Second
+
+	// deletedProjectsPurgeInterval is the interval between the checks for
+	// deleted projects to permanently delete
+	deletedProjectsPurgeInterval = 10 * time.Minute
+
+	// downstreamRunsInterval is the interval between the reconnections to the
+	// runservice run events stream
+	downstreamRunsInterval = 1 * time.Second
+
+	scheduledRunsLockKey        = "gateway-scheduledruns"
+	deletedProjectsPurgeLockKey = "gateway-deletedprojectspurge"
 )
 
 type Gateway struct {
@@ -48,10 +65,13 @@ type Gateway struct {
 	c   *config.Gateway
 
 	ost               *objectstorage.ObjStorage
+	lf                lock.LockFactory
 	runserviceClient  *rsclient.Client
 	configstoreClient *csclient.Client
 	ah                *action.ActionHandler
 	sd                *common.TokenSigningData
+	gitserverClient   *http.Client
+	serviceAuth       *common.ServiceAuth
 }
 
 func NewGateway(ctx context.Context, log zerolog.Logger, gc *config.Config) (*Gateway, error) {
@@ -61,7 +81,7 @@ func NewGateway(ctx context.Context, log zerolog.Logger, gc *config.Config) (*Ga
 		log = log.Level(zerolog.DebugLevel)
 	}
 
-	if c.Web.ListenAddress == "" {
+	if len(c.Web.Addresses()) == 0 {
 		return nil, errors.Errorf("listen address undefined")
 	}
 
@@ -74,66 +94,131 @@ func NewGateway(ctx context.Context, log zerolog.Logger, gc *config.Config) (*Ga
 		}
 	}
 
-	sd := &common.TokenSigningData{Duration: c.TokenSigning.Duration}
-	switch c.TokenSigning.Method {
-	case "hmac":
-		sd.Method = jwt.SigningMethodHS256
-		if c.TokenSigning.Key == "" {
-			return nil, errors.Errorf("empty token signing key for hmac method")
-		}
-		sd.Key = []byte(c.TokenSigning.Key)
-	case "rsa":
-		if c.TokenSigning.PrivateKeyPath == "" {
-			return nil, errors.Errorf("token signing private key file for rsa method not defined")
-		}
-		if c.TokenSigning.PublicKeyPath == "" {
-			return nil, errors.Errorf("token signing public key file for rsa method not defined")
-		}
+	sd, err := common.NewTokenSigningData(&c.TokenSigning)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 
-		sd.Method = jwt.SigningMethodRS256
-		privateKeyData, err := ioutil.ReadFile(c.TokenSigning.PrivateKeyPath)
-		if err != nil {
-			return nil, errors.Wrapf(err, "error reading token signing private key")
-		}
-		sd.PrivateKey, err = jwt.ParseRSAPrivateKeyFromPEM(privateKeyData)
-		if err != nil {
-			return nil, errors.Wrapf(err, "error parsing token signing private key")
-		}
-		publicKeyData, err := ioutil.ReadFile(c.TokenSigning.PublicKeyPath)
-		if err != nil {
-			return nil, errors.Wrapf(err, "error reading token signing public key")
-		}
-		sd.PublicKey, err = jwt.ParseRSAPublicKeyFromPEM(publicKeyData)
+	ost, err := scommon.NewObjectStorage(&c.ObjectStorage)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// without a db only local locks are available
+	var lf lock.LockFactory
+	switch c.DB.Type {
+	case "", sql.Sqlite3:
+		lf = lock.NewLocalLockFactory(lock.NewLocalLocks())
+	case sql.Postgres:
+		sdb, err := sql.NewDB(c.DB.Type, c.DB.ConnString)
 		if err != nil {
-			return nil, errors.Wrapf(err, "error parsing token signing public key")
+			return nil, errors.Wrapf(err, "new db error")
 		}
-	case "":
-		return nil, errors.Errorf("missing token signing method")
+		lf = lock.NewPGLockFactory(sdb)
 	default:
-		return nil, errors.Errorf("unknown token signing method: %q", c.TokenSigning.Method)
+		return nil, errors.Errorf("unknown type %q", c.DB.Type)
 	}
 
-	ost, err := scommon.NewObjectStorage(&c.ObjectStorage)
+	serviceAuth, err := common.NewServiceAuth(&gc.InternalServicesAuth, common.ServiceGateway)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
 	configstoreClient := csclient.NewClient(c.ConfigstoreURL)
+	configstoreClient.SetHTTPClient(serviceAuth.HTTPClient(common.ServiceConfigstore))
 	runserviceClient := rsclient.NewClient(c.RunserviceURL)
+	runserviceClient.SetHTTPClient(serviceAuth.HTTPClient(common.ServiceRunservice))
+
+	configEnv := make(map[string]string, len(c.ConfigEnv))
+	for _, name := range c.ConfigEnv {
+		configEnv[name] = os.Getenv(name)
+	}
 
-	ah := action.NewActionHandler(log, sd, configstoreClient, runserviceClient, gc.ID, c.APIExposedURL, c.WebExposedURL)
+	ah := action.NewActionHandler(log, sd, configstoreClient, runserviceClient, lf, gc.ID, c.APIExposedURL, c.WebExposedURL, configEnv, c.DepsProxyURL)
 
 	return &Gateway{
 		log:               log,
 		c:                 c,
 		ost:               ost,
+		lf:                lf,
 		runserviceClient:  runserviceClient,
 		configstoreClient: configstoreClient,
 		ah:                ah,
 		sd:                sd,
+		gitserverClient:   serviceAuth.HTTPClient(common.ServiceGitserver),
+		serviceAuth:       serviceAuth,
 	}, nil
 }
 
+// tryWithLock calls f only when the lock with the provided key isn't held by
+// another gateway instance
+func (g *Gateway) tryWithLock(ctx context.Context, key string, f func() error) error {
+	l := g.lf.NewLock(key)
+	if err := l.TryLock(ctx); err != nil {
+		if errors.Is(err, lock.ErrLocked) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	defer func() { _ = l.Unlock() }()
+
+	return f()
+}
+
+func (g *Gateway) scheduledRunsLoop(ctx context.Context) {
+	scheduledRuns := action.ScheduledRuns{}
+	for {
+		err := g.tryWithLock(ctx, scheduledRunsLockKey, func() error {
+			var err error
+			scheduledRuns, err = g.ah.CreateScheduledRuns(ctx, scheduledRuns, time.Now())
+			return errors.WithStack(err)
+		})
+		if err != nil {
+			g.log.Err(err).Send()
+		}
+
+		sleepCh := time.NewTimer(scheduledRunsInterval).C
+		select {
+		case <-ctx.Done():
+			return
+		case <-sleepCh:
+		}
+	}
+}
+
+func (g *Gateway) deletedProjectsPurgeLoop(ctx context.Context) {
+	for {
+		err := g.tryWithLock(ctx, deletedProjectsPurgeLockKey, func() error {
+			return errors.WithStack(g.ah.PurgeDeletedProjects(ctx, g.c.ProjectDeletionGracePeriod, time.Now()))
+		})
+		if err != nil {
+			g.log.Err(err).Send()
+		}
+
+		sleepCh := time.NewTimer(deletedProjectsPurgeInterval).C
+		select {
+		case <-ctx.Done():
+			return
+		case <-sleepCh:
+		}
+	}
+}
+
+func (g *Gateway) downstreamRunsLoop(ctx context.Context) {
+	for {
+		if err := g.ah.HandleDownstreamRuns(ctx); err != nil {
+			g.log.Err(err).Send()
+		}
+
+		sleepCh := time.NewTimer(downstreamRunsInterval).C
+		select {
+		case <-ctx.Done():
+			return
+		case <-sleepCh:
+		}
+	}
+}
+
 func (g *Gateway) Run(ctx context.Context) error {
 	// noop coors handler
 	corsHandler := func(h http.Handler) http.Handler {
@@ -147,7 +232,7 @@ func (g *Gateway) Run(ctx context.Context) error {
 		corsHandler = ghandlers.CORS(corsAllowedMethodsOptions, corsAllowedHeadersOptions, corsAllowedOriginsOptions)
 	}
 
-	webhooksHandler := api.NewWebhooksHandler(g.log, g.ah, g.configstoreClient, g.runserviceClient, g.c.APIExposedURL)
+	webhooksHandler := api.NewWebhooksHandler(g.log, g.ah, g.ost, g.lf, g.configstoreClient, g.runserviceClient, g.c.APIExposedURL, &g.c.WebhookQueue)
 
 	projectGroupHandler := api.NewProjectGroupHandler(g.log, g.ah)
 	projectGroupSubgroupsHandler := api.NewProjectGroupSubgroupsHandler(g.log, g.ah)
@@ -160,7 +245,13 @@ func (g *Gateway) Run(ctx context.Context) error {
 	createProjectHandler := api.NewCreateProjectHandler(g.log, g.ah)
 	updateProjectHandler := api.NewUpdateProjectHandler(g.log, g.ah)
 	deleteProjectHandler := api.NewDeleteProjectHandler(g.log, g.ah)
+	undeleteProjectHandler := api.NewUndeleteProjectHandler(g.log, g.ah)
+	pollProjectHandler := api.NewPollProjectHandler(g.log, g.ah)
+	cloneProjectHandler := api.NewCloneProjectHandler(g.log, g.ah)
+	importProjectsHandler := api.NewImportProjectsHandler(g.log, g.ah)
 	projectReconfigHandler := api.NewProjectReconfigHandler(g.log, g.ah)
+	projectCachesHandler := api.NewProjectCachesHandler(g.log, g.ah)
+	projectMetricHandler := api.NewProjectMetricHandler(g.log, g.ah)
 	projectUpdateRepoLinkedAccountHandler := api.NewProjectUpdateRepoLinkedAccountHandler(g.log, g.ah)
 	projectCreateRunHandler := api.NewProjectCreateRunHandler(g.log, g.ah)
 
@@ -168,11 +259,13 @@ func (g *Gateway) Run(ctx context.Context) error {
 	createSecretHandler := api.NewCreateSecretHandler(g.log, g.ah)
 	updateSecretHandler := api.NewUpdateSecretHandler(g.log, g.ah)
 	deleteSecretHandler := api.NewDeleteSecretHandler(g.log, g.ah)
+	sealedSecretsPublicKeyHandler := api.NewSealedSecretsPublicKeyHandler(g.log, g.ah)
 
 	variableHandler := api.NewVariableHandler(g.log, g.ah)
 	createVariableHandler := api.NewCreateVariableHandler(g.log, g.ah)
 	updateVariableHandler := api.NewUpdateVariableHandler(g.log, g.ah)
 	deleteVariableHandler := api.NewDeleteVariableHandler(g.log, g.ah)
+	projectGroupVariablesReportHandler := api.NewProjectGroupVariablesReportHandler(g.log, g.ah)
 
 	currentUserHandler := api.NewCurrentUserHandler(g.log, g.ah)
 	userHandler := api.NewUserHandler(g.log, g.ah)
@@ -181,9 +274,12 @@ func (g *Gateway) Run(ctx context.Context) error {
 	deleteUserHandler := api.NewDeleteUserHandler(g.log, g.ah)
 	userCreateRunHandler := api.NewUserCreateRunHandler(g.log, g.ah)
 	userOrgsHandler := api.NewUserOrgsHandler(g.log, g.ah)
+	userPreferencesHandler := api.NewUserPreferencesHandler(g.log, g.ah)
+	updateUserPreferencesHandler := api.NewUpdateUserPreferencesHandler(g.log, g.ah)
 
 	createUserLAHandler := api.NewCreateUserLAHandler(g.log, g.ah)
 	deleteUserLAHandler := api.NewDeleteUserLAHandler(g.log, g.ah)
+	userTokensHandler := api.NewUserTokensHandler(g.log, g.ah)
 	createUserTokenHandler := api.NewCreateUserTokenHandler(g.log, g.ah)
 	deleteUserTokenHandler := api.NewDeleteUserTokenHandler(g.log, g.ah)
 
@@ -192,15 +288,25 @@ func (g *Gateway) Run(ctx context.Context) error {
 	updateRemoteSourceHandler := api.NewUpdateRemoteSourceHandler(g.log, g.ah)
 	remoteSourcesHandler := api.NewRemoteSourcesHandler(g.log, g.ah)
 	deleteRemoteSourceHandler := api.NewDeleteRemoteSourceHandler(g.log, g.ah)
+	remoteSourceRateLimitHandler := api.NewRemoteSourceRateLimitHandler(g.log, g.ah)
 
 	orgHandler := api.NewOrgHandler(g.log, g.ah)
 	orgsHandler := api.NewOrgsHandler(g.log, g.ah)
 	createOrgHandler := api.NewCreateOrgHandler(g.log, g.ah)
+	updateOrgHandler := api.NewUpdateOrgHandler(g.log, g.ah)
 	deleteOrgHandler := api.NewDeleteOrgHandler(g.log, g.ah)
 
 	orgMembersHandler := api.NewOrgMembersHandler(g.log, g.ah)
 	addOrgMemberHandler := api.NewAddOrgMemberHandler(g.log, g.ah)
 	removeOrgMemberHandler := api.NewRemoveOrgMemberHandler(g.log, g.ah)
+	orgUsageHandler := api.NewOrgUsageHandler(g.log, g.ah)
+
+	executorsHandler := api.NewExecutorsHandler(g.log, g.ah)
+	executorActionsHandler := api.NewExecutorActionsHandler(g.log, g.ah)
+
+	announcementsHandler := api.NewAnnouncementsHandler(g.log, g.ah)
+	createAnnouncementHandler := api.NewCreateAnnouncementHandler(g.log, g.ah)
+	expireAnnouncementHandler := api.NewExpireAnnouncementHandler(g.log, g.ah)
 
 	projectRunsHandler := api.NewRunsHandler(g.log, g.ah, common.GroupTypeProject)
 	projectRunHandler := api.NewRunHandler(g.log, g.ah, common.GroupTypeProject)
@@ -209,6 +315,10 @@ func (g *Gateway) Run(ctx context.Context) error {
 	projectRunTaskActionsHandler := api.NewRunTaskActionsHandler(g.log, g.ah, common.GroupTypeProject)
 	projectRunLogsHandler := api.NewLogsHandler(g.log, g.ah, common.GroupTypeProject)
 	projectRunLogsDeleteHandler := api.NewLogsDeleteHandler(g.log, g.ah, common.GroupTypeProject)
+	projectRunLogsInfoHandler := api.NewLogsInfoHandler(g.log, g.ah, common.GroupTypeProject)
+	projectRunArtifactsHandler := api.NewRunArtifactsHandler(g.log, g.ah, common.GroupTypeProject)
+	projectRunTaskArtifactHandler := api.NewRunTaskArtifactHandler(g.log, g.ah, common.GroupTypeProject)
+	projectRunTestReportsHandler := api.NewRunTestReportsHandler(g.log, g.ah, common.GroupTypeProject)
 
 	userRunsHandler := api.NewRunsHandler(g.log, g.ah, common.GroupTypeUser)
 	userRunHandler := api.NewRunHandler(g.log, g.ah, common.GroupTypeUser)
@@ -217,6 +327,10 @@ func (g *Gateway) Run(ctx context.Context) error {
 	userRunTaskActionsHandler := api.NewRunTaskActionsHandler(g.log, g.ah, common.GroupTypeUser)
 	userRunLogsHandler := api.NewLogsHandler(g.log, g.ah, common.GroupTypeUser)
 	userRunLogsDeleteHandler := api.NewLogsDeleteHandler(g.log, g.ah, common.GroupTypeUser)
+	userRunLogsInfoHandler := api.NewLogsInfoHandler(g.log, g.ah, common.GroupTypeUser)
+	userRunArtifactsHandler := api.NewRunArtifactsHandler(g.log, g.ah, common.GroupTypeUser)
+	userRunTaskArtifactHandler := api.NewRunTaskArtifactHandler(g.log, g.ah, common.GroupTypeUser)
+	userRunTestReportsHandler := api.NewRunTestReportsHandler(g.log, g.ah, common.GroupTypeUser)
 
 	userRemoteReposHandler := api.NewUserRemoteReposHandler(g.log, g.ah, g.configstoreClient)
 
@@ -224,7 +338,10 @@ func (g *Gateway) Run(ctx context.Context) error {
 
 	versionHandler := api.NewVersionHandler(g.log, g.ah)
 
-	reposHandler := api.NewReposHandler(g.log, g.c.GitserverURL)
+	openAPIHandler := api.NewOpenAPIHandler(g.log)
+	swaggerUIHandler := api.NewSwaggerUIHandler(g.log)
+
+	reposHandler := api.NewReposHandler(g.log, g.sd, g.gitserverClient, g.c.GitserverURL)
 
 	loginUserHandler := api.NewLoginUserHandler(g.log, g.ah)
 	authorizeHandler := api.NewAuthorizeHandler(g.log, g.ah)
@@ -236,8 +353,12 @@ func (g *Gateway) Run(ctx context.Context) error {
 
 	apirouter := mux.NewRouter().PathPrefix("/api/v1alpha").Subrouter().UseEncodedPath()
 
-	authForcedHandler := handlers.NewAuthHandler(g.log, g.configstoreClient, g.c.AdminToken, g.sd, true)
-	authOptionalHandler := handlers.NewAuthHandler(g.log, g.configstoreClient, g.c.AdminToken, g.sd, false)
+	tokenLastUsedCache := handlers.NewUserTokenLastUsedCache()
+	authForcedHandler := handlers.NewAuthHandler(g.log, g.configstoreClient, g.c.AdminToken, g.sd, tokenLastUsedCache, true)
+	schedulerAuthHandler := func(h http.Handler) http.Handler {
+		return g.serviceAuth.NewServiceAuthHandler(g.log, common.ServiceAuthorizations{common.ServiceScheduler: nil}, h)
+	}
+	authOptionalHandler := handlers.NewAuthHandler(g.log, g.configstoreClient, g.c.AdminToken, g.sd, tokenLastUsedCache, false)
 
 	router.PathPrefix("/api/v1alpha").Handler(apirouter)
 
@@ -245,6 +366,7 @@ func (g *Gateway) Run(ctx context.Context) error {
 	apirouter.Handle("/projectgroups/{projectgroupref}", authForcedHandler(projectGroupHandler)).Methods("GET")
 	apirouter.Handle("/projectgroups/{projectgroupref}/subgroups", authForcedHandler(projectGroupSubgroupsHandler)).Methods("GET")
 	apirouter.Handle("/projectgroups/{projectgroupref}/projects", authForcedHandler(projectGroupProjectsHandler)).Methods("GET")
+	apirouter.Handle("/projectgroups/{projectgroupref}/importprojects", authForcedHandler(importProjectsHandler)).Methods("POST")
 	apirouter.Handle("/projectgroups", authForcedHandler(createProjectGroupHandler)).Methods("POST")
 	apirouter.Handle("/projectgroups/{projectgroupref}", authForcedHandler(updateProjectGroupHandler)).Methods("PUT")
 	apirouter.Handle("/projectgroups/{projectgroupref}", authForcedHandler(deleteProjectGroupHandler)).Methods("DELETE")
@@ -253,7 +375,13 @@ func (g *Gateway) Run(ctx context.Context) error {
 	apirouter.Handle("/projects", authForcedHandler(createProjectHandler)).Methods("POST")
 	apirouter.Handle("/projects/{projectref}", authForcedHandler(updateProjectHandler)).Methods("PUT")
 	apirouter.Handle("/projects/{projectref}", authForcedHandler(deleteProjectHandler)).Methods("DELETE")
+	apirouter.Handle("/projects/{projectref}/undelete", authForcedHandler(undeleteProjectHandler)).Methods("PUT")
+	apirouter.Handle("/projects/{projectref}/clone", authForcedHandler(cloneProjectHandler)).Methods("POST")
 	apirouter.Handle("/projects/{projectref}/reconfig", authForcedHandler(projectReconfigHandler)).Methods("PUT")
+	// internal route called by the scheduler, not part of the public api
+	apirouter.Handle("/projects/{projectref}/poll", schedulerAuthHandler(pollProjectHandler)).Methods("POST")
+	apirouter.Handle("/projects/{projectref}/caches", authForcedHandler(projectCachesHandler)).Methods("GET")
+	apirouter.Handle("/projects/{projectref}/stats/metrics/{metric}", authOptionalHandler(projectMetricHandler)).Methods("GET")
 	apirouter.Handle("/projects/{projectref}/updaterepolinkedaccount", authForcedHandler(projectUpdateRepoLinkedAccountHandler)).Methods("PUT")
 	apirouter.Handle("/projects/{projectref}/createrun", authForcedHandler(projectCreateRunHandler)).Methods("POST")
 	apirouter.Handle("/projects/{projectref}/runs", authForcedHandler(projectRunsHandler)).Methods("GET")
@@ -263,6 +391,10 @@ func (g *Gateway) Run(ctx context.Context) error {
 	apirouter.Handle("/projects/{projectref}/runs/{runnumber}/tasks/{taskid}/actions", authForcedHandler(projectRunTaskActionsHandler)).Methods("PUT")
 	apirouter.Handle("/projects/{projectref}/runs/{runnumber}/tasks/{taskid}/logs", authOptionalHandler(projectRunLogsHandler)).Methods("GET")
 	apirouter.Handle("/projects/{projectref}/runs/{runnumber}/tasks/{taskid}/logs", authForcedHandler(projectRunLogsDeleteHandler)).Methods("DELETE")
+	apirouter.Handle("/projects/{projectref}/runs/{runnumber}/tasks/{taskid}/logs/info", authOptionalHandler(projectRunLogsInfoHandler)).Methods("GET")
+	apirouter.Handle("/projects/{projectref}/runs/{runnumber}/artifacts", authOptionalHandler(projectRunArtifactsHandler)).Methods("GET")
+	apirouter.Handle("/projects/{projectref}/runs/{runnumber}/tasks/{taskid}/artifact", authOptionalHandler(projectRunTaskArtifactHandler)).Methods("GET")
+	apirouter.Handle("/projects/{projectref}/runs/{runnumber}/testreports", authOptionalHandler(projectRunTestReportsHandler)).Methods("GET")
 
 	apirouter.Handle("/projectgroups/{projectgroupref}/secrets", authForcedHandler(secretHandler)).Methods("GET")
 	apirouter.Handle("/projects/{projectref}/secrets", authForcedHandler(secretHandler)).Methods("GET")
@@ -272,6 +404,7 @@ func (g *Gateway) Run(ctx context.Context) error {
 	apirouter.Handle("/projects/{projectref}/secrets/{secretname}", authForcedHandler(updateSecretHandler)).Methods("PUT")
 	apirouter.Handle("/projectgroups/{projectgroupref}/secrets/{secretname}", authForcedHandler(deleteSecretHandler)).Methods("DELETE")
 	apirouter.Handle("/projects/{projectref}/secrets/{secretname}", authForcedHandler(deleteSecretHandler)).Methods("DELETE")
+	apirouter.Handle("/sealedsecrets/publickey", authForcedHandler(sealedSecretsPublicKeyHandler)).Methods("GET")
 
 	apirouter.Handle("/projectgroups/{projectgroupref}/variables", authForcedHandler(variableHandler)).Methods("GET")
 	apirouter.Handle("/projects/{projectref}/variables", authForcedHandler(variableHandler)).Methods("GET")
@@ -281,6 +414,7 @@ func (g *Gateway) Run(ctx context.Context) error {
 	apirouter.Handle("/projects/{projectref}/variables/{variablename}", authForcedHandler(updateVariableHandler)).Methods("PUT")
 	apirouter.Handle("/projectgroups/{projectgroupref}/variables/{variablename}", authForcedHandler(deleteVariableHandler)).Methods("DELETE")
 	apirouter.Handle("/projects/{projectref}/variables/{variablename}", authForcedHandler(deleteVariableHandler)).Methods("DELETE")
+	apirouter.Handle("/projectgroups/{projectgroupref}/variablesreport", authForcedHandler(projectGroupVariablesReportHandler)).Methods("GET")
 
 	apirouter.Handle("/user", authForcedHandler(currentUserHandler)).Methods("GET")
 	apirouter.Handle("/users/{userref}", authForcedHandler(userHandler)).Methods("GET")
@@ -289,6 +423,8 @@ func (g *Gateway) Run(ctx context.Context) error {
 	apirouter.Handle("/users/{userref}", authForcedHandler(deleteUserHandler)).Methods("DELETE")
 	apirouter.Handle("/user/createrun", authForcedHandler(userCreateRunHandler)).Methods("POST")
 	apirouter.Handle("/user/orgs", authForcedHandler(userOrgsHandler)).Methods("GET")
+	apirouter.Handle("/user/preferences", authForcedHandler(userPreferencesHandler)).Methods("GET")
+	apirouter.Handle("/user/preferences", authForcedHandler(updateUserPreferencesHandler)).Methods("PUT")
 
 	apirouter.Handle("/users/{userref}/runs", authForcedHandler(userRunsHandler)).Methods("GET")
 	apirouter.Handle("/users/{userref}/runs/{runnumber}", authOptionalHandler(userRunHandler)).Methods("GET")
@@ -297,9 +433,14 @@ func (g *Gateway) Run(ctx context.Context) error {
 	apirouter.Handle("/users/{userref}/runs/{runnumber}/tasks/{taskid}/actions", authForcedHandler(userRunTaskActionsHandler)).Methods("PUT")
 	apirouter.Handle("/users/{userref}/runs/{runnumber}/tasks/{taskid}/logs", authOptionalHandler(userRunLogsHandler)).Methods("GET")
 	apirouter.Handle("/users/{userref}/runs/{runnumber}/tasks/{taskid}/logs", authForcedHandler(userRunLogsDeleteHandler)).Methods("DELETE")
+	apirouter.Handle("/users/{userref}/runs/{runnumber}/tasks/{taskid}/logs/info", authOptionalHandler(userRunLogsInfoHandler)).Methods("GET")
+	apirouter.Handle("/users/{userref}/runs/{runnumber}/artifacts", authOptionalHandler(userRunArtifactsHandler)).Methods("GET")
+	apirouter.Handle("/users/{userref}/runs/{runnumber}/tasks/{taskid}/artifact", authOptionalHandler(userRunTaskArtifactHandler)).Methods("GET")
+	apirouter.Handle("/users/{userref}/runs/{runnumber}/testreports", authOptionalHandler(userRunTestReportsHandler)).Methods("GET")
 
 	apirouter.Handle("/users/{userref}/linkedaccounts", authForcedHandler(createUserLAHandler)).Methods("POST")
 	apirouter.Handle("/users/{userref}/linkedaccounts/{laid}", authForcedHandler(deleteUserLAHandler)).Methods("DELETE")
+	apirouter.Handle("/users/{userref}/tokens", authForcedHandler(userTokensHandler)).Methods("GET")
 	apirouter.Handle("/users/{userref}/tokens", authForcedHandler(createUserTokenHandler)).Methods("POST")
 	apirouter.Handle("/users/{userref}/tokens/{tokenname}", authForcedHandler(deleteUserTokenHandler)).Methods("DELETE")
 
@@ -308,14 +449,24 @@ func (g *Gateway) Run(ctx context.Context) error {
 	apirouter.Handle("/remotesources/{remotesourceref}", authForcedHandler(updateRemoteSourceHandler)).Methods("PUT")
 	apirouter.Handle("/remotesources", authOptionalHandler(remoteSourcesHandler)).Methods("GET")
 	apirouter.Handle("/remotesources/{remotesourceref}", authForcedHandler(deleteRemoteSourceHandler)).Methods("DELETE")
+	apirouter.Handle("/remotesources/{remotesourceref}/ratelimit", authForcedHandler(remoteSourceRateLimitHandler)).Methods("GET")
 
 	apirouter.Handle("/orgs/{orgref}", authForcedHandler(orgHandler)).Methods("GET")
 	apirouter.Handle("/orgs", authForcedHandler(orgsHandler)).Methods("GET")
 	apirouter.Handle("/orgs", authForcedHandler(createOrgHandler)).Methods("POST")
+	apirouter.Handle("/orgs/{orgref}", authForcedHandler(updateOrgHandler)).Methods("PUT")
 	apirouter.Handle("/orgs/{orgref}", authForcedHandler(deleteOrgHandler)).Methods("DELETE")
 	apirouter.Handle("/orgs/{orgref}/members", authForcedHandler(orgMembersHandler)).Methods("GET")
 	apirouter.Handle("/orgs/{orgref}/members/{userref}", authForcedHandler(addOrgMemberHandler)).Methods("PUT")
 	apirouter.Handle("/orgs/{orgref}/members/{userref}", authForcedHandler(removeOrgMemberHandler)).Methods("DELETE")
+	apirouter.Handle("/orgs/{orgref}/usage", authForcedHandler(orgUsageHandler)).Methods("GET")
+
+	apirouter.Handle("/executors", authForcedHandler(executorsHandler)).Methods("GET")
+	apirouter.Handle("/executors/{executorid}/actions", authForcedHandler(executorActionsHandler)).Methods("PUT")
+
+	apirouter.Handle("/announcements", authOptionalHandler(announcementsHandler)).Methods("GET")
+	apirouter.Handle("/announcements", authForcedHandler(createAnnouncementHandler)).Methods("POST")
+	apirouter.Handle("/announcements/{announcementid}/expire", authForcedHandler(expireAnnouncementHandler)).Methods("PUT")
 
 	apirouter.Handle("/user/remoterepos/{remotesourceref}", authForcedHandler(userRemoteReposHandler)).Methods("GET")
 
@@ -323,13 +474,17 @@ func (g *Gateway) Run(ctx context.Context) error {
 
 	apirouter.Handle("/version", versionHandler).Methods("GET")
 
+	apirouter.Handle("/openapi.json", openAPIHandler).Methods("GET")
+	if g.c.SwaggerUI {
+		apirouter.Handle("/swaggerui", swaggerUIHandler).Methods("GET")
+	}
+
 	apirouter.Handle("/auth/login", loginUserHandler).Methods("POST")
 	apirouter.Handle("/auth/authorize", authorizeHandler).Methods("POST")
 	apirouter.Handle("/auth/register", registerHandler).Methods("POST")
 	apirouter.Handle("/auth/oauth2/callback", oauth2callbackHandler).Methods("GET")
 
-	// TODO(sgotti) add auth to these requests
-	reposRouter.Handle("/repos/{rest:.*}", reposHandler).Methods("GET", "POST")
+	reposRouter.Handle("/repos/{rest:.*}", authOptionalHandler(reposHandler)).Methods("GET", "POST")
 
 	router.Handle("/webhooks", webhooksHandler).Methods("POST")
 	router.PathPrefix("/").HandlerFunc(handlers.NewWebBundleHandlerFunc(g.c.APIExposedURL))
@@ -351,18 +506,18 @@ func (g *Gateway) Run(ctx context.Context) error {
 	}
 
 	httpServer := http.Server{
-		Addr:      g.c.Web.ListenAddress,
 		Handler:   mainrouter,
 		TLSConfig: tlsConfig,
 	}
 
+	go g.scheduledRunsLoop(ctx)
+	go g.deletedProjectsPurgeLoop(ctx)
+	go g.downstreamRunsLoop(ctx)
+	go webhooksHandler.ProcessQueueLoop(ctx)
+
 	lerrCh := make(chan error)
 	go func() {
-		if !g.c.Web.TLS {
-			lerrCh <- httpServer.ListenAndServe()
-		} else {
-			lerrCh <- httpServer.ListenAndServeTLS("", "")
-		}
+		lerrCh <- util.ListenAndServe(&httpServer, g.c.Web.Addresses(), g.c.Web.TLS)
 	}()
 
 	select {
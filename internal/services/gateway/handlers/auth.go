@@ -18,11 +18,13 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"agola.io/agola/internal/errors"
 	scommon "agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/gateway/common"
 	"agola.io/agola/internal/util"
+	csapitypes "agola.io/agola/services/configstore/api/types"
 	csclient "agola.io/agola/services/configstore/client"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -30,6 +32,14 @@ import (
 	"github.com/rs/zerolog"
 )
 
+const (
+	// userTokenLastUsedUpdateInterval is the min interval between updates of
+	// the user tokens last used time
+	userTokenLastUsedUpdateInterval = 5 * time.Minute
+
+	userTokenLastUsedCacheMaxEntries = 10000
+)
+
 type AuthHandler struct {
 	log  zerolog.Logger
 	next http.Handler
@@ -40,21 +50,55 @@ type AuthHandler struct {
 	sd *scommon.TokenSigningData
 
 	required bool
+
+	// tokenLastUsedCache contains the recently updated user tokens
+	tokenLastUsedCache *util.TTLCache
 }
 
-func NewAuthHandler(log zerolog.Logger, configstoreClient *csclient.Client, adminToken string, sd *scommon.TokenSigningData, required bool) func(http.Handler) http.Handler {
+// NewUserTokenLastUsedCache creates the cache of the recently updated user
+// tokens. It must be shared by all the auth handlers so a token last used
+// time is updated at most once every userTokenLastUsedUpdateInterval
+// regardless of the called api.
+func NewUserTokenLastUsedCache() *util.TTLCache {
+	return util.NewTTLCache(userTokenLastUsedUpdateInterval, userTokenLastUsedCacheMaxEntries)
+}
+
+func NewAuthHandler(log zerolog.Logger, configstoreClient *csclient.Client, adminToken string, sd *scommon.TokenSigningData, tokenLastUsedCache *util.TTLCache, required bool) func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return &AuthHandler{
-			log:               log,
-			next:              h,
-			configstoreClient: configstoreClient,
-			adminToken:        adminToken,
-			sd:                sd,
-			required:          required,
+			log:                log,
+			next:               h,
+			configstoreClient:  configstoreClient,
+			adminToken:         adminToken,
+			sd:                 sd,
+			required:           required,
+			tokenLastUsedCache: tokenLastUsedCache,
 		}
 	}
 }
 
+// updateTokenLastUsed updates the user token last used time in the
+// configstore. To avoid a write at every request the update is done at most
+// once every userTokenLastUsedUpdateInterval.
+func (h *AuthHandler) updateTokenLastUsed(userID, tokenString string) {
+	// use the token hash to not keep the tokens in memory
+	key := util.EncodeSha256Hex(tokenString)
+	if _, ok := h.tokenLastUsedCache.Get(key); ok {
+		return
+	}
+	h.tokenLastUsedCache.Set(key, struct{}{})
+
+	req := &csapitypes.UpdateUserTokenLastUsedRequest{
+		Token:        tokenString,
+		LastUsedTime: time.Now(),
+	}
+	go func() {
+		if _, err := h.configstoreClient.UpdateUserTokenLastUsed(context.Background(), userID, req); err != nil {
+			h.log.Err(err).Msgf("failed to update user %q token last used time", userID)
+		}
+	}()
+}
+
 func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -75,6 +119,8 @@ func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			h.updateTokenLastUsed(user.ID, tokenString)
+
 			// pass userid to handlers via context
 			ctx = context.WithValue(ctx, common.ContextKeyUserID, user.ID)
 			ctx = context.WithValue(ctx, common.ContextKeyUsername, user.Name)
@@ -117,7 +163,12 @@ func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		// Set username in the request context
 		claims := token.Claims.(jwt.MapClaims)
-		userID := claims["sub"].(string)
+		// tokens without a subject (i.e. repo tokens) aren't login tokens
+		userID, ok := claims["sub"].(string)
+		if !ok || userID == "" {
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
 
 		user, _, err := h.configstoreClient.GetUser(ctx, userID)
 		if err != nil {
@@ -0,0 +1,375 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package openapi generates the OpenAPI 3 specification of the gateway API
+// from the operations table and the api types.
+package openapi
+
+//go:generate ../../../../tools/bin/generators -component gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/util"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+	apiBasePath    = "/api/v1alpha"
+)
+
+type document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       *info                           `json:"info"`
+	Servers    []*server                       `json:"servers"`
+	Paths      map[string]map[string]*opObject `json:"paths"`
+	Components *components                     `json:"components"`
+}
+
+type info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type server struct {
+	URL string `json:"url"`
+}
+
+type components struct {
+	Schemas         map[string]*schema         `json:"schemas"`
+	SecuritySchemes map[string]*securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+type opObject struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags"`
+	Parameters  []*parameter          `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+}
+
+var pathParamRegexp = regexp.MustCompile(`{([^}]+)}`)
+
+// Generate generates the OpenAPI specification of the gateway API in json
+// format
+func Generate() ([]byte, error) {
+	g := &generator{
+		schemas: map[string]*schema{},
+		names:   map[reflect.Type]string{},
+	}
+
+	doc, err := g.document(operations)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return data, nil
+}
+
+type generator struct {
+	schemas map[string]*schema
+	names   map[reflect.Type]string
+}
+
+func (g *generator) document(ops []*operation) (*document, error) {
+	doc := &document{
+		OpenAPI: openAPIVersion,
+		Info: &info{
+			Title:   "Agola Gateway API",
+			Version: "v1alpha",
+		},
+		Servers: []*server{{URL: apiBasePath}},
+		Paths:   map[string]map[string]*opObject{},
+		Components: &components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]*securityScheme{
+				"token": {
+					Type:        "apiKey",
+					Description: `user token provided as "token <token>"`,
+					In:          "header",
+					Name:        "Authorization",
+				},
+				"bearer": {
+					Type:   "http",
+					Scheme: "bearer",
+				},
+			},
+		},
+	}
+
+	errorSchema, err := g.schema(reflect.TypeOf(util.ErrorResponse{}))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ids := map[string]struct{}{}
+	for _, op := range ops {
+		if _, ok := ids[op.id]; ok {
+			return nil, errors.Errorf("duplicate operation id %q", op.id)
+		}
+		ids[op.id] = struct{}{}
+
+		o := &opObject{
+			OperationID: op.id,
+			Summary:     op.summary,
+			Tags:        []string{op.tag},
+			Responses: map[string]*response{
+				"default": {
+					Description: "error",
+					Content:     map[string]*mediaType{"application/json": {Schema: errorSchema}},
+				},
+			},
+		}
+
+		for _, m := range pathParamRegexp.FindAllStringSubmatch(op.path, -1) {
+			o.Parameters = append(o.Parameters, &parameter{Name: m[1], In: "path", Required: true, Schema: &schema{Type: "string"}})
+		}
+		for _, p := range op.params {
+			o.Parameters = append(o.Parameters, &parameter{Name: p.name, In: "query", Description: p.description, Schema: &schema{Type: p.typ}})
+		}
+
+		if op.request != nil {
+			s, err := g.schema(reflect.TypeOf(op.request))
+			if err != nil {
+				return nil, errors.Wrapf(err, "operation %q request", op.id)
+			}
+			o.RequestBody = &requestBody{
+				Required: true,
+				Content:  map[string]*mediaType{"application/json": {Schema: s}},
+			}
+		}
+
+		status := op.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		r := &response{Description: http.StatusText(status)}
+		switch {
+		case op.contentType != "":
+			r.Content = map[string]*mediaType{op.contentType: {Schema: &schema{Type: "string"}}}
+		case op.response != nil:
+			s, err := g.schema(reflect.TypeOf(op.response))
+			if err != nil {
+				return nil, errors.Wrapf(err, "operation %q response", op.id)
+			}
+			r.Content = map[string]*mediaType{"application/json": {Schema: s}}
+		}
+		o.Responses[strconv.Itoa(status)] = r
+
+		switch op.auth {
+		case authForced:
+			o.Security = []map[string][]string{{"token": {}}, {"bearer": {}}}
+		case authOptional:
+			o.Security = []map[string][]string{{"token": {}}, {"bearer": {}}, {}}
+		}
+
+		if _, ok := doc.Paths[op.path]; !ok {
+			doc.Paths[op.path] = map[string]*opObject{}
+		}
+		method := strings.ToLower(op.method)
+		if _, ok := doc.Paths[op.path][method]; ok {
+			return nil, errors.Errorf("duplicate operation for %s %s", op.method, op.path)
+		}
+		doc.Paths[op.path][method] = o
+	}
+
+	return doc, nil
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema returns the schema of the provided type. Named struct types are
+// added to the components schemas and referenced.
+func (g *generator) schema(t reflect.Type) (*schema, error) {
+	switch t {
+	case timeType:
+		return &schema{Type: "string", Format: "date-time"}, nil
+	case durationType:
+		// durations are encoded as nanoseconds
+		return &schema{Type: "integer", Format: "int64"}, nil
+	case rawMessageType:
+		return &schema{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return &schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &schema{Type: "integer", Format: "int32"}, nil
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &schema{Type: "integer", Format: "int64"}, nil
+	case reflect.Float32:
+		return &schema{Type: "number", Format: "float"}, nil
+	case reflect.Float64:
+		return &schema{Type: "number", Format: "double"}, nil
+	case reflect.String:
+		return &schema{Type: "string"}, nil
+	case reflect.Interface:
+		return &schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, errors.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+
+	return nil, errors.Errorf("unsupported type %s", t)
+}
+
+func (g *generator) structSchema(t reflect.Type) (*schema, error) {
+	if t.Name() == "" {
+		return nil, errors.Errorf("unsupported anonymous struct type %s", t)
+	}
+
+	if name, ok := g.names[t]; ok {
+		return &schema{Ref: schemaRef(name)}, nil
+	}
+	name := t.Name()
+	if _, ok := g.schemas[name]; ok {
+		return nil, errors.Errorf("schema name %q of type %s already used by another type", name, t)
+	}
+
+	// register the schema before generating the properties to handle
+	// recursive types
+	s := &schema{Type: "object", Properties: map[string]*schema{}}
+	g.names[t] = name
+	g.schemas[name] = s
+
+	if err := g.structProperties(t, s); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &schema{Ref: schemaRef(name)}, nil
+}
+
+func (g *generator) structProperties(t reflect.Type, s *schema) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		// embedded structs fields are promoted
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := g.structProperties(ft, s); err != nil {
+					return errors.WithStack(err)
+				}
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			// unexported field
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs, err := g.schema(f.Type)
+		if err != nil {
+			return errors.Wrapf(err, "field %s.%s", t, f.Name)
+		}
+		s.Properties[name] = fs
+	}
+
+	return nil
+}
+
+func schemaRef(name string) string {
+	return fmt.Sprintf("#/components/schemas/%s", name)
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"io/ioutil"
+	"regexp"
+	"testing"
+)
+
+func TestSpecUpToDate(t *testing.T) {
+	spec, err := Generate()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if string(spec) != Spec {
+		t.Fatalf("generated spec differs from the committed one, run \"make generate\"")
+	}
+}
+
+var routeRegexp = regexp.MustCompile(`(?m)^\s*apirouter\.Handle\("([^"]+)", (authForcedHandler|authOptionalHandler)?\(?[a-zA-Z0-9]+\)?\)\.Methods\("([A-Z]+)"\)`)
+
+// TestOperationsMatchRoutes checks that the operations match the routes
+// registered by the gateway
+func TestOperationsMatchRoutes(t *testing.T) {
+	data, err := ioutil.ReadFile("../gateway.go")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	type route struct {
+		method string
+		path   string
+	}
+	routes := map[route]authType{}
+	for _, m := range routeRegexp.FindAllStringSubmatch(string(data), -1) {
+		auth := authNone
+		switch m[2] {
+		case "authForcedHandler":
+			auth = authForced
+		case "authOptionalHandler":
+			auth = authOptional
+		}
+		routes[route{method: m[3], path: m[1]}] = auth
+	}
+	if len(routes) == 0 {
+		t.Fatalf("no routes found")
+	}
+
+	ops := map[route]struct{}{}
+	for _, op := range operations {
+		r := route{method: op.method, path: op.path}
+		ops[r] = struct{}{}
+		auth, ok := routes[r]
+		if !ok {
+			t.Errorf("operation %q: route %s %s not registered", op.id, op.method, op.path)
+			continue
+		}
+		if auth != op.auth {
+			t.Errorf("operation %q: auth %d, route auth %d", op.id, op.auth, auth)
+		}
+	}
+	for r := range routes {
+		if _, ok := ops[r]; !ok {
+			t.Errorf("route %s %s without operation", r.method, r.path)
+		}
+	}
+}
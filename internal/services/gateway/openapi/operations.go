@@ -0,0 +1,252 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"net/http"
+
+	gwapitypes "agola.io/agola/services/gateway/api/types"
+)
+
+type authType int
+
+const (
+	authNone authType = iota
+	authOptional
+	authForced
+)
+
+type queryParam struct {
+	name        string
+	typ         string
+	description string
+}
+
+// operation defines a gateway api operation. request and response are values
+// of the types encoded in the request and response bodies.
+type operation struct {
+	method  string
+	path    string
+	id      string
+	summary string
+	tag     string
+	auth    authType
+	params  []*queryParam
+
+	request  interface{}
+	response interface{}
+	// status is the response status code on success, defaults to 200
+	status int
+	// contentType is the content type of non json responses
+	contentType string
+}
+
+var (
+	listParams = []*queryParam{
+		{name: "start", typ: "string", description: "start listing after this name"},
+		{name: "limit", typ: "integer", description: "max number of returned items"},
+		{name: "asc", typ: "boolean", description: "sort ascending"},
+	}
+	treeParams = []*queryParam{
+		{name: "tree", typ: "boolean", description: "also return the items of the parent project groups"},
+		{name: "removeoverridden", typ: "boolean", description: "remove the items overridden by a child"},
+	}
+	logsParams = []*queryParam{
+		{name: "setup", typ: "boolean", description: "setup step logs"},
+		{name: "step", typ: "integer", description: "step number"},
+	}
+)
+
+// operations contains all the gateway api operations. It must be kept in sync
+// with the gateway routes.
+var operations = concatOperations(
+	projectGroupOperations,
+	projectOperations,
+	runOperations("/projects/{projectref}", "Project"),
+	secretOperations,
+	variableOperations,
+	userOperations,
+	runOperations("/users/{userref}", "User"),
+	remoteSourceOperations,
+	orgOperations,
+	miscOperations,
+)
+
+func concatOperations(opsl ...[]*operation) []*operation {
+	var res []*operation
+	for _, ops := range opsl {
+		res = append(res, ops...)
+	}
+	return res
+}
+
+var projectGroupOperations = []*operation{
+	{method: "GET", path: "/projectgroups/{projectgroupref}", id: "getProjectGroup", summary: "get a project group", tag: "projectgroups", auth: authForced, response: &gwapitypes.ProjectGroupResponse{}},
+	{method: "GET", path: "/projectgroups/{projectgroupref}/subgroups", id: "getProjectGroupSubgroups", summary: "get the project group subgroups", tag: "projectgroups", auth: authForced, response: []*gwapitypes.ProjectGroupResponse{}},
+	{method: "GET", path: "/projectgroups/{projectgroupref}/projects", id: "getProjectGroupProjects", summary: "get the project group projects", tag: "projectgroups", auth: authForced, response: []*gwapitypes.ProjectResponse{},
+		params: []*queryParam{{name: "tag", typ: "string", description: "filter projects by tag"}}},
+	{method: "POST", path: "/projectgroups/{projectgroupref}/importprojects", id: "importProjects", summary: "import the repositories of a remote source organization as projects", tag: "projectgroups", auth: authForced, request: &gwapitypes.ImportProjectsRequest{}, response: []*gwapitypes.ImportProjectResponse{}},
+	{method: "POST", path: "/projectgroups", id: "createProjectGroup", summary: "create a project group", tag: "projectgroups", auth: authForced, request: &gwapitypes.CreateProjectGroupRequest{}, response: &gwapitypes.ProjectGroupResponse{}, status: http.StatusCreated},
+	{method: "PUT", path: "/projectgroups/{projectgroupref}", id: "updateProjectGroup", summary: "update a project group", tag: "projectgroups", auth: authForced, request: &gwapitypes.UpdateProjectGroupRequest{}, response: &gwapitypes.ProjectGroupResponse{}, status: http.StatusCreated},
+	{method: "DELETE", path: "/projectgroups/{projectgroupref}", id: "deleteProjectGroup", summary: "delete a project group", tag: "projectgroups", auth: authForced, status: http.StatusNoContent},
+	{method: "GET", path: "/projectgroups/{projectgroupref}/variablesreport", id: "getProjectGroupVariablesReport", summary: "get the variables of all the project group projects", tag: "variables", auth: authForced, response: []*gwapitypes.ProjectVariablesReportResponse{}},
+}
+
+var projectOperations = []*operation{
+	{method: "GET", path: "/projects/{projectref}", id: "getProject", summary: "get a project", tag: "projects", auth: authOptional, response: &gwapitypes.ProjectResponse{}},
+	{method: "POST", path: "/projects", id: "createProject", summary: "create a project", tag: "projects", auth: authForced, request: &gwapitypes.CreateProjectRequest{}, response: &gwapitypes.ProjectResponse{}, status: http.StatusCreated},
+	{method: "PUT", path: "/projects/{projectref}", id: "updateProject", summary: "update a project", tag: "projects", auth: authForced, request: &gwapitypes.UpdateProjectRequest{}, response: &gwapitypes.ProjectResponse{}, status: http.StatusCreated},
+	{method: "DELETE", path: "/projects/{projectref}", id: "deleteProject", summary: "delete a project, it can be restored until the deletion grace period expires", tag: "projects", auth: authForced, status: http.StatusNoContent},
+	{method: "PUT", path: "/projects/{projectref}/undelete", id: "undeleteProject", summary: "restore a deleted project (admin only)", tag: "projects", auth: authForced, response: &gwapitypes.ProjectResponse{}},
+	{method: "POST", path: "/projects/{projectref}/clone", id: "cloneProject", summary: "clone a project", tag: "projects", auth: authForced, request: &gwapitypes.CloneProjectRequest{}, response: &gwapitypes.ProjectResponse{}, status: http.StatusCreated},
+	{method: "PUT", path: "/projects/{projectref}/reconfig", id: "reconfigProject", summary: "reconfigure the project remote repository", tag: "projects", auth: authForced, status: http.StatusNoContent},
+	{method: "GET", path: "/projects/{projectref}/caches", id: "getProjectCaches", summary: "get the project caches", tag: "projects", auth: authForced, response: &gwapitypes.ProjectCachesResponse{}},
+	{method: "GET", path: "/projects/{projectref}/stats/metrics/{metric}", id: "getProjectMetric", summary: "get the values of a metric emitted by the project runs", tag: "projects", auth: authOptional, response: []*gwapitypes.MetricValueResponse{},
+		params: []*queryParam{
+			{name: "branch", typ: "string", description: "filter runs by branch"},
+			{name: "start", typ: "integer", description: "start from this run number"},
+			{name: "limit", typ: "integer", description: "max number of returned values"},
+		}},
+	{method: "PUT", path: "/projects/{projectref}/updaterepolinkedaccount", id: "updateProjectRepoLinkedAccount", summary: "update the project repository linked account", tag: "projects", auth: authForced, response: &gwapitypes.ProjectResponse{}},
+	{method: "POST", path: "/projects/{projectref}/createrun", id: "projectCreateRun", summary: "create a project run", tag: "projects", auth: authForced, request: &gwapitypes.ProjectCreateRunRequest{}, status: http.StatusCreated},
+}
+
+// runOperations returns the run operations of the project or user runs
+func runOperations(base, kind string) []*operation {
+	tag := "runs"
+	return []*operation{
+		{method: "GET", path: base + "/runs", id: "get" + kind + "Runs", summary: "get the runs", tag: tag, auth: authForced, response: []*gwapitypes.RunsResponse{},
+			params: []*queryParam{
+				{name: "subgroup", typ: "string", description: "filter runs by subgroup"},
+				{name: "phase", typ: "string", description: "filter runs by phase"},
+				{name: "result", typ: "string", description: "filter runs by result"},
+				{name: "label", typ: "string", description: "filter runs by label (key=value)"},
+				{name: "start", typ: "integer", description: "start from this run number"},
+				{name: "limit", typ: "integer", description: "max number of returned runs"},
+				{name: "asc", typ: "boolean", description: "sort ascending"},
+			}},
+		{method: "GET", path: base + "/runs/{runnumber}", id: "get" + kind + "Run", summary: "get a run", tag: tag, auth: authOptional, response: &gwapitypes.RunResponse{}},
+		{method: "PUT", path: base + "/runs/{runnumber}/actions", id: kind + "RunActions", summary: "execute an action on a run", tag: tag, auth: authForced, request: &gwapitypes.RunActionsRequest{}, response: &gwapitypes.RunResponse{}},
+		{method: "GET", path: base + "/runs/{runnumber}/tasks/{taskid}", id: "get" + kind + "RunTask", summary: "get a run task", tag: tag, auth: authOptional, response: &gwapitypes.RunTaskResponse{}},
+		{method: "PUT", path: base + "/runs/{runnumber}/tasks/{taskid}/actions", id: kind + "RunTaskActions", summary: "execute an action on a run task", tag: tag, auth: authForced, request: &gwapitypes.RunTaskActionsRequest{}},
+		{method: "GET", path: base + "/runs/{runnumber}/tasks/{taskid}/logs", id: "get" + kind + "RunTaskLogs", summary: "get a run task step logs", tag: tag, auth: authOptional, contentType: "text/plain",
+			params: append(logsParams,
+				&queryParam{name: "follow", typ: "boolean", description: "stream the logs until the step finishes"},
+				&queryParam{name: "unit", typ: "string", description: "logs range unit (line or byte)"},
+				&queryParam{name: "offset", typ: "integer", description: "logs range offset"},
+				&queryParam{name: "limit", typ: "integer", description: "logs range limit"},
+			)},
+		{method: "DELETE", path: base + "/runs/{runnumber}/tasks/{taskid}/logs", id: "delete" + kind + "RunTaskLogs", summary: "delete a run task step logs", tag: tag, auth: authForced, params: logsParams, status: http.StatusNoContent},
+		{method: "GET", path: base + "/runs/{runnumber}/tasks/{taskid}/logs/info", id: "get" + kind + "RunTaskLogsInfo", summary: "get a run task step logs info", tag: tag, auth: authOptional, params: logsParams, response: &gwapitypes.LogsInfoResponse{}},
+		{method: "GET", path: base + "/runs/{runnumber}/artifacts", id: "get" + kind + "RunArtifacts", summary: "get the run artifacts", tag: tag, auth: authOptional, response: &gwapitypes.RunArtifactsResponse{}},
+		{method: "GET", path: base + "/runs/{runnumber}/tasks/{taskid}/artifact", id: "get" + kind + "RunTaskArtifact", summary: "download a run task artifact", tag: tag, auth: authOptional, contentType: "application/octet-stream",
+			params: []*queryParam{{name: "path", typ: "string", description: "artifact path"}}},
+		{method: "GET", path: base + "/runs/{runnumber}/testreports", id: "get" + kind + "RunTestReports", summary: "get the run test reports", tag: tag, auth: authOptional, response: &gwapitypes.RunTestReportsResponse{}},
+	}
+}
+
+var secretOperations = []*operation{
+	{method: "GET", path: "/projectgroups/{projectgroupref}/secrets", id: "getProjectGroupSecrets", summary: "get the project group secrets", tag: "secrets", auth: authForced, params: treeParams, response: []*gwapitypes.SecretResponse{}},
+	{method: "GET", path: "/projects/{projectref}/secrets", id: "getProjectSecrets", summary: "get the project secrets", tag: "secrets", auth: authForced, params: treeParams, response: []*gwapitypes.SecretResponse{}},
+	{method: "POST", path: "/projectgroups/{projectgroupref}/secrets", id: "createProjectGroupSecret", summary: "create a project group secret", tag: "secrets", auth: authForced, request: &gwapitypes.CreateSecretRequest{}, response: &gwapitypes.SecretResponse{}, status: http.StatusCreated},
+	{method: "POST", path: "/projects/{projectref}/secrets", id: "createProjectSecret", summary: "create a project secret", tag: "secrets", auth: authForced, request: &gwapitypes.CreateSecretRequest{}, response: &gwapitypes.SecretResponse{}, status: http.StatusCreated},
+	{method: "PUT", path: "/projectgroups/{projectgroupref}/secrets/{secretname}", id: "updateProjectGroupSecret", summary: "update a project group secret", tag: "secrets", auth: authForced, request: &gwapitypes.UpdateSecretRequest{}, response: &gwapitypes.SecretResponse{}},
+	{method: "PUT", path: "/projects/{projectref}/secrets/{secretname}", id: "updateProjectSecret", summary: "update a project secret", tag: "secrets", auth: authForced, request: &gwapitypes.UpdateSecretRequest{}, response: &gwapitypes.SecretResponse{}},
+	{method: "DELETE", path: "/projectgroups/{projectgroupref}/secrets/{secretname}", id: "deleteProjectGroupSecret", summary: "delete a project group secret", tag: "secrets", auth: authForced, status: http.StatusNoContent},
+	{method: "DELETE", path: "/projects/{projectref}/secrets/{secretname}", id: "deleteProjectSecret", summary: "delete a project secret", tag: "secrets", auth: authForced, status: http.StatusNoContent},
+	{method: "GET", path: "/sealedsecrets/publickey", id: "getSealedSecretsPublicKey", summary: "get the public key used to seal secrets", tag: "secrets", auth: authForced, response: &gwapitypes.SealedSecretsPublicKeyResponse{}},
+}
+
+var variableOperations = []*operation{
+	{method: "GET", path: "/projectgroups/{projectgroupref}/variables", id: "getProjectGroupVariables", summary: "get the project group variables", tag: "variables", auth: authForced, params: treeParams, response: []*gwapitypes.VariableResponse{}},
+	{method: "GET", path: "/projects/{projectref}/variables", id: "getProjectVariables", summary: "get the project variables", tag: "variables", auth: authForced, params: treeParams, response: []*gwapitypes.VariableResponse{}},
+	{method: "POST", path: "/projectgroups/{projectgroupref}/variables", id: "createProjectGroupVariable", summary: "create a project group variable", tag: "variables", auth: authForced, request: &gwapitypes.CreateVariableRequest{}, response: &gwapitypes.VariableResponse{}, status: http.StatusCreated},
+	{method: "POST", path: "/projects/{projectref}/variables", id: "createProjectVariable", summary: "create a project variable", tag: "variables", auth: authForced, request: &gwapitypes.CreateVariableRequest{}, response: &gwapitypes.VariableResponse{}, status: http.StatusCreated},
+	{method: "PUT", path: "/projectgroups/{projectgroupref}/variables/{variablename}", id: "updateProjectGroupVariable", summary: "update a project group variable", tag: "variables", auth: authForced, request: &gwapitypes.UpdateVariableRequest{}, response: &gwapitypes.VariableResponse{}},
+	{method: "PUT", path: "/projects/{projectref}/variables/{variablename}", id: "updateProjectVariable", summary: "update a project variable", tag: "variables", auth: authForced, request: &gwapitypes.UpdateVariableRequest{}, response: &gwapitypes.VariableResponse{}},
+	{method: "DELETE", path: "/projectgroups/{projectgroupref}/variables/{variablename}", id: "deleteProjectGroupVariable", summary: "delete a project group variable", tag: "variables", auth: authForced, status: http.StatusNoContent},
+	{method: "DELETE", path: "/projects/{projectref}/variables/{variablename}", id: "deleteProjectVariable", summary: "delete a project variable", tag: "variables", auth: authForced, status: http.StatusNoContent},
+}
+
+var userOperations = []*operation{
+	{method: "GET", path: "/user", id: "getCurrentUser", summary: "get the current user", tag: "users", auth: authForced, response: &gwapitypes.PrivateUserResponse{}},
+	{method: "GET", path: "/users/{userref}", id: "getUser", summary: "get a user", tag: "users", auth: authForced, response: &gwapitypes.UserResponse{}},
+	{method: "GET", path: "/users", id: "getUsers", summary: "get the users", tag: "users", auth: authForced, params: listParams, response: []*gwapitypes.UserResponse{}},
+	{method: "POST", path: "/users", id: "createUser", summary: "create a user", tag: "users", auth: authForced, request: &gwapitypes.CreateUserRequest{}, response: &gwapitypes.UserResponse{}, status: http.StatusCreated},
+	{method: "DELETE", path: "/users/{userref}", id: "deleteUser", summary: "delete a user", tag: "users", auth: authForced, status: http.StatusNoContent},
+	{method: "POST", path: "/user/createrun", id: "userCreateRun", summary: "create a user direct run", tag: "users", auth: authForced, request: &gwapitypes.UserCreateRunRequest{}, status: http.StatusCreated},
+	{method: "GET", path: "/user/orgs", id: "getUserOrgs", summary: "get the current user organizations", tag: "users", auth: authForced, response: []*gwapitypes.UserOrgsResponse{}},
+	{method: "GET", path: "/user/preferences", id: "getUserPreferences", summary: "get the current user preferences", tag: "users", auth: authForced, response: &gwapitypes.UserPreferencesResponse{}},
+	{method: "PUT", path: "/user/preferences", id: "updateUserPreferences", summary: "update the current user preferences", tag: "users", auth: authForced, request: &gwapitypes.UpdateUserPreferencesRequest{}, response: &gwapitypes.UserPreferencesResponse{}},
+	{method: "GET", path: "/user/remoterepos/{remotesourceref}", id: "getUserRemoteRepos", summary: "get the current user remote repositories", tag: "users", auth: authForced, response: []*gwapitypes.RemoteRepoResponse{}},
+
+	{method: "POST", path: "/users/{userref}/linkedaccounts", id: "createUserLinkedAccount", summary: "create a user linked account", tag: "users", auth: authForced, request: &gwapitypes.CreateUserLARequest{}, response: &gwapitypes.CreateUserLAResponse{}, status: http.StatusCreated},
+	{method: "DELETE", path: "/users/{userref}/linkedaccounts/{laid}", id: "deleteUserLinkedAccount", summary: "delete a user linked account", tag: "users", auth: authForced, status: http.StatusNoContent},
+	{method: "GET", path: "/users/{userref}/tokens", id: "getUserTokens", summary: "get the user tokens", tag: "users", auth: authForced, response: []*gwapitypes.UserTokenResponse{}},
+	{method: "POST", path: "/users/{userref}/tokens", id: "createUserToken", summary: "create a user token", tag: "users", auth: authForced, request: &gwapitypes.CreateUserTokenRequest{}, response: &gwapitypes.CreateUserTokenResponse{}, status: http.StatusCreated},
+	{method: "DELETE", path: "/users/{userref}/tokens/{tokenname}", id: "deleteUserToken", summary: "delete a user token", tag: "users", auth: authForced, status: http.StatusNoContent},
+}
+
+var remoteSourceOperations = []*operation{
+	{method: "GET", path: "/remotesources/{remotesourceref}", id: "getRemoteSource", summary: "get a remote source", tag: "remotesources", auth: authForced, response: &gwapitypes.RemoteSourceResponse{}},
+	{method: "POST", path: "/remotesources", id: "createRemoteSource", summary: "create a remote source", tag: "remotesources", auth: authForced, request: &gwapitypes.CreateRemoteSourceRequest{}, response: &gwapitypes.RemoteSourceResponse{}, status: http.StatusCreated},
+	{method: "PUT", path: "/remotesources/{remotesourceref}", id: "updateRemoteSource", summary: "update a remote source", tag: "remotesources", auth: authForced, request: &gwapitypes.UpdateRemoteSourceRequest{}, response: &gwapitypes.RemoteSourceResponse{}, status: http.StatusCreated},
+	{method: "GET", path: "/remotesources", id: "getRemoteSources", summary: "get the remote sources", tag: "remotesources", auth: authOptional, params: listParams, response: []*gwapitypes.RemoteSourceResponse{}},
+	{method: "DELETE", path: "/remotesources/{remotesourceref}", id: "deleteRemoteSource", summary: "delete a remote source", tag: "remotesources", auth: authForced, status: http.StatusNoContent},
+	{method: "GET", path: "/remotesources/{remotesourceref}/ratelimit", id: "getRemoteSourceRateLimit", summary: "get the remote source api rate limit", tag: "remotesources", auth: authForced, response: &gwapitypes.RemoteSourceRateLimitResponse{}},
+}
+
+var orgOperations = []*operation{
+	{method: "GET", path: "/orgs/{orgref}", id: "getOrg", summary: "get an organization", tag: "orgs", auth: authForced, response: &gwapitypes.OrgResponse{}},
+	{method: "GET", path: "/orgs", id: "getOrgs", summary: "get the organizations", tag: "orgs", auth: authForced, params: listParams, response: []*gwapitypes.OrgResponse{}},
+	{method: "POST", path: "/orgs", id: "createOrg", summary: "create an organization", tag: "orgs", auth: authForced, request: &gwapitypes.CreateOrgRequest{}, response: &gwapitypes.OrgResponse{}, status: http.StatusCreated},
+	{method: "PUT", path: "/orgs/{orgref}", id: "updateOrg", summary: "update an organization", tag: "orgs", auth: authForced, request: &gwapitypes.UpdateOrgRequest{}, response: &gwapitypes.OrgResponse{}},
+	{method: "DELETE", path: "/orgs/{orgref}", id: "deleteOrg", summary: "delete an organization", tag: "orgs", auth: authForced, status: http.StatusNoContent},
+	{method: "GET", path: "/orgs/{orgref}/members", id: "getOrgMembers", summary: "get the organization members", tag: "orgs", auth: authForced, response: &gwapitypes.OrgMembersResponse{}},
+	{method: "PUT", path: "/orgs/{orgref}/members/{userref}", id: "addOrgMember", summary: "add or update an organization member", tag: "orgs", auth: authForced, request: &gwapitypes.AddOrgMemberRequest{}, response: &gwapitypes.AddOrgMemberResponse{}},
+	{method: "DELETE", path: "/orgs/{orgref}/members/{userref}", id: "removeOrgMember", summary: "remove an organization member", tag: "orgs", auth: authForced, status: http.StatusNoContent},
+	{method: "GET", path: "/orgs/{orgref}/usage", id: "getOrgUsage", summary: "get the organization resources usage", tag: "orgs", auth: authForced, response: &gwapitypes.OrgUsageResponse{},
+		params: []*queryParam{
+			{name: "start", typ: "string", description: "usage period start (RFC3339)"},
+			{name: "end", typ: "string", description: "usage period end (RFC3339)"},
+			{name: "format", typ: "string", description: "response format (json or csv)"},
+		}},
+}
+
+var miscOperations = []*operation{
+	{method: "GET", path: "/executors", id: "getExecutors", summary: "get the executors", tag: "executors", auth: authForced, response: []*gwapitypes.ExecutorResponse{}},
+	{method: "PUT", path: "/executors/{executorid}/actions", id: "executorActions", summary: "execute an action on an executor", tag: "executors", auth: authForced, request: &gwapitypes.ExecutorActionsRequest{}},
+
+	{method: "GET", path: "/announcements", id: "getAnnouncements", summary: "get the announcements", tag: "announcements", auth: authOptional, response: []*gwapitypes.AnnouncementResponse{},
+		params: []*queryParam{{name: "all", typ: "boolean", description: "also return the expired announcements"}}},
+	{method: "POST", path: "/announcements", id: "createAnnouncement", summary: "create an announcement", tag: "announcements", auth: authForced, request: &gwapitypes.CreateAnnouncementRequest{}, response: &gwapitypes.AnnouncementResponse{}, status: http.StatusCreated},
+	{method: "PUT", path: "/announcements/{announcementid}/expire", id: "expireAnnouncement", summary: "expire an announcement", tag: "announcements", auth: authForced, response: &gwapitypes.AnnouncementResponse{}},
+
+	{method: "GET", path: "/badges/{projectref}", id: "getBadge", summary: "get the project status badge", tag: "projects", contentType: "image/svg+xml",
+		params: []*queryParam{{name: "branch", typ: "string", description: "branch name"}}},
+
+	{method: "GET", path: "/version", id: "getVersion", summary: "get the gateway version", tag: "misc", response: &gwapitypes.VersionResponse{}},
+	{method: "GET", path: "/openapi.json", id: "getOpenAPISpec", summary: "get the gateway OpenAPI specification", tag: "misc", contentType: "application/json"},
+	{method: "GET", path: "/swaggerui", id: "getSwaggerUI", summary: "get the Swagger UI (when enabled)", tag: "misc", contentType: "text/html"},
+
+	{method: "POST", path: "/auth/login", id: "login", summary: "login a user", tag: "auth", request: &gwapitypes.LoginUserRequest{}, response: &gwapitypes.LoginUserResponse{}, status: http.StatusCreated},
+	{method: "POST", path: "/auth/authorize", id: "authorize", summary: "authorize a user with a remote source", tag: "auth", request: &gwapitypes.LoginUserRequest{}, response: &gwapitypes.AuthorizeResponse{}, status: http.StatusCreated},
+	{method: "POST", path: "/auth/register", id: "register", summary: "register a user", tag: "auth", request: &gwapitypes.RegisterUserRequest{}, response: &gwapitypes.RegisterUserResponse{}, status: http.StatusCreated},
+	{method: "GET", path: "/auth/oauth2/callback", id: "oauth2Callback", summary: "remote source oauth2 callback", tag: "auth", response: &gwapitypes.RemoteSourceAuthResult{},
+		params: []*queryParam{
+			{name: "code", typ: "string", description: "oauth2 code"},
+			{name: "state", typ: "string", description: "oauth2 state"},
+		}},
+}
@@ -0,0 +1,7806 @@
+// Code generated by go generate; DO NOT EDIT.
+package openapi
+
+// Spec is the gateway api OpenAPI specification in json format
+const Spec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Agola Gateway API",
+    "version": "v1alpha"
+  },
+  "servers": [
+    {
+      "url": "/api/v1alpha"
+    }
+  ],
+  "paths": {
+    "/announcements": {
+      "get": {
+        "operationId": "getAnnouncements",
+        "summary": "get the announcements",
+        "tags": [
+          "announcements"
+        ],
+        "parameters": [
+          {
+            "name": "all",
+            "in": "query",
+            "description": "also return the expired announcements",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/AnnouncementResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      },
+      "post": {
+        "operationId": "createAnnouncement",
+        "summary": "create an announcement",
+        "tags": [
+          "announcements"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateAnnouncementRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AnnouncementResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/announcements/{announcementid}/expire": {
+      "put": {
+        "operationId": "expireAnnouncement",
+        "summary": "expire an announcement",
+        "tags": [
+          "announcements"
+        ],
+        "parameters": [
+          {
+            "name": "announcementid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AnnouncementResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/auth/authorize": {
+      "post": {
+        "operationId": "authorize",
+        "summary": "authorize a user with a remote source",
+        "tags": [
+          "auth"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/LoginUserRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AuthorizeResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/auth/login": {
+      "post": {
+        "operationId": "login",
+        "summary": "login a user",
+        "tags": [
+          "auth"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/LoginUserRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/LoginUserResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/auth/oauth2/callback": {
+      "get": {
+        "operationId": "oauth2Callback",
+        "summary": "remote source oauth2 callback",
+        "tags": [
+          "auth"
+        ],
+        "parameters": [
+          {
+            "name": "code",
+            "in": "query",
+            "description": "oauth2 code",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "state",
+            "in": "query",
+            "description": "oauth2 state",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RemoteSourceAuthResult"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/auth/register": {
+      "post": {
+        "operationId": "register",
+        "summary": "register a user",
+        "tags": [
+          "auth"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/RegisterUserRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RegisterUserResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/badges/{projectref}": {
+      "get": {
+        "operationId": "getBadge",
+        "summary": "get the project status badge",
+        "tags": [
+          "projects"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "branch",
+            "in": "query",
+            "description": "branch name",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "image/svg+xml": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/executors": {
+      "get": {
+        "operationId": "getExecutors",
+        "summary": "get the executors",
+        "tags": [
+          "executors"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/ExecutorResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/executors/{executorid}/actions": {
+      "put": {
+        "operationId": "executorActions",
+        "summary": "execute an action on an executor",
+        "tags": [
+          "executors"
+        ],
+        "parameters": [
+          {
+            "name": "executorid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/ExecutorActionsRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "operationId": "getOpenAPISpec",
+        "summary": "get the gateway OpenAPI specification",
+        "tags": [
+          "misc"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/orgs": {
+      "get": {
+        "operationId": "getOrgs",
+        "summary": "get the organizations",
+        "tags": [
+          "orgs"
+        ],
+        "parameters": [
+          {
+            "name": "start",
+            "in": "query",
+            "description": "start listing after this name",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "description": "max number of returned items",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "asc",
+            "in": "query",
+            "description": "sort ascending",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/OrgResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "createOrg",
+        "summary": "create an organization",
+        "tags": [
+          "orgs"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateOrgRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OrgResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/orgs/{orgref}": {
+      "delete": {
+        "operationId": "deleteOrg",
+        "summary": "delete an organization",
+        "tags": [
+          "orgs"
+        ],
+        "parameters": [
+          {
+            "name": "orgref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "getOrg",
+        "summary": "get an organization",
+        "tags": [
+          "orgs"
+        ],
+        "parameters": [
+          {
+            "name": "orgref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OrgResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "updateOrg",
+        "summary": "update an organization",
+        "tags": [
+          "orgs"
+        ],
+        "parameters": [
+          {
+            "name": "orgref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/UpdateOrgRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OrgResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/orgs/{orgref}/members": {
+      "get": {
+        "operationId": "getOrgMembers",
+        "summary": "get the organization members",
+        "tags": [
+          "orgs"
+        ],
+        "parameters": [
+          {
+            "name": "orgref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OrgMembersResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/orgs/{orgref}/members/{userref}": {
+      "delete": {
+        "operationId": "removeOrgMember",
+        "summary": "remove an organization member",
+        "tags": [
+          "orgs"
+        ],
+        "parameters": [
+          {
+            "name": "orgref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "addOrgMember",
+        "summary": "add or update an organization member",
+        "tags": [
+          "orgs"
+        ],
+        "parameters": [
+          {
+            "name": "orgref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/AddOrgMemberRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AddOrgMemberResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/orgs/{orgref}/usage": {
+      "get": {
+        "operationId": "getOrgUsage",
+        "summary": "get the organization resources usage",
+        "tags": [
+          "orgs"
+        ],
+        "parameters": [
+          {
+            "name": "orgref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "start",
+            "in": "query",
+            "description": "usage period start (RFC3339)",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "end",
+            "in": "query",
+            "description": "usage period end (RFC3339)",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "format",
+            "in": "query",
+            "description": "response format (json or csv)",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/OrgUsageResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projectgroups": {
+      "post": {
+        "operationId": "createProjectGroup",
+        "summary": "create a project group",
+        "tags": [
+          "projectgroups"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateProjectGroupRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProjectGroupResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projectgroups/{projectgroupref}": {
+      "delete": {
+        "operationId": "deleteProjectGroup",
+        "summary": "delete a project group",
+        "tags": [
+          "projectgroups"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "getProjectGroup",
+        "summary": "get a project group",
+        "tags": [
+          "projectgroups"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProjectGroupResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "updateProjectGroup",
+        "summary": "update a project group",
+        "tags": [
+          "projectgroups"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/UpdateProjectGroupRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProjectGroupResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projectgroups/{projectgroupref}/importprojects": {
+      "post": {
+        "operationId": "importProjects",
+        "summary": "import the repositories of a remote source organization as projects",
+        "tags": [
+          "projectgroups"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/ImportProjectsRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/ImportProjectResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projectgroups/{projectgroupref}/projects": {
+      "get": {
+        "operationId": "getProjectGroupProjects",
+        "summary": "get the project group projects",
+        "tags": [
+          "projectgroups"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "tag",
+            "in": "query",
+            "description": "filter projects by tag",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/ProjectResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projectgroups/{projectgroupref}/secrets": {
+      "get": {
+        "operationId": "getProjectGroupSecrets",
+        "summary": "get the project group secrets",
+        "tags": [
+          "secrets"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "tree",
+            "in": "query",
+            "description": "also return the items of the parent project groups",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "removeoverridden",
+            "in": "query",
+            "description": "remove the items overridden by a child",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/SecretResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "createProjectGroupSecret",
+        "summary": "create a project group secret",
+        "tags": [
+          "secrets"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateSecretRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SecretResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projectgroups/{projectgroupref}/secrets/{secretname}": {
+      "delete": {
+        "operationId": "deleteProjectGroupSecret",
+        "summary": "delete a project group secret",
+        "tags": [
+          "secrets"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "secretname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "updateProjectGroupSecret",
+        "summary": "update a project group secret",
+        "tags": [
+          "secrets"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "secretname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/UpdateSecretRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SecretResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projectgroups/{projectgroupref}/subgroups": {
+      "get": {
+        "operationId": "getProjectGroupSubgroups",
+        "summary": "get the project group subgroups",
+        "tags": [
+          "projectgroups"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/ProjectGroupResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projectgroups/{projectgroupref}/variables": {
+      "get": {
+        "operationId": "getProjectGroupVariables",
+        "summary": "get the project group variables",
+        "tags": [
+          "variables"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "tree",
+            "in": "query",
+            "description": "also return the items of the parent project groups",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "removeoverridden",
+            "in": "query",
+            "description": "remove the items overridden by a child",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/VariableResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "createProjectGroupVariable",
+        "summary": "create a project group variable",
+        "tags": [
+          "variables"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateVariableRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/VariableResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projectgroups/{projectgroupref}/variables/{variablename}": {
+      "delete": {
+        "operationId": "deleteProjectGroupVariable",
+        "summary": "delete a project group variable",
+        "tags": [
+          "variables"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "variablename",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "updateProjectGroupVariable",
+        "summary": "update a project group variable",
+        "tags": [
+          "variables"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "variablename",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/UpdateVariableRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/VariableResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projectgroups/{projectgroupref}/variablesreport": {
+      "get": {
+        "operationId": "getProjectGroupVariablesReport",
+        "summary": "get the variables of all the project group projects",
+        "tags": [
+          "variables"
+        ],
+        "parameters": [
+          {
+            "name": "projectgroupref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/ProjectVariablesReportResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects": {
+      "post": {
+        "operationId": "createProject",
+        "summary": "create a project",
+        "tags": [
+          "projects"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateProjectRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProjectResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}": {
+      "delete": {
+        "operationId": "deleteProject",
+        "summary": "delete a project, it can be restored until the deletion grace period expires",
+        "tags": [
+          "projects"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "getProject",
+        "summary": "get a project",
+        "tags": [
+          "projects"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProjectResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      },
+      "put": {
+        "operationId": "updateProject",
+        "summary": "update a project",
+        "tags": [
+          "projects"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/UpdateProjectRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProjectResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/caches": {
+      "get": {
+        "operationId": "getProjectCaches",
+        "summary": "get the project caches",
+        "tags": [
+          "projects"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProjectCachesResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/clone": {
+      "post": {
+        "operationId": "cloneProject",
+        "summary": "clone a project",
+        "tags": [
+          "projects"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CloneProjectRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProjectResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/createrun": {
+      "post": {
+        "operationId": "projectCreateRun",
+        "summary": "create a project run",
+        "tags": [
+          "projects"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/ProjectCreateRunRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/reconfig": {
+      "put": {
+        "operationId": "reconfigProject",
+        "summary": "reconfigure the project remote repository",
+        "tags": [
+          "projects"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/runs": {
+      "get": {
+        "operationId": "getProjectRuns",
+        "summary": "get the runs",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "subgroup",
+            "in": "query",
+            "description": "filter runs by subgroup",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "phase",
+            "in": "query",
+            "description": "filter runs by phase",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "result",
+            "in": "query",
+            "description": "filter runs by result",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "label",
+            "in": "query",
+            "description": "filter runs by label (key=value)",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "start",
+            "in": "query",
+            "description": "start from this run number",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "description": "max number of returned runs",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "asc",
+            "in": "query",
+            "description": "sort ascending",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/RunsResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/runs/{runnumber}": {
+      "get": {
+        "operationId": "getProjectRun",
+        "summary": "get a run",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RunResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/projects/{projectref}/runs/{runnumber}/actions": {
+      "put": {
+        "operationId": "ProjectRunActions",
+        "summary": "execute an action on a run",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/RunActionsRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RunResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/runs/{runnumber}/artifacts": {
+      "get": {
+        "operationId": "getProjectRunArtifacts",
+        "summary": "get the run artifacts",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RunArtifactsResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/projects/{projectref}/runs/{runnumber}/tasks/{taskid}": {
+      "get": {
+        "operationId": "getProjectRunTask",
+        "summary": "get a run task",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RunTaskResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/projects/{projectref}/runs/{runnumber}/tasks/{taskid}/actions": {
+      "put": {
+        "operationId": "ProjectRunTaskActions",
+        "summary": "execute an action on a run task",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/RunTaskActionsRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/runs/{runnumber}/tasks/{taskid}/artifact": {
+      "get": {
+        "operationId": "getProjectRunTaskArtifact",
+        "summary": "download a run task artifact",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "path",
+            "in": "query",
+            "description": "artifact path",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/octet-stream": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/projects/{projectref}/runs/{runnumber}/tasks/{taskid}/logs": {
+      "delete": {
+        "operationId": "deleteProjectRunTaskLogs",
+        "summary": "delete a run task step logs",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "setup",
+            "in": "query",
+            "description": "setup step logs",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "step",
+            "in": "query",
+            "description": "step number",
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "getProjectRunTaskLogs",
+        "summary": "get a run task step logs",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "setup",
+            "in": "query",
+            "description": "setup step logs",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "step",
+            "in": "query",
+            "description": "step number",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "follow",
+            "in": "query",
+            "description": "stream the logs until the step finishes",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "unit",
+            "in": "query",
+            "description": "logs range unit (line or byte)",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "offset",
+            "in": "query",
+            "description": "logs range offset",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "description": "logs range limit",
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/projects/{projectref}/runs/{runnumber}/tasks/{taskid}/logs/info": {
+      "get": {
+        "operationId": "getProjectRunTaskLogsInfo",
+        "summary": "get a run task step logs info",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "setup",
+            "in": "query",
+            "description": "setup step logs",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "step",
+            "in": "query",
+            "description": "step number",
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/LogsInfoResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/projects/{projectref}/runs/{runnumber}/testreports": {
+      "get": {
+        "operationId": "getProjectRunTestReports",
+        "summary": "get the run test reports",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RunTestReportsResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/projects/{projectref}/secrets": {
+      "get": {
+        "operationId": "getProjectSecrets",
+        "summary": "get the project secrets",
+        "tags": [
+          "secrets"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "tree",
+            "in": "query",
+            "description": "also return the items of the parent project groups",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "removeoverridden",
+            "in": "query",
+            "description": "remove the items overridden by a child",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/SecretResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "createProjectSecret",
+        "summary": "create a project secret",
+        "tags": [
+          "secrets"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateSecretRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SecretResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/secrets/{secretname}": {
+      "delete": {
+        "operationId": "deleteProjectSecret",
+        "summary": "delete a project secret",
+        "tags": [
+          "secrets"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "secretname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "updateProjectSecret",
+        "summary": "update a project secret",
+        "tags": [
+          "secrets"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "secretname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/UpdateSecretRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SecretResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/stats/metrics/{metric}": {
+      "get": {
+        "operationId": "getProjectMetric",
+        "summary": "get the values of a metric emitted by the project runs",
+        "tags": [
+          "projects"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "metric",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "branch",
+            "in": "query",
+            "description": "filter runs by branch",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "start",
+            "in": "query",
+            "description": "start from this run number",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "description": "max number of returned values",
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/MetricValueResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/projects/{projectref}/undelete": {
+      "put": {
+        "operationId": "undeleteProject",
+        "summary": "restore a deleted project (admin only)",
+        "tags": [
+          "projects"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProjectResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/updaterepolinkedaccount": {
+      "put": {
+        "operationId": "updateProjectRepoLinkedAccount",
+        "summary": "update the project repository linked account",
+        "tags": [
+          "projects"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ProjectResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/variables": {
+      "get": {
+        "operationId": "getProjectVariables",
+        "summary": "get the project variables",
+        "tags": [
+          "variables"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "tree",
+            "in": "query",
+            "description": "also return the items of the parent project groups",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "removeoverridden",
+            "in": "query",
+            "description": "remove the items overridden by a child",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/VariableResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "createProjectVariable",
+        "summary": "create a project variable",
+        "tags": [
+          "variables"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateVariableRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/VariableResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/projects/{projectref}/variables/{variablename}": {
+      "delete": {
+        "operationId": "deleteProjectVariable",
+        "summary": "delete a project variable",
+        "tags": [
+          "variables"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "variablename",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "updateProjectVariable",
+        "summary": "update a project variable",
+        "tags": [
+          "variables"
+        ],
+        "parameters": [
+          {
+            "name": "projectref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "variablename",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/UpdateVariableRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/VariableResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/remotesources": {
+      "get": {
+        "operationId": "getRemoteSources",
+        "summary": "get the remote sources",
+        "tags": [
+          "remotesources"
+        ],
+        "parameters": [
+          {
+            "name": "start",
+            "in": "query",
+            "description": "start listing after this name",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "description": "max number of returned items",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "asc",
+            "in": "query",
+            "description": "sort ascending",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/RemoteSourceResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      },
+      "post": {
+        "operationId": "createRemoteSource",
+        "summary": "create a remote source",
+        "tags": [
+          "remotesources"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateRemoteSourceRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RemoteSourceResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/remotesources/{remotesourceref}": {
+      "delete": {
+        "operationId": "deleteRemoteSource",
+        "summary": "delete a remote source",
+        "tags": [
+          "remotesources"
+        ],
+        "parameters": [
+          {
+            "name": "remotesourceref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "getRemoteSource",
+        "summary": "get a remote source",
+        "tags": [
+          "remotesources"
+        ],
+        "parameters": [
+          {
+            "name": "remotesourceref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RemoteSourceResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "updateRemoteSource",
+        "summary": "update a remote source",
+        "tags": [
+          "remotesources"
+        ],
+        "parameters": [
+          {
+            "name": "remotesourceref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/UpdateRemoteSourceRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RemoteSourceResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/remotesources/{remotesourceref}/ratelimit": {
+      "get": {
+        "operationId": "getRemoteSourceRateLimit",
+        "summary": "get the remote source api rate limit",
+        "tags": [
+          "remotesources"
+        ],
+        "parameters": [
+          {
+            "name": "remotesourceref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RemoteSourceRateLimitResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/sealedsecrets/publickey": {
+      "get": {
+        "operationId": "getSealedSecretsPublicKey",
+        "summary": "get the public key used to seal secrets",
+        "tags": [
+          "secrets"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/SealedSecretsPublicKeyResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/swaggerui": {
+      "get": {
+        "operationId": "getSwaggerUI",
+        "summary": "get the Swagger UI (when enabled)",
+        "tags": [
+          "misc"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "text/html": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/user": {
+      "get": {
+        "operationId": "getCurrentUser",
+        "summary": "get the current user",
+        "tags": [
+          "users"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/PrivateUserResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/user/createrun": {
+      "post": {
+        "operationId": "userCreateRun",
+        "summary": "create a user direct run",
+        "tags": [
+          "users"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/UserCreateRunRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/user/orgs": {
+      "get": {
+        "operationId": "getUserOrgs",
+        "summary": "get the current user organizations",
+        "tags": [
+          "users"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/UserOrgsResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/user/preferences": {
+      "get": {
+        "operationId": "getUserPreferences",
+        "summary": "get the current user preferences",
+        "tags": [
+          "users"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/UserPreferencesResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "put": {
+        "operationId": "updateUserPreferences",
+        "summary": "update the current user preferences",
+        "tags": [
+          "users"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/UpdateUserPreferencesRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/UserPreferencesResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/user/remoterepos/{remotesourceref}": {
+      "get": {
+        "operationId": "getUserRemoteRepos",
+        "summary": "get the current user remote repositories",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "remotesourceref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/RemoteRepoResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users": {
+      "get": {
+        "operationId": "getUsers",
+        "summary": "get the users",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "start",
+            "in": "query",
+            "description": "start listing after this name",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "description": "max number of returned items",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "asc",
+            "in": "query",
+            "description": "sort ascending",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/UserResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "createUser",
+        "summary": "create a user",
+        "tags": [
+          "users"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateUserRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/UserResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/{userref}": {
+      "delete": {
+        "operationId": "deleteUser",
+        "summary": "delete a user",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "getUser",
+        "summary": "get a user",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/UserResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/{userref}/linkedaccounts": {
+      "post": {
+        "operationId": "createUserLinkedAccount",
+        "summary": "create a user linked account",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateUserLARequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/CreateUserLAResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/{userref}/linkedaccounts/{laid}": {
+      "delete": {
+        "operationId": "deleteUserLinkedAccount",
+        "summary": "delete a user linked account",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "laid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/{userref}/runs": {
+      "get": {
+        "operationId": "getUserRuns",
+        "summary": "get the runs",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "subgroup",
+            "in": "query",
+            "description": "filter runs by subgroup",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "phase",
+            "in": "query",
+            "description": "filter runs by phase",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "result",
+            "in": "query",
+            "description": "filter runs by result",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "label",
+            "in": "query",
+            "description": "filter runs by label (key=value)",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "start",
+            "in": "query",
+            "description": "start from this run number",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "description": "max number of returned runs",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "asc",
+            "in": "query",
+            "description": "sort ascending",
+            "schema": {
+              "type": "boolean"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/RunsResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/{userref}/runs/{runnumber}": {
+      "get": {
+        "operationId": "getUserRun",
+        "summary": "get a run",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RunResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/users/{userref}/runs/{runnumber}/actions": {
+      "put": {
+        "operationId": "UserRunActions",
+        "summary": "execute an action on a run",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/RunActionsRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RunResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/{userref}/runs/{runnumber}/artifacts": {
+      "get": {
+        "operationId": "getUserRunArtifacts",
+        "summary": "get the run artifacts",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RunArtifactsResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/users/{userref}/runs/{runnumber}/tasks/{taskid}": {
+      "get": {
+        "operationId": "getUserRunTask",
+        "summary": "get a run task",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RunTaskResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/users/{userref}/runs/{runnumber}/tasks/{taskid}/actions": {
+      "put": {
+        "operationId": "UserRunTaskActions",
+        "summary": "execute an action on a run task",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/RunTaskActionsRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/{userref}/runs/{runnumber}/tasks/{taskid}/artifact": {
+      "get": {
+        "operationId": "getUserRunTaskArtifact",
+        "summary": "download a run task artifact",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "path",
+            "in": "query",
+            "description": "artifact path",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/octet-stream": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/users/{userref}/runs/{runnumber}/tasks/{taskid}/logs": {
+      "delete": {
+        "operationId": "deleteUserRunTaskLogs",
+        "summary": "delete a run task step logs",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "setup",
+            "in": "query",
+            "description": "setup step logs",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "step",
+            "in": "query",
+            "description": "step number",
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "get": {
+        "operationId": "getUserRunTaskLogs",
+        "summary": "get a run task step logs",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "setup",
+            "in": "query",
+            "description": "setup step logs",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "step",
+            "in": "query",
+            "description": "step number",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "follow",
+            "in": "query",
+            "description": "stream the logs until the step finishes",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "unit",
+            "in": "query",
+            "description": "logs range unit (line or byte)",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "offset",
+            "in": "query",
+            "description": "logs range offset",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "description": "logs range limit",
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/users/{userref}/runs/{runnumber}/tasks/{taskid}/logs/info": {
+      "get": {
+        "operationId": "getUserRunTaskLogsInfo",
+        "summary": "get a run task step logs info",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "taskid",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "setup",
+            "in": "query",
+            "description": "setup step logs",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "name": "step",
+            "in": "query",
+            "description": "step number",
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/LogsInfoResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/users/{userref}/runs/{runnumber}/testreports": {
+      "get": {
+        "operationId": "getUserRunTestReports",
+        "summary": "get the run test reports",
+        "tags": [
+          "runs"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "runnumber",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/RunTestReportsResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          },
+          {}
+        ]
+      }
+    },
+    "/users/{userref}/tokens": {
+      "get": {
+        "operationId": "getUserTokens",
+        "summary": "get the user tokens",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/UserTokenResponse"
+                  }
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      },
+      "post": {
+        "operationId": "createUserToken",
+        "summary": "create a user token",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateUserTokenRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {
+            "description": "Created",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/CreateUserTokenResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/users/{userref}/tokens/{tokenname}": {
+      "delete": {
+        "operationId": "deleteUserToken",
+        "summary": "delete a user token",
+        "tags": [
+          "users"
+        ],
+        "parameters": [
+          {
+            "name": "userref",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "tokenname",
+            "in": "path",
+            "required": true,
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "204": {
+            "description": "No Content"
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        },
+        "security": [
+          {
+            "token": []
+          },
+          {
+            "bearer": []
+          }
+        ]
+      }
+    },
+    "/version": {
+      "get": {
+        "operationId": "getVersion",
+        "summary": "get the gateway version",
+        "tags": [
+          "misc"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/VersionResponse"
+                }
+              }
+            }
+          },
+          "default": {
+            "description": "error",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ErrorResponse"
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "AddOrgMemberRequest": {
+        "type": "object",
+        "properties": {
+          "role": {
+            "type": "string"
+          }
+        }
+      },
+      "AddOrgMemberResponse": {
+        "type": "object",
+        "properties": {
+          "organization": {
+            "$ref": "#/components/schemas/OrgResponse"
+          },
+          "role": {
+            "type": "string"
+          },
+          "user": {
+            "$ref": "#/components/schemas/UserResponse"
+          }
+        }
+      },
+      "AnnouncementResponse": {
+        "type": "object",
+        "properties": {
+          "creation_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "expire_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "id": {
+            "type": "string"
+          },
+          "message": {
+            "type": "string"
+          },
+          "severity": {
+            "type": "string"
+          }
+        }
+      },
+      "AuthorizeResponse": {
+        "type": "object",
+        "properties": {
+          "oauth2_redirect": {
+            "type": "string"
+          },
+          "remote_source_name": {
+            "type": "string"
+          },
+          "remote_user_info": {
+            "$ref": "#/components/schemas/UserInfo"
+          }
+        }
+      },
+      "CloneProjectRequest": {
+        "type": "object",
+        "properties": {
+          "clone_secrets": {
+            "type": "boolean"
+          },
+          "clone_secrets_data": {
+            "type": "boolean"
+          },
+          "name": {
+            "type": "string"
+          },
+          "parent_ref": {
+            "type": "string"
+          },
+          "remote_source_name": {
+            "type": "string"
+          },
+          "repo_path": {
+            "type": "string"
+          }
+        }
+      },
+      "ConcurrencyLimits": {
+        "type": "object",
+        "properties": {
+          "max_running_runs": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "max_running_tasks": {
+            "type": "integer",
+            "format": "int32"
+          }
+        }
+      },
+      "CreateAnnouncementRequest": {
+        "type": "object",
+        "properties": {
+          "expire_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "message": {
+            "type": "string"
+          },
+          "severity": {
+            "type": "string"
+          }
+        }
+      },
+      "CreateOrgRequest": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "visibility": {
+            "type": "string"
+          }
+        }
+      },
+      "CreateProjectGroupRequest": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "parent_ref": {
+            "type": "string"
+          },
+          "visibility": {
+            "type": "string"
+          }
+        }
+      },
+      "CreateProjectRequest": {
+        "type": "object",
+        "properties": {
+          "allowed_upstream_projects": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "cancel_superseded_runs": {
+            "type": "boolean"
+          },
+          "concurrency_limits": {
+            "$ref": "#/components/schemas/ConcurrencyLimits"
+          },
+          "import_repo_topics": {
+            "type": "boolean"
+          },
+          "logs_visibility": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "parent_ref": {
+            "type": "string"
+          },
+          "pass_vars_to_forked_pr": {
+            "type": "boolean"
+          },
+          "post_pull_request_comments": {
+            "type": "boolean"
+          },
+          "protected_branches": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "protected_tags": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "remote_source_name": {
+            "type": "string"
+          },
+          "repo_path": {
+            "type": "string"
+          },
+          "report_skipped_runs": {
+            "type": "boolean"
+          },
+          "runs_visibility": {
+            "type": "string"
+          },
+          "schedules": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ProjectSchedule"
+            }
+          },
+          "skip_ssh_host_key_check": {
+            "type": "boolean"
+          },
+          "tags": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "use_deps_proxy": {
+            "type": "boolean"
+          },
+          "visibility": {
+            "type": "string"
+          }
+        }
+      },
+      "CreateRemoteSourceRequest": {
+        "type": "object",
+        "properties": {
+          "apiurl": {
+            "type": "string"
+          },
+          "auth_type": {
+            "type": "string"
+          },
+          "login_enabled": {
+            "type": "boolean"
+          },
+          "name": {
+            "type": "string"
+          },
+          "oauth_2_client_id": {
+            "type": "string"
+          },
+          "oauth_2_client_secret": {
+            "type": "string"
+          },
+          "org_webhooks": {
+            "type": "boolean"
+          },
+          "registration_enabled": {
+            "type": "boolean"
+          },
+          "skip_ssh_host_key_check": {
+            "type": "boolean"
+          },
+          "skip_verify": {
+            "type": "boolean"
+          },
+          "ssh_endpoint": {
+            "type": "string"
+          },
+          "ssh_host_key": {
+            "type": "string"
+          },
+          "type": {
+            "type": "string"
+          }
+        }
+      },
+      "CreateSecretRequest": {
+        "type": "object",
+        "properties": {
+          "data": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "name": {
+            "type": "string"
+          },
+          "path": {
+            "type": "string"
+          },
+          "sealed": {
+            "type": "boolean"
+          },
+          "secret_provider_id": {
+            "type": "string"
+          },
+          "type": {
+            "type": "string"
+          }
+        }
+      },
+      "CreateUserLARequest": {
+        "type": "object",
+        "properties": {
+          "remote_source_login_name": {
+            "type": "string"
+          },
+          "remote_source_login_password": {
+            "type": "string"
+          },
+          "remote_source_name": {
+            "type": "string"
+          }
+        }
+      },
+      "CreateUserLAResponse": {
+        "type": "object",
+        "properties": {
+          "linked_account": {
+            "$ref": "#/components/schemas/LinkedAccount"
+          },
+          "oauth2_redirect": {
+            "type": "string"
+          }
+        }
+      },
+      "CreateUserRequest": {
+        "type": "object",
+        "properties": {
+          "username": {
+            "type": "string"
+          }
+        }
+      },
+      "CreateUserTokenRequest": {
+        "type": "object",
+        "properties": {
+          "token_name": {
+            "type": "string"
+          }
+        }
+      },
+      "CreateUserTokenResponse": {
+        "type": "object",
+        "properties": {
+          "token": {
+            "type": "string"
+          }
+        }
+      },
+      "CreateVariableRequest": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "values": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/VariableValueRequest"
+            }
+          }
+        }
+      },
+      "ErrorResponse": {
+        "type": "object",
+        "properties": {
+          "code": {
+            "type": "string"
+          },
+          "message": {
+            "type": "string"
+          }
+        }
+      },
+      "ExecutorActionsRequest": {
+        "type": "object",
+        "properties": {
+          "action_type": {
+            "type": "string"
+          }
+        }
+      },
+      "ExecutorHealth": {
+        "type": "object",
+        "properties": {
+          "cpus": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "disk_free": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "disk_total": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "images_size": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "load1": {
+            "type": "number",
+            "format": "double"
+          }
+        }
+      },
+      "ExecutorResponse": {
+        "type": "object",
+        "properties": {
+          "active_tasks": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "active_tasks_limit": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "archs": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "draining": {
+            "type": "boolean"
+          },
+          "executor_id": {
+            "type": "string"
+          },
+          "health": {
+            "$ref": "#/components/schemas/ExecutorHealth"
+          },
+          "health_problems": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "labels": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "last_update_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "listen_url": {
+            "type": "string"
+          },
+          "state": {
+            "type": "string"
+          }
+        }
+      },
+      "ImportProjectResponse": {
+        "type": "object",
+        "properties": {
+          "error": {
+            "type": "string"
+          },
+          "project_id": {
+            "type": "string"
+          },
+          "project_name": {
+            "type": "string"
+          },
+          "repo_path": {
+            "type": "string"
+          },
+          "status": {
+            "type": "string"
+          }
+        }
+      },
+      "ImportProjectsRequest": {
+        "type": "object",
+        "properties": {
+          "concurrency": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "dry_run": {
+            "type": "boolean"
+          },
+          "exclude": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "import_repo_topics": {
+            "type": "boolean"
+          },
+          "include": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "remote_org": {
+            "type": "string"
+          },
+          "remote_source_name": {
+            "type": "string"
+          },
+          "skip_ssh_host_key_check": {
+            "type": "boolean"
+          },
+          "visibility": {
+            "type": "string"
+          }
+        }
+      },
+      "LinkedAccount": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "remote_source_id": {
+            "type": "string"
+          },
+          "remote_user_avatar_url": {
+            "type": "string"
+          },
+          "remote_user_display_name": {
+            "type": "string"
+          },
+          "remote_user_id": {
+            "type": "string"
+          },
+          "remote_username": {
+            "type": "string"
+          }
+        }
+      },
+      "LinkedAccountResponse": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "remote_source_id": {
+            "type": "string"
+          },
+          "remote_user_avatar_url": {
+            "type": "string"
+          },
+          "remote_user_display_name": {
+            "type": "string"
+          },
+          "remote_user_name": {
+            "type": "string"
+          }
+        }
+      },
+      "LoginUserRequest": {
+        "type": "object",
+        "properties": {
+          "login_name": {
+            "type": "string"
+          },
+          "password": {
+            "type": "string"
+          },
+          "remote_source_name": {
+            "type": "string"
+          }
+        }
+      },
+      "LoginUserResponse": {
+        "type": "object",
+        "properties": {
+          "oauth2_redirect": {
+            "type": "string"
+          },
+          "token": {
+            "type": "string"
+          },
+          "user": {
+            "$ref": "#/components/schemas/UserResponse"
+          }
+        }
+      },
+      "LogsInfoResponse": {
+        "type": "object",
+        "properties": {
+          "complete": {
+            "type": "boolean"
+          },
+          "lines": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "size": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "MetricValueResponse": {
+        "type": "object",
+        "properties": {
+          "branch": {
+            "type": "string"
+          },
+          "result": {
+            "type": "string"
+          },
+          "run_number": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "value": {
+            "type": "number",
+            "format": "double"
+          }
+        }
+      },
+      "OrgMemberResponse": {
+        "type": "object",
+        "properties": {
+          "role": {
+            "type": "string"
+          },
+          "user": {
+            "$ref": "#/components/schemas/UserResponse"
+          }
+        }
+      },
+      "OrgMembersResponse": {
+        "type": "object",
+        "properties": {
+          "members": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/OrgMemberResponse"
+            }
+          },
+          "organization": {
+            "$ref": "#/components/schemas/OrgResponse"
+          }
+        }
+      },
+      "OrgResponse": {
+        "type": "object",
+        "properties": {
+          "concurrency_limits": {
+            "$ref": "#/components/schemas/ConcurrencyLimits"
+          },
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "visibility": {
+            "type": "string"
+          }
+        }
+      },
+      "OrgUsageResponse": {
+        "type": "object",
+        "properties": {
+          "end_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "organization": {
+            "$ref": "#/components/schemas/OrgResponse"
+          },
+          "projects": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ProjectUsageResponse"
+            }
+          },
+          "start_time": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "PrivateUserResponse": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "linked_accounts": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/LinkedAccountResponse"
+            }
+          },
+          "tokens": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "username": {
+            "type": "string"
+          }
+        }
+      },
+      "ProjectCacheResponse": {
+        "type": "object",
+        "properties": {
+          "key": {
+            "type": "string"
+          },
+          "last_used": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "size": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "ProjectCachesResponse": {
+        "type": "object",
+        "properties": {
+          "caches": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ProjectCacheResponse"
+            }
+          },
+          "quota": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "size": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "ProjectCreateRunRequest": {
+        "type": "object",
+        "properties": {
+          "branch": {
+            "type": "string"
+          },
+          "commit_sha": {
+            "type": "string"
+          },
+          "inputs": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "ref": {
+            "type": "string"
+          },
+          "tag": {
+            "type": "string"
+          },
+          "variables": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "ProjectGroupResponse": {
+        "type": "object",
+        "properties": {
+          "global_visibility": {
+            "type": "string"
+          },
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "parent_path": {
+            "type": "string"
+          },
+          "path": {
+            "type": "string"
+          },
+          "visibility": {
+            "type": "string"
+          }
+        }
+      },
+      "ProjectResponse": {
+        "type": "object",
+        "properties": {
+          "allowed_upstream_projects": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "cancel_superseded_runs": {
+            "type": "boolean"
+          },
+          "concurrency_limits": {
+            "$ref": "#/components/schemas/ConcurrencyLimits"
+          },
+          "global_visibility": {
+            "type": "string"
+          },
+          "id": {
+            "type": "string"
+          },
+          "logs_visibility": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "parent_path": {
+            "type": "string"
+          },
+          "pass_vars_to_forked_pr": {
+            "type": "boolean"
+          },
+          "path": {
+            "type": "string"
+          },
+          "post_pull_request_comments": {
+            "type": "boolean"
+          },
+          "protected_branches": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "protected_tags": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "report_skipped_runs": {
+            "type": "boolean"
+          },
+          "runs_visibility": {
+            "type": "string"
+          },
+          "schedules": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ProjectSchedule"
+            }
+          },
+          "tags": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "use_deps_proxy": {
+            "type": "boolean"
+          },
+          "visibility": {
+            "type": "string"
+          }
+        }
+      },
+      "ProjectSchedule": {
+        "type": "object",
+        "properties": {
+          "branch": {
+            "type": "string"
+          },
+          "cron": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          }
+        }
+      },
+      "ProjectUsageResponse": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "path": {
+            "type": "string"
+          },
+          "runs": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "task_minutes": {
+            "type": "number",
+            "format": "double"
+          }
+        }
+      },
+      "ProjectVariablesReportResponse": {
+        "type": "object",
+        "properties": {
+          "project_id": {
+            "type": "string"
+          },
+          "project_path": {
+            "type": "string"
+          },
+          "secrets": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/SecretResponse"
+            }
+          },
+          "variables": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/VariableResponse"
+            }
+          }
+        }
+      },
+      "RegisterUserRequest": {
+        "type": "object",
+        "properties": {
+          "remote_source_login_name": {
+            "type": "string"
+          },
+          "remote_source_login_password": {
+            "type": "string"
+          },
+          "remote_source_name": {
+            "type": "string"
+          },
+          "username": {
+            "type": "string"
+          }
+        }
+      },
+      "RegisterUserResponse": {
+        "type": "object",
+        "properties": {
+          "oauth2_redirect": {
+            "type": "string"
+          }
+        }
+      },
+      "RemoteRepoResponse": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "path": {
+            "type": "string"
+          }
+        }
+      },
+      "RemoteSourceAuthResult": {
+        "type": "object",
+        "properties": {
+          "request_type": {
+            "type": "string"
+          },
+          "response": {}
+        }
+      },
+      "RemoteSourceRateLimitResponse": {
+        "type": "object",
+        "properties": {
+          "active_requests": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "limit": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "rate_limited_requests": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "remaining": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "reset": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "RemoteSourceResponse": {
+        "type": "object",
+        "properties": {
+          "auth_type": {
+            "type": "string"
+          },
+          "id": {
+            "type": "string"
+          },
+          "login_enabled": {
+            "type": "boolean"
+          },
+          "name": {
+            "type": "string"
+          },
+          "org_webhooks": {
+            "type": "boolean"
+          },
+          "registration_enabled": {
+            "type": "boolean"
+          },
+          "ssh_endpoint": {
+            "type": "string"
+          }
+        }
+      },
+      "RunActionsRequest": {
+        "type": "object",
+        "properties": {
+          "action_type": {
+            "type": "string"
+          },
+          "config_ref": {
+            "type": "string"
+          },
+          "from_start": {
+            "type": "boolean"
+          }
+        }
+      },
+      "RunArtifactResponse": {
+        "type": "object",
+        "properties": {
+          "path": {
+            "type": "string"
+          },
+          "size": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "task_id": {
+            "type": "string"
+          },
+          "task_name": {
+            "type": "string"
+          }
+        }
+      },
+      "RunArtifactsResponse": {
+        "type": "object",
+        "properties": {
+          "artifacts": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/RunArtifactResponse"
+            }
+          }
+        }
+      },
+      "RunConfigTaskDepend": {
+        "type": "object",
+        "properties": {
+          "conditions": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "task_id": {
+            "type": "string"
+          }
+        }
+      },
+      "RunResponse": {
+        "type": "object",
+        "properties": {
+          "annotations": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "can_restart_from_failed_tasks": {
+            "type": "boolean"
+          },
+          "can_restart_from_scratch": {
+            "type": "boolean"
+          },
+          "end_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "enqueue_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "labels": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "meta": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "metrics": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "number",
+              "format": "double"
+            }
+          },
+          "name": {
+            "type": "string"
+          },
+          "number": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "phase": {
+            "type": "string"
+          },
+          "result": {
+            "type": "string"
+          },
+          "setup_errors": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "start_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "stopping": {
+            "type": "boolean"
+          },
+          "tasks": {
+            "type": "object",
+            "additionalProperties": {
+              "$ref": "#/components/schemas/RunResponseTask"
+            }
+          },
+          "tasks_waiting_approval": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "RunResponseTask": {
+        "type": "object",
+        "properties": {
+          "allow_failure": {
+            "type": "boolean"
+          },
+          "approval_annotations": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "approved": {
+            "type": "boolean"
+          },
+          "attempt": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "depends": {
+            "type": "object",
+            "additionalProperties": {
+              "$ref": "#/components/schemas/RunConfigTaskDepend"
+            }
+          },
+          "end_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "fail_error": {
+            "type": "string"
+          },
+          "id": {
+            "type": "string"
+          },
+          "labels": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "level": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "name": {
+            "type": "string"
+          },
+          "start_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "status": {
+            "type": "string"
+          },
+          "waiting_approval": {
+            "type": "boolean"
+          }
+        }
+      },
+      "RunTaskActionsRequest": {
+        "type": "object",
+        "properties": {
+          "action_type": {
+            "type": "string"
+          },
+          "comment": {
+            "type": "string"
+          }
+        }
+      },
+      "RunTaskApprovalResponse": {
+        "type": "object",
+        "properties": {
+          "comment": {
+            "type": "string"
+          },
+          "time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "user_id": {
+            "type": "string"
+          },
+          "user_name": {
+            "type": "string"
+          }
+        }
+      },
+      "RunTaskResponse": {
+        "type": "object",
+        "properties": {
+          "approval_annotations": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "approval_org_roles": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "approval_users": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "approvals": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/RunTaskApprovalResponse"
+            }
+          },
+          "approved": {
+            "type": "boolean"
+          },
+          "attempt": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "end_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "fail_error": {
+            "type": "string"
+          },
+          "id": {
+            "type": "string"
+          },
+          "labels": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "name": {
+            "type": "string"
+          },
+          "required_approvals": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "resource_usage": {
+            "$ref": "#/components/schemas/RunTaskResponseResourceUsage"
+          },
+          "setup_step": {
+            "$ref": "#/components/schemas/RunTaskResponseSetupStep"
+          },
+          "start_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "status": {
+            "type": "string"
+          },
+          "steps": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/RunTaskResponseStep"
+            }
+          },
+          "waiting_approval": {
+            "type": "boolean"
+          }
+        }
+      },
+      "RunTaskResponseResourceUsage": {
+        "type": "object",
+        "properties": {
+          "cpu_time": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "disk": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "max_cpu": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "max_memory": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "memory": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "memory_limit": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "RunTaskResponseSetupStep": {
+        "type": "object",
+        "properties": {
+          "end_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "name": {
+            "type": "string"
+          },
+          "phase": {
+            "type": "string"
+          },
+          "start_time": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "RunTaskResponseStep": {
+        "type": "object",
+        "properties": {
+          "command": {
+            "type": "string"
+          },
+          "end_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "exit_status": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "log_archived": {
+            "type": "boolean"
+          },
+          "name": {
+            "type": "string"
+          },
+          "phase": {
+            "type": "string"
+          },
+          "shell": {
+            "type": "string"
+          },
+          "start_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "transfer": {
+            "$ref": "#/components/schemas/RunTaskResponseStepTransfer"
+          },
+          "type": {
+            "type": "string"
+          }
+        }
+      },
+      "RunTaskResponseStepTransfer": {
+        "type": "object",
+        "properties": {
+          "bytes": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "duration": {
+            "type": "integer",
+            "format": "int64"
+          }
+        }
+      },
+      "RunTestReportResponse": {
+        "type": "object",
+        "properties": {
+          "duration": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "errors": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "failed_tests": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/TestCaseFailureResponse"
+            }
+          },
+          "failures": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "passed": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "skipped": {
+            "type": "integer",
+            "format": "int32"
+          },
+          "task_id": {
+            "type": "string"
+          },
+          "task_name": {
+            "type": "string"
+          },
+          "tests": {
+            "type": "integer",
+            "format": "int32"
+          }
+        }
+      },
+      "RunTestReportsResponse": {
+        "type": "object",
+        "properties": {
+          "test_reports": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/RunTestReportResponse"
+            }
+          }
+        }
+      },
+      "RunsResponse": {
+        "type": "object",
+        "properties": {
+          "annotations": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "end_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "enqueue_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "labels": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "meta": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "metrics": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "number",
+              "format": "double"
+            }
+          },
+          "name": {
+            "type": "string"
+          },
+          "number": {
+            "type": "integer",
+            "format": "int64"
+          },
+          "phase": {
+            "type": "string"
+          },
+          "result": {
+            "type": "string"
+          },
+          "start_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "tasks_waiting_approval": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "SealedSecretsPublicKeyResponse": {
+        "type": "object",
+        "properties": {
+          "public_key": {
+            "type": "string"
+          }
+        }
+      },
+      "SecretResponse": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "parent_path": {
+            "type": "string"
+          },
+          "sealed": {
+            "type": "boolean"
+          }
+        }
+      },
+      "TestCaseFailureResponse": {
+        "type": "object",
+        "properties": {
+          "class_name": {
+            "type": "string"
+          },
+          "error": {
+            "type": "boolean"
+          },
+          "message": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "suite": {
+            "type": "string"
+          }
+        }
+      },
+      "UpdateOrgRequest": {
+        "type": "object",
+        "properties": {
+          "concurrency_limits": {
+            "$ref": "#/components/schemas/ConcurrencyLimits"
+          }
+        }
+      },
+      "UpdateProjectGroupRequest": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "parent_ref": {
+            "type": "string"
+          },
+          "visibility": {
+            "type": "string"
+          }
+        }
+      },
+      "UpdateProjectRequest": {
+        "type": "object",
+        "properties": {
+          "allowed_upstream_projects": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "cancel_superseded_runs": {
+            "type": "boolean"
+          },
+          "concurrency_limits": {
+            "$ref": "#/components/schemas/ConcurrencyLimits"
+          },
+          "import_repo_topics": {
+            "type": "boolean"
+          },
+          "logs_visibility": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "parent_ref": {
+            "type": "string"
+          },
+          "pass_vars_to_forked_pr": {
+            "type": "boolean"
+          },
+          "post_pull_request_comments": {
+            "type": "boolean"
+          },
+          "protected_branches": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "protected_tags": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "report_skipped_runs": {
+            "type": "boolean"
+          },
+          "runs_visibility": {
+            "type": "string"
+          },
+          "schedules": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ProjectSchedule"
+            }
+          },
+          "tags": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "use_deps_proxy": {
+            "type": "boolean"
+          },
+          "visibility": {
+            "type": "string"
+          }
+        }
+      },
+      "UpdateRemoteSourceRequest": {
+        "type": "object",
+        "properties": {
+          "apiurl": {
+            "type": "string"
+          },
+          "login_enabled": {
+            "type": "boolean"
+          },
+          "name": {
+            "type": "string"
+          },
+          "oauth_2_client_id": {
+            "type": "string"
+          },
+          "oauth_2_client_secret": {
+            "type": "string"
+          },
+          "org_webhooks": {
+            "type": "boolean"
+          },
+          "registration_enabled": {
+            "type": "boolean"
+          },
+          "skip_ssh_host_key_check": {
+            "type": "boolean"
+          },
+          "skip_verify": {
+            "type": "boolean"
+          },
+          "ssh_endpoint": {
+            "type": "string"
+          },
+          "ssh_host_key": {
+            "type": "string"
+          }
+        }
+      },
+      "UpdateSecretRequest": {
+        "type": "object",
+        "properties": {
+          "data": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          },
+          "name": {
+            "type": "string"
+          },
+          "path": {
+            "type": "string"
+          },
+          "sealed": {
+            "type": "boolean"
+          },
+          "secret_provider_id": {
+            "type": "string"
+          },
+          "type": {
+            "type": "string"
+          }
+        }
+      },
+      "UpdateUserPreferencesRequest": {
+        "type": "object",
+        "properties": {
+          "date_format": {
+            "type": "string"
+          },
+          "default_org_ref": {
+            "type": "string"
+          },
+          "default_project_ref": {
+            "type": "string"
+          },
+          "timezone": {
+            "type": "string"
+          },
+          "ui_settings": {}
+        }
+      },
+      "UpdateVariableRequest": {
+        "type": "object",
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "values": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/VariableValueRequest"
+            }
+          }
+        }
+      },
+      "UserCreateRunRequest": {
+        "type": "object",
+        "properties": {
+          "branch": {
+            "type": "string"
+          },
+          "changed_files": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "commit_sha": {
+            "type": "string"
+          },
+          "message": {
+            "type": "string"
+          },
+          "pull_request_ref_regexes": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "ref": {
+            "type": "string"
+          },
+          "repo_path": {
+            "type": "string"
+          },
+          "repo_uuid": {
+            "type": "string"
+          },
+          "run_names": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "tag": {
+            "type": "string"
+          },
+          "task_names": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "variables": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "UserInfo": {
+        "type": "object",
+        "properties": {
+          "Email": {
+            "type": "string"
+          },
+          "ID": {
+            "type": "string"
+          },
+          "LoginName": {
+            "type": "string"
+          }
+        }
+      },
+      "UserOrgsResponse": {
+        "type": "object",
+        "properties": {
+          "Organization": {
+            "$ref": "#/components/schemas/OrgResponse"
+          },
+          "Role": {
+            "type": "string"
+          }
+        }
+      },
+      "UserPreferencesResponse": {
+        "type": "object",
+        "properties": {
+          "date_format": {
+            "type": "string"
+          },
+          "default_org_id": {
+            "type": "string"
+          },
+          "default_project_id": {
+            "type": "string"
+          },
+          "timezone": {
+            "type": "string"
+          },
+          "ui_settings": {}
+        }
+      },
+      "UserResponse": {
+        "type": "object",
+        "properties": {
+          "avatar_url": {
+            "type": "string"
+          },
+          "display_name": {
+            "type": "string"
+          },
+          "id": {
+            "type": "string"
+          },
+          "username": {
+            "type": "string"
+          }
+        }
+      },
+      "UserTokenResponse": {
+        "type": "object",
+        "properties": {
+          "creation_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "last_used_time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "name": {
+            "type": "string"
+          }
+        }
+      },
+      "VariableResponse": {
+        "type": "object",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "parent_path": {
+            "type": "string"
+          },
+          "values": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/VariableValue"
+            }
+          }
+        }
+      },
+      "VariableValue": {
+        "type": "object",
+        "properties": {
+          "matching_secret_parent_path": {
+            "type": "string"
+          },
+          "protected": {
+            "type": "boolean"
+          },
+          "secret_name": {
+            "type": "string"
+          },
+          "secret_var": {
+            "type": "string"
+          },
+          "when": {
+            "$ref": "#/components/schemas/When"
+          }
+        }
+      },
+      "VariableValueRequest": {
+        "type": "object",
+        "properties": {
+          "protected": {
+            "type": "boolean"
+          },
+          "secret_name": {
+            "type": "string"
+          },
+          "secret_var": {
+            "type": "string"
+          },
+          "when": {
+            "$ref": "#/components/schemas/When"
+          }
+        }
+      },
+      "VersionResponse": {
+        "type": "object",
+        "properties": {
+          "service": {
+            "type": "string"
+          },
+          "version": {
+            "type": "string"
+          }
+        }
+      },
+      "When": {
+        "type": "object",
+        "properties": {
+          "branch": {
+            "$ref": "#/components/schemas/WhenConditions"
+          },
+          "expression": {
+            "type": "string"
+          },
+          "message": {
+            "$ref": "#/components/schemas/WhenConditions"
+          },
+          "paths": {
+            "$ref": "#/components/schemas/WhenConditions"
+          },
+          "ref": {
+            "$ref": "#/components/schemas/WhenConditions"
+          },
+          "schedule": {
+            "$ref": "#/components/schemas/WhenConditions"
+          },
+          "tag": {
+            "$ref": "#/components/schemas/WhenConditions"
+          }
+        }
+      },
+      "WhenCondition": {
+        "type": "object",
+        "properties": {
+          "match": {
+            "type": "string"
+          },
+          "type": {
+            "type": "string"
+          }
+        }
+      },
+      "WhenConditions": {
+        "type": "object",
+        "properties": {
+          "exclude": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/WhenCondition"
+            }
+          },
+          "include": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/WhenCondition"
+            }
+          }
+        }
+      }
+    },
+    "securitySchemes": {
+      "bearer": {
+        "type": "http",
+        "scheme": "bearer"
+      },
+      "token": {
+        "type": "apiKey",
+        "description": "user token provided as \"token \u003ctoken\u003e\"",
+        "in": "header",
+        "name": "Authorization"
+      }
+    }
+  }
+}`
@@ -93,10 +93,12 @@ func TestRepoCleaner(t *testing.T) {
 
 			gitDataDir := filepath.Join(dir, "gitserver")
 
-			config := &config.Gitserver{
-				DataDir:                      gitDataDir,
-				RepositoryCleanupInterval:    10 * time.Second,
-				RepositoryRefsExpireInterval: 24 * time.Hour,
+			config := &config.Config{
+				Gitserver: config.Gitserver{
+					DataDir:                      gitDataDir,
+					RepositoryCleanupInterval:    10 * time.Second,
+					RepositoryRefsExpireInterval: 24 * time.Hour,
+				},
 			}
 
 			gs, err := NewGitserver(ctx, log, config)
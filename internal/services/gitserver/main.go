@@ -25,6 +25,7 @@ import (
 
 	"agola.io/agola/internal/errors"
 	handlers "agola.io/agola/internal/git-handler"
+	"agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/config"
 	"agola.io/agola/internal/util"
 
@@ -122,18 +123,27 @@ func Matcher(matchRegexp *regexp.Regexp) mux.MatcherFunc {
 }
 
 type Gitserver struct {
-	log zerolog.Logger
-	c   *config.Gitserver
+	log         zerolog.Logger
+	c           *config.Gitserver
+	serviceAuth *common.ServiceAuth
 }
 
-func NewGitserver(ctx context.Context, log zerolog.Logger, c *config.Gitserver) (*Gitserver, error) {
+func NewGitserver(ctx context.Context, log zerolog.Logger, gc *config.Config) (*Gitserver, error) {
+	c := &gc.Gitserver
+
 	if c.Debug {
 		log = log.Level(zerolog.DebugLevel)
 	}
 
+	serviceAuth, err := common.NewServiceAuth(&gc.InternalServicesAuth, common.ServiceGitserver)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	return &Gitserver{
-		log: log,
-		c:   c,
+		log:         log,
+		c:           c,
+		serviceAuth: serviceAuth,
 	}, nil
 }
 
@@ -157,19 +167,20 @@ func (s *Gitserver) Run(ctx context.Context) error {
 		}
 	}
 
+	// only the gateway, that authorizes the users requests, can access the
+	// repositories
+	serviceAuthorizations := common.ServiceAuthorizations{
+		common.ServiceGateway: nil,
+	}
+
 	httpServer := http.Server{
-		Addr:      s.c.Web.ListenAddress,
-		Handler:   router,
+		Handler:   s.serviceAuth.NewServiceAuthHandler(s.log, serviceAuthorizations, router),
 		TLSConfig: tlsConfig,
 	}
 
 	lerrCh := make(chan error)
 	go func() {
-		if !s.c.Web.TLS {
-			lerrCh <- httpServer.ListenAndServe()
-		} else {
-			lerrCh <- httpServer.ListenAndServeTLS("", "")
-		}
+		lerrCh <- util.ListenAndServe(&httpServer, s.c.Web.Addresses(), s.c.Web.TLS)
 	}()
 
 	//TODO a lock is needed or it'll cause some concurrency issues if repo cleaner runs when someone at the same time is pushing
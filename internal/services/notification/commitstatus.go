@@ -18,17 +18,23 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 
 	"agola.io/agola/internal/errors"
 	gitsource "agola.io/agola/internal/gitsources"
 	"agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/gateway/action"
+	csapitypes "agola.io/agola/services/configstore/api/types"
 	cstypes "agola.io/agola/services/configstore/types"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
 	rstypes "agola.io/agola/services/runservice/types"
 )
 
-func (n *NotificationService) updateCommitStatus(ctx context.Context, ev *rstypes.RunEvent) error {
+// commitStatusFromRunEvent returns the commit status of a run event or an
+// empty commit status if the event doesn't change it
+func commitStatusFromRunEvent(ev *rstypes.RunEvent) gitsource.CommitStatus {
 	var commitStatus gitsource.CommitStatus
 	if ev.Phase == rstypes.RunPhaseSetupError {
 		commitStatus = gitsource.CommitStatusError
@@ -36,6 +42,9 @@ func (n *NotificationService) updateCommitStatus(ctx context.Context, ev *rstype
 	if ev.Phase == rstypes.RunPhaseCancelled {
 		commitStatus = gitsource.CommitStatusError
 	}
+	if ev.Phase == rstypes.RunPhaseQueued {
+		commitStatus = gitsource.CommitStatusQueued
+	}
 	if ev.Phase == rstypes.RunPhaseRunning && ev.Result == rstypes.RunResultUnknown {
 		commitStatus = gitsource.CommitStatusPending
 	}
@@ -50,6 +59,11 @@ func (n *NotificationService) updateCommitStatus(ctx context.Context, ev *rstype
 		}
 	}
 
+	return commitStatus
+}
+
+func (n *NotificationService) updateCommitStatus(ctx context.Context, ev *rstypes.RunEvent) error {
+	commitStatus := commitStatusFromRunEvent(ev)
 	if commitStatus == "" {
 		return nil
 	}
@@ -73,14 +87,172 @@ func (n *NotificationService) updateCommitStatus(ctx context.Context, ev *rstype
 		return errors.Wrapf(err, "failed to get project %s", groupID)
 	}
 
+	gitSource, err := n.projectGitSource(ctx, project)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	targetURL, err := webRunURL(n.c.WebExposedURL, project.ID, run.Run.Counter)
+	if err != nil {
+		return errors.Wrapf(err, "failed to generate commit status target url")
+	}
+	description := runStatusDescription(run.Run.Counter, commitStatus)
+	context := fmt.Sprintf("%s/%s/%s", n.gc.ID, project.Name, run.RunConfig.Name)
+
+	if err := n.updateApprovalCommitStatus(ev, run, project, gitSource, context); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// report the run using a check run when supported by the git source,
+	// fallback to a commit status on errors (i.e. github check runs can only
+	// be created when authenticated as a github app)
+	if checkRunSource, ok := gitSource.(gitsource.CheckRunSource); ok {
+		checkRun := genCheckRun(run, commitStatus, context, targetURL, n.testReportsSummary(ctx, run, commitStatus))
+		err := checkRunSource.CreateOrUpdateCheckRun(project.RepositoryPath, run.Run.Annotations[action.AnnotationCommitSHA], checkRun)
+		if err == nil {
+			return nil
+		}
+		n.log.Warn().Err(err).Msgf("failed to create check run for run %q, falling back to commit status", run.Run.ID)
+	}
+
+	if err := gitSource.CreateCommitStatus(project.RepositoryPath, run.Run.Annotations[action.AnnotationCommitSHA], commitStatus, targetURL, description, context); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// approvalCommitStatus returns the status of the run approvals or an empty
+// commit status if the run doesn't require approvals or its approval status
+// isn't changed. The returned task id is the first task waiting approval.
+func approvalCommitStatus(ev *rstypes.RunEvent, run *rsapitypes.RunResponse) (gitsource.CommitStatus, string) {
+	var approvalTasks []*rstypes.RunTask
+	for _, rt := range sortedRunTasks(run) {
+		if !run.RunConfig.Tasks[rt.ID].NeedsApproval || rt.Skip || rt.Status == rstypes.RunTaskStatusSkipped {
+			continue
+		}
+		approvalTasks = append(approvalTasks, rt)
+	}
+	if len(approvalTasks) == 0 {
+		return "", ""
+	}
+
+	if ev.WaitingApproval {
+		// use the run current state to get the tasks waiting approval
+		for _, rt := range approvalTasks {
+			if rt.WaitingApproval {
+				return gitsource.CommitStatusPending, rt.ID
+			}
+		}
+		return gitsource.CommitStatusPending, ""
+	}
+
+	for _, rt := range approvalTasks {
+		if rt.Approved {
+			return gitsource.CommitStatusSuccess, ""
+		}
+	}
+
+	// the run ended (i.e. it was stopped) without approvals
+	if ev.Phase == rstypes.RunPhaseFinished || ev.Phase == rstypes.RunPhaseCancelled {
+		return gitsource.CommitStatusError, ""
+	}
+
+	return "", ""
+}
+
+// updateApprovalCommitStatus sets a distinct commit status reporting that the
+// run requires an approval with a link to the task to approve. The commit
+// status is updated when the run is approved or ends without approvals.
+func (n *NotificationService) updateApprovalCommitStatus(ev *rstypes.RunEvent, run *rsapitypes.RunResponse, project *csapitypes.Project, gitSource gitsource.GitSource, context string) error {
+	commitStatus, taskID := approvalCommitStatus(ev, run)
+	if commitStatus == "" {
+		return nil
+	}
+
+	var targetURL string
+	var err error
+	if taskID != "" {
+		targetURL, err = webRunTaskURL(n.c.WebExposedURL, project.ID, run.Run.Counter, taskID)
+	} else {
+		targetURL, err = webRunURL(n.c.WebExposedURL, project.ID, run.Run.Counter)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to generate approval commit status target url")
+	}
+
+	var description string
+	switch commitStatus {
+	case gitsource.CommitStatusPending:
+		description = "Action required: the run is waiting for approval"
+	case gitsource.CommitStatusSuccess:
+		description = "The run was approved"
+	case gitsource.CommitStatusError:
+		description = "The run ended without approval"
+	}
+	description = fmt.Sprintf("Run #%d: %s", run.Run.Counter, description)
+
+	if err := gitSource.CreateCommitStatus(project.RepositoryPath, run.Run.Annotations[action.AnnotationCommitSHA], commitStatus, targetURL, description, context+"/approval"); err != nil {
+		return errors.Wrapf(err, "failed to update approval commit status")
+	}
+
+	return nil
+}
+
+// genCheckRun generates a check run for the provided run with a summary of the
+// tasks statuses, the optional tests summary and an annotation for every not
+// successful task
+func genCheckRun(run *rsapitypes.RunResponse, commitStatus gitsource.CommitStatus, name, detailsURL, testsSummary string) *gitsource.CheckRun {
+	var text strings.Builder
+	text.WriteString("| Task | Status |\n| --- | --- |\n")
+	annotations := []*gitsource.CheckRunAnnotation{}
+	for _, rt := range sortedRunTasks(run) {
+		taskName := run.RunConfig.Tasks[rt.ID].Name
+		fmt.Fprintf(&text, "| %s | %s |\n", taskName, runTaskStatus(rt))
+
+		var level gitsource.CheckRunAnnotationLevel
+		switch rt.Status {
+		case rstypes.RunTaskStatusFailed:
+			level = gitsource.CheckRunAnnotationLevelFailure
+		case rstypes.RunTaskStatusStopped, rstypes.RunTaskStatusCancelled:
+			level = gitsource.CheckRunAnnotationLevelWarning
+		default:
+			continue
+		}
+		annotations = append(annotations, &gitsource.CheckRunAnnotation{
+			Path:    ".agola",
+			Level:   level,
+			Title:   fmt.Sprintf("Task %s %s", taskName, rt.Status),
+			Message: fmt.Sprintf("Task %q %s. See %s for details", taskName, rt.Status, detailsURL),
+		})
+	}
+	if testsSummary != "" {
+		fmt.Fprintf(&text, "\n%s", testsSummary)
+	}
+
+	return &gitsource.CheckRun{
+		Name:        name,
+		ExternalID:  run.Run.ID,
+		Status:      commitStatus,
+		DetailsURL:  detailsURL,
+		Title:       runStatusDescription(run.Run.Counter, commitStatus),
+		Summary:     fmt.Sprintf("[Run #%d](%s): %s", run.Run.Counter, detailsURL, statusDescription(commitStatus)),
+		Text:        text.String(),
+		Annotations: annotations,
+	}
+}
+
+// projectGitSource returns the git source client of the project linked
+// account
+func (n *NotificationService) projectGitSource(ctx context.Context, project *csapitypes.Project) (gitsource.GitSource, error) {
 	user, _, err := n.configstoreClient.GetUserByLinkedAccount(ctx, project.LinkedAccountID)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get user by linked account %q", project.LinkedAccountID)
+		return nil, errors.Wrapf(err, "failed to get user by linked account %q", project.LinkedAccountID)
 	}
 
 	linkedAccounts, _, err := n.configstoreClient.GetUserLinkedAccounts(ctx, user.ID)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get user %q linked accounts", user.Name)
+		return nil, errors.Wrapf(err, "failed to get user %q linked accounts", user.Name)
 	}
 
 	var la *cstypes.LinkedAccount
@@ -91,31 +263,40 @@ func (n *NotificationService) updateCommitStatus(ctx context.Context, ev *rstype
 		}
 	}
 	if la == nil {
-		return errors.Errorf("linked account %q for user %q doesn't exist", project.LinkedAccountID, user.Name)
+		return nil, errors.Errorf("linked account %q for user %q doesn't exist", project.LinkedAccountID, user.Name)
 	}
 	rs, _, err := n.configstoreClient.GetRemoteSource(ctx, la.RemoteSourceID)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get remote source %q", la.RemoteSourceID)
+		return nil, errors.Wrapf(err, "failed to get remote source %q", la.RemoteSourceID)
 	}
 
 	// TODO(sgotti) handle refreshing oauth2 tokens
 	gitSource, err := common.GetGitSource(rs, la)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create gitea client")
+		return nil, errors.Wrapf(err, "failed to create gitea client")
 	}
 
-	targetURL, err := webRunURL(n.c.WebExposedURL, project.ID, run.Run.Counter)
-	if err != nil {
-		return errors.Wrapf(err, "failed to generate commit status target url")
-	}
-	description := statusDescription(commitStatus)
-	context := fmt.Sprintf("%s/%s/%s", n.gc.ID, project.Name, run.RunConfig.Name)
+	return gitSource, nil
+}
 
-	if err := gitSource.CreateCommitStatus(project.RepositoryPath, run.Run.Annotations[action.AnnotationCommitSHA], commitStatus, targetURL, description, context); err != nil {
-		return errors.WithStack(err)
+// sortedRunTasks returns the run tasks sorted by name
+func sortedRunTasks(run *rsapitypes.RunResponse) []*rstypes.RunTask {
+	tasks := make([]*rstypes.RunTask, 0, len(run.Run.Tasks))
+	for _, rt := range run.Run.Tasks {
+		tasks = append(tasks, rt)
 	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return run.RunConfig.Tasks[tasks[i].ID].Name < run.RunConfig.Tasks[tasks[j].ID].Name
+	})
 
-	return nil
+	return tasks
+}
+
+func runTaskStatus(rt *rstypes.RunTask) string {
+	if rt.WaitingApproval {
+		return "waiting approval"
+	}
+	return string(rt.Status)
 }
 
 func webRunURL(webExposedURL, projectID string, runNumber uint64) (string, error) {
@@ -132,8 +313,32 @@ func webRunURL(webExposedURL, projectID string, runNumber uint64) (string, error
 	return u.String(), nil
 }
 
+func webRunTaskURL(webExposedURL, projectID string, runNumber uint64, taskID string) (string, error) {
+	u, err := url.Parse(webExposedURL + "/run")
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	q := url.Values{}
+	q.Set("projectref", projectID)
+	q.Set("runnumber", strconv.FormatUint(runNumber, 10))
+	q.Set("taskid", taskID)
+
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// runStatusDescription returns the commit status description of a run
+// including its number so the status can be related to the run also without
+// following the target url
+func runStatusDescription(runNumber uint64, commitStatus gitsource.CommitStatus) string {
+	return fmt.Sprintf("Run #%d: %s", runNumber, statusDescription(commitStatus))
+}
+
 func statusDescription(commitStatus gitsource.CommitStatus) string {
 	switch commitStatus {
+	case gitsource.CommitStatusQueued:
+		return "The run is queued"
 	case gitsource.CommitStatusPending:
 		return "The run is pending"
 	case gitsource.CommitStatusSuccess:
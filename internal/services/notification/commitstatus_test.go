@@ -0,0 +1,416 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notification
+
+import (
+	"testing"
+
+	gitsource "agola.io/agola/internal/gitsources"
+	"agola.io/agola/internal/services/config"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	cstypes "agola.io/agola/services/configstore/types"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+	rstypes "agola.io/agola/services/runservice/types"
+	stypes "agola.io/agola/services/types"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenCheckRun(t *testing.T) {
+	detailsURL := "https://agola.example.com/run?projectref=project01&runnumber=3"
+
+	tests := []struct {
+		name         string
+		run          func() *rsapitypes.RunResponse
+		commitStatus gitsource.CommitStatus
+		testsSummary string
+		out          *gitsource.CheckRun
+	}{
+		{
+			name:         "failed run",
+			run:          func() *rsapitypes.RunResponse { return testPullRequestRun(3) },
+			commitStatus: gitsource.CommitStatusFailed,
+			out: &gitsource.CheckRun{
+				Name:       "agola/project01/run01",
+				ExternalID: "run3",
+				Status:     gitsource.CommitStatusFailed,
+				DetailsURL: detailsURL,
+				Title:      "Run #3: The run failed",
+				Summary:    "[Run #3](" + detailsURL + "): The run failed",
+				Text:       "| Task | Status |\n| --- | --- |\n| build | success |\n| test | failed |\n",
+				Annotations: []*gitsource.CheckRunAnnotation{
+					{
+						Path:    ".agola",
+						Level:   gitsource.CheckRunAnnotationLevelFailure,
+						Title:   "Task test failed",
+						Message: `Task "test" failed. See ` + detailsURL + " for details",
+					},
+				},
+			},
+		},
+		{
+			name: "stopped and cancelled tasks",
+			run: func() *rsapitypes.RunResponse {
+				run := testPullRequestRun(3)
+				run.Run.Tasks["task01"].Status = rstypes.RunTaskStatusCancelled
+				run.Run.Tasks["task02"].Status = rstypes.RunTaskStatusStopped
+				return run
+			},
+			commitStatus: gitsource.CommitStatusFailed,
+			out: &gitsource.CheckRun{
+				Name:       "agola/project01/run01",
+				ExternalID: "run3",
+				Status:     gitsource.CommitStatusFailed,
+				DetailsURL: detailsURL,
+				Title:      "Run #3: The run failed",
+				Summary:    "[Run #3](" + detailsURL + "): The run failed",
+				Text:       "| Task | Status |\n| --- | --- |\n| build | cancelled |\n| test | stopped |\n",
+				Annotations: []*gitsource.CheckRunAnnotation{
+					{
+						Path:    ".agola",
+						Level:   gitsource.CheckRunAnnotationLevelWarning,
+						Title:   "Task build cancelled",
+						Message: `Task "build" cancelled. See ` + detailsURL + " for details",
+					},
+					{
+						Path:    ".agola",
+						Level:   gitsource.CheckRunAnnotationLevelWarning,
+						Title:   "Task test stopped",
+						Message: `Task "test" stopped. See ` + detailsURL + " for details",
+					},
+				},
+			},
+		},
+		{
+			name: "running run waiting approval",
+			run: func() *rsapitypes.RunResponse {
+				run := testPullRequestRun(3)
+				run.Run.Tasks["task01"].Status = rstypes.RunTaskStatusRunning
+				run.Run.Tasks["task02"].Status = rstypes.RunTaskStatusNotStarted
+				run.Run.Tasks["task02"].WaitingApproval = true
+				return run
+			},
+			commitStatus: gitsource.CommitStatusPending,
+			out: &gitsource.CheckRun{
+				Name:        "agola/project01/run01",
+				ExternalID:  "run3",
+				Status:      gitsource.CommitStatusPending,
+				DetailsURL:  detailsURL,
+				Title:       "Run #3: The run is pending",
+				Summary:     "[Run #3](" + detailsURL + "): The run is pending",
+				Text:        "| Task | Status |\n| --- | --- |\n| build | running |\n| test | waiting approval |\n",
+				Annotations: []*gitsource.CheckRunAnnotation{},
+			},
+		},
+		{
+			name: "successful run with tests summary",
+			run: func() *rsapitypes.RunResponse {
+				run := testPullRequestRun(3)
+				run.Run.Tasks["task02"].Status = rstypes.RunTaskStatusSuccess
+				return run
+			},
+			commitStatus: gitsource.CommitStatusSuccess,
+			testsSummary: "**Tests**: 2 passed, 0 failed, 0 errors, 0 skipped\n",
+			out: &gitsource.CheckRun{
+				Name:        "agola/project01/run01",
+				ExternalID:  "run3",
+				Status:      gitsource.CommitStatusSuccess,
+				DetailsURL:  detailsURL,
+				Title:       "Run #3: The run finished successfully",
+				Summary:     "[Run #3](" + detailsURL + "): The run finished successfully",
+				Text:        "| Task | Status |\n| --- | --- |\n| build | success |\n| test | success |\n\n**Tests**: 2 passed, 0 failed, 0 errors, 0 skipped\n",
+				Annotations: []*gitsource.CheckRunAnnotation{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := genCheckRun(tt.run(), tt.commitStatus, "agola/project01/run01", detailsURL, tt.testsSummary)
+
+			if diff := cmp.Diff(tt.out, out); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func testApprovalRun() *rsapitypes.RunResponse {
+	run := testPullRequestRun(3)
+	run.Run.Tasks["task01"].Status = rstypes.RunTaskStatusSuccess
+	run.Run.Tasks["task02"].Status = rstypes.RunTaskStatusNotStarted
+	run.RunConfig.Tasks["task02"].NeedsApproval = true
+	return run
+}
+
+func TestApprovalCommitStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		ev           *rstypes.RunEvent
+		run          func() *rsapitypes.RunResponse
+		commitStatus gitsource.CommitStatus
+		taskID       string
+	}{
+		{
+			name:         "run without approval tasks",
+			ev:           &rstypes.RunEvent{Phase: rstypes.RunPhaseRunning, WaitingApproval: true},
+			run:          func() *rsapitypes.RunResponse { return testPullRequestRun(3) },
+			commitStatus: "",
+		},
+		{
+			name: "task waiting approval",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseRunning, WaitingApproval: true},
+			run: func() *rsapitypes.RunResponse {
+				run := testApprovalRun()
+				run.Run.Tasks["task02"].WaitingApproval = true
+				return run
+			},
+			commitStatus: gitsource.CommitStatusPending,
+			taskID:       "task02",
+		},
+		{
+			name:         "run state not waiting approval anymore",
+			ev:           &rstypes.RunEvent{Phase: rstypes.RunPhaseRunning, WaitingApproval: true},
+			run:          testApprovalRun,
+			commitStatus: gitsource.CommitStatusPending,
+		},
+		{
+			name: "approved task",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseRunning},
+			run: func() *rsapitypes.RunResponse {
+				run := testApprovalRun()
+				run.Run.Tasks["task02"].Approved = true
+				return run
+			},
+			commitStatus: gitsource.CommitStatusSuccess,
+		},
+		{
+			name:         "run finished without approval",
+			ev:           &rstypes.RunEvent{Phase: rstypes.RunPhaseFinished, Result: rstypes.RunResultStopped},
+			run:          testApprovalRun,
+			commitStatus: gitsource.CommitStatusError,
+		},
+		{
+			name:         "running run not yet waiting approval",
+			ev:           &rstypes.RunEvent{Phase: rstypes.RunPhaseRunning},
+			run:          testApprovalRun,
+			commitStatus: "",
+		},
+		{
+			name: "skipped approval task",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseFinished, Result: rstypes.RunResultSuccess},
+			run: func() *rsapitypes.RunResponse {
+				run := testApprovalRun()
+				run.Run.Tasks["task02"].Status = rstypes.RunTaskStatusSkipped
+				return run
+			},
+			commitStatus: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commitStatus, taskID := approvalCommitStatus(tt.ev, tt.run())
+			if commitStatus != tt.commitStatus {
+				t.Fatalf("expected commit status %q, got %q", tt.commitStatus, commitStatus)
+			}
+			if taskID != tt.taskID {
+				t.Fatalf("expected task id %q, got %q", tt.taskID, taskID)
+			}
+		})
+	}
+}
+
+type testCommitStatus struct {
+	RepoPath    string
+	CommitSHA   string
+	Status      gitsource.CommitStatus
+	TargetURL   string
+	Description string
+	Context     string
+}
+
+// testCommitStatusSource records the created commit statuses
+type testCommitStatusSource struct {
+	gitsource.GitSource
+
+	commitStatuses []testCommitStatus
+}
+
+func (s *testCommitStatusSource) CreateCommitStatus(repopath, commitSHA string, status gitsource.CommitStatus, targetURL, description, context string) error {
+	s.commitStatuses = append(s.commitStatuses, testCommitStatus{
+		RepoPath:    repopath,
+		CommitSHA:   commitSHA,
+		Status:      status,
+		TargetURL:   targetURL,
+		Description: description,
+		Context:     context,
+	})
+	return nil
+}
+
+func TestUpdateApprovalCommitStatus(t *testing.T) {
+	n := &NotificationService{c: &config.Notification{WebExposedURL: "https://agola.example.com"}}
+	project := &csapitypes.Project{
+		Project: &cstypes.Project{
+			ObjectMeta:     stypes.ObjectMeta{ID: "project01"},
+			RepositoryPath: "org01/repo01",
+		},
+	}
+
+	tests := []struct {
+		name string
+		ev   *rstypes.RunEvent
+		run  func() *rsapitypes.RunResponse
+		out  []testCommitStatus
+	}{
+		{
+			name: "task waiting approval",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseRunning, WaitingApproval: true},
+			run: func() *rsapitypes.RunResponse {
+				run := testApprovalRun()
+				run.Run.Tasks["task02"].WaitingApproval = true
+				return run
+			},
+			out: []testCommitStatus{
+				{
+					RepoPath:    "org01/repo01",
+					CommitSHA:   "c0ffee",
+					Status:      gitsource.CommitStatusPending,
+					TargetURL:   "https://agola.example.com/run?projectref=project01&runnumber=3&taskid=task02",
+					Description: "Run #3: Action required: the run is waiting for approval",
+					Context:     "agola/project01/run01/approval",
+				},
+			},
+		},
+		{
+			name: "approved task",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseRunning},
+			run: func() *rsapitypes.RunResponse {
+				run := testApprovalRun()
+				run.Run.Tasks["task02"].Approved = true
+				return run
+			},
+			out: []testCommitStatus{
+				{
+					RepoPath:    "org01/repo01",
+					CommitSHA:   "c0ffee",
+					Status:      gitsource.CommitStatusSuccess,
+					TargetURL:   "https://agola.example.com/run?projectref=project01&runnumber=3",
+					Description: "Run #3: The run was approved",
+					Context:     "agola/project01/run01/approval",
+				},
+			},
+		},
+		{
+			name: "run finished without approval",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseFinished, Result: rstypes.RunResultStopped},
+			run:  testApprovalRun,
+			out: []testCommitStatus{
+				{
+					RepoPath:    "org01/repo01",
+					CommitSHA:   "c0ffee",
+					Status:      gitsource.CommitStatusError,
+					TargetURL:   "https://agola.example.com/run?projectref=project01&runnumber=3",
+					Description: "Run #3: The run ended without approval",
+					Context:     "agola/project01/run01/approval",
+				},
+			},
+		},
+		{
+			name: "run without approval tasks",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseFinished, Result: rstypes.RunResultSuccess},
+			run:  func() *rsapitypes.RunResponse { return testPullRequestRun(3) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitSource := &testCommitStatusSource{}
+			if err := n.updateApprovalCommitStatus(tt.ev, tt.run(), project, gitSource, "agola/project01/run01"); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.out, gitSource.commitStatuses); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunStatusDescription(t *testing.T) {
+	tests := []struct {
+		commitStatus gitsource.CommitStatus
+		out          string
+	}{
+		{commitStatus: gitsource.CommitStatusQueued, out: "Run #7: The run is queued"},
+		{commitStatus: gitsource.CommitStatusPending, out: "Run #7: The run is pending"},
+		{commitStatus: gitsource.CommitStatusSuccess, out: "Run #7: The run finished successfully"},
+		{commitStatus: gitsource.CommitStatusError, out: "Run #7: The run encountered an error"},
+		{commitStatus: gitsource.CommitStatusFailed, out: "Run #7: The run failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.commitStatus), func(t *testing.T) {
+			if out := runStatusDescription(7, tt.commitStatus); out != tt.out {
+				t.Fatalf("expected description %q, got %q", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestWebRunURL(t *testing.T) {
+	tests := []struct {
+		name          string
+		webExposedURL string
+		taskID        string
+		out           string
+	}{
+		{
+			name:          "run",
+			webExposedURL: "https://agola.example.com",
+			out:           "https://agola.example.com/run?projectref=project01&runnumber=7",
+		},
+		{
+			name:          "run with web exposed url path",
+			webExposedURL: "https://example.com/agola",
+			out:           "https://example.com/agola/run?projectref=project01&runnumber=7",
+		},
+		{
+			name:          "run task",
+			webExposedURL: "https://agola.example.com",
+			taskID:        "task01",
+			out:           "https://agola.example.com/run?projectref=project01&runnumber=7&taskid=task01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out string
+			var err error
+			if tt.taskID != "" {
+				out, err = webRunTaskURL(tt.webExposedURL, "project01", 7, tt.taskID)
+			} else {
+				out, err = webRunURL(tt.webExposedURL, "project01", 7)
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if out != tt.out {
+				t.Fatalf("expected url %q, got %q", tt.out, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notification
+
+import (
+	"context"
+	stdsql "database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/sql"
+	"agola.io/agola/internal/util"
+	rstypes "agola.io/agola/services/runservice/types"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var sb = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+const (
+	commitStatusQueueTableName = "commitstatus_queue"
+
+	// commitStatusRetryInterval is the interval between checks for queued
+	// commit statuses to deliver
+	commitStatusRetryInterval = 5 * time.Second
+	// commitStatusRetryBaseDelay is the delay before the first retry. The
+	// delay is doubled at every retry up to commitStatusRetryMaxDelay
+	commitStatusRetryBaseDelay = 10 * time.Second
+	commitStatusRetryMaxDelay  = 10 * time.Minute
+	// commitStatusMaxAttempts is the max number of delivery attempts before
+	// dropping a commit status
+	commitStatusMaxAttempts = 12
+)
+
+var (
+	commitStatusQueueTableDDL = fmt.Sprintf("create table if not exists %s (run_id varchar not null, attempts bigint not null, next_attempt timestamptz not null, data bytea not null, PRIMARY KEY (run_id))", commitStatusQueueTableName)
+)
+
+// queuedCommitStatus is a run event whose commit status delivery failed and
+// must be retried. Only the last failed event of a run is kept since it
+// supersedes the previous ones.
+type queuedCommitStatus struct {
+	RunEvent    *rstypes.RunEvent
+	Attempts    int
+	NextAttempt time.Time
+}
+
+func commitStatusRetryDelay(attempts int) time.Duration {
+	delay := commitStatusRetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= commitStatusRetryMaxDelay {
+			delay = commitStatusRetryMaxDelay
+			break
+		}
+	}
+
+	return util.Jitter(delay, 0.1)
+}
+
+func (n *NotificationService) setupCommitStatusQueue(ctx context.Context) error {
+	err := n.sdb.Do(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(commitStatusQueueTableDDL); err != nil {
+			return errors.Wrapf(err, "failed to create %s table", commitStatusQueueTableName)
+		}
+		return nil
+	})
+
+	return errors.WithStack(err)
+}
+
+func getQueuedCommitStatus(tx *sql.Tx, runID string) (*queuedCommitStatus, error) {
+	q, args, err := sb.Select("attempts", "next_attempt", "data").From(commitStatusQueueTableName).Where(sq.Eq{"run_id": runID}).ToSql()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	qcs := &queuedCommitStatus{}
+	var data []byte
+	if err := tx.QueryRow(q, args...).Scan(&qcs.Attempts, &qcs.NextAttempt, &data); err != nil {
+		if errors.Is(err, stdsql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	if err := json.Unmarshal(data, &qcs.RunEvent); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return qcs, nil
+}
+
+func getDueQueuedCommitStatusRunIDs(tx *sql.Tx, now time.Time) ([]string, error) {
+	q, args, err := sb.Select("run_id").From(commitStatusQueueTableName).Where(sq.LtOrEq{"next_attempt": now}).OrderBy("next_attempt").ToSql()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	rows, err := tx.Query(q, args...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	runIDs := []string{}
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		runIDs = append(runIDs, runID)
+	}
+
+	return runIDs, errors.WithStack(rows.Err())
+}
+
+func deleteQueuedCommitStatus(tx *sql.Tx, runID string) error {
+	q, args, err := sb.Delete(commitStatusQueueTableName).Where(sq.Eq{"run_id": runID}).ToSql()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := tx.Exec(q, args...); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func putQueuedCommitStatus(tx *sql.Tx, qcs *queuedCommitStatus) error {
+	if err := deleteQueuedCommitStatus(tx, qcs.RunEvent.RunID); err != nil {
+		return errors.WithStack(err)
+	}
+
+	data, err := json.Marshal(qcs.RunEvent)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	q, args, err := sb.Insert(commitStatusQueueTableName).Columns("run_id", "attempts", "next_attempt", "data").Values(qcs.RunEvent.RunID, qcs.Attempts, qcs.NextAttempt, data).ToSql()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := tx.Exec(q, args...); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// deliverCommitStatus updates the commit status for the run event. On failure
+// the event is queued for a later retry, on success any queued (older) event
+// of the same run is removed.
+func (n *NotificationService) deliverCommitStatus(ctx context.Context, ev *rstypes.RunEvent) error {
+	n.commitStatusMu.Lock()
+	defer n.commitStatusMu.Unlock()
+
+	deliveryErr := n.updateCommitStatus(ctx, ev)
+
+	err := n.sdb.Do(ctx, func(tx *sql.Tx) error {
+		if deliveryErr == nil {
+			return errors.WithStack(deleteQueuedCommitStatus(tx, ev.RunID))
+		}
+
+		qcs := &queuedCommitStatus{
+			RunEvent:    ev,
+			Attempts:    1,
+			NextAttempt: time.Now().Add(commitStatusRetryDelay(1)),
+		}
+		return errors.WithStack(putQueuedCommitStatus(tx, qcs))
+	})
+	if err != nil {
+		n.log.Err(err).Msgf("failed to update commit status queue for run %q", ev.RunID)
+	}
+
+	return errors.WithStack(deliveryErr)
+}
+
+// retryQueuedCommitStatus retries the delivery of the queued commit status of
+// the provided run if it's due.
+func (n *NotificationService) retryQueuedCommitStatus(ctx context.Context, runID string) error {
+	n.commitStatusMu.Lock()
+	defer n.commitStatusMu.Unlock()
+
+	var qcs *queuedCommitStatus
+	err := n.sdb.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		qcs, err = getQueuedCommitStatus(tx, runID)
+		return errors.WithStack(err)
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// already delivered or superseded by a newer event
+	if qcs == nil || qcs.NextAttempt.After(time.Now()) {
+		return nil
+	}
+
+	deliveryErr := n.updateCommitStatus(ctx, qcs.RunEvent)
+	if deliveryErr != nil {
+		qcs.Attempts++
+		n.log.Info().Msgf("failed to update commit status for run %q (attempt %d): %v", runID, qcs.Attempts, deliveryErr)
+	}
+
+	err = n.sdb.Do(ctx, func(tx *sql.Tx) error {
+		if deliveryErr == nil {
+			return errors.WithStack(deleteQueuedCommitStatus(tx, runID))
+		}
+		if qcs.Attempts >= commitStatusMaxAttempts {
+			n.log.Warn().Msgf("dropping commit status for run %q after %d failed attempts", runID, qcs.Attempts)
+			return errors.WithStack(deleteQueuedCommitStatus(tx, runID))
+		}
+
+		qcs.NextAttempt = time.Now().Add(commitStatusRetryDelay(qcs.Attempts))
+		return errors.WithStack(putQueuedCommitStatus(tx, qcs))
+	})
+
+	return errors.WithStack(err)
+}
+
+func (n *NotificationService) commitStatusRetryLoop(ctx context.Context) {
+	for {
+		if err := n.commitStatusRetry(ctx); err != nil {
+			n.log.Err(err).Send()
+		}
+
+		sleepCh := time.NewTimer(commitStatusRetryInterval).C
+		select {
+		case <-ctx.Done():
+			return
+		case <-sleepCh:
+		}
+	}
+}
+
+func (n *NotificationService) commitStatusRetry(ctx context.Context) error {
+	var runIDs []string
+	err := n.sdb.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		runIDs, err = getDueQueuedCommitStatusRunIDs(tx, time.Now())
+		return errors.WithStack(err)
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, runID := range runIDs {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := n.retryQueuedCommitStatus(ctx, runID); err != nil {
+			n.log.Err(err).Msgf("failed to retry commit status for run %q", runID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notification
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	gitsource "agola.io/agola/internal/gitsources"
+	"agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/sql"
+	"agola.io/agola/internal/testutil"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+	rsclient "agola.io/agola/services/runservice/client"
+	rstypes "agola.io/agola/services/runservice/types"
+	stypes "agola.io/agola/services/types"
+)
+
+func TestCommitStatusFromRunEvent(t *testing.T) {
+	tests := []struct {
+		name string
+		ev   *rstypes.RunEvent
+		out  gitsource.CommitStatus
+	}{
+		{
+			name: "setup error",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseSetupError, Result: rstypes.RunResultUnknown},
+			out:  gitsource.CommitStatusError,
+		},
+		{
+			name: "cancelled",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseCancelled, Result: rstypes.RunResultUnknown},
+			out:  gitsource.CommitStatusError,
+		},
+		{
+			name: "queued",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseQueued, Result: rstypes.RunResultUnknown},
+			out:  gitsource.CommitStatusQueued,
+		},
+		{
+			name: "running",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseRunning, Result: rstypes.RunResultUnknown},
+			out:  gitsource.CommitStatusPending,
+		},
+		{
+			name: "running with a failed result",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseRunning, Result: rstypes.RunResultFailed},
+			out:  "",
+		},
+		{
+			name: "finished successfully",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseFinished, Result: rstypes.RunResultSuccess},
+			out:  gitsource.CommitStatusSuccess,
+		},
+		{
+			name: "finished failed",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseFinished, Result: rstypes.RunResultFailed},
+			out:  gitsource.CommitStatusFailed,
+		},
+		{
+			name: "finished stopped",
+			ev:   &rstypes.RunEvent{Phase: rstypes.RunPhaseFinished, Result: rstypes.RunResultStopped},
+			out:  gitsource.CommitStatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := commitStatusFromRunEvent(tt.ev)
+			if out != tt.out {
+				t.Fatalf("expected commit status %q, got %q", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestCommitStatusRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{attempts: 1, delay: 10 * time.Second},
+		{attempts: 2, delay: 20 * time.Second},
+		{attempts: 3, delay: 40 * time.Second},
+		{attempts: 6, delay: 320 * time.Second},
+		{attempts: 7, delay: 10 * time.Minute},
+		{attempts: commitStatusMaxAttempts, delay: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		// the delay has a jitter of up to 10%
+		maxDelay := tt.delay + tt.delay/10
+		for i := 0; i < 10; i++ {
+			delay := commitStatusRetryDelay(tt.attempts)
+			if delay < tt.delay || delay > maxDelay {
+				t.Fatalf("attempt %d: expected delay between %s and %s, got %s", tt.attempts, tt.delay, maxDelay, delay)
+			}
+		}
+	}
+}
+
+func TestCommitStatusQueue(t *testing.T) {
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	sdb, err := sql.NewDB(sql.Sqlite3, filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	// the runservice fails until the run is set, the run is a user direct
+	// run so the commit status update is a noop
+	var mu sync.Mutex
+	var run *rsapitypes.RunResponse
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if run == nil {
+			writeTestJSON(t, w, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+			return
+		}
+		writeTestJSON(t, w, http.StatusOK, run)
+	}))
+	t.Cleanup(ts.Close)
+
+	n := &NotificationService{
+		log:              log,
+		sdb:              sdb,
+		runserviceClient: rsclient.NewClient(ts.URL),
+	}
+	if err := n.setupCommitStatusQueue(ctx); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	getQueued := func(t *testing.T) *queuedCommitStatus {
+		var qcs *queuedCommitStatus
+		err := sdb.Do(ctx, func(tx *sql.Tx) error {
+			var err error
+			qcs, err = getQueuedCommitStatus(tx, "run01")
+			return err
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		return qcs
+	}
+	expireQueued := func(t *testing.T) {
+		qcs := getQueued(t)
+		qcs.NextAttempt = time.Now().Add(-time.Second)
+		err := sdb.Do(ctx, func(tx *sql.Tx) error {
+			return putQueuedCommitStatus(tx, qcs)
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+
+	ev := &rstypes.RunEvent{RunID: "run01", Phase: rstypes.RunPhaseFinished, Result: rstypes.RunResultSuccess}
+
+	t.Run("failed delivery is queued", func(t *testing.T) {
+		if err := n.deliverCommitStatus(ctx, ev); err == nil {
+			t.Fatalf("expected err")
+		}
+
+		qcs := getQueued(t)
+		if qcs == nil {
+			t.Fatalf("expected queued commit status")
+		}
+		if qcs.Attempts != 1 {
+			t.Fatalf("expected 1 attempt, got %d", qcs.Attempts)
+		}
+		if qcs.RunEvent.RunID != ev.RunID || qcs.RunEvent.Result != ev.Result {
+			t.Fatalf("unexpected queued run event: %+v", qcs.RunEvent)
+		}
+	})
+
+	t.Run("not due queued commit status isn't retried", func(t *testing.T) {
+		if err := n.retryQueuedCommitStatus(ctx, "run01"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if qcs := getQueued(t); qcs.Attempts != 1 {
+			t.Fatalf("expected 1 attempt, got %d", qcs.Attempts)
+		}
+	})
+
+	t.Run("failed retry increases the attempts", func(t *testing.T) {
+		expireQueued(t)
+
+		if err := n.retryQueuedCommitStatus(ctx, "run01"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		qcs := getQueued(t)
+		if qcs.Attempts != 2 {
+			t.Fatalf("expected 2 attempts, got %d", qcs.Attempts)
+		}
+		if !qcs.NextAttempt.After(time.Now()) {
+			t.Fatalf("expected next attempt in the future, got %s", qcs.NextAttempt)
+		}
+	})
+
+	t.Run("successful retry removes the queued commit status", func(t *testing.T) {
+		mu.Lock()
+		run = &rsapitypes.RunResponse{
+			Run: &rstypes.Run{ObjectMeta: stypes.ObjectMeta{ID: "run01"}},
+			RunConfig: &rstypes.RunConfig{
+				Group: common.GenRunGroup(common.GroupTypeUser, "user01", common.GroupTypeBranch, "master"),
+			},
+		}
+		mu.Unlock()
+		expireQueued(t)
+
+		if err := n.commitStatusRetry(ctx); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		if qcs := getQueued(t); qcs != nil {
+			t.Fatalf("expected no queued commit status, got %+v", qcs)
+		}
+	})
+}
@@ -16,9 +16,11 @@ package notification
 
 import (
 	"context"
+	"sync"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/lock"
+	"agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/config"
 	"agola.io/agola/internal/sql"
 	csclient "agola.io/agola/services/configstore/client"
@@ -32,7 +34,11 @@ type NotificationService struct {
 	gc  *config.Config
 	c   *config.Notification
 
-	lf lock.LockFactory
+	sdb *sql.DB
+	lf  lock.LockFactory
+
+	// commitStatusMu serializes commit status deliveries and retries
+	commitStatusMu sync.Mutex
 
 	runserviceClient  *rsclient.Client
 	configstoreClient *csclient.Client
@@ -50,8 +56,6 @@ func NewNotificationService(ctx context.Context, log zerolog.Logger, gc *config.
 		return nil, errors.Wrapf(err, "new db error")
 	}
 
-	// We are currently using the db only for locking. No tables are created.
-
 	var lf lock.LockFactory
 	switch c.DB.Type {
 	case sql.Sqlite3:
@@ -63,17 +67,31 @@ func NewNotificationService(ctx context.Context, log zerolog.Logger, gc *config.
 		return nil, errors.Errorf("unknown type %q", c.DB.Type)
 	}
 
+	serviceAuth, err := common.NewServiceAuth(&gc.InternalServicesAuth, common.ServiceNotification)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	configstoreClient := csclient.NewClient(c.ConfigstoreURL)
+	configstoreClient.SetHTTPClient(serviceAuth.HTTPClient(common.ServiceConfigstore))
 	runserviceClient := rsclient.NewClient(c.RunserviceURL)
+	runserviceClient.SetHTTPClient(serviceAuth.HTTPClient(common.ServiceRunservice))
 
-	return &NotificationService{
+	n := &NotificationService{
 		log:               log,
 		gc:                gc,
 		c:                 c,
+		sdb:               sdb,
 		lf:                lf,
 		runserviceClient:  runserviceClient,
 		configstoreClient: configstoreClient,
-	}, nil
+	}
+
+	if err := n.setupCommitStatusQueue(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return n, nil
 }
 
 func (n *NotificationService) Run(ctx context.Context) error {
@@ -0,0 +1,123 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+	gitsource "agola.io/agola/internal/gitsources"
+	"agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/services/gateway/action"
+	"agola.io/agola/internal/services/types"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+	rstypes "agola.io/agola/services/runservice/types"
+)
+
+// updatePullRequestComment posts, or updates in place, a pull request comment
+// with the run results summary for projects with pull request comments enabled
+func (n *NotificationService) updatePullRequestComment(ctx context.Context, ev *rstypes.RunEvent) error {
+	commitStatus := commitStatusFromRunEvent(ev)
+	if commitStatus == "" {
+		return nil
+	}
+
+	run, _, err := n.runserviceClient.GetRun(ctx, ev.RunID, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if run.Run.Annotations[action.AnnotationRefType] != string(types.RunRefTypePullRequest) {
+		return nil
+	}
+	prID := run.Run.Annotations[action.AnnotationPullRequestID]
+	if prID == "" {
+		return nil
+	}
+
+	groupType, groupID, err := common.GroupTypeIDFromRunGroup(run.RunConfig.Group)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// ignore user direct runs
+	if groupType == common.GroupTypeUser {
+		return nil
+	}
+
+	project, _, err := n.configstoreClient.GetProject(ctx, groupID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get project %s", groupID)
+	}
+	if !project.PostPullRequestComments {
+		return nil
+	}
+
+	gitSource, err := n.projectGitSource(ctx, project)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	prCommentSource, ok := gitSource.(gitsource.PullRequestCommentSource)
+	if !ok {
+		return nil
+	}
+
+	// use a marker to find the comment of this run so it'll be updated by the
+	// next runs for the same pull request
+	marker := fmt.Sprintf("<!-- agola run summary: %s/%s/%s -->", n.gc.ID, project.ID, run.RunConfig.Name)
+
+	testsSummary := n.testReportsSummary(ctx, run, commitStatus)
+
+	body, err := n.genPullRequestComment(project, run, commitStatus, marker, testsSummary)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := prCommentSource.CreateOrUpdatePullRequestComment(project.RepositoryPath, prID, marker, body); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func (n *NotificationService) genPullRequestComment(project *csapitypes.Project, run *rsapitypes.RunResponse, commitStatus gitsource.CommitStatus, marker, testsSummary string) (string, error) {
+	runURL, err := webRunURL(n.c.WebExposedURL, project.ID, run.Run.Counter)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to generate run url")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", marker)
+	fmt.Fprintf(&b, "**Agola run [%s #%d](%s)**: %s\n\n", run.RunConfig.Name, run.Run.Counter, runURL, statusDescription(commitStatus))
+	if commitSHA := run.Run.Annotations[action.AnnotationCommitSHA]; commitSHA != "" {
+		fmt.Fprintf(&b, "Commit: %s\n\n", commitSHA)
+	}
+
+	b.WriteString("| Task | Status | Logs |\n| --- | --- | --- |\n")
+	for _, rt := range sortedRunTasks(run) {
+		taskURL, err := webRunTaskURL(n.c.WebExposedURL, project.ID, run.Run.Counter, rt.ID)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to generate run task url")
+		}
+		fmt.Fprintf(&b, "| %s | %s | [logs](%s) |\n", run.RunConfig.Tasks[rt.ID].Name, runTaskStatus(rt), taskURL)
+	}
+	if testsSummary != "" {
+		fmt.Fprintf(&b, "\n%s", testsSummary)
+	}
+
+	return b.String(), nil
+}
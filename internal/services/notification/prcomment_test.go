@@ -0,0 +1,455 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	gitsource "agola.io/agola/internal/gitsources"
+	"agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/services/config"
+	"agola.io/agola/internal/services/gateway/action"
+	"agola.io/agola/internal/services/types"
+	"agola.io/agola/internal/testutil"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	csclient "agola.io/agola/services/configstore/client"
+	cstypes "agola.io/agola/services/configstore/types"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+	rsclient "agola.io/agola/services/runservice/client"
+	rstypes "agola.io/agola/services/runservice/types"
+	stypes "agola.io/agola/services/types"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testPullRequestRun(counter uint64) *rsapitypes.RunResponse {
+	return &rsapitypes.RunResponse{
+		Run: &rstypes.Run{
+			ObjectMeta: stypes.ObjectMeta{ID: "run" + strconv.FormatUint(counter, 10)},
+			Counter:    counter,
+			Annotations: map[string]string{
+				action.AnnotationRefType:       string(types.RunRefTypePullRequest),
+				action.AnnotationPullRequestID: "1",
+				action.AnnotationCommitSHA:     "c0ffee",
+			},
+			Tasks: map[string]*rstypes.RunTask{
+				"task02": {ID: "task02", Status: rstypes.RunTaskStatusFailed},
+				"task01": {ID: "task01", Status: rstypes.RunTaskStatusSuccess},
+			},
+		},
+		RunConfig: &rstypes.RunConfig{
+			Name:  "run01",
+			Group: common.GenRunGroup(common.GroupTypeProject, "project01", common.GroupTypePullRequest, "1"),
+			Tasks: map[string]*rstypes.RunConfigTask{
+				"task01": {ID: "task01", Name: "build"},
+				"task02": {ID: "task02", Name: "test"},
+			},
+		},
+	}
+}
+
+func TestGenPullRequestComment(t *testing.T) {
+	n := &NotificationService{c: &config.Notification{WebExposedURL: "https://agola.example.com"}}
+	project := &csapitypes.Project{Project: &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project01"}}}
+	marker := "<!-- agola run summary: agola/project01/run01 -->"
+
+	tests := []struct {
+		name         string
+		run          func() *rsapitypes.RunResponse
+		commitStatus gitsource.CommitStatus
+		testsSummary string
+		out          string
+	}{
+		{
+			name:         "failed run",
+			run:          func() *rsapitypes.RunResponse { return testPullRequestRun(3) },
+			commitStatus: gitsource.CommitStatusFailed,
+			out: `<!-- agola run summary: agola/project01/run01 -->
+**Agola run [run01 #3](https://agola.example.com/run?projectref=project01&runnumber=3)**: The run failed
+
+Commit: c0ffee
+
+| Task | Status | Logs |
+| --- | --- | --- |
+| build | success | [logs](https://agola.example.com/run?projectref=project01&runnumber=3&taskid=task01) |
+| test | failed | [logs](https://agola.example.com/run?projectref=project01&runnumber=3&taskid=task02) |
+`,
+		},
+		{
+			name: "running run without commit sha waiting approval",
+			run: func() *rsapitypes.RunResponse {
+				run := testPullRequestRun(4)
+				delete(run.Run.Annotations, action.AnnotationCommitSHA)
+				run.Run.Tasks["task01"].Status = rstypes.RunTaskStatusRunning
+				run.Run.Tasks["task02"].Status = rstypes.RunTaskStatusNotStarted
+				run.Run.Tasks["task02"].WaitingApproval = true
+				return run
+			},
+			commitStatus: gitsource.CommitStatusPending,
+			out: `<!-- agola run summary: agola/project01/run01 -->
+**Agola run [run01 #4](https://agola.example.com/run?projectref=project01&runnumber=4)**: The run is pending
+
+| Task | Status | Logs |
+| --- | --- | --- |
+| build | running | [logs](https://agola.example.com/run?projectref=project01&runnumber=4&taskid=task01) |
+| test | waiting approval | [logs](https://agola.example.com/run?projectref=project01&runnumber=4&taskid=task02) |
+`,
+		},
+		{
+			name:         "run with tests summary",
+			run:          func() *rsapitypes.RunResponse { return testPullRequestRun(5) },
+			commitStatus: gitsource.CommitStatusSuccess,
+			testsSummary: "**Tests**: 2 passed, 0 failed, 0 errors, 0 skipped\n",
+			out: `<!-- agola run summary: agola/project01/run01 -->
+**Agola run [run01 #5](https://agola.example.com/run?projectref=project01&runnumber=5)**: The run finished successfully
+
+Commit: c0ffee
+
+| Task | Status | Logs |
+| --- | --- | --- |
+| build | success | [logs](https://agola.example.com/run?projectref=project01&runnumber=5&taskid=task01) |
+| test | failed | [logs](https://agola.example.com/run?projectref=project01&runnumber=5&taskid=task02) |
+
+**Tests**: 2 passed, 0 failed, 0 errors, 0 skipped
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := n.genPullRequestComment(project, tt.run(), tt.commitStatus, marker, tt.testsSummary)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.out, out); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPullRequestCommentSources(t *testing.T) {
+	la := &cstypes.LinkedAccount{UserAccessToken: "token01"}
+
+	tests := []struct {
+		rsType cstypes.RemoteSourceType
+		apiURL string
+		out    bool
+	}{
+		{rsType: cstypes.RemoteSourceTypeGitea, apiURL: "https://gitea.example.com", out: true},
+		{rsType: cstypes.RemoteSourceTypeGithub, apiURL: "https://api.github.com", out: true},
+		{rsType: cstypes.RemoteSourceTypeGitlab, apiURL: "https://gitlab.example.com", out: true},
+		{rsType: cstypes.RemoteSourceTypeGit, apiURL: "https://git.example.com", out: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.rsType), func(t *testing.T) {
+			rs := &cstypes.RemoteSource{
+				Name:     "rs01",
+				Type:     tt.rsType,
+				AuthType: cstypes.RemoteSourceAuthTypePassword,
+				APIURL:   tt.apiURL,
+			}
+			gitSource, err := common.GetGitSource(rs, la)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			if _, ok := gitSource.(gitsource.PullRequestCommentSource); ok != tt.out {
+				t.Fatalf("expected pull request comment source %t, got %t", tt.out, ok)
+			}
+		})
+	}
+}
+
+type prCommentTestComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+// prCommentTestServices fakes the runservice, configstore and gitea api calls
+// done when updating a pull request comment
+type prCommentTestServices struct {
+	mu sync.Mutex
+
+	run     *rsapitypes.RunResponse
+	project *csapitypes.Project
+	rs      *cstypes.RemoteSource
+
+	// comments are the pull request comments
+	comments []*prCommentTestComment
+	// giteaRequests is the number of requests received by gitea
+	giteaRequests int
+}
+
+func (s *prCommentTestServices) runservice(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		runPath := "/api/v1alpha/runs/" + s.run.Run.ID
+		switch {
+		case r.Method == "GET" && r.URL.Path == runPath:
+			writeTestJSON(t, w, http.StatusOK, s.run)
+		case r.Method == "GET" && r.URL.Path == runPath+"/testreports":
+			writeTestJSON(t, w, http.StatusOK, &rsapitypes.RunTestReportsResponse{})
+		default:
+			t.Errorf("unexpected runservice request %s %s", r.Method, r.URL.Path)
+			writeTestJSON(t, w, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func (s *prCommentTestServices) configstore(t *testing.T) *httptest.Server {
+	user := &cstypes.User{ObjectMeta: stypes.ObjectMeta{ID: "user01"}, Name: "user01"}
+	la := &cstypes.LinkedAccount{
+		ObjectMeta:      stypes.ObjectMeta{ID: "la01"},
+		UserID:          "user01",
+		RemoteSourceID:  "rs01",
+		UserAccessToken: "token01",
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/projects/"+s.project.ID:
+			writeTestJSON(t, w, http.StatusOK, s.project)
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/users" && r.URL.Query().Get("linkedaccountid") == la.ID:
+			writeTestJSON(t, w, http.StatusOK, []*cstypes.User{user})
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/users/user01/linkedaccounts":
+			writeTestJSON(t, w, http.StatusOK, []*cstypes.LinkedAccount{la})
+		case r.Method == "GET" && r.URL.Path == "/api/v1alpha/remotesources/"+s.rs.ID:
+			writeTestJSON(t, w, http.StatusOK, s.rs)
+		default:
+			t.Errorf("unexpected configstore request %s %s", r.Method, r.URL.Path)
+			writeTestJSON(t, w, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func (s *prCommentTestServices) gitea(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.giteaRequests++
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v1/repos/org01/repo01/issues/1/comments":
+			writeTestJSON(t, w, http.StatusOK, s.comments)
+		case r.Method == "POST" && r.URL.Path == "/api/v1/repos/org01/repo01/issues/1/comments":
+			var comment *prCommentTestComment
+			if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			comment.ID = int64(len(s.comments) + 1)
+			s.comments = append(s.comments, comment)
+			writeTestJSON(t, w, http.StatusCreated, comment)
+		case r.Method == "PATCH" && strings.HasPrefix(r.URL.Path, "/api/v1/repos/org01/repo01/issues/comments/"):
+			id, _ := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/v1/repos/org01/repo01/issues/comments/"), 10, 64)
+			var req *prCommentTestComment
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			for _, comment := range s.comments {
+				if comment.ID == id {
+					comment.Body = req.Body
+					writeTestJSON(t, w, http.StatusOK, comment)
+					return
+				}
+			}
+			writeTestJSON(t, w, http.StatusNotFound, map[string]string{"message": "not found"})
+		default:
+			t.Errorf("unexpected gitea request %s %s", r.Method, r.URL.Path)
+			writeTestJSON(t, w, http.StatusNotFound, map[string]string{"message": "not found"})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func (s *prCommentTestServices) update(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f()
+}
+
+func (s *prCommentTestServices) state() ([]prCommentTestComment, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comments := []prCommentTestComment{}
+	for _, c := range s.comments {
+		comments = append(comments, *c)
+	}
+	return comments, s.giteaRequests
+}
+
+func writeTestJSON(t *testing.T, w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+}
+
+func TestUpdatePullRequestComment(t *testing.T) {
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	s := &prCommentTestServices{
+		run: testPullRequestRun(1),
+		project: &csapitypes.Project{
+			Project: &cstypes.Project{
+				ObjectMeta:              stypes.ObjectMeta{ID: "project01"},
+				Name:                    "project01",
+				LinkedAccountID:         "la01",
+				RepositoryPath:          "org01/repo01",
+				PostPullRequestComments: true,
+			},
+		},
+	}
+	s.rs = &cstypes.RemoteSource{
+		ObjectMeta: stypes.ObjectMeta{ID: "rs01"},
+		Name:       "rs01",
+		Type:       cstypes.RemoteSourceTypeGitea,
+		AuthType:   cstypes.RemoteSourceAuthTypePassword,
+		APIURL:     s.gitea(t).URL,
+	}
+
+	gc := &config.Config{
+		ID:           "agola",
+		Notification: config.Notification{WebExposedURL: "https://agola.example.com"},
+	}
+	n := &NotificationService{
+		log:               log,
+		gc:                gc,
+		c:                 &gc.Notification,
+		runserviceClient:  rsclient.NewClient(s.runservice(t).URL),
+		configstoreClient: csclient.NewClient(s.configstore(t).URL),
+	}
+
+	marker := "<!-- agola run summary: agola/project01/run01 -->"
+	finishedEvent := func(runID string) *rstypes.RunEvent {
+		return &rstypes.RunEvent{RunID: runID, Phase: rstypes.RunPhaseFinished, Result: rstypes.RunResultFailed}
+	}
+
+	t.Run("pull request run comment is created", func(t *testing.T) {
+		if err := n.updatePullRequestComment(ctx, finishedEvent("run1")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		comments, _ := s.state()
+		if len(comments) != 1 {
+			t.Fatalf("expected 1 comment, got %d", len(comments))
+		}
+		if !strings.HasPrefix(comments[0].Body, marker+"\n") || !strings.Contains(comments[0].Body, "[run01 #1]") {
+			t.Fatalf("unexpected comment body: %q", comments[0].Body)
+		}
+	})
+
+	t.Run("next run of the same pull request updates the comment", func(t *testing.T) {
+		s.update(func() { s.run = testPullRequestRun(2) })
+
+		if err := n.updatePullRequestComment(ctx, finishedEvent("run2")); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		comments, _ := s.state()
+		if len(comments) != 1 {
+			t.Fatalf("expected 1 comment, got %d", len(comments))
+		}
+		if !strings.HasPrefix(comments[0].Body, marker+"\n") || !strings.Contains(comments[0].Body, "[run01 #2]") {
+			t.Fatalf("unexpected comment body: %q", comments[0].Body)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		ev     *rstypes.RunEvent
+		update func()
+	}{
+		{
+			name: "events not changing the commit status are ignored",
+			ev:   &rstypes.RunEvent{RunID: "run3", Phase: rstypes.RunPhaseFinished, Result: rstypes.RunResultUnknown},
+			update: func() {
+				s.run = testPullRequestRun(3)
+			},
+		},
+		{
+			name: "branch runs aren't commented",
+			ev:   finishedEvent("run3"),
+			update: func() {
+				s.run = testPullRequestRun(3)
+				s.run.Run.Annotations[action.AnnotationRefType] = string(types.RunRefTypeBranch)
+				delete(s.run.Run.Annotations, action.AnnotationPullRequestID)
+			},
+		},
+		{
+			name: "projects without pull request comments aren't commented",
+			ev:   finishedEvent("run3"),
+			update: func() {
+				s.run = testPullRequestRun(3)
+				s.project.PostPullRequestComments = false
+			},
+		},
+		{
+			name: "git sources without pull request comments aren't called",
+			ev:   finishedEvent("run3"),
+			update: func() {
+				s.run = testPullRequestRun(3)
+				s.project.PostPullRequestComments = true
+				s.rs.Type = cstypes.RemoteSourceTypeGit
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.update(tt.update)
+			prevComments, prevRequests := s.state()
+
+			if err := n.updatePullRequestComment(ctx, tt.ev); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			comments, requests := s.state()
+			if requests != prevRequests {
+				t.Fatalf("expected no gitea requests, got %d", requests-prevRequests)
+			}
+			if diff := cmp.Diff(prevComments, comments); diff != "" {
+				t.Fatalf("comments mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
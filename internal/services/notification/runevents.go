@@ -57,6 +57,12 @@ func (n *NotificationService) runEventsHandler(ctx context.Context) error {
 	}
 	defer func() { _ = l.Unlock() }()
 
+	// retry failed commit statuses only on the instance handling the run
+	// events so they are delivered in order
+	rctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go n.commitStatusRetryLoop(rctx)
+
 	resp, err := n.runserviceClient.GetRunEvents(ctx, "")
 	if err != nil {
 		return errors.WithStack(err)
@@ -97,12 +103,15 @@ func (n *NotificationService) runEventsHandler(ctx context.Context) error {
 			}
 
 			// TODO(sgotti)
-			// this is just a basic handling. Improve it to store received events and
-			// their status in the db so we can also do more logic like retrying and handle
-			// multiple kind of notifications (email etc...)
-			if err := n.updateCommitStatus(ctx, ev); err != nil {
+			// this is just a basic handling. Only failed commit statuses are
+			// stored in the db to be retried. Improve it to handle multiple kind
+			// of notifications (email etc...)
+			if err := n.deliverCommitStatus(ctx, ev); err != nil {
 				n.log.Info().Msgf("failed to update commit status: %v", err)
 			}
+			if err := n.updatePullRequestComment(ctx, ev); err != nil {
+				n.log.Info().Msgf("failed to update pull request comment: %v", err)
+			}
 
 		default:
 			return errors.Errorf("wrong data")
@@ -0,0 +1,83 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gitsource "agola.io/agola/internal/gitsources"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+)
+
+// maxSummaryFailedTests is the max number of failed tests listed in the test
+// reports summary
+const maxSummaryFailedTests = 20
+
+// testReportsSummary returns a summary of the test reports saved by the run
+// tasks. It returns an empty string when the run isn't finished or no task
+// saved a test report.
+func (n *NotificationService) testReportsSummary(ctx context.Context, run *rsapitypes.RunResponse, commitStatus gitsource.CommitStatus) string {
+	if commitStatus == gitsource.CommitStatusPending {
+		return ""
+	}
+
+	res, _, err := n.runserviceClient.GetRunTestReports(ctx, run.Run.ID)
+	if err != nil {
+		// the test reports are just informative, don't fail the notification
+		n.log.Warn().Err(err).Msgf("failed to get run %q test reports", run.Run.ID)
+		return ""
+	}
+	if len(res.TestReports) == 0 {
+		return ""
+	}
+
+	var passed, failures, errs, skipped int
+	var failedTests []string
+	for _, tr := range res.TestReports {
+		passed += tr.Report.Passed()
+		failures += tr.Report.Failures
+		errs += tr.Report.Errors
+		skipped += tr.Report.Skipped
+
+		taskName := tr.TaskID
+		if rct, ok := run.RunConfig.Tasks[tr.TaskID]; ok {
+			taskName = rct.Name
+		}
+		for _, f := range tr.Report.FailedTests {
+			name := f.Name
+			if f.ClassName != "" {
+				name = f.ClassName + "." + f.Name
+			}
+			failedTests = append(failedTests, fmt.Sprintf("- %s: `%s`", taskName, name))
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "**Tests**: %d passed, %d failed, %d errors, %d skipped\n", passed, failures, errs, skipped)
+	if len(failedTests) > 0 {
+		b.WriteString("\nFailed tests:\n")
+		for i, f := range failedTests {
+			if i == maxSummaryFailedTests {
+				fmt.Fprintf(&b, "- and %d more\n", len(failedTests)-maxSummaryFailedTests)
+				break
+			}
+			fmt.Fprintf(&b, "%s\n", f)
+		}
+	}
+
+	return b.String()
+}
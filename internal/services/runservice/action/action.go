@@ -16,6 +16,7 @@ package action
 
 import (
 	"context"
+	"encoding/json"
 	"path"
 	"reflect"
 	"time"
@@ -24,8 +25,12 @@ import (
 	"agola.io/agola/internal/lock"
 	"agola.io/agola/internal/objectstorage"
 	"agola.io/agola/internal/runconfig"
+	"agola.io/agola/internal/sealedsecret"
+	scommon "agola.io/agola/internal/services/common"
+	"agola.io/agola/internal/services/config"
 	"agola.io/agola/internal/services/runservice/common"
 	"agola.io/agola/internal/services/runservice/db"
+	"agola.io/agola/internal/services/runservice/store"
 	"agola.io/agola/internal/sql"
 	"agola.io/agola/internal/util"
 	"agola.io/agola/services/runservice/types"
@@ -39,17 +44,32 @@ type ActionHandler struct {
 	d               *db.DB
 	ost             *objectstorage.ObjStorage
 	lf              lock.LockFactory
+	limits          config.RunLimits
 	maintenanceMode bool
+
+	sealedSecretsKey *sealedsecret.Key
+	repoTokenSD      *scommon.TokenSigningData
 }
 
-func NewActionHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage, lf lock.LockFactory) *ActionHandler {
+func NewActionHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage, lf lock.LockFactory, limits config.RunLimits, sealedSecretsKey *sealedsecret.Key, repoTokenSD *scommon.TokenSigningData) *ActionHandler {
 	return &ActionHandler{
-		log:             log,
-		d:               d,
-		ost:             ost,
-		lf:              lf,
-		maintenanceMode: false,
+		log:              log,
+		d:                d,
+		ost:              ost,
+		lf:               lf,
+		limits:           limits,
+		maintenanceMode:  false,
+		sealedSecretsKey: sealedSecretsKey,
+		repoTokenSD:      repoTokenSD,
+	}
+}
+
+// SealedSecretsPublicKey returns the public key used to seal the secrets
+func (h *ActionHandler) SealedSecretsPublicKey() (string, error) {
+	if h.sealedSecretsKey == nil {
+		return "", util.NewAPIError(util.ErrNotExist, errors.Errorf("sealed secrets aren't enabled"))
 	}
+	return h.sealedSecretsKey.EncodePublicKey(), nil
 }
 
 func (h *ActionHandler) SetMaintenanceMode(maintenanceMode bool) {
@@ -170,7 +190,7 @@ func (h *ActionHandler) ChangeRunPhase(ctx context.Context, req *RunChangePhaseR
 		}
 
 		run.ChangePhase(req.Phase)
-		runEvent, err := common.NewRunEvent(h.d, tx, run.ID, run.Phase, run.Result)
+		runEvent, err := common.NewRunEvent(h.d, tx, run)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -220,7 +240,8 @@ func (h *ActionHandler) StopRun(ctx context.Context, req *RunStopRequest) error
 			return errors.Errorf("run %s is not running but in %q phase", r.ID, r.Phase)
 		}
 		r.Stop = true
-		for _, t := range r.TasksWaitingApproval() {
+		waitingApproval := r.TasksWaitingApproval()
+		for _, t := range waitingApproval {
 			r.Tasks[t].WaitingApproval = false
 		}
 
@@ -228,6 +249,13 @@ func (h *ActionHandler) StopRun(ctx context.Context, req *RunStopRequest) error
 			return errors.WithStack(err)
 		}
 
+		// notify that the run isn't waiting for approval anymore
+		if len(waitingApproval) > 0 {
+			if err := h.insertRunEvent(tx, r); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -244,6 +272,14 @@ type RunCreateRequest struct {
 	SetupErrors       []string
 	StaticEnvironment map[string]string
 	CacheGroup        string
+	RepoTokenPath     string
+	Labels            map[string]string
+	DependsOn         []string
+	ConcurrencyGroups []*types.RunConcurrencyGroup
+	CancelSuperseded  bool
+	FailFast          bool
+	Triggers          []*types.RunConfigTrigger
+	SealedValues      map[string]types.SealedValue
 
 	// existing run fields
 	RunID      string
@@ -273,9 +309,32 @@ func (h *ActionHandler) CreateRun(ctx context.Context, req *RunCreateRequest) (*
 		return nil, errors.WithStack(err)
 	}
 
+	applyRunLimits(rb.Rc, h.limits)
+
 	return rb, h.saveRun(ctx, rb, runcgt)
 }
 
+// applyRunLimits sets the default values and caps the run config values to
+// the installation limits. It's also applied to recreated runs since the
+// limits could have been changed.
+func applyRunLimits(rc *types.RunConfig, limits config.RunLimits) {
+	if limits.MaxRunTimeout > 0 && (rc.Timeout == 0 || rc.Timeout > limits.MaxRunTimeout) {
+		rc.Timeout = limits.MaxRunTimeout
+	}
+	if limits.MaxStepLogSize > 0 && (rc.MaxStepLogSize == 0 || rc.MaxStepLogSize > limits.MaxStepLogSize) {
+		rc.MaxStepLogSize = limits.MaxStepLogSize
+	}
+
+	for _, rct := range rc.Tasks {
+		if rct.Timeout == 0 {
+			rct.Timeout = limits.DefaultTaskTimeout
+		}
+		if limits.MaxTaskTimeout > 0 && (rct.Timeout == 0 || rct.Timeout > limits.MaxTaskTimeout) {
+			rct.Timeout = limits.MaxTaskTimeout
+		}
+	}
+}
+
 func (h *ActionHandler) newRun(ctx context.Context, req *RunCreateRequest) (*types.RunBundle, error) {
 	rcts := req.RunConfigTasks
 	setupErrors := req.SetupErrors
@@ -289,6 +348,17 @@ func (h *ActionHandler) newRun(ctx context.Context, req *RunCreateRequest) (*typ
 	if req.RunConfigTasks == nil && len(setupErrors) == 0 {
 		return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty run config tasks and setup errors"))
 	}
+	if err := util.ValidateLabels(req.Labels); err != nil {
+		return nil, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "invalid run labels"))
+	}
+	for _, cg := range req.ConcurrencyGroups {
+		if cg.Name == "" {
+			return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty concurrency group name"))
+		}
+		if cg.MaxRunningRuns < 0 || cg.MaxRunningTasks < 0 {
+			return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("concurrency group %q limits must be greater or equal than 0", cg.Name))
+		}
+	}
 
 	if err := runconfig.CheckRunConfigTasks(rcts); err != nil {
 		h.log.Err(err).Msgf("check run config tasks failed")
@@ -311,9 +381,17 @@ func (h *ActionHandler) newRun(ctx context.Context, req *RunCreateRequest) (*typ
 	rc.StaticEnvironment = req.StaticEnvironment
 	rc.Environment = req.Environment
 	rc.Annotations = req.Annotations
+	rc.Labels = req.Labels
 	rc.CacheGroup = req.CacheGroup
+	rc.RepoTokenPath = req.RepoTokenPath
+	rc.FailFast = req.FailFast
+	rc.Triggers = req.Triggers
+	rc.SealedValues = req.SealedValues
 
 	run := genRun(rc)
+	run.DependsOn = req.DependsOn
+	run.ConcurrencyGroups = req.ConcurrencyGroups
+	run.CancelSuperseded = req.CancelSuperseded
 	h.log.Debug().Msgf("created run: %s", util.Dump(run))
 
 	return &types.RunBundle{
@@ -396,6 +474,8 @@ func recreateRun(uuid util.UUIDGenerator, run *types.Run, rc *types.RunConfig, n
 	run.Result = types.RunResultUnknown
 	run.Archived = false
 	run.Stop = false
+	// a recreated run doesn't wait for the runs it depended on
+	run.DependsOn = nil
 	run.EnqueueTime = nil
 	run.StartTime = nil
 	run.EndTime = nil
@@ -528,7 +608,7 @@ func (h *ActionHandler) saveRun(ctx context.Context, rb *types.RunBundle, runcgt
 
 		run.Counter = runCounter
 
-		runEvent, err := common.NewRunEvent(h.d, tx, run.ID, run.Phase, run.Result)
+		runEvent, err := common.NewRunEvent(h.d, tx, run)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -543,6 +623,10 @@ func (h *ActionHandler) saveRun(ctx context.Context, rb *types.RunBundle, runcgt
 			return errors.WithStack(err)
 		}
 
+		if err := h.cancelConcurrencyGroupsRuns(tx, run); err != nil {
+			return errors.WithStack(err)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -552,11 +636,78 @@ func (h *ActionHandler) saveRun(ctx context.Context, rb *types.RunBundle, runcgt
 	return nil
 }
 
+// cancelConcurrencyGroupsRuns stops the running runs and cancels the queued
+// runs sharing with the provided run a concurrency group with cancel in
+// progress
+func (h *ActionHandler) cancelConcurrencyGroupsRuns(tx *sql.Tx, run *types.Run) error {
+	cancelGroups := map[string]struct{}{}
+	for _, cg := range run.ConcurrencyGroups {
+		if cg.CancelInProgress {
+			cancelGroups[cg.Name] = struct{}{}
+		}
+	}
+	if len(cancelGroups) == 0 {
+		return nil
+	}
+
+	// the concurrency groups are scoped to the run base group
+	pl := util.PathList(run.Group)
+	if len(pl) < 2 {
+		return errors.Errorf("cannot determine run base group, wrong group path %q", run.Group)
+	}
+	baseGroup := path.Join("/", pl[0], pl[1])
+
+	runs, err := h.d.GetRuns(tx, []string{baseGroup}, false, []types.RunPhase{types.RunPhaseQueued, types.RunPhaseRunning}, nil, nil, 0, 0, types.SortOrderAsc)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, r := range runs {
+		if r.ID == run.ID || !sharesConcurrencyGroup(r, cancelGroups) {
+			continue
+		}
+
+		switch r.Phase {
+		case types.RunPhaseQueued:
+			h.log.Info().Msgf("cancelling run %s superseded by run %s", r.ID, run.ID)
+			r.ChangePhase(types.RunPhaseCancelled)
+		case types.RunPhaseRunning:
+			if r.Stop {
+				continue
+			}
+			h.log.Info().Msgf("stopping run %s superseded by run %s", r.ID, run.ID)
+			r.Stop = true
+			for _, t := range r.TasksWaitingApproval() {
+				r.Tasks[t].WaitingApproval = false
+			}
+		}
+
+		if err := h.d.UpdateRun(tx, r); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := h.insertRunEvent(tx, r); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+func sharesConcurrencyGroup(run *types.Run, groups map[string]struct{}) bool {
+	for _, cg := range run.ConcurrencyGroups {
+		if _, ok := groups[cg.Name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 func genRunTask(rct *types.RunConfigTask) *types.RunTask {
 	rt := &types.RunTask{
 		ID:                rct.ID,
 		Status:            types.RunTaskStatusNotStarted,
 		Skip:              rct.Skip,
+		Labels:            rct.Labels,
 		Steps:             make([]*types.RunTaskStep, len(rct.Steps)),
 		WorkspaceArchives: []int{},
 	}
@@ -594,6 +745,7 @@ func genRun(rc *types.RunConfig) *types.Run {
 	r.Name = rc.Name
 	r.Group = rc.Group
 	r.Annotations = rc.Annotations
+	r.Labels = rc.Labels
 	r.Phase = types.RunPhaseQueued
 	r.Result = types.RunResultUnknown
 	r.Tasks = make(map[string]*types.RunTask)
@@ -611,6 +763,109 @@ func genRun(rc *types.RunConfig) *types.Run {
 	return r
 }
 
+// deleteGroupRunsBatchSize is the max number of runs deleted in a single
+// transaction
+const deleteGroupRunsBatchSize = 100
+
+// DeleteGroupRuns permanently removes the runs of the group and of its
+// subgroups with their logs, workspace archives and artifacts. All the runs
+// must be finished.
+func (h *ActionHandler) DeleteGroupRuns(ctx context.Context, group string) error {
+	if !path.IsAbs(group) {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("run group %q must be an absolute path", group))
+	}
+
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		activeRuns, err := h.d.GetGroupRuns(tx, group, []types.RunPhase{types.RunPhaseQueued, types.RunPhaseRunning}, nil, nil, 0, 1, types.SortOrderAsc)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(activeRuns) > 0 {
+			return util.NewAPIError(util.ErrBadRequest, errors.Errorf("run group %q has active runs", group))
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for {
+		var runs []*types.Run
+		err := h.d.Do(ctx, func(tx *sql.Tx) error {
+			var err error
+			runs, err = h.d.GetGroupRuns(tx, group, nil, nil, nil, 0, deleteGroupRunsBatchSize, types.SortOrderAsc)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			for _, r := range runs {
+				executorTasks, err := h.d.GetExecutorTasksByRun(tx, r.ID)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				for _, et := range executorTasks {
+					if err := h.d.DeleteExecutorTask(tx, et.ID); err != nil {
+						return errors.WithStack(err)
+					}
+				}
+				if err := h.d.DeleteRunConfig(tx, r.RunConfigID); err != nil {
+					return errors.WithStack(err)
+				}
+				if err := h.d.DeleteRun(tx, r.ID); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		// the run objects are removed after the runs so a failure will only
+		// leave some orphaned objects
+		for _, r := range runs {
+			for rtID := range r.Tasks {
+				if err := store.DeleteObjects(h.ost, store.OSTRunTaskLogsBaseDir(rtID)); err != nil {
+					h.log.Warn().Err(err).Msgf("failed to delete run %q task %q logs", r.ID, rtID)
+				}
+				if err := store.DeleteObjects(h.ost, store.OSTRunTaskArchivesBaseDir(rtID)); err != nil {
+					h.log.Warn().Err(err).Msgf("failed to delete run %q task %q workspace archives", r.ID, rtID)
+				}
+			}
+			if err := store.DeleteObjects(h.ost, store.OSTRunArtifactsDir(r.ID)); err != nil {
+				h.log.Warn().Err(err).Msgf("failed to delete run %q artifacts", r.ID)
+			}
+			if err := store.DeleteObjects(h.ost, store.OSTRunTestReportsDir(r.ID)); err != nil {
+				h.log.Warn().Err(err).Msgf("failed to delete run %q test reports", r.ID)
+			}
+		}
+
+		if len(runs) < deleteGroupRunsBatchSize {
+			break
+		}
+	}
+
+	// remove the run counter of a base group
+	if pl := util.PathList(group); len(pl) == 2 {
+		err := h.d.Do(ctx, func(tx *sql.Tx) error {
+			runCounter, err := h.d.GetRunCounter(tx, pl[1])
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if runCounter == nil {
+				return nil
+			}
+			return errors.WithStack(h.d.DeleteRunCounter(tx, runCounter.ID))
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
 type RunTaskSetAnnotationsRequest struct {
 	RunID                   string
 	TaskID                  string
@@ -704,6 +959,13 @@ func (h *ActionHandler) ApproveRunTask(ctx context.Context, req *RunTaskApproveR
 			return errors.WithStack(err)
 		}
 
+		// notify that the run isn't waiting for approval anymore
+		if len(r.TasksWaitingApproval()) == 0 {
+			if err := h.insertRunEvent(tx, r); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -713,6 +975,15 @@ func (h *ActionHandler) ApproveRunTask(ctx context.Context, req *RunTaskApproveR
 	return nil
 }
 
+func (h *ActionHandler) insertRunEvent(tx *sql.Tx, r *types.Run) error {
+	runEvent, err := common.NewRunEvent(h.d, tx, r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(h.d.InsertRunEvent(tx, runEvent))
+}
+
 func (h *ActionHandler) getRunCounterGroupID(group string) (string, error) {
 	// use the first group dir after the root
 	pl := util.PathList(group)
@@ -757,7 +1028,10 @@ func (h *ActionHandler) GetExecutorTask(ctx context.Context, etID string) (*type
 		}
 
 		// generate ExecutorTaskSpecData
-		et.Spec.ExecutorTaskSpecData = common.GenExecutorTaskSpecData(r, rt, rc)
+		et.Spec.ExecutorTaskSpecData, err = common.GenExecutorTaskSpecData(r, rt, rc, h.sealedSecretsKey, h.repoTokenSD)
+		if err != nil {
+			return errors.WithStack(err)
+		}
 
 		return nil
 	})
@@ -801,7 +1075,10 @@ func (h *ActionHandler) GetExecutorTasks(ctx context.Context, executorID string)
 			}
 
 			// generate ExecutorTaskSpecData
-			et.Spec.ExecutorTaskSpecData = common.GenExecutorTaskSpecData(r, rt, rc)
+			et.Spec.ExecutorTaskSpecData, err = common.GenExecutorTaskSpecData(r, rt, rc, h.sealedSecretsKey, h.repoTokenSD)
+			if err != nil {
+				return errors.WithStack(err)
+			}
 		}
 
 		return nil
@@ -812,3 +1089,159 @@ func (h *ActionHandler) GetExecutorTasks(ctx context.Context, executorID string)
 
 	return ets, nil
 }
+
+func (h *ActionHandler) GetExecutors(ctx context.Context) ([]*types.Executor, error) {
+	var executors []*types.Executor
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		executors, err = h.d.GetExecutors(tx)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return executors, nil
+}
+
+// DrainExecutor puts the executor in draining state. The scheduler won't
+// schedule new tasks on it and the executor, when its active tasks are
+// finished, will deregister itself.
+func (h *ActionHandler) DrainExecutor(ctx context.Context, executorID string) error {
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		executor, err := h.d.GetExecutorByExecutorID(tx, executorID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if executor == nil {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("executor with executor id %s doesn't exist", executorID))
+		}
+
+		if executor.Draining {
+			return nil
+		}
+		executor.Draining = true
+
+		if err := h.d.InsertOrUpdateExecutor(tx, executor); err != nil {
+			return errors.WithStack(err)
+		}
+
+		return nil
+	})
+
+	return errors.WithStack(err)
+}
+
+// SetExecutorState sets the executor registration state. It's used by an
+// admin to approve a pending executor or to ban an executor.
+func (h *ActionHandler) SetExecutorState(ctx context.Context, executorID string, state types.ExecutorState) error {
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		executor, err := h.d.GetExecutorByExecutorID(tx, executorID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if executor == nil {
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("executor with executor id %s doesn't exist", executorID))
+		}
+
+		if executor.State == state {
+			return nil
+		}
+		executor.State = state
+
+		if err := h.d.InsertOrUpdateExecutor(tx, executor); err != nil {
+			return errors.WithStack(err)
+		}
+
+		return nil
+	})
+
+	return errors.WithStack(err)
+}
+
+// GetTasksDemand returns the run tasks ready to be executed but not yet
+// assigned to an executor grouped by their executor requirements. It could be
+// used by the executors provisioners to scale the executors capacity.
+func (h *ActionHandler) GetTasksDemand(ctx context.Context) ([]*types.TasksDemand, error) {
+	demands := []*types.TasksDemand{}
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		runs, err := h.d.GetRuns(tx, nil, false, []types.RunPhase{types.RunPhaseRunning}, nil, nil, 0, 0, types.SortOrderAsc)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		demandsMap := map[string]*types.TasksDemand{}
+		for _, r := range runs {
+			if r.Stop {
+				continue
+			}
+
+			rc, err := h.d.GetRunConfig(tx, r.RunConfigID)
+			if err != nil {
+				return errors.Wrapf(err, "cannot get run config %q", r.RunConfigID)
+			}
+			if rc == nil {
+				return errors.Errorf("runconfig %q doesn't exist", r.RunConfigID)
+			}
+
+			tasksToRun, err := common.GetTasksToRun(h.log, r, rc)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if len(tasksToRun) == 0 {
+				continue
+			}
+
+			ets, err := h.d.GetExecutorTasksByRun(tx, r.ID)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			scheduledTasks := map[string]struct{}{}
+			for _, et := range ets {
+				scheduledTasks[et.Spec.RunTaskID] = struct{}{}
+			}
+
+			for _, rt := range tasksToRun {
+				if _, ok := scheduledTasks[rt.ID]; ok {
+					continue
+				}
+
+				rct := rc.Tasks[rt.ID]
+				runtimeType := rct.Runtime.Type
+				if runtimeType == "" {
+					runtimeType = types.RuntimeTypePod
+				}
+				demand := &types.TasksDemand{
+					RuntimeType:    runtimeType,
+					Arch:           rct.Runtime.Arch,
+					ExecutorLabels: rct.Runtime.ExecutorLabels,
+					GPUs:           rct.Runtime.GPUs,
+				}
+
+				// json marshalling sorts the map keys so it can be used as the
+				// demand key
+				k, err := json.Marshal(demand)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				if d, ok := demandsMap[string(k)]; ok {
+					demand = d
+				} else {
+					demandsMap[string(k)] = demand
+					demands = append(demands, demand)
+				}
+				demand.PendingTasks++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return demands, nil
+}
@@ -16,7 +16,9 @@ package action
 
 import (
 	"testing"
+	"time"
 
+	"agola.io/agola/internal/services/config"
 	"agola.io/agola/internal/util"
 	"agola.io/agola/services/runservice/types"
 	stypes "agola.io/agola/services/types"
@@ -287,3 +289,99 @@ func TestRecreateRun(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyRunLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits config.RunLimits
+		rc     *types.RunConfig
+		outrc  *types.RunConfig
+	}{
+		{
+			name:   "no limits",
+			limits: config.RunLimits{},
+			rc: &types.RunConfig{
+				Tasks: map[string]*types.RunConfigTask{
+					"task01": {ID: "task01"},
+					"task02": {ID: "task02", Timeout: 2 * time.Hour},
+				},
+			},
+			outrc: &types.RunConfig{
+				Tasks: map[string]*types.RunConfigTask{
+					"task01": {ID: "task01"},
+					"task02": {ID: "task02", Timeout: 2 * time.Hour},
+				},
+			},
+		},
+		{
+			name: "default task timeout",
+			limits: config.RunLimits{
+				DefaultTaskTimeout: 1 * time.Hour,
+			},
+			rc: &types.RunConfig{
+				Tasks: map[string]*types.RunConfigTask{
+					"task01": {ID: "task01"},
+					"task02": {ID: "task02", Timeout: 2 * time.Hour},
+				},
+			},
+			outrc: &types.RunConfig{
+				Tasks: map[string]*types.RunConfigTask{
+					"task01": {ID: "task01", Timeout: 1 * time.Hour},
+					"task02": {ID: "task02", Timeout: 2 * time.Hour},
+				},
+			},
+		},
+		{
+			name: "max values",
+			limits: config.RunLimits{
+				DefaultTaskTimeout: 1 * time.Hour,
+				MaxTaskTimeout:     90 * time.Minute,
+				MaxRunTimeout:      3 * time.Hour,
+				MaxStepLogSize:     1024,
+			},
+			rc: &types.RunConfig{
+				Timeout:        5 * time.Hour,
+				MaxStepLogSize: 2048,
+				Tasks: map[string]*types.RunConfigTask{
+					"task01": {ID: "task01"},
+					"task02": {ID: "task02", Timeout: 2 * time.Hour},
+					"task03": {ID: "task03", Timeout: 30 * time.Minute},
+				},
+			},
+			outrc: &types.RunConfig{
+				Timeout:        3 * time.Hour,
+				MaxStepLogSize: 1024,
+				Tasks: map[string]*types.RunConfigTask{
+					"task01": {ID: "task01", Timeout: 1 * time.Hour},
+					"task02": {ID: "task02", Timeout: 90 * time.Minute},
+					"task03": {ID: "task03", Timeout: 30 * time.Minute},
+				},
+			},
+		},
+		{
+			name: "max task timeout without default",
+			limits: config.RunLimits{
+				MaxTaskTimeout: 90 * time.Minute,
+			},
+			rc: &types.RunConfig{
+				Tasks: map[string]*types.RunConfigTask{
+					"task01": {ID: "task01"},
+				},
+			},
+			outrc: &types.RunConfig{
+				Tasks: map[string]*types.RunConfigTask{
+					"task01": {ID: "task01", Timeout: 90 * time.Minute},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyRunLimits(tt.rc, tt.limits)
+			if diff := cmp.Diff(tt.outrc, tt.rc); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
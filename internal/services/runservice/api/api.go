@@ -15,13 +15,16 @@
 package api
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"agola.io/agola/internal/errors"
@@ -39,44 +42,39 @@ import (
 )
 
 type LogsHandler struct {
-	log zerolog.Logger
-	d   *db.DB
-	ost *objectstorage.ObjStorage
+	log            zerolog.Logger
+	d              *db.DB
+	ost            *objectstorage.ObjStorage
+	executorClient *http.Client
 }
 
-func NewLogsHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage) *LogsHandler {
+func NewLogsHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage, executorClient *http.Client) *LogsHandler {
 	return &LogsHandler{
-		log: log,
-		d:   d,
-		ost: ost,
+		log:            log,
+		d:              d,
+		ost:            ost,
+		executorClient: executorClient,
 	}
 }
 
-func (h *LogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
-	q := r.URL.Query()
-
+// parseLogsParams parses the common logs query params
+func parseLogsParams(q url.Values) (string, string, bool, int, error) {
 	runID := q.Get("runid")
 	if runID == "" {
-		http.Error(w, "", http.StatusBadRequest)
-		return
+		return "", "", false, 0, errors.Errorf("empty run id")
 	}
 	taskID := q.Get("taskid")
 	if taskID == "" {
-		http.Error(w, "", http.StatusBadRequest)
-		return
+		return "", "", false, 0, errors.Errorf("empty task id")
 	}
 
 	_, setup := q["setup"]
 	stepStr := q.Get("step")
 	if !setup && stepStr == "" {
-		http.Error(w, "", http.StatusBadRequest)
-		return
+		return "", "", false, 0, errors.Errorf("no setup or step number provided")
 	}
 	if setup && stepStr != "" {
-		http.Error(w, "", http.StatusBadRequest)
-		return
+		return "", "", false, 0, errors.Errorf("both setup and step number provided")
 	}
 
 	var step int
@@ -84,17 +82,112 @@ func (h *LogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		var err error
 		step, err = strconv.Atoi(stepStr)
 		if err != nil {
-			http.Error(w, "", http.StatusBadRequest)
-			return
+			return "", "", false, 0, errors.Wrapf(err, "cannot parse step number")
 		}
 	}
 
+	return runID, taskID, setup, step, nil
+}
+
+// parseLogsRange parses the logs range query params. It returns nil if no
+// range is requested.
+func parseLogsRange(q url.Values) (*rsapitypes.LogsRange, error) {
+	unitStr := q.Get("unit")
+	offsetStr := q.Get("offset")
+	limitStr := q.Get("limit")
+	if unitStr == "" && offsetStr == "" && limitStr == "" {
+		return nil, nil
+	}
+
+	logsRange := &rsapitypes.LogsRange{Unit: rsapitypes.LogsRangeUnitLine}
+	if unitStr != "" {
+		logsRange.Unit = rsapitypes.LogsRangeUnit(unitStr)
+	}
+	switch logsRange.Unit {
+	case rsapitypes.LogsRangeUnitLine:
+	case rsapitypes.LogsRangeUnitByte:
+	default:
+		return nil, errors.Errorf("wrong logs range unit %q", unitStr)
+	}
+
+	if offsetStr != "" {
+		offset, err := strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil || offset < 0 {
+			return nil, errors.Errorf("wrong logs range offset %q", offsetStr)
+		}
+		logsRange.Offset = offset
+	}
+	if limitStr != "" {
+		limit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || limit < 0 {
+			return nil, errors.Errorf("wrong logs range limit %q", limitStr)
+		}
+		logsRange.Limit = limit
+	}
+
+	return logsRange, nil
+}
+
+// logsRangeReader returns a reader that returns only the logs in the provided
+// range
+func logsRangeReader(r io.Reader, logsRange *rsapitypes.LogsRange) (io.Reader, error) {
+	if logsRange == nil {
+		return r, nil
+	}
+
+	switch logsRange.Unit {
+	case rsapitypes.LogsRangeUnitByte:
+		if logsRange.Offset > 0 {
+			// avoid reading all the skipped bytes when the reader is seekable
+			if rs, ok := r.(io.Seeker); ok {
+				if _, err := rs.Seek(logsRange.Offset, io.SeekStart); err != nil {
+					return nil, errors.WithStack(err)
+				}
+			} else if _, err := io.CopyN(ioutil.Discard, r, logsRange.Offset); err != nil && !errors.Is(err, io.EOF) {
+				return nil, errors.WithStack(err)
+			}
+		}
+		if logsRange.Limit > 0 {
+			r = io.LimitReader(r, logsRange.Limit)
+		}
+
+	case rsapitypes.LogsRangeUnitLine:
+		br := bufio.NewReader(r)
+		if err := util.SkipLines(br, logsRange.Offset); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		r = br
+		if logsRange.Limit > 0 {
+			r = util.LineLimitReader(r, logsRange.Limit)
+		}
+	}
+
+	return r, nil
+}
+
+func (h *LogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	q := r.URL.Query()
+
+	runID, taskID, setup, step, err := parseLogsParams(q)
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	logsRange, err := parseLogsRange(q)
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
 	follow := false
 	if _, ok := q["follow"]; ok {
 		follow = true
 	}
 
-	if sendError, err := h.readTaskLogs(ctx, runID, taskID, setup, step, w, follow); err != nil {
+	if sendError, err := h.readTaskLogs(ctx, runID, taskID, setup, step, w, follow, logsRange); err != nil {
 		h.log.Err(err).Send()
 		if sendError {
 			switch {
@@ -107,30 +200,69 @@ func (h *LogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *LogsHandler) readTaskLogs(ctx context.Context, runID, taskID string, setup bool, step int, w http.ResponseWriter, follow bool) (bool, error) {
+func (h *LogsHandler) readTaskLogs(ctx context.Context, runID, taskID string, setup bool, step int, w http.ResponseWriter, follow bool, logsRange *rsapitypes.LogsRange) (bool, error) {
+	lr, err := openTaskLogs(ctx, h.d, h.ost, h.executorClient, runID, taskID, setup, step, follow)
+	if err != nil {
+		return true, errors.WithStack(err)
+	}
+	defer lr.Close()
+
+	r, err := logsRangeReader(lr.ReadCloser, logsRange)
+	if err != nil {
+		return true, errors.WithStack(err)
+	}
+
+	if !lr.fetched {
+		// write and flush the headers so the client will receive the response
+		// header also if there're currently no lines to send
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		var flusher http.Flusher
+		if fl, ok := w.(http.Flusher); ok {
+			flusher = fl
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	return false, sendLogs(w, r)
+}
+
+// taskLogsReader reads a task log from the object storage if already fetched
+// or from the executor
+type taskLogsReader struct {
+	io.ReadCloser
+
+	// fetched reports if the log has already been fetched from the executor
+	fetched bool
+}
+
+func openTaskLogs(ctx context.Context, d *db.DB, ost *objectstorage.ObjStorage, executorClient *http.Client, runID, taskID string, setup bool, step int, follow bool) (*taskLogsReader, error) {
 	var r *types.Run
-	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+	err := d.Do(ctx, func(tx *sql.Tx) error {
 		var err error
-		r, err = h.d.GetRun(tx, runID)
+		r, err = d.GetRun(tx, runID)
 		if err != nil {
 			return errors.WithStack(err)
 		}
 		return nil
 	})
 	if err != nil {
-		return true, errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 
 	if r == nil {
-		return true, util.NewAPIError(util.ErrNotExist, errors.Errorf("no such run with id: %s", runID))
+		return nil, util.NewAPIError(util.ErrNotExist, errors.Errorf("no such run with id: %s", runID))
 	}
 
 	task, ok := r.Tasks[taskID]
 	if !ok {
-		return true, util.NewAPIError(util.ErrNotExist, errors.Errorf("no such task with ID %s in run %s", taskID, runID))
+		return nil, util.NewAPIError(util.ErrNotExist, errors.Errorf("no such task with ID %s in run %s", taskID, runID))
 	}
 	if len(task.Steps) <= step {
-		return true, util.NewAPIError(util.ErrNotExist, errors.Errorf("no such step for task %s in run %s", taskID, runID))
+		return nil, util.NewAPIError(util.ErrNotExist, errors.Errorf("no such step for task %s in run %s", taskID, runID))
 	}
 
 	// if the log has been already fetched use it, otherwise fetch it from the executor
@@ -141,31 +273,49 @@ func (h *LogsHandler) readTaskLogs(ctx context.Context, runID, taskID string, se
 		} else {
 			logPath = store.OSTRunTaskStepLogPath(task.ID, step)
 		}
-		f, err := h.ost.ReadObject(logPath)
+		f, err := ost.ReadObject(logPath)
 		if err != nil {
 			if objectstorage.IsNotExist(err) {
-				return true, util.NewAPIError(util.ErrNotExist, err)
+				return nil, util.NewAPIError(util.ErrNotExist, err)
 			}
-			return true, errors.WithStack(err)
+			return nil, errors.WithStack(err)
+		}
+		return &taskLogsReader{ReadCloser: f, fetched: true}, nil
+	}
+
+	lr, err := openExecutorTaskLogs(ctx, d, executorClient, runID, task.ID, setup, step, follow)
+	if err != nil {
+		// the executor may be lost, use the log chunks uploaded by the executor
+		// if available
+		cr, ok, cerr := store.ReadLogChunks(ost, task.ID, setup, step)
+		if cerr != nil {
+			return nil, errors.WithStack(cerr)
+		}
+		if !ok {
+			return nil, errors.WithStack(err)
 		}
-		defer f.Close()
-		return false, sendLogs(w, f)
+		return &taskLogsReader{ReadCloser: cr}, nil
 	}
 
+	return lr, nil
+}
+
+// openExecutorTaskLogs reads a task log from the executor executing the task
+func openExecutorTaskLogs(ctx context.Context, d *db.DB, executorClient *http.Client, runID, rtID string, setup bool, step int, follow bool) (*taskLogsReader, error) {
 	var et *types.ExecutorTask
 	var executor *types.Executor
-	err = h.d.Do(ctx, func(tx *sql.Tx) error {
+	err := d.Do(ctx, func(tx *sql.Tx) error {
 		var err error
 
-		et, err = h.d.GetExecutorTaskByRunTask(tx, runID, task.ID)
+		et, err = d.GetExecutorTaskByRunTask(tx, runID, rtID)
 		if err != nil {
 			return errors.WithStack(err)
 		}
 		if et == nil {
-			return util.NewAPIError(util.ErrNotExist, errors.Errorf("executor task for run task with id %q doesn't exist", task.ID))
+			return util.NewAPIError(util.ErrNotExist, errors.Errorf("executor task for run task with id %q doesn't exist", rtID))
 		}
 
-		executor, err = h.d.GetExecutorByExecutorID(tx, et.Spec.ExecutorID)
+		executor, err = d.GetExecutorByExecutorID(tx, et.Spec.ExecutorID)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -176,7 +326,7 @@ func (h *LogsHandler) readTaskLogs(ctx context.Context, runID, taskID string, se
 		return nil
 	})
 	if err != nil {
-		return true, errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 
 	var url string
@@ -188,32 +338,83 @@ func (h *LogsHandler) readTaskLogs(ctx context.Context, runID, taskID string, se
 	if follow {
 		url += "&follow"
 	}
-	req, err := http.Get(url)
+	req, err := executorClient.Get(url)
 	if err != nil {
-		return true, errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
-	defer req.Body.Close()
 	if req.StatusCode != http.StatusOK {
+		req.Body.Close()
 		if req.StatusCode == http.StatusNotFound {
-			return true, util.NewAPIError(util.ErrNotExist, errors.New("no log on executor"))
+			return nil, util.NewAPIError(util.ErrNotExist, errors.New("no log on executor"))
 		}
-		return true, errors.Errorf("received http status: %d", req.StatusCode)
+		return nil, errors.Errorf("received http status: %d", req.StatusCode)
 	}
 
-	// write and flush the headers so the client will receive the response
-	// header also if there're currently no lines to send
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.WriteHeader(http.StatusOK)
-	var flusher http.Flusher
-	if fl, ok := w.(http.Flusher); ok {
-		flusher = fl
+	return &taskLogsReader{ReadCloser: req.Body}, nil
+}
+
+type LogsInfoHandler struct {
+	log            zerolog.Logger
+	d              *db.DB
+	ost            *objectstorage.ObjStorage
+	executorClient *http.Client
+}
+
+func NewLogsInfoHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage, executorClient *http.Client) *LogsInfoHandler {
+	return &LogsInfoHandler{
+		log:            log,
+		d:              d,
+		ost:            ost,
+		executorClient: executorClient,
 	}
-	if flusher != nil {
-		flusher.Flush()
+}
+
+func (h *LogsInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	q := r.URL.Query()
+
+	runID, taskID, setup, step, err := parseLogsParams(q)
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+
+	res, err := h.logsInfo(ctx, runID, taskID, setup, step)
+	if err != nil {
+		h.log.Err(err).Send()
+		switch {
+		case util.APIErrorIs(err, util.ErrNotExist):
+			util.HTTPError(w, util.NewAPIError(util.ErrNotExist, errors.Wrapf(err, "log doesn't exist")))
+		default:
+			util.HTTPError(w, err)
+		}
+		return
 	}
 
-	return false, sendLogs(w, req.Body)
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+func (h *LogsInfoHandler) logsInfo(ctx context.Context, runID, taskID string, setup bool, step int) (*rsapitypes.LogsInfoResponse, error) {
+	lr, err := openTaskLogs(ctx, h.d, h.ost, h.executorClient, runID, taskID, setup, step, false)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer lr.Close()
+
+	size, lines, err := util.CountReaderLines(lr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &rsapitypes.LogsInfoResponse{
+		Size:  size,
+		Lines: lines,
+		// a not yet fetched log could still grow
+		Complete: lr.fetched,
+	}, nil
 }
 
 func sendLogs(w http.ResponseWriter, r io.Reader) error {
@@ -397,6 +598,57 @@ func (h *ChangeGroupsUpdateTokensHandler) ServeHTTP(w http.ResponseWriter, r *ht
 	}
 }
 
+type TasksDemandHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewTasksDemandHandler(log zerolog.Logger, ah *action.ActionHandler) *TasksDemandHandler {
+	return &TasksDemandHandler{
+		log: log,
+		ah:  ah,
+	}
+}
+
+func (h *TasksDemandHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	demands, err := h.ah.GetTasksDemand(ctx)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, demands); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type SealedSecretsPublicKeyHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewSealedSecretsPublicKeyHandler(log zerolog.Logger, ah *action.ActionHandler) *SealedSecretsPublicKeyHandler {
+	return &SealedSecretsPublicKeyHandler{
+		log: log,
+		ah:  ah,
+	}
+}
+
+func (h *SealedSecretsPublicKeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := h.ah.SealedSecretsPublicKey()
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := &rsapitypes.SealedSecretsPublicKeyResponse{PublicKey: publicKey}
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
 type RunHandler struct {
 	log zerolog.Logger
 	d   *db.DB
@@ -576,6 +828,24 @@ const (
 	MaxRunEventsLimit = 40
 )
 
+// parseLabelFilter parses the label filters provided as "key=value"
+func parseLabelFilter(labels []string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	labelFilter := make(map[string]string, len(labels))
+	for _, l := range labels {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			return nil, util.NewAPIError(util.ErrBadRequest, errors.Errorf("wrong label filter %q, must be in the format key=value", l))
+		}
+		labelFilter[kv[0]] = kv[1]
+	}
+
+	return labelFilter, nil
+}
+
 type RunsHandler struct {
 	log zerolog.Logger
 	d   *db.DB
@@ -596,6 +866,11 @@ func (h *RunsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	phaseFilter := types.RunPhaseFromStringSlice(query["phase"])
 	resultFilter := types.RunResultFromStringSlice(query["result"])
+	labelFilter, err := parseLabelFilter(query["label"])
+	if err != nil {
+		util.HTTPError(w, err)
+		return
+	}
 
 	changeGroups := query["changegroup"]
 	groups := query["group"]
@@ -637,9 +912,9 @@ func (h *RunsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var runs []*types.Run
 	var cgt *types.ChangeGroupsUpdateToken
 
-	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+	err = h.d.Do(ctx, func(tx *sql.Tx) error {
 		var err error
-		runs, err = h.d.GetRuns(tx, groups, lastRun, phaseFilter, resultFilter, startRunSequence, limit, sortOrder)
+		runs, err = h.d.GetRuns(tx, groups, lastRun, phaseFilter, resultFilter, labelFilter, startRunSequence, limit, sortOrder)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -689,6 +964,11 @@ func (h *RunsByGroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	phaseFilter := types.RunPhaseFromStringSlice(query["phase"])
 	resultFilter := types.RunResultFromStringSlice(query["result"])
+	labelFilter, err := parseLabelFilter(query["label"])
+	if err != nil {
+		util.HTTPError(w, err)
+		return
+	}
 
 	changeGroups := query["changegroup"]
 
@@ -736,7 +1016,7 @@ func (h *RunsByGroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	err = h.d.Do(ctx, func(tx *sql.Tx) error {
 		var err error
-		runs, err = h.d.GetGroupRuns(tx, group, phaseFilter, resultFilter, startRunCounter, limit, sortOrder)
+		runs, err = h.d.GetGroupRuns(tx, group, phaseFilter, resultFilter, labelFilter, startRunCounter, limit, sortOrder)
 		if err != nil {
 			h.log.Err(err).Send()
 			return errors.WithStack(err)
@@ -765,6 +1045,39 @@ func (h *RunsByGroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+type RunsByGroupDeleteHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewRunsByGroupDeleteHandler(log zerolog.Logger, ah *action.ActionHandler) *RunsByGroupDeleteHandler {
+	return &RunsByGroupDeleteHandler{
+		log: log,
+		ah:  ah,
+	}
+}
+
+func (h *RunsByGroupDeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	group, err := url.PathUnescape(vars["group"])
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "cannot parse group")))
+		return
+	}
+
+	err = h.ah.DeleteGroupRuns(ctx, group)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusNoContent, nil); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
 type RunCreateHandler struct {
 	log zerolog.Logger
 	ah  *action.ActionHandler
@@ -794,6 +1107,14 @@ func (h *RunCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		SetupErrors:       req.SetupErrors,
 		StaticEnvironment: req.StaticEnvironment,
 		CacheGroup:        req.CacheGroup,
+		RepoTokenPath:     req.RepoTokenPath,
+		Labels:            req.Labels,
+		DependsOn:         req.DependsOn,
+		ConcurrencyGroups: req.ConcurrencyGroups,
+		CancelSuperseded:  req.CancelSuperseded,
+		FailFast:          req.FailFast,
+		Triggers:          req.Triggers,
+		SealedValues:      req.SealedValues,
 
 		RunID:      req.RunID,
 		FromStart:  req.FromStart,
@@ -0,0 +1,277 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/services/runservice/db"
+	"agola.io/agola/internal/services/runservice/store"
+	"agola.io/agola/internal/sql"
+	"agola.io/agola/internal/util"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+)
+
+// checkExecutorRunTask verifies that the run task is assigned to the executor
+func checkExecutorRunTask(ctx context.Context, d *db.DB, executorID, runID, taskID string) error {
+	return d.Do(ctx, func(tx *sql.Tx) error {
+		et, err := d.GetExecutorTaskByRunTask(tx, runID, taskID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if et == nil || et.Spec.ExecutorID != executorID {
+			return util.NewAPIError(util.ErrForbidden, errors.Errorf("run %q task %q isn't assigned to executor %s", runID, taskID, executorID))
+		}
+		return nil
+	})
+}
+
+// artifactsRunTaskIDs returns the run and task ids from the request vars
+func artifactsRunTaskIDs(vars map[string]string) (string, string, error) {
+	runID := vars["runid"]
+	if runID == "" || runID == "." || runID == ".." {
+		return "", "", errors.Errorf("wrong run id %q", runID)
+	}
+	taskID := vars["taskid"]
+	if taskID == "" || taskID == "." || taskID == ".." {
+		return "", "", errors.Errorf("wrong task id %q", taskID)
+	}
+
+	return runID, taskID, nil
+}
+
+type ArtifactsHandler struct {
+	log zerolog.Logger
+	d   *db.DB
+	ost *objectstorage.ObjStorage
+}
+
+func NewArtifactsHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage) *ArtifactsHandler {
+	return &ArtifactsHandler{
+		log: log,
+		d:   d,
+		ost: ost,
+	}
+}
+
+func (h *ArtifactsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	executorID := requestExecutorID(r)
+
+	runID, taskID, err := artifactsRunTaskIDs(vars)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the artifacts of every task of a run executed by the executor can be
+	// restored
+	err = h.d.Do(ctx, func(tx *sql.Tx) error {
+		ets, err := h.d.GetExecutorTasksByExecutor(tx, executorID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, et := range ets {
+			if et.Spec.RunID == runID {
+				return nil
+			}
+		}
+		return util.NewAPIError(util.ErrForbidden, errors.Errorf("run %q isn't executed by executor %s", runID, executorID))
+	})
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	f, err := store.ReadTaskArtifacts(h.ost, runID, taskID)
+	if err != nil {
+		switch {
+		case util.APIErrorIs(err, util.ErrNotExist):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if _, err := io.Copy(w, f); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type ArtifactsCreateHandler struct {
+	log zerolog.Logger
+	d   *db.DB
+	ost *objectstorage.ObjStorage
+	// maxArtifactsSize is the max artifacts archive size. 0 means no limit
+	maxArtifactsSize int64
+}
+
+func NewArtifactsCreateHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage, maxArtifactsSize int64) *ArtifactsCreateHandler {
+	return &ArtifactsCreateHandler{
+		log:              log,
+		d:                d,
+		ost:              ost,
+		maxArtifactsSize: maxArtifactsSize,
+	}
+}
+
+func (h *ArtifactsCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	runID, taskID, err := artifactsRunTaskIDs(vars)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkExecutorRunTask(ctx, h.d, requestExecutorID(r), runID, taskID); util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+
+	size := int64(-1)
+	sizeStr := r.Header.Get("Content-Length")
+	if sizeStr != "" {
+		size, err = strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+	}
+	if h.maxArtifactsSize > 0 {
+		if size < 0 {
+			http.Error(w, "artifacts size is required", http.StatusLengthRequired)
+			return
+		}
+		if size > h.maxArtifactsSize {
+			http.Error(w, fmt.Sprintf("artifacts size %d greater than max artifacts size %d", size, h.maxArtifactsSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	if _, err := store.WriteTaskArtifacts(h.ost, runID, taskID, r.Body, size); err != nil {
+		h.log.Err(err).Send()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+type RunArtifactsHandler struct {
+	log zerolog.Logger
+	ost *objectstorage.ObjStorage
+}
+
+func NewRunArtifactsHandler(log zerolog.Logger, ost *objectstorage.ObjStorage) *RunArtifactsHandler {
+	return &RunArtifactsHandler{
+		log: log,
+		ost: ost,
+	}
+}
+
+func (h *RunArtifactsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	runID := vars["runid"]
+	if runID == "" || runID == "." || runID == ".." {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("wrong run id %q", runID)))
+		return
+	}
+
+	runArtifacts, err := store.ListRunArtifacts(h.ost, runID)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := &rsapitypes.RunArtifactsResponse{
+		Artifacts: []*rsapitypes.ArtifactResponse{},
+	}
+	for taskID, artifacts := range runArtifacts {
+		for _, ai := range artifacts {
+			res.Artifacts = append(res.Artifacts, &rsapitypes.ArtifactResponse{
+				TaskID: taskID,
+				Path:   ai.Path,
+				Size:   ai.Size,
+				Mode:   ai.Mode,
+			})
+		}
+	}
+	sort.Slice(res.Artifacts, func(i, j int) bool {
+		if res.Artifacts[i].TaskID == res.Artifacts[j].TaskID {
+			return res.Artifacts[i].Path < res.Artifacts[j].Path
+		}
+		return res.Artifacts[i].TaskID < res.Artifacts[j].TaskID
+	})
+
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type RunTaskArtifactHandler struct {
+	log zerolog.Logger
+	ost *objectstorage.ObjStorage
+}
+
+func NewRunTaskArtifactHandler(log zerolog.Logger, ost *objectstorage.ObjStorage) *RunTaskArtifactHandler {
+	return &RunTaskArtifactHandler{
+		log: log,
+		ost: ost,
+	}
+}
+
+func (h *RunTaskArtifactHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	runID, taskID, err := artifactsRunTaskIDs(vars)
+	if err != nil {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, err))
+		return
+	}
+	artifact := r.URL.Query().Get("path")
+	if artifact == "" {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("empty artifact path")))
+		return
+	}
+
+	f, ai, err := store.ReadTaskArtifact(h.ost, runID, taskID, artifact)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(ai.Size, 10))
+	if _, err := io.Copy(w, f); err != nil {
+		h.log.Err(err).Send()
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/services/runservice/store"
+	"agola.io/agola/internal/util"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+)
+
+type CacheGroupHandler struct {
+	log zerolog.Logger
+	ost *objectstorage.ObjStorage
+	// cacheGroupQuota is the max size of the caches of a cache group. 0 means
+	// no quota
+	cacheGroupQuota int64
+}
+
+func NewCacheGroupHandler(log zerolog.Logger, ost *objectstorage.ObjStorage, cacheGroupQuota int64) *CacheGroupHandler {
+	return &CacheGroupHandler{
+		log:             log,
+		ost:             ost,
+		cacheGroupQuota: cacheGroupQuota,
+	}
+}
+
+func (h *CacheGroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	group := vars["group"]
+	if group == "" || group == "." || group == ".." {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("wrong cache group %q", group)))
+		return
+	}
+
+	caches, err := store.ListCaches(h.ost, group)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := &rsapitypes.CacheGroupResponse{
+		Group:  group,
+		Quota:  h.cacheGroupQuota,
+		Caches: make([]*rsapitypes.CacheResponse, len(caches)),
+	}
+	for i, ci := range caches {
+		res.Size += ci.Size
+		res.Caches[i] = &rsapitypes.CacheResponse{
+			Key:      ci.Key,
+			Size:     ci.Size,
+			LastUsed: ci.LastUsed,
+		}
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type CacheGroupDeleteHandler struct {
+	log zerolog.Logger
+	ost *objectstorage.ObjStorage
+}
+
+func NewCacheGroupDeleteHandler(log zerolog.Logger, ost *objectstorage.ObjStorage) *CacheGroupDeleteHandler {
+	return &CacheGroupDeleteHandler{
+		log: log,
+		ost: ost,
+	}
+}
+
+func (h *CacheGroupDeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	group := vars["group"]
+	if group == "" || group == "." || group == ".." {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("wrong cache group %q", group)))
+		return
+	}
+
+	err := store.DeleteCacheGroup(h.ost, group)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusNoContent, nil); err != nil {
+		h.log.Err(err).Send()
+	}
+}
@@ -17,39 +17,170 @@ package api
 import (
 	"bufio"
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/services/config"
 	"agola.io/agola/internal/services/runservice/action"
 	"agola.io/agola/internal/services/runservice/common"
 	"agola.io/agola/internal/services/runservice/db"
 	"agola.io/agola/internal/services/runservice/store"
 	"agola.io/agola/internal/sql"
 	"agola.io/agola/internal/util"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
 	"agola.io/agola/services/runservice/types"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
 )
 
+// checkExecutorToken verifies that the calling executor isn't banned and that
+// it provided the registration token saved at its registration
+func checkExecutorToken(executor *types.Executor, token string) error {
+	if executor.State == types.ExecutorStateBanned {
+		return util.NewAPIError(util.ErrForbidden, errors.Errorf("executor %s is banned", executor.ExecutorID))
+	}
+	if executor.TokenHash == "" {
+		return util.NewAPIError(util.ErrForbidden, errors.Errorf("executor %s has no registration token", executor.ExecutorID))
+	}
+	if subtle.ConstantTimeCompare([]byte(util.EncodeSha256Hex(token)), []byte(executor.TokenHash)) != 1 {
+		return util.NewAPIError(util.ErrForbidden, errors.Errorf("wrong executor %s registration token", executor.ExecutorID))
+	}
+
+	return nil
+}
+
+// requestExecutorID returns the id of the executor calling the api. It's
+// provided in the request path or, for the apis not scoped to an executor, in
+// the executor id header
+func requestExecutorID(r *http.Request) string {
+	if executorID, ok := mux.Vars(r)["executorid"]; ok {
+		return executorID
+	}
+	return r.Header.Get(rsapitypes.ExecutorIDHeader)
+}
+
+// ExecutorAuthHandler verifies the calls of a registered executor to its
+// dedicated api. When requireApproved is true only approved executors are
+// allowed
+type ExecutorAuthHandler struct {
+	log             zerolog.Logger
+	d               *db.DB
+	requireApproved bool
+	next            http.Handler
+}
+
+func NewExecutorAuthHandler(log zerolog.Logger, d *db.DB, requireApproved bool, next http.Handler) *ExecutorAuthHandler {
+	return &ExecutorAuthHandler{log: log, d: d, requireApproved: requireApproved, next: next}
+}
+
+func (h *ExecutorAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	executorID := requestExecutorID(r)
+
+	var executor *types.Executor
+	err := h.d.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		executor, err = h.d.GetExecutorByExecutorID(tx, executorID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if executor == nil {
+			return util.NewAPIError(util.ErrForbidden, errors.Errorf("executor %q isn't registered", executorID))
+		}
+		if err := checkExecutorToken(executor, r.Header.Get(rsapitypes.ExecutorTokenHeader)); err != nil {
+			return errors.WithStack(err)
+		}
+		if h.requireApproved && !executor.IsApproved() {
+			return util.NewAPIError(util.ErrForbidden, errors.Errorf("executor %s isn't approved", executorID))
+		}
+		return nil
+	})
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// executorRuns returns the runs of the tasks assigned to the executor
+func executorRuns(tx *sql.Tx, d *db.DB, executorID string) ([]*types.Run, error) {
+	ets, err := d.GetExecutorTasksByExecutor(tx, executorID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var runs []*types.Run
+	seen := map[string]struct{}{}
+	for _, et := range ets {
+		if _, ok := seen[et.Spec.RunID]; ok {
+			continue
+		}
+		seen[et.Spec.RunID] = struct{}{}
+
+		r, err := d.GetRun(tx, et.Spec.RunID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if r == nil {
+			continue
+		}
+		runs = append(runs, r)
+	}
+
+	return runs, nil
+}
+
+// executorHealthProblems returns the executor health values exceeding the
+// configured thresholds
+func executorHealthProblems(h *types.ExecutorHealth, c config.ExecutorsHealth) []string {
+	if h == nil {
+		return nil
+	}
+
+	var problems []string
+	if c.MinDiskFreePercent > 0 && h.DiskTotal > 0 {
+		freePercent := float64(h.DiskFree) * 100 / float64(h.DiskTotal)
+		if freePercent < float64(c.MinDiskFreePercent) {
+			problems = append(problems, fmt.Sprintf("disk free %.1f%% is lower than %d%%", freePercent, c.MinDiskFreePercent))
+		}
+	}
+	if c.MaxLoadPerCPU > 0 && h.CPUs > 0 {
+		loadPerCPU := h.Load1 / float64(h.CPUs)
+		if loadPerCPU > c.MaxLoadPerCPU {
+			problems = append(problems, fmt.Sprintf("load per cpu %.2f is greater than %.2f", loadPerCPU, c.MaxLoadPerCPU))
+		}
+	}
+
+	return problems
+}
+
 type ExecutorStatusHandler struct {
-	log zerolog.Logger
-	d   *db.DB
-	ah  *action.ActionHandler
+	log               zerolog.Logger
+	d                 *db.DB
+	ah                *action.ActionHandler
+	executorsApproval bool
+	executorsHealth   config.ExecutorsHealth
 }
 
-func NewExecutorStatusHandler(log zerolog.Logger, d *db.DB, ah *action.ActionHandler) *ExecutorStatusHandler {
-	return &ExecutorStatusHandler{log: log, d: d, ah: ah}
+func NewExecutorStatusHandler(log zerolog.Logger, d *db.DB, ah *action.ActionHandler, executorsApproval bool, executorsHealth config.ExecutorsHealth) *ExecutorStatusHandler {
+	return &ExecutorStatusHandler{log: log, d: d, ah: ah, executorsApproval: executorsApproval, executorsHealth: executorsHealth}
 }
 
 func (h *ExecutorStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// TODO(sgotti) Check authorized call from executors
+	token := r.Header.Get(rsapitypes.ExecutorTokenHeader)
+
 	var recExecutor *types.Executor
 	d := json.NewDecoder(r.Body)
 	defer r.Body.Close()
@@ -69,8 +200,30 @@ func (h *ExecutorStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 			return errors.WithStack(err)
 		}
 
-		if executor == nil {
+		if token == "" {
+			return util.NewAPIError(util.ErrForbidden, errors.Errorf("executor %s registration token is required", recExecutor.ExecutorID))
+		}
+
+		switch {
+		case executor == nil:
 			executor = types.NewExecutor()
+			executor.State = types.ExecutorStateApproved
+			if h.executorsApproval {
+				executor.State = types.ExecutorStatePending
+				h.log.Info().Msgf("executor %s registered, waiting for approval", recExecutor.ExecutorID)
+			}
+		case executor.TokenHash == "" && executor.State != types.ExecutorStateBanned:
+			// executor registered before the introduction of the
+			// registration tokens, its token is saved below
+		default:
+			if err := checkExecutorToken(executor, token); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		// save the token of new executors and of executors registered before
+		// the introduction of the registration tokens
+		if executor.TokenHash == "" {
+			executor.TokenHash = util.EncodeSha256Hex(token)
 		}
 
 		executor.ExecutorID = recExecutor.ExecutorID
@@ -78,11 +231,23 @@ func (h *ExecutorStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		executor.Archs = recExecutor.Archs
 		executor.Labels = recExecutor.Labels
 		executor.AllowPrivilegedContainers = recExecutor.AllowPrivilegedContainers
+		executor.PrivilegedContainersProjects = recExecutor.PrivilegedContainersProjects
+		executor.RuntimeType = recExecutor.RuntimeType
+		executor.GPUs = recExecutor.GPUs
 		executor.ActiveTasksLimit = recExecutor.ActiveTasksLimit
 		executor.ActiveTasks = recExecutor.ActiveTasks
+		executor.LabelsActiveTasksLimits = recExecutor.LabelsActiveTasksLimits
 		executor.Dynamic = recExecutor.Dynamic
 		executor.ExecutorGroup = recExecutor.ExecutorGroup
 		executor.SiblingsExecutors = recExecutor.SiblingsExecutors
+		executor.Version = recExecutor.Version
+		executor.Features = recExecutor.Features
+		executor.Health = recExecutor.Health
+		wasHealthy := executor.IsHealthy()
+		executor.HealthProblems = executorHealthProblems(recExecutor.Health, h.executorsHealth)
+		if wasHealthy && !executor.IsHealthy() {
+			h.log.Warn().Msgf("executor %s is unhealthy: %s", executor.ExecutorID, strings.Join(executor.HealthProblems, ", "))
+		}
 
 		if err := h.d.InsertOrUpdateExecutor(tx, executor); err != nil {
 			return errors.WithStack(err)
@@ -100,6 +265,11 @@ func (h *ExecutorStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		h.log.Err(err).Send()
 		return
 	}
+
+	// return the stored executor so the executor knows if it's draining
+	if err := util.HTTPResponse(w, http.StatusOK, executor); err != nil {
+		h.log.Err(err).Send()
+	}
 }
 
 func (h *ExecutorStatusHandler) deleteStaleExecutors(ctx context.Context, curExecutor *types.Executor) error {
@@ -158,8 +328,11 @@ func NewExecutorTaskStatusHandler(log zerolog.Logger, d *db.DB, c chan<- string)
 
 func (h *ExecutorTaskStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	executorID := vars["executorid"]
+	etID := vars["taskid"]
 
-	// TODO(sgotti) Check authorized call from executors
 	var et *types.ExecutorTask
 	d := json.NewDecoder(r.Body)
 	defer r.Body.Close()
@@ -168,9 +341,13 @@ func (h *ExecutorTaskStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
+	if et.ID != etID {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("executor task id %q doesn't match the path task id %q", et.ID, etID)))
+		return
+	}
 
 	err := h.d.Do(ctx, func(tx *sql.Tx) error {
-		curEt, err := h.d.GetExecutorTask(tx, et.ID)
+		curEt, err := h.d.GetExecutorTask(tx, etID)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -182,6 +359,9 @@ func (h *ExecutorTaskStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 		if curEt == nil {
 			return nil
 		}
+		if curEt.Spec.ExecutorID != executorID {
+			return util.NewAPIError(util.ErrForbidden, errors.Errorf("executor task %q isn't assigned to executor %s", etID, executorID))
+		}
 
 		curEt.Status = et.Status
 
@@ -196,7 +376,7 @@ func (h *ExecutorTaskStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	go func() { h.c <- et.ID }()
+	go func() { h.c <- etID }()
 }
 
 type ExecutorTaskHandler struct {
@@ -212,7 +392,7 @@ func (h *ExecutorTaskHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	ctx := r.Context()
 	vars := mux.Vars(r)
 
-	// TODO(sgotti) Check authorized call from executors
+	executorID := vars["executorid"]
 	etID := vars["taskid"]
 	if etID == "" {
 		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("taskid is empty")))
@@ -224,6 +404,10 @@ func (h *ExecutorTaskHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		h.log.Err(err).Send()
 		return
 	}
+	if et.Spec.ExecutorID != executorID {
+		util.HTTPError(w, util.NewAPIError(util.ErrForbidden, errors.Errorf("executor task %q isn't assigned to executor %s", etID, executorID)))
+		return
+	}
 
 	if err := util.HTTPResponse(w, http.StatusOK, et); err != nil {
 		h.log.Err(err).Send()
@@ -243,7 +427,6 @@ func (h *ExecutorTasksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	ctx := r.Context()
 	vars := mux.Vars(r)
 
-	// TODO(sgotti) Check authorized call from executors
 	executorID := vars["executorid"]
 	if executorID == "" {
 		http.Error(w, "", http.StatusBadRequest)
@@ -264,18 +447,21 @@ func (h *ExecutorTasksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 
 type ArchivesHandler struct {
 	log zerolog.Logger
+	d   *db.DB
 	ost *objectstorage.ObjStorage
 }
 
-func NewArchivesHandler(log zerolog.Logger, ost *objectstorage.ObjStorage) *ArchivesHandler {
+func NewArchivesHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage) *ArchivesHandler {
 	return &ArchivesHandler{
 		log: log,
+		d:   d,
 		ost: ost,
 	}
 }
 
 func (h *ArchivesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// TODO(sgotti) Check authorized call from executors
+	ctx := r.Context()
+	executorID := requestExecutorID(r)
 
 	taskID := r.URL.Query().Get("taskid")
 	if taskID == "" {
@@ -293,6 +479,24 @@ func (h *ArchivesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the archive must belong to a task of a run executed by the executor
+	err = h.d.Do(ctx, func(tx *sql.Tx) error {
+		runs, err := executorRuns(tx, h.d, executorID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, run := range runs {
+			if _, ok := run.Tasks[taskID]; ok {
+				return nil
+			}
+		}
+		return util.NewAPIError(util.ErrForbidden, errors.Errorf("task %q isn't part of a run of executor %s", taskID, executorID))
+	})
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
 	w.Header().Set("Cache-Control", "no-cache")
 
 	if err := h.readArchive(taskID, step, w); err != nil {
@@ -325,34 +529,36 @@ func (h *ArchivesHandler) readArchive(rtID string, step int, w io.Writer) error
 
 type CacheHandler struct {
 	log zerolog.Logger
+	d   *db.DB
 	ost *objectstorage.ObjStorage
 }
 
-func NewCacheHandler(log zerolog.Logger, ost *objectstorage.ObjStorage) *CacheHandler {
+func NewCacheHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage) *CacheHandler {
 	return &CacheHandler{
 		log: log,
+		d:   d,
 		ost: ost,
 	}
 }
 
 func (h *CacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	vars := mux.Vars(r)
-	// TODO(sgotti) Check authorized call from executors
 
 	// keep and use the escaped path
-	key := vars["key"]
-	if key == "" {
-		http.Error(w, "empty cache key", http.StatusBadRequest)
+	group, key, err := cacheGroupKey(vars)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if len(key) > common.MaxCacheKeyLength {
-		http.Error(w, "cache key too long", http.StatusBadRequest)
+	if err := checkExecutorCacheGroup(ctx, h.d, requestExecutorID(r), group); util.HTTPError(w, err) {
+		h.log.Err(err).Send()
 		return
 	}
 	query := r.URL.Query()
 	_, prefix := query["prefix"]
 
-	matchedKey, err := matchCache(h.ost, key, prefix)
+	matchedKey, err := matchCache(h.ost, group, key, prefix)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -367,8 +573,15 @@ func (h *CacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Cache-Control", "no-cache")
+	// report the matched key since, when matching by prefix, it could be
+	// different from the requested one
+	w.Header().Set(common.CacheKeyHeader, matchedKey)
 
-	if err := h.readCache(matchedKey, w); err != nil {
+	if err := store.TouchCache(h.ost, group, matchedKey); err != nil {
+		h.log.Warn().Msgf("failed to record cache %q use: %v", matchedKey, err)
+	}
+
+	if err := h.readCache(group, matchedKey, w); err != nil {
 		switch {
 		case util.APIErrorIs(err, util.ErrNotExist):
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -379,8 +592,61 @@ func (h *CacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func matchCache(ost *objectstorage.ObjStorage, key string, prefix bool) (string, error) {
-	cachePath := store.OSTCachePath(key)
+// cacheGroupKey returns the cache group and key from the request vars. The
+// legacy caches api, used by executors older than the cache groups, doesn't
+// provide the group and uses the legacy caches not assigned to a group
+func cacheGroupKey(vars map[string]string) (string, string, error) {
+	group, ok := vars["group"]
+	if ok && (group == "" || group == "." || group == "..") {
+		return "", "", errors.Errorf("wrong cache group %q", group)
+	}
+	key := vars["key"]
+	if key == "" {
+		return "", "", errors.Errorf("empty cache key")
+	}
+	if len(key) > common.MaxCacheKeyLength {
+		return "", "", errors.Errorf("cache key too long")
+	}
+
+	return group, key, nil
+}
+
+// checkExecutorCacheGroup verifies that the cache group is the one of a run
+// executed by the executor. The legacy caches not assigned to a group are
+// available to every executor
+func checkExecutorCacheGroup(ctx context.Context, d *db.DB, executorID, group string) error {
+	if group == "" {
+		return nil
+	}
+	// the group is kept escaped
+	unescapedGroup, err := url.PathUnescape(group)
+	if err != nil {
+		return util.NewAPIError(util.ErrBadRequest, errors.Errorf("wrong cache group %q", group))
+	}
+
+	return d.Do(ctx, func(tx *sql.Tx) error {
+		runs, err := executorRuns(tx, d, executorID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, run := range runs {
+			rc, err := d.GetRunConfig(tx, run.RunConfigID)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if rc == nil {
+				continue
+			}
+			if common.RunCacheGroup(run, rc) == unescapedGroup {
+				return nil
+			}
+		}
+		return util.NewAPIError(util.ErrForbidden, errors.Errorf("cache group %q isn't used by a run of executor %s", unescapedGroup, executorID))
+	})
+}
+
+func matchCache(ost *objectstorage.ObjStorage, group, key string, prefix bool) (string, error) {
+	cachePath := store.OSTCachePath(group, key)
 
 	if prefix {
 		doneCh := make(chan struct{})
@@ -388,7 +654,7 @@ func matchCache(ost *objectstorage.ObjStorage, key string, prefix bool) (string,
 
 		// get the latest modified object
 		var lastObject *objectstorage.ObjectInfo
-		for object := range ost.List(store.OSTCacheDir()+"/"+key, "", false, doneCh) {
+		for object := range ost.List(store.OSTCacheGroupDir(group)+"/"+key, "", false, doneCh) {
 			if object.Err != nil {
 				return "", errors.WithStack(object.Err)
 			}
@@ -415,8 +681,8 @@ func matchCache(ost *objectstorage.ObjStorage, key string, prefix bool) (string,
 	return key, nil
 }
 
-func (h *CacheHandler) readCache(key string, w io.Writer) error {
-	cachePath := store.OSTCachePath(key)
+func (h *CacheHandler) readCache(group, key string, w io.Writer) error {
+	cachePath := store.OSTCachePath(group, key)
 	f, err := h.ost.ReadObject(cachePath)
 	if err != nil {
 		if objectstorage.IsNotExist(err) {
@@ -434,34 +700,39 @@ func (h *CacheHandler) readCache(key string, w io.Writer) error {
 
 type CacheCreateHandler struct {
 	log zerolog.Logger
+	d   *db.DB
 	ost *objectstorage.ObjStorage
+	// maxCacheSize is the max cache archive size. 0 means no limit
+	maxCacheSize int64
 }
 
-func NewCacheCreateHandler(log zerolog.Logger, ost *objectstorage.ObjStorage) *CacheCreateHandler {
+func NewCacheCreateHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage, maxCacheSize int64) *CacheCreateHandler {
 	return &CacheCreateHandler{
-		log: log,
-		ost: ost,
+		log:          log,
+		d:            d,
+		ost:          ost,
+		maxCacheSize: maxCacheSize,
 	}
 }
 
 func (h *CacheCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	vars := mux.Vars(r)
-	// TODO(sgotti) Check authorized call from executors
 
 	// keep and use the escaped path
-	key := vars["key"]
-	if key == "" {
-		http.Error(w, "empty cache key", http.StatusBadRequest)
+	group, key, err := cacheGroupKey(vars)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if len(key) > common.MaxCacheKeyLength {
-		http.Error(w, "cache key too long", http.StatusBadRequest)
+	if err := checkExecutorCacheGroup(ctx, h.d, requestExecutorID(r), group); util.HTTPError(w, err) {
+		h.log.Err(err).Send()
 		return
 	}
 
 	w.Header().Set("Cache-Control", "no-cache")
 
-	matchedKey, err := matchCache(h.ost, key, false)
+	matchedKey, err := matchCache(h.ost, group, key, false)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -480,14 +751,103 @@ func (h *CacheCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if h.maxCacheSize > 0 {
+		if size < 0 {
+			http.Error(w, "cache size is required", http.StatusLengthRequired)
+			return
+		}
+		if size > h.maxCacheSize {
+			http.Error(w, fmt.Sprintf("cache size %d greater than max cache size %d", size, h.maxCacheSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
 
-	cachePath := store.OSTCachePath(key)
+	cachePath := store.OSTCachePath(group, key)
 	if err := h.ost.WriteObject(cachePath, r.Body, size, false); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
+// maxLogChunkSize is the max size of a log chunk uploaded by the executor
+const maxLogChunkSize = 4 * 1024 * 1024
+
+type ExecutorTaskLogChunkHandler struct {
+	log zerolog.Logger
+	d   *db.DB
+	ost *objectstorage.ObjStorage
+}
+
+func NewExecutorTaskLogChunkHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage) *ExecutorTaskLogChunkHandler {
+	return &ExecutorTaskLogChunkHandler{
+		log: log,
+		d:   d,
+		ost: ost,
+	}
+}
+
+func (h *ExecutorTaskLogChunkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	q := r.URL.Query()
+
+	executorID := vars["executorid"]
+	etID := vars["taskid"]
+
+	_, setup := q["setup"]
+	stepStr := q.Get("step")
+	if setup == (stepStr != "") {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("one of setup or step number must be provided")))
+		return
+	}
+	var step int
+	if stepStr != "" {
+		var err error
+		step, err = strconv.Atoi(stepStr)
+		if err != nil || step < 0 {
+			util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("wrong step number %q", stepStr)))
+			return
+		}
+	}
+	offset, err := strconv.ParseInt(q.Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("wrong log chunk offset %q", q.Get("offset"))))
+		return
+	}
+
+	if r.ContentLength < 0 {
+		http.Error(w, "log chunk size is required", http.StatusLengthRequired)
+		return
+	}
+	if r.ContentLength > maxLogChunkSize {
+		http.Error(w, fmt.Sprintf("log chunk size %d greater than max log chunk size %d", r.ContentLength, maxLogChunkSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var et *types.ExecutorTask
+	err = h.d.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		et, err = h.d.GetExecutorTask(tx, etID)
+		return errors.WithStack(err)
+	})
+	if err != nil {
+		h.log.Err(err).Send()
+		util.HTTPError(w, err)
+		return
+	}
+	if et == nil || et.Spec.ExecutorID != executorID {
+		util.HTTPError(w, util.NewAPIError(util.ErrNotExist, errors.Errorf("executor task %q doesn't exist", etID)))
+		return
+	}
+
+	chunkPath := store.OSTRunTaskLogChunkPath(et.Spec.RunTaskID, setup, step, offset)
+	if err := h.ost.WriteObject(chunkPath, r.Body, r.ContentLength, false); err != nil {
+		h.log.Err(err).Send()
+		util.HTTPError(w, err)
+		return
+	}
+}
+
 type ExecutorDeleteHandler struct {
 	log zerolog.Logger
 	d   *db.DB
@@ -504,7 +864,6 @@ func (h *ExecutorDeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 	ctx := r.Context()
 	vars := mux.Vars(r)
 
-	// TODO(sgotti) Check authorized call from executors
 	executorID := vars["executorid"]
 	if executorID == "" {
 		http.Error(w, "", http.StatusBadRequest)
@@ -531,3 +890,78 @@ func (h *ExecutorDeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		return
 	}
 }
+
+type ExecutorsHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewExecutorsHandler(log zerolog.Logger, ah *action.ActionHandler) *ExecutorsHandler {
+	return &ExecutorsHandler{
+		log: log,
+		ah:  ah,
+	}
+}
+
+func (h *ExecutorsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	executors, err := h.ah.GetExecutors(ctx)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	if err := util.HTTPResponse(w, http.StatusOK, executors); err != nil {
+		h.log.Err(err).Send()
+	}
+}
+
+type ExecutorActionsHandler struct {
+	log zerolog.Logger
+	ah  *action.ActionHandler
+}
+
+func NewExecutorActionsHandler(log zerolog.Logger, ah *action.ActionHandler) *ExecutorActionsHandler {
+	return &ExecutorActionsHandler{
+		log: log,
+		ah:  ah,
+	}
+}
+
+func (h *ExecutorActionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+	executorID := vars["executorid"]
+
+	var req rsapitypes.ExecutorActionsRequest
+	d := json.NewDecoder(r.Body)
+	if err := d.Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch req.ActionType {
+	case rsapitypes.ExecutorActionTypeDrain:
+		if err := h.ah.DrainExecutor(ctx, executorID); err != nil {
+			h.log.Err(err).Send()
+			util.HTTPError(w, err)
+			return
+		}
+	case rsapitypes.ExecutorActionTypeApprove:
+		if err := h.ah.SetExecutorState(ctx, executorID, types.ExecutorStateApproved); err != nil {
+			h.log.Err(err).Send()
+			util.HTTPError(w, err)
+			return
+		}
+	case rsapitypes.ExecutorActionTypeBan:
+		if err := h.ah.SetExecutorState(ctx, executorID, types.ExecutorStateBanned); err != nil {
+			h.log.Err(err).Send()
+			util.HTTPError(w, err)
+			return
+		}
+	default:
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+}
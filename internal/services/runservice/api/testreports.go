@@ -0,0 +1,140 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/services/runservice/db"
+	"agola.io/agola/internal/services/runservice/store"
+	"agola.io/agola/internal/util"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+)
+
+type TestReportsCreateHandler struct {
+	log zerolog.Logger
+	d   *db.DB
+	ost *objectstorage.ObjStorage
+	// maxTestReportsSize is the max test reports archive size. 0 means no
+	// limit
+	maxTestReportsSize int64
+}
+
+func NewTestReportsCreateHandler(log zerolog.Logger, d *db.DB, ost *objectstorage.ObjStorage, maxTestReportsSize int64) *TestReportsCreateHandler {
+	return &TestReportsCreateHandler{
+		log:                log,
+		d:                  d,
+		ost:                ost,
+		maxTestReportsSize: maxTestReportsSize,
+	}
+}
+
+func (h *TestReportsCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	runID, taskID, err := artifactsRunTaskIDs(vars)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkExecutorRunTask(ctx, h.d, requestExecutorID(r), runID, taskID); util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+
+	size := int64(-1)
+	sizeStr := r.Header.Get("Content-Length")
+	if sizeStr != "" {
+		size, err = strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+	}
+	if h.maxTestReportsSize > 0 {
+		if size < 0 {
+			http.Error(w, "test reports size is required", http.StatusLengthRequired)
+			return
+		}
+		if size > h.maxTestReportsSize {
+			http.Error(w, fmt.Sprintf("test reports size %d greater than max test reports size %d", size, h.maxTestReportsSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	if _, err := store.WriteTaskTestReports(h.ost, runID, taskID, r.Body, size); err != nil {
+		if util.APIErrorIs(err, util.ErrBadRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.log.Err(err).Send()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+type RunTestReportsHandler struct {
+	log zerolog.Logger
+	ost *objectstorage.ObjStorage
+}
+
+func NewRunTestReportsHandler(log zerolog.Logger, ost *objectstorage.ObjStorage) *RunTestReportsHandler {
+	return &RunTestReportsHandler{
+		log: log,
+		ost: ost,
+	}
+}
+
+func (h *RunTestReportsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	runID := vars["runid"]
+	if runID == "" || runID == "." || runID == ".." {
+		util.HTTPError(w, util.NewAPIError(util.ErrBadRequest, errors.Errorf("wrong run id %q", runID)))
+		return
+	}
+
+	runTestReports, err := store.ListRunTestReports(h.ost, runID)
+	if util.HTTPError(w, err) {
+		h.log.Err(err).Send()
+		return
+	}
+
+	res := &rsapitypes.RunTestReportsResponse{
+		TestReports: []*rsapitypes.TaskTestReportResponse{},
+	}
+	for taskID, report := range runTestReports {
+		res.TestReports = append(res.TestReports, &rsapitypes.TaskTestReportResponse{
+			TaskID: taskID,
+			Report: report,
+		})
+	}
+	sort.Slice(res.TestReports, func(i, j int) bool { return res.TestReports[i].TaskID < res.TestReports[j].TaskID })
+
+	if err := util.HTTPResponse(w, http.StatusOK, res); err != nil {
+		h.log.Err(err).Send()
+	}
+}
@@ -17,15 +17,35 @@ package common
 import (
 	"path"
 	"sort"
+	"strconv"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/runconfig"
+	"agola.io/agola/internal/sealedsecret"
+	scommon "agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/util"
 	"agola.io/agola/services/runservice/types"
+
+	"github.com/rs/zerolog"
 )
 
 const (
 	MaxCacheKeyLength = 200
+
+	// CacheKeyHeader is the response header reporting the matched cache key
+	CacheKeyHeader = "X-Agola-Cache-Key"
+
+	// RunNumberEnv is the environment variable containing the run number
+	// inside its group (i.e. the project run number)
+	RunNumberEnv = "AGOLA_RUN_NUMBER"
+
+	// RepoTokenUsernameEnv and RepoTokenPasswordEnv are the environment
+	// variables containing the http credentials used to clone the run config
+	// RepoTokenPath repository
+	RepoTokenUsernameEnv = "AGOLA_GIT_USERNAME"
+	RepoTokenPasswordEnv = "AGOLA_GIT_PASSWORD"
+	// RepoTokenUsername is the username provided with the repo token
+	RepoTokenUsername = "agola"
 )
 
 type DataType string
@@ -38,6 +58,7 @@ const (
 	CacheCleanerLockKey     = "cachecleaner"
 	WorkspaceCleanerLockKey = "workspacecleaner"
 	TaskUpdaterLockKey      = "taskupdater"
+	ProvisionerLockKey      = "provisioner"
 )
 
 func TaskFetcherLockKey(taskID string) string {
@@ -113,7 +134,86 @@ func mergeEnv(dest, src map[string]string) {
 	}
 }
 
-func GenExecutorTaskSpecData(r *types.Run, rt *types.RunTask, rc *types.RunConfig) *types.ExecutorTaskSpecData {
+// openSealedValue returns the decrypted value if it's a sealed value resolved
+// from a project secret or the value itself
+func openSealedValue(key *sealedsecret.Key, sealedValues map[string]types.SealedValue, v string) (string, error) {
+	if !sealedsecret.IsSealed(v) {
+		return v, nil
+	}
+	sv, ok := sealedValues[v]
+	if !ok {
+		// not resolved from a secret (i.e. copied in the config), keep it sealed
+		return v, nil
+	}
+	if key == nil {
+		return "", errors.Errorf("sealed value provided but no sealed secrets key is configured")
+	}
+	ov, err := key.Open(v, sealedsecret.Binding(sv.ParentID, sv.SecretName))
+	return ov, errors.WithStack(err)
+}
+
+func openSealedEnv(key *sealedsecret.Key, sealedValues map[string]types.SealedValue, env map[string]string) (map[string]string, error) {
+	if env == nil {
+		return nil, nil
+	}
+	oenv := make(map[string]string, len(env))
+	for k, v := range env {
+		ov, err := openSealedValue(key, sealedValues, v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "environment variable %q", k)
+		}
+		oenv[k] = ov
+	}
+	return oenv, nil
+}
+
+// openSealedSecrets decrypts the sealed values, resolved from the project
+// secrets, in the executor task spec data. Sealed values are kept sealed in
+// the run config and decrypted only when sending the task to the executor
+func openSealedSecrets(key *sealedsecret.Key, sealedValues map[string]types.SealedValue, data *types.ExecutorTaskSpecData) error {
+	var err error
+	if data.Environment, err = openSealedEnv(key, sealedValues, data.Environment); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// copy the containers since they are shared with the run config
+	containers := make([]*types.Container, len(data.Containers))
+	for i, c := range data.Containers {
+		nc := *c
+		if nc.Environment, err = openSealedEnv(key, sealedValues, c.Environment); err != nil {
+			return errors.Wrapf(err, "container %d", i)
+		}
+		containers[i] = &nc
+	}
+	data.Containers = containers
+
+	if data.DockerRegistriesAuth == nil {
+		return nil
+	}
+	registriesAuth := make(map[string]types.DockerRegistryAuth, len(data.DockerRegistriesAuth))
+	for regname, auth := range data.DockerRegistriesAuth {
+		for _, v := range []*string{&auth.Username, &auth.Password, &auth.Auth, &auth.Token} {
+			if *v, err = openSealedValue(key, sealedValues, *v); err != nil {
+				return errors.Wrapf(err, "docker registry %q auth", regname)
+			}
+		}
+		registriesAuth[regname] = auth
+	}
+	data.DockerRegistriesAuth = registriesAuth
+
+	return nil
+}
+
+// RunCacheGroup returns the cache group used by the run tasks: the run config
+// cache group when defined or the run root group
+func RunCacheGroup(r *types.Run, rc *types.RunConfig) string {
+	if rc.CacheGroup != "" {
+		return rc.CacheGroup
+	}
+	return OSTRootGroup(r.Group)
+}
+
+func GenExecutorTaskSpecData(r *types.Run, rt *types.RunTask, rc *types.RunConfig, sealedSecretsKey *sealedsecret.Key, repoTokenSD *scommon.TokenSigningData) (*types.ExecutorTaskSpecData, error) {
 	rct := rc.Tasks[rt.ID]
 
 	environment := map[string]string{}
@@ -121,20 +221,38 @@ func GenExecutorTaskSpecData(r *types.Run, rt *types.RunTask, rc *types.RunConfi
 		environment = rct.Environment
 	}
 	mergeEnv(environment, rc.StaticEnvironment)
+	// the run number is generated by the runservice at run creation so it
+	// cannot be part of the static environment provided by the run creator
+	environment[RunNumberEnv] = strconv.FormatUint(r.Counter, 10)
 	// run config Environment variables ovverride every other environment variable
 	mergeEnv(environment, rc.Environment)
-
-	cachePrefix := OSTRootGroup(r.Group)
-	if rc.CacheGroup != "" {
-		cachePrefix = rc.CacheGroup
+	// the repo token expires so it isn't saved in the run config but a new
+	// one is generated every time the task is sent to the executor, also when
+	// the run is restarted
+	if rc.RepoTokenPath != "" {
+		if repoTokenSD == nil {
+			return nil, errors.Errorf("cannot generate repo %q token: token signing isn't configured", rc.RepoTokenPath)
+		}
+		repoToken, err := scommon.GenerateRepoJWTToken(repoTokenSD, rc.RepoTokenPath)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		environment[RepoTokenUsernameEnv] = RepoTokenUsername
+		environment[RepoTokenPasswordEnv] = repoToken
 	}
 
+	cachePrefix := RunCacheGroup(r, rc)
+
 	data := &types.ExecutorTaskSpecData{
 		// The executorTask ID must be the same as the runTask ID so we can detect if
 		// there's already an executorTask scheduled for that run task and we can get
 		// at most once task execution
 		TaskName:             rct.Name,
+		RuntimeType:          rct.Runtime.Type,
 		Arch:                 rct.Runtime.Arch,
+		GPUs:                 rct.Runtime.GPUs,
+		NodeSelector:         rct.Runtime.NodeSelector,
+		Tolerations:          rct.Runtime.Tolerations,
 		Containers:           rct.Runtime.Containers,
 		Environment:          environment,
 		WorkingDir:           rct.WorkingDir,
@@ -143,12 +261,48 @@ func GenExecutorTaskSpecData(r *types.Run, rt *types.RunTask, rc *types.RunConfi
 		Steps:                rct.Steps,
 		CachePrefix:          cachePrefix,
 		DockerRegistriesAuth: rct.DockerRegistriesAuth,
+		Timeout:              rct.Timeout,
+		MaxStepLogSize:       rc.MaxStepLogSize,
+		SkipWorkspace:        rct.SkipWorkspace,
+		Retries:              rct.Retries,
+		RetryInterval:        rct.RetryInterval,
+		RetryBackoff:         rct.RetryBackoff,
+	}
+
+	if err := openSealedSecrets(sealedSecretsKey, rc.SealedValues, data); err != nil {
+		return nil, errors.Wrapf(err, "failed to open sealed secrets")
+	}
+
+	// tasks not using the workspace don't need to restore the parents workspace
+	// archives
+	if rct.SkipWorkspace {
+		return data, nil
 	}
 
 	// calculate workspace operations
 	// TODO(sgotti) right now we don't support duplicated files. So it's not currently possibile to overwrite a file in a upper layer.
 	// this simplifies the workspaces extractions since they could be extracted in any order. We make them ordered just for reproducibility
 	wsops := []types.WorkspaceOperation{}
+
+	// tasks using a persistent workspace restore only the whole working dir
+	// saved by the nearest parents using it, overwriting the files in
+	// dependency order
+	if rct.PersistentWorkspace {
+		rctParents := persistentWorkspaceParents(rc, rct)
+		sort.Sort(parentsByLevelName(rctParents))
+
+		for _, rctParent := range rctParents {
+			for _, archiveStep := range r.Tasks[rctParent.ID].WorkspaceArchives {
+				wsop := types.WorkspaceOperation{TaskID: rctParent.ID, Step: archiveStep, Overwrite: true}
+				wsops = append(wsops, wsop)
+			}
+		}
+
+		data.WorkspaceOperations = wsops
+
+		return data, nil
+	}
+
 	rctAllParents := runconfig.GetAllParents(rc.Tasks, rct)
 
 	// sort parents by level and name just for reproducibility
@@ -163,7 +317,7 @@ func GenExecutorTaskSpecData(r *types.Run, rt *types.RunTask, rc *types.RunConfi
 
 	data.WorkspaceOperations = wsops
 
-	return data
+	return data, nil
 }
 
 func GenExecutorTask(r *types.Run, rt *types.RunTask, rc *types.RunConfig, executor *types.Executor) *types.ExecutorTask {
@@ -174,6 +328,7 @@ func GenExecutorTask(r *types.Run, rt *types.RunTask, rc *types.RunConfig, execu
 		ExecutorID: executor.ExecutorID,
 		RunID:      r.ID,
 		RunTaskID:  rt.ID,
+		Labels:     rct.Labels,
 		// ExecutorTaskSpecData is currently not saved in the database to keep
 		// size smaller but is generated everytime the executor task is sent to
 		// the executor
@@ -191,3 +346,111 @@ func GenExecutorTask(r *types.Run, rt *types.RunTask, rc *types.RunConfig, execu
 
 	return et
 }
+
+// persistentWorkspaceParents returns the nearest task ancestors using a
+// persistent workspace
+func persistentWorkspaceParents(rc *types.RunConfig, rct *types.RunConfigTask) []*types.RunConfigTask {
+	seen := map[string]struct{}{}
+	parents := []*types.RunConfigTask{}
+
+	var walk func(t *types.RunConfigTask)
+	walk = func(t *types.RunConfigTask) {
+		for _, p := range runconfig.GetParents(rc.Tasks, t) {
+			if _, ok := seen[p.ID]; ok {
+				continue
+			}
+			seen[p.ID] = struct{}{}
+			if p.PersistentWorkspace {
+				parents = append(parents, p)
+				continue
+			}
+			walk(p)
+		}
+	}
+	walk(rct)
+
+	return parents
+}
+
+// TaskMatchesParentDependCondition reports if the run task parents match the
+// task depend conditions
+func TaskMatchesParentDependCondition(rt *types.RunTask, r *types.Run, rc *types.RunConfig) bool {
+	rct := rc.Tasks[rt.ID]
+	parents := runconfig.GetParents(rc.Tasks, rct)
+
+	matchedNum := 0
+	for _, p := range parents {
+		matched := false
+		rp := r.Tasks[p.ID]
+		conds := runconfig.GetParentDependConditions(rct, p)
+		for _, cond := range conds {
+			switch cond {
+			case types.RunConfigTaskDependConditionOnSuccess:
+				if rp.Status == types.RunTaskStatusSuccess {
+					matched = true
+				}
+			case types.RunConfigTaskDependConditionOnFailure:
+				if rp.Status == types.RunTaskStatusFailed {
+					matched = true
+				}
+			case types.RunConfigTaskDependConditionOnSkipped:
+				if rp.Status == types.RunTaskStatusSkipped {
+					matched = true
+				}
+			case types.RunConfigTaskDependConditionAlways:
+				if rp.Status.IsFinished() {
+					matched = true
+				}
+			}
+		}
+		if matched {
+			matchedNum++
+		}
+	}
+
+	return len(parents) == matchedNum
+}
+
+// GetTasksToRun returns the run tasks that can be executed
+func GetTasksToRun(log zerolog.Logger, r *types.Run, rc *types.RunConfig) ([]*types.RunTask, error) {
+	log.Debug().Msgf("run: %s", util.Dump(r))
+	log.Debug().Msgf("rc: %s", util.Dump(rc))
+
+	tasksToRun := []*types.RunTask{}
+	// get tasks that can be executed
+	for _, rt := range r.Tasks {
+		if rt.Skip {
+			continue
+		}
+		if rt.Status != types.RunTaskStatusNotStarted {
+			continue
+		}
+
+		rct := rc.Tasks[rt.ID]
+		parents := runconfig.GetParents(rc.Tasks, rct)
+		finishedParents := 0
+		for _, p := range parents {
+			rp := r.Tasks[p.ID]
+			if rp.Status.IsFinished() && rp.ArchivesFetchFinished() {
+				finishedParents++
+			}
+		}
+
+		allParentsFinished := finishedParents == len(parents)
+
+		if allParentsFinished {
+			// TODO(sgotti) This could be removed when advanceRunTasks will calculate the
+			// state in a deterministic a complete way in one loop (see the related TODO)
+			if !TaskMatchesParentDependCondition(rt, r, rc) {
+				continue
+			}
+
+			// Run only if approved (when needs approval)
+			if !rct.NeedsApproval || (rct.NeedsApproval && rt.Approved) {
+				tasksToRun = append(tasksToRun, rt)
+			}
+		}
+	}
+
+	return tasksToRun, nil
+}
@@ -0,0 +1,253 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"agola.io/agola/internal/sealedsecret"
+	scommon "agola.io/agola/internal/services/common"
+	"agola.io/agola/services/runservice/types"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOpenSealedSecrets(t *testing.T) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	key, err := sealedsecret.ParseKey(base64.StdEncoding.EncodeToString(b))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	seal := func(parentID, secretName, v string) string {
+		sv, err := sealedsecret.Seal(key.PublicKey, sealedsecret.Binding(parentID, secretName), v)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		return sv
+	}
+
+	password := seal("project01", "secret01", "password01")
+	token := seal("project01", "secret01", "token01")
+	otherProjectPassword := seal("project02", "secret01", "password02")
+
+	tests := []struct {
+		name         string
+		key          *sealedsecret.Key
+		sealedValues map[string]types.SealedValue
+		in           *types.ExecutorTaskSpecData
+		out          *types.ExecutorTaskSpecData
+		err          bool
+	}{
+		{
+			name: "test sealed values resolved from the secrets",
+			key:  key,
+			sealedValues: map[string]types.SealedValue{
+				password: {ParentID: "project01", SecretName: "secret01"},
+				token:    {ParentID: "project01", SecretName: "secret01"},
+			},
+			in: &types.ExecutorTaskSpecData{
+				Environment: map[string]string{"PASSWORD": password, "ENV01": "value01"},
+				Containers:  []*types.Container{{Image: "image01", Environment: map[string]string{"PASSWORD": password}}},
+				DockerRegistriesAuth: map[string]types.DockerRegistryAuth{
+					"registry01": {Type: types.DockerRegistryAuthTypeBasic, Username: "user01", Password: password},
+					"registry02": {Type: types.DockerRegistryAuthTypeBasic, Token: token},
+				},
+			},
+			out: &types.ExecutorTaskSpecData{
+				Environment: map[string]string{"PASSWORD": "password01", "ENV01": "value01"},
+				Containers:  []*types.Container{{Image: "image01", Environment: map[string]string{"PASSWORD": "password01"}}},
+				DockerRegistriesAuth: map[string]types.DockerRegistryAuth{
+					"registry01": {Type: types.DockerRegistryAuthTypeBasic, Username: "user01", Password: "password01"},
+					"registry02": {Type: types.DockerRegistryAuthTypeBasic, Token: "token01"},
+				},
+			},
+		},
+		{
+			name: "test sealed values not resolved from the secrets are kept sealed",
+			key:  key,
+			sealedValues: map[string]types.SealedValue{
+				password: {ParentID: "project01", SecretName: "secret01"},
+			},
+			in: &types.ExecutorTaskSpecData{
+				Environment: map[string]string{"PASSWORD": password, "TOKEN": token, "OTHER": otherProjectPassword},
+				Containers:  []*types.Container{},
+			},
+			out: &types.ExecutorTaskSpecData{
+				Environment: map[string]string{"PASSWORD": "password01", "TOKEN": token, "OTHER": otherProjectPassword},
+				Containers:  []*types.Container{},
+			},
+		},
+		{
+			name: "test sealed value bound to another project secret",
+			key:  key,
+			sealedValues: map[string]types.SealedValue{
+				otherProjectPassword: {ParentID: "project01", SecretName: "secret01"},
+			},
+			in: &types.ExecutorTaskSpecData{
+				Environment: map[string]string{"PASSWORD": otherProjectPassword},
+			},
+			err: true,
+		},
+		{
+			name: "test sealed value bound to another secret",
+			key:  key,
+			sealedValues: map[string]types.SealedValue{
+				password: {ParentID: "project01", SecretName: "secret02"},
+			},
+			in: &types.ExecutorTaskSpecData{
+				Environment: map[string]string{"PASSWORD": password},
+			},
+			err: true,
+		},
+		{
+			name: "test sealed value without sealed secrets key",
+			sealedValues: map[string]types.SealedValue{
+				password: {ParentID: "project01", SecretName: "secret01"},
+			},
+			in: &types.ExecutorTaskSpecData{
+				Environment: map[string]string{"PASSWORD": password},
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := openSealedSecrets(tt.key, tt.sealedValues, tt.in)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if diff := cmp.Diff(tt.out, tt.in); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenExecutorTaskSpecDataEnvironment(t *testing.T) {
+	tests := []struct {
+		name              string
+		taskEnvironment   map[string]string
+		staticEnvironment map[string]string
+		environment       map[string]string
+		out               map[string]string
+	}{
+		{
+			name: "run number",
+			out:  map[string]string{RunNumberEnv: "42"},
+		},
+		{
+			name:              "run number overrides task and static environment",
+			taskEnvironment:   map[string]string{RunNumberEnv: "1", "TASKVAR": "task"},
+			staticEnvironment: map[string]string{RunNumberEnv: "2", "STATICVAR": "static"},
+			out:               map[string]string{RunNumberEnv: "42", "TASKVAR": "task", "STATICVAR": "static"},
+		},
+		{
+			name:        "run config environment overrides run number",
+			environment: map[string]string{RunNumberEnv: "3", "VAR": "value"},
+			out:         map[string]string{RunNumberEnv: "3", "VAR": "value"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &types.Run{
+				Group:   "/project/project01/branch/master",
+				Counter: 42,
+				Tasks: map[string]*types.RunTask{
+					"task01": {ID: "task01"},
+				},
+			}
+			rc := &types.RunConfig{
+				StaticEnvironment: tt.staticEnvironment,
+				Environment:       tt.environment,
+				Tasks: map[string]*types.RunConfigTask{
+					"task01": {ID: "task01", Name: "task01", Runtime: &types.Runtime{}, Environment: tt.taskEnvironment},
+				},
+			}
+
+			data, err := GenExecutorTaskSpecData(r, r.Tasks["task01"], rc, nil, nil)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.out, data.Environment); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenExecutorTaskSpecDataRepoToken(t *testing.T) {
+	sd := &scommon.TokenSigningData{Method: jwt.SigningMethodHS256, Key: []byte("key01")}
+
+	r := &types.Run{
+		Group: "/user/user01/branch/master",
+		Tasks: map[string]*types.RunTask{
+			"task01": {ID: "task01"},
+		},
+	}
+	rc := &types.RunConfig{
+		StaticEnvironment: map[string]string{RepoTokenPasswordEnv: "oldtoken"},
+		RepoTokenPath:     "user01/repo01",
+		Tasks: map[string]*types.RunConfigTask{
+			"task01": {ID: "task01", Name: "task01", Runtime: &types.Runtime{}},
+		},
+	}
+
+	data, err := GenExecutorTaskSpecData(r, r.Tasks["task01"], rc, nil, sd)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if data.Environment[RepoTokenUsernameEnv] != RepoTokenUsername {
+		t.Fatalf("expected repo token username %q, got %q", RepoTokenUsername, data.Environment[RepoTokenUsernameEnv])
+	}
+	repoPath, err := scommon.ParseRepoJWTToken(sd, data.Environment[RepoTokenPasswordEnv])
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if repoPath != rc.RepoTokenPath {
+		t.Fatalf("expected repo token for repo %q, got %q", rc.RepoTokenPath, repoPath)
+	}
+
+	// without token signing the task cannot be generated
+	if _, err := GenExecutorTaskSpecData(r, r.Tasks["task01"], rc, nil, nil); err == nil {
+		t.Fatalf("expected err")
+	}
+
+	// runs without a repo token path don't get a repo token
+	rc.RepoTokenPath = ""
+	rc.StaticEnvironment = nil
+	data, err = GenExecutorTaskSpecData(r, r.Tasks["task01"], rc, nil, sd)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, ok := data.Environment[RepoTokenPasswordEnv]; ok {
+		t.Fatalf("unexpected repo token")
+	}
+}
@@ -21,11 +21,13 @@ import (
 	"agola.io/agola/services/runservice/types"
 )
 
-func NewRunEvent(d *db.DB, tx *sql.Tx, runID string, phase types.RunPhase, result types.RunResult) (*types.RunEvent, error) {
+func NewRunEvent(d *db.DB, tx *sql.Tx, run *types.Run) (*types.RunEvent, error) {
 	runEvent := types.NewRunEvent()
-	runEvent.RunID = runID
-	runEvent.Phase = phase
-	runEvent.Result = result
+	runEvent.RunID = run.ID
+	runEvent.RunNumber = run.Counter
+	runEvent.Phase = run.Phase
+	runEvent.Result = run.Result
+	runEvent.WaitingApproval = len(run.TasksWaitingApproval()) > 0
 
 	runEventSequence, err := d.NextSequence(tx, types.SequenceTypeRunEvent)
 	if err != nil {
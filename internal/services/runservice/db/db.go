@@ -4,6 +4,7 @@ import (
 	"context"
 	stdsql "database/sql"
 	"encoding/json"
+	"sort"
 	"strings"
 
 	idb "agola.io/agola/internal/db"
@@ -21,7 +22,7 @@ import (
 
 const (
 	dataTablesVersion  = 1
-	queryTablesVersion = 1
+	queryTablesVersion = 2
 )
 
 var dstmts = []string{
@@ -40,7 +41,7 @@ var qstmts = []string{
 	// query tables for single object types. Can be rebuilt by data tables.
 	"create table if not exists sequence_t_q (id varchar, revision bigint, sequence_type varchar, data bytea, PRIMARY KEY (id))",
 	"create table if not exists changegroup_q (id varchar, revision bigint, name varchar, data bytea, PRIMARY KEY (id))",
-	"create table if not exists run_q (id varchar, revision bigint, grouppath varchar, sequence bigint, counter bigint, phase varchar, result varchar, archived boolean, data bytea, PRIMARY KEY (id))",
+	"create table if not exists run_q (id varchar, revision bigint, grouppath varchar, sequence bigint, counter bigint, phase varchar, result varchar, archived boolean, labels varchar, data bytea, PRIMARY KEY (id))",
 	"create table if not exists runconfig_q (id varchar, revision bigint, data bytea, PRIMARY KEY (id))",
 	"create table if not exists runcounter_q (id varchar, revision bigint, groupid varchar, data bytea, PRIMARY KEY (id))",
 	"create table if not exists runevent_q (id varchar, revision bigint, sequence bigint, data bytea, PRIMARY KEY (id))",
@@ -267,11 +268,46 @@ func (d *DB) GetRunByGroup(tx *sql.Tx, groupPath string, runCounter uint64) (*ty
 	return runs[0], nil
 }
 
-func (d *DB) GetRuns(tx *sql.Tx, groups []string, lastRun bool, phaseFilter []types.RunPhase, resultFilter []types.RunResult, startRunSequence uint64, limit int, sortOrder types.SortOrder) ([]*types.Run, error) {
-	return d.getRunsFiltered(tx, groups, lastRun, phaseFilter, resultFilter, startRunSequence, limit, sortOrder)
+// runLabelsQ encodes the run labels in a string like ",key01=value01,key02=value02,"
+// with the keys sorted. It's saved in the run_q labels column and used to
+// filter runs by labels.
+func runLabelsQ(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(",")
+	for _, k := range keys {
+		b.WriteString(k + "=" + labels[k] + ",")
+	}
+
+	return b.String()
+}
+
+var labelLikeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// whereLabels adds to the query a condition for every label in labelFilter.
+// Only runs with all the provided labels are matched.
+func whereLabels(q sq.SelectBuilder, labelFilter map[string]string) sq.SelectBuilder {
+	for k, v := range labelFilter {
+		q = q.Where("run_q.labels LIKE ? ESCAPE '!'", "%,"+labelLikeEscaper.Replace(k+"="+v)+",%")
+	}
+
+	return q
+}
+
+func (d *DB) GetRuns(tx *sql.Tx, groups []string, lastRun bool, phaseFilter []types.RunPhase, resultFilter []types.RunResult, labelFilter map[string]string, startRunSequence uint64, limit int, sortOrder types.SortOrder) ([]*types.Run, error) {
+	return d.getRunsFiltered(tx, groups, lastRun, phaseFilter, resultFilter, labelFilter, startRunSequence, limit, sortOrder)
 }
 
-func (d *DB) getRunsFilteredQuery(phaseFilter []types.RunPhase, resultFilter []types.RunResult, groups []string, lastRun bool, startRunSequence uint64, limit int, sortOrder types.SortOrder) sq.SelectBuilder {
+func (d *DB) getRunsFilteredQuery(phaseFilter []types.RunPhase, resultFilter []types.RunResult, labelFilter map[string]string, groups []string, lastRun bool, startRunSequence uint64, limit int, sortOrder types.SortOrder) sq.SelectBuilder {
 	q := runQSelect
 	if len(groups) > 0 && lastRun {
 		q = q.Columns("max(run_q.sequence)")
@@ -289,6 +325,7 @@ func (d *DB) getRunsFilteredQuery(phaseFilter []types.RunPhase, resultFilter []t
 	if len(resultFilter) > 0 {
 		q = q.Where(sq.Eq{"result": resultFilter})
 	}
+	q = whereLabels(q, labelFilter)
 	if startRunSequence > 0 {
 		if lastRun {
 			switch sortOrder {
@@ -329,8 +366,8 @@ func (d *DB) getRunsFilteredQuery(phaseFilter []types.RunPhase, resultFilter []t
 	return q
 }
 
-func (d *DB) getRunsFiltered(tx *sql.Tx, groups []string, lastRun bool, phaseFilter []types.RunPhase, resultFilter []types.RunResult, startRunSequence uint64, limit int, sortOrder types.SortOrder) ([]*types.Run, error) {
-	q := d.getRunsFilteredQuery(phaseFilter, resultFilter, groups, lastRun, startRunSequence, limit, sortOrder)
+func (d *DB) getRunsFiltered(tx *sql.Tx, groups []string, lastRun bool, phaseFilter []types.RunPhase, resultFilter []types.RunResult, labelFilter map[string]string, startRunSequence uint64, limit int, sortOrder types.SortOrder) ([]*types.Run, error) {
+	q := d.getRunsFilteredQuery(phaseFilter, resultFilter, labelFilter, groups, lastRun, startRunSequence, limit, sortOrder)
 
 	runs, _, err := d.fetchRuns(tx, q)
 
@@ -344,11 +381,11 @@ func (d *DB) GetUnarchivedRuns(tx *sql.Tx) ([]*types.Run, error) {
 	return runs, errors.WithStack(err)
 }
 
-func (d *DB) GetGroupRuns(tx *sql.Tx, group string, phaseFilter []types.RunPhase, resultFilter []types.RunResult, startRunCounter uint64, limit int, sortOrder types.SortOrder) ([]*types.Run, error) {
-	return d.getGroupRunsFiltered(tx, group, phaseFilter, resultFilter, startRunCounter, limit, sortOrder)
+func (d *DB) GetGroupRuns(tx *sql.Tx, group string, phaseFilter []types.RunPhase, resultFilter []types.RunResult, labelFilter map[string]string, startRunCounter uint64, limit int, sortOrder types.SortOrder) ([]*types.Run, error) {
+	return d.getGroupRunsFiltered(tx, group, phaseFilter, resultFilter, labelFilter, startRunCounter, limit, sortOrder)
 }
 
-func (d *DB) getGroupRunsFilteredQuery(phaseFilter []types.RunPhase, resultFilter []types.RunResult, groupPath string, startRunCounter uint64, limit int, sortOrder types.SortOrder, objectstorage bool) sq.SelectBuilder {
+func (d *DB) getGroupRunsFilteredQuery(phaseFilter []types.RunPhase, resultFilter []types.RunResult, labelFilter map[string]string, groupPath string, startRunCounter uint64, limit int, sortOrder types.SortOrder, objectstorage bool) sq.SelectBuilder {
 	q := runQSelect
 
 	switch sortOrder {
@@ -363,6 +400,7 @@ func (d *DB) getGroupRunsFilteredQuery(phaseFilter []types.RunPhase, resultFilte
 	if len(resultFilter) > 0 {
 		q = q.Where(sq.Eq{"result": resultFilter})
 	}
+	q = whereLabels(q, labelFilter)
 	if startRunCounter > 0 {
 		switch sortOrder {
 		case types.SortOrderAsc:
@@ -385,8 +423,8 @@ func (d *DB) getGroupRunsFilteredQuery(phaseFilter []types.RunPhase, resultFilte
 	return q
 }
 
-func (d *DB) getGroupRunsFiltered(tx *sql.Tx, group string, phaseFilter []types.RunPhase, resultFilter []types.RunResult, startRunCounter uint64, limit int, sortOrder types.SortOrder) ([]*types.Run, error) {
-	q := d.getGroupRunsFilteredQuery(phaseFilter, resultFilter, group, startRunCounter, limit, sortOrder, false)
+func (d *DB) getGroupRunsFiltered(tx *sql.Tx, group string, phaseFilter []types.RunPhase, resultFilter []types.RunResult, labelFilter map[string]string, startRunCounter uint64, limit int, sortOrder types.SortOrder) ([]*types.Run, error) {
+	q := d.getGroupRunsFilteredQuery(phaseFilter, resultFilter, labelFilter, group, startRunCounter, limit, sortOrder, false)
 
 	runs, _, err := d.fetchRuns(tx, q)
 
@@ -31,11 +31,11 @@ var (
 	}
 
 	runQSelect = sb.Select("run_q.id", "run_q.revision", "run_q.data").From("run_q")
-	runQInsert = func(id string, revision uint64, groupPath string, sequence, counter uint64, phase types.RunPhase, result types.RunResult, archived bool, data []byte) sq.InsertBuilder {
-		return sb.Insert("run_q").Columns("id", "revision", "grouppath", "sequence", "counter", "phase", "result", "archived", "data").Values(id, revision, groupPath, sequence, counter, phase, result, archived, data)
+	runQInsert = func(id string, revision uint64, groupPath string, sequence, counter uint64, phase types.RunPhase, result types.RunResult, archived bool, labels string, data []byte) sq.InsertBuilder {
+		return sb.Insert("run_q").Columns("id", "revision", "grouppath", "sequence", "counter", "phase", "result", "archived", "labels", "data").Values(id, revision, groupPath, sequence, counter, phase, result, archived, labels, data)
 	}
-	runQUpdate = func(id string, revision uint64, groupPath string, sequence, counter uint64, phase types.RunPhase, result types.RunResult, archived bool, data []byte) sq.UpdateBuilder {
-		return sb.Update("run_q").SetMap(map[string]interface{}{"id": id, "revision": revision, "grouppath": groupPath, "sequence": sequence, "counter": counter, "phase": phase, "result": result, "archived": archived, "data": data}).Where(sq.Eq{"id": id})
+	runQUpdate = func(id string, revision uint64, groupPath string, sequence, counter uint64, phase types.RunPhase, result types.RunResult, archived bool, labels string, data []byte) sq.UpdateBuilder {
+		return sb.Update("run_q").SetMap(map[string]interface{}{"id": id, "revision": revision, "grouppath": groupPath, "sequence": sequence, "counter": counter, "phase": phase, "result": result, "archived": archived, "labels": labels, "data": data}).Where(sq.Eq{"id": id})
 	}
 
 	runConfigQSelect = sb.Select("runconfig_q.id", "runconfig_q.revision", "runconfig_q.data").From("runconfig_q")
@@ -161,7 +161,7 @@ func (d *DB) insertRunQ(tx *sql.Tx, run *types.Run, data []byte) error {
 		groupPath += "/"
 	}
 
-	q := runQInsert(run.ID, run.Revision, groupPath, run.Sequence, run.Counter, run.Phase, run.Result, run.Archived, data)
+	q := runQInsert(run.ID, run.Revision, groupPath, run.Sequence, run.Counter, run.Phase, run.Result, run.Archived, runLabelsQ(run.Labels), data)
 	if _, err := d.exec(tx, q); err != nil {
 		return errors.Wrapf(err, "failed to insert run_q")
 	}
@@ -176,7 +176,7 @@ func (d *DB) updateRunQ(tx *sql.Tx, run *types.Run, data []byte) error {
 		groupPath += "/"
 	}
 
-	q := runQUpdate(run.ID, run.Revision, groupPath, run.Sequence, run.Counter, run.Phase, run.Result, run.Archived, data)
+	q := runQUpdate(run.ID, run.Revision, groupPath, run.Sequence, run.Counter, run.Phase, run.Result, run.Archived, runLabelsQ(run.Labels), data)
 	if _, err := d.exec(tx, q); err != nil {
 		return errors.Wrapf(err, "failed to insert run_q")
 	}
@@ -0,0 +1,146 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agola.io/agola/internal/services/runservice/action"
+	"agola.io/agola/internal/sql"
+	"agola.io/agola/internal/testutil"
+	"agola.io/agola/internal/util"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+	"agola.io/agola/services/runservice/types"
+)
+
+func TestExecutorAPIAuth(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	rs := setupRunservice(ctx, t, log, dir)
+
+	rb, err := rs.ah.CreateRun(ctx, &action.RunCreateRequest{Group: "/project/project01/branch/master", RunConfigTasks: map[string]*types.RunConfigTask{"task01": {ID: "task01", Name: "task01", Runtime: &types.Runtime{Type: types.RuntimeTypePod}}}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	runID := rb.Run.ID
+	rtID := "task01"
+
+	// executor01 owns the run task, executor02 is another approved executor
+	// and executor03 is waiting for approval
+	et := types.NewExecutorTask()
+	et.ID = rtID
+	et.Spec.ExecutorID = "executor01"
+	et.Spec.RunID = runID
+	et.Spec.RunTaskID = rtID
+	err = rs.d.Do(ctx, func(tx *sql.Tx) error {
+		for _, e := range []struct {
+			executorID string
+			state      types.ExecutorState
+		}{
+			{executorID: "executor01", state: types.ExecutorStateApproved},
+			{executorID: "executor02", state: types.ExecutorStateApproved},
+			{executorID: "executor03", state: types.ExecutorStatePending},
+		} {
+			executor := types.NewExecutor()
+			executor.ExecutorID = e.executorID
+			executor.State = e.state
+			executor.TokenHash = util.EncodeSha256Hex("token-" + e.executorID)
+			if err := rs.d.InsertOrUpdateExecutor(tx, executor); err != nil {
+				return err
+			}
+		}
+		return rs.d.InsertExecutorTask(tx, et)
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	etJSON, err := json.Marshal(et)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	router := rs.setupDefaultRouter(make(chan string, 10))
+
+	routes := []struct {
+		name   string
+		method string
+		// path is formatted with the executor id when it's scoped to an
+		// executor
+		path string
+		body []byte
+	}{
+		{name: "get task", method: "GET", path: "/api/v1alpha/executor/%s/tasks/" + rtID},
+		{name: "update task status", method: "POST", path: "/api/v1alpha/executor/%s/tasks/" + rtID, body: etJSON},
+		{name: "get archive", method: "GET", path: "/api/v1alpha/executor/archives?taskid=" + rtID + "&step=0"},
+		{name: "check cache", method: "HEAD", path: "/api/v1alpha/executor/caches/project01/key01"},
+		{name: "create cache", method: "POST", path: "/api/v1alpha/executor/caches/project01/key01"},
+		{name: "get artifacts", method: "GET", path: "/api/v1alpha/executor/artifacts/" + runID + "/" + rtID},
+		{name: "create artifacts", method: "POST", path: "/api/v1alpha/executor/artifacts/" + runID + "/" + rtID},
+		{name: "create test reports", method: "POST", path: "/api/v1alpha/executor/testreports/" + runID + "/" + rtID},
+	}
+
+	executors := []struct {
+		name       string
+		executorID string
+		token      string
+		forbidden  bool
+	}{
+		{name: "owner executor", executorID: "executor01", token: "token-executor01"},
+		{name: "owner executor with wrong token", executorID: "executor01", token: "token-executor02", forbidden: true},
+		{name: "foreign executor", executorID: "executor02", token: "token-executor02", forbidden: true},
+		{name: "unknown executor", executorID: "executor04", token: "token-executor04", forbidden: true},
+		{name: "pending executor", executorID: "executor03", token: "token-executor03", forbidden: true},
+	}
+
+	for _, route := range routes {
+		for _, e := range executors {
+			t.Run(route.name+" "+e.name, func(t *testing.T) {
+				path := route.path
+				scoped := strings.Contains(path, "%s")
+				if scoped {
+					path = fmt.Sprintf(path, e.executorID)
+				}
+				req := httptest.NewRequest(route.method, path, bytes.NewReader(route.body))
+				if !scoped {
+					req.Header.Set(rsapitypes.ExecutorIDHeader, e.executorID)
+				}
+				req.Header.Set(rsapitypes.ExecutorTokenHeader, e.token)
+
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				if e.forbidden {
+					if w.Code != http.StatusForbidden {
+						t.Fatalf("expected status code %d, got %d: %s", http.StatusForbidden, w.Code, w.Body.String())
+					}
+					return
+				}
+				if w.Code == http.StatusForbidden || w.Code == http.StatusUnauthorized {
+					t.Fatalf("unexpected status code %d: %s", w.Code, w.Body.String())
+				}
+			})
+		}
+	}
+}
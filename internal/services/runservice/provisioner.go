@@ -0,0 +1,144 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/services/config"
+	"agola.io/agola/internal/services/runservice/common"
+	"agola.io/agola/internal/sql"
+	rsapitypes "agola.io/agola/services/runservice/api/types"
+	"agola.io/agola/services/runservice/types"
+)
+
+const (
+	provisionerRequestTimeout = 30 * time.Second
+)
+
+// Provisioner is an executors provisioner. It's periodically called with the
+// pending tasks demand and the current executors and can scale the executors
+// capacity up or down.
+type Provisioner interface {
+	Provision(ctx context.Context, demands []*types.TasksDemand, executors []*types.Executor) error
+}
+
+func newProvisioner(c *config.Provisioner) (Provisioner, error) {
+	switch c.Type {
+	case "":
+		return nil, nil
+	case config.ProvisionerTypeWebhook:
+		return newWebhookProvisioner(c.URL), nil
+	default:
+		return nil, errors.Errorf("unknown provisioner type %q", c.Type)
+	}
+}
+
+// webhookProvisioner delegates the provisioning to an external service (i.e.
+// an autoscaler) posting to it the tasks demand and the current executors
+type webhookProvisioner struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookProvisioner(url string) *webhookProvisioner {
+	return &webhookProvisioner{
+		url:    url,
+		client: &http.Client{Timeout: provisionerRequestTimeout},
+	}
+}
+
+func (p *webhookProvisioner) Provision(ctx context.Context, demands []*types.TasksDemand, executors []*types.Executor) error {
+	req := &rsapitypes.ProvisionerWebhookRequest{
+		Demands:   demands,
+		Executors: executors,
+	}
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	hreq, err := http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(reqj))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(hreq)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("webhook provisioner returned status %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (s *Runservice) provisionerLoop(ctx context.Context) {
+	for {
+		if err := s.provision(ctx); err != nil {
+			s.log.Err(err).Send()
+		}
+
+		sleepCh := time.NewTimer(s.c.Provisioner.Interval).C
+		select {
+		case <-ctx.Done():
+			return
+		case <-sleepCh:
+		}
+	}
+}
+
+func (s *Runservice) provision(ctx context.Context) error {
+	s.log.Debug().Msgf("provision")
+
+	l := s.lf.NewLock(common.ProvisionerLockKey)
+	if err := l.Lock(ctx); err != nil {
+		return errors.Wrap(err, "failed to acquire provisioner lock")
+	}
+	defer func() { _ = l.Unlock() }()
+
+	demands, err := s.ah.GetTasksDemand(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var executors []*types.Executor
+	err = s.d.Do(ctx, func(tx *sql.Tx) error {
+		var err error
+		executors, err = s.d.GetExecutors(tx)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := s.provisioner.Provision(ctx, demands, executors); err != nil {
+		return errors.Wrapf(err, "%s provisioner error", s.c.Provisioner.Type)
+	}
+
+	return nil
+}
@@ -17,6 +17,7 @@ package runservice
 import (
 	"context"
 	"crypto/tls"
+	"io/ioutil"
 	"net/http"
 	"sync"
 	"time"
@@ -26,6 +27,8 @@ import (
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/lock"
 	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/sealedsecret"
+	"agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/config"
 	"agola.io/agola/internal/services/runservice/action"
 	"agola.io/agola/internal/services/runservice/api"
@@ -79,9 +82,17 @@ type Runservice struct {
 	lf              lock.LockFactory
 	ah              *action.ActionHandler
 	maintenanceMode bool
+	serviceAuth     *common.ServiceAuth
+	executorClient  *http.Client
+
+	sealedSecretsKey *sealedsecret.Key
+	repoTokenSD      *common.TokenSigningData
+	provisioner      Provisioner
 }
 
-func NewRunservice(ctx context.Context, log zerolog.Logger, c *config.Runservice) (*Runservice, error) {
+func NewRunservice(ctx context.Context, log zerolog.Logger, gc *config.Config) (*Runservice, error) {
+	c := &gc.Runservice
+
 	if c.Debug {
 		log = log.Level(zerolog.DebugLevel)
 	}
@@ -91,10 +102,17 @@ func NewRunservice(ctx context.Context, log zerolog.Logger, c *config.Runservice
 		return nil, errors.WithStack(err)
 	}
 
+	serviceAuth, err := common.NewServiceAuth(&gc.InternalServicesAuth, common.ServiceRunservice)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	s := &Runservice{
-		log: log,
-		c:   c,
-		ost: ost,
+		log:            log,
+		c:              c,
+		ost:            ost,
+		serviceAuth:    serviceAuth,
+		executorClient: serviceAuth.HTTPClient(common.ServiceExecutor),
 	}
 
 	sdb, err := sql.NewDB(c.DB.Type, c.DB.ConnString)
@@ -124,9 +142,34 @@ func NewRunservice(ctx context.Context, log zerolog.Logger, c *config.Runservice
 		return nil, errors.Wrapf(err, "create db error")
 	}
 
-	ah := action.NewActionHandler(log, d, ost, lf)
+	if c.SealedSecretsKeyFile != "" {
+		keyData, err := ioutil.ReadFile(c.SealedSecretsKeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read sealed secrets key file")
+		}
+		s.sealedSecretsKey, err = sealedsecret.ParseKey(string(keyData))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	// the repo tokens provided to the run tasks are verified by the gateway
+	// so they're signed with the gateway token signing config
+	if gc.Gateway.TokenSigning.Method != "" {
+		s.repoTokenSD, err = common.NewTokenSigningData(&gc.Gateway.TokenSigning)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	ah := action.NewActionHandler(log, d, ost, lf, c.Limits, s.sealedSecretsKey, s.repoTokenSD)
 	s.ah = ah
 
+	s.provisioner, err = newProvisioner(&c.Provisioner)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	return s, nil
 }
 
@@ -136,31 +179,56 @@ func (s *Runservice) setupDefaultRouter(etCh chan string) http.Handler {
 	importHandler := api.NewImportHandler(s.log, s.ah)
 
 	// executor dedicated api, only calls from executor should happen on these handlers
-	executorStatusHandler := api.NewExecutorStatusHandler(s.log, s.d, s.ah)
+	executorStatusHandler := api.NewExecutorStatusHandler(s.log, s.d, s.ah, s.c.ExecutorsApproval, s.c.ExecutorsHealth)
 	executorTaskStatusHandler := api.NewExecutorTaskStatusHandler(s.log, s.d, etCh)
 	executorTaskHandler := api.NewExecutorTaskHandler(s.log, s.ah)
 	executorTasksHandler := api.NewExecutorTasksHandler(s.log, s.ah)
-	archivesHandler := api.NewArchivesHandler(s.log, s.ost)
-	cacheHandler := api.NewCacheHandler(s.log, s.ost)
-	cacheCreateHandler := api.NewCacheCreateHandler(s.log, s.ost)
+	executorTaskLogChunkHandler := api.NewExecutorTaskLogChunkHandler(s.log, s.d, s.ost)
+	tasksDemandHandler := api.NewTasksDemandHandler(s.log, s.ah)
+	executorsHandler := api.NewExecutorsHandler(s.log, s.ah)
+	executorActionsHandler := api.NewExecutorActionsHandler(s.log, s.ah)
+	archivesHandler := api.NewArchivesHandler(s.log, s.d, s.ost)
+	cacheHandler := api.NewCacheHandler(s.log, s.d, s.ost)
+	cacheCreateHandler := api.NewCacheCreateHandler(s.log, s.d, s.ost, s.c.Limits.MaxCacheSize)
+	cacheGroupHandler := api.NewCacheGroupHandler(s.log, s.ost, s.c.Cache.GroupQuota)
+	cacheGroupDeleteHandler := api.NewCacheGroupDeleteHandler(s.log, s.ost)
+	artifactsHandler := api.NewArtifactsHandler(s.log, s.d, s.ost)
+	artifactsCreateHandler := api.NewArtifactsCreateHandler(s.log, s.d, s.ost, s.c.Limits.MaxArtifactsSize)
+	testReportsCreateHandler := api.NewTestReportsCreateHandler(s.log, s.d, s.ost, s.c.Limits.MaxTestReportsSize)
+
+	// verifies the calls of registered executors
+	executorAuthHandler := func(h http.Handler) http.Handler {
+		return api.NewExecutorAuthHandler(s.log, s.d, false, h)
+	}
+	// verifies the calls of registered and approved executors
+	approvedExecutorAuthHandler := func(h http.Handler) http.Handler {
+		return api.NewExecutorAuthHandler(s.log, s.d, true, h)
+	}
 
 	// api from clients
 	executorDeleteHandler := api.NewExecutorDeleteHandler(s.log, s.d)
 
-	logsHandler := api.NewLogsHandler(s.log, s.d, s.ost)
+	logsHandler := api.NewLogsHandler(s.log, s.d, s.ost, s.executorClient)
 	logsDeleteHandler := api.NewLogsDeleteHandler(s.log, s.d, s.ost)
+	logsInfoHandler := api.NewLogsInfoHandler(s.log, s.d, s.ost, s.executorClient)
 
 	runHandler := api.NewRunHandler(s.log, s.d, s.ah)
 	runByGroupHandler := api.NewRunByGroupHandler(s.log, s.d, s.ah)
 	runTaskActionsHandler := api.NewRunTaskActionsHandler(s.log, s.ah)
 	runsHandler := api.NewRunsHandler(s.log, s.d, s.ah)
 	runsByGroupHandler := api.NewRunsByGroupHandler(s.log, s.d, s.ah)
+	runsByGroupDeleteHandler := api.NewRunsByGroupDeleteHandler(s.log, s.ah)
 	runActionsHandler := api.NewRunActionsHandler(s.log, s.ah)
 	runCreateHandler := api.NewRunCreateHandler(s.log, s.ah)
 	runEventsHandler := api.NewRunEventsHandler(s.log, s.d, s.ost)
+	runArtifactsHandler := api.NewRunArtifactsHandler(s.log, s.ost)
+	runTaskArtifactHandler := api.NewRunTaskArtifactHandler(s.log, s.ost)
+	runTestReportsHandler := api.NewRunTestReportsHandler(s.log, s.ost)
 
 	changeGroupsUpdateTokensHandler := api.NewChangeGroupsUpdateTokensHandler(s.log, s.d, s.ah)
 
+	sealedSecretsPublicKeyHandler := api.NewSealedSecretsPublicKeyHandler(s.log, s.ah)
+
 	router := mux.NewRouter().UseEncodedPath().SkipClean(true)
 	apirouter := router.PathPrefix("/api/v1alpha").Subrouter().UseEncodedPath().SkipClean(true)
 
@@ -168,31 +236,53 @@ func (s *Runservice) setupDefaultRouter(etCh chan string) http.Handler {
 	apirouter.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadRequest) })
 
 	apirouter.Handle("/executor/{executorid}", executorStatusHandler).Methods("POST")
-	apirouter.Handle("/executor/{executorid}", executorDeleteHandler).Methods("DELETE")
-	apirouter.Handle("/executor/{executorid}/tasks", executorTasksHandler).Methods("GET")
-	apirouter.Handle("/executor/{executorid}/tasks/{taskid}", executorTaskHandler).Methods("GET")
-	apirouter.Handle("/executor/{executorid}/tasks/{taskid}", executorTaskStatusHandler).Methods("POST")
-	apirouter.Handle("/executor/archives", archivesHandler).Methods("GET")
-	apirouter.Handle("/executor/caches/{key}", cacheHandler).Methods("HEAD")
-	apirouter.Handle("/executor/caches/{key}", cacheHandler).Methods("GET")
-	apirouter.Handle("/executor/caches/{key}", cacheCreateHandler).Methods("POST")
+	apirouter.Handle("/executor/{executorid}", executorAuthHandler(executorDeleteHandler)).Methods("DELETE")
+	apirouter.Handle("/executor/{executorid}/tasks", approvedExecutorAuthHandler(executorTasksHandler)).Methods("GET")
+	apirouter.Handle("/executor/{executorid}/tasks/{taskid}", approvedExecutorAuthHandler(executorTaskHandler)).Methods("GET")
+	apirouter.Handle("/executor/{executorid}/tasks/{taskid}", approvedExecutorAuthHandler(executorTaskStatusHandler)).Methods("POST")
+	apirouter.Handle("/executor/{executorid}/tasks/{taskid}/logs", approvedExecutorAuthHandler(executorTaskLogChunkHandler)).Methods("PUT")
+	apirouter.Handle("/executor/archives", approvedExecutorAuthHandler(archivesHandler)).Methods("GET")
+	apirouter.Handle("/executor/demand", tasksDemandHandler).Methods("GET")
+	apirouter.Handle("/executor/caches/{group}/{key}", approvedExecutorAuthHandler(cacheHandler)).Methods("HEAD")
+	apirouter.Handle("/executor/caches/{group}/{key}", approvedExecutorAuthHandler(cacheHandler)).Methods("GET")
+	apirouter.Handle("/executor/caches/{group}/{key}", approvedExecutorAuthHandler(cacheCreateHandler)).Methods("POST")
+	// legacy caches api used by executors without the cache groups feature
+	apirouter.Handle("/executor/caches/{key}", approvedExecutorAuthHandler(cacheHandler)).Methods("HEAD")
+	apirouter.Handle("/executor/caches/{key}", approvedExecutorAuthHandler(cacheHandler)).Methods("GET")
+	apirouter.Handle("/executor/caches/{key}", approvedExecutorAuthHandler(cacheCreateHandler)).Methods("POST")
+	apirouter.Handle("/executor/artifacts/{runid}/{taskid}", approvedExecutorAuthHandler(artifactsHandler)).Methods("GET")
+	apirouter.Handle("/executor/artifacts/{runid}/{taskid}", approvedExecutorAuthHandler(artifactsCreateHandler)).Methods("POST")
+	apirouter.Handle("/executor/testreports/{runid}/{taskid}", approvedExecutorAuthHandler(testReportsCreateHandler)).Methods("POST")
+
+	apirouter.Handle("/executors", executorsHandler).Methods("GET")
+	apirouter.Handle("/executors/{executorid}/actions", executorActionsHandler).Methods("PUT")
+
+	apirouter.Handle("/caches/{group}", cacheGroupHandler).Methods("GET")
+	apirouter.Handle("/caches/{group}", cacheGroupDeleteHandler).Methods("DELETE")
 
 	apirouter.Handle("/logs", logsHandler).Methods("GET")
 	apirouter.Handle("/logs", logsDeleteHandler).Methods("DELETE")
+	apirouter.Handle("/logs/info", logsInfoHandler).Methods("GET")
 
 	apirouter.Handle("/runs/events", runEventsHandler).Methods("GET")
 	apirouter.Handle("/runs/{runid}", runHandler).Methods("GET")
 	apirouter.Handle("/runs/{runid}/actions", runActionsHandler).Methods("PUT")
 	apirouter.Handle("/runs/{runid}/tasks/{taskid}/actions", runTaskActionsHandler).Methods("PUT")
+	apirouter.Handle("/runs/{runid}/artifacts", runArtifactsHandler).Methods("GET")
+	apirouter.Handle("/runs/{runid}/tasks/{taskid}/artifact", runTaskArtifactHandler).Methods("GET")
+	apirouter.Handle("/runs/{runid}/testreports", runTestReportsHandler).Methods("GET")
 
 	apirouter.Handle("/runs/group/{group}/{runcounter}", runByGroupHandler).Methods("GET")
 	apirouter.Handle("/runs/group/{group}", runsByGroupHandler).Methods("GET")
+	apirouter.Handle("/runs/group/{group}", runsByGroupDeleteHandler).Methods("DELETE")
 
 	apirouter.Handle("/runs", runsHandler).Methods("GET")
 	apirouter.Handle("/runs", runCreateHandler).Methods("POST")
 
 	apirouter.Handle("/changegroups", changeGroupsUpdateTokensHandler).Methods("GET")
 
+	apirouter.Handle("/sealedsecrets/publickey", sealedSecretsPublicKeyHandler).Methods("GET")
+
 	apirouter.Handle("/maintenance", maintenanceModeHandler).Methods("PUT", "DELETE")
 
 	apirouter.Handle("/export", exportHandler).Methods("GET")
@@ -284,24 +374,36 @@ func (s *Runservice) run(ctx context.Context) error {
 		util.GoWait(&wg, func() { s.fetcherLoop(ctx) })
 		util.GoWait(&wg, func() { s.finishedRunsArchiverLoop(ctx) })
 		util.GoWait(&wg, func() { s.compactChangeGroupsLoop(ctx) })
-		util.GoWait(&wg, func() { s.cacheCleanerLoop(ctx, s.c.RunCacheExpireInterval) })
+		util.GoWait(&wg, func() { s.cacheCleanerLoop(ctx, s.c.Cache.ExpireInterval, s.c.Cache.GroupQuota) })
 		util.GoWait(&wg, func() { s.workspaceCleanerLoop(ctx, s.c.RunWorkspaceExpireInterval) })
 		util.GoWait(&wg, func() { s.executorTaskUpdateHandler(ctx, ch) })
+		if s.provisioner != nil {
+			util.GoWait(&wg, func() { s.provisionerLoop(ctx) })
+		}
+	}
+
+	// the notification service only needs to read runs and their events
+	// while the executors are limited to their dedicated api
+	serviceAuthorizations := common.ServiceAuthorizations{
+		common.ServiceGateway:   nil,
+		common.ServiceScheduler: nil,
+		common.ServiceNotification: {
+			{Methods: []string{"GET"}, Path: "/api/v1alpha/runs/*"},
+			{Methods: []string{"GET"}, Path: "/api/v1alpha/runs/*/testreports"},
+		},
+		common.ServiceExecutor: {
+			{Path: "/api/v1alpha/executor", Prefix: true},
+		},
 	}
 
 	httpServer := http.Server{
-		Addr:      s.c.Web.ListenAddress,
-		Handler:   mainrouter,
+		Handler:   s.serviceAuth.NewServiceAuthHandler(s.log, serviceAuthorizations, mainrouter),
 		TLSConfig: tlsConfig,
 	}
 
 	lerrCh := make(chan error, 1)
 	util.GoWait(&wg, func() {
-		if !s.c.Web.TLS {
-			lerrCh <- httpServer.ListenAndServe()
-		} else {
-			lerrCh <- httpServer.ListenAndServeTLS("", "")
-		}
+		lerrCh <- util.ListenAndServe(&httpServer, s.c.Web.Addresses(), s.c.Web.TLS)
 	})
 
 	select {
@@ -17,8 +17,10 @@ package runservice
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io/ioutil"
 	"net"
+	"path"
 	"path/filepath"
 	"reflect"
 	"sync"
@@ -66,7 +68,7 @@ func setupRunservice(ctx context.Context, t *testing.T, log zerolog.Logger, dir
 	rsConfig.DataDir = rsDir
 	rsConfig.Web.ListenAddress = net.JoinHostPort(listenAddress, port)
 
-	rs, err := NewRunservice(ctx, log, &rsConfig)
+	rs, err := NewRunservice(ctx, log, &config.Config{Runservice: rsConfig})
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -78,7 +80,7 @@ func getRuns(ctx context.Context, rs *Runservice) ([]*types.Run, error) {
 	var runs []*types.Run
 	err := rs.d.Do(ctx, func(tx *sql.Tx) error {
 		var err error
-		runs, err = rs.d.GetRuns(tx, nil, false, nil, nil, 0, 0, types.SortOrderAsc)
+		runs, err = rs.d.GetRuns(tx, nil, false, nil, nil, nil, 0, 0, types.SortOrderAsc)
 		return errors.WithStack(err)
 	})
 
@@ -256,7 +258,7 @@ func TestGetRunsLastRun(t *testing.T) {
 	var runs []*types.Run
 	err := rs.d.Do(ctx, func(tx *sql.Tx) error {
 		var err error
-		runs, err = rs.d.GetRuns(tx, groups, true, nil, nil, 0, 0, types.SortOrderDesc)
+		runs, err = rs.d.GetRuns(tx, groups, true, nil, nil, nil, 0, 0, types.SortOrderDesc)
 
 		return errors.WithStack(err)
 	})
@@ -280,3 +282,205 @@ func TestGetRunsLastRun(t *testing.T) {
 		}
 	}
 }
+
+func TestGetRunsLabels(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	rs := setupRunservice(ctx, t, log, dir)
+
+	t.Logf("starting rs")
+	go func() { _ = rs.Run(ctx) }()
+
+	time.Sleep(1 * time.Second)
+
+	runsLabels := []map[string]string{
+		{"team": "backend", "tier": "e2e"},
+		{"team": "backend", "tier": "unit"},
+		{"team": "frontend", "tier": "e2e"},
+		{"team": "back_end", "tier": "e2e"},
+		nil,
+	}
+	for _, labels := range runsLabels {
+		if _, err := rs.ah.CreateRun(ctx, &action.RunCreateRequest{Group: "/user/user01", RunConfigTasks: map[string]*types.RunConfigTask{"task01": {}}, Labels: labels}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		labelFilter map[string]string
+		num         int
+	}{
+		{name: "no label filter", num: 5},
+		{name: "single label filter", labelFilter: map[string]string{"team": "backend"}, num: 2},
+		{name: "multiple labels filter", labelFilter: map[string]string{"team": "backend", "tier": "e2e"}, num: 1},
+		{name: "label filter with like wildcards", labelFilter: map[string]string{"team": "back%"}, num: 0},
+		{name: "label filter with underscore", labelFilter: map[string]string{"team": "back_end"}, num: 1},
+		{name: "not existing label", labelFilter: map[string]string{"env": "prod"}, num: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runs, groupRuns []*types.Run
+			err := rs.d.Do(ctx, func(tx *sql.Tx) error {
+				var err error
+				runs, err = rs.d.GetRuns(tx, nil, false, nil, nil, tt.labelFilter, 0, 0, types.SortOrderAsc)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				groupRuns, err = rs.d.GetGroupRuns(tx, "/user/user01", nil, nil, tt.labelFilter, 0, 0, types.SortOrderAsc)
+
+				return errors.WithStack(err)
+			})
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+
+			if len(runs) != tt.num {
+				t.Fatalf("expected %d runs, got %d runs", tt.num, len(runs))
+			}
+			if len(groupRuns) != tt.num {
+				t.Fatalf("expected %d group runs, got %d runs", tt.num, len(groupRuns))
+			}
+			for _, r := range runs {
+				for k, v := range tt.labelFilter {
+					if r.Labels[k] != v {
+						t.Fatalf("expected run label %s=%s, got labels %v", k, v, r.Labels)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteGroupRuns(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	rs := setupRunservice(ctx, t, log, dir)
+
+	t.Logf("starting rs")
+	go func() { _ = rs.Run(ctx) }()
+
+	time.Sleep(1 * time.Second)
+
+	groups := []string{"/project/project01", "/project/project02"}
+
+	for _, group := range groups {
+		for i := 0; i < 3; i++ {
+			if _, err := rs.ah.CreateRun(ctx, &action.RunCreateRequest{Group: path.Join(group, "branch", "master"), RunConfigTasks: map[string]*types.RunConfigTask{"task01": {}}}); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		}
+	}
+
+	expectedErr := fmt.Sprintf("run group %q has active runs", groups[0])
+	if err := rs.ah.DeleteGroupRuns(ctx, groups[0]); err == nil || err.Error() != expectedErr {
+		t.Fatalf("expected err %v, got err: %v", expectedErr, err)
+	}
+
+	runs, err := getRuns(ctx, rs)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	for _, r := range runs {
+		if err := rs.ah.ChangeRunPhase(ctx, &action.RunChangePhaseRequest{RunID: r.ID, Phase: types.RunPhaseCancelled}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+
+	if err := rs.ah.DeleteGroupRuns(ctx, groups[0]); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	err = rs.d.Do(ctx, func(tx *sql.Tx) error {
+		for i, group := range groups {
+			runs, err := rs.d.GetGroupRuns(tx, group, nil, nil, nil, 0, 0, types.SortOrderAsc)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			runCounter, err := rs.d.GetRunCounter(tx, path.Base(group))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			// only the runs of the first group must be deleted
+			expectedRuns := 3
+			if i == 0 {
+				expectedRuns = 0
+			}
+			if len(runs) != expectedRuns {
+				return errors.Errorf("expected %d runs in group %q, got %d runs", expectedRuns, group, len(runs))
+			}
+			if (runCounter == nil) != (i == 0) {
+				return errors.Errorf("unexpected run counter for group %q: %v", group, runCounter)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
+
+func TestConcurrencyGroupCancelInProgress(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	rs := setupRunservice(ctx, t, log, dir)
+
+	t.Logf("starting rs")
+	go func() { _ = rs.Run(ctx) }()
+
+	time.Sleep(1 * time.Second)
+
+	deployGroup := &types.RunConcurrencyGroup{Name: "/project/project01/concurrency/deploy", MaxRunningRuns: 1, CancelInProgress: true}
+	otherGroup := &types.RunConcurrencyGroup{Name: "/project/project01/concurrency/other", MaxRunningRuns: 1, CancelInProgress: true}
+
+	createRun := func(cg *types.RunConcurrencyGroup) *types.Run {
+		rb, err := rs.ah.CreateRun(ctx, &action.RunCreateRequest{Group: "/project/project01/branch/master", RunConfigTasks: map[string]*types.RunConfigTask{"task01": {}}, ConcurrencyGroups: []*types.RunConcurrencyGroup{cg}})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		return rb.Run
+	}
+
+	runningRun := createRun(deployGroup)
+	if err := rs.ah.ChangeRunPhase(ctx, &action.RunChangePhaseRequest{RunID: runningRun.ID, Phase: types.RunPhaseRunning}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	queuedRun := createRun(deployGroup)
+	otherRun := createRun(otherGroup)
+	newRun := createRun(deployGroup)
+
+	err := rs.d.Do(ctx, func(tx *sql.Tx) error {
+		expected := []struct {
+			runID string
+			phase types.RunPhase
+			stop  bool
+		}{
+			{runID: runningRun.ID, phase: types.RunPhaseRunning, stop: true},
+			{runID: queuedRun.ID, phase: types.RunPhaseCancelled},
+			{runID: otherRun.ID, phase: types.RunPhaseQueued},
+			{runID: newRun.ID, phase: types.RunPhaseQueued},
+		}
+		for _, e := range expected {
+			r, err := rs.d.GetRun(tx, e.runID)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if r.Phase != e.phase || r.Stop != e.stop {
+				return errors.Errorf("expected run %q phase %q and stop %t, got phase %q and stop %t", r.ID, e.phase, e.stop, r.Phase, r.Stop)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
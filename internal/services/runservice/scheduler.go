@@ -20,24 +20,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/objectstorage"
 	"agola.io/agola/internal/runconfig"
+	scommon "agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/runservice/common"
 	"agola.io/agola/internal/services/runservice/store"
 	"agola.io/agola/internal/sql"
 	"agola.io/agola/internal/util"
 	"agola.io/agola/services/runservice/types"
+	stypes "agola.io/agola/services/types"
 
 	"github.com/rs/zerolog"
 )
 
 const (
 	changeGroupCompactorInterval = 1 * time.Minute
-	cacheCleanerInterval         = 1 * 24 * time.Hour
+	cacheCleanerInterval         = 1 * time.Hour
 	workspaceCleanerInterval     = 1 * 24 * time.Hour
 
 	defaultExecutorNotAliveInterval = 60 * time.Second
@@ -45,37 +48,31 @@ const (
 	changeGroupMinDuration = 5 * time.Minute
 )
 
-func taskMatchesParentDependCondition(rt *types.RunTask, r *types.Run, rc *types.RunConfig) bool {
-	rct := rc.Tasks[rt.ID]
-	parents := runconfig.GetParents(rc.Tasks, rct)
+// failFastExempt reports if the task isn't cancelled or stopped by the run fail
+// fast: tasks marked as always run and tasks executed whatever their parents
+// result (all their parents depends have the always condition)
+func failFastExempt(rc *types.RunConfig, rct *types.RunConfigTask) bool {
+	if rct.AlwaysRun {
+		return true
+	}
 
-	matchedNum := 0
+	parents := runconfig.GetParents(rc.Tasks, rct)
+	if len(parents) == 0 {
+		return false
+	}
 	for _, p := range parents {
-		matched := false
-		rp := r.Tasks[p.ID]
-		conds := runconfig.GetParentDependConditions(rct, p)
-		for _, cond := range conds {
-			switch cond {
-			case types.RunConfigTaskDependConditionOnSuccess:
-				if rp.Status == types.RunTaskStatusSuccess {
-					matched = true
-				}
-			case types.RunConfigTaskDependConditionOnFailure:
-				if rp.Status == types.RunTaskStatusFailed {
-					matched = true
-				}
-			case types.RunConfigTaskDependConditionOnSkipped:
-				if rp.Status == types.RunTaskStatusSkipped {
-					matched = true
-				}
+		always := false
+		for _, cond := range runconfig.GetParentDependConditions(rct, p) {
+			if cond == types.RunConfigTaskDependConditionAlways {
+				always = true
+				break
 			}
 		}
-		if matched {
-			matchedNum++
+		if !always {
+			return false
 		}
 	}
-
-	return len(parents) == matchedNum
+	return true
 }
 
 func advanceRunTasks(log zerolog.Logger, curRun *types.Run, rc *types.RunConfig, scheduledExecutorTasks []*types.ExecutorTask) (*types.Run, error) {
@@ -92,6 +89,7 @@ func advanceRunTasks(log zerolog.Logger, curRun *types.Run, rc *types.RunConfig,
 			for _, et := range scheduledExecutorTasks {
 				if rt.ID == et.Spec.RunTaskID {
 					isScheduled = true
+					break
 				}
 			}
 			if isScheduled {
@@ -103,6 +101,29 @@ func advanceRunTasks(log zerolog.Logger, curRun *types.Run, rc *types.RunConfig,
 		}
 	}
 
+	// with fail fast, when the run is failed, cancel all the not scheduled
+	// tasks that aren't exempted from it
+	failFast := rc.FailFast && curRun.Result == types.RunResultFailed && !curRun.Stop
+	if failFast {
+		for _, rt := range newRun.Tasks {
+			if rt.Status != types.RunTaskStatusNotStarted || failFastExempt(rc, rc.Tasks[rt.ID]) {
+				continue
+			}
+			isScheduled := false
+			for _, et := range scheduledExecutorTasks {
+				if rt.ID == et.Spec.RunTaskID {
+					isScheduled = true
+					break
+				}
+			}
+			if isScheduled {
+				continue
+			}
+			rt.Status = types.RunTaskStatusCancelled
+			rt.WaitingApproval = false
+		}
+	}
+
 	// handle root tasks
 	for _, rt := range newRun.Tasks {
 		if rt.Skip {
@@ -118,12 +139,14 @@ func advanceRunTasks(log zerolog.Logger, curRun *types.Run, rc *types.RunConfig,
 			continue
 		}
 
-		// cancel task if the run has a result set and is not yet scheduled
-		if curRun.Result.IsSet() {
+		// cancel task if the run has a result set and is not yet scheduled.
+		// With fail fast the exempted tasks are still executed
+		if curRun.Result.IsSet() && !(failFast && failFastExempt(rc, rct)) {
 			isScheduled := false
 			for _, et := range scheduledExecutorTasks {
 				if rt.ID == et.Spec.RunTaskID {
 					isScheduled = true
+					break
 				}
 			}
 			if isScheduled {
@@ -164,7 +187,7 @@ func advanceRunTasks(log zerolog.Logger, curRun *types.Run, rc *types.RunConfig,
 
 		// if all parents are finished check if the task could be executed or be skipped
 		if allParentsFinished {
-			matched := taskMatchesParentDependCondition(rt, curRun, rc)
+			matched := common.TaskMatchesParentDependCondition(rt, curRun, rc)
 
 			// if all parents are matched then we can start it, otherwise we mark the step to be skipped
 			skip := !matched
@@ -183,50 +206,67 @@ func advanceRunTasks(log zerolog.Logger, curRun *types.Run, rc *types.RunConfig,
 	return newRun, nil
 }
 
-func getTasksToRun(log zerolog.Logger, r *types.Run, rc *types.RunConfig) ([]*types.RunTask, error) {
-	log.Debug().Msgf("run: %s", util.Dump(r))
-	log.Debug().Msgf("rc: %s", util.Dump(rc))
-
-	tasksToRun := []*types.RunTask{}
-	// get tasks that can be executed
-	for _, rt := range r.Tasks {
-		if rt.Skip {
-			continue
-		}
-		if rt.Status != types.RunTaskStatusNotStarted {
+// concurrencyGroupsTasks contains the number of active executor tasks of
+// every run concurrency group
+type concurrencyGroupsTasks map[string]int
+
+// tasksConcurrencyLimitReached reports if one of the run concurrency groups
+// reached its max running tasks.
+// When groupsTasks is nil (tasks submitted outside the runs scheduler loop)
+// the runs with a tasks limit are always considered limited, so the capacity
+// freed by a finished task is handed off by the runs scheduler loop to the
+// oldest runs instead of the run of the finished task.
+func tasksConcurrencyLimitReached(r *types.Run, groupsTasks concurrencyGroupsTasks) bool {
+	for _, cg := range r.ConcurrencyGroups {
+		if cg.MaxRunningTasks == 0 {
 			continue
 		}
-
-		rct := rc.Tasks[rt.ID]
-		parents := runconfig.GetParents(rc.Tasks, rct)
-		finishedParents := 0
-		for _, p := range parents {
-			rp := r.Tasks[p.ID]
-			if rp.Status.IsFinished() && rp.ArchivesFetchFinished() {
-				finishedParents++
-			}
+		if groupsTasks == nil || groupsTasks[cg.Name] >= cg.MaxRunningTasks {
+			return true
 		}
+	}
+	return false
+}
 
-		allParentsFinished := finishedParents == len(parents)
+func (g concurrencyGroupsTasks) addRunTask(r *types.Run) {
+	if g == nil {
+		return
+	}
+	for _, cg := range r.ConcurrencyGroups {
+		g[cg.Name]++
+	}
+}
 
-		if allParentsFinished {
-			// TODO(sgotti) This could be removed when advanceRunTasks will calculate the
-			// state in a deterministic a complete way in one loop (see the related TODO)
-			if !taskMatchesParentDependCondition(rt, r, rc) {
+// getConcurrencyGroupsTasks returns the number of active executor tasks of the
+// concurrency groups of the provided runs
+func (s *Runservice) getConcurrencyGroupsTasks(ctx context.Context, runs []*types.Run) (concurrencyGroupsTasks, error) {
+	groupsTasks := concurrencyGroupsTasks{}
+	err := s.d.Do(ctx, func(tx *sql.Tx) error {
+		for _, r := range runs {
+			if len(r.ConcurrencyGroups) == 0 {
 				continue
 			}
-
-			// Run only if approved (when needs approval)
-			if !rct.NeedsApproval || (rct.NeedsApproval && rt.Approved) {
-				tasksToRun = append(tasksToRun, rt)
+			executorTasks, err := s.d.GetExecutorTasksByRun(tx, r.ID)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			for _, et := range executorTasks {
+				if et.Status.Phase.IsFinished() {
+					continue
+				}
+				groupsTasks.addRunTask(r)
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
 
-	return tasksToRun, nil
+	return groupsTasks, nil
 }
 
-func (s *Runservice) submitRunTasks(ctx context.Context, r *types.Run, rc *types.RunConfig, tasks []*types.RunTask) error {
+func (s *Runservice) submitRunTasks(ctx context.Context, r *types.Run, rc *types.RunConfig, tasks []*types.RunTask, groupsTasks concurrencyGroupsTasks) error {
 	s.log.Debug().Msgf("tasksToRun: %s", util.Dump(tasks))
 
 	for _, rt := range tasks {
@@ -252,7 +292,12 @@ func (s *Runservice) submitRunTasks(ctx context.Context, r *types.Run, rc *types
 			continue
 		}
 
-		executor, err := s.chooseExecutor(ctx, rct)
+		if tasksConcurrencyLimitReached(r, groupsTasks) {
+			s.log.Debug().Msgf("run %q concurrency groups max running tasks reached", r.ID)
+			return nil
+		}
+
+		executor, err := s.chooseExecutor(ctx, r, rct)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -289,6 +334,10 @@ func (s *Runservice) submitRunTasks(ctx context.Context, r *types.Run, rc *types
 			return errors.WithStack(err)
 		}
 
+		if shouldSend {
+			groupsTasks.addRunTask(r)
+		}
+
 		if shouldSend {
 			if err := s.sendExecutorTask(ctx, executorTask); err != nil {
 				return errors.WithStack(err)
@@ -301,9 +350,10 @@ func (s *Runservice) submitRunTasks(ctx context.Context, r *types.Run, rc *types
 
 // chooseExecutor chooses the executor to schedule the task on. Now it's a very simple/dumb selection
 // TODO(sgotti) improve this to use executor statistic, labels (arch type) etc...
-func (s *Runservice) chooseExecutor(ctx context.Context, rct *types.RunConfigTask) (*types.Executor, error) {
+func (s *Runservice) chooseExecutor(ctx context.Context, r *types.Run, rct *types.RunConfigTask) (*types.Executor, error) {
 	var executors []*types.Executor
-	executorTasksCount := map[string]int{}
+	executorsTasks := map[string][]*types.ExecutorTask{}
+	runExecutors := map[string]struct{}{}
 	err := s.d.Do(ctx, func(tx *sql.Tx) error {
 		var err error
 
@@ -312,6 +362,14 @@ func (s *Runservice) chooseExecutor(ctx context.Context, rct *types.RunConfigTas
 			return errors.WithStack(err)
 		}
 
+		runExecutorTasks, err := s.d.GetExecutorTasksByRun(tx, r.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, et := range runExecutorTasks {
+			runExecutors[et.Spec.ExecutorID] = struct{}{}
+		}
+
 		// TODO(sgotti) implement a db method that just returns the count
 		for _, executor := range executors {
 			executorTasks, err := s.d.GetExecutorTasksByExecutor(tx, executor.ExecutorID)
@@ -319,7 +377,7 @@ func (s *Runservice) chooseExecutor(ctx context.Context, rct *types.RunConfigTas
 				return errors.WithStack(err)
 			}
 
-			executorTasksCount[executor.ExecutorID] = len(executorTasks)
+			executorsTasks[executor.ExecutorID] = executorTasks
 		}
 
 		return nil
@@ -328,25 +386,116 @@ func (s *Runservice) chooseExecutor(ctx context.Context, rct *types.RunConfigTas
 		return nil, errors.WithStack(err)
 	}
 
-	return chooseExecutor(executors, executorTasksCount, rct), nil
+	return chooseExecutor(executors, executorsTasks, runExecutors, r.Group, rct), nil
 }
 
-func chooseExecutor(executors []*types.Executor, executorTasksCount map[string]int, rct *types.RunConfigTask) *types.Executor {
+// executorAllowsPrivilegedContainers reports if the executor allows privileged
+// containers for the runs in the provided run group
+func executorAllowsPrivilegedContainers(e *types.Executor, runGroup string) bool {
+	if !e.AllowPrivilegedContainers {
+		return false
+	}
+	if len(e.PrivilegedContainersProjects) == 0 {
+		return true
+	}
+
+	groupType, groupID, err := scommon.GroupTypeIDFromRunGroup(runGroup)
+	if err != nil {
+		return false
+	}
+	if groupType != scommon.GroupTypeProject {
+		return false
+	}
+	return util.StringInSlice(e.PrivilegedContainersProjects, groupID)
+}
+
+// executorLabelsActiveTasksLimitReached reports if one of the executor labels
+// active tasks limits matching the task labels has been reached
+func executorLabelsActiveTasksLimitReached(e *types.Executor, executorTasks []*types.ExecutorTask, labels map[string]string) bool {
+	for _, l := range e.LabelsActiveTasksLimits {
+		if !l.Matches(labels) {
+			continue
+		}
+		activeTasks := 0
+		for _, et := range executorTasks {
+			if l.Matches(et.Spec.Labels) {
+				activeTasks++
+			}
+		}
+		if activeTasks >= l.Limit {
+			return true
+		}
+	}
+	return false
+}
+
+// chooseExecutor returns the executor that will execute the task.
+// executorsTasks are the tasks currently assigned to every executor.
+// runExecutors are the executors running other tasks of the same run and are
+// used to honor the task executor affinity.
+func chooseExecutor(executors []*types.Executor, executorsTasks map[string][]*types.ExecutorTask, runExecutors map[string]struct{}, runGroup string, rct *types.RunConfigTask) *types.Executor {
 	requiresPrivilegedContainers := false
 	for _, c := range rct.Runtime.Containers {
-		if c.Privileged {
+		if c.RequiresPrivileges() {
 			requiresPrivilegedContainers = true
 			break
 		}
 	}
 
+	runtimeType := rct.Runtime.Type
+	if runtimeType == "" {
+		runtimeType = types.RuntimeTypePod
+	}
+
+	requiredFeatures := rct.RequiredExecutorFeatures()
+
+	// fallback is the first suitable executor, used when no executor matches
+	// the executor affinity
+	var fallback *types.Executor
 	for _, e := range executors {
 		if time.Since(e.UpdateTime) > defaultExecutorNotAliveInterval {
 			continue
 		}
 
+		// skip draining executors
+		if e.Draining {
+			continue
+		}
+
+		// skip not approved (pending or banned) executors
+		if !e.IsApproved() {
+			continue
+		}
+
+		// skip unhealthy (disk full, overloaded) executors
+		if !e.IsHealthy() {
+			continue
+		}
+
+		// skip executors not supporting the task runtime type. Host runtime
+		// tasks are executed only by executors using the host driver and
+		// these executors only execute host runtime tasks
+		executorRuntimeType := e.RuntimeType
+		if executorRuntimeType == "" {
+			executorRuntimeType = types.RuntimeTypePod
+		}
+		if executorRuntimeType != runtimeType {
+			continue
+		}
+
 		// skip executor provileged containers are required but not allowed
-		if requiresPrivilegedContainers && !e.AllowPrivilegedContainers {
+		if requiresPrivilegedContainers && !executorAllowsPrivilegedContainers(e, runGroup) {
+			continue
+		}
+
+		// skip executors, like executors of an older version, not providing
+		// the features required by the task
+		if !e.HasFeatures(requiredFeatures) {
+			continue
+		}
+
+		// skip executors not providing the required gpus
+		if rct.Runtime.GPUs > e.GPUs {
 			continue
 		}
 
@@ -363,9 +512,13 @@ func chooseExecutor(executors []*types.Executor, executorTasksCount map[string]i
 			}
 		}
 
+		if !executorMatchesLabels(e, rct.Runtime.ExecutorLabels) {
+			continue
+		}
+
 		if e.ActiveTasksLimit != 0 {
-			// will be 0 when executorTasksCount[e.ExecutorID] doesn't exist
-			activeTasks := executorTasksCount[e.ExecutorID]
+			// will be 0 when executorsTasks[e.ExecutorID] doesn't exist
+			activeTasks := len(executorsTasks[e.ExecutorID])
 			if e.ActiveTasks > activeTasks {
 				activeTasks = e.ActiveTasks
 			}
@@ -376,10 +529,73 @@ func chooseExecutor(executors []*types.Executor, executorTasksCount map[string]i
 			}
 		}
 
-		return e
+		if executorLabelsActiveTasksLimitReached(e, executorsTasks[e.ExecutorID], rct.Labels) {
+			continue
+		}
+
+		_, runExecutor := runExecutors[e.ExecutorID]
+		switch rct.Runtime.ExecutorAffinity {
+		case types.ExecutorAffinityRun:
+			if runExecutor {
+				return e
+			}
+		case types.ExecutorAntiAffinityRun:
+			if !runExecutor {
+				return e
+			}
+		default:
+			return e
+		}
+
+		if fallback == nil {
+			fallback = e
+		}
 	}
 
-	return nil
+	return fallback
+}
+
+// executorMatchesLabels reports whether the executor has all the provided
+// labels. Since tasks and executors without the os label are considered
+// linux tasks and executors, tasks targeting another os must explicitly
+// define it. The arch label is matched against all the executor archs since
+// it's set only on single arch executors.
+func executorMatchesLabels(e *types.Executor, labels map[string]string) bool {
+	taskOS := stypes.OSLinux
+	if os, ok := labels[types.ExecutorLabelOS]; ok {
+		taskOS = stypes.OS(os)
+	}
+	executorOS := stypes.OSLinux
+	if os, ok := e.Labels[types.ExecutorLabelOS]; ok {
+		executorOS = stypes.OS(os)
+	}
+	if taskOS != executorOS {
+		return false
+	}
+
+	if arch, ok := labels[types.ExecutorLabelArch]; ok {
+		found := false
+		for _, earch := range e.Archs {
+			if earch == stypes.Arch(arch) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for k, v := range labels {
+		if k == types.ExecutorLabelOS || k == types.ExecutorLabelArch {
+			continue
+		}
+		if ev, ok := e.Labels[k]; !ok || ev != v {
+			return false
+		}
+	}
+
+	return true
 }
 
 // sendExecutorTask sends executor task to executor, if this fails the executor
@@ -434,14 +650,17 @@ func (s *Runservice) sendExecutorTask(ctx context.Context, et *types.ExecutorTas
 	et = et.DeepCopy()
 
 	// generate ExecutorTaskSpecData
-	et.Spec.ExecutorTaskSpecData = common.GenExecutorTaskSpecData(r, rt, rc)
+	et.Spec.ExecutorTaskSpecData, err = common.GenExecutorTaskSpecData(r, rt, rc, s.sealedSecretsKey, s.repoTokenSD)
+	if err != nil {
+		return errors.WithStack(err)
+	}
 
 	etj, err := json.Marshal(et)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	req, err := http.Post(executor.ListenURL+"/api/v1alpha/executor", "", bytes.NewReader(etj))
+	req, err := s.executorClient.Post(executor.ListenURL+"/api/v1alpha/executor", "", bytes.NewReader(etj))
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -492,7 +711,7 @@ func (s *Runservice) compactChangeGroups(ctx context.Context) error {
 	return nil
 }
 
-func (s *Runservice) scheduleRun(ctx context.Context, runID string) error {
+func (s *Runservice) scheduleRun(ctx context.Context, runID string, groupsTasks concurrencyGroupsTasks) error {
 	// we use multiple transactions to split the logic in multiple steps and
 	// rely on optimistic object locking.
 	// We could probably also use a single transaction without many issues
@@ -544,6 +763,16 @@ func (s *Runservice) scheduleRun(ctx context.Context, runID string) error {
 
 	prevPhase := r.Phase
 	prevResult := r.Result
+	prevWaitingApproval := len(r.TasksWaitingApproval()) > 0
+
+	// stop the run if it exceeded its timeout
+	if r.Phase == types.RunPhaseRunning && !r.Stop && rc.Timeout > 0 && r.StartTime != nil && time.Since(*r.StartTime) > rc.Timeout {
+		s.log.Info().Msgf("stopping run %q since it exceeded its timeout of %s", r.ID, rc.Timeout)
+		r.Stop = true
+		for _, t := range r.TasksWaitingApproval() {
+			r.Tasks[t].WaitingApproval = false
+		}
+	}
 
 	if err := advanceRun(s.log, r, rc, scheduledExecutorTasks); err != nil {
 		return errors.WithStack(err)
@@ -559,6 +788,19 @@ func (s *Runservice) scheduleRun(ctx context.Context, runID string) error {
 				}
 				etsToSend = append(etsToSend, et)
 			}
+		} else if rc.FailFast && r.Result == types.RunResultFailed {
+			// with fail fast stop the active tasks that aren't exempted from it
+			for _, et := range scheduledExecutorTasks {
+				if et.Spec.Stop || et.Status.Phase.IsFinished() || failFastExempt(rc, rc.Tasks[et.Spec.RunTaskID]) {
+					continue
+				}
+				s.log.Info().Msgf("stopping task %q of failed run %q with fail fast", et.Spec.RunTaskID, r.ID)
+				et.Spec.Stop = true
+				if err := s.d.UpdateExecutorTask(tx, et); err != nil {
+					return errors.WithStack(err)
+				}
+				etsToSend = append(etsToSend, et)
+			}
 		}
 
 		// advance tasks
@@ -575,9 +817,11 @@ func (s *Runservice) scheduleRun(ctx context.Context, runID string) error {
 			return errors.WithStack(err)
 		}
 
-		// detect changes to phase and result and set related events
-		if prevPhase != r.Phase || prevResult != r.Result {
-			runEvent, err := common.NewRunEvent(s.d, tx, r.ID, r.Phase, r.Result)
+		// detect changes to phase, result and tasks waiting approval and set
+		// related events
+		waitingApproval := len(r.TasksWaitingApproval()) > 0
+		if prevPhase != r.Phase || prevResult != r.Result || prevWaitingApproval != waitingApproval {
+			runEvent, err := common.NewRunEvent(s.d, tx, r)
 			if err != nil {
 				return errors.WithStack(err)
 			}
@@ -597,12 +841,12 @@ func (s *Runservice) scheduleRun(ctx context.Context, runID string) error {
 		}
 	}
 	if shouldSubmitRunTasks {
-		tasksToRun, err := getTasksToRun(s.log, r, rc)
+		tasksToRun, err := common.GetTasksToRun(s.log, r, rc)
 		if err != nil {
 			return errors.WithStack(err)
 		}
 
-		if err := s.submitRunTasks(ctx, r, rc, tasksToRun); err != nil {
+		if err := s.submitRunTasks(ctx, r, rc, tasksToRun, groupsTasks); err != nil {
 			return errors.WithStack(err)
 		}
 	}
@@ -726,7 +970,7 @@ func (s *Runservice) handleExecutorTaskUpdate(ctx context.Context, executorTaskI
 		return errors.WithStack(err)
 	}
 
-	return s.scheduleRun(ctx, r.ID)
+	return s.scheduleRun(ctx, r.ID, nil)
 }
 
 func (s *Runservice) updateRunTaskStatus(et *types.ExecutorTask, r *types.Run) error {
@@ -804,6 +1048,31 @@ func (s *Runservice) updateRunTaskStatus(et *types.ExecutorTask, r *types.Run) e
 		rt.Steps[i].ExitStatus = s.ExitStatus
 		rt.Steps[i].StartTime = s.StartTime
 		rt.Steps[i].EndTime = s.EndTime
+		rt.Steps[i].Transfer = s.Transfer
+	}
+
+	if et.Status.ResourceUsage != nil {
+		rt.ResourceUsage = et.Status.ResourceUsage
+	}
+	rt.FailError = et.Status.FailError
+	rt.Attempt = et.Status.Attempt
+
+	// merge the run metadata set by the task. If multiple tasks set the same
+	// key the last update wins
+	if len(et.Status.RunMeta) > 0 && r.Meta == nil {
+		r.Meta = map[string]string{}
+	}
+	for k, v := range et.Status.RunMeta {
+		r.Meta[k] = v
+	}
+
+	// merge the metrics emitted by the task. If multiple tasks emit the same
+	// metric the last update wins
+	if len(et.Status.Metrics) > 0 && r.Metrics == nil {
+		r.Metrics = map[string]float64{}
+	}
+	for k, v := range et.Status.Metrics {
+		r.Metrics[k] = v
 	}
 
 	return nil
@@ -1034,11 +1303,6 @@ func (s *Runservice) fetchLog(ctx context.Context, runID string, rt *types.RunTa
 		return nil
 	}
 
-	if executor == nil {
-		s.log.Warn().Msgf("executor with id %q doesn't exist. Skipping fetching", et.Spec.ExecutorID)
-		return nil
-	}
-
 	var logPath string
 	if setup {
 		logPath = store.OSTRunTaskSetupLogPath(rt.ID)
@@ -1053,21 +1317,26 @@ func (s *Runservice) fetchLog(ctx context.Context, runID string, rt *types.RunTa
 		return nil
 	}
 
+	if executor == nil {
+		s.log.Warn().Msgf("executor with id %q doesn't exist. Using the log chunks uploaded by the executor", et.Spec.ExecutorID)
+		return s.fetchLogChunks(rt.ID, setup, stepnum, logPath)
+	}
+
 	var u string
 	if setup {
 		u = fmt.Sprintf(executor.ListenURL+"/api/v1alpha/executor/logs?taskid=%s&setup", et.ID)
 	} else {
 		u = fmt.Sprintf(executor.ListenURL+"/api/v1alpha/executor/logs?taskid=%s&step=%d", et.ID, stepnum)
 	}
-	r, err := http.Get(u)
+	r, err := s.executorClient.Get(u)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	defer r.Body.Close()
 
-	// ignore if not found
+	// the executor could have lost its data, use the log chunks if available
 	if r.StatusCode == http.StatusNotFound {
-		return nil
+		return s.fetchLogChunks(rt.ID, setup, stepnum, logPath)
 	}
 	if r.StatusCode != http.StatusOK {
 		return errors.Errorf("received http status: %d", r.StatusCode)
@@ -1082,7 +1351,40 @@ func (s *Runservice) fetchLog(ctx context.Context, runID string, rt *types.RunTa
 		}
 	}
 
-	return errors.WithStack(s.ost.WriteObject(logPath, r.Body, size, false))
+	if err := s.ost.WriteObject(logPath, r.Body, size, false); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// the log chunks aren't needed anymore
+	if err := store.DeleteLogChunks(s.ost, rt.ID, setup, stepnum); err != nil {
+		s.log.Warn().Err(err).Msgf("failed to delete log chunks")
+	}
+
+	return nil
+}
+
+// fetchLogChunks saves the log made by the log chunks uploaded by the executor
+// while the task was running. It's used when the executor is lost before the
+// log is fetched to keep the log up to the executor loss
+func (s *Runservice) fetchLogChunks(rtID string, setup bool, stepnum int, logPath string) error {
+	cr, ok, err := store.ReadLogChunks(s.ost, rtID, setup, stepnum)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !ok {
+		return nil
+	}
+	defer cr.Close()
+
+	if err := s.ost.WriteObject(logPath, cr, -1, false); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if err := store.DeleteLogChunks(s.ost, rtID, setup, stepnum); err != nil {
+		s.log.Warn().Err(err).Msgf("failed to delete log chunks")
+	}
+
+	return nil
 }
 
 func (s *Runservice) finishSetupLogPhase(ctx context.Context, runID, runTaskID string) error {
@@ -1261,7 +1563,7 @@ func (s *Runservice) fetchArchive(ctx context.Context, runID string, rt *types.R
 
 	u := fmt.Sprintf(executor.ListenURL+"/api/v1alpha/executor/archives?taskid=%s&step=%d", et.ID, stepnum)
 	s.log.Debug().Msgf("fetchArchive: %s", u)
-	r, err := http.Get(u)
+	r, err := s.executorClient.Get(u)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -1442,8 +1744,17 @@ func (s *Runservice) runsScheduler(ctx context.Context) error {
 		return errors.WithStack(err)
 	}
 
+	// schedule the runs from the oldest so the available concurrency groups
+	// capacity is assigned to the runs in creation order
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Sequence < runs[j].Sequence })
+
+	groupsTasks, err := s.getConcurrencyGroupsTasks(ctx, runs)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	for _, r := range runs {
-		if err := s.runScheduler(ctx, r); err != nil {
+		if err := s.runScheduler(ctx, r, groupsTasks); err != nil {
 			s.log.Err(err).Send()
 		}
 	}
@@ -1451,8 +1762,8 @@ func (s *Runservice) runsScheduler(ctx context.Context) error {
 	return nil
 }
 
-func (s *Runservice) runScheduler(ctx context.Context, r *types.Run) error {
-	return s.scheduleRun(ctx, r.ID)
+func (s *Runservice) runScheduler(ctx context.Context, r *types.Run, groupsTasks concurrencyGroupsTasks) error {
+	return s.scheduleRun(ctx, r.ID, groupsTasks)
 }
 
 func (s *Runservice) finishedRunsArchiverLoop(ctx context.Context) {
@@ -1545,9 +1856,9 @@ func (s *Runservice) finishedRunArchiver(ctx context.Context, runID string) erro
 	return nil
 }
 
-func (s *Runservice) cacheCleanerLoop(ctx context.Context, cacheExpireInterval time.Duration) {
+func (s *Runservice) cacheCleanerLoop(ctx context.Context, cacheExpireInterval time.Duration, cacheGroupQuota int64) {
 	for {
-		if err := s.cacheCleaner(ctx, cacheExpireInterval); err != nil {
+		if err := s.cacheCleaner(ctx, cacheExpireInterval, cacheGroupQuota); err != nil {
 			s.log.Err(err).Send()
 		}
 
@@ -1560,7 +1871,9 @@ func (s *Runservice) cacheCleanerLoop(ctx context.Context, cacheExpireInterval t
 	}
 }
 
-func (s *Runservice) cacheCleaner(ctx context.Context, cacheExpireInterval time.Duration) error {
+// cacheCleaner removes the caches not used since cacheExpireInterval and the
+// least recently used caches of the cache groups exceeding cacheGroupQuota
+func (s *Runservice) cacheCleaner(ctx context.Context, cacheExpireInterval time.Duration, cacheGroupQuota int64) error {
 	s.log.Debug().Msgf("cacheCleaner")
 
 	l := s.lf.NewLock(common.CacheCleanerLockKey)
@@ -1569,18 +1882,15 @@ func (s *Runservice) cacheCleaner(ctx context.Context, cacheExpireInterval time.
 	}
 	defer func() { _ = l.Unlock() }()
 
-	doneCh := make(chan struct{})
-	defer close(doneCh)
-	for object := range s.ost.List(store.OSTCacheDir()+"/", "", true, doneCh) {
-		if object.Err != nil {
-			return object.Err
-		}
-		if object.LastModified.Add(cacheExpireInterval).Before(time.Now()) {
-			if err := s.ost.DeleteObject(object.Path); err != nil {
-				if !objectstorage.IsNotExist(err) {
-					s.log.Warn().Msgf("failed to delete cache object %q: %v", object.Path, err)
-				}
-			}
+	caches, err := store.ListCaches(s.ost, "")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, ci := range store.CachesToEvict(caches, cacheExpireInterval, cacheGroupQuota, time.Now()) {
+		s.log.Debug().Msgf("removing cache group: %q, key: %q, size: %d, last used: %s", ci.Group, ci.Key, ci.Size, ci.LastUsed)
+		if err := store.DeleteCache(s.ost, ci.Group, ci.Key); err != nil {
+			s.log.Warn().Msgf("failed to delete cache group: %q, key: %q: %v", ci.Group, ci.Key, err)
 		}
 	}
 
@@ -19,6 +19,7 @@ import (
 	"testing"
 	"time"
 
+	"agola.io/agola/internal/services/runservice/common"
 	"agola.io/agola/internal/testutil"
 	"agola.io/agola/services/runservice/types"
 	ctypes "agola.io/agola/services/types"
@@ -244,6 +245,48 @@ func TestAdvanceRunTasks(t *testing.T) {
 				return run
 			}(),
 		},
+		{
+			name: "test task set to not skipped when the parent is failed and task condition is always",
+			rc: func() *types.RunConfig {
+				rc := rc.DeepCopy()
+				rc.Tasks["task02"].Depends["task01"].Conditions = []types.RunConfigTaskDependCondition{types.RunConfigTaskDependConditionAlways}
+				return rc
+			}(),
+			r: func() *types.Run {
+				run := run.DeepCopy()
+				run.Tasks["task01"].Status = types.RunTaskStatusFailed
+				return run
+			}(),
+			out: func() *types.Run {
+				run := run.DeepCopy()
+				run.Tasks["task01"].Status = types.RunTaskStatusFailed
+				run.Tasks["task02"].Status = types.RunTaskStatusNotStarted
+				return run
+			}(),
+		},
+		{
+			name: "test task set to not skipped when the parents are success and skipped and task conditions are always",
+			rc: func() *types.RunConfig {
+				rc := rc.DeepCopy()
+				rc.Tasks["task03"].Skip = true
+				rc.Tasks["task05"].Depends["task03"].Conditions = []types.RunConfigTaskDependCondition{types.RunConfigTaskDependConditionAlways}
+				rc.Tasks["task05"].Depends["task04"].Conditions = []types.RunConfigTaskDependCondition{types.RunConfigTaskDependConditionAlways}
+				return rc
+			}(),
+			r: func() *types.Run {
+				run := run.DeepCopy()
+				run.Tasks["task03"].Status = types.RunTaskStatusSkipped
+				run.Tasks["task04"].Status = types.RunTaskStatusSuccess
+				return run
+			}(),
+			out: func() *types.Run {
+				run := run.DeepCopy()
+				run.Tasks["task03"].Status = types.RunTaskStatusSkipped
+				run.Tasks["task04"].Status = types.RunTaskStatusSuccess
+				run.Tasks["task05"].Status = types.RunTaskStatusNotStarted
+				return run
+			}(),
+		},
 		{
 			name: "test task set to not skipped when one of the parent is skipped and task condition is on_skipped",
 			rc: func() *types.RunConfig {
@@ -397,6 +440,144 @@ func TestAdvanceRunTasks(t *testing.T) {
 				return run
 			}(),
 		},
+		{
+			name: "cancel all not started tasks not always run when run is failed with fail fast",
+			rc: func() *types.RunConfig {
+				rc := rc.DeepCopy()
+				rc.FailFast = true
+				rc.Tasks["task03"].AlwaysRun = true
+				return rc
+			}(),
+			r: func() *types.Run {
+				run := run.DeepCopy()
+				run.Result = types.RunResultFailed
+				run.Tasks["task01"].Status = types.RunTaskStatusRunning
+				run.Tasks["task04"].Status = types.RunTaskStatusFailed
+				return run
+			}(),
+			scheduledExecutorTasks: []*types.ExecutorTask{
+				{
+					ObjectMeta: ctypes.ObjectMeta{ID: "executortask01"},
+					Spec: types.ExecutorTaskSpec{
+						RunTaskID: "task01",
+					},
+				},
+			},
+			out: func() *types.Run {
+				run := run.DeepCopy()
+				run.Result = types.RunResultFailed
+				run.Tasks["task01"].Status = types.RunTaskStatusRunning
+				run.Tasks["task02"].Status = types.RunTaskStatusCancelled
+				run.Tasks["task03"].Status = types.RunTaskStatusNotStarted
+				run.Tasks["task04"].Status = types.RunTaskStatusFailed
+				run.Tasks["task05"].Status = types.RunTaskStatusCancelled
+				return run
+			}(),
+		},
+		{
+			name: "don't cancel tasks depending on their parents with the always condition when run is failed with fail fast",
+			rc: func() *types.RunConfig {
+				rc := rc.DeepCopy()
+				rc.FailFast = true
+				rc.Tasks["task05"].Depends["task03"].Conditions = []types.RunConfigTaskDependCondition{types.RunConfigTaskDependConditionAlways}
+				rc.Tasks["task05"].Depends["task04"].Conditions = []types.RunConfigTaskDependCondition{types.RunConfigTaskDependConditionAlways}
+				return rc
+			}(),
+			r: func() *types.Run {
+				run := run.DeepCopy()
+				run.Result = types.RunResultFailed
+				run.Tasks["task01"].Status = types.RunTaskStatusFailed
+				run.Tasks["task03"].Status = types.RunTaskStatusRunning
+				run.Tasks["task04"].Status = types.RunTaskStatusSuccess
+				return run
+			}(),
+			scheduledExecutorTasks: []*types.ExecutorTask{
+				{
+					ObjectMeta: ctypes.ObjectMeta{ID: "executortask03"},
+					Spec: types.ExecutorTaskSpec{
+						RunTaskID: "task03",
+					},
+				},
+			},
+			out: func() *types.Run {
+				run := run.DeepCopy()
+				run.Result = types.RunResultFailed
+				run.Tasks["task01"].Status = types.RunTaskStatusFailed
+				run.Tasks["task02"].Status = types.RunTaskStatusCancelled
+				run.Tasks["task03"].Status = types.RunTaskStatusRunning
+				run.Tasks["task04"].Status = types.RunTaskStatusSuccess
+				run.Tasks["task05"].Status = types.RunTaskStatusNotStarted
+				return run
+			}(),
+		},
+		{
+			name: "cancel not started always run root tasks when run is failed without fail fast",
+			rc: func() *types.RunConfig {
+				rc := rc.DeepCopy()
+				rc.Tasks["task03"].AlwaysRun = true
+				return rc
+			}(),
+			r: func() *types.Run {
+				run := run.DeepCopy()
+				run.Result = types.RunResultFailed
+				run.Tasks["task01"].Status = types.RunTaskStatusRunning
+				run.Tasks["task04"].Status = types.RunTaskStatusFailed
+				return run
+			}(),
+			scheduledExecutorTasks: []*types.ExecutorTask{
+				{
+					ObjectMeta: ctypes.ObjectMeta{ID: "executortask01"},
+					Spec: types.ExecutorTaskSpec{
+						RunTaskID: "task01",
+					},
+				},
+			},
+			out: func() *types.Run {
+				run := run.DeepCopy()
+				run.Result = types.RunResultFailed
+				run.Tasks["task01"].Status = types.RunTaskStatusRunning
+				run.Tasks["task02"].Status = types.RunTaskStatusNotStarted
+				run.Tasks["task03"].Status = types.RunTaskStatusCancelled
+				run.Tasks["task04"].Status = types.RunTaskStatusFailed
+				run.Tasks["task05"].Status = types.RunTaskStatusNotStarted
+				return run
+			}(),
+		},
+		{
+			name: "skip not started always run root tasks when run is set to stop without fail fast",
+			rc: func() *types.RunConfig {
+				rc := rc.DeepCopy()
+				rc.Tasks["task03"].AlwaysRun = true
+				return rc
+			}(),
+			r: func() *types.Run {
+				run := run.DeepCopy()
+				run.Result = types.RunResultStopped
+				run.Stop = true
+				run.Tasks["task01"].Status = types.RunTaskStatusRunning
+				run.Tasks["task04"].Status = types.RunTaskStatusSuccess
+				return run
+			}(),
+			scheduledExecutorTasks: []*types.ExecutorTask{
+				{
+					ObjectMeta: ctypes.ObjectMeta{ID: "executortask01"},
+					Spec: types.ExecutorTaskSpec{
+						RunTaskID: "task01",
+					},
+				},
+			},
+			out: func() *types.Run {
+				run := run.DeepCopy()
+				run.Result = types.RunResultStopped
+				run.Stop = true
+				run.Tasks["task01"].Status = types.RunTaskStatusRunning
+				run.Tasks["task02"].Status = types.RunTaskStatusSkipped
+				run.Tasks["task03"].Status = types.RunTaskStatusSkipped
+				run.Tasks["task04"].Status = types.RunTaskStatusSuccess
+				run.Tasks["task05"].Status = types.RunTaskStatusSkipped
+				return run
+			}(),
+		},
 	}
 
 	for _, tt := range tests {
@@ -412,6 +593,71 @@ func TestAdvanceRunTasks(t *testing.T) {
 	}
 }
 
+func TestAdvanceRunAllowFailure(t *testing.T) {
+	log := testutil.NewLogger(t)
+
+	rc := &types.RunConfig{
+		Tasks: map[string]*types.RunConfigTask{
+			"task01": &types.RunConfigTask{
+				ID:            "task01",
+				Name:          "task01",
+				Depends:       map[string]*types.RunConfigTaskDepend{},
+				IgnoreFailure: true,
+			},
+			"task02": &types.RunConfigTask{
+				ID:      "task02",
+				Name:    "task02",
+				Depends: map[string]*types.RunConfigTaskDepend{},
+			},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		task01Status types.RunTaskStatus
+		task02Status types.RunTaskStatus
+		outResult    types.RunResult
+	}{
+		{
+			name:         "test failed task allowed to fail",
+			task01Status: types.RunTaskStatusFailed,
+			task02Status: types.RunTaskStatusSuccess,
+			outResult:    types.RunResultSuccess,
+		},
+		{
+			name:         "test failed task not allowed to fail",
+			task01Status: types.RunTaskStatusSuccess,
+			task02Status: types.RunTaskStatusFailed,
+			outResult:    types.RunResultFailed,
+		},
+		{
+			name:         "test failed task allowed to fail with other tasks running",
+			task01Status: types.RunTaskStatusFailed,
+			task02Status: types.RunTaskStatusRunning,
+			outResult:    types.RunResultUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &types.Run{
+				Phase:  types.RunPhaseRunning,
+				Result: types.RunResultUnknown,
+				Tasks: map[string]*types.RunTask{
+					"task01": &types.RunTask{ID: "task01", Status: tt.task01Status},
+					"task02": &types.RunTask{ID: "task02", Status: tt.task02Status},
+				},
+			}
+			if err := advanceRun(log, r, rc, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if r.Result != tt.outResult {
+				t.Fatalf("expected run result %q, got %q", tt.outResult, r.Result)
+			}
+		})
+	}
+}
+
 func TestGetTasksToRun(t *testing.T) {
 	log := testutil.NewLogger(t)
 
@@ -563,7 +809,7 @@ func TestGetTasksToRun(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tasks, err := getTasksToRun(log, tt.r, tt.rc)
+			tasks, err := common.GetTasksToRun(log, tt.r, tt.rc)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -606,6 +852,34 @@ func TestChooseExecutor(t *testing.T) {
 		return e
 	}()
 
+	executorDraining := func() *types.Executor {
+		e := executorOK.DeepCopy()
+		e.ExecutorID = "executorDraining"
+		e.Draining = true
+		return e
+	}()
+
+	executorPending := func() *types.Executor {
+		e := executorOK.DeepCopy()
+		e.ExecutorID = "executorPending"
+		e.State = types.ExecutorStatePending
+		return e
+	}()
+
+	executorBanned := func() *types.Executor {
+		e := executorOK.DeepCopy()
+		e.ExecutorID = "executorBanned"
+		e.State = types.ExecutorStateBanned
+		return e
+	}()
+
+	executorUnhealthy := func() *types.Executor {
+		e := executorOK.DeepCopy()
+		e.ExecutorID = "executorUnhealthy"
+		e.HealthProblems = []string{"disk free 1.0% is lower than 5%"}
+		return e
+	}()
+
 	executorOKMultipleArchs := func() *types.Executor {
 		e := executorOK.DeepCopy()
 		e.ExecutorID = "executorOKMultipleArchs"
@@ -620,6 +894,61 @@ func TestChooseExecutor(t *testing.T) {
 		return e
 	}()
 
+	executorOKAllowsProjectPriviledContainers := func() *types.Executor {
+		e := executorOK.DeepCopy()
+		e.ExecutorID = "executorOKAllowsProjectPrivilegedContainers"
+		e.AllowPrivilegedContainers = true
+		e.PrivilegedContainersProjects = []string{"project01"}
+		return e
+	}()
+
+	executorOKGPUs := func() *types.Executor {
+		e := executorOK.DeepCopy()
+		e.ExecutorID = "executorOKGPUs"
+		e.GPUs = 2
+		return e
+	}()
+
+	executorOKHost := func() *types.Executor {
+		e := executorOK.DeepCopy()
+		e.ExecutorID = "executorOKHost"
+		e.RuntimeType = types.RuntimeTypeHost
+		return e
+	}()
+
+	executorOKWindows := func() *types.Executor {
+		e := executorOK.DeepCopy()
+		e.ExecutorID = "executorOKWindows"
+		e.Labels = map[string]string{types.ExecutorLabelOS: "windows"}
+		return e
+	}()
+
+	executorOKWithLabels := func() *types.Executor {
+		e := executorOK.DeepCopy()
+		e.ExecutorID = "executorOKWithLabels"
+		e.Labels = map[string]string{"disk": "ssd"}
+		return e
+	}()
+
+	executorOKFeatures := func() *types.Executor {
+		e := executorOK.DeepCopy()
+		e.ExecutorID = "executorOKFeatures"
+		e.Features = []types.ExecutorFeature{types.ExecutorFeatureCacheGroups, types.ExecutorFeatureWorkspaceOverwrite}
+		return e
+	}()
+
+	executorOKLabelsActiveTasksLimits := func() *types.Executor {
+		e := executorOK.DeepCopy()
+		e.ExecutorID = "executorOKLabelsActiveTasksLimits"
+		e.LabelsActiveTasksLimits = []types.LabelsActiveTasksLimit{
+			{Labels: map[string]string{"type": "deploy"}, Limit: 1},
+		}
+		return e
+	}()
+
+	deployExecutorTask := types.NewExecutorTask()
+	deployExecutorTask.Spec.Labels = map[string]string{"type": "deploy", "env": "prod"}
+
 	// Only primary and the required variables for this test are set
 	rct := &types.RunConfigTask{
 		ID:   "task01",
@@ -642,11 +971,108 @@ func TestChooseExecutor(t *testing.T) {
 		},
 	}
 
+	rctWithAddedCapabilities := &types.RunConfigTask{
+		ID:   "task01",
+		Name: "task01",
+		Runtime: &types.Runtime{Type: types.RuntimeType("pod"),
+			Arch: ctypes.ArchAMD64,
+			Containers: []*types.Container{
+				{
+					CapAdd: []string{"SYS_ADMIN"},
+				},
+			},
+		},
+	}
+
+	rctWithGPUs := &types.RunConfigTask{
+		ID:   "task01",
+		Name: "task01",
+		Runtime: &types.Runtime{Type: types.RuntimeType("pod"),
+			Arch: ctypes.ArchAMD64,
+			GPUs: 1,
+		},
+	}
+
+	rctWithCache := &types.RunConfigTask{
+		ID:   "task01",
+		Name: "task01",
+		Runtime: &types.Runtime{Type: types.RuntimeType("pod"),
+			Arch: ctypes.ArchAMD64,
+		},
+		Steps: types.Steps{
+			&types.RestoreCacheStep{Keys: []string{"cache01"}},
+		},
+	}
+
+	rctHost := &types.RunConfigTask{
+		ID:   "task01",
+		Name: "task01",
+		Runtime: &types.Runtime{Type: types.RuntimeTypeHost,
+			Arch: ctypes.ArchAMD64,
+		},
+	}
+
+	rctWindows := &types.RunConfigTask{
+		ID:   "task01",
+		Name: "task01",
+		Runtime: &types.Runtime{Type: types.RuntimeType("pod"),
+			Arch:           ctypes.ArchAMD64,
+			ExecutorLabels: map[string]string{types.ExecutorLabelOS: "windows"},
+		},
+	}
+
+	rctARM64 := &types.RunConfigTask{
+		ID:   "task01",
+		Name: "task01",
+		Runtime: &types.Runtime{Type: types.RuntimeType("pod"),
+			ExecutorLabels: map[string]string{types.ExecutorLabelArch: "arm64"},
+		},
+	}
+
+	rctWithLabels := &types.RunConfigTask{
+		ID:   "task01",
+		Name: "task01",
+		Runtime: &types.Runtime{Type: types.RuntimeType("pod"),
+			Arch:           ctypes.ArchAMD64,
+			ExecutorLabels: map[string]string{"disk": "ssd"},
+		},
+	}
+
+	rctDeploy := &types.RunConfigTask{
+		ID:     "task01",
+		Name:   "task01",
+		Labels: map[string]string{"type": "deploy"},
+		Runtime: &types.Runtime{Type: types.RuntimeType("pod"),
+			Arch: ctypes.ArchAMD64,
+		},
+	}
+
+	rctAffinity := &types.RunConfigTask{
+		ID:   "task01",
+		Name: "task01",
+		Runtime: &types.Runtime{Type: types.RuntimeType("pod"),
+			Arch:             ctypes.ArchAMD64,
+			ExecutorAffinity: types.ExecutorAffinityRun,
+		},
+	}
+
+	rctAntiAffinity := &types.RunConfigTask{
+		ID:   "task01",
+		Name: "task01",
+		Runtime: &types.Runtime{Type: types.RuntimeType("pod"),
+			Arch:             ctypes.ArchAMD64,
+			ExecutorAffinity: types.ExecutorAntiAffinityRun,
+		},
+	}
+
 	tests := []struct {
-		name      string
-		executors []*types.Executor
-		rct       *types.RunConfigTask
-		out       *types.Executor
+		name           string
+		executors      []*types.Executor
+		executorsTasks map[string][]*types.ExecutorTask
+		runExecutors   map[string]struct{}
+		runGroup       string
+		rct            *types.RunConfigTask
+		out            *types.Executor
 	}{
 		{
 			name:      "test single executor ok",
@@ -696,11 +1122,219 @@ func TestChooseExecutor(t *testing.T) {
 			rct:       rctWithPrivilegedContainers,
 			out:       executorOKAllowsPriviledContainers,
 		},
+		{
+			name:      "test single executor without allowed privileged container but added capabilities are required",
+			executors: []*types.Executor{executorOK},
+			rct:       rctWithAddedCapabilities,
+			out:       nil,
+		},
+		{
+			name:      "test single executor with allowed privileged container and added capabilities are required",
+			executors: []*types.Executor{executorOKAllowsPriviledContainers},
+			rct:       rctWithAddedCapabilities,
+			out:       executorOKAllowsPriviledContainers,
+		},
+		{
+			name:      "test single executor with privileged containers allowed for the run project",
+			executors: []*types.Executor{executorOKAllowsProjectPriviledContainers},
+			runGroup:  "/project/project01/branch/master",
+			rct:       rctWithPrivilegedContainers,
+			out:       executorOKAllowsProjectPriviledContainers,
+		},
+		{
+			name:      "test single executor with privileged containers not allowed for the run project",
+			executors: []*types.Executor{executorOKAllowsProjectPriviledContainers},
+			runGroup:  "/project/project02/branch/master",
+			rct:       rctWithPrivilegedContainers,
+			out:       nil,
+		},
+		{
+			name:      "test single executor with privileged containers allowed for a project and user direct run",
+			executors: []*types.Executor{executorOKAllowsProjectPriviledContainers},
+			runGroup:  "/user/user01/direct/branch01",
+			rct:       rctWithPrivilegedContainers,
+			out:       nil,
+		},
+		{
+			name:      "test single draining executor",
+			executors: []*types.Executor{executorDraining},
+			rct:       rct,
+			out:       nil,
+		},
+		{
+			name:      "test draining executor and executor ok",
+			executors: []*types.Executor{executorDraining, executorOK},
+			rct:       rct,
+			out:       executorOK,
+		},
+		{
+			name:      "test pending and banned executors",
+			executors: []*types.Executor{executorPending, executorBanned},
+			rct:       rct,
+			out:       nil,
+		},
+		{
+			name:      "test pending executor and executor ok",
+			executors: []*types.Executor{executorPending, executorOK},
+			rct:       rct,
+			out:       executorOK,
+		},
+		{
+			name:      "test unhealthy executor",
+			executors: []*types.Executor{executorUnhealthy},
+			rct:       rct,
+			out:       nil,
+		},
+		{
+			name:      "test unhealthy executor and executor ok",
+			executors: []*types.Executor{executorUnhealthy, executorOK},
+			rct:       rct,
+			out:       executorOK,
+		},
+		{
+			name:      "test task requiring gpus and executor without gpus",
+			executors: []*types.Executor{executorOK},
+			rct:       rctWithGPUs,
+			out:       nil,
+		},
+		{
+			name:      "test task requiring gpus and executors with and without gpus",
+			executors: []*types.Executor{executorOK, executorOKGPUs},
+			rct:       rctWithGPUs,
+			out:       executorOKGPUs,
+		},
+		{
+			name:      "test task requiring features and executor without features",
+			executors: []*types.Executor{executorOK},
+			rct:       rctWithCache,
+			out:       nil,
+		},
+		{
+			name:      "test task requiring features and executors with and without features",
+			executors: []*types.Executor{executorOK, executorOKFeatures},
+			rct:       rctWithCache,
+			out:       executorOKFeatures,
+		},
+		{
+			name:      "test host executor and pod task",
+			executors: []*types.Executor{executorOKHost},
+			rct:       rct,
+			out:       nil,
+		},
+		{
+			name:      "test pod executor and host task",
+			executors: []*types.Executor{executorOK},
+			rct:       rctHost,
+			out:       nil,
+		},
+		{
+			name:      "test multiple executors and host task",
+			executors: []*types.Executor{executorOK, executorOKHost},
+			rct:       rctHost,
+			out:       executorOKHost,
+		},
+		{
+			name:      "test windows executor and linux task",
+			executors: []*types.Executor{executorOKWindows},
+			rct:       rct,
+			out:       nil,
+		},
+		{
+			name:      "test linux executor and windows task",
+			executors: []*types.Executor{executorOK},
+			rct:       rctWindows,
+			out:       nil,
+		},
+		{
+			name:      "test multiple executors and windows task",
+			executors: []*types.Executor{executorOK, executorOKWindows},
+			rct:       rctWindows,
+			out:       executorOKWindows,
+		},
+		{
+			name:      "test single executor and task with different arch label",
+			executors: []*types.Executor{executorOK},
+			rct:       rctARM64,
+			out:       nil,
+		},
+		{
+			name:      "test single executor with multiple archs and task with arch label matching one of them",
+			executors: []*types.Executor{executorOKMultipleArchs},
+			rct:       rctARM64,
+			out:       executorOKMultipleArchs,
+		},
+		{
+			name:      "test executor without the task required labels",
+			executors: []*types.Executor{executorOK},
+			rct:       rctWithLabels,
+			out:       nil,
+		},
+		{
+			name:      "test multiple executors and one with the task required labels",
+			executors: []*types.Executor{executorOK, executorOKWithLabels},
+			rct:       rctWithLabels,
+			out:       executorOKWithLabels,
+		},
+		{
+			name:      "test executor labels active tasks limit not reached",
+			executors: []*types.Executor{executorOKLabelsActiveTasksLimits},
+			executorsTasks: map[string][]*types.ExecutorTask{
+				executorOKLabelsActiveTasksLimits.ExecutorID: {types.NewExecutorTask()},
+			},
+			rct: rctDeploy,
+			out: executorOKLabelsActiveTasksLimits,
+		},
+		{
+			name:      "test executor labels active tasks limit reached",
+			executors: []*types.Executor{executorOKLabelsActiveTasksLimits},
+			executorsTasks: map[string][]*types.ExecutorTask{
+				executorOKLabelsActiveTasksLimits.ExecutorID: {deployExecutorTask},
+			},
+			rct: rctDeploy,
+			out: nil,
+		},
+		{
+			name:      "test executor labels active tasks limit reached with task not matching the limit labels",
+			executors: []*types.Executor{executorOKLabelsActiveTasksLimits},
+			executorsTasks: map[string][]*types.ExecutorTask{
+				executorOKLabelsActiveTasksLimits.ExecutorID: {deployExecutorTask},
+			},
+			rct: rct,
+			out: executorOKLabelsActiveTasksLimits,
+		},
+		{
+			name:         "test executor affinity",
+			executors:    []*types.Executor{executorOK, executorOKMultipleArchs},
+			runExecutors: map[string]struct{}{executorOKMultipleArchs.ExecutorID: {}},
+			rct:          rctAffinity,
+			out:          executorOKMultipleArchs,
+		},
+		{
+			name:         "test executor affinity with run executor not available",
+			executors:    []*types.Executor{executorOK, executorNoFreeTaskSlots},
+			runExecutors: map[string]struct{}{executorNoFreeTaskSlots.ExecutorID: {}},
+			rct:          rctAffinity,
+			out:          executorOK,
+		},
+		{
+			name:         "test executor anti affinity",
+			executors:    []*types.Executor{executorOK, executorOKMultipleArchs},
+			runExecutors: map[string]struct{}{executorOK.ExecutorID: {}},
+			rct:          rctAntiAffinity,
+			out:          executorOKMultipleArchs,
+		},
+		{
+			name:         "test executor anti affinity with only run executors available",
+			executors:    []*types.Executor{executorOK},
+			runExecutors: map[string]struct{}{executorOK.ExecutorID: {}},
+			rct:          rctAntiAffinity,
+			out:          executorOK,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			e := chooseExecutor(tt.executors, map[string]int{}, tt.rct)
+			e := chooseExecutor(tt.executors, tt.executorsTasks, tt.runExecutors, tt.runGroup, tt.rct)
 			if e == nil && tt.out == nil {
 				return
 			}
@@ -716,3 +1350,166 @@ func TestChooseExecutor(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateRunTaskStatusRunMeta(t *testing.T) {
+	log := testutil.NewLogger(t)
+
+	s := &Runservice{log: log}
+
+	r := &types.Run{
+		Tasks: map[string]*types.RunTask{
+			"task01": {ID: "task01", Status: types.RunTaskStatusRunning},
+			"task02": {ID: "task02", Status: types.RunTaskStatusRunning},
+		},
+	}
+
+	et1 := &types.ExecutorTask{
+		Spec: types.ExecutorTaskSpec{RunTaskID: "task01"},
+		Status: types.ExecutorTaskStatus{
+			Phase:   types.ExecutorTaskPhaseRunning,
+			RunMeta: map[string]string{"version": "1.0.0", "image": "image01"},
+		},
+	}
+	et2 := &types.ExecutorTask{
+		Spec: types.ExecutorTaskSpec{RunTaskID: "task02"},
+		Status: types.ExecutorTaskStatus{
+			Phase:   types.ExecutorTaskPhaseRunning,
+			RunMeta: map[string]string{"version": "1.0.1"},
+		},
+	}
+
+	for _, et := range []*types.ExecutorTask{et1, et2} {
+		if err := s.updateRunTaskStatus(et, r); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+
+	expectedMeta := map[string]string{"version": "1.0.1", "image": "image01"}
+	if diff := cmp.Diff(expectedMeta, r.Meta); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestUpdateRunTaskStatusMetrics(t *testing.T) {
+	log := testutil.NewLogger(t)
+
+	s := &Runservice{log: log}
+
+	r := &types.Run{
+		Tasks: map[string]*types.RunTask{
+			"task01": {ID: "task01", Status: types.RunTaskStatusRunning},
+			"task02": {ID: "task02", Status: types.RunTaskStatusRunning},
+		},
+	}
+
+	et1 := &types.ExecutorTask{
+		Spec: types.ExecutorTaskSpec{RunTaskID: "task01"},
+		Status: types.ExecutorTaskStatus{
+			Phase:   types.ExecutorTaskPhaseRunning,
+			Metrics: map[string]float64{"coverage": 80.5, "bundle_size": 1024},
+		},
+	}
+	et2 := &types.ExecutorTask{
+		Spec: types.ExecutorTaskSpec{RunTaskID: "task02"},
+		Status: types.ExecutorTaskStatus{
+			Phase:   types.ExecutorTaskPhaseRunning,
+			Metrics: map[string]float64{"coverage": 81},
+		},
+	}
+
+	for _, et := range []*types.ExecutorTask{et1, et2} {
+		if err := s.updateRunTaskStatus(et, r); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+
+	expectedMetrics := map[string]float64{"coverage": 81, "bundle_size": 1024}
+	if diff := cmp.Diff(expectedMetrics, r.Metrics); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestUpdateRunTaskStatusResourceUsage(t *testing.T) {
+	log := testutil.NewLogger(t)
+
+	s := &Runservice{log: log}
+
+	r := &types.Run{
+		Tasks: map[string]*types.RunTask{
+			"task01": {ID: "task01", Status: types.RunTaskStatusRunning},
+		},
+	}
+
+	ru := &types.ResourceUsage{
+		CPUTime:     30 * time.Second,
+		MaxCPU:      1500,
+		Memory:      100 * 1024 * 1024,
+		MaxMemory:   200 * 1024 * 1024,
+		MemoryLimit: 256 * 1024 * 1024,
+		Disk:        10 * 1024 * 1024,
+	}
+
+	et := &types.ExecutorTask{
+		Spec:   types.ExecutorTaskSpec{RunTaskID: "task01"},
+		Status: types.ExecutorTaskStatus{Phase: types.ExecutorTaskPhaseRunning, ResourceUsage: ru},
+	}
+	if err := s.updateRunTaskStatus(et, r); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if diff := cmp.Diff(ru, r.Tasks["task01"].ResourceUsage); diff != "" {
+		t.Error(diff)
+	}
+
+	// a status without resource usage (i.e. sent before the first sample)
+	// doesn't reset the last reported one
+	et.Status.ResourceUsage = nil
+	if err := s.updateRunTaskStatus(et, r); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if diff := cmp.Diff(ru, r.Tasks["task01"].ResourceUsage); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestTasksConcurrencyLimitReached(t *testing.T) {
+	r := &types.Run{
+		ConcurrencyGroups: []*types.RunConcurrencyGroup{
+			{Name: "/org/org01", MaxRunningTasks: 3},
+			{Name: "/project/project01", MaxRunningTasks: 2},
+		},
+	}
+	unlimitedRun := &types.Run{
+		ConcurrencyGroups: []*types.RunConcurrencyGroup{
+			{Name: "/project/project02", MaxRunningRuns: 1},
+		},
+	}
+
+	groupsTasks := concurrencyGroupsTasks{"/org/org01": 1}
+
+	if tasksConcurrencyLimitReached(r, groupsTasks) {
+		t.Fatalf("unexpected limit reached")
+	}
+	groupsTasks.addRunTask(r)
+	if tasksConcurrencyLimitReached(r, groupsTasks) {
+		t.Fatalf("unexpected limit reached")
+	}
+	// the project limit is reached before the org one
+	groupsTasks.addRunTask(r)
+	if !tasksConcurrencyLimitReached(r, groupsTasks) {
+		t.Fatalf("expected project limit reached")
+	}
+
+	expectedGroupsTasks := concurrencyGroupsTasks{"/org/org01": 3, "/project/project01": 2}
+	if diff := cmp.Diff(expectedGroupsTasks, groupsTasks); diff != "" {
+		t.Error(diff)
+	}
+
+	// without the groups running tasks count, runs with a tasks limit are
+	// considered limited
+	if !tasksConcurrencyLimitReached(r, nil) {
+		t.Fatalf("expected limit reached")
+	}
+	if tasksConcurrencyLimitReached(unlimitedRun, nil) {
+		t.Fatalf("unexpected limit reached")
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/util"
+)
+
+// The artifacts saved by a run task are saved as a tar archive. An index of
+// the archived files is saved alongside the archive to list the artifacts
+// without reading the whole archive.
+
+// ArtifactInfo contains the information of an artifact file
+type ArtifactInfo struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	Mode int64  `json:"mode"`
+}
+
+func OSTArtifactsDir() string {
+	return "artifacts"
+}
+
+func OSTRunArtifactsDir(runID string) string {
+	return path.Join(OSTArtifactsDir(), runID)
+}
+
+func OSTRunTaskArtifactsPath(runID, taskID string) string {
+	return path.Join(OSTRunArtifactsDir(runID), taskID+".tar")
+}
+
+func OSTRunTaskArtifactsIndexPath(runID, taskID string) string {
+	return path.Join(OSTRunArtifactsDir(runID), taskID+".json")
+}
+
+// artifactPath returns the artifact path from the tar header name
+func artifactPath(name string) string {
+	return path.Clean(strings.TrimPrefix(name, "/"))
+}
+
+// readArtifactsIndex returns the regular files contained in the tar archive
+func readArtifactsIndex(r io.Reader) ([]*ArtifactInfo, error) {
+	artifacts := []*ArtifactInfo{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		artifacts = append(artifacts, &ArtifactInfo{Path: artifactPath(hdr.Name), Size: hdr.Size, Mode: hdr.Mode})
+	}
+
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Path < artifacts[j].Path })
+
+	return artifacts, nil
+}
+
+// WriteTaskArtifacts saves the task artifacts tar archive and its index
+func WriteTaskArtifacts(ost *objectstorage.ObjStorage, runID, taskID string, r io.Reader, size int64) ([]*ArtifactInfo, error) {
+	// read the archive index while writing it
+	pr, pw := io.Pipe()
+	type indexResult struct {
+		artifacts []*ArtifactInfo
+		err       error
+	}
+	indexCh := make(chan indexResult, 1)
+	go func() {
+		artifacts, err := readArtifactsIndex(pr)
+		if err != nil {
+			pr.CloseWithError(err)
+		} else {
+			// consume the data after the tar end to not block the writer
+			_, _ = io.Copy(ioutil.Discard, pr)
+		}
+		indexCh <- indexResult{artifacts: artifacts, err: err}
+	}()
+
+	werr := ost.WriteObject(OSTRunTaskArtifactsPath(runID, taskID), io.TeeReader(r, pw), size, false)
+	pw.Close()
+	ir := <-indexCh
+	if werr != nil {
+		return nil, errors.WithStack(werr)
+	}
+	if ir.err != nil {
+		return nil, errors.Wrapf(ir.err, "failed to read artifacts archive")
+	}
+
+	indexj, err := json.Marshal(ir.artifacts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := ost.WriteObject(OSTRunTaskArtifactsIndexPath(runID, taskID), bytes.NewReader(indexj), int64(len(indexj)), false); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return ir.artifacts, nil
+}
+
+// ListRunArtifacts returns the artifacts of the run tasks grouped by task id
+func ListRunArtifacts(ost *objectstorage.ObjStorage, runID string) (map[string][]*ArtifactInfo, error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	artifacts := map[string][]*ArtifactInfo{}
+	for object := range ost.List(OSTRunArtifactsDir(runID)+"/", "", false, doneCh) {
+		if object.Err != nil {
+			return nil, errors.WithStack(object.Err)
+		}
+		if path.Ext(object.Path) != ".json" {
+			continue
+		}
+		taskID := strings.TrimSuffix(path.Base(object.Path), ".json")
+
+		f, err := ost.ReadObject(object.Path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var taskArtifacts []*ArtifactInfo
+		err = json.NewDecoder(f).Decode(&taskArtifacts)
+		f.Close()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		artifacts[taskID] = taskArtifacts
+	}
+
+	return artifacts, nil
+}
+
+// ReadTaskArtifacts returns the task artifacts tar archive
+func ReadTaskArtifacts(ost *objectstorage.ObjStorage, runID, taskID string) (io.ReadCloser, error) {
+	f, err := ost.ReadObject(OSTRunTaskArtifactsPath(runID, taskID))
+	if err != nil {
+		if objectstorage.IsNotExist(err) {
+			return nil, util.NewAPIError(util.ErrNotExist, err)
+		}
+		return nil, errors.WithStack(err)
+	}
+	return f, nil
+}
+
+type artifactReader struct {
+	io.Reader
+	f io.Closer
+}
+
+func (r *artifactReader) Close() error {
+	return r.f.Close()
+}
+
+// ReadTaskArtifact returns the content of the provided task artifact file
+func ReadTaskArtifact(ost *objectstorage.ObjStorage, runID, taskID, artifact string) (io.ReadCloser, *ArtifactInfo, error) {
+	f, err := ReadTaskArtifacts(ost, runID, taskID)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	artifact = artifactPath(artifact)
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, nil, errors.WithStack(err)
+		}
+		if hdr.Typeflag != tar.TypeReg || artifactPath(hdr.Name) != artifact {
+			continue
+		}
+		ai := &ArtifactInfo{Path: artifact, Size: hdr.Size, Mode: hdr.Mode}
+		return &artifactReader{Reader: tr, f: f}, ai, nil
+	}
+
+	f.Close()
+	return nil, nil, util.NewAPIError(util.ErrNotExist, errors.Errorf("artifact %q doesn't exist", artifact))
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/util"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTaskArtifacts(t *testing.T) {
+	posix, err := objectstorage.NewPosix(path.Join(t.TempDir(), "ost"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	ost := objectstorage.NewObjStorage(posix, "/")
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "bin", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	files := []struct {
+		name    string
+		content string
+		mode    int64
+	}{
+		{name: "bin/app", content: "binary", mode: 0755},
+		{name: "report.txt", content: "report", mode: 0644},
+	}
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: f.mode, Size: int64(len(f.content))}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if _, err := tw.Write([]byte(f.content)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	expectedArtifacts := []*ArtifactInfo{
+		{Path: "bin/app", Size: 6, Mode: 0755},
+		{Path: "report.txt", Size: 6, Mode: 0644},
+	}
+
+	artifacts, err := WriteTaskArtifacts(ost, "run01", "task01", bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if diff := cmp.Diff(expectedArtifacts, artifacts); diff != "" {
+		t.Fatalf("artifacts mismatch (-want +got):\n%s", diff)
+	}
+
+	runArtifacts, err := ListRunArtifacts(ost, "run01")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if diff := cmp.Diff(map[string][]*ArtifactInfo{"task01": expectedArtifacts}, runArtifacts); diff != "" {
+		t.Fatalf("run artifacts mismatch (-want +got):\n%s", diff)
+	}
+
+	r, ai, err := ReadTaskArtifact(ost, "run01", "task01", "/bin/app")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	content, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if string(content) != "binary" {
+		t.Fatalf("expected artifact content %q, got %q", "binary", content)
+	}
+	if diff := cmp.Diff(expectedArtifacts[0], ai); diff != "" {
+		t.Fatalf("artifact mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, _, err := ReadTaskArtifact(ost, "run01", "task01", "bin"); !util.APIErrorIs(err, util.ErrNotExist) {
+		t.Fatalf("expected not exist error, got: %v", err)
+	}
+	if _, _, err := ReadTaskArtifact(ost, "run01", "task02", "report.txt"); !util.APIErrorIs(err, util.ErrNotExist) {
+		t.Fatalf("expected not exist error, got: %v", err)
+	}
+}
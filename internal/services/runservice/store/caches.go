@@ -0,0 +1,160 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/util"
+)
+
+// Every cache belongs to a cache group (a project or a user direct runs
+// repository) and is saved in the group dir. Since the object storage objects
+// can't be touched, when a cache is restored an empty access object is
+// written to track the cache last use.
+
+// CacheInfo contains the information of a saved cache
+type CacheInfo struct {
+	// Group is the cache group. Empty for caches saved before the caches were
+	// grouped
+	Group    string
+	Key      string
+	Size     int64
+	LastUsed time.Time
+}
+
+// ListCaches returns the caches of the provided group or, when group is
+// empty, of all the groups. The caches are sorted by last use.
+func ListCaches(ost *objectstorage.ObjStorage, group string) ([]*CacheInfo, error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	cachesDir := OSTCacheDir()
+	accessDir := OSTCacheAccessDir()
+	if group != "" {
+		cachesDir = OSTCacheGroupDir(group)
+		accessDir = path.Join(OSTCacheAccessDir(), group)
+	}
+
+	caches := map[string]*CacheInfo{}
+	for object := range ost.List(cachesDir+"/", "", true, doneCh) {
+		if object.Err != nil {
+			return nil, errors.WithStack(object.Err)
+		}
+		ci := &CacheInfo{Key: OSTCacheKey(object.Path), Size: object.Size, LastUsed: object.LastModified}
+		pl := util.PathList(object.Path)
+		switch len(pl) {
+		case 2:
+		case 3:
+			ci.Group = pl[1]
+		default:
+			continue
+		}
+		caches[path.Join(ci.Group, ci.Key)] = ci
+	}
+
+	for object := range ost.List(accessDir+"/", "", true, doneCh) {
+		if object.Err != nil {
+			return nil, errors.WithStack(object.Err)
+		}
+		ci, ok := caches[strings.TrimPrefix(object.Path, OSTCacheAccessDir()+"/")]
+		if !ok {
+			continue
+		}
+		if object.LastModified.After(ci.LastUsed) {
+			ci.LastUsed = object.LastModified
+		}
+	}
+
+	res := make([]*CacheInfo, 0, len(caches))
+	for _, ci := range caches {
+		res = append(res, ci)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].LastUsed.Equal(res[j].LastUsed) {
+			return res[i].Key < res[j].Key
+		}
+		return res[i].LastUsed.Before(res[j].LastUsed)
+	})
+
+	return res, nil
+}
+
+// CachesToEvict returns the caches, sorted by last use, not used since
+// expireInterval and, for every cache group, the least recently used caches
+// to remove to keep the group caches size under quota. 0 expireInterval or
+// quota means no expiration or no quota. Caches without a group are only
+// expired.
+func CachesToEvict(caches []*CacheInfo, expireInterval time.Duration, quota int64, now time.Time) []*CacheInfo {
+	groupsSize := map[string]int64{}
+	for _, ci := range caches {
+		groupsSize[ci.Group] += ci.Size
+	}
+
+	evict := []*CacheInfo{}
+	for _, ci := range caches {
+		expired := expireInterval > 0 && ci.LastUsed.Add(expireInterval).Before(now)
+		overQuota := quota > 0 && ci.Group != "" && groupsSize[ci.Group] > quota
+		if expired || overQuota {
+			evict = append(evict, ci)
+			groupsSize[ci.Group] -= ci.Size
+		}
+	}
+
+	return evict
+}
+
+// DeleteCache removes the cache and its access object
+func DeleteCache(ost *objectstorage.ObjStorage, group, key string) error {
+	cachePath := path.Join(OSTCacheDir(), key+".tar")
+	if group != "" {
+		cachePath = OSTCachePath(group, key)
+	}
+	if err := ost.DeleteObject(cachePath); err != nil && !objectstorage.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+	if group == "" {
+		return nil
+	}
+	if err := ost.DeleteObject(OSTCacheAccessPath(group, key)); err != nil && !objectstorage.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// DeleteCacheGroup removes all the caches of the group and their access
+// objects
+func DeleteCacheGroup(ost *objectstorage.ObjStorage, group string) error {
+	if group == "" {
+		return errors.Errorf("empty cache group")
+	}
+	if err := DeleteObjects(ost, OSTCacheGroupDir(group)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(DeleteObjects(ost, path.Join(OSTCacheAccessDir(), group)))
+}
+
+// TouchCache records the cache use
+func TouchCache(ost *objectstorage.ObjStorage, group, key string) error {
+	return errors.WithStack(ost.WriteObject(OSTCacheAccessPath(group, key), &bytes.Buffer{}, 0, false))
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"path"
+	"testing"
+	"time"
+
+	"agola.io/agola/internal/objectstorage"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListCaches(t *testing.T) {
+	posix, err := objectstorage.NewPosix(path.Join(t.TempDir(), "ost"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	ost := objectstorage.NewObjStorage(posix, "/")
+
+	writeObject := func(p string, size int) {
+		if err := ost.WriteObject(p, bytes.NewReader(make([]byte, size)), int64(size), false); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+
+	writeObject(path.Join(OSTCacheDir(), "legacy01.tar"), 10)
+	writeObject(OSTCachePath("group01", "key01"), 20)
+	writeObject(OSTCachePath("group01", "key02"), 30)
+	writeObject(OSTCachePath("group02", "key01"), 40)
+
+	// wait to get a different last modified time
+	time.Sleep(1100 * time.Millisecond)
+	if err := TouchCache(ost, "group01", "key01"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	caches, err := ListCaches(ost, "group01")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	out := []string{}
+	for _, ci := range caches {
+		out = append(out, ci.Group+"/"+ci.Key)
+	}
+	if diff := cmp.Diff([]string{"group01/key02", "group01/key01"}, out); diff != "" {
+		t.Error(diff)
+	}
+
+	caches, err = ListCaches(ost, "")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(caches) != 4 {
+		t.Fatalf("expected 4 caches, got %d", len(caches))
+	}
+	last := caches[len(caches)-1]
+	if last.Group != "group01" || last.Key != "key01" || last.Size != 20 {
+		t.Fatalf("expected last used cache group01/key01, got %s/%s", last.Group, last.Key)
+	}
+
+	if err := DeleteCache(ost, "group01", "key01"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := DeleteCache(ost, "", "legacy01"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	caches, err = ListCaches(ost, "")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(caches) != 2 {
+		t.Fatalf("expected 2 caches, got %d", len(caches))
+	}
+
+	if err := TouchCache(ost, "group02", "key01"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := DeleteCacheGroup(ost, "group02"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	caches, err = ListCaches(ost, "")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	out = []string{}
+	for _, ci := range caches {
+		out = append(out, ci.Group+"/"+ci.Key)
+	}
+	if diff := cmp.Diff([]string{"group01/key02"}, out); diff != "" {
+		t.Error(diff)
+	}
+	if _, err := ost.Stat(OSTCacheAccessPath("group02", "key01")); !objectstorage.IsNotExist(err) {
+		t.Fatalf("expected cache access object removed, got err: %v", err)
+	}
+}
+
+func TestCachesToEvict(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+
+	// caches are sorted by last use
+	caches := []*CacheInfo{
+		{Group: "", Key: "legacy01", Size: 100, LastUsed: now.Add(-10 * day)},
+		{Group: "group01", Key: "key01", Size: 100, LastUsed: now.Add(-9 * day)},
+		{Group: "group02", Key: "key01", Size: 100, LastUsed: now.Add(-3 * day)},
+		{Group: "group01", Key: "key02", Size: 100, LastUsed: now.Add(-2 * day)},
+		{Group: "group01", Key: "key03", Size: 100, LastUsed: now.Add(-1 * day)},
+		{Group: "", Key: "legacy02", Size: 1000, LastUsed: now.Add(-1 * day)},
+	}
+
+	tests := []struct {
+		name           string
+		expireInterval time.Duration
+		quota          int64
+		out            []string
+	}{
+		{
+			name: "test no expiration and no quota",
+			out:  []string{},
+		},
+		{
+			name:           "test expiration",
+			expireInterval: 7 * day,
+			out:            []string{"/legacy01", "group01/key01"},
+		},
+		{
+			name:  "test quota",
+			quota: 200,
+			out:   []string{"group01/key01"},
+		},
+		{
+			name:  "test quota smaller than the caches",
+			quota: 50,
+			out:   []string{"group01/key01", "group02/key01", "group01/key02", "group01/key03"},
+		},
+		{
+			name:           "test expiration and quota",
+			expireInterval: 2*day + time.Hour,
+			quota:          150,
+			out:            []string{"/legacy01", "group01/key01", "group02/key01", "group01/key02"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := []string{}
+			for _, ci := range CachesToEvict(caches, tt.expireInterval, tt.quota, now) {
+				out = append(out, ci.Group+"/"+ci.Key)
+			}
+
+			if diff := cmp.Diff(tt.out, out); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/objectstorage"
+)
+
+// The executor incrementally uploads the task logs as chunks while the task
+// is running so they are available also when the executor is lost before the
+// logs are fetched. Every chunk object is named by its offset in the log.
+
+func OSTRunTaskLogChunksDir(rtID string, setup bool, step int) string {
+	if setup {
+		return path.Join(OSTRunTaskLogsBaseDir(rtID), "chunks", "setup")
+	}
+	return path.Join(OSTRunTaskLogsBaseDir(rtID), "chunks", "steps", strconv.Itoa(step))
+}
+
+func OSTRunTaskLogChunkPath(rtID string, setup bool, step int, offset int64) string {
+	// zero pad the offset so the chunks are listed in order
+	return path.Join(OSTRunTaskLogChunksDir(rtID, setup, step), fmt.Sprintf("%020d", offset))
+}
+
+type logChunk struct {
+	path   string
+	offset int64
+	size   int64
+}
+
+func listLogChunks(ost *objectstorage.ObjStorage, rtID string, setup bool, step int) ([]*logChunk, error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	chunks := []*logChunk{}
+	for object := range ost.List(OSTRunTaskLogChunksDir(rtID, setup, step)+"/", "", true, doneCh) {
+		if object.Err != nil {
+			return nil, errors.WithStack(object.Err)
+		}
+		offset, err := strconv.ParseInt(path.Base(object.Path), 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("wrong log chunk path %q", object.Path)
+		}
+		chunks = append(chunks, &logChunk{path: object.Path, offset: offset, size: object.Size})
+	}
+
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].offset < chunks[j].offset })
+
+	return chunks, nil
+}
+
+// ReadLogChunks returns a reader of the log made by the contiguous log chunks
+// starting at offset 0. It returns false if there are no log chunks.
+func ReadLogChunks(ost *objectstorage.ObjStorage, rtID string, setup bool, step int) (io.ReadCloser, bool, error) {
+	chunks, err := listLogChunks(ost, rtID, setup, step)
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+
+	// keep only the contiguous chunks, a chunk may overlap the previous one
+	// if the executor uploaded it again with more data
+	var contiguous []*logChunk
+	var end int64
+	for _, c := range chunks {
+		if c.offset > end {
+			break
+		}
+		if c.offset+c.size <= end {
+			continue
+		}
+		contiguous = append(contiguous, c)
+		end = c.offset + c.size
+	}
+	if len(contiguous) == 0 {
+		return nil, false, nil
+	}
+
+	return &logChunksReader{ost: ost, chunks: contiguous}, true, nil
+}
+
+// DeleteLogChunks deletes all the log chunks
+func DeleteLogChunks(ost *objectstorage.ObjStorage, rtID string, setup bool, step int) error {
+	chunks, err := listLogChunks(ost, rtID, setup, step)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, c := range chunks {
+		if err := ost.DeleteObject(c.path); err != nil && !objectstorage.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// logChunksReader sequentially reads the log chunks, opening them only when
+// needed
+type logChunksReader struct {
+	ost    *objectstorage.ObjStorage
+	chunks []*logChunk
+	// read is the log offset already read
+	read int64
+	cur  io.ReadCloser
+}
+
+func (r *logChunksReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			c := r.chunks[0]
+			r.chunks = r.chunks[1:]
+
+			f, err := r.ost.ReadObject(c.path)
+			if err != nil {
+				return 0, errors.WithStack(err)
+			}
+			// skip the data overlapping the previous chunk
+			if skip := r.read - c.offset; skip > 0 {
+				if _, err := io.CopyN(ioutil.Discard, f, skip); err != nil {
+					f.Close()
+					return 0, errors.WithStack(err)
+				}
+			}
+			r.cur = f
+		}
+
+		n, err := r.cur.Read(p)
+		r.read += int64(n)
+		if errors.Is(err, io.EOF) {
+			r.cur.Close()
+			r.cur = nil
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, errors.WithStack(err)
+	}
+}
+
+func (r *logChunksReader) Close() error {
+	if r.cur != nil {
+		return errors.WithStack(r.cur.Close())
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"agola.io/agola/internal/objectstorage"
+)
+
+func TestReadLogChunks(t *testing.T) {
+	type chunk struct {
+		offset int64
+		data   string
+	}
+
+	tests := []struct {
+		name   string
+		chunks []chunk
+		ok     bool
+		out    string
+	}{
+		{
+			name: "test no chunks",
+		},
+		{
+			name:   "test contiguous chunks",
+			chunks: []chunk{{0, "line01\n"}, {7, "line02\n"}, {14, "line03\n"}},
+			ok:     true,
+			out:    "line01\nline02\nline03\n",
+		},
+		{
+			name:   "test overlapping chunks",
+			chunks: []chunk{{0, "line01\n"}, {7, "line02\n"}, {7, "line02\nline03\n"}, {21, "line04\n"}},
+			ok:     true,
+			out:    "line01\nline02\nline03\nline04\n",
+		},
+		{
+			name:   "test partially overlapping chunks",
+			chunks: []chunk{{0, "line01\nline02\n"}, {7, "line02\nline03\n"}},
+			ok:     true,
+			out:    "line01\nline02\nline03\n",
+		},
+		{
+			name:   "test chunks with gap",
+			chunks: []chunk{{0, "line01\n"}, {14, "line03\n"}},
+			ok:     true,
+			out:    "line01\n",
+		},
+		{
+			name:   "test missing first chunk",
+			chunks: []chunk{{7, "line02\n"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posix, err := objectstorage.NewPosix(path.Join(t.TempDir(), "ost"))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			ost := objectstorage.NewObjStorage(posix, "/")
+
+			for _, c := range tt.chunks {
+				// a chunk uploaded again at the same offset replaces the previous one
+				if err := ost.WriteObject(OSTRunTaskLogChunkPath("task01", false, 1, c.offset), bytes.NewReader([]byte(c.data)), int64(len(c.data)), false); err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+			}
+
+			r, ok, err := ReadLogChunks(ost, "task01", false, 1)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if ok != tt.ok {
+				t.Fatalf("expected ok %t, got %t", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			defer r.Close()
+
+			out, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if string(out) != tt.out {
+				t.Fatalf("expected log %q, got %q", tt.out, string(out))
+			}
+
+			if err := DeleteLogChunks(ost, "task01", false, 1); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if _, ok, err := ReadLogChunks(ost, "task01", false, 1); err != nil || ok {
+				t.Fatalf("expected no log chunks after delete, got ok: %t, err: %v", ok, err)
+			}
+		})
+	}
+}
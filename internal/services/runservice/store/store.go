@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/objectstorage"
 	"agola.io/agola/internal/util"
 )
 
@@ -87,11 +88,46 @@ func OSTCacheDir() string {
 	return "caches"
 }
 
-func OSTCachePath(key string) string {
-	return path.Join(OSTCacheDir(), fmt.Sprintf("%s.tar", key))
+func OSTCacheGroupDir(group string) string {
+	return path.Join(OSTCacheDir(), group)
+}
+
+func OSTCachePath(group, key string) string {
+	return path.Join(OSTCacheGroupDir(group), fmt.Sprintf("%s.tar", key))
+}
+
+func OSTCacheAccessDir() string {
+	return "cachesaccess"
+}
+
+func OSTCacheAccessPath(group, key string) string {
+	return path.Join(OSTCacheAccessDir(), group, key)
 }
 
 func OSTCacheKey(p string) string {
 	base := path.Base(p)
 	return strings.TrimSuffix(base, path.Ext(base))
 }
+
+// DeleteObjects removes all the objects inside the provided dir
+func DeleteObjects(ost *objectstorage.ObjStorage, dir string) error {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	// list all the objects before removing them to not alter the listing
+	var paths []string
+	for object := range ost.List(dir+"/", "", true, doneCh) {
+		if object.Err != nil {
+			return errors.WithStack(object.Err)
+		}
+		paths = append(paths, object.Path)
+	}
+
+	for _, p := range paths {
+		if err := ost.DeleteObject(p); err != nil && !objectstorage.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
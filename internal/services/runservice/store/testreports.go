@@ -0,0 +1,148 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/testreport"
+	"agola.io/agola/internal/util"
+	"agola.io/agola/services/runservice/types"
+)
+
+// The JUnit/XUnit XML test reports saved by a run task are saved as a tar
+// archive. The reports are parsed while saving them and the resulting summary
+// is saved alongside the archive.
+
+func OSTTestReportsDir() string {
+	return "testreports"
+}
+
+func OSTRunTestReportsDir(runID string) string {
+	return path.Join(OSTTestReportsDir(), runID)
+}
+
+func OSTRunTaskTestReportsPath(runID, taskID string) string {
+	return path.Join(OSTRunTestReportsDir(runID), taskID+".tar")
+}
+
+func OSTRunTaskTestReportPath(runID, taskID string) string {
+	return path.Join(OSTRunTestReportsDir(runID), taskID+".json")
+}
+
+// readTestReports parses all the reports contained in the tar archive
+func readTestReports(r io.Reader) (*types.TestReport, error) {
+	report := &types.TestReport{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := testreport.ParseJUnit(tr, report); err != nil {
+			return nil, util.NewAPIError(util.ErrBadRequest, errors.Wrapf(err, "failed to parse test report %q", artifactPath(hdr.Name)))
+		}
+	}
+
+	return report, nil
+}
+
+// WriteTaskTestReports saves the task test reports tar archive and the parsed
+// test report
+func WriteTaskTestReports(ost *objectstorage.ObjStorage, runID, taskID string, r io.Reader, size int64) (*types.TestReport, error) {
+	// parse the reports while writing the archive
+	pr, pw := io.Pipe()
+	type reportResult struct {
+		report *types.TestReport
+		err    error
+	}
+	reportCh := make(chan reportResult, 1)
+	go func() {
+		report, err := readTestReports(pr)
+		if err != nil {
+			pr.CloseWithError(err)
+		} else {
+			// consume the data after the tar end to not block the writer
+			_, _ = io.Copy(ioutil.Discard, pr)
+		}
+		reportCh <- reportResult{report: report, err: err}
+	}()
+
+	werr := ost.WriteObject(OSTRunTaskTestReportsPath(runID, taskID), io.TeeReader(r, pw), size, false)
+	pw.Close()
+	rr := <-reportCh
+	// a report parsing error also causes a write error, report it first
+	if rr.err != nil {
+		return nil, errors.WithStack(rr.err)
+	}
+	if werr != nil {
+		return nil, errors.WithStack(werr)
+	}
+
+	reportj, err := json.Marshal(rr.report)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := ost.WriteObject(OSTRunTaskTestReportPath(runID, taskID), bytes.NewReader(reportj), int64(len(reportj)), false); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return rr.report, nil
+}
+
+// ListRunTestReports returns the test reports of the run tasks by task id
+func ListRunTestReports(ost *objectstorage.ObjStorage, runID string) (map[string]*types.TestReport, error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	reports := map[string]*types.TestReport{}
+	for object := range ost.List(OSTRunTestReportsDir(runID)+"/", "", false, doneCh) {
+		if object.Err != nil {
+			return nil, errors.WithStack(object.Err)
+		}
+		if path.Ext(object.Path) != ".json" {
+			continue
+		}
+		taskID := strings.TrimSuffix(path.Base(object.Path), ".json")
+
+		f, err := ost.ReadObject(object.Path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var report *types.TestReport
+		err = json.NewDecoder(f).Decode(&report)
+		f.Close()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		reports[taskID] = report
+	}
+
+	return reports, nil
+}
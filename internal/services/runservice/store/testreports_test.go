@@ -0,0 +1,98 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"archive/tar"
+	"bytes"
+	"path"
+	"testing"
+	"time"
+
+	"agola.io/agola/internal/objectstorage"
+	"agola.io/agola/internal/util"
+	"agola.io/agola/services/runservice/types"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testReportsArchive(t *testing.T, files map[string]string) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	return buf
+}
+
+func TestTaskTestReports(t *testing.T) {
+	posix, err := objectstorage.NewPosix(path.Join(t.TempDir(), "ost"))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	ost := objectstorage.NewObjStorage(posix, "/")
+
+	buf := testReportsArchive(t, map[string]string{
+		"reports/unit.xml": `<testsuite name="unit">
+	<testcase classname="unit" name="TestA" time="1"></testcase>
+	<testcase classname="unit" name="TestB" time="1"><failure message="failed"/></testcase>
+</testsuite>`,
+		"reports/e2e.xml": `<testsuites>
+	<testsuite name="e2e">
+		<testcase classname="e2e" name="TestC" time="2"><skipped/></testcase>
+	</testsuite>
+</testsuites>`,
+	})
+
+	expectedReport := &types.TestReport{
+		Tests:    3,
+		Failures: 1,
+		Skipped:  1,
+		Duration: 4 * time.Second,
+		FailedTests: []*types.TestCaseFailure{
+			{Suite: "unit", ClassName: "unit", Name: "TestB", Message: "failed"},
+		},
+	}
+
+	report, err := WriteTaskTestReports(ost, "run01", "task01", bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if diff := cmp.Diff(expectedReport, report); diff != "" {
+		t.Fatalf("test report mismatch (-want +got):\n%s", diff)
+	}
+
+	runTestReports, err := ListRunTestReports(ost, "run01")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if diff := cmp.Diff(map[string]*types.TestReport{"task01": expectedReport}, runTestReports); diff != "" {
+		t.Fatalf("run test reports mismatch (-want +got):\n%s", diff)
+	}
+
+	buf = testReportsArchive(t, map[string]string{"report.xml": "not xml"})
+	if _, err := WriteTaskTestReports(ost, "run01", "task02", bytes.NewReader(buf.Bytes()), int64(buf.Len())); !util.APIErrorIs(err, util.ErrBadRequest) {
+		t.Fatalf("expected bad request error, got: %v", err)
+	}
+}
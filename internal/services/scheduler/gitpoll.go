@@ -0,0 +1,92 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/lock"
+	cstypes "agola.io/agola/services/configstore/types"
+)
+
+const (
+	gitPollLockKey = "gitpoll"
+
+	remoteSourcesFetchLimit = 20
+)
+
+func (s *Scheduler) gitPollLoop(ctx context.Context) {
+	for {
+		if err := s.gitPoll(ctx); err != nil {
+			s.log.Err(err).Send()
+		}
+
+		sleepCh := time.NewTimer(s.c.GitPollInterval).C
+		select {
+		case <-ctx.Done():
+			return
+		case <-sleepCh:
+		}
+	}
+}
+
+// gitPoll asks the gateway to poll all the projects using a plain git remote
+// source. Only one scheduler instance at a time polls the projects, so the
+// same ref change isn't seen by concurrent polls.
+func (s *Scheduler) gitPoll(ctx context.Context) error {
+	l := s.lf.NewLock(gitPollLockKey)
+	if err := l.TryLock(ctx); err != nil {
+		if errors.Is(err, lock.ErrLocked) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	defer func() { _ = l.Unlock() }()
+
+	var start string
+	for {
+		remoteSources, _, err := s.configstoreClient.GetRemoteSources(ctx, start, remoteSourcesFetchLimit, true)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get remote sources")
+		}
+
+		for _, rs := range remoteSources {
+			if rs.Type != cstypes.RemoteSourceTypeGit {
+				continue
+			}
+
+			projects, _, err := s.configstoreClient.GetRemoteSourceProjects(ctx, rs.ID, "")
+			if err != nil {
+				s.log.Err(err).Msgf("failed to get remote source %q projects", rs.Name)
+				continue
+			}
+
+			for _, p := range projects {
+				if _, err := s.gatewayClient.PollProject(ctx, p.ID); err != nil {
+					s.log.Err(err).Msgf("failed to poll project %q", p.Path)
+				}
+			}
+		}
+
+		if len(remoteSources) < remoteSourcesFetchLimit {
+			break
+		}
+		start = remoteSources[len(remoteSources)-1].Name
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"agola.io/agola/internal/lock"
+	"agola.io/agola/internal/services/config"
+	"agola.io/agola/internal/testutil"
+	csapitypes "agola.io/agola/services/configstore/api/types"
+	csclient "agola.io/agola/services/configstore/client"
+	cstypes "agola.io/agola/services/configstore/types"
+	gwclient "agola.io/agola/services/gateway/client"
+	stypes "agola.io/agola/services/types"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGitPoll(t *testing.T) {
+	ctx := context.Background()
+	log := testutil.NewLogger(t)
+
+	writeJSON := func(w http.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Errorf("unexpected err: %v", err)
+		}
+	}
+
+	remoteSources := []*cstypes.RemoteSource{
+		{ObjectMeta: stypes.ObjectMeta{ID: "rs01"}, Name: "rs01", Type: cstypes.RemoteSourceTypeGit},
+		{ObjectMeta: stypes.ObjectMeta{ID: "rs02"}, Name: "rs02", Type: cstypes.RemoteSourceTypeGitea},
+	}
+	projects := map[string][]*csapitypes.Project{
+		"rs01": {
+			{Project: &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project01"}}},
+			{Project: &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project02"}}},
+		},
+		"rs02": {
+			{Project: &cstypes.Project{ObjectMeta: stypes.ObjectMeta{ID: "project03"}}},
+		},
+	}
+
+	cs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1alpha/remotesources":
+			writeJSON(w, remoteSources)
+		case "/api/v1alpha/remotesources/rs01/projects":
+			writeJSON(w, projects["rs01"])
+		case "/api/v1alpha/remotesources/rs02/projects":
+			writeJSON(w, projects["rs02"])
+		default:
+			t.Errorf("unexpected configstore request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer cs.Close()
+
+	var mu sync.Mutex
+	polledProjects := []string{}
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		polledProjects = append(polledProjects, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer gw.Close()
+
+	lf := lock.NewLocalLockFactory(lock.NewLocalLocks())
+	s := &Scheduler{
+		log:               log,
+		c:                 &config.Scheduler{},
+		lf:                lf,
+		configstoreClient: csclient.NewClient(cs.URL),
+		gatewayClient:     gwclient.NewClient(gw.URL, ""),
+	}
+
+	t.Run("only plain git projects are polled", func(t *testing.T) {
+		if err := s.gitPoll(ctx); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		sort.Strings(polledProjects)
+		expectedPolledProjects := []string{
+			"POST /api/v1alpha/projects/project01/poll",
+			"POST /api/v1alpha/projects/project02/poll",
+		}
+		if diff := cmp.Diff(expectedPolledProjects, polledProjects); diff != "" {
+			t.Fatalf("polled projects mismatch (-want +got):\n%s", diff)
+		}
+		polledProjects = []string{}
+	})
+
+	t.Run("projects aren't polled when another instance holds the lock", func(t *testing.T) {
+		l := lf.NewLock(gitPollLockKey)
+		if err := l.Lock(ctx); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		defer func() { _ = l.Unlock() }()
+
+		if err := s.gitPoll(ctx); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(polledProjects) != 0 {
+			t.Fatalf("expected no polled projects, got %v", polledProjects)
+		}
+	})
+}
@@ -18,14 +18,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"sort"
 	"time"
 
 	"agola.io/agola/internal/errors"
+	"agola.io/agola/internal/lock"
 	"agola.io/agola/internal/services/common"
 	"agola.io/agola/internal/services/config"
+	"agola.io/agola/internal/sql"
 	"agola.io/agola/internal/util"
+	csclient "agola.io/agola/services/configstore/client"
+	gwclient "agola.io/agola/services/gateway/client"
 	rsapitypes "agola.io/agola/services/runservice/api/types"
 	rsclient "agola.io/agola/services/runservice/client"
+	rstypes "agola.io/agola/services/runservice/types"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -47,8 +54,12 @@ func (s *Scheduler) scheduleLoop(ctx context.Context) {
 }
 
 func (s *Scheduler) schedule(ctx context.Context) error {
-	// create a list of project and users with queued runs
-	groups := map[string]struct{}{}
+	// create a list of project and users with queued runs ordered by their
+	// oldest queued run, so the concurrency groups capacity freed by finished
+	// runs is handed off to the runs in creation order
+	groups := []string{}
+	seenGroups := map[string]struct{}{}
+	queuedRuns := []*rstypes.Run{}
 
 	var lastRunSequence uint64
 	for {
@@ -57,8 +68,13 @@ func (s *Scheduler) schedule(ctx context.Context) error {
 			return errors.Wrapf(err, "failed to get queued runs")
 		}
 
+		queuedRuns = append(queuedRuns, queuedRunsResponse.Runs...)
 		for _, run := range queuedRunsResponse.Runs {
-			groups[run.Group] = struct{}{}
+			if _, ok := seenGroups[run.Group]; ok {
+				continue
+			}
+			seenGroups[run.Group] = struct{}{}
+			groups = append(groups, run.Group)
 		}
 
 		if len(queuedRunsResponse.Runs) == 0 {
@@ -68,8 +84,28 @@ func (s *Scheduler) schedule(ctx context.Context) error {
 		lastRunSequence = queuedRunsResponse.Runs[len(queuedRunsResponse.Runs)-1].Sequence
 	}
 
-	for groupID := range groups {
-		if err := s.scheduleRun(ctx, groupID); err != nil {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	runningRuns, err := s.getRunningRuns(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	groupsRuns := concurrencyGroupsRuns{}
+	for _, run := range runningRuns {
+		groupsRuns.addRun(run)
+	}
+
+	// queued and running runs by group
+	activeRuns := map[string][]*rstypes.Run{}
+	for _, run := range append(runningRuns, queuedRuns...) {
+		activeRuns[run.Group] = append(activeRuns[run.Group], run)
+	}
+
+	for _, groupID := range groups {
+		s.cancelSupersededRuns(ctx, activeRuns[groupID])
+		if err := s.scheduleRun(ctx, groupID, groupsRuns); err != nil {
 			s.log.Err(err).Msgf("scheduler err")
 		}
 	}
@@ -77,7 +113,105 @@ func (s *Scheduler) schedule(ctx context.Context) error {
 	return nil
 }
 
-func (s *Scheduler) scheduleRun(ctx context.Context, groupID string) error {
+// cancelSupersededRuns stops the running runs and cancels the queued runs of
+// a group superseded by a newer queued run with the same name. runs are the
+// group queued and running runs
+func (s *Scheduler) cancelSupersededRuns(ctx context.Context, runs []*rstypes.Run) {
+	for _, run := range supersededRuns(runs) {
+		rsreq := &rsapitypes.RunActionsRequest{
+			ActionType: rsapitypes.RunActionTypeStop,
+		}
+		if run.Phase == rstypes.RunPhaseQueued {
+			rsreq = &rsapitypes.RunActionsRequest{
+				ActionType: rsapitypes.RunActionTypeChangePhase,
+				Phase:      rstypes.RunPhaseCancelled,
+			}
+		}
+
+		log.Info().Msgf("stopping run %s superseded by a newer run", run.ID)
+		// the run phase could have changed in the meantime, just log the
+		// error
+		if _, err := s.runserviceClient.RunActions(ctx, run.ID, rsreq); err != nil {
+			s.log.Err(err).Msgf("failed to stop run %s", run.ID)
+		}
+	}
+}
+
+// supersededRuns returns the not finished runs, ordered by sequence, older than
+// a queued run of the same group with the same name that cancels the
+// superseded runs
+func supersededRuns(runs []*rstypes.Run) []*rstypes.Run {
+	type runKey struct {
+		group string
+		name  string
+	}
+
+	// the sequence of the newest superseding run by run group and name
+	superseding := map[runKey]uint64{}
+	for _, run := range runs {
+		k := runKey{group: run.Group, name: run.Name}
+		if run.Phase == rstypes.RunPhaseQueued && run.CancelSuperseded && run.Sequence > superseding[k] {
+			superseding[k] = run.Sequence
+		}
+	}
+
+	superseded := []*rstypes.Run{}
+	for _, run := range runs {
+		seq, ok := superseding[runKey{group: run.Group, name: run.Name}]
+		if !ok || run.Sequence >= seq || run.Stop || run.Phase.IsFinished() {
+			continue
+		}
+		superseded = append(superseded, run)
+	}
+	sort.Slice(superseded, func(i, j int) bool { return superseded[i].Sequence < superseded[j].Sequence })
+
+	return superseded
+}
+
+// concurrencyGroupsRuns contains the number of running runs of every run
+// concurrency group
+type concurrencyGroupsRuns map[string]int
+
+// runsConcurrencyLimitReached reports if one of the run concurrency groups
+// reached its max running runs
+func runsConcurrencyLimitReached(run *rstypes.Run, groupsRuns concurrencyGroupsRuns) bool {
+	for _, cg := range run.ConcurrencyGroups {
+		if cg.MaxRunningRuns > 0 && groupsRuns[cg.Name] >= cg.MaxRunningRuns {
+			return true
+		}
+	}
+	return false
+}
+
+func (g concurrencyGroupsRuns) addRun(run *rstypes.Run) {
+	for _, cg := range run.ConcurrencyGroups {
+		g[cg.Name]++
+	}
+}
+
+func (s *Scheduler) getRunningRuns(ctx context.Context) ([]*rstypes.Run, error) {
+	runningRuns := []*rstypes.Run{}
+
+	var lastRunSequence uint64
+	for {
+		runningRunsResponse, _, err := s.runserviceClient.GetRunningRuns(ctx, lastRunSequence, 0, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get running runs")
+		}
+
+		if len(runningRunsResponse.Runs) == 0 {
+			break
+		}
+
+		runningRuns = append(runningRuns, runningRunsResponse.Runs...)
+
+		lastRunSequence = runningRunsResponse.Runs[len(runningRunsResponse.Runs)-1].Sequence
+	}
+
+	return runningRuns, nil
+}
+
+func (s *Scheduler) scheduleRun(ctx context.Context, groupID string, groupsRuns concurrencyGroupsRuns) error {
 	// get first queued run
 	queuedRunsResponse, _, err := s.runserviceClient.GetGroupFirstQueuedRuns(ctx, groupID, nil)
 	if err != nil {
@@ -95,15 +229,68 @@ func (s *Scheduler) scheduleRun(ctx context.Context, groupID string) error {
 		return errors.Wrapf(err, "failed to get running runs")
 	}
 	if len(runningRunsResponse.Runs) == 0 {
+		ready, succeeded, err := s.runDependenciesStatus(ctx, run)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check run %q dependencies", run.ID)
+		}
+		if !ready {
+			return nil
+		}
+		if !succeeded {
+			log.Info().Msgf("cancelling run %s since its dependencies didn't succeed", run.ID)
+			if _, err := s.runserviceClient.CancelRun(ctx, run.ID, runningRunsResponse.ChangeGroupsUpdateToken); err != nil {
+				s.log.Err(err).Msgf("failed to cancel run %s", run.ID)
+			}
+			return nil
+		}
+
+		if runsConcurrencyLimitReached(run, groupsRuns) {
+			s.log.Debug().Msgf("run %s concurrency groups max running runs reached", run.ID)
+			return nil
+		}
+
 		log.Info().Msgf("starting run %s", run.ID)
 		if _, err := s.runserviceClient.StartRun(ctx, run.ID, runningRunsResponse.ChangeGroupsUpdateToken); err != nil {
 			s.log.Err(err).Msgf("failed to start run %s", run.ID)
+			return nil
 		}
+		groupsRuns.addRun(run)
 	}
 
 	return nil
 }
 
+// runDependenciesStatus reports if all the runs the run depends on are
+// finished and if they all succeeded
+func (s *Scheduler) runDependenciesStatus(ctx context.Context, run *rstypes.Run) (bool, bool, error) {
+	succeeded := true
+	for _, depRunID := range run.DependsOn {
+		depRunResp, resp, err := s.runserviceClient.GetRun(ctx, depRunID, nil)
+		if err != nil {
+			// a removed dependency run will never succeed
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				succeeded = false
+				continue
+			}
+			return false, false, errors.Wrapf(err, "failed to get run %q", depRunID)
+		}
+		depRun := depRunResp.Run
+
+		switch depRun.Phase {
+		case rstypes.RunPhaseSetupError, rstypes.RunPhaseCancelled:
+			succeeded = false
+		case rstypes.RunPhaseFinished:
+			if depRun.Result != rstypes.RunResultSuccess {
+				succeeded = false
+			}
+		default:
+			return false, false, nil
+		}
+	}
+
+	return true, succeeded, nil
+}
+
 func (s *Scheduler) approveLoop(ctx context.Context) {
 	for {
 		if err := s.approve(ctx); err != nil {
@@ -171,8 +358,11 @@ func (s *Scheduler) approveRunTasks(ctx context.Context, runID string) error {
 		if err := json.Unmarshal([]byte(approversAnnotation), &approvers); err != nil {
 			return errors.Wrapf(err, "failed to unmarshal run task approvers annotation")
 		}
-		// TODO(sgotti) change when we introduce a config the set the minimum number of required approvers
-		if len(approvers) > 0 {
+		rct, ok := runResp.RunConfig.Tasks[rt.ID]
+		if !ok {
+			return errors.Errorf("run config %q doesn't have task %q", run.ID, rtID)
+		}
+		if len(approvers) >= rct.RequiredApprovals() {
 			rsreq := &rsapitypes.RunTaskActionsRequest{
 				ActionType:              rsapitypes.RunTaskActionTypeApprove,
 				ChangeGroupsUpdateToken: runResp.ChangeGroupsUpdateToken,
@@ -187,26 +377,65 @@ func (s *Scheduler) approveRunTasks(ctx context.Context, runID string) error {
 }
 
 type Scheduler struct {
-	log              zerolog.Logger
-	c                *config.Scheduler
-	runserviceClient *rsclient.Client
+	log               zerolog.Logger
+	c                 *config.Scheduler
+	lf                lock.LockFactory
+	runserviceClient  *rsclient.Client
+	configstoreClient *csclient.Client
+	gatewayClient     *gwclient.Client
 }
 
-func NewScheduler(ctx context.Context, log zerolog.Logger, c *config.Scheduler) (*Scheduler, error) {
+func NewScheduler(ctx context.Context, log zerolog.Logger, gc *config.Config) (*Scheduler, error) {
+	c := &gc.Scheduler
+
 	if c.Debug {
 		log = log.Level(zerolog.DebugLevel)
 	}
 
-	return &Scheduler{
+	serviceAuth, err := common.NewServiceAuth(&gc.InternalServicesAuth, common.ServiceScheduler)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	runserviceClient := rsclient.NewClient(c.RunserviceURL)
+	runserviceClient.SetHTTPClient(serviceAuth.HTTPClient(common.ServiceRunservice))
+
+	s := &Scheduler{
 		log:              log,
 		c:                c,
-		runserviceClient: rsclient.NewClient(c.RunserviceURL),
-	}, nil
+		runserviceClient: runserviceClient,
+	}
+
+	if c.ConfigstoreURL != "" && c.GatewayURL != "" {
+		// without a db only local locks are available
+		switch c.DB.Type {
+		case "", sql.Sqlite3:
+			s.lf = lock.NewLocalLockFactory(lock.NewLocalLocks())
+		case sql.Postgres:
+			sdb, err := sql.NewDB(c.DB.Type, c.DB.ConnString)
+			if err != nil {
+				return nil, errors.Wrapf(err, "new db error")
+			}
+			s.lf = lock.NewPGLockFactory(sdb)
+		default:
+			return nil, errors.Errorf("unknown type %q", c.DB.Type)
+		}
+
+		s.configstoreClient = csclient.NewClient(c.ConfigstoreURL)
+		s.configstoreClient.SetHTTPClient(serviceAuth.HTTPClient(common.ServiceConfigstore))
+		s.gatewayClient = gwclient.NewClient(c.GatewayURL, "")
+		s.gatewayClient.SetHTTPClient(serviceAuth.HTTPClient(common.ServiceGateway))
+	}
+
+	return s, nil
 }
 
 func (s *Scheduler) Run(ctx context.Context) error {
 	go s.scheduleLoop(ctx)
 	go s.approveLoop(ctx)
+	if s.gatewayClient != nil {
+		go s.gitPollLoop(ctx)
+	}
 
 	<-ctx.Done()
 	log.Info().Msgf("scheduler exiting")
@@ -0,0 +1,130 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"testing"
+
+	rstypes "agola.io/agola/services/runservice/types"
+	stypes "agola.io/agola/services/types"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSupersededRuns(t *testing.T) {
+	const (
+		branch01 = "/project/project01/branch/branch01"
+		branch02 = "/project/project01/branch/branch02"
+	)
+
+	newRun := func(id string, seq uint64, group, name string, phase rstypes.RunPhase, cancelSuperseded bool) *rstypes.Run {
+		return &rstypes.Run{
+			ObjectMeta:       stypes.ObjectMeta{ID: id},
+			Sequence:         seq,
+			Name:             name,
+			Group:            group,
+			Phase:            phase,
+			CancelSuperseded: cancelSuperseded,
+		}
+	}
+
+	tests := []struct {
+		name string
+		runs []*rstypes.Run
+		out  []string
+	}{
+		{
+			name: "no runs",
+			out:  []string{},
+		},
+		{
+			name: "newer queued run supersedes older queued and running runs of the same branch",
+			runs: []*rstypes.Run{
+				newRun("run03", 3, branch01, "run", rstypes.RunPhaseQueued, true),
+				newRun("run01", 1, branch01, "run", rstypes.RunPhaseRunning, true),
+				newRun("run02", 2, branch01, "run", rstypes.RunPhaseQueued, false),
+			},
+			out: []string{"run01", "run02"},
+		},
+		{
+			name: "runs of a different branch aren't superseded",
+			runs: []*rstypes.Run{
+				newRun("run01", 1, branch01, "run", rstypes.RunPhaseRunning, true),
+				newRun("run02", 2, branch02, "run", rstypes.RunPhaseQueued, true),
+			},
+			out: []string{},
+		},
+		{
+			name: "older queued run doesn't supersede newer runs",
+			runs: []*rstypes.Run{
+				newRun("run01", 1, branch01, "run", rstypes.RunPhaseQueued, true),
+				newRun("run02", 2, branch01, "run", rstypes.RunPhaseRunning, false),
+			},
+			out: []string{},
+		},
+		{
+			name: "runs with a different name aren't superseded",
+			runs: []*rstypes.Run{
+				newRun("run01", 1, branch01, "run01", rstypes.RunPhaseRunning, true),
+				newRun("run02", 2, branch01, "run02", rstypes.RunPhaseQueued, true),
+			},
+			out: []string{},
+		},
+		{
+			name: "running run doesn't supersede older runs",
+			runs: []*rstypes.Run{
+				newRun("run01", 1, branch01, "run", rstypes.RunPhaseQueued, true),
+				newRun("run02", 2, branch01, "run", rstypes.RunPhaseRunning, true),
+			},
+			out: []string{},
+		},
+		{
+			name: "queued run without cancel superseded doesn't supersede older runs",
+			runs: []*rstypes.Run{
+				newRun("run01", 1, branch01, "run", rstypes.RunPhaseRunning, true),
+				newRun("run02", 2, branch01, "run", rstypes.RunPhaseQueued, false),
+			},
+			out: []string{},
+		},
+		{
+			name: "already finished and stopping runs aren't superseded",
+			runs: []*rstypes.Run{
+				newRun("run01", 1, branch01, "run", rstypes.RunPhaseFinished, true),
+				newRun("run02", 2, branch01, "run", rstypes.RunPhaseCancelled, true),
+				func() *rstypes.Run {
+					r := newRun("run03", 3, branch01, "run", rstypes.RunPhaseRunning, true)
+					r.Stop = true
+					return r
+				}(),
+				newRun("run04", 4, branch01, "run", rstypes.RunPhaseRunning, true),
+				newRun("run05", 5, branch01, "run", rstypes.RunPhaseQueued, true),
+			},
+			out: []string{"run04"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := []string{}
+			for _, run := range supersededRuns(tt.runs) {
+				out = append(out, run.ID)
+			}
+
+			if diff := cmp.Diff(tt.out, out); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
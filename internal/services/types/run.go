@@ -27,11 +27,19 @@ const (
 	RunRefTypeBranch      RunRefType = "branch"
 	RunRefTypeTag         RunRefType = "tag"
 	RunRefTypePullRequest RunRefType = "pull_request"
+	// RunRefTypeSchedule is the ref type of the runs created by a project
+	// schedule on the schedule branch
+	RunRefTypeSchedule RunRefType = "schedule"
 )
 
 type RunCreationTriggerType string
 
 const (
-	RunCreationTriggerTypeWebhook RunCreationTriggerType = "webhook"
-	RunCreationTriggerTypeManual  RunCreationTriggerType = "manual"
+	RunCreationTriggerTypeWebhook  RunCreationTriggerType = "webhook"
+	RunCreationTriggerTypeManual   RunCreationTriggerType = "manual"
+	RunCreationTriggerTypePoll     RunCreationTriggerType = "poll"
+	RunCreationTriggerTypeSchedule RunCreationTriggerType = "schedule"
+	// RunCreationTriggerTypeDownstream is the trigger of the runs created by
+	// the triggers of an upstream run
+	RunCreationTriggerTypeDownstream RunCreationTriggerType = "downstream"
 )
@@ -45,6 +45,15 @@ type WebhookData struct {
 	PullRequestLink string `json:"link,omitempty"` // Link to pull request
 	PRFromSameRepo  bool   `json:"pr_from_same_repo,omitempty"`
 
+	// ChangedFiles are the files changed by the pushed commits. Nil when the
+	// git source doesn't provide them (i.e. pull requests, tags or too many
+	// commits)
+	ChangedFiles []string `json:"changed_files,omitempty"`
+	// CompareBase is the commit or ref the changes are compared to (the
+	// commit before the push or the pull request target) to get the changed
+	// files from the git source when not provided by the webhook
+	CompareBase string `json:"compare_base,omitempty"`
+
 	Repo WebhookDataRepo `json:"repo,omitempty"`
 }
 
@@ -0,0 +1,142 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testreport parses the JUnit/XUnit XML test reports generated by the
+// test tools.
+package testreport
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"agola.io/agola/internal/errors"
+	"agola.io/agola/services/runservice/types"
+)
+
+// maxMessageLength is the max length of a failed test case message
+const maxMessageLength = 1024
+
+// testSuite is both a junit testsuites root element and a testsuite element
+// since they could be nested
+type testSuite struct {
+	XMLName   xml.Name
+	Name      string       `xml:"name,attr"`
+	Suites    []*testSuite `xml:"testsuite"`
+	TestCases []*testCase  `xml:"testcase"`
+}
+
+type testCase struct {
+	Name      string  `xml:"name,attr"`
+	ClassName string  `xml:"classname,attr"`
+	Time      string  `xml:"time,attr"`
+	Failure   *result `xml:"failure"`
+	Error     *result `xml:"error"`
+	Skipped   *result `xml:"skipped"`
+}
+
+type result struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnit parses a JUnit/XUnit XML report adding its test cases to the
+// provided report. The report counters are calculated from the test cases
+// since the suites attributes are optional and not reliable across tools.
+func ParseJUnit(r io.Reader, report *types.TestReport) error {
+	var root testSuite
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return errors.Wrapf(err, "failed to decode junit report")
+	}
+
+	switch root.XMLName.Local {
+	case "testsuites", "testsuite":
+	default:
+		return errors.Errorf("unknown junit report root element %q", root.XMLName.Local)
+	}
+
+	addTestSuite(&root, report)
+
+	return nil
+}
+
+func addTestSuite(ts *testSuite, report *types.TestReport) {
+	for _, tc := range ts.TestCases {
+		report.Tests++
+		report.Duration += parseTime(tc.Time)
+
+		var res *result
+		switch {
+		case tc.Error != nil:
+			report.Errors++
+			res = tc.Error
+		case tc.Failure != nil:
+			report.Failures++
+			res = tc.Failure
+		case tc.Skipped != nil:
+			report.Skipped++
+			continue
+		default:
+			continue
+		}
+
+		if len(report.FailedTests) >= types.MaxTestReportFailedTests {
+			continue
+		}
+		report.FailedTests = append(report.FailedTests, &types.TestCaseFailure{
+			Suite:     ts.Name,
+			ClassName: tc.ClassName,
+			Name:      tc.Name,
+			Error:     tc.Error != nil,
+			Message:   resultMessage(res),
+		})
+	}
+
+	for _, s := range ts.Suites {
+		addTestSuite(s, report)
+	}
+}
+
+// parseTime parses a junit time attribute expressed in seconds. Malformed
+// times are ignored.
+func parseTime(s string) time.Duration {
+	// some tools format the time with thousands separators
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	if s == "" {
+		return 0
+	}
+	secs, err := strconv.ParseFloat(s, 64)
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+// resultMessage returns the failure message or, when not defined, the first
+// line of the failure text
+func resultMessage(res *result) string {
+	msg := strings.TrimSpace(res.Message)
+	if msg == "" {
+		msg = strings.TrimSpace(res.Text)
+		if i := strings.IndexByte(msg, '\n'); i >= 0 {
+			msg = strings.TrimSpace(msg[:i])
+		}
+	}
+	if len(msg) > maxMessageLength {
+		msg = msg[:maxMessageLength]
+	}
+	return msg
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testreport
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"agola.io/agola/services/runservice/types"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseJUnit(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		out    *types.TestReport
+		errStr string
+	}{
+		{
+			name: "single testsuite",
+			in: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="pkg" tests="3" failures="1">
+	<testcase classname="pkg" name="TestA" time="0.5"></testcase>
+	<testcase classname="pkg" name="TestB" time="1.5">
+		<failure message="expected 1, got 2">file_test.go:10</failure>
+	</testcase>
+	<testcase classname="pkg" name="TestC" time="0">
+		<skipped message="skipped"/>
+	</testcase>
+</testsuite>`,
+			out: &types.TestReport{
+				Tests:    3,
+				Failures: 1,
+				Skipped:  1,
+				Duration: 2 * time.Second,
+				FailedTests: []*types.TestCaseFailure{
+					{Suite: "pkg", ClassName: "pkg", Name: "TestB", Message: "expected 1, got 2"},
+				},
+			},
+		},
+		{
+			name: "nested testsuites",
+			in: `<testsuites>
+	<testsuite name="pkg1">
+		<testcase classname="pkg1" name="TestA" time="1,000.25"></testcase>
+	</testsuite>
+	<testsuite name="pkg2">
+		<testsuite name="pkg2/sub">
+			<testcase classname="pkg2/sub" name="TestB">
+				<error>panic: runtime error
+goroutine 1 [running]:</error>
+			</testcase>
+		</testsuite>
+	</testsuite>
+</testsuites>`,
+			out: &types.TestReport{
+				Tests:    2,
+				Errors:   1,
+				Duration: 1000250 * time.Millisecond,
+				FailedTests: []*types.TestCaseFailure{
+					{Suite: "pkg2/sub", ClassName: "pkg2/sub", Name: "TestB", Error: true, Message: "panic: runtime error"},
+				},
+			},
+		},
+		{
+			name:   "unknown root element",
+			in:     `<html></html>`,
+			errStr: `unknown junit report root element "html"`,
+		},
+		{
+			name:   "malformed xml",
+			in:     `<testsuite>`,
+			errStr: "failed to decode junit report: XML syntax error on line 1: unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &types.TestReport{}
+			err := ParseJUnit(strings.NewReader(tt.in), report)
+			if tt.errStr != "" {
+				if err == nil {
+					t.Fatalf("expected error %q, got nil error", tt.errStr)
+				}
+				if err.Error() != tt.errStr {
+					t.Fatalf("expected error %q, got %q", tt.errStr, err.Error())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.out, report); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestParseJUnitMaxFailedTests(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("<testsuite>")
+	for i := 0; i < types.MaxTestReportFailedTests+10; i++ {
+		b.WriteString(`<testcase name="test"><failure/></testcase>`)
+	}
+	b.WriteString("</testsuite>")
+
+	report := &types.TestReport{}
+	if err := ParseJUnit(strings.NewReader(b.String()), report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Failures != types.MaxTestReportFailedTests+10 {
+		t.Fatalf("expected %d failures, got %d", types.MaxTestReportFailedTests+10, report.Failures)
+	}
+	if len(report.FailedTests) != types.MaxTestReportFailedTests {
+		t.Fatalf("expected %d failed tests, got %d", types.MaxTestReportFailedTests, len(report.FailedTests))
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"agola.io/agola/internal/errors"
+)
+
+// Version is the version of the protocol used by the executor to execute the
+// toolbox commands inside the task pods (commands, flags and their input and
+// output). It must be increased on every incompatible change.
+const Version = 2
+
+// Info is the output of the toolbox version command
+type Info struct {
+	Version         string `json:"version"`
+	ProtocolVersion int    `json:"protocol_version"`
+}
+
+// Check returns an error if the toolbox protocol version isn't the one used
+// by the executor
+func (i *Info) Check() error {
+	if i.ProtocolVersion != Version {
+		return errors.Errorf("toolbox protocol version %d (toolbox version %q) isn't supported, expected protocol version %d", i.ProtocolVersion, i.Version, Version)
+	}
+	return nil
+}
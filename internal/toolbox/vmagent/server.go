@@ -0,0 +1,148 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vmagent
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"agola.io/agola/internal/errors"
+)
+
+// Serve handles a single request received on conn and closes it
+func Serve(conn io.ReadWriteCloser) error {
+	defer conn.Close()
+
+	fw := &frameWriter{mu: &sync.Mutex{}, w: conn}
+
+	t, payload, err := ReadFrame(conn)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if t != FrameTypeRequest {
+		return errors.Errorf("unexpected frame type %d, expected request", t)
+	}
+	var req *Request
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return errors.WithStack(err)
+	}
+
+	var exitCode int
+	switch req.Type {
+	case RequestTypeExec:
+		exitCode, err = serveExec(conn, fw, req)
+	case RequestTypePutFile:
+		err = servePutFile(conn, req)
+	default:
+		err = errors.Errorf("unknown request type %q", req.Type)
+	}
+	if err != nil {
+		if werr := fw.writeFrame(FrameTypeError, []byte(err.Error())); werr != nil {
+			return errors.WithStack(werr)
+		}
+		return errors.WithStack(err)
+	}
+
+	var exitb [4]byte
+	binary.BigEndian.PutUint32(exitb[:], uint32(int32(exitCode)))
+	return errors.WithStack(fw.writeFrame(FrameTypeExit, exitb[:]))
+}
+
+// copyStdin writes the received stdin frames to w until a stdin close frame
+func copyStdin(r io.Reader, w io.Writer) error {
+	for {
+		t, payload, err := ReadFrame(r)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		switch t {
+		case FrameTypeStdin:
+			if _, err := w.Write(payload); err != nil {
+				return errors.WithStack(err)
+			}
+		case FrameTypeStdinClose:
+			return nil
+		default:
+			return errors.Errorf("unexpected frame type %d", t)
+		}
+	}
+}
+
+func serveExec(conn io.ReadWriteCloser, fw *frameWriter, req *Request) (int, error) {
+	if len(req.Cmd) == 0 {
+		return 0, errors.Errorf("empty command")
+	}
+
+	cmd := exec.Command(req.Cmd[0], req.Cmd[1:]...)
+	cmd.Stdout = &frameWriter{mu: fw.mu, w: fw.w, t: FrameTypeStdout}
+	cmd.Stderr = &frameWriter{mu: fw.mu, w: fw.w, t: FrameTypeStderr}
+	if req.User != "" {
+		if err := setCmdUser(cmd, req.User); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	go func() {
+		// errors are ignored since the process could have exited without
+		// reading its stdin
+		_ = copyStdin(conn, stdin)
+		stdin.Close()
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 0, errors.WithStack(err)
+	}
+
+	return 0, nil
+}
+
+func servePutFile(conn io.Reader, req *Request) error {
+	if req.Path == "" {
+		return errors.Errorf("empty path")
+	}
+	if err := os.MkdirAll(filepath.Dir(req.Path), 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.OpenFile(req.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, req.Mode)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := copyStdin(conn, f); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	// set the mode also when the file already existed
+	return errors.WithStack(os.Chmod(req.Path, req.Mode))
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package vmagent
+
+import (
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"agola.io/agola/internal/errors"
+)
+
+// setCmdUser sets the command to be executed as the provided user
+func setCmdUser(cmd *exec.Cmd, u string) error {
+	credential, err := userCredential(u)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+
+	return nil
+}
+
+// userCredential returns the credential for the provided user in the
+// "user[:group]" format where user and group can be names or numeric ids
+func userCredential(u string) (*syscall.Credential, error) {
+	parts := strings.SplitN(u, ":", 2)
+
+	var uid, gid uint64
+	uid, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		usr, err := user.Lookup(parts[0])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if uid, err = strconv.ParseUint(usr.Uid, 10, 32); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if gid, err = strconv.ParseUint(usr.Gid, 10, 32); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	} else if usr, err := user.LookupId(parts[0]); err == nil {
+		if gid, err = strconv.ParseUint(usr.Gid, 10, 32); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if len(parts) > 1 {
+		if gid, err = strconv.ParseUint(parts[1], 10, 32); err != nil {
+			grp, err := user.LookupGroup(parts[1])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if gid, err = strconv.ParseUint(grp.Gid, 10, 32); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}, nil
+}
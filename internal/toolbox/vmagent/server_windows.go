@@ -0,0 +1,27 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vmagent
+
+import (
+	"os/exec"
+
+	"agola.io/agola/internal/errors"
+)
+
+// setCmdUser returns an error since executing a command as another user isn't
+// supported on windows
+func setCmdUser(cmd *exec.Cmd, u string) error {
+	return errors.Errorf("executing commands as user %q isn't supported on windows", u)
+}
@@ -0,0 +1,263 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vmagent implements the protocol used by an executor driver to
+// execute commands inside a virtual machine through the toolbox agent
+// running in the guest.
+//
+// Every connection handles a single request. The client sends a request frame
+// followed by stdin frames and a stdin close frame, the agent replies with
+// stdout and stderr frames and terminates with an exit or an error frame.
+package vmagent
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"agola.io/agola/internal/errors"
+)
+
+const (
+	// DefaultPort is the default vsock port the agent listens on
+	DefaultPort = 1024
+
+	// maxFrameSize is the max size of a frame payload
+	maxFrameSize = 1024 * 1024
+)
+
+type FrameType byte
+
+const (
+	FrameTypeRequest FrameType = iota + 1
+	FrameTypeStdin
+	FrameTypeStdinClose
+	FrameTypeStdout
+	FrameTypeStderr
+	FrameTypeExit
+	FrameTypeError
+)
+
+type RequestType string
+
+const (
+	// RequestTypeExec executes a command
+	RequestTypeExec RequestType = "exec"
+	// RequestTypePutFile writes a file with the content received from stdin
+	RequestTypePutFile RequestType = "putfile"
+)
+
+type Request struct {
+	Type RequestType `json:"type"`
+
+	// exec request fields
+	Cmd  []string `json:"cmd,omitempty"`
+	User string   `json:"user,omitempty"`
+
+	// putfile request fields
+	Path string      `json:"path,omitempty"`
+	Mode os.FileMode `json:"mode,omitempty"`
+}
+
+// WriteFrame writes a frame made of a one byte type, a four bytes big endian
+// payload length and the payload
+func WriteFrame(w io.Writer, t FrameType, payload []byte) error {
+	if len(payload) > maxFrameSize {
+		return errors.Errorf("frame payload size %d greater than max size %d", len(payload), maxFrameSize)
+	}
+	buf := make([]byte, 5+len(payload))
+	buf[0] = byte(t)
+	binary.BigEndian.PutUint32(buf[1:5], uint32(len(payload)))
+	copy(buf[5:], payload)
+
+	_, err := w.Write(buf)
+	return errors.WithStack(err)
+}
+
+// ReadFrame reads a frame written by WriteFrame
+func ReadFrame(r io.Reader) (FrameType, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, errors.WithStack(err)
+	}
+	size := binary.BigEndian.Uint32(header[1:5])
+	if size > maxFrameSize {
+		return 0, nil, errors.Errorf("frame payload size %d greater than max size %d", size, maxFrameSize)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, errors.WithStack(err)
+	}
+
+	return FrameType(header[0]), payload, nil
+}
+
+// frameWriter is an io.Writer that writes the data as frames of the provided
+// type. Multiple frameWriters can share the same underlying writer.
+type frameWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+	t  FrameType
+}
+
+func (fw *frameWriter) Write(p []byte) (int, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		end := n + maxFrameSize
+		if end > len(p) {
+			end = len(p)
+		}
+		if err := WriteFrame(fw.w, fw.t, p[n:end]); err != nil {
+			return n, errors.WithStack(err)
+		}
+		n = end
+	}
+
+	return n, nil
+}
+
+func (fw *frameWriter) writeFrame(t FrameType, payload []byte) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	return WriteFrame(fw.w, t, payload)
+}
+
+// stdinWriter sends the written data as stdin frames and a stdin close frame
+// on Close
+type stdinWriter struct {
+	*frameWriter
+	closeOnce sync.Once
+}
+
+func (sw *stdinWriter) Close() error {
+	var err error
+	sw.closeOnce.Do(func() {
+		err = sw.writeFrame(FrameTypeStdinClose, nil)
+	})
+	return errors.WithStack(err)
+}
+
+// Exec is a request started with Start
+type Exec struct {
+	stdin *stdinWriter
+	endCh chan execResult
+}
+
+type execResult struct {
+	exitCode int
+	err      error
+}
+
+// Start sends the request to the agent on conn. The stdout and stderr frames
+// received from the agent are written to stdout and stderr. conn must not be
+// used by the caller after Start.
+func Start(conn io.ReadWriteCloser, req *Request, stdout, stderr io.Writer) (*Exec, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fw := &frameWriter{mu: &sync.Mutex{}, w: conn, t: FrameTypeStdin}
+	if err := fw.writeFrame(FrameTypeRequest, reqj); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	e := &Exec{
+		stdin: &stdinWriter{frameWriter: fw},
+		endCh: make(chan execResult, 1),
+	}
+
+	go func() {
+		defer conn.Close()
+		exitCode, err := readOutput(conn, stdout, stderr)
+		e.endCh <- execResult{exitCode: exitCode, err: err}
+	}()
+
+	return e, nil
+}
+
+func readOutput(r io.Reader, stdout, stderr io.Writer) (int, error) {
+	for {
+		t, payload, err := ReadFrame(r)
+		if err != nil {
+			return -1, errors.Wrapf(err, "failed to read frame")
+		}
+		switch t {
+		case FrameTypeStdout:
+			if stdout != nil {
+				if _, err := stdout.Write(payload); err != nil {
+					return -1, errors.WithStack(err)
+				}
+			}
+		case FrameTypeStderr:
+			if stderr != nil {
+				if _, err := stderr.Write(payload); err != nil {
+					return -1, errors.WithStack(err)
+				}
+			}
+		case FrameTypeExit:
+			if len(payload) != 4 {
+				return -1, errors.Errorf("wrong exit frame size %d", len(payload))
+			}
+			return int(int32(binary.BigEndian.Uint32(payload))), nil
+		case FrameTypeError:
+			return -1, errors.Errorf("agent error: %s", payload)
+		default:
+			return -1, errors.Errorf("unexpected frame type %d", t)
+		}
+	}
+}
+
+// Stdin returns a writer to send data to the request stdin. It must be closed
+// when done.
+func (e *Exec) Stdin() io.WriteCloser {
+	return e.stdin
+}
+
+// Wait waits for the request to finish and returns its exit code
+func (e *Exec) Wait() (int, error) {
+	res := <-e.endCh
+	return res.exitCode, errors.WithStack(res.err)
+}
+
+// PutFile writes a file with the content of r at path inside the guest
+func PutFile(conn io.ReadWriteCloser, path string, mode os.FileMode, r io.Reader) error {
+	e, err := Start(conn, &Request{Type: RequestTypePutFile, Path: path, Mode: mode}, nil, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	stdin := e.Stdin()
+	if _, err := io.Copy(stdin, r); err != nil {
+		conn.Close()
+		return errors.WithStack(err)
+	}
+	if err := stdin.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	exitCode, err := e.Wait()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if exitCode != 0 {
+		return errors.Errorf("putfile failed with exit code %d", exitCode)
+	}
+
+	return nil
+}
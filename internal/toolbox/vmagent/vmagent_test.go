@@ -0,0 +1,96 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vmagent
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExec(t *testing.T) {
+	client, server := net.Pipe()
+	serveErrCh := make(chan error, 1)
+	go func() { serveErrCh <- Serve(server) }()
+
+	var stdout, stderr bytes.Buffer
+	e, err := Start(client, &Request{Type: RequestTypeExec, Cmd: []string{"sh", "-c", "cat; echo error >&2; exit 3"}}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	stdin := e.Stdin()
+	if _, err := stdin.Write([]byte("input\n")); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := stdin.Close(); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	exitCode, err := e.Wait()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if exitCode != 3 {
+		t.Fatalf("got exit code %d, want %d", exitCode, 3)
+	}
+	if stdout.String() != "input\n" {
+		t.Fatalf("got stdout %q, want %q", stdout.String(), "input\n")
+	}
+	if stderr.String() != "error\n" {
+		t.Fatalf("got stderr %q, want %q", stderr.String(), "error\n")
+	}
+	if err := <-serveErrCh; err != nil {
+		t.Fatalf("unexpected serve err: %v", err)
+	}
+}
+
+func TestExecError(t *testing.T) {
+	client, server := net.Pipe()
+	go func() { _ = Serve(server) }()
+
+	e, err := Start(client, &Request{Type: RequestTypeExec, Cmd: []string{"/nonexistent"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := e.Wait(); err == nil || !strings.Contains(err.Error(), "agent error") {
+		t.Fatalf("got error %v, want agent error", err)
+	}
+}
+
+func TestPutFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "subdir", "file")
+
+	// use a content bigger than the max frame size
+	content := bytes.Repeat([]byte("0123456789"), maxFrameSize/5)
+
+	client, server := net.Pipe()
+	go func() { _ = Serve(server) }()
+
+	if err := PutFile(client, path, 0755, bytes.NewReader(content)); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("wrong file content")
+	}
+}
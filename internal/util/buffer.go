@@ -17,6 +17,7 @@ package util
 import (
 	"bytes"
 	"io"
+	"sync"
 
 	"agola.io/agola/internal/errors"
 )
@@ -38,3 +39,45 @@ func (b *LimitedBuffer) Write(p []byte) (int, error) {
 func NewLimitedBuffer(cap int) *LimitedBuffer {
 	return &LimitedBuffer{Buffer: &bytes.Buffer{}, cap: cap}
 }
+
+type truncatingWriter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	n         int64
+	msg       string
+	truncated bool
+}
+
+// NewTruncatingWriter returns a writer that writes to w only the first n bytes.
+// When the limit is exceeded msg is written once to w and the remaining data is
+// discarded. Writes never fail because of the limit so the writer won't
+// be blocked. It's safe for concurrent use.
+func NewTruncatingWriter(w io.Writer, n int64, msg string) io.Writer {
+	return &truncatingWriter{w: w, n: n, msg: msg}
+}
+
+func (t *truncatingWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.truncated {
+		return len(p), nil
+	}
+
+	if int64(len(p)) <= t.n {
+		n, err := t.w.Write(p)
+		t.n -= int64(n)
+		return n, errors.WithStack(err)
+	}
+
+	if _, err := t.w.Write(p[:t.n]); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	t.n = 0
+	t.truncated = true
+	if _, err := io.WriteString(t.w, t.msg); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTruncatingWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		n      int64
+		out    string
+	}{
+		{name: "under the limit", writes: []string{"line1\n", "line2\n"}, n: 20, out: "line1\nline2\n"},
+		{name: "exactly the limit", writes: []string{"line1\n", "line2\n"}, n: 12, out: "line1\nline2\n"},
+		{name: "over the limit", writes: []string{"line1\n", "line2\n", "line3\n"}, n: 8, out: "line1\nli[truncated]\n"},
+		{name: "zero limit", writes: []string{"line1\n", "line2\n"}, n: 0, out: "[truncated]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewTruncatingWriter(&buf, tt.n, "[truncated]\n")
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				if n != len(s) {
+					t.Fatalf("got %d bytes written, want %d", n, len(s))
+				}
+			}
+			if buf.String() != tt.out {
+				t.Fatalf("got %q, want %q", buf.String(), tt.out)
+			}
+		})
+	}
+}
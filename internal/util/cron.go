@@ -0,0 +1,210 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"agola.io/agola/internal/errors"
+)
+
+// cronMaxSearch is the max time span searched for the next activation of a
+// cron schedule. Schedules like "0 0 30 2 *" never activate.
+const cronMaxSearch = 5 * 366 * 24 * time.Hour
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronDayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// CronSchedule is a parsed standard five fields (minute, hour, day of month,
+// month, day of week) cron expression. Every field is a bitmask of the
+// matching values.
+type CronSchedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// domStar and dowStar report if the day of month and day of week fields
+	// are unrestricted, like in vixie cron a field is unrestricted when it
+	// starts with a "*", also with a step. When both are restricted a day
+	// matches if one of them matches, otherwise it must match both
+	domStar bool
+	dowStar bool
+}
+
+// ParseCronSchedule parses a standard five fields cron expression. Fields
+// support lists, ranges, steps and month and day of week names. The @yearly,
+// @annually, @monthly, @weekly, @daily, @midnight and @hourly macros are also
+// supported.
+func ParseCronSchedule(spec string) (*CronSchedule, error) {
+	expr := strings.TrimSpace(spec)
+	if m, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = m
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("cron expression %q must have 5 fields", spec)
+	}
+
+	s := &CronSchedule{}
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, errors.Wrapf(err, "cron expression %q minute", spec)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, errors.Wrapf(err, "cron expression %q hour", spec)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, errors.Wrapf(err, "cron expression %q day of month", spec)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, errors.Wrapf(err, "cron expression %q month", spec)
+	}
+	// 7 is also accepted as sunday
+	if s.dow, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return nil, errors.Wrapf(err, "cron expression %q day of week", spec)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[2], "?")
+	s.dowStar = strings.HasPrefix(fields[4], "*") || strings.HasPrefix(fields[4], "?")
+
+	return s, nil
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepS := part, ""
+		if i := strings.Index(part, "/"); i >= 0 {
+			rng, stepS = part[:i], part[i+1:]
+		}
+
+		start, end := min, max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			i := strings.Index(rng, "-")
+			var err error
+			if start, err = parseCronValue(rng[:i], min, max, names); err != nil {
+				return 0, errors.WithStack(err)
+			}
+			if end, err = parseCronValue(rng[i+1:], min, max, names); err != nil {
+				return 0, errors.WithStack(err)
+			}
+			if start > end {
+				return 0, errors.Errorf("invalid range %q", rng)
+			}
+		default:
+			var err error
+			if start, err = parseCronValue(rng, min, max, names); err != nil {
+				return 0, errors.WithStack(err)
+			}
+			// a single value with a step means from the value to the max
+			end = start
+			if stepS != "" {
+				end = max
+			}
+		}
+
+		step := 1
+		if stepS != "" {
+			var err error
+			step, err = strconv.Atoi(stepS)
+			if err != nil || step <= 0 {
+				return 0, errors.Errorf("invalid step %q", stepS)
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCronValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.Errorf("invalid value %q", s)
+	}
+	if v < min || v > max {
+		return 0, errors.Errorf("value %d out of range [%d-%d]", v, min, max)
+	}
+	return v, nil
+}
+
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first activation time of the schedule after t, in the t
+// location. It returns the zero time if the schedule never activates.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronMaxSearch)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2022-03-15 is a tuesday
+	from := time.Date(2022, 3, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		next time.Time
+		err  bool
+	}{
+		{spec: "* * * * *", next: time.Date(2022, 3, 15, 10, 31, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", next: time.Date(2022, 3, 15, 10, 45, 0, 0, time.UTC)},
+		{spec: "0 2 * * *", next: time.Date(2022, 3, 16, 2, 0, 0, 0, time.UTC)},
+		{spec: "@daily", next: time.Date(2022, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{spec: "@hourly", next: time.Date(2022, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{spec: "0 0 * * sun", next: time.Date(2022, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 * * 7", next: time.Date(2022, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 9-17/4 * * mon-fri", next: time.Date(2022, 3, 15, 13, 0, 0, 0, time.UTC)},
+		{spec: "30 8 1,15 * *", next: time.Date(2022, 4, 1, 8, 30, 0, 0, time.UTC)},
+		// day of month and day of week both restricted match either of them
+		{spec: "0 0 1 * fri", next: time.Date(2022, 3, 18, 0, 0, 0, 0, time.UTC)},
+		// a day of month or day of week starting with "*" is unrestricted, the
+		// day must match both of them
+		{spec: "0 0 */2 * 1", next: time.Date(2022, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 1 */2 *", next: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 29 feb *", next: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 30 2 *", next: time.Time{}},
+		{spec: "0 0 * *", err: true},
+		{spec: "60 * * * *", err: true},
+		{spec: "0 5-2 * * *", err: true},
+		{spec: "*/0 * * * *", err: true},
+		{spec: "0 0 * foo *", err: true},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseCronSchedule(tt.spec)
+		if tt.err {
+			if err == nil {
+				t.Errorf("spec %q: expected error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("spec %q: unexpected err: %v", tt.spec, err)
+			continue
+		}
+		if next := s.Next(from); !next.Equal(tt.next) {
+			t.Errorf("spec %q: got next %s, want %s", tt.spec, next, tt.next)
+		}
+	}
+}
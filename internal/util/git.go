@@ -24,6 +24,7 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -32,7 +33,7 @@ import (
 
 // scpSyntaxRe matches the SCP-like addresses used by Git to access repositories
 // by SSH.
-var scpSyntaxRe = regexp.MustCompile(`^([a-zA-Z0-9_]+)@([a-zA-Z0-9._-]+):(.*)$`)
+var scpSyntaxRe = regexp.MustCompile(`^([a-zA-Z0-9_]+)@([a-zA-Z0-9._-]+|\[[0-9a-fA-F:.]+\]):(.*)$`)
 
 func ParseGitURL(us string) (*url.URL, error) {
 	if m := scpSyntaxRe.FindStringSubmatch(us); m != nil {
@@ -52,6 +53,50 @@ func ParseGitURL(us string) (*url.URL, error) {
 	return u, errors.WithStack(err)
 }
 
+// ValidateSSHEndpoint validates an ssh endpoint in the host[:port] format.
+// IPv6 hosts must be enclosed in square brackets.
+func ValidateSSHEndpoint(endpoint string) error {
+	u, err := url.Parse("ssh://" + endpoint)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.Host != endpoint {
+		return errors.Errorf("ssh endpoint must be in the host[:port] format")
+	}
+	if u.Hostname() == "" {
+		return errors.Errorf("empty ssh endpoint host")
+	}
+	if port := u.Port(); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil || p < 1 || p > 65535 {
+			return errors.Errorf("invalid ssh endpoint port %q", port)
+		}
+	}
+
+	return nil
+}
+
+// RewriteSSHCloneURL replaces the host and port of the provided ssh clone url
+// with the provided ssh endpoint (in the host[:port] format). SCP-like clone
+// urls are converted to ssh:// urls. Non ssh clone urls are returned
+// unchanged.
+func RewriteSSHCloneURL(cloneURL, endpoint string) (string, error) {
+	if endpoint == "" {
+		return cloneURL, nil
+	}
+
+	u, err := ParseGitURL(cloneURL)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if u.Scheme != "ssh" {
+		return cloneURL, nil
+	}
+	u.Host = endpoint
+
+	return u.String(), nil
+}
+
 type Git struct {
 	GitDir string
 	Env    []string
@@ -0,0 +1,100 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+)
+
+func TestValidateSSHEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		ok       bool
+	}{
+		{endpoint: "git.example.com", ok: true},
+		{endpoint: "git.example.com:2222", ok: true},
+		{endpoint: "192.168.1.1:2222", ok: true},
+		{endpoint: "[::1]:2222", ok: true},
+		{endpoint: "[::1]", ok: true},
+		{endpoint: "", ok: false},
+		{endpoint: ":2222", ok: false},
+		{endpoint: "git.example.com:port", ok: false},
+		{endpoint: "git.example.com:0", ok: false},
+		{endpoint: "git.example.com:70000", ok: false},
+		{endpoint: "git@git.example.com", ok: false},
+		{endpoint: "git.example.com/path", ok: false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateSSHEndpoint(tt.endpoint)
+		if tt.ok && err != nil {
+			t.Errorf("endpoint %q: unexpected err: %v", tt.endpoint, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("endpoint %q: expected error", tt.endpoint)
+		}
+	}
+}
+
+func TestRewriteSSHCloneURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		cloneURL string
+		endpoint string
+		out      string
+	}{
+		{
+			name:     "empty endpoint",
+			cloneURL: "git@gitea.example.com:owner/repo.git",
+			out:      "git@gitea.example.com:owner/repo.git",
+		},
+		{
+			name:     "scp like url",
+			cloneURL: "git@gitea.example.com:owner/repo.git",
+			endpoint: "gitea.example.com:2222",
+			out:      "ssh://git@gitea.example.com:2222/owner/repo.git",
+		},
+		{
+			name:     "ssh url with port",
+			cloneURL: "ssh://git@gitea.example.com:2222/owner/repo.git",
+			endpoint: "ssh.example.com",
+			out:      "ssh://git@ssh.example.com/owner/repo.git",
+		},
+		{
+			name:     "ssh url with ipv6 endpoint",
+			cloneURL: "ssh://git@gitea.example.com/owner/repo.git",
+			endpoint: "[::1]:2222",
+			out:      "ssh://git@[::1]:2222/owner/repo.git",
+		},
+		{
+			name:     "http url is not rewritten",
+			cloneURL: "https://gitea.example.com/owner/repo.git",
+			endpoint: "ssh.example.com:2222",
+			out:      "https://gitea.example.com/owner/repo.git",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := RewriteSSHCloneURL(tt.cloneURL, tt.endpoint)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if out != tt.out {
+				t.Fatalf("got %q, want %q", out, tt.out)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"agola.io/agola/internal/errors"
+)
+
+// SkipLines reads and discards the first n lines from br. If br contains less
+// than n lines all its content is discarded.
+func SkipLines(br *bufio.Reader, n int64) error {
+	for n > 0 {
+		_, err := br.ReadSlice('\n')
+		switch {
+		case err == nil:
+			n--
+		case errors.Is(err, bufio.ErrBufferFull):
+			// line longer than the buffer, continue reading it
+		case errors.Is(err, io.EOF):
+			return nil
+		default:
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+type lineLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+// LineLimitReader returns a reader that reads from r and stops with io.EOF
+// after n lines. Like io.LimitReader the underlying reader could have been
+// read past the returned data.
+func LineLimitReader(r io.Reader, n int64) io.Reader {
+	return &lineLimitReader{r: r, n: n}
+}
+
+func (l *lineLimitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
+	}
+
+	n, err := l.r.Read(p)
+	for i := 0; i < n; {
+		idx := bytes.IndexByte(p[i:n], '\n')
+		if idx < 0 {
+			break
+		}
+		i += idx + 1
+		l.n--
+		if l.n == 0 {
+			return i, nil
+		}
+	}
+
+	return n, err
+}
+
+// CountReaderLines reads all the content of r returning its size and its number of
+// lines. A final line without a newline is also counted.
+func CountReaderLines(r io.Reader) (int64, int64, error) {
+	buf := make([]byte, 32*1024)
+
+	var size, lines int64
+	var last byte
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			size += int64(n)
+			lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+			last = buf[n-1]
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return 0, 0, errors.WithStack(err)
+		}
+	}
+	if size > 0 && last != '\n' {
+		lines++
+	}
+
+	return size, lines, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bufio"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLineRange(t *testing.T) {
+	in := "line1\nline2\nline3\nline4\nline5"
+
+	tests := []struct {
+		name   string
+		offset int64
+		limit  int64
+		out    string
+	}{
+		{name: "no offset and no limit", out: in},
+		{name: "offset", offset: 2, out: "line3\nline4\nline5"},
+		{name: "limit", limit: 2, out: "line1\nline2\n"},
+		{name: "offset and limit", offset: 1, limit: 3, out: "line2\nline3\nline4\n"},
+		{name: "limit past the end", offset: 3, limit: 10, out: "line4\nline5"},
+		{name: "offset past the end", offset: 10, limit: 1, out: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// use a small buffer and a one byte reader to also test lines
+			// longer than the buffer and lines split between reads
+			br := bufio.NewReaderSize(iotest.OneByteReader(strings.NewReader(in)), 16)
+			if err := SkipLines(br, tt.offset); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			r := iotest.HalfReader(br)
+			if tt.limit > 0 {
+				r = LineLimitReader(r, tt.limit)
+			}
+			out, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if string(out) != tt.out {
+				t.Fatalf("got %q, want %q", out, tt.out)
+			}
+		})
+	}
+}
+
+func TestCountReaderLines(t *testing.T) {
+	tests := []struct {
+		in    string
+		size  int64
+		lines int64
+	}{
+		{in: "", size: 0, lines: 0},
+		{in: "\n", size: 1, lines: 1},
+		{in: "line1", size: 5, lines: 1},
+		{in: "line1\nline2\n", size: 12, lines: 2},
+		{in: "line1\nline2", size: 11, lines: 2},
+	}
+
+	for _, tt := range tests {
+		size, lines, err := CountReaderLines(strings.NewReader(tt.in))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if size != tt.size || lines != tt.lines {
+			t.Errorf("in %q: got size %d lines %d, want size %d lines %d", tt.in, size, lines, tt.size, tt.lines)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+
+	"agola.io/agola/internal/errors"
+)
+
+// ValidateListenAddress checks that addr is a valid listen address in the
+// host:port form. IPv6 literals must be enclosed in square brackets (i.e.
+// [::1]:8000).
+func ValidateListenAddress(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.Wrapf(err, "invalid listen address %q", addr)
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err != nil || (p == 0 && port != "0") {
+		return errors.Errorf("invalid port %q in listen address %q", port, addr)
+	}
+	// an ip literal must be a valid ip
+	if host != "" && net.ParseIP(host) == nil && !isHostName(host) {
+		return errors.Errorf("invalid host %q in listen address %q", host, addr)
+	}
+
+	return nil
+}
+
+func isHostName(host string) bool {
+	for _, c := range host {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// listenNetwork returns the network to use for the provided listen address.
+// An empty host or an host name uses the "tcp" network that, for wildcard
+// addresses, binds dual-stack. The same happens for a single IPv6 wildcard
+// address. When listening on multiple addresses every ip literal is bound only
+// to its address family to avoid conflicts between a dual-stack IPv6 wildcard
+// listener and an IPv4 listener on the same port.
+func listenNetwork(addr string, multiple bool) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "tcp"
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !multiple {
+		return "tcp"
+	}
+	if ip.To4() != nil {
+		return "tcp4"
+	}
+	return "tcp6"
+}
+
+// Listen creates a listener for every provided address.
+func Listen(addresses []string) ([]net.Listener, error) {
+	if len(addresses) == 0 {
+		return nil, errors.Errorf("no listen addresses")
+	}
+
+	listeners := []net.Listener{}
+	for _, addr := range addresses {
+		l, err := net.Listen(listenNetwork(addr, len(addresses) > 1), addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, errors.Wrapf(err, "failed to listen on %q", addr)
+		}
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+// ListenAndServe listens on all the provided addresses and serves the http
+// server on all of them (using TLS if useTLS is true, the server TLSConfig
+// must contain the certificates).
+// It returns the first listen or serve error. Like http.Server.ListenAndServe
+// it always returns a non nil error, http.ErrServerClosed after the server is
+// closed.
+func ListenAndServe(server *http.Server, addresses []string, useTLS bool) error {
+	listeners, err := Listen(addresses)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	errCh := make(chan error, len(listeners))
+	for _, l := range listeners {
+		l := l
+		go func() {
+			if !useTLS {
+				errCh <- server.Serve(l)
+			} else {
+				errCh <- server.ServeTLS(l, "", "")
+			}
+		}()
+	}
+
+	// return the first error, the other listeners will be closed when the
+	// server is closed
+	return <-errCh
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import "testing"
+
+func TestValidateListenAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		ok   bool
+	}{
+		{addr: ":8000", ok: true},
+		{addr: "0.0.0.0:8000", ok: true},
+		{addr: "localhost:8000", ok: true},
+		{addr: "[::]:8000", ok: true},
+		{addr: "[::1]:8000", ok: true},
+		{addr: "[fe80::1]:0", ok: true},
+		{addr: "", ok: false},
+		{addr: "8000", ok: false},
+		{addr: "::1:8000", ok: false},
+		{addr: "[::1]", ok: false},
+		{addr: ":http", ok: false},
+		{addr: ":70000", ok: false},
+		{addr: "local_host:8000", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			err := ValidateListenAddress(tt.addr)
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestListenNetwork(t *testing.T) {
+	tests := []struct {
+		addr     string
+		multiple bool
+		network  string
+	}{
+		{addr: ":8000", multiple: false, network: "tcp"},
+		{addr: ":8000", multiple: true, network: "tcp"},
+		{addr: "localhost:8000", multiple: true, network: "tcp"},
+		{addr: "[::]:8000", multiple: false, network: "tcp"},
+		{addr: "[::]:8000", multiple: true, network: "tcp6"},
+		{addr: "0.0.0.0:8000", multiple: true, network: "tcp4"},
+		{addr: "127.0.0.1:8000", multiple: false, network: "tcp"},
+	}
+
+	for _, tt := range tests {
+		network := listenNetwork(tt.addr, tt.multiple)
+		if network != tt.network {
+			t.Errorf("addr %q, multiple %t: got network %q, want %q", tt.addr, tt.multiple, network, tt.network)
+		}
+	}
+}
+
+func TestParseGitURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		host string
+		path string
+	}{
+		{url: "git@example.com:user/repo", host: "example.com", path: "user/repo"},
+		{url: "git@[::1]:user/repo", host: "::1", path: "user/repo"},
+		{url: "ssh://git@[::1]:2222/user/repo", host: "::1", path: "/user/repo"},
+	}
+
+	for _, tt := range tests {
+		u, err := ParseGitURL(tt.url)
+		if err != nil {
+			t.Fatalf("url %q: unexpected error: %v", tt.url, err)
+		}
+		if u.Hostname() != tt.host {
+			t.Errorf("url %q: got host %q, want %q", tt.url, u.Hostname(), tt.host)
+		}
+		if u.Path != tt.path {
+			t.Errorf("url %q: got path %q, want %q", tt.url, u.Path, tt.path)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"io"
+
+	"agola.io/agola/internal/errors"
+
+	"golang.org/x/time/rate"
+)
+
+const maxBandwidthLimiterBurst = 64 * 1024
+
+// NewBandwidthLimiter returns a rate limiter limiting the bandwidth to the
+// provided bytes per second. It returns nil (no limit) when bytesPerSecond is
+// zero.
+func NewBandwidthLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	burst := bytesPerSecond
+	if burst > maxBandwidthLimiterBurst {
+		burst = maxBandwidthLimiterBurst
+	}
+
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(burst))
+}
+
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+// NewRateLimitedReader returns a reader that reads from r waiting on the
+// limiter for every read byte. If limiter is nil r is returned.
+// The same limiter can be shared by multiple readers to limit their total
+// bandwidth.
+func NewRateLimitedReader(ctx context.Context, r io.Reader, limiter *rate.Limiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+
+	return &rateLimitedReader{ctx: ctx, r: r, limiter: limiter}
+}
+
+func (l *rateLimitedReader) Read(p []byte) (int, error) {
+	// don't read more than the limiter burst since WaitN will fail
+	if len(p) > l.limiter.Burst() {
+		p = p[:l.limiter.Burst()]
+	}
+
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if werr := l.limiter.WaitN(l.ctx, n); werr != nil {
+			return n, errors.WithStack(werr)
+		}
+	}
+
+	return n, err
+}
+
+// CountingReader is a reader counting the bytes read from the underlying
+// reader
+type CountingReader struct {
+	r io.Reader
+	n int64
+}
+
+func NewCountingReader(r io.Reader) *CountingReader {
+	return &CountingReader{r: r}
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Count returns the number of bytes read
+func (c *CountingReader) Count() int64 {
+	return c.n
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedReader(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 40000)
+
+	if l := NewBandwidthLimiter(0); l != nil {
+		t.Fatalf("expected nil limiter")
+	}
+
+	// the limiter starts with a full burst (20000 bytes) so reading 40000
+	// bytes should take at least one second
+	limiter := NewBandwidthLimiter(20000)
+	cr := NewCountingReader(NewRateLimitedReader(context.Background(), bytes.NewReader(data), limiter))
+
+	start := time.Now()
+	out, err := ioutil.ReadAll(cr)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if !bytes.Equal(out, data) {
+		t.Fatalf("read data differs from source data")
+	}
+	if cr.Count() != int64(len(data)) {
+		t.Fatalf("expected count %d, got %d", len(data), cr.Count())
+	}
+	if elapsed < 900*time.Millisecond {
+		t.Fatalf("expected read to take at least 1s, took %s", elapsed)
+	}
+}
+
+func TestRateLimitedReaderCancel(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 40000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	limiter := NewBandwidthLimiter(1000)
+	if _, err := ioutil.ReadAll(NewRateLimitedReader(ctx, bytes.NewReader(data), limiter)); err == nil {
+		t.Fatalf("expected error reading with a canceled context")
+	}
+}
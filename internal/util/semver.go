@@ -0,0 +1,127 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"strconv"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+)
+
+type semver struct {
+	core       [3]uint64
+	prerelease []string
+}
+
+func parseSemver(v string) (*semver, error) {
+	s := strings.TrimPrefix(v, "v")
+	// build metadata doesn't affect precedence
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+
+	sv := &semver{}
+	if i := strings.Index(s, "-"); i >= 0 {
+		sv.prerelease = strings.Split(s[i+1:], ".")
+		s = s[:i]
+		for _, id := range sv.prerelease {
+			if id == "" {
+				return nil, errors.Errorf("invalid semantic version %q: empty prerelease identifier", v)
+			}
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, errors.Errorf("invalid semantic version %q", v)
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid semantic version %q", v)
+		}
+		sv.core[i] = n
+	}
+
+	return sv, nil
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease compares two prerelease versions following the semantic
+// versioning precedence rules
+func comparePrerelease(a, b []string) int {
+	// a version without prerelease has higher precedence
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		an, aerr := strconv.ParseUint(a[i], 10, 64)
+		bn, berr := strconv.ParseUint(b[i], 10, 64)
+		switch {
+		case aerr == nil && berr == nil:
+			if c := compareUint(an, bn); c != 0 {
+				return c
+			}
+		// numeric identifiers have lower precedence than alphanumeric ones
+		case aerr == nil:
+			return -1
+		case berr == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return compareUint(uint64(len(a)), uint64(len(b)))
+}
+
+// CompareSemver compares two semantic versions (an optional "v" prefix is
+// accepted) returning -1, 0 or 1 if a is lower, equal or greater than b.
+func CompareSemver(a, b string) (int, error) {
+	sa, err := parseSemver(a)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	sb, err := parseSemver(b)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	for i := range sa.core {
+		if c := compareUint(sa.core[i], sb.core[i]); c != 0 {
+			return c, nil
+		}
+	}
+
+	return comparePrerelease(sa.prerelease, sb.prerelease), nil
+}
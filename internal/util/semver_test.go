@@ -0,0 +1,60 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+)
+
+func TestCompareSemver(t *testing.T) {
+	tests := []struct {
+		a   string
+		b   string
+		out int
+		err bool
+	}{
+		{a: "1.0.0", b: "1.0.0", out: 0},
+		{a: "v1.0.0", b: "1.0.0", out: 0},
+		{a: "1.0.0", b: "2.0.0", out: -1},
+		{a: "2.1.0", b: "2.0.10", out: 1},
+		{a: "1.0.10", b: "1.0.9", out: 1},
+		{a: "1.0.0-alpha", b: "1.0.0", out: -1},
+		{a: "1.0.0-alpha", b: "1.0.0-alpha.1", out: -1},
+		{a: "1.0.0-alpha.1", b: "1.0.0-alpha.beta", out: -1},
+		{a: "1.0.0-beta.11", b: "1.0.0-beta.2", out: 1},
+		{a: "1.0.0-rc.1", b: "1.0.0-beta.11", out: 1},
+		{a: "1.0.0+build1", b: "1.0.0+build2", out: 0},
+		{a: "1.0", b: "1.0.0", err: true},
+		{a: "1.0.0", b: "1.0.x", err: true},
+		{a: "1.0.0-", b: "1.0.0", err: true},
+	}
+
+	for _, tt := range tests {
+		out, err := CompareSemver(tt.a, tt.b)
+		if tt.err {
+			if err == nil {
+				t.Errorf("compare %q %q: expected error", tt.a, tt.b)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("compare %q %q: unexpected err: %v", tt.a, tt.b, err)
+			continue
+		}
+		if out != tt.out {
+			t.Errorf("compare %q %q: got %d, want %d", tt.a, tt.b, out, tt.out)
+		}
+	}
+}
@@ -83,3 +83,23 @@ func Difference(a []string, b []string) []string {
 	}
 	return diff
 }
+
+// UniqueSortedStrings returns a sorted copy of s without duplicate elements.
+// It returns nil for an empty slice
+func UniqueSortedStrings(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+
+	r := append([]string(nil), s...)
+	sort.Strings(r)
+
+	j := 0
+	for i := 1; i < len(r); i++ {
+		if r[i] != r[j] {
+			j++
+			r[j] = r[i]
+		}
+	}
+	return r[:j+1]
+}
@@ -97,3 +97,24 @@ func TestDifference(t *testing.T) {
 		}
 	}
 }
+
+func TestUniqueSortedStrings(t *testing.T) {
+	tests := []struct {
+		s []string
+		r []string
+	}{
+		{nil, nil},
+		{[]string{}, nil},
+		{[]string{"a"}, []string{"a"}},
+		{[]string{"b", "a"}, []string{"a", "b"}},
+		{[]string{"b", "a", "b", "c", "a"}, []string{"a", "b", "c"}},
+		{[]string{"a", "a", "a"}, []string{"a"}},
+	}
+
+	for i, tt := range tests {
+		r := UniqueSortedStrings(tt.s)
+		if !CompareStringSlice(r, tt.r) {
+			t.Errorf("%d: got %v but wanted: %v", i, r, tt.r)
+		}
+	}
+}
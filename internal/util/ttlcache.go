@@ -0,0 +1,117 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type ttlCacheEntry struct {
+	value      interface{}
+	expiration time.Time
+}
+
+// TTLCache is an in memory key/value cache where every entry expires after the
+// cache ttl. It's safe for concurrent use.
+type TTLCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*ttlCacheEntry
+
+	// now is used to override the current time in tests
+	now func() time.Time
+}
+
+// NewTTLCache creates a new TTLCache. When maxEntries is greater than 0 and
+// the cache is full, the expired entries are removed and, if still full, a
+// new entry won't be added.
+func NewTTLCache(ttl time.Duration, maxEntries int) *TTLCache {
+	return &TTLCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*ttlCacheEntry),
+		now:        time.Now,
+	}
+}
+
+// Get returns the value of a not expired entry.
+func (c *TTLCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(e.expiration) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+// Set adds or replaces an entry.
+func (c *TTLCache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if _, ok := c.entries[key]; !ok && c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expiration) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			return
+		}
+	}
+
+	c.entries[key] = &ttlCacheEntry{value: value, expiration: now.Add(c.ttl)}
+}
+
+// Delete removes an entry.
+func (c *TTLCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+// DeletePrefix removes all the entries with a key starting with prefix.
+func (c *TTLCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Len returns the number of entries, including the expired ones not yet
+// removed.
+func (c *TTLCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
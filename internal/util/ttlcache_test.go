@@ -0,0 +1,66 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCache(t *testing.T) {
+	now := time.Now()
+	c := NewTTLCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	c.Set("rs/01", "v01")
+	if v, ok := c.Get("rs/01"); !ok || v != "v01" {
+		t.Fatalf("got %v, %t, want %q, true", v, ok, "v01")
+	}
+
+	// cache full
+	c.Set("rs/02", "v02")
+	c.Set("la/03", "v03")
+	if _, ok := c.Get("la/03"); ok {
+		t.Fatalf("expected entry not added to a full cache")
+	}
+	// replacing an entry of a full cache is allowed
+	c.Set("rs/02", "v02b")
+	if v, ok := c.Get("rs/02"); !ok || v != "v02b" {
+		t.Fatalf("got %v, %t, want %q, true", v, ok, "v02b")
+	}
+
+	// expired entries are removed when the cache is full
+	now = now.Add(time.Minute)
+	c.Set("la/03", "v03")
+	if v, ok := c.Get("la/03"); !ok || v != "v03" {
+		t.Fatalf("got %v, %t, want %q, true", v, ok, "v03")
+	}
+	if c.Len() != 1 {
+		t.Fatalf("got %d entries, want 1", c.Len())
+	}
+	if _, ok := c.Get("rs/01"); ok {
+		t.Fatalf("expected expired entry")
+	}
+
+	c.Set("rs/01", "v01")
+	c.DeletePrefix("rs/")
+	if _, ok := c.Get("rs/01"); ok {
+		t.Fatalf("expected deleted entry")
+	}
+	c.Delete("la/03")
+	if c.Len() != 0 {
+		t.Fatalf("got %d entries, want 0", c.Len())
+	}
+}
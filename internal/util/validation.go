@@ -24,6 +24,19 @@ import (
 
 var nameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]*([-]?[a-zA-Z0-9]+)+$`)
 
+// tagRegexp matches the tags accepted by agola. They are a superset of the
+// repository topics accepted by the git sources (lowercase, digits, hyphens)
+var tagRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*$`)
+
+const maxTagLength = 50
+
+// labelRegexp matches the run and task label keys and values. The allowed
+// characters don't include the characters used to encode the labels filters
+// (like "=" and ",")
+var labelRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._-]*[a-zA-Z0-9])?$`)
+
+const maxLabelLength = 63
+
 var (
 	ErrValidation = errors.New("validation error")
 )
@@ -36,3 +49,38 @@ func ValidateName(s string) bool {
 	}
 	return nameRegexp.MatchString(s)
 }
+
+func ValidateTag(s string) bool {
+	if len(s) > maxTagLength {
+		return false
+	}
+	return tagRegexp.MatchString(s)
+}
+
+func ValidateLabelKey(s string) bool {
+	if len(s) > maxLabelLength {
+		return false
+	}
+	return labelRegexp.MatchString(s)
+}
+
+// ValidateLabelValue is like ValidateLabelKey but also accepts empty values
+func ValidateLabelValue(s string) bool {
+	if s == "" {
+		return true
+	}
+	return ValidateLabelKey(s)
+}
+
+func ValidateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if !ValidateLabelKey(k) {
+			return errors.Errorf("invalid label key %q", k)
+		}
+		if !ValidateLabelValue(v) {
+			return errors.Errorf("label %q: invalid value %q", k, v)
+		}
+	}
+
+	return nil
+}
@@ -65,3 +65,69 @@ func TestValidateName(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateTag(t *testing.T) {
+	goodTags := []string{
+		"go",
+		"team-backend",
+		"k8s",
+		"1password",
+		"node.js",
+	}
+	badTags := []string{
+		"",
+		"Go",
+		"-go",
+		".go",
+		"team backend",
+		"team_backend",
+		"averyveryveryveryveryveryveryveryveryveryverylongtag",
+	}
+
+	for _, tag := range goodTags {
+		if !ValidateTag(tag) {
+			t.Errorf("expect valid tag for %q", tag)
+		}
+	}
+	for _, tag := range badTags {
+		if ValidateTag(tag) {
+			t.Errorf("expect invalid tag for %q", tag)
+		}
+	}
+}
+
+func TestValidateLabelKey(t *testing.T) {
+	goodKeys := []string{
+		"team",
+		"Tier",
+		"team_name",
+		"app.kubernetes.io",
+		"e2e-tests",
+		"1",
+	}
+	badKeys := []string{
+		"",
+		"-team",
+		"team-",
+		"team name",
+		"team=backend",
+		"team,tier",
+		"team/name",
+		"averyveryveryveryveryveryveryveryveryveryveryveryveryverylonglabel",
+	}
+
+	for _, key := range goodKeys {
+		if !ValidateLabelKey(key) {
+			t.Errorf("expect valid label key for %q", key)
+		}
+	}
+	for _, key := range badKeys {
+		if ValidateLabelKey(key) {
+			t.Errorf("expect invalid label key for %q", key)
+		}
+	}
+
+	if !ValidateLabelValue("") {
+		t.Errorf("expect valid empty label value")
+	}
+}
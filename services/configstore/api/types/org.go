@@ -24,6 +24,10 @@ type CreateOrgRequest struct {
 	CreatorUserID string
 }
 
+type UpdateOrgRequest struct {
+	ConcurrencyLimits cstypes.ConcurrencyLimits
+}
+
 type AddOrgMemberRequest struct {
 	Role cstypes.MemberRole
 }
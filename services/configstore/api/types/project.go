@@ -30,6 +30,22 @@ type CreateUpdateProjectRequest struct {
 	SSHPrivateKey              string
 	SkipSSHHostKeyCheck        bool
 	PassVarsToForkedPR         bool
+	ReportSkippedRuns          bool
+	Tags                       []string
+	PostPullRequestComments    bool
+	UseDepsProxy               bool
+	CancelSupersededRuns       bool
+	RunsVisibility             cstypes.RunsVisibility
+	LogsVisibility             cstypes.RunsVisibility
+	ProtectedBranches          []string
+	ProtectedTags              []string
+	AllowedUpstreamProjects    []string
+	Schedules                  []*cstypes.ProjectSchedule
+	ConcurrencyLimits          cstypes.ConcurrencyLimits
+}
+
+type UpdateProjectPolledRefsRequest struct {
+	Refs map[string]string `json:"refs"`
 }
 
 // Project augments cstypes.Project with dynamic data
@@ -28,6 +28,8 @@ type CreateUpdateRemoteSourceRequest struct {
 	Oauth2ClientSecret  string
 	SSHHostKey          string
 	SkipSSHHostKeyCheck bool
+	SSHEndpoint         string
+	OrgWebhooks         bool
 	RegistrationEnabled *bool
 	LoginEnabled        *bool
 }
@@ -22,6 +22,7 @@ type CreateUpdateSecretRequest struct {
 	Name             string
 	Type             cstypes.SecretType
 	Data             map[string]string
+	Sealed           bool
 	SecretProviderID string
 	Path             string
 }
@@ -15,6 +15,7 @@
 package types
 
 import (
+	"encoding/json"
 	"time"
 
 	cstypes "agola.io/agola/services/configstore/types"
@@ -34,6 +35,8 @@ type CreateUserLARequest struct {
 	RemoteSourceName           string    `json:"remote_source_name"`
 	RemoteUserID               string    `json:"remote_user_id"`
 	RemoteUserName             string    `json:"remote_user_name"`
+	RemoteUserDisplayName      string    `json:"remote_user_display_name"`
+	RemoteUserAvatarURL        string    `json:"remote_user_avatar_url"`
 	UserAccessToken            string    `json:"user_access_token"`
 	Oauth2AccessToken          string    `json:"oauth2_access_token"`
 	Oauth2RefreshToken         string    `json:"oauth2_refresh_token"`
@@ -43,6 +46,8 @@ type CreateUserLARequest struct {
 type UpdateUserLARequest struct {
 	RemoteUserID               string    `json:"remote_user_id"`
 	RemoteUserName             string    `json:"remote_user_name"`
+	RemoteUserDisplayName      string    `json:"remote_user_display_name"`
+	RemoteUserAvatarURL        string    `json:"remote_user_avatar_url"`
 	UserAccessToken            string    `json:"user_access_token"`
 	Oauth2AccessToken          string    `json:"oauth2_access_token"`
 	Oauth2RefreshToken         string    `json:"oauth2_refresh_token"`
@@ -58,6 +63,19 @@ type CreateUserTokenResponse struct {
 	Token string `json:"token"`
 }
 
+type UpdateUserTokenLastUsedRequest struct {
+	Token        string    `json:"token"`
+	LastUsedTime time.Time `json:"last_used_time"`
+}
+
+type UpdateUserPreferencesRequest struct {
+	DefaultOrgRef     string          `json:"default_org_ref"`
+	DefaultProjectRef string          `json:"default_project_ref"`
+	Timezone          string          `json:"timezone"`
+	DateFormat        string          `json:"date_format"`
+	UISettings        json.RawMessage `json:"ui_settings"`
+}
+
 type UserOrgsResponse struct {
 	Organization *cstypes.Organization
 	Role         cstypes.MemberRole
@@ -109,9 +109,13 @@ func (c *Client) GetProjectGroupSubgroups(ctx context.Context, projectGroupRef s
 	return projectGroups, resp, errors.WithStack(err)
 }
 
-func (c *Client) GetProjectGroupProjects(ctx context.Context, projectGroupRef string) ([]*csapitypes.Project, *http.Response, error) {
+func (c *Client) GetProjectGroupProjects(ctx context.Context, projectGroupRef string, tags []string) ([]*csapitypes.Project, *http.Response, error) {
 	projects := []*csapitypes.Project{}
-	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/projectgroups/%s/projects", url.PathEscape(projectGroupRef)), nil, jsonContent, nil, &projects)
+	q := url.Values{}
+	for _, tag := range tags {
+		q.Add("tag", tag)
+	}
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/projectgroups/%s/projects", url.PathEscape(projectGroupRef)), q, jsonContent, nil, &projects)
 	return projects, resp, errors.WithStack(err)
 }
 
@@ -173,6 +177,41 @@ func (c *Client) DeleteProject(ctx context.Context, projectRef string) (*http.Re
 	return c.getResponse(ctx, "DELETE", fmt.Sprintf("/projects/%s", url.PathEscape(projectRef)), nil, jsonContent, nil)
 }
 
+func (c *Client) SoftDeleteProject(ctx context.Context, projectRef string) (*http.Response, error) {
+	q := url.Values{}
+	q.Add("soft", "")
+	return c.getResponse(ctx, "DELETE", fmt.Sprintf("/projects/%s", url.PathEscape(projectRef)), q, jsonContent, nil)
+}
+
+func (c *Client) UndeleteProject(ctx context.Context, projectRef string) (*csapitypes.Project, *http.Response, error) {
+	resProject := new(csapitypes.Project)
+	resp, err := c.getParsedResponse(ctx, "PUT", fmt.Sprintf("/projects/%s/undelete", url.PathEscape(projectRef)), nil, jsonContent, nil, resProject)
+	return resProject, resp, errors.WithStack(err)
+}
+
+func (c *Client) GetProjectPolledRefs(ctx context.Context, projectRef string) (*cstypes.ProjectPolledRefs, *http.Response, error) {
+	projectPolledRefs := new(cstypes.ProjectPolledRefs)
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/projects/%s/polledrefs", url.PathEscape(projectRef)), nil, jsonContent, nil, projectPolledRefs)
+	return projectPolledRefs, resp, errors.WithStack(err)
+}
+
+func (c *Client) UpdateProjectPolledRefs(ctx context.Context, projectRef string, req *csapitypes.UpdateProjectPolledRefsRequest) (*cstypes.ProjectPolledRefs, *http.Response, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	projectPolledRefs := new(cstypes.ProjectPolledRefs)
+	resp, err := c.getParsedResponse(ctx, "PUT", fmt.Sprintf("/projects/%s/polledrefs", url.PathEscape(projectRef)), nil, jsonContent, bytes.NewReader(reqj), projectPolledRefs)
+	return projectPolledRefs, resp, errors.WithStack(err)
+}
+
+func (c *Client) GetDeletedProjects(ctx context.Context) ([]*csapitypes.Project, *http.Response, error) {
+	projects := []*csapitypes.Project{}
+	resp, err := c.getParsedResponse(ctx, "GET", "/deletedprojects", nil, jsonContent, nil, &projects)
+	return projects, resp, errors.WithStack(err)
+}
+
 func (c *Client) GetProjectGroupSecrets(ctx context.Context, projectGroupRef string, tree bool) ([]*csapitypes.Secret, *http.Response, error) {
 	q := url.Values{}
 	if tree {
@@ -463,6 +502,32 @@ func (c *Client) DeleteUserToken(ctx context.Context, userRef, tokenName string)
 	return c.getResponse(ctx, "DELETE", fmt.Sprintf("/users/%s/tokens/%s", userRef, tokenName), nil, jsonContent, nil)
 }
 
+func (c *Client) UpdateUserTokenLastUsed(ctx context.Context, userRef string, req *csapitypes.UpdateUserTokenLastUsedRequest) (*http.Response, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return c.getResponse(ctx, "PUT", fmt.Sprintf("/users/%s/tokens/lastused", userRef), nil, jsonContent, bytes.NewReader(reqj))
+}
+
+func (c *Client) GetUserPreferences(ctx context.Context, userRef string) (*cstypes.UserPreferences, *http.Response, error) {
+	userPreferences := new(cstypes.UserPreferences)
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/users/%s/preferences", userRef), nil, jsonContent, nil, userPreferences)
+	return userPreferences, resp, errors.WithStack(err)
+}
+
+func (c *Client) UpdateUserPreferences(ctx context.Context, userRef string, req *csapitypes.UpdateUserPreferencesRequest) (*cstypes.UserPreferences, *http.Response, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	userPreferences := new(cstypes.UserPreferences)
+	resp, err := c.getParsedResponse(ctx, "PUT", fmt.Sprintf("/users/%s/preferences", userRef), nil, jsonContent, bytes.NewReader(reqj), userPreferences)
+	return userPreferences, resp, errors.WithStack(err)
+}
+
 func (c *Client) GetUserOrgs(ctx context.Context, userRef string) ([]*csapitypes.UserOrgsResponse, *http.Response, error) {
 	userOrgs := []*csapitypes.UserOrgsResponse{}
 	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/users/%s/orgs", userRef), nil, jsonContent, nil, &userOrgs)
@@ -492,6 +557,19 @@ func (c *Client) GetRemoteSources(ctx context.Context, start string, limit int,
 	return rss, resp, errors.WithStack(err)
 }
 
+// GetRemoteSourceProjects returns the projects using the remote source. When
+// repositoryPath isn't empty only the projects of that repository are returned.
+func (c *Client) GetRemoteSourceProjects(ctx context.Context, rsRef, repositoryPath string) ([]*csapitypes.Project, *http.Response, error) {
+	q := url.Values{}
+	if repositoryPath != "" {
+		q.Add("repositorypath", repositoryPath)
+	}
+
+	projects := []*csapitypes.Project{}
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/remotesources/%s/projects", rsRef), q, jsonContent, nil, &projects)
+	return projects, resp, errors.WithStack(err)
+}
+
 func (c *Client) CreateRemoteSource(ctx context.Context, req *csapitypes.CreateUpdateRemoteSourceRequest) (*cstypes.RemoteSource, *http.Response, error) {
 	reqj, err := json.Marshal(req)
 	if err != nil {
@@ -529,6 +607,17 @@ func (c *Client) CreateOrg(ctx context.Context, req *csapitypes.CreateOrgRequest
 	return org, resp, errors.WithStack(err)
 }
 
+func (c *Client) UpdateOrg(ctx context.Context, orgRef string, req *csapitypes.UpdateOrgRequest) (*cstypes.Organization, *http.Response, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	org := new(cstypes.Organization)
+	resp, err := c.getParsedResponse(ctx, "PUT", fmt.Sprintf("/orgs/%s", orgRef), nil, jsonContent, bytes.NewReader(reqj), org)
+	return org, resp, errors.WithStack(err)
+}
+
 func (c *Client) DeleteOrg(ctx context.Context, orgRef string) (*http.Response, error) {
 	return c.getResponse(ctx, "DELETE", fmt.Sprintf("/orgs/%s", orgRef), nil, jsonContent, nil)
 }
@@ -579,3 +668,31 @@ func (c *Client) GetOrgMembers(ctx context.Context, orgRef string) ([]*csapitype
 	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/orgs/%s/members", orgRef), nil, jsonContent, nil, &orgMembers)
 	return orgMembers, resp, errors.WithStack(err)
 }
+
+func (c *Client) GetAnnouncements(ctx context.Context, onlyActive bool) ([]*cstypes.Announcement, *http.Response, error) {
+	q := url.Values{}
+	if onlyActive {
+		q.Add("active", "")
+	}
+
+	announcements := []*cstypes.Announcement{}
+	resp, err := c.getParsedResponse(ctx, "GET", "/announcements", q, jsonContent, nil, &announcements)
+	return announcements, resp, errors.WithStack(err)
+}
+
+func (c *Client) CreateAnnouncement(ctx context.Context, req *csapitypes.CreateAnnouncementRequest) (*cstypes.Announcement, *http.Response, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	announcement := new(cstypes.Announcement)
+	resp, err := c.getParsedResponse(ctx, "POST", "/announcements", nil, jsonContent, bytes.NewReader(reqj), announcement)
+	return announcement, resp, errors.WithStack(err)
+}
+
+func (c *Client) ExpireAnnouncement(ctx context.Context, announcementID string) (*cstypes.Announcement, *http.Response, error) {
+	announcement := new(cstypes.Announcement)
+	resp, err := c.getParsedResponse(ctx, "PUT", fmt.Sprintf("/announcements/%s/expire", url.PathEscape(announcementID)), nil, jsonContent, nil, announcement)
+	return announcement, resp, errors.WithStack(err)
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"time"
+
+	stypes "agola.io/agola/services/types"
+
+	"github.com/gofrs/uuid"
+)
+
+type AnnouncementSeverity string
+
+const (
+	AnnouncementSeverityInfo     AnnouncementSeverity = "info"
+	AnnouncementSeverityWarning  AnnouncementSeverity = "warning"
+	AnnouncementSeverityCritical AnnouncementSeverity = "critical"
+)
+
+func IsValidAnnouncementSeverity(s AnnouncementSeverity) bool {
+	switch s {
+	case AnnouncementSeverityInfo, AnnouncementSeverityWarning, AnnouncementSeverityCritical:
+		return true
+	}
+	return false
+}
+
+const (
+	AnnouncementKind    = "announcement"
+	AnnouncementVersion = "v0.1.0"
+)
+
+// Announcement is a message broadcasted by the administrators to all the
+// users (i.e. to warn about a maintenance window)
+type Announcement struct {
+	stypes.TypeMeta
+	stypes.ObjectMeta
+
+	Message  string               `json:"message,omitempty"`
+	Severity AnnouncementSeverity `json:"severity,omitempty"`
+
+	// ExpireTime is the time after which the announcement isn't shown
+	// anymore. Nil means no expiration
+	ExpireTime *time.Time `json:"expire_time,omitempty"`
+}
+
+func NewAnnouncement() *Announcement {
+	return &Announcement{
+		TypeMeta: stypes.TypeMeta{
+			Kind:    AnnouncementKind,
+			Version: AnnouncementVersion,
+		},
+		ObjectMeta: stypes.ObjectMeta{
+			ID: uuid.Must(uuid.NewV4()).String(),
+		},
+	}
+}
+
+// IsExpired reports if the announcement is expired at the provided time
+func (a *Announcement) IsExpired(t time.Time) bool {
+	return a.ExpireTime != nil && !a.ExpireTime.After(t)
+}
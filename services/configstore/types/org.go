@@ -36,6 +36,17 @@ type Organization struct {
 	// CreatorUserID is the user id that created the organization. It could be empty
 	// if the org was created by using the admin user or the user has been removed.
 	CreatorUserID string `json:"creator_user_id,omitempty"`
+
+	// ConcurrencyLimits limits the concurrently running runs and tasks of all
+	// the organization projects. It can be set only by an admin
+	ConcurrencyLimits ConcurrencyLimits `json:"concurrency_limits"`
+}
+
+// ConcurrencyLimits defines the max number of concurrently running runs and
+// tasks. A zero value means no limit.
+type ConcurrencyLimits struct {
+	MaxRunningRuns  int `json:"max_running_runs,omitempty"`
+	MaxRunningTasks int `json:"max_running_tasks,omitempty"`
 }
 
 func NewOrganization() *Organization {
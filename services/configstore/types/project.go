@@ -15,6 +15,8 @@
 package types
 
 import (
+	"time"
+
 	stypes "agola.io/agola/services/types"
 
 	"github.com/gofrs/uuid"
@@ -86,6 +88,64 @@ type Project struct {
 	WebhookSecret string `json:"webhook_secret,omitempty"`
 
 	PassVarsToForkedPR bool `json:"pass_vars_to_forked_pr,omitempty"`
+
+	// ReportSkippedRuns enables the creation of a commit status for runs
+	// skipped due to a [ci skip] commit message or not matching when conditions
+	ReportSkippedRuns bool `json:"report_skipped_runs,omitempty"`
+
+	// Tags are free form labels used to filter and group the projects
+	Tags []string `json:"tags,omitempty"`
+
+	// PostPullRequestComments enables posting (and updating in place) a pull
+	// request comment with the run results summary
+	PostPullRequestComments bool `json:"post_pull_request_comments,omitempty"`
+
+	// UseDepsProxy configures the package managers of the project runs to use
+	// the dependencies proxy
+	UseDepsProxy bool `json:"use_deps_proxy,omitempty"`
+
+	// CancelSupersededRuns stops the running runs of a branch or pull request
+	// when a new run is created for a newer commit of the same ref
+	CancelSupersededRuns bool `json:"cancel_superseded_runs,omitempty"`
+
+	// RunsVisibility defines who can read the project runs results and
+	// LogsVisibility who can read the runs logs. When empty they follow the
+	// project visibility
+	RunsVisibility RunsVisibility `json:"runs_visibility,omitempty"`
+	LogsVisibility RunsVisibility `json:"logs_visibility,omitempty"`
+
+	// ProtectedBranches and ProtectedTags are the glob patterns of the
+	// project protected branches and tags. The protected variables values are
+	// provided only to the runs of the protected branches and tags
+	ProtectedBranches []string `json:"protected_branches,omitempty"`
+	ProtectedTags     []string `json:"protected_tags,omitempty"`
+
+	// AllowedUpstreamProjects are the ids or paths of the projects, of the
+	// same owner, whose runs can trigger runs in this project using the
+	// config triggers. When empty no project can trigger runs
+	AllowedUpstreamProjects []string `json:"allowed_upstream_projects,omitempty"`
+
+	// Schedules are the cron schedules creating periodic runs on the project
+	// branches
+	Schedules []*ProjectSchedule `json:"schedules,omitempty"`
+
+	// ConcurrencyLimits limits the concurrently running runs and tasks of the
+	// project. The organization limits, if any, still apply
+	ConcurrencyLimits ConcurrencyLimits `json:"concurrency_limits"`
+
+	// DeletionTime is set when the project is deleted. A deleted project is
+	// hidden and permanently removed, with its runs, after the deletion
+	// grace period
+	DeletionTime *time.Time `json:"deletion_time,omitempty"`
+}
+
+// ProjectSchedule creates a run on the project branch at every activation of
+// the cron expression
+type ProjectSchedule struct {
+	Name string `json:"name,omitempty"`
+	// Cron is a standard five fields cron expression evaluated in UTC
+	Cron   string `json:"cron,omitempty"`
+	Branch string `json:"branch,omitempty"`
 }
 
 func NewProject() *Project {
@@ -99,3 +159,32 @@ func NewProject() *Project {
 		},
 	}
 }
+
+const (
+	ProjectPolledRefsKind    = "projectpolledrefs"
+	ProjectPolledRefsVersion = "v0.1.0"
+)
+
+// ProjectPolledRefs contains the refs of a project using a plain git remote
+// source seen by the last poll
+type ProjectPolledRefs struct {
+	stypes.TypeMeta
+	stypes.ObjectMeta
+
+	ProjectID string `json:"project_id,omitempty"`
+
+	// Refs contains the commit sha of every polled ref (ref -> commit sha)
+	Refs map[string]string `json:"refs,omitempty"`
+}
+
+func NewProjectPolledRefs() *ProjectPolledRefs {
+	return &ProjectPolledRefs{
+		TypeMeta: stypes.TypeMeta{
+			Kind:    ProjectPolledRefsKind,
+			Version: ProjectPolledRefsVersion,
+		},
+		ObjectMeta: stypes.ObjectMeta{
+			ID: uuid.Must(uuid.NewV4()).String(),
+		},
+	}
+}
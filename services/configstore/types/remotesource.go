@@ -30,6 +30,9 @@ const (
 	RemoteSourceTypeGitea  RemoteSourceType = "gitea"
 	RemoteSourceTypeGithub RemoteSourceType = "github"
 	RemoteSourceTypeGitlab RemoteSourceType = "gitlab"
+	// RemoteSourceTypeGit is a plain git server without any api. New refs are
+	// detected by polling the remote repository
+	RemoteSourceTypeGit RemoteSourceType = "git"
 )
 
 type RemoteSourceAuthType string
@@ -64,6 +67,20 @@ type RemoteSource struct {
 
 	SkipSSHHostKeyCheck bool `json:"skip_ssh_host_key_check,omitempty"`
 
+	// SSHEndpoint, when defined, overrides the host and port (in the host[:port]
+	// format) of the repositories ssh clone urls reported by the remote source.
+	// It's useful when the git server ssh daemon is reachable on a different
+	// host or port than the one reported (i.e. gitea with a non standard ssh
+	// port or behind a proxy)
+	SSHEndpoint string `json:"ssh_endpoint,omitempty"`
+
+	// OrgWebhooks defines if a single organization level webhook should be
+	// registered on the remote source for every remote organization
+	// instead of a webhook for every project repository
+	OrgWebhooks bool `json:"org_webhooks,omitempty"`
+	// WebhookSecret is the secret used to sign the organization level webhooks
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+
 	RegistrationEnabled *bool `json:"registration_enabled,omitempty"`
 	LoginEnabled        *bool `json:"login_enabled,omitempty"`
 }
@@ -107,6 +124,8 @@ func SourceSupportedAuthTypes(rsType RemoteSourceType) []RemoteSourceAuthType {
 		fallthrough
 	case RemoteSourceTypeGitlab:
 		return []RemoteSourceAuthType{RemoteSourceAuthTypeOauth2}
+	case RemoteSourceTypeGit:
+		return []RemoteSourceAuthType{RemoteSourceAuthTypePassword}
 
 	default:
 		panic(errors.Errorf("unsupported remote source type: %q", rsType))
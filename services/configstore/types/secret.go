@@ -52,6 +52,9 @@ type Secret struct {
 
 	// internal secret
 	Data map[string]string `json:"data,omitempty"`
+	// Sealed reports that the internal secret data values are sealed client
+	// side and can only be decrypted by the runservice
+	Sealed bool `json:"sealed,omitempty"`
 
 	// external secret
 	SecretProviderID string `json:"secret_provider_id,omitempty"`
@@ -44,6 +44,35 @@ func IsValidVisibility(v Visibility) bool {
 	return true
 }
 
+// RunsVisibility defines who can read the project runs results or the runs
+// logs
+type RunsVisibility string
+
+const (
+	// RunsVisibilityProject uses the project visibility: everyone for public
+	// projects, the project members for private projects
+	RunsVisibilityProject RunsVisibility = ""
+	// RunsVisibilityOwners restricts the read to the project owners
+	RunsVisibilityOwners RunsVisibility = "owners"
+	// RunsVisibilityMembers allows the read to the project members. For
+	// organization projects they are all the organization members
+	RunsVisibilityMembers RunsVisibility = "members"
+	// RunsVisibilityPublic allows the read to everyone
+	RunsVisibilityPublic RunsVisibility = "public"
+)
+
+func IsValidRunsVisibility(v RunsVisibility) bool {
+	switch v {
+	case RunsVisibilityProject:
+	case RunsVisibilityOwners:
+	case RunsVisibilityMembers:
+	case RunsVisibilityPublic:
+	default:
+		return false
+	}
+	return true
+}
+
 type MemberRole string
 
 const (
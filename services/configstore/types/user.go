@@ -15,6 +15,7 @@
 package types
 
 import (
+	"encoding/json"
 	"time"
 
 	stypes "agola.io/agola/services/types"
@@ -39,6 +40,11 @@ type User struct {
 
 	// Admin defines if the user is a global admin
 	Admin bool `json:"admin,omitempty"`
+
+	// DisplayName and AvatarURL are the user profile synced from the last
+	// created or updated user linked account
+	DisplayName string `json:"display_name,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
 }
 
 func NewUser() *User {
@@ -66,6 +72,10 @@ type UserToken struct {
 	Value string `json:"value,omitempty"`
 
 	UserID string `json:"user_id,omitempty"`
+
+	// LastUsedTime is the last time the token has been used to authenticate.
+	// It's updated with a coarse granularity to avoid a write at every request
+	LastUsedTime time.Time `json:"last_used_time,omitempty"`
 }
 
 func NewUserToken() *UserToken {
@@ -91,9 +101,10 @@ type LinkedAccount struct {
 
 	UserID string `json:"user_id,omitempty"`
 
-	RemoteUserID        string `json:"remote_user_id,omitempty"`
-	RemoteUserName      string `json:"remote_username,omitempty"`
-	RemoteUserAvatarURL string `json:"remote_user_avatar_url,omitempty"`
+	RemoteUserID          string `json:"remote_user_id,omitempty"`
+	RemoteUserName        string `json:"remote_username,omitempty"`
+	RemoteUserDisplayName string `json:"remote_user_display_name,omitempty"`
+	RemoteUserAvatarURL   string `json:"remote_user_avatar_url,omitempty"`
 
 	RemoteSourceID string `json:"remote_source_id,omitempty"`
 
@@ -115,3 +126,41 @@ func NewLinkedAccount() *LinkedAccount {
 		},
 	}
 }
+
+const (
+	UserPreferencesKind    = "userpreferences"
+	UserPreferencesVersion = "v0.1.0"
+)
+
+// UserPreferences contains the user settings that must be shared between
+// different clients (web ui, cli)
+type UserPreferences struct {
+	stypes.TypeMeta
+	stypes.ObjectMeta
+
+	UserID string `json:"user_id,omitempty"`
+
+	// DefaultOrgID is the id of the organization to land on
+	DefaultOrgID string `json:"default_org_id,omitempty"`
+	// DefaultProjectID is the id of the project to land on and used by the
+	// cli when no project is provided
+	DefaultProjectID string `json:"default_project_id,omitempty"`
+
+	Timezone   string `json:"timezone,omitempty"`
+	DateFormat string `json:"date_format,omitempty"`
+
+	// UISettings is an opaque json object managed by the web ui
+	UISettings json.RawMessage `json:"ui_settings,omitempty"`
+}
+
+func NewUserPreferences() *UserPreferences {
+	return &UserPreferences{
+		TypeMeta: stypes.TypeMeta{
+			Kind:    UserPreferencesKind,
+			Version: UserPreferencesVersion,
+		},
+		ObjectMeta: stypes.ObjectMeta{
+			ID: uuid.Must(uuid.NewV4()).String(),
+		},
+	}
+}
@@ -25,6 +25,10 @@ type VariableValue struct {
 	SecretVar  string `json:"secret_var,omitempty"`
 
 	When *stypes.When `json:"when,omitempty"`
+
+	// Protected values are used only by the runs of the project protected
+	// branches and tags
+	Protected bool `json:"protected,omitempty"`
 }
 
 const (
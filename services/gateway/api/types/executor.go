@@ -0,0 +1,53 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"time"
+)
+
+type ExecutorResponse struct {
+	ExecutorID       string            `json:"executor_id"`
+	ListenURL        string            `json:"listen_url"`
+	Archs            []string          `json:"archs"`
+	Labels           map[string]string `json:"labels"`
+	ActiveTasksLimit int               `json:"active_tasks_limit"`
+	ActiveTasks      int               `json:"active_tasks"`
+	Draining         bool              `json:"draining"`
+	State            string            `json:"state"`
+	LastUpdateTime   time.Time         `json:"last_update_time"`
+	Health           *ExecutorHealth   `json:"health,omitempty"`
+	HealthProblems   []string          `json:"health_problems"`
+}
+
+type ExecutorHealth struct {
+	DiskTotal  uint64  `json:"disk_total"`
+	DiskFree   uint64  `json:"disk_free"`
+	ImagesSize int64   `json:"images_size"`
+	Load1      float64 `json:"load1"`
+	CPUs       int     `json:"cpus"`
+}
+
+type ExecutorActionType string
+
+const (
+	ExecutorActionTypeDrain   ExecutorActionType = "drain"
+	ExecutorActionTypeApprove ExecutorActionType = "approve"
+	ExecutorActionTypeBan     ExecutorActionType = "ban"
+)
+
+type ExecutorActionsRequest struct {
+	ActionType ExecutorActionType `json:"action_type"`
+}
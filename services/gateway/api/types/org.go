@@ -14,6 +14,8 @@
 
 package types
 
+import "time"
+
 type MemberRole string
 
 const (
@@ -26,10 +28,22 @@ type CreateOrgRequest struct {
 	Visibility Visibility `json:"visibility"`
 }
 
+type UpdateOrgRequest struct {
+	ConcurrencyLimits ConcurrencyLimits `json:"concurrency_limits"`
+}
+
+// ConcurrencyLimits defines the max number of concurrently running runs and
+// tasks. A zero value means no limit.
+type ConcurrencyLimits struct {
+	MaxRunningRuns  int `json:"max_running_runs"`
+	MaxRunningTasks int `json:"max_running_tasks"`
+}
+
 type OrgResponse struct {
-	ID         string     `json:"id"`
-	Name       string     `json:"name"`
-	Visibility Visibility `json:"visibility,omitempty"`
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	Visibility        Visibility        `json:"visibility,omitempty"`
+	ConcurrencyLimits ConcurrencyLimits `json:"concurrency_limits"`
 }
 
 type OrgMembersResponse struct {
@@ -50,3 +64,17 @@ type AddOrgMemberResponse struct {
 type AddOrgMemberRequest struct {
 	Role MemberRole `json:"role"`
 }
+
+type OrgUsageResponse struct {
+	Organization *OrgResponse            `json:"organization"`
+	StartTime    time.Time               `json:"start_time"`
+	EndTime      time.Time               `json:"end_time"`
+	Projects     []*ProjectUsageResponse `json:"projects"`
+}
+
+type ProjectUsageResponse struct {
+	ID          string  `json:"id"`
+	Path        string  `json:"path"`
+	Runs        uint64  `json:"runs"`
+	TaskMinutes float64 `json:"task_minutes"`
+}
@@ -14,31 +14,117 @@
 
 package types
 
+import (
+	"time"
+)
+
 type CreateProjectRequest struct {
-	Name                string     `json:"name,omitempty"`
-	ParentRef           string     `json:"parent_ref,omitempty"`
-	Visibility          Visibility `json:"visibility,omitempty"`
-	RepoPath            string     `json:"repo_path,omitempty"`
+	Name                    string             `json:"name,omitempty"`
+	ParentRef               string             `json:"parent_ref,omitempty"`
+	Visibility              Visibility         `json:"visibility,omitempty"`
+	RepoPath                string             `json:"repo_path,omitempty"`
+	RemoteSourceName        string             `json:"remote_source_name,omitempty"`
+	SkipSSHHostKeyCheck     bool               `json:"skip_ssh_host_key_check,omitempty"`
+	PassVarsToForkedPR      bool               `json:"pass_vars_to_forked_pr,omitempty"`
+	ReportSkippedRuns       bool               `json:"report_skipped_runs,omitempty"`
+	Tags                    []string           `json:"tags,omitempty"`
+	PostPullRequestComments bool               `json:"post_pull_request_comments,omitempty"`
+	UseDepsProxy            bool               `json:"use_deps_proxy,omitempty"`
+	CancelSupersededRuns    bool               `json:"cancel_superseded_runs,omitempty"`
+	RunsVisibility          RunsVisibility     `json:"runs_visibility,omitempty"`
+	LogsVisibility          RunsVisibility     `json:"logs_visibility,omitempty"`
+	ProtectedBranches       []string           `json:"protected_branches,omitempty"`
+	ProtectedTags           []string           `json:"protected_tags,omitempty"`
+	AllowedUpstreamProjects []string           `json:"allowed_upstream_projects,omitempty"`
+	Schedules               []*ProjectSchedule `json:"schedules,omitempty"`
+	ConcurrencyLimits       ConcurrencyLimits  `json:"concurrency_limits"`
+	ImportRepoTopics        bool               `json:"import_repo_topics,omitempty"`
+}
+
+type UpdateProjectRequest struct {
+	Name                    *string             `json:"name,omitempty"`
+	ParentRef               *string             `json:"parent_ref,omitempty"`
+	Visibility              *Visibility         `json:"visibility,omitempty"`
+	PassVarsToForkedPR      *bool               `json:"pass_vars_to_forked_pr,omitempty"`
+	ReportSkippedRuns       *bool               `json:"report_skipped_runs,omitempty"`
+	Tags                    *[]string           `json:"tags,omitempty"`
+	PostPullRequestComments *bool               `json:"post_pull_request_comments,omitempty"`
+	UseDepsProxy            *bool               `json:"use_deps_proxy,omitempty"`
+	CancelSupersededRuns    *bool               `json:"cancel_superseded_runs,omitempty"`
+	RunsVisibility          *RunsVisibility     `json:"runs_visibility,omitempty"`
+	LogsVisibility          *RunsVisibility     `json:"logs_visibility,omitempty"`
+	ProtectedBranches       *[]string           `json:"protected_branches,omitempty"`
+	ProtectedTags           *[]string           `json:"protected_tags,omitempty"`
+	AllowedUpstreamProjects *[]string           `json:"allowed_upstream_projects,omitempty"`
+	Schedules               *[]*ProjectSchedule `json:"schedules,omitempty"`
+	ConcurrencyLimits       *ConcurrencyLimits  `json:"concurrency_limits,omitempty"`
+	ImportRepoTopics        bool                `json:"import_repo_topics,omitempty"`
+}
+
+type CloneProjectRequest struct {
+	Name             string `json:"name,omitempty"`
+	ParentRef        string `json:"parent_ref,omitempty"`
+	RemoteSourceName string `json:"remote_source_name,omitempty"`
+	RepoPath         string `json:"repo_path,omitempty"`
+	CloneSecrets     bool   `json:"clone_secrets,omitempty"`
+	CloneSecretsData bool   `json:"clone_secrets_data,omitempty"`
+}
+
+type ImportProjectsRequest struct {
 	RemoteSourceName    string     `json:"remote_source_name,omitempty"`
+	RemoteOrg           string     `json:"remote_org,omitempty"`
+	Include             []string   `json:"include,omitempty"`
+	Exclude             []string   `json:"exclude,omitempty"`
+	DryRun              bool       `json:"dry_run,omitempty"`
+	Concurrency         int        `json:"concurrency,omitempty"`
+	Visibility          Visibility `json:"visibility,omitempty"`
 	SkipSSHHostKeyCheck bool       `json:"skip_ssh_host_key_check,omitempty"`
-	PassVarsToForkedPR  bool       `json:"pass_vars_to_forked_pr,omitempty"`
+	ImportRepoTopics    bool       `json:"import_repo_topics,omitempty"`
 }
 
-type UpdateProjectRequest struct {
-	Name               *string     `json:"name,omitempty"`
-	ParentRef          *string     `json:"parent_ref,omitempty"`
-	Visibility         *Visibility `json:"visibility,omitempty"`
-	PassVarsToForkedPR *bool       `json:"pass_vars_to_forked_pr,omitempty"`
+type ImportProjectStatus string
+
+const (
+	ImportProjectStatusCreated  ImportProjectStatus = "created"
+	ImportProjectStatusToCreate ImportProjectStatus = "tocreate"
+	ImportProjectStatusExists   ImportProjectStatus = "exists"
+	ImportProjectStatusFailed   ImportProjectStatus = "failed"
+)
+
+type ImportProjectResponse struct {
+	RepoPath    string              `json:"repo_path"`
+	ProjectName string              `json:"project_name"`
+	ProjectID   string              `json:"project_id,omitempty"`
+	Status      ImportProjectStatus `json:"status"`
+	Error       string              `json:"error,omitempty"`
 }
 
 type ProjectResponse struct {
-	ID                 string     `json:"id,omitempty"`
-	Name               string     `json:"name,omitempty"`
-	Path               string     `json:"path,omitempty"`
-	ParentPath         string     `json:"parent_path,omitempty"`
-	Visibility         Visibility `json:"visibility,omitempty"`
-	GlobalVisibility   string     `json:"global_visibility,omitempty"`
-	PassVarsToForkedPR bool       `json:"pass_vars_to_forked_pr,omitempty"`
+	ID                      string             `json:"id,omitempty"`
+	Name                    string             `json:"name,omitempty"`
+	Path                    string             `json:"path,omitempty"`
+	ParentPath              string             `json:"parent_path,omitempty"`
+	Visibility              Visibility         `json:"visibility,omitempty"`
+	GlobalVisibility        string             `json:"global_visibility,omitempty"`
+	PassVarsToForkedPR      bool               `json:"pass_vars_to_forked_pr,omitempty"`
+	ReportSkippedRuns       bool               `json:"report_skipped_runs,omitempty"`
+	Tags                    []string           `json:"tags,omitempty"`
+	PostPullRequestComments bool               `json:"post_pull_request_comments,omitempty"`
+	UseDepsProxy            bool               `json:"use_deps_proxy,omitempty"`
+	CancelSupersededRuns    bool               `json:"cancel_superseded_runs,omitempty"`
+	RunsVisibility          RunsVisibility     `json:"runs_visibility,omitempty"`
+	LogsVisibility          RunsVisibility     `json:"logs_visibility,omitempty"`
+	ProtectedBranches       []string           `json:"protected_branches,omitempty"`
+	ProtectedTags           []string           `json:"protected_tags,omitempty"`
+	AllowedUpstreamProjects []string           `json:"allowed_upstream_projects,omitempty"`
+	Schedules               []*ProjectSchedule `json:"schedules,omitempty"`
+	ConcurrencyLimits       ConcurrencyLimits  `json:"concurrency_limits"`
+}
+
+type ProjectSchedule struct {
+	Name   string `json:"name,omitempty"`
+	Cron   string `json:"cron,omitempty"`
+	Branch string `json:"branch,omitempty"`
 }
 
 type ProjectCreateRunRequest struct {
@@ -46,4 +132,24 @@ type ProjectCreateRunRequest struct {
 	Tag       string `json:"tag,omitempty"`
 	Ref       string `json:"ref,omitempty"`
 	CommitSHA string `json:"commit_sha,omitempty"`
+	// Variables override the project variables with the same name
+	Variables map[string]string `json:"variables,omitempty"`
+	// Inputs are the values of the config inputs
+	Inputs map[string]string `json:"inputs,omitempty"`
+}
+
+type ProjectCacheResponse struct {
+	Key      string    `json:"key"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// ProjectCachesResponse reports the project caches usage
+type ProjectCachesResponse struct {
+	// Size is the size in bytes of all the project caches
+	Size int64 `json:"size"`
+	// Quota is the max size of the project caches. When exceeded the least
+	// recently used caches are removed. 0 means no quota
+	Quota  int64                   `json:"quota"`
+	Caches []*ProjectCacheResponse `json:"caches"`
 }
@@ -14,6 +14,8 @@
 
 package types
 
+import "time"
+
 type CreateRemoteSourceRequest struct {
 	Name                string `json:"name"`
 	APIURL              string `json:"apiurl"`
@@ -24,6 +26,8 @@ type CreateRemoteSourceRequest struct {
 	Oauth2ClientSecret  string `json:"oauth_2_client_secret"`
 	SSHHostKey          string `json:"ssh_host_key"`
 	SkipSSHHostKeyCheck bool   `json:"skip_ssh_host_key_check"`
+	SSHEndpoint         string `json:"ssh_endpoint"`
+	OrgWebhooks         bool   `json:"org_webhooks"`
 	RegistrationEnabled *bool  `json:"registration_enabled"`
 	LoginEnabled        *bool  `json:"login_enabled"`
 }
@@ -36,6 +40,8 @@ type UpdateRemoteSourceRequest struct {
 	Oauth2ClientSecret  *string `json:"oauth_2_client_secret"`
 	SSHHostKey          *string `json:"ssh_host_key"`
 	SkipSSHHostKeyCheck *bool   `json:"skip_ssh_host_key_check"`
+	SSHEndpoint         *string `json:"ssh_endpoint"`
+	OrgWebhooks         *bool   `json:"org_webhooks"`
 	RegistrationEnabled *bool   `json:"registration_enabled"`
 	LoginEnabled        *bool   `json:"login_enabled"`
 }
@@ -46,4 +52,18 @@ type RemoteSourceResponse struct {
 	AuthType            string `json:"auth_type"`
 	RegistrationEnabled bool   `json:"registration_enabled"`
 	LoginEnabled        bool   `json:"login_enabled"`
+	OrgWebhooks         bool   `json:"org_webhooks"`
+	SSHEndpoint         string `json:"ssh_endpoint"`
+}
+
+type RemoteSourceRateLimitResponse struct {
+	// Limit is the max number of api requests in the rate limit window. 0 if
+	// not reported by the remote source
+	Limit int `json:"limit"`
+	// Remaining is the number of remaining api requests in the rate limit
+	// window. -1 if not reported by the remote source
+	Remaining           int       `json:"remaining"`
+	Reset               time.Time `json:"reset"`
+	ActiveRequests      int       `json:"active_requests"`
+	RateLimitedRequests int64     `json:"rate_limited_requests"`
 }
@@ -27,11 +27,14 @@ import (
 // sequential id generator).
 
 type RunsResponse struct {
-	Number      uint64            `json:"number"`
-	Name        string            `json:"name"`
-	Annotations map[string]string `json:"annotations"`
-	Phase       rstypes.RunPhase  `json:"phase"`
-	Result      rstypes.RunResult `json:"result"`
+	Number      uint64             `json:"number"`
+	Name        string             `json:"name"`
+	Annotations map[string]string  `json:"annotations"`
+	Meta        map[string]string  `json:"meta"`
+	Metrics     map[string]float64 `json:"metrics"`
+	Labels      map[string]string  `json:"labels"`
+	Phase       rstypes.RunPhase   `json:"phase"`
+	Result      rstypes.RunResult  `json:"result"`
 
 	TasksWaitingApproval []string `json:"tasks_waiting_approval"`
 
@@ -41,13 +44,16 @@ type RunsResponse struct {
 }
 
 type RunResponse struct {
-	Number      uint64            `json:"number"`
-	Name        string            `json:"name"`
-	Annotations map[string]string `json:"annotations"`
-	Phase       rstypes.RunPhase  `json:"phase"`
-	Result      rstypes.RunResult `json:"result"`
-	SetupErrors []string          `json:"setup_errors"`
-	Stopping    bool              `json:"stopping"`
+	Number      uint64             `json:"number"`
+	Name        string             `json:"name"`
+	Annotations map[string]string  `json:"annotations"`
+	Meta        map[string]string  `json:"meta"`
+	Metrics     map[string]float64 `json:"metrics"`
+	Labels      map[string]string  `json:"labels"`
+	Phase       rstypes.RunPhase   `json:"phase"`
+	Result      rstypes.RunResult  `json:"result"`
+	SetupErrors []string           `json:"setup_errors"`
+	Stopping    bool               `json:"stopping"`
 
 	Tasks                map[string]*RunResponseTask `json:"tasks"`
 	TasksWaitingApproval []string                    `json:"tasks_waiting_approval"`
@@ -66,11 +72,22 @@ type RunResponseTask struct {
 	Status  rstypes.RunTaskStatus                   `json:"status"`
 	Level   int                                     `json:"level"`
 	Depends map[string]*rstypes.RunConfigTaskDepend `json:"depends"`
+	Labels  map[string]string                       `json:"labels"`
+
+	// AllowFailure reports if the task failure doesn't fail the run
+	AllowFailure bool `json:"allow_failure,omitempty"`
 
 	WaitingApproval     bool              `json:"waiting_approval"`
 	Approved            bool              `json:"approved"`
 	ApprovalAnnotations map[string]string `json:"approval_annotations"`
 
+	// FailError is the reason of the task failure when not caused by a step
+	// exit code (i.e. a task or step timeout)
+	FailError string `json:"fail_error,omitempty"`
+
+	// Attempt is the current execution attempt of a task with retries
+	Attempt int `json:"attempt,omitempty"`
+
 	StartTime *time.Time `json:"start_time"`
 	EndTime   *time.Time `json:"end_time"`
 }
@@ -79,18 +96,46 @@ type RunTaskResponse struct {
 	ID     string                `json:"id"`
 	Name   string                `json:"name"`
 	Status rstypes.RunTaskStatus `json:"status"`
+	Labels map[string]string     `json:"labels"`
 
 	WaitingApproval     bool              `json:"waiting_approval"`
 	Approved            bool              `json:"approved"`
 	ApprovalAnnotations map[string]string `json:"approval_annotations"`
 
+	// Approvals are the approvals received by the task
+	Approvals []*RunTaskApprovalResponse `json:"approvals"`
+	// RequiredApprovals is the number of approvals required to approve the
+	// task
+	RequiredApprovals int `json:"required_approvals,omitempty"`
+	// ApprovalUsers and ApprovalOrgRoles are the user names and the project
+	// organization roles allowed to approve the task. When empty the project
+	// owners can approve it
+	ApprovalUsers    []string `json:"approval_users,omitempty"`
+	ApprovalOrgRoles []string `json:"approval_org_roles,omitempty"`
+
 	SetupStep *RunTaskResponseSetupStep `json:"setup_step"`
 	Steps     []*RunTaskResponseStep    `json:"steps"`
 
+	ResourceUsage *RunTaskResponseResourceUsage `json:"resource_usage,omitempty"`
+
+	// FailError is the reason of the task failure when not caused by a step
+	// exit code (i.e. a task or step timeout)
+	FailError string `json:"fail_error,omitempty"`
+
+	// Attempt is the current execution attempt of a task with retries
+	Attempt int `json:"attempt,omitempty"`
+
 	StartTime *time.Time `json:"start_time"`
 	EndTime   *time.Time `json:"end_time"`
 }
 
+type RunTaskApprovalResponse struct {
+	UserID   string    `json:"user_id"`
+	UserName string    `json:"user_name"`
+	Comment  string    `json:"comment,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
 type RunTaskResponseSetupStep struct {
 	Phase rstypes.ExecutorTaskPhase `json:"phase"`
 	Name  string                    `json:"name"`
@@ -112,6 +157,83 @@ type RunTaskResponseStep struct {
 	EndTime   *time.Time `json:"end_time"`
 
 	LogArchived bool `json:"log_archived"`
+
+	// Transfer contains the stats of the data transferred by steps that
+	// restore or save workspaces and caches
+	Transfer *RunTaskResponseStepTransfer `json:"transfer,omitempty"`
+}
+
+type RunTaskResponseStepTransfer struct {
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+}
+
+// RunTaskResponseResourceUsage is the resource usage of the task containers.
+// CPU values are in millicores, memory and disk values in bytes.
+type RunTaskResponseResourceUsage struct {
+	CPUTime     time.Duration `json:"cpu_time"`
+	MaxCPU      int64         `json:"max_cpu"`
+	Memory      int64         `json:"memory"`
+	MaxMemory   int64         `json:"max_memory"`
+	MemoryLimit int64         `json:"memory_limit"`
+	Disk        int64         `json:"disk"`
+}
+
+type LogsRangeUnit string
+
+const (
+	LogsRangeUnitLine LogsRangeUnit = "line"
+	LogsRangeUnitByte LogsRangeUnit = "byte"
+)
+
+// LogsRange defines the part of a log to return. Offset and Limit are
+// expressed in lines or bytes based on Unit. A zero Limit means no limit.
+type LogsRange struct {
+	Unit   LogsRangeUnit
+	Offset int64
+	Limit  int64
+}
+
+type LogsInfoResponse struct {
+	Size     int64 `json:"size"`
+	Lines    int64 `json:"lines"`
+	Complete bool  `json:"complete"`
+}
+
+type RunArtifactResponse struct {
+	TaskID   string `json:"task_id"`
+	TaskName string `json:"task_name"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+}
+
+type RunArtifactsResponse struct {
+	Artifacts []*RunArtifactResponse `json:"artifacts"`
+}
+
+type RunTestReportResponse struct {
+	TaskID   string        `json:"task_id"`
+	TaskName string        `json:"task_name"`
+	Tests    int           `json:"tests"`
+	Passed   int           `json:"passed"`
+	Failures int           `json:"failures"`
+	Errors   int           `json:"errors"`
+	Skipped  int           `json:"skipped"`
+	Duration time.Duration `json:"duration"`
+
+	FailedTests []*TestCaseFailureResponse `json:"failed_tests"`
+}
+
+type TestCaseFailureResponse struct {
+	Suite     string `json:"suite"`
+	ClassName string `json:"class_name"`
+	Name      string `json:"name"`
+	Error     bool   `json:"error"`
+	Message   string `json:"message"`
+}
+
+type RunTestReportsResponse struct {
+	TestReports []*RunTestReportResponse `json:"test_reports"`
 }
 
 type RunActionType string
@@ -120,6 +242,8 @@ const (
 	RunActionTypeRestart RunActionType = "restart"
 	RunActionTypeCancel  RunActionType = "cancel"
 	RunActionTypeStop    RunActionType = "stop"
+
+	RunActionTypeRerunWithConfig RunActionType = "rerunwithconfig"
 )
 
 type RunActionsRequest struct {
@@ -127,6 +251,9 @@ type RunActionsRequest struct {
 
 	// Restart
 	FromStart bool `json:"from_start"`
+
+	// RerunWithConfig
+	ConfigRef string `json:"config_ref"`
 }
 
 type RunTaskActionType string
@@ -137,4 +264,15 @@ const (
 
 type RunTaskActionsRequest struct {
 	ActionType RunTaskActionType `json:"action_type"`
+	// Comment is an optional comment saved with the approval
+	Comment string `json:"comment,omitempty"`
+}
+
+// MetricValueResponse is the value of a metric emitted by a run
+type MetricValueResponse struct {
+	RunNumber uint64            `json:"run_number"`
+	Branch    string            `json:"branch"`
+	Result    rstypes.RunResult `json:"result"`
+	Time      *time.Time        `json:"time"`
+	Value     float64           `json:"value"`
 }
@@ -25,6 +25,13 @@ type SecretResponse struct {
 	ID         string `json:"id"`
 	Name       string `json:"name"`
 	ParentPath string `json:"parent_path"`
+	Sealed     bool   `json:"sealed,omitempty"`
+}
+
+type SealedSecretsPublicKeyResponse struct {
+	// PublicKey is the base64 encoded public key used to seal the secrets
+	// data values
+	PublicKey string `json:"public_key"`
 }
 
 type CreateSecretRequest struct {
@@ -34,6 +41,9 @@ type CreateSecretRequest struct {
 
 	// internal secret
 	Data map[string]string `json:"data,omitempty"`
+	// Sealed reports that the data values are sealed with the sealed secrets
+	// public key
+	Sealed bool `json:"sealed,omitempty"`
 
 	// external secret
 	SecretProviderID string `json:"secret_provider_id,omitempty"`
@@ -47,6 +57,9 @@ type UpdateSecretRequest struct {
 
 	// internal secret
 	Data map[string]string `json:"data,omitempty"`
+	// Sealed reports that the data values are sealed with the sealed secrets
+	// public key
+	Sealed bool `json:"sealed,omitempty"`
 
 	// external secret
 	SecretProviderID string `json:"secret_provider_id,omitempty"`
@@ -14,12 +14,18 @@
 
 package types
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type LinkedAccount struct {
 	ID string `json:"id,omitempty"`
 
-	RemoteUserID        string `json:"remote_user_id,omitempty"`
-	RemoteUserName      string `json:"remote_username,omitempty"`
-	RemoteUserAvatarURL string `json:"remote_user_avatar_url,omitempty"`
+	RemoteUserID          string `json:"remote_user_id,omitempty"`
+	RemoteUserName        string `json:"remote_username,omitempty"`
+	RemoteUserDisplayName string `json:"remote_user_display_name,omitempty"`
+	RemoteUserAvatarURL   string `json:"remote_user_avatar_url,omitempty"`
 
 	RemoteSourceID string `json:"remote_source_id,omitempty"`
 }
@@ -36,15 +42,18 @@ type PrivateUserResponse struct {
 }
 
 type UserResponse struct {
-	ID       string `json:"id"`
-	UserName string `json:"username"`
+	ID          string `json:"id"`
+	UserName    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
 }
 
 type LinkedAccountResponse struct {
-	ID                  string `json:"id"`
-	RemoteSourceID      string `json:"remote_source_id"`
-	RemoteUserName      string `json:"remote_user_name"`
-	RemoteUserAvatarURL string `json:"remote_user_avatar_url"`
+	ID                    string `json:"id"`
+	RemoteSourceID        string `json:"remote_source_id"`
+	RemoteUserName        string `json:"remote_user_name"`
+	RemoteUserDisplayName string `json:"remote_user_display_name"`
+	RemoteUserAvatarURL   string `json:"remote_user_avatar_url"`
 }
 
 type CreateUserLARequest struct {
@@ -66,6 +75,28 @@ type CreateUserTokenResponse struct {
 	Token string `json:"token"`
 }
 
+type UserTokenResponse struct {
+	Name         string     `json:"name"`
+	CreationTime time.Time  `json:"creation_time"`
+	LastUsedTime *time.Time `json:"last_used_time"`
+}
+
+type UpdateUserPreferencesRequest struct {
+	DefaultOrgRef     string          `json:"default_org_ref"`
+	DefaultProjectRef string          `json:"default_project_ref"`
+	Timezone          string          `json:"timezone"`
+	DateFormat        string          `json:"date_format"`
+	UISettings        json.RawMessage `json:"ui_settings"`
+}
+
+type UserPreferencesResponse struct {
+	DefaultOrgID     string          `json:"default_org_id"`
+	DefaultProjectID string          `json:"default_project_id"`
+	Timezone         string          `json:"timezone"`
+	DateFormat       string          `json:"date_format"`
+	UISettings       json.RawMessage `json:"ui_settings"`
+}
+
 type RegisterUserRequest struct {
 	CreateUserRequest
 	CreateUserLARequest
@@ -110,6 +141,14 @@ type UserCreateRunRequest struct {
 
 	PullRequestRefRegexes []string          `json:"pull_request_ref_regexes,omitempty"`
 	Variables             map[string]string `json:"variables,omitempty"`
+
+	RunNames  []string `json:"run_names,omitempty"`
+	TaskNames []string `json:"task_names,omitempty"`
+
+	// ChangedFiles are the files changed by the pushed commit, used to match
+	// the when paths conditions. Nil when unknown. Not omitted when empty
+	// since no changed files differs from unknown changed files
+	ChangedFiles []string `json:"changed_files"`
 }
 
 type UserOrgsResponse struct {
@@ -22,7 +22,8 @@ type VariableValueRequest struct {
 	SecretName string `json:"secret_name"`
 	SecretVar  string `json:"secret_var"`
 
-	When *types.When `json:"when"`
+	When      *types.When `json:"when"`
+	Protected bool        `json:"protected"`
 }
 
 type VariableValue struct {
@@ -30,7 +31,8 @@ type VariableValue struct {
 	SecretVar                string `json:"secret_var"`
 	MatchingSecretParentPath string `json:"matching_secret_parent_path"`
 
-	When *types.When `json:"when"`
+	When      *types.When `json:"when"`
+	Protected bool        `json:"protected"`
 }
 
 type VariableResponse struct {
@@ -40,6 +42,16 @@ type VariableResponse struct {
 	ParentPath string          `json:"parent_path"`
 }
 
+// ProjectVariablesReportResponse contains the variables and secrets resolved
+// by a project. Every variable value reports the parent path of the matching
+// secret.
+type ProjectVariablesReportResponse struct {
+	ProjectID   string              `json:"project_id"`
+	ProjectPath string              `json:"project_path"`
+	Variables   []*VariableResponse `json:"variables"`
+	Secrets     []*SecretResponse   `json:"secrets"`
+}
+
 type CreateVariableRequest struct {
 	Name string `json:"name,omitempty"`
 
@@ -20,3 +20,12 @@ const (
 	VisibilityPublic  Visibility = "public"
 	VisibilityPrivate Visibility = "private"
 )
+
+type RunsVisibility string
+
+const (
+	RunsVisibilityProject RunsVisibility = ""
+	RunsVisibilityOwners  RunsVisibility = "owners"
+	RunsVisibilityMembers RunsVisibility = "members"
+	RunsVisibilityPublic  RunsVisibility = "public"
+)
@@ -25,6 +25,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"agola.io/agola/internal/errors"
 	"agola.io/agola/internal/util"
@@ -112,9 +113,13 @@ func (c *Client) GetProjectGroupSubgroups(ctx context.Context, projectGroupRef s
 	return projectGroups, resp, errors.WithStack(err)
 }
 
-func (c *Client) GetProjectGroupProjects(ctx context.Context, projectGroupRef string) ([]*gwapitypes.ProjectResponse, *http.Response, error) {
+func (c *Client) GetProjectGroupProjects(ctx context.Context, projectGroupRef string, tags []string) ([]*gwapitypes.ProjectResponse, *http.Response, error) {
 	projects := []*gwapitypes.ProjectResponse{}
-	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/projectgroups/%s/projects", url.PathEscape(projectGroupRef)), nil, jsonContent, nil, &projects)
+	q := url.Values{}
+	for _, tag := range tags {
+		q.Add("tag", tag)
+	}
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/projectgroups/%s/projects", url.PathEscape(projectGroupRef)), q, jsonContent, nil, &projects)
 	return projects, resp, errors.WithStack(err)
 }
 
@@ -161,6 +166,17 @@ func (c *Client) CreateProject(ctx context.Context, req *gwapitypes.CreateProjec
 	return project, resp, errors.WithStack(err)
 }
 
+func (c *Client) ImportProjects(ctx context.Context, projectGroupRef string, req *gwapitypes.ImportProjectsRequest) ([]*gwapitypes.ImportProjectResponse, *http.Response, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	var results []*gwapitypes.ImportProjectResponse
+	resp, err := c.getParsedResponse(ctx, "POST", fmt.Sprintf("/projectgroups/%s/importprojects", url.PathEscape(projectGroupRef)), nil, jsonContent, bytes.NewReader(reqj), &results)
+	return results, resp, errors.WithStack(err)
+}
+
 func (c *Client) UpdateProject(ctx context.Context, projectRef string, req *gwapitypes.UpdateProjectRequest) (*gwapitypes.ProjectResponse, *http.Response, error) {
 	reqj, err := json.Marshal(req)
 	if err != nil {
@@ -172,6 +188,17 @@ func (c *Client) UpdateProject(ctx context.Context, projectRef string, req *gwap
 	return project, resp, errors.WithStack(err)
 }
 
+func (c *Client) CloneProject(ctx context.Context, projectRef string, req *gwapitypes.CloneProjectRequest) (*gwapitypes.ProjectResponse, *http.Response, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	project := new(gwapitypes.ProjectResponse)
+	resp, err := c.getParsedResponse(ctx, "POST", fmt.Sprintf("/projects/%s/clone", url.PathEscape(projectRef)), nil, jsonContent, bytes.NewReader(reqj), project)
+	return project, resp, errors.WithStack(err)
+}
+
 func (c *Client) CreateProjectGroupSecret(ctx context.Context, projectGroupRef string, req *gwapitypes.CreateSecretRequest) (*gwapitypes.SecretResponse, *http.Response, error) {
 	reqj, err := json.Marshal(req)
 	if err != nil {
@@ -237,6 +264,12 @@ func (c *Client) DeleteProjectSecret(ctx context.Context, projectRef, secretName
 	return c.getResponse(ctx, "DELETE", path.Join("/projects", url.PathEscape(projectRef), "secrets", secretName), nil, jsonContent, nil)
 }
 
+func (c *Client) GetSealedSecretsPublicKey(ctx context.Context) (*gwapitypes.SealedSecretsPublicKeyResponse, *http.Response, error) {
+	res := new(gwapitypes.SealedSecretsPublicKeyResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", "/sealedsecrets/publickey", nil, jsonContent, nil, res)
+	return res, resp, errors.WithStack(err)
+}
+
 func (c *Client) GetProjectSecrets(ctx context.Context, projectRef string, tree, removeoverridden bool) ([]*gwapitypes.SecretResponse, *http.Response, error) {
 	secrets := []*gwapitypes.SecretResponse{}
 	q := url.Values{}
@@ -289,6 +322,12 @@ func (c *Client) GetProjectGroupVariables(ctx context.Context, projectRef string
 	return variables, resp, errors.WithStack(err)
 }
 
+func (c *Client) GetProjectGroupVariablesReport(ctx context.Context, projectGroupRef string) ([]*gwapitypes.ProjectVariablesReportResponse, *http.Response, error) {
+	reports := []*gwapitypes.ProjectVariablesReportResponse{}
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/projectgroups/%s/variablesreport", url.PathEscape(projectGroupRef)), nil, jsonContent, nil, &reports)
+	return reports, resp, errors.WithStack(err)
+}
+
 func (c *Client) CreateProjectVariable(ctx context.Context, projectRef string, req *gwapitypes.CreateVariableRequest) (*gwapitypes.VariableResponse, *http.Response, error) {
 	reqj, err := json.Marshal(req)
 	if err != nil {
@@ -332,6 +371,16 @@ func (c *Client) DeleteProject(ctx context.Context, projectRef string) (*http.Re
 	return c.getResponse(ctx, "DELETE", fmt.Sprintf("/projects/%s", url.PathEscape(projectRef)), nil, jsonContent, nil)
 }
 
+func (c *Client) UndeleteProject(ctx context.Context, projectRef string) (*gwapitypes.ProjectResponse, *http.Response, error) {
+	project := new(gwapitypes.ProjectResponse)
+	resp, err := c.getParsedResponse(ctx, "PUT", fmt.Sprintf("/projects/%s/undelete", url.PathEscape(projectRef)), nil, jsonContent, nil, project)
+	return project, resp, errors.WithStack(err)
+}
+
+func (c *Client) PollProject(ctx context.Context, projectRef string) (*http.Response, error) {
+	return c.getResponse(ctx, "POST", fmt.Sprintf("/projects/%s/poll", url.PathEscape(projectRef)), nil, jsonContent, nil)
+}
+
 func (c *Client) ProjectCreateRun(ctx context.Context, projectRef string, req *gwapitypes.ProjectCreateRunRequest) (*http.Response, error) {
 	reqj, err := json.Marshal(req)
 	if err != nil {
@@ -341,6 +390,12 @@ func (c *Client) ProjectCreateRun(ctx context.Context, projectRef string, req *g
 	return c.getResponse(ctx, "POST", fmt.Sprintf("/projects/%s/createrun", url.PathEscape(projectRef)), nil, jsonContent, bytes.NewReader(reqj))
 }
 
+func (c *Client) GetProjectCaches(ctx context.Context, projectRef string) (*gwapitypes.ProjectCachesResponse, *http.Response, error) {
+	caches := new(gwapitypes.ProjectCachesResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/projects/%s/caches", url.PathEscape(projectRef)), nil, jsonContent, nil, caches)
+	return caches, resp, errors.WithStack(err)
+}
+
 func (c *Client) ReconfigProject(ctx context.Context, projectRef string) (*http.Response, error) {
 	return c.getResponse(ctx, "PUT", fmt.Sprintf("/projects/%s/reconfig", url.PathEscape(projectRef)), nil, jsonContent, nil)
 }
@@ -435,6 +490,12 @@ func (c *Client) CreateUserToken(ctx context.Context, userRef string, req *gwapi
 	return tresp, resp, errors.WithStack(err)
 }
 
+func (c *Client) GetUserTokens(ctx context.Context, userRef string) ([]*gwapitypes.UserTokenResponse, *http.Response, error) {
+	tokens := []*gwapitypes.UserTokenResponse{}
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/users/%s/tokens", userRef), nil, jsonContent, nil, &tokens)
+	return tokens, resp, errors.WithStack(err)
+}
+
 func (c *Client) DeleteUserToken(ctx context.Context, userRef, tokenName string) (*http.Response, error) {
 	return c.getResponse(ctx, "DELETE", fmt.Sprintf("/users/%s/tokens/%s", userRef, tokenName), nil, jsonContent, nil)
 }
@@ -467,15 +528,32 @@ func (c *Client) getRunTask(ctx context.Context, groupType, groupRef string, run
 	return task, resp, errors.WithStack(err)
 }
 
-func (c *Client) GetProjectRuns(ctx context.Context, projectRef string, phaseFilter, resultFilter []string, start uint64, limit int, asc bool) ([]*gwapitypes.RunsResponse, *http.Response, error) {
-	return c.getRuns(ctx, "projects", projectRef, phaseFilter, resultFilter, start, limit, asc)
+func (c *Client) GetProjectMetric(ctx context.Context, projectRef, metric, branch string, start uint64, limit int) ([]*gwapitypes.MetricValueResponse, *http.Response, error) {
+	q := url.Values{}
+	if branch != "" {
+		q.Add("branch", branch)
+	}
+	if start > 0 {
+		q.Add("start", strconv.FormatUint(start, 10))
+	}
+	if limit > 0 {
+		q.Add("limit", strconv.Itoa(limit))
+	}
+
+	values := []*gwapitypes.MetricValueResponse{}
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/projects/%s/stats/metrics/%s", url.PathEscape(projectRef), url.PathEscape(metric)), q, jsonContent, nil, &values)
+	return values, resp, errors.WithStack(err)
+}
+
+func (c *Client) GetProjectRuns(ctx context.Context, projectRef string, phaseFilter, resultFilter, labelFilter []string, start uint64, limit int, asc bool) ([]*gwapitypes.RunsResponse, *http.Response, error) {
+	return c.getRuns(ctx, "projects", projectRef, phaseFilter, resultFilter, labelFilter, start, limit, asc)
 }
 
-func (c *Client) GetUserRuns(ctx context.Context, userRef string, phaseFilter, resultFilter []string, start uint64, limit int, asc bool) ([]*gwapitypes.RunsResponse, *http.Response, error) {
-	return c.getRuns(ctx, "users", userRef, phaseFilter, resultFilter, start, limit, asc)
+func (c *Client) GetUserRuns(ctx context.Context, userRef string, phaseFilter, resultFilter, labelFilter []string, start uint64, limit int, asc bool) ([]*gwapitypes.RunsResponse, *http.Response, error) {
+	return c.getRuns(ctx, "users", userRef, phaseFilter, resultFilter, labelFilter, start, limit, asc)
 }
 
-func (c *Client) getRuns(ctx context.Context, groupType, groupRef string, phaseFilter, resultFilter []string, start uint64, limit int, asc bool) ([]*gwapitypes.RunsResponse, *http.Response, error) {
+func (c *Client) getRuns(ctx context.Context, groupType, groupRef string, phaseFilter, resultFilter, labelFilter []string, start uint64, limit int, asc bool) ([]*gwapitypes.RunsResponse, *http.Response, error) {
 	q := url.Values{}
 	for _, phase := range phaseFilter {
 		q.Add("phase", phase)
@@ -483,6 +561,9 @@ func (c *Client) getRuns(ctx context.Context, groupType, groupRef string, phaseF
 	for _, result := range resultFilter {
 		q.Add("result", result)
 	}
+	for _, label := range labelFilter {
+		q.Add("label", label)
+	}
 	if start > 0 {
 		q.Add("start", strconv.FormatUint(start, 10))
 	}
@@ -498,15 +579,15 @@ func (c *Client) getRuns(ctx context.Context, groupType, groupRef string, phaseF
 	return getRunsResponse, resp, errors.WithStack(err)
 }
 
-func (c *Client) GetProjectLogs(ctx context.Context, projectRef string, runNumber uint64, taskID string, setup bool, step int, follow bool) (*http.Response, error) {
-	return c.getLogs(ctx, "projects", projectRef, runNumber, taskID, setup, step, follow)
+func (c *Client) GetProjectLogs(ctx context.Context, projectRef string, runNumber uint64, taskID string, setup bool, step int, follow bool, logsRange *gwapitypes.LogsRange) (*http.Response, error) {
+	return c.getLogs(ctx, "projects", projectRef, runNumber, taskID, setup, step, follow, logsRange)
 }
 
-func (c *Client) GetUserLogs(ctx context.Context, userRef string, runNumber uint64, taskID string, setup bool, step int, follow bool) (*http.Response, error) {
-	return c.getLogs(ctx, "users", userRef, runNumber, taskID, setup, step, follow)
+func (c *Client) GetUserLogs(ctx context.Context, userRef string, runNumber uint64, taskID string, setup bool, step int, follow bool, logsRange *gwapitypes.LogsRange) (*http.Response, error) {
+	return c.getLogs(ctx, "users", userRef, runNumber, taskID, setup, step, follow, logsRange)
 }
 
-func (c *Client) getLogs(ctx context.Context, groupType, groupRef string, runNumber uint64, taskID string, setup bool, step int, follow bool) (*http.Response, error) {
+func (c *Client) getLogs(ctx context.Context, groupType, groupRef string, runNumber uint64, taskID string, setup bool, step int, follow bool, logsRange *gwapitypes.LogsRange) (*http.Response, error) {
 	q := url.Values{}
 	if setup {
 		q.Add("setup", "")
@@ -516,9 +597,82 @@ func (c *Client) getLogs(ctx context.Context, groupType, groupRef string, runNum
 	if follow {
 		q.Add("follow", "")
 	}
+	if logsRange != nil {
+		if logsRange.Unit != "" {
+			q.Add("unit", string(logsRange.Unit))
+		}
+		q.Add("offset", strconv.FormatInt(logsRange.Offset, 10))
+		if logsRange.Limit > 0 {
+			q.Add("limit", strconv.FormatInt(logsRange.Limit, 10))
+		}
+	}
 	return c.getResponse(ctx, "GET", fmt.Sprintf("/%s/%s/runs/%d/tasks/%s/logs", groupType, url.PathEscape(groupRef), runNumber, taskID), q, nil, nil)
 }
 
+func (c *Client) GetProjectLogsInfo(ctx context.Context, projectRef string, runNumber uint64, taskID string, setup bool, step int) (*gwapitypes.LogsInfoResponse, *http.Response, error) {
+	return c.getLogsInfo(ctx, "projects", projectRef, runNumber, taskID, setup, step)
+}
+
+func (c *Client) GetUserLogsInfo(ctx context.Context, userRef string, runNumber uint64, taskID string, setup bool, step int) (*gwapitypes.LogsInfoResponse, *http.Response, error) {
+	return c.getLogsInfo(ctx, "users", userRef, runNumber, taskID, setup, step)
+}
+
+func (c *Client) getLogsInfo(ctx context.Context, groupType, groupRef string, runNumber uint64, taskID string, setup bool, step int) (*gwapitypes.LogsInfoResponse, *http.Response, error) {
+	q := url.Values{}
+	if setup {
+		q.Add("setup", "")
+	} else {
+		q.Add("step", strconv.Itoa(step))
+	}
+
+	logsInfo := new(gwapitypes.LogsInfoResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/%s/%s/runs/%d/tasks/%s/logs/info", groupType, url.PathEscape(groupRef), runNumber, taskID), q, jsonContent, nil, logsInfo)
+	return logsInfo, resp, errors.WithStack(err)
+}
+
+func (c *Client) GetProjectRunArtifacts(ctx context.Context, projectRef string, runNumber uint64) (*gwapitypes.RunArtifactsResponse, *http.Response, error) {
+	return c.getRunArtifacts(ctx, "projects", projectRef, runNumber)
+}
+
+func (c *Client) GetUserRunArtifacts(ctx context.Context, userRef string, runNumber uint64) (*gwapitypes.RunArtifactsResponse, *http.Response, error) {
+	return c.getRunArtifacts(ctx, "users", userRef, runNumber)
+}
+
+func (c *Client) getRunArtifacts(ctx context.Context, groupType, groupRef string, runNumber uint64) (*gwapitypes.RunArtifactsResponse, *http.Response, error) {
+	artifacts := new(gwapitypes.RunArtifactsResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/%s/%s/runs/%d/artifacts", groupType, url.PathEscape(groupRef), runNumber), nil, jsonContent, nil, artifacts)
+	return artifacts, resp, errors.WithStack(err)
+}
+
+func (c *Client) GetProjectRunTestReports(ctx context.Context, projectRef string, runNumber uint64) (*gwapitypes.RunTestReportsResponse, *http.Response, error) {
+	return c.getRunTestReports(ctx, "projects", projectRef, runNumber)
+}
+
+func (c *Client) GetUserRunTestReports(ctx context.Context, userRef string, runNumber uint64) (*gwapitypes.RunTestReportsResponse, *http.Response, error) {
+	return c.getRunTestReports(ctx, "users", userRef, runNumber)
+}
+
+func (c *Client) getRunTestReports(ctx context.Context, groupType, groupRef string, runNumber uint64) (*gwapitypes.RunTestReportsResponse, *http.Response, error) {
+	testReports := new(gwapitypes.RunTestReportsResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/%s/%s/runs/%d/testreports", groupType, url.PathEscape(groupRef), runNumber), nil, jsonContent, nil, testReports)
+	return testReports, resp, errors.WithStack(err)
+}
+
+func (c *Client) GetProjectRunTaskArtifact(ctx context.Context, projectRef string, runNumber uint64, taskID, artifactPath string) (*http.Response, error) {
+	return c.getRunTaskArtifact(ctx, "projects", projectRef, runNumber, taskID, artifactPath)
+}
+
+func (c *Client) GetUserRunTaskArtifact(ctx context.Context, userRef string, runNumber uint64, taskID, artifactPath string) (*http.Response, error) {
+	return c.getRunTaskArtifact(ctx, "users", userRef, runNumber, taskID, artifactPath)
+}
+
+func (c *Client) getRunTaskArtifact(ctx context.Context, groupType, groupRef string, runNumber uint64, taskID, artifactPath string) (*http.Response, error) {
+	q := url.Values{}
+	q.Add("path", artifactPath)
+
+	return c.getResponse(ctx, "GET", fmt.Sprintf("/%s/%s/runs/%d/tasks/%s/artifact", groupType, url.PathEscape(groupRef), runNumber, taskID), q, nil, nil)
+}
+
 func (c *Client) DeleteProjectLogs(ctx context.Context, projectRef string, runNumber uint64, taskID string, setup bool, step int) (*http.Response, error) {
 	return c.deleteLogs(ctx, "projects", projectRef, runNumber, taskID, setup, step)
 }
@@ -544,6 +698,12 @@ func (c *Client) GetRemoteSource(ctx context.Context, rsRef string) (*gwapitypes
 	return rs, resp, errors.WithStack(err)
 }
 
+func (c *Client) GetRemoteSourceRateLimit(ctx context.Context, rsRef string) (*gwapitypes.RemoteSourceRateLimitResponse, *http.Response, error) {
+	rl := new(gwapitypes.RemoteSourceRateLimitResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/remotesources/%s/ratelimit", rsRef), nil, jsonContent, nil, rl)
+	return rl, resp, errors.WithStack(err)
+}
+
 func (c *Client) GetRemoteSources(ctx context.Context, start string, limit int, asc bool) ([]*gwapitypes.RemoteSourceResponse, *http.Response, error) {
 	q := url.Values{}
 	if start != "" {
@@ -598,6 +758,17 @@ func (c *Client) CreateOrg(ctx context.Context, req *gwapitypes.CreateOrgRequest
 	return org, resp, errors.WithStack(err)
 }
 
+func (c *Client) UpdateOrg(ctx context.Context, orgRef string, req *gwapitypes.UpdateOrgRequest) (*gwapitypes.OrgResponse, *http.Response, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	org := new(gwapitypes.OrgResponse)
+	resp, err := c.getParsedResponse(ctx, "PUT", fmt.Sprintf("/orgs/%s", orgRef), nil, jsonContent, bytes.NewReader(reqj), org)
+	return org, resp, errors.WithStack(err)
+}
+
 func (c *Client) DeleteOrg(ctx context.Context, orgRef string) (*http.Response, error) {
 	return c.getResponse(ctx, "DELETE", fmt.Sprintf("/orgs/%s", orgRef), nil, jsonContent, nil)
 }
@@ -626,6 +797,34 @@ func (c *Client) GetOrgMembers(ctx context.Context, orgRef string) (*gwapitypes.
 	return res, resp, errors.WithStack(err)
 }
 
+func (c *Client) GetOrgUsage(ctx context.Context, orgRef string, startTime, endTime time.Time) (*gwapitypes.OrgUsageResponse, *http.Response, error) {
+	q := orgUsageQuery(startTime, endTime)
+
+	res := &gwapitypes.OrgUsageResponse{}
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/orgs/%s/usage", orgRef), q, jsonContent, nil, &res)
+	return res, resp, errors.WithStack(err)
+}
+
+// GetOrgUsageCSV returns the org usage in csv format. The caller must close
+// the response body.
+func (c *Client) GetOrgUsageCSV(ctx context.Context, orgRef string, startTime, endTime time.Time) (*http.Response, error) {
+	q := orgUsageQuery(startTime, endTime)
+	q.Add("format", "csv")
+
+	return c.getResponse(ctx, "GET", fmt.Sprintf("/orgs/%s/usage", orgRef), q, nil, nil)
+}
+
+func orgUsageQuery(startTime, endTime time.Time) url.Values {
+	q := url.Values{}
+	if !startTime.IsZero() {
+		q.Add("start", startTime.Format(time.RFC3339))
+	}
+	if !endTime.IsZero() {
+		q.Add("end", endTime.Format(time.RFC3339))
+	}
+	return q
+}
+
 func (c *Client) GetVersion(ctx context.Context) (*gwapitypes.VersionResponse, *http.Response, error) {
 	res := &gwapitypes.VersionResponse{}
 	resp, err := c.getParsedResponse(ctx, "GET", "/version", nil, jsonContent, nil, &res)
@@ -637,3 +836,77 @@ func (c *Client) GetUserOrgs(ctx context.Context) ([]*gwapitypes.UserOrgsRespons
 	resp, err := c.getParsedResponse(ctx, "GET", "/user/orgs", nil, jsonContent, nil, &userOrgs)
 	return userOrgs, resp, errors.WithStack(err)
 }
+
+func (c *Client) GetUserPreferences(ctx context.Context) (*gwapitypes.UserPreferencesResponse, *http.Response, error) {
+	userPreferences := new(gwapitypes.UserPreferencesResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", "/user/preferences", nil, jsonContent, nil, userPreferences)
+	return userPreferences, resp, errors.WithStack(err)
+}
+
+func (c *Client) UpdateUserPreferences(ctx context.Context, req *gwapitypes.UpdateUserPreferencesRequest) (*gwapitypes.UserPreferencesResponse, *http.Response, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	userPreferences := new(gwapitypes.UserPreferencesResponse)
+	resp, err := c.getParsedResponse(ctx, "PUT", "/user/preferences", nil, jsonContent, bytes.NewReader(reqj), userPreferences)
+	return userPreferences, resp, errors.WithStack(err)
+}
+
+func (c *Client) GetExecutors(ctx context.Context) ([]*gwapitypes.ExecutorResponse, *http.Response, error) {
+	executors := []*gwapitypes.ExecutorResponse{}
+	resp, err := c.getParsedResponse(ctx, "GET", "/executors", nil, jsonContent, nil, &executors)
+	return executors, resp, errors.WithStack(err)
+}
+
+func (c *Client) DrainExecutor(ctx context.Context, executorID string) (*http.Response, error) {
+	return c.executorAction(ctx, executorID, gwapitypes.ExecutorActionTypeDrain)
+}
+
+func (c *Client) ApproveExecutor(ctx context.Context, executorID string) (*http.Response, error) {
+	return c.executorAction(ctx, executorID, gwapitypes.ExecutorActionTypeApprove)
+}
+
+func (c *Client) BanExecutor(ctx context.Context, executorID string) (*http.Response, error) {
+	return c.executorAction(ctx, executorID, gwapitypes.ExecutorActionTypeBan)
+}
+
+func (c *Client) executorAction(ctx context.Context, executorID string, actionType gwapitypes.ExecutorActionType) (*http.Response, error) {
+	req := &gwapitypes.ExecutorActionsRequest{
+		ActionType: actionType,
+	}
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.getResponse(ctx, "PUT", fmt.Sprintf("/executors/%s/actions", executorID), nil, jsonContent, bytes.NewReader(reqj))
+}
+
+func (c *Client) GetAnnouncements(ctx context.Context, all bool) ([]*gwapitypes.AnnouncementResponse, *http.Response, error) {
+	q := url.Values{}
+	if all {
+		q.Add("all", "")
+	}
+
+	announcements := []*gwapitypes.AnnouncementResponse{}
+	resp, err := c.getParsedResponse(ctx, "GET", "/announcements", q, jsonContent, nil, &announcements)
+	return announcements, resp, errors.WithStack(err)
+}
+
+func (c *Client) CreateAnnouncement(ctx context.Context, req *gwapitypes.CreateAnnouncementRequest) (*gwapitypes.AnnouncementResponse, *http.Response, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	announcement := new(gwapitypes.AnnouncementResponse)
+	resp, err := c.getParsedResponse(ctx, "POST", "/announcements", nil, jsonContent, bytes.NewReader(reqj), announcement)
+	return announcement, resp, errors.WithStack(err)
+}
+
+func (c *Client) ExpireAnnouncement(ctx context.Context, announcementID string) (*gwapitypes.AnnouncementResponse, *http.Response, error) {
+	announcement := new(gwapitypes.AnnouncementResponse)
+	resp, err := c.getParsedResponse(ctx, "PUT", fmt.Sprintf("/announcements/%s/expire", announcementID), nil, jsonContent, nil, announcement)
+	return announcement, resp, errors.WithStack(err)
+}
@@ -0,0 +1,35 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"time"
+)
+
+type CacheResponse struct {
+	Key      string    `json:"key"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// CacheGroupResponse reports the caches usage of a cache group
+type CacheGroupResponse struct {
+	Group string `json:"group"`
+	// Size is the size in bytes of all the group caches
+	Size int64 `json:"size"`
+	// Quota is the max size of the group caches. 0 means no quota
+	Quota  int64            `json:"quota"`
+	Caches []*CacheResponse `json:"caches"`
+}
@@ -0,0 +1,24 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+const (
+	// ExecutorTokenHeader is the header containing the executor registration
+	// token sent by the executor in its calls to the runservice
+	ExecutorTokenHeader = "X-Agola-Executor-Token"
+	// ExecutorIDHeader is the header containing the id of the executor
+	// calling the runservice apis not scoped to an executor
+	ExecutorIDHeader = "X-Agola-Executor-ID"
+)
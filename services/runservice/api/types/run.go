@@ -37,6 +37,21 @@ type RunCreateRequest struct {
 	SetupErrors       []string                          `json:"setup_errors"`
 	StaticEnvironment map[string]string                 `json:"static_environment"`
 	CacheGroup        string                            `json:"cache_group"`
+	RepoTokenPath     string                            `json:"repo_token_path"`
+	Labels            map[string]string                 `json:"labels"`
+	// DependsOn are the ids of the runs that must succeed before starting
+	// the new run
+	DependsOn []string `json:"depends_on"`
+	// ConcurrencyGroups are the concurrency groups limiting the run
+	ConcurrencyGroups []*rstypes.RunConcurrencyGroup `json:"concurrency_groups"`
+	// CancelSuperseded stops the older runs with the same group and name
+	CancelSuperseded bool `json:"cancel_superseded"`
+	// FailFast stops the run tasks when the run fails
+	FailFast bool `json:"fail_fast"`
+	// Triggers are the downstream runs created when the run succeeds
+	Triggers []*rstypes.RunConfigTrigger `json:"triggers"`
+	// SealedValues are the sealed values resolved from the project secrets
+	SealedValues map[string]rstypes.SealedValue `json:"sealed_values"`
 
 	// existing run fields
 	RunID      string   `json:"run_id"`
@@ -64,6 +79,18 @@ type RunActionsRequest struct {
 	ChangeGroupsUpdateToken string           `json:"change_groups_update_tokens"`
 }
 
+type ExecutorActionType string
+
+const (
+	ExecutorActionTypeDrain   ExecutorActionType = "drain"
+	ExecutorActionTypeApprove ExecutorActionType = "approve"
+	ExecutorActionTypeBan     ExecutorActionType = "ban"
+)
+
+type ExecutorActionsRequest struct {
+	ActionType ExecutorActionType `json:"action_type"`
+}
+
 type RunTaskActionType string
 
 const (
@@ -80,3 +107,39 @@ type RunTaskActionsRequest struct {
 	// global fields
 	ChangeGroupsUpdateToken string `json:"change_groups_update_tokens"`
 }
+
+type LogsRangeUnit string
+
+const (
+	LogsRangeUnitLine LogsRangeUnit = "line"
+	LogsRangeUnitByte LogsRangeUnit = "byte"
+)
+
+// LogsRange defines the part of a log to return. Offset and Limit are
+// expressed in lines or bytes based on Unit. A zero Limit means no limit.
+type LogsRange struct {
+	Unit   LogsRangeUnit
+	Offset int64
+	Limit  int64
+}
+
+type SealedSecretsPublicKeyResponse struct {
+	// PublicKey is the base64 encoded public key used to seal the secrets
+	PublicKey string `json:"public_key"`
+}
+
+type LogsInfoResponse struct {
+	// Size is the log size in bytes
+	Size int64 `json:"size"`
+	// Lines is the log number of lines
+	Lines int64 `json:"lines"`
+	// Complete reports if the log is complete or if it could still grow
+	Complete bool `json:"complete"`
+}
+
+// ProvisionerWebhookRequest is the payload posted to the webhook executors
+// provisioner
+type ProvisionerWebhookRequest struct {
+	Demands   []*rstypes.TasksDemand `json:"demands"`
+	Executors []*rstypes.Executor    `json:"executors"`
+}
@@ -101,12 +101,18 @@ func (c *Client) getParsedResponse(ctx context.Context, method, path string, que
 	return resp, errors.WithStack(d.Decode(obj))
 }
 
-func (c *Client) SendExecutorStatus(ctx context.Context, executor *rstypes.Executor) (*http.Response, error) {
+func (c *Client) SendExecutorStatus(ctx context.Context, executor *rstypes.Executor) (*rstypes.Executor, *http.Response, error) {
 	executorj, err := json.Marshal(executor)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, nil, errors.WithStack(err)
 	}
-	return c.getResponse(ctx, "POST", fmt.Sprintf("/executor/%s", executor.ExecutorID), nil, -1, jsonContent, bytes.NewReader(executorj))
+	rexecutor := new(rstypes.Executor)
+	resp, err := c.getParsedResponse(ctx, "POST", fmt.Sprintf("/executor/%s", executor.ExecutorID), nil, jsonContent, bytes.NewReader(executorj), rexecutor)
+	return rexecutor, resp, errors.WithStack(err)
+}
+
+func (c *Client) DeleteExecutor(ctx context.Context, executorID string) (*http.Response, error) {
+	return c.getResponse(ctx, "DELETE", fmt.Sprintf("/executor/%s", executorID), nil, -1, jsonContent, nil)
 }
 
 func (c *Client) SendExecutorTaskStatus(ctx context.Context, executorID string, et *rstypes.ExecutorTask) (*http.Response, error) {
@@ -117,6 +123,19 @@ func (c *Client) SendExecutorTaskStatus(ctx context.Context, executorID string,
 	return c.getResponse(ctx, "POST", fmt.Sprintf("/executor/%s/tasks/%s", executorID, et.ID), nil, -1, jsonContent, bytes.NewReader(etj))
 }
 
+// SendExecutorTaskLogChunk uploads a chunk of a task log starting at the
+// provided offset
+func (c *Client) SendExecutorTaskLogChunk(ctx context.Context, executorID, etID string, setup bool, step int, offset int64, size int64, r io.Reader) (*http.Response, error) {
+	q := url.Values{}
+	if setup {
+		q.Add("setup", "")
+	} else {
+		q.Add("step", strconv.Itoa(step))
+	}
+	q.Add("offset", strconv.FormatInt(offset, 10))
+	return c.getResponse(ctx, "PUT", fmt.Sprintf("/executor/%s/tasks/%s/logs", executorID, etID), q, size, nil, r)
+}
+
 func (c *Client) GetExecutorTask(ctx context.Context, executorID, etID string) (*rstypes.ExecutorTask, *http.Response, error) {
 	et := new(rstypes.ExecutorTask)
 	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/executor/%s/tasks/%s", executorID, etID), nil, jsonContent, nil, et)
@@ -129,6 +148,26 @@ func (c *Client) GetExecutorTasks(ctx context.Context, executorID string) ([]*rs
 	return ets, resp, errors.WithStack(err)
 }
 
+func (c *Client) GetTasksDemand(ctx context.Context) ([]*rstypes.TasksDemand, *http.Response, error) {
+	demands := []*rstypes.TasksDemand{}
+	resp, err := c.getParsedResponse(ctx, "GET", "/executor/demand", nil, jsonContent, nil, &demands)
+	return demands, resp, errors.WithStack(err)
+}
+
+func (c *Client) GetExecutors(ctx context.Context) ([]*rstypes.Executor, *http.Response, error) {
+	executors := []*rstypes.Executor{}
+	resp, err := c.getParsedResponse(ctx, "GET", "/executors", nil, jsonContent, nil, &executors)
+	return executors, resp, errors.WithStack(err)
+}
+
+func (c *Client) ExecutorActions(ctx context.Context, executorID string, req *rsapitypes.ExecutorActionsRequest) (*http.Response, error) {
+	reqj, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.getResponse(ctx, "PUT", fmt.Sprintf("/executors/%s/actions", executorID), nil, -1, jsonContent, bytes.NewReader(reqj))
+}
+
 func (c *Client) GetArchive(ctx context.Context, taskID string, step int) (*http.Response, error) {
 	q := url.Values{}
 	q.Add("taskid", taskID)
@@ -137,27 +176,68 @@ func (c *Client) GetArchive(ctx context.Context, taskID string, step int) (*http
 	return c.getResponse(ctx, "GET", "/executor/archives", q, -1, nil, nil)
 }
 
-func (c *Client) CheckCache(ctx context.Context, key string, prefix bool) (*http.Response, error) {
+func (c *Client) CheckCache(ctx context.Context, group, key string, prefix bool) (*http.Response, error) {
 	q := url.Values{}
 	if prefix {
 		q.Add("prefix", "")
 	}
-	return c.getResponse(ctx, "HEAD", fmt.Sprintf("/executor/caches/%s", url.PathEscape(key)), q, -1, nil, nil)
+	return c.getResponse(ctx, "HEAD", fmt.Sprintf("/executor/caches/%s/%s", url.PathEscape(group), url.PathEscape(key)), q, -1, nil, nil)
 }
 
-func (c *Client) GetCache(ctx context.Context, key string, prefix bool) (*http.Response, error) {
+func (c *Client) GetCache(ctx context.Context, group, key string, prefix bool) (*http.Response, error) {
 	q := url.Values{}
 	if prefix {
 		q.Add("prefix", "")
 	}
-	return c.getResponse(ctx, "GET", fmt.Sprintf("/executor/caches/%s", url.PathEscape(key)), q, -1, nil, nil)
+	return c.getResponse(ctx, "GET", fmt.Sprintf("/executor/caches/%s/%s", url.PathEscape(group), url.PathEscape(key)), q, -1, nil, nil)
+}
+
+func (c *Client) PutCache(ctx context.Context, group, key string, size int64, r io.Reader) (*http.Response, error) {
+	return c.getResponse(ctx, "POST", fmt.Sprintf("/executor/caches/%s/%s", url.PathEscape(group), url.PathEscape(key)), nil, size, nil, r)
+}
+
+func (c *Client) GetCacheGroup(ctx context.Context, group string) (*rsapitypes.CacheGroupResponse, *http.Response, error) {
+	cacheGroup := new(rsapitypes.CacheGroupResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/caches/%s", url.PathEscape(group)), nil, jsonContent, nil, cacheGroup)
+	return cacheGroup, resp, errors.WithStack(err)
+}
+
+func (c *Client) DeleteCacheGroup(ctx context.Context, group string) (*http.Response, error) {
+	return c.getResponse(ctx, "DELETE", fmt.Sprintf("/caches/%s", url.PathEscape(group)), nil, -1, jsonContent, nil)
+}
+
+func (c *Client) GetTaskArtifacts(ctx context.Context, runID, taskID string) (*http.Response, error) {
+	return c.getResponse(ctx, "GET", fmt.Sprintf("/executor/artifacts/%s/%s", runID, taskID), nil, -1, nil, nil)
+}
+
+func (c *Client) PutTaskArtifacts(ctx context.Context, runID, taskID string, size int64, r io.Reader) (*http.Response, error) {
+	return c.getResponse(ctx, "POST", fmt.Sprintf("/executor/artifacts/%s/%s", runID, taskID), nil, size, nil, r)
+}
+
+func (c *Client) GetRunArtifacts(ctx context.Context, runID string) (*rsapitypes.RunArtifactsResponse, *http.Response, error) {
+	runArtifacts := new(rsapitypes.RunArtifactsResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/runs/%s/artifacts", runID), nil, jsonContent, nil, runArtifacts)
+	return runArtifacts, resp, errors.WithStack(err)
 }
 
-func (c *Client) PutCache(ctx context.Context, key string, size int64, r io.Reader) (*http.Response, error) {
-	return c.getResponse(ctx, "POST", fmt.Sprintf("/executor/caches/%s", url.PathEscape(key)), nil, size, nil, r)
+func (c *Client) PutTaskTestReports(ctx context.Context, runID, taskID string, size int64, r io.Reader) (*http.Response, error) {
+	return c.getResponse(ctx, "POST", fmt.Sprintf("/executor/testreports/%s/%s", runID, taskID), nil, size, nil, r)
 }
 
-func (c *Client) GetRuns(ctx context.Context, phaseFilter, resultFilter, groups []string, lastRun bool, changeGroups []string, startRunSequence uint64, limit int, asc bool) (*rsapitypes.GetRunsResponse, *http.Response, error) {
+func (c *Client) GetRunTestReports(ctx context.Context, runID string) (*rsapitypes.RunTestReportsResponse, *http.Response, error) {
+	runTestReports := new(rsapitypes.RunTestReportsResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", fmt.Sprintf("/runs/%s/testreports", runID), nil, jsonContent, nil, runTestReports)
+	return runTestReports, resp, errors.WithStack(err)
+}
+
+func (c *Client) GetRunTaskArtifact(ctx context.Context, runID, taskID, artifactPath string) (*http.Response, error) {
+	q := url.Values{}
+	q.Add("path", artifactPath)
+
+	return c.getResponse(ctx, "GET", fmt.Sprintf("/runs/%s/tasks/%s/artifact", runID, taskID), q, -1, nil, nil)
+}
+
+func (c *Client) GetRuns(ctx context.Context, phaseFilter, resultFilter, labelFilter, groups []string, lastRun bool, changeGroups []string, startRunSequence uint64, limit int, asc bool) (*rsapitypes.GetRunsResponse, *http.Response, error) {
 	q := url.Values{}
 	for _, phase := range phaseFilter {
 		q.Add("phase", phase)
@@ -165,6 +245,9 @@ func (c *Client) GetRuns(ctx context.Context, phaseFilter, resultFilter, groups
 	for _, result := range resultFilter {
 		q.Add("result", result)
 	}
+	for _, label := range labelFilter {
+		q.Add("label", label)
+	}
 	for _, group := range groups {
 		q.Add("group", group)
 	}
@@ -191,30 +274,30 @@ func (c *Client) GetRuns(ctx context.Context, phaseFilter, resultFilter, groups
 }
 
 func (c *Client) GetQueuedRuns(ctx context.Context, startRunSequence uint64, limit int, changeGroups []string) (*rsapitypes.GetRunsResponse, *http.Response, error) {
-	return c.GetRuns(ctx, []string{"queued"}, nil, []string{}, false, changeGroups, startRunSequence, limit, true)
+	return c.GetRuns(ctx, []string{"queued"}, nil, nil, []string{}, false, changeGroups, startRunSequence, limit, true)
 }
 
 func (c *Client) GetRunningRuns(ctx context.Context, startRunSequence uint64, limit int, changeGroups []string) (*rsapitypes.GetRunsResponse, *http.Response, error) {
-	return c.GetRuns(ctx, []string{"running"}, nil, []string{}, false, changeGroups, startRunSequence, limit, true)
+	return c.GetRuns(ctx, []string{"running"}, nil, nil, []string{}, false, changeGroups, startRunSequence, limit, true)
 }
 
 func (c *Client) GetGroupQueuedRuns(ctx context.Context, group string, limit int, changeGroups []string) (*rsapitypes.GetRunsResponse, *http.Response, error) {
-	return c.GetRuns(ctx, []string{"queued"}, nil, []string{group}, false, changeGroups, 0, limit, false)
+	return c.GetRuns(ctx, []string{"queued"}, nil, nil, []string{group}, false, changeGroups, 0, limit, false)
 }
 
 func (c *Client) GetGroupRunningRuns(ctx context.Context, group string, limit int, changeGroups []string) (*rsapitypes.GetRunsResponse, *http.Response, error) {
-	return c.GetRuns(ctx, []string{"running"}, nil, []string{group}, false, changeGroups, 0, limit, false)
+	return c.GetRuns(ctx, []string{"running"}, nil, nil, []string{group}, false, changeGroups, 0, limit, false)
 }
 
 func (c *Client) GetGroupFirstQueuedRuns(ctx context.Context, group string, changeGroups []string) (*rsapitypes.GetRunsResponse, *http.Response, error) {
-	return c.GetRuns(ctx, []string{"queued"}, nil, []string{group}, false, changeGroups, 0, 1, true)
+	return c.GetRuns(ctx, []string{"queued"}, nil, nil, []string{group}, false, changeGroups, 0, 1, true)
 }
 
 func (c *Client) GetGroupLastRun(ctx context.Context, group string, changeGroups []string) (*rsapitypes.GetRunsResponse, *http.Response, error) {
-	return c.GetRuns(ctx, nil, nil, []string{group}, false, changeGroups, 0, 1, false)
+	return c.GetRuns(ctx, nil, nil, nil, []string{group}, false, changeGroups, 0, 1, false)
 }
 
-func (c *Client) GetGroupRuns(ctx context.Context, phaseFilter, resultFilter []string, group string, changeGroups []string, startRunCounter uint64, limit int, asc bool) (*rsapitypes.GetRunsResponse, *http.Response, error) {
+func (c *Client) GetGroupRuns(ctx context.Context, phaseFilter, resultFilter, labelFilter []string, group string, changeGroups []string, startRunCounter uint64, limit int, asc bool) (*rsapitypes.GetRunsResponse, *http.Response, error) {
 	q := url.Values{}
 	for _, phase := range phaseFilter {
 		q.Add("phase", phase)
@@ -222,6 +305,9 @@ func (c *Client) GetGroupRuns(ctx context.Context, phaseFilter, resultFilter []s
 	for _, result := range resultFilter {
 		q.Add("result", result)
 	}
+	for _, label := range labelFilter {
+		q.Add("label", label)
+	}
 	for _, changeGroup := range changeGroups {
 		q.Add("changegroup", changeGroup)
 	}
@@ -240,6 +326,10 @@ func (c *Client) GetGroupRuns(ctx context.Context, phaseFilter, resultFilter []s
 	return getRunsResponse, resp, errors.WithStack(err)
 }
 
+func (c *Client) DeleteGroupRuns(ctx context.Context, group string) (*http.Response, error) {
+	return c.getResponse(ctx, "DELETE", fmt.Sprintf("/runs/group/%s", url.PathEscape(group)), nil, -1, jsonContent, nil)
+}
+
 func (c *Client) CreateRun(ctx context.Context, req *rsapitypes.RunCreateRequest) (*rsapitypes.RunResponse, *http.Response, error) {
 	reqj, err := json.Marshal(req)
 	if err != nil {
@@ -269,6 +359,16 @@ func (c *Client) StartRun(ctx context.Context, runID string, changeGroupsUpdateT
 	return c.RunActions(ctx, runID, req)
 }
 
+func (c *Client) CancelRun(ctx context.Context, runID string, changeGroupsUpdateToken string) (*http.Response, error) {
+	req := &rsapitypes.RunActionsRequest{
+		ActionType:              rsapitypes.RunActionTypeChangePhase,
+		Phase:                   rstypes.RunPhaseCancelled,
+		ChangeGroupsUpdateToken: changeGroupsUpdateToken,
+	}
+
+	return c.RunActions(ctx, runID, req)
+}
+
 func (c *Client) RunTaskActions(ctx context.Context, runID, taskID string, req *rsapitypes.RunTaskActionsRequest) (*http.Response, error) {
 	reqj, err := json.Marshal(req)
 	if err != nil {
@@ -318,7 +418,7 @@ func (c *Client) GetRunByGroup(ctx context.Context, group string, runNumber uint
 	return runResponse, resp, errors.WithStack(err)
 }
 
-func (c *Client) GetLogs(ctx context.Context, runID, taskID string, setup bool, step int, follow bool) (*http.Response, error) {
+func (c *Client) GetLogs(ctx context.Context, runID, taskID string, setup bool, step int, follow bool, logsRange *rsapitypes.LogsRange) (*http.Response, error) {
 	q := url.Values{}
 	q.Add("runid", runID)
 	q.Add("taskid", taskID)
@@ -330,10 +430,32 @@ func (c *Client) GetLogs(ctx context.Context, runID, taskID string, setup bool,
 	if follow {
 		q.Add("follow", "")
 	}
+	if logsRange != nil {
+		q.Add("unit", string(logsRange.Unit))
+		q.Add("offset", strconv.FormatInt(logsRange.Offset, 10))
+		if logsRange.Limit > 0 {
+			q.Add("limit", strconv.FormatInt(logsRange.Limit, 10))
+		}
+	}
 
 	return c.getResponse(ctx, "GET", "/logs", q, -1, nil, nil)
 }
 
+func (c *Client) GetLogsInfo(ctx context.Context, runID, taskID string, setup bool, step int) (*rsapitypes.LogsInfoResponse, *http.Response, error) {
+	q := url.Values{}
+	q.Add("runid", runID)
+	q.Add("taskid", taskID)
+	if setup {
+		q.Add("setup", "")
+	} else {
+		q.Add("step", strconv.Itoa(step))
+	}
+
+	logsInfo := new(rsapitypes.LogsInfoResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", "/logs/info", q, jsonContent, nil, logsInfo)
+	return logsInfo, resp, errors.WithStack(err)
+}
+
 func (c *Client) DeleteLogs(ctx context.Context, runID, taskID string, setup bool, step int) (*http.Response, error) {
 	q := url.Values{}
 	q.Add("runid", runID)
@@ -347,6 +469,12 @@ func (c *Client) DeleteLogs(ctx context.Context, runID, taskID string, setup boo
 	return c.getResponse(ctx, "DELETE", "/logs", q, -1, nil, nil)
 }
 
+func (c *Client) GetSealedSecretsPublicKey(ctx context.Context) (*rsapitypes.SealedSecretsPublicKeyResponse, *http.Response, error) {
+	res := new(rsapitypes.SealedSecretsPublicKeyResponse)
+	resp, err := c.getParsedResponse(ctx, "GET", "/sealedsecrets/publickey", nil, jsonContent, nil, res)
+	return res, resp, errors.WithStack(err)
+}
+
 func (c *Client) GetRunEvents(ctx context.Context, startRunEventID string) (*http.Response, error) {
 	q := url.Values{}
 	q.Add("startruneventid", startRunEventID)
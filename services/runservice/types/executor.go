@@ -12,6 +12,72 @@ const (
 	ExecutorVersion = "v0.1.0"
 )
 
+// Executor labels automatically set by the executor
+const (
+	// ExecutorLabelOS contains the os of the containers executed by the executor
+	ExecutorLabelOS = "agola.io/os"
+	// ExecutorLabelArch contains the arch of the containers executed by the
+	// executor. It's set only when the executor supports a single arch
+	ExecutorLabelArch = "agola.io/arch"
+	// ExecutorLabelDriver contains the executor driver type
+	ExecutorLabelDriver = "agola.io/driver"
+	// ExecutorLabelKernelVersion contains the kernel version of the host
+	// running the containers when available
+	ExecutorLabelKernelVersion = "agola.io/kernel-version"
+	// ExecutorLabelDockerVersion contains the docker daemon version
+	ExecutorLabelDockerVersion = "agola.io/docker-version"
+	// ExecutorLabelKubernetesVersion contains the kubernetes server version
+	ExecutorLabelKubernetesVersion = "agola.io/kubernetes-version"
+)
+
+// ExecutorFeature is a task execution feature provided by an executor and
+// by the toolbox it copies into the task pods. A task is assigned only to the
+// executors providing all the features it requires, so executors of an older
+// version, that don't report them, keep executing only the tasks they can
+// correctly execute during an upgrade
+type ExecutorFeature string
+
+const (
+	// ExecutorFeatureCacheGroups reports that the executor saves and restores
+	// the caches using the per cache group api
+	ExecutorFeatureCacheGroups ExecutorFeature = "cache_groups"
+	// ExecutorFeatureWorkspaceOverwrite reports that the executor restores
+	// workspace archives overwriting the existing files
+	ExecutorFeatureWorkspaceOverwrite ExecutorFeature = "workspace_overwrite"
+	// ExecutorFeatureArtifacts reports that the executor executes the save
+	// and restore artifacts steps
+	ExecutorFeatureArtifacts ExecutorFeature = "artifacts"
+	// ExecutorFeatureStepRetries reports that the executor retries the failed
+	// run steps that define retries
+	ExecutorFeatureStepRetries ExecutorFeature = "step_retries"
+	// ExecutorFeatureStepTimeouts reports that the executor stops the steps
+	// exceeding their timeout
+	ExecutorFeatureStepTimeouts ExecutorFeature = "step_timeouts"
+	// ExecutorFeatureStepRetryBackoff reports that the executor increases the
+	// interval between the run step retries by the step retry backoff
+	ExecutorFeatureStepRetryBackoff ExecutorFeature = "step_retry_backoff"
+	// ExecutorFeatureTaskRetries reports that the executor retries the failed
+	// tasks that define retries
+	ExecutorFeatureTaskRetries ExecutorFeature = "task_retries"
+	// ExecutorFeatureTestReports reports that the executor executes the
+	// test_report steps
+	ExecutorFeatureTestReports ExecutorFeature = "test_reports"
+)
+
+// ExecutorState is the executor registration state
+type ExecutorState string
+
+const (
+	// ExecutorStatePending is the state of a new executor waiting to be
+	// approved by an admin. No tasks are scheduled on pending executors
+	ExecutorStatePending ExecutorState = "pending"
+	// ExecutorStateApproved is the state of an executor that can execute tasks
+	ExecutorStateApproved ExecutorState = "approved"
+	// ExecutorStateBanned is the state of an executor banned by an admin. Its
+	// calls to the runservice executor api are rejected
+	ExecutorStateBanned ExecutorState = "banned"
+)
+
 type Executor struct {
 	stypes.TypeMeta
 	stypes.ObjectMeta
@@ -25,9 +91,22 @@ type Executor struct {
 	Labels map[string]string `json:"labels,omitempty"`
 
 	AllowPrivilegedContainers bool `json:"allow_privileged_containers,omitempty"`
+	// PrivilegedContainersProjects are the projects ids allowed to use
+	// privileged containers. Empty means all
+	PrivilegedContainersProjects []string `json:"privileged_containers_projects,omitempty"`
+
+	// GPUs is the number of GPUs provided by the executor to the tasks
+	GPUs int `json:"gpus,omitempty"`
+
+	// RuntimeType is the type of the task runtimes the executor can execute.
+	// Empty means RuntimeTypePod
+	RuntimeType RuntimeType `json:"runtime_type,omitempty"`
 
 	ActiveTasksLimit int `json:"active_tasks_limit,omitempty"`
 	ActiveTasks      int `json:"active_tasks,omitempty"`
+	// LabelsActiveTasksLimits are the limits of the concurrent active tasks
+	// with the provided labels
+	LabelsActiveTasksLimits []LabelsActiveTasksLimit `json:"labels_active_tasks_limits,omitempty"`
 
 	// Dynamic represents an executor that can be automatically removed since it's
 	// part of a group of executors managing the same resources (i.e. a k8s
@@ -39,6 +118,83 @@ type Executor struct {
 	ExecutorGroup string `json:"executor_group,omitempty"`
 	// SiblingExecutors are all the executors in the ExecutorGroup
 	SiblingsExecutors []string `json:"siblings_executors,omitempty"`
+
+	// Version is the agola version of the executor
+	Version string `json:"version,omitempty"`
+	// Features are the task execution features provided by the executor
+	Features []ExecutorFeature `json:"features,omitempty"`
+
+	// Draining is set by an admin to decommission the executor: no new tasks
+	// are scheduled on it and, when its active tasks are finished, the
+	// executor deregisters itself
+	Draining bool `json:"draining,omitempty"`
+
+	// TokenHash is the sha256 hash of the executor registration token. When
+	// set, all the executor calls to the runservice must provide the token
+	TokenHash string `json:"token_hash,omitempty"`
+	// State is the executor registration state. Empty means approved
+	// (executors registered before the introduction of the approval)
+	State ExecutorState `json:"state,omitempty"`
+
+	// Health is the executor resources usage reported in the status updates
+	Health *ExecutorHealth `json:"health,omitempty"`
+	// HealthProblems are set by the runservice when the executor health
+	// exceeds the configured thresholds. No tasks are scheduled on executors
+	// with health problems
+	HealthProblems []string `json:"health_problems,omitempty"`
+}
+
+// ExecutorHealth is the executor resources usage. Values not available for
+// the executor platform or driver are zero
+type ExecutorHealth struct {
+	// DiskTotal and DiskFree are the total and available bytes of the
+	// filesystem containing the executor data dir
+	DiskTotal uint64 `json:"disk_total,omitempty"`
+	DiskFree  uint64 `json:"disk_free,omitempty"`
+	// ImagesSize is the size in bytes of the container images cached by the
+	// driver
+	ImagesSize int64 `json:"images_size,omitempty"`
+	// Load1 is the 1 minute load average of the executor host
+	Load1 float64 `json:"load1,omitempty"`
+	CPUs  int     `json:"cpus,omitempty"`
+}
+
+// IsHealthy reports if the executor has no health problems
+func (e *Executor) IsHealthy() bool {
+	return len(e.HealthProblems) == 0
+}
+
+// IsApproved reports if tasks can be scheduled on the executor
+func (e *Executor) IsApproved() bool {
+	return e.State == "" || e.State == ExecutorStateApproved
+}
+
+// LabelsActiveTasksLimit is the max number of concurrent active tasks, having
+// all the provided labels, executed by an executor
+type LabelsActiveTasksLimit struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Limit  int               `json:"limit,omitempty"`
+}
+
+// Matches reports if the provided task labels contain all the limit labels
+func (l *LabelsActiveTasksLimit) Matches(labels map[string]string) bool {
+	for k, v := range l.Labels {
+		if lv, ok := labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// TasksDemand is the number of run tasks ready to be executed but not yet
+// assigned to an executor, grouped by their executor requirements
+type TasksDemand struct {
+	RuntimeType    RuntimeType       `json:"runtime_type,omitempty"`
+	Arch           stypes.Arch       `json:"arch,omitempty"`
+	ExecutorLabels map[string]string `json:"executor_labels,omitempty"`
+	GPUs           int               `json:"gpus,omitempty"`
+
+	PendingTasks int `json:"pending_tasks"`
 }
 
 func (e *Executor) DeepCopy() *Executor {
@@ -49,6 +205,23 @@ func (e *Executor) DeepCopy() *Executor {
 	return ne.(*Executor)
 }
 
+// HasFeatures reports if the executor provides all the provided features
+func (e *Executor) HasFeatures(features []ExecutorFeature) bool {
+	for _, f := range features {
+		found := false
+		for _, ef := range e.Features {
+			if ef == f {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 func NewExecutor() *Executor {
 	return &Executor{
 		TypeMeta: stypes.TypeMeta{
@@ -50,6 +50,9 @@ type ExecutorTaskSpec struct {
 	ExecutorID string `json:"executor_id,omitempty"`
 	RunID      string `json:"run_id,omitempty"`
 	RunTaskID  string `json:"run_task_id,omitempty"`
+	// Labels are the run task labels, used to honor the executor labels
+	// active tasks limits
+	Labels map[string]string `json:"labels,omitempty"`
 
 	// Stop is used to signal from the scheduler when the task must be stopped
 	Stop bool `json:"stop,omitempty"`
@@ -62,6 +65,7 @@ type ExecutorTaskSpec struct {
 // generated everytime they are sent to the executor
 type ExecutorTaskSpecData struct {
 	TaskName    string            `json:"task_name,omitempty"`
+	RuntimeType RuntimeType       `json:"runtime_type,omitempty"`
 	Arch        stypes.Arch       `json:"arch,omitempty"`
 	Containers  []*Container      `json:"containers,omitempty"`
 	Environment map[string]string `json:"environment,omitempty"`
@@ -69,8 +73,14 @@ type ExecutorTaskSpecData struct {
 	Shell       string            `json:"shell,omitempty"`
 	User        string            `json:"user,omitempty"`
 	Privileged  bool              `json:"privileged"`
+	// GPUs is the number of GPUs assigned to the main container
+	GPUs int `json:"gpus,omitempty"`
+	// NodeSelector and Tolerations are used only by the k8s driver
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	Tolerations  []Toleration      `json:"tolerations,omitempty"`
 
 	WorkspaceOperations []WorkspaceOperation `json:"workspace_operations,omitempty"`
+	SkipWorkspace       bool                 `json:"skip_workspace,omitempty"`
 
 	DockerRegistriesAuth map[string]DockerRegistryAuth `json:"docker_registries_auth"`
 
@@ -78,6 +88,18 @@ type ExecutorTaskSpecData struct {
 	// groups (projects)
 	CachePrefix string `json:"cache_prefix,omitempty"`
 
+	// Timeout is the max duration of the task steps. 0 means no timeout
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// MaxStepLogSize is the max size in bytes of a step log. 0 means no limit
+	MaxStepLogSize int64 `json:"max_step_log_size,omitempty"`
+
+	// Retries is the number of times the failed task is executed again in a
+	// new pod, waiting RetryInterval, multiplied by RetryBackoff after every
+	// retry, between the attempts
+	Retries       int           `json:"retries,omitempty"`
+	RetryInterval time.Duration `json:"retry_interval,omitempty"`
+	RetryBackoff  float64       `json:"retry_backoff,omitempty"`
+
 	Steps Steps `json:"steps,omitempty"`
 }
 
@@ -88,9 +110,22 @@ type ExecutorTaskStatus struct {
 
 	FailError string `json:"fail_error,omitempty"`
 
+	// Attempt is the current task execution attempt, starting from 1
+	Attempt int `json:"attempt,omitempty"`
+
 	SetupStep ExecutorTaskStepStatus    `json:"setup_step,omitempty"`
 	Steps     []*ExecutorTaskStepStatus `json:"steps,omitempty"`
 
+	// RunMeta contains the run metadata set by the task steps
+	RunMeta map[string]string `json:"run_meta,omitempty"`
+
+	// Metrics contains the numeric metrics emitted by the task steps
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+
+	// ResourceUsage contains the resource usage of the task containers sampled
+	// by the executor. Nil when not supported by the executor driver
+	ResourceUsage *ResourceUsage `json:"resource_usage,omitempty"`
+
 	StartTime *time.Time `json:"start_time,omitempty"`
 	EndTime   *time.Time `json:"end_time,omitempty"`
 }
@@ -102,6 +137,37 @@ type ExecutorTaskStepStatus struct {
 	EndTime   *time.Time `json:"end_time,omitempty"`
 
 	ExitStatus *int `json:"exit_status,omitempty"`
+
+	// Transfer contains the stats of the data transferred from/to the
+	// runservice by the step (workspace archives and caches)
+	Transfer *TransferStats `json:"transfer,omitempty"`
+}
+
+type TransferStats struct {
+	Bytes    int64         `json:"bytes,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+func (s *TransferStats) Add(bytes int64, d time.Duration) {
+	s.Bytes += bytes
+	s.Duration += d
+}
+
+// ResourceUsage contains the resource usage of the task containers.
+// CPU values are in millicores, memory and disk values in bytes.
+type ResourceUsage struct {
+	// CPUTime is the total cpu time used by the task containers
+	CPUTime time.Duration `json:"cpu_time,omitempty"`
+	// MaxCPU is the max cpu usage between two samples
+	MaxCPU int64 `json:"max_cpu,omitempty"`
+	// Memory is the last sampled memory usage
+	Memory int64 `json:"memory,omitempty"`
+	// MaxMemory is the max sampled memory usage
+	MaxMemory int64 `json:"max_memory,omitempty"`
+	// MemoryLimit is the memory limit of the task containers. 0 means no limit
+	MemoryLimit int64 `json:"memory_limit,omitempty"`
+	// Disk is the last sampled disk usage of the task containers
+	Disk int64 `json:"disk,omitempty"`
 }
 
 type WorkspaceOperation struct {
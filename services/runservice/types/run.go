@@ -59,6 +59,18 @@ func RunResultFromStringSlice(slice []string) []RunResult {
 	return rss
 }
 
+// RunConcurrencyGroup defines the max number of concurrently running runs and
+// tasks of all the runs sharing the same concurrency group name. A zero value
+// means no limit.
+type RunConcurrencyGroup struct {
+	Name            string `json:"name,omitempty"`
+	MaxRunningRuns  int    `json:"max_running_runs,omitempty"`
+	MaxRunningTasks int    `json:"max_running_tasks,omitempty"`
+	// CancelInProgress stops the running runs and cancels the queued runs
+	// of the concurrency group when a new run is created
+	CancelInProgress bool `json:"cancel_in_progress,omitempty"`
+}
+
 // Run is the run status of a RUN. It should containt the status of the current
 // run. The run definition must live in the RunConfig and not here.
 type Run struct {
@@ -83,6 +95,18 @@ type Run struct {
 	// Annotations contain custom run annotations
 	Annotations map[string]string `json:"annotations,omitempty"`
 
+	// Labels contain custom run labels (i.e. team: backend) that can be used
+	// to filter the runs
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Meta contains the custom run metadata set by the run tasks steps (i.e.
+	// version numbers, image digests, deployment urls)
+	Meta map[string]string `json:"meta,omitempty"`
+
+	// Metrics contains the numeric metrics emitted by the run tasks steps (i.e.
+	// bundle size, test coverage) used to track their trend between runs
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+
 	// Phase represent the current run status. A run could be running but already
 	// marked as failed due to some tasks failed. The run will be marked as finished
 	// only then all the executor tasks are known to be really ended. This permits
@@ -96,6 +120,19 @@ type Run struct {
 	// Stop is used to signal from the scheduler when the run must be stopped
 	Stop bool `json:"stop,omitempty"`
 
+	// DependsOn are the ids of the runs that must succeed before this run is
+	// started. If one of them doesn't succeed the run is cancelled
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	// ConcurrencyGroups are the concurrency groups (i.e. the run project and
+	// organization) limiting the number of concurrently running runs and tasks
+	ConcurrencyGroups []*RunConcurrencyGroup `json:"concurrency_groups,omitempty"`
+
+	// CancelSuperseded reports that the run, when created, supersedes the
+	// older running and queued runs with the same group and name that must be
+	// stopped by the scheduler
+	CancelSuperseded bool `json:"cancel_superseded,omitempty"`
+
 	Tasks       map[string]*RunTask `json:"tasks,omitempty"`
 	EnqueueTime *time.Time          `json:"enqueue_time,omitempty"`
 	StartTime   *time.Time          `json:"start_time,omitempty"`
@@ -206,6 +243,9 @@ type RunTask struct {
 	// example to stores task approval metadata.
 	Annotations map[string]string `json:"annotations,omitempty"`
 
+	// Labels contain custom task labels
+	Labels map[string]string `json:"labels,omitempty"`
+
 	Skip bool `json:"skip,omitempty"`
 
 	WaitingApproval bool `json:"waiting_approval,omitempty"`
@@ -218,6 +258,18 @@ type RunTask struct {
 	WorkspaceArchives      []int               `json:"workspace_archives,omitempty"`
 	WorkspaceArchivesPhase []RunTaskFetchPhase `json:"workspace_archives_phase,omitempty"`
 
+	// ResourceUsage is the last resource usage of the task containers reported
+	// by the executor
+	ResourceUsage *ResourceUsage `json:"resource_usage,omitempty"`
+
+	// FailError is the reason of the task failure reported by the executor
+	// when not caused by a step exit code (i.e. a task or step timeout)
+	FailError string `json:"fail_error,omitempty"`
+
+	// Attempt is the task execution attempt reported by the executor. It's
+	// greater than 1 when the task has been retried
+	Attempt int `json:"attempt,omitempty"`
+
 	StartTime *time.Time `json:"start_time,omitempty"`
 	EndTime   *time.Time `json:"end_time,omitempty"`
 }
@@ -254,6 +306,8 @@ type RunTaskStep struct {
 
 	StartTime *time.Time `json:"start_time,omitempty"`
 	EndTime   *time.Time `json:"end_time,omitempty"`
+
+	Transfer *TransferStats `json:"transfer,omitempty"`
 }
 
 func NewRun() *Run {
@@ -2,6 +2,7 @@ package types
 
 import (
 	"encoding/json"
+	"time"
 
 	"agola.io/agola/internal/errors"
 	stypes "agola.io/agola/services/types"
@@ -42,6 +43,9 @@ type RunConfig struct {
 	// easily return them without loading RunConfig from the lts
 	Annotations map[string]string `json:"annotations,omitempty"`
 
+	// Labels contain custom run labels used for querying
+	Labels map[string]string `json:"labels,omitempty"`
+
 	// StaticEnvironment contains all environment variables that won't change when
 	// generating a new run (like COMMIT_SHA, BRANCH, REPOSITORY_URL etc...)
 	StaticEnvironment map[string]string `json:"static_environment,omitempty"`
@@ -55,6 +59,48 @@ type RunConfig struct {
 
 	// CacheGroup is the cache group where the run caches belongs
 	CacheGroup string `json:"cache_group,omitempty"`
+
+	// RepoTokenPath is the gitserver repository cloned by the run tasks. When
+	// defined, every time a task is sent to an executor, a new token granting
+	// read access to it is provided in the task environment
+	RepoTokenPath string `json:"repo_token_path,omitempty"`
+
+	// Timeout is the max run duration, when exceeded the run is stopped. 0
+	// means no timeout
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// MaxStepLogSize is the max size in bytes of a step log. 0 means no limit
+	MaxStepLogSize int64 `json:"max_step_log_size,omitempty"`
+
+	// FailFast, when the run fails, stops the running tasks and cancels the
+	// not started ones, excluding the tasks marked as always run
+	FailFast bool `json:"fail_fast,omitempty"`
+
+	// Triggers are the downstream runs created in other projects when the run
+	// finishes successfully
+	Triggers []*RunConfigTrigger `json:"triggers,omitempty"`
+
+	// SealedValues are the sealed values resolved from the project secrets
+	// when creating the run, keyed by the sealed value. Only these values are
+	// decrypted when generating the executor tasks, every other sealed value
+	// (i.e. a value copied in the config) is kept as is
+	SealedValues map[string]SealedValue `json:"sealed_values,omitempty"`
+}
+
+// SealedValue is the secret where a sealed value was resolved from
+type SealedValue struct {
+	// ParentID is the id of the project or project group owning the secret
+	ParentID   string `json:"parent_id,omitempty"`
+	SecretName string `json:"secret_name,omitempty"`
+}
+
+// RunConfigTrigger defines a run created in another project on the provided
+// branch or tag with the provided variables
+type RunConfigTrigger struct {
+	Project   string            `json:"project,omitempty"`
+	Branch    string            `json:"branch,omitempty"`
+	Tag       string            `json:"tag,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
 }
 
 func (rc *RunConfig) DeepCopy() *RunConfig {
@@ -80,6 +126,44 @@ type RunConfigTask struct {
 	NeedsApproval        bool                            `json:"needs_approval,omitempty"`
 	Skip                 bool                            `json:"skip,omitempty"`
 	DockerRegistriesAuth map[string]DockerRegistryAuth   `json:"docker_registries_auth"`
+	// Labels contain custom task labels used for querying
+	Labels map[string]string `json:"labels,omitempty"`
+	// SkipWorkspace reports that the task doesn't use the workspace, so no
+	// workspace operations are needed
+	SkipWorkspace bool `json:"skip_workspace,omitempty"`
+	// Timeout is the max task duration. 0 means no timeout
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// PersistentWorkspace reports that the task restores the whole working dir
+	// saved by its parents and saves it at the end
+	PersistentWorkspace bool `json:"persistent_workspace,omitempty"`
+	// Retries is the number of times the executor executes again the failed
+	// task, waiting RetryInterval, multiplied by RetryBackoff after every
+	// retry, between the attempts
+	Retries       int           `json:"retries,omitempty"`
+	RetryInterval time.Duration `json:"retry_interval,omitempty"`
+	RetryBackoff  float64       `json:"retry_backoff,omitempty"`
+	// ApprovalUsers and ApprovalOrgRoles restrict the users allowed to
+	// approve the task to the listed user names and project organization
+	// member roles. When both are empty the project owners can approve it
+	ApprovalUsers    []string `json:"approval_users,omitempty"`
+	ApprovalOrgRoles []string `json:"approval_org_roles,omitempty"`
+	// MinApprovals is the number of approvals required to approve the task.
+	// 0 means 1
+	MinApprovals int `json:"min_approvals,omitempty"`
+	// AlwaysRun marks the task as executed also when the run has already
+	// failed: it isn't cancelled or stopped by the run fail fast. Tasks
+	// depending on their parents with the always condition are also
+	// exempted from the fail fast
+	AlwaysRun bool `json:"always_run,omitempty"`
+}
+
+// RequiredApprovals returns the number of approvals required to approve the
+// task
+func (rct *RunConfigTask) RequiredApprovals() int {
+	if rct.MinApprovals > 0 {
+		return rct.MinApprovals
+	}
+	return 1
 }
 
 func (rct *RunConfigTask) DeepCopy() *RunConfigTask {
@@ -90,12 +174,67 @@ func (rct *RunConfigTask) DeepCopy() *RunConfigTask {
 	return nrct.(*RunConfigTask)
 }
 
+// RequiredExecutorFeatures returns the executor features required to execute
+// the task
+func (rct *RunConfigTask) RequiredExecutorFeatures() []ExecutorFeature {
+	features := []ExecutorFeature{}
+	if rct.PersistentWorkspace {
+		features = append(features, ExecutorFeatureWorkspaceOverwrite)
+	}
+	if rct.Retries > 0 {
+		features = append(features, ExecutorFeatureTaskRetries)
+	}
+	var usesCaches, usesArtifacts, usesTestReports, usesStepRetries, usesStepRetryBackoff, usesStepTimeouts bool
+	for _, s := range rct.Steps {
+		if bs := StepBase(s); bs != nil && bs.Timeout > 0 {
+			usesStepTimeouts = true
+		}
+		switch s := s.(type) {
+		case *RunStep:
+			if s.Retries > 0 {
+				usesStepRetries = true
+				if s.RetryBackoff > 1 {
+					usesStepRetryBackoff = true
+				}
+			}
+		case *SaveCacheStep, *RestoreCacheStep:
+			usesCaches = true
+		case *SaveArtifactsStep, *RestoreArtifactsStep:
+			usesArtifacts = true
+		case *TestReportStep:
+			usesTestReports = true
+		}
+	}
+	if usesCaches {
+		features = append(features, ExecutorFeatureCacheGroups)
+	}
+	if usesArtifacts {
+		features = append(features, ExecutorFeatureArtifacts)
+	}
+	if usesTestReports {
+		features = append(features, ExecutorFeatureTestReports)
+	}
+	if usesStepRetries {
+		features = append(features, ExecutorFeatureStepRetries)
+	}
+	if usesStepRetryBackoff {
+		features = append(features, ExecutorFeatureStepRetryBackoff)
+	}
+	if usesStepTimeouts {
+		features = append(features, ExecutorFeatureStepTimeouts)
+	}
+	return features
+}
+
 type RunConfigTaskDependCondition string
 
 const (
 	RunConfigTaskDependConditionOnSuccess RunConfigTaskDependCondition = "on_success"
 	RunConfigTaskDependConditionOnFailure RunConfigTaskDependCondition = "on_failure"
 	RunConfigTaskDependConditionOnSkipped RunConfigTaskDependCondition = "on_skipped"
+	// RunConfigTaskDependConditionAlways matches a parent task finished with
+	// any status
+	RunConfigTaskDependConditionAlways RunConfigTaskDependCondition = "always"
 )
 
 type RunConfigTaskDepend struct {
@@ -106,7 +245,18 @@ type RunConfigTaskDepend struct {
 type RuntimeType string
 
 const (
-	RuntimeTypePod RuntimeType = "pod"
+	RuntimeTypePod  RuntimeType = "pod"
+	RuntimeTypeHost RuntimeType = "host"
+)
+
+type ExecutorAffinity string
+
+const (
+	// ExecutorAffinityRun prefers executors running other tasks of the same run
+	ExecutorAffinityRun ExecutorAffinity = "affinity"
+	// ExecutorAntiAffinityRun prefers executors not running other tasks of the
+	// same run
+	ExecutorAntiAffinityRun ExecutorAffinity = "anti_affinity"
 )
 
 type DockerRegistryAuthType string
@@ -114,6 +264,7 @@ type DockerRegistryAuthType string
 const (
 	DockerRegistryAuthTypeBasic       DockerRegistryAuthType = "basic"
 	DockerRegistryAuthTypeEncodedAuth DockerRegistryAuthType = "encodedauth"
+	DockerRegistryAuthTypeToken       DockerRegistryAuthType = "token"
 )
 
 type DockerRegistryAuth struct {
@@ -126,6 +277,9 @@ type DockerRegistryAuth struct {
 	// encoded auth string
 	Auth string `json:"auth"`
 
+	// registry bearer token
+	Token string `json:"token,omitempty"`
+
 	// future auths like aws ecr auth
 }
 
@@ -133,15 +287,97 @@ type Runtime struct {
 	Type       RuntimeType  `json:"type,omitempty"`
 	Arch       stypes.Arch  `json:"arch,omitempty"`
 	Containers []*Container `json:"containers,omitempty"`
+	// ExecutorLabels are the labels that the executor must have to execute
+	// the task
+	ExecutorLabels map[string]string `json:"executor_labels,omitempty"`
+	// ExecutorAffinity is a scheduling hint to prefer or avoid the executors
+	// running other tasks of the same run. When no preferred executor is
+	// available any other suitable executor is chosen
+	ExecutorAffinity ExecutorAffinity `json:"executor_affinity,omitempty"`
+	// GPUs is the number of GPUs required by the task main container
+	GPUs int `json:"gpus,omitempty"`
+	// NodeSelector are the labels of the nodes where the task pod can be
+	// scheduled. Used only by the k8s driver
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	// Tolerations are the taints tolerated by the task pod. Used only by the
+	// k8s driver
+	Tolerations []Toleration `json:"tolerations,omitempty"`
+}
+
+type Toleration struct {
+	Key      string `json:"key,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Effect   string `json:"effect,omitempty"`
 }
 
 type Container struct {
+	Name        string            `json:"name,omitempty"`
 	Image       string            `json:"image,omitempty"`
 	Environment map[string]string `json:"environment,omitempty"`
 	User        string            `json:"user,omitempty"`
 	Privileged  bool              `json:"privileged"`
 	Entrypoint  string            `json:"entrypoint"`
-	Volumes     []Volume          `json:"volumes"`
+	// Args overrides the container image command
+	Args      []string  `json:"args,omitempty"`
+	Volumes   []Volume  `json:"volumes"`
+	Resources Resources `json:"resources"`
+	// PullPolicy is the container image pull policy. When empty the executor
+	// default pull policy is used
+	PullPolicy PullPolicy `json:"pull_policy,omitempty"`
+	// Readiness is the readiness check of a service container
+	Readiness *ContainerReadiness `json:"readiness,omitempty"`
+	// CapAdd and CapDrop are the linux capabilities (without the CAP_ prefix)
+	// added to or dropped from the container default ones
+	CapAdd  []string `json:"cap_add,omitempty"`
+	CapDrop []string `json:"cap_drop,omitempty"`
+	// Devices are the host devices paths made available inside the container
+	Devices []string `json:"devices,omitempty"`
+}
+
+// RequiresPrivileges reports if the container requires privileges that must
+// be allowed by the executor: privileged mode, added capabilities or host
+// devices
+func (c *Container) RequiresPrivileges() bool {
+	return c.Privileged || len(c.CapAdd) > 0 || len(c.Devices) > 0
+}
+
+// ContainerReadiness defines the check executed by the executor before running
+// the task steps. Only one of TCPPort and Command is defined
+type ContainerReadiness struct {
+	TCPPort int    `json:"tcp_port,omitempty"`
+	Command string `json:"command,omitempty"`
+	// Timeout is the max time to wait for the container to be ready. 0 means
+	// the executor default
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+type PullPolicy string
+
+const (
+	PullPolicyAlways       PullPolicy = "always"
+	PullPolicyIfNotPresent PullPolicy = "if-not-present"
+	PullPolicyNever        PullPolicy = "never"
+)
+
+func IsValidPullPolicy(p PullPolicy) bool {
+	switch p {
+	case PullPolicyAlways, PullPolicyIfNotPresent, PullPolicyNever:
+		return true
+	}
+	return false
+}
+
+type Resources struct {
+	Requests ResourceList `json:"requests"`
+	Limits   ResourceList `json:"limits"`
+}
+
+// ResourceList defines the cpu (in millicores) and memory (in bytes)
+// resources. Zero values mean not defined.
+type ResourceList struct {
+	CPU    int64 `json:"cpu,omitempty"`
+	Memory int64 `json:"memory,omitempty"`
 }
 
 type Volume struct {
@@ -161,6 +397,18 @@ type Steps []Step
 type BaseStep struct {
 	Type string `json:"type,omitempty"`
 	Name string `json:"name,omitempty"`
+	// Timeout is the max step duration. 0 means no timeout
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+func (s *BaseStep) baseStep() *BaseStep { return s }
+
+// StepBase returns the base fields of a step
+func StepBase(s Step) *BaseStep {
+	if bs, ok := s.(interface{ baseStep() *BaseStep }); ok {
+		return bs.baseStep()
+	}
+	return nil
 }
 
 type RunStep struct {
@@ -170,6 +418,12 @@ type RunStep struct {
 	WorkingDir  string            `json:"working_dir,omitempty"`
 	Shell       string            `json:"shell,omitempty"`
 	Tty         *bool             `json:"tty,omitempty"`
+	// Retries is the number of times the toolbox executes again the command
+	// when it fails, waiting RetryInterval, multiplied by RetryBackoff after
+	// every retry, between the attempts
+	Retries       int           `json:"retries,omitempty"`
+	RetryInterval time.Duration `json:"retry_interval,omitempty"`
+	RetryBackoff  float64       `json:"retry_backoff,omitempty"`
 }
 
 type SaveContent struct {
@@ -200,6 +454,23 @@ type RestoreCacheStep struct {
 	DestDir string   `json:"dest_dir,omitempty"`
 }
 
+type SaveArtifactsStep struct {
+	BaseStep
+	Contents []SaveContent `json:"contents,omitempty"`
+}
+
+type RestoreArtifactsStep struct {
+	BaseStep
+	// TaskIDs are the ids of the run tasks whose artifacts are restored
+	TaskIDs []string `json:"task_ids,omitempty"`
+	DestDir string   `json:"dest_dir,omitempty"`
+}
+
+type TestReportStep struct {
+	BaseStep
+	Paths []string `json:"paths,omitempty"`
+}
+
 func (et *Steps) UnmarshalJSON(b []byte) error {
 	type rawSteps []json.RawMessage
 
@@ -248,6 +519,24 @@ func (et *Steps) UnmarshalJSON(b []byte) error {
 				return errors.WithStack(err)
 			}
 			steps[i] = &s
+		case "save_artifacts":
+			var s SaveArtifactsStep
+			if err := json.Unmarshal(step, &s); err != nil {
+				return errors.WithStack(err)
+			}
+			steps[i] = &s
+		case "restore_artifacts":
+			var s RestoreArtifactsStep
+			if err := json.Unmarshal(step, &s); err != nil {
+				return errors.WithStack(err)
+			}
+			steps[i] = &s
+		case "test_report":
+			var s TestReportStep
+			if err := json.Unmarshal(step, &s); err != nil {
+				return errors.WithStack(err)
+			}
+			steps[i] = &s
 		}
 	}
 
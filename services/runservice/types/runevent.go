@@ -17,9 +17,15 @@ type RunEvent struct {
 
 	Sequence uint64
 
-	RunID  string
-	Phase  RunPhase
-	Result RunResult
+	RunID string
+	// RunNumber is the run number inside its group (i.e. the project run
+	// number)
+	RunNumber uint64
+	Phase     RunPhase
+	Result    RunResult
+
+	// WaitingApproval reports if the run has tasks waiting for approval
+	WaitingApproval bool
 }
 
 func NewRunEvent() *RunEvent {
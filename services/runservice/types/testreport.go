@@ -0,0 +1,39 @@
+package types
+
+import (
+	"time"
+)
+
+// MaxTestReportFailedTests is the max number of failed test cases kept in a
+// test report
+const MaxTestReportFailedTests = 100
+
+// TestReport is the summary of the JUnit/XUnit test reports saved by a run
+// task
+type TestReport struct {
+	Tests    int           `json:"tests"`
+	Failures int           `json:"failures"`
+	Errors   int           `json:"errors"`
+	Skipped  int           `json:"skipped"`
+	Duration time.Duration `json:"duration"`
+
+	// FailedTests are the failed test cases, limited to
+	// MaxTestReportFailedTests
+	FailedTests []*TestCaseFailure `json:"failed_tests"`
+}
+
+// Passed returns the number of passed test cases
+func (r *TestReport) Passed() int {
+	return r.Tests - r.Failures - r.Errors - r.Skipped
+}
+
+// TestCaseFailure is a failed test case
+type TestCaseFailure struct {
+	Suite     string `json:"suite"`
+	ClassName string `json:"class_name"`
+	Name      string `json:"name"`
+	// Error reports if the test case had an unexpected error instead of a
+	// failed assertion
+	Error   bool   `json:"error"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+type OS string
+
+const (
+	OSLinux   OS = "linux"
+	OSWindows OS = "windows"
+)
+
+var ValidOSes = []OS{OSLinux, OSWindows}
+
+func IsValidOS(os OS) bool {
+	for _, vo := range ValidOSes {
+		if os == vo {
+			return true
+		}
+	}
+	return false
+}
+
+func OSFromString(os string) OS {
+	for _, vo := range ValidOSes {
+		if os == string(vo) {
+			return vo
+		}
+	}
+	return ""
+}
@@ -16,14 +16,34 @@ package types
 
 import (
 	"regexp"
+	"strings"
 
 	itypes "agola.io/agola/internal/services/types"
+
+	"github.com/bmatcuk/doublestar"
 )
 
 type When struct {
 	Branch *WhenConditions `json:"branch,omitempty"`
 	Tag    *WhenConditions `json:"tag,omitempty"`
 	Ref    *WhenConditions `json:"ref,omitempty"`
+
+	// Paths are matched against the files changed by the commits that
+	// triggered the run. Simple conditions are glob patterns
+	Paths *WhenConditions `json:"paths,omitempty"`
+
+	// Message conditions are matched against the commit message (or the pull
+	// request title). Simple conditions match when contained in the message
+	Message *WhenConditions `json:"message,omitempty"`
+
+	// Expression is a boolean expression over the run annotations and
+	// variables. See MatchWhenExpression
+	Expression string `json:"expression,omitempty"`
+
+	// Schedule conditions are matched against the name of the project
+	// schedule that created the run. Scheduled runs are also matched by the
+	// branch conditions against the schedule branch
+	Schedule *WhenConditions `json:"schedule,omitempty"`
 }
 
 type WhenConditions struct {
@@ -43,9 +63,11 @@ type WhenCondition struct {
 	Match string            `json:"match,omitempty"`
 }
 
-func MatchWhen(when *When, refType itypes.RunRefType, branch, tag, ref string) bool {
+func MatchWhen(when *When, refType itypes.RunRefType, branch, tag, ref, schedule string) bool {
 	include := true
-	if when != nil {
+	// a when with only paths, message or expression conditions doesn't filter
+	// on the refs
+	if when != nil && (when.Branch != nil || when.Tag != nil || when.Ref != nil || when.Schedule != nil || (when.Paths == nil && when.Message == nil && when.Expression == "")) {
 		include = false
 		// test only if branch is not empty, if empty mean that we are not in a branch
 		if refType == itypes.RunRefTypeBranch && when.Branch != nil && branch != "" {
@@ -67,6 +89,18 @@ func MatchWhen(when *When, refType itypes.RunRefType, branch, tag, ref string) b
 				include = false
 			}
 		}
+		// scheduled runs are runs of the schedule branch: the branch
+		// conditions are matched against it and the schedule conditions, when
+		// defined, also filter them by schedule name
+		if refType == itypes.RunRefTypeSchedule && (when.Branch != nil || when.Schedule != nil) {
+			include = true
+			if when.Branch != nil && !matchWhenConditions(when.Branch, branch) {
+				include = false
+			}
+			if when.Schedule != nil && !matchWhenConditions(when.Schedule, schedule) {
+				include = false
+			}
+		}
 		// we assume that ref always have a value
 		if when.Ref != nil {
 			// first check includes and override with excludes
@@ -82,6 +116,116 @@ func MatchWhen(when *When, refType itypes.RunRefType, branch, tag, ref string) b
 	return include
 }
 
+// matchWhenConditions reports if the value matches the includes and doesn't
+// match the excludes. An empty value never matches
+func matchWhenConditions(conditions *WhenConditions, s string) bool {
+	if s == "" {
+		return false
+	}
+	return matchCondition(conditions.Include, s) && !matchCondition(conditions.Exclude, s)
+}
+
+// MatchWhenPaths reports if the changed files match the when paths
+// conditions: at least one changed file must match the includes (if any) and
+// not match the excludes. When the changed files are unknown (nil) the
+// conditions always match.
+func MatchWhenPaths(when *When, changedFiles []string) bool {
+	if when == nil || when.Paths == nil || changedFiles == nil {
+		return true
+	}
+
+	for _, f := range changedFiles {
+		if len(when.Paths.Include) > 0 && !matchPathCondition(when.Paths.Include, f) {
+			continue
+		}
+		if matchPathCondition(when.Paths.Exclude, f) {
+			continue
+		}
+		return true
+	}
+
+	return false
+}
+
+// MatchWhenMessage reports if the commit message matches the when message
+// conditions: it must match the includes (if any) and not match the excludes
+func MatchWhenMessage(when *When, message string) bool {
+	if when == nil || when.Message == nil {
+		return true
+	}
+
+	if len(when.Message.Include) > 0 && !matchMessageCondition(when.Message.Include, message) {
+		return false
+	}
+
+	return !matchMessageCondition(when.Message.Exclude, message)
+}
+
+// MatchProtectedRef reports if the branch or tag matches one of the protected
+// branches or tags glob patterns. Pull requests refs are never protected.
+func MatchProtectedRef(protectedBranches, protectedTags []string, refType itypes.RunRefType, branch, tag string) bool {
+	var patterns []string
+	var name string
+	switch refType {
+	case itypes.RunRefTypeBranch, itypes.RunRefTypeSchedule:
+		patterns, name = protectedBranches, branch
+	case itypes.RunRefTypeTag:
+		patterns, name = protectedTags, tag
+	default:
+		return false
+	}
+
+	for _, pattern := range patterns {
+		// patterns are validated when saving the project
+		if ok, _ := doublestar.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchPathCondition(conds []WhenCondition, p string) bool {
+	for _, cond := range conds {
+		switch cond.Type {
+		case WhenConditionTypeSimple:
+			// patterns are validated when parsing the config
+			if ok, _ := doublestar.Match(cond.Match, p); ok {
+				return true
+			}
+		case WhenConditionTypeRegExp:
+			re, err := regexp.Compile(cond.Match)
+			if err != nil {
+				panic(err)
+			}
+			if re.MatchString(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchMessageCondition(conds []WhenCondition, message string) bool {
+	for _, cond := range conds {
+		switch cond.Type {
+		case WhenConditionTypeSimple:
+			if strings.Contains(message, cond.Match) {
+				return true
+			}
+		case WhenConditionTypeRegExp:
+			re, err := regexp.Compile(cond.Match)
+			if err != nil {
+				panic(err)
+			}
+			if re.MatchString(message) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func matchCondition(conds []WhenCondition, s string) bool {
 	for _, cond := range conds {
 		switch cond.Type {
@@ -22,13 +22,14 @@ import (
 
 func TestMatchWhen(t *testing.T) {
 	tests := []struct {
-		name    string
-		when    *When
-		refType itypes.RunRefType
-		branch  string
-		tag     string
-		ref     string
-		out     bool
+		name     string
+		when     *When
+		refType  itypes.RunRefType
+		branch   string
+		tag      string
+		ref      string
+		schedule string
+		out      bool
 	}{
 		{
 			name: "test no when, should always match",
@@ -313,11 +314,376 @@ func TestMatchWhen(t *testing.T) {
 			tag: "master",
 			out: false,
 		},
+		{
+			name: "test only paths when, should match any ref",
+			when: &When{
+				Paths: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "docs/**"},
+					},
+				},
+			},
+			refType: itypes.RunRefTypeBranch,
+			branch:  "master",
+			out:     true,
+		},
+		{
+			name: "test schedule when include, should match",
+			when: &When{
+				Schedule: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "nightly"},
+					},
+				},
+			},
+			refType:  itypes.RunRefTypeSchedule,
+			branch:   "master",
+			schedule: "nightly",
+			out:      true,
+		},
+		{
+			name: "test schedule when include on a branch push, should not match",
+			when: &When{
+				Schedule: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "nightly"},
+					},
+				},
+			},
+			refType: itypes.RunRefTypeBranch,
+			branch:  "master",
+			out:     false,
+		},
+		{
+			name: "test branch when include on a scheduled run, should match",
+			when: &When{
+				Branch: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "master"},
+					},
+				},
+			},
+			refType:  itypes.RunRefTypeSchedule,
+			branch:   "master",
+			schedule: "nightly",
+			out:      true,
+		},
+		{
+			name: "test branch when include on a scheduled run of another branch, should not match",
+			when: &When{
+				Branch: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "master"},
+					},
+				},
+			},
+			refType:  itypes.RunRefTypeSchedule,
+			branch:   "develop",
+			schedule: "nightly",
+			out:      false,
+		},
+		{
+			name: "test branch exclude on a scheduled run, should not match",
+			when: &When{
+				Branch: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeRegExp, Match: ".*"},
+					},
+					Exclude: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "master"},
+					},
+				},
+			},
+			refType:  itypes.RunRefTypeSchedule,
+			branch:   "master",
+			schedule: "nightly",
+			out:      false,
+		},
+		{
+			name: "test branch and schedule when include on a scheduled run, should match",
+			when: &When{
+				Branch: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "master"},
+					},
+				},
+				Schedule: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "nightly"},
+					},
+				},
+			},
+			refType:  itypes.RunRefTypeSchedule,
+			branch:   "master",
+			schedule: "nightly",
+			out:      true,
+		},
+		{
+			name: "test branch and schedule when include on a scheduled run of another branch, should not match",
+			when: &When{
+				Branch: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "master"},
+					},
+				},
+				Schedule: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "nightly"},
+					},
+				},
+			},
+			refType:  itypes.RunRefTypeSchedule,
+			branch:   "develop",
+			schedule: "nightly",
+			out:      false,
+		},
+		{
+			name: "test branch and schedule when include on a scheduled run of another schedule, should not match",
+			when: &When{
+				Branch: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "master"},
+					},
+				},
+				Schedule: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "nightly"},
+					},
+				},
+			},
+			refType:  itypes.RunRefTypeSchedule,
+			branch:   "master",
+			schedule: "weekly",
+			out:      false,
+		},
+		{
+			name: "test tag when include on a scheduled run, should not match",
+			when: &When{
+				Tag: &WhenConditions{
+					Include: []WhenCondition{
+						{Type: WhenConditionTypeRegExp, Match: ".*"},
+					},
+				},
+			},
+			refType:  itypes.RunRefTypeSchedule,
+			branch:   "master",
+			schedule: "nightly",
+			out:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := MatchWhen(tt.when, tt.refType, tt.branch, tt.tag, tt.ref, tt.schedule)
+			if tt.out != out {
+				t.Fatalf("expected match: %t, got: %t", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestMatchWhenPaths(t *testing.T) {
+	when := &When{
+		Paths: &WhenConditions{
+			Include: []WhenCondition{
+				{Type: WhenConditionTypeSimple, Match: "src/**"},
+				{Type: WhenConditionTypeRegExp, Match: `^go\.(mod|sum)$`},
+			},
+			Exclude: []WhenCondition{
+				{Type: WhenConditionTypeSimple, Match: "**/*.md"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		when         *When
+		changedFiles []string
+		out          bool
+	}{
+		{
+			name:         "test no paths conditions, should always match",
+			when:         &When{},
+			changedFiles: []string{"README.md"},
+			out:          true,
+		},
+		{
+			name:         "test unknown changed files, should match",
+			when:         when,
+			changedFiles: nil,
+			out:          true,
+		},
+		{
+			name:         "test included glob",
+			when:         when,
+			changedFiles: []string{"README.md", "src/cmd/main.go"},
+			out:          true,
+		},
+		{
+			name:         "test included regexp",
+			when:         when,
+			changedFiles: []string{"go.sum"},
+			out:          true,
+		},
+		{
+			name:         "test only excluded files, should not match",
+			when:         when,
+			changedFiles: []string{"src/README.md", "docs/index.md"},
+			out:          false,
+		},
+		{
+			name: "test only excludes",
+			when: &When{
+				Paths: &WhenConditions{
+					Exclude: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "docs/**"},
+					},
+				},
+			},
+			changedFiles: []string{"docs/index.md"},
+			out:          false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := MatchWhenPaths(tt.when, tt.changedFiles)
+			if tt.out != out {
+				t.Fatalf("expected match: %t, got: %t", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestMatchWhenMessage(t *testing.T) {
+	when := &When{
+		Message: &WhenConditions{
+			Include: []WhenCondition{
+				{Type: WhenConditionTypeSimple, Match: "[deploy]"},
+				{Type: WhenConditionTypeRegExp, Match: `^release: v\d+`},
+			},
+			Exclude: []WhenCondition{
+				{Type: WhenConditionTypeRegExp, Match: `(?i)\bwip\b`},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		when    *When
+		message string
+		out     bool
+	}{
+		{
+			name:    "test no message conditions, should always match",
+			when:    &When{},
+			message: "fix typo",
+			out:     true,
+		},
+		{
+			name:    "test included simple condition contained in the message",
+			when:    when,
+			message: "update docs [deploy]",
+			out:     true,
+		},
+		{
+			name:    "test included regexp",
+			when:    when,
+			message: "release: v1.2.0",
+			out:     true,
+		},
+		{
+			name:    "test not included message",
+			when:    when,
+			message: "fix typo",
+			out:     false,
+		},
+		{
+			name:    "test included and excluded message, should not match",
+			when:    when,
+			message: "WIP: new feature [deploy]",
+			out:     false,
+		},
+		{
+			name: "test only excludes",
+			when: &When{
+				Message: &WhenConditions{
+					Exclude: []WhenCondition{
+						{Type: WhenConditionTypeSimple, Match: "[skip tests]"},
+					},
+				},
+			},
+			message: "fix typo",
+			out:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := MatchWhenMessage(tt.when, tt.message)
+			if tt.out != out {
+				t.Fatalf("expected match: %t, got: %t", tt.out, out)
+			}
+			// message conditions alone don't filter on the refs
+			if tt.when.Message != nil && !MatchWhen(tt.when, itypes.RunRefTypeBranch, "master", "", "refs/heads/master", "") {
+				t.Fatalf("expected when to match the ref")
+			}
+		})
+	}
+}
+
+func TestMatchProtectedRef(t *testing.T) {
+	protectedBranches := []string{"master", "release/*"}
+	protectedTags := []string{"v*"}
+
+	tests := []struct {
+		name    string
+		refType itypes.RunRefType
+		branch  string
+		tag     string
+		out     bool
+	}{
+		{
+			name:    "test protected branch",
+			refType: itypes.RunRefTypeBranch,
+			branch:  "master",
+			out:     true,
+		},
+		{
+			name:    "test protected branch glob",
+			refType: itypes.RunRefTypeBranch,
+			branch:  "release/1.0",
+			out:     true,
+		},
+		{
+			name:    "test not protected branch",
+			refType: itypes.RunRefTypeBranch,
+			branch:  "feature/release/1.0",
+			out:     false,
+		},
+		{
+			name:    "test protected tag",
+			refType: itypes.RunRefTypeTag,
+			tag:     "v1.0.0",
+			out:     true,
+		},
+		{
+			name:    "test tag matching only a protected branch",
+			refType: itypes.RunRefTypeTag,
+			tag:     "master",
+			out:     false,
+		},
+		{
+			name:    "test pull request, never protected",
+			refType: itypes.RunRefTypePullRequest,
+			branch:  "master",
+			out:     false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			out := MatchWhen(tt.when, tt.refType, tt.branch, tt.tag, tt.ref)
+			out := MatchProtectedRef(protectedBranches, protectedTags, tt.refType, tt.branch, tt.tag)
 			if tt.out != out {
 				t.Fatalf("expected match: %t, got: %t", tt.out, out)
 			}
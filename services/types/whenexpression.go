@@ -0,0 +1,373 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"agola.io/agola/internal/errors"
+)
+
+// A when expression is a boolean expression evaluated over the run
+// annotations and variables, i.e.:
+//
+//   annotations.pull_request_id != '' && variables.deploy == 'true'
+//
+// Supported operators are ==, != , =~ and !~ (matching a regular expression
+// literal), &&, ||, ! and parentheses. Operands are string literals (single or
+// double quoted), true, false and the annotations.NAME and variables.NAME
+// references (an undefined reference is an empty string). A string is true
+// when not empty.
+
+// ValidateWhenExpression reports if the when expression is valid
+func ValidateWhenExpression(expression string) error {
+	_, err := parseWhenExpression(expression)
+	return err
+}
+
+// MatchWhenExpression reports if the when expression evaluates to true with the
+// provided annotations and variables
+func MatchWhenExpression(when *When, annotations, variables map[string]string) bool {
+	if when == nil || when.Expression == "" {
+		return true
+	}
+
+	// expressions are validated when parsing the config
+	e, err := parseWhenExpression(when.Expression)
+	if err != nil {
+		return false
+	}
+
+	return e.eval(&exprEnv{annotations: annotations, variables: variables}).bool()
+}
+
+type exprEnv struct {
+	annotations map[string]string
+	variables   map[string]string
+}
+
+// exprValue is a string or a boolean value
+type exprValue struct {
+	s      string
+	b      bool
+	isBool bool
+}
+
+func (v exprValue) bool() bool {
+	if v.isBool {
+		return v.b
+	}
+	return v.s != ""
+}
+
+func (v exprValue) string() string {
+	if v.isBool {
+		return fmt.Sprintf("%t", v.b)
+	}
+	return v.s
+}
+
+func boolValue(b bool) exprValue {
+	return exprValue{b: b, isBool: true}
+}
+
+type expr interface {
+	eval(env *exprEnv) exprValue
+}
+
+type literalExpr struct {
+	v exprValue
+}
+
+func (e *literalExpr) eval(env *exprEnv) exprValue {
+	return e.v
+}
+
+type refExpr struct {
+	kind string
+	name string
+}
+
+func (e *refExpr) eval(env *exprEnv) exprValue {
+	switch e.kind {
+	case "annotations":
+		return exprValue{s: env.annotations[e.name]}
+	default:
+		return exprValue{s: env.variables[e.name]}
+	}
+}
+
+type notExpr struct {
+	e expr
+}
+
+func (e *notExpr) eval(env *exprEnv) exprValue {
+	return boolValue(!e.e.eval(env).bool())
+}
+
+type binaryExpr struct {
+	op   string
+	l, r expr
+	re   *regexp.Regexp
+}
+
+func (e *binaryExpr) eval(env *exprEnv) exprValue {
+	switch e.op {
+	case "&&":
+		return boolValue(e.l.eval(env).bool() && e.r.eval(env).bool())
+	case "||":
+		return boolValue(e.l.eval(env).bool() || e.r.eval(env).bool())
+	case "==":
+		return boolValue(e.l.eval(env).string() == e.r.eval(env).string())
+	case "!=":
+		return boolValue(e.l.eval(env).string() != e.r.eval(env).string())
+	case "=~":
+		return boolValue(e.re.MatchString(e.l.eval(env).string()))
+	case "!~":
+		return boolValue(!e.re.MatchString(e.l.eval(env).string()))
+	default:
+		panic(errors.Errorf("unknown operator %q", e.op))
+	}
+}
+
+type exprTokenType int
+
+const (
+	exprTokenEOF exprTokenType = iota
+	exprTokenOp
+	exprTokenString
+	exprTokenIdent
+)
+
+type exprToken struct {
+	typ exprTokenType
+	val string
+	pos int
+}
+
+var exprOps = []string{"&&", "||", "==", "!=", "=~", "!~", "!", "(", ")"}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '-' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func tokenizeWhenExpression(s string) ([]exprToken, error) {
+	tokens := []exprToken{}
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, errors.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, exprToken{typ: exprTokenString, val: s[i+1 : i+1+end], pos: i})
+			i += end + 2
+			continue
+
+		case isIdentChar(c):
+			start := i
+			for i < len(s) && isIdentChar(s[i]) {
+				i++
+			}
+			tokens = append(tokens, exprToken{typ: exprTokenIdent, val: s[start:i], pos: start})
+			continue
+		}
+
+		found := false
+		for _, op := range exprOps {
+			if strings.HasPrefix(s[i:], op) {
+				tokens = append(tokens, exprToken{typ: exprTokenOp, val: op, pos: i})
+				i += len(op)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	tokens = append(tokens, exprToken{typ: exprTokenEOF, pos: len(s)})
+
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func parseWhenExpression(s string) (expr, error) {
+	tokens, err := tokenizeWhenExpression(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "wrong expression %q", s)
+	}
+
+	p := &exprParser{tokens: tokens}
+	e, err := p.parseOr()
+	if err == nil && p.peek().typ != exprTokenEOF {
+		err = errors.Errorf("unexpected %q at position %d", p.peek().val, p.peek().pos)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "wrong expression %q", s)
+	}
+
+	return e, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.typ != exprTokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) acceptOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.typ != exprTokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.val == op {
+			p.next()
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp("||"); !ok {
+			return l, nil
+		}
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "||", l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp("&&"); !ok {
+			return l, nil
+		}
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "&&", l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	if _, ok := p.acceptOp("!"); ok {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{e: e}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (expr, error) {
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.acceptOp("==", "!=", "=~", "!~")
+	if !ok {
+		return l, nil
+	}
+
+	if op == "=~" || op == "!~" {
+		t := p.next()
+		if t.typ != exprTokenString {
+			return nil, errors.Errorf("expected regular expression string after %q at position %d", op, t.pos)
+		}
+		re, err := regexp.Compile(t.val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "wrong regular expression %q", t.val)
+		}
+		return &binaryExpr{op: op, l: l, re: re}, nil
+	}
+
+	r, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryExpr{op: op, l: l, r: r}, nil
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.typ {
+	case exprTokenString:
+		return &literalExpr{v: exprValue{s: t.val}}, nil
+
+	case exprTokenIdent:
+		switch t.val {
+		case "true":
+			return &literalExpr{v: boolValue(true)}, nil
+		case "false":
+			return &literalExpr{v: boolValue(false)}, nil
+		}
+		parts := strings.SplitN(t.val, ".", 2)
+		if len(parts) != 2 || (parts[0] != "annotations" && parts[0] != "variables") || parts[1] == "" {
+			return nil, errors.Errorf("unknown reference %q at position %d, expected annotations.NAME or variables.NAME", t.val, t.pos)
+		}
+		return &refExpr{kind: parts[0], name: parts[1]}, nil
+
+	case exprTokenOp:
+		if t.val == "(" {
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.acceptOp(")"); !ok {
+				return nil, errors.Errorf("expected \")\" at position %d", p.peek().pos)
+			}
+			return e, nil
+		}
+		return nil, errors.Errorf("unexpected %q at position %d", t.val, t.pos)
+
+	default:
+		return nil, errors.Errorf("unexpected end of expression")
+	}
+}
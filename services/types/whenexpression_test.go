@@ -0,0 +1,133 @@
+// Copyright 2022 Sorint.lab
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package types
+
+import (
+	"testing"
+)
+
+func TestMatchWhenExpression(t *testing.T) {
+	annotations := map[string]string{
+		"ref_type":        "pull_request",
+		"branch":          "master",
+		"pull_request_id": "12",
+	}
+	variables := map[string]string{
+		"deploy": "true",
+		"env":    "staging",
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		out        bool
+	}{
+		{
+			name:       "test empty expression, should always match",
+			expression: "",
+			out:        true,
+		},
+		{
+			name:       "test annotation and variable comparison",
+			expression: "annotations.pull_request_id != '' && variables.deploy == 'true'",
+			out:        true,
+		},
+		{
+			name:       "test undefined reference is an empty string",
+			expression: `annotations.tag == ""`,
+			out:        true,
+		},
+		{
+			name:       "test undefined reference is false",
+			expression: "variables.notdefined",
+			out:        false,
+		},
+		{
+			name:       "test defined reference is true",
+			expression: "annotations.branch",
+			out:        true,
+		},
+		{
+			name:       "test not equal",
+			expression: "variables.env != 'staging'",
+			out:        false,
+		},
+		{
+			name:       "test or",
+			expression: "variables.env == 'production' || annotations.branch == 'master'",
+			out:        true,
+		},
+		{
+			name:       "test and has precedence over or",
+			expression: "true || false && false",
+			out:        true,
+		},
+		{
+			name:       "test parentheses",
+			expression: "(true || false) && false",
+			out:        false,
+		},
+		{
+			name:       "test not",
+			expression: "!(annotations.ref_type == 'branch')",
+			out:        true,
+		},
+		{
+			name:       "test regexp match",
+			expression: `annotations.branch =~ '^(master|release-.*)$'`,
+			out:        true,
+		},
+		{
+			name:       "test regexp not match",
+			expression: `variables.env !~ 'stag'`,
+			out:        false,
+		},
+		{
+			name:       "test bool compared to string",
+			expression: "(variables.env == 'staging') == 'true'",
+			out:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := MatchWhenExpression(&When{Expression: tt.expression}, annotations, variables)
+			if tt.out != out {
+				t.Fatalf("expected match: %t, got: %t", tt.out, out)
+			}
+		})
+	}
+}
+
+func TestValidateWhenExpression(t *testing.T) {
+	tests := []string{
+		"annotations.branch ==",
+		"variables.deploy == 'true",
+		"(true || false",
+		"true false",
+		"branch == 'master'",
+		"annotations. == 'master'",
+		"variables.deploy == 'true' & true",
+		"annotations.branch =~ annotations.tag",
+		"annotations.branch =~ '('",
+	}
+
+	for _, expression := range tests {
+		t.Run(expression, func(t *testing.T) {
+			if err := ValidateWhenExpression(expression); err == nil {
+				t.Fatalf("expected error for expression %q", expression)
+			}
+		})
+	}
+}
@@ -124,22 +124,22 @@ func shutdownGitea(tgitea *testutil.TestGitea) {
 }
 
 func startAgola(ctx context.Context, t *testing.T, log zerolog.Logger, dir string, c *config.Config) (<-chan error, error) {
-	rs, err := rsscheduler.NewRunservice(ctx, log, &c.Runservice)
+	rs, err := rsscheduler.NewRunservice(ctx, log, c)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to start run service scheduler")
 	}
 
-	ex, err := executor.NewExecutor(ctx, log, &c.Executor)
+	ex, err := executor.NewExecutor(ctx, log, c)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to start run service executor")
 	}
 
-	cs, err := configstore.NewConfigstore(ctx, log, &c.Configstore)
+	cs, err := configstore.NewConfigstore(ctx, log, c)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to start config store")
 	}
 
-	sched, err := scheduler.NewScheduler(ctx, log, &c.Scheduler)
+	sched, err := scheduler.NewScheduler(ctx, log, c)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to start scheduler")
 	}
@@ -154,7 +154,7 @@ func startAgola(ctx context.Context, t *testing.T, log zerolog.Logger, dir strin
 		return nil, errors.Wrapf(err, "failed to start gateway")
 	}
 
-	gs, err := gitserver.NewGitserver(ctx, log, &c.Gitserver)
+	gs, err := gitserver.NewGitserver(ctx, log, c)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to start git server")
 	}
@@ -208,8 +208,11 @@ func setup(ctx context.Context, t *testing.T, dir string, gitea bool) (*testutil
 			AdminToken: "admintoken",
 		},
 		Scheduler: config.Scheduler{
-			Debug:         false,
-			RunserviceURL: "",
+			Debug:           false,
+			RunserviceURL:   "",
+			ConfigstoreURL:  "",
+			GatewayURL:      "",
+			GitPollInterval: 1 * time.Minute,
 		},
 		Notification: config.Notification{
 			Debug:          false,
@@ -327,6 +330,8 @@ func setup(ctx context.Context, t *testing.T, dir string, gitea bool) (*testutil
 	c.Gateway.GitserverURL = gitServerURL
 
 	c.Scheduler.RunserviceURL = rsURL
+	c.Scheduler.ConfigstoreURL = csURL
+	c.Scheduler.GatewayURL = gwURL
 
 	c.Notification.WebExposedURL = gwURL
 	c.Notification.RunserviceURL = rsURL
@@ -358,6 +363,16 @@ func TestCreateLinkedAccount(t *testing.T) {
 	defer shutdownGitea(tgitea)
 
 	createLinkedAccount(ctx, t, tgitea, c)
+
+	gwClient := gwclient.NewClient(c.Gateway.APIExposedURL, "admintoken")
+	user, _, err := gwClient.GetUser(ctx, agolaUser01)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// the user profile should be synced from the linked account remote user
+	if user.AvatarURL == "" {
+		t.Fatalf("expected user avatar url to be set")
+	}
 }
 
 func createAgolaUserToken(ctx context.Context, t *testing.T, c *config.Config) string {
@@ -487,6 +502,64 @@ func TestUpdateProject(t *testing.T) {
 	}
 }
 
+func TestProjectTags(t *testing.T) {
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tgitea, c := setup(ctx, t, dir, true)
+	defer shutdownGitea(tgitea)
+
+	giteaAPIURL := fmt.Sprintf("http://%s:%s", tgitea.HTTPListenAddress, tgitea.HTTPPort)
+
+	giteaToken, token := createLinkedAccount(ctx, t, tgitea, c)
+
+	giteaClient := gitea.NewClient(giteaAPIURL, giteaToken)
+	gwClient := gwclient.NewClient(c.Gateway.APIExposedURL, token)
+
+	giteaRepo, project := createProject(ctx, t, giteaClient, gwClient)
+
+	if err := giteaClient.SetRepoTopics(giteaUser01, giteaRepo.Name, []string{"golang", "backend"}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	project, _, err := gwClient.UpdateProject(ctx, project.ID, &gwapitypes.UpdateProjectRequest{
+		Tags:             &[]string{"team01"},
+		ImportRepoTopics: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	expectedTags := []string{"backend", "golang", "team01"}
+	if !util.CompareStringSlice(project.Tags, expectedTags) {
+		t.Fatalf("expected tags %v, got %v", expectedTags, project.Tags)
+	}
+
+	projects, _, err := gwClient.GetProjectGroupProjects(ctx, path.Join("user", agolaUser01), []string{"golang", "team01"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(projects) != 1 {
+		t.Fatalf("expected 1 project, got %d projects", len(projects))
+	}
+
+	projects, _, err = gwClient.GetProjectGroupProjects(ctx, path.Join("user", agolaUser01), []string{"team02"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(projects) != 0 {
+		t.Fatalf("expected 0 projects, got %d projects", len(projects))
+	}
+
+	if _, _, err := gwClient.UpdateProject(ctx, project.ID, &gwapitypes.UpdateProjectRequest{
+		Tags: &[]string{"Invalid Tag"},
+	}); err == nil {
+		t.Fatalf("expected error updating project with invalid tag")
+	}
+}
+
 func createProject(ctx context.Context, t *testing.T, giteaClient *gitea.Client, gwClient *gwclient.Client) (*gitea.Repository, *gwapitypes.ProjectResponse) {
 	giteaRepo, err := giteaClient.CreateRepo(gitea.CreateRepoOption{
 		Name:    "repo01",
@@ -782,7 +855,7 @@ func TestPush(t *testing.T) {
 			push(t, tt.config, giteaRepo.CloneURL, giteaToken, tt.message, false)
 
 			_ = testutil.Wait(30*time.Second, func() (bool, error) {
-				runs, _, err := gwClient.GetProjectRuns(ctx, project.ID, nil, nil, 0, 0, false)
+				runs, _, err := gwClient.GetProjectRuns(ctx, project.ID, nil, nil, nil, 0, 0, false)
 				if err != nil {
 					return false, nil
 				}
@@ -798,7 +871,7 @@ func TestPush(t *testing.T) {
 				return true, nil
 			})
 
-			runs, _, err := gwClient.GetProjectRuns(ctx, project.ID, nil, nil, 0, 0, false)
+			runs, _, err := gwClient.GetProjectRuns(ctx, project.ID, nil, nil, nil, 0, 0, false)
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
@@ -970,7 +1043,7 @@ func TestDirectRun(t *testing.T) {
 			directRun(t, dir, config, ConfigFormatJsonnet, c.Gateway.APIExposedURL, token, tt.args...)
 
 			_ = testutil.Wait(30*time.Second, func() (bool, error) {
-				runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, 0, 0, false)
+				runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, nil, 0, 0, false)
 				if err != nil {
 					return false, nil
 				}
@@ -987,7 +1060,7 @@ func TestDirectRun(t *testing.T) {
 				return true, nil
 			})
 
-			runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, 0, 0, false)
+			runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, nil, 0, 0, false)
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
@@ -1118,7 +1191,7 @@ func TestDirectRunVariables(t *testing.T) {
 
 			// TODO(sgotti) add an util to wait for a run phase
 			_ = testutil.Wait(30*time.Second, func() (bool, error) {
-				runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, 0, 0, false)
+				runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, nil, 0, 0, false)
 				if err != nil {
 					return false, nil
 				}
@@ -1135,7 +1208,7 @@ func TestDirectRunVariables(t *testing.T) {
 				return true, nil
 			})
 
-			runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, 0, 0, false)
+			runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, nil, 0, 0, false)
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
@@ -1165,7 +1238,7 @@ func TestDirectRunVariables(t *testing.T) {
 				}
 			}
 
-			resp, err := gwClient.GetUserLogs(ctx, user.ID, run.Number, task.ID, false, 1, false)
+			resp, err := gwClient.GetUserLogs(ctx, user.ID, run.Number, task.ID, false, 1, false, nil)
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
@@ -1282,7 +1355,7 @@ func TestDirectRunLogs(t *testing.T) {
 			directRun(t, dir, config, ConfigFormatJsonnet, c.Gateway.APIExposedURL, token)
 
 			_ = testutil.Wait(30*time.Second, func() (bool, error) {
-				runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, 0, 0, false)
+				runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, nil, 0, 0, false)
 				if err != nil {
 					return false, nil
 				}
@@ -1299,7 +1372,7 @@ func TestDirectRunLogs(t *testing.T) {
 				return true, nil
 			})
 
-			runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, 0, 0, false)
+			runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, nil, 0, 0, false)
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
@@ -1347,7 +1420,7 @@ func TestDirectRunLogs(t *testing.T) {
 			if tt.delete {
 				_, err = gwClient.DeleteUserLogs(ctx, user.ID, run.Number, task.ID, tt.setup, tt.step)
 			} else {
-				_, err = gwClient.GetUserLogs(ctx, user.ID, run.Number, task.ID, tt.setup, tt.step, false)
+				_, err = gwClient.GetUserLogs(ctx, user.ID, run.Number, task.ID, tt.setup, tt.step, false, nil)
 			}
 
 			if err != nil {
@@ -1366,6 +1439,161 @@ func TestDirectRunLogs(t *testing.T) {
 	}
 }
 
+func TestDirectRunLogsRange(t *testing.T) {
+	config := `
+      {
+        runs: [
+          {
+            name: 'run01',
+            tasks: [
+              {
+                name: 'task01',
+                runtime: {
+                  containers: [
+                    {
+                      image: 'alpine/git',
+                    },
+                  ],
+                },
+                steps: [
+                  { type: 'clone' },
+                  { type: 'run', command: 'printf "line1\\nline2\\nline3\\nline4\\nline5\\n"' },
+                ],
+              },
+            ],
+          },
+        ],
+      }
+    `
+
+	tests := []struct {
+		name      string
+		logsRange *gwapitypes.LogsRange
+		out       string
+	}{
+		{
+			name: "test get log without range",
+			out:  "line1\nline2\nline3\nline4\nline5\n",
+		},
+		{
+			name:      "test get log with lines range",
+			logsRange: &gwapitypes.LogsRange{Unit: gwapitypes.LogsRangeUnitLine, Offset: 1, Limit: 2},
+			out:       "line2\nline3\n",
+		},
+		{
+			name:      "test get log with lines offset",
+			logsRange: &gwapitypes.LogsRange{Unit: gwapitypes.LogsRangeUnitLine, Offset: 3},
+			out:       "line4\nline5\n",
+		},
+		{
+			name:      "test get log with bytes range",
+			logsRange: &gwapitypes.LogsRange{Unit: gwapitypes.LogsRangeUnitByte, Offset: 6, Limit: 5},
+			out:       "line2",
+		},
+	}
+
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, c := setup(ctx, t, dir, false)
+
+	gwClient := gwclient.NewClient(c.Gateway.APIExposedURL, "admintoken")
+	user, _, err := gwClient.CreateUser(ctx, &gwapitypes.CreateUserRequest{UserName: agolaUser01})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	t.Logf("created agola user: %s", user.UserName)
+
+	token := createAgolaUserToken(ctx, t, c)
+
+	// From now use the user token
+	gwClient = gwclient.NewClient(c.Gateway.APIExposedURL, token)
+
+	directRun(t, dir, config, ConfigFormatJsonnet, c.Gateway.APIExposedURL, token)
+
+	_ = testutil.Wait(30*time.Second, func() (bool, error) {
+		runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, nil, 0, 0, false)
+		if err != nil {
+			return false, nil
+		}
+
+		if len(runs) != 1 {
+			return false, nil
+		}
+
+		run := runs[0]
+		if run.Phase != rstypes.RunPhaseFinished {
+			return false, nil
+		}
+
+		return true, nil
+	})
+
+	runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, nil, 0, 0, false)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 run got: %d", len(runs))
+	}
+
+	run, _, err := gwClient.GetUserRun(ctx, user.ID, runs[0].Number)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if run.Result != rstypes.RunResultSuccess {
+		t.Fatalf("expected run result %q, got %q", rstypes.RunResultSuccess, run.Result)
+	}
+
+	var task *gwapitypes.RunResponseTask
+	for _, t := range run.Tasks {
+		if t.Name == "task01" {
+			task = t
+			break
+		}
+	}
+
+	_ = testutil.Wait(30*time.Second, func() (bool, error) {
+		t, _, err := gwClient.GetUserRunTask(ctx, user.ID, runs[0].Number, task.ID)
+		if err != nil {
+			return false, nil
+		}
+		if !t.Steps[1].LogArchived {
+			return false, nil
+		}
+		return true, nil
+	})
+
+	logsInfo, _, err := gwClient.GetUserLogsInfo(ctx, user.ID, run.Number, task.ID, false, 1)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	expectedLogsInfo := &gwapitypes.LogsInfoResponse{Size: 30, Lines: 5, Complete: true}
+	if diff := cmp.Diff(expectedLogsInfo, logsInfo); diff != "" {
+		t.Fatalf("logs info mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := gwClient.GetUserLogs(ctx, user.ID, run.Number, task.ID, false, 1, false, tt.logsRange)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			defer resp.Body.Close()
+
+			logs, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if string(logs) != tt.out {
+				t.Fatalf("got logs %q, want %q", logs, tt.out)
+			}
+		})
+	}
+}
+
 func TestPullRequest(t *testing.T) {
 	config := `
        {
@@ -1583,7 +1811,7 @@ func TestPullRequest(t *testing.T) {
 				}
 			}
 			_ = testutil.Wait(30*time.Second, func() (bool, error) {
-				runs, _, err := gwClient.GetProjectRuns(ctx, project.ID, nil, nil, 0, 0, false)
+				runs, _, err := gwClient.GetProjectRuns(ctx, project.ID, nil, nil, nil, 0, 0, false)
 				if err != nil {
 					return false, nil
 				}
@@ -1599,7 +1827,7 @@ func TestPullRequest(t *testing.T) {
 				return true, nil
 			})
 
-			runs, _, err := gwClient.GetProjectRuns(ctx, project.ID, nil, nil, 0, 0, false)
+			runs, _, err := gwClient.GetProjectRuns(ctx, project.ID, nil, nil, nil, 0, 0, false)
 			if err != nil {
 				t.Fatalf("unexpected err: %v", err)
 			}
@@ -1627,7 +1855,7 @@ func TestPullRequest(t *testing.T) {
 				if run.Result != rstypes.RunResultSuccess {
 					t.Fatalf("expected run result %q, got %q", rstypes.RunResultSuccess, run.Result)
 				}
-				resp, err := gwClient.GetProjectLogs(ctx, project.ID, run.Number, task.ID, false, 1, false)
+				resp, err := gwClient.GetProjectLogs(ctx, project.ID, run.Number, task.ID, false, 1, false, nil)
 				if err != nil {
 					t.Fatalf("failed to get log: %v", err)
 				}
@@ -1791,7 +2019,7 @@ def main(ctx):
 
 				// TODO(sgotti) add an util to wait for a run phase
 				_ = testutil.Wait(30*time.Second, func() (bool, error) {
-					runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, 0, 0, false)
+					runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, nil, 0, 0, false)
 					if err != nil {
 						return false, nil
 					}
@@ -1808,7 +2036,7 @@ def main(ctx):
 					return true, nil
 				})
 
-				runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, 0, 0, false)
+				runs, _, err := gwClient.GetUserRuns(ctx, user.ID, nil, nil, nil, 0, 0, false)
 				if err != nil {
 					t.Fatalf("unexpected err: %v", err)
 				}
@@ -1838,7 +2066,7 @@ def main(ctx):
 					}
 				}
 
-				resp, err := gwClient.GetUserLogs(ctx, user.ID, run.Number, task.ID, false, 1, false)
+				resp, err := gwClient.GetUserLogs(ctx, user.ID, run.Number, task.ID, false, 1, false, nil)
 				if err != nil {
 					t.Fatalf("unexpected err: %v", err)
 				}
@@ -1928,3 +2156,55 @@ func TestUserOrgs(t *testing.T) {
 		t.Fatalf("user orgs mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestOrgUsage(t *testing.T) {
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tgitea, c := setup(ctx, t, dir, true)
+	defer shutdownGitea(tgitea)
+
+	gwClient := gwclient.NewClient(c.Gateway.APIExposedURL, "admintoken")
+
+	org01, _, err := gwClient.CreateOrg(ctx, &gwapitypes.CreateOrgRequest{Name: "org01", Visibility: gwapitypes.VisibilityPublic})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	_, token := createLinkedAccount(ctx, t, tgitea, c)
+
+	_, _, err = gwClient.AddOrgMember(ctx, "org01", giteaUser01, gwapitypes.MemberRoleMember)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	gwClientNew := gwclient.NewClient(c.Gateway.APIExposedURL, token)
+
+	// only org owners can get the org usage
+	if _, _, err := gwClientNew.GetOrgUsage(ctx, "org01", time.Time{}, time.Time{}); err == nil {
+		t.Fatalf("expected error getting org usage as org member")
+	}
+
+	endTime := time.Now().Truncate(time.Second).UTC()
+	startTime := endTime.Add(-24 * time.Hour)
+	usage, _, err := gwClient.GetOrgUsage(ctx, "org01", startTime, endTime)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	expectedUsage := &gwapitypes.OrgUsageResponse{
+		Organization: &gwapitypes.OrgResponse{ID: org01.ID, Name: "org01", Visibility: gwapitypes.VisibilityPublic},
+		StartTime:    startTime,
+		EndTime:      endTime,
+		Projects:     []*gwapitypes.ProjectUsageResponse{},
+	}
+	if diff := cmp.Diff(expectedUsage, usage); diff != "" {
+		t.Fatalf("org usage mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, _, err := gwClient.GetOrgUsage(ctx, "org01", endTime, startTime); err == nil {
+		t.Fatalf("expected error with start time after end time")
+	}
+}